@@ -10,6 +10,12 @@ import (
 
 type ServiceConfig struct {
 	ControllerBoardStatus ControllerBoardStatusConfig
+	Reports               ReportsConfig
+	Notifications         NotificationsConfig
+	Heartbeat             HeartbeatConfig
+	DoorSensor            DoorSensorConfig
+	Thresholds            ThresholdsConfig
+	Warmup                WarmupConfig
 }
 
 // ControllerBoardStatusConfig is a data structure that holds the
@@ -36,6 +42,108 @@ type ControllerBoardStatusConfig struct {
 	SubscriptionAdminState                            string
 }
 
+// ReportsConfig holds the settings of the scheduled reports. Unlike the
+// ControllerBoardStatus settings every value is optional, and a report
+// without a schedule is not generated.
+type ReportsConfig struct {
+	// SalesReportEndpoint is the ledger service's /reports/sales API
+	// endpoint, which the daily sales report is built from
+	SalesReportEndpoint string
+	// InventoryEndpoint is the inventory service's /inventory API endpoint,
+	// which the low stock report is built from
+	InventoryEndpoint string
+	// ObjectStorageURL is the bucket URL that reports delivered to object
+	// storage are uploaded to with HTTP PUT
+	ObjectStorageURL string
+	// TimeZone is the IANA time zone of the kiosk, such as America/Chicago,
+	// that the schedules and report times are in. Empty is UTC.
+	TimeZone              string
+	DailySales            ReportSchedule
+	LowStock              ReportSchedule
+	TemperatureCompliance ReportSchedule
+}
+
+// NotificationsConfig holds the settings of the notification dispatcher,
+// which sends the notifications in the background and retries the failed
+// ones. Every value is optional and has a default.
+type NotificationsConfig struct {
+	// QueueFile is the JSON file that the queued and dead-lettered
+	// notifications are kept in, so that they survive a restart
+	QueueFile string
+	// MaxAttempts is how many times a notification is sent before it is
+	// moved to the dead letters
+	MaxAttempts int
+	// RetryIntervalDuration is how long to wait before the first retry, it
+	// doubles with every further retry
+	RetryIntervalDuration string
+	// MaxRetryIntervalDuration caps the wait between retries
+	MaxRetryIntervalDuration string
+}
+
+// HeartbeatConfig holds the settings of the heartbeat this service
+// publishes to the message bus, which the fleet tracks to detect kiosks
+// that have gone silent
+type HeartbeatConfig struct {
+	// KioskID identifies the kiosk in its heartbeats, and must match the
+	// KioskID of its as-vending service
+	KioskID string
+	// IntervalDuration is how often the heartbeat is published, empty
+	// disables publishing
+	IntervalDuration string
+}
+
+// DoorSensorConfig holds the settings of the door sensor checks, which
+// debounce the door readings and detect a sensor that cannot be trusted.
+// Every value is optional, and an empty value turns its check off.
+type DoorSensorConfig struct {
+	// DebounceDuration is how long a changed door state must be read before
+	// it is reported to the vending service
+	DebounceDuration string
+	// FlapThreshold is how many door state changes within the
+	// FlapWindowDuration mark the sensor suspect
+	FlapThreshold      int
+	FlapWindowDuration string
+	// StuckOpenDuration is how long the door may be reported open before
+	// the sensor is suspect
+	StuckOpenDuration string
+	// FailSafe is what a suspect sensor does to the vend workflow:
+	// maintenance takes the kiosk out of service until the sensor
+	// recovers, and alert only sends a notification. Empty is maintenance.
+	FailSafe string
+}
+
+// ThresholdsConfig holds the settings of the thresholds that operators
+// adjust at runtime through the /status/thresholds API. Every value is
+// optional.
+type ThresholdsConfig struct {
+	// File is the JSON file that the adjusted thresholds are kept in, which
+	// override the configured thresholds across restarts. Empty keeps them
+	// in memory.
+	File string
+	// MinHumidity and MaxHumidity are the relative humidity range, in
+	// percent, outside of which a notification is sent. Both 0 turn the
+	// check off.
+	MinHumidity float64
+	MaxHumidity float64
+}
+
+// WarmupConfig holds the settings of the warmup after power-on, which holds
+// vending until the cooler has cooled down
+type WarmupConfig struct {
+	// Duration is how long the temperature must be within its thresholds
+	// before vending is allowed after power-on. Empty turns the warmup off.
+	Duration string
+}
+
+// ReportSchedule is when a report is generated and where it is delivered
+type ReportSchedule struct {
+	// Schedule is a cron expression in the kiosk's TimeZone, empty
+	// disables the report
+	Schedule string
+	// Delivery is a comma-separated list of notification and objectstorage
+	Delivery string
+}
+
 // UpdateFromRaw updates the service's full configuration from raw data received from
 // the Service Provider.
 func (c *ServiceConfig) UpdateFromRaw(rawConfig interface{}) bool {
@@ -0,0 +1,115 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"sync"
+	"time"
+)
+
+// TemperatureCompliance is the content of the temperature compliance report.
+// A reading is out of range when it reaches the minimum or maximum
+// temperature threshold, the same as the average temperature that raises
+// the threshold notifications.
+type TemperatureCompliance struct {
+	From                    string  `json:"from"`
+	To                      string  `json:"to"`
+	MinTemperatureThreshold float64 `json:"minTemperatureThreshold"`
+	MaxTemperatureThreshold float64 `json:"maxTemperatureThreshold"`
+	Readings                int     `json:"readings"`
+	OutOfRangeReadings      int     `json:"outOfRangeReadings"`
+	// Excursions counts the times the temperature went out of range, and
+	// SecondsOutOfRange how long it stayed out of range in total
+	Excursions        int     `json:"excursions"`
+	SecondsOutOfRange float64 `json:"secondsOutOfRange"`
+	// CompliancePercent is the percentage of readings that were in range
+	CompliancePercent  float64 `json:"compliancePercent"`
+	MinTemperature     float64 `json:"minTemperature"`
+	MaxTemperature     float64 `json:"maxTemperature"`
+	AverageTemperature float64 `json:"averageTemperature"`
+}
+
+// TemperatureComplianceTracker keeps the temperature readings statistics of
+// the current report period. It is safe for concurrent use, since readings
+// come in on the functions pipeline while reports are generated on the
+// report scheduler.
+type TemperatureComplianceTracker struct {
+	mutex          sync.Mutex
+	compliance     TemperatureCompliance
+	from           time.Time
+	sum            float64
+	lastReadingAt  time.Time
+	lastOutOfRange bool
+	outOfRange     time.Duration
+}
+
+// NewTemperatureComplianceTracker starts a report period at the given time
+func NewTemperatureComplianceTracker(from time.Time) *TemperatureComplianceTracker {
+	return &TemperatureComplianceTracker{from: from}
+}
+
+// Record adds a temperature reading to the report period. The time between
+// two out of range readings counts as out of range. A nil tracker ignores
+// the reading, so that the reports can be turned off.
+func (tracker *TemperatureComplianceTracker) Record(temperature float64, minThreshold float64, maxThreshold float64, at time.Time) {
+	if tracker == nil {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	compliance := &tracker.compliance
+	compliance.MinTemperatureThreshold = minThreshold
+	compliance.MaxTemperatureThreshold = maxThreshold
+	if compliance.Readings == 0 || temperature < compliance.MinTemperature {
+		compliance.MinTemperature = temperature
+	}
+	if compliance.Readings == 0 || temperature > compliance.MaxTemperature {
+		compliance.MaxTemperature = temperature
+	}
+	compliance.Readings++
+	tracker.sum += temperature
+
+	outOfRange := temperature >= maxThreshold || temperature <= minThreshold
+	if outOfRange {
+		compliance.OutOfRangeReadings++
+		if tracker.lastOutOfRange {
+			tracker.outOfRange += at.Sub(tracker.lastReadingAt)
+		} else {
+			compliance.Excursions++
+		}
+	}
+	tracker.lastOutOfRange = outOfRange
+	tracker.lastReadingAt = at
+}
+
+// Report returns the compliance of the report period that ends at the given
+// time, and starts the next one
+func (tracker *TemperatureComplianceTracker) Report(to time.Time) TemperatureCompliance {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	// an excursion still going on counts as out of range until the end of
+	// the period, and carries over to the next one
+	if tracker.lastOutOfRange && to.After(tracker.lastReadingAt) {
+		tracker.outOfRange += to.Sub(tracker.lastReadingAt)
+		tracker.lastReadingAt = to
+	}
+
+	compliance := tracker.compliance
+	compliance.From = tracker.from.UTC().Format(time.RFC3339)
+	compliance.To = to.UTC().Format(time.RFC3339)
+	compliance.SecondsOutOfRange = tracker.outOfRange.Seconds()
+	compliance.CompliancePercent = 100
+	if compliance.Readings > 0 {
+		compliance.AverageTemperature = tracker.sum / float64(compliance.Readings)
+		compliance.CompliancePercent = 100 * float64(compliance.Readings-compliance.OutOfRangeReadings) / float64(compliance.Readings)
+	}
+
+	tracker.compliance = TemperatureCompliance{}
+	tracker.from = to
+	tracker.sum = 0
+	tracker.outOfRange = 0
+	return compliance
+}
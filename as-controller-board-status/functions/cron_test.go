@@ -0,0 +1,92 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// a Wednesday
+	after := time.Date(2023, time.March, 15, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		Name       string
+		Expression string
+		Expected   time.Time
+	}{
+		{"Every minute", "* * * * *", time.Date(2023, time.March, 15, 10, 31, 0, 0, time.UTC)},
+		{"Daily shorthand", "@daily", time.Date(2023, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"Hourly shorthand", "@hourly", time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"Weekly shorthand", "@weekly", time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"Monthly shorthand", "@monthly", time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"Later today", "45 10 * * *", time.Date(2023, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"Tomorrow", "0 6 * * *", time.Date(2023, time.March, 16, 6, 0, 0, 0, time.UTC)},
+		{"Step", "*/20 * * * *", time.Date(2023, time.March, 15, 10, 40, 0, 0, time.UTC)},
+		{"Step from a value", "5/20 * * * *", time.Date(2023, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"List", "0 8,20 * * *", time.Date(2023, time.March, 15, 20, 0, 0, 0, time.UTC)},
+		{"Weekdays", "0 7 * * 1-5", time.Date(2023, time.March, 16, 7, 0, 0, 0, time.UTC)},
+		{"Sunday as 7", "0 7 * * 7", time.Date(2023, time.March, 19, 7, 0, 0, 0, time.UTC)},
+		{"Day of month", "0 0 31 * *", time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{"Day of month skips short months", "0 0 31 4-12 *", time.Date(2023, time.May, 31, 0, 0, 0, 0, time.UTC)},
+		{"Day of month or day of week", "0 0 1 * 5", time.Date(2023, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"Leap day", "0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"Never", "0 0 30 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			schedule, err := ParseCron(currentTest.Expression)
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, schedule.Next(after))
+		})
+	}
+}
+
+func TestCronScheduleNextTimeZone(t *testing.T) {
+	chicago, err := LoadTimeZone("America/Chicago")
+	require.NoError(t, err)
+	schedule, err := ParseCron("0 6 * * *")
+	require.NoError(t, err)
+
+	// 6am in Chicago is 11am UTC during daylight saving time
+	after := time.Date(2023, time.March, 15, 10, 30, 0, 0, time.UTC)
+	assert.True(t, time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC).Equal(schedule.Next(after.In(chicago))))
+
+	_, err = LoadTimeZone("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Expression    string
+		ExpectedError string
+	}{
+		{"Too few fields", "0 0 * *", "must have 5 fields"},
+		{"Unknown shorthand", "@yearly", "must have 5 fields"},
+		{"Minute out of range", "60 * * * *", "invalid minute"},
+		{"Hour out of range", "0 24 * * *", "invalid hour"},
+		{"Day of month zero", "0 0 0 * *", "invalid day of month"},
+		{"Month out of range", "0 0 * 13 *", "invalid month"},
+		{"Day of week out of range", "0 0 * * 8", "invalid day of week"},
+		{"Not a number", "a * * * *", `"a" is not a number`},
+		{"Reversed range", "0 0 * * 5-1", "must be between 0 and 7"},
+		{"Zero step", "*/0 * * * *", "must be a positive number"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			_, err := ParseCron(currentTest.Expression)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), currentTest.ExpectedError)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"fmt"
+	"os"
+)
+
+// ValidateDataDirectory creates the directory when it does not exist yet
+// and checks that the service can write to it, so that a directory that
+// cannot be used fails the start of the service rather than its first
+// write
+func ValidateDataDirectory(directory string) error {
+	if directory == "" {
+		directory = "."
+	}
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory %s: %s", directory, err.Error())
+	}
+	probe, err := os.CreateTemp(directory, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("data directory %s is not writable: %s", directory, err.Error())
+	}
+	_ = probe.Close()
+	return os.Remove(probe.Name())
+}
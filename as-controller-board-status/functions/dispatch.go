@@ -0,0 +1,332 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultNotificationQueueFile        = "notification-queue.json"
+	defaultNotificationMaxAttempts      = 10
+	defaultNotificationRetryInterval    = 10 * time.Second
+	defaultNotificationMaxRetryInterval = 10 * time.Minute
+)
+
+// ErrNotificationNotFound is returned when there is no dead letter with the
+// requested ID
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// QueuedNotification is a notification that is waiting to be sent, or that
+// was moved to the dead letters after its last attempt failed
+type QueuedNotification struct {
+	ID          string    `json:"id"`
+	Message     string    `json:"message"`
+	QueuedAt    time.Time `json:"queuedAt"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// NotificationQueue is the content of the queue file, and the response of
+// the GET /notifications API
+type NotificationQueue struct {
+	Pending     []QueuedNotification `json:"pending"`
+	DeadLetters []QueuedNotification `json:"deadLetters"`
+}
+
+// NotificationDispatcher sends notifications in the background, so that
+// processing a reading never waits on the EdgeX notification service. A
+// notification that fails is retried with a doubling interval, and after
+// its last attempt it is kept as a dead letter until an operator retries or
+// deletes it. The queue is written to a file on every change. A nil
+// NotificationDispatcher has no notifications.
+type NotificationDispatcher struct {
+	lc               logger.LoggingClient
+	send             func(message string) error
+	fileName         string
+	maxAttempts      int
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+	mutex            sync.Mutex
+	queue            NotificationQueue
+	// wake is signalled when a notification is queued
+	wake chan struct{}
+}
+
+// NewNotificationDispatcher validates the notifications configuration and
+// loads the notifications that were queued before a restart. send delivers
+// a single notification.
+func NewNotificationDispatcher(lc logger.LoggingClient, notificationsConfig config.NotificationsConfig, send func(message string) error) (*NotificationDispatcher, error) {
+	dispatcher := &NotificationDispatcher{
+		lc:               lc,
+		send:             send,
+		fileName:         notificationsConfig.QueueFile,
+		maxAttempts:      notificationsConfig.MaxAttempts,
+		retryInterval:    defaultNotificationRetryInterval,
+		maxRetryInterval: defaultNotificationMaxRetryInterval,
+		wake:             make(chan struct{}, 1),
+	}
+	if dispatcher.fileName == "" {
+		dispatcher.fileName = defaultNotificationQueueFile
+	}
+	if dispatcher.maxAttempts < 0 {
+		return nil, fmt.Errorf("MaxAttempts must not be negative, got %d", dispatcher.maxAttempts)
+	}
+	if dispatcher.maxAttempts == 0 {
+		dispatcher.maxAttempts = defaultNotificationMaxAttempts
+	}
+
+	var err error
+	if notificationsConfig.RetryIntervalDuration != "" {
+		dispatcher.retryInterval, err = time.ParseDuration(notificationsConfig.RetryIntervalDuration)
+		if err != nil || dispatcher.retryInterval <= 0 {
+			return nil, fmt.Errorf("RetryIntervalDuration must be a positive duration, got %q", notificationsConfig.RetryIntervalDuration)
+		}
+	}
+	if notificationsConfig.MaxRetryIntervalDuration != "" {
+		dispatcher.maxRetryInterval, err = time.ParseDuration(notificationsConfig.MaxRetryIntervalDuration)
+		if err != nil || dispatcher.maxRetryInterval <= 0 {
+			return nil, fmt.Errorf("MaxRetryIntervalDuration must be a positive duration, got %q", notificationsConfig.MaxRetryIntervalDuration)
+		}
+	}
+	if dispatcher.maxRetryInterval < dispatcher.retryInterval {
+		return nil, fmt.Errorf("MaxRetryIntervalDuration %v is shorter than RetryIntervalDuration %v", dispatcher.maxRetryInterval, dispatcher.retryInterval)
+	}
+
+	if err := ValidateDataDirectory(filepath.Dir(dispatcher.fileName)); err != nil {
+		return nil, fmt.Errorf("QueueFile must be in a writable directory: %s", err.Error())
+	}
+	queueJSON, err := os.ReadFile(dispatcher.fileName)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read the notification queue %s: %s", dispatcher.fileName, err.Error())
+	default:
+		if err := json.Unmarshal(queueJSON, &dispatcher.queue); err != nil {
+			return nil, fmt.Errorf("failed to parse the notification queue %s: %s", dispatcher.fileName, err.Error())
+		}
+	}
+	return dispatcher, nil
+}
+
+// Enqueue queues a notification to be sent now. The notification is kept in
+// memory when the queue file cannot be written.
+func (dispatcher *NotificationDispatcher) Enqueue(message string) {
+	now := time.Now()
+	dispatcher.mutex.Lock()
+	dispatcher.queue.Pending = append(dispatcher.queue.Pending, QueuedNotification{
+		ID:          uuid.NewString(),
+		Message:     message,
+		QueuedAt:    now,
+		NextAttempt: now,
+	})
+	dispatcher.save()
+	dispatcher.mutex.Unlock()
+
+	select {
+	case dispatcher.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Queue returns the pending notifications and the dead letters
+func (dispatcher *NotificationDispatcher) Queue() NotificationQueue {
+	queue := NotificationQueue{Pending: []QueuedNotification{}, DeadLetters: []QueuedNotification{}}
+	if dispatcher == nil {
+		return queue
+	}
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	queue.Pending = append(queue.Pending, dispatcher.queue.Pending...)
+	queue.DeadLetters = append(queue.DeadLetters, dispatcher.queue.DeadLetters...)
+	return queue
+}
+
+// RetryDeadLetter moves a dead letter back to the pending notifications,
+// with all of its attempts again
+func (dispatcher *NotificationDispatcher) RetryDeadLetter(id string) error {
+	if dispatcher == nil {
+		return ErrNotificationNotFound
+	}
+	dispatcher.mutex.Lock()
+	index := findNotification(dispatcher.queue.DeadLetters, id)
+	if index < 0 {
+		dispatcher.mutex.Unlock()
+		return ErrNotificationNotFound
+	}
+	notification := dispatcher.queue.DeadLetters[index]
+	notification.Attempts = 0
+	notification.NextAttempt = time.Now()
+	dispatcher.queue.DeadLetters = append(dispatcher.queue.DeadLetters[:index], dispatcher.queue.DeadLetters[index+1:]...)
+	dispatcher.queue.Pending = append(dispatcher.queue.Pending, notification)
+	dispatcher.save()
+	dispatcher.mutex.Unlock()
+
+	select {
+	case dispatcher.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// DeleteDeadLetter drops a dead letter
+func (dispatcher *NotificationDispatcher) DeleteDeadLetter(id string) error {
+	if dispatcher == nil {
+		return ErrNotificationNotFound
+	}
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	index := findNotification(dispatcher.queue.DeadLetters, id)
+	if index < 0 {
+		return ErrNotificationNotFound
+	}
+	dispatcher.queue.DeadLetters = append(dispatcher.queue.DeadLetters[:index], dispatcher.queue.DeadLetters[index+1:]...)
+	dispatcher.save()
+	return nil
+}
+
+// Run sends the pending notifications when they are due, until the context
+// is done
+func (dispatcher *NotificationDispatcher) Run(ctx context.Context) {
+	for {
+		next := dispatcher.dispatchDue(time.Now())
+
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			timer = time.After(time.Until(next))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-dispatcher.wake:
+		case <-timer:
+		}
+	}
+}
+
+// dispatchDue sends the notifications that are due in the order they were
+// queued, and returns when the next one is due, or the zero time when none
+// are pending
+func (dispatcher *NotificationDispatcher) dispatchDue(now time.Time) time.Time {
+	attempted := make(map[string]bool)
+	for {
+		dispatcher.mutex.Lock()
+		var notification *QueuedNotification
+		for i := range dispatcher.queue.Pending {
+			pending := dispatcher.queue.Pending[i]
+			if !attempted[pending.ID] && !pending.NextAttempt.After(now) {
+				notification = &pending
+				break
+			}
+		}
+		if notification == nil {
+			next := dispatcher.nextAttempt()
+			dispatcher.mutex.Unlock()
+			return next
+		}
+		dispatcher.mutex.Unlock()
+
+		// the notification service is called without holding the lock, so
+		// that notifications can be queued in the meantime
+		attempted[notification.ID] = true
+		err := dispatcher.send(notification.Message)
+
+		dispatcher.mutex.Lock()
+		dispatcher.recordAttempt(notification.ID, err, now)
+		dispatcher.mutex.Unlock()
+	}
+}
+
+// recordAttempt removes a sent notification, or schedules the retry of a
+// failed one and moves it to the dead letters after its last attempt. The
+// mutex must be held.
+func (dispatcher *NotificationDispatcher) recordAttempt(id string, sendErr error, now time.Time) {
+	index := findNotification(dispatcher.queue.Pending, id)
+	if index < 0 {
+		return
+	}
+	notification := dispatcher.queue.Pending[index]
+	dispatcher.queue.Pending = append(dispatcher.queue.Pending[:index], dispatcher.queue.Pending[index+1:]...)
+
+	if sendErr == nil {
+		dispatcher.save()
+		return
+	}
+	notification.Attempts++
+	notification.LastError = sendErr.Error()
+	if notification.Attempts >= dispatcher.maxAttempts {
+		dispatcher.lc.Errorf("Giving up on notification %s after %d attempts: %s", notification.ID, notification.Attempts, sendErr.Error())
+		dispatcher.queue.DeadLetters = append(dispatcher.queue.DeadLetters, notification)
+		dispatcher.save()
+		return
+	}
+	notification.NextAttempt = now.Add(dispatcher.retryDelay(notification.Attempts))
+	dispatcher.lc.Warnf("Failed to send notification %s, attempt %d of %d, retrying at %s: %s", notification.ID, notification.Attempts, dispatcher.maxAttempts, notification.NextAttempt.Format(time.RFC3339), sendErr.Error())
+	// the notification keeps its place in the queue
+	dispatcher.queue.Pending = append(dispatcher.queue.Pending[:index], append([]QueuedNotification{notification}, dispatcher.queue.Pending[index:]...)...)
+	dispatcher.save()
+}
+
+// retryDelay is how long to wait after a number of failed attempts, which
+// doubles with every attempt up to the maximum retry interval
+func (dispatcher *NotificationDispatcher) retryDelay(attempts int) time.Duration {
+	delay := dispatcher.retryInterval
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= dispatcher.maxRetryInterval {
+			return dispatcher.maxRetryInterval
+		}
+	}
+	return delay
+}
+
+// nextAttempt returns the earliest next attempt of the pending
+// notifications. The mutex must be held.
+func (dispatcher *NotificationDispatcher) nextAttempt() time.Time {
+	var next time.Time
+	for _, notification := range dispatcher.queue.Pending {
+		if next.IsZero() || notification.NextAttempt.Before(next) {
+			next = notification.NextAttempt
+		}
+	}
+	return next
+}
+
+// save writes the queue file. A failure is only logged, the notifications
+// are still sent, but are lost on a restart. The mutex must be held.
+func (dispatcher *NotificationDispatcher) save() {
+	queueJSON, err := json.MarshalIndent(dispatcher.queue, "", "  ")
+	if err == nil {
+		// the queue is written to a temporary file that replaces it, so
+		// that a crash never leaves a partial queue file behind
+		tempName := filepath.Join(filepath.Dir(dispatcher.fileName), "."+filepath.Base(dispatcher.fileName)+".tmp")
+		if err = os.WriteFile(tempName, queueJSON, 0644); err == nil {
+			err = os.Rename(tempName, dispatcher.fileName)
+		}
+	}
+	if err != nil {
+		dispatcher.lc.Errorf("Failed to write the notification queue %s: %s", dispatcher.fileName, err.Error())
+	}
+}
+
+func findNotification(notifications []QueuedNotification, id string) int {
+	for i, notification := range notifications {
+		if notification.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,169 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotificationService records the notifications sent to it, and fails
+// while it is down
+type fakeNotificationService struct {
+	mutex sync.Mutex
+	down  bool
+	sent  []string
+}
+
+func (service *fakeNotificationService) send(message string) error {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if service.down {
+		return errors.New("notification service unreachable")
+	}
+	service.sent = append(service.sent, message)
+	return nil
+}
+
+func TestNewNotificationDispatcher(t *testing.T) {
+	queueFile := filepath.Join(t.TempDir(), "queue.json")
+	notDirectory := filepath.Join(t.TempDir(), "not-a-directory")
+	require.NoError(t, os.WriteFile(notDirectory, nil, 0644))
+	tests := []struct {
+		Name          string
+		Config        config.NotificationsConfig
+		ExpectedError bool
+	}{
+		{"defaults", config.NotificationsConfig{QueueFile: queueFile}, false},
+		{"configured", config.NotificationsConfig{QueueFile: queueFile, MaxAttempts: 3, RetryIntervalDuration: "1s", MaxRetryIntervalDuration: "1m"}, false},
+		{"negative attempts", config.NotificationsConfig{QueueFile: queueFile, MaxAttempts: -1}, true},
+		{"invalid interval", config.NotificationsConfig{QueueFile: queueFile, RetryIntervalDuration: "often"}, true},
+		{"zero interval", config.NotificationsConfig{QueueFile: queueFile, RetryIntervalDuration: "0s"}, true},
+		{"max below interval", config.NotificationsConfig{QueueFile: queueFile, RetryIntervalDuration: "1m", MaxRetryIntervalDuration: "1s"}, true},
+		{"unreadable queue", config.NotificationsConfig{QueueFile: t.TempDir()}, true},
+		{"queue directory is a file", config.NotificationsConfig{QueueFile: filepath.Join(notDirectory, "queue.json")}, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			_, err := NewNotificationDispatcher(logger.NewMockClient(), currentTest.Config, nil)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// TestNotificationDispatcherRetry tests that a failed notification is
+// retried with a doubling interval, and becomes a dead letter after its
+// last attempt
+func TestNotificationDispatcherRetry(t *testing.T) {
+	service := &fakeNotificationService{down: true}
+	queueFile := filepath.Join(t.TempDir(), "queue.json")
+	dispatcher, err := NewNotificationDispatcher(logger.NewMockClient(), config.NotificationsConfig{
+		QueueFile:                queueFile,
+		MaxAttempts:              4,
+		RetryIntervalDuration:    "10s",
+		MaxRetryIntervalDuration: "15s",
+	}, service.send)
+	require.NoError(t, err)
+
+	dispatcher.Enqueue("door left open")
+	now := time.Now()
+
+	next := dispatcher.dispatchDue(now)
+	assert.Equal(t, now.Add(10*time.Second), next)
+	// nothing is due before the retry
+	assert.Equal(t, next, dispatcher.dispatchDue(now.Add(5*time.Second)))
+
+	next = dispatcher.dispatchDue(next)
+	assert.Equal(t, now.Add(25*time.Second), next, "the second retry waits at most 15s")
+	next = dispatcher.dispatchDue(next)
+	assert.Equal(t, now.Add(40*time.Second), next)
+	assert.True(t, dispatcher.dispatchDue(next).IsZero(), "nothing is pending after the last attempt")
+
+	queue := dispatcher.Queue()
+	assert.Empty(t, queue.Pending)
+	require.Len(t, queue.DeadLetters, 1)
+	deadLetter := queue.DeadLetters[0]
+	assert.Equal(t, "door left open", deadLetter.Message)
+	assert.Equal(t, 4, deadLetter.Attempts)
+	assert.Equal(t, "notification service unreachable", deadLetter.LastError)
+
+	// the dead letter survives a restart
+	restarted, err := NewNotificationDispatcher(logger.NewMockClient(), config.NotificationsConfig{QueueFile: queueFile}, service.send)
+	require.NoError(t, err)
+	assert.Equal(t, deadLetter.ID, restarted.Queue().DeadLetters[0].ID)
+
+	// once the service is back the dead letter can be sent again
+	service.down = false
+	require.NoError(t, restarted.RetryDeadLetter(deadLetter.ID))
+	assert.Equal(t, ErrNotificationNotFound, restarted.RetryDeadLetter(deadLetter.ID))
+	assert.True(t, restarted.dispatchDue(time.Now()).IsZero())
+	assert.Equal(t, []string{"door left open"}, service.sent)
+	assert.Equal(t, NotificationQueue{Pending: []QueuedNotification{}, DeadLetters: []QueuedNotification{}}, restarted.Queue())
+}
+
+func TestNotificationDispatcherDeleteDeadLetter(t *testing.T) {
+	service := &fakeNotificationService{down: true}
+	dispatcher, err := NewNotificationDispatcher(logger.NewMockClient(), config.NotificationsConfig{
+		QueueFile:   filepath.Join(t.TempDir(), "queue.json"),
+		MaxAttempts: 1,
+	}, service.send)
+	require.NoError(t, err)
+
+	dispatcher.Enqueue("temperature too high")
+	dispatcher.dispatchDue(time.Now())
+	deadLetters := dispatcher.Queue().DeadLetters
+	require.Len(t, deadLetters, 1)
+
+	assert.Equal(t, ErrNotificationNotFound, dispatcher.DeleteDeadLetter("unknown"))
+	require.NoError(t, dispatcher.DeleteDeadLetter(deadLetters[0].ID))
+	assert.Empty(t, dispatcher.Queue().DeadLetters)
+
+	var nilDispatcher *NotificationDispatcher
+	assert.Empty(t, nilDispatcher.Queue().DeadLetters)
+	assert.Equal(t, ErrNotificationNotFound, nilDispatcher.DeleteDeadLetter(deadLetters[0].ID))
+}
+
+// TestNotifyDoesNotBlock tests that a notification is queued right away and
+// sent by the running dispatcher
+func TestNotifyDoesNotBlock(t *testing.T) {
+	sent := make(chan string, 1)
+	release := make(chan struct{})
+	dispatcher, err := NewNotificationDispatcher(logger.NewMockClient(), config.NotificationsConfig{
+		QueueFile: filepath.Join(t.TempDir(), "queue.json"),
+	}, func(message string) error {
+		<-release
+		sent <- message
+		return nil
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	boardStatus := CheckBoardStatus{Notifications: dispatcher}
+	require.NoError(t, boardStatus.Notify("door left open"))
+	close(release)
+
+	select {
+	case message := <-sent:
+		assert.Equal(t, "door left open", message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the notification was not sent")
+	}
+}
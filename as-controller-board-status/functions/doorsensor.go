@@ -0,0 +1,226 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// DoorSensorFailSafeMaintenance takes the kiosk out of service while the
+	// door sensor is suspect, so that no vend relies on its readings
+	DoorSensorFailSafeMaintenance = "maintenance"
+	// DoorSensorFailSafeAlert only sends a notification when the door
+	// sensor is suspect
+	DoorSensorFailSafeAlert = "alert"
+
+	// DoorSensorFlapping is set while the door state changes more often
+	// than a door is opened and closed
+	DoorSensorFlapping = "doorSensorFlapping"
+	// DoorSensorStuckOpen is set while the door has been open for longer
+	// than any vend or restock takes
+	DoorSensorStuckOpen = "doorSensorStuckOpen"
+)
+
+// doorSensorMessages are the notifications sent when the door sensor
+// becomes suspect
+var doorSensorMessages = map[string]string{
+	DoorSensorFlapping:  "The door sensor of the cooler is flapping between open and closed. It needs maintenance.",
+	DoorSensorStuckOpen: "The door sensor of the cooler reports the door open for too long. It needs maintenance.",
+}
+
+// DoorReading is the outcome of a door reading: the debounced door state,
+// whether it changed, and whether the sensor is suspect
+type DoorReading struct {
+	Closed         bool
+	Changed        bool
+	Suspect        string
+	SuspectChanged bool
+}
+
+// DoorSensor debounces the door readings of the controller board, so that a
+// bouncing contact does not open and close the door of a vend, and detects
+// a sensor whose readings are implausible. A nil DoorSensor reports every
+// reading as it is.
+type DoorSensor struct {
+	mutex         sync.Mutex
+	debounce      time.Duration
+	flapThreshold int
+	flapWindow    time.Duration
+	stuckOpen     time.Duration
+	failSafe      string
+	// closed is the debounced door state, and readClosed the last reading,
+	// which was first read at readSince
+	closed     bool
+	readClosed bool
+	readSince  time.Time
+	// changes are when the readings changed within the flap window
+	changes   []time.Time
+	openSince time.Time
+	suspect   string
+}
+
+// NewDoorSensor validates the door sensor configuration. The door starts
+// closed. It returns nil when every check is turned off.
+func NewDoorSensor(doorSensorConfig config.DoorSensorConfig) (*DoorSensor, error) {
+	sensor := &DoorSensor{
+		flapThreshold: doorSensorConfig.FlapThreshold,
+		failSafe:      doorSensorConfig.FailSafe,
+		closed:        true,
+		readClosed:    true,
+	}
+	var err error
+	if sensor.debounce, err = parseDoorSensorDuration("DebounceDuration", doorSensorConfig.DebounceDuration); err != nil {
+		return nil, err
+	}
+	if sensor.flapWindow, err = parseDoorSensorDuration("FlapWindowDuration", doorSensorConfig.FlapWindowDuration); err != nil {
+		return nil, err
+	}
+	if sensor.stuckOpen, err = parseDoorSensorDuration("StuckOpenDuration", doorSensorConfig.StuckOpenDuration); err != nil {
+		return nil, err
+	}
+	if sensor.flapThreshold < 0 || sensor.flapThreshold == 1 {
+		return nil, fmt.Errorf("FlapThreshold must be 0 or at least 2, got %d", sensor.flapThreshold)
+	}
+	if sensor.flapThreshold > 0 && sensor.flapWindow == 0 {
+		return nil, fmt.Errorf("FlapWindowDuration is needed for the FlapThreshold")
+	}
+	switch sensor.failSafe {
+	case "":
+		sensor.failSafe = DoorSensorFailSafeMaintenance
+	case DoorSensorFailSafeMaintenance, DoorSensorFailSafeAlert:
+	default:
+		return nil, fmt.Errorf("FailSafe %q is not one of %s, %s", sensor.failSafe, DoorSensorFailSafeMaintenance, DoorSensorFailSafeAlert)
+	}
+	if sensor.debounce == 0 && sensor.flapThreshold == 0 && sensor.stuckOpen == 0 {
+		return nil, nil
+	}
+	return sensor, nil
+}
+
+func parseDoorSensorDuration(name string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration, got %q", name, value)
+	}
+	return duration, nil
+}
+
+// Read records a door reading. A changed door state is only reported once
+// it has been read for the debounce duration. The sensor is suspect while
+// the readings changed at least the flap threshold times within the flap
+// window, or the door has been open for the stuck open duration.
+func (sensor *DoorSensor) Read(closed bool, now time.Time) DoorReading {
+	if sensor == nil {
+		return DoorReading{Closed: closed}
+	}
+	sensor.mutex.Lock()
+	defer sensor.mutex.Unlock()
+
+	if closed != sensor.readClosed {
+		sensor.readClosed = closed
+		sensor.readSince = now
+		if sensor.flapThreshold > 0 {
+			sensor.changes = append(sensor.changes, now)
+		}
+	}
+	for len(sensor.changes) > 0 && now.Sub(sensor.changes[0]) > sensor.flapWindow {
+		sensor.changes = sensor.changes[1:]
+	}
+
+	var reading DoorReading
+	if sensor.readClosed != sensor.closed && now.Sub(sensor.readSince) >= sensor.debounce {
+		sensor.closed = sensor.readClosed
+		if !sensor.closed {
+			sensor.openSince = sensor.readSince
+		}
+		reading.Changed = true
+	}
+
+	suspect := ""
+	switch {
+	case sensor.flapThreshold > 0 && len(sensor.changes) >= sensor.flapThreshold:
+		suspect = DoorSensorFlapping
+	case sensor.stuckOpen > 0 && !sensor.closed && now.Sub(sensor.openSince) >= sensor.stuckOpen:
+		suspect = DoorSensorStuckOpen
+	}
+	reading.SuspectChanged = suspect != sensor.suspect
+	sensor.suspect = suspect
+	reading.Closed = sensor.closed
+	reading.Suspect = suspect
+	return reading
+}
+
+// Suspect returns why the door sensor is suspect, or empty when it is not
+func (sensor *DoorSensor) Suspect() string {
+	if sensor == nil {
+		return ""
+	}
+	sensor.mutex.Lock()
+	defer sensor.mutex.Unlock()
+	return sensor.suspect
+}
+
+// Faulted returns whether the kiosk is taken out of service because the
+// door sensor is suspect
+func (sensor *DoorSensor) Faulted() bool {
+	if sensor == nil {
+		return false
+	}
+	sensor.mutex.Lock()
+	defer sensor.mutex.Unlock()
+	return sensor.suspect != "" && sensor.failSafe == DoorSensorFailSafeMaintenance
+}
+
+// readDoorSensor returns the debounced door state of the reading. When the
+// sensor becomes suspect or recovers it is logged and notified, and the
+// vending service is told with the maintenance fail-safe.
+func (boardStatus *CheckBoardStatus) readDoorSensor(lc logger.LoggingClient, doorClosed bool) bool {
+	reading := boardStatus.DoorSensor.Read(doorClosed, time.Now())
+	if !reading.SuspectChanged {
+		return reading.Closed
+	}
+
+	if reading.Suspect != "" {
+		lc.Errorf("The door sensor is suspect: %s", reading.Suspect)
+		if err := boardStatus.Notify(doorSensorMessages[reading.Suspect]); err != nil {
+			lc.Errorf("Failed to send the door sensor notification: %s", err.Error())
+		}
+	} else {
+		lc.Info("The door sensor has recovered")
+		if err := boardStatus.Notify("The door sensor of the cooler has recovered."); err != nil {
+			lc.Errorf("Failed to send the door sensor notification: %s", err.Error())
+		}
+	}
+	if boardStatus.DoorSensor.failSafe == DoorSensorFailSafeMaintenance {
+		if err := boardStatus.RESTCommandJSON(boardStatus.Configuration.VendingEndpoint, http.MethodPost, boardStatus.vendingStatus()); err != nil {
+			lc.Errorf("Failed to submit the door sensor state to the central vending state service: %s", err.Error())
+		}
+	}
+	return reading.Closed
+}
+
+// vendingStatus returns the controller board status that is submitted to
+// the vending service, with the debounced door state, the warmup state and
+// the controller board it is for
+func (boardStatus *CheckBoardStatus) vendingStatus() ControllerBoardStatus {
+	var status ControllerBoardStatus
+	if boardStatus.ControllerBoardStatus != nil {
+		status = *boardStatus.ControllerBoardStatus
+	}
+	status.DoorClosed = boardStatus.DoorClosed
+	status.DoorSensorFault = boardStatus.DoorSensor.Faulted()
+	status.DeviceName = boardStatus.Configuration.DeviceName
+	status.Warmup = boardStatus.Warmup.WarmingUp()
+	return status
+}
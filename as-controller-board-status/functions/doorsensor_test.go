@@ -0,0 +1,141 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDoorSensor(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Config        config.DoorSensorConfig
+		ExpectedNil   bool
+		ExpectedError bool
+	}{
+		{"Default", config.DoorSensorConfig{DebounceDuration: "2s", FlapThreshold: 8, FlapWindowDuration: "1m", StuckOpenDuration: "10m", FailSafe: "maintenance"}, false, false},
+		{"Alert fail-safe", config.DoorSensorConfig{StuckOpenDuration: "10m", FailSafe: "alert"}, false, false},
+		{"Disabled", config.DoorSensorConfig{}, true, false},
+		{"Invalid debounce", config.DoorSensorConfig{DebounceDuration: "briefly"}, false, true},
+		{"Negative stuck open", config.DoorSensorConfig{StuckOpenDuration: "-1m"}, false, true},
+		{"Flap threshold of one", config.DoorSensorConfig{FlapThreshold: 1, FlapWindowDuration: "1m"}, false, true},
+		{"Flap threshold without window", config.DoorSensorConfig{FlapThreshold: 8}, false, true},
+		{"Unknown fail-safe", config.DoorSensorConfig{DebounceDuration: "2s", FailSafe: "ignore"}, false, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			sensor, err := NewDoorSensor(currentTest.Config)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedNil, sensor == nil)
+		})
+	}
+}
+
+func TestDoorSensorDebounce(t *testing.T) {
+	sensor, err := NewDoorSensor(config.DoorSensorConfig{DebounceDuration: "2s"})
+	require.NoError(t, err)
+	start := time.Unix(1678860000, 0)
+
+	// a bounce shorter than the debounce duration is never reported
+	assert.Equal(t, DoorReading{Closed: true}, sensor.Read(false, start))
+	assert.Equal(t, DoorReading{Closed: true}, sensor.Read(true, start.Add(time.Second)))
+	assert.Equal(t, DoorReading{Closed: true}, sensor.Read(true, start.Add(4*time.Second)))
+
+	assert.Equal(t, DoorReading{Closed: true}, sensor.Read(false, start.Add(5*time.Second)))
+	assert.Equal(t, DoorReading{Closed: false, Changed: true}, sensor.Read(false, start.Add(7*time.Second)))
+	assert.Equal(t, DoorReading{Closed: false}, sensor.Read(false, start.Add(10*time.Second)))
+}
+
+func TestDoorSensorFlapping(t *testing.T) {
+	sensor, err := NewDoorSensor(config.DoorSensorConfig{FlapThreshold: 4, FlapWindowDuration: "1m", FailSafe: "maintenance"})
+	require.NoError(t, err)
+	start := time.Unix(1678860000, 0)
+
+	sensor.Read(false, start)
+	sensor.Read(true, start.Add(3*time.Second))
+	sensor.Read(false, start.Add(6*time.Second))
+	assert.False(t, sensor.Faulted())
+	reading := sensor.Read(true, start.Add(9*time.Second))
+	assert.Equal(t, DoorReading{Closed: true, Changed: true, Suspect: DoorSensorFlapping, SuspectChanged: true}, reading)
+	assert.Equal(t, DoorSensorFlapping, sensor.Suspect())
+	assert.True(t, sensor.Faulted())
+
+	// the sensor recovers once the changes fall out of the flap window
+	assert.Equal(t, DoorReading{Closed: true, SuspectChanged: true}, sensor.Read(true, start.Add(70*time.Second)))
+	assert.Empty(t, sensor.Suspect())
+	assert.False(t, sensor.Faulted())
+}
+
+func TestDoorSensorStuckOpen(t *testing.T) {
+	sensor, err := NewDoorSensor(config.DoorSensorConfig{StuckOpenDuration: "10m", FailSafe: "alert"})
+	require.NoError(t, err)
+	start := time.Unix(1678860000, 0)
+
+	assert.Equal(t, DoorReading{Closed: false, Changed: true}, sensor.Read(false, start))
+	assert.Equal(t, DoorReading{Closed: false}, sensor.Read(false, start.Add(9*time.Minute)))
+	assert.Equal(t, DoorReading{Closed: false, Suspect: DoorSensorStuckOpen, SuspectChanged: true}, sensor.Read(false, start.Add(10*time.Minute)))
+	// the alert fail-safe does not take the kiosk out of service
+	assert.False(t, sensor.Faulted())
+
+	assert.Equal(t, DoorReading{Closed: true, Changed: true, SuspectChanged: true}, sensor.Read(true, start.Add(11*time.Minute)))
+	assert.Empty(t, sensor.Suspect())
+}
+
+func TestDoorSensorNil(t *testing.T) {
+	var sensor *DoorSensor
+	assert.Equal(t, DoorReading{Closed: false}, sensor.Read(false, time.Now()))
+	assert.Empty(t, sensor.Suspect())
+	assert.False(t, sensor.Faulted())
+}
+
+func TestReadDoorSensor(t *testing.T) {
+	var submitted []ControllerBoardStatus
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var status ControllerBoardStatus
+		assert.NoError(t, json.NewDecoder(request.Body).Decode(&status))
+		submitted = append(submitted, status)
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	mockNotificationClient := &client_mocks.NotificationClient{}
+	mockNotificationClient.On("SendNotification", mock.Anything, mock.Anything).Return(nil, nil)
+	sensor, err := NewDoorSensor(config.DoorSensorConfig{FlapThreshold: 2, FlapWindowDuration: "1m"})
+	require.NoError(t, err)
+	boardStatus := CheckBoardStatus{
+		Configuration:         &config.ControllerBoardStatusConfig{VendingEndpoint: server.URL},
+		ControllerBoardStatus: &ControllerBoardStatus{},
+		NotificationClient:    mockNotificationClient,
+		DoorClosed:            true,
+		DoorSensor:            sensor,
+		restCommandTimeout:    time.Second,
+	}
+	lc := logger.NewMockClient()
+
+	assert.False(t, boardStatus.readDoorSensor(lc, false))
+	assert.Empty(t, submitted)
+
+	// the sensor becomes suspect and the vending service is told right away
+	assert.True(t, boardStatus.readDoorSensor(lc, true))
+	require.Len(t, submitted, 1)
+	assert.True(t, submitted[0].DoorSensorFault)
+	assert.True(t, submitted[0].DoorClosed)
+	mockNotificationClient.AssertNumberOfCalls(t, "SendNotification", 1)
+}
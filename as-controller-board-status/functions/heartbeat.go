@@ -0,0 +1,117 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+const (
+	// HeartbeatDeviceName is the device name of the events that kiosk
+	// heartbeats are published as, which the fleet's as-vending service reads
+	HeartbeatDeviceName = "kiosk-heartbeat"
+	// HeartbeatResource is the resource of the object reading that holds
+	// the heartbeat
+	HeartbeatResource = "heartbeat"
+	// heartbeatTopicPrefix is the message bus topic that heartbeats are
+	// published under, followed by the service key and kiosk ID
+	heartbeatTopicPrefix = "events/heartbeat"
+
+	// IssueMinTemperature and IssueMaxTemperature are the issues of a
+	// heartbeat while the cooler is too cold or too warm
+	IssueMinTemperature = "minTemperature"
+	IssueMaxTemperature = "maxTemperature"
+)
+
+// Heartbeat is published periodically by each app service of a kiosk, so
+// that the fleet can tell a kiosk that is powered off from one without
+// customers
+type Heartbeat struct {
+	ServiceKey    string   `json:"serviceKey"`
+	KioskID       string   `json:"kioskId"`
+	Version       string   `json:"version"`
+	Healthy       bool     `json:"healthy"`
+	Issues        []string `json:"issues,omitempty"`
+	UptimeSeconds int64    `json:"uptimeSeconds"`
+	Timestamp     int64    `json:"timestamp,string"`
+}
+
+// ParseHeartbeatInterval parses how often the heartbeat is published. An
+// empty interval disables publishing.
+func ParseHeartbeatInterval(interval string) (time.Duration, error) {
+	if interval == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(interval)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("heartbeat interval %q must be a positive duration", interval)
+	}
+	return duration, nil
+}
+
+// HeartbeatTopic returns the message bus topic of the heartbeats of the
+// service of the kiosk
+func HeartbeatTopic(serviceKey string, kioskID string) string {
+	return common.BuildTopic(heartbeatTopicPrefix, serviceKey, kioskID)
+}
+
+// NewHeartbeatEvent returns the heartbeat as the event it is published as
+func NewHeartbeatEvent(heartbeat Heartbeat) dtos.Event {
+	event := dtos.NewEvent(HeartbeatDeviceName, HeartbeatDeviceName, heartbeat.ServiceKey)
+	event.AddObjectReading(HeartbeatResource, heartbeat)
+	return event
+}
+
+// Heartbeat returns the heartbeat of the controller board status service.
+// The kiosk is unhealthy while the cooler temperature is outside of its
+// thresholds, or the door sensor is suspect.
+func (boardStatus *CheckBoardStatus) Heartbeat(serviceKey string, kioskID string, version string, startedAt time.Time, now time.Time) Heartbeat {
+	heartbeat := Heartbeat{
+		ServiceKey:    serviceKey,
+		KioskID:       kioskID,
+		Version:       version,
+		UptimeSeconds: int64(now.Sub(startedAt).Seconds()),
+		Timestamp:     now.UnixNano(),
+	}
+	if status := boardStatus.ControllerBoardStatus; status != nil {
+		if status.MinTemperatureStatus {
+			heartbeat.Issues = append(heartbeat.Issues, IssueMinTemperature)
+		}
+		if status.MaxTemperatureStatus {
+			heartbeat.Issues = append(heartbeat.Issues, IssueMaxTemperature)
+		}
+	}
+	if suspect := boardStatus.DoorSensor.Suspect(); suspect != "" {
+		heartbeat.Issues = append(heartbeat.Issues, suspect)
+	}
+	heartbeat.Healthy = len(heartbeat.Issues) == 0
+	return heartbeat
+}
+
+// PublishHeartbeats publishes the heartbeat of the controller board status
+// service at the interval until the context is done. A heartbeat that fails
+// to publish is logged and the next one is still sent.
+func (boardStatus *CheckBoardStatus) PublishHeartbeats(ctx context.Context, lc logger.LoggingClient, serviceKey string, kioskID string, version string, interval time.Duration, publish func(topic string, event dtos.Event) error) {
+	startedAt := time.Now()
+	topic := HeartbeatTopic(serviceKey, kioskID)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		heartbeat := boardStatus.Heartbeat(serviceKey, kioskID, version, startedAt, time.Now())
+		if err := publish(topic, NewHeartbeatEvent(heartbeat)); err != nil {
+			lc.Errorf("failed to publish the heartbeat of kiosk %s: %s", kioskID, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
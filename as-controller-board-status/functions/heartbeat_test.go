@@ -0,0 +1,87 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeartbeatInterval(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Interval      string
+		Expected      time.Duration
+		ExpectedError bool
+	}{
+		{"Interval", "30s", 30 * time.Second, false},
+		{"Disabled", "", 0, false},
+		{"Invalid duration", "often", 0, true},
+		{"Negative duration", "-30s", 0, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			interval, err := ParseHeartbeatInterval(currentTest.Interval)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, interval)
+		})
+	}
+}
+
+func TestBoardStatusHeartbeat(t *testing.T) {
+	boardStatus := CheckBoardStatus{ControllerBoardStatus: &ControllerBoardStatus{}}
+	startedAt := time.Unix(1678860000, 0)
+	now := startedAt.Add(time.Minute)
+
+	assert.Equal(t, Heartbeat{
+		ServiceKey:    "as-controller-board-status",
+		KioskID:       "kiosk-1",
+		Version:       "1.2.0",
+		Healthy:       true,
+		UptimeSeconds: 60,
+		Timestamp:     now.UnixNano(),
+	}, boardStatus.Heartbeat("as-controller-board-status", "kiosk-1", "1.2.0", startedAt, now))
+
+	boardStatus.ControllerBoardStatus.MaxTemperatureStatus = true
+	heartbeat := boardStatus.Heartbeat("as-controller-board-status", "kiosk-1", "1.2.0", startedAt, now)
+	assert.False(t, heartbeat.Healthy)
+	assert.Equal(t, []string{IssueMaxTemperature}, heartbeat.Issues)
+}
+
+func TestPublishHeartbeats(t *testing.T) {
+	boardStatus := CheckBoardStatus{ControllerBoardStatus: &ControllerBoardStatus{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	published := make(chan string)
+	var events []dtos.Event
+	go func() {
+		boardStatus.PublishHeartbeats(ctx, logger.NewMockClient(), "as-controller-board-status", "kiosk-1", "1.2.0", time.Millisecond, func(topic string, event dtos.Event) error {
+			events = append(events, event)
+			published <- topic
+			return nil
+		})
+		close(published)
+	}()
+
+	// the first heartbeat is published right away
+	assert.Equal(t, "events/heartbeat/as-controller-board-status/kiosk-1", <-published)
+	cancel()
+	for range published {
+	}
+	require.NotEmpty(t, events)
+	assert.Equal(t, HeartbeatDeviceName, events[0].DeviceName)
+	assert.Equal(t, "as-controller-board-status", events[0].SourceName)
+	require.Len(t, events[0].Readings, 1)
+	assert.Equal(t, HeartbeatResource, events[0].Readings[0].ResourceName)
+}
@@ -45,6 +45,15 @@ type ControllerBoardStatus struct {
 	Humidity             float64 `json:"humidity"`
 	MinTemperatureStatus bool    `json:"minTemperatureStatus"`
 	MaxTemperatureStatus bool    `json:"maxTemperatureStatus"`
+	// DoorSensorFault is set while the door sensor is suspect and takes the
+	// kiosk out of service
+	DoorSensorFault bool `json:"doorSensorFault"`
+	// DeviceName is the controller board of the status, which tells the
+	// vending service running a bank of coolers the door it is for
+	DeviceName string `json:"deviceName,omitempty"`
+	// Warmup is set while vending is held after power-on, until the cooler
+	// has been within its temperature thresholds for the warmup duration
+	Warmup bool `json:"warmup"`
 }
 
 // TempMeasurement is a simple data structure that is meant to plug temperature
@@ -71,6 +80,11 @@ type CheckBoardStatus struct {
 	NotificationClient                        interfaces.NotificationClient
 	CommandClient                             interfaces.CommandClient
 	ControllerBoardStatus                     *ControllerBoardStatus
+	TemperatureCompliance                     *TemperatureComplianceTracker // nil when the temperature compliance report is off
+	Notifications                             *NotificationDispatcher       // nil sends the notifications synchronously
+	DoorSensor                                *DoorSensor                   // nil reports every door reading as it is
+	Thresholds                                *ThresholdStore               // nil uses the configured temperature thresholds
+	Warmup                                    *Warmup                       // nil never holds vending after power-on
 	averageTemperatureMeasurement             time.Duration
 	notificationSubscriptionRESTRetryInterval time.Duration
 	notificationThrottle                      time.Duration
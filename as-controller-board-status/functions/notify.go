@@ -43,6 +43,17 @@ func (boardStatus CheckBoardStatus) SubscribeToNotificationService() error {
 	return nil
 }
 
+// Notify queues a notification with the notification dispatcher, which
+// sends it in the background, or sends it now when there is no dispatcher
+func (boardStatus CheckBoardStatus) Notify(message string) error {
+	if boardStatus.Notifications == nil {
+		return boardStatus.SendNotification(message)
+	}
+	boardStatus.Notifications.Enqueue(message)
+	return nil
+}
+
+// SendNotification submits a notification to the EdgeX notification service
 func (boardStatus CheckBoardStatus) SendNotification(message string) error {
 	dto := dtos.NewNotification(boardStatus.notificationLabels,
 		boardStatus.Configuration.NotificationCategory,
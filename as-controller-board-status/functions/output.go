@@ -68,8 +68,22 @@ func (boardStatus *CheckBoardStatus) CheckControllerBoardStatus(ctx interfaces.A
 				lc.Errorf("Encountered error while checking temperature thresholds: %s", err.Error())
 			}
 
-			// Check if the door open/closed state requires action
-			err = boardStatus.processVendingDoorState(lc, boardStatus.ControllerBoardStatus.DoorClosed)
+			// Check if vending is still held after power-on
+			err = boardStatus.processWarmup(lc)
+			if err != nil {
+				lc.Errorf("Encountered error while checking the warmup: %s", err.Error())
+			}
+
+			// Check if the humidity is outside of its range
+			err = boardStatus.processHumidity(lc, boardStatus.ControllerBoardStatus.Humidity)
+			if err != nil {
+				lc.Errorf("Encountered error while checking humidity thresholds: %s", err.Error())
+			}
+
+			// Check if the door open/closed state requires action, once a
+			// changed state has been read for the debounce duration
+			doorClosed := boardStatus.readDoorSensor(lc, boardStatus.ControllerBoardStatus.DoorClosed)
+			err = boardStatus.processVendingDoorState(lc, doorClosed)
 			if err != nil {
 				lc.Errorf("Encountered error while checking the open/closed state of the door: %s", err.Error())
 			}
@@ -132,11 +146,11 @@ func getTempThresholdExceededMessage(minOrMax string, avgTemp float64, tempThres
 	return resultMessage, nil
 }
 
-// sendTempThresholdExceededNotification leverages the SendNotification
-// function to submit an EdgeX REST call to send a notification to a user.
+// sendTempThresholdExceededNotification leverages the Notify function to
+// queue a notification to a user, which is sent in the background.
 // It does not check if a notification needs to be sent, it simply sends it
 func (boardStatus *CheckBoardStatus) sendTempThresholdExceededNotification(message string) error {
-	err := boardStatus.SendNotification(message)
+	err := boardStatus.Notify(message)
 	if err != nil {
 		return fmt.Errorf("Encountered error sending notification for exceeding temperature threshold: %v", err.Error())
 	}
@@ -151,11 +165,12 @@ func (boardStatus *CheckBoardStatus) sendTempThresholdExceededNotifications(avgT
 	// only if there is a message that needs to be sent when the
 	// min/max thresholds are exceeded, then loop over that map
 	messages := make(map[string]float64)
+	thresholds := boardStatus.CurrentThresholds()
 	if boardStatus.ControllerBoardStatus.MaxTemperatureStatus {
-		messages[maximum] = boardStatus.Configuration.MaxTemperatureThreshold
+		messages[maximum] = thresholds.MaxTemperature
 	}
 	if boardStatus.ControllerBoardStatus.MinTemperatureStatus {
-		messages[minimum] = boardStatus.Configuration.MinTemperatureThreshold
+		messages[minimum] = thresholds.MinTemperature
 	}
 	for minMaxStr, tempThresholdValueFloat := range messages {
 		// Build the message out
@@ -175,12 +190,14 @@ func (boardStatus *CheckBoardStatus) sendTempThresholdExceededNotifications(avgT
 // processTemperature checks to see if we've exceeded any temperature thresholds
 // and submits EdgeX REST commands accordingly
 func (boardStatus *CheckBoardStatus) processTemperature(lc logger.LoggingClient, temperature float64) error {
+	thresholds := boardStatus.CurrentThresholds()
+	boardStatus.TemperatureCompliance.Record(temperature, thresholds.MinTemperature, thresholds.MaxTemperature, time.Now())
 	avgTemp := boardStatus.processTemperatureMeasurements(temperature)
 
 	// Update the min/max temperature status readout for the global controller
 	// board status according to the how the average temperature compares to
 	// the configured min/max temperature threshold values
-	boardStatus.ControllerBoardStatus.updateThresholdsFromAverageTemperature(avgTemp, boardStatus.Configuration.MaxTemperatureThreshold, boardStatus.Configuration.MinTemperatureThreshold)
+	boardStatus.ControllerBoardStatus.updateThresholdsFromAverageTemperature(avgTemp, thresholds.MaxTemperature, thresholds.MinTemperature)
 
 	// Take note of whether or not we've sent a notification within a duration
 	// not allowable by the user's configuration
@@ -200,7 +217,7 @@ func (boardStatus *CheckBoardStatus) processTemperature(lc logger.LoggingClient,
 	// react accordingly
 	if boardStatus.ControllerBoardStatus.MinTemperatureStatus || boardStatus.ControllerBoardStatus.MaxTemperatureStatus {
 		lc.Info("Pushing controller board status to central vending service due to a temperature threshold being exceeded")
-		err := boardStatus.RESTCommandJSON(boardStatus.Configuration.VendingEndpoint, http.MethodPost, boardStatus.ControllerBoardStatus)
+		err := boardStatus.RESTCommandJSON(boardStatus.Configuration.VendingEndpoint, http.MethodPost, boardStatus.vendingStatus())
 		if err != nil {
 			return fmt.Errorf("Encountered error sending the controller board's status to the central vending endpoint: %v", err.Error())
 		}
@@ -248,6 +265,9 @@ func (boardStatus *CheckBoardStatus) processVendingDoorState(lc logger.LoggingCl
 			DoorClosed:           doorClosed,
 			MinTemperatureStatus: false,
 			MaxTemperatureStatus: false,
+			DoorSensorFault:      boardStatus.DoorSensor.Faulted(),
+			DeviceName:           boardStatus.Configuration.DeviceName,
+			Warmup:               boardStatus.Warmup.WarmingUp(),
 		})
 		if err != nil {
 			return fmt.Errorf("failed to submit the controller board's status to the central vending state service: %v", err.Error())
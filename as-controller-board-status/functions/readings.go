@@ -0,0 +1,145 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ReadingSerializer converts the payload of a binary reading into the
+// textual value that the pipeline functions read
+type ReadingSerializer func(payload []byte) (string, error)
+
+// ReadingDecoder is a pipeline function that fills in the Value of binary
+// and object readings, so that events published with CBOR or binary
+// payloads are handled like events with JSON string readings. Binary
+// readings are converted by the serializer of their media type.
+type ReadingDecoder struct {
+	serializers map[string]ReadingSerializer
+}
+
+// cborDecMode decodes CBOR maps with string keys, so that they can be
+// encoded as JSON
+var cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+
+// NewReadingDecoder creates a ReadingDecoder with the serializers of JSON,
+// CBOR and plain text payloads
+func NewReadingDecoder() *ReadingDecoder {
+	decoder := &ReadingDecoder{serializers: make(map[string]ReadingSerializer)}
+	decoder.Register(common.ContentTypeJSON, serializeJSONReading)
+	decoder.Register(common.ContentTypeCBOR, serializeCBORReading)
+	decoder.Register(common.ContentTypeText, serializeTextReading)
+	return decoder
+}
+
+// Register sets the serializer of the binary readings of the media type
+func (decoder *ReadingDecoder) Register(mediaType string, serializer ReadingSerializer) {
+	decoder.serializers[normalizeMediaType(mediaType)] = serializer
+}
+
+// DecodeReadings is an EdgeX function that is passed into the EdgeX SDK's
+// function pipeline ahead of the functions that read the reading values.
+func (decoder *ReadingDecoder) DecodeReadings(ctx interfaces.AppFunctionContext, data interface{}) (bool, interface{}) {
+	if data == nil {
+		return false, nil
+	}
+	event, ok := data.(dtos.Event)
+	if !ok {
+		return false, fmt.Errorf("DecodeReadings expected an event, received %T", data)
+	}
+	event, err := decoder.Decode(event)
+	if err != nil {
+		ctx.LoggingClient().Errorf("Failed to decode the readings of %s: %s", event.DeviceName, err.Error())
+		return false, err
+	}
+	return true, event
+}
+
+// Decode returns the event with the Value of its binary and object readings
+// filled in. Readings that already have a Value are left as they are.
+func (decoder *ReadingDecoder) Decode(event dtos.Event) (dtos.Event, error) {
+	readings := make([]dtos.BaseReading, len(event.Readings))
+	copy(readings, event.Readings)
+	for i, reading := range readings {
+		if reading.Value != "" {
+			continue
+		}
+		var err error
+		switch reading.ValueType {
+		case common.ValueTypeBinary:
+			serializer, ok := decoder.serializers[normalizeMediaType(reading.MediaType)]
+			if !ok {
+				return event, fmt.Errorf("binary reading %s has the unsupported media type %q", reading.ResourceName, reading.MediaType)
+			}
+			readings[i].Value, err = serializer(reading.BinaryValue)
+		case common.ValueTypeObject:
+			readings[i].Value, err = serializeObjectReading(reading.ObjectValue)
+		}
+		if err != nil {
+			return event, fmt.Errorf("failed to decode reading %s: %s", reading.ResourceName, err.Error())
+		}
+	}
+	event.Readings = readings
+	return event, nil
+}
+
+// normalizeMediaType drops the parameters of a media type, such as its
+// charset
+func normalizeMediaType(mediaType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(mediaType, ";")[0]))
+}
+
+func serializeJSONReading(payload []byte) (string, error) {
+	if !json.Valid(payload) {
+		return "", fmt.Errorf("payload is not valid JSON")
+	}
+	return string(payload), nil
+}
+
+func serializeTextReading(payload []byte) (string, error) {
+	return string(payload), nil
+}
+
+// serializeCBORReading decodes a CBOR payload. Strings, such as a card
+// number, are used as they are, and other values are encoded as JSON.
+func serializeCBORReading(payload []byte) (string, error) {
+	var value interface{}
+	if err := cborDecMode.Unmarshal(payload, &value); err != nil {
+		return "", err
+	}
+	return serializeValue(value)
+}
+
+// serializeObjectReading encodes the value of an object reading as JSON.
+// Objects decoded from CBOR events may have maps with keys that JSON does
+// not support, so the value is passed through CBOR first.
+func serializeObjectReading(value interface{}) (string, error) {
+	payload, err := cbor.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return serializeCBORReading(payload)
+}
+
+func serializeValue(value interface{}) (string, error) {
+	switch typed := value.(type) {
+	case string:
+		return typed, nil
+	case []byte:
+		return string(typed), nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
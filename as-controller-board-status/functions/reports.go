@@ -0,0 +1,397 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// ReportDailySales is the sales of the last 24 hours by SKU, from the
+	// ledger service
+	ReportDailySales = "daily-sales"
+	// ReportLowStock is the products below their minimum restocking level,
+	// from the inventory service
+	ReportLowStock = "low-stock"
+	// ReportTemperatureCompliance is the share of temperature readings
+	// within the thresholds since the last report
+	ReportTemperatureCompliance = "temperature-compliance"
+
+	// ReportDeliveryNotification sends the report with the EdgeX
+	// notification service, to the same subscribers as the maintenance
+	// notifications
+	ReportDeliveryNotification = "notification"
+	// ReportDeliveryObjectStorage uploads the report to ObjectStorageURL
+	ReportDeliveryObjectStorage = "objectstorage"
+
+	// reportObjectTimeLayout names the report objects by the time they were
+	// generated, so that they sort in time order
+	reportObjectTimeLayout = "20060102T150405Z"
+)
+
+// ReportNames are the reports that can be scheduled, in the order they are
+// generated when due at the same time
+var ReportNames = []string{ReportDailySales, ReportLowStock, ReportTemperatureCompliance}
+
+// Report is a generated report, as it is delivered
+type Report struct {
+	Name        string `json:"name"`
+	GeneratedAt string `json:"generatedAt"`
+	// Summary is a short human readable text of the report, used as the
+	// first lines of the notification
+	Summary string          `json:"summary"`
+	Content json.RawMessage `json:"content"`
+}
+
+// scheduledReport is the parsed ReportSchedule of a report
+type scheduledReport struct {
+	name       string
+	schedule   CronSchedule
+	deliveries []string
+}
+
+// salesReportSummary holds the fields of the ledger service's sales report
+// that are summarized
+type salesReportSummary struct {
+	Currency string `json:"currency"`
+	Totals   struct {
+		TransactionCount int     `json:"transactionCount"`
+		ItemCount        int     `json:"itemCount"`
+		Revenue          float64 `json:"revenue"`
+	} `json:"totals"`
+}
+
+// lowStockSummary holds the fields of the inventory service's products that
+// are summarized
+type lowStockSummary struct {
+	Data []struct {
+		SKU                string `json:"sku"`
+		ProductName        string `json:"productName"`
+		UnitsOnHand        int    `json:"unitsOnHand"`
+		MinRestockingLevel int    `json:"minRestockingLevel"`
+	} `json:"data"`
+}
+
+// ReportScheduler generates the configured reports on their schedules and
+// delivers them
+type ReportScheduler struct {
+	lc          logger.LoggingClient
+	config      config.ReportsConfig
+	boardStatus *CheckBoardStatus
+	reports     map[string]scheduledReport
+	httpClient  *http.Client
+	// location is the kiosk's time zone
+	location *time.Location
+}
+
+// NewReportScheduler validates the reports configuration and returns the
+// scheduler of the reports that have a schedule. The temperature compliance
+// tracker of the board status is started when that report is scheduled.
+func NewReportScheduler(lc logger.LoggingClient, reportsConfig config.ReportsConfig, boardStatus *CheckBoardStatus) (*ReportScheduler, error) {
+	location, err := LoadTimeZone(reportsConfig.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone of the reports: %s", err.Error())
+	}
+	scheduler := &ReportScheduler{
+		lc:          lc,
+		config:      reportsConfig,
+		boardStatus: boardStatus,
+		reports:     make(map[string]scheduledReport),
+		httpClient:  &http.Client{Timeout: boardStatus.restCommandTimeout},
+		location:    location,
+	}
+
+	schedules := map[string]config.ReportSchedule{
+		ReportDailySales:            reportsConfig.DailySales,
+		ReportLowStock:              reportsConfig.LowStock,
+		ReportTemperatureCompliance: reportsConfig.TemperatureCompliance,
+	}
+	for _, name := range ReportNames {
+		reportSchedule := schedules[name]
+		deliveries, err := parseReportDeliveries(reportSchedule.Delivery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delivery of the %s report: %s", name, err.Error())
+		}
+		for _, delivery := range deliveries {
+			if delivery == ReportDeliveryObjectStorage && reportsConfig.ObjectStorageURL == "" {
+				return nil, fmt.Errorf("the %s report is delivered to object storage, but ObjectStorageURL is not set", name)
+			}
+		}
+		if strings.TrimSpace(reportSchedule.Schedule) == "" {
+			continue
+		}
+		schedule, err := ParseCron(reportSchedule.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule of the %s report: %s", name, err.Error())
+		}
+		scheduler.reports[name] = scheduledReport{name: name, schedule: schedule, deliveries: deliveries}
+	}
+
+	if _, ok := scheduler.reports[ReportDailySales]; ok && reportsConfig.SalesReportEndpoint == "" {
+		return nil, fmt.Errorf("the %s report is scheduled, but SalesReportEndpoint is not set", ReportDailySales)
+	}
+	if _, ok := scheduler.reports[ReportLowStock]; ok && reportsConfig.InventoryEndpoint == "" {
+		return nil, fmt.Errorf("the %s report is scheduled, but InventoryEndpoint is not set", ReportLowStock)
+	}
+	if _, ok := scheduler.reports[ReportTemperatureCompliance]; ok && boardStatus.TemperatureCompliance == nil {
+		boardStatus.TemperatureCompliance = NewTemperatureComplianceTracker(time.Now())
+	}
+	return scheduler, nil
+}
+
+// parseReportDeliveries splits a comma-separated list of deliveries, which
+// defaults to the notification delivery
+func parseReportDeliveries(delivery string) ([]string, error) {
+	if strings.TrimSpace(delivery) == "" {
+		return []string{ReportDeliveryNotification}, nil
+	}
+	var deliveries []string
+	for _, value := range strings.Split(delivery, ",") {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != ReportDeliveryNotification && value != ReportDeliveryObjectStorage {
+			return nil, fmt.Errorf("%q must be %s or %s", value, ReportDeliveryNotification, ReportDeliveryObjectStorage)
+		}
+		deliveries = append(deliveries, value)
+	}
+	return deliveries, nil
+}
+
+// Scheduled reports whether any report has a schedule
+func (scheduler *ReportScheduler) Scheduled() bool {
+	return len(scheduler.reports) > 0
+}
+
+// Run generates and delivers the reports when they are due, until the
+// context is done. A report that fails is logged and generated again on its
+// next schedule.
+func (scheduler *ReportScheduler) Run(ctx context.Context) {
+	for {
+		// the schedules are in the kiosk's time zone
+		now := time.Now().In(scheduler.location)
+		var next time.Time
+		var due []string
+		for _, name := range ReportNames {
+			report, ok := scheduler.reports[name]
+			if !ok {
+				continue
+			}
+			reportNext := report.schedule.Next(now)
+			switch {
+			case reportNext.IsZero():
+				continue
+			case next.IsZero() || reportNext.Before(next):
+				next = reportNext
+				due = []string{name}
+			case reportNext.Equal(next):
+				due = append(due, name)
+			}
+		}
+		if next.IsZero() {
+			scheduler.lc.Warn("None of the scheduled reports is ever due, stopping the report scheduler")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, name := range due {
+			if _, err := scheduler.RunReport(name); err != nil {
+				scheduler.lc.Errorf("Failed to run the scheduled %s report: %s", name, err.Error())
+				continue
+			}
+			scheduler.lc.Infof("Delivered the scheduled %s report", name)
+		}
+	}
+}
+
+// RunReport generates a report and delivers it to its configured deliveries.
+// A report without a schedule is sent as a notification.
+func (scheduler *ReportScheduler) RunReport(name string) (Report, error) {
+	report, err := scheduler.Generate(name, time.Now())
+	if err != nil {
+		return report, err
+	}
+	deliveries := []string{ReportDeliveryNotification}
+	if scheduled, ok := scheduler.reports[name]; ok {
+		deliveries = scheduled.deliveries
+	}
+	for _, delivery := range deliveries {
+		if err := scheduler.Deliver(report, delivery); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// Generate builds a report as of the given time. The report is dated in the
+// kiosk's time zone.
+func (scheduler *ReportScheduler) Generate(name string, now time.Time) (Report, error) {
+	report := Report{Name: name, GeneratedAt: now.In(scheduler.location).Format(time.RFC3339)}
+	var err error
+	switch name {
+	case ReportDailySales:
+		report.Summary, report.Content, err = scheduler.dailySales(now)
+	case ReportLowStock:
+		report.Summary, report.Content, err = scheduler.lowStock()
+	case ReportTemperatureCompliance:
+		report.Summary, report.Content, err = scheduler.temperatureCompliance(now)
+	default:
+		return report, fmt.Errorf("unknown report %q, the reports are %s", name, strings.Join(ReportNames, ", "))
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to generate the %s report: %s", name, err.Error())
+	}
+	return report, nil
+}
+
+func (scheduler *ReportScheduler) dailySales(now time.Time) (string, json.RawMessage, error) {
+	query := url.Values{}
+	query.Set("groupBy", "sku")
+	query.Set("from", now.Add(-24*time.Hour).UTC().Format(time.RFC3339))
+	query.Set("to", now.UTC().Format(time.RFC3339))
+	content, err := scheduler.getJSON(scheduler.config.SalesReportEndpoint + "?" + query.Encode())
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sales salesReportSummary
+	if err := json.Unmarshal(content, &sales); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal the sales report: %s", err.Error())
+	}
+	summary := fmt.Sprintf("Sales of the last 24 hours: %d transactions, %d items, %.2f %s revenue.",
+		sales.Totals.TransactionCount, sales.Totals.ItemCount, sales.Totals.Revenue, sales.Currency)
+	return summary, content, nil
+}
+
+func (scheduler *ReportScheduler) lowStock() (string, json.RawMessage, error) {
+	content, err := scheduler.getJSON(scheduler.config.InventoryEndpoint + "?belowMin=true")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var products lowStockSummary
+	if err := json.Unmarshal(content, &products); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal the inventory: %s", err.Error())
+	}
+	if len(products.Data) == 0 {
+		return "No products are below their minimum restocking level.", content, nil
+	}
+	sort.Slice(products.Data, func(i, j int) bool {
+		return products.Data[i].SKU < products.Data[j].SKU
+	})
+	lines := []string{fmt.Sprintf("%d products are below their minimum restocking level:", len(products.Data))}
+	for _, product := range products.Data {
+		lines = append(lines, fmt.Sprintf("- %s (%s): %d on hand, minimum %d", product.ProductName, product.SKU, product.UnitsOnHand, product.MinRestockingLevel))
+	}
+	return strings.Join(lines, "\n"), content, nil
+}
+
+func (scheduler *ReportScheduler) temperatureCompliance(now time.Time) (string, json.RawMessage, error) {
+	if scheduler.boardStatus.TemperatureCompliance == nil {
+		return "", nil, fmt.Errorf("the temperature readings are only tracked when the %s report is scheduled", ReportTemperatureCompliance)
+	}
+	compliance := scheduler.boardStatus.TemperatureCompliance.Report(now)
+	content, err := json.Marshal(compliance)
+	if err != nil {
+		return "", nil, err
+	}
+	summary := fmt.Sprintf("Temperature compliance since %s: %.1f%% of %d readings within %v to %v degrees, %d excursions out of range for %s in total.",
+		compliance.From, compliance.CompliancePercent, compliance.Readings, compliance.MinTemperatureThreshold, compliance.MaxTemperatureThreshold,
+		compliance.Excursions, time.Duration(compliance.SecondsOutOfRange*float64(time.Second)).Round(time.Second))
+	return summary, content, nil
+}
+
+// getJSON returns the body of a GET request, which must succeed with
+// HTTP 200 status OK
+func (scheduler *ReportScheduler) getJSON(restURL string) (json.RawMessage, error) {
+	resp, err := scheduler.httpClient.Get(restURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit REST GET request due to error: %v", err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response body from %v: %v", restURL, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("did not receive an HTTP 200 status OK response from %v, instead got a response code of %v, and the response body was: %v", restURL, resp.StatusCode, string(body))
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("the response body from %v is not JSON", restURL)
+	}
+	return body, nil
+}
+
+// Deliver sends a report to a delivery
+func (scheduler *ReportScheduler) Deliver(report Report, delivery string) error {
+	switch delivery {
+	case ReportDeliveryNotification:
+		content, err := json.MarshalIndent(report.Content, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize the %s report: %s", report.Name, err.Error())
+		}
+		message := fmt.Sprintf("Automated vending %s report of %s\n\n%s\n\n%s", report.Name, report.GeneratedAt, report.Summary, content)
+		if err := scheduler.boardStatus.Notify(message); err != nil {
+			return fmt.Errorf("failed to deliver the %s report: %s", report.Name, err.Error())
+		}
+	case ReportDeliveryObjectStorage:
+		if err := scheduler.upload(report); err != nil {
+			return fmt.Errorf("failed to upload the %s report: %s", report.Name, err.Error())
+		}
+	default:
+		return fmt.Errorf("unknown report delivery %q", delivery)
+	}
+	return nil
+}
+
+// upload puts the report into object storage, as
+// <ObjectStorageURL>/<report name>/<generated at>.json
+func (scheduler *ReportScheduler) upload(report Report) error {
+	generatedAt, err := time.Parse(time.RFC3339, report.GeneratedAt)
+	if err != nil {
+		return err
+	}
+	objectURL := fmt.Sprintf("%s/%s/%s.json", strings.TrimSuffix(scheduler.config.ObjectStorageURL, "/"), report.Name, generatedAt.UTC().Format(reportObjectTimeLayout))
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, objectURL, bytes.NewReader(reportJSON))
+	if err != nil {
+		return fmt.Errorf("failed to build the REST PUT request for the URL %v due to error: %v", objectURL, err.Error())
+	}
+	req.Header.Set("Content-Type", ApplicationJSONContentType)
+
+	resp, err := scheduler.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit REST PUT request due to error: %v", err.Error())
+	}
+	defer resp.Body.Close()
+
+	// object stores answer a PUT with 200 OK, 201 Created or 204 No Content
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("did not receive a successful response from %v, instead got a response code of %v, and the response body was: %v", objectURL, resp.StatusCode, string(body))
+	}
+	return nil
+}
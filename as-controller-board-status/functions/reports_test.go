@@ -0,0 +1,232 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemperatureComplianceTracker(t *testing.T) {
+	start := time.Date(2023, time.March, 15, 0, 0, 0, 0, time.UTC)
+	tracker := NewTemperatureComplianceTracker(start)
+
+	// in range, out of range for two minutes, in range, then out of range
+	// until the end of the period
+	for i, temperature := range []float64{40, 90, 95, 85, 50, 5} {
+		tracker.Record(temperature, 10, 83, start.Add(time.Duration(i)*time.Minute))
+	}
+	compliance := tracker.Report(start.Add(10 * time.Minute))
+
+	assert.Equal(t, TemperatureCompliance{
+		From:                    "2023-03-15T00:00:00Z",
+		To:                      "2023-03-15T00:10:00Z",
+		MinTemperatureThreshold: 10,
+		MaxTemperatureThreshold: 83,
+		Readings:                6,
+		OutOfRangeReadings:      4,
+		Excursions:              2,
+		SecondsOutOfRange:       (2*time.Minute + 5*time.Minute).Seconds(),
+		CompliancePercent:       100 * 2.0 / 6.0,
+		MinTemperature:          5,
+		MaxTemperature:          95,
+		AverageTemperature:      (40 + 90 + 95 + 85 + 50 + 5) / 6.0,
+	}, compliance)
+
+	// the next period starts where the last one ended, with the excursion
+	// still going on
+	tracker.Record(4, 10, 83, start.Add(11*time.Minute))
+	compliance = tracker.Report(start.Add(12 * time.Minute))
+	assert.Equal(t, "2023-03-15T00:10:00Z", compliance.From)
+	assert.Equal(t, 1, compliance.Readings)
+	assert.Equal(t, 0, compliance.Excursions)
+	assert.Equal(t, (2 * time.Minute).Seconds(), compliance.SecondsOutOfRange)
+
+	// a nil tracker ignores the readings
+	var nilTracker *TemperatureComplianceTracker
+	nilTracker.Record(100, 10, 83, start)
+}
+
+func TestNewReportScheduler(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Config        config.ReportsConfig
+		ExpectedError string
+	}{
+		{
+			Name: "Success",
+			Config: config.ReportsConfig{
+				SalesReportEndpoint:   "http://localhost:48093/reports/sales",
+				InventoryEndpoint:     "http://localhost:48095/inventory",
+				ObjectStorageURL:      "http://localhost:9000/reports",
+				DailySales:            config.ReportSchedule{Schedule: "0 6 * * *", Delivery: "notification, objectstorage"},
+				LowStock:              config.ReportSchedule{Schedule: "@hourly"},
+				TemperatureCompliance: config.ReportSchedule{Schedule: "@daily", Delivery: "OBJECTSTORAGE"},
+			},
+		},
+		{
+			Name:   "Nothing scheduled",
+			Config: config.ReportsConfig{},
+		},
+		{
+			Name:          "Invalid schedule",
+			Config:        config.ReportsConfig{LowStock: config.ReportSchedule{Schedule: "0 6 * *"}, InventoryEndpoint: "http://localhost:48095/inventory"},
+			ExpectedError: `invalid schedule of the low-stock report: cron expression "0 6 * *" must have 5 fields: minute, hour, day of month, month and day of week`,
+		},
+		{
+			Name:          "Invalid delivery",
+			Config:        config.ReportsConfig{LowStock: config.ReportSchedule{Schedule: "@daily", Delivery: "email"}, InventoryEndpoint: "http://localhost:48095/inventory"},
+			ExpectedError: `invalid delivery of the low-stock report: "email" must be notification or objectstorage`,
+		},
+		{
+			Name:          "Invalid time zone",
+			Config:        config.ReportsConfig{TimeZone: "Mars/Olympus_Mons"},
+			ExpectedError: `invalid time zone of the reports: unknown time zone "Mars/Olympus_Mons": unknown time zone Mars/Olympus_Mons`,
+		},
+		{
+			Name:          "Missing object storage URL",
+			Config:        config.ReportsConfig{TemperatureCompliance: config.ReportSchedule{Schedule: "@daily", Delivery: "objectstorage"}},
+			ExpectedError: "the temperature-compliance report is delivered to object storage, but ObjectStorageURL is not set",
+		},
+		{
+			Name:          "Missing sales report endpoint",
+			Config:        config.ReportsConfig{DailySales: config.ReportSchedule{Schedule: "@daily"}},
+			ExpectedError: "the daily-sales report is scheduled, but SalesReportEndpoint is not set",
+		},
+		{
+			Name:          "Missing inventory endpoint",
+			Config:        config.ReportsConfig{LowStock: config.ReportSchedule{Schedule: "@daily"}},
+			ExpectedError: "the low-stock report is scheduled, but InventoryEndpoint is not set",
+		},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			boardStatus := &CheckBoardStatus{Configuration: GetCommonSuccessConfig()}
+			scheduler, err := NewReportScheduler(logger.NewMockClient(), currentTest.Config, boardStatus)
+			if currentTest.ExpectedError != "" {
+				require.EqualError(t, err, currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			scheduled := currentTest.Config.TemperatureCompliance.Schedule != ""
+			assert.Equal(t, scheduled, scheduler.Scheduled())
+			// the temperature readings are only tracked for a scheduled report
+			assert.Equal(t, scheduled, boardStatus.TemperatureCompliance != nil)
+		})
+	}
+}
+
+func TestReportSchedulerGenerate(t *testing.T) {
+	now := time.Date(2023, time.March, 15, 6, 0, 0, 0, time.UTC)
+	var salesQuery string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reports/sales":
+			salesQuery = r.URL.RawQuery
+			_, _ = w.Write([]byte(`{"groupBy":"sku","currency":"USD","groups":[],"totals":{"transactionCount":3,"itemCount":5,"revenue":7.25}}`))
+		case "/inventory":
+			assert.Equal(t, "belowMin=true", r.URL.RawQuery)
+			_, _ = w.Write([]byte(`{"data":[{"sku":"4900002470","productName":"Sprite (Lemon-Lime) - 16.9 oz","unitsOnHand":1,"minRestockingLevel":3},{"sku":"1200010735","productName":"Mountain Dew - 16.9 oz","unitsOnHand":0,"minRestockingLevel":2}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	boardStatus := &CheckBoardStatus{Configuration: GetCommonSuccessConfig()}
+	require.NoError(t, boardStatus.ParseStringConfigurations())
+	scheduler, err := NewReportScheduler(logger.NewMockClient(), config.ReportsConfig{
+		SalesReportEndpoint:   testServer.URL + "/reports/sales",
+		InventoryEndpoint:     testServer.URL + "/inventory",
+		TemperatureCompliance: config.ReportSchedule{Schedule: "@daily"},
+	}, boardStatus)
+	require.NoError(t, err)
+
+	report, err := scheduler.Generate(ReportDailySales, now)
+	require.NoError(t, err)
+	assert.Equal(t, "from=2023-03-14T06%3A00%3A00Z&groupBy=sku&to=2023-03-15T06%3A00%3A00Z", salesQuery)
+	assert.Equal(t, "2023-03-15T06:00:00Z", report.GeneratedAt)
+	assert.Equal(t, "Sales of the last 24 hours: 3 transactions, 5 items, 7.25 USD revenue.", report.Summary)
+	assert.Contains(t, string(report.Content), `"transactionCount":3`)
+
+	report, err = scheduler.Generate(ReportLowStock, now)
+	require.NoError(t, err)
+	assert.Equal(t, "2 products are below their minimum restocking level:\n"+
+		"- Mountain Dew - 16.9 oz (1200010735): 0 on hand, minimum 2\n"+
+		"- Sprite (Lemon-Lime) - 16.9 oz (4900002470): 1 on hand, minimum 3", report.Summary)
+
+	boardStatus.TemperatureCompliance.Record(50, 10, 83, now.Add(-time.Minute))
+	report, err = scheduler.Generate(ReportTemperatureCompliance, now)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(report.Summary, "Temperature compliance since"))
+	var compliance TemperatureCompliance
+	require.NoError(t, json.Unmarshal(report.Content, &compliance))
+	assert.Equal(t, 1, compliance.Readings)
+
+	// reports are dated in the kiosk's time zone
+	scheduler.location, err = LoadTimeZone("America/Chicago")
+	require.NoError(t, err)
+	report, err = scheduler.Generate(ReportLowStock, now)
+	require.NoError(t, err)
+	assert.Equal(t, "2023-03-15T01:00:00-05:00", report.GeneratedAt)
+
+	_, err = scheduler.Generate("weekly-sales", now)
+	require.EqualError(t, err, `unknown report "weekly-sales", the reports are daily-sales, low-stock, temperature-compliance`)
+
+	scheduler.config.InventoryEndpoint = testServer.URL + "/missing"
+	_, err = scheduler.Generate(ReportLowStock, now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to generate the low-stock report: did not receive an HTTP 200 status OK response")
+}
+
+func TestReportSchedulerDeliver(t *testing.T) {
+	report := Report{Name: ReportLowStock, GeneratedAt: "2023-03-15T06:00:00Z", Summary: "No products are below their minimum restocking level.", Content: json.RawMessage(`{"data":[]}`)}
+
+	var uploadedPath string
+	var uploaded Report
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		uploadedPath = r.URL.Path
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &uploaded))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer testServer.Close()
+
+	mockNotificationClient := &client_mocks.NotificationClient{}
+	mockNotificationClient.On("SendNotification", mock.Anything, mock.MatchedBy(func(reqs []requests.AddNotificationRequest) bool {
+		return len(reqs) == 1 && reqs[0].Notification.Category == "HW_HEALTH" &&
+			strings.HasPrefix(reqs[0].Notification.Content, "Automated vending low-stock report of 2023-03-15T06:00:00Z\n\nNo products are below their minimum restocking level.")
+	})).Return(nil, nil)
+
+	boardStatus := &CheckBoardStatus{Configuration: GetCommonSuccessConfig(), NotificationClient: mockNotificationClient}
+	require.NoError(t, boardStatus.ParseStringConfigurations())
+	scheduler, err := NewReportScheduler(logger.NewMockClient(), config.ReportsConfig{ObjectStorageURL: testServer.URL + "/reports/"}, boardStatus)
+	require.NoError(t, err)
+
+	require.NoError(t, scheduler.Deliver(report, ReportDeliveryNotification))
+	mockNotificationClient.AssertExpectations(t)
+
+	require.NoError(t, scheduler.Deliver(report, ReportDeliveryObjectStorage))
+	assert.Equal(t, "/reports/low-stock/20230315T060000Z.json", uploadedPath)
+	assert.Equal(t, report, uploaded)
+
+	require.EqualError(t, scheduler.Deliver(report, "email"), `unknown report delivery "email"`)
+}
@@ -0,0 +1,162 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// Thresholds are the temperature and relative humidity ranges of the kiosk,
+// outside of which it needs maintenance. A humidity range of zeros is not
+// checked.
+type Thresholds struct {
+	MinTemperature float64 `json:"minTemperature"`
+	MaxTemperature float64 `json:"maxTemperature"`
+	MinHumidity    float64 `json:"minHumidity"`
+	MaxHumidity    float64 `json:"maxHumidity"`
+}
+
+// Validate returns an error when a range is empty, or a humidity is not a
+// percentage
+func (thresholds Thresholds) Validate() error {
+	if thresholds.MinTemperature >= thresholds.MaxTemperature {
+		return fmt.Errorf("minTemperature %v must be below maxTemperature %v", thresholds.MinTemperature, thresholds.MaxTemperature)
+	}
+	if thresholds.MinHumidity == 0 && thresholds.MaxHumidity == 0 {
+		return nil
+	}
+	if thresholds.MinHumidity < 0 || thresholds.MaxHumidity > 100 {
+		return fmt.Errorf("minHumidity %v and maxHumidity %v must be between 0 and 100", thresholds.MinHumidity, thresholds.MaxHumidity)
+	}
+	if thresholds.MinHumidity >= thresholds.MaxHumidity {
+		return fmt.Errorf("minHumidity %v must be below maxHumidity %v", thresholds.MinHumidity, thresholds.MaxHumidity)
+	}
+	return nil
+}
+
+// ThresholdStore holds the thresholds adjusted at runtime, and keeps them in
+// a JSON file so that they override the configured thresholds across
+// restarts, for seasonal adjustments without a configuration push
+type ThresholdStore struct {
+	mutex      sync.Mutex
+	fileName   string
+	thresholds Thresholds
+}
+
+// NewThresholdStore creates the store of the thresholds, which are the
+// thresholds kept in the file, or the configured ones when the file does
+// not exist yet. The thresholds are only kept in memory when the file is
+// empty.
+func NewThresholdStore(thresholdsConfig config.ThresholdsConfig, boardStatusConfig config.ControllerBoardStatusConfig) (*ThresholdStore, error) {
+	store := &ThresholdStore{
+		fileName: thresholdsConfig.File,
+		thresholds: Thresholds{
+			MinTemperature: boardStatusConfig.MinTemperatureThreshold,
+			MaxTemperature: boardStatusConfig.MaxTemperatureThreshold,
+			MinHumidity:    thresholdsConfig.MinHumidity,
+			MaxHumidity:    thresholdsConfig.MaxHumidity,
+		},
+	}
+	if err := store.thresholds.Validate(); err != nil {
+		return nil, err
+	}
+	if store.fileName == "" {
+		return store, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(store.fileName), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the thresholds directory: %s", err.Error())
+	}
+	thresholdsJSON, err := os.ReadFile(store.fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the thresholds %s: %s", store.fileName, err.Error())
+	}
+	var thresholds Thresholds
+	if err := json.Unmarshal(thresholdsJSON, &thresholds); err != nil {
+		return nil, fmt.Errorf("failed to parse the thresholds %s: %s", store.fileName, err.Error())
+	}
+	if err := thresholds.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid thresholds in %s: %s", store.fileName, err.Error())
+	}
+	store.thresholds = thresholds
+	return store, nil
+}
+
+// Get returns the current thresholds
+func (store *ThresholdStore) Get() Thresholds {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.thresholds
+}
+
+// Set validates the thresholds and keeps them in place of the current ones.
+// They are written to a temporary file that replaces the file, so that a
+// crash never leaves a partial file behind.
+func (store *ThresholdStore) Set(thresholds Thresholds) error {
+	if err := thresholds.Validate(); err != nil {
+		return err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.fileName != "" {
+		thresholdsJSON, err := json.MarshalIndent(thresholds, "", "  ")
+		if err != nil {
+			return err
+		}
+		tempName := filepath.Join(filepath.Dir(store.fileName), "."+filepath.Base(store.fileName)+".tmp")
+		if err := os.WriteFile(tempName, thresholdsJSON, 0644); err != nil {
+			return fmt.Errorf("failed to write the thresholds: %s", err.Error())
+		}
+		if err := os.Rename(tempName, store.fileName); err != nil {
+			return fmt.Errorf("failed to write the thresholds: %s", err.Error())
+		}
+	}
+	store.thresholds = thresholds
+	return nil
+}
+
+// CurrentThresholds returns the thresholds adjusted at runtime, or the
+// configured temperature thresholds without a ThresholdStore
+func (boardStatus *CheckBoardStatus) CurrentThresholds() Thresholds {
+	if boardStatus.Thresholds == nil {
+		return Thresholds{
+			MinTemperature: boardStatus.Configuration.MinTemperatureThreshold,
+			MaxTemperature: boardStatus.Configuration.MaxTemperatureThreshold,
+		}
+	}
+	return boardStatus.Thresholds.Get()
+}
+
+// processHumidity sends a notification when the humidity is outside of the
+// humidity range, unless a notification was sent recently
+func (boardStatus *CheckBoardStatus) processHumidity(lc logger.LoggingClient, humidity float64) error {
+	thresholds := boardStatus.CurrentThresholds()
+	if thresholds.MinHumidity == 0 && thresholds.MaxHumidity == 0 {
+		return nil
+	}
+	if humidity >= thresholds.MinHumidity && humidity <= thresholds.MaxHumidity {
+		return nil
+	}
+	if boardStatus.notificationThrottle > time.Since(boardStatus.LastNotified) {
+		return nil
+	}
+	lc.Warnf("The humidity %.2f is outside of the range %v to %v", humidity, thresholds.MinHumidity, thresholds.MaxHumidity)
+	message := fmt.Sprintf("The internal automated vending's relative humidity is currently %.2f%%, outside of the configured range of %v%% to %v%%. The automated vending needs maintenance as of: %s", humidity, thresholds.MinHumidity, thresholds.MaxHumidity, time.Now().Format("_2 Jan, Mon | 3:04PM MST"))
+	if err := boardStatus.Notify(message); err != nil {
+		return fmt.Errorf("Encountered error sending notification for exceeding humidity threshold: %v", err.Error())
+	}
+	boardStatus.LastNotified = time.Now()
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdsValidate(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Thresholds    Thresholds
+		ExpectedError bool
+	}{
+		{"Temperature only", Thresholds{MinTemperature: 10, MaxTemperature: 83}, false},
+		{"Humidity", Thresholds{MinTemperature: 10, MaxTemperature: 83, MinHumidity: 20, MaxHumidity: 60}, false},
+		{"Temperature range empty", Thresholds{MinTemperature: 83, MaxTemperature: 10}, true},
+		{"Humidity range empty", Thresholds{MinTemperature: 10, MaxTemperature: 83, MinHumidity: 60, MaxHumidity: 60}, true},
+		{"Humidity above 100", Thresholds{MinTemperature: 10, MaxTemperature: 83, MinHumidity: 20, MaxHumidity: 120}, true},
+		{"Humidity below 0", Thresholds{MinTemperature: 10, MaxTemperature: 83, MinHumidity: -5, MaxHumidity: 60}, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			err := currentTest.Thresholds.Validate()
+			assert.Equal(t, currentTest.ExpectedError, err != nil, err)
+		})
+	}
+}
+
+func TestThresholdStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "thresholds", "thresholds.json")
+	boardStatusConfig := config.ControllerBoardStatusConfig{MinTemperatureThreshold: 10, MaxTemperatureThreshold: 83}
+	thresholdsConfig := config.ThresholdsConfig{File: fileName, MinHumidity: 20, MaxHumidity: 60}
+
+	store, err := NewThresholdStore(thresholdsConfig, boardStatusConfig)
+	require.NoError(t, err)
+	assert.Equal(t, Thresholds{MinTemperature: 10, MaxTemperature: 83, MinHumidity: 20, MaxHumidity: 60}, store.Get())
+
+	summer := Thresholds{MinTemperature: 5, MaxTemperature: 75, MinHumidity: 30, MaxHumidity: 70}
+	require.NoError(t, store.Set(summer))
+	assert.Error(t, store.Set(Thresholds{MinTemperature: 75, MaxTemperature: 5}))
+	assert.Equal(t, summer, store.Get())
+
+	// the adjusted thresholds override the configured ones across restarts
+	restarted, err := NewThresholdStore(thresholdsConfig, boardStatusConfig)
+	require.NoError(t, err)
+	assert.Equal(t, summer, restarted.Get())
+
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"minTemperature":90,"maxTemperature":10}`), 0644))
+	_, err = NewThresholdStore(thresholdsConfig, boardStatusConfig)
+	assert.Error(t, err)
+	require.NoError(t, os.WriteFile(fileName, []byte(`{`), 0644))
+	_, err = NewThresholdStore(thresholdsConfig, boardStatusConfig)
+	assert.Error(t, err)
+
+	_, err = NewThresholdStore(config.ThresholdsConfig{MinHumidity: 80, MaxHumidity: 20}, boardStatusConfig)
+	assert.Error(t, err)
+}
+
+func TestProcessHumidity(t *testing.T) {
+	store, err := NewThresholdStore(config.ThresholdsConfig{MinHumidity: 20, MaxHumidity: 60}, config.ControllerBoardStatusConfig{MinTemperatureThreshold: 10, MaxTemperatureThreshold: 83})
+	require.NoError(t, err)
+	mockNotificationClient := &client_mocks.NotificationClient{}
+	mockNotificationClient.On("SendNotification", mock.Anything, mock.Anything).Return(nil, nil)
+	boardStatus := CheckBoardStatus{
+		Configuration:        &config.ControllerBoardStatusConfig{},
+		NotificationClient:   mockNotificationClient,
+		Thresholds:           store,
+		notificationThrottle: time.Minute,
+	}
+	lc := logger.NewMockClient()
+
+	require.NoError(t, boardStatus.processHumidity(lc, 40))
+	mockNotificationClient.AssertNumberOfCalls(t, "SendNotification", 0)
+	require.NoError(t, boardStatus.processHumidity(lc, 75))
+	mockNotificationClient.AssertNumberOfCalls(t, "SendNotification", 1)
+	// the notifications are throttled
+	require.NoError(t, boardStatus.processHumidity(lc, 10))
+	mockNotificationClient.AssertNumberOfCalls(t, "SendNotification", 1)
+
+	// without a humidity range nothing is checked
+	require.NoError(t, store.Set(Thresholds{MinTemperature: 10, MaxTemperature: 83}))
+	boardStatus.LastNotified = time.Time{}
+	require.NoError(t, boardStatus.processHumidity(lc, 95))
+	mockNotificationClient.AssertNumberOfCalls(t, "SendNotification", 1)
+}
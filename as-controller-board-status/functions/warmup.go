@@ -0,0 +1,184 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// WarmupState is whether vending is held after power-on until the cooler
+// has been within its temperature thresholds for the warmup duration
+type WarmupState struct {
+	WarmingUp bool  `json:"warmingUp"`
+	StartedAt int64 `json:"startedAt,string"`
+	// InBandSince is when the temperature came within its thresholds, zero
+	// while it is outside of them
+	InBandSince int64 `json:"inBandSince,string,omitempty"`
+	// RemainingSeconds is how much longer the temperature must stay within
+	// its thresholds before vending is allowed
+	RemainingSeconds float64 `json:"remainingSeconds,omitempty"`
+	Overridden       bool    `json:"overridden,omitempty"`
+}
+
+// Warmup holds vending after the service starts, which is when the kiosk is
+// powered on, until the temperature has been within its thresholds for the
+// warmup duration, so that warm product is not vended after a power outage.
+// A nil Warmup never holds vending.
+type Warmup struct {
+	mutex       sync.Mutex
+	duration    time.Duration
+	startedAt   time.Time
+	inBandSince time.Time
+	warmingUp   bool
+	overridden  bool
+	// reported is set once the vending service has been told the state
+	reported bool
+}
+
+// NewWarmup validates the warmup configuration and starts the warmup. It
+// returns nil when the warmup is turned off.
+func NewWarmup(warmupConfig config.WarmupConfig, now time.Time) (*Warmup, error) {
+	if warmupConfig.Duration == "" {
+		return nil, nil
+	}
+	duration, err := time.ParseDuration(warmupConfig.Duration)
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("Duration must be a positive duration, got %q", warmupConfig.Duration)
+	}
+	return &Warmup{duration: duration, startedAt: now, warmingUp: true}, nil
+}
+
+// Read records whether the temperature is within its thresholds, and
+// reports whether the vending service needs to be told the warmup state,
+// which is on the first reading, when the warmup ends, and after the state
+// failed to be submitted. Leaving the
+// thresholds restarts the warmup duration.
+func (warmup *Warmup) Read(inBand bool, now time.Time) bool {
+	if warmup == nil {
+		return false
+	}
+	warmup.mutex.Lock()
+	defer warmup.mutex.Unlock()
+
+	changed := !warmup.reported
+	warmup.reported = true
+	if !warmup.warmingUp {
+		return changed
+	}
+	if !inBand {
+		warmup.inBandSince = time.Time{}
+		return changed
+	}
+	if warmup.inBandSince.IsZero() {
+		warmup.inBandSince = now
+	}
+	if now.Sub(warmup.inBandSince) >= warmup.duration {
+		warmup.warmingUp = false
+		changed = true
+	}
+	return changed
+}
+
+// Override ends the warmup as requested by an operator, and reports whether
+// it was still warming up
+func (warmup *Warmup) Override() bool {
+	if warmup == nil {
+		return false
+	}
+	warmup.mutex.Lock()
+	defer warmup.mutex.Unlock()
+	if !warmup.warmingUp {
+		return false
+	}
+	warmup.warmingUp = false
+	warmup.overridden = true
+	return true
+}
+
+// WarmingUp returns whether vending is held
+func (warmup *Warmup) WarmingUp() bool {
+	if warmup == nil {
+		return false
+	}
+	warmup.mutex.Lock()
+	defer warmup.mutex.Unlock()
+	return warmup.warmingUp
+}
+
+// State returns the warmup state at the time
+func (warmup *Warmup) State(now time.Time) WarmupState {
+	if warmup == nil {
+		return WarmupState{}
+	}
+	warmup.mutex.Lock()
+	defer warmup.mutex.Unlock()
+	state := WarmupState{
+		WarmingUp:  warmup.warmingUp,
+		StartedAt:  warmup.startedAt.UnixNano(),
+		Overridden: warmup.overridden,
+	}
+	if !warmup.inBandSince.IsZero() {
+		state.InBandSince = warmup.inBandSince.UnixNano()
+	}
+	if warmup.warmingUp {
+		remaining := warmup.duration
+		if !warmup.inBandSince.IsZero() {
+			remaining -= now.Sub(warmup.inBandSince)
+		}
+		state.RemainingSeconds = remaining.Seconds()
+	}
+	return state
+}
+
+// processWarmup tells the vending service whether vending is held after
+// power-on, once the average temperature has been checked against the
+// thresholds
+func (boardStatus *CheckBoardStatus) processWarmup(lc logger.LoggingClient) error {
+	inBand := !boardStatus.ControllerBoardStatus.MinTemperatureStatus && !boardStatus.ControllerBoardStatus.MaxTemperatureStatus
+	if !boardStatus.Warmup.Read(inBand, time.Now()) {
+		return nil
+	}
+	if boardStatus.Warmup.WarmingUp() {
+		lc.Infof("Holding vending until the temperature has been within its thresholds for %s", boardStatus.Warmup.duration)
+	} else {
+		lc.Info("The cooler has cooled down, vending is allowed")
+	}
+	if err := boardStatus.submitWarmup(); err != nil {
+		// the state is submitted again with the next reading
+		boardStatus.Warmup.mutex.Lock()
+		boardStatus.Warmup.reported = false
+		boardStatus.Warmup.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// OverrideWarmup ends the warmup as requested by an operator and tells the
+// vending service, and returns the resulting warmup state. The vending
+// service is told even when the warmup had already ended, so that a failed
+// override can be retried.
+func (boardStatus *CheckBoardStatus) OverrideWarmup(lc logger.LoggingClient) (WarmupState, error) {
+	if boardStatus.Warmup.Override() {
+		lc.Warn("The warmup was overridden by an operator, vending is allowed")
+	}
+	if err := boardStatus.submitWarmup(); err != nil {
+		return WarmupState{}, err
+	}
+	return boardStatus.Warmup.State(time.Now()), nil
+}
+
+// submitWarmup submits the controller board status with the warmup state to
+// the vending service
+func (boardStatus *CheckBoardStatus) submitWarmup() error {
+	if err := boardStatus.RESTCommandJSON(boardStatus.Configuration.VendingEndpoint, http.MethodPost, boardStatus.vendingStatus()); err != nil {
+		return fmt.Errorf("failed to submit the warmup state to the central vending state service: %v", err.Error())
+	}
+	return nil
+}
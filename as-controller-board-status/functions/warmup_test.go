@@ -0,0 +1,111 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-controller-board-status/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWarmup(t *testing.T) {
+	warmup, err := NewWarmup(config.WarmupConfig{}, time.Now())
+	require.NoError(t, err)
+	assert.Nil(t, warmup)
+
+	for _, duration := range []string{"soon", "-5m", "0s"} {
+		_, err = NewWarmup(config.WarmupConfig{Duration: duration}, time.Now())
+		assert.Error(t, err, duration)
+	}
+}
+
+func TestWarmupRead(t *testing.T) {
+	start := time.Unix(1000, 0)
+	warmup, err := NewWarmup(config.WarmupConfig{Duration: "30m"}, start)
+	require.NoError(t, err)
+	assert.True(t, warmup.WarmingUp())
+
+	// the vending service is told on the first reading
+	assert.True(t, warmup.Read(false, start))
+	assert.False(t, warmup.Read(true, start.Add(time.Minute)))
+	assert.Equal(t, 30*60.0, warmup.State(start.Add(time.Minute)).RemainingSeconds)
+	assert.False(t, warmup.Read(true, start.Add(20*time.Minute)))
+	assert.Equal(t, 11*60.0, warmup.State(start.Add(20*time.Minute)).RemainingSeconds)
+
+	// leaving the thresholds restarts the warmup duration
+	assert.False(t, warmup.Read(false, start.Add(25*time.Minute)))
+	assert.False(t, warmup.Read(true, start.Add(26*time.Minute)))
+	assert.False(t, warmup.Read(true, start.Add(55*time.Minute)))
+	assert.True(t, warmup.WarmingUp())
+
+	assert.True(t, warmup.Read(true, start.Add(56*time.Minute)))
+	assert.False(t, warmup.WarmingUp())
+	state := warmup.State(start.Add(56 * time.Minute))
+	assert.Equal(t, WarmupState{StartedAt: start.UnixNano(), InBandSince: start.Add(26 * time.Minute).UnixNano()}, state)
+
+	// a warmed up cooler is not held again
+	assert.False(t, warmup.Read(false, start.Add(time.Hour)))
+	assert.False(t, warmup.WarmingUp())
+}
+
+func TestWarmupOverride(t *testing.T) {
+	warmup, err := NewWarmup(config.WarmupConfig{Duration: "30m"}, time.Now())
+	require.NoError(t, err)
+	assert.True(t, warmup.Override())
+	assert.False(t, warmup.Override())
+	assert.False(t, warmup.WarmingUp())
+	assert.True(t, warmup.State(time.Now()).Overridden)
+
+	var disabled *Warmup
+	assert.False(t, disabled.Read(false, time.Now()))
+	assert.False(t, disabled.Override())
+	assert.False(t, disabled.WarmingUp())
+	assert.Equal(t, WarmupState{}, disabled.State(time.Now()))
+}
+
+func TestProcessWarmup(t *testing.T) {
+	var submitted []ControllerBoardStatus
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var status ControllerBoardStatus
+		assert.NoError(t, json.NewDecoder(request.Body).Decode(&status))
+		submitted = append(submitted, status)
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	warmup, err := NewWarmup(config.WarmupConfig{Duration: "30m"}, time.Now())
+	require.NoError(t, err)
+	boardStatus := CheckBoardStatus{
+		Configuration:         &config.ControllerBoardStatusConfig{VendingEndpoint: server.URL},
+		ControllerBoardStatus: &ControllerBoardStatus{MaxTemperatureStatus: true},
+		Warmup:                warmup,
+		restCommandTimeout:    time.Second,
+	}
+	lc := logger.NewMockClient()
+
+	require.NoError(t, boardStatus.processWarmup(lc))
+	require.Len(t, submitted, 1)
+	assert.True(t, submitted[0].Warmup)
+	require.NoError(t, boardStatus.processWarmup(lc))
+	assert.Len(t, submitted, 1)
+
+	state, err := boardStatus.OverrideWarmup(lc)
+	require.NoError(t, err)
+	assert.False(t, state.WarmingUp)
+	require.Len(t, submitted, 2)
+	assert.False(t, submitted[1].Warmup)
+
+	// a failed override can be retried
+	_, err = boardStatus.OverrideWarmup(lc)
+	require.NoError(t, err)
+	require.Len(t, submitted, 3)
+	assert.False(t, submitted[2].Warmup)
+}
@@ -8,15 +8,25 @@ import (
 	"as-controller-board-status/functions"
 	"as-controller-board-status/routes"
 	"os"
+	"time"
+	// the time zone database is embedded, as the container image has none
+	_ "time/tzdata"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/transforms"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 const (
 	serviceKey = "as-controller-board-status"
+	// authTokenSecretName is the secret of the secret store that holds the
+	// AuthTokenSecret shared with ms-authentication
+	authTokenSecretName = "authtoken"
+	authTokenSecretKey  = "AuthTokenSecret"
 )
 
 type boardStatusAppService struct {
@@ -40,6 +50,8 @@ func (app *boardStatusAppService) CreateAndRunAppService(serviceKey string, newS
 	}
 
 	app.lc = app.service.LoggingClient()
+	// the build is logged first, so that every log shows what was running
+	app.lc.Info("starting service", routes.CurrentVersion().LogFields()...)
 
 	subscriptionClient := app.service.SubscriptionClient()
 	if subscriptionClient == nil {
@@ -88,9 +100,45 @@ func (app *boardStatusAppService) CreateAndRunAppService(serviceKey string, newS
 
 	app.boardStatus.NotificationClient = notificationClient
 
+	// notifications are sent in the background and retried, so that a slow
+	// or unreachable notification service never holds up the readings
+	app.boardStatus.Notifications, err = functions.NewNotificationDispatcher(app.lc, app.serviceConfig.Notifications, func(message string) error {
+		return app.boardStatus.SendNotification(message)
+	})
+	if err != nil {
+		app.lc.Errorf("failed to validate Notifications configuration: %v", err)
+		return 1
+	}
+	go app.boardStatus.Notifications.Run(app.service.AppContext())
+
+	// door readings are debounced, and a door sensor that cannot be trusted
+	// is alerted
+	app.boardStatus.DoorSensor, err = functions.NewDoorSensor(app.serviceConfig.DoorSensor)
+	if err != nil {
+		app.lc.Errorf("failed to validate DoorSensor configuration: %v", err)
+		return 1
+	}
+
 	app.boardStatus.MaxTemperatureThreshold = app.boardStatus.Configuration.MaxTemperatureThreshold
 	app.boardStatus.MinTemperatureThreshold = app.boardStatus.Configuration.MinTemperatureThreshold
 
+	// the thresholds adjusted by operators at runtime are kept on disk, and
+	// override the configured thresholds across restarts
+	app.boardStatus.Thresholds, err = functions.NewThresholdStore(app.serviceConfig.Thresholds, app.serviceConfig.ControllerBoardStatus)
+	if err != nil {
+		app.lc.Errorf("failed to validate Thresholds configuration: %v", err)
+		return 1
+	}
+	// vending is held after power-on until the cooler has cooled down
+	app.boardStatus.Warmup, err = functions.NewWarmup(app.serviceConfig.Warmup, time.Now())
+	if err != nil {
+		app.lc.Errorf("failed to validate Warmup configuration: %v", err)
+		return 1
+	}
+	// the AuthTokenSecret is a credential, so it is read from the secret
+	// store rather than the configuration
+	authTokenSecret := app.authTokenSecret()
+
 	app.boardStatus.CommandClient = app.service.CommandClient()
 	if app.boardStatus.CommandClient == nil {
 		app.lc.Error("error command service missing from client's configuration")
@@ -100,6 +148,9 @@ func (app *boardStatusAppService) CreateAndRunAppService(serviceKey string, newS
 	// Create the function pipeline to run when an event is read on the device channels
 	err = app.service.SetDefaultFunctionsPipeline(
 		transforms.NewFilterFor([]string{app.boardStatus.Configuration.DeviceName}).FilterByDeviceName,
+		// binary and object readings, such as those of CBOR events, are decoded
+		// into reading values
+		functions.NewReadingDecoder().DecodeReadings,
 		app.boardStatus.CheckControllerBoardStatus,
 	)
 
@@ -108,7 +159,32 @@ func (app *boardStatusAppService) CreateAndRunAppService(serviceKey string, newS
 		return 1
 	}
 
-	controller := routes.NewController(app.lc, app.service, &app.boardStatus)
+	reportScheduler, err := functions.NewReportScheduler(app.lc, app.serviceConfig.Reports, &app.boardStatus)
+	if err != nil {
+		app.lc.Errorf("failed to validate Reports configuration: %v", err)
+		return 1
+	}
+	if reportScheduler.Scheduled() {
+		go reportScheduler.Run(app.service.AppContext())
+	}
+
+	// the heartbeat tells the fleet that the kiosk is powered on
+	heartbeatInterval, err := functions.ParseHeartbeatInterval(app.serviceConfig.Heartbeat.IntervalDuration)
+	if err != nil {
+		app.lc.Errorf("failed to validate Heartbeat configuration: %v", err)
+		return 1
+	}
+	if heartbeatInterval > 0 {
+		if app.serviceConfig.Heartbeat.KioskID == "" {
+			app.lc.Error("failed to validate Heartbeat configuration: KioskID is empty")
+			return 1
+		}
+		go app.boardStatus.PublishHeartbeats(app.service.AppContext(), app.lc, serviceKey, app.serviceConfig.Heartbeat.KioskID, routes.CurrentVersion().Version, heartbeatInterval, func(topic string, event dtos.Event) error {
+			return app.service.PublishWithTopic(topic, event, common.ContentTypeJSON)
+		})
+	}
+
+	controller := routes.NewController(app.lc, app.service, &app.boardStatus, reportScheduler, utilities.NewTokenVerifier(authTokenSecret))
 	err = controller.AddAllRoutes()
 	if err != nil {
 		app.lc.Errorf("failed to add all Routes: %s", err.Error())
@@ -124,3 +200,18 @@ func (app *boardStatusAppService) CreateAndRunAppService(serviceKey string, newS
 
 	return 0
 }
+
+// authTokenSecret returns the AuthTokenSecret shared with ms-authentication
+// from the secret store, or empty when it is not stored, which leaves the
+// administrative routes open
+func (app *boardStatusAppService) authTokenSecret() string {
+	secrets, err := app.service.SecretProvider().GetSecret(authTokenSecretName, authTokenSecretKey)
+	if err != nil {
+		app.lc.Warnf("AuthTokenSecret is not in the secret store, the administrative routes are open: %s", err.Error())
+		return ""
+	}
+	if secrets[authTokenSecretKey] == "" {
+		app.lc.Warn("AuthTokenSecret is not set in the secret store, the administrative routes are open")
+	}
+	return secrets[authTokenSecretKey]
+}
@@ -7,24 +7,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"as-controller-board-status/functions"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 type Controller struct {
-	lc          logger.LoggingClient
-	service     interfaces.ApplicationService
-	boardStatus *functions.CheckBoardStatus
+	lc              logger.LoggingClient
+	service         interfaces.ApplicationService
+	boardStatus     *functions.CheckBoardStatus
+	reportScheduler *functions.ReportScheduler
+	// tokenVerifier checks the tokens of the administrative routes, nil
+	// leaves them open
+	tokenVerifier *utilities.TokenVerifier
 }
 
-func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, boardStatus *functions.CheckBoardStatus) Controller {
+func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, boardStatus *functions.CheckBoardStatus, reportScheduler *functions.ReportScheduler, tokenVerifier *utilities.TokenVerifier) Controller {
 	return Controller{
-		lc:          lc,
-		service:     service,
-		boardStatus: boardStatus,
+		lc:              lc,
+		service:         service,
+		boardStatus:     boardStatus,
+		reportScheduler: reportScheduler,
+		tokenVerifier:   tokenVerifier,
 	}
 }
 
@@ -34,6 +44,51 @@ func (c *Controller) AddAllRoutes() error {
 	if err != nil {
 		return fmt.Errorf("error adding route: %s", err.Error())
 	}
+
+	err = c.service.AddRoute("/status/thresholds", c.ThresholdsGet, http.MethodGet, http.MethodOptions)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/status/thresholds", c.requireMaintainer(c.ThresholdsPut), http.MethodPut)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/status/warmup", c.WarmupGet, http.MethodGet, http.MethodOptions)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/status/warmup/override", c.requireMaintainer(c.WarmupOverridePost), http.MethodPost)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/version", utilities.VersionHandler(CurrentVersion()), http.MethodGet, http.MethodOptions)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/reports/{report}", c.ReportPost, http.MethodPost, http.MethodOptions)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/notifications", c.NotificationsGet, http.MethodGet, http.MethodOptions)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/notifications/deadLetters/{id}", c.DeadLetterRetry, http.MethodPost, http.MethodOptions)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
+
+	err = c.service.AddRoute("/notifications/deadLetters/{id}", c.DeadLetterDelete, http.MethodDelete)
+	if err != nil {
+		return fmt.Errorf("error adding route: %s", err.Error())
+	}
 	return nil
 }
 
@@ -52,3 +107,177 @@ func (c *Controller) GetStatus(writer http.ResponseWriter, req *http.Request) {
 	c.lc.Info("GetStatus successfully!")
 	writer.Write(controllerBoardStatus)
 }
+
+// ThresholdsGet returns the temperature and humidity thresholds the
+// readings of the controller board are checked against
+func (c *Controller) ThresholdsGet(writer http.ResponseWriter, req *http.Request) {
+	thresholdsJSON, err := json.Marshal(c.boardStatus.CurrentThresholds())
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to serialize the thresholds: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", functions.ApplicationJSONContentType)
+	writer.Write(thresholdsJSON)
+}
+
+// ThresholdsPut replaces the thresholds at runtime, such as for a seasonal
+// adjustment, and keeps them across restarts. The next reading is checked
+// against them.
+func (c *Controller) ThresholdsPut(writer http.ResponseWriter, req *http.Request) {
+	if c.boardStatus.Thresholds == nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		writer.Write([]byte("The thresholds cannot be changed at runtime"))
+		return
+	}
+	var thresholds functions.Thresholds
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&thresholds); err != nil {
+		errMsg := fmt.Sprintf("Failed to read the thresholds: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if err := thresholds.Validate(); err != nil {
+		errMsg := fmt.Sprintf("Invalid thresholds: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if err := c.boardStatus.Thresholds.Set(thresholds); err != nil {
+		errMsg := fmt.Sprintf("Failed to keep the thresholds: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("The thresholds were changed to %+v", thresholds)
+	c.ThresholdsGet(writer, req)
+}
+
+// WarmupGet returns whether vending is held after power-on until the cooler
+// has cooled down
+func (c *Controller) WarmupGet(writer http.ResponseWriter, req *http.Request) {
+	c.writeWarmupState(writer, c.boardStatus.Warmup.State(time.Now()))
+}
+
+// WarmupOverridePost ends the warmup as requested by an operator, such as
+// after checking the product temperature by hand, so that vending is
+// allowed right away
+func (c *Controller) WarmupOverridePost(writer http.ResponseWriter, req *http.Request) {
+	state, err := c.boardStatus.OverrideWarmup(c.lc)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to override the warmup: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadGateway)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.writeWarmupState(writer, state)
+}
+
+func (c *Controller) writeWarmupState(writer http.ResponseWriter, state functions.WarmupState) {
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to serialize the warmup state: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", functions.ApplicationJSONContentType)
+	writer.Write(stateJSON)
+}
+
+// ReportPost generates a report now and delivers it like its scheduled runs,
+// so that an operator can get the numbers before the next schedule. The
+// report is also returned in the response.
+func (c *Controller) ReportPost(writer http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["report"]
+	if !isReportName(name) {
+		errMsg := fmt.Sprintf("Unknown report %q, the reports are %s", name, strings.Join(functions.ReportNames, ", "))
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	report, err := c.reportScheduler.RunReport(name)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to run the %s report: %s", name, err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to serialize the %s report: %s", name, err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Delivered the %s report", name)
+	writer.Header().Set("Content-Type", functions.ApplicationJSONContentType)
+	writer.Write(reportJSON)
+}
+
+// NotificationsGet returns the notifications waiting to be sent, and the
+// dead letters that failed on all of their attempts
+func (c *Controller) NotificationsGet(writer http.ResponseWriter, req *http.Request) {
+	queueJSON, err := json.Marshal(c.boardStatus.Notifications.Queue())
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to serialize the notification queue: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", functions.ApplicationJSONContentType)
+	writer.Write(queueJSON)
+}
+
+// DeadLetterRetry queues a dead letter to be sent again, once the cause of
+// its failure has been fixed
+func (c *Controller) DeadLetterRetry(writer http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	if err := c.boardStatus.Notifications.RetryDeadLetter(id); err != nil {
+		errMsg := fmt.Sprintf("Failed to retry the notification %s: %s", id, err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Queued the notification %s again", id)
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// DeadLetterDelete drops a dead letter that is no longer worth sending
+func (c *Controller) DeadLetterDelete(writer http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	if err := c.boardStatus.Notifications.DeleteDeadLetter(id); err != nil {
+		errMsg := fmt.Sprintf("Failed to delete the notification %s: %s", id, err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Deleted the notification %s", id)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func isReportName(name string) bool {
+	for _, reportName := range functions.ReportNames {
+		if name == reportName {
+			return true
+		}
+	}
+	return false
+}
@@ -6,16 +6,21 @@ package routes
 import (
 	"as-controller-board-status/config"
 	"as-controller-board-status/functions"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces/mocks"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -136,7 +141,7 @@ func TestNewController(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := NewController(tt.lc, tt.service, tt.boardStatus)
+			got := NewController(tt.lc, tt.service, tt.boardStatus, nil, nil)
 			require.NotEmpty(t, got)
 			require.Equal(t, tt.lc, got.lc, "logging is not the same")
 			require.Equal(t, tt.service, got.service, "service is not the same")
@@ -145,3 +150,171 @@ func TestNewController(t *testing.T) {
 		})
 	}
 }
+
+func TestController_ReportPost(t *testing.T) {
+	boardStatus := &functions.CheckBoardStatus{Configuration: &config.ControllerBoardStatusConfig{RESTCommandTimeoutDuration: "15s"}}
+	reportScheduler, err := functions.NewReportScheduler(logger.NewMockClient(), config.ReportsConfig{}, boardStatus)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		report         string
+		expectedStatus int
+	}{
+		{
+			name:           "unknown report",
+			report:         "weekly-sales",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			// the temperature readings are not tracked without a schedule
+			name:           "report failed",
+			report:         functions.ReportTemperatureCompliance,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/reports/"+tt.report, nil)
+			req = mux.SetURLVars(req, map[string]string{"report": tt.report})
+			w := httptest.NewRecorder()
+			c := NewController(logger.NewMockClient(), &mocks.ApplicationService{}, boardStatus, reportScheduler, nil)
+
+			c.ReportPost(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestController_DeadLetters(t *testing.T) {
+	dispatcher, err := functions.NewNotificationDispatcher(logger.NewMockClient(), config.NotificationsConfig{
+		QueueFile:   filepath.Join(t.TempDir(), "queue.json"),
+		MaxAttempts: 1,
+	}, func(message string) error {
+		return fmt.Errorf("notification service unreachable")
+	})
+	require.NoError(t, err)
+	dispatcher.Enqueue("door left open")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+	require.Eventually(t, func() bool {
+		return len(dispatcher.Queue().DeadLetters) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	c := NewController(logger.NewMockClient(), &mocks.ApplicationService{}, &functions.CheckBoardStatus{Notifications: dispatcher}, nil, nil)
+
+	w := httptest.NewRecorder()
+	c.NotificationsGet(w, httptest.NewRequest(http.MethodGet, "/notifications", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var queue functions.NotificationQueue
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queue))
+	require.Len(t, queue.DeadLetters, 1)
+	id := queue.DeadLetters[0].ID
+	assert.Equal(t, "notification service unreachable", queue.DeadLetters[0].LastError)
+
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		expectedStatus int
+	}{
+		{"retry unknown", http.MethodPost, "unknown", http.StatusNotFound},
+		{"retry", http.MethodPost, id, http.StatusAccepted},
+		{"delete queued", http.MethodDelete, id, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/notifications/deadLetters/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+			if tt.method == http.MethodPost {
+				c.DeadLetterRetry(w, req)
+			} else {
+				c.DeadLetterDelete(w, req)
+			}
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+	assert.Len(t, dispatcher.Queue().Pending, 1)
+}
+
+func TestController_Thresholds(t *testing.T) {
+	store, err := functions.NewThresholdStore(config.ThresholdsConfig{File: filepath.Join(t.TempDir(), "thresholds.json")}, config.ControllerBoardStatusConfig{MinTemperatureThreshold: 10, MaxTemperatureThreshold: 83})
+	require.NoError(t, err)
+	c := NewController(logger.NewMockClient(), &mocks.ApplicationService{}, &functions.CheckBoardStatus{Thresholds: store}, nil, nil)
+
+	w := httptest.NewRecorder()
+	c.ThresholdsGet(w, httptest.NewRequest(http.MethodGet, "/status/thresholds", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"minTemperature":10,"maxTemperature":83,"minHumidity":0,"maxHumidity":0}`, w.Body.String())
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"winter", `{"minTemperature":15,"maxTemperature":80,"minHumidity":20,"maxHumidity":50}`, http.StatusOK},
+		{"empty range", `{"minTemperature":80,"maxTemperature":15}`, http.StatusBadRequest},
+		{"unknown field", `{"minTemperature":15,"maxTemperature":80,"maxPressure":2}`, http.StatusBadRequest},
+		{"invalid JSON", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c.ThresholdsPut(w, httptest.NewRequest(http.MethodPut, "/status/thresholds", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+	assert.Equal(t, functions.Thresholds{MinTemperature: 15, MaxTemperature: 80, MinHumidity: 20, MaxHumidity: 50}, store.Get())
+
+	// without a store the configured thresholds are returned, and cannot
+	// be changed
+	c = NewController(logger.NewMockClient(), &mocks.ApplicationService{}, &functions.CheckBoardStatus{Configuration: &config.ControllerBoardStatusConfig{MinTemperatureThreshold: 10, MaxTemperatureThreshold: 83}}, nil, nil)
+	w = httptest.NewRecorder()
+	c.ThresholdsGet(w, httptest.NewRequest(http.MethodGet, "/status/thresholds", nil))
+	assert.JSONEq(t, `{"minTemperature":10,"maxTemperature":83,"minHumidity":0,"maxHumidity":0}`, w.Body.String())
+	w = httptest.NewRecorder()
+	c.ThresholdsPut(w, httptest.NewRequest(http.MethodPut, "/status/thresholds", strings.NewReader(`{"minTemperature":15,"maxTemperature":80}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestController_Warmup(t *testing.T) {
+	warmup, err := functions.NewWarmup(config.WarmupConfig{Duration: "30m"}, time.Now())
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	c := NewController(logger.NewMockClient(), &mocks.ApplicationService{}, &functions.CheckBoardStatus{
+		Configuration:         &config.ControllerBoardStatusConfig{VendingEndpoint: server.URL},
+		ControllerBoardStatus: &functions.ControllerBoardStatus{},
+		Warmup:                warmup,
+	}, nil, nil)
+
+	w := httptest.NewRecorder()
+	c.WarmupGet(w, httptest.NewRequest(http.MethodGet, "/status/warmup", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var state functions.WarmupState
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.True(t, state.WarmingUp)
+	assert.Equal(t, 30*60.0, state.RemainingSeconds)
+
+	w = httptest.NewRecorder()
+	c.WarmupOverridePost(w, httptest.NewRequest(http.MethodPost, "/status/warmup/override", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.False(t, state.WarmingUp)
+	assert.True(t, state.Overridden)
+	assert.False(t, warmup.WarmingUp())
+
+	// the vending service must be told of the override
+	server.Close()
+	w = httptest.NewRecorder()
+	c.WarmupOverridePost(w, httptest.NewRequest(http.MethodPost, "/status/warmup/override", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
@@ -0,0 +1,20 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import "github.com/intel-iot-devkit/automated-checkout-utilities"
+
+// versionServiceName is the name of the service in its build
+const versionServiceName = "as-controller-board-status"
+
+// schemaVersions are the versions of the layouts of the data this service
+// stores, each bumped when its layout changes
+var schemaVersions = map[string]int{
+	"notificationQueue": 1,
+}
+
+// CurrentVersion returns the build of the running service
+func CurrentVersion() utilities.VersionInfo {
+	return utilities.CurrentVersion(versionServiceName, utilities.AppSDKModulePath, schemaVersions)
+}
@@ -0,0 +1,16 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentVersion(t *testing.T) {
+	info := CurrentVersion()
+	assert.Equal(t, "as-controller-board-status", info.ServiceName)
+	assert.Equal(t, 1, info.SchemaVersions["notificationQueue"])
+}
@@ -31,8 +31,222 @@ type VendingConfig struct {
 	InventoryService               string
 	LCDRowLength                   int
 	LedgerService                  string
+	// SplitBasketRule is the ledger split rule used when a second customer
+	// badge is scanned before the door opens. Empty disables split baskets.
+	SplitBasketRule string
+	// PreAuthorizeCustomers has the ledger service place a hold on a
+	// customer's stored payment method before the door is unlocked
+	PreAuthorizeCustomers bool
+	// PreAuthHoldAmount is the amount held for a customer of this kiosk, in
+	// the ledger's currency. 0 holds the amount configured in ms-ledger.
+	PreAuthHoldAmount float64
+	// AuthSLADuration, UnlockSLADuration and InferenceSLADuration are the
+	// vend workflow stage targets, an empty duration disables the target
+	AuthSLADuration      string
+	UnlockSLADuration    string
+	InferenceSLADuration string
+	// SLAAlertTopic is the message bus topic SLA breaches are published to.
+	// Empty disables publishing.
+	SLAAlertTopic string
+	// CardReaderHeartbeatTimeoutDuration is how long the card reader may be
+	// silent before it is considered offline. Empty disables monitoring.
+	CardReaderHeartbeatTimeoutDuration string
+	// ReaderAlertTopic is the message bus topic card reader faults are
+	// published to. Empty disables publishing.
+	ReaderAlertTopic string
+	// SessionLingerDuration is how long a customer has to scan their card
+	// again and reopen the door, with every visit charged as one basket.
+	// Empty disables sessions.
+	SessionLingerDuration string
+	// BillingFailureThreshold is how many transactions in a row may fail to
+	// post to the ledger service before vending is suspended. 0 disables
+	// suspending vending.
+	BillingFailureThreshold int
+	// BillingAlertTopic is the message bus topic billing failures that
+	// suspend vending are published to. Empty disables publishing.
+	BillingAlertTopic string
+	// KioskID identifies this vending machine in its store state, which is
+	// the confirmation of a remote open or close
+	KioskID string
+	// FleetKiosks are the as-vending instances that the fleet endpoints open
+	// and close, as comma separated group:kioskId=url entries. Empty
+	// disables the fleet endpoints.
+	FleetKiosks string
+	// FleetRequestTimeoutDuration is how long each kiosk has to confirm a
+	// fleet request. Empty is 5s.
+	FleetRequestTimeoutDuration string
+	// HeartbeatIntervalDuration is how often the heartbeat of this kiosk is
+	// published to the message bus. Empty disables publishing.
+	HeartbeatIntervalDuration string
+	// FleetHeartbeatTimeoutDuration is how long a kiosk of the fleet may be
+	// silent before it is considered offline. Empty disables monitoring.
+	FleetHeartbeatTimeoutDuration string
+	// FleetAlertTopic is the message bus topic silent kiosks are published
+	// to. Empty disables publishing.
+	FleetAlertTopic string
+	// EnrollmentEndpoint is the authentication service endpoint that cards
+	// swiped in enrollment mode are enrolled at. Empty disables enrollment.
+	EnrollmentEndpoint string
+	// EnrollmentTimeoutDuration is how long enrollment mode waits for a card
+	// swipe. Empty is 60s.
+	EnrollmentTimeoutDuration string
+	// IdleDisplayScreens are the LCD screens rotated while the kiosk is
+	// idle. Screens are separated by ';' and their rows by '|', and rows are
+	// templates of the time, date and kiosk ID. Empty disables the rotation.
+	IdleDisplayScreens string
+	// IdleDisplayIntervalDuration is how long each idle screen is shown,
+	// unless it has its own duration. Empty is 10s.
+	IdleDisplayIntervalDuration string
+	// SessionDisplayScreen is the LCD screen shown during a vend, whose rows
+	// are separated by '|' and are templates of the session's progress, such
+	// as its countdown. Empty leaves the LCD to the vend workflow's messages.
+	SessionDisplayScreen string
+	// AuthTokenSecret is the secret shared with ms-authentication that its
+	// tokens are signed with. With it, the administrative routes need the
+	// token of a maintainer card. Empty leaves them open.
+	AuthTokenSecret string
+	// BarcodeScannerDeviceName is the barcode scanner whose scans between
+	// vends are price checks. Empty disables price checks.
+	BarcodeScannerDeviceName string
+	// PriceCheckEndpoint is the inventory service endpoint that scanned
+	// barcodes are looked up at
+	PriceCheckEndpoint string
+	// PriceCheckTopic is the message bus topic price checks are published to
+	// for demand analytics. Empty disables publishing.
+	PriceCheckTopic string
+	// AuthorizationEndpoint is the authentication service endpoint that
+	// checks whether a card may restock or enter maintenance mode. Empty
+	// lets the card's role alone decide.
+	AuthorizationEndpoint string
+	// PinVerificationEndpoint is the authentication service endpoint that
+	// verifies the PIN entered for a card that needs one. Empty refuses
+	// those cards.
+	PinVerificationEndpoint string
+	// PinEntryTimeoutDuration is how long the kiosk waits for the PIN of a
+	// card. Empty is 30s.
+	PinEntryTimeoutDuration string
+	// SessionJournalFile is the JSON file that the vend in progress is kept
+	// in, so that it is resumed or safely ended when the service restarts.
+	// Empty disables the journal.
+	SessionJournalFile string
+	// Doors are the other doors of a bank of coolers that this service
+	// runs, as comma separated controllerBoard:inferenceDevice:cardReader
+	// entries, followed by :weightSensor for a door with a weight sensor.
+	// Empty runs only the door of ControllerBoardDeviceName.
+	Doors string
+	// InferenceFallback is how customers vend while the inference service
+	// does not respond to its heartbeat: manualEntry has the items taken
+	// entered on the kiosk UI, and billLater lets them take items that are
+	// billed from the camera snapshots later. Empty puts the vending
+	// machine in maintenance mode instead.
+	InferenceFallback string
+	// MaintenanceStateFile is the JSON file that the maintenance reasons of
+	// each door are kept in, so that they survive a restart of the service.
+	// Empty keeps them in memory.
+	MaintenanceStateFile string
+	// MaintenanceTopic is the message bus topic that maintenance mode
+	// changes are published to. Empty disables publishing.
+	MaintenanceTopic string
+	// OutboxFile is the JSON file that the charges, inventory deltas and
+	// audit log entries that failed to be sent are kept in, to be replayed.
+	// Empty keeps them in memory.
+	OutboxFile string
+	// InferenceMinConfidence is the confidence of an inference result below
+	// which its vend is flagged for review instead of being charged. 0
+	// disables the check.
+	InferenceMinConfidence float64
+	// InferenceMinItemConfidence is the confidence of an item of an
+	// inference result below which its vend is flagged for review. 0
+	// disables the check.
+	InferenceMinItemConfidence float64
+	// MaxItemsPerSession is the number of items a session may take before
+	// it is flagged for review. 0 disables the check.
+	MaxItemsPerSession int
+	// PlanogramEndpoint is the inventory service's planogram endpoint, and
+	// a session that takes a SKU that is not stocked in any of its slots is
+	// flagged for review. Empty disables the check.
+	PlanogramEndpoint string
+	// WebhookURLs are the comma separated URLs that door unlocked, session
+	// completed, timeout and maintenance entered events are posted to.
+	// Empty disables webhooks.
+	WebhookURLs string
+	// WebhookSecret is the key of the HMAC-SHA256 signature of each webhook
+	// request. Empty sends them unsigned.
+	WebhookSecret string
+	// WebhookMaxAttempts is how many times an event is posted to a webhook
+	// before it is dropped. 0 is 5.
+	WebhookMaxAttempts int
+	// WebhookRetryIntervalDuration is how long to wait before posting a
+	// failed event again, doubled with each attempt up to 5m. Empty is 5s.
+	WebhookRetryIntervalDuration string
+	// RemoteCommandBroker is the MQTT broker that the remote commands of
+	// fleet-management tooling are received from, i.e.
+	// tcp://edgex-mqtt-broker:1883. Empty disables remote commands.
+	RemoteCommandBroker string
+	// RemoteCommandTopic is the MQTT topic of the remote commands, and their
+	// results are published to it followed by /response
+	RemoteCommandTopic string
+	// RemoteCommandAllowList are the comma separated remote commands that
+	// may be run on this kiosk, of unlock, lock, rebootDisplay and
+	// enterMaintenance
+	RemoteCommandAllowList string
+	// InferenceRetryAttempts is how many times the InferenceDoorStatusCmd is
+	// issued to the inference device again when its result does not arrive
+	// within the InferenceTimeoutDuration, before maintenance mode is
+	// entered. 0 disables retries.
+	InferenceRetryAttempts int
+	// InferenceRetryIntervalDuration is how long to wait for the result of
+	// the first retry, doubled with each next one. Empty is 5s.
+	InferenceRetryIntervalDuration string
+	// InferenceSecondaryDeviceName is the inference device that the
+	// InferenceDoorStatusCmd is issued to once the retries of the inference
+	// device are used up. Empty disables the secondary device.
+	InferenceSecondaryDeviceName string
+	// WeightSensorDeviceName is the weight sensor of the shelves of the door,
+	// whose weightDelta readings are the grams they gained since the door was
+	// opened, negative when items were taken. The inference result of each
+	// vend is cross-checked against it. Empty disables the cross-check.
+	WeightSensorDeviceName string
+	// CrossCheckProductEndpoint is the inventory service endpoint that the
+	// category and the unit weight of the SKUs of an inference result are
+	// looked up at
+	CrossCheckProductEndpoint string
+	// CrossCheckToleranceGrams is how far the weight measured may be from the
+	// weight of the inference result while they still agree. 0 is 10.
+	CrossCheckToleranceGrams float64
+	// CrossCheckPolicy is how a disagreement is resolved: trustCV charges the
+	// inference result, trustWeight charges the quantity of its SKU that the
+	// weight measured is, requireAgreement flags the vend for review, and
+	// weightedScore flags it when the score of the inference confidence and
+	// the weight agreement is below CrossCheckMinScore. Empty is
+	// requireAgreement.
+	CrossCheckPolicy string
+	// CrossCheckClassPolicies are the policies of SKU classes, which are the
+	// categories of the products in inventory, as comma separated
+	// class:policy entries. The strictest policy of the classes of a result
+	// resolves it, and the other classes have the CrossCheckPolicy.
+	CrossCheckClassPolicies string
+	// CrossCheckCVWeight is the weight of the inference confidence in the
+	// weighted score, the weight agreement having the rest. 0 is 0.5.
+	CrossCheckCVWeight float64
+	// CrossCheckMinScore is the weighted score below which the vend is
+	// flagged for review. 0 is 0.5.
+	CrossCheckMinScore float64
+	// WeightDiscrepancyFile is the JSON file that the disagreements between
+	// the inference results and the weight sensor are kept in, as feedback
+	// for the inference model. Empty keeps them in memory.
+	WeightDiscrepancyFile string
 }
 
+// SplitBasketRuleEven splits the basket evenly between the payers
+const SplitBasketRuleEven = "even"
+
+// The InferenceFallback flows of vends while inference is unavailable
+const (
+	InferenceFallbackManualEntry = "manualEntry"
+	InferenceFallbackBillLater   = "billLater"
+)
+
 // UpdateFromRaw updates the service's full configuration from raw data received from
 // the Service Provider.
 func (c *ServiceConfig) UpdateFromRaw(rawConfig interface{}) bool {
@@ -128,5 +342,59 @@ func (ac *VendingConfig) Validate() error {
 		return fmt.Errorf("configuration LedgerService is empty")
 	}
 
+	if ac.BillingFailureThreshold < 0 {
+		return fmt.Errorf("configuration BillingFailureThreshold is negative")
+	}
+
+	if ac.PreAuthHoldAmount < 0 {
+		return fmt.Errorf("configuration PreAuthHoldAmount is negative")
+	}
+
+	// itemized splits need someone to assign the items, so only an even
+	// split can be done at the machine
+	if ac.SplitBasketRule != "" && ac.SplitBasketRule != SplitBasketRuleEven {
+		return fmt.Errorf("configuration SplitBasketRule must be empty or %q", SplitBasketRuleEven)
+	}
+
+	if ac.InferenceMinConfidence < 0 || ac.InferenceMinConfidence > 1 {
+		return fmt.Errorf("configuration InferenceMinConfidence must be between 0 and 1")
+	}
+
+	if ac.InferenceMinItemConfidence < 0 || ac.InferenceMinItemConfidence > 1 {
+		return fmt.Errorf("configuration InferenceMinItemConfidence must be between 0 and 1")
+	}
+
+	if ac.MaxItemsPerSession < 0 {
+		return fmt.Errorf("configuration MaxItemsPerSession is negative")
+	}
+
+	if ac.InferenceRetryAttempts < 0 {
+		return fmt.Errorf("configuration InferenceRetryAttempts is negative")
+	}
+
+	if ac.InferenceSecondaryDeviceName != "" && ac.InferenceSecondaryDeviceName == ac.InferenceDeviceName {
+		return fmt.Errorf("configuration InferenceSecondaryDeviceName must differ from InferenceDeviceName")
+	}
+
+	if ac.WeightSensorDeviceName != "" && ac.CrossCheckProductEndpoint == "" {
+		return fmt.Errorf("configuration CrossCheckProductEndpoint is required for the weight sensor")
+	}
+
+	// remote commands are authenticated with the tokens of maintainer cards
+	if ac.RemoteCommandBroker != "" {
+		if ac.RemoteCommandTopic == "" {
+			return fmt.Errorf("configuration RemoteCommandTopic is empty")
+		}
+		if ac.AuthTokenSecret == "" {
+			return fmt.Errorf("configuration AuthTokenSecret is required for remote commands")
+		}
+	}
+
+	switch ac.InferenceFallback {
+	case "", InferenceFallbackManualEntry, InferenceFallbackBillLater:
+	default:
+		return fmt.Errorf("configuration InferenceFallback must be empty, %q or %q", InferenceFallbackManualEntry, InferenceFallbackBillLater)
+	}
+
 	return nil
 }
@@ -0,0 +1,160 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// BillingAlert is the context of the billing circuit opening after too many
+// transactions in a row failed to post to the ledger service. It is logged,
+// and published as an alert when an alert function is set.
+type BillingAlert struct {
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError"`
+	Timestamp           int64  `json:"timestamp,string"`
+}
+
+// BillingStatus is the state of the billing circuit for REST API consumers
+type BillingStatus struct {
+	Suspended           bool   `json:"suspended"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	FailureThreshold    int    `json:"failureThreshold"`
+	SuspendedAt         int64  `json:"suspendedAt,string,omitempty"`
+	LastError           string `json:"lastError,omitempty"`
+}
+
+// BillingCircuit counts the transactions in a row that failed to post to the
+// ledger service, and opens once they reach the threshold, so that product
+// is not given away while billing is broken. It stays open until it is
+// resumed by an operator. A nil BillingCircuit never opens.
+type BillingCircuit struct {
+	mutex       sync.Mutex
+	threshold   int
+	failures    int
+	suspended   bool
+	suspendedAt time.Time
+	lastError   string
+	alert       func(BillingAlert) error
+	now         func() time.Time
+}
+
+// NewBillingCircuit creates a BillingCircuit that opens after threshold
+// failed transactions in a row. alert is called when the circuit opens and
+// may be nil.
+func NewBillingCircuit(threshold int, alert func(BillingAlert) error) *BillingCircuit {
+	return &BillingCircuit{
+		threshold: threshold,
+		alert:     alert,
+		now:       time.Now,
+	}
+}
+
+// Record records whether a transaction posted to the ledger service. It
+// returns true when the failure opened the circuit.
+func (circuit *BillingCircuit) Record(lc logger.LoggingClient, err error) bool {
+	if circuit == nil {
+		return false
+	}
+	circuit.mutex.Lock()
+	if err == nil {
+		// a transaction posted while suspended does not resume vending,
+		// which is left to the operator
+		circuit.failures = 0
+		circuit.mutex.Unlock()
+		return false
+	}
+	circuit.failures++
+	circuit.lastError = err.Error()
+	if circuit.suspended || circuit.failures < circuit.threshold {
+		circuit.mutex.Unlock()
+		return false
+	}
+	circuit.suspended = true
+	circuit.suspendedAt = circuit.now()
+	alert := BillingAlert{
+		ConsecutiveFailures: circuit.failures,
+		LastError:           circuit.lastError,
+		Timestamp:           circuit.suspendedAt.UnixNano(),
+	}
+	circuit.mutex.Unlock()
+
+	lc.Errorf("%d transactions in a row failed to post to the ledger, vending is suspended until it is resumed: %s", alert.ConsecutiveFailures, alert.LastError)
+	if circuit.alert != nil {
+		if err := circuit.alert(alert); err != nil {
+			lc.Errorf("failed to alert the billing failure: %s", err.Error())
+		}
+	}
+	return true
+}
+
+// Suspended reports whether the circuit is open
+func (circuit *BillingCircuit) Suspended() bool {
+	if circuit == nil {
+		return false
+	}
+	circuit.mutex.Lock()
+	defer circuit.mutex.Unlock()
+	return circuit.suspended
+}
+
+// Resume closes the circuit and resets the failure count. It returns true
+// when the circuit was open.
+func (circuit *BillingCircuit) Resume() bool {
+	if circuit == nil {
+		return false
+	}
+	circuit.mutex.Lock()
+	defer circuit.mutex.Unlock()
+	wasSuspended := circuit.suspended
+	circuit.suspended = false
+	circuit.suspendedAt = time.Time{}
+	circuit.failures = 0
+	return wasSuspended
+}
+
+// Status returns the state of the circuit
+func (circuit *BillingCircuit) Status() BillingStatus {
+	if circuit == nil {
+		return BillingStatus{}
+	}
+	circuit.mutex.Lock()
+	defer circuit.mutex.Unlock()
+	status := BillingStatus{
+		Suspended:           circuit.suspended,
+		ConsecutiveFailures: circuit.failures,
+		FailureThreshold:    circuit.threshold,
+		LastError:           circuit.lastError,
+	}
+	if !circuit.suspendedAt.IsZero() {
+		status.SuspendedAt = circuit.suspendedAt.UnixNano()
+	}
+	return status
+}
+
+// recordCharge records whether a transaction posted to the ledger service
+// and how long it took, and takes the vending machine out of service when
+// billing is broken
+func (vendingState *VendingState) recordCharge(lc logger.LoggingClient, err error, latency time.Duration) {
+	vendingState.Metrics.RecordChargeLatency(latency)
+	if vendingState.Billing.Record(lc, err) {
+		vendingState.SetMaintenanceReason(lc, ReasonBillingUnavailable)
+	}
+}
+
+// ResumeBilling closes the billing circuit once an operator has fixed
+// billing, and takes the vending machine back into service unless another
+// condition keeps it out of service. It returns false when vending was not
+// suspended.
+func (vendingState *VendingState) ResumeBilling(lc logger.LoggingClient) bool {
+	if !vendingState.Billing.Resume() {
+		return false
+	}
+	lc.Info("Billing resumed by an operator")
+	vendingState.ClearMaintenanceReason(lc, ReasonBillingUnavailable)
+	return true
+}
@@ -0,0 +1,108 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillingCircuit(t *testing.T) {
+	var alerts []BillingAlert
+	circuit := NewBillingCircuit(3, func(alert BillingAlert) error {
+		alerts = append(alerts, alert)
+		return errors.New("message bus unavailable")
+	})
+	now := time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC)
+	circuit.now = func() time.Time { return now }
+	lc := logger.NewMockClient()
+	ledgerErr := errors.New("error sending command: received status code: 500 Internal Server Error")
+
+	// a transaction that posts resets the failures
+	assert.False(t, circuit.Record(lc, ledgerErr))
+	assert.False(t, circuit.Record(lc, ledgerErr))
+	assert.False(t, circuit.Record(lc, nil))
+	assert.Equal(t, BillingStatus{FailureThreshold: 3, LastError: ledgerErr.Error()}, circuit.Status())
+
+	assert.False(t, circuit.Record(lc, ledgerErr))
+	assert.False(t, circuit.Record(lc, ledgerErr))
+	assert.True(t, circuit.Record(lc, ledgerErr))
+	assert.True(t, circuit.Suspended())
+	require.Len(t, alerts, 1)
+	assert.Equal(t, BillingAlert{ConsecutiveFailures: 3, LastError: ledgerErr.Error(), Timestamp: now.UnixNano()}, alerts[0])
+
+	// an open circuit is only alerted once, and stays open until it is resumed
+	assert.False(t, circuit.Record(lc, ledgerErr))
+	assert.False(t, circuit.Record(lc, nil))
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, BillingStatus{Suspended: true, FailureThreshold: 3, SuspendedAt: now.UnixNano(), LastError: ledgerErr.Error()}, circuit.Status())
+
+	assert.True(t, circuit.Resume())
+	assert.False(t, circuit.Suspended())
+	assert.False(t, circuit.Resume())
+	assert.Equal(t, 0, circuit.Status().ConsecutiveFailures)
+}
+
+func TestBillingCircuitNil(t *testing.T) {
+	var circuit *BillingCircuit
+	assert.False(t, circuit.Record(logger.NewMockClient(), errors.New("ledger unavailable")))
+	assert.False(t, circuit.Suspended())
+	assert.False(t, circuit.Resume())
+	assert.Equal(t, BillingStatus{}, circuit.Status())
+}
+
+func TestBillingSuspendsVending(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName:      "controller-board",
+			ControllerBoardDisplayResetCmd: "displayReset",
+			ControllerBoardDisplayRow1Cmd:  "displayRow1",
+			ControllerBoardDisplayRow2Cmd:  "displayRow2",
+			ControllerBoardDisplayRow3Cmd:  "displayRow3",
+			LedgerService:                  testServer.URL,
+		},
+		CommandClient:   mockCommandClient,
+		CurrentUserData: OutputData{AccountID: 1, RoleID: 1},
+		Billing:         NewBillingCircuit(2, nil),
+	}
+	lc := logger.NewMockClient()
+
+	require.Error(t, vendingState.settleBasket(lc, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}))
+	assert.False(t, vendingState.MaintenanceMode)
+	require.Error(t, vendingState.settleBasket(lc, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}))
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonBillingUnavailable}, vendingState.MaintenanceReasons)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow2", map[string]string{"displayRow2": "Billing unavailable"})
+
+	// servicing the machine does not resume billing
+	vendingState.SetMaintenanceReason(lc, ReasonDoorLeftOpen)
+	vendingState.ClearMaintenance(lc)
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonBillingUnavailable}, vendingState.MaintenanceReasons)
+
+	assert.True(t, vendingState.ResumeBilling(lc))
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.Empty(t, vendingState.MaintenanceReasons)
+	assert.False(t, vendingState.ResumeBilling(lc))
+}
@@ -0,0 +1,142 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+var (
+	// ErrNoVendInProgress is returned when the vend workflow is cancelled
+	// while no vend or session is in progress
+	ErrNoVendInProgress = errors.New("no vend is in progress")
+	// ErrVendSettling is returned when the vend workflow is cancelled while
+	// its basket is being charged, which cannot be stopped
+	ErrVendSettling = errors.New("the basket of the vend is being charged")
+)
+
+// WorkflowCancellation is the vend that was cancelled: the state it was
+// cancelled in, its account, and the items taken during the earlier visits
+// of its session, which are not charged
+type WorkflowCancellation struct {
+	State          WorkflowState `json:"state"`
+	SessionID      string        `json:"sessionId,omitempty"`
+	AccountID      int           `json:"accountId,omitempty"`
+	CardID         string        `json:"cardId,omitempty"`
+	DiscardedItems []deltaSKU    `json:"discardedItems,omitempty"`
+	// DoorLocked is whether the door was locked again, and IntentVoided
+	// whether the basket intent of the session was removed from the ledger
+	// service
+	DoorLocked   bool `json:"doorLocked"`
+	IntentVoided bool `json:"intentVoided"`
+}
+
+// CancelWorkflow aborts the vend in progress, such as when a customer walks
+// away mid-session. It stops the timeout threads, locks the door, voids the
+// basket intent of a session whose basket was not charged yet, and returns
+// the workflow to idle, or to maintenance while maintenance mode is set. A
+// card waiting for its PIN is also cancelled. The authorization is passed
+// on to the ledger service, which needs the token of a maintainer card to
+// void the intent.
+func (vendingState *VendingState) CancelWorkflow(lc logger.LoggingClient, authorization string) (WorkflowCancellation, error) {
+	state := vendingState.Workflow.State()
+	pinWaiting := vendingState.PinEntry.Waiting()
+	if state == StateSettling {
+		return WorkflowCancellation{}, ErrVendSettling
+	}
+	if !state.vending() && !vendingState.SessionLingering && !pinWaiting {
+		return WorkflowCancellation{}, ErrNoVendInProgress
+	}
+
+	cancellation := WorkflowCancellation{
+		State:          state,
+		SessionID:      vendingState.SessionID,
+		AccountID:      vendingState.CurrentUserData.AccountID,
+		CardID:         vendingState.CurrentUserData.CardID,
+		DiscardedItems: vendingState.SessionBasket,
+	}
+	lc.Infof("Cancelling the vend of card %s in the %s state", cancellation.CardID, state)
+
+	// stop the door open, door close and inference wait threads, and the
+	// session linger thread
+	close(vendingState.ThreadStopChannel)
+	vendingState.ThreadStopChannel = make(chan int)
+	vendingState.stopLingering()
+	vendingState.PinEntry.Cancel(lc)
+
+	settings := make(map[string]string)
+	settings["lock1"] = "false"
+	if err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardLock1Cmd, settings); err != nil {
+		lc.Errorf("Failed to lock the door of the cancelled vend: %s", err.Error())
+	} else {
+		cancellation.DoorLocked = true
+	}
+
+	// the basket intent is recorded when the first visit of a session ends,
+	// and is only charged when the session ends
+	if vendingState.SessionBasket != nil {
+		lc.Warnf("Discarding the uncharged session basket %v of account %d", vendingState.SessionBasket, cancellation.AccountID)
+		if err := vendingState.voidBasketIntent(lc, cancellation.SessionID, authorization); err != nil {
+			lc.Errorf("Failed to void the basket intent of session %s: %s", cancellation.SessionID, err.Error())
+		} else {
+			cancellation.IntentVoided = true
+		}
+	}
+	if vendingState.CurrentUserData.RoleID == 1 && vendingState.Configuration.PreAuthorizeCustomers {
+		vendingState.releaseHold(lc, cancellation.AccountID)
+	}
+
+	vendingState.SessionBasket = nil
+	vendingState.CurrentUserData = OutputData{}
+	vendingState.SplitPayers = nil
+	vendingState.SessionID = ""
+	vendingState.DoorClosedAt = time.Time{}
+	vendingState.StageDeadline = time.Time{}
+	vendingState.Metrics.SetActiveSessions(0)
+	vendingState.ResetWorkflow(lc, "workflowCancelled")
+
+	settings = make(map[string]string)
+	settings["displayRow2"] = "Vend cancelled"
+	if err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings); err != nil {
+		lc.Errorf("Failed to display the cancelled vend: %s", err.Error())
+	}
+	return cancellation, nil
+}
+
+// voidBasketIntent removes the basket intent of the session from the ledger
+// service, so that the recovery job does not charge it
+func (vendingState *VendingState) voidBasketIntent(lc logger.LoggingClient, sessionID string, authorization string) error {
+	if sessionID == "" {
+		return nil
+	}
+	request, err := http.NewRequest(http.MethodDelete, vendingState.Configuration.LedgerService+"/intents/"+sessionID, bytes.NewBuffer([]byte("")))
+	if err != nil {
+		return err
+	}
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		lc.Infof("Voided the basket intent of session %s", sessionID)
+	case http.StatusNotFound:
+		// the intent was never recorded, or was already removed
+		lc.Debugf("Session %s has no basket intent to void", sessionID)
+	default:
+		return fmt.Errorf("received status code: %v", resp.Status)
+	}
+	return nil
+}
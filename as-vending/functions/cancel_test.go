@@ -0,0 +1,101 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelWorkflow(t *testing.T) {
+	var voided []string
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete && r.URL.Path == "/ledger/intents/session-1" {
+			voided = append(voided, r.URL.Path)
+			authorization = r.Header.Get("Authorization")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	lc := logger.NewMockClient()
+
+	t.Run("Door open", func(t *testing.T) {
+		voided = nil
+		vendingState, mockCommandClient := newSessionVendingState(server.URL)
+		vendingState.Metrics = NewVendingMetrics()
+		vendingState.Metrics.SetActiveSessions(1)
+		require.True(t, vendingState.Transition(lc, StateDoorOpen, "doorOpened"))
+		stopChannel := vendingState.ThreadStopChannel
+
+		cancellation, err := vendingState.CancelWorkflow(lc, "Bearer maintainer")
+		require.NoError(t, err)
+		assert.Equal(t, WorkflowCancellation{State: StateDoorOpen, SessionID: "session-1", AccountID: 1, CardID: "0003293374", DoorLocked: true}, cancellation)
+		assert.Empty(t, voided, "a session without a basket has no intent")
+
+		// the wait threads are stopped
+		select {
+		case <-stopChannel:
+		default:
+			assert.Fail(t, "the thread stop channel should be closed")
+		}
+		assert.Equal(t, StateIdle, vendingState.Workflow.State())
+		assert.Equal(t, OutputData{}, vendingState.CurrentUserData)
+		assert.Empty(t, vendingState.SessionID)
+		assert.Zero(t, vendingState.Metrics.activeSessions.Value())
+		mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", map[string]string{"lock1": "false"})
+		mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Vend cancelled"})
+
+		_, err = vendingState.CancelWorkflow(lc, "")
+		assert.ErrorIs(t, err, ErrNoVendInProgress)
+	})
+
+	t.Run("Lingering session", func(t *testing.T) {
+		voided = nil
+		vendingState, _ := newSessionVendingState(server.URL)
+		vendingState.ResetWorkflow(lc, "sessionLingering")
+		vendingState.SessionLingering = true
+		vendingState.SessionLingerStopChannel = make(chan int)
+		vendingState.SessionBasket = []deltaSKU{{SKU: "A", Delta: -2}}
+
+		cancellation, err := vendingState.CancelWorkflow(lc, "Bearer maintainer")
+		require.NoError(t, err)
+		assert.Equal(t, []deltaSKU{{SKU: "A", Delta: -2}}, cancellation.DiscardedItems)
+		assert.True(t, cancellation.IntentVoided)
+		assert.Equal(t, []string{"/ledger/intents/session-1"}, voided)
+		assert.Equal(t, "Bearer maintainer", authorization)
+		assert.False(t, vendingState.SessionLingering)
+		assert.Nil(t, vendingState.SessionBasket)
+	})
+
+	t.Run("Settling", func(t *testing.T) {
+		vendingState, mockCommandClient := newSessionVendingState(server.URL)
+		vendingState.Workflow = NewWorkflow(StateSettling)
+
+		_, err := vendingState.CancelWorkflow(lc, "")
+		assert.ErrorIs(t, err, ErrVendSettling)
+		assert.Equal(t, StateSettling, vendingState.Workflow.State())
+		assert.Equal(t, "session-1", vendingState.SessionID)
+		mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Waiting for a PIN", func(t *testing.T) {
+		vendingState, _ := newSessionVendingState(server.URL)
+		vendingState.Workflow = NewWorkflow(StateIdle)
+		vendingState.PinEntry = NewPinEntry(server.URL+"/authentication/verify-pin", time.Minute)
+		vendingState.PinEntry.Start(lc, OutputData{AccountID: 1, CardID: "0003293374", RoleID: 1}, time.Now(), func() {})
+
+		_, err := vendingState.CancelWorkflow(lc, "")
+		require.NoError(t, err)
+		assert.False(t, vendingState.PinEntry.Waiting())
+	})
+}
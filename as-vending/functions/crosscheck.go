@@ -0,0 +1,493 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+const (
+	// WeightDeltaResource is the weight sensor's resource, whose readings
+	// are the grams the shelves of the door gained since it was opened,
+	// negative when items were taken
+	WeightDeltaResource = "weightDelta"
+
+	defaultCrossCheckTolerance = 10.0
+	defaultCrossCheckCVWeight  = 0.5
+	defaultCrossCheckMinScore  = 0.5
+
+	// maxWeightDiscrepancies is the number of weight discrepancies kept
+	maxWeightDiscrepancies = 1000
+)
+
+// CrossCheckPolicy is how a disagreement between the inference result of a
+// vend and the weight its shelves lost is resolved
+type CrossCheckPolicy string
+
+const (
+	// CrossCheckTrustCV charges the inference result
+	CrossCheckTrustCV CrossCheckPolicy = "trustCV"
+	// CrossCheckTrustWeight charges the quantity of the SKU of the inference
+	// result that the weight is a whole number of units of, and flags the
+	// basket for review when it is not
+	CrossCheckTrustWeight CrossCheckPolicy = "trustWeight"
+	// CrossCheckRequireAgreement flags the basket for review
+	CrossCheckRequireAgreement CrossCheckPolicy = "requireAgreement"
+	// CrossCheckWeightedScore charges the inference result when the score of
+	// its confidence and of the weight agreement is high enough, and flags
+	// the basket for review when it is not
+	CrossCheckWeightedScore CrossCheckPolicy = "weightedScore"
+)
+
+// crossCheckStrictness orders the policies from the most lenient, so that
+// a result of several SKU classes is resolved by the strictest of their
+// policies
+var crossCheckStrictness = map[CrossCheckPolicy]int{
+	CrossCheckTrustCV:          0,
+	CrossCheckTrustWeight:      1,
+	CrossCheckWeightedScore:    2,
+	CrossCheckRequireAgreement: 3,
+}
+
+// The resolutions of a weight discrepancy
+const (
+	DiscrepancyChargedInference  = "chargedInference"
+	DiscrepancyCorrectedByWeight = "correctedByWeight"
+	DiscrepancyFlaggedForReview  = "flaggedForReview"
+)
+
+// WeightDiscrepancy is an inference result that disagreed with the weight
+// the shelves of its vend lost, and how it was resolved. Classes are the SKU
+// classes of the result, and Score is the weighted score of the
+// weightedScore policy. Discrepancies are kept as feedback for improving the
+// inference model.
+type WeightDiscrepancy struct {
+	KioskID       string           `json:"kioskId,omitempty"`
+	Door          string           `json:"door"`
+	SessionID     string           `json:"sessionId,omitempty"`
+	ModelVersion  string           `json:"modelVersion,omitempty"`
+	Items         []InferenceItem  `json:"items"`
+	Confidence    *float64         `json:"confidence,omitempty"`
+	Classes       []string         `json:"classes"`
+	Policy        CrossCheckPolicy `json:"policy"`
+	ExpectedGrams float64          `json:"expectedGrams"`
+	MeasuredGrams float64          `json:"measuredGrams"`
+	Score         *float64         `json:"score,omitempty"`
+	Resolution    string           `json:"resolution"`
+	Charged       []deltaSKU       `json:"charged"`
+	Timestamp     int64            `json:"timestamp,string"`
+}
+
+// reviewReason is why the basket of a discrepancy that was flagged needs
+// review
+func (discrepancy WeightDiscrepancy) reviewReason() string {
+	return fmt.Sprintf("Inference result of %.1fg disagrees with the %.1fg measured by the weight sensor", discrepancy.ExpectedGrams, discrepancy.MeasuredGrams)
+}
+
+// crossCheckProduct is the category and the weight of one unit of a SKU
+type crossCheckProduct struct {
+	Category string
+	Weight   float64
+}
+
+// crossCheckProducts holds the fields of the inventory service's products
+// that the weight of an inference result is computed from
+type crossCheckProducts struct {
+	Data []struct {
+		SKU      string  `json:"sku"`
+		Category string  `json:"category"`
+		Weight   float64 `json:"weight"`
+	} `json:"data"`
+}
+
+// WeightCrossCheck checks the inference result of each vend against the
+// weight the shelves of the door lost, as measured by its weight sensor. A
+// disagreement is resolved by the policy of the SKU classes of the result,
+// which are the categories of its products in inventory. A nil
+// WeightCrossCheck has no weight sensor.
+type WeightCrossCheck struct {
+	deviceName    string
+	endpoint      string
+	tolerance     float64
+	policy        CrossCheckPolicy
+	classPolicies map[string]CrossCheckPolicy
+	cvWeight      float64
+	minScore      float64
+}
+
+// ParseWeightCrossCheck parses the cross-check settings of the
+// configuration, and returns nil without a weight sensor
+func ParseWeightCrossCheck(configuration *config.VendingConfig) (*WeightCrossCheck, error) {
+	if configuration.WeightSensorDeviceName == "" {
+		return nil, nil
+	}
+	if configuration.CrossCheckProductEndpoint == "" {
+		return nil, errors.New("CrossCheckProductEndpoint is empty")
+	}
+	crossCheck := &WeightCrossCheck{
+		deviceName:    configuration.WeightSensorDeviceName,
+		endpoint:      configuration.CrossCheckProductEndpoint,
+		tolerance:     configuration.CrossCheckToleranceGrams,
+		classPolicies: map[string]CrossCheckPolicy{},
+		cvWeight:      configuration.CrossCheckCVWeight,
+		minScore:      configuration.CrossCheckMinScore,
+	}
+	if crossCheck.tolerance < 0 {
+		return nil, fmt.Errorf("CrossCheckToleranceGrams %v is negative", crossCheck.tolerance)
+	}
+	if crossCheck.tolerance == 0 {
+		crossCheck.tolerance = defaultCrossCheckTolerance
+	}
+	if crossCheck.cvWeight < 0 || crossCheck.cvWeight > 1 {
+		return nil, fmt.Errorf("CrossCheckCVWeight %v must be between 0 and 1", crossCheck.cvWeight)
+	}
+	if crossCheck.cvWeight == 0 {
+		crossCheck.cvWeight = defaultCrossCheckCVWeight
+	}
+	if crossCheck.minScore < 0 || crossCheck.minScore > 1 {
+		return nil, fmt.Errorf("CrossCheckMinScore %v must be between 0 and 1", crossCheck.minScore)
+	}
+	if crossCheck.minScore == 0 {
+		crossCheck.minScore = defaultCrossCheckMinScore
+	}
+
+	var err error
+	if crossCheck.policy, err = parseCrossCheckPolicy(configuration.CrossCheckPolicy); err != nil {
+		return nil, fmt.Errorf("CrossCheckPolicy: %s", err.Error())
+	}
+	if configuration.CrossCheckClassPolicies == "" {
+		return crossCheck, nil
+	}
+	for _, entry := range strings.Split(configuration.CrossCheckClassPolicies, ",") {
+		class, value, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		class = strings.ToLower(strings.TrimSpace(class))
+		if !ok || class == "" {
+			return nil, fmt.Errorf("CrossCheckClassPolicies entry %q must be class:policy", entry)
+		}
+		if _, ok := crossCheck.classPolicies[class]; ok {
+			return nil, fmt.Errorf("CrossCheckClassPolicies has class %q twice", class)
+		}
+		policy, err := parseCrossCheckPolicy(strings.TrimSpace(value))
+		if err != nil || value == "" {
+			return nil, fmt.Errorf("CrossCheckClassPolicies entry %q must be class:policy", entry)
+		}
+		crossCheck.classPolicies[class] = policy
+	}
+	return crossCheck, nil
+}
+
+// parseCrossCheckPolicy parses a policy, empty being requireAgreement
+func parseCrossCheckPolicy(value string) (CrossCheckPolicy, error) {
+	if value == "" {
+		return CrossCheckRequireAgreement, nil
+	}
+	policy := CrossCheckPolicy(value)
+	if _, ok := crossCheckStrictness[policy]; !ok {
+		return "", fmt.Errorf("unknown policy %q, expected %s, %s, %s or %s", value, CrossCheckTrustCV, CrossCheckTrustWeight, CrossCheckRequireAgreement, CrossCheckWeightedScore)
+	}
+	return policy, nil
+}
+
+// DeviceName returns the weight sensor device, empty without one
+func (crossCheck *WeightCrossCheck) DeviceName() string {
+	if crossCheck == nil {
+		return ""
+	}
+	return crossCheck.deviceName
+}
+
+// forDevice returns the cross-check of another weight sensor with the same
+// settings, nil without a weight sensor
+func (crossCheck *WeightCrossCheck) forDevice(deviceName string) *WeightCrossCheck {
+	if crossCheck == nil || deviceName == "" {
+		return nil
+	}
+	forDevice := *crossCheck
+	forDevice.deviceName = deviceName
+	return &forDevice
+}
+
+// classPolicy returns the policy of the SKU class
+func (crossCheck *WeightCrossCheck) classPolicy(class string) CrossCheckPolicy {
+	if policy, ok := crossCheck.classPolicies[strings.ToLower(class)]; ok {
+		return policy
+	}
+	return crossCheck.policy
+}
+
+// lookUp returns the category and unit weight of the SKUs of the items from
+// inventory, by their SKU
+func (crossCheck *WeightCrossCheck) lookUp(lc logger.LoggingClient, items []InferenceItem) (map[string]crossCheckProduct, error) {
+	skus := []string{}
+	for _, item := range items {
+		skus = append(skus, item.SKU)
+	}
+	lookUpURL := crossCheck.endpoint + "?skus=" + url.QueryEscape(strings.Join(skus, ","))
+	resp, err := sendHTTPRequest(lc, http.MethodGet, lookUpURL, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the products: %s", err.Error())
+	}
+	var products crossCheckProducts
+	if err := json.Unmarshal(body, &products); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the products: %s", err.Error())
+	}
+	bySKU := map[string]crossCheckProduct{}
+	for _, product := range products.Data {
+		bySKU[product.SKU] = crossCheckProduct{Category: product.Category, Weight: product.Weight}
+	}
+	return bySKU, nil
+}
+
+// resolve returns the SKU deltas charged for the inference result whose
+// vend measured the weight change, and the discrepancy when they disagree.
+// The result is not checked when a SKU it took has no unit weight.
+func (crossCheck *WeightCrossCheck) resolve(payload InferencePayload, products map[string]crossCheckProduct, measured float64) ([]deltaSKU, *WeightDiscrepancy, error) {
+	expected := 0.0
+	classes := []string{}
+	seen := map[string]bool{}
+	taken := []InferenceItem{}
+	for _, item := range payload.Items {
+		if item.Delta == 0 {
+			continue
+		}
+		product, ok := products[item.SKU]
+		if !ok || product.Weight <= 0 {
+			return nil, nil, fmt.Errorf("SKU %s has no unit weight in inventory", item.SKU)
+		}
+		expected += float64(item.Delta) * product.Weight
+		taken = append(taken, item)
+		if product.Category != "" && !seen[strings.ToLower(product.Category)] {
+			seen[strings.ToLower(product.Category)] = true
+			classes = append(classes, product.Category)
+		}
+	}
+	skuDelta := payload.skuDelta()
+	if math.Abs(measured-expected) <= crossCheck.tolerance {
+		return skuDelta, nil, nil
+	}
+
+	sort.Strings(classes)
+	policy := crossCheck.policy
+	if len(classes) > 0 {
+		policy = crossCheck.classPolicy(classes[0])
+		for _, class := range classes[1:] {
+			if classPolicy := crossCheck.classPolicy(class); crossCheckStrictness[classPolicy] > crossCheckStrictness[policy] {
+				policy = classPolicy
+			}
+		}
+	}
+	discrepancy := &WeightDiscrepancy{
+		ModelVersion:  payload.ModelVersion,
+		Items:         payload.Items,
+		Confidence:    payload.Confidence,
+		Classes:       classes,
+		Policy:        policy,
+		ExpectedGrams: expected,
+		MeasuredGrams: measured,
+		Resolution:    DiscrepancyFlaggedForReview,
+		Charged:       skuDelta,
+	}
+
+	switch policy {
+	case CrossCheckTrustCV:
+		discrepancy.Resolution = DiscrepancyChargedInference
+	case CrossCheckTrustWeight:
+		// only the quantity of a single SKU can be told from its weight
+		if len(taken) == 1 {
+			unitWeight := products[taken[0].SKU].Weight
+			units := math.Round(measured / unitWeight)
+			if math.Abs(measured-units*unitWeight) <= crossCheck.tolerance {
+				discrepancy.Resolution = DiscrepancyCorrectedByWeight
+				discrepancy.Charged = []deltaSKU{}
+				if units != 0 {
+					discrepancy.Charged = []deltaSKU{{SKU: taken[0].SKU, Delta: int(units)}}
+				}
+			}
+		}
+	case CrossCheckWeightedScore:
+		confidence := 1.0
+		if payload.Confidence != nil {
+			confidence = *payload.Confidence
+		}
+		agreement := 1 - math.Min(1, math.Abs(measured-expected)/math.Max(math.Abs(expected), math.Abs(measured)))
+		score := crossCheck.cvWeight*confidence + (1-crossCheck.cvWeight)*agreement
+		discrepancy.Score = &score
+		if score >= crossCheck.minScore {
+			discrepancy.Resolution = DiscrepancyChargedInference
+		}
+	}
+	return discrepancy.Charged, discrepancy, nil
+}
+
+// DiscrepancyLog keeps the most recent weight discrepancies in a JSON file,
+// so that they survive a restart of the service. A nil DiscrepancyLog does
+// not keep anything.
+type DiscrepancyLog struct {
+	mutex         sync.Mutex
+	fileName      string
+	discrepancies []WeightDiscrepancy
+}
+
+// NewDiscrepancyLog creates the discrepancy log kept in the file, and loads
+// the discrepancies kept in it. The discrepancies are only kept in memory
+// when the file is empty.
+func NewDiscrepancyLog(fileName string) (*DiscrepancyLog, error) {
+	log := &DiscrepancyLog{fileName: fileName, discrepancies: []WeightDiscrepancy{}}
+	if fileName == "" {
+		return log, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the weight discrepancy directory: %s", err.Error())
+	}
+	discrepanciesJSON, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return log, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the weight discrepancies %s: %s", fileName, err.Error())
+	}
+	if err := json.Unmarshal(discrepanciesJSON, &log.discrepancies); err != nil {
+		return nil, fmt.Errorf("failed to parse the weight discrepancies %s: %s", fileName, err.Error())
+	}
+	return log, nil
+}
+
+// Add keeps the discrepancy. A discrepancy that fails to be written is only
+// logged, as the basket is resolved either way.
+func (log *DiscrepancyLog) Add(lc logger.LoggingClient, discrepancy WeightDiscrepancy) {
+	lc.Warnf("The inference result of session %s weighs %.1fg, but %.1fg was measured, resolved by %s as %s", discrepancy.SessionID, discrepancy.ExpectedGrams, discrepancy.MeasuredGrams, discrepancy.Policy, discrepancy.Resolution)
+	if log == nil {
+		return
+	}
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.discrepancies = append(log.discrepancies, discrepancy)
+	if len(log.discrepancies) > maxWeightDiscrepancies {
+		log.discrepancies = log.discrepancies[len(log.discrepancies)-maxWeightDiscrepancies:]
+	}
+	if err := log.save(); err != nil {
+		lc.Errorf("failed to keep the weight discrepancy: %s", err.Error())
+	}
+}
+
+// Discrepancies returns the weight discrepancies, oldest first
+func (log *DiscrepancyLog) Discrepancies() []WeightDiscrepancy {
+	discrepancies := []WeightDiscrepancy{}
+	if log == nil {
+		return discrepancies
+	}
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	return append(discrepancies, log.discrepancies...)
+}
+
+// save writes the discrepancies to a temporary file that replaces the file,
+// so that a crash never leaves a partial file behind
+func (log *DiscrepancyLog) save() error {
+	if log.fileName == "" {
+		return nil
+	}
+	discrepanciesJSON, err := json.MarshalIndent(log.discrepancies, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempName := filepath.Join(filepath.Dir(log.fileName), "."+filepath.Base(log.fileName)+".tmp")
+	if err := os.WriteFile(tempName, discrepanciesJSON, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempName, log.fileName)
+}
+
+// recordWeight keeps the weight change of the weight sensor event for the
+// cross-check of the inference result of the vend. Readings outside of a
+// vend, such as those of a restock, are ignored.
+func (vendingState *VendingState) recordWeight(lc logger.LoggingClient, event dtos.Event) (bool, interface{}) {
+	for _, reading := range event.Readings {
+		if reading.ResourceName != WeightDeltaResource {
+			continue
+		}
+		grams, err := strconv.ParseFloat(reading.Value, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid weight change %q of %s: %s", reading.Value, event.DeviceName, err.Error())
+		}
+		if state := vendingState.Workflow.State(); state != StateDoorOpen && state != StateInferring {
+			lc.Debugf("ignoring the weight change of %.1fg outside of a vend", grams)
+			continue
+		}
+		vendingState.weightDelta = &grams
+	}
+	return false, nil
+}
+
+// DoorOpened forgets the weight change of the previous visit, as the weight
+// sensor measures each visit from the door being opened
+func (vendingState *VendingState) DoorOpened() {
+	vendingState.weightDelta = nil
+}
+
+// crossCheckWeight checks the inference result of the vend against the
+// weight change its weight sensor measured, and returns the SKU deltas to
+// charge. A disagreement is kept as a weight discrepancy, and its basket is
+// flagged for review unless its policy resolved it. The result is charged as
+// it is without a weight change or the unit weights of its SKUs.
+func (vendingState *VendingState) crossCheckWeight(lc logger.LoggingClient, payload InferencePayload) []deltaSKU {
+	if vendingState.CrossCheck == nil {
+		return payload.skuDelta()
+	}
+	measured := vendingState.weightDelta
+	vendingState.weightDelta = nil
+	if measured == nil {
+		lc.Warnf("No weight change was measured for session %s, its inference result is not cross-checked", vendingState.SessionID)
+		return payload.skuDelta()
+	}
+	products, err := vendingState.CrossCheck.lookUp(lc, payload.Items)
+	if err != nil {
+		lc.Errorf("Failed to look up the unit weights of the inference result of session %s: %s", vendingState.SessionID, err.Error())
+		return payload.skuDelta()
+	}
+	skuDelta, discrepancy, err := vendingState.CrossCheck.resolve(payload, products, *measured)
+	if err != nil {
+		lc.Warnf("The inference result of session %s is not cross-checked: %s", vendingState.SessionID, err.Error())
+		return payload.skuDelta()
+	}
+	if discrepancy == nil {
+		return skuDelta
+	}
+
+	discrepancy.SessionID = vendingState.SessionID
+	discrepancy.Timestamp = time.Now().UnixNano()
+	if vendingState.Configuration != nil {
+		discrepancy.KioskID = vendingState.Configuration.KioskID
+		discrepancy.Door = vendingState.Configuration.ControllerBoardDeviceName
+	}
+	vendingState.Discrepancies.Add(lc, *discrepancy)
+	if discrepancy.Resolution == DiscrepancyFlaggedForReview {
+		vendingState.reviewReasons = append(vendingState.reviewReasons, discrepancy.reviewReason())
+	}
+	return skuDelta
+}
@@ -0,0 +1,257 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crossCheckProductsFixture are the cross-checked products of the tests, a
+// 355g can of cola, a 50g bag of chips and a 500g bottle of water
+var crossCheckProductsFixture = map[string]crossCheckProduct{
+	"HXI86WHU":   {Category: "Beverages", Weight: 355},
+	"1200050408": {Category: "Snacks", Weight: 50},
+	"4900002470": {Category: "Beverages", Weight: 500},
+}
+
+func TestParseWeightCrossCheck(t *testing.T) {
+	crossCheck, err := ParseWeightCrossCheck(&config.VendingConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, crossCheck, "the cross-check needs a weight sensor")
+	assert.Empty(t, crossCheck.DeviceName())
+
+	crossCheck, err = ParseWeightCrossCheck(&config.VendingConfig{WeightSensorDeviceName: "shelf-scale", CrossCheckProductEndpoint: "http://localhost:48095/inventory"})
+	require.NoError(t, err)
+	assert.Equal(t, "shelf-scale", crossCheck.DeviceName())
+	assert.Equal(t, defaultCrossCheckTolerance, crossCheck.tolerance)
+	assert.Equal(t, CrossCheckRequireAgreement, crossCheck.policy)
+	assert.Equal(t, defaultCrossCheckCVWeight, crossCheck.cvWeight)
+	assert.Equal(t, defaultCrossCheckMinScore, crossCheck.minScore)
+
+	crossCheck, err = ParseWeightCrossCheck(&config.VendingConfig{
+		WeightSensorDeviceName:    "shelf-scale",
+		CrossCheckProductEndpoint: "http://localhost:48095/inventory",
+		CrossCheckPolicy:          "weightedScore",
+		CrossCheckClassPolicies:   "Beverages:trustWeight, snacks : trustCV",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, CrossCheckTrustWeight, crossCheck.classPolicy("beverages"))
+	assert.Equal(t, CrossCheckTrustCV, crossCheck.classPolicy("Snacks"))
+	assert.Equal(t, CrossCheckWeightedScore, crossCheck.classPolicy("Dairy"))
+
+	tests := []struct {
+		Name          string
+		Configuration config.VendingConfig
+		ExpectedError string
+	}{
+		{"No endpoint", config.VendingConfig{}, "CrossCheckProductEndpoint is empty"},
+		{"Negative tolerance", config.VendingConfig{CrossCheckToleranceGrams: -1}, "CrossCheckToleranceGrams -1 is negative"},
+		{"CV weight above 1", config.VendingConfig{CrossCheckCVWeight: 1.5}, "CrossCheckCVWeight 1.5 must be between 0 and 1"},
+		{"Negative min score", config.VendingConfig{CrossCheckMinScore: -0.5}, "CrossCheckMinScore -0.5 must be between 0 and 1"},
+		{"Unknown policy", config.VendingConfig{CrossCheckPolicy: "trustNobody"}, `CrossCheckPolicy: unknown policy "trustNobody", expected trustCV, trustWeight, requireAgreement or weightedScore`},
+		{"Class without policy", config.VendingConfig{CrossCheckClassPolicies: "beverages"}, `CrossCheckClassPolicies entry "beverages" must be class:policy`},
+		{"Class with empty policy", config.VendingConfig{CrossCheckClassPolicies: "beverages:"}, `CrossCheckClassPolicies entry "beverages:" must be class:policy`},
+		{"Class with unknown policy", config.VendingConfig{CrossCheckClassPolicies: "beverages:trustNobody"}, `CrossCheckClassPolicies entry "beverages:trustNobody" must be class:policy`},
+		{"Class twice", config.VendingConfig{CrossCheckClassPolicies: "beverages:trustCV,Beverages:trustWeight"}, `CrossCheckClassPolicies has class "beverages" twice`},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			configuration := currentTest.Configuration
+			configuration.WeightSensorDeviceName = "shelf-scale"
+			if currentTest.Name != "No endpoint" {
+				configuration.CrossCheckProductEndpoint = "http://localhost:48095/inventory"
+			}
+			_, err := ParseWeightCrossCheck(&configuration)
+			assert.EqualError(t, err, currentTest.ExpectedError)
+		})
+	}
+}
+
+func TestWeightCrossCheckResolve(t *testing.T) {
+	low := 0.4
+	high := 0.95
+	cola := []InferenceItem{{SKU: "HXI86WHU", Delta: -1}}
+	colaAndChips := []InferenceItem{{SKU: "HXI86WHU", Delta: -1}, {SKU: "1200050408", Delta: -1}}
+	tests := []struct {
+		Name               string
+		Policy             string
+		ClassPolicies      string
+		Payload            InferencePayload
+		Measured           float64
+		ExpectedCharged    []deltaSKU
+		ExpectedPolicy     CrossCheckPolicy
+		ExpectedResolution string
+	}{
+		{"Agreement", "", "", InferencePayload{Items: cola}, -352, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, "", ""},
+		{"Agreement of several SKUs", "", "", InferencePayload{Items: colaAndChips}, -400, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}, {SKU: "1200050408", Delta: -1}}, "", ""},
+		{"Trust CV", "trustCV", "", InferencePayload{Items: cola}, -710, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, CrossCheckTrustCV, DiscrepancyChargedInference},
+		{"Trust weight", "trustWeight", "", InferencePayload{Items: cola}, -712, []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}, CrossCheckTrustWeight, DiscrepancyCorrectedByWeight},
+		{"Trust weight of nothing taken", "trustWeight", "", InferencePayload{Items: cola}, 4, []deltaSKU{}, CrossCheckTrustWeight, DiscrepancyCorrectedByWeight},
+		{"Trust weight of no whole units", "trustWeight", "", InferencePayload{Items: cola}, -530, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, CrossCheckTrustWeight, DiscrepancyFlaggedForReview},
+		{"Trust weight of several SKUs", "trustWeight", "", InferencePayload{Items: colaAndChips}, -800, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}, {SKU: "1200050408", Delta: -1}}, CrossCheckTrustWeight, DiscrepancyFlaggedForReview},
+		{"Require agreement", "", "", InferencePayload{Items: cola}, -710, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, CrossCheckRequireAgreement, DiscrepancyFlaggedForReview},
+		{"Weighted score above the minimum", "weightedScore", "", InferencePayload{Confidence: &high, Items: cola}, -380, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, CrossCheckWeightedScore, DiscrepancyChargedInference},
+		{"Weighted score below the minimum", "weightedScore", "", InferencePayload{Confidence: &low, Items: cola}, -710, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, CrossCheckWeightedScore, DiscrepancyFlaggedForReview},
+		{"Class policy", "", "beverages:trustCV", InferencePayload{Items: cola}, -710, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, CrossCheckTrustCV, DiscrepancyChargedInference},
+		{"Strictest class policy", "", "beverages:trustCV,snacks:weightedScore", InferencePayload{Confidence: &low, Items: colaAndChips}, -810, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}, {SKU: "1200050408", Delta: -1}}, CrossCheckWeightedScore, DiscrepancyFlaggedForReview},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			crossCheck, err := ParseWeightCrossCheck(&config.VendingConfig{
+				WeightSensorDeviceName:    "shelf-scale",
+				CrossCheckProductEndpoint: "http://localhost:48095/inventory",
+				CrossCheckPolicy:          currentTest.Policy,
+				CrossCheckClassPolicies:   currentTest.ClassPolicies,
+			})
+			require.NoError(t, err)
+			charged, discrepancy, err := crossCheck.resolve(currentTest.Payload, crossCheckProductsFixture, currentTest.Measured)
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedCharged, charged)
+			if currentTest.ExpectedResolution == "" {
+				assert.Nil(t, discrepancy)
+				return
+			}
+			require.NotNil(t, discrepancy)
+			assert.Equal(t, currentTest.ExpectedPolicy, discrepancy.Policy)
+			assert.Equal(t, currentTest.ExpectedResolution, discrepancy.Resolution)
+			assert.Equal(t, currentTest.Measured, discrepancy.MeasuredGrams)
+			assert.Equal(t, charged, discrepancy.Charged)
+			assert.Equal(t, currentTest.ExpectedPolicy == CrossCheckWeightedScore, discrepancy.Score != nil)
+		})
+	}
+
+	crossCheck, err := ParseWeightCrossCheck(&config.VendingConfig{WeightSensorDeviceName: "shelf-scale", CrossCheckProductEndpoint: "http://localhost:48095/inventory"})
+	require.NoError(t, err)
+	_, _, err = crossCheck.resolve(InferencePayload{Items: []InferenceItem{{SKU: "unknown", Delta: -1}}}, crossCheckProductsFixture, -100)
+	assert.EqualError(t, err, "SKU unknown has no unit weight in inventory")
+}
+
+func TestCrossCheckWeight(t *testing.T) {
+	var lookUps []string
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookUps = append(lookUps, r.URL.Query().Get("skus"))
+		w.Write([]byte(`{"data":[{"sku":"HXI86WHU","category":"Beverages","weight":355,"isActive":true}]}`))
+	}))
+	defer inventoryServer.Close()
+
+	configuration := &config.VendingConfig{
+		KioskID:                   "kiosk-1",
+		ControllerBoardDeviceName: "controller-board",
+		WeightSensorDeviceName:    "shelf-scale",
+		CrossCheckProductEndpoint: inventoryServer.URL,
+	}
+	crossCheck, err := ParseWeightCrossCheck(configuration)
+	require.NoError(t, err)
+	discrepancies, err := NewDiscrepancyLog("")
+	require.NoError(t, err)
+	vendingState := VendingState{
+		Workflow:      NewWorkflow(StateIdle),
+		Configuration: configuration,
+		SessionID:     "42",
+		CrossCheck:    crossCheck,
+		Discrepancies: discrepancies,
+	}
+	lc := logger.NewMockClient()
+	weighed := func(grams string) {
+		event := dtos.Event{DeviceName: "shelf-scale", Readings: []dtos.BaseReading{{ResourceName: WeightDeltaResource, SimpleReading: dtos.SimpleReading{Value: grams}}}}
+		ok, result := vendingState.DeviceHelper(pkg.NewAppFuncContextForTest("", lc), event)
+		assert.False(t, ok)
+		assert.Nil(t, result)
+	}
+
+	// a weight change outside of a vend, such as a restock, is ignored
+	weighed("3550")
+	assert.Nil(t, vendingState.weightDelta)
+
+	// the inference result is charged as it is without a weight change
+	vendingState.Workflow = NewWorkflow(StateDoorOpen)
+	skuDelta, err := vendingState.readInference(lc, `{"schemaVersion":1,"sessionID":"42","modelVersion":"product-detection-2.1","items":[{"sku":"HXI86WHU","delta":-1}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, skuDelta)
+	assert.Empty(t, lookUps)
+
+	weighed("-353.5")
+	skuDelta, err = vendingState.readInference(lc, `{"schemaVersion":1,"sessionID":"42","modelVersion":"product-detection-2.1","items":[{"sku":"HXI86WHU","delta":-1}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, skuDelta)
+	assert.Equal(t, []string{"HXI86WHU"}, lookUps)
+	assert.Nil(t, vendingState.weightDelta, "a weight change is cross-checked once")
+	assert.Empty(t, vendingState.Discrepancies.Discrepancies())
+	assert.Empty(t, vendingState.reviewReasons)
+
+	weighed("-710")
+	skuDelta, err = vendingState.readInference(lc, `{"schemaVersion":1,"sessionID":"42","modelVersion":"product-detection-2.1","items":[{"sku":"HXI86WHU","delta":-1}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, skuDelta)
+	assert.Equal(t, []string{"Inference result of -355.0g disagrees with the -710.0g measured by the weight sensor"}, vendingState.reviewReasons)
+	records := vendingState.Discrepancies.Discrepancies()
+	require.Len(t, records, 1)
+	assert.NotZero(t, records[0].Timestamp)
+	assert.Equal(t, "kiosk-1", records[0].KioskID)
+	assert.Equal(t, "controller-board", records[0].Door)
+	assert.Equal(t, "42", records[0].SessionID)
+	assert.Equal(t, "product-detection-2.1", records[0].ModelVersion)
+	assert.Equal(t, []string{"Beverages"}, records[0].Classes)
+	assert.Equal(t, -355.0, records[0].ExpectedGrams)
+	assert.Equal(t, DiscrepancyFlaggedForReview, records[0].Resolution)
+
+	// the weight change of a visit is kept until the door is opened again
+	weighed("-12")
+	require.NotNil(t, vendingState.weightDelta)
+	vendingState.DoorOpened()
+	assert.Nil(t, vendingState.weightDelta)
+
+	// a weight change that is not a number is rejected
+	event := dtos.Event{DeviceName: "shelf-scale", Readings: []dtos.BaseReading{{ResourceName: WeightDeltaResource, SimpleReading: dtos.SimpleReading{Value: "heavy"}}}}
+	ok, result := vendingState.DeviceHelper(pkg.NewAppFuncContextForTest("", lc), event)
+	assert.False(t, ok)
+	assert.Error(t, result.(error))
+}
+
+func TestDiscrepancyLog(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "crosscheck", "discrepancies.json")
+	discrepancies, err := NewDiscrepancyLog(fileName)
+	require.NoError(t, err)
+	lc := logger.NewMockClient()
+
+	discrepancies.Add(lc, WeightDiscrepancy{SessionID: "41", Policy: CrossCheckTrustCV, Resolution: DiscrepancyChargedInference})
+	discrepancies.Add(lc, WeightDiscrepancy{SessionID: "42", Policy: CrossCheckTrustWeight, Resolution: DiscrepancyCorrectedByWeight, Charged: []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}})
+
+	// the discrepancies are recovered when the service restarts
+	recovered, err := NewDiscrepancyLog(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, discrepancies.Discrepancies(), recovered.Discrepancies())
+	require.Len(t, recovered.Discrepancies(), 2)
+	assert.Equal(t, []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}, recovered.Discrepancies()[1].Charged)
+
+	for i := 0; i < maxWeightDiscrepancies; i++ {
+		discrepancies.Add(lc, WeightDiscrepancy{SessionID: "43"})
+	}
+	require.Len(t, discrepancies.Discrepancies(), maxWeightDiscrepancies)
+	assert.Equal(t, "43", discrepancies.Discrepancies()[0].SessionID, "the oldest discrepancies are dropped")
+
+	require.NoError(t, os.WriteFile(fileName, []byte(`{`), 0644))
+	_, err = NewDiscrepancyLog(fileName)
+	assert.Error(t, err)
+
+	// a nil log does not keep anything
+	var none *DiscrepancyLog
+	none.Add(lc, WeightDiscrepancy{SessionID: "44"})
+	assert.Empty(t, none.Discrepancies())
+}
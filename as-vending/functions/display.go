@@ -0,0 +1,299 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// defaultIdleDisplayInterval is how long an idle screen without its own
+	// duration is shown
+	defaultIdleDisplayInterval = 10 * time.Second
+	// idleDisplaySettle is how long the kiosk is idle before the rotation
+	// starts, so that the vend total or enrollment result stays readable
+	idleDisplaySettle = 10 * time.Second
+	// idleDisplayPoll is how often the rotation checks whether the kiosk is
+	// idle
+	idleDisplayPoll = time.Second
+	// displayRowCount is the number of LCD rows a screen has
+	displayRowCount = 3
+)
+
+// DisplayData is what the rows of a display screen template can show, such
+// as {{.Time}}, {{.KioskID}} or {{.Session.RemainingSeconds}}
+type DisplayData struct {
+	Time    string
+	Date    string
+	KioskID string
+	Session SessionStatus
+}
+
+// ParseIdleDisplayInterval parses how long each idle screen is shown, empty
+// is the default interval
+func ParseIdleDisplayInterval(interval string) (time.Duration, error) {
+	if interval == "" {
+		return defaultIdleDisplayInterval, nil
+	}
+	duration, err := time.ParseDuration(interval)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("idle display interval %q must be a positive duration", interval)
+	}
+	return duration, nil
+}
+
+// DisplayScreen is a templated LCD screen, shown for its duration
+type DisplayScreen struct {
+	Duration time.Duration
+	rows     [displayRowCount]*template.Template
+}
+
+// ParseDisplayScreens parses the screens of a rotation schedule. Screens are
+// separated by ';' and their rows by '|', and a screen starting with a
+// duration and '=' is shown for that duration instead of the interval, i.e.
+// "5s={{.Time}}|{{.Date}};Swipe card|to begin".
+func ParseDisplayScreens(schedule string, interval time.Duration) ([]DisplayScreen, error) {
+	if interval <= 0 {
+		interval = defaultIdleDisplayInterval
+	}
+	var screens []DisplayScreen
+	for _, entry := range strings.Split(schedule, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		screen := DisplayScreen{Duration: interval}
+		if prefix, rest, found := strings.Cut(entry, "="); found && !strings.Contains(prefix, "|") {
+			if duration, err := time.ParseDuration(strings.TrimSpace(prefix)); err == nil {
+				if duration <= 0 {
+					return nil, fmt.Errorf("display screen %q must have a positive duration", entry)
+				}
+				screen.Duration = duration
+				entry = rest
+			}
+		}
+		rows := strings.Split(entry, "|")
+		if len(rows) > displayRowCount {
+			return nil, fmt.Errorf("display screen %q has more than %d rows", entry, displayRowCount)
+		}
+		for i, row := range rows {
+			rowTemplate, err := template.New(fmt.Sprintf("row%d", i+1)).Parse(row)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse display screen %q: %s", entry, err.Error())
+			}
+			// a row showing unknown data fails now rather than on the LCD
+			if err := rowTemplate.Execute(io.Discard, DisplayData{}); err != nil {
+				return nil, fmt.Errorf("failed to parse display screen %q: %s", entry, err.Error())
+			}
+			screen.rows[i] = rowTemplate
+		}
+		screens = append(screens, screen)
+	}
+	return screens, nil
+}
+
+// Render returns the rows of the screen for the data, truncated to the row
+// length of the LCD
+func (screen DisplayScreen) Render(data DisplayData, rowLength int) ([displayRowCount]string, error) {
+	var rows [displayRowCount]string
+	for i, rowTemplate := range screen.rows {
+		if rowTemplate == nil {
+			continue
+		}
+		var row bytes.Buffer
+		if err := rowTemplate.Execute(&row, data); err != nil {
+			return rows, fmt.Errorf("failed to render display row %d: %s", i+1, err.Error())
+		}
+		rows[i] = row.String()
+		if rowLength > 0 && len(rows[i]) > rowLength {
+			rows[i] = rows[i][:rowLength]
+		}
+	}
+	return rows, nil
+}
+
+// IdleDisplay rotates the screens of a schedule on the LCD while the kiosk
+// is idle. The rotation restarts at the first screen every time the kiosk
+// becomes idle. A nil IdleDisplay shows nothing.
+type IdleDisplay struct {
+	mutex     sync.Mutex
+	screens   []DisplayScreen
+	next      int
+	idleSince time.Time
+	shownTill time.Time
+}
+
+// NewIdleDisplay creates an IdleDisplay rotating the screens, or nil when
+// there are none
+func NewIdleDisplay(screens []DisplayScreen) *IdleDisplay {
+	if len(screens) == 0 {
+		return nil
+	}
+	return &IdleDisplay{screens: screens}
+}
+
+// due returns the screen to show at now, when the kiosk has been idle long
+// enough and the last screen was shown for its duration
+func (display *IdleDisplay) due(idle bool, now time.Time) (DisplayScreen, bool) {
+	if display == nil {
+		return DisplayScreen{}, false
+	}
+	display.mutex.Lock()
+	defer display.mutex.Unlock()
+	if !idle {
+		display.next = 0
+		display.idleSince = time.Time{}
+		display.shownTill = time.Time{}
+		return DisplayScreen{}, false
+	}
+	if display.idleSince.IsZero() {
+		display.idleSince = now
+	}
+	if now.Sub(display.idleSince) < idleDisplaySettle || now.Before(display.shownTill) {
+		return DisplayScreen{}, false
+	}
+	screen := display.screens[display.next]
+	display.next = (display.next + 1) % len(display.screens)
+	display.shownTill = now.Add(screen.Duration)
+	return screen, true
+}
+
+// SessionDisplay shows a screen of the current session's progress on the
+// LCD during a vend, such as its countdown, and shows it again only when it
+// changes. A nil SessionDisplay shows nothing.
+type SessionDisplay struct {
+	mutex  sync.Mutex
+	screen DisplayScreen
+	shown  *[displayRowCount]string
+}
+
+// NewSessionDisplay creates a SessionDisplay of the first of the screens, or
+// nil when there are none
+func NewSessionDisplay(screens []DisplayScreen) *SessionDisplay {
+	if len(screens) == 0 {
+		return nil
+	}
+	return &SessionDisplay{screen: screens[0]}
+}
+
+// changed returns whether the rows are not on the LCD yet, and records them
+// as shown
+func (display *SessionDisplay) changed(rows [displayRowCount]string) bool {
+	display.mutex.Lock()
+	defer display.mutex.Unlock()
+	if display.shown != nil && *display.shown == rows {
+		return false
+	}
+	display.shown = &rows
+	return true
+}
+
+// reset forgets the shown rows once the vend is over, so that the next vend
+// shows its screen at once
+func (display *SessionDisplay) reset() {
+	display.mutex.Lock()
+	defer display.mutex.Unlock()
+	display.shown = nil
+}
+
+// RunIdleDisplay rotates the idle screens on the LCD while the kiosk is
+// idle, and returns at once without an idle display
+func (vendingState *VendingState) RunIdleDisplay(lc logger.LoggingClient) {
+	if vendingState.IdleDisplay == nil {
+		return
+	}
+	ticker := time.NewTicker(idleDisplayPoll)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		vendingState.LockSession()
+		vendingState.showIdleScreen(lc, now)
+		vendingState.UnlockSession()
+	}
+}
+
+// showIdleScreen shows the next idle screen when it is due at now
+func (vendingState *VendingState) showIdleScreen(lc logger.LoggingClient, now time.Time) {
+	screen, ok := vendingState.IdleDisplay.due(vendingState.idle(), now)
+	if !ok {
+		return
+	}
+	rows, err := screen.Render(vendingState.displayData(now), vendingState.Configuration.LCDRowLength)
+	if err != nil {
+		lc.Errorf("failed to render the idle display: %s", err.Error())
+		return
+	}
+	if err := vendingState.displayRows(lc, rows[0], rows[1], rows[2]); err != nil {
+		lc.Errorf("failed to show the idle display: %s", err.Error())
+	}
+}
+
+// RunSessionDisplay shows the session screen on the LCD during each vend,
+// and returns at once without a session display
+func (vendingState *VendingState) RunSessionDisplay(lc logger.LoggingClient) {
+	if vendingState.SessionDisplay == nil {
+		return
+	}
+	ticker := time.NewTicker(idleDisplayPoll)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		vendingState.LockSession()
+		vendingState.showSessionScreen(lc, now)
+		vendingState.UnlockSession()
+	}
+}
+
+// showSessionScreen shows the session screen at now when the session's
+// progress changed the rows since they were last shown
+func (vendingState *VendingState) showSessionScreen(lc logger.LoggingClient, now time.Time) {
+	display := vendingState.SessionDisplay
+	data := vendingState.displayData(now)
+	if !data.Session.Stage.vending() {
+		display.reset()
+		return
+	}
+	rows, err := display.screen.Render(data, vendingState.Configuration.LCDRowLength)
+	if err != nil {
+		lc.Errorf("failed to render the session display: %s", err.Error())
+		return
+	}
+	if !display.changed(rows) {
+		return
+	}
+	if err := vendingState.displayRows(lc, rows[0], rows[1], rows[2]); err != nil {
+		lc.Errorf("failed to show the session display: %s", err.Error())
+	}
+}
+
+// displayData returns what the display screens show at now
+func (vendingState *VendingState) displayData(now time.Time) DisplayData {
+	return DisplayData{
+		Time:    now.Format("15:04"),
+		Date:    now.Format("Mon Jan 2"),
+		KioskID: vendingState.Configuration.KioskID,
+		Session: vendingState.CurrentSession(now),
+	}
+}
+
+// idle returns whether the kiosk is waiting for a customer, and the LCD is
+// free for the idle display
+func (vendingState *VendingState) idle() bool {
+	return !vendingState.busy() && !vendingState.PriceCheck.showing(time.Now())
+}
+
+// busy returns whether the kiosk is out of service, vending, waiting for a
+// PIN or enrolling a card
+func (vendingState *VendingState) busy() bool {
+	return vendingState.MaintenanceMode ||
+		vendingState.Workflow.Vending() ||
+		vendingState.SessionLingering ||
+		vendingState.PinEntry.Waiting() ||
+		vendingState.Enrollment.Waiting()
+}
@@ -0,0 +1,175 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDisplayScreens(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Schedule          string
+		ExpectedDurations []time.Duration
+		ExpectedError     bool
+	}{
+		{"empty", "", nil, false},
+		{"interval", "Swipe card|to begin;Fresh snacks", []time.Duration{10 * time.Second, 10 * time.Second}, false},
+		{"own duration", "5s={{.Time}}|{{.Date}};Swipe card", []time.Duration{5 * time.Second, 10 * time.Second}, false},
+		{"equals in text", "2+2=4|math", []time.Duration{10 * time.Second}, false},
+		{"too many rows", "a|b|c|d", nil, true},
+		{"bad template", "{{.Time", nil, true},
+		{"unknown data", "{{.Price}}", nil, true},
+		{"zero duration", "0s=Swipe card", nil, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			screens, err := ParseDisplayScreens(currentTest.Schedule, 10*time.Second)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var durations []time.Duration
+			for _, screen := range screens {
+				durations = append(durations, screen.Duration)
+			}
+			assert.Equal(t, currentTest.ExpectedDurations, durations)
+		})
+	}
+}
+
+func TestDisplayScreenRender(t *testing.T) {
+	screens, err := ParseDisplayScreens("{{.Time}} {{.KioskID}}|{{.Date}}|Swipe a card to begin your purchase", 0)
+	require.NoError(t, err)
+	require.Len(t, screens, 1)
+	rows, err := screens[0].Render(DisplayData{Time: "09:30", Date: "Mon Jan 2", KioskID: "kiosk-1"}, 19)
+	require.NoError(t, err)
+	assert.Equal(t, [3]string{"09:30 kiosk-1", "Mon Jan 2", "Swipe a card to beg"}, rows)
+
+	// rows a screen does not have are blank
+	screens, err = ParseDisplayScreens("Fresh snacks", 0)
+	require.NoError(t, err)
+	rows, err = screens[0].Render(DisplayData{}, 19)
+	require.NoError(t, err)
+	assert.Equal(t, [3]string{"Fresh snacks", "", ""}, rows)
+}
+
+func TestIdleDisplayRotation(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	screens, err := ParseDisplayScreens("5s={{.KioskID}};Swipe card|to begin", 10*time.Second)
+	require.NoError(t, err)
+	vendingState := VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName:     "controller-board",
+			ControllerBoardDisplayRow1Cmd: "displayRow1",
+			ControllerBoardDisplayRow2Cmd: "displayRow2",
+			ControllerBoardDisplayRow3Cmd: "displayRow3",
+			KioskID:                       "kiosk-1",
+			LCDRowLength:                  19,
+		},
+		CommandClient: mockCommandClient,
+		IdleDisplay:   NewIdleDisplay(screens),
+	}
+	lc := logger.NewMockClient()
+	displayed := func() int {
+		return len(mockCommandClient.Calls)
+	}
+
+	// the rotation starts once the kiosk has been idle for a while
+	start := time.Now()
+	vendingState.showIdleScreen(lc, start)
+	vendingState.showIdleScreen(lc, start.Add(idleDisplaySettle/2))
+	assert.Equal(t, 0, displayed())
+	vendingState.showIdleScreen(lc, start.Add(idleDisplaySettle))
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow1", map[string]string{"displayRow1": "kiosk-1"})
+	assert.Equal(t, 3, displayed())
+
+	// each screen is shown for its duration
+	vendingState.showIdleScreen(lc, start.Add(idleDisplaySettle+4*time.Second))
+	assert.Equal(t, 3, displayed())
+	vendingState.showIdleScreen(lc, start.Add(idleDisplaySettle+5*time.Second))
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow1", map[string]string{"displayRow1": "Swipe card"})
+	assert.Equal(t, 6, displayed())
+
+	// a vend stops the rotation, which restarts at the first screen
+	vendingState.Workflow = NewWorkflow(StateAuthorized)
+	vendingState.showIdleScreen(lc, start.Add(idleDisplaySettle+20*time.Second))
+	assert.Equal(t, 6, displayed())
+	vendingState.Workflow = NewWorkflow(StateIdle)
+	restart := start.Add(time.Minute)
+	vendingState.showIdleScreen(lc, restart)
+	assert.Equal(t, 6, displayed())
+	vendingState.showIdleScreen(lc, restart.Add(idleDisplaySettle))
+	assert.Equal(t, 9, displayed())
+	assert.Equal(t, map[string]string{"displayRow1": "kiosk-1"}, mockCommandClient.Calls[6].Arguments.Get(3))
+
+	// nothing is shown in maintenance mode or without an idle display
+	vendingState.MaintenanceMode = true
+	vendingState.showIdleScreen(lc, restart.Add(time.Hour))
+	assert.Equal(t, 9, displayed())
+	vendingState.MaintenanceMode = false
+	vendingState.IdleDisplay = nil
+	vendingState.showIdleScreen(lc, restart.Add(time.Hour))
+	assert.Equal(t, 9, displayed())
+}
+
+func TestSessionDisplay(t *testing.T) {
+	screens, err := ParseDisplayScreens("{{.Session.Stage}}|{{.Session.ItemCount}} items|{{.Session.RemainingSeconds}}s left", 0)
+	require.NoError(t, err)
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	start := time.Now()
+	vendingState := VendingState{
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName:     "controller-board",
+			ControllerBoardDisplayRow1Cmd: "displayRow1",
+			ControllerBoardDisplayRow2Cmd: "displayRow2",
+			ControllerBoardDisplayRow3Cmd: "displayRow3",
+			LCDRowLength:                  19,
+		},
+		CommandClient:  mockCommandClient,
+		SessionDisplay: NewSessionDisplay(screens),
+		Workflow:       NewWorkflow(StateAuthorized),
+		StageDeadline:  start.Add(20 * time.Second),
+	}
+	lc := logger.NewMockClient()
+	displayed := func() int {
+		return len(mockCommandClient.Calls)
+	}
+
+	// the screen is shown when the vend starts, and again only when it changes
+	vendingState.showSessionScreen(lc, start)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow1", map[string]string{"displayRow1": "unlocked"})
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow3", map[string]string{"displayRow3": "20s left"})
+	assert.Equal(t, 3, displayed())
+	vendingState.showSessionScreen(lc, start.Add(100*time.Millisecond))
+	assert.Equal(t, 3, displayed())
+	vendingState.showSessionScreen(lc, start.Add(time.Second))
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow3", map[string]string{"displayRow3": "19s left"})
+	assert.Equal(t, 6, displayed())
+
+	// nothing is shown between vends, and the next vend is shown at once
+	vendingState.Workflow = NewWorkflow(StateIdle)
+	vendingState.showSessionScreen(lc, start.Add(2*time.Second))
+	assert.Equal(t, 6, displayed())
+	vendingState.Workflow = NewWorkflow(StateAuthorized)
+	vendingState.showSessionScreen(lc, start.Add(time.Second))
+	assert.Equal(t, 9, displayed())
+
+	assert.Nil(t, NewSessionDisplay(nil))
+}
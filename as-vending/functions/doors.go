@@ -0,0 +1,211 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Door is a cooler of a bank of coolers that this service runs, with the
+// devices of its controller board, inference and card reader, and the
+// weight sensor of its shelves when it has one
+type Door struct {
+	ControllerBoardDeviceName string `json:"controllerBoardDeviceName"`
+	InferenceDeviceName       string `json:"inferenceDeviceName"`
+	CardReaderDeviceName      string `json:"cardReaderDeviceName"`
+	WeightSensorDeviceName    string `json:"weightSensorDeviceName,omitempty"`
+}
+
+// DoorStatus is the state of the vend workflow and maintenance mode of a
+// door of the bank
+type DoorStatus struct {
+	Door
+	Workflow           WorkflowStatus      `json:"workflow"`
+	DoorClosed         bool                `json:"doorClosed"`
+	MaintenanceMode    bool                `json:"maintenanceMode"`
+	MaintenanceReasons []MaintenanceReason `json:"maintenanceReasons,omitempty"`
+}
+
+// DoorBank is the doors of a bank of coolers, each with its own vend
+// workflow, found by any of the device names of the door. The first door is
+// the VendingState the bank was created for. A nil DoorBank has no doors,
+// and the service runs only the door of its VendingState.
+type DoorBank struct {
+	doors    []*VendingState
+	byDevice map[string]*VendingState
+}
+
+// ParseDoors parses the doors of a bank of coolers, which are comma
+// separated controllerBoard:inferenceDevice:cardReader entries, followed by
+// :weightSensor for a door with a weight sensor
+func ParseDoors(setting string) ([]Door, error) {
+	var doors []Door
+	for _, entry := range strings.Split(setting, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		devices := strings.Split(entry, ":")
+		if len(devices) != 3 && len(devices) != 4 {
+			return nil, fmt.Errorf("door %q must be controllerBoard:inferenceDevice:cardReader[:weightSensor]", entry)
+		}
+		for i := range devices {
+			devices[i] = strings.TrimSpace(devices[i])
+			if devices[i] == "" {
+				return nil, fmt.Errorf("door %q must be controllerBoard:inferenceDevice:cardReader[:weightSensor]", entry)
+			}
+		}
+		door := Door{ControllerBoardDeviceName: devices[0], InferenceDeviceName: devices[1], CardReaderDeviceName: devices[2]}
+		if len(devices) == 4 {
+			door.WeightSensorDeviceName = devices[3]
+		}
+		doors = append(doors, door)
+	}
+	return doors, nil
+}
+
+// NewDoorBank creates the bank of the door of the vending state and the
+// other doors. Each other door has its own vend workflow, card reader
+// monitor and copy of the configuration with its devices, and shares the
+// stage timeouts, SLA tracker, billing circuit and metrics of the vending
+// state. A door with a weight sensor is cross-checked with the settings of
+// the vending state, which needs a weight sensor too. Card enrollment, PIN
+// entry, price checks, the LCD screens and the session journal are only
+// available at the door of the vending state. It returns nil without other
+// doors.
+func NewDoorBank(vendingState *VendingState, doors []Door) (*DoorBank, error) {
+	if len(doors) == 0 {
+		return nil, nil
+	}
+	bank := &DoorBank{byDevice: make(map[string]*VendingState)}
+	if err := bank.add(vendingState, vendingState.door()); err != nil {
+		return nil, err
+	}
+	for _, door := range doors {
+		if door.WeightSensorDeviceName != "" && vendingState.CrossCheck == nil {
+			return nil, fmt.Errorf("the weight sensor %s of door %s needs WeightSensorDeviceName to be set", door.WeightSensorDeviceName, door.ControllerBoardDeviceName)
+		}
+		if err := bank.add(vendingState.newDoor(door), door); err != nil {
+			return nil, err
+		}
+	}
+	return bank, nil
+}
+
+func (bank *DoorBank) add(vendingState *VendingState, door Door) error {
+	for _, deviceName := range []string{door.ControllerBoardDeviceName, door.InferenceDeviceName, door.CardReaderDeviceName, door.WeightSensorDeviceName} {
+		if deviceName == "" {
+			continue
+		}
+		if _, ok := bank.byDevice[deviceName]; ok {
+			return fmt.Errorf("device %s is configured for more than one door", deviceName)
+		}
+		bank.byDevice[deviceName] = vendingState
+	}
+	bank.doors = append(bank.doors, vendingState)
+	return nil
+}
+
+// Door returns the door of the device, which is its controller board,
+// inference device, card reader or weight sensor, or false when no door has the device
+func (bank *DoorBank) Door(deviceName string) (*VendingState, bool) {
+	if bank == nil {
+		return nil, false
+	}
+	door, ok := bank.byDevice[deviceName]
+	return door, ok
+}
+
+// Doors returns every door of the bank, the door of the vending state first
+func (bank *DoorBank) Doors() []*VendingState {
+	if bank == nil {
+		return nil
+	}
+	return append([]*VendingState{}, bank.doors...)
+}
+
+// Status returns the state of each door of the bank
+func (bank *DoorBank) Status() []DoorStatus {
+	statuses := []DoorStatus{}
+	for _, door := range bank.Doors() {
+		door.LockSession()
+		statuses = append(statuses, DoorStatus{
+			Door:               door.door(),
+			Workflow:           door.WorkflowStatus(),
+			DoorClosed:         door.DoorClosed,
+			MaintenanceMode:    door.MaintenanceMode,
+			MaintenanceReasons: door.MaintenanceReasons,
+		})
+		door.UnlockSession()
+	}
+	return statuses
+}
+
+// door returns the devices of the door of the vending state
+func (vendingState *VendingState) door() Door {
+	return Door{
+		ControllerBoardDeviceName: vendingState.Configuration.ControllerBoardDeviceName,
+		InferenceDeviceName:       vendingState.Configuration.InferenceDeviceName,
+		CardReaderDeviceName:      vendingState.Configuration.CardReaderDeviceName,
+		WeightSensorDeviceName:    vendingState.CrossCheck.DeviceName(),
+	}
+}
+
+// newDoor returns the vending state of another door of the bank, which is
+// idle with its door closed
+func (vendingState *VendingState) newDoor(door Door) *VendingState {
+	configuration := *vendingState.Configuration
+	configuration.ControllerBoardDeviceName = door.ControllerBoardDeviceName
+	configuration.InferenceDeviceName = door.InferenceDeviceName
+	configuration.CardReaderDeviceName = door.CardReaderDeviceName
+	configuration.WeightSensorDeviceName = door.WeightSensorDeviceName
+
+	newDoor := &VendingState{
+		Workflow:                       NewWorkflow(StateIdle),
+		DoorClosed:                     true,
+		ThreadStopChannel:              make(chan int),
+		DoorOpenWaitThreadStopChannel:  make(chan int),
+		DoorCloseWaitThreadStopChannel: make(chan int),
+		InferenceWaitThreadStopChannel: make(chan int),
+		Configuration:                  &configuration,
+		CommandClient:                  vendingState.CommandClient,
+		Timeouts:                       vendingState.Timeouts,
+		SLA:                            vendingState.SLA,
+		Billing:                        vendingState.Billing,
+		Quarantine:                     vendingState.Quarantine,
+		Reconciliation:                 vendingState.Reconciliation,
+		Discrepancies:                  vendingState.Discrepancies,
+		Maintenance:                    vendingState.Maintenance,
+		Outbox:                         vendingState.Outbox,
+		Webhooks:                       vendingState.Webhooks,
+		InferenceRetry:                 vendingState.InferenceRetry,
+		CrossCheck:                     vendingState.CrossCheck.forDevice(door.WeightSensorDeviceName),
+		Metrics:                        vendingState.Metrics,
+		SessionLinger:                  vendingState.SessionLinger,
+	}
+	// the secondary inference device watches the door of the vending state
+	newDoor.InferenceRetry.SecondaryDeviceName = ""
+	if vendingState.Readers != nil {
+		newDoor.Readers = NewReaderMonitor(vendingState.Readers.timeout, []string{door.CardReaderDeviceName}, vendingState.Readers.alert)
+	}
+	return newDoor
+}
+
+// cardReaderName returns the device name of the card reader of the door
+func (vendingState *VendingState) cardReaderName() string {
+	if vendingState.Configuration != nil && vendingState.Configuration.CardReaderDeviceName != "" {
+		return vendingState.Configuration.CardReaderDeviceName
+	}
+	return DsCardReader
+}
+
+// inferenceDeviceName returns the device name of the inference device of
+// the door
+func (vendingState *VendingState) inferenceDeviceName() string {
+	if vendingState.Configuration != nil && vendingState.Configuration.InferenceDeviceName != "" {
+		return vendingState.Configuration.InferenceDeviceName
+	}
+	return InferenceMQTTDevice
+}
@@ -0,0 +1,163 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDoors(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Setting       string
+		Expected      []Door
+		ExpectedError bool
+	}{
+		{"Doors", "controller-board-2:Inference-device-2:card-reader-2, controller-board-3:Inference-device-3:card-reader-3", []Door{
+			{ControllerBoardDeviceName: "controller-board-2", InferenceDeviceName: "Inference-device-2", CardReaderDeviceName: "card-reader-2"},
+			{ControllerBoardDeviceName: "controller-board-3", InferenceDeviceName: "Inference-device-3", CardReaderDeviceName: "card-reader-3"},
+		}, false},
+		{"Weight sensor", "controller-board-2:Inference-device-2:card-reader-2:shelf-scale-2", []Door{
+			{ControllerBoardDeviceName: "controller-board-2", InferenceDeviceName: "Inference-device-2", CardReaderDeviceName: "card-reader-2", WeightSensorDeviceName: "shelf-scale-2"},
+		}, false},
+		{"Single door", "", nil, false},
+		{"Missing card reader", "controller-board-2:Inference-device-2", nil, true},
+		{"Empty device", "controller-board-2::card-reader-2", nil, true},
+		{"Empty weight sensor", "controller-board-2:Inference-device-2:card-reader-2:", nil, true},
+		{"Too many devices", "controller-board-2:Inference-device-2:card-reader-2:shelf-scale-2:x", nil, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			doors, err := ParseDoors(currentTest.Setting)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, doors)
+		})
+	}
+}
+
+func newDoorBankTestState() *VendingState {
+	return &VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName: "controller-board",
+			InferenceDeviceName:       "Inference-device",
+			CardReaderDeviceName:      "card-reader",
+		},
+		DoorClosed: true,
+		Readers:    NewReaderMonitor(time.Minute, []string{"card-reader"}, nil),
+	}
+}
+
+func TestNewDoorBank(t *testing.T) {
+	vendingState := newDoorBankTestState()
+	bank, err := NewDoorBank(vendingState, nil)
+	require.NoError(t, err)
+	assert.Nil(t, bank)
+
+	second := Door{ControllerBoardDeviceName: "controller-board-2", InferenceDeviceName: "Inference-device-2", CardReaderDeviceName: "card-reader-2"}
+	bank, err = NewDoorBank(vendingState, []Door{second})
+	require.NoError(t, err)
+	doors := bank.Doors()
+	require.Len(t, doors, 2)
+	assert.Same(t, vendingState, doors[0])
+
+	for _, deviceName := range []string{"controller-board-2", "Inference-device-2", "card-reader-2"} {
+		door, ok := bank.Door(deviceName)
+		require.True(t, ok)
+		assert.Same(t, doors[1], door)
+	}
+	door, ok := bank.Door("card-reader")
+	require.True(t, ok)
+	assert.Same(t, vendingState, door)
+	_, ok = bank.Door("card-reader-9")
+	assert.False(t, ok)
+
+	// the other door has its own workflow and devices
+	assert.Equal(t, second, doors[1].door())
+	assert.NotSame(t, vendingState.Workflow, doors[1].Workflow)
+	assert.Equal(t, "controller-board", vendingState.Configuration.ControllerBoardDeviceName)
+	assert.Equal(t, []DoorStatus{
+		{Door: vendingState.door(), Workflow: WorkflowStatus{State: StateIdle, EnteredAt: vendingState.Workflow.Status().EnteredAt}, DoorClosed: true},
+		{Door: second, Workflow: WorkflowStatus{State: StateIdle, EnteredAt: doors[1].Workflow.Status().EnteredAt}, DoorClosed: true},
+	}, bank.Status())
+
+	_, err = NewDoorBank(vendingState, []Door{second, {ControllerBoardDeviceName: "controller-board-3", InferenceDeviceName: "Inference-device-3", CardReaderDeviceName: "card-reader-2"}})
+	assert.Error(t, err, "a card reader may not open two doors")
+}
+
+func TestNewDoorBankWeightSensor(t *testing.T) {
+	second := Door{ControllerBoardDeviceName: "controller-board-2", InferenceDeviceName: "Inference-device-2", CardReaderDeviceName: "card-reader-2", WeightSensorDeviceName: "shelf-scale-2"}
+	vendingState := newDoorBankTestState()
+	_, err := NewDoorBank(vendingState, []Door{second})
+	assert.Error(t, err, "a weight sensor needs the cross-check settings")
+
+	vendingState.Configuration.WeightSensorDeviceName = "shelf-scale"
+	vendingState.Configuration.CrossCheckProductEndpoint = "http://localhost:48095/inventory"
+	vendingState.Configuration.CrossCheckPolicy = "trustWeight"
+	vendingState.CrossCheck, err = ParseWeightCrossCheck(vendingState.Configuration)
+	require.NoError(t, err)
+	vendingState.Discrepancies, err = NewDiscrepancyLog("")
+	require.NoError(t, err)
+	vendingState.Doors, err = NewDoorBank(vendingState, []Door{second, {ControllerBoardDeviceName: "controller-board-3", InferenceDeviceName: "Inference-device-3", CardReaderDeviceName: "card-reader-3"}})
+	require.NoError(t, err)
+	doors := vendingState.Doors.Doors()
+	require.Len(t, doors, 3)
+
+	// each door is cross-checked against its own weight sensor
+	assert.Equal(t, "shelf-scale", vendingState.door().WeightSensorDeviceName)
+	assert.Equal(t, second, doors[1].door())
+	assert.Equal(t, CrossCheckTrustWeight, doors[1].CrossCheck.policy)
+	assert.Same(t, vendingState.Discrepancies, doors[1].Discrepancies)
+	assert.Nil(t, doors[2].CrossCheck)
+	door, ok := vendingState.Doors.Door("shelf-scale-2")
+	require.True(t, ok)
+	assert.Same(t, doors[1], door)
+
+	// the weight change is recorded by the door of the weight sensor
+	doors[1].Workflow = NewWorkflow(StateDoorOpen)
+	event := dtos.Event{DeviceName: "shelf-scale-2", Readings: []dtos.BaseReading{{ResourceName: WeightDeltaResource, SimpleReading: dtos.SimpleReading{Value: "-355"}}}}
+	continuePipeline, _ := vendingState.DeviceHelper(pkg.NewAppFuncContextForTest("", logger.NewMockClient()), event)
+	assert.False(t, continuePipeline)
+	require.NotNil(t, doors[1].weightDelta)
+	assert.Equal(t, -355.0, *doors[1].weightDelta)
+	assert.Nil(t, vendingState.weightDelta)
+}
+
+func TestDoorBankNil(t *testing.T) {
+	var bank *DoorBank
+	_, ok := bank.Door("card-reader")
+	assert.False(t, ok)
+	assert.Empty(t, bank.Doors())
+	assert.Empty(t, bank.Status())
+}
+
+func TestDeviceHelperDoors(t *testing.T) {
+	vendingState := newDoorBankTestState()
+	var err error
+	vendingState.Doors, err = NewDoorBank(vendingState, []Door{{ControllerBoardDeviceName: "controller-board-2", InferenceDeviceName: "Inference-device-2", CardReaderDeviceName: "card-reader-2"}})
+	require.NoError(t, err)
+	second := vendingState.Doors.Doors()[1]
+	ctx := pkg.NewAppFuncContextForTest("", logger.NewMockClient())
+
+	// the heartbeat of the card reader of the other door is tracked by that door
+	event := dtos.NewEvent("card-reader", "card-reader-2", CardReaderStatusResource)
+	continuePipeline, _ := vendingState.DeviceHelper(ctx, event)
+	assert.False(t, continuePipeline)
+	require.Len(t, second.Readers.Health(), 1)
+	assert.NotZero(t, second.Readers.Health()[0].LastSeen)
+	assert.Zero(t, vendingState.Readers.Health()[0].LastSeen)
+}
@@ -0,0 +1,334 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+// The states of a card enrollment. Only a waiting enrollment captures the
+// next card swipe, the other states are the outcome of the last one.
+const (
+	EnrollmentIdle      = "idle"
+	EnrollmentWaiting   = "waiting"
+	EnrollmentEnrolled  = "enrolled"
+	EnrollmentFailed    = "failed"
+	EnrollmentTimedOut  = "timedOut"
+	EnrollmentCancelled = "cancelled"
+)
+
+// defaultEnrollmentTimeout is how long an enrollment waits for a card swipe
+// when no timeout is configured
+const defaultEnrollmentTimeout = 60 * time.Second
+
+// enrollmentResultDisplay is how long the outcome of an enrollment is shown
+// on the LCD before it goes back to normal operation
+const enrollmentResultDisplay = 5 * time.Second
+
+var (
+	// ErrEnrollmentDisabled is returned when no enrollment endpoint is
+	// configured
+	ErrEnrollmentDisabled = errors.New("card enrollment is not configured")
+	// ErrEnrollmentInProgress is returned when an enrollment is already
+	// waiting for a card swipe
+	ErrEnrollmentInProgress = errors.New("an enrollment is already waiting for a card swipe")
+	// ErrVendInProgress is returned when a customer is vending, whose card
+	// swipes must not be enrolled
+	ErrVendInProgress = errors.New("a vend is in progress")
+)
+
+// EnrollmentRequest is an admin's request to enroll the next card swiped at
+// the kiosk, for the existing person of PersonID, for a new person of the
+// existing account of AccountID, or, without either, for a new person with
+// a new account
+type EnrollmentRequest struct {
+	RoleID    int    `json:"roleID,omitempty"`
+	PersonID  int    `json:"personID,omitempty"`
+	AccountID int    `json:"accountID,omitempty"`
+	FullName  string `json:"fullName,omitempty"`
+}
+
+// enrollCardRequest is the request to the authentication service to enroll
+// the swiped card
+type enrollCardRequest struct {
+	CardID string `json:"cardID"`
+	EnrollmentRequest
+}
+
+// EnrollmentStatus is the current or last enrollment for REST API consumers
+type EnrollmentStatus struct {
+	State     string             `json:"state"`
+	Request   *EnrollmentRequest `json:"request,omitempty"`
+	StartedAt int64              `json:"startedAt,string,omitempty"`
+	ExpiresAt int64              `json:"expiresAt,string,omitempty"`
+	CardID    string             `json:"cardId,omitempty"` // the swiped card
+	// Enrollment is the enrolled card with its person and account, as
+	// returned by the authentication service
+	Enrollment json.RawMessage `json:"enrollment,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Enrollment puts the kiosk in enrollment mode, in which the next card swipe
+// is enrolled through the authentication service instead of opening the
+// door. A nil Enrollment is never waiting.
+type Enrollment struct {
+	mutex    sync.Mutex
+	endpoint string
+	timeout  time.Duration
+	status   EnrollmentStatus
+	timer    *time.Timer
+	// authorization is the Authorization header of the admin that started
+	// the enrollment, which the authentication service requires to enroll
+	// the card
+	authorization string
+}
+
+// NewEnrollment creates an Enrollment that enrolls cards at the endpoint of
+// the authentication service, and waits for the timeout for a card swipe
+func NewEnrollment(endpoint string, timeout time.Duration) *Enrollment {
+	if timeout <= 0 {
+		timeout = defaultEnrollmentTimeout
+	}
+	return &Enrollment{
+		endpoint: endpoint,
+		timeout:  timeout,
+		status:   EnrollmentStatus{State: EnrollmentIdle},
+	}
+}
+
+// ParseEnrollmentTimeout parses how long an enrollment waits for a card
+// swipe, empty is the default timeout
+func ParseEnrollmentTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return defaultEnrollmentTimeout, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("enrollment timeout %q must be a positive duration", timeout)
+	}
+	return duration, nil
+}
+
+// Start waits for a card swipe to enroll for the request, with the
+// Authorization header of the admin. The onTimeout function is called when
+// no card was swiped in time.
+func (enrollment *Enrollment) Start(lc logger.LoggingClient, request EnrollmentRequest, authorization string, onTimeout func()) (EnrollmentStatus, error) {
+	if enrollment == nil {
+		return EnrollmentStatus{State: EnrollmentIdle}, ErrEnrollmentDisabled
+	}
+	enrollment.mutex.Lock()
+	defer enrollment.mutex.Unlock()
+	if enrollment.status.State == EnrollmentWaiting {
+		return enrollment.status, ErrEnrollmentInProgress
+	}
+
+	now := time.Now()
+	enrollment.status = EnrollmentStatus{
+		State:     EnrollmentWaiting,
+		Request:   &request,
+		StartedAt: now.UnixNano(),
+		ExpiresAt: now.Add(enrollment.timeout).UnixNano(),
+	}
+	enrollment.authorization = authorization
+	startedAt := enrollment.status.StartedAt
+	enrollment.timer = time.AfterFunc(enrollment.timeout, func() {
+		if enrollment.expire(startedAt) {
+			lc.Info("card enrollment timed out without a card swipe")
+			if onTimeout != nil {
+				onTimeout()
+			}
+		}
+	})
+	lc.Infof("card enrollment started, waiting %s for a card swipe", enrollment.timeout)
+	return enrollment.status, nil
+}
+
+// expire times out the enrollment that was started at startedAt, if it is
+// still waiting, and returns whether it did
+func (enrollment *Enrollment) expire(startedAt int64) bool {
+	enrollment.mutex.Lock()
+	defer enrollment.mutex.Unlock()
+	if enrollment.status.State != EnrollmentWaiting || enrollment.status.StartedAt != startedAt {
+		return false
+	}
+	enrollment.status.State = EnrollmentTimedOut
+	return true
+}
+
+// Cancel stops waiting for a card swipe, and returns false when no
+// enrollment was waiting
+func (enrollment *Enrollment) Cancel(lc logger.LoggingClient) (EnrollmentStatus, bool) {
+	if enrollment == nil {
+		return EnrollmentStatus{State: EnrollmentIdle}, false
+	}
+	enrollment.mutex.Lock()
+	defer enrollment.mutex.Unlock()
+	if enrollment.status.State != EnrollmentWaiting {
+		return enrollment.status, false
+	}
+	enrollment.timer.Stop()
+	enrollment.status.State = EnrollmentCancelled
+	lc.Info("card enrollment cancelled")
+	return enrollment.status, true
+}
+
+// Waiting returns whether the next card swipe is enrolled
+func (enrollment *Enrollment) Waiting() bool {
+	if enrollment == nil {
+		return false
+	}
+	enrollment.mutex.Lock()
+	defer enrollment.mutex.Unlock()
+	return enrollment.status.State == EnrollmentWaiting
+}
+
+// Status returns the current or last enrollment
+func (enrollment *Enrollment) Status() EnrollmentStatus {
+	if enrollment == nil {
+		return EnrollmentStatus{State: EnrollmentIdle}
+	}
+	enrollment.mutex.Lock()
+	defer enrollment.mutex.Unlock()
+	return enrollment.status
+}
+
+// Capture enrolls the swiped card for the waiting enrollment through the
+// authentication service, and returns false when no enrollment was waiting
+func (enrollment *Enrollment) Capture(lc logger.LoggingClient, cardID string) (EnrollmentStatus, bool) {
+	if enrollment == nil {
+		return EnrollmentStatus{State: EnrollmentIdle}, false
+	}
+	enrollment.mutex.Lock()
+	defer enrollment.mutex.Unlock()
+	if enrollment.status.State != EnrollmentWaiting {
+		return enrollment.status, false
+	}
+	enrollment.timer.Stop()
+	enrollment.status.CardID = cardID
+
+	result, err := enrollment.enrollCard(lc, enrollCardRequest{CardID: cardID, EnrollmentRequest: *enrollment.status.Request})
+	if err != nil {
+		lc.Errorf("failed to enroll card %s: %s", cardID, err.Error())
+		enrollment.status.State = EnrollmentFailed
+		enrollment.status.Error = err.Error()
+		return enrollment.status, true
+	}
+	lc.Infof("enrolled card %s", cardID)
+	enrollment.status.State = EnrollmentEnrolled
+	enrollment.status.Enrollment = result
+	return enrollment.status, true
+}
+
+// enrollCard sends the card to the enrollment endpoint with the admin's
+// Authorization header, and the endpoint responds with the enrolled card,
+// person and account
+func (enrollment *Enrollment) enrollCard(lc logger.LoggingClient, request enrollCardRequest) (json.RawMessage, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	lc.Debugf("enrolling card %s at %s", request.CardID, enrollment.endpoint)
+	enrollRequest, err := http.NewRequest(http.MethodPost, enrollment.endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.authorization != "" {
+		enrollRequest.Header.Set("Authorization", enrollment.authorization)
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(enrollRequest)
+	if err != nil {
+		return nil, fmt.Errorf("error sending command: %v", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// the authentication service says why the card was not enrolled,
+		// such as a card that is already enrolled or a token that is not
+		// an admin's
+		err := fmt.Errorf("error sending command: received status code: %v", resp.Status)
+		if reason, readErr := io.ReadAll(resp.Body); readErr == nil && len(reason) > 0 {
+			return nil, fmt.Errorf("%s: %s", err.Error(), string(reason))
+		}
+		return nil, err
+	}
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the enrolled card: %s", err.Error())
+	}
+	if !json.Valid(result) {
+		return nil, errors.New("received an invalid enrolled card")
+	}
+	return result, nil
+}
+
+// StartEnrollment puts the kiosk in enrollment mode, so that the next card
+// swipe is enrolled for the request with the Authorization header of the
+// admin instead of opening the door. It is refused while a customer is
+// vending.
+func (vendingState *VendingState) StartEnrollment(lc logger.LoggingClient, request EnrollmentRequest, authorization string) (EnrollmentStatus, error) {
+	if vendingState.Enrollment == nil {
+		return EnrollmentStatus{State: EnrollmentIdle}, ErrEnrollmentDisabled
+	}
+	if vendingState.Workflow.Vending() || vendingState.SessionLingering {
+		return vendingState.Enrollment.Status(), ErrVendInProgress
+	}
+	status, err := vendingState.Enrollment.Start(lc, request, authorization, func() {
+		vendingState.LockSession()
+		defer vendingState.UnlockSession()
+		vendingState.displayMaintenance(lc)
+	})
+	if err != nil {
+		return status, err
+	}
+	if err := vendingState.displayRows(lc, "Card enrollment", "Swipe a card", "to enroll it"); err != nil {
+		lc.Errorf("failed to display the card enrollment: %s", err.Error())
+	}
+	return status, nil
+}
+
+// CancelEnrollment takes the kiosk out of enrollment mode, and returns
+// false when it was not waiting for a card swipe
+func (vendingState *VendingState) CancelEnrollment(lc logger.LoggingClient) (EnrollmentStatus, bool) {
+	status, cancelled := vendingState.Enrollment.Cancel(lc)
+	if cancelled {
+		vendingState.displayMaintenance(lc)
+	}
+	return status, cancelled
+}
+
+// enrollCard enrolls the card of the card reader event for the waiting
+// enrollment. The door is never unlocked for the swipe.
+func (vendingState *VendingState) enrollCard(lc logger.LoggingClient, event dtos.Event) (bool, interface{}) {
+	if len(event.Readings) == 0 || len(event.Readings[0].Value) < 1 {
+		return false, fmt.Errorf("event reading was empty, devicename: %s", event.DeviceName)
+	}
+	status, ok := vendingState.Enrollment.Capture(lc, event.Readings[0].Value)
+	if !ok {
+		return false, nil
+	}
+
+	result := "Enrollment failed"
+	if status.State == EnrollmentEnrolled {
+		result = "Card enrolled"
+	}
+	if err := vendingState.displayRows(lc, "Card enrollment", result, ""); err != nil {
+		lc.Errorf("failed to display the card enrollment: %s", err.Error())
+	}
+	time.AfterFunc(enrollmentResultDisplay, func() {
+		vendingState.LockSession()
+		defer vendingState.UnlockSession()
+		vendingState.displayMaintenance(lc)
+	})
+	return false, nil
+}
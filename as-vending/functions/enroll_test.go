@@ -0,0 +1,173 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newEnrollmentVendingState(endpoint string, timeout time.Duration) (*VendingState, *client_mocks.CommandClient) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	return &VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName:      "controller-board",
+			ControllerBoardDisplayResetCmd: "displayReset",
+			ControllerBoardDisplayRow1Cmd:  "displayRow1",
+			ControllerBoardDisplayRow2Cmd:  "displayRow2",
+			ControllerBoardDisplayRow3Cmd:  "displayRow3",
+		},
+		CommandClient: mockCommandClient,
+		Enrollment:    NewEnrollment(endpoint, timeout),
+	}, mockCommandClient
+}
+
+func TestEnrollmentCapture(t *testing.T) {
+	var received enrollCardRequest
+	var receivedAuthorization string
+	authentication := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedAuthorization = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		if received.CardID == "0003293374" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("Card 0003293374 is already enrolled for person 1"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"card":{"cardId":"` + received.CardID + `"},"person":{"personId":8},"account":{"accountId":6}}`))
+	}))
+	defer authentication.Close()
+
+	tests := []struct {
+		Name          string
+		CardID        string
+		ExpectedState string
+		ExpectedError string
+	}{
+		{"enrolled", "0001230009", EnrollmentEnrolled, ""},
+		{"already enrolled", "0003293374", EnrollmentFailed, "already enrolled"},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			lc := logger.NewMockClient()
+			vendingState, mockCommandClient := newEnrollmentVendingState(authentication.URL, time.Minute)
+
+			status, err := vendingState.StartEnrollment(lc, EnrollmentRequest{AccountID: 6, FullName: "New Person"}, "Bearer admin-token")
+			require.NoError(t, err)
+			assert.Equal(t, EnrollmentWaiting, status.State)
+			assert.True(t, vendingState.Enrollment.Waiting())
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow1", map[string]string{"displayRow1": "Card enrollment"})
+
+			// the swipe is enrolled and never starts a vend
+			event := dtos.Event{DeviceName: DsCardReader, Readings: []dtos.BaseReading{{SimpleReading: dtos.SimpleReading{Value: currentTest.CardID}}}}
+			ok, result := vendingState.DeviceHelper(pkg.NewAppFuncContextForTest("", lc), event)
+			assert.False(t, ok)
+			assert.Nil(t, result)
+			assert.False(t, vendingState.Workflow.Vending())
+			assert.False(t, vendingState.Enrollment.Waiting())
+
+			assert.Equal(t, currentTest.CardID, received.CardID)
+			assert.Equal(t, "Bearer admin-token", receivedAuthorization, "the card is enrolled with the token of the admin")
+			assert.Equal(t, 6, received.AccountID)
+			assert.Equal(t, "New Person", received.FullName)
+
+			status = vendingState.Enrollment.Status()
+			assert.Equal(t, currentTest.ExpectedState, status.State)
+			assert.Equal(t, currentTest.CardID, status.CardID)
+			if currentTest.ExpectedError != "" {
+				assert.Contains(t, status.Error, currentTest.ExpectedError)
+				assert.Empty(t, status.Enrollment)
+				return
+			}
+			assert.Empty(t, status.Error)
+			assert.JSONEq(t, `{"card":{"cardId":"0001230009"},"person":{"personId":8},"account":{"accountId":6}}`, string(status.Enrollment))
+		})
+	}
+}
+
+func TestEnrollmentStartAndCancel(t *testing.T) {
+	lc := logger.NewMockClient()
+	vendingState, mockCommandClient := newEnrollmentVendingState("http://localhost:48096/enroll", time.Minute)
+
+	_, err := vendingState.StartEnrollment(lc, EnrollmentRequest{}, "")
+	require.NoError(t, err)
+	_, err = vendingState.StartEnrollment(lc, EnrollmentRequest{}, "")
+	assert.ErrorIs(t, err, ErrEnrollmentInProgress)
+
+	status, cancelled := vendingState.CancelEnrollment(lc)
+	assert.True(t, cancelled)
+	assert.Equal(t, EnrollmentCancelled, status.State)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayReset", map[string]string{"displayReset": ""})
+	_, cancelled = vendingState.CancelEnrollment(lc)
+	assert.False(t, cancelled)
+
+	// a card swipe after the enrollment ended is not captured
+	_, ok := vendingState.Enrollment.Capture(lc, "0001230009")
+	assert.False(t, ok)
+
+	// enrollment is refused while a customer is vending
+	vendingState.Workflow = NewWorkflow(StateAuthorized)
+	_, err = vendingState.StartEnrollment(lc, EnrollmentRequest{}, "")
+	assert.ErrorIs(t, err, ErrVendInProgress)
+
+	// without an endpoint enrollment is disabled
+	vendingState.Workflow = NewWorkflow(StateIdle)
+	vendingState.Enrollment = nil
+	_, err = vendingState.StartEnrollment(lc, EnrollmentRequest{}, "")
+	assert.ErrorIs(t, err, ErrEnrollmentDisabled)
+	assert.False(t, vendingState.Enrollment.Waiting())
+	assert.Equal(t, EnrollmentIdle, vendingState.Enrollment.Status().State)
+}
+
+func TestEnrollmentTimeout(t *testing.T) {
+	lc := logger.NewMockClient()
+	vendingState, _ := newEnrollmentVendingState("http://localhost:48096/enroll", 10*time.Millisecond)
+	displayReset := make(chan struct{}, 1)
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, "controller-board", "displayReset", mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil).Run(func(mock.Arguments) {
+		displayReset <- struct{}{}
+	})
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	vendingState.CommandClient = mockCommandClient
+
+	_, err := vendingState.StartEnrollment(lc, EnrollmentRequest{}, "")
+	require.NoError(t, err)
+
+	// the display goes back to normal operation once the enrollment times out
+	select {
+	case <-displayReset:
+	case <-time.After(time.Second):
+		t.Fatal("the display was not reset after the enrollment timed out")
+	}
+	assert.Equal(t, EnrollmentTimedOut, vendingState.Enrollment.Status().State)
+	assert.False(t, vendingState.Enrollment.Waiting())
+}
+
+func TestParseEnrollmentTimeout(t *testing.T) {
+	timeout, err := ParseEnrollmentTimeout("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultEnrollmentTimeout, timeout)
+	timeout, err = ParseEnrollmentTimeout("30s")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, timeout)
+	_, err = ParseEnrollmentTimeout("0s")
+	assert.Error(t, err)
+	_, err = ParseEnrollmentTimeout("soon")
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// maxReconciliationVends is the number of fallback vends kept for
+// reconciliation
+const maxReconciliationVends = 1000
+
+// manualEntryFlagReason is the reason the ledger transactions of items
+// entered by hand are flagged with
+const manualEntryFlagReason = "Items were entered by hand while inference was unavailable"
+
+var (
+	// ErrManualEntryNotAwaited is returned when items are entered while no
+	// vend is waiting for them
+	ErrManualEntryNotAwaited = errors.New("no vend is waiting for its items to be entered")
+	// ErrInvalidManualEntry is returned for items entered without a SKU
+	ErrInvalidManualEntry = errors.New("invalid items")
+)
+
+// fallbackPrompts are the LCD messages displayed once the door of a vend of
+// each fallback is closed
+var fallbackPrompts = map[string]string{
+	config.InferenceFallbackManualEntry: "Enter items on UI",
+	config.InferenceFallbackBillLater:   "Billed later",
+}
+
+// ReconciliationVend is a vend made while inference was unavailable, which
+// an operator checks against the camera snapshots. Items are the items
+// entered by hand, and are empty when the vend is billed later.
+type ReconciliationVend struct {
+	Fallback  string          `json:"fallback"`
+	AccountID int             `json:"accountID,omitempty"`
+	CardID    string          `json:"cardID,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Items     []InferenceItem `json:"items,omitempty"`
+	Timestamp int64           `json:"timestamp,string"`
+}
+
+// ReconciliationLog keeps the most recent fallback vends. A nil
+// ReconciliationLog does not keep anything.
+type ReconciliationLog struct {
+	mutex sync.Mutex
+	vends []ReconciliationVend
+}
+
+// NewReconciliationLog creates an empty ReconciliationLog
+func NewReconciliationLog() *ReconciliationLog {
+	return &ReconciliationLog{vends: []ReconciliationVend{}}
+}
+
+// Add flags the vend for reconciliation
+func (log *ReconciliationLog) Add(lc logger.LoggingClient, vend ReconciliationVend) {
+	lc.Warnf("Flagged the %s vend of account %d, session %s for reconciliation", vend.Fallback, vend.AccountID, vend.SessionID)
+	if log == nil {
+		return
+	}
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.vends = append(log.vends, vend)
+	if len(log.vends) > maxReconciliationVends {
+		log.vends = log.vends[len(log.vends)-maxReconciliationVends:]
+	}
+}
+
+// Vends returns the vends flagged for reconciliation, oldest first
+func (log *ReconciliationLog) Vends() []ReconciliationVend {
+	vends := []ReconciliationVend{}
+	if log == nil {
+		return vends
+	}
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	return append(vends, log.vends...)
+}
+
+// reconcile flags the current vend for reconciliation, with the items that
+// were entered for it
+func (vendingState *VendingState) reconcile(lc logger.LoggingClient, items []InferenceItem) ReconciliationVend {
+	vend := ReconciliationVend{
+		Fallback:  vendingState.InferenceFallbackMode,
+		AccountID: vendingState.CurrentUserData.AccountID,
+		CardID:    vendingState.CurrentUserData.CardID,
+		SessionID: vendingState.SessionID,
+		Items:     items,
+		Timestamp: time.Now().UnixNano(),
+	}
+	vendingState.Reconciliation.Add(lc, vend)
+	return vend
+}
+
+// displayFallbackPrompt shows what the customer does next once the door of
+// a fallback vend is closed
+func (vendingState *VendingState) displayFallbackPrompt(lc logger.LoggingClient) {
+	settings := make(map[string]string)
+	settings["displayRow2"] = fallbackPrompts[vendingState.InferenceFallbackMode]
+	if err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings); err != nil {
+		lc.Errorf("Failed to display the %s prompt: %s", vendingState.InferenceFallbackMode, err.Error())
+	}
+}
+
+// EnterItems settles the vend waiting for its items to be entered on the
+// kiosk UI, with the items as its inference result, and flags it for
+// reconciliation
+func (vendingState *VendingState) EnterItems(lc logger.LoggingClient, items []InferenceItem) (ReconciliationVend, error) {
+	if vendingState.InferenceFallbackMode != config.InferenceFallbackManualEntry || vendingState.Workflow.State() != StateInferring {
+		return ReconciliationVend{}, ErrManualEntryNotAwaited
+	}
+	payload := InferencePayload{Items: items}
+	if err := payload.validate(); err != nil {
+		return ReconciliationVend{}, fmt.Errorf("%w: %s", ErrInvalidManualEntry, err.Error())
+	}
+	if !vendingState.TransitionFrom(lc, StateInferring, StateSettling, "itemsEntered") {
+		return ReconciliationVend{}, ErrManualEntryNotAwaited
+	}
+	vend := vendingState.reconcile(lc, items)
+	return vend, vendingState.completeVend(lc, payload.skuDelta())
+}
+
+// billLater ends the vend without charging what was taken in this visit,
+// which is flagged for reconciliation and billed from the camera snapshots
+// later. The items taken during the earlier visits of a session are still
+// charged.
+func (vendingState *VendingState) billLater(lc logger.LoggingClient, event string) {
+	if !vendingState.TransitionFrom(lc, StateInferring, StateSettling, event) {
+		return
+	}
+	vendingState.DoorClosedAt = time.Time{}
+	// items that were not entered in time are billed later too
+	vendingState.InferenceFallbackMode = config.InferenceFallbackBillLater
+	vendingState.reconcile(lc, nil)
+	vendingState.displayFallbackPrompt(lc)
+
+	if vendingState.SessionBasket != nil {
+		if err := vendingState.EndSession(lc); err != nil {
+			lc.Errorf("Failed to end the session: %s", err.Error())
+		}
+		return
+	}
+	vendingState.notifyWebhooks(lc, WebhookSessionCompleted, "billedLater", "")
+	vendingState.CurrentUserData = OutputData{}
+	vendingState.SplitPayers = nil
+	vendingState.SessionID = ""
+	vendingState.Metrics.SetActiveSessions(0)
+	vendingState.finishVend(lc, "billedLater")
+}
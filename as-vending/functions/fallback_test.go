@@ -0,0 +1,206 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/responses"
+	edgexError "github.com/edgexfoundry/go-mod-core-contracts/v3/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationLog(t *testing.T) {
+	lc := logger.NewMockClient()
+	var nilLog *ReconciliationLog
+	nilLog.Add(lc, ReconciliationVend{Fallback: config.InferenceFallbackBillLater})
+	assert.Empty(t, nilLog.Vends())
+
+	log := NewReconciliationLog()
+	for i := 0; i < maxReconciliationVends+1; i++ {
+		log.Add(lc, ReconciliationVend{Fallback: config.InferenceFallbackBillLater, AccountID: i})
+	}
+	vends := log.Vends()
+	require.Len(t, vends, maxReconciliationVends)
+	assert.Equal(t, 1, vends[0].AccountID, "the oldest vend is dropped")
+}
+
+// newFallbackVendingState returns a customer's vend that was started while
+// inference was unavailable, waiting for its inference result
+func newFallbackVendingState(t *testing.T, fallback string, ledgerURL string) (*VendingState, *client_mocks.CommandClient) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := &VendingState{
+		Workflow:                       NewWorkflow(StateInferring),
+		InferenceWaitThreadStopChannel: make(chan int),
+		ThreadStopChannel:              make(chan int),
+		CurrentUserData:                OutputData{AccountID: 1, CardID: "0003293374", RoleID: 1},
+		SessionID:                      "session-1",
+		InferenceFallbackMode:          fallback,
+		Reconciliation:                 NewReconciliationLog(),
+		Timeouts:                       NewStageTimeouts(StageDurations{Inference: time.Minute}),
+		Configuration: &config.VendingConfig{
+			InventoryService:               ledgerURL,
+			InventoryAuditLogService:       ledgerURL,
+			ControllerBoardDisplayResetCmd: "displayreset",
+			ControllerBoardDisplayRow1Cmd:  "displayrow1",
+			ControllerBoardDisplayRow2Cmd:  "displayrow2",
+			LedgerService:                  ledgerURL,
+			InferenceFallback:              fallback,
+		},
+		CommandClient: mockCommandClient,
+	}
+	t.Cleanup(func() { close(vendingState.ThreadStopChannel) })
+	return vendingState, mockCommandClient
+}
+
+func TestEnterItems(t *testing.T) {
+	var receivedLedger deltaLedger
+	ledgerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" && r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&receivedLedger); err == nil {
+				outputJSON, _ := json.Marshal(Ledger{TransactionID: 123, LineTotal: 3.98, IsFlagged: true})
+				w.Write(outputJSON)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ledgerServer.Close()
+	lc := logger.NewMockClient()
+
+	vendingState, mockCommandClient := newFallbackVendingState(t, config.InferenceFallbackManualEntry, ledgerServer.URL)
+	_, err := vendingState.EnterItems(lc, []InferenceItem{{Delta: -2}})
+	assert.True(t, errors.Is(err, ErrInvalidManualEntry))
+
+	vend, err := vendingState.EnterItems(lc, []InferenceItem{{SKU: "HXI86WHU", Delta: -2}})
+	require.NoError(t, err)
+	assert.Equal(t, config.InferenceFallbackManualEntry, vend.Fallback)
+	assert.Equal(t, "session-1", vend.SessionID)
+	assert.Equal(t, []ReconciliationVend{vend}, vendingState.Reconciliation.Vends())
+	assert.Equal(t, deltaLedger{AccountID: 1, DeltaSKUs: []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}, SessionID: "session-1", FlagReason: manualEntryFlagReason}, receivedLedger)
+	assert.Equal(t, StateIdle, vendingState.Workflow.State())
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow1", mock.Anything)
+
+	// the vend is settled, so the items cannot be entered again
+	_, err = vendingState.EnterItems(lc, []InferenceItem{{SKU: "HXI86WHU", Delta: -2}})
+	assert.True(t, errors.Is(err, ErrManualEntryNotAwaited))
+}
+
+func TestEnterItemsNotAwaited(t *testing.T) {
+	vendingState, _ := newFallbackVendingState(t, "", "http://localhost")
+	_, err := vendingState.EnterItems(logger.NewMockClient(), []InferenceItem{{SKU: "HXI86WHU", Delta: -2}})
+	assert.True(t, errors.Is(err, ErrManualEntryNotAwaited), "a vend with inference does not take manual entries")
+	assert.Empty(t, vendingState.Reconciliation.Vends())
+}
+
+func TestWaitForInferenceBillLater(t *testing.T) {
+	ledgerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("a vend billed later is not charged, got %s %s", r.Method, r.URL.Path)
+	}))
+	defer ledgerServer.Close()
+
+	vendingState, mockCommandClient := newFallbackVendingState(t, config.InferenceFallbackBillLater, ledgerServer.URL)
+	vendingState.WaitForInference(logger.NewMockClient())
+
+	assert.Equal(t, StateIdle, vendingState.Workflow.State())
+	assert.Equal(t, OutputData{}, vendingState.CurrentUserData)
+	vends := vendingState.Reconciliation.Vends()
+	require.Len(t, vends, 1)
+	assert.Equal(t, config.InferenceFallbackBillLater, vends[0].Fallback)
+	assert.Equal(t, 1, vends[0].AccountID)
+	assert.Empty(t, vends[0].Items)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Billed later"})
+}
+
+func TestManualEntryTimeoutBillsLater(t *testing.T) {
+	vendingState, mockCommandClient := newFallbackVendingState(t, config.InferenceFallbackManualEntry, "http://localhost")
+	vendingState.Timeouts = NewStageTimeouts(StageDurations{Inference: 10 * time.Millisecond})
+	lc := logger.NewMockClient()
+	vendingState.WaitForInference(lc)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Enter items on UI"})
+
+	require.Eventually(t, func() bool {
+		return len(vendingState.Reconciliation.Vends()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, config.InferenceFallbackBillLater, vendingState.Reconciliation.Vends()[0].Fallback)
+	assert.False(t, vendingState.MaintenanceMode, "the vend is billed later rather than not verified")
+}
+
+func TestVerifyDoorAccessInferenceFallback(t *testing.T) {
+	testCases := []struct {
+		TestCaseName      string
+		InferenceFallback string
+		ExpectedUnlock    bool
+	}{
+		{"Maintenance without a fallback", "", false},
+		{"Manual entry", config.InferenceFallbackManualEntry, true},
+		{"Bill later", config.InferenceFallbackBillLater, true},
+	}
+
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authDataJSON, err := json.Marshal(OutputData{AccountID: 1, RoleID: 1})
+		require.NoError(t, err)
+		w.Write(authDataJSON)
+	}))
+	defer authServer.Close()
+
+	for _, tc := range testCases {
+		currentTest := tc
+		t.Run(currentTest.TestCaseName, func(t *testing.T) {
+			mockCommandClient := &client_mocks.CommandClient{}
+			mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+			// the inference service does not respond to its heartbeat
+			eventResp := responses.NewEventResponse("", "", http.StatusInternalServerError, dtos.Event{})
+			mockCommandClient.On("IssueGetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&eventResp, edgexError.NewCommonEdgeXWrapper(errors.New("timed out")))
+
+			vendingState := VendingState{
+				Workflow:          NewWorkflow(StateIdle),
+				ThreadStopChannel: make(chan int),
+				Configuration: &config.VendingConfig{
+					InferenceHeartbeatCmd:         "inferenceHeartbeat",
+					ControllerBoardDisplayRow1Cmd: "displayrow1",
+					ControllerBoardDisplayRow2Cmd: "displayrow2",
+					ControllerBoardDisplayRow3Cmd: "displayrow3",
+					ControllerBoardLock1Cmd:       "lock1",
+					AuthenticationEndpoint:        authServer.URL,
+					InferenceFallback:             currentTest.InferenceFallback,
+				},
+				Timeouts:      NewStageTimeouts(StageDurations{DoorOpen: time.Minute}),
+				CommandClient: mockCommandClient,
+			}
+
+			event := dtos.Event{
+				DeviceName: "card-reader",
+				Readings:   []dtos.BaseReading{{DeviceName: "card-reader", SimpleReading: dtos.SimpleReading{Value: "0003293374"}}},
+			}
+			resp, _ := vendingState.VerifyDoorAccess(logger.NewMockClient(), event)
+			require.True(t, resp)
+			close(vendingState.ThreadStopChannel)
+
+			assert.Equal(t, currentTest.ExpectedUnlock, vendingState.Workflow.Vending())
+			assert.Equal(t, !currentTest.ExpectedUnlock, vendingState.MaintenanceMode)
+			if !currentTest.ExpectedUnlock {
+				assert.Equal(t, []MaintenanceReason{ReasonInferenceUnavailable}, vendingState.MaintenanceReasons)
+				return
+			}
+			assert.Equal(t, currentTest.InferenceFallback, vendingState.InferenceFallbackMode)
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Camera offline"})
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", mock.Anything)
+		})
+	}
+}
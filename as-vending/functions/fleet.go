@@ -0,0 +1,229 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// FleetGroupAll is the group of every kiosk of the fleet
+const FleetGroupAll = "all"
+
+// defaultFleetRequestTimeout is how long a kiosk has to confirm a change of
+// its store state when no timeout is configured
+const defaultFleetRequestTimeout = 5 * time.Second
+
+// FleetKiosk is an as-vending instance of the fleet, in a group of kiosks
+// that are opened and closed together, such as the kiosks of a building
+type FleetKiosk struct {
+	Group   string `json:"group"`
+	KioskID string `json:"kioskId"`
+	URL     string `json:"url"`
+}
+
+// KioskConfirmation is the outcome of a fleet request for one kiosk. The
+// kiosk confirmed the request when it reported the requested state.
+type KioskConfirmation struct {
+	KioskID   string      `json:"kioskId"`
+	URL       string      `json:"url"`
+	Confirmed bool        `json:"confirmed"`
+	State     *StoreState `json:"state,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// FleetReport is the outcome of a fleet request for each kiosk of the group
+type FleetReport struct {
+	Group     string              `json:"group"`
+	Open      *bool               `json:"open,omitempty"` // the requested state, none when it was only queried
+	Confirmed int                 `json:"confirmed"`
+	Failed    int                 `json:"failed"`
+	Kiosks    []KioskConfirmation `json:"kiosks"`
+}
+
+// FleetStoreStateRequest is a request to open or close every kiosk of the
+// group, or of the whole fleet with the all group
+type FleetStoreStateRequest struct {
+	Group string `json:"group"`
+	StoreStateRequest
+}
+
+// Fleet opens and closes groups of kiosks through the store state endpoint
+// of each kiosk's as-vending service. A nil Fleet has no kiosks.
+type Fleet struct {
+	kiosks []FleetKiosk
+	client *http.Client
+}
+
+// NewFleet creates a Fleet of the kiosks. Each kiosk has the timeout to
+// respond to a request.
+func NewFleet(kiosks []FleetKiosk, timeout time.Duration) *Fleet {
+	if timeout <= 0 {
+		timeout = defaultFleetRequestTimeout
+	}
+	return &Fleet{
+		kiosks: kiosks,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// ParseFleetKiosks parses the FleetKiosks configuration, comma separated
+// group:kioskId=url entries such as
+// building-a:kiosk-1=http://10.0.0.11:48099. A kiosk is in several groups
+// when it has an entry for each.
+func ParseFleetKiosks(setting string) ([]FleetKiosk, error) {
+	var kiosks []FleetKiosk
+	for _, entry := range strings.Split(setting, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, kiosk, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("fleet kiosk %q must be group:kioskId=url", entry)
+		}
+		kioskID, url, found := strings.Cut(kiosk, "=")
+		group, kioskID, url = strings.TrimSpace(group), strings.TrimSpace(kioskID), strings.TrimSpace(url)
+		if !found || group == "" || kioskID == "" || url == "" {
+			return nil, fmt.Errorf("fleet kiosk %q must be group:kioskId=url", entry)
+		}
+		if group == FleetGroupAll {
+			return nil, fmt.Errorf("fleet kiosk %q may not be in the %q group, which has every kiosk", entry, FleetGroupAll)
+		}
+		kiosks = append(kiosks, FleetKiosk{Group: group, KioskID: kioskID, URL: strings.TrimSuffix(url, "/")})
+	}
+	return kiosks, nil
+}
+
+// ParseFleetRequestTimeout parses how long a kiosk has to respond to a
+// fleet request, empty is the default timeout
+func ParseFleetRequestTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return defaultFleetRequestTimeout, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("fleet request timeout %q must be a positive duration", timeout)
+	}
+	return duration, nil
+}
+
+// Kiosks returns the kiosks of the group, each kiosk once, or false when
+// the group has no kiosks
+func (fleet *Fleet) Kiosks(group string) ([]FleetKiosk, bool) {
+	if fleet == nil {
+		return nil, false
+	}
+	var kiosks []FleetKiosk
+	seen := make(map[string]bool)
+	for _, kiosk := range fleet.kiosks {
+		if group != FleetGroupAll && kiosk.Group != group {
+			continue
+		}
+		if seen[kiosk.KioskID] {
+			continue
+		}
+		seen[kiosk.KioskID] = true
+		kiosks = append(kiosks, kiosk)
+	}
+	return kiosks, len(kiosks) > 0
+}
+
+// SetStoreState opens or closes every kiosk of the group and reports whether
+// each kiosk confirmed it. The kiosks are requested concurrently, so that
+// an unreachable kiosk does not hold up the others. The authorization is
+// sent to each kiosk, which checks it when tokens are configured.
+func (fleet *Fleet) SetStoreState(lc logger.LoggingClient, group string, request StoreStateRequest, authorization string) FleetReport {
+	body, _ := json.Marshal(request)
+	report := fleet.eachKiosk(group, func(kiosk FleetKiosk) KioskConfirmation {
+		confirmation := fleet.requestStoreState(kiosk, http.MethodPost, body, authorization)
+		if confirmation.State != nil && confirmation.State.Open != request.Open {
+			confirmation.Confirmed = false
+			confirmation.Error = fmt.Sprintf("kiosk reported open %t", confirmation.State.Open)
+		}
+		if !confirmation.Confirmed {
+			lc.Errorf("kiosk %s did not confirm the store state change: %s", kiosk.KioskID, confirmation.Error)
+		}
+		return confirmation
+	})
+	report.Open = &request.Open
+	lc.Infof("store state open %t sent to group %s: %d kiosks confirmed, %d failed", request.Open, group, report.Confirmed, report.Failed)
+	return report
+}
+
+// StoreStates reports the store state of every kiosk of the group
+func (fleet *Fleet) StoreStates(group string) FleetReport {
+	return fleet.eachKiosk(group, func(kiosk FleetKiosk) KioskConfirmation {
+		return fleet.requestStoreState(kiosk, http.MethodGet, nil, "")
+	})
+}
+
+// eachKiosk runs the request against every kiosk of the group and reports
+// the outcomes in the order of the kiosks
+func (fleet *Fleet) eachKiosk(group string, request func(FleetKiosk) KioskConfirmation) FleetReport {
+	kiosks, _ := fleet.Kiosks(group)
+	report := FleetReport{Group: group, Kiosks: make([]KioskConfirmation, len(kiosks))}
+	var wg sync.WaitGroup
+	for index, kiosk := range kiosks {
+		wg.Add(1)
+		go func(index int, kiosk FleetKiosk) {
+			defer wg.Done()
+			report.Kiosks[index] = request(kiosk)
+		}(index, kiosk)
+	}
+	wg.Wait()
+	for _, confirmation := range report.Kiosks {
+		if confirmation.Confirmed {
+			report.Confirmed++
+		} else {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// requestStoreState sends the request to the store state endpoint of the
+// kiosk, which responds with its store state
+func (fleet *Fleet) requestStoreState(kiosk FleetKiosk, method string, body []byte, authorization string) KioskConfirmation {
+	confirmation := KioskConfirmation{KioskID: kiosk.KioskID, URL: kiosk.URL}
+	request, err := http.NewRequest(method, kiosk.URL+"/storeState", bytes.NewBuffer(body))
+	if err != nil {
+		confirmation.Error = err.Error()
+		return confirmation
+	}
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	resp, err := fleet.client.Do(request)
+	if err != nil {
+		confirmation.Error = err.Error()
+		return confirmation
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		confirmation.Error = err.Error()
+		return confirmation
+	}
+	if resp.StatusCode != http.StatusOK {
+		confirmation.Error = fmt.Sprintf("received status code: %s: %s", resp.Status, string(responseBody))
+		return confirmation
+	}
+	var state StoreState
+	if err := json.Unmarshal(responseBody, &state); err != nil {
+		confirmation.Error = fmt.Sprintf("received an invalid store state: %s", err.Error())
+		return confirmation
+	}
+	confirmation.State = &state
+	confirmation.Confirmed = true
+	return confirmation
+}
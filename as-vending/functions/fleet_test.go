@@ -0,0 +1,117 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFleetKiosks(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Setting       string
+		Expected      []FleetKiosk
+		ExpectedError bool
+	}{
+		{"empty", "", nil, false},
+		{"kiosks", "building-a:kiosk-1=http://10.0.0.11:48099/, building-b:kiosk-2=http://10.0.0.12:48099", []FleetKiosk{
+			{Group: "building-a", KioskID: "kiosk-1", URL: "http://10.0.0.11:48099"},
+			{Group: "building-b", KioskID: "kiosk-2", URL: "http://10.0.0.12:48099"},
+		}, false},
+		{"missing group", "kiosk-1=http://10.0.0.11:48099", nil, true},
+		{"missing url", "building-a:kiosk-1", nil, true},
+		{"all group", "all:kiosk-1=http://10.0.0.11:48099", nil, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			kiosks, err := ParseFleetKiosks(currentTest.Setting)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, kiosks)
+		})
+	}
+}
+
+func TestFleetKiosks(t *testing.T) {
+	fleet := NewFleet([]FleetKiosk{
+		{Group: "building-a", KioskID: "kiosk-1", URL: "http://10.0.0.11:48099"},
+		{Group: "holiday", KioskID: "kiosk-1", URL: "http://10.0.0.11:48099"},
+		{Group: "holiday", KioskID: "kiosk-2", URL: "http://10.0.0.12:48099"},
+	}, 0)
+
+	kiosks, ok := fleet.Kiosks("holiday")
+	assert.True(t, ok)
+	assert.Len(t, kiosks, 2)
+	kiosks, ok = fleet.Kiosks(FleetGroupAll)
+	assert.True(t, ok)
+	assert.Len(t, kiosks, 2, "a kiosk in several groups is requested once")
+	_, ok = fleet.Kiosks("building-b")
+	assert.False(t, ok)
+
+	var nilFleet *Fleet
+	_, ok = nilFleet.Kiosks(FleetGroupAll)
+	assert.False(t, ok)
+}
+
+func TestFleetSetStoreState(t *testing.T) {
+	// a kiosk that applies the request, one that ignores it and one that fails
+	kiosk := func(apply bool, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			var request StoreStateRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			state := StoreState{KioskID: r.Host, Open: true}
+			if apply {
+				state.Open = request.Open
+				state.Reason = request.Reason
+			}
+			_ = json.NewEncoder(w).Encode(state)
+		}))
+	}
+	applied := kiosk(true, http.StatusOK)
+	defer applied.Close()
+	ignored := kiosk(false, http.StatusOK)
+	defer ignored.Close()
+	failed := kiosk(true, http.StatusInternalServerError)
+	defer failed.Close()
+
+	fleet := NewFleet([]FleetKiosk{
+		{Group: "building-a", KioskID: "kiosk-1", URL: applied.URL},
+		{Group: "building-a", KioskID: "kiosk-2", URL: ignored.URL},
+		{Group: "building-a", KioskID: "kiosk-3", URL: failed.URL},
+		{Group: "building-a", KioskID: "kiosk-4", URL: "http://127.0.0.1:1"},
+		{Group: "building-b", KioskID: "kiosk-5", URL: applied.URL},
+	}, time.Second)
+
+	report := fleet.SetStoreState(logger.NewMockClient(), "building-a", StoreStateRequest{Open: false, Reason: "evacuation"}, "")
+	assert.Equal(t, "building-a", report.Group)
+	require.NotNil(t, report.Open)
+	assert.False(t, *report.Open)
+	assert.Equal(t, 1, report.Confirmed)
+	assert.Equal(t, 3, report.Failed)
+	require.Len(t, report.Kiosks, 4)
+	assert.Equal(t, "kiosk-1", report.Kiosks[0].KioskID)
+	assert.True(t, report.Kiosks[0].Confirmed)
+	assert.Equal(t, "evacuation", report.Kiosks[0].State.Reason)
+	assert.False(t, report.Kiosks[1].Confirmed, "a kiosk that reports another state did not confirm")
+	assert.NotEmpty(t, report.Kiosks[1].Error)
+	assert.False(t, report.Kiosks[2].Confirmed)
+	assert.False(t, report.Kiosks[3].Confirmed)
+	assert.NotEmpty(t, report.Kiosks[3].Error)
+}
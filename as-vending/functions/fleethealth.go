@@ -0,0 +1,233 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+// minFleetCheckInterval is the shortest interval the kiosks are checked at
+const minFleetCheckInterval = time.Second
+
+// KioskHealth is the health of a kiosk of the fleet, based on the last
+// heartbeat of each of its services. A kiosk that has not been heard from
+// since the service started is silent since the service started.
+type KioskHealth struct {
+	KioskID     string      `json:"kioskId"`
+	Online      bool        `json:"online"`
+	LastSeen    int64       `json:"lastSeen,string,omitempty"`
+	SilentForMs int64       `json:"silentForMs"`
+	Services    []Heartbeat `json:"services"`
+}
+
+// KioskAlert is the context of a kiosk that has been silent for longer
+// than the heartbeat timeout. It is logged, and published as an alert when
+// an alert function is set.
+type KioskAlert struct {
+	KioskID     string `json:"kioskId"`
+	LastSeen    int64  `json:"lastSeen,string,omitempty"`
+	SilentForMs int64  `json:"silentForMs"`
+	TimeoutMs   int64  `json:"timeoutMs"`
+	Timestamp   int64  `json:"timestamp,string"`
+}
+
+// FleetMonitor tracks the heartbeats of the kiosks of the fleet and detects
+// kiosks that have gone silent, since a kiosk that is powered off otherwise
+// goes unnoticed until a customer complains. A nil FleetMonitor does not
+// track anything.
+type FleetMonitor struct {
+	mutex    sync.Mutex
+	timeout  time.Duration
+	lastSeen map[string]time.Time
+	services map[string]map[string]Heartbeat
+	offline  map[string]bool
+	since    time.Time
+	alert    func(KioskAlert) error
+	now      func() time.Time
+}
+
+// NewFleetMonitor creates a FleetMonitor for the kiosks, which are offline
+// once none of their services has sent a heartbeat for longer than the
+// timeout. alert is called for every kiosk that goes offline and may be
+// nil.
+func NewFleetMonitor(timeout time.Duration, kioskIDs []string, alert func(KioskAlert) error) *FleetMonitor {
+	monitor := &FleetMonitor{
+		timeout:  timeout,
+		lastSeen: make(map[string]time.Time),
+		services: make(map[string]map[string]Heartbeat),
+		offline:  make(map[string]bool),
+		since:    time.Now(),
+		alert:    alert,
+		now:      time.Now,
+	}
+	for _, kioskID := range kioskIDs {
+		monitor.lastSeen[kioskID] = time.Time{}
+		monitor.services[kioskID] = make(map[string]Heartbeat)
+	}
+	return monitor
+}
+
+// ParseFleetHeartbeatTimeout parses how long a kiosk of the fleet may be
+// silent. An empty timeout disables fleet monitoring.
+func ParseFleetHeartbeatTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("fleet heartbeat timeout %q must be a positive duration", timeout)
+	}
+	return duration, nil
+}
+
+// Heartbeat records the heartbeat of a service of a kiosk. Heartbeats of
+// kiosks that are not monitored are ignored. It returns true when the kiosk
+// was offline and has come back.
+func (monitor *FleetMonitor) Heartbeat(lc logger.LoggingClient, heartbeat Heartbeat) bool {
+	if monitor == nil {
+		return false
+	}
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	services, ok := monitor.services[heartbeat.KioskID]
+	if !ok {
+		lc.Debugf("ignoring the heartbeat of %s of kiosk %s, which is not in the fleet", heartbeat.ServiceKey, heartbeat.KioskID)
+		return false
+	}
+	services[heartbeat.ServiceKey] = heartbeat
+	monitor.lastSeen[heartbeat.KioskID] = monitor.now()
+	if !monitor.offline[heartbeat.KioskID] {
+		return false
+	}
+	delete(monitor.offline, heartbeat.KioskID)
+	lc.Infof("kiosk %s is back online", heartbeat.KioskID)
+	return true
+}
+
+// Check detects the kiosks that have been silent for longer than the
+// timeout. Every kiosk that has just gone offline is logged and alerted.
+// It returns the kiosks that have just gone offline.
+func (monitor *FleetMonitor) Check(lc logger.LoggingClient) []KioskAlert {
+	if monitor == nil {
+		return nil
+	}
+
+	now := monitor.now()
+	var alerts []KioskAlert
+	monitor.mutex.Lock()
+	for kioskID, lastSeen := range monitor.lastSeen {
+		silentFor := now.Sub(monitor.seenAt(lastSeen))
+		if silentFor <= monitor.timeout || monitor.offline[kioskID] {
+			continue
+		}
+		monitor.offline[kioskID] = true
+		alert := KioskAlert{
+			KioskID:     kioskID,
+			SilentForMs: silentFor.Milliseconds(),
+			TimeoutMs:   monitor.timeout.Milliseconds(),
+			Timestamp:   now.UnixNano(),
+		}
+		if !lastSeen.IsZero() {
+			alert.LastSeen = lastSeen.UnixNano()
+		}
+		alerts = append(alerts, alert)
+	}
+	monitor.mutex.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].KioskID < alerts[j].KioskID
+	})
+	for _, alert := range alerts {
+		lc.Errorf("kiosk %s has been silent for %v, timeout is %v", alert.KioskID, time.Duration(alert.SilentForMs)*time.Millisecond, monitor.timeout)
+		if monitor.alert != nil {
+			if err := monitor.alert(alert); err != nil {
+				lc.Errorf("failed to alert silent kiosk %s: %s", alert.KioskID, err.Error())
+			}
+		}
+	}
+	return alerts
+}
+
+// Health returns the health of each kiosk, ordered by kiosk ID, with the
+// last heartbeat of each of its services ordered by service key
+func (monitor *FleetMonitor) Health() []KioskHealth {
+	health := []KioskHealth{}
+	if monitor == nil {
+		return health
+	}
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	now := monitor.now()
+	for kioskID, lastSeen := range monitor.lastSeen {
+		kioskHealth := KioskHealth{
+			KioskID:     kioskID,
+			Online:      !monitor.offline[kioskID],
+			SilentForMs: now.Sub(monitor.seenAt(lastSeen)).Milliseconds(),
+			Services:    []Heartbeat{},
+		}
+		if !lastSeen.IsZero() {
+			kioskHealth.LastSeen = lastSeen.UnixNano()
+		}
+		for _, heartbeat := range monitor.services[kioskID] {
+			kioskHealth.Services = append(kioskHealth.Services, heartbeat)
+		}
+		sort.Slice(kioskHealth.Services, func(i, j int) bool {
+			return kioskHealth.Services[i].ServiceKey < kioskHealth.Services[j].ServiceKey
+		})
+		health = append(health, kioskHealth)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].KioskID < health[j].KioskID
+	})
+	return health
+}
+
+// seenAt is when a kiosk was last seen, which is when the service started
+// for kiosks that have not been seen
+func (monitor *FleetMonitor) seenAt(lastSeen time.Time) time.Time {
+	if lastSeen.IsZero() {
+		return monitor.since
+	}
+	return lastSeen
+}
+
+// MonitorFleet checks the kiosks of the fleet until the service stops
+func (vendingState *VendingState) MonitorFleet(lc logger.LoggingClient) {
+	if vendingState.FleetHealth == nil {
+		return
+	}
+	interval := vendingState.FleetHealth.timeout / 2
+	if interval < minFleetCheckInterval {
+		interval = minFleetCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		vendingState.FleetHealth.Check(lc)
+	}
+}
+
+// kioskHeartbeat records the heartbeats of the heartbeat event
+func (vendingState *VendingState) kioskHeartbeat(lc logger.LoggingClient, event dtos.Event) {
+	for _, reading := range event.Readings {
+		if reading.ResourceName != HeartbeatResource {
+			continue
+		}
+		var heartbeat Heartbeat
+		if err := json.Unmarshal([]byte(reading.Value), &heartbeat); err != nil {
+			lc.Errorf("failed to read the heartbeat of %s: %s", event.SourceName, err.Error())
+			continue
+		}
+		vendingState.FleetHealth.Heartbeat(lc, heartbeat)
+	}
+}
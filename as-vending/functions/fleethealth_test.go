@@ -0,0 +1,123 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFleetHeartbeatTimeout(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Timeout       string
+		Expected      time.Duration
+		ExpectedError bool
+	}{
+		{"Timeout", "2m", 2 * time.Minute, false},
+		{"Disabled", "", 0, false},
+		{"Invalid duration", "soon", 0, true},
+		{"Negative duration", "-1s", 0, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			timeout, err := ParseFleetHeartbeatTimeout(currentTest.Timeout)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, timeout)
+		})
+	}
+}
+
+func TestFleetMonitor(t *testing.T) {
+	var alerts []KioskAlert
+	monitor := NewFleetMonitor(time.Minute, []string{"kiosk-1", "kiosk-2"}, func(alert KioskAlert) error {
+		alerts = append(alerts, alert)
+		return errors.New("message bus unavailable")
+	})
+	now := monitor.since
+	monitor.now = func() time.Time { return now }
+	lc := logger.NewMockClient()
+	vending := Heartbeat{ServiceKey: "as-vending", KioskID: "kiosk-1", Version: "1.2.0", Healthy: true}
+	boardStatus := Heartbeat{ServiceKey: "as-controller-board-status", KioskID: "kiosk-1", Version: "1.2.0", Healthy: true}
+
+	now = now.Add(30 * time.Second)
+	assert.False(t, monitor.Heartbeat(lc, vending))
+	assert.False(t, monitor.Heartbeat(lc, boardStatus))
+	assert.False(t, monitor.Heartbeat(lc, Heartbeat{ServiceKey: "as-vending", KioskID: "kiosk-9"}), "kiosks outside of the fleet are ignored")
+
+	// a kiosk that was never heard from is silent since the service started
+	now = now.Add(31 * time.Second)
+	require.Len(t, monitor.Check(lc), 1)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, KioskAlert{KioskID: "kiosk-2", SilentForMs: 61000, TimeoutMs: 60000, Timestamp: now.UnixNano()}, alerts[0])
+
+	now = now.Add(30 * time.Second)
+	assert.Len(t, monitor.Check(lc), 1)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, KioskAlert{
+		KioskID:     "kiosk-1",
+		LastSeen:    monitor.since.Add(30 * time.Second).UnixNano(),
+		SilentForMs: 61000,
+		TimeoutMs:   60000,
+		Timestamp:   now.UnixNano(),
+	}, alerts[1])
+
+	// an offline kiosk is only alerted once
+	now = now.Add(time.Minute)
+	assert.Empty(t, monitor.Check(lc))
+	assert.Len(t, alerts, 2)
+
+	assert.True(t, monitor.Heartbeat(lc, vending))
+	assert.Empty(t, monitor.Check(lc))
+	assert.Equal(t, []KioskHealth{
+		{KioskID: "kiosk-1", Online: true, LastSeen: now.UnixNano(), Services: []Heartbeat{boardStatus, vending}},
+		{KioskID: "kiosk-2", Online: false, SilentForMs: 151000, Services: []Heartbeat{}},
+	}, monitor.Health())
+}
+
+func TestFleetMonitorNil(t *testing.T) {
+	var monitor *FleetMonitor
+	lc := logger.NewMockClient()
+	assert.False(t, monitor.Heartbeat(lc, Heartbeat{KioskID: "kiosk-1"}))
+	assert.Empty(t, monitor.Check(lc))
+	assert.Empty(t, monitor.Health())
+}
+
+func TestKioskHeartbeatEvent(t *testing.T) {
+	vendingState := VendingState{
+		Workflow:      NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{KioskID: "kiosk-1"},
+		FleetHealth:   NewFleetMonitor(time.Minute, []string{"kiosk-1"}, nil),
+	}
+	lc := logger.NewMockClient()
+	heartbeat := Heartbeat{ServiceKey: "as-vending", KioskID: "kiosk-1", Version: "1.2.0", Healthy: true, UptimeSeconds: 60, Timestamp: 1678860010000000000}
+
+	// the heartbeat is read as the message bus delivers the event
+	published, err := json.Marshal(NewHeartbeatEvent(heartbeat))
+	require.NoError(t, err)
+	var event dtos.Event
+	require.NoError(t, json.Unmarshal(published, &event))
+	event, err = NewReadingDecoder().Decode(event)
+	require.NoError(t, err)
+
+	continuePipeline, _ := vendingState.DeviceHelper(pkg.NewAppFuncContextForTest("", lc), event)
+	assert.False(t, continuePipeline)
+	health := vendingState.FleetHealth.Health()
+	require.Len(t, health, 1)
+	assert.Equal(t, []Heartbeat{heartbeat}, health[0].Services)
+}
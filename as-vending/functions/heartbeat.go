@@ -0,0 +1,108 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+const (
+	// HeartbeatDeviceName is the device name of the events that kiosk
+	// heartbeats are published as, so that they reach the pipelines of the
+	// app services like the events of a device
+	HeartbeatDeviceName = "kiosk-heartbeat"
+	// HeartbeatResource is the resource of the object reading that holds
+	// the heartbeat
+	HeartbeatResource = "heartbeat"
+	// heartbeatTopicPrefix is the message bus topic that heartbeats are
+	// published under, followed by the service key and kiosk ID. It is under
+	// the events topic that app services subscribe to.
+	heartbeatTopicPrefix = "events/heartbeat"
+)
+
+// Heartbeat is published periodically by each app service of a kiosk, so
+// that the fleet can tell a kiosk that is powered off from one without
+// customers
+type Heartbeat struct {
+	ServiceKey    string   `json:"serviceKey"`
+	KioskID       string   `json:"kioskId"`
+	Version       string   `json:"version"`
+	Healthy       bool     `json:"healthy"`
+	Issues        []string `json:"issues,omitempty"`
+	UptimeSeconds int64    `json:"uptimeSeconds"`
+	Timestamp     int64    `json:"timestamp,string"`
+}
+
+// ParseHeartbeatInterval parses how often the heartbeat is published. An
+// empty interval disables publishing.
+func ParseHeartbeatInterval(interval string) (time.Duration, error) {
+	if interval == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(interval)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("heartbeat interval %q must be a positive duration", interval)
+	}
+	return duration, nil
+}
+
+// HeartbeatTopic returns the message bus topic of the heartbeats of the
+// service of the kiosk
+func HeartbeatTopic(serviceKey string, kioskID string) string {
+	return common.BuildTopic(heartbeatTopicPrefix, serviceKey, kioskID)
+}
+
+// NewHeartbeatEvent returns the heartbeat as the event it is published as
+func NewHeartbeatEvent(heartbeat Heartbeat) dtos.Event {
+	event := dtos.NewEvent(HeartbeatDeviceName, HeartbeatDeviceName, heartbeat.ServiceKey)
+	event.AddObjectReading(HeartbeatResource, heartbeat)
+	return event
+}
+
+// Heartbeat returns the heartbeat of the vending service. The kiosk is
+// unhealthy while it is in maintenance mode, with the maintenance reasons
+// as its issues.
+func (vendingState *VendingState) Heartbeat(serviceKey string, version string, startedAt time.Time, now time.Time) Heartbeat {
+	heartbeat := Heartbeat{
+		ServiceKey:    serviceKey,
+		KioskID:       vendingState.Configuration.KioskID,
+		Version:       version,
+		Healthy:       !vendingState.MaintenanceMode,
+		UptimeSeconds: int64(now.Sub(startedAt).Seconds()),
+		Timestamp:     now.UnixNano(),
+	}
+	for _, reason := range vendingState.MaintenanceReasons {
+		heartbeat.Issues = append(heartbeat.Issues, string(reason))
+	}
+	return heartbeat
+}
+
+// PublishHeartbeats publishes the heartbeat of the vending service at the
+// interval until the context is done. A heartbeat that fails to publish is
+// logged and the next one is still sent.
+func (vendingState *VendingState) PublishHeartbeats(ctx context.Context, lc logger.LoggingClient, serviceKey string, version string, interval time.Duration, publish func(topic string, event dtos.Event) error) {
+	startedAt := time.Now()
+	topic := HeartbeatTopic(serviceKey, vendingState.Configuration.KioskID)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		vendingState.LockSession()
+		heartbeat := vendingState.Heartbeat(serviceKey, version, startedAt, time.Now())
+		vendingState.UnlockSession()
+		if err := publish(topic, NewHeartbeatEvent(heartbeat)); err != nil {
+			lc.Errorf("failed to publish the heartbeat of kiosk %s: %s", heartbeat.KioskID, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
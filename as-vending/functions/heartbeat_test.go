@@ -0,0 +1,89 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeartbeatInterval(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Interval      string
+		Expected      time.Duration
+		ExpectedError bool
+	}{
+		{"Interval", "30s", 30 * time.Second, false},
+		{"Disabled", "", 0, false},
+		{"Invalid duration", "often", 0, true},
+		{"Zero duration", "0s", 0, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			interval, err := ParseHeartbeatInterval(currentTest.Interval)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, interval)
+		})
+	}
+}
+
+func TestVendingHeartbeat(t *testing.T) {
+	vendingState := VendingState{Workflow: NewWorkflow(StateIdle), Configuration: &config.VendingConfig{KioskID: "kiosk-1"}}
+	startedAt := time.Unix(1678860000, 0)
+	now := startedAt.Add(90 * time.Second)
+
+	assert.Equal(t, Heartbeat{
+		ServiceKey:    "as-vending",
+		KioskID:       "kiosk-1",
+		Version:       "1.2.0",
+		Healthy:       true,
+		UptimeSeconds: 90,
+		Timestamp:     now.UnixNano(),
+	}, vendingState.Heartbeat("as-vending", "1.2.0", startedAt, now))
+
+	vendingState.MaintenanceMode = true
+	vendingState.MaintenanceReasons = []MaintenanceReason{ReasonCardReaderOffline}
+	heartbeat := vendingState.Heartbeat("as-vending", "1.2.0", startedAt, now)
+	assert.False(t, heartbeat.Healthy)
+	assert.Equal(t, []string{string(ReasonCardReaderOffline)}, heartbeat.Issues)
+}
+
+func TestPublishHeartbeats(t *testing.T) {
+	vendingState := VendingState{Workflow: NewWorkflow(StateIdle), Configuration: &config.VendingConfig{KioskID: "kiosk-1"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	published := make(chan string)
+	var events []dtos.Event
+	go func() {
+		vendingState.PublishHeartbeats(ctx, logger.NewMockClient(), "as-vending", "1.2.0", time.Millisecond, func(topic string, event dtos.Event) error {
+			events = append(events, event)
+			published <- topic
+			return nil
+		})
+		close(published)
+	}()
+
+	// the first heartbeat is published right away
+	assert.Equal(t, "events/heartbeat/as-vending/kiosk-1", <-published)
+	cancel()
+	for range published {
+	}
+	require.NotEmpty(t, events)
+	assert.Equal(t, HeartbeatDeviceName, events[0].DeviceName)
+	assert.Equal(t, "as-vending", events[0].SourceName)
+	require.Len(t, events[0].Readings, 1)
+	assert.Equal(t, HeartbeatResource, events[0].Readings[0].ResourceName)
+}
@@ -0,0 +1,199 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// InferenceSchemaVersion is the version of the inference payload schema
+	// this service reads. Payloads without a version are the legacy list of
+	// SKU deltas.
+	InferenceSchemaVersion = 1
+
+	// maxQuarantinedPayloads is the number of rejected inference payloads
+	// kept for review
+	maxQuarantinedPayloads = 100
+)
+
+// errUnsupportedSchemaVersion is returned for inference payloads of a
+// schema version this service does not read
+var errUnsupportedSchemaVersion = errors.New("unsupported inference schema version")
+
+// InferencePayload is the inference result of a vend, the SKU deltas since
+// the door was opened. SessionID, when set, must be the session of the vend
+// it is for. Confidence is between 0 and 1, and is optional.
+type InferencePayload struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	SessionID     string          `json:"sessionID,omitempty"`
+	ModelVersion  string          `json:"modelVersion"`
+	Items         []InferenceItem `json:"items"`
+	Confidence    *float64        `json:"confidence,omitempty"`
+}
+
+// InferenceItem is the change in the units of a SKU detected by the model
+type InferenceItem struct {
+	SKU        string   `json:"sku"`
+	Delta      int      `json:"delta"`
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+// ParseInferencePayload parses and validates an inference payload. A JSON
+// array is a legacy payload, which is read as the items of a payload of
+// schema version 0. Objects must have a supported schemaVersion and may not
+// have fields that are not part of it, so that a changed payload is
+// rejected rather than read wrongly.
+func ParseInferencePayload(value string) (InferencePayload, error) {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "[") {
+		var skuDelta []deltaSKU
+		if err := json.Unmarshal([]byte(trimmed), &skuDelta); err != nil {
+			return InferencePayload{}, fmt.Errorf("invalid legacy inference payload: %s", err.Error())
+		}
+		payload := InferencePayload{Items: []InferenceItem{}}
+		for _, item := range skuDelta {
+			payload.Items = append(payload.Items, InferenceItem{SKU: item.SKU, Delta: item.Delta})
+		}
+		return payload, payload.validate()
+	}
+
+	var version struct {
+		SchemaVersion *int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal([]byte(trimmed), &version); err != nil {
+		return InferencePayload{}, fmt.Errorf("invalid inference payload: %s", err.Error())
+	}
+	if version.SchemaVersion == nil {
+		return InferencePayload{}, errors.New("inference payload has no schemaVersion")
+	}
+	if *version.SchemaVersion != InferenceSchemaVersion {
+		return InferencePayload{}, fmt.Errorf("%w %d, expected %d", errUnsupportedSchemaVersion, *version.SchemaVersion, InferenceSchemaVersion)
+	}
+
+	var payload InferencePayload
+	decoder := json.NewDecoder(bytes.NewReader([]byte(trimmed)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&payload); err != nil {
+		return InferencePayload{}, fmt.Errorf("invalid inference payload of schema version %d: %s", InferenceSchemaVersion, err.Error())
+	}
+	if payload.ModelVersion == "" {
+		return payload, errors.New("inference payload has no modelVersion")
+	}
+	if payload.Items == nil {
+		return payload, errors.New("inference payload has no items")
+	}
+	return payload, payload.validate()
+}
+
+// validate checks the items and confidences of the payload
+func (payload InferencePayload) validate() error {
+	if !validConfidence(payload.Confidence) {
+		return fmt.Errorf("inference confidence %v must be between 0 and 1", *payload.Confidence)
+	}
+	for i, item := range payload.Items {
+		if item.SKU == "" {
+			return fmt.Errorf("inference item %d has no sku", i)
+		}
+		if !validConfidence(item.Confidence) {
+			return fmt.Errorf("inference confidence %v of sku %s must be between 0 and 1", *item.Confidence, item.SKU)
+		}
+	}
+	return nil
+}
+
+func validConfidence(confidence *float64) bool {
+	return confidence == nil || (*confidence >= 0 && *confidence <= 1)
+}
+
+// skuDelta returns the items as the SKU deltas charged for the vend
+func (payload InferencePayload) skuDelta() []deltaSKU {
+	skuDelta := []deltaSKU{}
+	for _, item := range payload.Items {
+		skuDelta = append(skuDelta, deltaSKU{SKU: item.SKU, Delta: item.Delta})
+	}
+	return skuDelta
+}
+
+// QuarantinedInference is an inference payload that was rejected, with the
+// vend it was received for, so that the vend can be billed by hand
+type QuarantinedInference struct {
+	Payload   string `json:"payload"`
+	Reason    string `json:"reason"`
+	AccountID int    `json:"accountID,omitempty"`
+	CardID    string `json:"cardID,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	Timestamp int64  `json:"timestamp,string"`
+}
+
+// InferenceQuarantine keeps the most recent rejected inference payloads. A
+// nil InferenceQuarantine does not keep anything.
+type InferenceQuarantine struct {
+	mutex    sync.Mutex
+	payloads []QuarantinedInference
+}
+
+// NewInferenceQuarantine creates an empty InferenceQuarantine
+func NewInferenceQuarantine() *InferenceQuarantine {
+	return &InferenceQuarantine{payloads: []QuarantinedInference{}}
+}
+
+// Add quarantines a rejected payload of the vend of the user and session
+func (quarantine *InferenceQuarantine) Add(lc logger.LoggingClient, payload string, reason error, user OutputData, sessionID string) {
+	lc.Errorf("Quarantined the inference payload of account %d, session %s: %s", user.AccountID, sessionID, reason.Error())
+	if quarantine == nil {
+		return
+	}
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+	quarantine.payloads = append(quarantine.payloads, QuarantinedInference{
+		Payload:   payload,
+		Reason:    reason.Error(),
+		AccountID: user.AccountID,
+		CardID:    user.CardID,
+		SessionID: sessionID,
+		Timestamp: time.Now().UnixNano(),
+	})
+	if len(quarantine.payloads) > maxQuarantinedPayloads {
+		quarantine.payloads = quarantine.payloads[len(quarantine.payloads)-maxQuarantinedPayloads:]
+	}
+}
+
+// Payloads returns the quarantined payloads, oldest first
+func (quarantine *InferenceQuarantine) Payloads() []QuarantinedInference {
+	payloads := []QuarantinedInference{}
+	if quarantine == nil {
+		return payloads
+	}
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+	return append(payloads, quarantine.payloads...)
+}
+
+// readInference parses the inference payload of the current vend. A payload
+// that is not valid, or is for another session, is quarantined, and one with
+// a low confidence is recorded for review.
+func (vendingState *VendingState) readInference(lc logger.LoggingClient, value string) ([]deltaSKU, error) {
+	payload, err := ParseInferencePayload(value)
+	if err == nil && payload.SessionID != "" && vendingState.SessionID != "" && payload.SessionID != vendingState.SessionID {
+		err = fmt.Errorf("inference payload is for session %s, not the current session %s", payload.SessionID, vendingState.SessionID)
+	}
+	if err != nil {
+		vendingState.Quarantine.Add(lc, value, err, vendingState.CurrentUserData, vendingState.SessionID)
+		return nil, err
+	}
+	if payload.SchemaVersion > 0 {
+		lc.Infof("Inference of model %s for session %s has %d items", payload.ModelVersion, vendingState.SessionID, len(payload.Items))
+	}
+	vendingState.reviewInference(lc, payload)
+	return vendingState.crossCheckWeight(lc, payload), nil
+}
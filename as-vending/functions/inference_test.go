@@ -0,0 +1,116 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInferencePayload(t *testing.T) {
+	confidence := 0.97
+	tests := []struct {
+		Name            string
+		Value           string
+		ExpectedPayload InferencePayload
+		ExpectedError   string
+	}{
+		{
+			Name:            "Legacy payload",
+			Value:           `[{"SKU": "HXI86WHU", "delta": -2}]`,
+			ExpectedPayload: InferencePayload{Items: []InferenceItem{{SKU: "HXI86WHU", Delta: -2}}},
+		},
+		{
+			Name:            "Empty legacy payload",
+			Value:           ` []`,
+			ExpectedPayload: InferencePayload{Items: []InferenceItem{}},
+		},
+		{
+			Name:  "Schema version 1",
+			Value: `{"schemaVersion":1,"sessionID":"42","modelVersion":"product-detection-2.1","items":[{"sku":"HXI86WHU","delta":-2,"confidence":0.97}],"confidence":0.97}`,
+			ExpectedPayload: InferencePayload{
+				SchemaVersion: 1,
+				SessionID:     "42",
+				ModelVersion:  "product-detection-2.1",
+				Items:         []InferenceItem{{SKU: "HXI86WHU", Delta: -2, Confidence: &confidence}},
+				Confidence:    &confidence,
+			},
+		},
+		{
+			Name:            "Schema version 1 without items taken",
+			Value:           `{"schemaVersion":1,"modelVersion":"product-detection-2.1","items":[]}`,
+			ExpectedPayload: InferencePayload{SchemaVersion: 1, ModelVersion: "product-detection-2.1", Items: []InferenceItem{}},
+		},
+		{"Unknown schema version", `{"schemaVersion":2,"modelVersion":"product-detection-3.0","items":[]}`, InferencePayload{}, "unsupported inference schema version 2, expected 1"},
+		{"No schema version", `{"modelVersion":"product-detection-2.1","items":[]}`, InferencePayload{}, "inference payload has no schemaVersion"},
+		{"Unknown field", `{"schemaVersion":1,"modelVersion":"product-detection-2.1","items":[],"basket":[]}`, InferencePayload{}, `invalid inference payload of schema version 1: json: unknown field "basket"`},
+		{"No model version", `{"schemaVersion":1,"items":[]}`, InferencePayload{}, "inference payload has no modelVersion"},
+		{"No items", `{"schemaVersion":1,"modelVersion":"product-detection-2.1"}`, InferencePayload{}, "inference payload has no items"},
+		{"Item without a SKU", `{"schemaVersion":1,"modelVersion":"product-detection-2.1","items":[{"delta":-1}]}`, InferencePayload{}, "inference item 0 has no sku"},
+		{"Confidence out of range", `{"schemaVersion":1,"modelVersion":"product-detection-2.1","items":[],"confidence":97}`, InferencePayload{}, "inference confidence 97 must be between 0 and 1"},
+		{"Item confidence out of range", `{"schemaVersion":1,"modelVersion":"product-detection-2.1","items":[{"sku":"HXI86WHU","delta":-2,"confidence":-0.1}]}`, InferencePayload{}, "inference confidence -0.1 of sku HXI86WHU must be between 0 and 1"},
+		{"Invalid JSON", `{"schemaVersion":`, InferencePayload{}, "invalid inference payload: unexpected end of JSON input"},
+		{"Invalid legacy payload", `[{"SKU": "HXI86WHU", "delta": "-2"}]`, InferencePayload{}, "invalid legacy inference payload: json: cannot unmarshal string into Go struct field .0.delta of type int"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			payload, err := ParseInferencePayload(currentTest.Value)
+			if currentTest.ExpectedError != "" {
+				require.EqualError(t, err, currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedPayload, payload)
+		})
+	}
+
+	_, err := ParseInferencePayload(`{"schemaVersion":2}`)
+	assert.True(t, errors.Is(err, errUnsupportedSchemaVersion))
+}
+
+func TestReadInference(t *testing.T) {
+	vendingState := VendingState{
+		Workflow:        NewWorkflow(StateIdle),
+		CurrentUserData: OutputData{AccountID: 1, CardID: "0003293374", RoleID: 1},
+		SessionID:       "42",
+		Quarantine:      NewInferenceQuarantine(),
+	}
+	lc := logger.NewMockClient()
+
+	skuDelta, err := vendingState.readInference(lc, `{"schemaVersion":1,"sessionID":"42","modelVersion":"product-detection-2.1","items":[{"sku":"HXI86WHU","delta":-2}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}, skuDelta)
+	assert.Empty(t, vendingState.Quarantine.Payloads())
+
+	payload := `{"schemaVersion":1,"sessionID":"41","modelVersion":"product-detection-2.1","items":[{"sku":"HXI86WHU","delta":-2}]}`
+	_, err = vendingState.readInference(lc, payload)
+	require.EqualError(t, err, "inference payload is for session 41, not the current session 42")
+	quarantined := vendingState.Quarantine.Payloads()
+	require.Len(t, quarantined, 1)
+	assert.NotZero(t, quarantined[0].Timestamp)
+	quarantined[0].Timestamp = 0
+	assert.Equal(t, QuarantinedInference{Payload: payload, Reason: err.Error(), AccountID: 1, CardID: "0003293374", SessionID: "42"}, quarantined[0])
+}
+
+func TestInferenceQuarantine(t *testing.T) {
+	quarantine := NewInferenceQuarantine()
+	lc := logger.NewMockClient()
+	for i := 0; i < maxQuarantinedPayloads+5; i++ {
+		quarantine.Add(lc, fmt.Sprintf(`{"schemaVersion":%d}`, i), errUnsupportedSchemaVersion, OutputData{}, "")
+	}
+	payloads := quarantine.Payloads()
+	require.Len(t, payloads, maxQuarantinedPayloads)
+	assert.Equal(t, `{"schemaVersion":5}`, payloads[0].Payload, "the oldest payloads are dropped")
+
+	var nilQuarantine *InferenceQuarantine
+	nilQuarantine.Add(lc, "[]", errUnsupportedSchemaVersion, OutputData{}, "")
+	assert.Empty(t, nilQuarantine.Payloads())
+}
@@ -0,0 +1,94 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const defaultInferenceRetryInterval = 5 * time.Second
+
+// InferenceRetryPolicy is how the inference is triggered again when its
+// result does not arrive in time. The inferenceDoorStatus command is issued
+// to the inference device Attempts times, waiting Interval for the result of
+// the first retry and twice as long for each next one, and then once to the
+// SecondaryDeviceName when there is one. The zero InferenceRetryPolicy does
+// not retry.
+type InferenceRetryPolicy struct {
+	Attempts            int
+	Interval            time.Duration
+	SecondaryDeviceName string
+}
+
+// ParseInferenceRetryPolicy parses the inference retry settings of the
+// configuration
+func ParseInferenceRetryPolicy(configuration *config.VendingConfig) (InferenceRetryPolicy, error) {
+	policy := InferenceRetryPolicy{
+		Attempts:            configuration.InferenceRetryAttempts,
+		Interval:            defaultInferenceRetryInterval,
+		SecondaryDeviceName: configuration.InferenceSecondaryDeviceName,
+	}
+	if policy.Attempts < 0 {
+		return InferenceRetryPolicy{}, fmt.Errorf("inference retry attempts %d is negative", policy.Attempts)
+	}
+	if interval := configuration.InferenceRetryIntervalDuration; interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil || duration <= 0 {
+			return InferenceRetryPolicy{}, fmt.Errorf("inference retry interval %q must be a positive duration", interval)
+		}
+		policy.Interval = duration
+	}
+	return policy, nil
+}
+
+// attempt returns the device that the inference is triggered on by the
+// retry, counted from 1, and how long to wait for its result, or false once
+// the retries are used up
+func (policy InferenceRetryPolicy) attempt(retry int, deviceName string) (string, time.Duration, bool) {
+	if retry < 1 {
+		return "", 0, false
+	}
+	if retry > policy.Attempts {
+		if retry > policy.Attempts+1 || policy.SecondaryDeviceName == "" {
+			return "", 0, false
+		}
+		deviceName = policy.SecondaryDeviceName
+	}
+	wait := policy.Interval
+	for i := 1; i < retry; i++ {
+		wait *= 2
+	}
+	return deviceName, wait, true
+}
+
+// secondaryInferenceDeviceName returns the secondary inference device of the
+// door, empty without one
+func (vendingState *VendingState) secondaryInferenceDeviceName() string {
+	return vendingState.InferenceRetry.SecondaryDeviceName
+}
+
+// retryInference triggers the inference again for the next retry of the
+// vend, and returns how long to wait for its result, or false once the
+// retries are used up. A retry whose command fails is still waited for, as
+// the device may only be restarting.
+func (vendingState *VendingState) retryInference(lc logger.LoggingClient) (time.Duration, bool) {
+	deviceName, wait, ok := vendingState.InferenceRetry.attempt(vendingState.InferenceRetries+1, vendingState.inferenceDeviceName())
+	if !ok {
+		return 0, false
+	}
+	vendingState.InferenceRetries++
+	lc.Warnf("No inference result yet, triggering the inference on %s again (retry %d)", deviceName, vendingState.InferenceRetries)
+
+	settings := make(map[string]string)
+	settings["inferenceDoorStatus"] = "true"
+	if err := vendingState.SendCommand(lc, http.MethodPut, deviceName, vendingState.Configuration.InferenceDoorStatusCmd, settings); err != nil {
+		lc.Errorf("Failed to trigger the inference on %s: %s", deviceName, err.Error())
+	}
+	return wait, true
+}
@@ -0,0 +1,139 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInferenceRetryPolicy(t *testing.T) {
+	policy, err := ParseInferenceRetryPolicy(&config.VendingConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, InferenceRetryPolicy{Interval: defaultInferenceRetryInterval}, policy)
+
+	policy, err = ParseInferenceRetryPolicy(&config.VendingConfig{InferenceRetryAttempts: 2, InferenceRetryIntervalDuration: "3s", InferenceSecondaryDeviceName: "Inference-device-2"})
+	require.NoError(t, err)
+	assert.Equal(t, InferenceRetryPolicy{Attempts: 2, Interval: 3 * time.Second, SecondaryDeviceName: "Inference-device-2"}, policy)
+
+	tests := []struct {
+		Name          string
+		Configuration config.VendingConfig
+	}{
+		{"Negative attempts", config.VendingConfig{InferenceRetryAttempts: -1}},
+		{"Invalid interval", config.VendingConfig{InferenceRetryIntervalDuration: "soon"}},
+		{"Zero interval", config.VendingConfig{InferenceRetryIntervalDuration: "0s"}},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			_, err := ParseInferenceRetryPolicy(&currentTest.Configuration)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestInferenceRetryPolicyAttempt(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Policy         InferenceRetryPolicy
+		Retry          int
+		ExpectedDevice string
+		ExpectedWait   time.Duration
+		ExpectedOK     bool
+	}{
+		{"No retries", InferenceRetryPolicy{Interval: time.Second}, 1, "", 0, false},
+		{"First retry", InferenceRetryPolicy{Attempts: 2, Interval: time.Second}, 1, "Inference-device", time.Second, true},
+		{"Backoff doubles", InferenceRetryPolicy{Attempts: 2, Interval: time.Second}, 2, "Inference-device", 2 * time.Second, true},
+		{"Retries used up", InferenceRetryPolicy{Attempts: 2, Interval: time.Second}, 3, "", 0, false},
+		{"Secondary device", InferenceRetryPolicy{Attempts: 2, Interval: time.Second, SecondaryDeviceName: "Inference-device-2"}, 3, "Inference-device-2", 4 * time.Second, true},
+		{"Secondary device only", InferenceRetryPolicy{Interval: time.Second, SecondaryDeviceName: "Inference-device-2"}, 1, "Inference-device-2", time.Second, true},
+		{"Secondary device used up", InferenceRetryPolicy{Attempts: 2, Interval: time.Second, SecondaryDeviceName: "Inference-device-2"}, 4, "", 0, false},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			deviceName, wait, ok := currentTest.Policy.attempt(currentTest.Retry, "Inference-device")
+			assert.Equal(t, currentTest.ExpectedOK, ok)
+			assert.Equal(t, currentTest.ExpectedDevice, deviceName)
+			assert.Equal(t, currentTest.ExpectedWait, wait)
+		})
+	}
+}
+
+func newInferenceRetryVendingState(policy InferenceRetryPolicy) *VendingState {
+	vendingState := newMaintenanceVendingState(nil)
+	vendingState.Workflow = NewWorkflow(StateInferring)
+	vendingState.Configuration.InferenceDeviceName = "Inference-device"
+	vendingState.Configuration.InferenceDoorStatusCmd = "inferenceDoorStatus"
+	vendingState.Timeouts = NewStageTimeouts(StageDurations{Inference: 10 * time.Millisecond})
+	vendingState.InferenceRetry = policy
+	vendingState.ThreadStopChannel = make(chan int)
+	vendingState.InferenceWaitThreadStopChannel = make(chan int)
+	return vendingState
+}
+
+// waitForInference closes the door of the vend like the board status route,
+// which holds the session lock
+func waitForInference(vendingState *VendingState, lc logger.LoggingClient) {
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	vendingState.WaitForInference(lc)
+}
+
+func TestInferenceTimeoutRetries(t *testing.T) {
+	vendingState := newInferenceRetryVendingState(InferenceRetryPolicy{Attempts: 2, Interval: 10 * time.Millisecond, SecondaryDeviceName: "Inference-device-2"})
+	waitForInference(vendingState, logger.NewMockClient())
+
+	require.Eventually(t, func() bool {
+		return vendingState.Workflow.State() == StateMaintenance
+	}, 2*time.Second, 5*time.Millisecond)
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	assert.Equal(t, 3, vendingState.InferenceRetries)
+	assert.Contains(t, vendingState.MaintenanceReasons, ReasonInferenceTimeout)
+
+	mockCommandClient := vendingState.CommandClient.(*client_mocks.CommandClient)
+	settings := map[string]string{"inferenceDoorStatus": "true"}
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "Inference-device", "inferenceDoorStatus", settings)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "Inference-device-2", "inferenceDoorStatus", settings)
+}
+
+func TestInferenceRetryStopped(t *testing.T) {
+	vendingState := newInferenceRetryVendingState(InferenceRetryPolicy{Attempts: 1, Interval: time.Minute})
+	lc := logger.NewMockClient()
+	waitForInference(vendingState, lc)
+
+	require.Eventually(t, func() bool {
+		vendingState.LockSession()
+		defer vendingState.UnlockSession()
+		return vendingState.StageDeadline.After(vendingState.DoorClosedAt.Add(time.Second))
+	}, time.Second, 5*time.Millisecond, "the retry waits for its own result")
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	close(vendingState.InferenceWaitThreadStopChannel)
+	assert.Equal(t, 1, vendingState.InferenceRetries)
+	assert.Equal(t, StateInferring, vendingState.Workflow.State())
+	assert.False(t, vendingState.MaintenanceMode)
+}
+
+func TestInferenceTimeoutWithoutRetries(t *testing.T) {
+	vendingState := newInferenceRetryVendingState(InferenceRetryPolicy{Interval: time.Second})
+	waitForInference(vendingState, logger.NewMockClient())
+
+	require.Eventually(t, func() bool {
+		return vendingState.Workflow.State() == StateMaintenance
+	}, time.Second, 5*time.Millisecond)
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	assert.Zero(t, vendingState.InferenceRetries)
+	vendingState.CommandClient.(*client_mocks.CommandClient).AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, "Inference-device", "inferenceDoorStatus", mock.Anything)
+}
@@ -0,0 +1,215 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// SessionJournalEntry is the vend in progress as it is kept in the session
+// journal: the workflow state, the account of the vend, and when its
+// current stage times out
+type SessionJournalEntry struct {
+	State            WorkflowState `json:"state"`
+	SessionID        string        `json:"sessionId"`
+	CurrentUserData  OutputData    `json:"currentUserData"`
+	SplitPayers      []OutputData  `json:"splitPayers,omitempty"`
+	SessionBasket    []deltaSKU    `json:"sessionBasket,omitempty"`
+	SessionLingering bool          `json:"sessionLingering,omitempty"`
+	DoorClosed       bool          `json:"doorClosed"`
+	DoorClosedAt     int64         `json:"doorClosedAt,string,omitempty"`
+	StageDeadline    int64         `json:"stageDeadline,string,omitempty"`
+	UpdatedAt        int64         `json:"updatedAt,string"`
+}
+
+// SessionJournal keeps the vend in progress in a JSON file, which is
+// removed once the vend has ended. A nil SessionJournal keeps nothing.
+type SessionJournal struct {
+	mutex    sync.Mutex
+	fileName string
+	// saved is whether the file holds a vend
+	saved bool
+}
+
+// NewSessionJournal creates the session journal kept in the file, and
+// checks that its directory can be written to. It returns nil when the file
+// is empty.
+func NewSessionJournal(fileName string) (*SessionJournal, error) {
+	if fileName == "" {
+		return nil, nil
+	}
+	directory := filepath.Dir(fileName)
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the session journal directory %s: %s", directory, err.Error())
+	}
+	probe, err := os.CreateTemp(directory, ".write-check-*")
+	if err != nil {
+		return nil, fmt.Errorf("the session journal directory %s is not writable: %s", directory, err.Error())
+	}
+	_ = probe.Close()
+	if err := os.Remove(probe.Name()); err != nil {
+		return nil, err
+	}
+	return &SessionJournal{fileName: fileName}, nil
+}
+
+// Load returns the vend that was kept in the journal, or nil when there is
+// none
+func (journal *SessionJournal) Load() (*SessionJournalEntry, error) {
+	if journal == nil {
+		return nil, nil
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	entryJSON, err := os.ReadFile(journal.fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the session journal %s: %s", journal.fileName, err.Error())
+	}
+	journal.saved = true
+	var entry SessionJournalEntry
+	if err := json.Unmarshal(entryJSON, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse the session journal %s: %s", journal.fileName, err.Error())
+	}
+	return &entry, nil
+}
+
+// Save replaces the vend kept in the journal
+func (journal *SessionJournal) Save(entry SessionJournalEntry) error {
+	if journal == nil {
+		return nil
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	entryJSON, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the entry is written to a temporary file that replaces the journal,
+	// so that a crash never leaves a partial journal behind
+	tempName := filepath.Join(filepath.Dir(journal.fileName), "."+filepath.Base(journal.fileName)+".tmp")
+	if err := os.WriteFile(tempName, entryJSON, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempName, journal.fileName); err != nil {
+		return err
+	}
+	journal.saved = true
+	return nil
+}
+
+// Clear removes the vend kept in the journal once it has ended
+func (journal *SessionJournal) Clear() error {
+	if journal == nil {
+		return nil
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if !journal.saved {
+		return nil
+	}
+	if err := os.Remove(journal.fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	journal.saved = false
+	return nil
+}
+
+// journalSession keeps the vend in progress or lingering session in the
+// session journal, and clears it between vends. A failure is only logged,
+// the vend goes on but is not recovered after a restart.
+func (vendingState *VendingState) journalSession(lc logger.LoggingClient) {
+	if vendingState.Journal == nil {
+		return
+	}
+	state := vendingState.Workflow.State()
+	var err error
+	if state.vending() || vendingState.SessionLingering {
+		err = vendingState.Journal.Save(SessionJournalEntry{
+			State:            state,
+			SessionID:        vendingState.SessionID,
+			CurrentUserData:  vendingState.CurrentUserData,
+			SplitPayers:      vendingState.SplitPayers,
+			SessionBasket:    vendingState.SessionBasket,
+			SessionLingering: vendingState.SessionLingering,
+			DoorClosed:       vendingState.DoorClosed,
+			DoorClosedAt:     unixNano(vendingState.DoorClosedAt),
+			StageDeadline:    unixNano(vendingState.StageDeadline),
+			UpdatedAt:        time.Now().UnixNano(),
+		})
+	} else {
+		err = vendingState.Journal.Clear()
+	}
+	if err != nil {
+		lc.Errorf("Failed to write the session journal: %s", err.Error())
+	}
+}
+
+// RecoverSession restores the vend that was in progress when the service
+// stopped. A vend whose stage had not timed out yet is resumed, and waits
+// only for the time its stage had left. Any other vend is ended, and the
+// vending machine is taken out of service, since it cannot tell what the
+// customer took while the service was down. A basket that was recorded
+// before it was charged is still charged by the ledger service.
+func (vendingState *VendingState) RecoverSession(lc logger.LoggingClient, entry *SessionJournalEntry, now time.Time) {
+	if entry == nil {
+		return
+	}
+	deadline := time.Unix(0, entry.StageDeadline)
+	vendingState.DoorClosed = entry.DoorClosed
+	resumable := entry.StageDeadline != 0 && deadline.After(now)
+
+	switch {
+	case resumable && entry.SessionLingering:
+		vendingState.restoreSession(entry)
+		vendingState.SessionLingering = true
+		lc.Infof("Resumed the lingering session %s of card %s, waiting %v for it to reopen the door", entry.SessionID, entry.CurrentUserData.CardID, deadline.Sub(now))
+		vendingState.awaitSessionEnd(lc, deadline)
+	case resumable && (entry.State == StateAuthorized || entry.State == StateDoorOpen || entry.State == StateInferring):
+		vendingState.restoreSession(entry)
+		vendingState.Workflow = NewWorkflow(entry.State)
+		vendingState.Metrics.SetActiveSessions(1)
+		lc.Infof("Resumed the vend %s of card %s in the %s state, waiting %v", entry.SessionID, entry.CurrentUserData.CardID, entry.State, deadline.Sub(now))
+		switch entry.State {
+		case StateAuthorized:
+			vendingState.awaitDoorOpen(lc, deadline)
+		case StateDoorOpen:
+			vendingState.awaitDoorClose(lc, deadline)
+		case StateInferring:
+			vendingState.DoorClosedAt = time.Unix(0, entry.DoorClosedAt)
+			vendingState.awaitInference(lc, deadline)
+		}
+	default:
+		lc.Errorf("The vend %s of card %s was interrupted in the %s state and cannot be resumed", entry.SessionID, entry.CurrentUserData.CardID, entry.State)
+		vendingState.SetMaintenanceReason(lc, ReasonSessionInterrupted)
+		// the journal is cleared when the workflow was already in maintenance
+		vendingState.journalSession(lc)
+	}
+}
+
+// restoreSession restores the account and basket of the vend in the entry
+func (vendingState *VendingState) restoreSession(entry *SessionJournalEntry) {
+	vendingState.SessionID = entry.SessionID
+	vendingState.CurrentUserData = entry.CurrentUserData
+	vendingState.SplitPayers = entry.SplitPayers
+	vendingState.SessionBasket = entry.SessionBasket
+}
+
+// unixNano returns the time in nanoseconds, or zero for the zero time
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
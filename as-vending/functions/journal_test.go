@@ -0,0 +1,155 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionJournal(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "journal", "session.json")
+	journal, err := NewSessionJournal(fileName)
+	require.NoError(t, err)
+
+	entry, err := journal.Load()
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	saved := SessionJournalEntry{
+		State:           StateInferring,
+		SessionID:       "session-1",
+		CurrentUserData: OutputData{AccountID: 1, PersonID: 1, RoleID: 1, CardID: "0003293374"},
+		SessionBasket:   []deltaSKU{{SKU: "4900002470", Delta: -1}},
+		DoorClosed:      true,
+		DoorClosedAt:    1678860010000000000,
+		StageDeadline:   1678860030000000000,
+		UpdatedAt:       1678860010000000000,
+	}
+	require.NoError(t, journal.Save(saved))
+
+	// the journal is read back as a restarted service finds it
+	restarted, err := NewSessionJournal(fileName)
+	require.NoError(t, err)
+	entry, err = restarted.Load()
+	require.NoError(t, err)
+	assert.Equal(t, &saved, entry)
+
+	require.NoError(t, restarted.Clear())
+	_, err = os.Stat(fileName)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSessionJournalNil(t *testing.T) {
+	journal, err := NewSessionJournal("")
+	require.NoError(t, err)
+	assert.Nil(t, journal)
+
+	entry, err := journal.Load()
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+	assert.NoError(t, journal.Save(SessionJournalEntry{State: StateDoorOpen}))
+	assert.NoError(t, journal.Clear())
+}
+
+func TestJournalSession(t *testing.T) {
+	journal, err := NewSessionJournal(filepath.Join(t.TempDir(), "session.json"))
+	require.NoError(t, err)
+	vendingState := VendingState{
+		Workflow:        NewWorkflow(StateIdle),
+		CurrentUserData: OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374", Token: "card-token"},
+		SessionID:       "session-1",
+		Journal:         journal,
+	}
+	lc := logger.NewMockClient()
+
+	vendingState.Transition(lc, StateAuthorized, "cardAuthorized")
+	entry, err := journal.Load()
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, StateAuthorized, entry.State)
+	assert.Equal(t, "session-1", entry.SessionID)
+	// the card's token is a credential, so it is not journaled
+	assert.Equal(t, OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374"}, entry.CurrentUserData)
+
+	// the journal is cleared once the vend has ended
+	vendingState.Transition(lc, StateIdle, "doorOpenTimeout")
+	entry, err = journal.Load()
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestRecoverSession(t *testing.T) {
+	now := time.Now()
+	user := OutputData{AccountID: 1, PersonID: 1, RoleID: 1, CardID: "0003293374"}
+	tests := []struct {
+		Name                string
+		Entry               SessionJournalEntry
+		ExpectedState       WorkflowState
+		ExpectedLingering   bool
+		ExpectedMaintenance bool
+	}{
+		{"Door open resumed", SessionJournalEntry{State: StateDoorOpen, SessionID: "session-1", CurrentUserData: user, StageDeadline: now.Add(time.Minute).UnixNano()}, StateDoorOpen, false, false},
+		{"Inferring resumed", SessionJournalEntry{State: StateInferring, SessionID: "session-1", CurrentUserData: user, DoorClosed: true, DoorClosedAt: now.Add(-time.Second).UnixNano(), StageDeadline: now.Add(time.Minute).UnixNano()}, StateInferring, false, false},
+		{"Lingering resumed", SessionJournalEntry{State: StateIdle, SessionID: "session-1", CurrentUserData: user, SessionBasket: []deltaSKU{{SKU: "4900002470", Delta: -1}}, SessionLingering: true, DoorClosed: true, StageDeadline: now.Add(time.Minute).UnixNano()}, StateIdle, true, false},
+		{"Timed out", SessionJournalEntry{State: StateInferring, SessionID: "session-1", CurrentUserData: user, DoorClosed: true, StageDeadline: now.Add(-time.Second).UnixNano()}, StateMaintenance, false, true},
+		{"Settling", SessionJournalEntry{State: StateSettling, SessionID: "session-1", CurrentUserData: user, DoorClosed: true}, StateMaintenance, false, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockCommandClient := &client_mocks.CommandClient{}
+			mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+			journal, err := NewSessionJournal(filepath.Join(t.TempDir(), "session.json"))
+			require.NoError(t, err)
+			require.NoError(t, journal.Save(currentTest.Entry))
+			vendingState := VendingState{
+				Workflow:                       NewWorkflow(StateIdle),
+				Configuration:                  new(config.VendingConfig),
+				CommandClient:                  mockCommandClient,
+				Journal:                        journal,
+				ThreadStopChannel:              make(chan int),
+				DoorOpenWaitThreadStopChannel:  make(chan int),
+				DoorCloseWaitThreadStopChannel: make(chan int),
+				InferenceWaitThreadStopChannel: make(chan int),
+			}
+			defer close(vendingState.ThreadStopChannel)
+			defer vendingState.stopLingering()
+			entry, err := journal.Load()
+			require.NoError(t, err)
+
+			vendingState.RecoverSession(logger.NewMockClient(), entry, now)
+			assert.Equal(t, currentTest.ExpectedState, vendingState.Workflow.State())
+			assert.Equal(t, currentTest.ExpectedLingering, vendingState.SessionLingering)
+			assert.Equal(t, currentTest.ExpectedMaintenance, vendingState.MaintenanceMode)
+			assert.Equal(t, currentTest.Entry.DoorClosed, vendingState.DoorClosed)
+
+			entry, err = journal.Load()
+			require.NoError(t, err)
+			if currentTest.ExpectedMaintenance {
+				assert.Equal(t, []MaintenanceReason{ReasonSessionInterrupted}, vendingState.MaintenanceReasons)
+				assert.Equal(t, OutputData{}, vendingState.CurrentUserData)
+				assert.Nil(t, entry, "the interrupted vend is cleared from the journal")
+				return
+			}
+			// the resumed vend waits only for the time its stage had left
+			assert.Equal(t, time.Unix(0, currentTest.Entry.StageDeadline), vendingState.StageDeadline)
+			assert.Equal(t, user, vendingState.CurrentUserData)
+			assert.Equal(t, "session-1", vendingState.SessionID)
+			require.NotNil(t, entry)
+			assert.Equal(t, currentTest.Entry.StageDeadline, entry.StageDeadline)
+		})
+	}
+}
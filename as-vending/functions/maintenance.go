@@ -0,0 +1,253 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// MaintenanceReason is the reason code of a condition that takes the vending
+// machine out of service.
+type MaintenanceReason string
+
+const (
+	// ReasonTemperatureFault is set while the cooler temperature is outside
+	// of its thresholds
+	ReasonTemperatureFault MaintenanceReason = "temperatureFault"
+	// ReasonDoorLeftOpen is set when the door was not closed during a vend
+	ReasonDoorLeftOpen MaintenanceReason = "doorLeftOpen"
+	// ReasonInferenceTimeout is set when no inference result was received
+	// after the door was closed
+	ReasonInferenceTimeout MaintenanceReason = "inferenceTimeout"
+	// ReasonInferenceUnavailable is set while the inference service does not
+	// respond to its heartbeat
+	ReasonInferenceUnavailable MaintenanceReason = "inferenceUnavailable"
+	// ReasonCardReaderOffline is set while a card reader has not sent a
+	// heartbeat within its timeout
+	ReasonCardReaderOffline MaintenanceReason = "cardReaderOffline"
+	// ReasonBillingUnavailable is set when too many transactions in a row
+	// failed to post to the ledger service, until billing is resumed
+	ReasonBillingUnavailable MaintenanceReason = "billingUnavailable"
+	// ReasonStoreClosed is set while the store is closed remotely, such as
+	// for a building evacuation or a holiday closure, until it is opened
+	ReasonStoreClosed MaintenanceReason = "storeClosed"
+	// ReasonDoorSensorFault is set while the controller board status
+	// service reports the door sensor as suspect, since a vend cannot rely
+	// on its door events
+	ReasonDoorSensorFault MaintenanceReason = "doorSensorFault"
+	// ReasonSessionInterrupted is set when the service restarted during a
+	// vend that could not be resumed, so what the customer took is unknown
+	ReasonSessionInterrupted MaintenanceReason = "sessionInterrupted"
+	// ReasonWarmup is set while the controller board status service holds
+	// vending after power-on, until the cooler has cooled down
+	ReasonWarmup MaintenanceReason = "warmup"
+	// ReasonManual is set by an operator through the REST API, such as while
+	// the vending machine is being cleaned, until it is cleared the same way
+	ReasonManual MaintenanceReason = "manual"
+)
+
+var (
+	// ErrUnknownMaintenanceReason is returned for a reason code that is not
+	// one of the maintenance reasons
+	ErrUnknownMaintenanceReason = errors.New("unknown maintenance reason")
+	// ErrManagedMaintenanceReason is returned for a reason that has its own
+	// endpoint, which keeps the state behind the reason
+	ErrManagedMaintenanceReason = errors.New("maintenance reason is managed by its own endpoint")
+)
+
+// MaintenanceModeRequest is a request to enter maintenance mode for the
+// reason, or to clear the reason. Clearing without a reason clears every
+// reason, as servicing the vending machine does. Entering without a reason
+// uses ReasonManual.
+type MaintenanceModeRequest struct {
+	MaintenanceMode bool              `json:"maintenanceMode"`
+	Reason          MaintenanceReason `json:"reason,omitempty"`
+}
+
+// maintenanceMessages are the LCD messages displayed for each reason
+var maintenanceMessages = map[MaintenanceReason]string{
+	ReasonTemperatureFault:     "Temperature fault",
+	ReasonDoorLeftOpen:         "Door left open",
+	ReasonInferenceTimeout:     "Vend not verified",
+	ReasonInferenceUnavailable: "Camera offline",
+	ReasonCardReaderOffline:    "Card reader offline",
+	ReasonBillingUnavailable:   "Billing unavailable",
+	ReasonStoreClosed:          "Store closed",
+	ReasonDoorSensorFault:      "Door sensor fault",
+	ReasonSessionInterrupted:   "Vend interrupted",
+	ReasonWarmup:               "Cooling down",
+	ReasonManual:               "Under maintenance",
+}
+
+// SetMaintenanceReason puts the vending machine in maintenance mode for the
+// reason and displays the reason on the LCD.
+func (vendingState *VendingState) SetMaintenanceReason(lc logger.LoggingClient, reason MaintenanceReason) {
+	vendingState.MaintenanceMode = true
+	vendingState.syncMaintenanceState(lc, string(reason))
+	if vendingState.hasMaintenanceReason(reason) {
+		return
+	}
+	lc.Warnf("entering maintenance mode: %s", reason)
+	vendingState.MaintenanceReasons = append(vendingState.MaintenanceReasons, reason)
+	vendingState.maintenanceChanged(lc, MaintenanceEntered, reason)
+	vendingState.displayMaintenance(lc)
+}
+
+// ClearMaintenanceReason removes the reason once its condition has cleared.
+// Maintenance mode ends when no reasons are left, otherwise the LCD is
+// updated with the remaining reason.
+func (vendingState *VendingState) ClearMaintenanceReason(lc logger.LoggingClient, reason MaintenanceReason) {
+	if !vendingState.hasMaintenanceReason(reason) {
+		return
+	}
+	lc.Infof("maintenance condition cleared: %s", reason)
+	reasons := []MaintenanceReason{}
+	for _, currentReason := range vendingState.MaintenanceReasons {
+		if currentReason != reason {
+			reasons = append(reasons, currentReason)
+		}
+	}
+	vendingState.MaintenanceReasons = reasons
+	if len(reasons) == 0 {
+		vendingState.MaintenanceMode = false
+		vendingState.syncMaintenanceState(lc, string(reason))
+	}
+	vendingState.maintenanceChanged(lc, MaintenanceCleared, reason)
+	vendingState.displayMaintenance(lc)
+}
+
+// ClearMaintenance clears maintenance mode and all of its reasons, which
+// happens when the vending machine has been serviced, and takes the reason
+// off the LCD. Servicing the machine does not fix billing, so suspended
+// billing stays a reason until it is resumed, nor does it open a closed
+// store or cool down a cooler that is warming up.
+func (vendingState *VendingState) ClearMaintenance(lc logger.LoggingClient) {
+	wasMaintenanceMode := vendingState.MaintenanceMode
+	storeClosed := vendingState.hasMaintenanceReason(ReasonStoreClosed)
+	warmingUp := vendingState.hasMaintenanceReason(ReasonWarmup)
+	vendingState.MaintenanceMode = false
+	vendingState.MaintenanceReasons = nil
+	if vendingState.Billing.Suspended() {
+		vendingState.MaintenanceMode = true
+		vendingState.MaintenanceReasons = append(vendingState.MaintenanceReasons, ReasonBillingUnavailable)
+	}
+	if storeClosed {
+		vendingState.MaintenanceMode = true
+		vendingState.MaintenanceReasons = append(vendingState.MaintenanceReasons, ReasonStoreClosed)
+	}
+	if warmingUp {
+		vendingState.MaintenanceMode = true
+		vendingState.MaintenanceReasons = append(vendingState.MaintenanceReasons, ReasonWarmup)
+	}
+	vendingState.syncMaintenanceState(lc, "maintenanceCleared")
+	if wasMaintenanceMode {
+		vendingState.maintenanceChanged(lc, MaintenanceCleared, "")
+		vendingState.displayMaintenance(lc)
+	}
+}
+
+// SetMaintenanceMode enters maintenance mode for the reason of the request,
+// or clears it, as requested by an operator. Billing suspensions, store
+// closures and warmups are rejected, since they are resumed with
+// /resumeBilling, opened with /storeState and overridden at the controller
+// board status service.
+func (vendingState *VendingState) SetMaintenanceMode(lc logger.LoggingClient, request MaintenanceModeRequest) (MaintenanceMode, error) {
+	reason := request.Reason
+	if reason == "" && request.MaintenanceMode {
+		reason = ReasonManual
+	}
+	if reason != "" {
+		if _, ok := maintenanceMessages[reason]; !ok {
+			return MaintenanceMode{}, fmt.Errorf("%w: %s", ErrUnknownMaintenanceReason, reason)
+		}
+		if reason == ReasonBillingUnavailable || reason == ReasonStoreClosed || reason == ReasonWarmup {
+			return MaintenanceMode{}, fmt.Errorf("%w: %s", ErrManagedMaintenanceReason, reason)
+		}
+	}
+
+	switch {
+	case request.MaintenanceMode:
+		vendingState.SetMaintenanceReason(lc, reason)
+	case reason == "":
+		vendingState.ClearMaintenance(lc)
+	default:
+		vendingState.ClearMaintenanceReason(lc, reason)
+	}
+	return MaintenanceMode{MaintenanceMode: vendingState.MaintenanceMode, Reasons: vendingState.MaintenanceReasons}, nil
+}
+
+func (vendingState *VendingState) hasMaintenanceReason(reason MaintenanceReason) bool {
+	for _, currentReason := range vendingState.MaintenanceReasons {
+		if currentReason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceMessage returns the LCD message of the most recent reason, or a
+// generic message when maintenance mode was set without a reason
+func (vendingState *VendingState) maintenanceMessage() string {
+	if len(vendingState.MaintenanceReasons) == 0 {
+		return "Out of Order"
+	}
+	reason := vendingState.MaintenanceReasons[len(vendingState.MaintenanceReasons)-1]
+	if message, ok := maintenanceMessages[reason]; ok {
+		return message
+	}
+	return string(reason)
+}
+
+// displayMaintenance shows why the vending machine is out of service on the
+// LCD, or resets the LCD when it is back in service
+func (vendingState *VendingState) displayMaintenance(lc logger.LoggingClient) {
+	deviceName := vendingState.Configuration.ControllerBoardDeviceName
+	if !vendingState.MaintenanceMode {
+		settings := make(map[string]string)
+		settings["displayReset"] = ""
+		if err := vendingState.SendCommand(lc, http.MethodPut, deviceName, vendingState.Configuration.ControllerBoardDisplayResetCmd, settings); err != nil {
+			lc.Errorf("failed to reset the display: %s", err.Error())
+		}
+		return
+	}
+
+	// a cooler that is warming up does not need service, it is back in
+	// service once it has cooled down
+	if reasons := vendingState.MaintenanceReasons; len(reasons) > 0 && reasons[len(reasons)-1] == ReasonWarmup {
+		if err := vendingState.displayRows(lc, "Please wait", vendingState.maintenanceMessage(), "Back shortly"); err != nil {
+			lc.Errorf("failed to display the maintenance reason: %s", err.Error())
+		}
+		return
+	}
+
+	if err := vendingState.displayRows(lc, "Out of service", vendingState.maintenanceMessage(), "Call for service"); err != nil {
+		lc.Errorf("failed to display the maintenance reason: %s", err.Error())
+	}
+}
+
+// displayRows shows the three rows of text on the LCD
+func (vendingState *VendingState) displayRows(lc logger.LoggingClient, row1 string, row2 string, row3 string) error {
+	deviceName := vendingState.Configuration.ControllerBoardDeviceName
+	rows := []struct {
+		command string
+		setting string
+		value   string
+	}{
+		{vendingState.Configuration.ControllerBoardDisplayRow1Cmd, "displayRow1", row1},
+		{vendingState.Configuration.ControllerBoardDisplayRow2Cmd, "displayRow2", row2},
+		{vendingState.Configuration.ControllerBoardDisplayRow3Cmd, "displayRow3", row3},
+	}
+	for _, row := range rows {
+		settings := make(map[string]string)
+		settings[row.setting] = row.value
+		if err := vendingState.SendCommand(lc, http.MethodPut, deviceName, row.command, settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"testing"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMaintenanceReasons(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName:      "controller-board",
+			ControllerBoardDisplayResetCmd: "displayReset",
+			ControllerBoardDisplayRow1Cmd:  "displayRow1",
+			ControllerBoardDisplayRow2Cmd:  "displayRow2",
+			ControllerBoardDisplayRow3Cmd:  "displayRow3",
+		},
+		CommandClient: mockCommandClient,
+	}
+	lc := logger.NewMockClient()
+
+	vendingState.SetMaintenanceReason(lc, ReasonTemperatureFault)
+	vendingState.SetMaintenanceReason(lc, ReasonInferenceUnavailable)
+	vendingState.SetMaintenanceReason(lc, ReasonTemperatureFault)
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonTemperatureFault, ReasonInferenceUnavailable}, vendingState.MaintenanceReasons)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow2", map[string]string{"displayRow2": "Camera offline"})
+
+	// the remaining reason is displayed once the other condition clears
+	vendingState.ClearMaintenanceReason(lc, ReasonInferenceUnavailable)
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonTemperatureFault}, vendingState.MaintenanceReasons)
+	assert.Equal(t, "Temperature fault", vendingState.maintenanceMessage())
+
+	// clearing a reason that is not set has no effect
+	vendingState.ClearMaintenanceReason(lc, ReasonDoorLeftOpen)
+	assert.True(t, vendingState.MaintenanceMode)
+
+	vendingState.ClearMaintenanceReason(lc, ReasonTemperatureFault)
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.Empty(t, vendingState.MaintenanceReasons)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayReset", map[string]string{"displayReset": ""})
+
+	vendingState.SetMaintenanceReason(lc, ReasonDoorLeftOpen)
+	vendingState.ClearMaintenance(lc)
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.Nil(t, vendingState.MaintenanceReasons)
+}
+
+func TestMaintenanceMessage(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Reasons  []MaintenanceReason
+		Expected string
+	}{
+		{"No reason", nil, "Out of Order"},
+		{"Known reason", []MaintenanceReason{ReasonDoorLeftOpen}, "Door left open"},
+		{"Most recent reason", []MaintenanceReason{ReasonDoorLeftOpen, ReasonInferenceTimeout}, "Vend not verified"},
+		{"Unknown reason", []MaintenanceReason{"lockFault"}, "lockFault"},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			vendingState := VendingState{Workflow: NewWorkflow(StateIdle), MaintenanceMode: true, MaintenanceReasons: currentTest.Reasons}
+			assert.Equal(t, currentTest.Expected, vendingState.maintenanceMessage())
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// MaintenanceEntered is the event of a maintenance reason being set
+	MaintenanceEntered = "entered"
+	// MaintenanceCleared is the event of a maintenance reason being cleared
+	MaintenanceCleared = "cleared"
+)
+
+// MaintenanceEvent is a change of the maintenance reasons of a door, which is
+// published when a publish function is set. Reason is empty when every
+// reason was cleared at once, such as when the vending machine was serviced.
+type MaintenanceEvent struct {
+	KioskID         string              `json:"kioskId,omitempty"`
+	Door            string              `json:"door"`
+	Event           string              `json:"event"`
+	Reason          MaintenanceReason   `json:"reason,omitempty"`
+	MaintenanceMode bool                `json:"maintenanceMode"`
+	Reasons         []MaintenanceReason `json:"reasons,omitempty"`
+	Timestamp       int64               `json:"timestamp,string"`
+}
+
+// MaintenanceStore keeps the maintenance reasons of each door, by its
+// controller board device name, in a JSON file so that they survive a
+// restart of the service, and publishes their changes. A nil
+// MaintenanceStore neither keeps nor publishes anything.
+type MaintenanceStore struct {
+	mutex    sync.Mutex
+	fileName string
+	reasons  map[string][]MaintenanceReason
+	publish  func(MaintenanceEvent) error
+}
+
+// NewMaintenanceStore creates the maintenance store kept in the file, and
+// loads the reasons kept in it. The reasons are only kept in memory when the
+// file is empty.
+func NewMaintenanceStore(fileName string, publish func(MaintenanceEvent) error) (*MaintenanceStore, error) {
+	store := &MaintenanceStore{fileName: fileName, reasons: map[string][]MaintenanceReason{}, publish: publish}
+	if fileName == "" {
+		return store, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the maintenance state directory: %s", err.Error())
+	}
+	reasonsJSON, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the maintenance state %s: %s", fileName, err.Error())
+	}
+	if err := json.Unmarshal(reasonsJSON, &store.reasons); err != nil {
+		return nil, fmt.Errorf("failed to parse the maintenance state %s: %s", fileName, err.Error())
+	}
+	return store, nil
+}
+
+// Reasons returns the maintenance reasons kept for the door
+func (store *MaintenanceStore) Reasons(door string) []MaintenanceReason {
+	if store == nil {
+		return nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return append([]MaintenanceReason{}, store.reasons[door]...)
+}
+
+// record keeps the reasons of the door of the event, and publishes the
+// event. Failures are only logged, as the door is out of service either way.
+func (store *MaintenanceStore) record(lc logger.LoggingClient, event MaintenanceEvent) {
+	if store == nil {
+		return
+	}
+	store.mutex.Lock()
+	if len(event.Reasons) == 0 {
+		delete(store.reasons, event.Door)
+	} else {
+		store.reasons[event.Door] = append([]MaintenanceReason{}, event.Reasons...)
+	}
+	err := store.save()
+	store.mutex.Unlock()
+	if err != nil {
+		lc.Errorf("failed to keep the maintenance reasons: %s", err.Error())
+	}
+
+	if store.publish != nil {
+		if err := store.publish(event); err != nil {
+			lc.Errorf("failed to publish the maintenance event: %s", err.Error())
+		}
+	}
+}
+
+// save writes the reasons to a temporary file that replaces the file, so
+// that a crash never leaves a partial file behind
+func (store *MaintenanceStore) save() error {
+	if store.fileName == "" {
+		return nil
+	}
+	reasonsJSON, err := json.MarshalIndent(store.reasons, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempName := filepath.Join(filepath.Dir(store.fileName), "."+filepath.Base(store.fileName)+".tmp")
+	if err := os.WriteFile(tempName, reasonsJSON, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempName, store.fileName)
+}
+
+// maintenanceChanged records the change of the maintenance reasons of the
+// door in the maintenance store, and posts a reason being set to the
+// webhooks
+func (vendingState *VendingState) maintenanceChanged(lc logger.LoggingClient, event string, reason MaintenanceReason) {
+	if event == MaintenanceEntered {
+		vendingState.notifyWebhooks(lc, WebhookMaintenanceEntered, event, reason)
+	}
+	if vendingState.Maintenance == nil || vendingState.Configuration == nil {
+		return
+	}
+	vendingState.Maintenance.record(lc, MaintenanceEvent{
+		KioskID:         vendingState.Configuration.KioskID,
+		Door:            vendingState.Configuration.ControllerBoardDeviceName,
+		Event:           event,
+		Reason:          reason,
+		MaintenanceMode: vendingState.MaintenanceMode,
+		Reasons:         append([]MaintenanceReason{}, vendingState.MaintenanceReasons...),
+		Timestamp:       time.Now().UnixNano(),
+	})
+}
+
+// RestoreMaintenance puts the door back in maintenance mode for the reasons
+// that were kept for it before the service restarted. The reasons are
+// cleared as usual once their conditions are seen to have cleared.
+func (vendingState *VendingState) RestoreMaintenance(lc logger.LoggingClient) {
+	if vendingState.Configuration == nil {
+		return
+	}
+	restored := false
+	for _, reason := range vendingState.Maintenance.Reasons(vendingState.Configuration.ControllerBoardDeviceName) {
+		if vendingState.hasMaintenanceReason(reason) {
+			continue
+		}
+		lc.Warnf("maintenance mode restored: %s", reason)
+		vendingState.MaintenanceReasons = append(vendingState.MaintenanceReasons, reason)
+		restored = true
+	}
+	if !restored {
+		return
+	}
+	vendingState.MaintenanceMode = true
+	vendingState.syncMaintenanceState(lc, "maintenanceRestored")
+	vendingState.displayMaintenance(lc)
+}
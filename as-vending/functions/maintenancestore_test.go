@@ -0,0 +1,126 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newMaintenanceVendingState(store *MaintenanceStore) *VendingState {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	return &VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			KioskID:                        "kiosk-1",
+			ControllerBoardDeviceName:      "controller-board",
+			ControllerBoardDisplayResetCmd: "displayReset",
+			ControllerBoardDisplayRow1Cmd:  "displayRow1",
+			ControllerBoardDisplayRow2Cmd:  "displayRow2",
+			ControllerBoardDisplayRow3Cmd:  "displayRow3",
+		},
+		CommandClient: mockCommandClient,
+		Maintenance:   store,
+	}
+}
+
+func TestMaintenanceStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "maintenance", "state.json")
+	var events []MaintenanceEvent
+	store, err := NewMaintenanceStore(fileName, func(event MaintenanceEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err)
+	lc := logger.NewMockClient()
+
+	vendingState := newMaintenanceVendingState(store)
+	vendingState.SetMaintenanceReason(lc, ReasonTemperatureFault)
+	vendingState.SetMaintenanceReason(lc, ReasonManual)
+	vendingState.SetMaintenanceReason(lc, ReasonManual)
+	vendingState.ClearMaintenanceReason(lc, ReasonTemperatureFault)
+	require.Len(t, events, 3, "a reason that is already set is not published again")
+	assert.Equal(t, MaintenanceEvent{
+		KioskID:         "kiosk-1",
+		Door:            "controller-board",
+		Event:           MaintenanceEntered,
+		Reason:          ReasonTemperatureFault,
+		MaintenanceMode: true,
+		Reasons:         []MaintenanceReason{ReasonTemperatureFault},
+		Timestamp:       events[0].Timestamp,
+	}, events[0])
+	assert.Equal(t, MaintenanceCleared, events[2].Event)
+	assert.Equal(t, []MaintenanceReason{ReasonManual}, events[2].Reasons)
+
+	// the reasons survive a restart of the service
+	restarted, err := NewMaintenanceStore(fileName, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []MaintenanceReason{ReasonManual}, restarted.Reasons("controller-board"))
+	restartedState := newMaintenanceVendingState(restarted)
+	restartedState.RestoreMaintenance(lc)
+	assert.True(t, restartedState.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonManual}, restartedState.MaintenanceReasons)
+	assert.Equal(t, StateMaintenance, restartedState.Workflow.State())
+
+	restartedState.ClearMaintenance(lc)
+	restarted, err = NewMaintenanceStore(fileName, nil)
+	require.NoError(t, err)
+	assert.Empty(t, restarted.Reasons("controller-board"))
+}
+
+func TestMaintenanceStoreInvalidFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(fileName, []byte("{"), 0644))
+	_, err := NewMaintenanceStore(fileName, nil)
+	assert.Error(t, err)
+
+	// a nil store keeps nothing
+	var store *MaintenanceStore
+	assert.Empty(t, store.Reasons("controller-board"))
+	newMaintenanceVendingState(nil).SetMaintenanceReason(logger.NewMockClient(), ReasonManual)
+}
+
+func TestSetMaintenanceMode(t *testing.T) {
+	store, err := NewMaintenanceStore("", nil)
+	require.NoError(t, err)
+	vendingState := newMaintenanceVendingState(store)
+	lc := logger.NewMockClient()
+
+	mm, err := vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{MaintenanceMode: true})
+	require.NoError(t, err)
+	assert.Equal(t, MaintenanceMode{MaintenanceMode: true, Reasons: []MaintenanceReason{ReasonManual}}, mm)
+	assert.Equal(t, []MaintenanceReason{ReasonManual}, store.Reasons("controller-board"))
+
+	mm, err = vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{MaintenanceMode: true, Reason: ReasonDoorLeftOpen})
+	require.NoError(t, err)
+	assert.Equal(t, []MaintenanceReason{ReasonManual, ReasonDoorLeftOpen}, mm.Reasons)
+
+	mm, err = vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{Reason: ReasonManual})
+	require.NoError(t, err)
+	assert.Equal(t, MaintenanceMode{MaintenanceMode: true, Reasons: []MaintenanceReason{ReasonDoorLeftOpen}}, mm)
+
+	_, err = vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{MaintenanceMode: true, Reason: "flooded"})
+	assert.True(t, errors.Is(err, ErrUnknownMaintenanceReason))
+	_, err = vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{Reason: ReasonStoreClosed})
+	assert.True(t, errors.Is(err, ErrManagedMaintenanceReason))
+	_, err = vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{Reason: ReasonWarmup})
+	assert.True(t, errors.Is(err, ErrManagedMaintenanceReason))
+
+	mm, err = vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{})
+	require.NoError(t, err)
+	assert.False(t, mm.MaintenanceMode)
+	assert.Empty(t, store.Reasons("controller-board"))
+}
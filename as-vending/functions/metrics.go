@@ -0,0 +1,85 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"time"
+
+	bootstrapInterfaces "github.com/edgexfoundry/go-mod-bootstrap/v3/bootstrap/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+const (
+	// ActiveSessionsMetricName is the gauge of the vend sessions in
+	// progress, from the card scan that unlocked the door until the basket
+	// is charged
+	ActiveSessionsMetricName = "ActiveSessions"
+	// QueuedOutboxMetricName is the gauge of the requests kept in the outbox
+	// waiting to be replayed
+	QueuedOutboxMetricName = "QueuedOutbox"
+	// LastChargeLatencyMetricName is the gauge of how long the last
+	// transaction took to post to the ledger service, in milliseconds
+	LastChargeLatencyMetricName = "LastChargeLatency"
+)
+
+// VendingMetrics are the vend session metrics reported through the EdgeX
+// service metrics, so that they reach the standard telemetry pipeline. A
+// nil VendingMetrics does not record anything.
+type VendingMetrics struct {
+	activeSessions    gometrics.Gauge
+	queuedOutbox      gometrics.Gauge
+	lastChargeLatency gometrics.Gauge
+}
+
+// NewVendingMetrics creates the vend session metrics
+func NewVendingMetrics() *VendingMetrics {
+	return &VendingMetrics{
+		activeSessions:    gometrics.NewGauge(),
+		queuedOutbox:      gometrics.NewGauge(),
+		lastChargeLatency: gometrics.NewGauge(),
+	}
+}
+
+// Register registers the metrics with the service's metrics manager. They
+// are only reported when they are enabled in Writable.Telemetry.Metrics.
+func (metrics *VendingMetrics) Register(lc logger.LoggingClient, manager bootstrapInterfaces.MetricsManager) {
+	if metrics == nil || manager == nil {
+		return
+	}
+	for name, metric := range map[string]gometrics.Gauge{
+		ActiveSessionsMetricName:    metrics.activeSessions,
+		QueuedOutboxMetricName:      metrics.queuedOutbox,
+		LastChargeLatencyMetricName: metrics.lastChargeLatency,
+	} {
+		if err := manager.Register(name, metric, nil); err != nil {
+			lc.Warnf("failed to register %s metric: %s", name, err.Error())
+		}
+	}
+}
+
+// SetActiveSessions sets the number of vend sessions in progress
+func (metrics *VendingMetrics) SetActiveSessions(sessions int) {
+	if metrics == nil {
+		return
+	}
+	metrics.activeSessions.Update(int64(sessions))
+}
+
+// SetQueuedOutbox sets the number of requests kept in the outbox
+func (metrics *VendingMetrics) SetQueuedOutbox(entries int) {
+	if metrics == nil {
+		return
+	}
+	metrics.queuedOutbox.Update(int64(entries))
+}
+
+// RecordChargeLatency records how long the last transaction took to post
+// to the ledger service
+func (metrics *VendingMetrics) RecordChargeLatency(latency time.Duration) {
+	if metrics == nil {
+		return
+	}
+	metrics.lastChargeLatency.Update(latency.Milliseconds())
+}
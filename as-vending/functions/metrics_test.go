@@ -0,0 +1,43 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-bootstrap/v3/bootstrap/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestVendingMetricsRegister(t *testing.T) {
+	metrics := NewVendingMetrics()
+	manager := &mocks.MetricsManager{}
+	manager.On("Register", ActiveSessionsMetricName, metrics.activeSessions, mock.Anything).Return(nil)
+	manager.On("Register", QueuedOutboxMetricName, metrics.queuedOutbox, mock.Anything).Return(errors.New("duplicate metric"))
+	manager.On("Register", LastChargeLatencyMetricName, metrics.lastChargeLatency, mock.Anything).Return(nil)
+
+	// a metric that fails to register does not stop the others
+	metrics.Register(logger.NewMockClient(), manager)
+	manager.AssertNumberOfCalls(t, "Register", 3)
+
+	metrics.SetActiveSessions(1)
+	metrics.SetQueuedOutbox(2)
+	metrics.RecordChargeLatency(1500 * time.Millisecond)
+	assert.Equal(t, int64(1), metrics.activeSessions.Value())
+	assert.Equal(t, int64(2), metrics.queuedOutbox.Value())
+	assert.Equal(t, int64(1500), metrics.lastChargeLatency.Value())
+}
+
+func TestVendingMetricsNil(t *testing.T) {
+	var metrics *VendingMetrics
+	metrics.Register(logger.NewMockClient(), &mocks.MetricsManager{})
+	metrics.SetActiveSessions(1)
+	metrics.SetQueuedOutbox(1)
+	metrics.RecordChargeLatency(time.Second)
+	NewVendingMetrics().Register(logger.NewMockClient(), nil)
+}
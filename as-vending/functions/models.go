@@ -16,28 +16,115 @@ import (
 // Information about the state of the vending workflow should generally
 // be stored in this struct.
 type VendingState struct {
-	CVWorkflowStarted              bool       `json:"cvWorkflowStarted"`
-	MaintenanceMode                bool       `json:"MaintenanceMode"`
-	CurrentUserData                OutputData `json:"personID"`
-	DoorClosed                     bool       `json:"doorClosed"`
-	ThreadStopChannel              chan int   `json:"threadStopChannel"`            // global stop channel for threads
-	DoorOpenedDuringCVWorkflow     bool       `json:"doorOpenedDuringCVWorkflow  "` // door open event
-	DoorOpenWaitThreadStopChannel  chan int   `json:"doorOpenWaitThreadStopChannel"`
-	DoorClosedDuringCVWorkflow     bool       `json:"doorClosedDuringCVWorkflow  "` //door close event
-	DoorCloseWaitThreadStopChannel chan int   `json:"doorCloseWaitThreadStopChannel"`
-	InferenceDataReceived          bool       `json:"inferenceDataReceived"` // inference event
-	InferenceWaitThreadStopChannel chan int   `json:"inferenceWaitThreadStopChannel"`
+	// Workflow is the state of the vend workflow, which the timeout threads
+	// move with guarded transitions. Its session lock guards the fields
+	// below, see LockSession.
+	Workflow                       *Workflow           `json:"-"`
+	MaintenanceMode                bool                `json:"MaintenanceMode"`
+	MaintenanceReasons             []MaintenanceReason `json:"maintenanceReasons"` // conditions that set maintenance mode
+	CurrentUserData                OutputData          `json:"personID"`
+	SplitPayers                    []OutputData        `json:"splitPayers"` // additional customers sharing the basket
+	DoorClosed                     bool                `json:"doorClosed"`
+	ThreadStopChannel              chan int            `json:"threadStopChannel"` // global stop channel for threads
+	DoorOpenWaitThreadStopChannel  chan int            `json:"doorOpenWaitThreadStopChannel"`
+	DoorCloseWaitThreadStopChannel chan int            `json:"doorCloseWaitThreadStopChannel"`
+	InferenceWaitThreadStopChannel chan int            `json:"inferenceWaitThreadStopChannel"`
 	Configuration                  *config.VendingConfig
 	CommandClient                  clientInterfaces.CommandClient
-	DoorCloseStateTimeout          time.Duration
-	DoorOpenStateTimeout           time.Duration
-	InferenceTimeout               time.Duration
+	// Timeouts are the timeouts of the vend workflow stages, which are
+	// updated when the configuration changes
+	Timeouts      *StageTimeouts       `json:"-"`
+	DoorClosedAt  time.Time            `json:"-"` // when the door was closed during the vend workflow
+	StageDeadline time.Time            `json:"-"` // when the current vend workflow stage times out
+	SLA           *SLATracker          `json:"-"`
+	Readers       *ReaderMonitor       `json:"-"`
+	Billing       *BillingCircuit      `json:"-"`
+	Quarantine    *InferenceQuarantine `json:"-"`
+	Metrics       *VendingMetrics      `json:"-"`
+	// SessionLinger is how long a customer has to scan their card again and
+	// reopen the door before their basket is charged, zero disables sessions
+	SessionLinger            time.Duration
+	SessionLingering         bool       `json:"sessionLingering"` // waiting for the customer to reopen the door
+	SessionBasket            []deltaSKU `json:"-"`                // items taken during the session, not yet charged
+	SessionLingerStopChannel chan int   `json:"-"`
+	// SessionID identifies the vend, from the card scan that unlocked the
+	// door until its basket is charged, in the inventory stock movements
+	SessionID string `json:"sessionId"`
+	// StoreClosedReason is why the store was closed remotely, and
+	// StoreChangedAt is when it was last opened or closed
+	StoreClosedReason string    `json:"storeClosedReason"`
+	StoreChangedAt    time.Time `json:"-"`
+	Fleet             *Fleet    `json:"-"`
+	// FleetHealth tracks the heartbeats of the kiosks of the fleet, nil when
+	// fleet monitoring is not configured
+	FleetHealth *FleetMonitor `json:"-"`
+	// Enrollment enrolls the next card swipe instead of opening the door,
+	// nil when card enrollment is not configured
+	Enrollment *Enrollment `json:"-"`
+	// IdleDisplay rotates the idle screens on the LCD, nil when the idle
+	// display is not configured
+	IdleDisplay *IdleDisplay `json:"-"`
+	// SessionDisplay shows the progress of each vend on the LCD, nil when
+	// the session display is not configured
+	SessionDisplay *SessionDisplay `json:"-"`
+	// PriceCheck shows the price of the products scanned between vends, nil
+	// when there is no barcode scanner
+	PriceCheck *PriceCheck `json:"-"`
+	// PinEntry holds a card that needs a PIN until the kiosk UI enters it,
+	// nil when PIN verification is not configured
+	PinEntry *PinEntry `json:"-"`
+	// Doors are the doors of the bank of coolers this service runs, nil
+	// when it runs a single door
+	Doors *DoorBank `json:"-"`
+	// Journal keeps the vend in progress on disk, so that it is recovered
+	// when the service restarts, nil when the session journal is disabled
+	Journal *SessionJournal `json:"-"`
+	// InferenceFallbackMode is the fallback of the current vend when it was
+	// started while inference was unavailable, empty for a vend with
+	// inference
+	InferenceFallbackMode string `json:"inferenceFallback,omitempty"`
+	// Reconciliation keeps the fallback vends for reconciliation, nil when
+	// they are only logged
+	Reconciliation *ReconciliationLog `json:"-"`
+	// Maintenance keeps the maintenance reasons of each door across
+	// restarts and publishes their changes, nil when they are only logged
+	Maintenance *MaintenanceStore `json:"-"`
+	// Outbox keeps the requests that settle a basket and failed to be sent,
+	// to be replayed
+	Outbox *Outbox `json:"-"`
+	// Webhooks posts the vend lifecycle and maintenance events to external
+	// systems, nil when no webhooks are configured
+	Webhooks *WebhookDispatcher `json:"-"`
+	// InferenceRetry is how the inference is triggered again before a vend
+	// whose result does not arrive enters maintenance mode, and
+	// InferenceRetries is how many times it was for the current vend
+	InferenceRetry   InferenceRetryPolicy `json:"-"`
+	InferenceRetries int                  `json:"-"`
+	// CrossCheck checks the inference results against the weight sensor,
+	// nil when there is no weight sensor
+	CrossCheck *WeightCrossCheck `json:"-"`
+	// Discrepancies keeps the inference results that disagreed with the
+	// weight sensor, nil when they are only logged
+	Discrepancies *DiscrepancyLog `json:"-"`
+	// weightDelta is the weight change of the shelves measured during the
+	// current visit, nil until the weight sensor reports it
+	weightDelta *float64
+	// inferenceUnavailable is whether the inference service did not respond
+	// to its heartbeat when the last card was scanned
+	inferenceUnavailable bool
+	// reviewReasons are why the inference results of the session need
+	// review before its basket is charged
+	reviewReasons []string
+	// qrCodeAuth is the authentication of the card whose QR code is being
+	// handled as a swipe, so that the card is not authenticated twice
+	qrCodeAuth *OutputData
 }
 
 // MaintenanceMode is a simple structure used to return the state of
 // maintenance mode to REST API consumers.
 type MaintenanceMode struct {
-	MaintenanceMode bool `json:"maintenanceMode"`
+	MaintenanceMode bool                `json:"maintenanceMode"`
+	Reasons         []MaintenanceReason `json:"reasons,omitempty"`
 }
 
 // ControllerBoardStatus represents the status of the controller board,
@@ -51,6 +138,15 @@ type ControllerBoardStatus struct {
 	Humidity             float64 `json:"humidity"`
 	MinTemperatureStatus bool    `json:"minTemperatureStatus"`
 	MaxTemperatureStatus bool    `json:"maxTemperatureStatus"`
+	// DoorSensorFault is set while the door sensor is suspect, such as when
+	// it is flapping or stuck open
+	DoorSensorFault bool `json:"doorSensorFault"`
+	// DeviceName is the controller board of the status, which is the door
+	// of a bank of coolers it is for
+	DeviceName string `json:"deviceName,omitempty"`
+	// Warmup is set while vending is held after power-on, until the cooler
+	// has cooled down
+	Warmup bool `json:"warmup"`
 }
 
 // Ledger is the data structure that represents financial ledger transactions,
@@ -63,6 +159,12 @@ type Ledger struct {
 	UpdatedAt     int64      `json:"updatedAt,string"`
 	IsPaid        bool       `json:"isPaid"`
 	LineItems     []LineItem `json:"lineItems"`
+	IsFlagged     bool       `json:"isFlagged,omitempty"`
+	FlagReasons   []string   `json:"flagReasons,omitempty"`
+	Currency      string     `json:"currency,omitempty"`
+	DisplayTotal  string     `json:"displayTotal,omitempty"` // LineTotal formatted in Currency by the ledger service
+	SplitID       int64      `json:"splitID,string,omitempty"`
+	IsTest        bool       `json:"isTest,omitempty"` // zero-priced test vend by a technician
 }
 
 // LineItem is a single item contained in the Ledger.
@@ -71,6 +173,8 @@ type LineItem struct {
 	ProductName string  `json:"productName"`
 	ItemPrice   float64 `json:"itemPrice"`
 	ItemCount   int     `json:"itemCount"`
+	Unavailable bool    `json:"unavailable,omitempty"` // vended outside of its availability window
+	Deposit     float64 `json:"deposit,omitempty"`     // per-unit container deposit
 }
 
 // deltaLedger is a representation of a set of deltaSKUs from an upstream
@@ -78,6 +182,31 @@ type LineItem struct {
 type deltaLedger struct {
 	AccountID int        `json:"accountId"`
 	DeltaSKUs []deltaSKU `json:"deltaSKUs"`
+	IsTest    bool       `json:"isTest,omitempty"`
+	SessionID string     `json:"sessionId,omitempty"`
+	// FlagReason flags the transaction for review before it is charged
+	FlagReason string `json:"flagReason,omitempty"`
+}
+
+// basketIntent is recorded in the ledger service before a basket is
+// charged, so that a basket that is never charged is found and charged
+type basketIntent struct {
+	SessionID  string     `json:"sessionId"`
+	AccountID  int        `json:"accountId"`
+	DeltaSKUs  []deltaSKU `json:"deltaSKUs"`
+	IsTest     bool       `json:"isTest,omitempty"`
+	AccountIDs []int      `json:"accountIds,omitempty"`
+	SplitRule  string     `json:"splitRule,omitempty"`
+}
+
+// splitLedger is a set of deltaSKUs from an upstream inference service
+// shared by multiple accounts.
+type splitLedger struct {
+	AccountIDs []int      `json:"accountIds"`
+	Rule       string     `json:"rule"`
+	DeltaSKUs  []deltaSKU `json:"deltaSKUs"`
+	SessionID  string     `json:"sessionId,omitempty"`
+	FlagReason string     `json:"flagReason,omitempty"`
 }
 
 // deltaSKU is a single representation of an integer quantity change of a
@@ -88,45 +217,118 @@ type deltaSKU struct {
 	Delta int    `json:"delta"`
 }
 
+// inventoryDelta is a deltaSKU posted to the inventory service, with the
+// reason the stock moved and the vend it came from
+type inventoryDelta struct {
+	SKU    string      `json:"SKU"`
+	Delta  int         `json:"delta"`
+	Reason string      `json:"reason"`
+	Source deltaSource `json:"source"`
+}
+
+// inventoryDeltaResult is an inventory delta applied by the inventory
+// service, with the units on hand before and after it
+type inventoryDeltaResult struct {
+	SKU               string `json:"sku"`
+	Delta             int    `json:"delta"`
+	AppliedDelta      int    `json:"appliedDelta"`
+	UnitsOnHandBefore int    `json:"unitsOnHandBefore"`
+	UnitsOnHandAfter  int    `json:"unitsOnHandAfter"`
+	Discrepancy       bool   `json:"discrepancy"`
+}
+
+// deltaSource attributes an inventory delta to this service, the card that
+// opened the door and the vending session
+type deltaSource struct {
+	Service   string `json:"service"`
+	User      string `json:"user,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
 // OutputData represents the authentication information associated with
 // a person that has been authenticated to open the vending machine and
 // remove items from inventory for purchase. This information is pushed to
 // the vending state and shared throughout this application service.
 type OutputData struct {
-	AccountID int    `json:"accountID"`
-	PersonID  int    `json:"personID"`
-	RoleID    int    `json:"roleID"`
-	CardID    string `json:"cardID"`
+	AccountID   int     `json:"accountID"`
+	PersonID    int     `json:"personID"`
+	RoleID      int     `json:"roleID"`
+	CardID      string  `json:"cardID"`
+	CreditLimit float64 `json:"creditLimit,omitempty"` // maximum unpaid balance, zero for no limit
+	PinRequired bool    `json:"pinRequired,omitempty"` // the card's PIN must be entered before the door is unlocked
+	// Token is the authentication token of the card, which the ledger
+	// service needs to read the account. It is a credential, so it is
+	// neither journaled nor reported.
+	Token string `json:"-"`
+}
+
+// authResponse is the response of the authentication service for a card,
+// with the card's authentication token when tokens are configured
+type authResponse struct {
+	OutputData
+	Token string `json:"token,omitempty"`
+}
+
+// The actions the authentication service authorizes cards for
+const (
+	// ActionStock opens the door to restock the vending machine
+	ActionStock = "stock"
+	// ActionMaintain puts the vending machine in maintenance mode
+	ActionMaintain = "maintain"
+)
+
+// cardAuthorization is the response of the authentication service's
+// authorization endpoint
+type cardAuthorization struct {
+	CardID  string `json:"cardID"`
+	RoleID  int    `json:"roleID"`
+	Action  string `json:"action"`
+	Allowed bool   `json:"allowed"`
+}
+
+// accountBalance is the unpaid balance of an account, which comes from the
+// ledger service.
+type accountBalance struct {
+	AccountID     int     `json:"accountID"`
+	Currency      string  `json:"currency"`
+	UnpaidBalance float64 `json:"unpaidBalance"`
+}
+
+// preAuthRequest is the hold amount of this kiosk, which is sent to the
+// ledger service when a customer is pre-authorized.
+type preAuthRequest struct {
+	Amount float64 `json:"amount"`
 }
 
 // AuditLogEntry is the representation of an inventory transaction that
 // occurs when someone opens the vending machine. Regardless of how many
 // items have been taken, an audit log transaction will always be created.
 type AuditLogEntry struct {
-	CardID         string     `json:"cardId"`
-	AccountID      int        `json:"accountId"`
-	RoleID         int        `json:"roleId"`
-	PersonID       int        `json:"personId"`
-	InventoryDelta []deltaSKU `json:"inventoryDelta"`
-	CreatedAt      int64      `json:"createdAt,string"`
-	AuditEntryID   string     `json:"auditEntryId"`
+	CardID         string           `json:"cardId"`
+	AccountID      int              `json:"accountId"`
+	RoleID         int              `json:"roleId"`
+	PersonID       int              `json:"personId"`
+	InventoryDelta []inventoryDelta `json:"inventoryDelta"`
+	CreatedAt      int64            `json:"createdAt,string"`
+	AuditEntryID   string           `json:"auditEntryId"`
 }
 
 func (vs *VendingState) ParseDurationFromConfig() error {
-	var err error
-	vs.DoorCloseStateTimeout, err = time.ParseDuration(vs.Configuration.DoorCloseStateTimeoutDuration)
-	if err != nil {
-		return fmt.Errorf("failed to parse DoorCloseStateTimeoutDuration configuration: %v", err)
-	}
-
-	vs.DoorOpenStateTimeout, err = time.ParseDuration(vs.Configuration.DoorOpenStateTimeoutDuration)
+	durations, err := ParseStageDurations(vs.Configuration)
 	if err != nil {
-		return fmt.Errorf("failed to parse DoorOpenStateTimeoutDuration configuration: %v", err)
+		return err
 	}
+	vs.Timeouts = NewStageTimeouts(durations)
 
-	vs.InferenceTimeout, err = time.ParseDuration(vs.Configuration.InferenceTimeoutDuration)
-	if err != nil {
-		return fmt.Errorf("failed to parse InferenceTimeoutDuration configuration: %v", err)
+	vs.SessionLinger = 0
+	if vs.Configuration.SessionLingerDuration != "" {
+		vs.SessionLinger, err = time.ParseDuration(vs.Configuration.SessionLingerDuration)
+		if err != nil {
+			return fmt.Errorf("failed to parse SessionLingerDuration configuration: %v", err)
+		}
+		if vs.SessionLinger < 0 {
+			return fmt.Errorf("failed to parse SessionLingerDuration configuration: %s is negative", vs.Configuration.SessionLingerDuration)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,429 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// The kinds of requests kept in the outbox
+const (
+	OutboxCharge         = "charge"
+	OutboxSplit          = "split"
+	OutboxInventoryDelta = "inventoryDelta"
+	OutboxAuditLog       = "auditLog"
+)
+
+// OutboxBackupVersion is the version of the outbox backup format
+const OutboxBackupVersion = 1
+
+// ErrOutboxImportVending is returned when a backup with a pending session is
+// imported while a vend is in progress
+var ErrOutboxImportVending = errors.New("a backup with a pending session cannot be imported during a vend")
+
+// OutboxEntry is a request that settles a basket and failed to be sent. ID
+// is the idempotency key of the request, the kind and session of the basket,
+// so that the same request is only kept once.
+type OutboxEntry struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	SessionID string          `json:"sessionId,omitempty"`
+	CardID    string          `json:"cardId,omitempty"`
+	Body      json.RawMessage `json:"body"`
+	CreatedAt int64           `json:"createdAt,string"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+}
+
+// OutboxBackup is the export of the outbox and the pending session, which a
+// reimaged kiosk imports to replay them
+type OutboxBackup struct {
+	Version    int                  `json:"version"`
+	KioskID    string               `json:"kioskId,omitempty"`
+	ExportedAt int64                `json:"exportedAt,string"`
+	Entries    []OutboxEntry        `json:"entries"`
+	Session    *SessionJournalEntry `json:"session,omitempty"`
+}
+
+// OutboxReplayReport is the result of replaying the outbox. Duplicates are
+// the entries that were already applied, which are dropped without being
+// sent again.
+type OutboxReplayReport struct {
+	Sent       int           `json:"sent"`
+	Duplicates int           `json:"duplicates"`
+	Failed     int           `json:"failed"`
+	Pending    []OutboxEntry `json:"pending"`
+}
+
+// Outbox keeps the requests that failed to be sent in a JSON file, so that
+// they are replayed rather than lost. A nil Outbox keeps nothing.
+type Outbox struct {
+	mutex    sync.Mutex
+	fileName string
+	entries  []OutboxEntry
+	// metrics reports the number of entries as the queued outbox gauge
+	metrics *VendingMetrics
+	// replayMutex keeps replays from sending the same entry twice
+	replayMutex sync.Mutex
+}
+
+// NewOutbox creates the outbox kept in the file, and loads the entries kept
+// in it. The entries are only kept in memory when the file is empty.
+func NewOutbox(fileName string) (*Outbox, error) {
+	outbox := &Outbox{fileName: fileName, entries: []OutboxEntry{}}
+	if fileName == "" {
+		return outbox, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the outbox directory: %s", err.Error())
+	}
+	entriesJSON, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return outbox, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the outbox %s: %s", fileName, err.Error())
+	}
+	if err := json.Unmarshal(entriesJSON, &outbox.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse the outbox %s: %s", fileName, err.Error())
+	}
+	return outbox, nil
+}
+
+// SetMetrics reports the number of entries of the outbox with the metrics
+// from now on, starting with the entries loaded from the file
+func (outbox *Outbox) SetMetrics(metrics *VendingMetrics) {
+	if outbox == nil {
+		return
+	}
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+	outbox.metrics = metrics
+	outbox.metrics.SetQueuedOutbox(len(outbox.entries))
+}
+
+// Add keeps the entries that are not kept yet, and returns how many were
+// added
+func (outbox *Outbox) Add(lc logger.LoggingClient, entries ...OutboxEntry) int {
+	if outbox == nil {
+		return 0
+	}
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+	added := 0
+	for _, entry := range entries {
+		if outbox.index(entry.ID) >= 0 {
+			continue
+		}
+		outbox.entries = append(outbox.entries, entry)
+		added++
+	}
+	if added > 0 {
+		outbox.save(lc)
+	}
+	outbox.metrics.SetQueuedOutbox(len(outbox.entries))
+	return added
+}
+
+// Entries returns the entries of the outbox, oldest first
+func (outbox *Outbox) Entries() []OutboxEntry {
+	entries := []OutboxEntry{}
+	if outbox == nil {
+		return entries
+	}
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+	return append(entries, outbox.entries...)
+}
+
+// remove drops the entry once it was sent or found to be applied already
+func (outbox *Outbox) remove(lc logger.LoggingClient, id string) {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+	if i := outbox.index(id); i >= 0 {
+		outbox.entries = append(outbox.entries[:i], outbox.entries[i+1:]...)
+		outbox.save(lc)
+	}
+	outbox.metrics.SetQueuedOutbox(len(outbox.entries))
+}
+
+// failed records the failed attempt to send the entry
+func (outbox *Outbox) failed(lc logger.LoggingClient, id string, err error) {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+	if i := outbox.index(id); i >= 0 {
+		outbox.entries[i].Attempts++
+		outbox.entries[i].LastError = err.Error()
+		outbox.save(lc)
+	}
+}
+
+func (outbox *Outbox) index(id string) int {
+	for i, entry := range outbox.entries {
+		if entry.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the entries to a temporary file that replaces the file, so
+// that a crash never leaves a partial file behind. A failure is only
+// logged, the entries are still kept in memory.
+func (outbox *Outbox) save(lc logger.LoggingClient) {
+	if outbox.fileName == "" {
+		return
+	}
+	entriesJSON, err := json.MarshalIndent(outbox.entries, "", "  ")
+	if err == nil {
+		tempName := filepath.Join(filepath.Dir(outbox.fileName), "."+filepath.Base(outbox.fileName)+".tmp")
+		if err = os.WriteFile(tempName, entriesJSON, 0644); err == nil {
+			err = os.Rename(tempName, outbox.fileName)
+		}
+	}
+	if err != nil {
+		lc.Errorf("Failed to write the outbox %s: %s", outbox.fileName, err.Error())
+	}
+}
+
+// undelivered returns whether a request failed because it did not reach the
+// service or the service failed, rather than because it was refused, so
+// that sending it again may succeed
+func undelivered(resp *http.Response, err error) bool {
+	return err != nil && (resp == nil || resp.StatusCode >= http.StatusInternalServerError)
+}
+
+// queueOutbox keeps the request of the kind for the current session in the
+// outbox, after it failed to be sent
+func (vendingState *VendingState) queueOutbox(lc logger.LoggingClient, kind string, request interface{}) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		lc.Errorf("Failed to marshal the %s request of session %s for the outbox: %s", kind, vendingState.SessionID, err.Error())
+		return
+	}
+	createdAt := time.Now().UnixNano()
+	id := kind + ":" + vendingState.SessionID
+	if vendingState.SessionID == "" {
+		id = kind + ":" + strconv.FormatInt(createdAt, 10)
+	}
+	if vendingState.Outbox.Add(lc, OutboxEntry{
+		ID:        id,
+		Kind:      kind,
+		SessionID: vendingState.SessionID,
+		CardID:    vendingState.CurrentUserData.CardID,
+		Body:      body,
+		CreatedAt: createdAt,
+	}) > 0 {
+		lc.Warnf("Kept the %s request of session %s in the outbox to be replayed", kind, vendingState.SessionID)
+	}
+}
+
+// queueSettlement keeps every request that settles the basket of the
+// current session in the outbox: its charge, its inventory delta and its
+// audit log entry
+func (vendingState *VendingState) queueSettlement(lc logger.LoggingClient, skuDelta []deltaSKU, flagReason string) {
+	roleID := vendingState.CurrentUserData.RoleID
+	switch {
+	case roleID == 1 && len(vendingState.SplitPayers) > 0:
+		vendingState.queueOutbox(lc, OutboxSplit, vendingState.splitRequest(skuDelta, flagReason))
+	case roleID == 1 || roleID == 4:
+		vendingState.queueOutbox(lc, OutboxCharge, vendingState.chargeRequest(skuDelta, flagReason))
+	}
+	inventoryDeltas := vendingState.inventoryDeltas(skuDelta)
+	vendingState.queueOutbox(lc, OutboxInventoryDelta, inventoryDeltas)
+	vendingState.queueOutbox(lc, OutboxAuditLog, vendingState.auditLogEntry(inventoryDeltas))
+}
+
+// ReplayOutbox sends the entries of the outbox again, oldest first. An entry
+// that was applied already, such as from a backup taken before it was sent,
+// is dropped instead of being sent twice: the ledger service does not
+// charge a session twice, and the inventory deltas and audit log entries of
+// a session are looked up in the inventory service first. Entries that fail
+// again are kept for the next replay.
+func (vendingState *VendingState) ReplayOutbox(lc logger.LoggingClient) OutboxReplayReport {
+	report := OutboxReplayReport{Pending: []OutboxEntry{}}
+	if vendingState.Outbox == nil {
+		return report
+	}
+	vendingState.Outbox.replayMutex.Lock()
+	defer vendingState.Outbox.replayMutex.Unlock()
+
+	for _, entry := range vendingState.Outbox.Entries() {
+		sent, err := vendingState.sendOutboxEntry(lc, entry)
+		switch {
+		case err != nil:
+			lc.Errorf("Failed to replay the %s request of session %s: %s", entry.Kind, entry.SessionID, err.Error())
+			vendingState.Outbox.failed(lc, entry.ID, err)
+			report.Failed++
+		case sent:
+			lc.Infof("Replayed the %s request of session %s", entry.Kind, entry.SessionID)
+			vendingState.Outbox.remove(lc, entry.ID)
+			report.Sent++
+		default:
+			lc.Infof("The %s request of session %s was already applied", entry.Kind, entry.SessionID)
+			vendingState.Outbox.remove(lc, entry.ID)
+			report.Duplicates++
+		}
+	}
+	report.Pending = vendingState.Outbox.Entries()
+	return report
+}
+
+// sendOutboxEntry sends the request of the entry, unless it was applied
+// already, and returns whether it was sent
+func (vendingState *VendingState) sendOutboxEntry(lc logger.LoggingClient, entry OutboxEntry) (bool, error) {
+	var endpoint string
+	switch entry.Kind {
+	case OutboxCharge:
+		// the ledger service returns the transaction that already charged the
+		// session rather than charging it again
+		endpoint = vendingState.Configuration.LedgerService
+	case OutboxSplit:
+		endpoint = vendingState.Configuration.LedgerService + "/split"
+	case OutboxInventoryDelta:
+		applied, err := vendingState.inventoryDeltaApplied(lc, entry)
+		if err != nil || applied {
+			return false, err
+		}
+		endpoint = vendingState.Configuration.InventoryService
+	case OutboxAuditLog:
+		recorded, err := vendingState.auditLogRecorded(lc, entry)
+		if err != nil || recorded {
+			return false, err
+		}
+		endpoint = vendingState.Configuration.InventoryAuditLogService
+	default:
+		return false, fmt.Errorf("unknown outbox entry kind %q", entry.Kind)
+	}
+
+	resp, err := sendHTTPRequest(lc, http.MethodPost, endpoint, entry.Body)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// inventoryDeltaApplied returns whether the inventory service has stock
+// movements of this service for the session of the entry. The movements are
+// at /inventory/movements, next to the /inventory/delta InventoryService.
+func (vendingState *VendingState) inventoryDeltaApplied(lc logger.LoggingClient, entry OutboxEntry) (bool, error) {
+	if entry.SessionID == "" {
+		return false, nil
+	}
+	query := url.Values{"service": {inventoryDeltaService}, "sessionId": {entry.SessionID}}
+	movementsURL := strings.TrimSuffix(vendingState.Configuration.InventoryService, "/delta") + "/movements?" + query.Encode()
+	var movements struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := getJSON(lc, movementsURL, &movements); err != nil {
+		return false, fmt.Errorf("failed to look up the stock movements of session %s: %s", entry.SessionID, err.Error())
+	}
+	return len(movements.Data) > 0, nil
+}
+
+// auditLogRecorded returns whether the inventory service has an audit log
+// entry of the card of the entry for its session, or created at the same
+// time
+func (vendingState *VendingState) auditLogRecorded(lc logger.LoggingClient, entry OutboxEntry) (bool, error) {
+	var queued AuditLogEntry
+	if err := json.Unmarshal(entry.Body, &queued); err != nil {
+		return false, fmt.Errorf("invalid audit log entry: %s", err.Error())
+	}
+	query := url.Values{"cardId": {queued.CardID}}
+	var auditLog struct {
+		Data []AuditLogEntry `json:"data"`
+	}
+	if err := getJSON(lc, vendingState.Configuration.InventoryAuditLogService+"?"+query.Encode(), &auditLog); err != nil {
+		return false, fmt.Errorf("failed to look up the audit log of card %s: %s", queued.CardID, err.Error())
+	}
+	for _, recorded := range auditLog.Data {
+		if recorded.CreatedAt == queued.CreatedAt {
+			return true, nil
+		}
+		for _, delta := range recorded.InventoryDelta {
+			if entry.SessionID != "" && delta.Source.SessionID == entry.SessionID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// getJSON gets the JSON response of the URL into the value
+func getJSON(lc logger.LoggingClient, url string, value interface{}) error {
+	resp, err := sendHTTPRequest(lc, http.MethodGet, url, []byte(""))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, value)
+}
+
+// ExportOutbox returns the entries of the outbox and the pending session of
+// the session journal, as a backup to import after the kiosk is reimaged
+func (vendingState *VendingState) ExportOutbox() (OutboxBackup, error) {
+	session, err := vendingState.Journal.Load()
+	if err != nil {
+		return OutboxBackup{}, err
+	}
+	backup := OutboxBackup{
+		Version:    OutboxBackupVersion,
+		ExportedAt: time.Now().UnixNano(),
+		Entries:    vendingState.Outbox.Entries(),
+		Session:    session,
+	}
+	if vendingState.Configuration != nil {
+		backup.KioskID = vendingState.Configuration.KioskID
+	}
+	return backup, nil
+}
+
+// ImportOutbox adds the entries of a backup to the outbox, and replays them.
+// The basket of a pending lingering session of the backup is settled through
+// the outbox too, since its customer is long gone. A pending vend without a
+// basket cannot be replayed, as what was taken is unknown, so it is only
+// logged and left to the basket intent recorded in the ledger service.
+func (vendingState *VendingState) ImportOutbox(lc logger.LoggingClient, backup OutboxBackup) (OutboxReplayReport, error) {
+	if backup.Version != OutboxBackupVersion {
+		return OutboxReplayReport{}, fmt.Errorf("unsupported outbox backup version %d, expected %d", backup.Version, OutboxBackupVersion)
+	}
+	if backup.Session != nil && vendingState.Workflow != nil && vendingState.Workflow.Vending() {
+		return OutboxReplayReport{}, ErrOutboxImportVending
+	}
+
+	added := vendingState.Outbox.Add(lc, backup.Entries...)
+	lc.Infof("Imported %d of the %d outbox entries of the backup of kiosk %s", added, len(backup.Entries), backup.KioskID)
+	if session := backup.Session; session != nil {
+		if session.SessionBasket != nil {
+			pending := &VendingState{Configuration: vendingState.Configuration, Outbox: vendingState.Outbox}
+			pending.restoreSession(session)
+			pending.queueSettlement(lc, session.SessionBasket, "")
+		} else {
+			lc.Errorf("The pending vend %s of card %s in the backup has no basket to replay", session.SessionID, session.CurrentUserData.CardID)
+		}
+	}
+	return vendingState.ReplayOutbox(lc), nil
+}
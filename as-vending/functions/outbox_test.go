@@ -0,0 +1,296 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outboxServer stands in for the ledger and inventory services. The
+// sessions of appliedSessions already have stock movements and audit log
+// entries, and requests fail while down is set.
+type outboxServer struct {
+	*httptest.Server
+	mutex           sync.Mutex
+	appliedSessions map[string]bool
+	down            bool
+	posted          map[string]int
+}
+
+func newOutboxServer(appliedSessions ...string) *outboxServer {
+	server := &outboxServer{appliedSessions: map[string]bool{}, posted: map[string]int{}}
+	for _, sessionID := range appliedSessions {
+		server.appliedSessions[sessionID] = true
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		if server.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodPost {
+			server.posted[r.URL.Path]++
+			w.Write([]byte(`{}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/inventory/movements":
+			if server.appliedSessions[r.URL.Query().Get("sessionId")] {
+				w.Write([]byte(`{"data":[{"sku":"HXI86WHU","delta":-1}]}`))
+				return
+			}
+			w.Write([]byte(`{"data":[]}`))
+		case "/auditlog":
+			entries := []AuditLogEntry{}
+			for sessionID := range server.appliedSessions {
+				entries = append(entries, AuditLogEntry{CardID: r.URL.Query().Get("cardId"), InventoryDelta: []inventoryDelta{{SKU: "HXI86WHU", Delta: -1, Source: deltaSource{SessionID: sessionID}}}})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": entries})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func (server *outboxServer) setDown(down bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.down = down
+}
+
+func (server *outboxServer) postedTo(path string) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.posted[path]
+}
+
+func newOutboxVendingState(t *testing.T, serverURL string, fileName string) *VendingState {
+	outbox, err := NewOutbox(fileName)
+	require.NoError(t, err)
+	return &VendingState{
+		Configuration: &config.VendingConfig{
+			KioskID:                  "kiosk-1",
+			LedgerService:            serverURL + "/ledger",
+			InventoryService:         serverURL + "/inventory/delta",
+			InventoryAuditLogService: serverURL + "/auditlog",
+		},
+		Workflow: NewWorkflow(StateIdle),
+		Outbox:   outbox,
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "outbox", "outbox.json")
+	outbox, err := NewOutbox(fileName)
+	require.NoError(t, err)
+	lc := logger.NewMockClient()
+	metrics := NewVendingMetrics()
+	outbox.SetMetrics(metrics)
+
+	entry := OutboxEntry{ID: "charge:session-1", Kind: OutboxCharge, SessionID: "session-1", Body: json.RawMessage(`{"accountId":1}`)}
+	assert.Equal(t, 1, outbox.Add(lc, entry))
+	assert.Equal(t, 0, outbox.Add(lc, entry), "an entry that is already kept is not added again")
+	assert.Equal(t, int64(1), metrics.queuedOutbox.Value())
+	outbox.failed(lc, entry.ID, errors.New("ledger unavailable"))
+
+	// the entries survive a restart of the service
+	restarted, err := NewOutbox(fileName)
+	require.NoError(t, err)
+	restarted.SetMetrics(metrics)
+	assert.Equal(t, int64(1), metrics.queuedOutbox.Value(), "the loaded entries are reported")
+	entries := restarted.Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, 1, entries[0].Attempts)
+	assert.Equal(t, "ledger unavailable", entries[0].LastError)
+	assert.JSONEq(t, `{"accountId":1}`, string(entries[0].Body))
+
+	restarted.remove(lc, entry.ID)
+	assert.Zero(t, metrics.queuedOutbox.Value())
+	restarted, err = NewOutbox(fileName)
+	require.NoError(t, err)
+	assert.Empty(t, restarted.Entries())
+
+	require.NoError(t, os.WriteFile(fileName, []byte("["), 0644))
+	_, err = NewOutbox(fileName)
+	assert.Error(t, err)
+
+	// a nil outbox keeps nothing
+	var nilOutbox *Outbox
+	assert.Equal(t, 0, nilOutbox.Add(lc, entry))
+	assert.Empty(t, nilOutbox.Entries())
+}
+
+func TestSettleBasketQueuesOutbox(t *testing.T) {
+	server := newOutboxServer()
+	defer server.Close()
+	server.setDown(true)
+	lc := logger.NewMockClient()
+
+	vendingState := newOutboxVendingState(t, server.URL, "")
+	vendingState.SessionID = "session-1"
+	vendingState.CurrentUserData = OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374"}
+	require.Error(t, vendingState.settleBasket(lc, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}))
+
+	entries := vendingState.Outbox.Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, []string{"charge:session-1", "inventoryDelta:session-1", "auditLog:session-1"}, []string{entries[0].ID, entries[1].ID, entries[2].ID})
+	var charge deltaLedger
+	require.NoError(t, json.Unmarshal(entries[0].Body, &charge))
+	assert.Equal(t, deltaLedger{AccountID: 1, DeltaSKUs: []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, SessionID: "session-1"}, charge)
+
+	// settling the basket again does not queue it twice
+	require.Error(t, vendingState.settleBasket(lc, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}))
+	assert.Len(t, vendingState.Outbox.Entries(), 3)
+
+	server.setDown(false)
+	report := vendingState.ReplayOutbox(lc)
+	assert.Equal(t, OutboxReplayReport{Sent: 3, Pending: []OutboxEntry{}}, report)
+	assert.Equal(t, 1, server.postedTo("/ledger"))
+	assert.Equal(t, 1, server.postedTo("/inventory/delta"))
+	assert.Equal(t, 1, server.postedTo("/auditlog"))
+}
+
+func TestSettleBasketRefusedIsNotQueued(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	vendingState := newOutboxVendingState(t, server.URL, "")
+	vendingState.SessionID = "session-1"
+	vendingState.CurrentUserData = OutputData{AccountID: 1, RoleID: 1}
+	require.Error(t, vendingState.settleBasket(logger.NewMockClient(), []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}))
+	assert.Empty(t, vendingState.Outbox.Entries())
+}
+
+func TestReplayOutbox(t *testing.T) {
+	server := newOutboxServer("session-applied")
+	defer server.Close()
+	lc := logger.NewMockClient()
+
+	vendingState := newOutboxVendingState(t, server.URL, "")
+	for _, sessionID := range []string{"session-applied", "session-pending"} {
+		vendingState.SessionID = sessionID
+		vendingState.CurrentUserData = OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374"}
+		vendingState.queueSettlement(lc, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, "")
+	}
+	vendingState.Outbox.Add(lc, OutboxEntry{ID: "unknown:1", Kind: "unknown", Body: json.RawMessage(`{}`)})
+
+	report := vendingState.ReplayOutbox(lc)
+	// the charges are sent again as the ledger service does not charge a
+	// session twice, the inventory deltas and audit log entries of the
+	// applied session are dropped
+	assert.Equal(t, 4, report.Sent)
+	assert.Equal(t, 2, report.Duplicates)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Pending, 1)
+	assert.Equal(t, "unknown:1", report.Pending[0].ID)
+	assert.Equal(t, 1, report.Pending[0].Attempts)
+	assert.Equal(t, 2, server.postedTo("/ledger"))
+	assert.Equal(t, 1, server.postedTo("/inventory/delta"))
+	assert.Equal(t, 1, server.postedTo("/auditlog"))
+
+	// replaying again sends nothing twice
+	report = vendingState.ReplayOutbox(lc)
+	assert.Equal(t, 0, report.Sent)
+	assert.Equal(t, 2, server.postedTo("/ledger"))
+}
+
+func TestExportImportOutbox(t *testing.T) {
+	server := newOutboxServer()
+	defer server.Close()
+	server.setDown(true)
+	lc := logger.NewMockClient()
+
+	// the kiosk failed to settle a basket, and has a lingering session
+	// pending when it is backed up
+	journal, err := NewSessionJournal(filepath.Join(t.TempDir(), "journal.json"))
+	require.NoError(t, err)
+	vendingState := newOutboxVendingState(t, server.URL, "")
+	vendingState.Journal = journal
+	vendingState.SessionID = "session-1"
+	vendingState.CurrentUserData = OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374"}
+	vendingState.queueSettlement(lc, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, "")
+	require.NoError(t, journal.Save(SessionJournalEntry{
+		State:            StateIdle,
+		SessionID:        "session-2",
+		CurrentUserData:  OutputData{AccountID: 2, RoleID: 1, CardID: "0003278380"},
+		SessionBasket:    []deltaSKU{{SKU: "1200050408", Delta: -2}},
+		SessionLingering: true,
+	}))
+
+	backup, err := vendingState.ExportOutbox()
+	require.NoError(t, err)
+	assert.Equal(t, OutboxBackupVersion, backup.Version)
+	assert.Equal(t, "kiosk-1", backup.KioskID)
+	assert.Len(t, backup.Entries, 3)
+	require.NotNil(t, backup.Session)
+	backupJSON, err := json.Marshal(backup)
+	require.NoError(t, err)
+
+	// the reimaged kiosk imports the backup
+	server.setDown(false)
+	reimaged := newOutboxVendingState(t, server.URL, "")
+	var imported OutboxBackup
+	require.NoError(t, json.Unmarshal(backupJSON, &imported))
+	report, err := reimaged.ImportOutbox(lc, imported)
+	require.NoError(t, err)
+	assert.Equal(t, OutboxReplayReport{Sent: 6, Pending: []OutboxEntry{}}, report)
+	assert.Equal(t, 2, server.postedTo("/ledger"))
+	assert.Empty(t, reimaged.SessionID, "the pending session is not resumed on the reimaged kiosk")
+
+	// importing the same backup twice applies nothing twice
+	server.mutex.Lock()
+	server.appliedSessions["session-1"] = true
+	server.appliedSessions["session-2"] = true
+	server.mutex.Unlock()
+	report, err = reimaged.ImportOutbox(lc, imported)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Sent, "only the charges are sent, which the ledger service does not apply twice")
+	assert.Equal(t, 4, report.Duplicates)
+	assert.Equal(t, 2, server.postedTo("/inventory/delta"))
+
+	imported.Version = 0
+	_, err = reimaged.ImportOutbox(lc, imported)
+	assert.Error(t, err)
+
+	imported.Version = OutboxBackupVersion
+	require.NoError(t, reimaged.Workflow.Transition(StateAuthorized, "cardAuthorized"))
+	_, err = reimaged.ImportOutbox(lc, imported)
+	assert.True(t, errors.Is(err, ErrOutboxImportVending))
+}
+
+func TestAuditLogRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Empty(t, body)
+		assert.Equal(t, "0003293374", r.URL.Query().Get("cardId"))
+		w.Write([]byte(`{"data":[{"cardId":"0003293374","createdAt":"1700000000000000000","inventoryDelta":[]}]}`))
+	}))
+	defer server.Close()
+	vendingState := newOutboxVendingState(t, server.URL, "")
+	lc := logger.NewMockClient()
+
+	recorded, err := vendingState.auditLogRecorded(lc, OutboxEntry{Kind: OutboxAuditLog, Body: json.RawMessage(`{"cardId":"0003293374","createdAt":"1700000000000000000"}`)})
+	require.NoError(t, err)
+	assert.True(t, recorded, "an entry without a session is matched by its creation time")
+	recorded, err = vendingState.auditLogRecorded(lc, OutboxEntry{Kind: OutboxAuditLog, Body: json.RawMessage(`{"cardId":"0003293374","createdAt":"1700000000000000001"}`)})
+	require.NoError(t, err)
+	assert.False(t, recorded)
+}
@@ -4,6 +4,7 @@
 package functions
 
 import (
+	"as-vending/config"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -17,6 +18,7 @@ import (
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/google/uuid"
 )
 
 const (
@@ -24,6 +26,16 @@ const (
 	DsCardReader        = "card-reader"
 )
 
+// Inventory deltas are posted as sales, as restocks when an item stocker
+// opened the door, or as tests when a technician made a test vend, and
+// attributed to this service
+const (
+	inventoryDeltaService       = "as-vending"
+	inventoryDeltaReasonSale    = "sale"
+	inventoryDeltaReasonRestock = "restock"
+	inventoryDeltaReasonTest    = "test"
+)
+
 // DeviceHelper is an EdgeX function that is passed into the EdgeX SDK's function pipeline.
 // It is a decision function that allows for multiple devices to have their events processed
 // correctly by this application service.
@@ -35,17 +47,48 @@ func (vendingState *VendingState) DeviceHelper(ctx interfaces.AppFunctionContext
 
 	event := data.(dtos.Event)
 
+	// the events of the devices of another door of the bank are handled by
+	// that door
+	if door, ok := vendingState.Doors.Door(event.DeviceName); ok && door != vendingState {
+		return door.DeviceHelper(ctx, data)
+	}
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+
 	switch event.DeviceName {
-	case DsCardReader:
+	case vendingState.cardReaderName():
 		{
+			// every event shows that the card reader is alive, and status
+			// readings are only its heartbeat
+			vendingState.readerSeen(ctx.LoggingClient(), event.DeviceName)
+			if event.SourceName == CardReaderStatusResource {
+				return false, nil
+			}
+			// in enrollment mode the swipe is enrolled and the door stays locked
+			if vendingState.Enrollment.Waiting() {
+				return vendingState.enrollCard(ctx.LoggingClient(), event)
+			}
 			return vendingState.VerifyDoorAccess(ctx.LoggingClient(), event)
 		}
-	case InferenceMQTTDevice:
+	case vendingState.inferenceDeviceName(), vendingState.secondaryInferenceDeviceName():
 		{
 			return vendingState.HandleMqttDeviceReading(ctx.LoggingClient(), event)
 		}
+	case HeartbeatDeviceName:
+		{
+			vendingState.kioskHeartbeat(ctx.LoggingClient(), event)
+			return false, nil
+		}
 	default:
 		{
+			// barcode scans between vends are price checks
+			if vendingState.PriceCheck != nil && event.DeviceName == vendingState.PriceCheck.DeviceName() {
+				return vendingState.CheckPrice(ctx.LoggingClient(), event)
+			}
+			// the weight sensor measures what each vend took from the shelves
+			if vendingState.CrossCheck != nil && event.DeviceName == vendingState.CrossCheck.DeviceName() {
+				return vendingState.recordWeight(ctx.LoggingClient(), event)
+			}
 			return false, nil
 		}
 	}
@@ -54,14 +97,11 @@ func (vendingState *VendingState) DeviceHelper(ctx interfaces.AppFunctionContext
 // HandleMqttDeviceReading is an EdgeX function that simply handles events coming from
 // the MQTT device service.
 func (vendingState *VendingState) HandleMqttDeviceReading(lc logger.LoggingClient, event dtos.Event) (bool, interface{}) {
-	if event.DeviceName == InferenceMQTTDevice {
+	if event.DeviceName == vendingState.inferenceDeviceName() || event.DeviceName == vendingState.secondaryInferenceDeviceName() {
 
 		lc.Infof("Inference mqtt device")
-		lc.Debugf("workflow: +%v", vendingState.CVWorkflowStarted)
+		lc.Debugf("workflow: %s", vendingState.Workflow.State())
 		lc.Debugf("maintenance mode: +%v", vendingState.MaintenanceMode)
-		lc.Debugf("open: +%v", vendingState.DoorOpenedDuringCVWorkflow)
-		lc.Debugf("closed: +%v", vendingState.DoorClosedDuringCVWorkflow)
-		lc.Debugf("Inference: +%v ", vendingState.InferenceDataReceived)
 		lc.Debugf("door: +%v", vendingState.DoorClosed)
 
 		lc.Debug("Processing reading from MQTT device service")
@@ -73,102 +113,27 @@ func (vendingState *VendingState) HandleMqttDeviceReading(lc logger.LoggingClien
 			case "inferenceSkuDelta":
 				{
 					lc.Info("Inference Started")
-					var skuDelta []deltaSKU
-
-					if err := json.Unmarshal([]byte(eventReading.Value), &skuDelta); err != nil {
-						lc.Errorf("HandleMqttDeviceReading failed to unmarshal skuDelta message for %s: %v", eventReading.Value, err)
-						lc.Error("Inference Failed")
-						return false, err
-					}
-
-					// do some things with the skuDelta
-					// example:
-					// [{"SKU": "HXI86WHU", "delta": -2}]
-					deltaLedger := deltaLedger{
-						AccountID: vendingState.CurrentUserData.AccountID,
-						DeltaSKUs: skuDelta,
-					}
-
-					vendingState.InferenceDataReceived = true
-					// Stop the open wait thread since the door is now opened
-					close(vendingState.InferenceWaitThreadStopChannel)
-					vendingState.InferenceWaitThreadStopChannel = make(chan int)
-
-					if vendingState.CurrentUserData.RoleID == 1 {
-						// POST the deltaLedger json string to the ledger endpoint
-						outputBytes, err := json.Marshal(deltaLedger)
-						if err != nil {
-							lc.Errorf("HandleMqttDeviceReading failed to marshal deltaLedger: %v", err)
-							return false, err
-						}
-
-						lc.Info("Sending SKU delta to ledger service")
-						// send SKU delta to ledger service and get back current ledger information
-						resp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.LedgerService, outputBytes)
-						if err != nil {
-							lc.Errorf("Ledger service failed: %s", err.Error())
-							return false, err
-						}
-
-						lc.Info("Successfully updated the user's ledger")
-
-						var currentLedger Ledger
-						defer resp.Body.Close()
-						body, err := io.ReadAll(resp.Body)
-						if err != nil {
-							return false, fmt.Errorf("Failed to read response body: %s", err.Error())
-						}
-						err = json.Unmarshal(body, &currentLedger)
-						if err != nil {
-							return false, fmt.Errorf("Failed to unmarshal Ledger from response body: %s", err.Error())
-						}
-						// Display Ledger Total on LCD
-						if displayErr := vendingState.displayLedger(lc, vendingState.Configuration.ControllerBoardDeviceName, currentLedger); displayErr != nil {
-							return false, displayErr
-						}
-
-					}
-
-					// POST the deltaLedger json string to the inventory endpoint
-					outputBytes, err := json.Marshal(deltaLedger.DeltaSKUs)
-					if err != nil {
-						return false, fmt.Errorf("HandleMqttDeviceReading failed to marshal deltaLedger.DeltaSKUs")
-					}
-
-					lc.Info("Sending SKU delta to inventory service")
-					inventoryResp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.InventoryService, outputBytes)
+					// a rejected payload is quarantined, and the vend is left to
+					// time out as not verified rather than being billed wrongly
+					skuDelta, err := vendingState.readInference(lc, eventReading.Value)
 					if err != nil {
+						lc.Errorf("HandleMqttDeviceReading rejected the inference payload %s: %v", eventReading.Value, err)
+						lc.Error("Inference Failed")
 						return false, err
 					}
-					defer inventoryResp.Body.Close()
-					// Post an audit log entry for this transaction, regardless of ledger or not
-					auditLogEntry := AuditLogEntry{
-						AccountID:      vendingState.CurrentUserData.AccountID,
-						CardID:         vendingState.CurrentUserData.CardID,
-						RoleID:         vendingState.CurrentUserData.RoleID,
-						PersonID:       vendingState.CurrentUserData.PersonID,
-						InventoryDelta: deltaLedger.DeltaSKUs,
-						CreatedAt:      time.Now().UnixNano(),
-					}
 
-					outputBytes, err = json.Marshal(auditLogEntry)
-					if err != nil {
-						return false, err
+					vendingState.Transition(lc, StateSettling, "inferenceReceived")
+					if !vendingState.DoorClosedAt.IsZero() {
+						vendingState.SLA.Record(lc, SLAStageInference, time.Since(vendingState.DoorClosedAt), vendingState.CurrentUserData)
+						vendingState.DoorClosedAt = time.Time{}
 					}
-
-					lc.Info("Sending audit log entry to inventory service")
-					auditResp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.InventoryAuditLogService, outputBytes)
-					if err != nil {
+					if err := vendingState.completeVend(lc, skuDelta); err != nil {
 						return false, err
 					}
-					defer auditResp.Body.Close()
-					vendingState.CurrentUserData = OutputData{}
-					vendingState.CVWorkflowStarted = false
-					lc.Info("Inference complete and workflow status reset")
-					// Close all thread to ensure all threads are cleaned up before the next card is scanned.
-					close(vendingState.ThreadStopChannel)
-					vendingState.ThreadStopChannel = make(chan int)
 				}
+			case QRCodeResource:
+				// a QR code decoded by the kiosk camera stands in for a card
+				return vendingState.VerifyQRCode(lc, eventReading)
 			default:
 				{
 					lc.Info("Received an event with an unknown name")
@@ -181,32 +146,299 @@ func (vendingState *VendingState) HandleMqttDeviceReading(lc logger.LoggingClien
 	return false, nil
 }
 
+// completeVend charges the SKU delta of the vend once it is settling, or
+// adds it to the basket of the session when the customer may reopen the door
+func (vendingState *VendingState) completeVend(lc logger.LoggingClient, skuDelta []deltaSKU) error {
+	// Stop the inference wait thread since the SKU delta was received
+	close(vendingState.InferenceWaitThreadStopChannel)
+	vendingState.InferenceWaitThreadStopChannel = make(chan int)
+
+	// the basket is recorded before it is charged, so that it is
+	// charged by the ledger service if this service fails first
+	if vendingState.SessionLinger > 0 {
+		vendingState.recordBasketIntent(lc, mergeSKUDeltas(vendingState.SessionBasket, skuDelta))
+	} else {
+		vendingState.recordBasketIntent(lc, skuDelta)
+	}
+
+	// the customer may reopen the door, so the basket is charged when the session ends
+	if vendingState.SessionLinger > 0 {
+		vendingState.lingerSession(lc, skuDelta)
+		return nil
+	}
+
+	if err := vendingState.settleBasket(lc, skuDelta); err != nil {
+		return err
+	}
+	vendingState.notifyWebhooks(lc, WebhookSessionCompleted, "basketSettled", "")
+	vendingState.CurrentUserData = OutputData{}
+	vendingState.SplitPayers = nil
+	vendingState.SessionID = ""
+	vendingState.Metrics.SetActiveSessions(0)
+	vendingState.finishVend(lc, "basketSettled")
+	lc.Info("Inference complete and workflow status reset")
+	// Close all thread to ensure all threads are cleaned up before the next card is scanned.
+	close(vendingState.ThreadStopChannel)
+	vendingState.ThreadStopChannel = make(chan int)
+	return nil
+}
+
+// settleBasket charges the SKU delta to the customer's ledger, or splits it
+// between the payers, and records it in inventory and the audit log
+func (vendingState *VendingState) settleBasket(lc logger.LoggingClient, skuDelta []deltaSKU) error {
+	// do some things with the skuDelta
+	// example:
+	// [{"SKU": "HXI86WHU", "delta": -2}]
+	// items entered by hand, low confidence inference results and baskets
+	// that fail the sanity checks are reviewed before they are charged
+	flagReason := vendingState.basketFlagReason(lc, skuDelta)
+	if flagReason != "" {
+		lc.Warnf("The basket of account %d, session %s is flagged for review: %s", vendingState.CurrentUserData.AccountID, vendingState.SessionID, flagReason)
+	}
+
+	if vendingState.CurrentUserData.RoleID == 1 && len(vendingState.SplitPayers) > 0 {
+		if err := vendingState.splitBasket(lc, skuDelta, flagReason); err != nil {
+			return err
+		}
+	} else if vendingState.CurrentUserData.RoleID == 1 || vendingState.CurrentUserData.RoleID == 4 {
+		// POST the deltaLedger json string to the ledger endpoint
+		deltaLedger := vendingState.chargeRequest(skuDelta, flagReason)
+		outputBytes, err := json.Marshal(deltaLedger)
+		if err != nil {
+			lc.Errorf("settleBasket failed to marshal deltaLedger: %v", err)
+			return err
+		}
+
+		lc.Info("Sending SKU delta to ledger service")
+		// send SKU delta to ledger service and get back current ledger information
+		chargeStart := time.Now()
+		resp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.LedgerService, outputBytes)
+		vendingState.recordCharge(lc, err, time.Since(chargeStart))
+		if err != nil {
+			lc.Errorf("Ledger service failed: %s", err.Error())
+			if undelivered(resp, err) {
+				// the basket is settled when the outbox is replayed
+				vendingState.queueSettlement(lc, skuDelta, flagReason)
+			}
+			return err
+		}
+
+		lc.Info("Successfully updated the user's ledger")
+
+		var currentLedger Ledger
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("Failed to read response body: %s", err.Error())
+		}
+		err = json.Unmarshal(body, &currentLedger)
+		if err != nil {
+			return fmt.Errorf("Failed to unmarshal Ledger from response body: %s", err.Error())
+		}
+		// Items taken outside of their availability windows are not charged by the
+		// ledger service, instead the transaction is flagged for review
+		if currentLedger.IsFlagged {
+			lc.Warnf("Ledger transaction %d for account %d was flagged: %v", currentLedger.TransactionID, deltaLedger.AccountID, currentLedger.FlagReasons)
+		}
+		// Display Ledger Total on LCD
+		if displayErr := vendingState.displayLedger(lc, vendingState.Configuration.ControllerBoardDeviceName, currentLedger); displayErr != nil {
+			return displayErr
+		}
+
+	}
+
+	// POST the inventory deltas json string to the inventory endpoint
+	inventoryDeltas := vendingState.inventoryDeltas(skuDelta)
+	// Post an audit log entry for this transaction, regardless of ledger or not
+	auditLogEntry := vendingState.auditLogEntry(inventoryDeltas)
+	outputBytes, err := json.Marshal(inventoryDeltas)
+	if err != nil {
+		return fmt.Errorf("settleBasket failed to marshal deltaLedger.DeltaSKUs")
+	}
+
+	lc.Info("Sending SKU delta to inventory service")
+	inventoryResp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.InventoryService, outputBytes)
+	if err != nil {
+		if undelivered(inventoryResp, err) {
+			vendingState.queueOutbox(lc, OutboxInventoryDelta, inventoryDeltas)
+			vendingState.queueOutbox(lc, OutboxAuditLog, auditLogEntry)
+		}
+		return err
+	}
+	defer inventoryResp.Body.Close()
+	logStockDiscrepancies(lc, inventoryResp.Body)
+
+	outputBytes, err = json.Marshal(auditLogEntry)
+	if err != nil {
+		return err
+	}
+
+	lc.Info("Sending audit log entry to inventory service")
+	auditResp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.InventoryAuditLogService, outputBytes)
+	if err != nil {
+		if undelivered(auditResp, err) {
+			vendingState.queueOutbox(lc, OutboxAuditLog, auditLogEntry)
+		}
+		return err
+	}
+	defer auditResp.Body.Close()
+	return nil
+}
+
+// chargeRequest is the request that charges the SKU delta to the customer's
+// ledger. Technicians validating the kiosk record a zero-priced test vend.
+func (vendingState *VendingState) chargeRequest(skuDelta []deltaSKU, flagReason string) deltaLedger {
+	return deltaLedger{
+		AccountID:  vendingState.CurrentUserData.AccountID,
+		DeltaSKUs:  skuDelta,
+		IsTest:     vendingState.CurrentUserData.RoleID == 4,
+		SessionID:  vendingState.SessionID,
+		FlagReason: flagReason,
+	}
+}
+
+// auditLogEntry is the audit log entry of the inventory deltas of the
+// current user
+func (vendingState *VendingState) auditLogEntry(inventoryDeltas []inventoryDelta) AuditLogEntry {
+	return AuditLogEntry{
+		AccountID:      vendingState.CurrentUserData.AccountID,
+		CardID:         vendingState.CurrentUserData.CardID,
+		RoleID:         vendingState.CurrentUserData.RoleID,
+		PersonID:       vendingState.CurrentUserData.PersonID,
+		InventoryDelta: inventoryDeltas,
+		CreatedAt:      time.Now().UnixNano(),
+	}
+}
+
+// recordBasketIntent records the basket of the session in the ledger
+// service before it is charged. The ledger service charges a basket that is
+// left uncharged, or escalates it to an operator. Only the baskets of
+// customers and technicians are charged, and the intent is a safeguard, so
+// a failure to record it does not stop the charge.
+func (vendingState *VendingState) recordBasketIntent(lc logger.LoggingClient, basket []deltaSKU) {
+	roleID := vendingState.CurrentUserData.RoleID
+	if vendingState.SessionID == "" || (roleID != 1 && roleID != 4) {
+		return
+	}
+	intent := basketIntent{
+		SessionID: vendingState.SessionID,
+		AccountID: vendingState.CurrentUserData.AccountID,
+		DeltaSKUs: basket,
+		IsTest:    roleID == 4,
+	}
+	if roleID == 1 && len(vendingState.SplitPayers) > 0 {
+		intent.AccountIDs = []int{intent.AccountID}
+		for _, payer := range vendingState.SplitPayers {
+			intent.AccountIDs = append(intent.AccountIDs, payer.AccountID)
+		}
+		intent.SplitRule = vendingState.Configuration.SplitBasketRule
+	}
+
+	outputBytes, err := json.Marshal(intent)
+	if err != nil {
+		lc.Errorf("Failed to marshal the basket intent of session %s: %s", intent.SessionID, err.Error())
+		return
+	}
+	resp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.LedgerService+"/intents", outputBytes)
+	if err != nil {
+		lc.Errorf("Failed to record the basket intent of session %s, charging it without one: %s", intent.SessionID, err.Error())
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return
+	}
+	resp.Body.Close()
+	lc.Debugf("Recorded the basket intent of session %s", intent.SessionID)
+}
+
+// logStockDiscrepancies warns about the applied inventory deltas that took
+// more units than were on hand, which means the inventory is out of step
+// with what is in the vending machine. The response is only informational,
+// so a response that cannot be read is ignored.
+func logStockDiscrepancies(lc logger.LoggingClient, body io.Reader) {
+	var results []inventoryDeltaResult
+	if err := json.NewDecoder(body).Decode(&results); err != nil {
+		lc.Debugf("Could not read the applied inventory deltas: %s", err.Error())
+		return
+	}
+	for _, result := range results {
+		if result.Discrepancy {
+			lc.Warnf("Stock discrepancy for SKU %s: a delta of %d was taken from %d units on hand, %d was applied leaving %d", result.SKU, result.Delta, result.UnitsOnHandBefore, result.AppliedDelta, result.UnitsOnHandAfter)
+		}
+	}
+}
+
+// inventoryDeltas attributes the SKU delta to the current user and session
+func (vendingState *VendingState) inventoryDeltas(skuDelta []deltaSKU) []inventoryDelta {
+	reason := inventoryDeltaReasonSale
+	switch vendingState.CurrentUserData.RoleID {
+	case 2:
+		reason = inventoryDeltaReasonRestock
+	case 4:
+		reason = inventoryDeltaReasonTest
+	}
+	source := deltaSource{
+		Service:   inventoryDeltaService,
+		User:      vendingState.CurrentUserData.CardID,
+		SessionID: vendingState.SessionID,
+	}
+
+	inventoryDeltas := []inventoryDelta{}
+	for _, item := range skuDelta {
+		inventoryDeltas = append(inventoryDeltas, inventoryDelta{SKU: item.SKU, Delta: item.Delta, Reason: reason, Source: source})
+	}
+	return inventoryDeltas
+}
+
 // VerifyDoorAccess will take the card reader events and verify the read card id against the allow list
 // If the card is valid the function will send the unlock message to the device-controller-board device service
 func (vendingState *VendingState) VerifyDoorAccess(lc logger.LoggingClient, event dtos.Event) (bool, interface{}) {
 
 	lc.Infof("new card scanned")
-	lc.Debugf("workflow: +%v", vendingState.CVWorkflowStarted)
+	lc.Debugf("workflow: %s", vendingState.Workflow.State())
 	lc.Debugf("maintenance mode: +%v", vendingState.MaintenanceMode)
-	lc.Debugf("open: +%v", vendingState.DoorOpenedDuringCVWorkflow)
-	lc.Debugf("closed: +%v", vendingState.DoorClosedDuringCVWorkflow)
-	lc.Debugf("Inference: +%v ", vendingState.InferenceDataReceived)
 	lc.Debugf("door: +%v", vendingState.DoorClosed)
 
-	if event.DeviceName == DsCardReader && !vendingState.CVWorkflowStarted {
+	// a second customer can share the basket until the door is opened
+	if event.DeviceName == vendingState.cardReaderName() && vendingState.Workflow.State() == StateAuthorized &&
+		vendingState.Configuration.SplitBasketRule != "" {
+		return vendingState.addSplitPayer(lc, event)
+	}
+
+	// the customer of a lingering session can reopen the door, any other card
+	// ends the session first
+	if event.DeviceName == vendingState.cardReaderName() && !vendingState.Workflow.Vending() && vendingState.SessionLingering {
+		if !vendingState.MaintenanceMode && len(event.Readings) > 0 && event.Readings[0].Value == vendingState.CurrentUserData.CardID {
+			return vendingState.resumeSession(lc, event)
+		}
+		if err := vendingState.EndSession(lc); err != nil {
+			lc.Errorf("Failed to end the session: %s", err.Error())
+		}
+	}
+
+	if event.DeviceName == vendingState.cardReaderName() && !vendingState.Workflow.Vending() {
 		lc.Info("Verify the card reader input against the allow list")
+		scannedAt := time.Now()
+		// a card scanned while another waits for its PIN takes its place
+		vendingState.PinEntry.Cancel(lc)
 
 		lc.Infof("Card Scanned")
-		lc.Debugf("workflow: +%v", vendingState.CVWorkflowStarted)
+		lc.Debugf("workflow: %s", vendingState.Workflow.State())
 		lc.Debugf("maintenance mode: +%v", vendingState.MaintenanceMode)
-		lc.Debugf("open: +%v", vendingState.DoorOpenedDuringCVWorkflow)
-		lc.Debugf("closed: +%v", vendingState.DoorClosedDuringCVWorkflow)
-		lc.Debugf("Inference: +%v ", vendingState.InferenceDataReceived)
 		lc.Debugf("door: +%v", vendingState.DoorClosed)
 
-		// check to see if inference is running and set maintenance mode accordingly
-		if !vendingState.MaintenanceMode {
-			vendingState.MaintenanceMode = !vendingState.checkInferenceStatus(lc, vendingState.Configuration.InferenceHeartbeatCmd, vendingState.Configuration.InferenceDeviceName)
+		// check to see if inference is running and set maintenance mode accordingly,
+		// unless the vends go on with a fallback while it is not
+		inferenceAvailable := vendingState.checkInferenceStatus(lc, vendingState.Configuration.InferenceHeartbeatCmd, vendingState.Configuration.InferenceDeviceName)
+		vendingState.inferenceUnavailable = !inferenceAvailable
+		switch {
+		case inferenceAvailable:
+			vendingState.ClearMaintenanceReason(lc, ReasonInferenceUnavailable)
+		case vendingState.Configuration.InferenceFallback != "":
+			lc.Warnf("Inference is unavailable, vending with the %s fallback", vendingState.Configuration.InferenceFallback)
+			vendingState.ClearMaintenanceReason(lc, ReasonInferenceUnavailable)
+		default:
+			vendingState.SetMaintenanceReason(lc, ReasonInferenceUnavailable)
 		}
 
 		for _, eventReading := range event.Readings {
@@ -215,144 +447,391 @@ func (vendingState *VendingState) VerifyDoorAccess(lc logger.LoggingClient, even
 			}
 
 			// Retrieve & Hit auth endpoint
+			authStartedAt := time.Now()
 			vendingState.getCardAuthInfo(lc, vendingState.Configuration.AuthenticationEndpoint, eventReading.Value)
+			vendingState.SLA.Record(lc, SLAStageAuth, time.Since(authStartedAt), vendingState.CurrentUserData)
 
 			switch vendingState.CurrentUserData.RoleID {
-			// Check the role of the card scanned. Role 1 = customer and Role 2 = item stocker
-			case 1, 2:
+			// Check the role of the card scanned. Role 1 = customer, Role 2 = item stocker and Role 4 = technician
+			case 1, 2, 4:
 				{
-					if !vendingState.MaintenanceMode {
-						lc.Infof("%s readable value from %s is %s", eventReading.ResourceName, eventReading.DeviceName, eventReading.Value)
-						// display "hello" on row 2
-						settings := make(map[string]string)
-						settings["displayRow2"] = "hello"
-						err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
-						if err != nil {
+					// the authentication service decides whether a stocker may restock
+					if vendingState.CurrentUserData.RoleID == 2 && !vendingState.authorize(lc, eventReading.Value, ActionStock) {
+						vendingState.CurrentUserData = OutputData{}
+						if err := vendingState.displayUnauthorized(lc, eventReading.Value); err != nil {
 							return false, err
 						}
-
-						settings = make(map[string]string)
-						settings["displayRow3"] = eventReading.Value
-						// display the card number on row 3
-						err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow3Cmd, settings)
-						if err != nil {
+						break
+					}
+					if vendingState.MaintenanceMode {
+						// display why the vending machine is out of service
+						vendingState.displayMaintenance(lc)
+						break
+					}
+					lc.Infof("%s readable value from %s is %s", eventReading.ResourceName, eventReading.DeviceName, eventReading.Value)
+					// the door is unlocked once the PIN of a card that needs one is entered
+					if vendingState.CurrentUserData.PinRequired {
+						if err := vendingState.awaitPin(lc, scannedAt); err != nil {
 							return false, err
 						}
-
-						settings = make(map[string]string)
-						settings["lock1"] = "true"
-						// unlock
-						err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardLock1Cmd, settings)
-						if err != nil {
+						break
+					}
+					if err := vendingState.openDoor(lc, eventReading.Value, scannedAt); err != nil {
+						return false, err
+					}
+				}
+			// Check the role of the card scanned. Role 3 = maintainer and Role 5 = admin
+			case 3, 5:
+				{
+					// the authentication service decides whether the card may enter maintenance mode
+					if !vendingState.authorize(lc, eventReading.Value, ActionMaintain) {
+						vendingState.CurrentUserData = OutputData{}
+						if err := vendingState.displayUnauthorized(lc, eventReading.Value); err != nil {
 							return false, err
 						}
-
-						// Start the workflow state and set all of the thread states to false
-						vendingState.CVWorkflowStarted = true
-						vendingState.DoorClosedDuringCVWorkflow = false
-						vendingState.DoorOpenedDuringCVWorkflow = false
-						vendingState.InferenceDataReceived = false
-
-						// Wait for the door open event to be received. If we don't receive the door open event within the timeout
-						// then leave the workflow state and remove all user data
-						go func() {
-							for {
-								select {
-								case <-time.After(vendingState.DoorOpenStateTimeout):
-									if !vendingState.DoorOpenedDuringCVWorkflow {
-										lc.Info("door wasn't opened so we reset")
-										vendingState.CVWorkflowStarted = false
-										vendingState.CurrentUserData = OutputData{}
-									}
-
-									lc.Infof("Card Scan")
-									lc.Debugf("workflow: +%v", vendingState.CVWorkflowStarted)
-									lc.Debugf("maintenance mode: +%v", vendingState.MaintenanceMode)
-									lc.Debugf("open: +%v", vendingState.DoorOpenedDuringCVWorkflow)
-									lc.Debugf("closed: +%v", vendingState.DoorClosedDuringCVWorkflow)
-									lc.Debugf("Inference: +%v ", vendingState.InferenceDataReceived)
-									lc.Debugf("door: +%v", vendingState.DoorClosed)
-									return
-
-								case <-vendingState.DoorOpenWaitThreadStopChannel:
-									lc.Info("Stopped the door open wait thread")
-									return
-
-								case <-vendingState.ThreadStopChannel:
-									lc.Info("Globally stopped the door open wait thread")
-									return
-								}
-							}
-						}()
-					} else {
-						settings := make(map[string]string)
-						settings["displayRow1"] = "Out of Order"
-						// display out of order when door waiting state is set to false
-						err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow1Cmd, settings)
-						if err != nil {
+						break
+					}
+					lc.Infof("%s readable value from %s is %s", eventReading.ResourceName, eventReading.DeviceName, eventReading.Value)
+					if vendingState.CurrentUserData.PinRequired {
+						if err := vendingState.awaitPin(lc, scannedAt); err != nil {
 							return false, err
 						}
+						break
+					}
+					if err := vendingState.maintenanceScan(lc, eventReading.Value, scannedAt); err != nil {
+						return false, err
 					}
-
 				}
-			// Check the role of the card scanned. Role 3 = maintainer
-			case 3:
-				{
-					close(vendingState.ThreadStopChannel)
-					vendingState.ThreadStopChannel = make(chan int)
+			default:
+				if err := vendingState.displayUnauthorized(lc, eventReading.Value); err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+	return true, event // Continues the functions pipeline execution with the current event
+}
 
-					lc.Infof("%s readable value from %s is %s", eventReading.ResourceName, eventReading.DeviceName, eventReading.Value)
+// openDoor unlocks the door for the customer, stocker or technician card
+// that was scanned at scannedAt, and starts the vend workflow. A customer
+// over their credit limit, or whose payment is not pre-authorized, is
+// declined instead.
+func (vendingState *VendingState) openDoor(lc logger.LoggingClient, cardID string, scannedAt time.Time) error {
+	// customers over their credit limit must pay their balance before the door is unlocked
+	if vendingState.CurrentUserData.RoleID == 1 {
+		if err := vendingState.checkCreditLimit(lc, vendingState.CurrentUserData); err != nil {
+			lc.Errorf("Credit limit check for account %d failed: %s", vendingState.CurrentUserData.AccountID, err.Error())
+			vendingState.CurrentUserData = OutputData{}
+			settings := make(map[string]string)
+			settings["displayRow2"] = "Card declined"
+			if errors.Is(err, errCreditLimitExceeded) {
+				settings["displayRow2"] = "Credit limit reached"
+			}
+			return vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+		}
+	}
+	// customers must have their payment pre-authorized before the door is unlocked
+	if vendingState.CurrentUserData.RoleID == 1 && vendingState.Configuration.PreAuthorizeCustomers {
+		if err := vendingState.preAuthorize(lc, vendingState.CurrentUserData.AccountID); err != nil {
+			lc.Errorf("Pre-authorization for account %d failed: %s", vendingState.CurrentUserData.AccountID, err.Error())
+			vendingState.CurrentUserData = OutputData{}
+			settings := make(map[string]string)
+			settings["displayRow2"] = "Card declined"
+			return vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+		}
+	}
+	// the vend goes on without inference when it is unavailable
+	vendingState.InferenceFallbackMode = ""
+	vendingState.reviewReasons = nil
+	if vendingState.inferenceUnavailable {
+		vendingState.InferenceFallbackMode = vendingState.Configuration.InferenceFallback
+	}
 
-					// display text "Maintenance Mode" in row 2
-					settings := make(map[string]string)
-					settings["displayRow2"] = "Maintenance Mode"
-					err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
-					if err != nil {
-						return false, err
-					}
+	// display "hello" on row 2, or that the camera is offline
+	settings := make(map[string]string)
+	settings["displayRow2"] = "hello"
+	if vendingState.InferenceFallbackMode != "" {
+		settings["displayRow2"] = "Camera offline"
+	}
+	err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+	if err != nil {
+		return err
+	}
 
-					// display any reading value in row 3
-					settings = make(map[string]string)
-					settings["displayRow3"] = eventReading.Value
-					err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow3Cmd, settings)
-					if err != nil {
-						return false, err
-					}
+	settings = make(map[string]string)
+	settings["displayRow3"] = cardID
+	// display the card number on row 3
+	err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow3Cmd, settings)
+	if err != nil {
+		return err
+	}
 
-					// send lock command
-					settings = make(map[string]string)
-					settings["lock1"] = "true"
-					err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardLock1Cmd, settings)
-					if err != nil {
-						return false, err
+	settings = make(map[string]string)
+	settings["lock1"] = "true"
+	// unlock
+	err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardLock1Cmd, settings)
+	if err != nil {
+		return err
+	}
+	vendingState.SLA.Record(lc, SLAStageUnlock, time.Since(scannedAt), vendingState.CurrentUserData)
+
+	// Start the workflow
+	vendingState.Transition(lc, StateAuthorized, "cardAuthorized")
+	vendingState.SessionID = uuid.New().String()
+	vendingState.Metrics.SetActiveSessions(1)
+	vendingState.SplitPayers = nil
+	vendingState.notifyWebhooks(lc, WebhookDoorUnlocked, "cardAuthorized", "")
+
+	vendingState.waitForDoorOpen(lc)
+	return nil
+}
+
+// maintenanceScan takes the vending machine out of maintenance mode for the
+// maintainer or admin card that was scanned at scannedAt, and unlocks the
+// door
+func (vendingState *VendingState) maintenanceScan(lc logger.LoggingClient, cardID string, scannedAt time.Time) error {
+	close(vendingState.ThreadStopChannel)
+	vendingState.ThreadStopChannel = make(chan int)
+	vendingState.ClearMaintenance(lc)
+
+	// display text "Maintenance Mode" in row 2
+	settings := make(map[string]string)
+	settings["displayRow2"] = "Maintenance Mode"
+	err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+	if err != nil {
+		return err
+	}
+
+	// display any reading value in row 3
+	settings = make(map[string]string)
+	settings["displayRow3"] = cardID
+	err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow3Cmd, settings)
+	if err != nil {
+		return err
+	}
+
+	// send lock command
+	settings = make(map[string]string)
+	settings["lock1"] = "true"
+	err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardLock1Cmd, settings)
+	if err != nil {
+		return err
+	}
+	vendingState.SLA.Record(lc, SLAStageUnlock, time.Since(scannedAt), vendingState.CurrentUserData)
+
+	lc.Infof("Maintenance Scan")
+	lc.Debugf("workflow: %s", vendingState.Workflow.State())
+	lc.Debugf("maintenance mode: +%v", vendingState.MaintenanceMode)
+	lc.Debugf("door: +%v", vendingState.DoorClosed)
+	return nil
+}
+
+// waitForDoorOpen waits for the door open event to be received. If we don't
+// receive the door open event within the timeout then leave the workflow
+// state and remove all user data
+func (vendingState *VendingState) waitForDoorOpen(lc logger.LoggingClient) {
+	// the timeout is read once, so that an update does not move a stage that
+	// is already waiting
+	vendingState.awaitDoorOpen(lc, time.Now().Add(vendingState.Timeouts.Durations().DoorOpen))
+}
+
+// awaitDoorOpen waits until the deadline for the door open event
+func (vendingState *VendingState) awaitDoorOpen(lc logger.LoggingClient, deadline time.Time) {
+	vendingState.StageDeadline = deadline
+	vendingState.journalSession(lc)
+	stopChannel, threadStopChannel := vendingState.DoorOpenWaitThreadStopChannel, vendingState.ThreadStopChannel
+	go func() {
+		for {
+			select {
+			case <-time.After(time.Until(deadline)):
+				vendingState.LockSession()
+				defer vendingState.UnlockSession()
+				if stopped(stopChannel, threadStopChannel) {
+					lc.Info("Stopped the door open wait thread")
+					return
+				}
+				if vendingState.TransitionFrom(lc, StateAuthorized, vendingState.restingState(), "doorOpenTimeout") {
+					lc.Info("door wasn't opened so we reset")
+					vendingState.notifyWebhooks(lc, WebhookTimeout, "doorOpenTimeout", "")
+					if vendingState.SessionBasket != nil {
+						// the customer did not take anything else, so charge what they took before
+						if err := vendingState.EndSession(lc); err != nil {
+							lc.Errorf("Failed to end the session: %s", err.Error())
+						}
+					} else {
+						if vendingState.CurrentUserData.RoleID == 1 && vendingState.Configuration.PreAuthorizeCustomers {
+							vendingState.releaseHold(lc, vendingState.CurrentUserData.AccountID)
+						}
+						vendingState.CurrentUserData = OutputData{}
+						vendingState.SplitPayers = nil
 					}
+				}
+
+				lc.Infof("Card Scan")
+				lc.Debugf("workflow: %s", vendingState.Workflow.State())
+				lc.Debugf("maintenance mode: +%v", vendingState.MaintenanceMode)
+				lc.Debugf("door: +%v", vendingState.DoorClosed)
+				return
+
+			case <-stopChannel:
+				lc.Info("Stopped the door open wait thread")
+				return
+
+			case <-threadStopChannel:
+				lc.Info("Globally stopped the door open wait thread")
+				return
+			}
+		}
+	}()
+}
+
+// WaitForDoorClose waits for the door closed event once the door was opened
+// during a vend. If the door isn't closed within the timeout then leave the
+// workflow, remove the user data, and enter maintenance mode.
+func (vendingState *VendingState) WaitForDoorClose(lc logger.LoggingClient) {
+	vendingState.awaitDoorClose(lc, time.Now().Add(vendingState.Timeouts.Durations().DoorClose))
+}
 
-					vendingState.MaintenanceMode = false
-					vendingState.CVWorkflowStarted = false
-					vendingState.DoorClosedDuringCVWorkflow = false
-					vendingState.DoorOpenedDuringCVWorkflow = false
-					vendingState.InferenceDataReceived = false
-					lc.Infof("Maintenance Scan")
-					lc.Debugf("workflow: +%v", vendingState.CVWorkflowStarted)
-					lc.Debugf("maintenance mode: +%v", vendingState.MaintenanceMode)
-					lc.Debugf("open: +%v", vendingState.DoorOpenedDuringCVWorkflow)
-					lc.Debugf("closed: +%v", vendingState.DoorClosedDuringCVWorkflow)
-					lc.Debugf("Inference: +%v ", vendingState.InferenceDataReceived)
-					lc.Debugf("door: +%v", vendingState.DoorClosed)
+// awaitDoorClose waits until the deadline for the door closed event
+func (vendingState *VendingState) awaitDoorClose(lc logger.LoggingClient, deadline time.Time) {
+	vendingState.StageDeadline = deadline
+	vendingState.journalSession(lc)
+	stopChannel, threadStopChannel := vendingState.DoorCloseWaitThreadStopChannel, vendingState.ThreadStopChannel
+	go func() {
+		timeout := time.Until(deadline)
+		lc.Infof("Door Opened: wait for %v seconds", timeout)
+		for {
+			select {
+			case <-time.After(timeout):
+				{
+					vendingState.LockSession()
+					defer vendingState.UnlockSession()
+					if stopped(stopChannel, threadStopChannel) {
+						lc.Info("Stopped the door closed wait thread")
+						return
+					}
+					if vendingState.TransitionFrom(lc, StateDoorOpen, StateIdle, "doorCloseTimeout") {
+						lc.Error("Door Opened: Failed")
+						vendingState.notifyWebhooks(lc, WebhookTimeout, "doorCloseTimeout", "")
+						// the items taken during earlier visits of a session are still charged
+						if err := vendingState.EndSession(lc); err != nil {
+							lc.Errorf("Failed to end the session: %s", err.Error())
+						}
+						vendingState.SetMaintenanceReason(lc, ReasonDoorLeftOpen)
+					}
+					return
 				}
-			default:
-				// display "Unauthorized" on display row 2
-				settings := make(map[string]string)
-				settings["displayRow2"] = "Unauthorized"
-				err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
-				if err != nil {
-					return false, err
+			case <-stopChannel:
+				lc.Info("Stopped the door closed wait thread")
+				return
+
+			case <-threadStopChannel:
+				lc.Info("Globally stopped the door closed wait thread")
+				return
+			}
+		}
+	}()
+}
+
+// WaitForInference waits for the inference data once the door was closed
+// during a vend. If we don't receive any inference data within the timeout,
+// nor after triggering the inference again as the retry policy allows, then
+// leave the workflow, remove the user data, and enter maintenance mode.
+// Without inference, the vend is billed later, or waits for its items to be
+// entered instead.
+func (vendingState *VendingState) WaitForInference(lc logger.LoggingClient) {
+	vendingState.DoorClosedAt = time.Now()
+	vendingState.InferenceRetries = 0
+	switch vendingState.InferenceFallbackMode {
+	case config.InferenceFallbackBillLater:
+		vendingState.billLater(lc, "billedLater")
+		return
+	case config.InferenceFallbackManualEntry:
+		vendingState.displayFallbackPrompt(lc)
+	}
+	vendingState.awaitInference(lc, vendingState.DoorClosedAt.Add(vendingState.Timeouts.Durations().Inference))
+}
+
+// awaitInference waits until the deadline for the inference data. The
+// retries of the inference are waited for by the same thread.
+func (vendingState *VendingState) awaitInference(lc logger.LoggingClient, deadline time.Time) {
+	vendingState.StageDeadline = deadline
+	vendingState.journalSession(lc)
+	stopChannel, threadStopChannel := vendingState.InferenceWaitThreadStopChannel, vendingState.ThreadStopChannel
+	go func() {
+		for {
+			lc.Infof("Door Closed: wait for %v seconds", time.Until(deadline))
+			select {
+			case <-time.After(time.Until(deadline)):
+				retryDeadline, ok := vendingState.inferenceTimeout(lc, stopChannel, threadStopChannel)
+				if !ok {
+					return
 				}
-				lc.Infof("Invalid card: %s", eventReading.Value)
+				deadline = retryDeadline
+
+			case <-stopChannel:
+				lc.Info("Stopped the inference wait thread")
+				return
+
+			case <-threadStopChannel:
+				lc.Info("Globally stopped the inference wait thread")
+				return
 			}
 		}
+	}()
+}
+
+// inferenceTimeout handles the inference data not arriving by the stage
+// deadline, while holding the session lock, and returns the deadline of the
+// retry it triggered, or false once the wait is over
+func (vendingState *VendingState) inferenceTimeout(lc logger.LoggingClient, stopChannels ...chan int) (time.Time, bool) {
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	if stopped(stopChannels...) {
+		lc.Info("Stopped the inference wait thread")
+		return time.Time{}, false
+	}
+	if vendingState.InferenceFallbackMode != "" {
+		// the items were not entered in time, so they are billed later
+		vendingState.billLater(lc, "manualEntryTimeout")
+		return time.Time{}, false
+	}
+	if vendingState.Workflow.State() == StateInferring {
+		if wait, ok := vendingState.retryInference(lc); ok {
+			vendingState.StageDeadline = time.Now().Add(wait)
+			vendingState.journalSession(lc)
+			return vendingState.StageDeadline, true
+		}
 	}
-	return true, event // Continues the functions pipeline execution with the current event
+	if vendingState.TransitionFrom(lc, StateInferring, StateIdle, "inferenceTimeout") {
+		lc.Error("Door Closed: Failed")
+		vendingState.notifyWebhooks(lc, WebhookTimeout, "inferenceTimeout", "")
+		// the inference result never arrived, which breaches its SLA; the
+		// inference timeout is measured from when the door was closed
+		vendingState.SLA.Record(lc, SLAStageInference, vendingState.StageDeadline.Sub(vendingState.DoorClosedAt), vendingState.CurrentUserData)
+		vendingState.DoorClosedAt = time.Time{}
+		// the items taken during earlier visits of a session are still charged
+		if err := vendingState.EndSession(lc); err != nil {
+			lc.Errorf("Failed to end the session: %s", err.Error())
+		}
+		vendingState.SetMaintenanceReason(lc, ReasonInferenceTimeout)
+	}
+	return time.Time{}, false
+}
+
+// stopped returns whether any of the stop channels of a wait thread was
+// closed. A wait thread keeps the stop channels it was started with, as they
+// are replaced once closed, and checks them again once it holds the session
+// lock, since it may have been stopped while it waited for it.
+func stopped(stopChannels ...chan int) bool {
+	for _, stopChannel := range stopChannels {
+		select {
+		case <-stopChannel:
+			return true
+		default:
+		}
+	}
+	return false
 }
 
 func (vendingState *VendingState) checkInferenceStatus(lc logger.LoggingClient, heartbeatEndPoint string, deviceName string) bool {
@@ -370,28 +849,287 @@ func (vendingState *VendingState) getCardAuthInfo(lc logger.LoggingClient, authE
 	// First, reset it, then populate it at the end of the function
 	vendingState.CurrentUserData = OutputData{}
 
+	auth, ok := vendingState.cardAuthInfo(lc, authEndpoint, cardID)
+	if !ok {
+		return
+	}
+
+	// Set the door waiting state to false while processing a use
+	vendingState.CurrentUserData = auth
+}
+
+// displayUnauthorized displays "Unauthorized" on display row 2 for a card
+// that may not open the door
+func (vendingState *VendingState) displayUnauthorized(lc logger.LoggingClient, cardID string) error {
+	settings := make(map[string]string)
+	settings["displayRow2"] = "Unauthorized"
+	err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+	if err != nil {
+		return err
+	}
+	lc.Infof("Invalid card: %s", cardID)
+	return nil
+}
+
+// authorize asks the authentication service whether the card is allowed the
+// action, which is allowed by the card's role alone without an
+// AuthorizationEndpoint. A card that could not be checked is not allowed.
+func (vendingState *VendingState) authorize(lc logger.LoggingClient, cardID string, action string) bool {
+	if vendingState.Configuration.AuthorizationEndpoint == "" {
+		return true
+	}
+	resp, err := sendHTTPRequest(lc, http.MethodGet, vendingState.Configuration.AuthorizationEndpoint+"/"+cardID+"/"+action, []byte(""))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		lc.Errorf("Failed to authorize card %s to %s: %s", cardID, action, err.Error())
+		return false
+	}
+
+	var authorization cardAuthorization
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		lc.Errorf("Failed to read response body from Authorization for card ID %s: %s", cardID, err.Error())
+		return false
+	}
+	if err = json.Unmarshal(body, &authorization); err != nil {
+		lc.Errorf("Could not unmarshal from AuthorizationEndpoint for card ID %s: %s", cardID, err.Error())
+		return false
+	}
+	if !authorization.Allowed {
+		lc.Infof("Card %s is not allowed to %s", cardID, action)
+	}
+	return authorization.Allowed
+}
+
+// lookupCardAuthInfo retrieves the authentication information for a card,
+// returning false when the card could not be authenticated
+func lookupCardAuthInfo(lc logger.LoggingClient, authEndpoint string, cardID string) (OutputData, bool) {
 	resp, err := sendHTTPRequest(lc, http.MethodGet, authEndpoint+"/"+cardID, []byte(""))
 	if err != nil {
 		lc.Infof("Unauthorized card: %s", cardID)
-		return
+		return OutputData{}, false
 	}
 
-	var auth OutputData
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		lc.Errorf("Failed to read response body from Authentication for card ID %s: %s", cardID, err.Error())
-		return
+		return OutputData{}, false
 	}
-	err = json.Unmarshal(body, &auth)
+	auth, err := unmarshalAuthResponse(body)
 	if err != nil {
 		lc.Errorf("Could not unmarshal from AuthenticationEndpoint for card ID %s: %s", cardID, err.Error())
-		return
+		return OutputData{}, false
 	}
 
-	// Set the door waiting state to false while processing a use
-	vendingState.CurrentUserData = auth
 	lc.Info("Successfully found user data for card " + cardID)
+	return auth, true
+}
+
+// unmarshalAuthResponse returns the authentication information of a card
+// in a response of the authentication service, with the card's token
+func unmarshalAuthResponse(body []byte) (OutputData, error) {
+	var response authResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return OutputData{}, err
+	}
+	response.OutputData.Token = response.Token
+	return response.OutputData, nil
+}
+
+// preAuthorize asks the ledger service to place a hold on the account's
+// stored payment method, for the kiosk's PreAuthHoldAmount when it is set.
+// The ledger service decides whether the account needs a hold, and an error
+// is returned when the hold was not placed.
+func (vendingState *VendingState) preAuthorize(lc logger.LoggingClient, accountID int) error {
+	body := []byte("")
+	if vendingState.Configuration.PreAuthHoldAmount > 0 {
+		var err error
+		body, err = json.Marshal(preAuthRequest{Amount: vendingState.Configuration.PreAuthHoldAmount})
+		if err != nil {
+			return fmt.Errorf("failed to marshal the pre-authorization: %s", err.Error())
+		}
+	}
+	resp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.LedgerService+"/"+strconv.Itoa(accountID)+"/preauth", body)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	lc.Infof("Pre-authorized account %d", accountID)
+	return nil
+}
+
+// errCreditLimitExceeded is returned when an account's unpaid balance is
+// over its credit limit
+var errCreditLimitExceeded = errors.New("credit limit exceeded")
+
+// checkCreditLimit asks the ledger service for the account's unpaid balance
+// with the card's token, and returns an error when it is over the account's
+// credit limit, or when the balance could not be checked. Accounts without
+// a limit are not checked.
+func (vendingState *VendingState) checkCreditLimit(lc logger.LoggingClient, auth OutputData) error {
+	if auth.CreditLimit <= 0 {
+		return nil
+	}
+	request, err := http.NewRequest(http.MethodGet, vendingState.Configuration.LedgerService+"/"+strconv.Itoa(auth.AccountID)+"/balance", nil)
+	if err != nil {
+		return err
+	}
+	if auth.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+auth.Token)
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending command: %v", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error sending command: received status code: %v", resp.Status)
+	}
+
+	var balance accountBalance
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	if err = json.Unmarshal(body, &balance); err != nil {
+		return fmt.Errorf("failed to unmarshal account balance from response body: %s", err.Error())
+	}
+	if balance.UnpaidBalance > auth.CreditLimit {
+		return fmt.Errorf("%w: unpaid balance %.2f is over the limit of %.2f", errCreditLimitExceeded, balance.UnpaidBalance, auth.CreditLimit)
+	}
+	return nil
+}
+
+// releaseHold asks the ledger service to release the account's hold when
+// the door was unlocked but never opened
+func (vendingState *VendingState) releaseHold(lc logger.LoggingClient, accountID int) {
+	resp, err := sendHTTPRequest(lc, http.MethodDelete, vendingState.Configuration.LedgerService+"/"+strconv.Itoa(accountID)+"/preauth", []byte(""))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		lc.Errorf("Failed to release the hold for account %d: %s", accountID, err.Error())
+		return
+	}
+	lc.Infof("Released the hold for account %d", accountID)
+}
+
+// addSplitPayer adds the customer of a card scanned after the door was
+// unlocked, but before it was opened, to the payers sharing the basket
+func (vendingState *VendingState) addSplitPayer(lc logger.LoggingClient, event dtos.Event) (bool, interface{}) {
+	for _, eventReading := range event.Readings {
+		if len(eventReading.Value) < 1 {
+			return false, fmt.Errorf("event reading was empty, devicename: %s, resourcename: %s", eventReading.DeviceName, eventReading.ResourceName)
+		}
+
+		displayRow2 := "Split declined"
+		auth, ok := vendingState.cardAuthInfo(lc, vendingState.Configuration.AuthenticationEndpoint, eventReading.Value)
+		switch {
+		case !ok || auth.RoleID != 1:
+			lc.Infof("Card %s cannot share the basket, only customers can split a basket", eventReading.Value)
+		case vendingState.CurrentUserData.RoleID != 1:
+			lc.Infof("Card %s cannot share the basket, the door was not opened by a customer", eventReading.Value)
+		case vendingState.isPayer(auth.AccountID):
+			lc.Infof("Account %d is already paying for the basket", auth.AccountID)
+		default:
+			if err := vendingState.checkCreditLimit(lc, auth); err != nil {
+				lc.Infof("Account %d cannot share the basket: %s", auth.AccountID, err.Error())
+				break
+			}
+			vendingState.SplitPayers = append(vendingState.SplitPayers, auth)
+			displayRow2 = fmt.Sprintf("Split: %d payers", len(vendingState.SplitPayers)+1)
+			lc.Infof("Account %d added to the basket of account %d", auth.AccountID, vendingState.CurrentUserData.AccountID)
+		}
+
+		settings := make(map[string]string)
+		settings["displayRow2"] = displayRow2
+		err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, event
+}
+
+// isPayer checks whether the account is already paying for the basket
+func (vendingState *VendingState) isPayer(accountID int) bool {
+	if vendingState.CurrentUserData.AccountID == accountID {
+		return true
+	}
+	for _, payer := range vendingState.SplitPayers {
+		if payer.AccountID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// splitBasket sends the SKU delta to the ledger service to be split between
+// the customer that opened the door and the split payers, and displays
+// each payer's share
+func (vendingState *VendingState) splitBasket(lc logger.LoggingClient, skuDelta []deltaSKU, flagReason string) error {
+	splitLedger := vendingState.splitRequest(skuDelta, flagReason)
+	outputBytes, err := json.Marshal(splitLedger)
+	if err != nil {
+		return fmt.Errorf("HandleMqttDeviceReading failed to marshal splitLedger: %v", err)
+	}
+
+	lc.Infof("Sending SKU delta to ledger service to split between accounts %v", splitLedger.AccountIDs)
+	chargeStart := time.Now()
+	resp, err := sendHTTPRequest(lc, http.MethodPost, vendingState.Configuration.LedgerService+"/split", outputBytes)
+	vendingState.recordCharge(lc, err, time.Since(chargeStart))
+	if err != nil {
+		if undelivered(resp, err) {
+			// the basket is settled when the outbox is replayed
+			vendingState.queueSettlement(lc, skuDelta, flagReason)
+		}
+		return fmt.Errorf("Ledger service failed: %s", err.Error())
+	}
+
+	var splitLedgers []Ledger
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to read response body: %s", err.Error())
+	}
+	err = json.Unmarshal(body, &splitLedgers)
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal Ledgers from response body: %s", err.Error())
+	}
+
+	lc.Info("Successfully split the basket between the users' ledgers")
+	for i, currentLedger := range splitLedgers {
+		if currentLedger.IsFlagged && i < len(splitLedger.AccountIDs) {
+			lc.Warnf("Ledger transaction %d for account %d was flagged: %v", currentLedger.TransactionID, splitLedger.AccountIDs[i], currentLedger.FlagReasons)
+		}
+		// Display each payer's Ledger Total on LCD
+		if displayErr := vendingState.displayLedger(lc, vendingState.Configuration.ControllerBoardDeviceName, currentLedger); displayErr != nil {
+			return displayErr
+		}
+	}
+	return nil
+}
+
+// splitRequest is the request that splits the SKU delta between the
+// customer that opened the door and the split payers
+func (vendingState *VendingState) splitRequest(skuDelta []deltaSKU, flagReason string) splitLedger {
+	splitLedger := splitLedger{
+		AccountIDs: []int{vendingState.CurrentUserData.AccountID},
+		Rule:       vendingState.Configuration.SplitBasketRule,
+		DeltaSKUs:  skuDelta,
+		SessionID:  vendingState.SessionID,
+		FlagReason: flagReason,
+	}
+	for _, payer := range vendingState.SplitPayers {
+		splitLedger.AccountIDs = append(splitLedger.AccountIDs, payer.AccountID)
+	}
+	return splitLedger
 }
 
 func (vendingState *VendingState) displayLedger(lc logger.LoggingClient, deviceName string, ledger Ledger) error {
@@ -426,8 +1164,12 @@ func (vendingState *VendingState) displayLedger(lc logger.LoggingClient, deviceN
 		return fmt.Errorf("sendCommand returned nil for %v : %v", vendingState.Configuration.ControllerBoardDisplayResetCmd, err.Error())
 	}
 
-	//display ledger.LineTotal from in currency format
-	displayLedgerTotal := "Total: $" + fmt.Sprintf("%3.2f", ledger.LineTotal)
+	//display ledger.LineTotal from in currency format, ledgers from older
+	//ledger services are not formatted and are in USD
+	displayLedgerTotal := "Total: " + ledger.DisplayTotal
+	if ledger.DisplayTotal == "" {
+		displayLedgerTotal = "Total: $" + fmt.Sprintf("%3.2f", ledger.LineTotal)
+	}
 	settings = make(map[string]string)
 	settings["displayRow1"] = displayLedgerTotal
 	err = vendingState.SendCommand(lc, http.MethodPut, deviceName, vendingState.Configuration.ControllerBoardDisplayRow1Cmd, settings)
@@ -435,6 +1177,26 @@ func (vendingState *VendingState) displayLedger(lc logger.LoggingClient, deviceN
 		return fmt.Errorf("sendCommand returned nil for %v : %v", vendingState.Configuration.ControllerBoardDisplayRow1Cmd, err.Error())
 	}
 
+	// let the technician know that the test vend was recorded and not charged
+	if ledger.IsTest {
+		settings = make(map[string]string)
+		settings["displayRow2"] = "Test vend"
+		err = vendingState.SendCommand(lc, http.MethodPut, deviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+		if err != nil {
+			return fmt.Errorf("sendCommand returned nil for %v : %v", vendingState.Configuration.ControllerBoardDisplayRow2Cmd, err.Error())
+		}
+	}
+
+	// let the customer know that part of the transaction was not charged
+	if ledger.IsFlagged {
+		settings = make(map[string]string)
+		settings["displayRow2"] = "Flagged for review"
+		err = vendingState.SendCommand(lc, http.MethodPut, deviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+		if err != nil {
+			return fmt.Errorf("sendCommand returned nil for %v : %v", vendingState.Configuration.ControllerBoardDisplayRow2Cmd, err.Error())
+		}
+	}
+
 	return nil
 }
 
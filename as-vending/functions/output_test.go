@@ -9,10 +9,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/require"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
@@ -57,6 +60,7 @@ func TestCheckInferenceStatus(t *testing.T) {
 			mockCommandClient.On("IssueGetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&resp, tc.GetCommandError)
 
 			vendingstate := VendingState{
+				Workflow:      NewWorkflow(StateIdle),
 				CommandClient: mockCommandClient,
 			}
 			assert.Equal(t, tc.Expected, vendingstate.checkInferenceStatus(logger.NewMockClient(), testServer.URL, "test-device"), "Expected value to match output")
@@ -76,7 +80,7 @@ func TestGetCardAuthInfo(t *testing.T) {
 		{"Internal error case", http.StatusInternalServerError, "1234567890", ""},
 	}
 
-	var vendingState VendingState
+	vendingState := VendingState{Workflow: NewWorkflow(StateIdle)}
 	for _, tc := range testCases {
 
 		t.Run(tc.TestCaseName, func(t *testing.T) {
@@ -111,6 +115,7 @@ func TestDisplayLedger(t *testing.T) {
 	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(resp, nil)
 
 	vendingState := VendingState{
+		Workflow: NewWorkflow(StateIdle),
 		Configuration: &config.VendingConfig{
 			LCDRowLength:                   20,
 			ControllerBoardDisplayResetCmd: "displayReset",
@@ -124,6 +129,17 @@ func TestDisplayLedger(t *testing.T) {
 	}
 	err := vendingState.displayLedger(logger.NewMockClient(), "test-device", ledger)
 	assert.NoError(t, err)
+
+	// flagged ledgers should let the customer know on row 2
+	vendingState.Configuration.ControllerBoardDisplayRow2Cmd = "displayRow2"
+	err = vendingState.displayLedger(logger.NewMockClient(), "test-device", Ledger{IsFlagged: true})
+	assert.NoError(t, err)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "test-device", "displayRow2", map[string]string{"displayRow2": "Flagged for review"})
+
+	// the ledger's formatted total is used when it is provided
+	err = vendingState.displayLedger(logger.NewMockClient(), "test-device", Ledger{LineTotal: 3, DisplayTotal: "€3.00"})
+	assert.NoError(t, err)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "test-device", "diplayrow1", map[string]string{"displayRow1": "Total: €3.00"})
 }
 
 func TestHandleMqttDeviceReading(t *testing.T) {
@@ -198,6 +214,7 @@ func TestHandleMqttDeviceReading(t *testing.T) {
 			stopChannel := make(chan int)
 
 			vendingState := VendingState{
+				Workflow:                       NewWorkflow(StateIdle),
 				InferenceWaitThreadStopChannel: inferenceStopChannel,
 				ThreadStopChannel:              stopChannel,
 				CurrentUserData:                OutputData{RoleID: 1},
@@ -298,7 +315,7 @@ func TestVerifyDoorAccess(t *testing.T) {
 				InferenceWaitThreadStopChannel: inferenceStopChannel,
 				ThreadStopChannel:              stopChannel,
 				CurrentUserData:                OutputData{RoleID: 1},
-				CVWorkflowStarted:              false,
+				Workflow:                       NewWorkflow(StateIdle),
 				MaintenanceMode:                tc.MaintenanceMode,
 				Configuration: &config.VendingConfig{
 					InferenceHeartbeatCmd:         "inferenceHeartbeat",
@@ -322,3 +339,434 @@ func TestVerifyDoorAccess(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyDoorAccessSplitPayer(t *testing.T) {
+	cardEvent := func(cardID string) dtos.Event {
+		return dtos.Event{
+			DeviceName: "card-reader",
+			Readings: []dtos.BaseReading{
+				{
+					DeviceName:    "card-reader",
+					SimpleReading: dtos.SimpleReading{Value: cardID},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		TestCaseName        string
+		SplitBasketRule     string
+		DoorOpened          bool
+		CardID              string
+		ExpectedSplitPayers int
+		ExpectedDisplayRow2 string
+	}{
+		{"Second customer", "even", false, "0003293374", 1, "Split: 2 payers"},
+		{"Same account", "even", false, "0003278380", 0, "Split declined"},
+		{"Stocker", "even", false, "0003278385", 0, "Split declined"},
+		{"Unknown card", "even", false, "0000000000", 0, "Split declined"},
+		{"Door already opened", "even", true, "0003293374", 0, ""},
+		{"Split baskets disabled", "", false, "0003293374", 0, ""},
+	}
+
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users := map[string]OutputData{
+			"/0003278380": {AccountID: 1, RoleID: 1, CardID: "0003278380"},
+			"/0003293374": {AccountID: 2, RoleID: 1, CardID: "0003293374"},
+			"/0003278385": {AccountID: 3, RoleID: 2, CardID: "0003278385"},
+		}
+		user, ok := users[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		authDataJSON, err := json.Marshal(user)
+		require.NoError(t, err)
+		w.Write(authDataJSON)
+	}))
+	defer authServer.Close()
+
+	for _, tc := range testCases {
+		currentTest := tc
+		t.Run(currentTest.TestCaseName, func(t *testing.T) {
+			mockCommandClient := &client_mocks.CommandClient{}
+			mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+			workflow := NewWorkflow(StateAuthorized)
+			if currentTest.DoorOpened {
+				workflow = NewWorkflow(StateDoorOpen)
+			}
+			vendingState := VendingState{
+				ThreadStopChannel: make(chan int),
+				CurrentUserData:   OutputData{AccountID: 1, RoleID: 1, CardID: "0003278380"},
+				Workflow:          workflow,
+				Configuration: &config.VendingConfig{
+					ControllerBoardDisplayRow2Cmd: "displayrow2",
+					AuthenticationEndpoint:        authServer.URL,
+					SplitBasketRule:               currentTest.SplitBasketRule,
+				},
+				CommandClient: mockCommandClient,
+			}
+
+			resp, err := vendingState.VerifyDoorAccess(logger.NewMockClient(), cardEvent(currentTest.CardID))
+			require.True(t, resp)
+			require.NotNil(t, err)
+
+			assert.Len(t, vendingState.SplitPayers, currentTest.ExpectedSplitPayers)
+			assert.Equal(t, 1, vendingState.CurrentUserData.AccountID, "the customer that opened the door should not change")
+			if currentTest.ExpectedDisplayRow2 == "" {
+				mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": currentTest.ExpectedDisplayRow2})
+			}
+		})
+	}
+}
+
+func TestHandleMqttDeviceReadingSplitBasket(t *testing.T) {
+	event := dtos.Event{
+		DeviceName: InferenceMQTTDevice,
+		Readings: []dtos.BaseReading{
+			{
+				ResourceName: "inferenceSkuDelta",
+				SimpleReading: dtos.SimpleReading{
+					Value: `[{"SKU": "HXI86WHU", "delta": -2}]`,
+				},
+			},
+		},
+	}
+
+	var receivedSplit splitLedger
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/split" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&receivedSplit))
+			output := []Ledger{{TransactionID: 123, LineTotal: 1.99, SplitID: 123}, {TransactionID: 124, LineTotal: 1.99, SplitID: 123}}
+			outputJSON, err := json.Marshal(output)
+			require.NoError(t, err)
+			w.Write(outputJSON)
+			return
+		}
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := VendingState{
+		Workflow:                       NewWorkflow(StateIdle),
+		InferenceWaitThreadStopChannel: make(chan int),
+		ThreadStopChannel:              make(chan int),
+		CurrentUserData:                OutputData{AccountID: 1, RoleID: 1},
+		SplitPayers:                    []OutputData{{AccountID: 2, RoleID: 1}},
+		Configuration: &config.VendingConfig{
+			InventoryService:               testServer.URL,
+			InventoryAuditLogService:       testServer.URL,
+			ControllerBoardDisplayResetCmd: "displayreset",
+			ControllerBoardDisplayRow1Cmd:  "displayrow1",
+			LedgerService:                  testServer.URL,
+			SplitBasketRule:                "even",
+		},
+		CommandClient: mockCommandClient,
+	}
+
+	_, err := vendingState.HandleMqttDeviceReading(logger.NewMockClient(), event)
+	require.Nil(t, err)
+
+	assert.Equal(t, []int{1, 2}, receivedSplit.AccountIDs)
+	assert.Equal(t, "even", receivedSplit.Rule)
+	assert.Equal(t, []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}, receivedSplit.DeltaSKUs)
+	assert.Nil(t, vendingState.SplitPayers, "split payers should be reset once the workflow completes")
+	// both ledgers are displayed, each resetting the LCD twice and showing its total
+	mockCommandClient.AssertNumberOfCalls(t, "IssueSetCommandByName", 6)
+}
+
+func TestHandleMqttDeviceReadingTestVend(t *testing.T) {
+	event := dtos.Event{
+		DeviceName: InferenceMQTTDevice,
+		Readings: []dtos.BaseReading{
+			{
+				ResourceName: "inferenceSkuDelta",
+				SimpleReading: dtos.SimpleReading{
+					Value: `[{"SKU": "HXI86WHU", "delta": -2}]`,
+				},
+			},
+		},
+	}
+
+	var receivedLedger deltaLedger
+	ledgerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&receivedLedger))
+		outputJSON, err := json.Marshal(Ledger{TransactionID: 123, IsPaid: true, IsTest: true, DisplayTotal: "$0.00"})
+		require.NoError(t, err)
+		w.Write(outputJSON)
+	}))
+	defer ledgerServer.Close()
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer inventoryServer.Close()
+
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := VendingState{
+		Workflow:                       NewWorkflow(StateIdle),
+		InferenceWaitThreadStopChannel: make(chan int),
+		ThreadStopChannel:              make(chan int),
+		CurrentUserData:                OutputData{AccountID: 6, RoleID: 4},
+		Configuration: &config.VendingConfig{
+			InventoryService:               inventoryServer.URL,
+			InventoryAuditLogService:       inventoryServer.URL,
+			ControllerBoardDisplayResetCmd: "displayreset",
+			ControllerBoardDisplayRow1Cmd:  "displayrow1",
+			ControllerBoardDisplayRow2Cmd:  "displayrow2",
+			LedgerService:                  ledgerServer.URL,
+		},
+		CommandClient: mockCommandClient,
+	}
+
+	_, err := vendingState.HandleMqttDeviceReading(logger.NewMockClient(), event)
+	require.Nil(t, err)
+
+	assert.Equal(t, deltaLedger{AccountID: 6, DeltaSKUs: []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}, IsTest: true}, receivedLedger)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow1", map[string]string{"displayRow1": "Total: $0.00"})
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Test vend"})
+}
+
+func TestVerifyDoorAccessPreAuthorize(t *testing.T) {
+	testCases := []struct {
+		TestCaseName     string
+		HoldAmount       float64
+		LedgerStatusCode int
+		ExpectedBody     string
+		ExpectedUnlock   bool
+	}{
+		{"Hold placed", 0, http.StatusOK, "", true},
+		{"Hold of the kiosk placed", 25, http.StatusOK, `{"amount":25}`, true},
+		{"Card declined", 0, http.StatusPaymentRequired, "", false},
+		{"Ledger service error", 0, http.StatusBadGateway, "", false},
+	}
+
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authDataJSON, err := json.Marshal(OutputData{AccountID: 1, RoleID: 1})
+		require.NoError(t, err)
+		w.Write(authDataJSON)
+	}))
+	defer authServer.Close()
+
+	for _, tc := range testCases {
+		currentTest := tc
+		t.Run(currentTest.TestCaseName, func(t *testing.T) {
+			ledgerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/1/preauth", r.URL.Path)
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, currentTest.ExpectedBody, string(body))
+				w.WriteHeader(currentTest.LedgerStatusCode)
+			}))
+			defer ledgerServer.Close()
+
+			mockCommandClient := &client_mocks.CommandClient{}
+			eventResp := responses.NewEventResponse("", "", http.StatusOK, dtos.Event{})
+			mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+			mockCommandClient.On("IssueGetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&eventResp, nil)
+
+			vendingState := VendingState{
+				Workflow:          NewWorkflow(StateIdle),
+				ThreadStopChannel: make(chan int),
+				Configuration: &config.VendingConfig{
+					InferenceHeartbeatCmd:         "inferenceHeartbeat",
+					ControllerBoardDisplayRow2Cmd: "displayrow2",
+					ControllerBoardDisplayRow3Cmd: "displayrow3",
+					ControllerBoardLock1Cmd:       "lock1",
+					AuthenticationEndpoint:        authServer.URL,
+					LedgerService:                 ledgerServer.URL,
+					PreAuthorizeCustomers:         true,
+					PreAuthHoldAmount:             currentTest.HoldAmount,
+				},
+				Timeouts:      NewStageTimeouts(StageDurations{DoorOpen: time.Minute}),
+				CommandClient: mockCommandClient,
+			}
+
+			event := dtos.Event{
+				DeviceName: "card-reader",
+				Readings:   []dtos.BaseReading{{DeviceName: "card-reader", SimpleReading: dtos.SimpleReading{Value: "0003293374"}}},
+			}
+			resp, _ := vendingState.VerifyDoorAccess(logger.NewMockClient(), event)
+			require.True(t, resp)
+			close(vendingState.ThreadStopChannel)
+
+			assert.Equal(t, currentTest.ExpectedUnlock, vendingState.Workflow.Vending())
+			if currentTest.ExpectedUnlock {
+				mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", mock.Anything)
+			} else {
+				mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", mock.Anything)
+				mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Card declined"})
+				assert.Equal(t, OutputData{}, vendingState.CurrentUserData)
+			}
+		})
+	}
+}
+
+func TestVerifyDoorAccessCreditLimit(t *testing.T) {
+	testCases := []struct {
+		TestCaseName        string
+		CreditLimit         float64
+		UnpaidBalance       float64
+		LedgerStatusCode    int
+		ExpectedUnlock      bool
+		ExpectedDisplayRow2 string
+	}{
+		{"No credit limit", 0, 100, http.StatusOK, true, "hello"},
+		{"Under the limit", 25, 24.99, http.StatusOK, true, "hello"},
+		{"At the limit", 25, 25, http.StatusOK, true, "hello"},
+		{"Over the limit", 25, 25.01, http.StatusOK, false, "Credit limit reached"},
+		{"Ledger service error", 25, 0, http.StatusInternalServerError, false, "Card declined"},
+	}
+
+	for _, tc := range testCases {
+		currentTest := tc
+		t.Run(currentTest.TestCaseName, func(t *testing.T) {
+			authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authDataJSON, err := json.Marshal(authResponse{OutputData: OutputData{AccountID: 1, RoleID: 1, CreditLimit: currentTest.CreditLimit}, Token: "card-token"})
+				require.NoError(t, err)
+				w.Write(authDataJSON)
+			}))
+			defer authServer.Close()
+
+			ledgerCalled := false
+			ledgerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ledgerCalled = true
+				assert.Equal(t, http.MethodGet, r.Method)
+				assert.Equal(t, "/1/balance", r.URL.Path)
+				assert.Equal(t, "Bearer card-token", r.Header.Get("Authorization"), "the balance should be read with the card's token")
+				if currentTest.LedgerStatusCode != http.StatusOK {
+					w.WriteHeader(currentTest.LedgerStatusCode)
+					return
+				}
+				balanceJSON, err := json.Marshal(accountBalance{AccountID: 1, Currency: "USD", UnpaidBalance: currentTest.UnpaidBalance})
+				require.NoError(t, err)
+				w.Write(balanceJSON)
+			}))
+			defer ledgerServer.Close()
+
+			mockCommandClient := &client_mocks.CommandClient{}
+			eventResp := responses.NewEventResponse("", "", http.StatusOK, dtos.Event{})
+			mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+			mockCommandClient.On("IssueGetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&eventResp, nil)
+
+			vendingState := VendingState{
+				Workflow:          NewWorkflow(StateIdle),
+				ThreadStopChannel: make(chan int),
+				Configuration: &config.VendingConfig{
+					InferenceHeartbeatCmd:         "inferenceHeartbeat",
+					ControllerBoardDisplayRow2Cmd: "displayrow2",
+					ControllerBoardDisplayRow3Cmd: "displayrow3",
+					ControllerBoardLock1Cmd:       "lock1",
+					AuthenticationEndpoint:        authServer.URL,
+					LedgerService:                 ledgerServer.URL,
+				},
+				Timeouts:      NewStageTimeouts(StageDurations{DoorOpen: time.Minute}),
+				CommandClient: mockCommandClient,
+			}
+
+			event := dtos.Event{
+				DeviceName: "card-reader",
+				Readings:   []dtos.BaseReading{{DeviceName: "card-reader", SimpleReading: dtos.SimpleReading{Value: "0003293374"}}},
+			}
+			resp, _ := vendingState.VerifyDoorAccess(logger.NewMockClient(), event)
+			require.True(t, resp)
+			close(vendingState.ThreadStopChannel)
+
+			assert.Equal(t, currentTest.CreditLimit > 0, ledgerCalled, "the balance should only be checked for accounts with a limit")
+			assert.Equal(t, currentTest.ExpectedUnlock, vendingState.Workflow.Vending())
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": currentTest.ExpectedDisplayRow2})
+			if currentTest.ExpectedUnlock {
+				mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", mock.Anything)
+			} else {
+				mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", mock.Anything)
+				assert.Equal(t, OutputData{}, vendingState.CurrentUserData)
+			}
+		})
+	}
+}
+
+// TestVerifyDoorAccessAuthorization tests that the authentication service
+// decides whether a card may restock or maintain the vending machine, which
+// clears its maintenance mode
+func TestVerifyDoorAccessAuthorization(t *testing.T) {
+	testCases := []struct {
+		TestCaseName string
+		RoleID       int
+		Allowed      bool
+		Unreachable  bool
+		ExpectedRow2 string
+	}{
+		{"maintainer allowed", 3, true, false, "Maintenance Mode"},
+		{"admin allowed", 5, true, false, "Maintenance Mode"},
+		{"maintainer denied", 3, false, false, "Unauthorized"},
+		{"stocker denied", 2, false, false, "Unauthorized"},
+		{"authorization unavailable", 3, true, true, "Unauthorized"},
+	}
+	for _, tc := range testCases {
+		currentTest := tc
+		t.Run(currentTest.TestCaseName, func(t *testing.T) {
+			var checked []string
+			authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/authorization/") {
+					checked = append(checked, r.URL.Path)
+					if currentTest.Unreachable {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					_, _ = w.Write([]byte(fmt.Sprintf(`{"allowed":%v}`, currentTest.Allowed)))
+					return
+				}
+				authDataJSON, err := json.Marshal(OutputData{RoleID: currentTest.RoleID, CardID: "0001230001"})
+				require.NoError(t, err)
+				_, _ = w.Write(authDataJSON)
+			}))
+			defer authServer.Close()
+
+			mockCommandClient := &client_mocks.CommandClient{}
+			eventResp := responses.NewEventResponse("", "", http.StatusOK, dtos.Event{})
+			mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+			mockCommandClient.On("IssueGetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&eventResp, nil)
+
+			vendingState := VendingState{
+				Workflow:                       NewWorkflow(StateIdle),
+				InferenceWaitThreadStopChannel: make(chan int),
+				ThreadStopChannel:              make(chan int),
+				MaintenanceMode:                true,
+				Configuration: &config.VendingConfig{
+					ControllerBoardDeviceName:     "controller-board",
+					ControllerBoardDisplayRow1Cmd: "displayrow1",
+					ControllerBoardDisplayRow2Cmd: "displayrow2",
+					ControllerBoardDisplayRow3Cmd: "displayrow3",
+					ControllerBoardLock1Cmd:       "lock1",
+					AuthenticationEndpoint:        authServer.URL + "/authentication",
+					AuthorizationEndpoint:         authServer.URL + "/authorization",
+				},
+				CommandClient: mockCommandClient,
+			}
+
+			event := dtos.Event{DeviceName: "card-reader", Readings: []dtos.BaseReading{{DeviceName: "card-reader", SimpleReading: dtos.SimpleReading{Value: "0001230001"}}}}
+			_, _ = vendingState.VerifyDoorAccess(logger.NewMockClient(), event)
+
+			action := ActionMaintain
+			if currentTest.RoleID == 2 {
+				action = ActionStock
+			}
+			assert.Equal(t, []string{"/authorization/0001230001/" + action}, checked)
+			assert.Equal(t, currentTest.ExpectedRow2 != "Maintenance Mode", vendingState.MaintenanceMode, "only an allowed card clears maintenance mode")
+			assert.False(t, vendingState.Workflow.Vending(), "a denied card never opens the door")
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayrow2", map[string]string{"displayRow2": currentTest.ExpectedRow2})
+		})
+	}
+}
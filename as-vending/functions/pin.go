@@ -0,0 +1,250 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// defaultPinEntryTimeout is how long the kiosk waits for the PIN of a card
+// when no timeout is configured
+const defaultPinEntryTimeout = 30 * time.Second
+
+var (
+	// ErrPinNotAwaited is returned when a PIN is entered while no card is
+	// waiting for one
+	ErrPinNotAwaited = errors.New("no card is waiting for a PIN")
+	// ErrWrongPin is returned when the PIN entered is not the card's PIN,
+	// and the kiosk keeps waiting for the right one
+	ErrWrongPin = errors.New("the PIN is not correct")
+	// ErrCardLocked is returned when the card was locked after too many
+	// wrong PINs, and the kiosk stops waiting for its PIN
+	ErrCardLocked = errors.New("the card is locked")
+)
+
+// pinVerification is the request to the authentication service to verify
+// the PIN entered for a card
+type pinVerification struct {
+	CardID string `json:"cardID"`
+	Pin    string `json:"pin"`
+}
+
+// pendingPin is a card that was authenticated and waits for its PIN before
+// the door is unlocked
+type pendingPin struct {
+	auth      OutputData
+	scannedAt time.Time
+}
+
+// PinEntry holds a card that needs a PIN until the kiosk UI enters it,
+// and verifies the PIN through the authentication service. A nil PinEntry
+// is never waiting.
+type PinEntry struct {
+	mutex    sync.Mutex
+	endpoint string
+	timeout  time.Duration
+	pending  *pendingPin
+	timer    *time.Timer
+}
+
+// NewPinEntry creates a PinEntry that verifies PINs at the endpoint of the
+// authentication service, and waits for the timeout for a PIN
+func NewPinEntry(endpoint string, timeout time.Duration) *PinEntry {
+	if timeout <= 0 {
+		timeout = defaultPinEntryTimeout
+	}
+	return &PinEntry{
+		endpoint: endpoint,
+		timeout:  timeout,
+	}
+}
+
+// ParsePinEntryTimeout parses how long the kiosk waits for the PIN of a
+// card, empty is the default timeout
+func ParsePinEntryTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return defaultPinEntryTimeout, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("PIN entry timeout %q must be a positive duration", timeout)
+	}
+	return duration, nil
+}
+
+// Start waits for the PIN of the card that was scanned at scannedAt, in
+// place of any card that was waiting, and returns when it times out. The
+// onTimeout function is called when no PIN was verified in time.
+func (entry *PinEntry) Start(lc logger.LoggingClient, auth OutputData, scannedAt time.Time, onTimeout func()) time.Time {
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	pending := &pendingPin{auth: auth, scannedAt: scannedAt}
+	entry.pending = pending
+	entry.timer = time.AfterFunc(entry.timeout, func() {
+		if entry.expire(pending) {
+			lc.Infof("card %s timed out waiting for its PIN", auth.CardID)
+			if onTimeout != nil {
+				onTimeout()
+			}
+		}
+	})
+	lc.Infof("waiting %s for the PIN of card %s", entry.timeout, auth.CardID)
+	return time.Now().Add(entry.timeout)
+}
+
+// expire stops waiting for the PIN of the pending card, if it is still
+// waiting, and returns whether it did
+func (entry *PinEntry) expire(pending *pendingPin) bool {
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.pending != pending {
+		return false
+	}
+	entry.pending = nil
+	return true
+}
+
+// Cancel stops waiting for a PIN, and returns false when no card was
+// waiting for one
+func (entry *PinEntry) Cancel(lc logger.LoggingClient) bool {
+	if entry == nil {
+		return false
+	}
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.pending == nil {
+		return false
+	}
+	entry.timer.Stop()
+	lc.Infof("stopped waiting for the PIN of card %s", entry.pending.auth.CardID)
+	entry.pending = nil
+	return true
+}
+
+// Waiting returns whether a card is waiting for its PIN
+func (entry *PinEntry) Waiting() bool {
+	if entry == nil {
+		return false
+	}
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	return entry.pending != nil
+}
+
+// Verify verifies the PIN of the waiting card through the authentication
+// service, and returns the card and when it was scanned once it is right.
+// A wrong PIN keeps the card waiting, and a locked card stops it.
+func (entry *PinEntry) Verify(lc logger.LoggingClient, pin string) (OutputData, time.Time, error) {
+	if entry == nil {
+		return OutputData{}, time.Time{}, ErrPinNotAwaited
+	}
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.pending == nil {
+		return OutputData{}, time.Time{}, ErrPinNotAwaited
+	}
+	pending := entry.pending
+
+	body, err := json.Marshal(pinVerification{CardID: pending.auth.CardID, Pin: pin})
+	if err != nil {
+		return OutputData{}, time.Time{}, err
+	}
+	resp, err := sendHTTPRequest(lc, http.MethodPost, entry.endpoint, body)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		switch {
+		case resp != nil && resp.StatusCode == http.StatusUnauthorized:
+			lc.Infof("wrong PIN entered for card %s", pending.auth.CardID)
+			return OutputData{}, time.Time{}, ErrWrongPin
+		case resp != nil && resp.StatusCode == http.StatusLocked:
+			lc.Infof("card %s is locked, stopped waiting for its PIN", pending.auth.CardID)
+			entry.timer.Stop()
+			entry.pending = nil
+			return OutputData{}, time.Time{}, ErrCardLocked
+		}
+		return OutputData{}, time.Time{}, fmt.Errorf("failed to verify the PIN of card %s: %s", pending.auth.CardID, err.Error())
+	}
+
+	entry.timer.Stop()
+	entry.pending = nil
+	lc.Infof("verified the PIN of card %s", pending.auth.CardID)
+	// the token is only issued once the PIN is verified
+	auth := pending.auth
+	if body, err := io.ReadAll(resp.Body); err == nil {
+		if verified, err := unmarshalAuthResponse(body); err == nil {
+			auth.Token = verified.Token
+		}
+	}
+	return auth, pending.scannedAt, nil
+}
+
+// awaitPin waits for the kiosk UI to enter the PIN of the card that was
+// authenticated, instead of unlocking the door. The card is forgotten when
+// no PIN is entered in time.
+func (vendingState *VendingState) awaitPin(lc logger.LoggingClient, scannedAt time.Time) error {
+	if vendingState.PinEntry == nil {
+		// the PIN cannot be verified, so the card may not open the door
+		lc.Errorf("card %s needs a PIN, but PinVerificationEndpoint is not configured", vendingState.CurrentUserData.CardID)
+		cardID := vendingState.CurrentUserData.CardID
+		vendingState.CurrentUserData = OutputData{}
+		return vendingState.displayUnauthorized(lc, cardID)
+	}
+	vendingState.StageDeadline = vendingState.PinEntry.Start(lc, vendingState.CurrentUserData, scannedAt, func() {
+		vendingState.LockSession()
+		defer vendingState.UnlockSession()
+		vendingState.CurrentUserData = OutputData{}
+		vendingState.StageDeadline = time.Time{}
+		vendingState.displayMaintenance(lc)
+	})
+	return vendingState.displayRows(lc, "Enter PIN", "on the screen", vendingState.CurrentUserData.CardID)
+}
+
+// EnterPin verifies the PIN entered on the kiosk UI for the card that is
+// waiting for it, and unlocks the door once it is right
+func (vendingState *VendingState) EnterPin(lc logger.LoggingClient, pin string) error {
+	auth, scannedAt, err := vendingState.PinEntry.Verify(lc, pin)
+	switch {
+	case errors.Is(err, ErrWrongPin):
+		if displayErr := vendingState.displayRows(lc, "Wrong PIN", "Try again", vendingState.CurrentUserData.CardID); displayErr != nil {
+			lc.Errorf("failed to display the wrong PIN: %s", displayErr.Error())
+		}
+		return err
+	case errors.Is(err, ErrCardLocked):
+		vendingState.CurrentUserData = OutputData{}
+		vendingState.StageDeadline = time.Time{}
+		if displayErr := vendingState.displayRows(lc, "Card locked", "Call for service", ""); displayErr != nil {
+			lc.Errorf("failed to display the locked card: %s", displayErr.Error())
+		}
+		return err
+	case err != nil:
+		return err
+	}
+
+	vendingState.CurrentUserData = auth
+	vendingState.StageDeadline = time.Time{}
+	switch auth.RoleID {
+	case 3, 5:
+		return vendingState.maintenanceScan(lc, auth.CardID, scannedAt)
+	}
+	if vendingState.MaintenanceMode {
+		// the vending machine went out of service while the PIN was entered
+		vendingState.CurrentUserData = OutputData{}
+		vendingState.displayMaintenance(lc)
+		return nil
+	}
+	return vendingState.openDoor(lc, auth.CardID, scannedAt)
+}
@@ -0,0 +1,155 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/responses"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePinEntryTimeout(t *testing.T) {
+	timeout, err := ParsePinEntryTimeout("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultPinEntryTimeout, timeout)
+	timeout, err = ParsePinEntryTimeout("45s")
+	require.NoError(t, err)
+	assert.Equal(t, 45*time.Second, timeout)
+	_, err = ParsePinEntryTimeout("0s")
+	assert.Error(t, err)
+	_, err = ParsePinEntryTimeout("soon")
+	assert.Error(t, err)
+}
+
+// newPinTestState returns a vending state whose authentication service
+// authenticates card 0001230001 with the role, needing a PIN, and accepts
+// the PIN 1234. The PIN 0000 locks the card.
+func newPinTestState(t *testing.T, roleID int, pinTimeout time.Duration) (*VendingState, *client_mocks.CommandClient) {
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/authentication/verify-pin" {
+			var request pinVerification
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, "0001230001", request.CardID)
+			switch request.Pin {
+			case "1234":
+				_, _ = w.Write([]byte(`{"accountID":1,"roleID":1,"cardID":"0001230001","token":"card-token"}`))
+			case "0000":
+				w.WriteHeader(http.StatusLocked)
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+			return
+		}
+		authDataJSON, err := json.Marshal(OutputData{AccountID: 1, RoleID: roleID, CardID: "0001230001", PinRequired: true})
+		require.NoError(t, err)
+		_, _ = w.Write(authDataJSON)
+	}))
+	t.Cleanup(authServer.Close)
+
+	mockCommandClient := &client_mocks.CommandClient{}
+	eventResp := responses.NewEventResponse("", "", http.StatusOK, dtos.Event{})
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	mockCommandClient.On("IssueGetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&eventResp, nil)
+
+	vendingState := &VendingState{
+		Workflow:                       NewWorkflow(StateIdle),
+		InferenceWaitThreadStopChannel: make(chan int),
+		ThreadStopChannel:              make(chan int),
+		DoorOpenWaitThreadStopChannel:  make(chan int),
+		Timeouts:                       NewStageTimeouts(StageDurations{DoorOpen: time.Minute}),
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName:     "controller-board",
+			ControllerBoardDisplayRow1Cmd: "displayrow1",
+			ControllerBoardDisplayRow2Cmd: "displayrow2",
+			ControllerBoardDisplayRow3Cmd: "displayrow3",
+			ControllerBoardLock1Cmd:       "lock1",
+			AuthenticationEndpoint:        authServer.URL + "/authentication",
+		},
+		CommandClient: mockCommandClient,
+		PinEntry:      NewPinEntry(authServer.URL+"/authentication/verify-pin", pinTimeout),
+	}
+	t.Cleanup(func() { close(vendingState.ThreadStopChannel) })
+	return vendingState, mockCommandClient
+}
+
+var pinCardEvent = dtos.Event{DeviceName: "card-reader", Readings: []dtos.BaseReading{{DeviceName: "card-reader", SimpleReading: dtos.SimpleReading{Value: "0001230001"}}}}
+
+func TestVerifyDoorAccessPin(t *testing.T) {
+	lc := logger.NewMockClient()
+	vendingState, mockCommandClient := newPinTestState(t, 1, time.Minute)
+
+	assert.ErrorIs(t, vendingState.EnterPin(lc, "1234"), ErrPinNotAwaited)
+
+	// the door stays locked until the PIN is entered
+	_, _ = vendingState.VerifyDoorAccess(lc, pinCardEvent)
+	assert.False(t, vendingState.Workflow.Vending())
+	assert.True(t, vendingState.PinEntry.Waiting())
+	assert.True(t, vendingState.busy())
+	mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "lock1", mock.Anything)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayrow1", map[string]string{"displayRow1": "Enter PIN"})
+	status := vendingState.CurrentSession(time.Now())
+	assert.Equal(t, SessionAwaitingPin, status.Stage)
+	assert.Equal(t, "0001230001", status.CardID)
+	assert.InDelta(t, 60, status.RemainingSeconds, 1)
+
+	// a wrong PIN keeps waiting for the right one
+	assert.ErrorIs(t, vendingState.EnterPin(lc, "4321"), ErrWrongPin)
+	assert.True(t, vendingState.PinEntry.Waiting())
+	assert.False(t, vendingState.Workflow.Vending())
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayrow1", map[string]string{"displayRow1": "Wrong PIN"})
+
+	require.NoError(t, vendingState.EnterPin(lc, "1234"))
+	assert.False(t, vendingState.PinEntry.Waiting())
+	assert.True(t, vendingState.Workflow.Vending())
+	assert.Equal(t, "0001230001", vendingState.CurrentUserData.CardID)
+	assert.Equal(t, "card-token", vendingState.CurrentUserData.Token, "the token is issued once the PIN is verified")
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "lock1", map[string]string{"lock1": "true"})
+
+	assert.ErrorIs(t, vendingState.EnterPin(lc, "1234"), ErrPinNotAwaited)
+}
+
+func TestVerifyDoorAccessPinLocked(t *testing.T) {
+	lc := logger.NewMockClient()
+	vendingState, mockCommandClient := newPinTestState(t, 3, time.Minute)
+	vendingState.MaintenanceMode = true
+
+	_, _ = vendingState.VerifyDoorAccess(lc, pinCardEvent)
+	require.True(t, vendingState.PinEntry.Waiting())
+	assert.True(t, vendingState.MaintenanceMode, "a maintainer card needs its PIN too")
+
+	assert.ErrorIs(t, vendingState.EnterPin(lc, "0000"), ErrCardLocked)
+	assert.False(t, vendingState.PinEntry.Waiting())
+	assert.Empty(t, vendingState.CurrentUserData.CardID)
+	assert.True(t, vendingState.MaintenanceMode)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayrow1", map[string]string{"displayRow1": "Card locked"})
+	mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "lock1", mock.Anything)
+}
+
+func TestVerifyDoorAccessPinTimeout(t *testing.T) {
+	lc := logger.NewMockClient()
+	vendingState, _ := newPinTestState(t, 1, 10*time.Millisecond)
+
+	_, _ = vendingState.VerifyDoorAccess(lc, pinCardEvent)
+	require.Eventually(t, func() bool { return !vendingState.PinEntry.Waiting() }, time.Second, 5*time.Millisecond)
+	assert.ErrorIs(t, vendingState.EnterPin(lc, "1234"), ErrPinNotAwaited)
+	assert.False(t, vendingState.Workflow.Vending())
+
+	// without a PIN verification endpoint the card is refused
+	vendingState.PinEntry = nil
+	_, _ = vendingState.VerifyDoorAccess(lc, pinCardEvent)
+	assert.False(t, vendingState.Workflow.Vending())
+	assert.Empty(t, vendingState.CurrentUserData.CardID)
+}
@@ -0,0 +1,218 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+const (
+	// BarcodeScanResource is the barcode scanner's resource, whose readings
+	// are the scanned codes
+	BarcodeScanResource = "barcode"
+
+	// priceCheckDisplay is how long the price stays on the LCD
+	priceCheckDisplay = 5 * time.Second
+)
+
+// PriceCheckResult is a barcode scanned outside of a vend and the product
+// it was looked up as. It is shown on the LCD, returned to the UI, and
+// published for demand analytics when a topic is configured, including the
+// scans of products that are not in inventory.
+type PriceCheckResult struct {
+	Barcode     string  `json:"barcode"`
+	Found       bool    `json:"found"`
+	SKU         string  `json:"sku,omitempty"`
+	ProductName string  `json:"productName,omitempty"`
+	ItemPrice   float64 `json:"itemPrice,omitempty"`
+	Currency    string  `json:"currency,omitempty"`
+	KioskID     string  `json:"kioskId"`
+	Timestamp   int64   `json:"timestamp,string"`
+}
+
+// priceCheckProducts holds the fields of the inventory service's products
+// that are shown
+type priceCheckProducts struct {
+	Data []struct {
+		SKU         string  `json:"sku"`
+		ProductName string  `json:"productName"`
+		ItemPrice   float64 `json:"itemPrice"`
+		Currency    string  `json:"currency"`
+		IsActive    bool    `json:"isActive"`
+	} `json:"data"`
+}
+
+// PriceCheck looks up the products of the barcodes scanned between vends,
+// so that a customer can check a price without opening the door. A nil
+// PriceCheck has no barcode scanner.
+type PriceCheck struct {
+	mutex      sync.Mutex
+	deviceName string
+	endpoint   string
+	publish    func(PriceCheckResult) error
+	last       *PriceCheckResult
+	shownTill  time.Time
+}
+
+// NewPriceCheck creates a PriceCheck for the barcode scanner device, which
+// looks products up by their barcode at the inventory endpoint. publish is
+// called with every price check, and may be nil.
+func NewPriceCheck(deviceName string, endpoint string, publish func(PriceCheckResult) error) *PriceCheck {
+	return &PriceCheck{deviceName: deviceName, endpoint: endpoint, publish: publish}
+}
+
+// DeviceName returns the barcode scanner device, empty without a scanner
+func (priceCheck *PriceCheck) DeviceName() string {
+	if priceCheck == nil {
+		return ""
+	}
+	return priceCheck.deviceName
+}
+
+// Last returns the last price check, and false when there has been none
+func (priceCheck *PriceCheck) Last() (PriceCheckResult, bool) {
+	if priceCheck == nil {
+		return PriceCheckResult{}, false
+	}
+	priceCheck.mutex.Lock()
+	defer priceCheck.mutex.Unlock()
+	if priceCheck.last == nil {
+		return PriceCheckResult{}, false
+	}
+	return *priceCheck.last, true
+}
+
+// showing returns whether a price is still on the LCD at now
+func (priceCheck *PriceCheck) showing(now time.Time) bool {
+	if priceCheck == nil {
+		return false
+	}
+	priceCheck.mutex.Lock()
+	defer priceCheck.mutex.Unlock()
+	return now.Before(priceCheck.shownTill)
+}
+
+// record keeps the price check as the last one, shown on the LCD until
+// shownTill, and publishes it
+func (priceCheck *PriceCheck) record(lc logger.LoggingClient, result PriceCheckResult, shownTill time.Time) {
+	priceCheck.mutex.Lock()
+	priceCheck.last = &result
+	priceCheck.shownTill = shownTill
+	priceCheck.mutex.Unlock()
+
+	lc.Infof("price check of barcode %s at kiosk %s, found: %v, sku: %s", result.Barcode, result.KioskID, result.Found, result.SKU)
+	if priceCheck.publish != nil {
+		if err := priceCheck.publish(result); err != nil {
+			lc.Errorf("failed to publish the price check of barcode %s: %s", result.Barcode, err.Error())
+		}
+	}
+}
+
+// lookUp finds the active product with the barcode in inventory
+func (priceCheck *PriceCheck) lookUp(lc logger.LoggingClient, result *PriceCheckResult) error {
+	lookUpURL := priceCheck.endpoint + "?barcode=" + url.QueryEscape(result.Barcode)
+	resp, err := sendHTTPRequest(lc, http.MethodGet, lookUpURL, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the products: %s", err.Error())
+	}
+	var products priceCheckProducts
+	if err := json.Unmarshal(body, &products); err != nil {
+		return fmt.Errorf("failed to unmarshal the products: %s", err.Error())
+	}
+	for _, product := range products.Data {
+		if !product.IsActive {
+			continue
+		}
+		result.Found = true
+		result.SKU = product.SKU
+		result.ProductName = product.ProductName
+		result.ItemPrice = product.ItemPrice
+		result.Currency = product.Currency
+		return nil
+	}
+	return nil
+}
+
+// CheckPrice looks up the product of a barcode scanner event and shows its
+// name and price on the LCD. Scans during a vend, enrollment or maintenance
+// are ignored, and the door is never unlocked.
+func (vendingState *VendingState) CheckPrice(lc logger.LoggingClient, event dtos.Event) (bool, interface{}) {
+	for _, reading := range event.Readings {
+		if reading.ResourceName != BarcodeScanResource {
+			continue
+		}
+		if len(reading.Value) < 1 {
+			return false, fmt.Errorf("event reading was empty, devicename: %s", event.DeviceName)
+		}
+		if vendingState.busy() {
+			lc.Infof("ignoring the scan of barcode %s, the kiosk is busy", reading.Value)
+			return false, nil
+		}
+
+		now := time.Now()
+		result := PriceCheckResult{
+			Barcode:   reading.Value,
+			KioskID:   vendingState.Configuration.KioskID,
+			Timestamp: now.UnixNano(),
+		}
+		if err := vendingState.PriceCheck.lookUp(lc, &result); err != nil {
+			lc.Errorf("failed to look up barcode %s: %s", result.Barcode, err.Error())
+			if displayErr := vendingState.displayRows(lc, "Price check", "Unavailable", "Try again later"); displayErr != nil {
+				lc.Errorf("failed to display the price check: %s", displayErr.Error())
+			}
+			vendingState.resetPriceCheckDisplay(lc)
+			return false, err
+		}
+		vendingState.PriceCheck.record(lc, result, now.Add(priceCheckDisplay))
+
+		row1, row2 := "Item not found", result.Barcode
+		if result.Found {
+			row1 = fmt.Sprintf("%.[1]*s", vendingState.Configuration.LCDRowLength, result.ProductName)
+			row2 = formatPrice(result.ItemPrice, result.Currency)
+		}
+		if err := vendingState.displayRows(lc, "Price check", row1, row2); err != nil {
+			lc.Errorf("failed to display the price check: %s", err.Error())
+		}
+		vendingState.resetPriceCheckDisplay(lc)
+		return false, nil
+	}
+	return false, nil
+}
+
+// resetPriceCheckDisplay resets the LCD once the price check has been shown,
+// unless the kiosk has become busy in the meantime
+func (vendingState *VendingState) resetPriceCheckDisplay(lc logger.LoggingClient) {
+	time.AfterFunc(priceCheckDisplay, func() {
+		vendingState.LockSession()
+		defer vendingState.UnlockSession()
+		if vendingState.idle() {
+			vendingState.displayMaintenance(lc)
+		}
+	})
+}
+
+// formatPrice formats a price for the LCD, prices without a currency are in
+// USD like the ledger totals
+func formatPrice(price float64, currency string) string {
+	if currency == "" || currency == "USD" {
+		return fmt.Sprintf("$%.2f", price)
+	}
+	return fmt.Sprintf("%.2f %s", price, currency)
+}
@@ -0,0 +1,128 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPrice(t *testing.T) {
+	inventory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("barcode") {
+		case "049000024708":
+			_, _ = w.Write([]byte(`{"data":[{"sku":"4900002470","productName":"Sprite (Lemon-Lime) - 16.9 oz","itemPrice":1.99,"isActive":true}]}`))
+		case "012000161155":
+			_, _ = w.Write([]byte(`{"data":[{"sku":"1200016115","productName":"Pepsi","itemPrice":2.5,"currency":"EUR","isActive":true}]}`))
+		case "012000001291":
+			// inactive products are not sold, so they are not found
+			_, _ = w.Write([]byte(`{"data":[{"sku":"1200000129","productName":"Mountain Dew","itemPrice":1.99,"isActive":false}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		}
+	}))
+	defer inventory.Close()
+
+	tests := []struct {
+		Name            string
+		Barcode         string
+		Busy            bool
+		ExpectedFound   bool
+		ExpectedRow2    string
+		ExpectedRow3    string
+		ExpectedChecked bool
+	}{
+		{"found", "049000024708", false, true, "Sprite (Lemon-Lime)", "$1.99", true},
+		{"another currency", "012000161155", false, true, "Pepsi", "2.50 EUR", true},
+		{"inactive", "012000001291", false, false, "Item not found", "012000001291", true},
+		{"not in inventory", "000000000000", false, false, "Item not found", "000000000000", true},
+		{"during a vend", "049000024708", true, false, "", "", false},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockCommandClient := &client_mocks.CommandClient{}
+			mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+			var published []PriceCheckResult
+			workflow := NewWorkflow(StateIdle)
+			if currentTest.Busy {
+				workflow = NewWorkflow(StateAuthorized)
+			}
+			vendingState := VendingState{
+				Configuration: &config.VendingConfig{
+					ControllerBoardDeviceName:     "controller-board",
+					ControllerBoardDisplayRow1Cmd: "displayRow1",
+					ControllerBoardDisplayRow2Cmd: "displayRow2",
+					ControllerBoardDisplayRow3Cmd: "displayRow3",
+					KioskID:                       "kiosk-1",
+					LCDRowLength:                  19,
+				},
+				CommandClient: mockCommandClient,
+				Workflow:      workflow,
+				PriceCheck: NewPriceCheck("barcode-scanner", inventory.URL, func(result PriceCheckResult) error {
+					published = append(published, result)
+					return nil
+				}),
+			}
+
+			lc := logger.NewMockClient()
+			event := dtos.Event{DeviceName: "barcode-scanner", Readings: []dtos.BaseReading{{ResourceName: BarcodeScanResource, SimpleReading: dtos.SimpleReading{Value: currentTest.Barcode}}}}
+			ok, result := vendingState.DeviceHelper(pkg.NewAppFuncContextForTest("", lc), event)
+			assert.False(t, ok)
+			assert.Nil(t, result)
+			assert.Equal(t, currentTest.Busy, vendingState.Workflow.Vending(), "a price check never starts a vend")
+
+			last, checked := vendingState.PriceCheck.Last()
+			require.Equal(t, currentTest.ExpectedChecked, checked)
+			if !checked {
+				assert.Empty(t, published)
+				mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.Equal(t, currentTest.Barcode, last.Barcode)
+			assert.Equal(t, currentTest.ExpectedFound, last.Found)
+			assert.Equal(t, "kiosk-1", last.KioskID)
+			assert.Equal(t, []PriceCheckResult{last}, published)
+			assert.False(t, vendingState.idle(), "the idle display waits while the price is shown")
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow1", map[string]string{"displayRow1": "Price check"})
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow2", map[string]string{"displayRow2": currentTest.ExpectedRow2})
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow3", map[string]string{"displayRow3": currentTest.ExpectedRow3})
+		})
+	}
+}
+
+func TestCheckPriceInventoryUnavailable(t *testing.T) {
+	inventory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer inventory.Close()
+
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	vendingState := VendingState{
+		Workflow:      NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{ControllerBoardDeviceName: "controller-board", ControllerBoardDisplayRow2Cmd: "displayRow2"},
+		CommandClient: mockCommandClient,
+		PriceCheck:    NewPriceCheck("barcode-scanner", inventory.URL, nil),
+	}
+
+	event := dtos.Event{DeviceName: "barcode-scanner", Readings: []dtos.BaseReading{{ResourceName: BarcodeScanResource, SimpleReading: dtos.SimpleReading{Value: "049000024708"}}}}
+	ok, result := vendingState.CheckPrice(logger.NewMockClient(), event)
+	assert.False(t, ok)
+	assert.Error(t, result.(error))
+	_, checked := vendingState.PriceCheck.Last()
+	assert.False(t, checked)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow2", map[string]string{"displayRow2": "Unavailable"})
+}
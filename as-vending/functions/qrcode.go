@@ -0,0 +1,104 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+// QRCodeResource is the inference device resource that carries the payload
+// of a QR code decoded by the kiosk camera
+const QRCodeResource = "inferenceQRCode"
+
+// qrCodeRequest is the body of a QR code authentication
+type qrCodeRequest struct {
+	Payload string `json:"payload"`
+}
+
+// VerifyQRCode authenticates the QR code that the kiosk camera decoded, and
+// then handles its card as a swipe of the card reader. The authentication
+// service checks the card of the QR code as it checks a swipe, so the card
+// is not authenticated again.
+func (vendingState *VendingState) VerifyQRCode(lc logger.LoggingClient, reading dtos.BaseReading) (bool, interface{}) {
+	if vendingState.Enrollment.Waiting() {
+		lc.Info("Ignored a QR code while waiting to enroll a card")
+		return false, nil
+	}
+
+	auth, ok := lookupQRCodeAuthInfo(lc, vendingState.Configuration.AuthenticationEndpoint, reading.Value)
+	if !ok {
+		// a QR code shown during a vend must not replace its display
+		if vendingState.Workflow.Vending() {
+			return false, nil
+		}
+		if err := vendingState.displayUnauthorized(lc, "QR code"); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	vendingState.qrCodeAuth = &auth
+	defer func() {
+		vendingState.qrCodeAuth = nil
+	}()
+	return vendingState.VerifyDoorAccess(lc, dtos.Event{
+		DeviceName: vendingState.cardReaderName(),
+		SourceName: reading.ResourceName,
+		Readings: []dtos.BaseReading{
+			{
+				DeviceName:    vendingState.cardReaderName(),
+				ResourceName:  reading.ResourceName,
+				SimpleReading: dtos.SimpleReading{Value: auth.CardID},
+			},
+		},
+	})
+}
+
+// lookupQRCodeAuthInfo retrieves the authentication information for the
+// card of a QR code, returning false when the QR code is not valid. The
+// payload is a credential, so it is not logged.
+func lookupQRCodeAuthInfo(lc logger.LoggingClient, authEndpoint string, payload string) (OutputData, bool) {
+	outputBytes, err := json.Marshal(qrCodeRequest{Payload: payload})
+	if err != nil {
+		lc.Errorf("Failed to marshal the QR code request: %s", err.Error())
+		return OutputData{}, false
+	}
+	resp, err := sendHTTPRequest(lc, http.MethodPost, authEndpoint+"/qr", outputBytes)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		lc.Infof("Unauthorized QR code: %s", err.Error())
+		return OutputData{}, false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		lc.Errorf("Failed to read response body from Authentication for a QR code: %s", err.Error())
+		return OutputData{}, false
+	}
+	auth, err := unmarshalAuthResponse(body)
+	if err != nil {
+		lc.Errorf("Could not unmarshal from AuthenticationEndpoint for a QR code: %s", err.Error())
+		return OutputData{}, false
+	}
+
+	lc.Info("Successfully found user data for the QR code of card " + auth.CardID)
+	return auth, true
+}
+
+// cardAuthInfo retrieves the authentication information for a card, or
+// takes it from the QR code of the card that was just authenticated
+func (vendingState *VendingState) cardAuthInfo(lc logger.LoggingClient, authEndpoint string, cardID string) (OutputData, bool) {
+	if auth := vendingState.qrCodeAuth; auth != nil && auth.CardID == cardID {
+		vendingState.qrCodeAuth = nil
+		return *auth, true
+	}
+	return lookupCardAuthInfo(lc, authEndpoint, cardID)
+}
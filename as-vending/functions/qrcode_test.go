@@ -0,0 +1,95 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func qrCodeEvent(payload string) dtos.Event {
+	return dtos.Event{
+		DeviceName: InferenceMQTTDevice,
+		Readings: []dtos.BaseReading{
+			{
+				DeviceName:    InferenceMQTTDevice,
+				ResourceName:  QRCodeResource,
+				SimpleReading: dtos.SimpleReading{Value: payload},
+			},
+		},
+	}
+}
+
+// TestVerifyQRCode tests that a QR code opens the door for its card, which
+// is authenticated once
+func TestVerifyQRCode(t *testing.T) {
+	services := &sessionServices{}
+	server := newSessionServer(t, services, OutputData{AccountID: 2, RoleID: 1, CardID: "0003278380"})
+	defer server.Close()
+	vendingState, mockCommandClient := newSessionVendingState(server.URL)
+	vendingState.Workflow = NewWorkflow(StateIdle)
+	vendingState.CurrentUserData = OutputData{}
+	lc := logger.NewMockClient()
+
+	ok, _ := vendingState.HandleMqttDeviceReading(lc, qrCodeEvent("acqr1:payload"))
+	assert.True(t, ok)
+	assert.Equal(t, 1, services.auth)
+	assert.Nil(t, vendingState.qrCodeAuth)
+	assert.True(t, vendingState.Workflow.Vending())
+	assert.Equal(t, OutputData{AccountID: 2, RoleID: 1, CardID: "0003278380"}, vendingState.CurrentUserData)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow3", map[string]string{"displayRow3": "0003278380"})
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", map[string]string{"lock1": "true"})
+}
+
+// TestVerifyQRCodeResumesSession tests that the QR code of the customer of
+// a lingering session reopens the door as their card would
+func TestVerifyQRCodeResumesSession(t *testing.T) {
+	services := &sessionServices{}
+	server := newSessionServer(t, services, OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374"})
+	defer server.Close()
+	vendingState, _ := newSessionVendingState(server.URL)
+	lc := logger.NewMockClient()
+
+	_, err := vendingState.HandleMqttDeviceReading(lc, inferenceEvent(`[{"SKU": "A", "delta": -2}]`))
+	require.Nil(t, err)
+	require.True(t, vendingState.SessionLingering)
+
+	ok, _ := vendingState.HandleMqttDeviceReading(lc, qrCodeEvent("acqr1:payload"))
+	assert.True(t, ok)
+	assert.Equal(t, 1, services.auth)
+	assert.False(t, vendingState.SessionLingering)
+	assert.True(t, vendingState.Workflow.Vending())
+	assert.Equal(t, "session-1", vendingState.SessionID)
+	assert.Empty(t, services.ledgers)
+}
+
+func TestVerifyQRCodeUnauthorized(t *testing.T) {
+	var request qrCodeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/authentication/qr", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	vendingState, mockCommandClient := newSessionVendingState(server.URL)
+	vendingState.Workflow = NewWorkflow(StateIdle)
+	vendingState.CurrentUserData = OutputData{}
+
+	ok, _ := vendingState.HandleMqttDeviceReading(logger.NewMockClient(), qrCodeEvent("acqr1:used"))
+	assert.False(t, ok)
+	assert.Equal(t, "acqr1:used", request.Payload)
+	assert.False(t, vendingState.Workflow.Vending())
+	assert.Equal(t, OutputData{}, vendingState.CurrentUserData)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Unauthorized"})
+	mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", mock.Anything)
+}
@@ -0,0 +1,225 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// CardReaderStatusResource is the card reader's status resource. Its
+	// auto event readings are the heartbeat of the card reader.
+	CardReaderStatusResource = "status"
+
+	// minReaderCheckInterval is the shortest interval the readers are
+	// checked at
+	minReaderCheckInterval = time.Second
+)
+
+// ReaderHealth is the health of a card reader, based on when it was last
+// seen. A reader that has not been seen since the service started is
+// silent since the service started.
+type ReaderHealth struct {
+	DeviceName  string `json:"deviceName"`
+	Online      bool   `json:"online"`
+	LastSeen    int64  `json:"lastSeen,string,omitempty"`
+	SilentForMs int64  `json:"silentForMs"`
+}
+
+// ReaderAlert is the context of a card reader that has been silent for
+// longer than the heartbeat timeout. It is logged, and published as an
+// alert when an alert function is set.
+type ReaderAlert struct {
+	DeviceName  string `json:"deviceName"`
+	LastSeen    int64  `json:"lastSeen,string,omitempty"`
+	SilentForMs int64  `json:"silentForMs"`
+	TimeoutMs   int64  `json:"timeoutMs"`
+	Timestamp   int64  `json:"timestamp,string"`
+}
+
+// ReaderMonitor tracks the last heartbeat of each card reader and detects
+// readers that have gone silent, since a dead reader otherwise looks like
+// there are no customers. A nil ReaderMonitor does not track anything.
+type ReaderMonitor struct {
+	mutex    sync.Mutex
+	timeout  time.Duration
+	lastSeen map[string]time.Time
+	offline  map[string]bool
+	since    time.Time
+	alert    func(ReaderAlert) error
+	now      func() time.Time
+}
+
+// NewReaderMonitor creates a ReaderMonitor for the card readers, which are
+// offline once they have been silent for longer than the timeout. alert is
+// called for every reader that goes offline and may be nil.
+func NewReaderMonitor(timeout time.Duration, deviceNames []string, alert func(ReaderAlert) error) *ReaderMonitor {
+	monitor := &ReaderMonitor{
+		timeout:  timeout,
+		lastSeen: make(map[string]time.Time),
+		offline:  make(map[string]bool),
+		since:    time.Now(),
+		alert:    alert,
+		now:      time.Now,
+	}
+	for _, deviceName := range deviceNames {
+		monitor.lastSeen[deviceName] = time.Time{}
+	}
+	return monitor
+}
+
+// ParseReaderHeartbeatTimeout parses the configured card reader heartbeat
+// timeout. An empty timeout disables reader monitoring.
+func ParseReaderHeartbeatTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("card reader heartbeat timeout %q must be a positive duration", timeout)
+	}
+	return duration, nil
+}
+
+// Heartbeat records that the card reader was seen. It returns true when the
+// reader was offline and has come back.
+func (monitor *ReaderMonitor) Heartbeat(lc logger.LoggingClient, deviceName string) bool {
+	if monitor == nil {
+		return false
+	}
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	monitor.lastSeen[deviceName] = monitor.now()
+	if !monitor.offline[deviceName] {
+		return false
+	}
+	delete(monitor.offline, deviceName)
+	lc.Infof("card reader %s is back online", deviceName)
+	return true
+}
+
+// Check detects the card readers that have been silent for longer than the
+// timeout. Every reader that has just gone offline is logged and alerted.
+// It returns whether any reader is offline.
+func (monitor *ReaderMonitor) Check(lc logger.LoggingClient) bool {
+	if monitor == nil {
+		return false
+	}
+
+	now := monitor.now()
+	var alerts []ReaderAlert
+	monitor.mutex.Lock()
+	for deviceName, lastSeen := range monitor.lastSeen {
+		silentFor := now.Sub(monitor.seenAt(lastSeen))
+		if silentFor <= monitor.timeout || monitor.offline[deviceName] {
+			continue
+		}
+		monitor.offline[deviceName] = true
+		alert := ReaderAlert{
+			DeviceName:  deviceName,
+			SilentForMs: silentFor.Milliseconds(),
+			TimeoutMs:   monitor.timeout.Milliseconds(),
+			Timestamp:   now.UnixNano(),
+		}
+		if !lastSeen.IsZero() {
+			alert.LastSeen = lastSeen.UnixNano()
+		}
+		alerts = append(alerts, alert)
+	}
+	anyOffline := len(monitor.offline) > 0
+	monitor.mutex.Unlock()
+
+	for _, alert := range alerts {
+		lc.Errorf("card reader %s has been silent for %v, timeout is %v", alert.DeviceName, time.Duration(alert.SilentForMs)*time.Millisecond, monitor.timeout)
+		if monitor.alert != nil {
+			if err := monitor.alert(alert); err != nil {
+				lc.Errorf("failed to alert card reader %s fault: %s", alert.DeviceName, err.Error())
+			}
+		}
+	}
+	return anyOffline
+}
+
+// Health returns the health of each card reader, ordered by device name
+func (monitor *ReaderMonitor) Health() []ReaderHealth {
+	health := []ReaderHealth{}
+	if monitor == nil {
+		return health
+	}
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	now := monitor.now()
+	for deviceName, lastSeen := range monitor.lastSeen {
+		readerHealth := ReaderHealth{
+			DeviceName:  deviceName,
+			Online:      !monitor.offline[deviceName],
+			SilentForMs: now.Sub(monitor.seenAt(lastSeen)).Milliseconds(),
+		}
+		if !lastSeen.IsZero() {
+			readerHealth.LastSeen = lastSeen.UnixNano()
+		}
+		health = append(health, readerHealth)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].DeviceName < health[j].DeviceName
+	})
+	return health
+}
+
+// seenAt is when a reader was last seen, which is when the service started
+// for readers that have not been seen
+func (monitor *ReaderMonitor) seenAt(lastSeen time.Time) time.Time {
+	if lastSeen.IsZero() {
+		return monitor.since
+	}
+	return lastSeen
+}
+
+// MonitorReaders checks the card readers until the service stops. While a
+// reader is offline the vending machine is out of service, since nobody can
+// scan their card.
+func (vendingState *VendingState) MonitorReaders(lc logger.LoggingClient) {
+	if vendingState.Readers == nil {
+		return
+	}
+	interval := vendingState.Readers.timeout / 2
+	if interval < minReaderCheckInterval {
+		interval = minReaderCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		vendingState.LockSession()
+		vendingState.checkReaders(lc)
+		vendingState.UnlockSession()
+	}
+}
+
+// checkReaders sets maintenance mode while any card reader is offline
+func (vendingState *VendingState) checkReaders(lc logger.LoggingClient) {
+	if vendingState.Readers.Check(lc) {
+		vendingState.SetMaintenanceReason(lc, ReasonCardReaderOffline)
+	}
+}
+
+// readerSeen records the card reader's heartbeat, and takes the vending
+// machine back into service once no card reader is offline
+func (vendingState *VendingState) readerSeen(lc logger.LoggingClient, deviceName string) {
+	if !vendingState.Readers.Heartbeat(lc, deviceName) {
+		return
+	}
+	for _, health := range vendingState.Readers.Health() {
+		if !health.Online {
+			return
+		}
+	}
+	vendingState.ClearMaintenanceReason(lc, ReasonCardReaderOffline)
+}
@@ -0,0 +1,133 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReaderHeartbeatTimeout(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Timeout       string
+		Expected      time.Duration
+		ExpectedError bool
+	}{
+		{"Timeout", "15s", 15 * time.Second, false},
+		{"Disabled", "", 0, false},
+		{"Invalid duration", "soon", 0, true},
+		{"Negative duration", "-1s", 0, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			timeout, err := ParseReaderHeartbeatTimeout(currentTest.Timeout)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, timeout)
+		})
+	}
+}
+
+func TestReaderMonitor(t *testing.T) {
+	var alerts []ReaderAlert
+	monitor := NewReaderMonitor(10*time.Second, []string{"card-reader"}, func(alert ReaderAlert) error {
+		alerts = append(alerts, alert)
+		return errors.New("message bus unavailable")
+	})
+	now := monitor.since
+	monitor.now = func() time.Time { return now }
+	lc := logger.NewMockClient()
+
+	// a reader that has not been seen is silent since the service started
+	now = now.Add(5 * time.Second)
+	assert.False(t, monitor.Check(lc))
+	assert.False(t, monitor.Heartbeat(lc, "card-reader"))
+
+	now = now.Add(10 * time.Second)
+	assert.False(t, monitor.Check(lc), "a reader silent for the timeout is still online")
+
+	now = now.Add(time.Second)
+	assert.True(t, monitor.Check(lc))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, ReaderAlert{
+		DeviceName:  "card-reader",
+		LastSeen:    monitor.since.Add(5 * time.Second).UnixNano(),
+		SilentForMs: 11000,
+		TimeoutMs:   10000,
+		Timestamp:   now.UnixNano(),
+	}, alerts[0])
+
+	// an offline reader is only alerted once
+	now = now.Add(time.Minute)
+	assert.True(t, monitor.Check(lc))
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, []ReaderHealth{{DeviceName: "card-reader", Online: false, LastSeen: alerts[0].LastSeen, SilentForMs: 71000}}, monitor.Health())
+
+	assert.True(t, monitor.Heartbeat(lc, "card-reader"))
+	assert.False(t, monitor.Check(lc))
+	assert.Equal(t, []ReaderHealth{{DeviceName: "card-reader", Online: true, LastSeen: now.UnixNano(), SilentForMs: 0}}, monitor.Health())
+}
+
+func TestReaderMonitorNil(t *testing.T) {
+	var monitor *ReaderMonitor
+	lc := logger.NewMockClient()
+	assert.False(t, monitor.Heartbeat(lc, "card-reader"))
+	assert.False(t, monitor.Check(lc))
+	assert.Empty(t, monitor.Health())
+}
+
+func TestReaderOfflineMaintenance(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName:      "controller-board",
+			ControllerBoardDisplayResetCmd: "displayReset",
+			ControllerBoardDisplayRow1Cmd:  "displayRow1",
+			ControllerBoardDisplayRow2Cmd:  "displayRow2",
+			ControllerBoardDisplayRow3Cmd:  "displayRow3",
+		},
+		CommandClient: mockCommandClient,
+		Readers:       NewReaderMonitor(time.Second, []string{DsCardReader}, nil),
+	}
+	now := vendingState.Readers.since
+	vendingState.Readers.now = func() time.Time { return now }
+	lc := logger.NewMockClient()
+
+	now = now.Add(2 * time.Second)
+	vendingState.checkReaders(lc)
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonCardReaderOffline}, vendingState.MaintenanceReasons)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow2", map[string]string{"displayRow2": "Card reader offline"})
+
+	// the status reading is a heartbeat rather than a card scan
+	continuePipeline, _ := vendingState.DeviceHelper(pkg.NewAppFuncContextForTest("", lc), dtos.Event{
+		DeviceName: DsCardReader,
+		SourceName: CardReaderStatusResource,
+		Readings:   []dtos.BaseReading{{DeviceName: DsCardReader, ResourceName: CardReaderStatusResource, SimpleReading: dtos.SimpleReading{Value: "ok"}}},
+	})
+	assert.False(t, continuePipeline)
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.Empty(t, vendingState.MaintenanceReasons)
+	assert.False(t, vendingState.Workflow.Vending())
+}
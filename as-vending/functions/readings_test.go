@@ -0,0 +1,86 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadingDecoder(t *testing.T) {
+	skuDelta := []deltaSKU{{SKU: "4900002470", Delta: -2}}
+	cborSKUDelta, err := cbor.Marshal(skuDelta)
+	require.NoError(t, err)
+	// an object decoded from a CBOR event has maps with interface{} keys
+	var cborObject interface{}
+	require.NoError(t, cbor.Unmarshal(cborSKUDelta, &cborObject))
+
+	tests := []struct {
+		Name          string
+		Reading       dtos.BaseReading
+		ExpectedValue string
+		ExpectedError string
+	}{
+		{"String reading", dtos.BaseReading{ValueType: common.ValueTypeString, SimpleReading: dtos.SimpleReading{Value: `{"door_closed":true}`}}, `{"door_closed":true}`, ""},
+		{"CBOR binary reading", dtos.BaseReading{ValueType: common.ValueTypeBinary, BinaryReading: dtos.BinaryReading{MediaType: common.ContentTypeCBOR, BinaryValue: cborSKUDelta}}, `[{"SKU":"4900002470","delta":-2}]`, ""},
+		{"JSON binary reading", dtos.BaseReading{ValueType: common.ValueTypeBinary, BinaryReading: dtos.BinaryReading{MediaType: "application/json; charset=utf-8", BinaryValue: []byte(`{"door_closed":true}`)}}, `{"door_closed":true}`, ""},
+		{"Text binary reading", dtos.BaseReading{ValueType: common.ValueTypeBinary, BinaryReading: dtos.BinaryReading{MediaType: common.ContentTypeText, BinaryValue: []byte("0003293374")}}, "0003293374", ""},
+		{"Object reading", dtos.BaseReading{ValueType: common.ValueTypeObject, ObjectReading: dtos.ObjectReading{ObjectValue: map[string]interface{}{"door_closed": false}}}, `{"door_closed":false}`, ""},
+		{"Object reading of a CBOR event", dtos.BaseReading{ValueType: common.ValueTypeObject, ObjectReading: dtos.ObjectReading{ObjectValue: cborObject}}, `[{"SKU":"4900002470","delta":-2}]`, ""},
+		{"Unsupported media type", dtos.BaseReading{ResourceName: "snapshot", ValueType: common.ValueTypeBinary, BinaryReading: dtos.BinaryReading{MediaType: "image/jpeg", BinaryValue: []byte{0xff, 0xd8}}}, "", `binary reading snapshot has the unsupported media type "image/jpeg"`},
+		{"Invalid CBOR", dtos.BaseReading{ResourceName: "inferenceSkuDelta", ValueType: common.ValueTypeBinary, BinaryReading: dtos.BinaryReading{MediaType: common.ContentTypeCBOR, BinaryValue: []byte{0xbf}}}, "", "failed to decode reading inferenceSkuDelta: unexpected EOF"},
+	}
+
+	decoder := NewReadingDecoder()
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			event := dtos.Event{DeviceName: DsCardReader, Readings: []dtos.BaseReading{currentTest.Reading}}
+			decoded, err := decoder.Decode(event)
+			if currentTest.ExpectedError != "" {
+				require.EqualError(t, err, currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedValue, decoded.Readings[0].Value)
+			assert.Equal(t, currentTest.Reading.Value, event.Readings[0].Value, "the readings of the event are not changed")
+		})
+	}
+
+	// serializers can be registered for other media types
+	decoder.Register("Application/X-Upper", func(payload []byte) (string, error) {
+		return strings.ToUpper(string(payload)), nil
+	})
+	decoded, err := decoder.Decode(dtos.Event{Readings: []dtos.BaseReading{{ValueType: common.ValueTypeBinary, BinaryReading: dtos.BinaryReading{MediaType: "application/x-upper", BinaryValue: []byte("ok")}}}})
+	require.NoError(t, err)
+	assert.Equal(t, "OK", decoded.Readings[0].Value)
+}
+
+func TestDecodeReadings(t *testing.T) {
+	ctx := pkg.NewAppFuncContextForTest("", logger.NewMockClient())
+	decoder := NewReadingDecoder()
+
+	continuePipeline, result := decoder.DecodeReadings(ctx, nil)
+	assert.False(t, continuePipeline)
+	assert.Nil(t, result)
+
+	continuePipeline, result = decoder.DecodeReadings(ctx, "not an event")
+	assert.False(t, continuePipeline)
+	assert.EqualError(t, result.(error), "DecodeReadings expected an event, received string")
+
+	// a card number published as a CBOR string is read as it is
+	cborCardID, err := cbor.Marshal("0003293374")
+	require.NoError(t, err)
+	continuePipeline, result = decoder.DecodeReadings(ctx, dtos.Event{DeviceName: DsCardReader, Readings: []dtos.BaseReading{{ValueType: common.ValueTypeBinary, BinaryReading: dtos.BinaryReading{MediaType: common.ContentTypeCBOR, BinaryValue: cborCardID}}}})
+	require.True(t, continuePipeline)
+	assert.Equal(t, "0003293374", result.(dtos.Event).Readings[0].Value)
+}
@@ -0,0 +1,243 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// The remote commands that fleet-management tooling can send to a kiosk
+const (
+	RemoteCommandUnlock           = "unlock"
+	RemoteCommandLock             = "lock"
+	RemoteCommandRebootDisplay    = "rebootDisplay"
+	RemoteCommandEnterMaintenance = "enterMaintenance"
+)
+
+// The statuses of a remote command result
+const (
+	RemoteCommandDone     = "done"
+	RemoteCommandRejected = "rejected"
+	RemoteCommandFailed   = "failed"
+)
+
+// remoteCommandResponseSuffix is appended to the command topic for the
+// topic that the results are published to
+const remoteCommandResponseSuffix = "/response"
+
+var (
+	// ErrRemoteCommandNotAllowed is returned for a command that is not in the
+	// allow-list of the kiosk
+	ErrRemoteCommandNotAllowed = errors.New("the remote command is not allowed")
+	// ErrUnknownRemoteCommand is returned for a command that is not one of
+	// the remote commands
+	ErrUnknownRemoteCommand = errors.New("unknown remote command")
+)
+
+// RemoteCommand is a command sent to the kiosk on the remote command topic.
+// Token is the authentication token of a maintainer or admin card, Door is
+// the controller board of a door of a bank of coolers, empty for the door
+// of the service, and Reason is the maintenance reason of enterMaintenance,
+// empty for ReasonManual.
+type RemoteCommand struct {
+	ID      string            `json:"id"`
+	Command string            `json:"command"`
+	Door    string            `json:"door,omitempty"`
+	Reason  MaintenanceReason `json:"reason,omitempty"`
+	Token   string            `json:"token"`
+}
+
+// RemoteCommandResult is the result of a remote command, which is published
+// to the response topic
+type RemoteCommandResult struct {
+	ID        string `json:"id,omitempty"`
+	Command   string `json:"command,omitempty"`
+	KioskID   string `json:"kioskId,omitempty"`
+	Door      string `json:"door,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp,string"`
+}
+
+// RemoteCommands authenticates the remote commands and runs those in the
+// allow-list. The authorize function returns the person of the token of a
+// maintainer or admin card, and an error for any other token.
+type RemoteCommands struct {
+	allowed   map[string]bool
+	authorize func(token string) (int, error)
+}
+
+// NewRemoteCommands parses the comma separated allow-list of the commands
+// that may be run remotely
+func NewRemoteCommands(allowList string, authorize func(token string) (int, error)) (*RemoteCommands, error) {
+	commands := &RemoteCommands{allowed: map[string]bool{}, authorize: authorize}
+	for _, command := range strings.Split(allowList, ",") {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		switch command {
+		case RemoteCommandUnlock, RemoteCommandLock, RemoteCommandRebootDisplay, RemoteCommandEnterMaintenance:
+			commands.allowed[command] = true
+		default:
+			return nil, fmt.Errorf("%w %q in the allow-list", ErrUnknownRemoteCommand, command)
+		}
+	}
+	if len(commands.allowed) == 0 {
+		return nil, errors.New("the remote command allow-list is empty")
+	}
+	return commands, nil
+}
+
+// Handle authenticates the remote command of the payload and runs it on its
+// door of the vending state, and returns its result
+func (commands *RemoteCommands) Handle(lc logger.LoggingClient, vendingState *VendingState, payload []byte) RemoteCommandResult {
+	result := RemoteCommandResult{Timestamp: time.Now().UnixNano()}
+	if vendingState.Configuration != nil {
+		result.KioskID = vendingState.Configuration.KioskID
+	}
+	var command RemoteCommand
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&command); err != nil {
+		return result.rejected(lc, fmt.Errorf("failed to read the remote command: %s", err.Error()))
+	}
+	result.ID = command.ID
+	result.Command = command.Command
+	result.Door = command.Door
+
+	personID, err := commands.authorize(command.Token)
+	if err != nil {
+		return result.rejected(lc, err)
+	}
+	if !commands.allowed[command.Command] {
+		return result.rejected(lc, fmt.Errorf("%w: %s", ErrRemoteCommandNotAllowed, command.Command))
+	}
+	door, ok := vendingState.remoteCommandDoor(command.Door)
+	if !ok {
+		return result.rejected(lc, fmt.Errorf("no door has the device %q", command.Door))
+	}
+
+	lc.Infof("Running the remote command %s %s of person %d", command.ID, command.Command, personID)
+	if err := door.RunRemoteCommand(lc, command); err != nil {
+		if errors.Is(err, ErrVendInProgress) || errors.Is(err, ErrUnknownMaintenanceReason) || errors.Is(err, ErrManagedMaintenanceReason) {
+			return result.rejected(lc, err)
+		}
+		result.Status = RemoteCommandFailed
+		result.Error = err.Error()
+		lc.Errorf("The remote command %s %s failed: %s", command.ID, command.Command, err.Error())
+		return result
+	}
+	result.Status = RemoteCommandDone
+	return result
+}
+
+// rejected returns the result of a command that was not run for the error
+func (result RemoteCommandResult) rejected(lc logger.LoggingClient, err error) RemoteCommandResult {
+	lc.Warnf("Rejected the remote command %s %s: %s", result.ID, result.Command, err.Error())
+	result.Status = RemoteCommandRejected
+	result.Error = err.Error()
+	return result
+}
+
+// remoteCommandDoor returns the door of a remote command, which is the
+// controller board of a door of the bank of coolers, or the door of the
+// vending state without one
+func (vendingState *VendingState) remoteCommandDoor(deviceName string) (*VendingState, bool) {
+	if deviceName == "" {
+		return vendingState, true
+	}
+	if door, ok := vendingState.Doors.Door(deviceName); ok {
+		return door, true
+	}
+	if vendingState.Doors == nil && vendingState.Configuration != nil && deviceName == vendingState.Configuration.ControllerBoardDeviceName {
+		return vendingState, true
+	}
+	return nil, false
+}
+
+// RunRemoteCommand runs the remote command on the door. The door is only
+// unlocked or locked while no customer is vending, so that a vend is never
+// left with a door it does not expect.
+func (vendingState *VendingState) RunRemoteCommand(lc logger.LoggingClient, command RemoteCommand) error {
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	deviceName := vendingState.Configuration.ControllerBoardDeviceName
+	switch command.Command {
+	case RemoteCommandUnlock, RemoteCommandLock:
+		if vendingState.Workflow.Vending() || vendingState.SessionLingering {
+			return ErrVendInProgress
+		}
+		settings := make(map[string]string)
+		settings["lock1"] = strconv.FormatBool(command.Command == RemoteCommandUnlock)
+		return vendingState.SendCommand(lc, http.MethodPut, deviceName, vendingState.Configuration.ControllerBoardLock1Cmd, settings)
+
+	case RemoteCommandRebootDisplay:
+		settings := make(map[string]string)
+		settings["displayReset"] = ""
+		if err := vendingState.SendCommand(lc, http.MethodPut, deviceName, vendingState.Configuration.ControllerBoardDisplayResetCmd, settings); err != nil {
+			return err
+		}
+		// a door out of service shows why again
+		if vendingState.MaintenanceMode {
+			vendingState.displayMaintenance(lc)
+		}
+		return nil
+
+	case RemoteCommandEnterMaintenance:
+		_, err := vendingState.SetMaintenanceMode(lc, MaintenanceModeRequest{MaintenanceMode: true, Reason: command.Reason})
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownRemoteCommand, command.Command)
+}
+
+// SubscribeRemoteCommands connects to the MQTT broker and runs the remote
+// commands received on the topic until the context is done. The result of
+// each command is published to the topic followed by /response. The
+// subscription is renewed whenever the connection is.
+func (vendingState *VendingState) SubscribeRemoteCommands(ctx context.Context, lc logger.LoggingClient, broker string, topic string, commands *RemoteCommands) {
+	responseTopic := topic + remoteCommandResponseSuffix
+	handler := func(client mqtt.Client, message mqtt.Message) {
+		result := commands.Handle(lc, vendingState, message.Payload())
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			lc.Errorf("Failed to marshal the result of the remote command %s: %s", result.ID, err.Error())
+			return
+		}
+		if token := client.Publish(responseTopic, 1, false, resultJSON); token.Wait() && token.Error() != nil {
+			lc.Errorf("Failed to publish the result of the remote command %s: %s", result.ID, token.Error().Error())
+		}
+	}
+
+	options := mqtt.NewClientOptions().AddBroker(broker).SetAutoReconnect(true).SetConnectRetry(true)
+	if vendingState.Configuration.KioskID != "" {
+		options.SetClientID("as-vending-" + vendingState.Configuration.KioskID)
+	}
+	options.SetOnConnectHandler(func(client mqtt.Client) {
+		if token := client.Subscribe(topic, 1, handler); token.Wait() && token.Error() != nil {
+			lc.Errorf("Failed to subscribe to the remote command topic %s: %s", topic, token.Error().Error())
+			return
+		}
+		lc.Infof("Subscribed to the remote command topic %s at %s", topic, broker)
+	})
+	options.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		lc.Warnf("Lost the connection to the remote command broker %s: %s", broker, err.Error())
+	})
+
+	client := mqtt.NewClient(options)
+	client.Connect()
+	<-ctx.Done()
+	client.Disconnect(250)
+}
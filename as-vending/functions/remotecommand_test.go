@@ -0,0 +1,92 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"errors"
+	"testing"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// authorizeTestToken accepts the "maintainer" token of person 7
+func authorizeTestToken(token string) (int, error) {
+	if token != "maintainer" {
+		return 0, errors.New("the token is not valid")
+	}
+	return 7, nil
+}
+
+func TestNewRemoteCommands(t *testing.T) {
+	commands, err := NewRemoteCommands(" lock, rebootDisplay ,", authorizeTestToken)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{RemoteCommandLock: true, RemoteCommandRebootDisplay: true}, commands.allowed)
+
+	_, err = NewRemoteCommands("lock,selfDestruct", authorizeTestToken)
+	assert.ErrorIs(t, err, ErrUnknownRemoteCommand)
+	_, err = NewRemoteCommands(" ", authorizeTestToken)
+	assert.Error(t, err)
+}
+
+func TestRemoteCommandsHandle(t *testing.T) {
+	tests := []struct {
+		Name            string
+		Payload         string
+		Vending         bool
+		ExpectedStatus  string
+		ExpectedLock    string
+		ExpectedReasons []MaintenanceReason
+	}{
+		{"Unlock", `{"id":"1","command":"unlock","token":"maintainer"}`, false, RemoteCommandDone, "true", nil},
+		{"Lock", `{"id":"1","command":"lock","door":"controller-board","token":"maintainer"}`, false, RemoteCommandDone, "false", nil},
+		{"Enter maintenance", `{"id":"1","command":"enterMaintenance","token":"maintainer"}`, false, RemoteCommandDone, "", []MaintenanceReason{ReasonManual}},
+		{"Managed maintenance reason", `{"id":"1","command":"enterMaintenance","reason":"storeClosed","token":"maintainer"}`, false, RemoteCommandRejected, "", nil},
+		{"Reboot display", `{"id":"1","command":"rebootDisplay","token":"maintainer"}`, false, RemoteCommandRejected, "", nil},
+		{"Unlock during a vend", `{"id":"1","command":"unlock","token":"maintainer"}`, true, RemoteCommandRejected, "", nil},
+		{"Invalid token", `{"id":"1","command":"unlock","token":"customer"}`, false, RemoteCommandRejected, "", nil},
+		{"Unknown door", `{"id":"1","command":"unlock","door":"other-board","token":"maintainer"}`, false, RemoteCommandRejected, "", nil},
+		{"Unknown field", `{"id":"1","command":"unlock","token":"maintainer","force":true}`, false, RemoteCommandRejected, "", nil},
+	}
+
+	commands, err := NewRemoteCommands("unlock,lock,enterMaintenance", authorizeTestToken)
+	require.NoError(t, err)
+	lc := logger.NewMockClient()
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			vendingState := newMaintenanceVendingState(nil)
+			vendingState.Configuration.ControllerBoardLock1Cmd = "lock1"
+			if currentTest.Vending {
+				vendingState.Workflow = NewWorkflow(StateAuthorized)
+			}
+
+			result := commands.Handle(lc, vendingState, []byte(currentTest.Payload))
+			assert.Equal(t, currentTest.ExpectedStatus, result.Status, result.Error)
+			assert.Equal(t, "kiosk-1", result.KioskID)
+			assert.Equal(t, currentTest.ExpectedReasons, vendingState.MaintenanceReasons)
+			mockCommandClient := vendingState.CommandClient.(*client_mocks.CommandClient)
+			if currentTest.ExpectedLock == "" {
+				mockCommandClient.AssertNotCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", mock.Anything)
+				return
+			}
+			assert.Equal(t, "1", result.ID)
+			mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", map[string]string{"lock1": currentTest.ExpectedLock})
+		})
+	}
+}
+
+func TestRunRemoteCommandRebootDisplay(t *testing.T) {
+	vendingState := newMaintenanceVendingState(nil)
+	vendingState.MaintenanceMode = true
+	vendingState.MaintenanceReasons = []MaintenanceReason{ReasonDoorLeftOpen}
+	require.NoError(t, vendingState.RunRemoteCommand(logger.NewMockClient(), RemoteCommand{Command: RemoteCommandRebootDisplay}))
+
+	mockCommandClient := vendingState.CommandClient.(*client_mocks.CommandClient)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayReset", map[string]string{"displayReset": ""})
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayRow2", map[string]string{"displayRow2": "Door left open"})
+}
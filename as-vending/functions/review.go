@@ -0,0 +1,116 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// planogram is the response of the inventory service's planogram endpoint,
+// of which only the SKUs of the slots are needed
+type planogram struct {
+	Data []struct {
+		SKU string `json:"sku"`
+	} `json:"data"`
+}
+
+// reviewInference records why the inference result of the current vend
+// needs review before it is charged: its confidence, or the confidence of
+// one of its items, is below the configured thresholds. Results without a
+// confidence are not checked.
+func (vendingState *VendingState) reviewInference(lc logger.LoggingClient, payload InferencePayload) {
+	if vendingState.Configuration == nil {
+		return
+	}
+	reasons := []string{}
+	minConfidence := vendingState.Configuration.InferenceMinConfidence
+	if minConfidence > 0 && payload.Confidence != nil && *payload.Confidence < minConfidence {
+		reasons = append(reasons, fmt.Sprintf("Inference confidence %.2f is below %.2f", *payload.Confidence, minConfidence))
+	}
+	minItemConfidence := vendingState.Configuration.InferenceMinItemConfidence
+	for _, item := range payload.Items {
+		if minItemConfidence > 0 && item.Confidence != nil && *item.Confidence < minItemConfidence {
+			reasons = append(reasons, fmt.Sprintf("Inference confidence %.2f of SKU %s is below %.2f", *item.Confidence, item.SKU, minItemConfidence))
+		}
+	}
+	for _, reason := range reasons {
+		lc.Warnf("The inference result of session %s needs review: %s", vendingState.SessionID, reason)
+	}
+	vendingState.reviewReasons = append(vendingState.reviewReasons, reasons...)
+}
+
+// basketFlagReason returns why the basket of the session is flagged for
+// review in the ledger instead of being charged, or an empty string when it
+// is charged as usual. The reasons are those of the inference results of the
+// session, the items being entered by hand, and the basket failing the
+// sanity checks.
+func (vendingState *VendingState) basketFlagReason(lc logger.LoggingClient, skuDelta []deltaSKU) string {
+	reasons := []string{}
+	if vendingState.InferenceFallbackMode == config.InferenceFallbackManualEntry {
+		reasons = append(reasons, manualEntryFlagReason)
+	}
+	reasons = append(reasons, vendingState.reviewReasons...)
+
+	taken := 0
+	for _, item := range skuDelta {
+		if item.Delta < 0 {
+			taken -= item.Delta
+		}
+	}
+	if maxItems := vendingState.Configuration.MaxItemsPerSession; maxItems > 0 && taken > maxItems {
+		reasons = append(reasons, fmt.Sprintf("%d items were taken, more than the %d allowed per session", taken, maxItems))
+	}
+
+	if vendingState.Configuration.PlanogramEndpoint != "" {
+		unstocked, err := vendingState.unstockedSKUs(lc, skuDelta)
+		if err != nil {
+			// the basket is not held back because the planogram is unreachable
+			lc.Errorf("Failed to check the basket against the planogram: %s", err.Error())
+		}
+		for _, sku := range unstocked {
+			reasons = append(reasons, fmt.Sprintf("SKU %s is not stocked in the planogram", sku))
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// unstockedSKUs returns the SKUs taken that are not stocked in any slot of
+// the planogram. Nothing is checked against a planogram without slots.
+func (vendingState *VendingState) unstockedSKUs(lc logger.LoggingClient, skuDelta []deltaSKU) ([]string, error) {
+	resp, err := sendHTTPRequest(lc, http.MethodGet, vendingState.Configuration.PlanogramEndpoint, []byte(""))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the planogram: %s", err.Error())
+	}
+	var slots planogram
+	if err := json.Unmarshal(body, &slots); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the planogram: %s", err.Error())
+	}
+
+	unstocked := []string{}
+	if len(slots.Data) == 0 {
+		return unstocked, nil
+	}
+	stocked := map[string]bool{}
+	for _, slot := range slots.Data {
+		stocked[slot.SKU] = true
+	}
+	for _, item := range skuDelta {
+		if item.Delta < 0 && !stocked[item.SKU] {
+			unstocked = append(unstocked, item.SKU)
+		}
+	}
+	return unstocked, nil
+}
@@ -0,0 +1,89 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewInference(t *testing.T) {
+	low := 0.4
+	high := 0.95
+	tests := []struct {
+		Name            string
+		Payload         InferencePayload
+		ExpectedReasons []string
+	}{
+		{"Confident", InferencePayload{Confidence: &high, Items: []InferenceItem{{SKU: "HXI86WHU", Delta: -1, Confidence: &high}}}, nil},
+		{"No confidence", InferencePayload{Items: []InferenceItem{{SKU: "HXI86WHU", Delta: -1}}}, nil},
+		{"Low confidence", InferencePayload{Confidence: &low, Items: []InferenceItem{{SKU: "HXI86WHU", Delta: -1}}}, []string{"Inference confidence 0.40 is below 0.80"}},
+		{"Low item confidence", InferencePayload{Confidence: &high, Items: []InferenceItem{{SKU: "HXI86WHU", Delta: -1, Confidence: &low}}}, []string{"Inference confidence 0.40 of SKU HXI86WHU is below 0.60"}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			vendingState := VendingState{Workflow: NewWorkflow(StateIdle), Configuration: &config.VendingConfig{InferenceMinConfidence: 0.8, InferenceMinItemConfidence: 0.6}}
+			vendingState.reviewInference(logger.NewMockClient(), currentTest.Payload)
+			assert.Equal(t, currentTest.ExpectedReasons, vendingState.reviewReasons)
+		})
+	}
+
+	// without thresholds nothing is checked
+	vendingState := VendingState{Workflow: NewWorkflow(StateIdle), Configuration: &config.VendingConfig{}}
+	vendingState.reviewInference(logger.NewMockClient(), InferencePayload{Confidence: &low})
+	assert.Empty(t, vendingState.reviewReasons)
+}
+
+func TestBasketFlagReason(t *testing.T) {
+	planogramServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"shelf":1,"lane":1,"sku":"HXI86WHU","capacity":8}]}`))
+	}))
+	defer planogramServer.Close()
+	emptyPlanogramServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer emptyPlanogramServer.Close()
+
+	tests := []struct {
+		Name              string
+		Fallback          string
+		ReviewReasons     []string
+		MaxItems          int
+		PlanogramEndpoint string
+		SKUDelta          []deltaSKU
+		Expected          string
+	}{
+		{"Charged", "", nil, 3, planogramServer.URL, []deltaSKU{{SKU: "HXI86WHU", Delta: -3}}, ""},
+		{"Low confidence", "", []string{"Inference confidence 0.40 is below 0.80"}, 0, "", []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, "Inference confidence 0.40 is below 0.80"},
+		{"Entered by hand", config.InferenceFallbackManualEntry, nil, 0, "", []deltaSKU{{SKU: "HXI86WHU", Delta: -1}}, manualEntryFlagReason},
+		{"Too many items", "", nil, 3, "", []deltaSKU{{SKU: "HXI86WHU", Delta: -3}, {SKU: "1200050408", Delta: -1}, {SKU: "4900002470", Delta: 1}}, "4 items were taken, more than the 3 allowed per session"},
+		{"Not in the planogram", "", nil, 0, planogramServer.URL, []deltaSKU{{SKU: "HXI86WHU", Delta: -1}, {SKU: "1200050408", Delta: -1}, {SKU: "4900002470", Delta: 1}}, "SKU 1200050408 is not stocked in the planogram"},
+		{"Empty planogram", "", nil, 0, emptyPlanogramServer.URL, []deltaSKU{{SKU: "1200050408", Delta: -1}}, ""},
+		{"Planogram unreachable", "", nil, 0, "http://localhost:0/planogram", []deltaSKU{{SKU: "1200050408", Delta: -1}}, ""},
+		{"Several reasons", "", []string{"Inference confidence 0.40 is below 0.80"}, 1, "", []deltaSKU{{SKU: "HXI86WHU", Delta: -2}}, "Inference confidence 0.40 is below 0.80; 2 items were taken, more than the 1 allowed per session"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			vendingState := VendingState{
+				Workflow:              NewWorkflow(StateIdle),
+				InferenceFallbackMode: currentTest.Fallback,
+				reviewReasons:         currentTest.ReviewReasons,
+				Configuration: &config.VendingConfig{
+					MaxItemsPerSession: currentTest.MaxItems,
+					PlanogramEndpoint:  currentTest.PlanogramEndpoint,
+				},
+			}
+			assert.Equal(t, currentTest.Expected, vendingState.basketFlagReason(logger.NewMockClient(), currentTest.SKUDelta))
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+)
+
+// A slow shopper may close the door and open it again to take more items.
+// When SessionLinger is set, the inference results of each visit are added
+// to the session basket, and the customer can scan the same card again to
+// reopen the door without being authenticated or charged in between. The
+// basket is charged as one transaction once the linger window passes without
+// the door being reopened, or another card is scanned.
+
+// SessionStage is the step of the vend workflow a kiosk is at
+type SessionStage string
+
+const (
+	// SessionIdle is a kiosk waiting for a customer
+	SessionIdle SessionStage = "idle"
+	// SessionAwaitingPin is a card waiting for its PIN before the door is
+	// unlocked
+	SessionAwaitingPin SessionStage = "awaitingPin"
+	// SessionUnlocked is an unlocked door waiting to be opened
+	SessionUnlocked SessionStage = "unlocked"
+	// SessionDoorOpen is an open door waiting to be closed
+	SessionDoorOpen SessionStage = "doorOpen"
+	// SessionVerifying is a closed door waiting for the inference result
+	SessionVerifying SessionStage = "verifying"
+	// SessionLingering is a session waiting for the customer to reopen the
+	// door before its basket is charged
+	SessionLingering SessionStage = "lingering"
+	// SessionEnrolling is a kiosk waiting for a card swipe to enroll
+	SessionEnrolling SessionStage = "enrolling"
+	// SessionMaintenance is a kiosk out of service
+	SessionMaintenance SessionStage = "maintenance"
+)
+
+// vending returns whether the stage is part of a customer's vend
+func (stage SessionStage) vending() bool {
+	switch stage {
+	case SessionAwaitingPin, SessionUnlocked, SessionDoorOpen, SessionVerifying, SessionLingering:
+		return true
+	}
+	return false
+}
+
+// SessionStatus is the progress of the current session, which the LCD and
+// the UI both show so that they agree. The countdown is to the timeout of
+// the stage, and the items are those detected during the earlier visits of a
+// lingering session.
+type SessionStatus struct {
+	Stage            SessionStage `json:"stage"`
+	SessionID        string       `json:"sessionId,omitempty"`
+	CardID           string       `json:"cardId,omitempty"`
+	AccountID        int          `json:"accountId,omitempty"`
+	RoleID           int          `json:"roleId,omitempty"`
+	TimeoutAt        int64        `json:"timeoutAt,string,omitempty"`
+	RemainingSeconds int          `json:"remainingSeconds"`
+	Items            []deltaSKU   `json:"items"`
+	ItemCount        int          `json:"itemCount"` // units taken so far
+	KioskID          string       `json:"kioskId"`
+}
+
+// CurrentSession returns the progress of the current session at now
+func (vendingState *VendingState) CurrentSession(now time.Time) SessionStatus {
+	status := SessionStatus{Stage: SessionIdle, Items: []deltaSKU{}}
+	if vendingState.Configuration != nil {
+		status.KioskID = vendingState.Configuration.KioskID
+	}
+	workflowState := vendingState.Workflow.State()
+	switch {
+	case vendingState.SessionLingering:
+		status.Stage = SessionLingering
+	case workflowState == StateInferring || workflowState == StateSettling:
+		status.Stage = SessionVerifying
+	case workflowState == StateDoorOpen:
+		status.Stage = SessionDoorOpen
+	case workflowState == StateAuthorized:
+		status.Stage = SessionUnlocked
+	case vendingState.PinEntry.Waiting():
+		status.Stage = SessionAwaitingPin
+	case vendingState.Enrollment.Waiting():
+		status.Stage = SessionEnrolling
+	case vendingState.MaintenanceMode:
+		status.Stage = SessionMaintenance
+	}
+	if !status.Stage.vending() {
+		return status
+	}
+
+	status.SessionID = vendingState.SessionID
+	status.CardID = vendingState.CurrentUserData.CardID
+	status.AccountID = vendingState.CurrentUserData.AccountID
+	status.RoleID = vendingState.CurrentUserData.RoleID
+	status.Items = append(status.Items, vendingState.SessionBasket...)
+	for _, item := range status.Items {
+		if item.Delta < 0 {
+			status.ItemCount -= item.Delta
+		}
+	}
+	if !vendingState.StageDeadline.IsZero() {
+		status.TimeoutAt = vendingState.StageDeadline.UnixNano()
+		if remaining := vendingState.StageDeadline.Sub(now); remaining > 0 {
+			status.RemainingSeconds = int(math.Ceil(remaining.Seconds()))
+		}
+	}
+	return status
+}
+
+// lingerSession adds the SKU delta of a visit to the session basket, and
+// waits for the customer to come back before charging it
+func (vendingState *VendingState) lingerSession(lc logger.LoggingClient, skuDelta []deltaSKU) {
+	vendingState.SessionBasket = mergeSKUDeltas(vendingState.SessionBasket, skuDelta)
+	vendingState.SessionLingering = true
+	vendingState.Transition(lc, StateIdle, "sessionLingering")
+	lc.Infof("Inference complete, waiting %v for card %s to reopen the door", vendingState.SessionLinger, vendingState.CurrentUserData.CardID)
+	// Close all thread to ensure all threads are cleaned up before the next card is scanned.
+	close(vendingState.ThreadStopChannel)
+	vendingState.ThreadStopChannel = make(chan int)
+
+	settings := make(map[string]string)
+	settings["displayRow2"] = "Scan card to reopen"
+	if err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings); err != nil {
+		lc.Errorf("Failed to display the session prompt: %s", err.Error())
+	}
+
+	vendingState.awaitSessionEnd(lc, time.Now().Add(vendingState.SessionLinger))
+}
+
+// awaitSessionEnd waits until the deadline for the customer to reopen the
+// door, and ends the session when they do not. The linger state is guarded by
+// the session lock like the rest of the session, so the thread ends it while
+// holding the lock, unless the session was resumed or ended meanwhile.
+func (vendingState *VendingState) awaitSessionEnd(lc logger.LoggingClient, deadline time.Time) {
+	vendingState.StageDeadline = deadline
+	vendingState.journalSession(lc)
+	stopChannel := make(chan int)
+	vendingState.SessionLingerStopChannel = stopChannel
+	go func() {
+		select {
+		case <-time.After(time.Until(deadline)):
+			vendingState.LockSession()
+			defer vendingState.UnlockSession()
+			if stopped(stopChannel) {
+				lc.Info("Stopped the session linger thread")
+				return
+			}
+			lc.Info("Session linger window passed")
+			if err := vendingState.EndSession(lc); err != nil {
+				lc.Errorf("Failed to end the session: %s", err.Error())
+			}
+		case <-stopChannel:
+			lc.Info("Stopped the session linger thread")
+		}
+	}()
+}
+
+// resumeSession unlocks the door again for the customer of a lingering
+// session, who is already authenticated
+func (vendingState *VendingState) resumeSession(lc logger.LoggingClient, event dtos.Event) (bool, interface{}) {
+	scannedAt := time.Now()
+	vendingState.stopLingering()
+	lc.Infof("Card %s reopened the door during its session", vendingState.CurrentUserData.CardID)
+
+	settings := make(map[string]string)
+	settings["displayRow2"] = "welcome back"
+	err := vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardDisplayRow2Cmd, settings)
+	if err != nil {
+		return false, err
+	}
+
+	settings = make(map[string]string)
+	settings["lock1"] = "true"
+	// unlock
+	err = vendingState.SendCommand(lc, http.MethodPut, vendingState.Configuration.ControllerBoardDeviceName, vendingState.Configuration.ControllerBoardLock1Cmd, settings)
+	if err != nil {
+		return false, err
+	}
+	vendingState.SLA.Record(lc, SLAStageUnlock, time.Since(scannedAt), vendingState.CurrentUserData)
+
+	// the split payers and the basket are kept for the rest of the session
+	vendingState.Transition(lc, StateAuthorized, "sessionResumed")
+	vendingState.waitForDoorOpen(lc)
+	return true, event
+}
+
+// EndSession charges the session basket, if any, as one transaction and
+// resets the workflow. The session is ended even when the basket cannot be
+// charged, so that the vending machine is not stuck with it.
+func (vendingState *VendingState) EndSession(lc logger.LoggingClient) error {
+	vendingState.stopLingering()
+	basket := vendingState.SessionBasket
+	vendingState.SessionBasket = nil
+
+	var err error
+	if basket != nil {
+		lc.Infof("Ending the session of card %s", vendingState.CurrentUserData.CardID)
+		if err = vendingState.settleBasket(lc, basket); err != nil {
+			err = fmt.Errorf("failed to charge the session basket %v of account %d: %s", basket, vendingState.CurrentUserData.AccountID, err.Error())
+		}
+	}
+	if vendingState.SessionID != "" {
+		vendingState.notifyWebhooks(lc, WebhookSessionCompleted, "sessionEnded", "")
+	}
+	vendingState.CurrentUserData = OutputData{}
+	vendingState.SplitPayers = nil
+	vendingState.SessionID = ""
+	vendingState.Metrics.SetActiveSessions(0)
+	vendingState.finishVend(lc, "sessionEnded")
+	return err
+}
+
+// stopLingering stops waiting for the customer to reopen the door
+func (vendingState *VendingState) stopLingering() {
+	if !vendingState.SessionLingering {
+		return
+	}
+	vendingState.SessionLingering = false
+	if vendingState.SessionLingerStopChannel != nil {
+		close(vendingState.SessionLingerStopChannel)
+		vendingState.SessionLingerStopChannel = nil
+	}
+}
+
+// mergeSKUDeltas adds the SKU delta of a visit to the basket, in the order
+// the SKUs were first taken. SKUs that were put back are left out.
+func mergeSKUDeltas(basket []deltaSKU, skuDelta []deltaSKU) []deltaSKU {
+	totals := make(map[string]int)
+	var skus []string
+	for _, item := range append(append([]deltaSKU{}, basket...), skuDelta...) {
+		if _, ok := totals[item.SKU]; !ok {
+			skus = append(skus, item.SKU)
+		}
+		totals[item.SKU] += item.Delta
+	}
+
+	merged := []deltaSKU{}
+	for _, sku := range skus {
+		if totals[sku] != 0 {
+			merged = append(merged, deltaSKU{SKU: sku, Delta: totals[sku]})
+		}
+	}
+	return merged
+}
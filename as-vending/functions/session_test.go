@@ -0,0 +1,353 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/responses"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeSKUDeltas(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Basket   []deltaSKU
+		SKUDelta []deltaSKU
+		Expected []deltaSKU
+	}{
+		{"First visit", nil, []deltaSKU{{SKU: "A", Delta: -2}}, []deltaSKU{{SKU: "A", Delta: -2}}},
+		{"Nothing taken", nil, []deltaSKU{}, []deltaSKU{}},
+		{"Same SKU again", []deltaSKU{{SKU: "A", Delta: -2}}, []deltaSKU{{SKU: "A", Delta: -1}}, []deltaSKU{{SKU: "A", Delta: -3}}},
+		{"Another SKU", []deltaSKU{{SKU: "A", Delta: -2}}, []deltaSKU{{SKU: "B", Delta: -1}}, []deltaSKU{{SKU: "A", Delta: -2}, {SKU: "B", Delta: -1}}},
+		{"Put back", []deltaSKU{{SKU: "A", Delta: -1}, {SKU: "B", Delta: -1}}, []deltaSKU{{SKU: "A", Delta: 1}}, []deltaSKU{{SKU: "B", Delta: -1}}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			assert.Equal(t, currentTest.Expected, mergeSKUDeltas(currentTest.Basket, currentTest.SKUDelta))
+		})
+	}
+}
+
+func TestInventoryDeltas(t *testing.T) {
+	tests := []struct {
+		Name           string
+		RoleID         int
+		ExpectedReason string
+	}{
+		{"Customer", 1, "sale"},
+		{"Item stocker", 2, "restock"},
+		{"Technician test vend", 4, "test"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			vendingState := VendingState{
+				Workflow:        NewWorkflow(StateIdle),
+				CurrentUserData: OutputData{AccountID: 1, RoleID: currentTest.RoleID, CardID: "0003293374"},
+				SessionID:       "session-1",
+			}
+			source := deltaSource{Service: "as-vending", User: "0003293374", SessionID: "session-1"}
+			assert.Equal(t, []inventoryDelta{{SKU: "A", Delta: -2, Reason: currentTest.ExpectedReason, Source: source}}, vendingState.inventoryDeltas([]deltaSKU{{SKU: "A", Delta: -2}}))
+		})
+	}
+}
+
+func TestParseSessionLinger(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Duration      string
+		Expected      time.Duration
+		ExpectedError bool
+	}{
+		{"Disabled", "", 0, false},
+		{"Valid", "30s", 30 * time.Second, false},
+		{"Invalid", "thirty", 0, true},
+		{"Negative", "-30s", 0, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			vendingState := VendingState{
+				Workflow: NewWorkflow(StateIdle),
+				Configuration: &config.VendingConfig{
+					DoorCloseStateTimeoutDuration: "20s",
+					DoorOpenStateTimeoutDuration:  "15s",
+					InferenceTimeoutDuration:      "20s",
+					SessionLingerDuration:         currentTest.Duration,
+				},
+			}
+			err := vendingState.ParseDurationFromConfig()
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, vendingState.SessionLinger)
+		})
+	}
+}
+
+// sessionServices records the requests of a vend to the ledger, inventory
+// and audit log. The mutex guards them from the session linger thread.
+type sessionServices struct {
+	mutex     sync.Mutex
+	intents   []basketIntent
+	ledgers   []deltaLedger
+	inventory [][]inventoryDelta
+	auditLog  []AuditLogEntry
+	auth      int
+}
+
+func newSessionServer(t *testing.T, services *sessionServices, auth OutputData) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		services.mutex.Lock()
+		defer services.mutex.Unlock()
+		switch {
+		case r.URL.Path == "/ledger/intents":
+			var intent basketIntent
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&intent))
+			services.intents = append(services.intents, intent)
+		case r.URL.Path == "/ledger":
+			var ledger deltaLedger
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&ledger))
+			services.ledgers = append(services.ledgers, ledger)
+			outputJSON, err := json.Marshal(Ledger{TransactionID: 123, LineTotal: 1.99})
+			require.NoError(t, err)
+			w.Write(outputJSON)
+		case r.URL.Path == "/inventory/delta":
+			var delta []inventoryDelta
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&delta))
+			services.inventory = append(services.inventory, delta)
+		case r.URL.Path == "/auditlog":
+			var entry AuditLogEntry
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&entry))
+			services.auditLog = append(services.auditLog, entry)
+		case strings.HasPrefix(r.URL.Path, "/authentication/"):
+			services.auth++
+			outputJSON, err := json.Marshal(auth)
+			require.NoError(t, err)
+			w.Write(outputJSON)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newSessionVendingState(serverURL string) (*VendingState, *client_mocks.CommandClient) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	eventResp := responses.NewEventResponse("", "", http.StatusOK, dtos.Event{})
+	mockCommandClient.On("IssueGetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&eventResp, nil)
+
+	return &VendingState{
+		InferenceWaitThreadStopChannel: make(chan int),
+		ThreadStopChannel:              make(chan int),
+		Workflow:                       NewWorkflow(StateAuthorized),
+		CurrentUserData:                OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374"},
+		SessionID:                      "session-1",
+		Timeouts:                       NewStageTimeouts(StageDurations{DoorOpen: time.Hour}),
+		SessionLinger:                  time.Hour,
+		Configuration: &config.VendingConfig{
+			AuthenticationEndpoint:         serverURL + "/authentication",
+			InventoryService:               serverURL + "/inventory/delta",
+			InventoryAuditLogService:       serverURL + "/auditlog",
+			LedgerService:                  serverURL + "/ledger",
+			ControllerBoardDisplayResetCmd: "displayreset",
+			ControllerBoardDisplayRow1Cmd:  "displayrow1",
+			ControllerBoardDisplayRow2Cmd:  "displayrow2",
+			ControllerBoardDisplayRow3Cmd:  "displayrow3",
+			ControllerBoardLock1Cmd:        "lock1",
+		},
+		CommandClient: mockCommandClient,
+	}, mockCommandClient
+}
+
+func inferenceEvent(value string) dtos.Event {
+	return dtos.Event{
+		DeviceName: InferenceMQTTDevice,
+		Readings: []dtos.BaseReading{
+			{
+				ResourceName:  "inferenceSkuDelta",
+				SimpleReading: dtos.SimpleReading{Value: value},
+			},
+		},
+	}
+}
+
+func cardEvent(cardID string) dtos.Event {
+	return dtos.Event{
+		DeviceName: DsCardReader,
+		Readings: []dtos.BaseReading{
+			{
+				DeviceName:    DsCardReader,
+				SimpleReading: dtos.SimpleReading{Value: cardID},
+			},
+		},
+	}
+}
+
+func TestSessionLinger(t *testing.T) {
+	services := &sessionServices{}
+	server := newSessionServer(t, services, OutputData{})
+	defer server.Close()
+	vendingState, mockCommandClient := newSessionVendingState(server.URL)
+	vendingState.Metrics = NewVendingMetrics()
+	vendingState.Metrics.SetActiveSessions(1)
+	lc := logger.NewMockClient()
+
+	// the first visit is kept in the basket instead of being charged
+	_, err := vendingState.HandleMqttDeviceReading(lc, inferenceEvent(`[{"SKU": "A", "delta": -2}]`))
+	require.Nil(t, err)
+	assert.True(t, vendingState.SessionLingering)
+	assert.False(t, vendingState.Workflow.Vending())
+	assert.Equal(t, []deltaSKU{{SKU: "A", Delta: -2}}, vendingState.SessionBasket)
+	assert.Empty(t, services.ledgers)
+	assert.Empty(t, services.inventory)
+	assert.Empty(t, services.auditLog)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "displayrow2", map[string]string{"displayRow2": "Scan card to reopen"})
+
+	// the same card reopens the door without being authenticated again
+	ok, _ := vendingState.VerifyDoorAccess(lc, cardEvent("0003293374"))
+	assert.True(t, ok)
+	assert.Zero(t, services.auth)
+	assert.False(t, vendingState.SessionLingering)
+	assert.True(t, vendingState.Workflow.Vending())
+	assert.Equal(t, 1, vendingState.CurrentUserData.AccountID)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, mock.Anything, "lock1", map[string]string{"lock1": "true"})
+
+	// the second visit puts one item back and takes another
+	_, err = vendingState.HandleMqttDeviceReading(lc, inferenceEvent(`[{"SKU": "A", "delta": 1}, {"SKU": "B", "delta": -1}]`))
+	require.Nil(t, err)
+	assert.True(t, vendingState.SessionLingering)
+	assert.Empty(t, services.ledgers)
+
+	// both visits are charged as one transaction
+	require.NoError(t, vendingState.EndSession(lc))
+	expected := []deltaSKU{{SKU: "A", Delta: -1}, {SKU: "B", Delta: -1}}
+	require.Len(t, services.ledgers, 1)
+	assert.Equal(t, deltaLedger{AccountID: 1, DeltaSKUs: expected, SessionID: "session-1"}, services.ledgers[0])
+	// the intent of the session is recorded with the basket of every visit
+	require.Len(t, services.intents, 2)
+	assert.Equal(t, basketIntent{SessionID: "session-1", AccountID: 1, DeltaSKUs: []deltaSKU{{SKU: "A", Delta: -2}}}, services.intents[0])
+	assert.Equal(t, expected, services.intents[1].DeltaSKUs)
+	// the inventory deltas are sales of the card and session
+	source := deltaSource{Service: "as-vending", User: "0003293374", SessionID: "session-1"}
+	expectedInventory := []inventoryDelta{{SKU: "A", Delta: -1, Reason: "sale", Source: source}, {SKU: "B", Delta: -1, Reason: "sale", Source: source}}
+	assert.Equal(t, [][]inventoryDelta{expectedInventory}, services.inventory)
+	require.Len(t, services.auditLog, 1)
+	assert.Equal(t, "0003293374", services.auditLog[0].CardID)
+	assert.Equal(t, expectedInventory, services.auditLog[0].InventoryDelta)
+
+	assert.False(t, vendingState.SessionLingering)
+	assert.Nil(t, vendingState.SessionBasket)
+	assert.Equal(t, OutputData{}, vendingState.CurrentUserData)
+	assert.Empty(t, vendingState.SessionID)
+	assert.False(t, vendingState.Workflow.Vending())
+	assert.Zero(t, vendingState.Metrics.activeSessions.Value())
+}
+
+func TestSessionLingerExpires(t *testing.T) {
+	services := &sessionServices{}
+	server := newSessionServer(t, services, OutputData{})
+	defer server.Close()
+	vendingState, _ := newSessionVendingState(server.URL)
+	vendingState.SessionLinger = 10 * time.Millisecond
+
+	_, err := vendingState.HandleMqttDeviceReading(logger.NewMockClient(), inferenceEvent(`[{"SKU": "A", "delta": -2}]`))
+	require.Nil(t, err)
+
+	require.Eventually(t, func() bool {
+		services.mutex.Lock()
+		defer services.mutex.Unlock()
+		return len(services.auditLog) == 1
+	}, time.Second, 10*time.Millisecond)
+	services.mutex.Lock()
+	defer services.mutex.Unlock()
+	assert.Equal(t, []deltaLedger{{AccountID: 1, DeltaSKUs: []deltaSKU{{SKU: "A", Delta: -2}}, SessionID: "session-1"}}, services.ledgers)
+}
+
+func TestSessionEndedByAnotherCard(t *testing.T) {
+	services := &sessionServices{}
+	server := newSessionServer(t, services, OutputData{AccountID: 2, RoleID: 1, CardID: "0003278380"})
+	defer server.Close()
+	vendingState, _ := newSessionVendingState(server.URL)
+	lc := logger.NewMockClient()
+
+	_, err := vendingState.HandleMqttDeviceReading(lc, inferenceEvent(`[{"SKU": "A", "delta": -2}]`))
+	require.Nil(t, err)
+
+	// the basket of the first customer is charged before the next one is let in
+	ok, _ := vendingState.VerifyDoorAccess(lc, cardEvent("0003278380"))
+	assert.True(t, ok)
+	assert.Equal(t, []deltaLedger{{AccountID: 1, DeltaSKUs: []deltaSKU{{SKU: "A", Delta: -2}}, SessionID: "session-1"}}, services.ledgers)
+	require.Len(t, services.auditLog, 1)
+	assert.Equal(t, "0003293374", services.auditLog[0].CardID)
+
+	assert.Equal(t, 1, services.auth)
+	assert.False(t, vendingState.SessionLingering)
+	assert.Nil(t, vendingState.SessionBasket)
+	assert.Equal(t, 2, vendingState.CurrentUserData.AccountID)
+	assert.True(t, vendingState.Workflow.Vending())
+	assert.NotEmpty(t, vendingState.SessionID)
+	assert.NotEqual(t, "session-1", vendingState.SessionID, "the next customer starts a new session")
+}
+
+func TestCurrentSession(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		Name              string
+		State             VendingState
+		ExpectedStage     SessionStage
+		ExpectedRemaining int
+		ExpectedItemCount int
+	}{
+		{"idle", VendingState{Workflow: NewWorkflow(StateIdle)}, SessionIdle, 0, 0},
+		{"maintenance", VendingState{Workflow: NewWorkflow(StateIdle), MaintenanceMode: true}, SessionMaintenance, 0, 0},
+		{"unlocked", VendingState{Workflow: NewWorkflow(StateAuthorized), StageDeadline: now.Add(20 * time.Second)}, SessionUnlocked, 20, 0},
+		{"door open", VendingState{Workflow: NewWorkflow(StateDoorOpen), StageDeadline: now.Add(1500 * time.Millisecond)}, SessionDoorOpen, 2, 0},
+		{"verifying", VendingState{Workflow: NewWorkflow(StateInferring), StageDeadline: now.Add(-time.Second)}, SessionVerifying, 0, 0},
+		{"lingering", VendingState{Workflow: NewWorkflow(StateIdle), SessionLingering: true, StageDeadline: now.Add(30 * time.Second), SessionBasket: []deltaSKU{{SKU: "A", Delta: -2}, {SKU: "B", Delta: -1}}}, SessionLingering, 30, 3},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			currentTest.State.Configuration = &config.VendingConfig{KioskID: "kiosk-1"}
+			currentTest.State.CurrentUserData = OutputData{CardID: "0003278425", AccountID: 1, RoleID: 1}
+			currentTest.State.SessionID = "session-1"
+
+			status := currentTest.State.CurrentSession(now)
+			assert.Equal(t, currentTest.ExpectedStage, status.Stage)
+			assert.Equal(t, currentTest.ExpectedRemaining, status.RemainingSeconds)
+			assert.Equal(t, currentTest.ExpectedItemCount, status.ItemCount)
+			assert.Equal(t, "kiosk-1", status.KioskID)
+			if !currentTest.ExpectedStage.vending() {
+				assert.Empty(t, status.SessionID, "there is no session outside of a vend")
+				assert.Empty(t, status.CardID)
+				assert.Equal(t, []deltaSKU{}, status.Items)
+				return
+			}
+			assert.Equal(t, "session-1", status.SessionID)
+			assert.Equal(t, "0003278425", status.CardID)
+			assert.Equal(t, currentTest.State.StageDeadline.UnixNano(), status.TimeoutAt)
+		})
+	}
+}
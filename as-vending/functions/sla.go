@@ -0,0 +1,200 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// SLAStage is a stage of the vend workflow that has a service level target
+type SLAStage string
+
+const (
+	// SLAStageAuth is the authentication service's response to a card scan
+	SLAStageAuth SLAStage = "auth"
+	// SLAStageUnlock is from the card scan until the door is unlocked
+	SLAStageUnlock SLAStage = "unlock"
+	// SLAStageInference is from the door closing until the inference result
+	// is received
+	SLAStageInference SLAStage = "inference"
+
+	// maxRecentBreaches is the number of breaches kept for the SLA report
+	maxRecentBreaches = 100
+)
+
+// slaStages are the stages in the order they happen in the vend workflow
+var slaStages = []SLAStage{SLAStageAuth, SLAStageUnlock, SLAStageInference}
+
+// SLABreach is the context of a vend workflow stage that took longer than
+// its target. It is logged, and published as an alert when an alert
+// function is set.
+type SLABreach struct {
+	Stage      SLAStage `json:"stage"`
+	DurationMs int64    `json:"durationMs"`
+	TargetMs   int64    `json:"targetMs"`
+	AccountID  int      `json:"accountID,omitempty"`
+	RoleID     int      `json:"roleID,omitempty"`
+	CardID     string   `json:"cardID,omitempty"`
+	Timestamp  int64    `json:"timestamp,string"`
+}
+
+// SLAStageReport is the compliance of a single stage with its target.
+// Stages without a target are measured but never breached.
+type SLAStageReport struct {
+	Stage             SLAStage `json:"stage"`
+	Target            string   `json:"target,omitempty"`
+	Samples           int      `json:"samples"`
+	Breaches          int      `json:"breaches"`
+	CompliancePercent float64  `json:"compliancePercent"`
+	AverageMs         int64    `json:"averageMs"`
+	MaxMs             int64    `json:"maxMs"`
+}
+
+// SLAReport is the SLA compliance of every stage since the service started,
+// with the most recent breaches
+type SLAReport struct {
+	Since          int64            `json:"since,string"`
+	Stages         []SLAStageReport `json:"stages"`
+	RecentBreaches []SLABreach      `json:"recentBreaches"`
+}
+
+type slaStageStats struct {
+	samples  int
+	breaches int
+	total    time.Duration
+	max      time.Duration
+}
+
+// SLATracker measures the vend workflow stages against their targets. A nil
+// SLATracker does not record anything.
+type SLATracker struct {
+	mutex          sync.Mutex
+	targets        map[SLAStage]time.Duration
+	stats          map[SLAStage]*slaStageStats
+	recentBreaches []SLABreach
+	since          time.Time
+	alert          func(SLABreach) error
+}
+
+// NewSLATracker creates an SLATracker for the targets. alert is called for
+// every breach and may be nil.
+func NewSLATracker(targets map[SLAStage]time.Duration, alert func(SLABreach) error) *SLATracker {
+	tracker := &SLATracker{
+		targets: targets,
+		stats:   make(map[SLAStage]*slaStageStats),
+		since:   time.Now(),
+		alert:   alert,
+	}
+	for _, stage := range slaStages {
+		tracker.stats[stage] = &slaStageStats{}
+	}
+	return tracker
+}
+
+// ParseSLATargets parses the configured stage targets. Stages without a
+// target are not checked.
+func ParseSLATargets(vendingConfig *config.VendingConfig) (map[SLAStage]time.Duration, error) {
+	durations := map[SLAStage]string{
+		SLAStageAuth:      vendingConfig.AuthSLADuration,
+		SLAStageUnlock:    vendingConfig.UnlockSLADuration,
+		SLAStageInference: vendingConfig.InferenceSLADuration,
+	}
+	targets := make(map[SLAStage]time.Duration)
+	for stage, duration := range durations {
+		if duration == "" {
+			continue
+		}
+		target, err := time.ParseDuration(duration)
+		if err != nil || target <= 0 {
+			return nil, fmt.Errorf("%s SLA duration %q must be a positive duration", stage, duration)
+		}
+		targets[stage] = target
+	}
+	return targets, nil
+}
+
+// Record adds a measurement of the stage for the user. When it took longer
+// than the stage's target, the breach is logged and alerted.
+func (tracker *SLATracker) Record(lc logger.LoggingClient, stage SLAStage, duration time.Duration, user OutputData) {
+	if tracker == nil {
+		return
+	}
+
+	tracker.mutex.Lock()
+	stats, ok := tracker.stats[stage]
+	if !ok {
+		stats = &slaStageStats{}
+		tracker.stats[stage] = stats
+	}
+	stats.samples++
+	stats.total = stats.total + duration
+	if duration > stats.max {
+		stats.max = duration
+	}
+	target, ok := tracker.targets[stage]
+	if !ok || duration <= target {
+		tracker.mutex.Unlock()
+		return
+	}
+	stats.breaches++
+	breach := SLABreach{
+		Stage:      stage,
+		DurationMs: duration.Milliseconds(),
+		TargetMs:   target.Milliseconds(),
+		AccountID:  user.AccountID,
+		RoleID:     user.RoleID,
+		CardID:     user.CardID,
+		Timestamp:  time.Now().UnixNano(),
+	}
+	tracker.recentBreaches = append(tracker.recentBreaches, breach)
+	if len(tracker.recentBreaches) > maxRecentBreaches {
+		tracker.recentBreaches = tracker.recentBreaches[len(tracker.recentBreaches)-maxRecentBreaches:]
+	}
+	tracker.mutex.Unlock()
+
+	lc.Warnf("%s SLA breached: took %v, target is %v, account %d card %s", stage, duration, target, user.AccountID, user.CardID)
+	if tracker.alert != nil {
+		if err := tracker.alert(breach); err != nil {
+			lc.Errorf("failed to alert %s SLA breach: %s", stage, err.Error())
+		}
+	}
+}
+
+// Report returns the compliance of each stage with its target
+func (tracker *SLATracker) Report() SLAReport {
+	report := SLAReport{Stages: []SLAStageReport{}, RecentBreaches: []SLABreach{}}
+	if tracker == nil {
+		return report
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	report.Since = tracker.since.UnixNano()
+	for _, stage := range slaStages {
+		stats := tracker.stats[stage]
+		stageReport := SLAStageReport{
+			Stage:             stage,
+			Samples:           stats.samples,
+			Breaches:          stats.breaches,
+			CompliancePercent: 100,
+			MaxMs:             stats.max.Milliseconds(),
+		}
+		if target, ok := tracker.targets[stage]; ok {
+			stageReport.Target = target.String()
+		}
+		if stats.samples > 0 {
+			stageReport.CompliancePercent = float64(stats.samples-stats.breaches) * 100 / float64(stats.samples)
+			stageReport.AverageMs = (stats.total / time.Duration(stats.samples)).Milliseconds()
+		}
+		report.Stages = append(report.Stages, stageReport)
+	}
+	report.RecentBreaches = append(report.RecentBreaches, tracker.recentBreaches...)
+	return report
+}
@@ -0,0 +1,92 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSLATargets(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Config        config.VendingConfig
+		Expected      map[SLAStage]time.Duration
+		ExpectedError bool
+	}{
+		{"All targets", config.VendingConfig{AuthSLADuration: "1s", UnlockSLADuration: "2s", InferenceSLADuration: "15s"},
+			map[SLAStage]time.Duration{SLAStageAuth: time.Second, SLAStageUnlock: 2 * time.Second, SLAStageInference: 15 * time.Second}, false},
+		{"Disabled targets", config.VendingConfig{AuthSLADuration: "500ms"}, map[SLAStage]time.Duration{SLAStageAuth: 500 * time.Millisecond}, false},
+		{"Invalid duration", config.VendingConfig{UnlockSLADuration: "fast"}, nil, true},
+		{"Negative duration", config.VendingConfig{InferenceSLADuration: "-1s"}, nil, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			targets, err := ParseSLATargets(&currentTest.Config)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, targets)
+		})
+	}
+}
+
+func TestSLATracker(t *testing.T) {
+	var alerts []SLABreach
+	tracker := NewSLATracker(map[SLAStage]time.Duration{SLAStageAuth: time.Second, SLAStageUnlock: 2 * time.Second}, func(breach SLABreach) error {
+		alerts = append(alerts, breach)
+		return errors.New("message bus unavailable")
+	})
+	lc := logger.NewMockClient()
+	user := OutputData{AccountID: 1, RoleID: 1, CardID: "0003293374"}
+
+	tracker.Record(lc, SLAStageAuth, 200*time.Millisecond, user)
+	tracker.Record(lc, SLAStageAuth, 1500*time.Millisecond, user)
+	tracker.Record(lc, SLAStageUnlock, time.Second, user)
+	// inference has no target, so it is measured but never breached
+	tracker.Record(lc, SLAStageInference, time.Minute, user)
+
+	require.Len(t, alerts, 1)
+	assert.Equal(t, SLAStageAuth, alerts[0].Stage)
+	assert.Equal(t, int64(1500), alerts[0].DurationMs)
+	assert.Equal(t, int64(1000), alerts[0].TargetMs)
+	assert.Equal(t, "0003293374", alerts[0].CardID)
+
+	report := tracker.Report()
+	require.Len(t, report.Stages, 3)
+	assert.Equal(t, SLAStageReport{Stage: SLAStageAuth, Target: "1s", Samples: 2, Breaches: 1, CompliancePercent: 50, AverageMs: 850, MaxMs: 1500}, report.Stages[0])
+	assert.Equal(t, SLAStageReport{Stage: SLAStageUnlock, Target: "2s", Samples: 1, Breaches: 0, CompliancePercent: 100, AverageMs: 1000, MaxMs: 1000}, report.Stages[1])
+	assert.Equal(t, SLAStageReport{Stage: SLAStageInference, Samples: 1, Breaches: 0, CompliancePercent: 100, AverageMs: 60000, MaxMs: 60000}, report.Stages[2])
+	assert.Equal(t, alerts, report.RecentBreaches)
+	assert.NotZero(t, report.Since)
+}
+
+func TestSLATrackerRecentBreaches(t *testing.T) {
+	tracker := NewSLATracker(map[SLAStage]time.Duration{SLAStageAuth: time.Millisecond}, nil)
+	for i := 0; i < maxRecentBreaches+5; i++ {
+		tracker.Record(logger.NewMockClient(), SLAStageAuth, time.Duration(i+2)*time.Millisecond, OutputData{})
+	}
+
+	report := tracker.Report()
+	assert.Equal(t, maxRecentBreaches+5, report.Stages[0].Breaches)
+	require.Len(t, report.RecentBreaches, maxRecentBreaches)
+	assert.Equal(t, int64(maxRecentBreaches+6), report.RecentBreaches[maxRecentBreaches-1].DurationMs)
+}
+
+func TestSLATrackerNil(t *testing.T) {
+	var tracker *SLATracker
+	tracker.Record(logger.NewMockClient(), SLAStageAuth, time.Hour, OutputData{})
+	report := tracker.Report()
+	assert.Empty(t, report.Stages)
+	assert.Empty(t, report.RecentBreaches)
+}
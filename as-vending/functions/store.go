@@ -0,0 +1,79 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// StoreState is whether the vending machine is open for vending, for REST
+// API consumers and as the confirmation of a remote open or close
+type StoreState struct {
+	KioskID            string              `json:"kioskId,omitempty"`
+	Open               bool                `json:"open"`
+	Reason             string              `json:"reason,omitempty"` // why the store was closed
+	ChangedAt          int64               `json:"changedAt,string,omitempty"`
+	MaintenanceMode    bool                `json:"maintenanceMode"`
+	MaintenanceReasons []MaintenanceReason `json:"maintenanceReasons,omitempty"`
+}
+
+// StoreStateRequest is a request to open or close the vending machine
+type StoreStateRequest struct {
+	Open   bool   `json:"open"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// CloseStore closes the vending machine for the reason, such as a building
+// evacuation or a holiday closure, by putting it in maintenance mode until
+// it is opened again. A vend in progress is completed and charged, but no
+// new vend is started.
+func (vendingState *VendingState) CloseStore(lc logger.LoggingClient, reason string) {
+	if !vendingState.hasMaintenanceReason(ReasonStoreClosed) || vendingState.StoreClosedReason != reason {
+		vendingState.StoreChangedAt = time.Now()
+	}
+	vendingState.StoreClosedReason = reason
+	lc.Infof("store closed: %s", reason)
+	vendingState.SetMaintenanceReason(lc, ReasonStoreClosed)
+}
+
+// OpenStore opens the vending machine again after it was closed, and takes
+// it back into service unless another condition keeps it out of service
+func (vendingState *VendingState) OpenStore(lc logger.LoggingClient) {
+	if vendingState.hasMaintenanceReason(ReasonStoreClosed) {
+		vendingState.StoreChangedAt = time.Now()
+		lc.Info("store opened")
+	}
+	vendingState.StoreClosedReason = ""
+	vendingState.ClearMaintenanceReason(lc, ReasonStoreClosed)
+}
+
+// SetStoreState opens or closes the vending machine as requested and
+// returns its resulting state
+func (vendingState *VendingState) SetStoreState(lc logger.LoggingClient, request StoreStateRequest) StoreState {
+	if request.Open {
+		vendingState.OpenStore(lc)
+	} else {
+		vendingState.CloseStore(lc, request.Reason)
+	}
+	return vendingState.StoreState()
+}
+
+// StoreState returns whether the vending machine is open
+func (vendingState *VendingState) StoreState() StoreState {
+	state := StoreState{
+		Open:               !vendingState.hasMaintenanceReason(ReasonStoreClosed),
+		Reason:             vendingState.StoreClosedReason,
+		MaintenanceMode:    vendingState.MaintenanceMode,
+		MaintenanceReasons: vendingState.MaintenanceReasons,
+	}
+	if vendingState.Configuration != nil {
+		state.KioskID = vendingState.Configuration.KioskID
+	}
+	if !vendingState.StoreChangedAt.IsZero() {
+		state.ChangedAt = vendingState.StoreChangedAt.UnixNano()
+	}
+	return state
+}
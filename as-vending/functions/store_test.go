@@ -0,0 +1,63 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"testing"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStoreState(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := VendingState{
+		Workflow: NewWorkflow(StateIdle),
+		Configuration: &config.VendingConfig{
+			KioskID:                        "kiosk-1",
+			ControllerBoardDeviceName:      "controller-board",
+			ControllerBoardDisplayResetCmd: "displayReset",
+			ControllerBoardDisplayRow1Cmd:  "displayRow1",
+			ControllerBoardDisplayRow2Cmd:  "displayRow2",
+			ControllerBoardDisplayRow3Cmd:  "displayRow3",
+		},
+		CommandClient: mockCommandClient,
+	}
+	lc := logger.NewMockClient()
+
+	state := vendingState.StoreState()
+	assert.True(t, state.Open)
+	assert.Equal(t, "kiosk-1", state.KioskID)
+
+	state = vendingState.SetStoreState(lc, StoreStateRequest{Open: false, Reason: "evacuation"})
+	assert.False(t, state.Open)
+	assert.Equal(t, "evacuation", state.Reason)
+	assert.NotZero(t, state.ChangedAt)
+	assert.True(t, state.MaintenanceMode)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow2", map[string]string{"displayRow2": "Store closed"})
+
+	// servicing the machine does not open a closed store
+	vendingState.SetMaintenanceReason(lc, ReasonDoorLeftOpen)
+	vendingState.ClearMaintenance(lc)
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonStoreClosed}, vendingState.MaintenanceReasons)
+
+	// another condition keeps the machine out of service once it is opened
+	vendingState.SetMaintenanceReason(lc, ReasonTemperatureFault)
+	state = vendingState.SetStoreState(lc, StoreStateRequest{Open: true})
+	assert.True(t, state.Open)
+	assert.Empty(t, state.Reason)
+	assert.True(t, state.MaintenanceMode)
+	assert.Equal(t, []MaintenanceReason{ReasonTemperatureFault}, state.MaintenanceReasons)
+
+	vendingState.ClearMaintenanceReason(lc, ReasonTemperatureFault)
+	assert.False(t, vendingState.MaintenanceMode)
+}
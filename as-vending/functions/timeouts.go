@@ -0,0 +1,101 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// StageDurations are the timeouts of the vend workflow stages. DoorOpen is
+// how long the door may stay closed after it was unlocked, DoorClose is how
+// long it may stay open, and Inference is how long the inference result may
+// take once it was closed.
+type StageDurations struct {
+	DoorOpen  time.Duration
+	DoorClose time.Duration
+	Inference time.Duration
+}
+
+// ParseStageDurations parses the stage timeouts of the configuration, which
+// must be positive
+func ParseStageDurations(configuration *config.VendingConfig) (StageDurations, error) {
+	var durations StageDurations
+	var err error
+	if durations.DoorClose, err = parseStageDuration("DoorCloseStateTimeoutDuration", configuration.DoorCloseStateTimeoutDuration); err != nil {
+		return StageDurations{}, err
+	}
+	if durations.DoorOpen, err = parseStageDuration("DoorOpenStateTimeoutDuration", configuration.DoorOpenStateTimeoutDuration); err != nil {
+		return StageDurations{}, err
+	}
+	if durations.Inference, err = parseStageDuration("InferenceTimeoutDuration", configuration.InferenceTimeoutDuration); err != nil {
+		return StageDurations{}, err
+	}
+	return durations, nil
+}
+
+func parseStageDuration(name string, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s configuration: %v", name, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("failed to parse %s configuration: %s is not positive", name, value)
+	}
+	return duration, nil
+}
+
+// StageTimeouts holds the stage timeouts, which can be updated while the
+// service runs. A stage that is already waiting keeps the timeout it started
+// with. A nil StageTimeouts has no timeouts.
+type StageTimeouts struct {
+	mutex     sync.Mutex
+	durations StageDurations
+}
+
+// NewStageTimeouts creates StageTimeouts with the durations
+func NewStageTimeouts(durations StageDurations) *StageTimeouts {
+	return &StageTimeouts{durations: durations}
+}
+
+// Durations returns the current stage timeouts
+func (timeouts *StageTimeouts) Durations() StageDurations {
+	if timeouts == nil {
+		return StageDurations{}
+	}
+	timeouts.mutex.Lock()
+	defer timeouts.mutex.Unlock()
+	return timeouts.durations
+}
+
+// Update replaces the stage timeouts, and returns the previous ones
+func (timeouts *StageTimeouts) Update(durations StageDurations) StageDurations {
+	timeouts.mutex.Lock()
+	defer timeouts.mutex.Unlock()
+	previous := timeouts.durations
+	timeouts.durations = durations
+	return previous
+}
+
+// UpdateStageTimeouts applies the stage timeouts of an updated
+// configuration. Invalid timeouts are rejected and the current ones kept.
+func (vendingState *VendingState) UpdateStageTimeouts(lc logger.LoggingClient, configuration *config.VendingConfig) error {
+	durations, err := ParseStageDurations(configuration)
+	if err != nil {
+		return err
+	}
+	if vendingState.Timeouts == nil {
+		vendingState.Timeouts = NewStageTimeouts(durations)
+		return nil
+	}
+	previous := vendingState.Timeouts.Update(durations)
+	if previous != durations {
+		lc.Infof("Updated the vend stage timeouts: door open %v, door close %v, inference %v", durations.DoorOpen, durations.DoorClose, durations.Inference)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTimeoutsConfig() *config.VendingConfig {
+	return &config.VendingConfig{
+		DoorCloseStateTimeoutDuration: "20s",
+		DoorOpenStateTimeoutDuration:  "15s",
+		InferenceTimeoutDuration:      "20s",
+	}
+}
+
+func TestParseStageDurations(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Update        func(*config.VendingConfig)
+		ExpectedError string
+	}{
+		{"Valid", func(*config.VendingConfig) {}, ""},
+		{"Invalid door open", func(c *config.VendingConfig) { c.DoorOpenStateTimeoutDuration = "fifteen" }, "DoorOpenStateTimeoutDuration"},
+		{"Zero door close", func(c *config.VendingConfig) { c.DoorCloseStateTimeoutDuration = "0s" }, "DoorCloseStateTimeoutDuration"},
+		{"Negative inference", func(c *config.VendingConfig) { c.InferenceTimeoutDuration = "-20s" }, "InferenceTimeoutDuration"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			configuration := getTimeoutsConfig()
+			currentTest.Update(configuration)
+			durations, err := ParseStageDurations(configuration)
+			if currentTest.ExpectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, StageDurations{DoorOpen: 15 * time.Second, DoorClose: 20 * time.Second, Inference: 20 * time.Second}, durations)
+		})
+	}
+}
+
+func TestUpdateStageTimeouts(t *testing.T) {
+	vendingState := VendingState{Workflow: NewWorkflow(StateIdle), Configuration: getTimeoutsConfig()}
+	require.NoError(t, vendingState.ParseDurationFromConfig())
+	assert.Equal(t, 15*time.Second, vendingState.Timeouts.Durations().DoorOpen)
+
+	updated := getTimeoutsConfig()
+	updated.DoorOpenStateTimeoutDuration = "45s"
+	updated.InferenceTimeoutDuration = "1m"
+	require.NoError(t, vendingState.UpdateStageTimeouts(logger.NewMockClient(), updated))
+	assert.Equal(t, StageDurations{DoorOpen: 45 * time.Second, DoorClose: 20 * time.Second, Inference: time.Minute}, vendingState.Timeouts.Durations())
+
+	// an invalid update keeps every current timeout
+	updated.DoorCloseStateTimeoutDuration = "twenty"
+	updated.DoorOpenStateTimeoutDuration = "5s"
+	require.Error(t, vendingState.UpdateStageTimeouts(logger.NewMockClient(), updated))
+	assert.Equal(t, StageDurations{DoorOpen: 45 * time.Second, DoorClose: 20 * time.Second, Inference: time.Minute}, vendingState.Timeouts.Durations())
+
+	var nilTimeouts *StageTimeouts
+	assert.Equal(t, StageDurations{}, nilTimeouts.Durations())
+}
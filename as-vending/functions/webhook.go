@@ -0,0 +1,297 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/google/uuid"
+)
+
+// The types of the events posted to the webhooks
+const (
+	WebhookDoorUnlocked       = "doorUnlocked"
+	WebhookSessionCompleted   = "sessionCompleted"
+	WebhookTimeout            = "timeout"
+	WebhookMaintenanceEntered = "maintenanceEntered"
+)
+
+// The headers of a webhook request. The signature is the hex HMAC-SHA256 of
+// the timestamp, a dot and the body, keyed with the webhook secret, so that
+// a receiver can check the event came from this kiosk and reject replays.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	defaultWebhookMaxAttempts   = 5
+	defaultWebhookRetryInterval = 5 * time.Second
+	maxWebhookRetryInterval     = 5 * time.Minute
+	webhookRequestTimeout       = 10 * time.Second
+	// maxPendingWebhooks bounds the deliveries kept while a webhook is down,
+	// the oldest are dropped first
+	maxPendingWebhooks = 1000
+)
+
+// WebhookEvent is a vend lifecycle or maintenance event posted to the
+// webhooks. Event is the workflow event of a timeout or a completed
+// session, such as doorCloseTimeout or basketSettled, and Reason is the
+// maintenance reason that was set.
+type WebhookEvent struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	KioskID   string            `json:"kioskId,omitempty"`
+	Door      string            `json:"door"`
+	SessionID string            `json:"sessionId,omitempty"`
+	AccountID int               `json:"accountId,omitempty"`
+	Event     string            `json:"event,omitempty"`
+	Reason    MaintenanceReason `json:"reason,omitempty"`
+	Timestamp int64             `json:"timestamp,string"`
+}
+
+// webhookDelivery is an event waiting to be posted to one webhook
+type webhookDelivery struct {
+	url         string
+	event       WebhookEvent
+	body        []byte
+	attempts    int
+	nextAttempt time.Time
+}
+
+// WebhookDispatcher posts the events to each webhook, and retries a failed
+// delivery with a doubling interval until it runs out of attempts. The
+// deliveries are only kept in memory. A nil WebhookDispatcher posts nothing.
+type WebhookDispatcher struct {
+	mutex         sync.Mutex
+	urls          []string
+	secret        string
+	maxAttempts   int
+	retryInterval time.Duration
+	client        *http.Client
+	pending       []*webhookDelivery
+	wake          chan struct{}
+}
+
+// NewWebhookDispatcher parses the comma separated webhook URLs and the
+// retry settings. It returns nil when there are no webhooks.
+func NewWebhookDispatcher(urls string, secret string, maxAttempts int, retryInterval string) (*WebhookDispatcher, error) {
+	dispatcher := &WebhookDispatcher{
+		secret:        secret,
+		maxAttempts:   maxAttempts,
+		retryInterval: defaultWebhookRetryInterval,
+		client:        &http.Client{Timeout: webhookRequestTimeout},
+		wake:          make(chan struct{}, 1),
+	}
+	for _, webhookURL := range strings.Split(urls, ",") {
+		webhookURL = strings.TrimSpace(webhookURL)
+		if webhookURL == "" {
+			continue
+		}
+		parsed, err := url.Parse(webhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("webhook URL %q must be an http or https URL", webhookURL)
+		}
+		dispatcher.urls = append(dispatcher.urls, webhookURL)
+	}
+	if len(dispatcher.urls) == 0 {
+		return nil, nil
+	}
+
+	if maxAttempts < 0 {
+		return nil, fmt.Errorf("webhook max attempts %d is negative", maxAttempts)
+	}
+	if maxAttempts == 0 {
+		dispatcher.maxAttempts = defaultWebhookMaxAttempts
+	}
+	if retryInterval != "" {
+		duration, err := time.ParseDuration(retryInterval)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("webhook retry interval %q must be a positive duration", retryInterval)
+		}
+		dispatcher.retryInterval = duration
+	}
+	return dispatcher, nil
+}
+
+// Notify queues the event for each webhook, and wakes the dispatcher to
+// post it
+func (dispatcher *WebhookDispatcher) Notify(lc logger.LoggingClient, event WebhookEvent) {
+	if dispatcher == nil {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		lc.Errorf("Failed to marshal the %s webhook event: %s", event.Type, err.Error())
+		return
+	}
+
+	dispatcher.mutex.Lock()
+	for _, webhookURL := range dispatcher.urls {
+		dispatcher.pending = append(dispatcher.pending, &webhookDelivery{url: webhookURL, event: event, body: body})
+	}
+	if dropped := len(dispatcher.pending) - maxPendingWebhooks; dropped > 0 {
+		lc.Errorf("Dropped the %d oldest webhook deliveries, as %d are pending", dropped, maxPendingWebhooks)
+		dispatcher.pending = dispatcher.pending[dropped:]
+	}
+	dispatcher.mutex.Unlock()
+
+	select {
+	case dispatcher.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run posts the queued events until the context is done
+func (dispatcher *WebhookDispatcher) Run(ctx context.Context, lc logger.LoggingClient) {
+	if dispatcher == nil {
+		return
+	}
+	for {
+		wait := dispatcher.deliverDue(lc, time.Now())
+		var retry <-chan time.Time
+		if wait > 0 {
+			retry = time.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-dispatcher.wake:
+		case <-retry:
+		}
+	}
+}
+
+// deliverDue posts the deliveries that are due, and returns how long until
+// the next retry is due, zero when nothing waits for a retry
+func (dispatcher *WebhookDispatcher) deliverDue(lc logger.LoggingClient, now time.Time) time.Duration {
+	dispatcher.mutex.Lock()
+	var due []*webhookDelivery
+	waiting := dispatcher.pending[:0]
+	for _, delivery := range dispatcher.pending {
+		if delivery.nextAttempt.After(now) {
+			waiting = append(waiting, delivery)
+		} else {
+			due = append(due, delivery)
+		}
+	}
+	dispatcher.pending = waiting
+	dispatcher.mutex.Unlock()
+
+	var retries []*webhookDelivery
+	for _, delivery := range due {
+		err := dispatcher.post(delivery)
+		if err == nil {
+			continue
+		}
+		delivery.attempts++
+		if delivery.attempts >= dispatcher.maxAttempts {
+			lc.Errorf("Dropped the %s webhook event %s to %s after %d attempts: %s", delivery.event.Type, delivery.event.ID, delivery.url, delivery.attempts, err.Error())
+			continue
+		}
+		delivery.nextAttempt = now.Add(dispatcher.retryDelay(delivery.attempts))
+		lc.Warnf("Failed to post the %s webhook event %s to %s, retrying at %s: %s", delivery.event.Type, delivery.event.ID, delivery.url, delivery.nextAttempt.Format(time.RFC3339), err.Error())
+		retries = append(retries, delivery)
+	}
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	dispatcher.pending = append(retries, dispatcher.pending...)
+	var wait time.Duration
+	for _, delivery := range dispatcher.pending {
+		until := delivery.nextAttempt.Sub(now)
+		if until <= 0 {
+			// queued while the due deliveries were posted, which woke the
+			// dispatcher already
+			continue
+		}
+		if wait == 0 || until < wait {
+			wait = until
+		}
+	}
+	return wait
+}
+
+// retryDelay doubles the retry interval with each failed attempt, up to the
+// maximum retry interval
+func (dispatcher *WebhookDispatcher) retryDelay(attempts int) time.Duration {
+	delay := dispatcher.retryInterval
+	for i := 1; i < attempts && delay < maxWebhookRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > maxWebhookRetryInterval {
+		return maxWebhookRetryInterval
+	}
+	return delay
+}
+
+// post posts the event of the delivery to its webhook, signed when a secret
+// is configured. A response other than 2xx is a failure.
+func (dispatcher *WebhookDispatcher) post(delivery *webhookDelivery) error {
+	request, err := http.NewRequest(http.MethodPost, delivery.url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(delivery.event.Timestamp, 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, delivery.event.Type)
+	request.Header.Set(WebhookIDHeader, delivery.event.ID)
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	if dispatcher.secret != "" {
+		request.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(dispatcher.secret, timestamp, delivery.body))
+	}
+
+	resp, err := dispatcher.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of the timestamp, a dot and the
+// body of a webhook request, keyed with the secret
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks posts the event of the type for the door and its current
+// session to the webhooks
+func (vendingState *VendingState) notifyWebhooks(lc logger.LoggingClient, eventType string, event string, reason MaintenanceReason) {
+	if vendingState.Webhooks == nil || vendingState.Configuration == nil {
+		return
+	}
+	vendingState.Webhooks.Notify(lc, WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		KioskID:   vendingState.Configuration.KioskID,
+		Door:      vendingState.Configuration.ControllerBoardDeviceName,
+		SessionID: vendingState.SessionID,
+		AccountID: vendingState.CurrentUserData.AccountID,
+		Event:     event,
+		Reason:    reason,
+		Timestamp: time.Now().UnixNano(),
+	})
+}
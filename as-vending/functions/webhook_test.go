@@ -0,0 +1,149 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebhookDispatcher(t *testing.T) {
+	dispatcher, err := NewWebhookDispatcher(" , ", "", 0, "")
+	require.NoError(t, err)
+	assert.Nil(t, dispatcher)
+
+	dispatcher, err = NewWebhookDispatcher("http://a.example/hook, https://b.example/hook", "secret", 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://a.example/hook", "https://b.example/hook"}, dispatcher.urls)
+	assert.Equal(t, defaultWebhookMaxAttempts, dispatcher.maxAttempts)
+	assert.Equal(t, defaultWebhookRetryInterval, dispatcher.retryInterval)
+
+	tests := []struct {
+		Name          string
+		URLs          string
+		MaxAttempts   int
+		RetryInterval string
+	}{
+		{"Not a URL", "a.example/hook", 0, ""},
+		{"Unsupported scheme", "ftp://a.example/hook", 0, ""},
+		{"Negative max attempts", "http://a.example/hook", -1, ""},
+		{"Invalid retry interval", "http://a.example/hook", 0, "soon"},
+		{"Zero retry interval", "http://a.example/hook", 0, "0s"},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			_, err := NewWebhookDispatcher(currentTest.URLs, "", currentTest.MaxAttempts, currentTest.RetryInterval)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWebhookDispatcherDeliver(t *testing.T) {
+	var received []*http.Request
+	var bodies [][]byte
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		received = append(received, request)
+		bodies = append(bodies, body)
+		if failures > 0 {
+			failures--
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher, err := NewWebhookDispatcher(server.URL, "secret", 3, "10s")
+	require.NoError(t, err)
+	lc := logger.NewMockClient()
+	event := WebhookEvent{ID: "event-1", Type: WebhookDoorUnlocked, Door: "controller-board", SessionID: "session-1", AccountID: 1, Event: "cardAuthorized", Timestamp: 1000}
+	dispatcher.Notify(lc, event)
+
+	// the first attempt fails, and is retried after the retry interval
+	now := time.Unix(2000, 0)
+	assert.Equal(t, 10*time.Second, dispatcher.deliverDue(lc, now))
+	require.Len(t, received, 1)
+	assert.Equal(t, 5*time.Second, dispatcher.deliverDue(lc, now.Add(5*time.Second)))
+	require.Len(t, received, 1, "a retry is not posted before it is due")
+	assert.Equal(t, time.Duration(0), dispatcher.deliverDue(lc, now.Add(10*time.Second)))
+	require.Len(t, received, 2)
+	assert.Empty(t, dispatcher.pending)
+
+	request := received[1]
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, WebhookDoorUnlocked, request.Header.Get(WebhookEventHeader))
+	assert.Equal(t, "event-1", request.Header.Get(WebhookIDHeader))
+	assert.Equal(t, "1000", request.Header.Get(WebhookTimestampHeader))
+	assert.Equal(t, "sha256="+SignWebhook("secret", "1000", bodies[1]), request.Header.Get(WebhookSignatureHeader))
+	var posted WebhookEvent
+	require.NoError(t, json.Unmarshal(bodies[1], &posted))
+	assert.Equal(t, event, posted)
+}
+
+func TestWebhookDispatcherDropsAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		attempts++
+		assert.Empty(t, request.Header.Get(WebhookSignatureHeader), "events are unsigned without a secret")
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dispatcher, err := NewWebhookDispatcher(server.URL, "", 3, "1s")
+	require.NoError(t, err)
+	lc := logger.NewMockClient()
+	dispatcher.Notify(lc, WebhookEvent{ID: "event-1", Type: WebhookTimeout})
+
+	now := time.Unix(2000, 0)
+	assert.Equal(t, time.Second, dispatcher.deliverDue(lc, now))
+	assert.Equal(t, 2*time.Second, dispatcher.deliverDue(lc, now.Add(time.Second)), "the retry interval doubles")
+	assert.Equal(t, time.Duration(0), dispatcher.deliverDue(lc, now.Add(3*time.Second)))
+	assert.Equal(t, 3, attempts)
+	assert.Empty(t, dispatcher.pending)
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	dispatcher := &WebhookDispatcher{retryInterval: time.Minute}
+	assert.Equal(t, time.Minute, dispatcher.retryDelay(1))
+	assert.Equal(t, 4*time.Minute, dispatcher.retryDelay(3))
+	assert.Equal(t, maxWebhookRetryInterval, dispatcher.retryDelay(10))
+}
+
+func TestNotifyWebhooks(t *testing.T) {
+	dispatcher, err := NewWebhookDispatcher("http://a.example/hook,http://b.example/hook", "", 0, "")
+	require.NoError(t, err)
+	lc := logger.NewMockClient()
+	vendingState := newMaintenanceVendingState(nil)
+	vendingState.Webhooks = dispatcher
+	vendingState.SessionID = "session-1"
+	vendingState.CurrentUserData = OutputData{AccountID: 1}
+
+	vendingState.SetMaintenanceReason(lc, ReasonDoorLeftOpen)
+	vendingState.ClearMaintenanceReason(lc, ReasonDoorLeftOpen)
+	require.Len(t, dispatcher.pending, 2, "the event is posted to each webhook, and clearing a reason is not posted")
+	event := dispatcher.pending[0].event
+	assert.NotEmpty(t, event.ID)
+	assert.Equal(t, WebhookMaintenanceEntered, event.Type)
+	assert.Equal(t, "kiosk-1", event.KioskID)
+	assert.Equal(t, "controller-board", event.Door)
+	assert.Equal(t, "session-1", event.SessionID)
+	assert.Equal(t, 1, event.AccountID)
+	assert.Equal(t, ReasonDoorLeftOpen, event.Reason)
+	assert.Equal(t, "http://b.example/hook", dispatcher.pending[1].url)
+
+	// nothing is posted without webhooks
+	newMaintenanceVendingState(nil).notifyWebhooks(lc, WebhookTimeout, "doorOpenTimeout", "")
+}
@@ -0,0 +1,292 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+// WorkflowState is the state of the vend workflow
+type WorkflowState string
+
+const (
+	// StateIdle waits for a card to be scanned
+	StateIdle WorkflowState = "idle"
+	// StateAuthorized has unlocked the door for a card, and waits for the
+	// door to be opened
+	StateAuthorized WorkflowState = "authorized"
+	// StateDoorOpen waits for the door to be closed
+	StateDoorOpen WorkflowState = "doorOpen"
+	// StateInferring waits for the inference result of the vend
+	StateInferring WorkflowState = "inferring"
+	// StateSettling charges the inference result and records it in
+	// inventory
+	StateSettling WorkflowState = "settling"
+	// StateMaintenance is out of service until maintenance mode is cleared
+	StateMaintenance WorkflowState = "maintenance"
+
+	// maxWorkflowTransitions is the number of transitions kept in the log
+	maxWorkflowTransitions = 100
+)
+
+// ErrInvalidTransition is returned for a transition that is not allowed
+// from the current state
+var ErrInvalidTransition = errors.New("invalid vend workflow transition")
+
+// workflowTransitions are the states each state can move to. An inference
+// result is settled whenever it arrives, as it also records the restocking
+// of a maintainer, who opens the door without a vend.
+var workflowTransitions = map[WorkflowState][]WorkflowState{
+	StateIdle:        {StateAuthorized, StateSettling, StateMaintenance},
+	StateAuthorized:  {StateDoorOpen, StateInferring, StateSettling, StateIdle, StateMaintenance},
+	StateDoorOpen:    {StateInferring, StateSettling, StateIdle, StateMaintenance},
+	StateInferring:   {StateSettling, StateIdle, StateMaintenance},
+	StateSettling:    {StateIdle, StateMaintenance},
+	StateMaintenance: {StateSettling, StateIdle},
+}
+
+// vending returns whether a vend is in progress in the state
+func (state WorkflowState) vending() bool {
+	switch state {
+	case StateAuthorized, StateDoorOpen, StateInferring, StateSettling:
+		return true
+	}
+	return false
+}
+
+// WorkflowTransition is a change of the state of the vend workflow, and the
+// event that caused it
+type WorkflowTransition struct {
+	From  WorkflowState `json:"from"`
+	To    WorkflowState `json:"to"`
+	Event string        `json:"event"`
+	At    int64         `json:"at,string"`
+}
+
+// WorkflowStatus is the current state of the vend workflow, when it was
+// entered, and the account of the vend in progress or lingering session
+type WorkflowStatus struct {
+	State     WorkflowState `json:"state"`
+	EnteredAt int64         `json:"enteredAt,string,omitempty"`
+	SessionID string        `json:"sessionId,omitempty"`
+	AccountID int           `json:"accountId,omitempty"`
+	CardID    string        `json:"cardId,omitempty"`
+	RoleID    int           `json:"roleId,omitempty"`
+}
+
+// WorkflowHistory is the most recent transitions of the vend workflow,
+// oldest first
+type WorkflowHistory struct {
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+// Workflow is the state machine of the vend workflow. Its transitions are
+// guarded, so that the timeout threads, the board status events and the
+// inference events cannot move it to a state it cannot be in. A nil
+// Workflow is idle.
+type Workflow struct {
+	mutex       sync.Mutex
+	state       WorkflowState
+	enteredAt   time.Time
+	transitions []WorkflowTransition
+	// session guards the session of the door of the workflow, see
+	// LockSession
+	session sync.Mutex
+}
+
+// NewWorkflow creates a Workflow in the state
+func NewWorkflow(state WorkflowState) *Workflow {
+	return &Workflow{state: state, enteredAt: time.Now(), transitions: []WorkflowTransition{}}
+}
+
+// State returns the current state
+func (workflow *Workflow) State() WorkflowState {
+	if workflow == nil {
+		return StateIdle
+	}
+	workflow.mutex.Lock()
+	defer workflow.mutex.Unlock()
+	return workflow.state
+}
+
+// Vending returns whether a vend is in progress, from the card scan that
+// unlocked the door until its basket is settled
+func (workflow *Workflow) Vending() bool {
+	return workflow.State().vending()
+}
+
+// Transition moves the workflow to the state for the event. Moving to the
+// current state does nothing.
+func (workflow *Workflow) Transition(to WorkflowState, event string) error {
+	workflow.mutex.Lock()
+	defer workflow.mutex.Unlock()
+	if workflow.state == to {
+		return nil
+	}
+	if !workflow.allowed(to) {
+		return fmt.Errorf("%w from %s to %s on %s", ErrInvalidTransition, workflow.state, to, event)
+	}
+	workflow.move(to, event)
+	return nil
+}
+
+// TransitionFrom moves the workflow to the state for the event only if it
+// is still in the from state, and returns whether it moved. The timeout
+// threads use it, so that a timeout does nothing once the workflow has moved
+// on.
+func (workflow *Workflow) TransitionFrom(from WorkflowState, to WorkflowState, event string) bool {
+	workflow.mutex.Lock()
+	defer workflow.mutex.Unlock()
+	if workflow.state != from || !workflow.allowed(to) {
+		return false
+	}
+	workflow.move(to, event)
+	return true
+}
+
+// Reset moves the workflow to idle from any state for the event
+func (workflow *Workflow) Reset(event string) {
+	workflow.mutex.Lock()
+	defer workflow.mutex.Unlock()
+	if workflow.state != StateIdle {
+		workflow.move(StateIdle, event)
+	}
+}
+
+// Status returns the current state and when it was entered
+func (workflow *Workflow) Status() WorkflowStatus {
+	if workflow == nil {
+		return WorkflowStatus{State: StateIdle}
+	}
+	workflow.mutex.Lock()
+	defer workflow.mutex.Unlock()
+	return WorkflowStatus{State: workflow.state, EnteredAt: workflow.enteredAt.UnixNano()}
+}
+
+// History returns the transition log
+func (workflow *Workflow) History() WorkflowHistory {
+	history := WorkflowHistory{Transitions: []WorkflowTransition{}}
+	if workflow == nil {
+		return history
+	}
+	workflow.mutex.Lock()
+	defer workflow.mutex.Unlock()
+	history.Transitions = append(history.Transitions, workflow.transitions...)
+	return history
+}
+
+func (workflow *Workflow) allowed(to WorkflowState) bool {
+	for _, state := range workflowTransitions[workflow.state] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (workflow *Workflow) move(to WorkflowState, event string) {
+	workflow.enteredAt = time.Now()
+	workflow.transitions = append(workflow.transitions, WorkflowTransition{
+		From:  workflow.state,
+		To:    to,
+		Event: event,
+		At:    workflow.enteredAt.UnixNano(),
+	})
+	if len(workflow.transitions) > maxWorkflowTransitions {
+		workflow.transitions = workflow.transitions[len(workflow.transitions)-maxWorkflowTransitions:]
+	}
+	workflow.state = to
+}
+
+// LockSession locks the session of the door: the customer, maintenance,
+// stage deadline and linger fields of the vending state, and its stop
+// channels. The function pipeline, the routes, and the timeout and
+// background threads hold it while they handle an event, and the functions
+// they call expect it to be held. The Workflow is created when the service
+// starts, so that every thread locks the same one.
+func (vendingState *VendingState) LockSession() {
+	vendingState.Workflow.session.Lock()
+}
+
+// UnlockSession unlocks the session of the door
+func (vendingState *VendingState) UnlockSession() {
+	vendingState.Workflow.session.Unlock()
+}
+
+// WorkflowStatus returns the current state of the vend workflow, with the
+// account of the vend in progress or lingering session
+func (vendingState *VendingState) WorkflowStatus() WorkflowStatus {
+	status := vendingState.Workflow.Status()
+	if !status.State.vending() && !vendingState.SessionLingering {
+		return status
+	}
+	status.SessionID = vendingState.SessionID
+	status.AccountID = vendingState.CurrentUserData.AccountID
+	status.CardID = vendingState.CurrentUserData.CardID
+	status.RoleID = vendingState.CurrentUserData.RoleID
+	return status
+}
+
+// restingState is the state of the workflow between vends, which is
+// maintenance while maintenance mode is set
+func (vendingState *VendingState) restingState() WorkflowState {
+	if vendingState.MaintenanceMode {
+		return StateMaintenance
+	}
+	return StateIdle
+}
+
+// Transition moves the vend workflow to the state for the event, and
+// returns whether it could
+func (vendingState *VendingState) Transition(lc logger.LoggingClient, to WorkflowState, event string) bool {
+	if err := vendingState.Workflow.Transition(to, event); err != nil {
+		lc.Warn(err.Error())
+		return false
+	}
+	lc.Debugf("workflow: %s", to)
+	vendingState.journalSession(lc)
+	return true
+}
+
+// TransitionFrom moves the vend workflow to the state for the event only if
+// it is still in the from state, and returns whether it moved
+func (vendingState *VendingState) TransitionFrom(lc logger.LoggingClient, from WorkflowState, to WorkflowState, event string) bool {
+	if !vendingState.Workflow.TransitionFrom(from, to, event) {
+		return false
+	}
+	lc.Debugf("workflow: %s", to)
+	vendingState.journalSession(lc)
+	return true
+}
+
+// finishVend moves the vend workflow out of a vend, to idle or to
+// maintenance, for the event
+func (vendingState *VendingState) finishVend(lc logger.LoggingClient, event string) {
+	vendingState.Transition(lc, vendingState.restingState(), event)
+}
+
+// ResetWorkflow moves the vend workflow to idle from any state for the
+// event, or to maintenance while maintenance mode is set
+func (vendingState *VendingState) ResetWorkflow(lc logger.LoggingClient, event string) {
+	vendingState.Workflow.Reset(event)
+	vendingState.journalSession(lc)
+	vendingState.syncMaintenanceState(lc, event)
+}
+
+// syncMaintenanceState moves the vend workflow between idle and maintenance
+// as maintenance mode is set and cleared. A vend in progress is finished
+// first.
+func (vendingState *VendingState) syncMaintenanceState(lc logger.LoggingClient, event string) {
+	switch {
+	case vendingState.MaintenanceMode:
+		vendingState.TransitionFrom(lc, StateIdle, StateMaintenance, event)
+	default:
+		vendingState.TransitionFrom(lc, StateMaintenance, StateIdle, event)
+	}
+}
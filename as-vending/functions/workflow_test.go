@@ -0,0 +1,140 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package functions
+
+import (
+	"as-vending/config"
+	"net/http"
+	"testing"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowTransition(t *testing.T) {
+	tests := []struct {
+		Name     string
+		From     WorkflowState
+		To       WorkflowState
+		Expected bool
+	}{
+		{"Card authorized", StateIdle, StateAuthorized, true},
+		{"Door opened", StateAuthorized, StateDoorOpen, true},
+		{"Door closed", StateDoorOpen, StateInferring, true},
+		{"Inference received", StateInferring, StateSettling, true},
+		{"Basket settled", StateSettling, StateIdle, true},
+		{"Restock settled", StateIdle, StateSettling, true},
+		{"Maintenance cleared", StateMaintenance, StateIdle, true},
+		{"Same state", StateDoorOpen, StateDoorOpen, true},
+		{"Door opened without a card", StateIdle, StateDoorOpen, false},
+		{"Door closed without a vend", StateIdle, StateInferring, false},
+		{"Door reopened during inference", StateInferring, StateDoorOpen, false},
+		{"Card authorized during a vend", StateDoorOpen, StateAuthorized, false},
+		{"Card authorized in maintenance", StateMaintenance, StateAuthorized, false},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			workflow := NewWorkflow(currentTest.From)
+			err := workflow.Transition(currentTest.To, "event")
+			if !currentTest.Expected {
+				require.ErrorIs(t, err, ErrInvalidTransition)
+				assert.Equal(t, currentTest.From, workflow.State())
+				assert.Empty(t, workflow.History().Transitions)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.To, workflow.State())
+		})
+	}
+}
+
+func TestWorkflowTransitionFrom(t *testing.T) {
+	workflow := NewWorkflow(StateAuthorized)
+	require.NoError(t, workflow.Transition(StateDoorOpen, "doorOpened"))
+
+	// the door open timeout fires after the door was opened
+	assert.False(t, workflow.TransitionFrom(StateAuthorized, StateIdle, "doorOpenTimeout"))
+	assert.Equal(t, StateDoorOpen, workflow.State())
+
+	assert.True(t, workflow.TransitionFrom(StateDoorOpen, StateIdle, "doorCloseTimeout"))
+	assert.Equal(t, StateIdle, workflow.State())
+
+	history := workflow.History()
+	require.Len(t, history.Transitions, 2)
+	assert.Equal(t, WorkflowTransition{From: StateDoorOpen, To: StateIdle, Event: "doorCloseTimeout", At: workflow.Status().EnteredAt}, history.Transitions[1])
+}
+
+func TestWorkflowReset(t *testing.T) {
+	workflow := NewWorkflow(StateInferring)
+	workflow.Reset("doorLockReset")
+	assert.Equal(t, StateIdle, workflow.State())
+	workflow.Reset("doorLockReset")
+	assert.Len(t, workflow.History().Transitions, 1, "resetting an idle workflow is not a transition")
+
+	for i := 0; i < maxWorkflowTransitions; i++ {
+		require.NoError(t, workflow.Transition(StateAuthorized, "cardAuthorized"))
+		workflow.Reset("doorLockReset")
+	}
+	assert.Len(t, workflow.History().Transitions, maxWorkflowTransitions)
+
+	var nilWorkflow *Workflow
+	assert.Equal(t, StateIdle, nilWorkflow.State())
+	assert.False(t, nilWorkflow.Vending())
+	assert.Equal(t, WorkflowStatus{State: StateIdle}, nilWorkflow.Status())
+	assert.Equal(t, WorkflowHistory{Transitions: []WorkflowTransition{}}, nilWorkflow.History())
+}
+
+func TestWorkflowMaintenance(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	lc := logger.NewMockClient()
+
+	vendingState := VendingState{
+		Configuration: &config.VendingConfig{},
+		CommandClient: mockCommandClient,
+		Workflow:      NewWorkflow(StateIdle),
+	}
+
+	vendingState.SetMaintenanceReason(lc, ReasonTemperatureFault)
+	assert.Equal(t, StateMaintenance, vendingState.Workflow.State())
+	vendingState.ClearMaintenanceReason(lc, ReasonTemperatureFault)
+	assert.Equal(t, StateIdle, vendingState.Workflow.State())
+
+	// a vend in progress is finished before the kiosk is out of service
+	require.True(t, vendingState.Transition(lc, StateAuthorized, "cardAuthorized"))
+	vendingState.SetMaintenanceReason(lc, ReasonCardReaderOffline)
+	assert.Equal(t, StateAuthorized, vendingState.Workflow.State())
+	vendingState.finishVend(lc, "basketSettled")
+	assert.Equal(t, StateMaintenance, vendingState.Workflow.State())
+
+	vendingState.ClearMaintenance(lc)
+	assert.Equal(t, StateIdle, vendingState.Workflow.State())
+}
+
+func TestVendingStateWorkflowStatus(t *testing.T) {
+	vendingState := VendingState{
+		Workflow:        NewWorkflow(StateIdle),
+		CurrentUserData: OutputData{AccountID: 1, CardID: "0003278425", RoleID: 1},
+		SessionID:       "session-1",
+	}
+	assert.Equal(t, WorkflowStatus{State: StateIdle, EnteredAt: vendingState.Workflow.Status().EnteredAt}, vendingState.WorkflowStatus(), "no account is active between vends")
+
+	vendingState.SessionLingering = true
+	assert.Equal(t, 1, vendingState.WorkflowStatus().AccountID)
+
+	vendingState.SessionLingering = false
+	require.True(t, vendingState.Transition(logger.NewMockClient(), StateAuthorized, "cardAuthorized"))
+	status := vendingState.WorkflowStatus()
+	assert.Equal(t, StateAuthorized, status.State)
+	assert.Equal(t, "session-1", status.SessionID)
+	assert.Equal(t, 1, status.AccountID)
+	assert.Equal(t, "0003278425", status.CardID)
+	assert.Equal(t, 1, status.RoleID)
+}
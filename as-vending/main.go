@@ -5,6 +5,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"as-vending/config"
 	"as-vending/functions"
@@ -14,6 +15,9 @@ import (
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/transforms"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 const (
@@ -41,7 +45,11 @@ func (app *vendingAppService) CreateAndRunAppService(serviceKey string, newServi
 	}
 
 	app.lc = app.service.LoggingClient()
-	newVendingState := functions.VendingState{}
+	// the build is logged first, so that every log shows what was running
+	app.lc.Info("starting service", routes.CurrentVersion().LogFields()...)
+	// the vend workflow is created once, as its session lock is shared by
+	// the pipeline, the routes and the timeout threads
+	newVendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
 	app.vendingState = &newVendingState
 
 	// retrieve the required configurations
@@ -63,6 +71,229 @@ func (app *vendingAppService) CreateAndRunAppService(serviceKey string, newServi
 		return 1
 	}
 
+	// SLA breaches are logged, and published when an alert topic is configured
+	slaTargets, err := functions.ParseSLATargets(app.vendingState.Configuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	var slaAlert func(functions.SLABreach) error
+	if alertTopic := app.vendingState.Configuration.SLAAlertTopic; alertTopic != "" {
+		slaAlert = func(breach functions.SLABreach) error {
+			return app.service.PublishWithTopic(alertTopic, breach, common.ContentTypeJSON)
+		}
+	}
+	app.vendingState.SLA = functions.NewSLATracker(slaTargets, slaAlert)
+
+	// a silent card reader takes the vending machine out of service, and is
+	// published when an alert topic is configured
+	readerTimeout, err := functions.ParseReaderHeartbeatTimeout(app.vendingState.Configuration.CardReaderHeartbeatTimeoutDuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	if readerTimeout > 0 {
+		var readerAlert func(functions.ReaderAlert) error
+		if alertTopic := app.vendingState.Configuration.ReaderAlertTopic; alertTopic != "" {
+			readerAlert = func(alert functions.ReaderAlert) error {
+				return app.service.PublishWithTopic(alertTopic, alert, common.ContentTypeJSON)
+			}
+		}
+		app.vendingState.Readers = functions.NewReaderMonitor(readerTimeout, []string{app.vendingState.Configuration.CardReaderDeviceName}, readerAlert)
+	}
+
+	// repeated ledger failures suspend vending until it is resumed, and are
+	// published when an alert topic is configured
+	if threshold := app.vendingState.Configuration.BillingFailureThreshold; threshold > 0 {
+		var billingAlert func(functions.BillingAlert) error
+		if alertTopic := app.vendingState.Configuration.BillingAlertTopic; alertTopic != "" {
+			billingAlert = func(alert functions.BillingAlert) error {
+				return app.service.PublishWithTopic(alertTopic, alert, common.ContentTypeJSON)
+			}
+		}
+		app.vendingState.Billing = functions.NewBillingCircuit(threshold, billingAlert)
+	}
+
+	// rejected inference payloads are kept for review
+	app.vendingState.Quarantine = functions.NewInferenceQuarantine()
+	// vends made while inference is unavailable are kept for reconciliation
+	app.vendingState.Reconciliation = functions.NewReconciliationLog()
+
+	// the fleet endpoints open and close the configured kiosks
+	fleetKiosks, err := functions.ParseFleetKiosks(app.vendingState.Configuration.FleetKiosks)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	fleetTimeout, err := functions.ParseFleetRequestTimeout(app.vendingState.Configuration.FleetRequestTimeoutDuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	app.vendingState.Fleet = functions.NewFleet(fleetKiosks, fleetTimeout)
+
+	// the kiosks of the fleet that go silent are logged, and published when
+	// an alert topic is configured
+	fleetHeartbeatTimeout, err := functions.ParseFleetHeartbeatTimeout(app.vendingState.Configuration.FleetHeartbeatTimeoutDuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	if kiosks, ok := app.vendingState.Fleet.Kiosks(functions.FleetGroupAll); ok && fleetHeartbeatTimeout > 0 {
+		var kioskIDs []string
+		for _, kiosk := range kiosks {
+			kioskIDs = append(kioskIDs, kiosk.KioskID)
+		}
+		var fleetAlert func(functions.KioskAlert) error
+		if alertTopic := app.vendingState.Configuration.FleetAlertTopic; alertTopic != "" {
+			fleetAlert = func(alert functions.KioskAlert) error {
+				return app.service.PublishWithTopic(alertTopic, alert, common.ContentTypeJSON)
+			}
+		}
+		app.vendingState.FleetHealth = functions.NewFleetMonitor(fleetHeartbeatTimeout, kioskIDs, fleetAlert)
+	}
+	heartbeatInterval, err := functions.ParseHeartbeatInterval(app.vendingState.Configuration.HeartbeatIntervalDuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+
+	// cards swiped in enrollment mode are enrolled instead of opening the door
+	if app.vendingState.Configuration.EnrollmentEndpoint != "" {
+		enrollmentTimeout, err := functions.ParseEnrollmentTimeout(app.vendingState.Configuration.EnrollmentTimeoutDuration)
+		if err != nil {
+			app.lc.Errorf("failed to parse configuration: %v", err)
+			return 1
+		}
+		app.vendingState.Enrollment = functions.NewEnrollment(app.vendingState.Configuration.EnrollmentEndpoint, enrollmentTimeout)
+	}
+
+	// cards that need a PIN wait for the kiosk UI to enter it before the
+	// door is unlocked
+	if app.vendingState.Configuration.PinVerificationEndpoint != "" {
+		pinTimeout, err := functions.ParsePinEntryTimeout(app.vendingState.Configuration.PinEntryTimeoutDuration)
+		if err != nil {
+			app.lc.Errorf("failed to parse configuration: %v", err)
+			return 1
+		}
+		app.vendingState.PinEntry = functions.NewPinEntry(app.vendingState.Configuration.PinVerificationEndpoint, pinTimeout)
+	}
+
+	// the idle screens are rotated on the LCD between vends
+	idleInterval, err := functions.ParseIdleDisplayInterval(app.vendingState.Configuration.IdleDisplayIntervalDuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	idleScreens, err := functions.ParseDisplayScreens(app.vendingState.Configuration.IdleDisplayScreens, idleInterval)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	app.vendingState.IdleDisplay = functions.NewIdleDisplay(idleScreens)
+
+	// the progress of each vend is shown on the LCD when a screen is configured
+	sessionScreens, err := functions.ParseDisplayScreens(app.vendingState.Configuration.SessionDisplayScreen, 0)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	app.vendingState.SessionDisplay = functions.NewSessionDisplay(sessionScreens)
+
+	// barcodes scanned between vends show the product's price, and are
+	// published for demand analytics when a topic is configured
+	deviceNames := []string{app.vendingState.Configuration.CardReaderDeviceName, app.vendingState.Configuration.InferenceDeviceName}
+	if scannerName := app.vendingState.Configuration.BarcodeScannerDeviceName; scannerName != "" {
+		if app.vendingState.Configuration.PriceCheckEndpoint == "" {
+			app.lc.Error("failed to parse configuration: PriceCheckEndpoint is empty")
+			return 1
+		}
+		var priceCheckPublish func(functions.PriceCheckResult) error
+		if priceCheckTopic := app.vendingState.Configuration.PriceCheckTopic; priceCheckTopic != "" {
+			priceCheckPublish = func(result functions.PriceCheckResult) error {
+				return app.service.PublishWithTopic(priceCheckTopic, result, common.ContentTypeJSON)
+			}
+		}
+		app.vendingState.PriceCheck = functions.NewPriceCheck(scannerName, app.vendingState.Configuration.PriceCheckEndpoint, priceCheckPublish)
+		deviceNames = append(deviceNames, scannerName)
+	}
+	if app.vendingState.FleetHealth != nil {
+		deviceNames = append(deviceNames, functions.HeartbeatDeviceName)
+	}
+
+	// the vend in progress is kept on disk, so that it is recovered when the
+	// service restarts
+	app.vendingState.Journal, err = functions.NewSessionJournal(app.vendingState.Configuration.SessionJournalFile)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: SessionJournalFile must be in a writable directory: %s", err.Error())
+		return 1
+	}
+	journalEntry, err := app.vendingState.Journal.Load()
+	if err != nil {
+		app.lc.Errorf("failed to recover the session journal: %s", err.Error())
+		return 1
+	}
+
+	// the maintenance reasons are kept on disk, so that a door stays out of
+	// service across restarts, and their changes are published when a
+	// maintenance topic is configured
+	var maintenancePublish func(functions.MaintenanceEvent) error
+	if maintenanceTopic := app.vendingState.Configuration.MaintenanceTopic; maintenanceTopic != "" {
+		maintenancePublish = func(event functions.MaintenanceEvent) error {
+			return app.service.PublishWithTopic(maintenanceTopic, event, common.ContentTypeJSON)
+		}
+	}
+	app.vendingState.Maintenance, err = functions.NewMaintenanceStore(app.vendingState.Configuration.MaintenanceStateFile, maintenancePublish)
+	if err != nil {
+		app.lc.Errorf("failed to recover the maintenance state: %s", err.Error())
+		return 1
+	}
+	app.vendingState.Outbox, err = functions.NewOutbox(app.vendingState.Configuration.OutboxFile)
+	if err != nil {
+		app.lc.Errorf("failed to recover the outbox: %s", err.Error())
+		return 1
+	}
+
+	// the vend lifecycle and maintenance events are posted to the webhooks
+	// of external systems
+	app.vendingState.Webhooks, err = functions.NewWebhookDispatcher(app.vendingState.Configuration.WebhookURLs, app.vendingState.Configuration.WebhookSecret, app.vendingState.Configuration.WebhookMaxAttempts, app.vendingState.Configuration.WebhookRetryIntervalDuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+
+	// an inference result that does not arrive is triggered again, on the
+	// secondary inference device last, before maintenance mode is entered
+	app.vendingState.InferenceRetry, err = functions.ParseInferenceRetryPolicy(app.vendingState.Configuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	if secondaryName := app.vendingState.InferenceRetry.SecondaryDeviceName; secondaryName != "" {
+		deviceNames = append(deviceNames, secondaryName)
+	}
+
+	// the inference results are cross-checked against the weight sensor, and
+	// their disagreements are kept as feedback for the inference model
+	app.vendingState.CrossCheck, err = functions.ParseWeightCrossCheck(app.vendingState.Configuration)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	if weightSensorName := app.vendingState.CrossCheck.DeviceName(); weightSensorName != "" {
+		deviceNames = append(deviceNames, weightSensorName)
+	}
+	app.vendingState.Discrepancies, err = functions.NewDiscrepancyLog(app.vendingState.Configuration.WeightDiscrepancyFile)
+	if err != nil {
+		app.lc.Errorf("failed to recover the weight discrepancies: %v", err)
+		return 1
+	}
+
+	// the vend session metrics are reported with the EdgeX service metrics
+	app.vendingState.Metrics = functions.NewVendingMetrics()
+	app.vendingState.Metrics.Register(app.lc, app.service.MetricsManager())
+	app.vendingState.Outbox.SetMetrics(app.vendingState.Metrics)
+
 	app.vendingState.CommandClient = app.service.CommandClient()
 	if app.vendingState.CommandClient == nil {
 		app.lc.Error("Error command service missing from client's configuration")
@@ -78,24 +309,74 @@ func (app *vendingAppService) CreateAndRunAppService(serviceKey string, newServi
 	inferenceStopChannel := make(chan int)
 
 	// Set default values for vending state
-	app.vendingState.CVWorkflowStarted = false
 	app.vendingState.MaintenanceMode = false
 	app.vendingState.CurrentUserData = functions.OutputData{}
+	app.vendingState.SplitPayers = nil
 	app.vendingState.DoorClosed = true
 	// global stop channel for threads
 	app.vendingState.ThreadStopChannel = stopChannel
 	// open event thread
-	app.vendingState.DoorOpenedDuringCVWorkflow = false
 	app.vendingState.DoorOpenWaitThreadStopChannel = doorOpenStopChannel
 	// close event thread
-	app.vendingState.DoorClosedDuringCVWorkflow = false
 	app.vendingState.DoorCloseWaitThreadStopChannel = doorCloseStopChannel
 	// inference thread
-	app.vendingState.InferenceDataReceived = false
 	app.vendingState.InferenceWaitThreadStopChannel = inferenceStopChannel
+	// the door stays out of service for the reasons it had before a restart
+	app.vendingState.RestoreMaintenance(app.lc)
+	// a vend interrupted by a restart is resumed, or ends in maintenance mode
+	app.vendingState.RecoverSession(app.lc, journalEntry, time.Now())
+	// the requests that failed to be sent before a restart are sent again
+	if pending := len(app.vendingState.Outbox.Entries()); pending > 0 {
+		app.lc.Infof("Replaying the %d requests kept in the outbox", pending)
+		go app.vendingState.ReplayOutbox(app.lc)
+	}
 
-	controller := routes.NewController(app.lc, app.service, app.vendingState)
-	err := controller.AddAllRoutes()
+	// the other doors of a bank of coolers each have their own vend workflow
+	doors, err := functions.ParseDoors(app.vendingState.Configuration.Doors)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	app.vendingState.Doors, err = functions.NewDoorBank(app.vendingState, doors)
+	if err != nil {
+		app.lc.Errorf("failed to parse configuration: %v", err)
+		return 1
+	}
+	for _, door := range doors {
+		deviceNames = append(deviceNames, door.CardReaderDeviceName, door.InferenceDeviceName)
+		if door.WeightSensorDeviceName != "" {
+			deviceNames = append(deviceNames, door.WeightSensorDeviceName)
+		}
+	}
+	for _, door := range app.vendingState.Doors.Doors() {
+		if door != app.vendingState {
+			door.RestoreMaintenance(app.lc)
+		}
+	}
+
+	// the administrative routes need the token of a maintainer card when a
+	// token secret is configured
+	tokenVerifier := utilities.NewTokenVerifier(app.vendingState.Configuration.AuthTokenSecret)
+	if tokenVerifier == nil {
+		app.lc.Warn("AuthTokenSecret is not configured, the administrative routes are open")
+	}
+
+	// fleet-management tooling runs the commands of the allow-list with the
+	// token of a maintainer card on the remote command topic
+	var remoteCommands *functions.RemoteCommands
+	if app.vendingState.Configuration.RemoteCommandBroker != "" {
+		remoteCommands, err = functions.NewRemoteCommands(app.vendingState.Configuration.RemoteCommandAllowList, func(token string) (int, error) {
+			claims, err := tokenVerifier.VerifyMaintainer(token)
+			return claims.PersonID, err
+		})
+		if err != nil {
+			app.lc.Errorf("failed to parse configuration: RemoteCommandAllowList: %v", err)
+			return 1
+		}
+	}
+
+	controller := routes.NewController(app.lc, app.service, app.vendingState, tokenVerifier)
+	err = controller.AddAllRoutes()
 	if err != nil {
 		app.lc.Errorf("failed to add all Routes: %s", err.Error())
 		return 1
@@ -103,7 +384,10 @@ func (app *vendingAppService) CreateAndRunAppService(serviceKey string, newServi
 
 	// create the function pipeline to run when an event is read on the device channels
 	err = app.service.SetDefaultFunctionsPipeline(
-		transforms.NewFilterFor([]string{app.vendingState.Configuration.CardReaderDeviceName, app.vendingState.Configuration.InferenceDeviceName}).FilterByDeviceName,
+		transforms.NewFilterFor(deviceNames).FilterByDeviceName,
+		// binary and object readings, such as those of CBOR events, are decoded
+		// into reading values
+		functions.NewReadingDecoder().DecodeReadings,
 		app.vendingState.DeviceHelper,
 	)
 	if err != nil {
@@ -111,6 +395,32 @@ func (app *vendingAppService) CreateAndRunAppService(serviceKey string, newServi
 		return 1
 	}
 
+	// the stage timeouts are updated when the Vending configuration changes
+	// in the Configuration Provider, without restarting the service
+	if err := app.service.ListenForCustomConfigChanges(&config.VendingConfig{}, "Vending", app.ProcessConfigUpdates); err != nil {
+		app.lc.Errorf("unable to watch the Vending configuration for changes: %s", err.Error())
+		return 1
+	}
+
+	for _, door := range app.vendingState.Doors.Doors() {
+		if door != app.vendingState {
+			go door.MonitorReaders(app.lc)
+		}
+	}
+	go app.vendingState.MonitorReaders(app.lc)
+	go app.vendingState.RunIdleDisplay(app.lc)
+	go app.vendingState.RunSessionDisplay(app.lc)
+	go app.vendingState.MonitorFleet(app.lc)
+	go app.vendingState.Webhooks.Run(app.service.AppContext(), app.lc)
+	if remoteCommands != nil {
+		go app.vendingState.SubscribeRemoteCommands(app.service.AppContext(), app.lc, app.vendingState.Configuration.RemoteCommandBroker, app.vendingState.Configuration.RemoteCommandTopic, remoteCommands)
+	}
+	if heartbeatInterval > 0 {
+		go app.vendingState.PublishHeartbeats(app.service.AppContext(), app.lc, serviceKey, routes.CurrentVersion().Version, heartbeatInterval, func(topic string, event dtos.Event) error {
+			return app.service.PublishWithTopic(topic, event, common.ContentTypeJSON)
+		})
+	}
+
 	// tell the SDK to "start" and begin listening for events to trigger the pipeline.
 	err = app.service.Run()
 	if err != nil {
@@ -122,3 +432,16 @@ func (app *vendingAppService) CreateAndRunAppService(serviceKey string, newServi
 
 	return 0
 }
+
+// ProcessConfigUpdates applies the stage timeouts of the updated Vending
+// configuration. The other settings are only read when the service starts.
+func (app *vendingAppService) ProcessConfigUpdates(rawWritableConfig interface{}) {
+	updated, ok := rawWritableConfig.(*config.VendingConfig)
+	if !ok {
+		app.lc.Error("unable to process Vending configuration updates: cannot cast the raw configuration to VendingConfig")
+		return
+	}
+	if err := app.vendingState.UpdateStageTimeouts(app.lc, updated); err != nil {
+		app.lc.Errorf("rejected the updated stage timeouts, the current ones are kept: %s", err.Error())
+	}
+}
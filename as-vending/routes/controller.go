@@ -6,6 +6,7 @@ package routes
 import (
 	"as-vending/functions"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,19 +14,24 @@ import (
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 type Controller struct {
 	lc           logger.LoggingClient
 	service      interfaces.ApplicationService
 	vendingState *functions.VendingState
+	// tokenVerifier checks the tokens of the administrative routes, nil
+	// leaves them open
+	tokenVerifier *utilities.TokenVerifier
 }
 
-func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, vendingState *functions.VendingState) Controller {
+func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, vendingState *functions.VendingState, tokenVerifier *utilities.TokenVerifier) Controller {
 	return Controller{
-		lc:           lc,
-		service:      service,
-		vendingState: vendingState,
+		lc:            lc,
+		service:       service,
+		vendingState:  vendingState,
+		tokenVerifier: tokenVerifier,
 	}
 }
 
@@ -37,7 +43,7 @@ func (c *Controller) AddAllRoutes() error {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/resetDoorLock", c.ResetDoorLock, http.MethodPost)
+	err = c.service.AddRoute("/resetDoorLock", c.requireMaintainer(c.ResetDoorLock), http.MethodPost)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
@@ -47,15 +53,169 @@ func (c *Controller) AddAllRoutes() error {
 		return errWithMsg
 	}
 
+	err = c.service.AddRoute("/maintenanceMode", c.requireMaintainer(c.SetMaintenanceMode), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/slaReport", c.GetSLAReport, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/readerHealth", c.GetReaderHealth, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inferenceQuarantine", c.GetInferenceQuarantine, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/billingStatus", c.GetBillingStatus, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/resumeBilling", c.requireMaintainer(c.ResumeBilling), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/storeState", c.GetStoreState, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/storeState", c.requireMaintainer(c.SetStoreState), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/fleet/storeState", c.GetFleetStoreState, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/fleet/health", c.GetFleetHealth, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/fleet/storeState", c.requireMaintainer(c.SetFleetStoreState), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/enroll", c.GetEnrollment, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/enroll", c.requireMaintainer(c.StartEnrollment), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/enroll", c.requireMaintainer(c.CancelEnrollment), http.MethodDelete)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/priceCheck", c.GetPriceCheck, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/session/current", c.GetCurrentSession, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/workflow/state", c.GetWorkflowState, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/workflow/history", c.GetWorkflowHistory, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/workflow/cancel", c.requireMaintainer(c.CancelWorkflow), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/doors", c.GetDoors, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/pin", c.EnterPin, http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/manualEntry", c.EnterItems, http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/reconciliation", c.GetReconciliation, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/discrepancies", c.GetDiscrepancies, http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/outbox", c.requireMaintainer(c.GetOutbox), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/outbox/export", c.requireMaintainer(c.ExportOutbox), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/outbox/import", c.requireMaintainer(c.ImportOutbox), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/outbox/replay", c.requireMaintainer(c.ReplayOutbox), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/version", utilities.VersionHandler(CurrentVersion()), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
 	return nil
 
 }
 
 // GetMaintenanceMode will return a JSON response containing the boolean state
-// of the vendingState's maintenance mode.
+// of the vendingState's maintenance mode and the reasons it was set.
 func (c *Controller) GetMaintenanceMode(writer http.ResponseWriter, req *http.Request) {
+	vendingState, ok := c.door(writer, req)
+	if !ok {
+		return
+	}
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
 
-	mm, err := json.Marshal(functions.MaintenanceMode{MaintenanceMode: c.vendingState.MaintenanceMode})
+	mm, err := json.Marshal(functions.MaintenanceMode{
+		MaintenanceMode: vendingState.MaintenanceMode,
+		Reasons:         vendingState.MaintenanceReasons,
+	})
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to marshal requested state: %s", err.Error())
 		c.lc.Error(errMsg)
@@ -66,6 +226,510 @@ func (c *Controller) GetMaintenanceMode(writer http.ResponseWriter, req *http.Re
 	writer.Write(mm)
 }
 
+// SetMaintenanceMode endpoint for an operator to enter maintenance mode
+// for a reason code, or to clear a reason or every reason. The response is
+// the resulting maintenance mode. An unknown reason returns status code 400,
+// and 409 is returned for the reasons that have their own endpoint.
+func (c *Controller) SetMaintenanceMode(writer http.ResponseWriter, req *http.Request) {
+	vendingState, ok := c.door(writer, req)
+	if !ok {
+		return
+	}
+	var request functions.MaintenanceModeRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		errMsg := fmt.Sprintf("failed to read maintenance mode request: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	mm, err := vendingState.SetMaintenanceMode(c.lc, request)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, functions.ErrUnknownMaintenanceReason):
+			statusCode = http.StatusBadRequest
+		case errors.Is(err, functions.ErrManagedMaintenanceReason):
+			statusCode = http.StatusConflict
+		}
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	c.writeJSON(writer, "maintenance mode", mm)
+}
+
+// GetSLAReport will return a JSON response containing the compliance of
+// each vend workflow stage with its SLA target, and the recent breaches.
+func (c *Controller) GetSLAReport(writer http.ResponseWriter, req *http.Request) {
+	report, err := json.Marshal(c.vendingState.SLA.Report())
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal SLA report: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(report)
+}
+
+// GetReaderHealth will return a JSON response containing the health of each
+// card reader, based on when its last heartbeat was received.
+func (c *Controller) GetReaderHealth(writer http.ResponseWriter, req *http.Request) {
+	health, err := json.Marshal(c.vendingState.Readers.Health())
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal card reader health: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(health)
+}
+
+// GetInferenceQuarantine will return a JSON response containing the most
+// recent inference payloads that were rejected, with the vends they were for.
+func (c *Controller) GetInferenceQuarantine(writer http.ResponseWriter, req *http.Request) {
+	payloads, err := json.Marshal(c.vendingState.Quarantine.Payloads())
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal quarantined inference payloads: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(payloads)
+}
+
+// GetBillingStatus will return a JSON response containing whether vending is
+// suspended because transactions failed to post to the ledger service.
+func (c *Controller) GetBillingStatus(writer http.ResponseWriter, req *http.Request) {
+	status, err := json.Marshal(c.vendingState.Billing.Status())
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal billing status: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(status)
+}
+
+// ResumeBilling endpoint to resume vending once billing has been fixed
+func (c *Controller) ResumeBilling(writer http.ResponseWriter, req *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain")
+	returnval := "vending was not suspended"
+	c.vendingState.LockSession()
+	defer c.vendingState.UnlockSession()
+	if c.vendingState.ResumeBilling(c.lc) {
+		returnval = "resumed billing"
+	}
+	writer.WriteHeader(http.StatusOK)
+	if _, writeErr := writer.Write([]byte(returnval)); writeErr != nil {
+		c.lc.Errorf("Failed to write item data back to caller")
+	}
+}
+
+// GetStoreState will return a JSON response containing whether the vending
+// machine is open, or why it was closed.
+func (c *Controller) GetStoreState(writer http.ResponseWriter, req *http.Request) {
+	c.vendingState.LockSession()
+	defer c.vendingState.UnlockSession()
+	c.writeJSON(writer, "store state", c.vendingState.StoreState())
+}
+
+// SetStoreState endpoint to open or close the vending machine remotely. The
+// response is the resulting store state, which confirms the change.
+func (c *Controller) SetStoreState(writer http.ResponseWriter, req *http.Request) {
+	var request functions.StoreStateRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		errMsg := fmt.Sprintf("failed to read store state request: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.vendingState.LockSession()
+	defer c.vendingState.UnlockSession()
+	c.writeJSON(writer, "store state", c.vendingState.SetStoreState(c.lc, request))
+}
+
+// GetFleetStoreState will return a JSON response containing the store state
+// of each kiosk of the group in the group query parameter, or of every
+// kiosk without one.
+func (c *Controller) GetFleetStoreState(writer http.ResponseWriter, req *http.Request) {
+	group := req.URL.Query().Get("group")
+	if group == "" {
+		group = functions.FleetGroupAll
+	}
+	if _, ok := c.vendingState.Fleet.Kiosks(group); !ok {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(fmt.Sprintf("fleet group %s has no kiosks", group)))
+		return
+	}
+	c.writeJSON(writer, "fleet store state", c.vendingState.Fleet.StoreStates(group))
+}
+
+// GetFleetHealth will return a JSON response containing the health of each
+// kiosk of the fleet from the heartbeats of its services. Status code 404 is
+// returned when fleet monitoring is not configured.
+func (c *Controller) GetFleetHealth(writer http.ResponseWriter, req *http.Request) {
+	if c.vendingState.FleetHealth == nil {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte("fleet monitoring is not configured"))
+		return
+	}
+	c.writeJSON(writer, "fleet health", c.vendingState.FleetHealth.Health())
+}
+
+// SetFleetStoreState endpoint to open or close every kiosk of a group, such
+// as for a building evacuation or a holiday closure. The response reports
+// whether each kiosk confirmed the change, and has status code 502 when any
+// kiosk did not.
+func (c *Controller) SetFleetStoreState(writer http.ResponseWriter, req *http.Request) {
+	var request functions.FleetStoreStateRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		errMsg := fmt.Sprintf("failed to read fleet store state request: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if request.Group == "" {
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprintf("a group is required, %s for every kiosk", functions.FleetGroupAll)))
+		return
+	}
+	if _, ok := c.vendingState.Fleet.Kiosks(request.Group); !ok {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(fmt.Sprintf("fleet group %s has no kiosks", request.Group)))
+		return
+	}
+
+	// the kiosks check the token of the request as this kiosk did
+	report := c.vendingState.Fleet.SetStoreState(c.lc, request.Group, request.StoreStateRequest, req.Header.Get("Authorization"))
+	if report.Failed > 0 {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusBadGateway)
+	}
+	c.writeJSON(writer, "fleet report", report)
+}
+
+// GetEnrollment will return a JSON response containing the current or last
+// card enrollment
+func (c *Controller) GetEnrollment(writer http.ResponseWriter, req *http.Request) {
+	c.writeJSON(writer, "enrollment", c.vendingState.Enrollment.Status())
+}
+
+// GetPriceCheck endpoint to get the last barcode scanned between vends and
+// its product, so that the UI can show it like the LCD. It returns status
+// code 404 when no barcode has been scanned.
+func (c *Controller) GetPriceCheck(writer http.ResponseWriter, req *http.Request) {
+	result, ok := c.vendingState.PriceCheck.Last()
+	if !ok {
+		errMsg := "no barcode has been scanned"
+		c.lc.Debug(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.writeJSON(writer, "price check", result)
+}
+
+// GetCurrentSession will return a JSON response containing the progress of
+// the current session, the same progress the LCD shows
+func (c *Controller) GetCurrentSession(writer http.ResponseWriter, req *http.Request) {
+	if vendingState, ok := c.door(writer, req); ok {
+		vendingState.LockSession()
+		defer vendingState.UnlockSession()
+		c.writeJSON(writer, "current session", vendingState.CurrentSession(time.Now()))
+	}
+}
+
+// GetWorkflowState will return a JSON response containing the state of the
+// vend workflow, when it was entered and the account of the vend
+func (c *Controller) GetWorkflowState(writer http.ResponseWriter, req *http.Request) {
+	if vendingState, ok := c.door(writer, req); ok {
+		vendingState.LockSession()
+		defer vendingState.UnlockSession()
+		c.writeJSON(writer, "workflow state", vendingState.WorkflowStatus())
+	}
+}
+
+// GetWorkflowHistory will return a JSON response containing the most recent
+// transitions of the vend workflow
+func (c *Controller) GetWorkflowHistory(writer http.ResponseWriter, req *http.Request) {
+	if vendingState, ok := c.door(writer, req); ok {
+		c.writeJSON(writer, "workflow history", vendingState.Workflow.History())
+	}
+}
+
+// CancelWorkflow endpoint to abort the vend in progress, such as when a
+// customer walks away mid-session. It locks the door, voids the uncharged
+// basket intent of the session and returns the cancelled vend. 409 is
+// returned when no vend is in progress or its basket is being charged.
+func (c *Controller) CancelWorkflow(writer http.ResponseWriter, req *http.Request) {
+	vendingState, ok := c.door(writer, req)
+	if !ok {
+		return
+	}
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	cancellation, err := vendingState.CancelWorkflow(c.lc, req.Header.Get("Authorization"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, functions.ErrNoVendInProgress), errors.Is(err, functions.ErrVendSettling):
+			statusCode = http.StatusConflict
+		default:
+			c.lc.Errorf("failed to cancel the vend: %s", err.Error())
+		}
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	c.writeJSON(writer, "workflow cancellation", cancellation)
+}
+
+// pinEntry is the PIN the kiosk UI entered for the card waiting for it
+type pinEntry struct {
+	Pin string `json:"pin"`
+}
+
+// EnterPin endpoint for the kiosk UI to enter the PIN of the card waiting for
+// it, which unlocks the door and returns the current session once it is
+// right. A wrong PIN returns status code 401 and keeps waiting, a card that
+// was locked returns 423, and 409 is returned when no card is waiting.
+func (c *Controller) EnterPin(writer http.ResponseWriter, req *http.Request) {
+	var entry pinEntry
+	if err := json.NewDecoder(req.Body).Decode(&entry); err != nil {
+		errMsg := fmt.Sprintf("failed to read PIN: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.vendingState.LockSession()
+	defer c.vendingState.UnlockSession()
+	err := c.vendingState.EnterPin(c.lc, entry.Pin)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, functions.ErrPinNotAwaited):
+			statusCode = http.StatusConflict
+		case errors.Is(err, functions.ErrWrongPin):
+			statusCode = http.StatusUnauthorized
+		case errors.Is(err, functions.ErrCardLocked):
+			statusCode = http.StatusLocked
+		default:
+			c.lc.Errorf("failed to enter PIN: %s", err.Error())
+		}
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	c.writeJSON(writer, "current session", c.vendingState.CurrentSession(time.Now()))
+}
+
+// manualEntry is the items taken during a vend, entered on the kiosk UI
+// while inference is unavailable
+type manualEntry struct {
+	Items []functions.InferenceItem `json:"items"`
+}
+
+// EnterItems endpoint for the kiosk UI to enter the items taken during a
+// vend made while inference is unavailable, which charges them and returns
+// the vend flagged for reconciliation. Items without a SKU return status
+// code 400, and 409 is returned when no vend is waiting for its items.
+func (c *Controller) EnterItems(writer http.ResponseWriter, req *http.Request) {
+	vendingState, ok := c.door(writer, req)
+	if !ok {
+		return
+	}
+	var entry manualEntry
+	if err := json.NewDecoder(req.Body).Decode(&entry); err != nil {
+		errMsg := fmt.Sprintf("failed to read items: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
+	vend, err := vendingState.EnterItems(c.lc, entry.Items)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, functions.ErrManualEntryNotAwaited):
+			statusCode = http.StatusConflict
+		case errors.Is(err, functions.ErrInvalidManualEntry):
+			statusCode = http.StatusBadRequest
+		default:
+			c.lc.Errorf("failed to charge the items entered: %s", err.Error())
+		}
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	c.writeJSON(writer, "reconciliation vend", vend)
+}
+
+// GetReconciliation will return a JSON response containing the most recent
+// vends made while inference was unavailable, oldest first
+func (c *Controller) GetReconciliation(writer http.ResponseWriter, req *http.Request) {
+	c.writeJSON(writer, "reconciliation", c.vendingState.Reconciliation.Vends())
+}
+
+// GetDiscrepancies returns the most recent inference results that disagreed
+// with the weight sensor and how they were resolved, oldest first
+func (c *Controller) GetDiscrepancies(writer http.ResponseWriter, req *http.Request) {
+	c.writeJSON(writer, "weight discrepancies", c.vendingState.Discrepancies.Discrepancies())
+}
+
+// GetOutbox returns the charges, inventory deltas and audit log entries
+// that failed to be sent and are waiting to be replayed
+func (c *Controller) GetOutbox(writer http.ResponseWriter, req *http.Request) {
+	c.writeJSON(writer, "outbox", c.vendingState.Outbox.Entries())
+}
+
+// ExportOutbox returns the outbox and the pending session as a backup, to
+// import on the kiosk once it is reimaged
+func (c *Controller) ExportOutbox(writer http.ResponseWriter, req *http.Request) {
+	backup, err := c.vendingState.ExportOutbox()
+	if err != nil {
+		c.lc.Errorf("failed to export the outbox: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	c.writeJSON(writer, "outbox backup", backup)
+}
+
+// ImportOutbox adds the entries and pending session of an outbox backup to
+// the outbox, and replays them without applying any of them twice. It is
+// refused with status code 409 when the backup has a pending session and a
+// vend is in progress.
+func (c *Controller) ImportOutbox(writer http.ResponseWriter, req *http.Request) {
+	var backup functions.OutboxBackup
+	if err := json.NewDecoder(req.Body).Decode(&backup); err != nil {
+		errMsg := fmt.Sprintf("failed to read outbox backup: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	report, err := c.vendingState.ImportOutbox(c.lc, backup)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if errors.Is(err, functions.ErrOutboxImportVending) {
+			statusCode = http.StatusConflict
+		}
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	c.writeJSON(writer, "outbox replay", report)
+}
+
+// ReplayOutbox sends the entries of the outbox again, and returns what was
+// sent, what was already applied and what is still pending
+func (c *Controller) ReplayOutbox(writer http.ResponseWriter, req *http.Request) {
+	c.writeJSON(writer, "outbox replay", c.vendingState.ReplayOutbox(c.lc))
+}
+
+// StartEnrollment endpoint to put the kiosk in enrollment mode, in which the
+// next card swipe is enrolled for the request instead of opening the door.
+// It is refused with status code 409 while a customer is vending or another
+// enrollment is waiting, and 503 when card enrollment is not configured.
+func (c *Controller) StartEnrollment(writer http.ResponseWriter, req *http.Request) {
+	var request functions.EnrollmentRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		errMsg := fmt.Sprintf("failed to read enrollment request: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.vendingState.LockSession()
+	defer c.vendingState.UnlockSession()
+	status, err := c.vendingState.StartEnrollment(c.lc, request, req.Header.Get("Authorization"))
+	switch {
+	case errors.Is(err, functions.ErrEnrollmentDisabled):
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		writer.Write([]byte(err.Error()))
+		return
+	case err != nil:
+		writer.WriteHeader(http.StatusConflict)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	c.writeJSON(writer, "enrollment", status)
+}
+
+// CancelEnrollment endpoint to take the kiosk out of enrollment mode
+func (c *Controller) CancelEnrollment(writer http.ResponseWriter, req *http.Request) {
+	c.vendingState.LockSession()
+	defer c.vendingState.UnlockSession()
+	status, cancelled := c.vendingState.CancelEnrollment(c.lc)
+	if !cancelled {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte("no enrollment is waiting for a card swipe"))
+		return
+	}
+	c.writeJSON(writer, "enrollment", status)
+}
+
+// writeJSON writes the value as the JSON response
+func (c *Controller) writeJSON(writer http.ResponseWriter, name string, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal %s: %s", name, err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(body)
+}
+
+// door returns the door of the request's door query parameter, which is
+// the controller board of a door of the bank of coolers, or the door of the
+// vending state without one. Status code 404 is written for an unknown door.
+func (c *Controller) door(writer http.ResponseWriter, req *http.Request) (*functions.VendingState, bool) {
+	deviceName := req.URL.Query().Get("door")
+	if deviceName == "" {
+		return c.vendingState, true
+	}
+	if door, ok := c.vendingState.Doors.Door(deviceName); ok {
+		return door, true
+	}
+	if c.vendingState.Doors == nil && c.vendingState.Configuration != nil && deviceName == c.vendingState.Configuration.ControllerBoardDeviceName {
+		return c.vendingState, true
+	}
+	writer.WriteHeader(http.StatusNotFound)
+	writer.Write([]byte(fmt.Sprintf("no door has the device %q", deviceName)))
+	return nil, false
+}
+
+// GetDoors will return a JSON response containing the devices, vend workflow
+// state and maintenance mode of each door of the bank of coolers. Status
+// code 404 is returned when the service runs a single door.
+func (c *Controller) GetDoors(writer http.ResponseWriter, req *http.Request) {
+	if c.vendingState.Doors == nil {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte("the service runs a single door"))
+		return
+	}
+	c.writeJSON(writer, "doors", c.vendingState.Doors.Status())
+}
+
 func (c *Controller) errorAddRouteHandler(err error) error {
 	errorMsg := "error adding route: %s"
 	if err != nil {
@@ -80,24 +744,24 @@ func (c *Controller) ResetDoorLock(writer http.ResponseWriter, req *http.Request
 	writer.Header().Set("Content-Type", "text/plain")
 	// Check the HTTP Request's form values
 	returnval := "reset the door lock"
+	vendingState, ok := c.door(writer, req)
+	if !ok {
+		return
+	}
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
 
-	close(c.vendingState.ThreadStopChannel)
-	c.vendingState.ThreadStopChannel = make(chan int)
+	close(vendingState.ThreadStopChannel)
+	vendingState.ThreadStopChannel = make(chan int)
 
-	c.vendingState.MaintenanceMode = false
-	c.vendingState.CVWorkflowStarted = false
-	c.vendingState.DoorClosed = true
-	c.vendingState.DoorClosedDuringCVWorkflow = false
-	c.vendingState.DoorOpenedDuringCVWorkflow = false
-	c.vendingState.InferenceDataReceived = false
+	vendingState.ClearMaintenance(c.lc)
+	vendingState.ResetWorkflow(c.lc, "doorLockReset")
+	vendingState.DoorClosed = true
 
 	c.lc.Infof("Maintenance card scanned")
-	c.lc.Debugf("workflow: %t", c.vendingState.CVWorkflowStarted)
-	c.lc.Debugf("maintenance mode: %t", c.vendingState.MaintenanceMode)
-	c.lc.Debugf("open: %t", c.vendingState.DoorOpenedDuringCVWorkflow)
-	c.lc.Debugf("closed: %t", c.vendingState.DoorClosedDuringCVWorkflow)
-	c.lc.Debugf("Inference: %t", c.vendingState.InferenceDataReceived)
-	c.lc.Debugf("door: %t", c.vendingState.DoorClosed)
+	c.lc.Debugf("workflow: %s", vendingState.Workflow.State())
+	c.lc.Debugf("maintenance mode: %t", vendingState.MaintenanceMode)
+	c.lc.Debugf("door: %t", vendingState.DoorClosed)
 
 	// Write the HTTP status header
 	writer.WriteHeader(http.StatusOK)
@@ -126,6 +790,20 @@ func (c *Controller) BoardStatus(writer http.ResponseWriter, req *http.Request)
 	if err := json.Unmarshal(body, &boardStatus); err != nil {
 		c.lc.Errorf("Failed to read request data")
 	}
+	// the status of a controller board of a bank of coolers is for its door
+	vendingState := c.vendingState
+	if c.vendingState.Doors != nil && boardStatus.DeviceName != "" {
+		door, ok := c.vendingState.Doors.Door(boardStatus.DeviceName)
+		if !ok {
+			c.lc.Errorf("Board status received for unknown controller board %q", boardStatus.DeviceName)
+			writer.WriteHeader(http.StatusNotFound)
+			writer.Write([]byte(fmt.Sprintf("no door has the controller board %q", boardStatus.DeviceName)))
+			return
+		}
+		vendingState = door
+	}
+	vendingState.LockSession()
+	defer vendingState.UnlockSession()
 	returnval := "Board status received but maintenance mode was not set"
 	status = http.StatusOK
 
@@ -134,91 +812,67 @@ func (c *Controller) BoardStatus(writer http.ResponseWriter, req *http.Request)
 		returnval = string("Temperature status received and maintenance mode was set")
 		status = http.StatusOK
 		c.lc.Error("Cooler temperature exceeds the minimum temperature threshold. The cooler needs maintenance.")
-		c.vendingState.MaintenanceMode = true
+		vendingState.SetMaintenanceReason(c.lc, functions.ReasonTemperatureFault)
 	}
 	// Check controller board MaxTemperatureStatus state. If it's true then a maximum temperature event has happened
 	if boardStatus.MaxTemperatureStatus {
 		returnval = string("Temperature status received and maintenance mode was set")
 		status = http.StatusOK
 		c.lc.Error("Cooler temperature exceeds the maximum temperature threshold. The cooler needs maintenance.")
-		c.vendingState.MaintenanceMode = true
+		vendingState.SetMaintenanceReason(c.lc, functions.ReasonTemperatureFault)
+	}
+	// the temperature fault clears once the temperature is back within its thresholds
+	if !boardStatus.MinTemperatureStatus && !boardStatus.MaxTemperatureStatus {
+		vendingState.ClearMaintenanceReason(c.lc, functions.ReasonTemperatureFault)
+	}
+
+	// the door sensor fault clears once the sensor is no longer suspect
+	if boardStatus.DoorSensorFault {
+		returnval = "Door sensor fault received and maintenance mode was set"
+		c.lc.Error("The door sensor is suspect. The cooler needs maintenance.")
+		vendingState.SetMaintenanceReason(c.lc, functions.ReasonDoorSensorFault)
+	} else {
+		vendingState.ClearMaintenanceReason(c.lc, functions.ReasonDoorSensorFault)
+	}
+
+	// vending is held after power-on until the cooler has cooled down, or
+	// the warmup is overridden by an operator
+	if boardStatus.Warmup {
+		returnval = "Warmup received and maintenance mode was set"
+		vendingState.SetMaintenanceReason(c.lc, functions.ReasonWarmup)
+	} else {
+		vendingState.ClearMaintenanceReason(c.lc, functions.ReasonWarmup)
 	}
 
 	// Check to see if the board closed state is different from the previous state. If it is we need to update the state and
 	// set the related properties.
-	if c.vendingState.DoorClosed != boardStatus.DoorClosed {
+	if vendingState.DoorClosed != boardStatus.DoorClosed {
 		c.lc.Infof("Successfully updated the door event. Door closed: %v", boardStatus.DoorClosed)
 		returnval = string("Door closed change event was received ")
 		status = http.StatusOK //FIXME: This is an issue
-		c.vendingState.DoorClosed = boardStatus.DoorClosed
-		if c.vendingState.CVWorkflowStarted {
+		vendingState.DoorClosed = boardStatus.DoorClosed
+		if vendingState.Workflow.Vending() {
 			// If the door was opened then we want to wait for the door closed event
-			if !boardStatus.DoorClosed {
-				c.vendingState.DoorOpenedDuringCVWorkflow = true
+			if !boardStatus.DoorClosed && vendingState.Transition(c.lc, functions.StateDoorOpen, "doorOpened") {
+				vendingState.DoorOpened()
+
 				// Stop the open wait thread since the door is now opened
-				close(c.vendingState.DoorOpenWaitThreadStopChannel)
-				c.vendingState.DoorOpenWaitThreadStopChannel = make(chan int)
+				close(vendingState.DoorOpenWaitThreadStopChannel)
+				vendingState.DoorOpenWaitThreadStopChannel = make(chan int)
 
 				// Wait for door closed event. If the door isn't closed within the timeout
 				// then leave the workflow, remove the user data, and enter maintenance mode
-				go func() {
-					c.lc.Infof("Door Opened: wait for %v seconds", c.vendingState.DoorCloseStateTimeout)
-					for {
-						select {
-						case <-time.After(c.vendingState.DoorCloseStateTimeout):
-							{
-								if !c.vendingState.DoorClosedDuringCVWorkflow {
-									c.lc.Error("Door Opened: Failed")
-									c.vendingState.CVWorkflowStarted = false
-									c.vendingState.CurrentUserData = functions.OutputData{}
-									c.vendingState.MaintenanceMode = true
-								}
-								return
-							}
-						case <-c.vendingState.DoorCloseWaitThreadStopChannel:
-							c.lc.Info("Stopped the door closed wait thread")
-							return
-
-						case <-c.vendingState.ThreadStopChannel:
-							c.lc.Info("Globally stopped the door closed wait thread")
-							return
-						}
-					}
-				}()
+				vendingState.WaitForDoorClose(c.lc)
 			}
 			// If the door was closed we want to wait for the inference event
-			if boardStatus.DoorClosed {
-				c.vendingState.DoorClosedDuringCVWorkflow = true
+			if boardStatus.DoorClosed && vendingState.Transition(c.lc, functions.StateInferring, "doorClosed") {
 				// Stop the open wait thread since the door is now opened
-				close(c.vendingState.DoorCloseWaitThreadStopChannel)
-				c.vendingState.DoorCloseWaitThreadStopChannel = make(chan int)
+				close(vendingState.DoorCloseWaitThreadStopChannel)
+				vendingState.DoorCloseWaitThreadStopChannel = make(chan int)
 
 				// Wait for the inference data to be received. If we don't receive any inference data with the timeout
 				// then leave the workflow, remove the user data, and enter maintenance mode
-				go func() {
-					c.lc.Infof("Door Closed: wait for %v seconds", c.vendingState.InferenceTimeout)
-					for {
-						select {
-						case <-time.After(c.vendingState.InferenceTimeout):
-							{
-								if !c.vendingState.InferenceDataReceived {
-									c.lc.Error("Door Closed: Failed")
-									c.vendingState.CVWorkflowStarted = false
-									c.vendingState.CurrentUserData = functions.OutputData{}
-									c.vendingState.MaintenanceMode = true
-								}
-								return
-							}
-						case <-c.vendingState.InferenceWaitThreadStopChannel:
-							c.lc.Info("Stopped the inference wait thread")
-							return
-
-						case <-c.vendingState.ThreadStopChannel:
-							c.lc.Info("Globally stopped the inference wait thread")
-							return
-						}
-					}
-				}()
+				vendingState.WaitForInference(c.lc)
 			}
 		}
 	}
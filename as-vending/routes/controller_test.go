@@ -13,9 +13,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces/mocks"
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -72,17 +75,19 @@ func TestControllerAddAllRoutes(t *testing.T) {
 func TestGetMaintenanceMode(t *testing.T) {
 	maintModeTrue := functions.MaintenanceMode{
 		MaintenanceMode: true,
+		Reasons:         []functions.MaintenanceReason{functions.ReasonTemperatureFault},
 	}
 	maintModeFalse := functions.MaintenanceMode{
 		MaintenanceMode: false,
 	}
 
 	t.Run("TestGetMaintenanceMode MaintenanceMode=True", func(t *testing.T) {
-		var vendingState functions.VendingState
+		vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
 		var maintModeAPIResponse functions.MaintenanceMode
-		c := NewController(logger.NewMockClient(), nil, &vendingState)
+		c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
 		// set the vendingState's MaintenanceMode boolean accordingly
 		c.vendingState.MaintenanceMode = true
+		c.vendingState.MaintenanceReasons = []functions.MaintenanceReason{functions.ReasonTemperatureFault}
 
 		req := httptest.NewRequest(http.MethodGet, "/maintenanceMode", nil)
 		w := httptest.NewRecorder()
@@ -102,9 +107,9 @@ func TestGetMaintenanceMode(t *testing.T) {
 		assert.Equal(t, maintModeAPIResponse, maintModeTrue, "Received a maintenance mode response that was different than anticipated")
 	})
 	t.Run("TestGetMaintenanceMode MaintenanceMode=False", func(t *testing.T) {
-		var vendingState functions.VendingState
+		vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
 		var maintModeAPIResponse functions.MaintenanceMode
-		c := NewController(logger.NewMockClient(), nil, &vendingState)
+		c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
 		// set the vendingState's MaintenanceMode boolean accordingly
 		c.vendingState.MaintenanceMode = false
 
@@ -126,22 +131,50 @@ func TestGetMaintenanceMode(t *testing.T) {
 	})
 }
 
+func TestSetMaintenanceMode(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	vendingState := functions.VendingState{
+		Workflow:      functions.NewWorkflow(functions.StateIdle),
+		Configuration: &config.VendingConfig{},
+		CommandClient: mockCommandClient,
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	var mm functions.MaintenanceMode
+	w := httptest.NewRecorder()
+	c.SetMaintenanceMode(w, httptest.NewRequest(http.MethodPost, "/maintenanceMode", bytes.NewBufferString(`{"maintenanceMode":true,"reason":"manual"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &mm))
+	assert.Equal(t, functions.MaintenanceMode{MaintenanceMode: true, Reasons: []functions.MaintenanceReason{functions.ReasonManual}}, mm)
+
+	w = httptest.NewRecorder()
+	c.SetMaintenanceMode(w, httptest.NewRequest(http.MethodPost, "/maintenanceMode", bytes.NewBufferString(`{"maintenanceMode":true,"reason":"flooded"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	c.SetMaintenanceMode(w, httptest.NewRequest(http.MethodPost, "/maintenanceMode", bytes.NewBufferString(`{"reason":"billingUnavailable"}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	c.SetMaintenanceMode(w, httptest.NewRequest(http.MethodPost, "/maintenanceMode", bytes.NewBufferString(`{"maintenanceMode":false}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, vendingState.MaintenanceMode)
+}
+
 func TestResetDoorLock(t *testing.T) {
 	stopChannel := make(chan int)
-	var vendingState functions.VendingState
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
 	vendingState.ThreadStopChannel = stopChannel
-	c := NewController(logger.NewMockClient(), nil, &vendingState)
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
 	request, _ := http.NewRequest(http.MethodPost, "", nil)
 	recorder := httptest.NewRecorder()
 	handler := http.HandlerFunc(c.ResetDoorLock)
 	handler.ServeHTTP(recorder, request)
 
 	assert.Equal(t, false, c.vendingState.MaintenanceMode, "MaintanceMode should be false")
-	assert.Equal(t, false, c.vendingState.CVWorkflowStarted, "CVWorkflowStarted should be false")
+	assert.Equal(t, functions.StateIdle, c.vendingState.Workflow.State(), "the workflow should be idle")
 	assert.Equal(t, true, c.vendingState.DoorClosed, "DoorClosed should be false")
-	assert.Equal(t, false, c.vendingState.DoorClosedDuringCVWorkflow, "DoorClosedDuringCVWorkflow should be false")
-	assert.Equal(t, false, c.vendingState.DoorOpenedDuringCVWorkflow, "DoorOpenedDuringCVWorkflow should be false")
-	assert.Equal(t, false, c.vendingState.InferenceDataReceived, "InferenceDataReceived should be false")
 }
 
 func TestController_BoardStatus(t *testing.T) {
@@ -156,9 +189,9 @@ func TestController_BoardStatus(t *testing.T) {
 	}{
 		{"Board Status Open", fields{
 			vendingState: functions.VendingState{
-				DoorClosed:        false,
-				CVWorkflowStarted: true,
-				Configuration:     new(config.VendingConfig),
+				DoorClosed:    false,
+				Workflow:      functions.NewWorkflow(functions.StateAuthorized),
+				Configuration: new(config.VendingConfig),
 			},
 			boardStatus: functions.ControllerBoardStatus{
 				MaxTemperatureStatus: true,
@@ -169,9 +202,9 @@ func TestController_BoardStatus(t *testing.T) {
 		},
 		{"Board Status Closed", fields{
 			vendingState: functions.VendingState{
-				DoorClosed:        true,
-				CVWorkflowStarted: true,
-				Configuration:     new(config.VendingConfig),
+				DoorClosed:    true,
+				Workflow:      functions.NewWorkflow(functions.StateAuthorized),
+				Configuration: new(config.VendingConfig),
 			},
 			boardStatus: functions.ControllerBoardStatus{
 				MaxTemperatureStatus: true,
@@ -182,24 +215,639 @@ func TestController_BoardStatus(t *testing.T) {
 		},
 	}
 
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
+		// the wait threads of a test keep its vending state
+		currentTest := tt
+		t.Run(currentTest.name, func(t *testing.T) {
+			currentTest.fields.vendingState.CommandClient = mockCommandClient
 			doorOpenStopChannel := make(chan int)
 			doorCloseStopChannel := make(chan int)
-			tt.fields.vendingState.DoorOpenWaitThreadStopChannel = doorOpenStopChannel
-			tt.fields.vendingState.DoorCloseWaitThreadStopChannel = doorCloseStopChannel
-			b, _ := json.Marshal(tt.fields.boardStatus)
-			c := NewController(logger.NewMockClient(), nil, &tt.fields.vendingState)
+			currentTest.fields.vendingState.DoorOpenWaitThreadStopChannel = doorOpenStopChannel
+			currentTest.fields.vendingState.DoorCloseWaitThreadStopChannel = doorCloseStopChannel
+			b, _ := json.Marshal(currentTest.fields.boardStatus)
+			c := NewController(logger.NewMockClient(), nil, &currentTest.fields.vendingState, nil)
 			request, _ := http.NewRequest(http.MethodPost, "", bytes.NewBuffer(b))
 			recorder := httptest.NewRecorder()
 			handler := http.HandlerFunc(c.BoardStatus)
 			handler.ServeHTTP(recorder, request)
-			if tt.fields.vendingState.DoorClosedDuringCVWorkflow {
+			if currentTest.fields.vendingState.Workflow.State() == functions.StateInferring {
 				close(doorOpenStopChannel)
 			}
-			if tt.fields.vendingState.DoorOpenedDuringCVWorkflow {
+			if currentTest.fields.vendingState.Workflow.State() == functions.StateDoorOpen {
 				close(doorCloseStopChannel)
 			}
 		})
 	}
 }
+
+func TestController_BoardStatusTemperatureCleared(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := functions.VendingState{
+		Workflow:      functions.NewWorkflow(functions.StateIdle),
+		DoorClosed:    true,
+		Configuration: new(config.VendingConfig),
+		CommandClient: mockCommandClient,
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	postBoardStatus := func(boardStatus functions.ControllerBoardStatus) {
+		b, err := json.Marshal(boardStatus)
+		require.NoError(t, err)
+		request, _ := http.NewRequest(http.MethodPost, "", bytes.NewBuffer(b))
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(c.BoardStatus).ServeHTTP(recorder, request)
+	}
+
+	postBoardStatus(functions.ControllerBoardStatus{DoorClosed: true, MaxTemperatureStatus: true})
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []functions.MaintenanceReason{functions.ReasonTemperatureFault}, vendingState.MaintenanceReasons)
+
+	postBoardStatus(functions.ControllerBoardStatus{DoorClosed: true})
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.Empty(t, vendingState.MaintenanceReasons)
+}
+
+func TestController_BoardStatusDoorSensorFault(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := functions.VendingState{
+		Workflow:      functions.NewWorkflow(functions.StateIdle),
+		DoorClosed:    true,
+		Configuration: new(config.VendingConfig),
+		CommandClient: mockCommandClient,
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	postBoardStatus := func(boardStatus functions.ControllerBoardStatus) string {
+		b, err := json.Marshal(boardStatus)
+		require.NoError(t, err)
+		request, _ := http.NewRequest(http.MethodPost, "", bytes.NewBuffer(b))
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(c.BoardStatus).ServeHTTP(recorder, request)
+		return recorder.Body.String()
+	}
+
+	assert.Contains(t, postBoardStatus(functions.ControllerBoardStatus{DoorClosed: true, DoorSensorFault: true}), "Door sensor fault received")
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []functions.MaintenanceReason{functions.ReasonDoorSensorFault}, vendingState.MaintenanceReasons)
+
+	postBoardStatus(functions.ControllerBoardStatus{DoorClosed: true})
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.Empty(t, vendingState.MaintenanceReasons)
+}
+
+func TestController_BoardStatusWarmup(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := functions.VendingState{
+		Workflow:      functions.NewWorkflow(functions.StateIdle),
+		DoorClosed:    true,
+		Configuration: &config.VendingConfig{ControllerBoardDeviceName: "controller-board", ControllerBoardDisplayRow1Cmd: "displayRow1", ControllerBoardDisplayRow2Cmd: "displayRow2", ControllerBoardDisplayRow3Cmd: "displayRow3"},
+		CommandClient: mockCommandClient,
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	postBoardStatus := func(boardStatus functions.ControllerBoardStatus) string {
+		b, err := json.Marshal(boardStatus)
+		require.NoError(t, err)
+		request, _ := http.NewRequest(http.MethodPost, "", bytes.NewBuffer(b))
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(c.BoardStatus).ServeHTTP(recorder, request)
+		return recorder.Body.String()
+	}
+
+	assert.Contains(t, postBoardStatus(functions.ControllerBoardStatus{DoorClosed: true, Warmup: true}), "Warmup received")
+	assert.True(t, vendingState.MaintenanceMode)
+	assert.Equal(t, []functions.MaintenanceReason{functions.ReasonWarmup}, vendingState.MaintenanceReasons)
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow1", map[string]string{"displayRow1": "Please wait"})
+	mockCommandClient.AssertCalled(t, "IssueSetCommandByName", mock.Anything, "controller-board", "displayRow2", map[string]string{"displayRow2": "Cooling down"})
+
+	// servicing the machine does not cool it down
+	vendingState.ClearMaintenance(c.lc)
+	assert.Equal(t, []functions.MaintenanceReason{functions.ReasonWarmup}, vendingState.MaintenanceReasons)
+
+	postBoardStatus(functions.ControllerBoardStatus{DoorClosed: true})
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.Empty(t, vendingState.MaintenanceReasons)
+}
+
+func TestGetSLAReport(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	vendingState.SLA = functions.NewSLATracker(map[functions.SLAStage]time.Duration{functions.SLAStageAuth: time.Second}, nil)
+	vendingState.SLA.Record(logger.NewMockClient(), functions.SLAStageAuth, 2*time.Second, functions.OutputData{AccountID: 1})
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/slaReport", nil)
+	w := httptest.NewRecorder()
+	c.GetSLAReport(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var report functions.SLAReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	require.Len(t, report.Stages, 3)
+	assert.Equal(t, functions.SLAStageAuth, report.Stages[0].Stage)
+	assert.Equal(t, 1, report.Stages[0].Breaches)
+	require.Len(t, report.RecentBreaches, 1)
+	assert.Equal(t, 1, report.RecentBreaches[0].AccountID)
+}
+
+func TestGetReaderHealth(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	vendingState.Readers = functions.NewReaderMonitor(time.Minute, []string{"card-reader"}, nil)
+	vendingState.Readers.Heartbeat(logger.NewMockClient(), "card-reader")
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/readerHealth", nil)
+	w := httptest.NewRecorder()
+	c.GetReaderHealth(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var health []functions.ReaderHealth
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+	require.Len(t, health, 1)
+	assert.Equal(t, "card-reader", health[0].DeviceName)
+	assert.True(t, health[0].Online)
+	assert.NotZero(t, health[0].LastSeen)
+}
+
+func TestBillingStatusAndResume(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	vendingState.Configuration = &config.VendingConfig{ControllerBoardDeviceName: "controller-board"}
+	vendingState.CommandClient = mockCommandClient
+	vendingState.Billing = functions.NewBillingCircuit(1, nil)
+	lc := logger.NewMockClient()
+	vendingState.Billing.Record(lc, fmt.Errorf("ledger unavailable"))
+	vendingState.SetMaintenanceReason(lc, functions.ReasonBillingUnavailable)
+	c := NewController(lc, nil, &vendingState, nil)
+
+	w := httptest.NewRecorder()
+	c.GetBillingStatus(w, httptest.NewRequest(http.MethodGet, "/billingStatus", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var status functions.BillingStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Suspended)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	assert.Equal(t, "ledger unavailable", status.LastError)
+
+	w = httptest.NewRecorder()
+	c.ResumeBilling(w, httptest.NewRequest(http.MethodPost, "/resumeBilling", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "resumed billing", w.Body.String())
+	assert.False(t, vendingState.MaintenanceMode)
+	assert.False(t, vendingState.Billing.Suspended())
+
+	w = httptest.NewRecorder()
+	c.ResumeBilling(w, httptest.NewRequest(http.MethodPost, "/resumeBilling", nil))
+	assert.Equal(t, "vending was not suspended", w.Body.String())
+}
+
+func TestGetInferenceQuarantine(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	vendingState.Quarantine = functions.NewInferenceQuarantine()
+	vendingState.Quarantine.Add(logger.NewMockClient(), `{"schemaVersion":2}`, fmt.Errorf("unsupported inference schema version 2, expected 1"), functions.OutputData{AccountID: 1}, "42")
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	w := httptest.NewRecorder()
+	c.GetInferenceQuarantine(w, httptest.NewRequest(http.MethodGet, "/inferenceQuarantine", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var payloads []functions.QuarantinedInference
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payloads))
+	require.Len(t, payloads, 1)
+	assert.Equal(t, `{"schemaVersion":2}`, payloads[0].Payload)
+	assert.Equal(t, "42", payloads[0].SessionID)
+}
+
+func TestFleetStoreState(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	lc := logger.NewMockClient()
+
+	// a kiosk serving its store state endpoints
+	kioskState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	kioskState.Configuration = &config.VendingConfig{KioskID: "kiosk-1", ControllerBoardDeviceName: "controller-board"}
+	kioskState.CommandClient = mockCommandClient
+	kioskController := NewController(lc, nil, &kioskState, nil)
+	kiosk := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			kioskController.SetStoreState(w, r)
+			return
+		}
+		kioskController.GetStoreState(w, r)
+	}))
+	defer kiosk.Close()
+
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	vendingState.Fleet = functions.NewFleet([]functions.FleetKiosk{{Group: "building-a", KioskID: "kiosk-1", URL: kiosk.URL}}, time.Second)
+	c := NewController(lc, nil, &vendingState, nil)
+
+	w := httptest.NewRecorder()
+	c.SetFleetStoreState(w, httptest.NewRequest(http.MethodPost, "/fleet/storeState", bytes.NewBufferString(`{"group":"building-a","open":false,"reason":"evacuation"}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report functions.FleetReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Confirmed)
+	require.Len(t, report.Kiosks, 1)
+	assert.Equal(t, "kiosk-1", report.Kiosks[0].State.KioskID)
+	assert.True(t, kioskState.MaintenanceMode)
+	assert.Equal(t, []functions.MaintenanceReason{functions.ReasonStoreClosed}, kioskState.MaintenanceReasons)
+
+	w = httptest.NewRecorder()
+	c.GetFleetStoreState(w, httptest.NewRequest(http.MethodGet, "/fleet/storeState?group=building-a", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Kiosks, 1)
+	assert.False(t, report.Kiosks[0].State.Open)
+	assert.Equal(t, "evacuation", report.Kiosks[0].State.Reason)
+
+	w = httptest.NewRecorder()
+	c.SetFleetStoreState(w, httptest.NewRequest(http.MethodPost, "/fleet/storeState", bytes.NewBufferString(`{"group":"all","open":true}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, kioskState.MaintenanceMode)
+
+	w = httptest.NewRecorder()
+	c.SetFleetStoreState(w, httptest.NewRequest(http.MethodPost, "/fleet/storeState", bytes.NewBufferString(`{"group":"building-b","open":true}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	c.SetFleetStoreState(w, httptest.NewRequest(http.MethodPost, "/fleet/storeState", bytes.NewBufferString(`{"open":true}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// a kiosk that cannot be reached fails the request
+	kiosk.Close()
+	w = httptest.NewRecorder()
+	c.SetFleetStoreState(w, httptest.NewRequest(http.MethodPost, "/fleet/storeState", bytes.NewBufferString(`{"group":"building-a","open":false}`)))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Failed)
+}
+
+func TestEnrollment(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	lc := logger.NewMockClient()
+
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	vendingState.Configuration = &config.VendingConfig{ControllerBoardDeviceName: "controller-board"}
+	vendingState.CommandClient = mockCommandClient
+	c := NewController(lc, nil, &vendingState, nil)
+
+	// without an enrollment endpoint enrollment is disabled
+	w := httptest.NewRecorder()
+	c.StartEnrollment(w, httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewBufferString(`{}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	vendingState.Enrollment = functions.NewEnrollment("http://localhost:48096/enroll", time.Minute)
+	w = httptest.NewRecorder()
+	c.StartEnrollment(w, httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewBufferString(`{"accountID":2}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var status functions.EnrollmentStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, functions.EnrollmentWaiting, status.State)
+	require.NotNil(t, status.Request)
+	assert.Equal(t, 2, status.Request.AccountID)
+
+	w = httptest.NewRecorder()
+	c.StartEnrollment(w, httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewBufferString(`{}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	c.GetEnrollment(w, httptest.NewRequest(http.MethodGet, "/enroll", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, functions.EnrollmentWaiting, status.State)
+
+	w = httptest.NewRecorder()
+	c.CancelEnrollment(w, httptest.NewRequest(http.MethodDelete, "/enroll", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, functions.EnrollmentCancelled, status.State)
+
+	w = httptest.NewRecorder()
+	c.CancelEnrollment(w, httptest.NewRequest(http.MethodDelete, "/enroll", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// enrollment is refused while a customer is vending
+	vendingState.Workflow = functions.NewWorkflow(functions.StateAuthorized)
+	w = httptest.NewRecorder()
+	c.StartEnrollment(w, httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewBufferString(`{}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	c.StartEnrollment(w, httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewBufferString(`{`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetPriceCheck(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	// without a barcode scanner there is no price check
+	w := httptest.NewRecorder()
+	c.GetPriceCheck(w, httptest.NewRequest(http.MethodGet, "/priceCheck", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	vendingState.PriceCheck = functions.NewPriceCheck("barcode-scanner", "http://localhost:48095/inventory", nil)
+	w = httptest.NewRecorder()
+	c.GetPriceCheck(w, httptest.NewRequest(http.MethodGet, "/priceCheck", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetCurrentSession(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle), Configuration: &config.VendingConfig{KioskID: "kiosk-1"}}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	var status functions.SessionStatus
+	w := httptest.NewRecorder()
+	c.GetCurrentSession(w, httptest.NewRequest(http.MethodGet, "/session/current", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, functions.SessionIdle, status.Stage)
+	assert.Equal(t, "kiosk-1", status.KioskID)
+
+	// the countdown is to the timeout of the vend's current stage
+	vendingState.Workflow = functions.NewWorkflow(functions.StateDoorOpen)
+	vendingState.SessionID = "session-1"
+	vendingState.StageDeadline = time.Now().Add(time.Minute)
+	w = httptest.NewRecorder()
+	c.GetCurrentSession(w, httptest.NewRequest(http.MethodGet, "/session/current", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, functions.SessionDoorOpen, status.Stage)
+	assert.Equal(t, "session-1", status.SessionID)
+	assert.InDelta(t, 60, status.RemainingSeconds, 1)
+	assert.Equal(t, vendingState.StageDeadline.UnixNano(), status.TimeoutAt)
+}
+
+func TestEnterPin(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	// no card is waiting for a PIN
+	w := httptest.NewRecorder()
+	c.EnterPin(w, httptest.NewRequest(http.MethodPost, "/pin", bytes.NewBufferString(`{"pin":"1234"}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	vendingState.PinEntry = functions.NewPinEntry("http://localhost:48096/authentication/verify-pin", time.Minute)
+	w = httptest.NewRecorder()
+	c.EnterPin(w, httptest.NewRequest(http.MethodPost, "/pin", bytes.NewBufferString(`{"pin":"1234"}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	c.EnterPin(w, httptest.NewRequest(http.MethodPost, "/pin", bytes.NewBufferString(`{`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestManualEntry(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle), Reconciliation: functions.NewReconciliationLog()}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	// no vend is waiting for its items to be entered
+	w := httptest.NewRecorder()
+	c.EnterItems(w, httptest.NewRequest(http.MethodPost, "/manualEntry", bytes.NewBufferString(`{"items":[{"sku":"HXI86WHU","delta":-1}]}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	c.EnterItems(w, httptest.NewRequest(http.MethodPost, "/manualEntry", bytes.NewBufferString(`{`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	vendingState.Reconciliation.Add(logger.NewMockClient(), functions.ReconciliationVend{Fallback: "billLater", AccountID: 1, SessionID: "42"})
+	w = httptest.NewRecorder()
+	c.GetReconciliation(w, httptest.NewRequest(http.MethodGet, "/reconciliation", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var vends []functions.ReconciliationVend
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vends))
+	require.Len(t, vends, 1)
+	assert.Equal(t, "billLater", vends[0].Fallback)
+	assert.Equal(t, "42", vends[0].SessionID)
+}
+
+func TestGetDiscrepancies(t *testing.T) {
+	discrepancies, err := functions.NewDiscrepancyLog("")
+	require.NoError(t, err)
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle), Discrepancies: discrepancies}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	discrepancies.Add(logger.NewMockClient(), functions.WeightDiscrepancy{SessionID: "42", Policy: functions.CrossCheckRequireAgreement, ExpectedGrams: -355, MeasuredGrams: -710, Resolution: functions.DiscrepancyFlaggedForReview})
+	w := httptest.NewRecorder()
+	c.GetDiscrepancies(w, httptest.NewRequest(http.MethodGet, "/discrepancies", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var records []functions.WeightDiscrepancy
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+	require.Len(t, records, 1)
+	assert.Equal(t, "42", records[0].SessionID)
+	assert.Equal(t, functions.DiscrepancyFlaggedForReview, records[0].Resolution)
+	assert.Equal(t, -710.0, records[0].MeasuredGrams)
+}
+
+func TestOutbox(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer testServer.Close()
+	outbox, err := functions.NewOutbox("")
+	require.NoError(t, err)
+	vendingState := functions.VendingState{
+		Workflow: functions.NewWorkflow(functions.StateIdle),
+		Configuration: &config.VendingConfig{
+			KioskID:                  "kiosk-1",
+			LedgerService:            testServer.URL + "/ledger",
+			InventoryService:         testServer.URL + "/inventory/delta",
+			InventoryAuditLogService: testServer.URL + "/auditlog",
+		},
+		Outbox: outbox,
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	w := httptest.NewRecorder()
+	c.ImportOutbox(w, httptest.NewRequest(http.MethodPost, "/outbox/import", bytes.NewBufferString(`{"version":1,"kioskId":"kiosk-1","entries":[{"id":"inventoryDelta:session-1","kind":"inventoryDelta","sessionId":"session-1","body":[{"sku":"HXI86WHU","delta":-1}]}]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var report functions.OutboxReplayReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, functions.OutboxReplayReport{Sent: 1, Pending: []functions.OutboxEntry{}}, report)
+
+	w = httptest.NewRecorder()
+	c.ImportOutbox(w, httptest.NewRequest(http.MethodPost, "/outbox/import", bytes.NewBufferString(`{"version":2}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	c.ImportOutbox(w, httptest.NewRequest(http.MethodPost, "/outbox/import", bytes.NewBufferString(`{`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	c.GetOutbox(w, httptest.NewRequest(http.MethodGet, "/outbox", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	c.ExportOutbox(w, httptest.NewRequest(http.MethodGet, "/outbox/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var backup functions.OutboxBackup
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &backup))
+	assert.Equal(t, "kiosk-1", backup.KioskID)
+	assert.Empty(t, backup.Entries)
+	assert.Nil(t, backup.Session)
+
+	w = httptest.NewRecorder()
+	c.ReplayOutbox(w, httptest.NewRequest(http.MethodPost, "/outbox/replay", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, functions.OutboxReplayReport{Pending: []functions.OutboxEntry{}}, report)
+}
+
+func TestGetWorkflow(t *testing.T) {
+	vendingState := functions.VendingState{
+		Workflow:        functions.NewWorkflow(functions.StateAuthorized),
+		CurrentUserData: functions.OutputData{AccountID: 1, CardID: "0003278425", RoleID: 1},
+		SessionID:       "session-1",
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+	require.True(t, vendingState.Transition(logger.NewMockClient(), functions.StateDoorOpen, "doorOpened"))
+
+	var status functions.WorkflowStatus
+	w := httptest.NewRecorder()
+	c.GetWorkflowState(w, httptest.NewRequest(http.MethodGet, "/workflow/state", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, functions.StateDoorOpen, status.State)
+	assert.Equal(t, "session-1", status.SessionID)
+	assert.Equal(t, 1, status.AccountID)
+	assert.Equal(t, "0003278425", status.CardID)
+
+	var history functions.WorkflowHistory
+	w = httptest.NewRecorder()
+	c.GetWorkflowHistory(w, httptest.NewRequest(http.MethodGet, "/workflow/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history.Transitions, 1)
+	assert.Equal(t, functions.StateAuthorized, history.Transitions[0].From)
+	assert.Equal(t, "doorOpened", history.Transitions[0].Event)
+	assert.Equal(t, history.Transitions[0].At, status.EnteredAt)
+}
+
+func TestCancelWorkflow(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	vendingState := functions.VendingState{
+		Workflow:          functions.NewWorkflow(functions.StateAuthorized),
+		ThreadStopChannel: make(chan int),
+		CurrentUserData:   functions.OutputData{AccountID: 1, CardID: "0003278425", RoleID: 2},
+		SessionID:         "session-1",
+		Configuration:     &config.VendingConfig{ControllerBoardLock1Cmd: "lock1"},
+		CommandClient:     mockCommandClient,
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	var cancellation functions.WorkflowCancellation
+	w := httptest.NewRecorder()
+	c.CancelWorkflow(w, httptest.NewRequest(http.MethodPost, "/workflow/cancel", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cancellation))
+	assert.Equal(t, functions.StateAuthorized, cancellation.State)
+	assert.Equal(t, "session-1", cancellation.SessionID)
+	assert.True(t, cancellation.DoorLocked)
+	assert.Equal(t, functions.StateIdle, vendingState.Workflow.State())
+
+	w = httptest.NewRecorder()
+	c.CancelWorkflow(w, httptest.NewRequest(http.MethodPost, "/workflow/cancel", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestGetFleetHealth(t *testing.T) {
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	w := httptest.NewRecorder()
+	c.GetFleetHealth(w, httptest.NewRequest(http.MethodGet, "/fleet/health", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	vendingState.FleetHealth = functions.NewFleetMonitor(time.Minute, []string{"kiosk-1"}, nil)
+	vendingState.FleetHealth.Heartbeat(logger.NewMockClient(), functions.Heartbeat{ServiceKey: "as-vending", KioskID: "kiosk-1", Healthy: true})
+
+	var health []functions.KioskHealth
+	w = httptest.NewRecorder()
+	c.GetFleetHealth(w, httptest.NewRequest(http.MethodGet, "/fleet/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	require.Len(t, health, 1)
+	assert.True(t, health[0].Online)
+	require.Len(t, health[0].Services, 1)
+	assert.Equal(t, "as-vending", health[0].Services[0].ServiceKey)
+}
+
+func TestDoors(t *testing.T) {
+	vendingState := functions.VendingState{
+		Workflow:   functions.NewWorkflow(functions.StateIdle),
+		DoorClosed: true,
+		Configuration: &config.VendingConfig{
+			ControllerBoardDeviceName: "controller-board",
+			InferenceDeviceName:       "Inference-device",
+			CardReaderDeviceName:      "card-reader",
+		},
+	}
+	c := NewController(logger.NewMockClient(), nil, &vendingState, nil)
+
+	w := httptest.NewRecorder()
+	c.GetDoors(w, httptest.NewRequest(http.MethodGet, "/doors", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var err error
+	vendingState.Doors, err = functions.NewDoorBank(&vendingState, []functions.Door{{ControllerBoardDeviceName: "controller-board-2", InferenceDeviceName: "Inference-device-2", CardReaderDeviceName: "card-reader-2"}})
+	require.NoError(t, err)
+	second := vendingState.Doors.Doors()[1]
+
+	// the board status of the other door's controller board opens that door
+	b, err := json.Marshal(functions.ControllerBoardStatus{DeviceName: "controller-board-2", DoorClosed: false})
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	c.BoardStatus(w, httptest.NewRequest(http.MethodPost, "/boardStatus", bytes.NewBuffer(b)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, second.DoorClosed)
+	assert.True(t, vendingState.DoorClosed)
+
+	b, err = json.Marshal(functions.ControllerBoardStatus{DeviceName: "controller-board-9"})
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	c.BoardStatus(w, httptest.NewRequest(http.MethodPost, "/boardStatus", bytes.NewBuffer(b)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var doors []functions.DoorStatus
+	w = httptest.NewRecorder()
+	c.GetDoors(w, httptest.NewRequest(http.MethodGet, "/doors", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doors))
+	require.Len(t, doors, 2)
+	assert.Equal(t, "controller-board", doors[0].ControllerBoardDeviceName)
+	assert.True(t, doors[0].DoorClosed)
+	assert.Equal(t, "controller-board-2", doors[1].ControllerBoardDeviceName)
+	assert.False(t, doors[1].DoorClosed)
+
+	// the workflow routes take the door as a query parameter
+	var status functions.WorkflowStatus
+	w = httptest.NewRecorder()
+	c.GetWorkflowState(w, httptest.NewRequest(http.MethodGet, "/workflow/state?door=controller-board-2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, functions.StateIdle, status.State)
+
+	w = httptest.NewRecorder()
+	c.GetWorkflowState(w, httptest.NewRequest(http.MethodGet, "/workflow/state?door=controller-board-9", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,12 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import "net/http"
+
+// requireMaintainer wraps an administrative route handler to only serve
+// requests with the token of a maintainer card
+func (c *Controller) requireMaintainer(handler http.HandlerFunc) http.HandlerFunc {
+	return c.tokenVerifier.RequireMaintainer(c.lc, handler)
+}
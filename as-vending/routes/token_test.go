@@ -0,0 +1,89 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"as-vending/config"
+	"as-vending/functions"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	client_mocks "github.com/edgexfoundry/go-mod-core-contracts/v3/clients/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/dtos/common"
+	"github.com/golang-jwt/jwt"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func signTestToken(t *testing.T, roleID int) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, utilities.AuthClaims{
+		PersonID: 1,
+		RoleID:   roleID,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    utilities.TokenIssuer,
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	return "Bearer " + token
+}
+
+// TestFleetStoreStateToken tests that a fleet request needs a maintainer
+// token, which is passed on to the kiosks that check it too
+func TestFleetStoreStateToken(t *testing.T) {
+	mockCommandClient := &client_mocks.CommandClient{}
+	mockCommandClient.On("IssueSetCommandByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(common.BaseResponse{StatusCode: http.StatusOK}, nil)
+	lc := logger.NewMockClient()
+
+	kioskState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	kioskState.Configuration = &config.VendingConfig{KioskID: "kiosk-1", ControllerBoardDeviceName: "controller-board"}
+	kioskState.CommandClient = mockCommandClient
+	kioskController := NewController(lc, nil, &kioskState, utilities.NewTokenVerifier("secret"))
+	setStoreState := kioskController.requireMaintainer(kioskController.SetStoreState)
+	kiosk := httptest.NewServer(http.HandlerFunc(setStoreState))
+	defer kiosk.Close()
+
+	vendingState := functions.VendingState{Workflow: functions.NewWorkflow(functions.StateIdle)}
+	vendingState.Fleet = functions.NewFleet([]functions.FleetKiosk{{Group: "building-a", KioskID: "kiosk-1", URL: kiosk.URL}}, time.Second)
+	c := NewController(lc, nil, &vendingState, utilities.NewTokenVerifier("secret"))
+	setFleetStoreState := c.requireMaintainer(c.SetFleetStoreState)
+
+	tests := []struct {
+		Name           string
+		Authorization  string
+		ExpectedStatus int
+		ExpectedClosed bool
+	}{
+		{"no token", "", http.StatusUnauthorized, false},
+		{"consumer", signTestToken(t, 1), http.StatusForbidden, false},
+		{"maintainer", signTestToken(t, utilities.MaintainerRoleID), http.StatusOK, true},
+		{"admin", signTestToken(t, utilities.AdminRoleID), http.StatusOK, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/fleet/storeState", bytes.NewBufferString(`{"group":"building-a","open":false,"reason":"evacuation"}`))
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			setFleetStoreState(w, req)
+			require.Equal(t, currentTest.ExpectedStatus, w.Code, w.Body.String())
+			assert.Equal(t, currentTest.ExpectedClosed, kioskState.MaintenanceMode)
+			if currentTest.ExpectedStatus != http.StatusOK {
+				return
+			}
+			var report functions.FleetReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, 1, report.Confirmed)
+		})
+	}
+}
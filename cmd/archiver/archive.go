@@ -0,0 +1,264 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/intel-retail/automated-vending/pkg/client"
+)
+
+// The datasets of the archive
+const (
+	datasetTelemetry    = "telemetry"
+	datasetSessions     = "sessions"
+	datasetTransactions = "transactions"
+)
+
+// datasetColumns are the columns of the files of each dataset
+var datasetColumns = map[string][]column{
+	datasetTelemetry: {
+		{"kiosk_id", kindString},
+		{"timestamp", kindTimestamp},
+		{"temperature", kindDouble},
+		{"humidity", kindDouble},
+		{"door_closed", kindBool},
+		{"lock1_status", kindInt64},
+		{"lock2_status", kindInt64},
+		{"min_temperature_status", kindBool},
+		{"max_temperature_status", kindBool},
+	},
+	datasetSessions: {
+		{"kiosk_id", kindString},
+		{"timestamp", kindTimestamp},
+		{"from_state", kindString},
+		{"to_state", kindString},
+		{"event", kindString},
+	},
+	datasetTransactions: {
+		{"kiosk_id", kindString},
+		{"account_id", kindInt64},
+		{"transaction_id", kindInt64},
+		{"tx_timestamp", kindTimestamp},
+		{"created_at", kindTimestamp},
+		{"currency", kindString},
+		{"line_total", kindDouble},
+		{"line_total_minor", kindInt64},
+		{"subtotal_minor", kindInt64},
+		{"tax_minor", kindInt64},
+		{"item_count", kindInt64},
+		{"is_paid", kindBool},
+		{"is_voided", kindBool},
+		{"is_test", kindBool},
+		{"refund_of", kindInt64},
+		{"split_id", kindInt64},
+	},
+}
+
+// record is a row of a dataset, with the time that its partition is chosen
+// by
+type record struct {
+	at     time.Time
+	values []interface{}
+}
+
+// archiveState is how far the sessions and transactions have been archived,
+// so that a restarted archiver does not archive them again
+type archiveState struct {
+	// SessionsAfter is the time of the last archived workflow transition
+	SessionsAfter int64 `json:"sessionsAfter,string"`
+	// TransactionsAfter is the creation time of the last archived
+	// transaction
+	TransactionsAfter int64 `json:"transactionsAfter,string"`
+}
+
+// archiver collects the board telemetry, the vend workflow transitions and
+// the transactions of a kiosk, and writes them to Parquet files partitioned
+// by dataset, date and kiosk
+type archiver struct {
+	client    *client.Client
+	store     store
+	kioskID   string
+	stateFile string
+	// state is how far the flushed files go, and pending how far the
+	// collected records go
+	state   archiveState
+	pending archiveState
+	records map[string][]record
+	now     func() time.Time
+}
+
+func newArchiver(c *client.Client, s store, kioskID string, stateFile string) (*archiver, error) {
+	a := &archiver{
+		client:    c,
+		store:     s,
+		kioskID:   kioskID,
+		stateFile: stateFile,
+		records:   map[string][]record{},
+		now:       time.Now,
+	}
+	data, err := os.ReadFile(stateFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read state file %s: %s", stateFile, err.Error())
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &a.state); err != nil {
+			return nil, fmt.Errorf("failed to parse state file %s: %s", stateFile, err.Error())
+		}
+	}
+	a.pending = a.state
+	return a, nil
+}
+
+// collect samples the board telemetry and collects the workflow transitions
+// and transactions since the last collection. A service that cannot be
+// reached is skipped, and collected from again the next time.
+func (a *archiver) collect(ctx context.Context) error {
+	var errs []error
+	now := a.now()
+
+	status, err := a.client.BoardStatus.Status(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("board status: %s", err.Error()))
+	} else {
+		a.add(datasetTelemetry, record{at: now, values: []interface{}{
+			a.kioskID, now.UnixNano(), status.Temperature, status.Humidity, status.DoorClosed,
+			int64(status.Lock1), int64(status.Lock2), status.MinTemperatureStatus, status.MaxTemperatureStatus,
+		}})
+	}
+
+	history, err := a.client.Vending.WorkflowHistory(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("workflow history: %s", err.Error()))
+	} else {
+		for _, transition := range history.Transitions {
+			if transition.At <= a.pending.SessionsAfter {
+				continue
+			}
+			a.add(datasetSessions, record{at: time.Unix(0, transition.At), values: []interface{}{
+				a.kioskID, transition.At, transition.From, transition.To, transition.Event,
+			}})
+			a.pending.SessionsAfter = transition.At
+		}
+	}
+
+	accounts, err := a.client.Ledger.Accounts(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("ledger: %s", err.Error()))
+	} else {
+		transactionsAfter := a.pending.TransactionsAfter
+		for _, account := range accounts.Data {
+			for _, ledger := range account.Ledgers {
+				if ledger.CreatedAt <= a.pending.TransactionsAfter {
+					continue
+				}
+				var itemCount int64
+				for _, item := range ledger.LineItems {
+					itemCount += int64(item.ItemCount)
+				}
+				a.add(datasetTransactions, record{at: time.Unix(0, ledger.CreatedAt), values: []interface{}{
+					a.kioskID, int64(account.AccountID), ledger.TransactionID, ledger.TxTimeStamp, ledger.CreatedAt,
+					ledger.Currency, ledger.LineTotal, ledger.LineTotalMinor, ledger.SubtotalMinor, ledger.TaxMinor,
+					itemCount, ledger.IsPaid, ledger.IsVoided, ledger.IsTest, ledger.RefundOf, ledger.SplitID,
+				}})
+				if ledger.CreatedAt > transactionsAfter {
+					transactionsAfter = ledger.CreatedAt
+				}
+			}
+		}
+		a.pending.TransactionsAfter = transactionsAfter
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *archiver) add(dataset string, r record) {
+	a.records[dataset] = append(a.records[dataset], r)
+}
+
+// partitionKey is the key of a file of the dataset for the date, such as
+// sessions/date=2023-10-01/kiosk=kiosk-1/sessions-1696118400000000000.parquet
+func partitionKey(dataset string, date string, kioskID string, flushedAt time.Time) string {
+	return fmt.Sprintf("%s/date=%s/kiosk=%s/%s-%s.parquet", dataset, date, kioskID, dataset, strconv.FormatInt(flushedAt.UnixNano(), 10))
+}
+
+// flush writes the collected records of each dataset to a file per UTC date,
+// and saves how far the archive goes once every file was written. The
+// records of a file that could not be written are kept for the next flush.
+func (a *archiver) flush(ctx context.Context) (int, error) {
+	flushedAt := a.now()
+	written := 0
+	var errs []error
+
+	datasets := make([]string, 0, len(a.records))
+	for dataset := range a.records {
+		datasets = append(datasets, dataset)
+	}
+	sort.Strings(datasets)
+	for _, dataset := range datasets {
+		byDate := map[string][]record{}
+		for _, r := range a.records[dataset] {
+			date := r.at.UTC().Format("2006-01-02")
+			byDate[date] = append(byDate[date], r)
+		}
+		var kept []record
+		for date, records := range byDate {
+			rows := make([][]interface{}, len(records))
+			for i, r := range records {
+				rows[i] = r.values
+			}
+			var data bytes.Buffer
+			err := writeParquet(&data, datasetColumns[dataset], rows)
+			if err == nil {
+				err = a.store.Put(ctx, partitionKey(dataset, date, a.kioskID, flushedAt), data.Bytes())
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s of %s: %s", dataset, date, err.Error()))
+				kept = append(kept, records...)
+				continue
+			}
+			written++
+		}
+		if len(kept) == 0 {
+			delete(a.records, dataset)
+		} else {
+			a.records[dataset] = kept
+		}
+	}
+	if len(errs) > 0 {
+		return written, errors.Join(errs...)
+	}
+
+	// the state only moves on once everything collected is in the archive,
+	// so that records that were not written are collected again after a
+	// restart
+	a.state = a.pending
+	return written, a.saveState()
+}
+
+// saveState writes the state file through a temporary file, so that it is
+// never left partially written
+func (a *archiver) saveState() error {
+	data, err := json.Marshal(a.state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.stateFile), 0755); err != nil {
+		return err
+	}
+	tempName := filepath.Join(filepath.Dir(a.stateFile), "."+filepath.Base(a.stateFile)+".tmp")
+	if err := os.WriteFile(tempName, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempName, a.stateFile)
+}
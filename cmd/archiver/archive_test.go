@@ -0,0 +1,144 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/intel-retail/automated-vending/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServicesTestServer serves the board status, workflow history and
+// ledger of a kiosk
+func newServicesTestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/status":
+			writer.Write([]byte(`{"lock1_status":1,"lock2_status":1,"door_closed":true,"temperature":4.5,"humidity":30,"minTemperatureStatus":false,"maxTemperatureStatus":false}`))
+		case "/workflow/history":
+			writer.Write([]byte(`{"transitions":[
+				{"from":"idle","to":"authorized","event":"cardAuthorized","at":"1696118400000000000"},
+				{"from":"authorized","to":"doorOpen","event":"doorOpened","at":"1696204800000000000"}]}`))
+		case "/ledger":
+			writer.Write([]byte(`{"data":[{"accountID":1,"ledgers":[
+				{"transactionID":"1696118400000000001","txTimeStamp":"1696118400000000001","createdAt":"1696118400000000001","lineTotal":1.99,"isPaid":false,"lineItems":[{"sku":"4900002470","itemCount":2}]}]}]}`))
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestArchiver(t *testing.T, s store) *archiver {
+	server := newServicesTestServer(t)
+	options := client.DefaultOptions()
+	options.Endpoints = client.Endpoints{Ledger: server.URL, Vending: server.URL, BoardStatus: server.URL}
+	a, err := newArchiver(client.New(options), s, "kiosk-1", filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	a.now = func() time.Time { return time.Unix(0, 1696204800000000000) }
+	return a
+}
+
+func TestArchive(t *testing.T) {
+	output := t.TempDir()
+	s, err := newStore(output, "", nil)
+	require.NoError(t, err)
+	a := newTestArchiver(t, s)
+
+	require.NoError(t, a.collect(context.Background()))
+	written, err := a.flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, written)
+
+	var files []string
+	require.NoError(t, filepath.Walk(output, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			relative, _ := filepath.Rel(output, path)
+			files = append(files, filepath.ToSlash(relative))
+		}
+		return err
+	}))
+	sort.Strings(files)
+	assert.Equal(t, []string{
+		"sessions/date=2023-10-01/kiosk=kiosk-1/sessions-1696204800000000000.parquet",
+		"sessions/date=2023-10-02/kiosk=kiosk-1/sessions-1696204800000000000.parquet",
+		"telemetry/date=2023-10-02/kiosk=kiosk-1/telemetry-1696204800000000000.parquet",
+		"transactions/date=2023-10-01/kiosk=kiosk-1/transactions-1696204800000000000.parquet",
+	}, files)
+	assert.Equal(t, archiveState{SessionsAfter: 1696204800000000000, TransactionsAfter: 1696118400000000001}, a.state)
+
+	// the sessions and transactions already archived are not collected
+	// again, even after a restart
+	restarted, err := newArchiver(a.client, s, "kiosk-1", a.stateFile)
+	require.NoError(t, err)
+	require.NoError(t, restarted.collect(context.Background()))
+	assert.Len(t, restarted.records[datasetTelemetry], 1)
+	assert.Empty(t, restarted.records[datasetSessions])
+	assert.Empty(t, restarted.records[datasetTransactions])
+}
+
+// failingStore fails to write every file
+type failingStore struct{}
+
+func (failingStore) Put(context.Context, string, []byte) error {
+	return errors.New("storage unavailable")
+}
+
+func TestArchiveFlushFailed(t *testing.T) {
+	a := newTestArchiver(t, failingStore{})
+	require.NoError(t, a.collect(context.Background()))
+
+	written, err := a.flush(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, written)
+	// the records are kept for the next flush, and the state is not moved
+	// on until they are written
+	assert.Len(t, a.records[datasetSessions], 2)
+	assert.Equal(t, archiveState{}, a.state)
+	_, err = os.Stat(a.stateFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestObjectStore(t *testing.T) {
+	var path, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		path = req.URL.Path
+		authorization = req.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	s, err := newStore(server.URL+"/archive/", "token", server.Client())
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), "sessions/date=2023-10-01/kiosk=kiosk-1/sessions-1.parquet", []byte(parquetMagic)))
+	assert.Equal(t, "/archive/sessions/date=2023-10-01/kiosk=kiosk-1/sessions-1.parquet", path)
+	assert.Equal(t, "Bearer token", authorization)
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := config{KioskID: "kiosk-1", StateFile: "state.json", SampleInterval: time.Minute, FlushInterval: time.Hour}
+	assert.NoError(t, valid.validate())
+
+	invalid := valid
+	invalid.FlushInterval = time.Second
+	assert.Error(t, invalid.validate())
+	invalid = valid
+	invalid.KioskID = ""
+	assert.Error(t, invalid.validate())
+}
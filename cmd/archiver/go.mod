@@ -0,0 +1,20 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+module github.com/intel-retail/automated-vending/cmd/archiver
+
+go 1.21
+
+require (
+	github.com/intel-retail/automated-vending/pkg/client v0.0.0
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// pkg/client is the module of the client in this repository
+replace github.com/intel-retail/automated-vending/pkg/client => ../../pkg/client
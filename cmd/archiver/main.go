@@ -0,0 +1,133 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// archiver periodically writes the controller board telemetry, the vend
+// workflow transitions and the ledger transactions of a kiosk to Parquet
+// files, partitioned by date and kiosk, in a local directory or object
+// storage, so that they can be analyzed long after the services have
+// rotated them out.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/intel-retail/automated-vending/pkg/client"
+)
+
+func main() {
+	endpoints := client.DefaultEndpoints()
+	var cfg config
+	flag.StringVar(&endpoints.Ledger, "ledger", endpoints.Ledger, "ms-ledger base URL")
+	flag.StringVar(&endpoints.Vending, "vending", endpoints.Vending, "as-vending base URL")
+	flag.StringVar(&endpoints.BoardStatus, "board-status", endpoints.BoardStatus, "as-controller-board-status base URL")
+	flag.StringVar(&cfg.KioskID, "kiosk", "", "kiosk ID that the files are partitioned by, the host name when empty")
+	flag.StringVar(&cfg.Output, "output", "archive", "local directory, or http(s) URL of object storage, to write the files to")
+	flag.StringVar(&cfg.StorageToken, "storage-token", "", "bearer token sent with the files PUT to object storage")
+	flag.StringVar(&cfg.StateFile, "state-file", "archiver-state.json", "file that keeps how far the sessions and transactions have been archived")
+	flag.DurationVar(&cfg.SampleInterval, "sample-interval", time.Minute, "how often the telemetry is sampled and new sessions and transactions are collected")
+	flag.DurationVar(&cfg.FlushInterval, "flush-interval", time.Hour, "how often the collected records are written to files")
+	flag.BoolVar(&cfg.Once, "once", false, "collect and write once, then exit, i.e. when run from cron")
+	flag.Parse()
+
+	if cfg.KioskID == "" {
+		cfg.KioskID, _ = os.Hostname()
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	options := client.DefaultOptions()
+	options.Endpoints = endpoints
+	httpClient := &http.Client{Timeout: client.DefaultTimeout}
+	options.HTTPClient = httpClient
+	s, err := newStore(cfg.Output, cfg.StorageToken, httpClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid output: %s\n", err.Error())
+		os.Exit(1)
+	}
+	a, err := newArchiver(client.New(options), s, cfg.KioskID, cfg.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	run(ctx, a, cfg)
+}
+
+// run collects every sample interval and writes the files every flush
+// interval, until the context is done, and writes what was collected
+// before it returns
+func run(ctx context.Context, a *archiver, cfg config) {
+	collect := func() {
+		if err := a.collect(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to collect: %s\n", err.Error())
+		}
+	}
+	flush := func() {
+		// the last flush runs after the context is done
+		written, err := a.flush(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write files: %s\n", err.Error())
+		}
+		if written > 0 {
+			fmt.Printf("wrote %d files to %s\n", written, cfg.Output)
+		}
+	}
+
+	collect()
+	if cfg.Once {
+		flush()
+		return
+	}
+	sampleTicker := time.NewTicker(cfg.SampleInterval)
+	defer sampleTicker.Stop()
+	flushTicker := time.NewTicker(cfg.FlushInterval)
+	defer flushTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+		case <-sampleTicker.C:
+			collect()
+		case <-flushTicker.C:
+			flush()
+		}
+	}
+}
+
+type config struct {
+	KioskID        string
+	Output         string
+	StorageToken   string
+	StateFile      string
+	SampleInterval time.Duration
+	FlushInterval  time.Duration
+	Once           bool
+}
+
+func (cfg config) validate() error {
+	if cfg.KioskID == "" {
+		return fmt.Errorf("kiosk must be set")
+	}
+	if cfg.StateFile == "" {
+		return fmt.Errorf("state-file must be set")
+	}
+	if cfg.SampleInterval <= 0 {
+		return fmt.Errorf("sample-interval must be greater than 0")
+	}
+	if cfg.FlushInterval < cfg.SampleInterval {
+		return fmt.Errorf("flush-interval must not be shorter than sample-interval")
+	}
+	return nil
+}
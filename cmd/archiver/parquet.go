@@ -0,0 +1,340 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The archive files are written in the Parquet format with a single row
+// group, one uncompressed, PLAIN encoded data page per column, and only
+// required columns, which is as much of the format as the archive needs and
+// is read by any Parquet reader.
+// See https://github.com/apache/parquet-format for the format.
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// columnKind is the type of the values of a column
+type columnKind int
+
+const (
+	kindInt64 columnKind = iota
+	// kindTimestamp is an int64 of nanoseconds since the epoch in UTC
+	kindTimestamp
+	kindDouble
+	kindBool
+	kindString
+)
+
+// column is a column of an archive file
+type column struct {
+	Name string
+	Kind columnKind
+}
+
+// Parquet physical types, encodings and the other enums of the format that
+// are written
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	repetitionRequired = 0
+	convertedUTF8      = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+
+	createdBy = "automated-checkout archiver"
+)
+
+// Thrift compact protocol field types
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// physicalType returns the Parquet type that the values of the kind are
+// stored as
+func (kind columnKind) physicalType() int64 {
+	switch kind {
+	case kindDouble:
+		return parquetDouble
+	case kindBool:
+		return parquetBoolean
+	case kindString:
+		return parquetByteArray
+	default:
+		return parquetInt64
+	}
+}
+
+// columnChunk is where a column was written in the file
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// writeParquet writes the rows as a Parquet file with the columns. Each row
+// has a value of the kind of each column: an int64 for kindInt64 and
+// kindTimestamp, a float64, a bool or a string.
+func writeParquet(w io.Writer, columns []column, rows [][]interface{}) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	chunks := make([]columnChunk, len(columns))
+	for i, col := range columns {
+		values, err := encodePlain(col, i, rows)
+		if err != nil {
+			return err
+		}
+		header := &thriftWriter{}
+		header.structBegin()
+		header.i32(1, pageTypeData)
+		header.i32(2, int64(len(values)))
+		header.i32(3, int64(len(values)))
+		header.structField(5)
+		header.i32(1, int64(len(rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.structEnd()
+		header.structEnd()
+
+		chunks[i] = columnChunk{offset: int64(file.Len()), size: int64(header.buf.Len() + len(values))}
+		file.Write(header.buf.Bytes())
+		file.Write(values)
+	}
+
+	footer := fileMetaData(columns, chunks, int64(len(rows)))
+	file.Write(footer)
+	if err := binary.Write(&file, binary.LittleEndian, uint32(len(footer))); err != nil {
+		return err
+	}
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// encodePlain encodes the values of the column in the PLAIN encoding.
+// Required columns have no repetition or definition levels.
+func encodePlain(col column, index int, rows [][]interface{}) ([]byte, error) {
+	var values bytes.Buffer
+	var bits byte
+	for rowIndex, row := range rows {
+		if len(row) <= index {
+			return nil, fmt.Errorf("row %d has no value for column %s", rowIndex, col.Name)
+		}
+		ok := true
+		switch col.Kind {
+		case kindInt64, kindTimestamp:
+			var value int64
+			value, ok = row[index].(int64)
+			_ = binary.Write(&values, binary.LittleEndian, value)
+		case kindDouble:
+			var value float64
+			value, ok = row[index].(float64)
+			_ = binary.Write(&values, binary.LittleEndian, math.Float64bits(value))
+		case kindBool:
+			// booleans are bit packed, the first value in the lowest bit
+			var value bool
+			value, ok = row[index].(bool)
+			if value {
+				bits |= 1 << (rowIndex % 8)
+			}
+			if rowIndex%8 == 7 {
+				values.WriteByte(bits)
+				bits = 0
+			}
+		case kindString:
+			var value string
+			value, ok = row[index].(string)
+			_ = binary.Write(&values, binary.LittleEndian, uint32(len(value)))
+			values.WriteString(value)
+		}
+		if !ok {
+			return nil, fmt.Errorf("row %d has a %T for column %s", rowIndex, row[index], col.Name)
+		}
+	}
+	if col.Kind == kindBool && len(rows)%8 != 0 {
+		values.WriteByte(bits)
+	}
+	return values.Bytes(), nil
+}
+
+// fileMetaData encodes the footer of the file, with the schema of the
+// columns and where each was written
+func fileMetaData(columns []column, chunks []columnChunk, numRows int64) []byte {
+	t := &thriftWriter{}
+	t.structBegin()
+	t.i32(1, 1)
+
+	t.listBegin(2, thriftStruct, len(columns)+1)
+	t.structBegin()
+	t.binary(4, "schema")
+	t.i32(5, int64(len(columns)))
+	t.structEnd()
+	for _, col := range columns {
+		t.structBegin()
+		t.i32(1, col.Kind.physicalType())
+		t.i32(3, repetitionRequired)
+		t.binary(4, col.Name)
+		switch col.Kind {
+		case kindString:
+			t.i32(6, convertedUTF8)
+			t.structField(10)
+			// LogicalType STRING
+			t.structField(1)
+			t.structEnd()
+			t.structEnd()
+		case kindTimestamp:
+			t.structField(10)
+			// LogicalType TIMESTAMP, adjusted to UTC, in NANOS
+			t.structField(8)
+			t.boolean(1, true)
+			t.structField(2)
+			t.structField(3)
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+		}
+		t.structEnd()
+	}
+
+	t.i64(3, numRows)
+
+	var totalSize int64
+	for _, chunk := range chunks {
+		totalSize += chunk.size
+	}
+	t.listBegin(4, thriftStruct, 1)
+	t.structBegin()
+	t.listBegin(1, thriftStruct, len(columns))
+	for i, col := range columns {
+		t.structBegin()
+		t.i64(2, chunks[i].offset)
+		t.structField(3)
+		t.i32(1, col.Kind.physicalType())
+		t.listBegin(2, thriftI32, 1)
+		t.varint(zigzag(encodingPlain))
+		t.listBegin(3, thriftBinary, 1)
+		t.bytes(col.Name)
+		t.i32(4, codecUncompressed)
+		t.i64(5, numRows)
+		t.i64(6, chunks[i].size)
+		t.i64(7, chunks[i].size)
+		t.i64(9, chunks[i].offset)
+		t.structEnd()
+		t.structEnd()
+	}
+	t.i64(2, totalSize)
+	t.i64(3, numRows)
+	t.structEnd()
+
+	t.binary(6, createdBy)
+	t.structEnd()
+	return t.buf.Bytes()
+}
+
+// thriftWriter writes the Thrift compact protocol that the Parquet footer
+// and page headers are encoded in
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastField is the last field ID written in each struct being written,
+	// innermost last
+	lastField []int16
+}
+
+func (t *thriftWriter) structBegin() {
+	t.lastField = append(t.lastField, 0)
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+// fieldHeader writes the header of the field, as a delta of the last field
+// ID when it is small enough
+func (t *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	last := &t.lastField[len(t.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, value int64) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(zigzag(value))
+}
+
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(value))
+}
+
+func (t *thriftWriter) boolean(id int16, value bool) {
+	if value {
+		t.fieldHeader(id, thriftTrue)
+	} else {
+		t.fieldHeader(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) binary(id int16, value string) {
+	t.fieldHeader(id, thriftBinary)
+	t.bytes(value)
+}
+
+// structField begins a struct field, which is ended with structEnd
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+// listBegin writes the header of a list field, whose elements are written
+// next without field headers
+func (t *thriftWriter) listBegin(id int16, elementType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elementType)
+		return
+	}
+	t.buf.WriteByte(0xF0 | elementType)
+	t.varint(uint64(size))
+}
+
+func (t *thriftWriter) bytes(value string) {
+	t.varint(uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+func (t *thriftWriter) varint(value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	t.buf.Write(encoded[:binary.PutUvarint(encoded[:], value)])
+}
+
+// zigzag maps signed integers to unsigned ones so that small negative
+// numbers have short varints
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
@@ -0,0 +1,165 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thriftReader reads the Thrift compact protocol into maps of field IDs to
+// values, so that the tests check the files as a Parquet reader sees them
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) zigzag() int64 {
+	value := r.varint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		size := int(r.varint())
+		value := string(r.data[r.pos : r.pos+size])
+		r.pos += size
+		return value
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		elements := make([]interface{}, size)
+		for i := range elements {
+			elements[i] = r.value(header & 0x0F)
+		}
+		return elements
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unexpected thrift type")
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fieldType := header & 0x0F
+		if fieldType == thriftTrue || fieldType == thriftFalse {
+			fields[id] = fieldType == thriftTrue
+		} else {
+			fields[id] = r.value(fieldType)
+		}
+		last = id
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	columns := []column{
+		{"kiosk_id", kindString},
+		{"timestamp", kindTimestamp},
+		{"temperature", kindDouble},
+		{"door_closed", kindBool},
+		{"item_count", kindInt64},
+	}
+	var rows [][]interface{}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, []interface{}{"kiosk-1", int64(1696118400000000000 + i), 4.5 + float64(i), i%3 == 0, int64(i)})
+	}
+	var file bytes.Buffer
+	require.NoError(t, writeParquet(&file, columns, rows))
+	data := file.Bytes()
+
+	require.Equal(t, parquetMagic, string(data[:4]))
+	require.Equal(t, parquetMagic, string(data[len(data)-4:]))
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerSize
+	footer := &thriftReader{data: data[:len(data)-8], pos: footerStart}
+	metaData := footer.readStruct()
+	assert.Equal(t, footerStart+footerSize, footer.pos, "the footer is read to its end")
+
+	assert.Equal(t, int64(1), metaData[1])
+	assert.Equal(t, int64(10), metaData[3])
+	schema := metaData[2].([]interface{})
+	require.Len(t, schema, 6)
+	assert.Equal(t, int64(5), schema[0].(map[int16]interface{})[5])
+	timestamp := schema[2].(map[int16]interface{})
+	assert.Equal(t, "timestamp", timestamp[4])
+	assert.Equal(t, map[int16]interface{}{8: map[int16]interface{}{1: true, 2: map[int16]interface{}{3: map[int16]interface{}{}}}}, timestamp[10])
+	assert.Equal(t, int64(convertedUTF8), schema[1].(map[int16]interface{})[6])
+
+	rowGroups := metaData[4].([]interface{})
+	require.Len(t, rowGroups, 1)
+	chunks := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	require.Len(t, chunks, len(columns))
+	for i, col := range columns {
+		chunkMetaData := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		assert.Equal(t, []interface{}{col.Name}, chunkMetaData[3])
+		assert.Equal(t, col.Kind.physicalType(), chunkMetaData[1])
+		assert.Equal(t, int64(10), chunkMetaData[5])
+
+		page := &thriftReader{data: data, pos: int(chunkMetaData[9].(int64))}
+		pageHeader := page.readStruct()
+		assert.Equal(t, int64(10), pageHeader[5].(map[int16]interface{})[1])
+		values := data[page.pos : page.pos+int(pageHeader[3].(int64))]
+		assert.Equal(t, chunkMetaData[6], int64(page.pos)-chunkMetaData[9].(int64)+int64(len(values)))
+
+		for rowIndex, row := range rows {
+			switch col.Kind {
+			case kindString:
+				size := int(binary.LittleEndian.Uint32(values))
+				assert.Equal(t, row[i], string(values[4:4+size]))
+				values = values[4+size:]
+			case kindInt64, kindTimestamp:
+				assert.Equal(t, row[i], int64(binary.LittleEndian.Uint64(values)))
+				values = values[8:]
+			case kindDouble:
+				assert.Equal(t, row[i], math.Float64frombits(binary.LittleEndian.Uint64(values)))
+				values = values[8:]
+			case kindBool:
+				assert.Equal(t, row[i], values[rowIndex/8]&(1<<(rowIndex%8)) != 0)
+			}
+		}
+	}
+}
+
+func TestWriteParquetWrongValue(t *testing.T) {
+	var file bytes.Buffer
+	err := writeParquet(&file, []column{{"item_count", kindInt64}}, [][]interface{}{{1}})
+	assert.Error(t, err, "an int is not an int64")
+}
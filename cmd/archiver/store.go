@@ -0,0 +1,85 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// store keeps the archive files under their keys, such as
+// telemetry/date=2023-10-01/kiosk=kiosk-1/telemetry-1696118400000000000.parquet
+type store interface {
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// newStore returns the store of the output, which is an http or https URL
+// of object storage that the files are PUT to, or a local directory
+func newStore(output string, token string, client *http.Client) (store, error) {
+	if output == "" {
+		return nil, fmt.Errorf("output must be a directory or an object storage URL")
+	}
+	if strings.HasPrefix(output, "http://") || strings.HasPrefix(output, "https://") {
+		return &objectStore{baseURL: strings.TrimSuffix(output, "/"), token: token, client: client}, nil
+	}
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory %s: %s", output, err.Error())
+	}
+	return &localStore{directory: output}, nil
+}
+
+// localStore keeps the files in a local directory
+type localStore struct {
+	directory string
+}
+
+// Put writes the file to a temporary file that is renamed into place, so
+// that a reader never sees a partial file
+func (s *localStore) Put(_ context.Context, key string, data []byte) error {
+	name := filepath.Join(s.directory, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tempName := filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err := os.WriteFile(tempName, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempName, name)
+}
+
+// objectStore PUTs the files to object storage under its base URL, such as
+// an S3 compatible bucket that accepts PUT requests with the bearer token
+type objectStore struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func (s *objectStore) Put(ctx context.Context, key string, data []byte) error {
+	url := s.baseURL + "/" + key
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.apache.parquet")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("PUT %s returned %d: %s", url, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var productNames = []string{
+	"Sprite (Lemon-Lime) - 16.9 oz",
+	"Mountain Dew - 16.9 oz",
+	"Pringles (Original) - 5.2 oz",
+	"Ruffles (Original) - 1 oz",
+	"Gatorade (Glacier Freeze) - 28 oz",
+	"Water (Dejablue) - 16.9 oz",
+	"Trail Mix - 8 oz",
+	"Granola Bar - 1.5 oz",
+}
+
+type product struct {
+	SKU                string  `json:"sku"`
+	ProductName        string  `json:"productName"`
+	ItemPrice          float64 `json:"itemPrice"`
+	UnitsOnHand        int     `json:"unitsOnHand"`
+	MaxRestockingLevel int     `json:"maxRestockingLevel"`
+	MinRestockingLevel int     `json:"minRestockingLevel"`
+	IsActive           bool    `json:"isActive"`
+}
+
+type deltaSKU struct {
+	SKU   string `json:"sku"`
+	Delta int    `json:"delta"`
+}
+
+type deltaLedger struct {
+	AccountID int        `json:"accountId"`
+	DeltaSKUs []deltaSKU `json:"deltaSKUs"`
+}
+
+type ledgerAccount struct {
+	AccountID int           `json:"accountID"`
+	Ledgers   []interface{} `json:"ledgers"`
+}
+
+type ledgerSeed struct {
+	Data []ledgerAccount `json:"data"`
+}
+
+// generator produces deterministic synthetic data for a given random source.
+// The random source is not safe for concurrent use, so it is guarded.
+type generator struct {
+	mutex    sync.Mutex
+	rnd      *rand.Rand
+	accounts int
+	maxItems int
+	products []product
+}
+
+func newGenerator(rnd *rand.Rand, accounts int, products int, maxItems int) *generator {
+	gen := &generator{rnd: rnd, accounts: accounts, maxItems: maxItems}
+	for i := 0; i < products; i++ {
+		gen.products = append(gen.products, product{
+			SKU:                fmt.Sprintf("%010d", 9000000000+i),
+			ProductName:        productNames[i%len(productNames)],
+			ItemPrice:          float64(99+rnd.Intn(400)) / 100,
+			UnitsOnHand:        24,
+			MaxRestockingLevel: 24,
+			MinRestockingLevel: 0,
+			IsActive:           true,
+		})
+	}
+	return gen
+}
+
+// transaction returns a random basket for a random account. Most shoppers
+// take a single item, so the basket size is skewed towards small baskets.
+func (gen *generator) transaction() deltaLedger {
+	gen.mutex.Lock()
+	defer gen.mutex.Unlock()
+
+	tx := deltaLedger{AccountID: gen.rnd.Intn(gen.accounts) + 1}
+	items := 1 + int(gen.rnd.ExpFloat64())%gen.maxItems
+	for _, i := range gen.rnd.Perm(len(gen.products))[:min(items, len(gen.products))] {
+		tx.DeltaSKUs = append(tx.DeltaSKUs, deltaSKU{SKU: gen.products[i].SKU, Delta: -(1 + gen.rnd.Intn(2))})
+	}
+	return tx
+}
+
+func (gen *generator) writeLedgerSeed(fileName string) error {
+	seed := ledgerSeed{Data: []ledgerAccount{}}
+	for i := 1; i <= gen.accounts; i++ {
+		seed.Data = append(seed.Data, ledgerAccount{AccountID: i, Ledgers: []interface{}{}})
+	}
+	data, err := json.Marshal(seed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger seed: %s", err.Error())
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+func seedInventory(client *http.Client, inventoryEndpoint string, products []product, rep *report) error {
+	data, err := json.Marshal(products)
+	if err != nil {
+		return fmt.Errorf("failed to marshal products: %s", err.Error())
+	}
+	return post(client, "inventory seed", inventoryEndpoint, data, rep)
+}
+
+// run drives transactions at the rate given by the profile until the
+// configured duration elapses, spreading the work across the workers
+func run(client *http.Client, cfg config, gen *generator, rate profile, rep *report) {
+	jobs := make(chan deltaLedger, cfg.Workers)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tx := range jobs {
+				sendTransaction(client, cfg, tx, rep)
+			}
+		}()
+	}
+
+	start := time.Now()
+	next := start
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= cfg.Duration {
+			break
+		}
+		current := rate(elapsed)
+		if current <= 0 {
+			time.Sleep(100 * time.Millisecond)
+			next = time.Now()
+			continue
+		}
+		next = next.Add(time.Duration(float64(time.Second) / current))
+		time.Sleep(time.Until(next))
+		select {
+		case jobs <- gen.transaction():
+		default:
+			// every worker is busy, so the target rate cannot be sustained
+			rep.drop()
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func sendTransaction(client *http.Client, cfg config, tx deltaLedger, rep *report) {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		rep.record("ledger", 0, err)
+		return
+	}
+	if err := post(client, "ledger", cfg.LedgerEndpoint, data, rep); err != nil {
+		return
+	}
+
+	data, err = json.Marshal(tx.DeltaSKUs)
+	if err != nil {
+		rep.record("inventory delta", 0, err)
+		return
+	}
+	_ = post(client, "inventory delta", cfg.InventoryEndpoint+"/delta", data, rep)
+}
+
+func post(client *http.Client, name string, url string, data []byte, rep *report) error {
+	start := time.Now()
+	resp, err := client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("received status code: %s", resp.Status)
+		}
+	}
+	rep.record(name, time.Since(start), err)
+	return err
+}
@@ -0,0 +1,107 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// loadgen generates synthetic accounts, products, and transaction streams
+// against the Automated Checkout REST APIs so that edge hardware can be sized
+// before deployment. It prints a latency and error report when it finishes.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.InventoryEndpoint, "inventory", "http://localhost:48095/inventory", "ms-inventory endpoint")
+	flag.StringVar(&cfg.LedgerEndpoint, "ledger", "http://localhost:48093/ledger", "ms-ledger endpoint")
+	flag.StringVar(&cfg.LedgerSeedFile, "ledger-seed-file", "", "if set, write a ledger JSON file with the generated accounts to this path and exit")
+	flag.IntVar(&cfg.Accounts, "accounts", 6, "number of accounts to generate or transact against")
+	flag.IntVar(&cfg.Products, "products", 50, "number of products to generate")
+	flag.IntVar(&cfg.MaxItems, "max-items", 3, "maximum distinct SKUs per transaction")
+	flag.Float64Var(&cfg.Rate, "rate", 5, "target transactions per second at full load")
+	flag.DurationVar(&cfg.Duration, "duration", time.Minute, "total test duration")
+	flag.DurationVar(&cfg.Ramp, "ramp", 10*time.Second, "ramp duration used by the linear and step profiles")
+	flag.StringVar(&cfg.Profile, "profile", profileLinear, "ramp profile: constant, linear, or step")
+	flag.IntVar(&cfg.Workers, "workers", 8, "number of concurrent workers")
+	flag.Int64Var(&cfg.Seed, "seed", time.Now().UnixNano(), "random seed for repeatable runs")
+	flag.BoolVar(&cfg.SkipSeed, "skip-seed", false, "do not POST generated products to inventory before the run")
+	flag.Parse()
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	gen := newGenerator(rand.New(rand.NewSource(cfg.Seed)), cfg.Accounts, cfg.Products, cfg.MaxItems)
+
+	if cfg.LedgerSeedFile != "" {
+		if err := gen.writeLedgerSeed(cfg.LedgerSeedFile); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write ledger seed file: %s\n", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("wrote %d accounts to %s\n", cfg.Accounts, cfg.LedgerSeedFile)
+		os.Exit(0)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	rep := newReport()
+
+	if !cfg.SkipSeed {
+		if err := seedInventory(client, cfg.InventoryEndpoint, gen.products, rep); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to seed inventory: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	profile, err := newProfile(cfg.Profile, cfg.Rate, cfg.Ramp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid profile: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	run(client, cfg, gen, profile, rep)
+	rep.print(os.Stdout, cfg.Duration)
+}
+
+type config struct {
+	InventoryEndpoint string
+	LedgerEndpoint    string
+	LedgerSeedFile    string
+	Accounts          int
+	Products          int
+	MaxItems          int
+	Rate              float64
+	Duration          time.Duration
+	Ramp              time.Duration
+	Profile           string
+	Workers           int
+	Seed              int64
+	SkipSeed          bool
+}
+
+func (cfg config) validate() error {
+	if cfg.Accounts <= 0 {
+		return fmt.Errorf("accounts must be greater than 0")
+	}
+	if cfg.Products <= 0 {
+		return fmt.Errorf("products must be greater than 0")
+	}
+	if cfg.MaxItems <= 0 {
+		return fmt.Errorf("max-items must be greater than 0")
+	}
+	if cfg.Rate <= 0 {
+		return fmt.Errorf("rate must be greater than 0")
+	}
+	if cfg.Workers <= 0 {
+		return fmt.Errorf("workers must be greater than 0")
+	}
+	if cfg.Duration <= 0 {
+		return fmt.Errorf("duration must be greater than 0")
+	}
+	return nil
+}
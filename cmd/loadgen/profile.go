@@ -0,0 +1,46 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	profileConstant = "constant"
+	profileLinear   = "linear"
+	profileStep     = "step"
+
+	// stepCount is the number of equal increments used by the step profile
+	stepCount = 4
+)
+
+// profile returns the target request rate (per second) for the time elapsed
+// since the start of the run
+type profile func(elapsed time.Duration) float64
+
+func newProfile(name string, rate float64, ramp time.Duration) (profile, error) {
+	switch name {
+	case profileConstant:
+		return func(time.Duration) float64 { return rate }, nil
+	case profileLinear:
+		return func(elapsed time.Duration) float64 {
+			if ramp <= 0 || elapsed >= ramp {
+				return rate
+			}
+			return rate * float64(elapsed) / float64(ramp)
+		}, nil
+	case profileStep:
+		return func(elapsed time.Duration) float64 {
+			if ramp <= 0 || elapsed >= ramp {
+				return rate
+			}
+			step := int(elapsed*stepCount/ramp) + 1
+			return rate * float64(step) / stepCount
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+}
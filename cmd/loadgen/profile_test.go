@@ -0,0 +1,44 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProfile(t *testing.T) {
+	tests := []struct {
+		Name         string
+		Profile      string
+		Elapsed      time.Duration
+		ExpectedRate float64
+		Error        bool
+	}{
+		{"Constant at start", profileConstant, 0, 10, false},
+		{"Linear at start", profileLinear, 0, 0, false},
+		{"Linear half way", profileLinear, 5 * time.Second, 5, false},
+		{"Linear after ramp", profileLinear, 20 * time.Second, 10, false},
+		{"Step first step", profileStep, time.Second, 2.5, false},
+		{"Step third step", profileStep, 6 * time.Second, 7.5, false},
+		{"Step after ramp", profileStep, 10 * time.Second, 10, false},
+		{"Unknown profile", "burst", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			rate, err := newProfile(currentTest.Profile, 10, 10*time.Second)
+			if currentTest.Error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedRate, rate(currentTest.Elapsed))
+		})
+	}
+}
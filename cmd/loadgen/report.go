@@ -0,0 +1,107 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxErrorSamples limits how many distinct error messages are kept per endpoint
+const maxErrorSamples = 5
+
+type endpointStats struct {
+	latencies []time.Duration
+	errors    int
+	samples   map[string]int
+}
+
+// report collects latency and error information for each endpoint
+type report struct {
+	mutex     sync.Mutex
+	endpoints map[string]*endpointStats
+	dropped   int
+}
+
+func newReport() *report {
+	return &report{endpoints: map[string]*endpointStats{}}
+}
+
+func (r *report) record(name string, latency time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats, ok := r.endpoints[name]
+	if !ok {
+		stats = &endpointStats{samples: map[string]int{}}
+		r.endpoints[name] = stats
+	}
+	stats.latencies = append(stats.latencies, latency)
+	if err != nil {
+		stats.errors++
+		if _, seen := stats.samples[err.Error()]; seen || len(stats.samples) < maxErrorSamples {
+			stats.samples[err.Error()]++
+		}
+	}
+}
+
+func (r *report) drop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.dropped++
+}
+
+// percentile returns the p-th percentile of the sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(p/100*float64(len(sorted)) + 0.5)
+	if index < 1 {
+		index = 1
+	}
+	if index > len(sorted) {
+		index = len(sorted)
+	}
+	return sorted[index-1]
+}
+
+func (r *report) print(w io.Writer, duration time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	names := make([]string, 0, len(r.endpoints))
+	for name := range r.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%-16s %8s %8s %8s %10s %10s %10s %10s\n", "endpoint", "requests", "errors", "req/s", "p50", "p95", "p99", "max")
+	for _, name := range names {
+		stats := r.endpoints[name]
+		sorted := append([]time.Duration(nil), stats.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		fmt.Fprintf(w, "%-16s %8d %8d %8.2f %10s %10s %10s %10s\n",
+			name,
+			len(sorted),
+			stats.errors,
+			float64(len(sorted))/duration.Seconds(),
+			percentile(sorted, 50).Round(time.Microsecond),
+			percentile(sorted, 95).Round(time.Microsecond),
+			percentile(sorted, 99).Round(time.Microsecond),
+			percentile(sorted, 100).Round(time.Microsecond),
+		)
+	}
+	if r.dropped > 0 {
+		fmt.Fprintf(w, "dropped %d transactions because all workers were busy\n", r.dropped)
+	}
+	for _, name := range names {
+		for msg, count := range r.endpoints[name].samples {
+			fmt.Fprintf(w, "%s error (%d): %s\n", name, count, msg)
+		}
+	}
+}
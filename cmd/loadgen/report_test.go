@@ -0,0 +1,39 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 95*time.Millisecond, percentile(sorted, 95))
+	assert.Equal(t, 100*time.Millisecond, percentile(sorted, 100))
+}
+
+func TestReport(t *testing.T) {
+	rep := newReport()
+	rep.record("ledger", 10*time.Millisecond, nil)
+	rep.record("ledger", 20*time.Millisecond, errors.New("received status code: 400 Bad Request"))
+	rep.drop()
+
+	var out bytes.Buffer
+	rep.print(&out, time.Second)
+
+	assert.Contains(t, out.String(), "ledger")
+	assert.Contains(t, out.String(), "dropped 1 transactions")
+	assert.Contains(t, out.String(), "ledger error (1): received status code: 400 Bad Request")
+}
@@ -10,3 +10,8 @@ const (
 	CommandCardReaderStatus = "status"
 	CommandCardNumber       = "card-number"
 )
+
+// CardReaderStatusOK is the value of the status reading when the device is
+// healthy. Every status auto event carries it, so that consumers can use the
+// readings as a heartbeat of the card reader.
+const CardReaderStatusOK = "ok"
@@ -5,6 +5,7 @@ package driver
 
 import (
 	"fmt"
+	"net/http"
 
 	common "ds-card-reader/common"
 	device "ds-card-reader/device"
@@ -12,7 +13,9 @@ import (
 	"github.com/edgexfoundry/device-sdk-go/v3/pkg/interfaces"
 	dsModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	edgexcommon "github.com/edgexfoundry/go-mod-core-contracts/v3/common"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 // CardReaderDriver represents the EdgeX driver that interfaces with the
@@ -38,6 +41,12 @@ func (drv *CardReaderDriver) Initialize(sdk interfaces.DeviceServiceSDK) (err er
 	drv.LoggingClient = sdk.LoggingClient()
 	drv.asyncCh = sdk.AsyncValuesChannel()
 
+	// the build is logged first, so that every log shows what was running
+	drv.LoggingClient.Info("starting service", CurrentVersion(sdk.Name()).LogFields()...)
+	if err := sdk.AddRoute("/version", utilities.VersionHandler(CurrentVersion(sdk.Name())), http.MethodGet); err != nil {
+		return fmt.Errorf("failed to add the version route: %w", err)
+	}
+
 	// Only setting if nil allows for unit testing with VirtualBoard enabled
 	if drv.Config == nil {
 		drv.Config = &device.ServiceConfig{}
@@ -87,7 +96,14 @@ func (drv *CardReaderDriver) HandleReadCommands(deviceName string, protocols map
 		}
 
 		drv.LoggingClient.Debug(fmt.Sprintf("read command: %v, device ok", common.CommandCardReaderStatus))
-		return result, nil
+
+		// a healthy device reports a reading, which is the heartbeat of the
+		// card reader, while a failed check reports nothing
+		commandValue, err := dsModels.NewCommandValue(common.CommandCardReaderStatus, edgexcommon.ValueTypeString, common.CardReaderStatusOK)
+		if err != nil {
+			return result, fmt.Errorf("read command: %v, failed to create status reading: %v", common.CommandCardReaderStatus, err)
+		}
+		return []*dsModels.CommandValue{commandValue}, nil
 	}
 
 	errMsg := fmt.Sprintf("read command \"%v\" is not handled by this device service", deviceResourceName)
@@ -18,6 +18,7 @@ import (
 	"ds-card-reader/common"
 	"ds-card-reader/device"
 	"fmt"
+	"net/http"
 	"testing"
 
 	sdkMocks "github.com/edgexfoundry/device-sdk-go/v3/pkg/interfaces/mocks"
@@ -111,6 +112,14 @@ func TestHandleReadCommands(t *testing.T) {
 				fmt.Sprintf("read command: %v, verifying lock on device", common.CommandCardReaderStatus),
 				fmt.Sprintf("read command: %v, device ok", common.CommandCardReaderStatus),
 			},
+			ExpectedResult: []*dsModels.CommandValue{
+				{
+					DeviceResourceName: common.CommandCardReaderStatus,
+					Type:               edgexcommon.ValueTypeString,
+					Value:              common.CardReaderStatusOK,
+					Tags:               map[string]string{},
+				},
+			},
 			ExpectedError: nil,
 			driver: &CardReaderDriver{
 				LoggingClient: lc,
@@ -376,6 +385,8 @@ func TestCardReaderDriver_Initialize(t *testing.T) {
 			mockSDK.On("LoggingClient").Return(tt.args.lc)
 			mockSDK.On("AsyncValuesChannel").Return(nil)
 			mockSDK.On("LoadCustomConfig", mock.Anything, mock.Anything).Return(nil)
+			mockSDK.On("Name").Return("ds-card-reader")
+			mockSDK.On("AddRoute", "/version", mock.Anything, http.MethodGet).Return(nil)
 			if err := tt.drv.Initialize(mockSDK); (err != nil) != tt.wantErr {
 				t.Errorf("CardReaderDriver.Initialize() error = %v, wantErr %v", err, tt.wantErr)
 			}
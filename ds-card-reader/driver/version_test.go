@@ -0,0 +1,19 @@
+//go:build all || physical || !physical
+// +build all physical !physical
+
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentVersion(t *testing.T) {
+	info := CurrentVersion("ds-card-reader")
+	assert.Equal(t, "ds-card-reader", info.ServiceName)
+	assert.Empty(t, info.SchemaVersions, "the service stores no data")
+}
@@ -7,14 +7,12 @@ import (
 	"ds-card-reader/driver"
 
 	"github.com/edgexfoundry/device-sdk-go/v3/pkg/startup"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
-const (
-	version     string = "1.0"
-	serviceName string = "ds-card-reader"
-)
+const serviceName string = "ds-card-reader"
 
 func main() {
 	drv := driver.NewCardReaderDriver()
-	startup.Bootstrap(serviceName, version, drv)
+	startup.Bootstrap(serviceName, utilities.Version, drv)
 }
@@ -23,6 +23,11 @@ var Command = struct {
 	Message1    string
 	Message2    string
 	Message3    string
+	// LED is followed by one of LEDColors and a line break. It is not part
+	// of the stock controller board firmware, which ignores the unknown
+	// line, so the firmware must be extended to drive an LED for it to
+	// have any effect.
+	LED string
 }{
 	Lock1:       "L1\n",
 	Lock2:       "L2\n",
@@ -35,6 +40,7 @@ var Command = struct {
 	Message1:    "M1",
 	Message2:    "M2",
 	Message3:    "M3",
+	LED:         "LED",
 }
 
 // StatusEvent is a struct to handle the mapping of status event values to their
@@ -0,0 +1,125 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// blankRow clears a row of the LCD
+const blankRow = "                   "
+
+// LEDColors are the colors the controller board's LED can be set to with
+// the LED serial command
+var LEDColors = []string{"off", "red", "green", "blue", "yellow", "white"}
+
+// CompositeCommand sets the display rows, LED color and locks of the
+// controller board in one command. Its serial commands are written to the
+// board in a single write, so that they land together instead of the
+// display flickering as separate commands arrive. Fields that are not set
+// are left as they are. DisplayReset clears the display before the rows
+// are set, and a lock set to true is unlocked, the same as the lock1 and
+// lock2 commands.
+type CompositeCommand struct {
+	DisplayReset bool    `json:"displayReset,omitempty"`
+	DisplayRow0  *string `json:"displayRow0,omitempty"`
+	DisplayRow1  *string `json:"displayRow1,omitempty"`
+	DisplayRow2  *string `json:"displayRow2,omitempty"`
+	DisplayRow3  *string `json:"displayRow3,omitempty"`
+	LED          string  `json:"led,omitempty"`
+	Lock1        *bool   `json:"lock1,omitempty"`
+	Lock2        *bool   `json:"lock2,omitempty"`
+}
+
+// ParseCompositeCommand parses and validates the JSON of a composite
+// command
+func ParseCompositeCommand(value string) (CompositeCommand, error) {
+	var command CompositeCommand
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&command); err != nil {
+		return command, fmt.Errorf("invalid composite command: %s", err.Error())
+	}
+	if !command.SetsDisplay() && command.LED == "" && command.Lock1 == nil && command.Lock2 == nil {
+		return command, errors.New("composite command does not set anything")
+	}
+	// a line break or other control character in a row would end its
+	// serial command early and run the rest of the row as another command
+	for i, row := range command.displayRows() {
+		if row != nil && strings.IndexFunc(*row, unicode.IsControl) >= 0 {
+			return command, fmt.Errorf("displayRow%d must not contain control characters", i)
+		}
+	}
+	if command.LED != "" && !isLEDColor(command.LED) {
+		return command, fmt.Errorf("unknown LED color %q, expected one of %s", command.LED, strings.Join(LEDColors, ", "))
+	}
+	return command, nil
+}
+
+func isLEDColor(color string) bool {
+	for _, known := range LEDColors {
+		if color == known {
+			return true
+		}
+	}
+	return false
+}
+
+// displayRows returns the display rows of the command, in row order
+func (command CompositeCommand) displayRows() []*string {
+	return []*string{command.DisplayRow0, command.DisplayRow1, command.DisplayRow2, command.DisplayRow3}
+}
+
+// SetsDisplay reports whether the command resets or writes to the display
+func (command CompositeCommand) SetsDisplay() bool {
+	return command.DisplayReset || command.DisplayRow0 != nil || command.DisplayRow1 != nil || command.DisplayRow2 != nil || command.DisplayRow3 != nil
+}
+
+// Serial returns the serial commands of the composite command, in the order
+// the board runs them: the display, then the LED, then the locks, so that
+// the message is shown by the time the door unlocks
+func (command CompositeCommand) Serial() string {
+	var serial strings.Builder
+	if command.DisplayReset {
+		serial.WriteString(DisplayResetCommands())
+	}
+	prefixes := []string{Command.Message0, Command.Message1, Command.Message2, Command.Message3}
+	for i, row := range command.displayRows() {
+		if row != nil {
+			serial.WriteString(prefixes[i] + *row + "\n")
+		}
+	}
+	if command.LED != "" {
+		serial.WriteString(Command.LED + command.LED + "\n")
+	}
+	if command.Lock1 != nil {
+		serial.WriteString(lockCommand(*command.Lock1, Command.UnLock1, Command.Lock1))
+	}
+	if command.Lock2 != nil {
+		serial.WriteString(lockCommand(*command.Lock2, Command.UnLock2, Command.Lock2))
+	}
+	return serial.String()
+}
+
+func lockCommand(unlock bool, unlockCommand string, lockCommand string) string {
+	if unlock {
+		return unlockCommand
+	}
+	return lockCommand
+}
+
+// DisplayResetCommands returns the serial commands that clear the display
+// rows and show the default display
+func DisplayResetCommands() string {
+	return Command.Message0 + blankRow + "\n" +
+		Command.Message1 + blankRow + "\n" +
+		Command.Message2 + blankRow + "\n" +
+		Command.Message3 + blankRow + "\n" +
+		Command.DefaultDisp
+}
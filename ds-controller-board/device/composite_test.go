@@ -0,0 +1,63 @@
+//go:build all || !physical
+// +build all !physical
+
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package device
+
+import (
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompositeCommand(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Value          string
+		ExpectedSerial string
+		ExpectedError  string
+	}{
+		{"Display, LED and lock", `{"displayRow1":"Welcome","displayRow2":"Open the door","led":"green","lock1":true}`, "M1Welcome\nM2Open the door\nLEDgreen\nU1\n", ""},
+		{"Reset and lock", `{"displayReset":true,"displayRow2":"Thank you","lock1":false,"lock2":false}`, DisplayResetCommands() + "M2Thank you\nL1\nL2\n", ""},
+		{"Empty row", `{"displayRow3":""}`, "M3\n", ""},
+		{"LED only", `{"led":"off"}`, "LEDoff\n", ""},
+		{"Nothing set", `{}`, "", "composite command does not set anything"},
+		{"Line break in row", `{"displayRow1":"Welcome\nU1"}`, "", "displayRow1 must not contain control characters"},
+		{"Carriage return in row", `{"displayRow3":"Welcome\r"}`, "", "displayRow3 must not contain control characters"},
+		{"Control character in row", `{"displayRow0":"Wel\u0007come"}`, "", "displayRow0 must not contain control characters"},
+		{"Unknown LED color", `{"led":"purple"}`, "", `unknown LED color "purple", expected one of off, red, green, blue, yellow, white`},
+		{"Unknown field", `{"displayRow4":"Welcome"}`, "", `invalid composite command: json: unknown field "displayRow4"`},
+		{"Invalid JSON", `Welcome`, "", "invalid composite command: invalid character 'W' looking for beginning of value"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			command, err := ParseCompositeCommand(currentTest.Value)
+			if currentTest.ExpectedError != "" {
+				require.EqualError(t, err, currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedSerial, command.Serial())
+		})
+	}
+}
+
+func TestVirtualWriteComposite(t *testing.T) {
+	target := ControllerBoardVirtual{
+		LoggingClient: logger.NewMockClient(),
+		L1:            1,
+		L2:            1,
+	}
+	command, err := ParseCompositeCommand(`{"displayRow1":"Welcome","led":"green","lock1":true}`)
+	require.NoError(t, err)
+
+	require.NoError(t, target.Write(command.Serial()))
+	assert.Equal(t, "green", target.LED)
+	assert.Equal(t, "STATUS,L1,0,L2,1,D,0,T,0.00,H,0", target.getRawStatus())
+}
@@ -6,6 +6,7 @@ package device
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	dsModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
@@ -25,6 +26,7 @@ type ControllerBoardVirtual struct {
 	Temperature   float64
 	Humidity      int64
 	DeviceName    string
+	LED           string
 }
 
 // Read : A continuous loop that reads ControllerBoard Status and forwards it to the EdgeX stack as a Reading.
@@ -68,8 +70,21 @@ func (board *ControllerBoardVirtual) Read() {
 }
 
 // Write : Used to handle Commands being written to the ControllerBoard.
+// Several commands may be written at once, one per line.
 func (board *ControllerBoardVirtual) Write(cmd string) error {
 	board.LoggingClient.Debugf("Write: '%s' command issued.\n", cmd)
+	for _, line := range strings.SplitAfter(cmd, "\n") {
+		board.writeLine(line)
+	}
+	return nil
+}
+
+func (board *ControllerBoardVirtual) writeLine(cmd string) {
+	if strings.HasPrefix(cmd, Command.LED) {
+		board.LED = strings.TrimSuffix(strings.TrimPrefix(cmd, Command.LED), "\n")
+		board.LoggingClient.Infof("Set the LED to %s", board.LED)
+		return
+	}
 	switch cmd {
 	case Command.Lock1:
 		board.L1 = 1
@@ -84,8 +99,6 @@ func (board *ControllerBoardVirtual) Write(cmd string) error {
 		board.L2 = 0
 		board.LoggingClient.Info("Unlocked Lock2")
 	}
-
-	return nil
 }
 
 // GetStatus : Returns the ControllerBoard's JSON 'DevStatus' field as a String.
@@ -6,6 +6,7 @@ package driver
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
 	edgexcommon "github.com/edgexfoundry/go-mod-core-contracts/v3/common"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 const (
@@ -30,6 +32,7 @@ const (
 	setHumidity    = "setHumidity"
 	setTemperature = "setTemperature"
 	setDoorClosed  = "setDoorClosed"
+	composite      = "compositeCommand"
 )
 
 // ControllerBoardDriver follows EdgeX standards for a device struct.
@@ -57,6 +60,12 @@ func (drv *ControllerBoardDriver) Initialize(sdk interfaces.DeviceServiceSDK) (e
 	drv.svc = sdk
 	drv.lc = sdk.LoggingClient()
 
+	// the build is logged first, so that every log shows what was running
+	drv.lc.Info("starting service", CurrentVersion(sdk.Name()).LogFields()...)
+	if err := sdk.AddRoute("/version", utilities.VersionHandler(CurrentVersion(sdk.Name())), http.MethodGet); err != nil {
+		return fmt.Errorf("failed to add the version route: %w", err)
+	}
+
 	// Only setting if nil allows for unit testing with VirtualBoard enabled
 	if drv.config == nil {
 		drv.config = &device.ServiceConfig{}
@@ -185,6 +194,17 @@ func (drv *ControllerBoardDriver) HandleWriteCommands(deviceName string, protoco
 	case displayReset:
 		drv.displayReset()
 
+	case composite:
+		cmdType, err := params[0].StringValue()
+		if err != nil {
+			return err
+		}
+		command, err := device.ParseCompositeCommand(cmdType)
+		if err != nil {
+			return err
+		}
+		return drv.writeComposite(command)
+
 	case setHumidity:
 		cmdType, _ := params[0].StringValue()
 		newHumidity, _ := strconv.ParseInt(cmdType, 10, 64)
@@ -225,9 +245,42 @@ func (drv *ControllerBoardDriver) HandleWriteCommands(deviceName string, protoco
 	return nil
 }
 
+// writeComposite writes the serial commands of a composite command to the
+// board in one write, and then restarts the display timeout and relocks
+// the unlocked locks the same way their own commands do
+func (drv *ControllerBoardDriver) writeComposite(command device.CompositeCommand) error {
+	serial := command.Serial()
+	drv.lc.Info(serial)
+	if err := drv.controllerBoard.Write(serial); err != nil {
+		return err
+	}
+	if command.SetsDisplay() {
+		drv.restartDisplayTimeout()
+	}
+	if command.Lock1 != nil && *command.Lock1 {
+		go func() {
+			time.Sleep(drv.displayTimeout)
+			_ = drv.controllerBoard.Write(device.Command.Lock1)
+		}()
+	}
+	if command.Lock2 != nil && *command.Lock2 {
+		go func() {
+			time.Sleep(drv.lockTimeout)
+			_ = drv.controllerBoard.Write(device.Command.Lock2)
+		}()
+	}
+	return nil
+}
+
 func (drv *ControllerBoardDriver) displayText(message string) {
 	drv.lc.Info(message)
 	_ = drv.controllerBoard.Write(message)
+	drv.restartDisplayTimeout()
+}
+
+// restartDisplayTimeout resets the display once the display timeout passes
+// without anything else being displayed
+func (drv *ControllerBoardDriver) restartDisplayTimeout() {
 	// Stop the display reset thread and restart the timeout
 	close(drv.StopChannel)
 	drv.StopChannel = make(chan int)
@@ -247,11 +300,7 @@ func (drv *ControllerBoardDriver) displayText(message string) {
 }
 
 func (drv *ControllerBoardDriver) displayReset() {
-	_ = drv.controllerBoard.Write(device.Command.Message0 + "                   " + "\n")
-	_ = drv.controllerBoard.Write(device.Command.Message1 + "                   " + "\n")
-	_ = drv.controllerBoard.Write(device.Command.Message2 + "                   " + "\n")
-	_ = drv.controllerBoard.Write(device.Command.Message3 + "                   " + "\n")
-	_ = drv.controllerBoard.Write(device.Command.DefaultDisp)
+	_ = drv.controllerBoard.Write(device.DisplayResetCommands())
 }
 
 // AddDevice responds to when a device is added.
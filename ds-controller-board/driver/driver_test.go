@@ -10,6 +10,7 @@ import (
 	"ds-controller-board/device"
 	"ds-controller-board/device/mocks"
 	"fmt"
+	"net/http"
 	"os"
 	"reflect"
 	"testing"
@@ -73,6 +74,8 @@ func TestHandleWriteCommands(t *testing.T) {
 		{Name: "HandleWriteCommands - displayRow2", Resource: displayRow2, CommandValue: "Row 2", ExpectedError: nil, controllerBoard: emptyControllerBoard},
 		{Name: "HandleWriteCommands - displayRow3", Resource: displayRow3, CommandValue: "Row 3", ExpectedError: nil, controllerBoard: emptyControllerBoard},
 		{Name: "HandleWriteCommands - displayReset", Resource: displayReset, CommandValue: nil, ExpectedError: nil, controllerBoard: emptyControllerBoard},
+		{Name: "HandleWriteCommands - compositeCommand", Resource: composite, CommandValue: `{"displayRow1":"Welcome","led":"green","lock2":false}`, ExpectedError: nil, controllerBoard: emptyControllerBoard},
+		{Name: "HandleWriteCommands -error compositeCommand", Resource: composite, CommandValue: `{"led":"purple"}`, ExpectedError: fmt.Errorf(`unknown LED color "purple", expected one of off, red, green, blue, yellow, white`), controllerBoard: emptyControllerBoard},
 		{Name: "HandleWriteCommands - setHumidity", Resource: setHumidity, CommandValue: "86", ExpectedError: nil, controllerBoard: emptyControllerBoard},
 		{Name: "HandleWriteCommands - setTemperature", Resource: setTemperature, CommandValue: "102", ExpectedError: nil, controllerBoard: emptyControllerBoard},
 		{Name: "HandleWriteCommands - setDoorClosed", Resource: setDoorClosed, CommandValue: "Yes", ExpectedError: nil, controllerBoard: emptyControllerBoard},
@@ -155,6 +158,8 @@ func CreateControllerBoardDriver(t *testing.T, virtual bool, initialize bool, ex
 		mockSDK.On("LoggingClient").Return(lc)
 		mockSDK.On("AsyncValuesChannel").Return(nil)
 		mockSDK.On("LoadCustomConfig", mock.Anything, mock.Anything).Return(nil)
+		mockSDK.On("Name").Return("ds-controller-board")
+		mockSDK.On("AddRoute", "/version", mock.Anything, http.MethodGet).Return(nil)
 		err = target.Initialize(mockSDK)
 		require.NoError(err)
 
@@ -215,6 +220,8 @@ func TestControllerBoardDriver_Initialize(t *testing.T) {
 			mockSDK.On("LoggingClient").Return(mocklc)
 			mockSDK.On("AsyncValuesChannel").Return(nil)
 			mockSDK.On("LoadCustomConfig", mock.Anything, mock.Anything).Return(nil)
+			mockSDK.On("Name").Return("ds-controller-board")
+			mockSDK.On("AddRoute", "/version", mock.Anything, http.MethodGet).Return(nil)
 			err := drv.Initialize(mockSDK)
 			if tt.wantErr {
 				require.Error(t, err)
@@ -0,0 +1,15 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package driver
+
+import "github.com/intel-iot-devkit/automated-checkout-utilities"
+
+// schemaVersions are the versions of the layouts of the data this service
+// stores. The controller board stores no data.
+var schemaVersions = map[string]int{}
+
+// CurrentVersion returns the build of the running service of serviceName
+func CurrentVersion(serviceName string) utilities.VersionInfo {
+	return utilities.CurrentVersion(serviceName, utilities.DeviceSDKModulePath, schemaVersions)
+}
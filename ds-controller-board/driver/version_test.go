@@ -0,0 +1,19 @@
+//go:build all || !physical
+// +build all !physical
+
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentVersion(t *testing.T) {
+	info := CurrentVersion("ds-controller-board")
+	assert.Equal(t, "ds-controller-board", info.ServiceName)
+	assert.Empty(t, info.SchemaVersions, "the service stores no data")
+}
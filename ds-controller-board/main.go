@@ -7,14 +7,12 @@ import (
 	"ds-controller-board/driver"
 
 	"github.com/edgexfoundry/device-sdk-go/v3/pkg/startup"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
-const (
-	version     string = "1.0"
-	serviceName string = "ds-controller-board"
-)
+const serviceName string = "ds-controller-board"
 
 func main() {
 	d := driver.NewControllerBoardDeviceDriver()
-	startup.Bootstrap(serviceName, version, d)
+	startup.Bootstrap(serviceName, utilities.Version, d)
 }
@@ -7,13 +7,19 @@ go 1.21
 
 require (
 	github.com/eclipse/paho.mqtt.golang v1.2.0
-	github.com/stretchr/testify v1.8.0
+	github.com/intel-iot-devkit/automated-checkout-utilities v0.0.0
+	github.com/stretchr/testify v1.9.0
 	gocv.io/x/gocv v0.27.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/net v0.23.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// utilities is the module of this repository shared by the services
+replace github.com/intel-iot-devkit/automated-checkout-utilities => ../utilities
@@ -10,15 +10,20 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 	"gocv.io/x/gocv"
 )
 
 func main() {
 
+	// the build is logged first, so that every log shows what was running
+	log.Println("starting service " + currentVersion().String())
+
 	// Flags
 	directory := flag.String("dir", "./images", "Images directory.")
 	mqttAddress := flag.String("mqtt", "localhost:1883", "Mqtt address.")
@@ -47,10 +52,31 @@ func main() {
 
 	go updateMjpegServer()
 
+	http.HandleFunc("/version", utilities.VersionHandler(currentVersion()))
+	http.HandleFunc("/qrcode", qrCodeHandler(&mqttConnection))
 	http.Handle("/", inference.Stream)
 	log.Fatal(http.ListenAndServe(":9005", nil))
 }
 
+// qrCodeHandler publishes the payload of a QR code decoded from the kiosk
+// camera, which is posted as the request body. The camera frames are read
+// from the images directory, so a scan is simulated by posting its payload.
+func qrCodeHandler(mqttConnection *mqtt.Connection) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		payload, err := io.ReadAll(io.LimitReader(req.Body, 4096))
+		if err != nil || len(payload) == 0 {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mqttConnection.SendQRCode(string(payload))
+		writer.WriteHeader(http.StatusOK)
+	}
+}
+
 func updateMjpegServer() {
 	for img := range inference.StreamChannel {
 		buf, err := gocv.IMEncode(".jpg", img)
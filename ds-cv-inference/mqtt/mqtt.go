@@ -174,6 +174,22 @@ func SendDeltaData(client MQTT.Client, delta []byte) {
 	fmt.Println("published deltaMessage ", string(deltaMessage), " to topic ", publishTopic)
 }
 
+// SendQRCode publishes the payload of a QR code decoded by the kiosk camera
+// to mqtt broker, for the vending service to authenticate as a card
+func (mqttCon *Connection) SendQRCode(payload string) {
+
+	cmdQRCode := "inferenceQRCode"
+	publishTopic := fmt.Sprintf("%s/%s/%s", dataTopic, "Inference-device", cmdQRCode)
+	edgeXMessage := make(map[string]string)
+	edgeXMessage[cmdQRCode] = payload
+
+	qrCodeMessage, _ := json.Marshal(edgeXMessage)
+	token := mqttCon.MqttClient.Publish(publishTopic, 0, false, qrCodeMessage)
+	token.Wait()
+	// the payload is a credential, so it is not printed
+	fmt.Println("published a QR code to topic ", publishTopic)
+}
+
 func (mqttCon *Connection) Connect(connectionString string) {
 	//create a ClientOptions struct setting the broker address, clientid, turn
 	//off trace output and set the default message handler
@@ -0,0 +1,19 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import "github.com/intel-iot-devkit/automated-checkout-utilities"
+
+const (
+	versionServiceName = "ds-cv-inference"
+	// the inference is not an EdgeX service, so the SDK it is built on is
+	// the computer vision library
+	sdkModulePath = "gocv.io/x/gocv"
+)
+
+// currentVersion returns the build of the running service. The inference
+// stores no data, so it has no schema versions.
+func currentVersion() utilities.VersionInfo {
+	return utilities.CurrentVersion(versionServiceName, sdkModulePath, nil)
+}
@@ -0,0 +1,178 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	inventoryEndpoint       = "http://localhost:48095/inventory"
+	auditLogEndpoint        = "http://localhost:48095/auditlog"
+	ledgerEndpoint          = "http://localhost:48093/ledger"
+	maintenanceModeEndpoint = "http://localhost:48099/maintenanceMode"
+	boardStatusEndpoint     = "http://localhost:48094/status"
+	cardReaderEndpoint      = "http://localhost:48098/api/v3/device/name/card-reader/card-number"
+	doorClosedEndpoint      = "http://localhost:48097/api/v3/device/name/controller-board/setDoorClosed"
+)
+
+// The types below are the parts of the service payloads the scenarios
+// check. They are declared here rather than imported so that a change to a
+// service's payload breaks the scenarios instead of silently following it.
+
+type product struct {
+	SKU         string  `json:"sku"`
+	ProductName string  `json:"productName"`
+	ItemPrice   float64 `json:"itemPrice"`
+	UnitsOnHand int     `json:"unitsOnHand"`
+}
+
+type products struct {
+	Data []product `json:"data"`
+}
+
+type deltaSKU struct {
+	SKU   string `json:"sku"`
+	Delta int    `json:"delta"`
+}
+
+type auditLogEntry struct {
+	CardID         string     `json:"cardId"`
+	AccountID      int        `json:"accountId"`
+	RoleID         int        `json:"roleId"`
+	PersonID       int        `json:"personId"`
+	InventoryDelta []deltaSKU `json:"inventoryDelta"`
+	CreatedAt      int64      `json:"createdAt,string"`
+	AuditEntryID   string     `json:"auditEntryId"`
+}
+
+type auditLog struct {
+	Data []auditLogEntry `json:"data"`
+}
+
+type lineItem struct {
+	SKU         string  `json:"sku"`
+	ProductName string  `json:"productName"`
+	ItemPrice   float64 `json:"itemPrice"`
+	ItemCount   int     `json:"itemCount"`
+}
+
+type ledger struct {
+	TransactionID int64      `json:"transactionID,string"`
+	LineTotal     float64    `json:"lineTotal"`
+	IsPaid        bool       `json:"isPaid"`
+	LineItems     []lineItem `json:"lineItems"`
+}
+
+type account struct {
+	AccountID int      `json:"accountID"`
+	Ledgers   []ledger `json:"ledgers"`
+}
+
+type boardStatus struct {
+	Lock1      int  `json:"lock1_status"`
+	Lock2      int  `json:"lock2_status"`
+	DoorClosed bool `json:"door_closed"`
+}
+
+type maintenanceMode struct {
+	MaintenanceMode bool `json:"maintenanceMode"`
+}
+
+// client calls the REST APIs of the services and simulated devices
+type client struct {
+	http *http.Client
+}
+
+func newClient() *client {
+	return &client{http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *client) Inventory() (products, error) {
+	var inventory products
+	return inventory, c.get(inventoryEndpoint, &inventory)
+}
+
+func (c *client) AuditLog() (auditLog, error) {
+	var log auditLog
+	return log, c.get(auditLogEndpoint, &log)
+}
+
+func (c *client) Account(accountID int) (account, error) {
+	var acct account
+	return acct, c.get(ledgerEndpoint+"/"+strconv.Itoa(accountID), &acct)
+}
+
+func (c *client) BoardStatus() (boardStatus, error) {
+	var status boardStatus
+	return status, c.get(boardStatusEndpoint, &status)
+}
+
+func (c *client) MaintenanceMode() (maintenanceMode, error) {
+	var mode maintenanceMode
+	return mode, c.get(maintenanceModeEndpoint, &mode)
+}
+
+// ReadCard makes the simulated card reader read the card
+func (c *client) ReadCard(cardID string) error {
+	return c.put(cardReaderEndpoint, map[string]string{"card-number": cardID})
+}
+
+// SetDoorClosed opens or closes the door of the simulated controller board
+func (c *client) SetDoorClosed(closed bool) error {
+	value := "0"
+	if closed {
+		value = "1"
+	}
+	return c.put(doorClosedEndpoint, map[string]string{"setDoorClosed": value})
+}
+
+func (c *client) get(url string, result interface{}) error {
+	resp, err := c.http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d: %s", url, resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("GET %s returned an unexpected payload: %s: %s", url, err.Error(), body)
+	}
+	return nil
+}
+
+func (c *client) put(url string, content interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("PUT %s returned %d: %s", url, resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package integration runs end-to-end vend scenarios against the Automated
+// Checkout services and simulated devices started with docker compose. The
+// tests need Docker and the service images, so they are only built with the
+// integration build tag:
+//
+//	go test -tags integration -v -timeout 30m ./...
+package integration
@@ -0,0 +1,156 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ProfileCheckout starts the Automated Checkout services
+	ProfileCheckout = "checkout"
+	// ProfileDevices starts the simulated card reader, controller board and
+	// CV inference
+	ProfileDevices = "devices"
+)
+
+// composeFiles are the compose files of the stack, relative to the root of
+// the repository
+var composeFiles = []string{
+	"docker-compose.av.yml",
+	"docker-compose.edgex.yml",
+	"docker-compose.integration.yml",
+}
+
+// pingEndpoints are checked until every service of the stack answers
+var pingEndpoints = map[string]string{
+	"ms-authentication":          "http://localhost:48096/api/v3/ping",
+	"ms-inventory":               "http://localhost:48095/api/v3/ping",
+	"ms-ledger":                  "http://localhost:48093/api/v3/ping",
+	"as-vending":                 "http://localhost:48099/api/v3/ping",
+	"as-controller-board-status": "http://localhost:48094/api/v3/ping",
+	"ds-card-reader":             "http://localhost:48098/api/v3/ping",
+	"ds-controller-board":        "http://localhost:48097/api/v3/ping",
+	"core-data":                  "http://localhost:59880/api/v3/ping",
+	"core-command":               "http://localhost:59882/api/v3/ping",
+}
+
+// Stack is a docker compose project of the services with the given profiles
+type Stack struct {
+	dir      string
+	project  string
+	profiles []string
+}
+
+// NewStack creates the stack of the compose files in the repository
+// directory. Nothing is started until Up is called.
+func NewStack(dir string, project string, profiles ...string) *Stack {
+	return &Stack{dir: dir, project: project, profiles: profiles}
+}
+
+// Up starts the services of the stack
+func (stack *Stack) Up() error {
+	return stack.compose("up", "-d")
+}
+
+// Down stops the services and removes their volumes, so that the next run
+// starts from the inventory and ledgers shipped in the images
+func (stack *Stack) Down() error {
+	return stack.compose("down", "-v", "-t", "1")
+}
+
+// Stop stops a service, to simulate an outage
+func (stack *Stack) Stop(service string) error {
+	return stack.compose("stop", "-t", "1", service)
+}
+
+// Start starts a stopped service
+func (stack *Stack) Start(service string) error {
+	return stack.compose("start", service)
+}
+
+// Restart restarts a service, which resets any state it keeps in memory
+func (stack *Stack) Restart(service string) error {
+	return stack.compose("restart", "-t", "1", service)
+}
+
+// Logs returns the logs of the services, to explain a failed scenario
+func (stack *Stack) Logs(services ...string) string {
+	output, err := stack.output(append([]string{"logs", "--no-color", "--tail", "200"}, services...)...)
+	if err != nil {
+		return fmt.Sprintf("failed to get logs: %s", err.Error())
+	}
+	return output
+}
+
+// WaitReady waits until every service answers its ping endpoint
+func (stack *Stack) WaitReady(timeout time.Duration) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	for service, endpoint := range pingEndpoints {
+		err := waitFor(timeout, time.Second, func() (bool, error) {
+			resp, err := client.Get(endpoint)
+			if err != nil {
+				return false, nil
+			}
+			resp.Body.Close()
+			return resp.StatusCode == http.StatusOK, nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s is not ready: %s", service, err.Error())
+		}
+	}
+	return nil
+}
+
+func (stack *Stack) compose(args ...string) error {
+	_, err := stack.output(args...)
+	return err
+}
+
+func (stack *Stack) output(args ...string) (string, error) {
+	composeArgs := []string{"compose", "-p", stack.project}
+	for _, file := range composeFiles {
+		composeArgs = append(composeArgs, "-f", filepath.Join(stack.dir, file))
+	}
+	for _, profile := range stack.profiles {
+		composeArgs = append(composeArgs, "--profile", profile)
+	}
+	composeArgs = append(composeArgs, args...)
+
+	var output bytes.Buffer
+	cmd := exec.Command("docker", composeArgs...)
+	cmd.Dir = stack.dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return output.String(), fmt.Errorf("docker compose %v failed: %s: %s", args, err.Error(), output.String())
+	}
+	return output.String(), nil
+}
+
+// waitFor polls the condition every interval until it is met, it fails, or
+// the timeout passes
+func waitFor(timeout time.Duration, interval time.Duration, condition func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build integration
+
+package integration
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// customerCard is enrolled with the customer role, unknownCard is not
+	// enrolled at all
+	customerCard = "0003278380"
+	unknownCard  = "0000000001"
+
+	startupTimeout = 5 * time.Minute
+	// unlockTimeout and vendTimeout are above the as-vending SLAs and door
+	// timeouts, so that a slow run is not reported as a failure
+	unlockTimeout = 15 * time.Second
+	vendTimeout   = time.Minute
+	pollInterval  = 500 * time.Millisecond
+)
+
+var (
+	keepStack     = flag.Bool("integration.keep", false, "leave the stack running after the tests")
+	externalStack = flag.Bool("integration.external", false, "use a stack that is already running instead of starting one")
+
+	stack *Stack
+	api   = newClient()
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	dir, err := filepath.Abs("..")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to find the repository: %s\n", err.Error())
+		os.Exit(1)
+	}
+	stack = NewStack(dir, "automated-checkout", ProfileCheckout, ProfileDevices)
+
+	if !*externalStack {
+		if err := stack.Up(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
+	code := 1
+	if err := stack.WaitReady(startupTimeout); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		fmt.Fprintln(os.Stderr, stack.Logs())
+	} else {
+		code = m.Run()
+	}
+	if !*externalStack && !*keepStack {
+		if err := stack.Down(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}
+	os.Exit(code)
+}
+
+// snapshot is the state of the services a vend changes
+type snapshot struct {
+	inventory products
+	auditLog  auditLog
+}
+
+func takeSnapshot(t *testing.T) snapshot {
+	inventory, err := api.Inventory()
+	require.NoError(t, err)
+	log, err := api.AuditLog()
+	require.NoError(t, err)
+	return snapshot{inventory: inventory, auditLog: log}
+}
+
+func (s snapshot) product(sku string) (product, bool) {
+	for _, item := range s.inventory.Data {
+		if item.SKU == sku {
+			return item, true
+		}
+	}
+	return product{}, false
+}
+
+// waitIdle waits until the door is closed and locked, which is how every
+// scenario starts and ends
+func waitIdle(t *testing.T) {
+	err := waitFor(vendTimeout, pollInterval, func() (bool, error) {
+		status, err := api.BoardStatus()
+		if err != nil {
+			return false, nil
+		}
+		return status.DoorClosed && status.Lock1 == 1, nil
+	})
+	require.NoError(t, err, "the machine is not idle")
+}
+
+// waitUnlocked waits for the door lock to open, and reports whether it did
+func waitUnlocked(timeout time.Duration) bool {
+	return waitFor(timeout, pollInterval, func() (bool, error) {
+		status, err := api.BoardStatus()
+		if err != nil {
+			return false, nil
+		}
+		return status.Lock1 == 0, nil
+	}) == nil
+}
+
+// openAndCloseDoor takes items out of the simulated machine, which makes the
+// simulated CV inference report them
+func openAndCloseDoor(t *testing.T) {
+	require.NoError(t, api.SetDoorClosed(false))
+	require.NoError(t, waitFor(unlockTimeout, pollInterval, func() (bool, error) {
+		status, err := api.BoardStatus()
+		return err == nil && !status.DoorClosed, nil
+	}), "the door did not open")
+	time.Sleep(2 * time.Second)
+	require.NoError(t, api.SetDoorClosed(true))
+}
+
+// dumpLogsOnFailure adds the logs of the services to a failed scenario
+func dumpLogsOnFailure(t *testing.T, services ...string) {
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Log(stack.Logs(services...))
+		}
+	})
+}
+
+// resetVending restarts as-vending once the scenario is done, so that a
+// workflow left unfinished by a failure does not affect the next scenario
+func resetVending(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, stack.Restart("as-vending"))
+		require.NoError(t, stack.WaitReady(startupTimeout))
+	})
+}
+
+// TestGoldenPathVend is a customer taking items: the ledger charges the
+// account for them, inventory is decremented, and the vend is audited, all
+// with the same SKUs and counts
+func TestGoldenPathVend(t *testing.T) {
+	dumpLogsOnFailure(t, "as-vending", "ms-ledger", "ms-inventory", "ds-cv-inference")
+	waitIdle(t)
+	before := takeSnapshot(t)
+
+	require.NoError(t, api.ReadCard(customerCard))
+	require.True(t, waitUnlocked(unlockTimeout), "the door was not unlocked for an enrolled customer")
+	openAndCloseDoor(t)
+
+	var entry auditLogEntry
+	require.NoError(t, waitFor(vendTimeout, pollInterval, func() (bool, error) {
+		log, err := api.AuditLog()
+		if err != nil || len(log.Data) <= len(before.auditLog.Data) {
+			return false, nil
+		}
+		entry = log.Data[len(log.Data)-1]
+		return true, nil
+	}), "the vend was not audited")
+	waitIdle(t)
+	after := takeSnapshot(t)
+
+	assert.Equal(t, customerCard, entry.CardID)
+	assert.NotZero(t, entry.AccountID)
+	assert.NotZero(t, entry.CreatedAt)
+	require.NotEmpty(t, entry.InventoryDelta, "the simulated inference did not report any items")
+
+	taken := map[string]int{}
+	for _, delta := range entry.InventoryDelta {
+		beforeProduct, ok := before.product(delta.SKU)
+		require.True(t, ok, "inference reported %s, which is not in inventory", delta.SKU)
+		afterProduct, _ := after.product(delta.SKU)
+		assert.Equal(t, beforeProduct.UnitsOnHand+delta.Delta, afterProduct.UnitsOnHand, "inventory of %s", delta.SKU)
+		if delta.Delta < 0 {
+			taken[delta.SKU] = -delta.Delta
+		}
+	}
+
+	acct, err := api.Account(entry.AccountID)
+	require.NoError(t, err)
+	require.NotEmpty(t, acct.Ledgers, "the account was not charged")
+	transaction := acct.Ledgers[len(acct.Ledgers)-1]
+	assert.NotZero(t, transaction.TransactionID)
+	assert.False(t, transaction.IsPaid)
+	charged := map[string]int{}
+	for _, item := range transaction.LineItems {
+		charged[item.SKU] += item.ItemCount
+		inventoryProduct, ok := before.product(item.SKU)
+		require.True(t, ok, "the ledger charged %s, which is not in inventory", item.SKU)
+		assert.Equal(t, inventoryProduct.ProductName, item.ProductName, "product name of %s", item.SKU)
+		assert.Equal(t, inventoryProduct.ItemPrice, item.ItemPrice, "price of %s", item.SKU)
+	}
+	assert.Equal(t, taken, charged, "the ledger charged different items than were taken")
+	assert.Positive(t, transaction.LineTotal)
+}
+
+// TestUnknownCardStaysLocked is a card that is not enrolled, which must not
+// unlock the door or change anything
+func TestUnknownCardStaysLocked(t *testing.T) {
+	dumpLogsOnFailure(t, "as-vending", "ms-authentication")
+	waitIdle(t)
+	before := takeSnapshot(t)
+
+	require.NoError(t, api.ReadCard(unknownCard))
+	assert.False(t, waitUnlocked(unlockTimeout), "the door was unlocked for an unknown card")
+
+	after := takeSnapshot(t)
+	assert.Equal(t, before, after)
+}
+
+// TestInferenceOutage is the CV inference going down, which puts the machine
+// in maintenance mode so that customers cannot take items that would not be
+// charged
+func TestInferenceOutage(t *testing.T) {
+	dumpLogsOnFailure(t, "as-vending", "ds-cv-inference")
+	resetVending(t)
+	waitIdle(t)
+	require.NoError(t, stack.Stop("ds-cv-inference"))
+	t.Cleanup(func() {
+		require.NoError(t, stack.Start("ds-cv-inference"))
+	})
+
+	require.NoError(t, api.ReadCard(customerCard))
+	assert.False(t, waitUnlocked(unlockTimeout), "the door was unlocked without inference")
+	mode, err := api.MaintenanceMode()
+	require.NoError(t, err)
+	assert.True(t, mode.MaintenanceMode)
+}
+
+// TestLedgerOutage is the ledger going down during a vend, which must not
+// decrement inventory for items the customer was not charged for
+func TestLedgerOutage(t *testing.T) {
+	dumpLogsOnFailure(t, "as-vending", "ms-ledger")
+	resetVending(t)
+	waitIdle(t)
+	before := takeSnapshot(t)
+
+	require.NoError(t, api.ReadCard(customerCard))
+	require.True(t, waitUnlocked(unlockTimeout), "the door was not unlocked for an enrolled customer")
+	require.NoError(t, stack.Stop("ms-ledger"))
+	t.Cleanup(func() {
+		require.NoError(t, stack.Start("ms-ledger"))
+		require.NoError(t, stack.WaitReady(startupTimeout))
+	})
+	openAndCloseDoor(t)
+
+	// give the vend time to fail rather than time to succeed
+	time.Sleep(vendTimeout / 2)
+	after := takeSnapshot(t)
+	assert.Equal(t, before.inventory, after.inventory, "inventory changed without a charge")
+	assert.Equal(t, len(before.auditLog.Data), len(after.auditLog.Data), "a vend without a charge was audited")
+}
@@ -6,8 +6,10 @@ package main
 import (
 	"ms-authentication/routes"
 	"os"
+	"strconv"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 const (
@@ -21,9 +23,164 @@ func main() {
 		os.Exit(1)
 	}
 	lc := service.LoggingClient()
+	// the build is logged first, so that every log shows what was running
+	lc.Info("starting service", routes.CurrentVersion().LogFields()...)
 
-	controller := routes.NewController(service)
-	err := controller.AddAllRoutes()
+	// CardIDFormats is optional, without it card IDs must be read exactly
+	// as they are enrolled
+	var cardIDFormats routes.CardIDFormats
+	formats, err := service.GetAppSettingStrings("CardIDFormats")
+	if err != nil {
+		lc.Info("CardIDFormats is not set in ApplicationSettings, card IDs will not be normalized")
+	} else {
+		cardIDFormats, err = routes.ParseCardIDFormats(formats)
+		if err != nil {
+			lc.Errorf("CardIDFormats from ApplicationSettings is not valid: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// AuthStore is optional, by default the cards, accounts and people are
+	// kept in their files. AuthStoreURL is the Redis URL of the redis store.
+	storeType, err := service.GetAppSetting("AuthStore")
+	if err != nil || len(storeType) == 0 {
+		storeType = routes.AuthStoreFile
+	}
+	storeURL, err := service.GetAppSetting("AuthStoreURL")
+	if err != nil {
+		storeURL = ""
+	}
+	// DataDirectory is optional, it holds the cards, accounts and people
+	// files. Without it they are in the working directory.
+	routes.DataDirectory, err = service.GetAppSetting("DataDirectory")
+	if err != nil {
+		routes.DataDirectory = ""
+	}
+	if storeType == routes.AuthStoreFile {
+		if err := utilities.ValidateDataDirectory(routes.DataDirectory); err != nil {
+			// the sample data may be deployed read-only, which still
+			// authenticates the sample cards
+			lc.Warnf("cards, accounts and people cannot be changed: %s", err.Error())
+		}
+	}
+	store, err := routes.NewAuthStore(storeType, storeURL)
+	if err != nil {
+		lc.Errorf("AuthStore from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	if err := routes.MigrateAuthData(store); err != nil {
+		lc.Errorf("failed to migrate the authentication data into the %s store: %s", storeType, err.Error())
+		os.Exit(1)
+	}
+
+	// AuthTokenSecret is optional, with it every authentication response has
+	// a signed token for the administrative routes of the other services
+	tokenSecret, err := service.GetAppSetting("AuthTokenSecret")
+	if err != nil {
+		tokenSecret = ""
+	}
+	tokenTTLSetting, err := service.GetAppSetting("AuthTokenTTL")
+	if err != nil {
+		tokenTTLSetting = ""
+	}
+	tokenTTL, err := routes.ParseTokenTTL(tokenTTLSetting)
+	if err != nil {
+		lc.Errorf("AuthTokenTTL from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	tokenSigner := routes.NewTokenSigner(tokenSecret, tokenTTL)
+	if tokenSigner == nil {
+		lc.Info("AuthTokenSecret is not set in ApplicationSettings, authentication responses will have no token")
+	}
+
+	// MaxFailedSwipes and AntiPassbackInterval are optional, without them
+	// cards are never locked and may be swiped again at once
+	maxFailedSwipes := 0
+	maxFailedSwipesSetting, err := service.GetAppSetting("MaxFailedSwipes")
+	if err == nil && maxFailedSwipesSetting != "" {
+		maxFailedSwipes, err = strconv.Atoi(maxFailedSwipesSetting)
+		if err != nil || maxFailedSwipes < 0 {
+			lc.Errorf("MaxFailedSwipes from ApplicationSettings is not valid: %q must be a count that is not negative", maxFailedSwipesSetting)
+			os.Exit(1)
+		}
+	}
+	failedSwipeWindowSetting, err := service.GetAppSetting("FailedSwipeWindow")
+	if err != nil {
+		failedSwipeWindowSetting = ""
+	}
+	failedSwipeWindow, err := routes.ParseSwipeDuration("FailedSwipeWindow", failedSwipeWindowSetting, 0)
+	if err != nil {
+		lc.Errorf("FailedSwipeWindow from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	passbackSetting, err := service.GetAppSetting("AntiPassbackInterval")
+	if err != nil {
+		passbackSetting = ""
+	}
+	passback, err := routes.ParseSwipeDuration("AntiPassbackInterval", passbackSetting, 0)
+	if err != nil {
+		lc.Errorf("AntiPassbackInterval from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	swipeGuard := routes.NewSwipeGuard(maxFailedSwipes, failedSwipeWindow, passback)
+
+	// IdentityProvider is optional, with it cards are looked up in an
+	// external identity provider before the store
+	appSetting := func(name string) string {
+		value, err := service.GetAppSetting(name)
+		if err != nil {
+			return ""
+		}
+		return value
+	}
+	identityProvider, err := routes.NewIdentityProviderFromConfig(routes.IdentityProviderConfig{
+		Type:       appSetting("IdentityProvider"),
+		URL:        appSetting("IdentityProviderURL"),
+		Timeout:    appSetting("IdentityProviderTimeout"),
+		CacheTTL:   appSetting("IdentityCacheTTL"),
+		OfflineTTL: appSetting("IdentityOfflineTTL"),
+		APIKey:     appSetting("IdentityProviderAPIKey"),
+		LDAP: routes.LDAPIdentityConnector{
+			BindDN:               appSetting("IdentityLDAPBindDN"),
+			BindPassword:         appSetting("IdentityLDAPBindPassword"),
+			BaseDN:               appSetting("IdentityLDAPBaseDN"),
+			CardAttribute:        appSetting("IdentityLDAPCardAttribute"),
+			RoleAttribute:        appSetting("IdentityLDAPRoleAttribute"),
+			PersonAttribute:      appSetting("IdentityLDAPPersonAttribute"),
+			AccountAttribute:     appSetting("IdentityLDAPAccountAttribute"),
+			CreditLimitAttribute: appSetting("IdentityLDAPCreditLimitAttribute"),
+		},
+	})
+	if err != nil {
+		lc.Errorf("IdentityProvider from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	if identityProvider != nil {
+		lc.Infof("cards are looked up in the %s identity provider before the %s store", appSetting("IdentityProvider"), storeType)
+	}
+
+	// QRCodeSecret is optional, with it card holders can open the door with a
+	// QR code issued to their phone instead of their card
+	qrCodeSecret, err := service.GetAppSetting("QRCodeSecret")
+	if err != nil {
+		qrCodeSecret = ""
+	}
+	qrCodeTTLSetting, err := service.GetAppSetting("QRCodeTTL")
+	if err != nil {
+		qrCodeTTLSetting = ""
+	}
+	qrCodeTTL, err := routes.ParseQRCodeTTL(qrCodeTTLSetting)
+	if err != nil {
+		lc.Errorf("QRCodeTTL from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	qrCodeSigner := routes.NewQRCodeSigner(qrCodeSecret, qrCodeTTL)
+	if qrCodeSigner == nil {
+		lc.Info("QRCodeSecret is not set in ApplicationSettings, QR codes will not be issued or accepted")
+	}
+
+	controller := routes.NewController(service, cardIDFormats, store, tokenSigner, swipeGuard, identityProvider, qrCodeSigner)
+	err = controller.AddAllRoutes()
 	if err != nil {
 		lc.Errorf("failed to add all Routes: %s", err.Error())
 		os.Exit(1)
@@ -32,6 +189,10 @@ func main() {
 		lc.Errorf("Run returned error: %s", err.Error())
 		os.Exit(1)
 	}
+	if err := store.Close(); err != nil {
+		lc.Errorf("failed to close the authentication store: %s", err.Error())
+		os.Exit(1)
+	}
 
 	os.Exit(0)
 }
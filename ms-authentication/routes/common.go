@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 // PeopleFileName is the name of the respective struct data file that
@@ -21,13 +23,18 @@ const AccountsFileName = "accounts.json"
 // contains sample authentication data
 const CardsFileName = "cards.json"
 
+// DataDirectory is the directory of the people, accounts and cards files,
+// which is set from the configuration at startup. Empty is the working
+// directory, where the sample authentication data is.
+var DataDirectory = ""
+
 // WritePeople writes data to the respective JSON file
 func (people *People) WritePeople() (err error) {
 	peopleJson, err := json.Marshal(people)
 	if err != nil {
 		return fmt.Errorf("failed to marshal people: %s", err.Error())
 	}
-	err = os.WriteFile(PeopleFileName, peopleJson, 0644)
+	err = os.WriteFile(utilities.DataFileName(DataDirectory, PeopleFileName), peopleJson, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write people data to file: %s", err.Error())
 	}
@@ -40,7 +47,7 @@ func (accounts *Accounts) WriteAccounts() (err error) {
 	if err != nil {
 		return fmt.Errorf("failed to marshal accounts: %s", err.Error())
 	}
-	err = os.WriteFile(AccountsFileName, accountsJson, 0644)
+	err = os.WriteFile(utilities.DataFileName(DataDirectory, AccountsFileName), accountsJson, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write accounts data to file: %s", err.Error())
 	}
@@ -53,7 +60,7 @@ func (cards *Cards) WriteCards() (err error) {
 	if err != nil {
 		return fmt.Errorf("failed to marshal cards: %s", err.Error())
 	}
-	err = os.WriteFile(CardsFileName, cardsJson, 0644)
+	err = os.WriteFile(utilities.DataFileName(DataDirectory, CardsFileName), cardsJson, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write cards data to file: %s", err.Error())
 	}
@@ -110,7 +117,7 @@ func (cards *Cards) DeleteCard(cardToDelete Card) {
 
 // GetPeopleData reads the data from the respective JSON file
 func GetPeopleData() (people People, err error) {
-	data, err := os.ReadFile(PeopleFileName)
+	data, err := os.ReadFile(utilities.DataFileName(DataDirectory, PeopleFileName))
 	if err != nil {
 		return People{}, fmt.Errorf("failed to read from people JSON file: %s", err.Error())
 	}
@@ -122,7 +129,7 @@ func GetPeopleData() (people People, err error) {
 
 // GetAccountsData reads the data from the respective JSON file
 func GetAccountsData() (accounts Accounts, err error) {
-	data, err := os.ReadFile(AccountsFileName)
+	data, err := os.ReadFile(utilities.DataFileName(DataDirectory, AccountsFileName))
 	if err != nil {
 		return Accounts{}, fmt.Errorf("failed to read from accounts JSON file: %s", err.Error())
 	}
@@ -134,7 +141,7 @@ func GetAccountsData() (accounts Accounts, err error) {
 
 // GetCardsData reads the data from the respective JSON file
 func GetCardsData() (cards Cards, err error) {
-	data, err := os.ReadFile(CardsFileName)
+	data, err := os.ReadFile(utilities.DataFileName(DataDirectory, CardsFileName))
 	if err != nil {
 		return Cards{}, fmt.Errorf("failed to read from cards JSON file: %s", err.Error())
 	}
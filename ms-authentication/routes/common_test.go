@@ -5,6 +5,7 @@ package routes
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -569,3 +570,23 @@ func TestGetCardsDataError(t *testing.T) {
 	_, err := GetCardsData()
 	assert.Error(t, err, "Expected failure calling GetCardsData() for invalid JSON contents but did not get one")
 }
+
+// TestFileStoreDataDirectory tests that two instances with their own data
+// directories keep their cards apart
+func TestFileStoreDataDirectory(t *testing.T) {
+	defer func() { DataDirectory = "" }()
+	store := NewFileStore()
+
+	DataDirectory = t.TempDir()
+	require.NoError(t, store.SaveCards(Cards{Cards: []Card{{CardID: "0001230001"}}}))
+	assert.FileExists(t, filepath.Join(DataDirectory, CardsFileName))
+
+	DataDirectory = t.TempDir()
+	_, err := store.LoadCards()
+	assert.Error(t, err, "the other instance has no cards yet")
+	require.NoError(t, store.SaveCards(Cards{Cards: []Card{{CardID: "0001230002"}}}))
+	cards, err := store.LoadCards()
+	require.NoError(t, err)
+	require.Len(t, cards.Cards, 1)
+	assert.Equal(t, "0001230002", cards.Cards[0].CardID)
+}
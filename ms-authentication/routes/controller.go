@@ -5,20 +5,42 @@ package routes
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 type Controller struct {
-	service interfaces.ApplicationService
-	lc      logger.LoggingClient
+	service       interfaces.ApplicationService
+	lc            logger.LoggingClient
+	cardIDFormats CardIDFormats
+	store         AuthStore
+	// storeMutex is held while the cards, accounts and people are changed,
+	// so that the referential integrity checks see the saved data
+	storeMutex  *sync.Mutex
+	tokenSigner *TokenSigner
+	swipeGuard  *SwipeGuard
+	// identityProvider is where cards are looked up before the store, nil
+	// looks them up only in the store
+	identityProvider *IdentityProvider
+	// qrCodeSigner issues and verifies the QR codes that authenticate at
+	// the kiosk camera, nil disables QR codes
+	qrCodeSigner *QRCodeSigner
 }
 
-func NewController(service interfaces.ApplicationService) Controller {
+func NewController(service interfaces.ApplicationService, cardIDFormats CardIDFormats, store AuthStore, tokenSigner *TokenSigner, swipeGuard *SwipeGuard, identityProvider *IdentityProvider, qrCodeSigner *QRCodeSigner) Controller {
 	return Controller{
-		service: service,
-		lc:      service.LoggingClient(),
+		service:          service,
+		lc:               service.LoggingClient(),
+		cardIDFormats:    cardIDFormats,
+		store:            store,
+		storeMutex:       &sync.Mutex{},
+		tokenSigner:      tokenSigner,
+		swipeGuard:       swipeGuard,
+		identityProvider: identityProvider,
+		qrCodeSigner:     qrCodeSigner,
 	}
 }
 
@@ -27,6 +49,116 @@ func (c *Controller) AddAllRoutes() error {
 	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
+
+	err = c.service.AddRoute("/authentication/verify-pin", c.VerifyPinPost, "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/authentication/qr", c.AuthenticationQRPost, "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/authorization/{cardid}/{action}", c.AuthorizationGet, "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/enroll", c.requireAdmin(c.EnrollPost), "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards", c.requireAdmin(c.CardPost), "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}", c.requireAdmin(c.CardPut), "PUT")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}", c.requireAdmin(c.CardDelete), "DELETE")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}/pin", c.requireAdmin(c.CardPinPut), "PUT")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}/pin", c.requireAdmin(c.CardPinDelete), "DELETE")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}/lock", c.CardLockGet, "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}/lock", c.requireAdmin(c.CardLockDelete), "DELETE")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}/qrcode", c.requireAdmin(c.QRCodePost), "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/cards/{cardid}/status", c.requireAdmin(c.CardStatusPut), "PUT")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/accounts", c.requireAdmin(c.AccountPost), "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/accounts/{accountid}", c.AccountGet, "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/accounts/{accountid}", c.requireAdmin(c.AccountPut), "PUT")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/accounts/{accountid}", c.requireAdmin(c.AccountDelete), "DELETE")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/accounts/{accountid}/status", c.requireAdmin(c.AccountStatusPut), "PUT")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/people", c.requireAdmin(c.PersonPost), "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/people/{personid}", c.requireAdmin(c.PersonPut), "PUT")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/people/{personid}", c.requireAdmin(c.PersonDelete), "DELETE")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/version", utilities.VersionHandler(CurrentVersion()), "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
 	return nil
 }
 func errorAddRouteHandler(err error) error {
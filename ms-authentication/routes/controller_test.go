@@ -40,7 +40,7 @@ func TestController_AddAllRoutes(t *testing.T) {
 				mockAppService.On("AddRoute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("fail"))
 			}
 
-			c := NewController(mockAppService)
+			c := NewController(mockAppService, nil, NewFileStore(), nil, nil, nil, nil)
 
 			err := c.AddAllRoutes()
 
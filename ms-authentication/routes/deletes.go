@@ -0,0 +1,122 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CardDelete removes the card of the card ID in the URL, so that it can no
+// longer be used to open the vending machine
+func (c *Controller) CardDelete(writer http.ResponseWriter, req *http.Request) {
+	cardID := mux.Vars(req)["cardid"]
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	card := cards.GetCardByCardID(cardID)
+	if card.CardID != cardID || cardID == "" {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Card %s is not enrolled", cardID))
+		return
+	}
+
+	cards.DeleteCard(card)
+	if err := c.store.SaveCards(cards); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write cards data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Deleted card %s", cardID)
+	c.writeJSON(writer, card.withoutPinHash())
+}
+
+// AccountDelete removes the account of the account ID in the URL. An
+// account that people are still associated with is not removed.
+func (c *Controller) AccountDelete(writer http.ResponseWriter, req *http.Request) {
+	accountID, err := strconv.Atoi(mux.Vars(req)["accountid"])
+	if err != nil {
+		c.writeError(writer, http.StatusBadRequest, "accountID contains bad data")
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read accounts data: "+err.Error())
+		return
+	}
+	if !accounts.hasAccount(accountID) {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Account %d does not exist", accountID))
+		return
+	}
+	people, err := c.loadPeople()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read people data: "+err.Error())
+		return
+	}
+	if person := people.GetPersonByAccountID(accountID); person.AccountID == accountID {
+		c.writeError(writer, http.StatusConflict, fmt.Sprintf("Account %d still has person %d, delete or move them first", accountID, person.PersonID))
+		return
+	}
+
+	account := accounts.GetAccountByAccountID(accountID)
+	accounts.DeleteAccount(account)
+	if err := c.store.SaveAccounts(accounts); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write accounts data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Deleted account %d", accountID)
+	c.writeJSON(writer, account)
+}
+
+// PersonDelete removes the person of the person ID in the URL. A person who
+// still has cards is not removed.
+func (c *Controller) PersonDelete(writer http.ResponseWriter, req *http.Request) {
+	personID, err := strconv.Atoi(mux.Vars(req)["personid"])
+	if err != nil {
+		c.writeError(writer, http.StatusBadRequest, "personID contains bad data")
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	people, err := c.loadPeople()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read people data: "+err.Error())
+		return
+	}
+	if !people.hasPerson(personID) {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Person %d does not exist", personID))
+		return
+	}
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	if card := cards.GetCardByPersonID(personID); card.PersonID == personID && card.CardID != "" {
+		c.writeError(writer, http.StatusConflict, fmt.Sprintf("Person %d still has card %s, delete or move it first", personID, card.CardID))
+		return
+	}
+
+	person := people.GetPersonByPersonID(personID)
+	people.DeletePerson(person)
+	if err := c.store.SavePeople(people); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write people data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Deleted person %d", personID)
+	c.writeJSON(writer, person)
+}
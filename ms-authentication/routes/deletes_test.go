@@ -0,0 +1,83 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardDelete(t *testing.T) {
+	c := newStoreTestController(t)
+
+	w := storeRequest(c.CardDelete, http.MethodDelete, "http://localhost:48096/cards/0001230001", map[string]string{"cardid": "0001230001"}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	cards, err := c.store.LoadCards()
+	require.NoError(t, err)
+	assert.Empty(t, cards.GetCardByCardID("0001230001").CardID)
+
+	w = storeRequest(c.CardDelete, http.MethodDelete, "http://localhost:48096/cards/0001230001", map[string]string{"cardid": "0001230001"}, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPersonDelete(t *testing.T) {
+	tests := []struct {
+		Name               string
+		PersonID           string
+		ExpectedStatusCode int
+	}{
+		{"person without cards", "6", http.StatusOK},
+		{"person with cards", "1", http.StatusConflict},
+		{"unknown person", "42", http.StatusNotFound},
+		{"invalid person ID", "abc", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t)
+			w := storeRequest(c.PersonDelete, http.MethodDelete, "http://localhost:48096/people/"+currentTest.PersonID, map[string]string{"personid": currentTest.PersonID}, "")
+			assert.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+
+			people, err := c.store.LoadPeople()
+			require.NoError(t, err)
+			expectedLen := len(setupPeople().People)
+			if currentTest.ExpectedStatusCode == http.StatusOK {
+				expectedLen--
+			}
+			assert.Len(t, people.People, expectedLen)
+		})
+	}
+}
+
+func TestAccountDelete(t *testing.T) {
+	tests := []struct {
+		Name               string
+		AccountID          string
+		ExpectedStatusCode int
+	}{
+		{"account without people", "5", http.StatusOK},
+		{"account with people", "1", http.StatusConflict},
+		{"unknown account", "42", http.StatusNotFound},
+		{"invalid account ID", "abc", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t)
+			w := storeRequest(c.AccountDelete, http.MethodDelete, "http://localhost:48096/accounts/"+currentTest.AccountID, map[string]string{"accountid": currentTest.AccountID}, "")
+			assert.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+
+			accounts, err := c.store.LoadAccounts()
+			require.NoError(t, err)
+			expectedLen := len(setupAccounts().Accounts)
+			if currentTest.ExpectedStatusCode == http.StatusOK {
+				expectedLen--
+			}
+			assert.Len(t, accounts.Accounts, expectedLen)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultEnrollmentRoleID is the role of an enrolled card without a role
+const defaultEnrollmentRoleID = RoleConsumer
+
+// EnrollmentRequest is a card read by a kiosk in enrollment mode. The card
+// is enrolled for the existing person of PersonID, for a new person of the
+// existing account of AccountID, or, without either, for a new person with
+// a new account.
+type EnrollmentRequest struct {
+	CardID    string `json:"cardID"`
+	RoleID    int    `json:"roleID,omitempty"`
+	PersonID  int    `json:"personID,omitempty"`
+	AccountID int    `json:"accountID,omitempty"`
+	FullName  string `json:"fullName,omitempty"`
+}
+
+// Enrollment is the enrolled card with the person and account it was
+// enrolled for
+type Enrollment struct {
+	Card    Card    `json:"card"`
+	Person  Person  `json:"person"`
+	Account Account `json:"account"`
+}
+
+// EnrollPost enrolls a card read by the card reader of a kiosk in enrollment
+// mode, adding the person and account it is for when they are new. The card
+// ID is normalized with the CardIDFormats, as it is when it authenticates.
+func (c *Controller) EnrollPost(writer http.ResponseWriter, req *http.Request) {
+	var request EnrollmentRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal enrollment: "+err.Error())
+		return
+	}
+	cardIDs := []string{}
+	if request.CardID != "" {
+		for _, candidate := range c.cardIDFormats.Candidates(request.CardID) {
+			if len(candidate) == CardIDLength {
+				cardIDs = append(cardIDs, candidate)
+			}
+		}
+	}
+	if len(cardIDs) == 0 {
+		c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Card ID %s is not a %d-character card ID in any of the card ID formats", request.CardID, CardIDLength))
+		return
+	}
+	if request.RoleID == 0 {
+		request.RoleID = defaultEnrollmentRoleID
+	}
+	if !IsRole(request.RoleID) {
+		c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Unknown role %d", request.RoleID))
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	for _, cardID := range cardIDs {
+		if card := cards.GetCardByCardID(cardID); card.CardID == cardID {
+			c.writeError(writer, http.StatusConflict, fmt.Sprintf("Card %s is already enrolled for person %d", cardID, card.PersonID))
+			return
+		}
+	}
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read accounts data: "+err.Error())
+		return
+	}
+	people, err := c.loadPeople()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read people data: "+err.Error())
+		return
+	}
+
+	now := time.Now().UnixNano()
+	var enrollment Enrollment
+	newAccount, newPerson := false, false
+	switch {
+	case request.PersonID != 0:
+		if !people.hasPerson(request.PersonID) {
+			c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Card is associated with an unknown person %d", request.PersonID))
+			return
+		}
+		enrollment.Person = people.GetPersonByPersonID(request.PersonID)
+		if request.AccountID != 0 && request.AccountID != enrollment.Person.AccountID {
+			c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Person %d is not associated with account %d", request.PersonID, request.AccountID))
+			return
+		}
+		if !accounts.hasAccount(enrollment.Person.AccountID) {
+			c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Person %d is associated with an unknown account %d", request.PersonID, enrollment.Person.AccountID))
+			return
+		}
+		enrollment.Account = accounts.GetAccountByAccountID(enrollment.Person.AccountID)
+	case request.AccountID != 0:
+		if !accounts.hasAccount(request.AccountID) {
+			c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Person is associated with an unknown account %d", request.AccountID))
+			return
+		}
+		enrollment.Account = accounts.GetAccountByAccountID(request.AccountID)
+		newPerson = true
+	default:
+		enrollment.Account = Account{AccountID: accounts.nextAccountID(), CreatedAt: now, UpdatedAt: now, IsActive: true}
+		newAccount, newPerson = true, true
+	}
+	if newPerson {
+		enrollment.Person = Person{
+			PersonID:  people.nextPersonID(),
+			AccountID: enrollment.Account.AccountID,
+			FullName:  request.FullName,
+			CreatedAt: now,
+			UpdatedAt: now,
+			IsActive:  true,
+		}
+	}
+	enrollment.Card = Card{
+		CardID:    cardIDs[0],
+		RoleID:    request.RoleID,
+		IsValid:   true,
+		PersonID:  enrollment.Person.PersonID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// the account is saved before the person, and the person before the
+	// card, so that a failed save never leaves a card of an unknown person
+	if newAccount {
+		accounts.Accounts = append(accounts.Accounts, enrollment.Account)
+		if err := c.store.SaveAccounts(accounts); err != nil {
+			c.writeError(writer, http.StatusInternalServerError, "Failed to write accounts data: "+err.Error())
+			return
+		}
+	}
+	if newPerson {
+		people.People = append(people.People, enrollment.Person)
+		if err := c.store.SavePeople(people); err != nil {
+			c.writeError(writer, http.StatusInternalServerError, "Failed to write people data: "+err.Error())
+			return
+		}
+	}
+	cards.Cards = append(cards.Cards, enrollment.Card)
+	if err := c.store.SaveCards(cards); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write cards data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Enrolled card %s for person %d of account %d", enrollment.Card.CardID, enrollment.Person.PersonID, enrollment.Account.AccountID)
+	c.writeJSON(writer, enrollment)
+}
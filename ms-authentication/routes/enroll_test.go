@@ -0,0 +1,81 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrollPost(t *testing.T) {
+	tests := []struct {
+		Name               string
+		Body               string
+		ExpectedStatusCode int
+		ExpectedCardID     string
+		ExpectedPersonID   int
+		ExpectedAccountID  int
+	}{
+		{"existing person", `{"cardID":"0001239999","personID":1}`, http.StatusOK, "0001239999", 1, 1},
+		{"person of an unknown account", `{"cardID":"0001239999","personID":6}`, http.StatusBadRequest, "", 0, 0},
+		{"new person of existing account", `{"cardID":"0001239999","accountID":2,"fullName":"New Person"}`, http.StatusOK, "0001239999", 8, 2},
+		{"new person and account", `{"cardID":"0001239999","fullName":"New Person"}`, http.StatusOK, "0001239999", 8, 6},
+		{"hex card ID", `{"cardID":"0x12C4B1"}`, http.StatusOK, "0001230001", 0, 0},
+		{"already enrolled", `{"cardID":"0001230001"}`, http.StatusConflict, "", 0, 0},
+		{"unknown person", `{"cardID":"0001239999","personID":42}`, http.StatusBadRequest, "", 0, 0},
+		{"person of another account", `{"cardID":"0001239999","personID":1,"accountID":2}`, http.StatusBadRequest, "", 0, 0},
+		{"unknown account", `{"cardID":"0001239999","accountID":42}`, http.StatusBadRequest, "", 0, 0},
+		{"invalid card ID", `{"cardID":"123"}`, http.StatusBadRequest, "", 0, 0},
+		{"unknown role", `{"cardID":"0001239999","roleID":9}`, http.StatusBadRequest, "", 0, 0},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t)
+			// the enrolled hex card is removed, so that it can be enrolled again
+			if currentTest.Name == "hex card ID" {
+				cards := setupCards()
+				cards.DeleteCard(Card{CardID: "0001230001"})
+				require.NoError(t, c.store.SaveCards(cards))
+			}
+			formats, err := ParseCardIDFormats([]string{"stripPrefix:0x radix:16:10 pad:10"})
+			require.NoError(t, err)
+			c.cardIDFormats = formats
+
+			w := storeRequest(c.EnrollPost, http.MethodPost, "http://localhost:48096/enroll", nil, currentTest.Body)
+			require.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				cards, err := c.store.LoadCards()
+				require.NoError(t, err)
+				assert.Equal(t, setupCards(), cards, "a rejected card is not enrolled")
+				return
+			}
+
+			var enrollment Enrollment
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+			assert.Equal(t, currentTest.ExpectedCardID, enrollment.Card.CardID)
+			assert.Equal(t, defaultEnrollmentRoleID, enrollment.Card.RoleID)
+			assert.True(t, enrollment.Card.IsValid)
+			if currentTest.ExpectedPersonID != 0 {
+				assert.Equal(t, currentTest.ExpectedPersonID, enrollment.Person.PersonID)
+				assert.Equal(t, currentTest.ExpectedAccountID, enrollment.Account.AccountID)
+			}
+
+			// the enrolled card authenticates through the stored references
+			cards, err := c.store.LoadCards()
+			require.NoError(t, err)
+			people, err := c.store.LoadPeople()
+			require.NoError(t, err)
+			accounts, err := c.store.LoadAccounts()
+			require.NoError(t, err)
+			card := cards.GetCardByCardID(currentTest.ExpectedCardID)
+			assert.True(t, people.hasPerson(card.PersonID))
+			assert.True(t, accounts.hasAccount(people.GetPersonByPersonID(card.PersonID).AccountID))
+		})
+	}
+}
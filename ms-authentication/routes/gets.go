@@ -5,82 +5,169 @@ package routes
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // AuthenticationGet accepts a 10-character URL parameter in the form:
 // /authentication/0001230001
+// Card IDs read in another format are normalized with the configured
+// CardIDFormats first.
 // It will look up the associated Person and Account for the given card and
 // return an instance of AuthData
 func (c *Controller) AuthenticationGet(writer http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	cardID := vars["cardid"]
-	// check if the passed cardID is valid
-	if cardID == "" || len(cardID) != 10 {
-		c.lc.Infof("Please pass in a 10-character card ID as a URL parameter, like this: /authentication/0001230001")
-		writer.WriteHeader(http.StatusBadRequest)
-		writer.Write([]byte("Please pass in a 10-character card ID as a URL parameter, like this: /authentication/0001230001"))
+	c.respondAuthentication(writer, mux.Vars(req)["cardid"])
+}
+
+// respondAuthentication authenticates the card ID, and responds with its
+// authentication data and token
+func (c *Controller) respondAuthentication(writer http.ResponseWriter, readCardID string) {
+	now := time.Now()
+	authData, statusCode, errMsg := c.authenticate(readCardID)
+	// a card swiped and refused too often is locked until an admin unlocks it
+	if statusCode == http.StatusUnauthorized && c.swipeGuard.Failed(authData.CardID, now) {
+		c.lc.Infof("Card ID: %s is locked after too many failed swipes", authData.CardID)
+	}
+	if statusCode != http.StatusOK {
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg))
 		return
 	}
+	if !c.swipeGuard.Admit(authData.CardID, now) {
+		c.lc.Infof("Card ID: %s was swiped again within the anti-passback interval", authData.CardID)
+		writer.WriteHeader(http.StatusTooManyRequests)
+		writer.Write([]byte("Card ID was swiped again too soon"))
+		return
+	}
+
+	// the token lets the card holder call the administrative routes of the
+	// other services that their role allows, once any PIN is verified
+	if c.tokenSigner != nil && !authData.PinRequired {
+		token, err := c.tokenSigner.Sign(authData, time.Now())
+		if err != nil {
+			c.lc.Errorf("Failed to sign authentication token: %s", err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("failed to sign authentication token"))
+			return
+		}
+		authData.Token = token
+	}
+
+	authDataJSON, err := json.Marshal(authData)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("failed to marshal authentication data"))
+	}
+
+	// Because of how type-safe Go is, it's actually impossible to
+	// reach this error condition based on how this function is written
+	// Generally json.Marshal can throw errors if you pass a chan
+	// or something unmarshalable, but since authData is simply a struct
+	// with only ints and strings, we can't actually _not_ marshal it ever
+	// (I did some searching and that is my conclusion, I'm not stating this
+	// as fact)
+
+	c.lc.Infof("Successfully authenticated person and card")
+	writer.Write(authDataJSON)
+}
+
+// authenticate looks up the valid card of a card ID, read in any of the
+// configured CardIDFormats, and the active person and account it belongs
+// to. It returns the status code and message of the response when the card
+// cannot be authenticated, with only the normalized card ID when it is
+// unauthorized, and status code 423 when the card is locked.
+func (c *Controller) authenticate(readCardID string) (AuthData, int, string) {
+	// normalize the card ID from the reader's format and check that at least
+	// one form is a valid card ID
+	cardIDs := []string{}
+	if readCardID != "" {
+		for _, candidate := range c.cardIDFormats.Candidates(readCardID) {
+			if len(candidate) == CardIDLength {
+				cardIDs = append(cardIDs, candidate)
+			}
+		}
+	}
+	if len(cardIDs) == 0 {
+		c.lc.Infof("Please pass in a 10-character card ID as a URL parameter, like this: /authentication/0001230001")
+		return AuthData{}, http.StatusBadRequest, "Please pass in a 10-character card ID as a URL parameter, like this: /authentication/0001230001"
+	}
+
+	// the identity provider authenticates the cards it knows, and the store
+	// the cards it does not know, such as local maintenance cards, and every
+	// card while the provider cannot be reached
+	if c.identityProvider != nil {
+		if authData, statusCode, errMsg, ok := c.authenticateIdentity(cardIDs); ok {
+			return authData, statusCode, errMsg
+		}
+	}
 
 	// load up all card data so we can find our card
-	cards, err := GetCardsData()
+	cards, err := c.store.LoadCards()
 	if err != nil {
 		c.lc.Errorf("Failed to read authentication data: %s", err.Error())
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte("failed to read authentication data"))
-		return
+		return AuthData{}, http.StatusInternalServerError, "failed to read authentication data"
 	}
 
-	// check if the card's ID matches our given cardID
-	card := cards.GetCardByCardID(cardID)
+	// check if the card's ID matches one of the normalized card IDs
+	var card Card
+	cardID := cardIDs[0]
+	for _, candidate := range cardIDs {
+		card = cards.GetCardByCardID(candidate)
+		if card.CardID == candidate {
+			cardID = candidate
+			break
+		}
+	}
+	if c.swipeGuard.Locked(cardID) {
+		c.lc.Infof("Card ID: %s is locked", cardID)
+		return AuthData{}, http.StatusLocked, "Card ID is locked"
+	}
 	if card.CardID != cardID {
-		c.lc.Infof("Card ID: %s is not an authorized card", cardID)
-		writer.WriteHeader(http.StatusUnauthorized)
-		writer.Write([]byte("Card ID is not an authorized card"))
-		return
+		c.lc.Infof("Card ID: %s is not an authorized card", readCardID)
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is not an authorized card"
+	}
+	if cardID != readCardID {
+		c.lc.Debugf("Card ID %s was normalized to %s", readCardID, cardID)
 	}
 	if !card.IsValid {
 		c.lc.Infof("Card ID: %s is not an valid card", cardID)
-		writer.WriteHeader(http.StatusUnauthorized)
-		writer.Write([]byte("Card ID is not a valid card"))
-		return
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is not a valid card"
+	}
+	now := time.Now()
+	if err := card.check(now); err != nil {
+		c.lc.Infof("Card ID: %s %s", cardID, err.Error())
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID " + err.Error()
 	}
 
 	// card is found, get the cardholder's AccountID, RoleID, and PersonID
-	accounts, err := GetAccountsData()
+	accounts, err := c.store.LoadAccounts()
 	if err != nil {
 		c.lc.Errorf("Failed to read accounts data: %s", err.Error())
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte("failed to read accounts data"))
-		return
+		return AuthData{}, http.StatusInternalServerError, "failed to read accounts data"
 	}
-	people, err := GetPeopleData()
+	people, err := c.store.LoadPeople()
 	if err != nil {
 		c.lc.Errorf("Failed to read people data: %s", err.Error())
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte("failed to read people data"))
-		return
+		return AuthData{}, http.StatusInternalServerError, "failed to read people data"
 	}
 
 	// begin to store the output AuthData
-	authData := AuthData{CardID: cardID, RoleID: card.RoleID}
+	authData := AuthData{CardID: cardID, RoleID: card.RoleID, PinRequired: card.PinRequired}
 
 	// check if the associated person is valid
 	person := people.GetPersonByPersonID(card.PersonID)
 	if person.PersonID != card.PersonID {
 		c.lc.Infof("Card ID is associated with an unknown person %s", person.PersonID)
-		writer.WriteHeader(http.StatusUnauthorized)
-		writer.Write([]byte("Card ID is associated with an unknown person"))
-		return
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is associated with an unknown person"
 	}
 	if !person.IsActive {
 		c.lc.Infof("Card ID is associated with an inactive person %s", person.PersonID)
-		writer.WriteHeader(http.StatusUnauthorized)
-		writer.Write([]byte("Card ID is associated with an inactive person"))
-		return
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is associated with an inactive person"
 	}
 
 	// store the personID in the output AuthData
@@ -90,34 +177,57 @@ func (c *Controller) AuthenticationGet(writer http.ResponseWriter, req *http.Req
 	account := accounts.GetAccountByAccountID(person.AccountID)
 	if account.AccountID != person.AccountID {
 		c.lc.Infof("Card ID is associated with an unknown account %s", person.AccountID)
-		writer.WriteHeader(http.StatusUnauthorized)
-		writer.Write([]byte("Card ID is associated with an unknown account"))
-		return
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is associated with an unknown account"
 	}
 	if !account.IsActive {
 		c.lc.Infof("Card ID is associated with an inactive account %s", person.AccountID)
-		writer.WriteHeader(http.StatusUnauthorized)
-		writer.Write([]byte("Card ID is associated with an inactive account"))
-		return
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is associated with an inactive account"
+	}
+	if err := account.check(now); err != nil {
+		c.lc.Infof("Card ID is associated with account %d, which %s", account.AccountID, err.Error())
+		return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is associated with an account that " + err.Error()
 	}
 
-	// store the accountID in the output AuthData
+	// store the accountID and credit limit in the output AuthData
 	authData.AccountID = account.AccountID
+	authData.CreditLimit = account.CreditLimit
+	return authData, http.StatusOK, ""
+}
 
-	authDataJSON, err := json.Marshal(authData)
+// AccountGet returns the account of the account ID in the URL, such as for
+// ms-ledger to look up the email address that statements are sent to. Only
+// the last four digits of its credit card number are returned.
+func (c *Controller) AccountGet(writer http.ResponseWriter, req *http.Request) {
+	accountID, err := strconv.Atoi(mux.Vars(req)["accountid"])
 	if err != nil {
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte("failed to marshal authentication data"))
+		c.writeError(writer, http.StatusBadRequest, "accountID contains bad data")
+		return
 	}
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read accounts data: "+err.Error())
+		return
+	}
+	if !accounts.hasAccount(accountID) {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Account %d does not exist", accountID))
+		return
+	}
+	c.writeJSON(writer, accounts.GetAccountByAccountID(accountID).withoutCreditCardNumber())
+}
 
-	// Because of how type-safe Go is, it's actually impossible to
-	// reach this error condition based on how this function is written
-	// Generally json.Marshal can throw errors if you pass a chan
-	// or something unmarshalable, but since authData is simply a struct
-	// with only ints and strings, we can't actually _not_ marshal it ever
-	// (I did some searching and that is my conclusion, I'm not stating this
-	// as fact)
-
-	c.lc.Infof("Successfully authenticated person and card")
-	writer.Write(authDataJSON)
+// withoutCreditCardNumber returns the account with all but the last four
+// digits of its credit card number masked
+func (account Account) withoutCreditCardNumber() Account {
+	digits := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, account.CreditCardNumber)
+	if len(digits) > 4 {
+		account.CreditCardNumber = strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	} else {
+		account.CreditCardNumber = ""
+	}
+	return account
 }
@@ -90,6 +90,7 @@ func setupAccounts() Accounts {
 				CreatedAt:        1560815799,
 				UpdatedAt:        1560815799,
 				IsActive:         true,
+				CreditLimit:      25,
 			}, {
 				AccountID:        2,
 				Address:          "2 Test Lane",
@@ -216,10 +217,11 @@ func TestAuthenticationGet(t *testing.T) {
 	cards := setupCards()
 
 	validAuthData := AuthData{
-		AccountID: accounts.Accounts[0].AccountID,
-		PersonID:  people.People[0].PersonID,
-		RoleID:    cards.Cards[0].RoleID,
-		CardID:    cards.Cards[0].CardID,
+		AccountID:   accounts.Accounts[0].AccountID,
+		PersonID:    people.People[0].PersonID,
+		RoleID:      cards.Cards[0].RoleID,
+		CardID:      cards.Cards[0].CardID,
+		CreditLimit: accounts.Accounts[0].CreditLimit,
 	}
 
 	tests := []struct {
@@ -249,7 +251,7 @@ func TestAuthenticationGet(t *testing.T) {
 			mockAppService.On("LoggingClient").Return(logger.NewMockClient())
 			mockAppService.On("AddRoute", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-			c := NewController(mockAppService)
+			c := NewController(mockAppService, nil, NewFileStore(), nil, nil, nil, nil)
 
 			if currentTest.WriteFiles {
 				err := writeJSONFiles(people, accounts, cards)
@@ -287,3 +289,80 @@ func TestAuthenticationGet(t *testing.T) {
 		})
 	}
 }
+
+// TestAuthenticationGetNormalizedCardID tests that card IDs read in another
+// format authenticate as the enrolled card
+func TestAuthenticationGetNormalizedCardID(t *testing.T) {
+	people := setupPeople()
+	accounts := setupAccounts()
+	cards := setupCards()
+	require.NoError(t, writeJSONFiles(people, accounts, cards), "Failed to write to test file")
+
+	cardIDFormats, err := ParseCardIDFormats([]string{"stripPrefix:0x radix:16:10 pad:10", "pad:10"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name           string
+		CardID         string
+		StatusCode     int
+		ExpectedCardID string
+	}{
+		{"Enrolled form", "0001230001", http.StatusOK, "0001230001"},
+		{"Hex form", "0x12C4B1", http.StatusOK, "0001230001"},
+		{"Decimal without leading zeros", "1230001", http.StatusOK, "0001230001"},
+		{"Unknown hex card", "0xFFFFFF", http.StatusUnauthorized, ""},
+		{"Too long in every format", "00012300010", http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockAppService := &mocks.ApplicationService{}
+			mockAppService.On("LoggingClient").Return(logger.NewMockClient())
+
+			c := NewController(mockAppService, cardIDFormats, NewFileStore(), nil, nil, nil, nil)
+
+			req := httptest.NewRequest("GET", "/authentication/"+currentTest.CardID, nil)
+			w := httptest.NewRecorder()
+			req = mux.SetURLVars(req, map[string]string{"cardid": currentTest.CardID})
+			c.AuthenticationGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.StatusCode, resp.StatusCode)
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			responseAuthData := AuthData{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&responseAuthData))
+			assert.Equal(t, currentTest.ExpectedCardID, responseAuthData.CardID)
+			assert.Equal(t, cards.Cards[0].RoleID, responseAuthData.RoleID)
+		})
+	}
+}
+
+func TestAccountGet(t *testing.T) {
+	tests := []struct {
+		Name               string
+		AccountID          string
+		ExpectedStatusCode int
+	}{
+		{"account", "1", http.StatusOK},
+		{"unknown account", "42", http.StatusNotFound},
+		{"invalid account ID", "abc", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t)
+			w := storeRequest(c.AccountGet, http.MethodGet, "http://localhost:48096/accounts/"+currentTest.AccountID, map[string]string{"accountid": currentTest.AccountID}, "")
+			require.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var account Account
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+			assert.Equal(t, "test1@example.com", account.EmailAddress)
+			assert.Equal(t, "************3456", account.CreditCardNumber)
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// IdentityProviderREST looks cards up in an identity provider or HR
+	// system with a REST API
+	IdentityProviderREST = "rest"
+	// IdentityProviderLDAP looks cards up in an LDAP directory
+	IdentityProviderLDAP = "ldap"
+
+	// DefaultIdentityCacheTTL is how long a card looked up in the identity
+	// provider is reused before it is looked up again
+	DefaultIdentityCacheTTL = 5 * time.Minute
+	// DefaultIdentityOfflineTTL is how long a card looked up in the identity
+	// provider is still authenticated while the provider cannot be reached
+	DefaultIdentityOfflineTTL = 24 * time.Hour
+	// DefaultIdentityTimeout is how long a lookup in the identity provider
+	// may take, as the card holder waits at the kiosk
+	DefaultIdentityTimeout = 3 * time.Second
+)
+
+var (
+	// ErrIdentityNotFound is returned when the identity provider does not
+	// know the card
+	ErrIdentityNotFound = errors.New("card is not known to the identity provider")
+	// ErrIdentityProviderUnavailable is returned when the identity provider
+	// cannot be reached and the card was not looked up recently enough
+	ErrIdentityProviderUnavailable = errors.New("identity provider is unavailable")
+)
+
+// roleNames are the names of the roles, which an identity provider may
+// hold in place of the role IDs
+var roleNames = map[string]int{
+	"consumer":   RoleConsumer,
+	"stocker":    RoleStocker,
+	"maintainer": RoleMaintainer,
+	"technician": RoleTechnician,
+	"admin":      RoleAdmin,
+}
+
+// Identity is the holder of a card as the identity provider knows them
+type Identity struct {
+	CardID      string  `json:"cardID"`
+	RoleID      int     `json:"roleID"`
+	PersonID    int     `json:"personID"`
+	AccountID   int     `json:"accountID"`
+	CreditLimit float64 `json:"creditLimit,omitempty"`
+	IsActive    bool    `json:"isActive"`
+}
+
+// IdentityConnector looks the holder of a card up in an identity provider.
+// It returns ErrIdentityNotFound when the provider does not know the card,
+// and any other error when the provider cannot be reached.
+type IdentityConnector interface {
+	LookupCard(cardID string) (Identity, error)
+}
+
+// IdentityProviderConfig holds the settings of the identity provider. The
+// durations are strings, empty is their default.
+type IdentityProviderConfig struct {
+	Type       string
+	URL        string
+	Timeout    string
+	CacheTTL   string
+	OfflineTTL string
+	// APIKey is sent as the bearer token of the REST lookups
+	APIKey string
+	// LDAP is the directory of the ldap identity provider
+	LDAP LDAPIdentityConnector
+}
+
+// cachedIdentity is the last answer of the identity provider for a card,
+// which is either the identity or that the card is not known
+type cachedIdentity struct {
+	identity  Identity
+	found     bool
+	fetchedAt time.Time
+}
+
+// IdentityProvider delegates card lookups to an external identity provider
+// and caches its answers, so that a card is not looked up on every swipe
+// and recently seen cards are still authenticated while the provider
+// cannot be reached
+type IdentityProvider struct {
+	connector  IdentityConnector
+	cacheTTL   time.Duration
+	offlineTTL time.Duration
+	mutex      sync.Mutex
+	cache      map[string]cachedIdentity
+}
+
+// NewIdentityProvider creates an IdentityProvider that looks cards up with
+// the connector
+func NewIdentityProvider(connector IdentityConnector, cacheTTL time.Duration, offlineTTL time.Duration) *IdentityProvider {
+	return &IdentityProvider{
+		connector:  connector,
+		cacheTTL:   cacheTTL,
+		offlineTTL: offlineTTL,
+		cache:      map[string]cachedIdentity{},
+	}
+}
+
+// NewIdentityProviderFromConfig validates the identity provider settings
+// and creates the IdentityProvider of their type. It returns nil when no
+// identity provider is configured.
+func NewIdentityProviderFromConfig(config IdentityProviderConfig) (*IdentityProvider, error) {
+	if config.Type == "" {
+		return nil, nil
+	}
+	timeout, err := parseIdentityDuration("IdentityProviderTimeout", config.Timeout, DefaultIdentityTimeout)
+	if err != nil {
+		return nil, err
+	}
+	cacheTTL, err := parseIdentityDuration("IdentityCacheTTL", config.CacheTTL, DefaultIdentityCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	offlineTTL, err := parseIdentityDuration("IdentityOfflineTTL", config.OfflineTTL, DefaultIdentityOfflineTTL)
+	if err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("IdentityProviderURL must be set for the %s identity provider", config.Type)
+	}
+
+	var connector IdentityConnector
+	switch config.Type {
+	case IdentityProviderREST:
+		connector = &RESTIdentityConnector{
+			URL:    config.URL,
+			APIKey: config.APIKey,
+			client: &http.Client{Timeout: timeout},
+		}
+	case IdentityProviderLDAP:
+		ldap := config.LDAP
+		ldap.URL = config.URL
+		ldap.Timeout = timeout
+		if err := ldap.validate(); err != nil {
+			return nil, err
+		}
+		connector = &ldap
+	default:
+		return nil, fmt.Errorf("unknown identity provider %q, expected %s or %s", config.Type, IdentityProviderREST, IdentityProviderLDAP)
+	}
+	return NewIdentityProvider(connector, cacheTTL, offlineTTL), nil
+}
+
+// parseIdentityDuration parses a duration setting of the identity
+// provider, empty is the default
+func parseIdentityDuration(name string, value string, defaultDuration time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultDuration, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s %q must be a duration that is not negative", name, value)
+	}
+	return duration, nil
+}
+
+// ParseRole parses the role of a card held by an identity provider, which
+// is either a role ID or the name of a role
+func ParseRole(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if roleID, ok := roleNames[strings.ToLower(value)]; ok {
+		return roleID, nil
+	}
+	roleID, err := strconv.Atoi(value)
+	if err != nil || !IsRole(roleID) {
+		return 0, fmt.Errorf("%q is not a role", value)
+	}
+	return roleID, nil
+}
+
+// Lookup returns the holder of the card, which is looked up in the identity
+// provider unless it was within the cache TTL. When the provider cannot be
+// reached, the card is answered from the cache for up to the offline TTL.
+func (provider *IdentityProvider) Lookup(lc logger.LoggingClient, cardID string, now time.Time) (Identity, error) {
+	provider.mutex.Lock()
+	cached, isCached := provider.cache[cardID]
+	provider.mutex.Unlock()
+	if isCached && now.Sub(cached.fetchedAt) < provider.cacheTTL {
+		return cached.result()
+	}
+
+	identity, err := provider.connector.LookupCard(cardID)
+	if err == nil || errors.Is(err, ErrIdentityNotFound) {
+		provider.mutex.Lock()
+		provider.cache[cardID] = cachedIdentity{identity: identity, found: err == nil, fetchedAt: now}
+		provider.mutex.Unlock()
+		return identity, err
+	}
+
+	if isCached && now.Sub(cached.fetchedAt) < provider.offlineTTL {
+		lc.Warnf("Identity provider cannot be reached, using card %s as it was looked up at %s: %s", cardID, cached.fetchedAt.Format(time.RFC3339), err.Error())
+		return cached.result()
+	}
+	return Identity{}, fmt.Errorf("%w: %s", ErrIdentityProviderUnavailable, err.Error())
+}
+
+// result returns the cached answer as the connector returned it
+func (cached cachedIdentity) result() (Identity, error) {
+	if !cached.found {
+		return Identity{}, ErrIdentityNotFound
+	}
+	return cached.identity, nil
+}
+
+// authenticateIdentity authenticates the first of the card IDs that the
+// identity provider knows. It returns false when the provider knows none of
+// them or cannot be reached, and the card is looked up in the store instead.
+func (c *Controller) authenticateIdentity(cardIDs []string) (AuthData, int, string, bool) {
+	for _, cardID := range cardIDs {
+		identity, err := c.identityProvider.Lookup(c.lc, cardID, time.Now())
+		if errors.Is(err, ErrIdentityNotFound) {
+			continue
+		}
+		if err != nil {
+			c.lc.Warnf("Failed to look up card ID %s in the identity provider, looking it up in the local cards: %s", cardID, err.Error())
+			return AuthData{}, 0, "", false
+		}
+
+		if c.swipeGuard.Locked(cardID) {
+			c.lc.Infof("Card ID: %s is locked", cardID)
+			return AuthData{}, http.StatusLocked, "Card ID is locked", true
+		}
+		if !identity.IsActive {
+			c.lc.Infof("Card ID: %s is not active in the identity provider", cardID)
+			return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID is not an active card", true
+		}
+		if !IsRole(identity.RoleID) {
+			c.lc.Infof("Card ID: %s has the unknown role %d in the identity provider", cardID, identity.RoleID)
+			return AuthData{CardID: cardID}, http.StatusUnauthorized, "Card ID has an unknown role", true
+		}
+		return AuthData{
+			AccountID:   identity.AccountID,
+			PersonID:    identity.PersonID,
+			RoleID:      identity.RoleID,
+			CardID:      cardID,
+			CreditLimit: identity.CreditLimit,
+		}, http.StatusOK, "", true
+	}
+	return AuthData{}, 0, "", false
+}
@@ -0,0 +1,58 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RESTIdentityConnector looks cards up with GET <URL>/<cardID>, which
+// returns the Identity of the card holder as JSON, or status code 404 for
+// a card it does not know
+type RESTIdentityConnector struct {
+	URL string
+	// APIKey is sent as the bearer token of every lookup, when it is set
+	APIKey string
+	client *http.Client
+}
+
+// LookupCard looks the holder of the card up in the identity provider
+func (connector *RESTIdentityConnector) LookupCard(cardID string) (Identity, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(connector.URL, "/")+"/"+url.PathEscape(cardID), nil)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to create the identity request: %s", err.Error())
+	}
+	if connector.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+connector.APIKey)
+	}
+	client := connector.client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultIdentityTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to reach the identity provider: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Identity{}, ErrIdentityNotFound
+	default:
+		return Identity{}, fmt.Errorf("identity provider returned status code %d", resp.StatusCode)
+	}
+	var identity Identity
+	if err := json.NewDecoder(resp.Body).Decode(&identity); err != nil {
+		return Identity{}, fmt.Errorf("failed to parse the identity of card %s: %s", cardID, err.Error())
+	}
+	if identity.CardID == "" {
+		identity.CardID = cardID
+	}
+	return identity, nil
+}
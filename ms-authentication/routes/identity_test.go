@@ -0,0 +1,202 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdentityConnector knows the identities, and cannot be reached while
+// it is offline
+type fakeIdentityConnector struct {
+	identities map[string]Identity
+	offline    bool
+	lookups    int
+}
+
+func (connector *fakeIdentityConnector) LookupCard(cardID string) (Identity, error) {
+	connector.lookups++
+	if connector.offline {
+		return Identity{}, errors.New("connection refused")
+	}
+	identity, ok := connector.identities[cardID]
+	if !ok {
+		return Identity{}, ErrIdentityNotFound
+	}
+	return identity, nil
+}
+
+func TestParseRole(t *testing.T) {
+	for value, expected := range map[string]int{"1": RoleConsumer, "maintainer": RoleMaintainer, " Admin ": RoleAdmin} {
+		roleID, err := ParseRole(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, roleID, value)
+	}
+	for _, value := range []string{"9", "owner", ""} {
+		_, err := ParseRole(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestNewIdentityProviderFromConfig(t *testing.T) {
+	ldap := LDAPIdentityConnector{BaseDN: "ou=people,dc=example,dc=com", CardAttribute: "badgeID", RoleAttribute: "kioskRole", PersonAttribute: "employeeNumber", AccountAttribute: "costCenter"}
+	tests := []struct {
+		Name          string
+		Config        IdentityProviderConfig
+		ExpectedNil   bool
+		ExpectedError bool
+	}{
+		{"none", IdentityProviderConfig{}, true, false},
+		{"rest", IdentityProviderConfig{Type: IdentityProviderREST, URL: "http://hr.example.com/badges"}, false, false},
+		{"ldap", IdentityProviderConfig{Type: IdentityProviderLDAP, URL: "ldaps://ldap.example.com", LDAP: ldap}, false, false},
+		{"unknown type", IdentityProviderConfig{Type: "saml", URL: "http://idp.example.com"}, false, true},
+		{"no URL", IdentityProviderConfig{Type: IdentityProviderREST}, false, true},
+		{"ldap without base DN", IdentityProviderConfig{Type: IdentityProviderLDAP, URL: "ldap://ldap.example.com", LDAP: LDAPIdentityConnector{CardAttribute: "badgeID"}}, false, true},
+		{"ldap with an http URL", IdentityProviderConfig{Type: IdentityProviderLDAP, URL: "http://ldap.example.com", LDAP: ldap}, false, true},
+		{"invalid cache TTL", IdentityProviderConfig{Type: IdentityProviderREST, URL: "http://hr.example.com/badges", CacheTTL: "soon"}, false, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			provider, err := NewIdentityProviderFromConfig(currentTest.Config)
+			if currentTest.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedNil, provider == nil)
+		})
+	}
+}
+
+// TestIdentityProviderLookup tests that lookups are cached, and that the
+// cached cards are still authenticated while the provider is offline
+func TestIdentityProviderLookup(t *testing.T) {
+	lc := logger.NewMockClient()
+	connector := &fakeIdentityConnector{identities: map[string]Identity{
+		"0009990001": {CardID: "0009990001", RoleID: RoleConsumer, PersonID: 100, AccountID: 10, IsActive: true},
+	}}
+	provider := NewIdentityProvider(connector, time.Minute, time.Hour)
+	start := time.Now()
+
+	identity, err := provider.Lookup(lc, "0009990001", start)
+	require.NoError(t, err)
+	assert.Equal(t, 100, identity.PersonID)
+	_, err = provider.Lookup(lc, "0009990002", start)
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+	assert.Equal(t, 2, connector.lookups)
+
+	// both answers are cached
+	_, _ = provider.Lookup(lc, "0009990001", start.Add(30*time.Second))
+	_, err = provider.Lookup(lc, "0009990002", start.Add(30*time.Second))
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+	assert.Equal(t, 2, connector.lookups)
+
+	// offline, the cached card is authenticated until the offline TTL
+	connector.offline = true
+	identity, err = provider.Lookup(lc, "0009990001", start.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 100, identity.PersonID)
+	assert.Equal(t, 3, connector.lookups, "the provider is asked again once the cache TTL passed")
+	_, err = provider.Lookup(lc, "0009990001", start.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrIdentityProviderUnavailable)
+	_, err = provider.Lookup(lc, "0009990003", start)
+	assert.ErrorIs(t, err, ErrIdentityProviderUnavailable, "a card that was never looked up")
+
+	// back online, the provider is asked again
+	connector.offline = false
+	connector.identities["0009990001"] = Identity{CardID: "0009990001", RoleID: RoleConsumer, PersonID: 100, AccountID: 10, IsActive: false}
+	identity, err = provider.Lookup(lc, "0009990001", start.Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, identity.IsActive)
+}
+
+func TestRESTIdentityConnector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/badges/0009990001":
+			_, _ = w.Write([]byte(`{"roleID":3,"personID":100,"accountID":10,"creditLimit":50,"isActive":true}`))
+		case "/badges/0009990002":
+			_, _ = w.Write([]byte(`{"roleID":`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	connector := &RESTIdentityConnector{URL: server.URL + "/badges/", APIKey: "test-key"}
+
+	identity, err := connector.LookupCard("0009990001")
+	require.NoError(t, err)
+	assert.Equal(t, Identity{CardID: "0009990001", RoleID: RoleMaintainer, PersonID: 100, AccountID: 10, CreditLimit: 50, IsActive: true}, identity)
+	_, err = connector.LookupCard("0009990003")
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+	_, err = connector.LookupCard("0009990002")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrIdentityNotFound)
+
+	connector.APIKey = "wrong-key"
+	_, err = connector.LookupCard("0009990001")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrIdentityNotFound)
+
+	server.Close()
+	_, err = connector.LookupCard("0009990001")
+	assert.Error(t, err)
+}
+
+// TestAuthenticationGetIdentityProvider tests that cards are authenticated
+// by the identity provider, and by the store for the cards it does not
+// know and while it is offline
+func TestAuthenticationGetIdentityProvider(t *testing.T) {
+	c := newStoreTestController(t)
+	connector := &fakeIdentityConnector{identities: map[string]Identity{
+		"0009990001": {CardID: "0009990001", RoleID: RoleStocker, PersonID: 100, AccountID: 10, IsActive: true},
+		"0009990002": {CardID: "0009990002", RoleID: RoleConsumer, PersonID: 101, AccountID: 10},
+	}}
+	c.identityProvider = NewIdentityProvider(connector, 0, time.Hour)
+	authenticate := func(cardID string) (int, AuthData) {
+		w := storeRequest(c.AuthenticationGet, http.MethodGet, "http://localhost:48096/authentication/"+cardID, map[string]string{"cardid": cardID}, "")
+		var authData AuthData
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &authData))
+		}
+		return w.Code, authData
+	}
+
+	statusCode, authData := authenticate("0009990001")
+	require.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, AuthData{AccountID: 10, PersonID: 100, RoleID: RoleStocker, CardID: "0009990001"}, authData)
+
+	statusCode, _ = authenticate("0009990002")
+	assert.Equal(t, http.StatusUnauthorized, statusCode, "a card that is not active")
+
+	// the local cards are still authenticated
+	statusCode, authData = authenticate("0001230001")
+	require.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, 1, authData.AccountID)
+	statusCode, _ = authenticate("0009999999")
+	assert.Equal(t, http.StatusUnauthorized, statusCode)
+
+	// offline, the card looked up before is still authenticated, and the
+	// others are looked up in the store
+	connector.offline = true
+	statusCode, authData = authenticate("0009990001")
+	require.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, 100, authData.PersonID)
+	statusCode, _ = authenticate("0001230001")
+	assert.Equal(t, http.StatusOK, statusCode)
+}
@@ -0,0 +1,429 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// The BER tags of the LDAP messages and their elements (RFC 4511) that the
+// LDAP connector uses
+const (
+	berTagBoolean         = 0x01
+	berTagInteger         = 0x02
+	berTagOctetString     = 0x04
+	berTagEnumerated      = 0x0a
+	berTagSequence        = 0x30
+	ldapTagBindRequest    = 0x60
+	ldapTagBindResponse   = 0x61
+	ldapTagUnbindRequest  = 0x42
+	ldapTagSearchRequest  = 0x63
+	ldapTagSearchEntry    = 0x64
+	ldapTagSearchDone     = 0x65
+	ldapTagSimpleAuth     = 0x80
+	ldapTagEqualityFilter = 0xa3
+
+	ldapVersion         = 3
+	ldapScopeSubtree    = 2
+	ldapNeverDerefAlias = 0
+	ldapResultSuccess   = 0
+)
+
+// LDAPIdentityConnector looks cards up in an LDAP directory, by searching
+// the BaseDN for the entry whose CardAttribute is the card ID. An entry
+// that is found is an active card holder, and is removed from the
+// directory, or from the BaseDN, when they leave.
+type LDAPIdentityConnector struct {
+	// URL is the ldap:// or ldaps:// URL of the directory
+	URL     string
+	Timeout time.Duration
+	// BindDN and BindPassword are the credentials of the lookups, empty
+	// binds anonymously
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// the attributes of the entries that hold the card ID, the role, which
+	// is a role ID or name, the person ID, the account ID and the optional
+	// credit limit
+	CardAttribute        string
+	RoleAttribute        string
+	PersonAttribute      string
+	AccountAttribute     string
+	CreditLimitAttribute string
+}
+
+// ldapEntry is an entry found by a search, with the values of its
+// attributes
+type ldapEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// validate checks that the directory and the attributes of the card
+// holders are configured
+func (connector *LDAPIdentityConnector) validate() error {
+	directoryURL, err := url.Parse(connector.URL)
+	if err != nil || (directoryURL.Scheme != "ldap" && directoryURL.Scheme != "ldaps") || directoryURL.Host == "" {
+		return fmt.Errorf("IdentityProviderURL %q must be an ldap:// or ldaps:// URL", connector.URL)
+	}
+	if connector.BaseDN == "" {
+		return errors.New("IdentityLDAPBaseDN must be set for the ldap identity provider")
+	}
+	for name, attribute := range map[string]string{
+		"IdentityLDAPCardAttribute":    connector.CardAttribute,
+		"IdentityLDAPRoleAttribute":    connector.RoleAttribute,
+		"IdentityLDAPPersonAttribute":  connector.PersonAttribute,
+		"IdentityLDAPAccountAttribute": connector.AccountAttribute,
+	} {
+		if attribute == "" {
+			return fmt.Errorf("%s must be set for the ldap identity provider", name)
+		}
+	}
+	return nil
+}
+
+// LookupCard looks the holder of the card up in the directory
+func (connector *LDAPIdentityConnector) LookupCard(cardID string) (Identity, error) {
+	conn, err := connector.dial()
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to reach the LDAP directory: %s", err.Error())
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(connector.timeout())); err != nil {
+		return Identity{}, err
+	}
+	reader := bufio.NewReader(conn)
+
+	if connector.BindDN != "" {
+		if err := ldapBind(conn, reader, 1, connector.BindDN, connector.BindPassword); err != nil {
+			return Identity{}, err
+		}
+	}
+	attributes := []string{connector.RoleAttribute, connector.PersonAttribute, connector.AccountAttribute}
+	if connector.CreditLimitAttribute != "" {
+		attributes = append(attributes, connector.CreditLimitAttribute)
+	}
+	entries, err := ldapSearch(conn, reader, 2, connector.BaseDN, connector.CardAttribute, cardID, attributes)
+	// the connection is closed either way, so a failed unbind is ignored
+	_, _ = conn.Write(ldapMessage(3, berElementBytes(ldapTagUnbindRequest, nil)))
+	if err != nil {
+		return Identity{}, err
+	}
+	switch len(entries) {
+	case 0:
+		return Identity{}, ErrIdentityNotFound
+	case 1:
+	default:
+		return Identity{}, fmt.Errorf("card %s matches %d LDAP entries", cardID, len(entries))
+	}
+	return connector.identity(cardID, entries[0])
+}
+
+// timeout is how long a lookup may take
+func (connector *LDAPIdentityConnector) timeout() time.Duration {
+	if connector.Timeout <= 0 {
+		return DefaultIdentityTimeout
+	}
+	return connector.Timeout
+}
+
+// dial connects to the directory, with TLS for ldaps:// URLs
+func (connector *LDAPIdentityConnector) dial() (net.Conn, error) {
+	directoryURL, err := url.Parse(connector.URL)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: connector.timeout()}
+	host := directoryURL.Host
+	if directoryURL.Scheme == "ldaps" {
+		if directoryURL.Port() == "" {
+			host = net.JoinHostPort(directoryURL.Hostname(), "636")
+		}
+		return tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: directoryURL.Hostname(), MinVersion: tls.VersionTLS12})
+	}
+	if directoryURL.Port() == "" {
+		host = net.JoinHostPort(directoryURL.Hostname(), "389")
+	}
+	return dialer.Dial("tcp", host)
+}
+
+// identity maps the attributes of the card holder's entry to their identity
+func (connector *LDAPIdentityConnector) identity(cardID string, entry ldapEntry) (Identity, error) {
+	value := func(attribute string) (string, error) {
+		values := entry.attributes[attribute]
+		if len(values) == 0 {
+			return "", fmt.Errorf("LDAP entry %s has no %s", entry.dn, attribute)
+		}
+		return values[0], nil
+	}
+	identity := Identity{CardID: cardID, IsActive: true}
+
+	role, err := value(connector.RoleAttribute)
+	if err != nil {
+		return Identity{}, err
+	}
+	if identity.RoleID, err = ParseRole(role); err != nil {
+		return Identity{}, fmt.Errorf("LDAP entry %s has an invalid %s: %s", entry.dn, connector.RoleAttribute, err.Error())
+	}
+	for attribute, id := range map[string]*int{connector.PersonAttribute: &identity.PersonID, connector.AccountAttribute: &identity.AccountID} {
+		text, err := value(attribute)
+		if err != nil {
+			return Identity{}, err
+		}
+		if *id, err = strconv.Atoi(text); err != nil {
+			return Identity{}, fmt.Errorf("LDAP entry %s has an invalid %s %q", entry.dn, attribute, text)
+		}
+	}
+	if connector.CreditLimitAttribute != "" {
+		if text, err := value(connector.CreditLimitAttribute); err == nil {
+			if identity.CreditLimit, err = strconv.ParseFloat(text, 64); err != nil {
+				return Identity{}, fmt.Errorf("LDAP entry %s has an invalid %s %q", entry.dn, connector.CreditLimitAttribute, text)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// ldapBind authenticates the connection with a simple bind
+func ldapBind(writer io.Writer, reader *bufio.Reader, messageID int, bindDN string, password string) error {
+	request := berElementBytes(ldapTagBindRequest, concatBytes(
+		berIntegerBytes(berTagInteger, ldapVersion),
+		berElementBytes(berTagOctetString, []byte(bindDN)),
+		berElementBytes(ldapTagSimpleAuth, []byte(password)),
+	))
+	if _, err := writer.Write(ldapMessage(messageID, request)); err != nil {
+		return fmt.Errorf("failed to bind to the LDAP directory: %s", err.Error())
+	}
+	response, err := readLDAPResponse(reader, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to bind to the LDAP directory: %s", err.Error())
+	}
+	if response.tag != ldapTagBindResponse {
+		return fmt.Errorf("failed to bind to the LDAP directory: unexpected response 0x%x", response.tag)
+	}
+	return ldapResultError("bind", response)
+}
+
+// ldapSearch returns the entries under the base DN whose attribute equals
+// the value, with the requested attributes
+func ldapSearch(writer io.Writer, reader *bufio.Reader, messageID int, baseDN string, attribute string, value string, attributes []string) ([]ldapEntry, error) {
+	requested := []byte{}
+	for _, name := range attributes {
+		requested = append(requested, berElementBytes(berTagOctetString, []byte(name))...)
+	}
+	request := berElementBytes(ldapTagSearchRequest, concatBytes(
+		berElementBytes(berTagOctetString, []byte(baseDN)),
+		berIntegerBytes(berTagEnumerated, ldapScopeSubtree),
+		berIntegerBytes(berTagEnumerated, ldapNeverDerefAlias),
+		// two entries are enough to tell that a card matches several
+		berIntegerBytes(berTagInteger, 2),
+		berIntegerBytes(berTagInteger, 0),
+		berElementBytes(berTagBoolean, []byte{0}),
+		berElementBytes(ldapTagEqualityFilter, concatBytes(
+			berElementBytes(berTagOctetString, []byte(attribute)),
+			berElementBytes(berTagOctetString, []byte(value)),
+		)),
+		berElementBytes(berTagSequence, requested),
+	))
+	if _, err := writer.Write(ldapMessage(messageID, request)); err != nil {
+		return nil, fmt.Errorf("failed to search the LDAP directory: %s", err.Error())
+	}
+
+	entries := []ldapEntry{}
+	for {
+		response, err := readLDAPResponse(reader, messageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search the LDAP directory: %s", err.Error())
+		}
+		switch response.tag {
+		case ldapTagSearchEntry:
+			entry, err := parseLDAPEntry(response)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case ldapTagSearchDone:
+			if len(entries) > 1 {
+				// the size limit is exceeded by a card that matches several
+				return entries, nil
+			}
+			return entries, ldapResultError("search", response)
+		}
+		// search result references are not followed
+	}
+}
+
+// parseLDAPEntry parses the DN and the attributes of a search result entry
+func parseLDAPEntry(response berElement) (ldapEntry, error) {
+	elements, err := response.children()
+	if err != nil || len(elements) < 2 {
+		return ldapEntry{}, errors.New("failed to parse an LDAP search result entry")
+	}
+	entry := ldapEntry{dn: string(elements[0].content), attributes: map[string][]string{}}
+	attributes, err := elements[1].children()
+	if err != nil {
+		return ldapEntry{}, errors.New("failed to parse the attributes of an LDAP search result entry")
+	}
+	for _, attribute := range attributes {
+		typeAndValues, err := attribute.children()
+		if err != nil || len(typeAndValues) < 2 {
+			return ldapEntry{}, errors.New("failed to parse an attribute of an LDAP search result entry")
+		}
+		values, err := typeAndValues[1].children()
+		if err != nil {
+			return ldapEntry{}, errors.New("failed to parse the values of an LDAP attribute")
+		}
+		name := string(typeAndValues[0].content)
+		for _, value := range values {
+			entry.attributes[name] = append(entry.attributes[name], string(value.content))
+		}
+	}
+	return entry, nil
+}
+
+// ldapResultError returns the error of an LDAPResult that is not a
+// success
+func ldapResultError(operation string, response berElement) error {
+	elements, err := response.children()
+	if err != nil || len(elements) < 3 {
+		return fmt.Errorf("failed to parse the LDAP %s result", operation)
+	}
+	resultCode := berInteger(elements[0].content)
+	if resultCode != ldapResultSuccess {
+		return fmt.Errorf("LDAP %s failed with result code %d: %s", operation, resultCode, string(elements[2].content))
+	}
+	return nil
+}
+
+// ldapMessage wraps a protocol operation in an LDAPMessage
+func ldapMessage(messageID int, protocolOp []byte) []byte {
+	return berElementBytes(berTagSequence, concatBytes(berIntegerBytes(berTagInteger, messageID), protocolOp))
+}
+
+// readLDAPResponse reads the next LDAPMessage and returns its protocol
+// operation, which must answer the message ID
+func readLDAPResponse(reader *bufio.Reader, messageID int) (berElement, error) {
+	message, err := readBERElement(reader)
+	if err != nil {
+		return berElement{}, err
+	}
+	elements, err := message.children()
+	if err != nil || len(elements) < 2 || elements[0].tag != berTagInteger {
+		return berElement{}, errors.New("malformed LDAP message")
+	}
+	if id := berInteger(elements[0].content); id != messageID {
+		return berElement{}, fmt.Errorf("LDAP message %d answers %d", id, messageID)
+	}
+	return elements[1], nil
+}
+
+// berElement is a BER encoded element with a single byte tag
+type berElement struct {
+	tag     byte
+	content []byte
+}
+
+// children parses the content of a constructed element into its elements
+func (element berElement) children() ([]berElement, error) {
+	reader := bufio.NewReader(bytes.NewReader(element.content))
+	children := []berElement{}
+	for {
+		child, err := readBERElement(reader)
+		if errors.Is(err, io.EOF) {
+			return children, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+}
+
+// readBERElement reads an element with a definite length
+func readBERElement(reader *bufio.Reader) (berElement, error) {
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	lengthByte, err := reader.ReadByte()
+	if err != nil {
+		return berElement{}, io.ErrUnexpectedEOF
+	}
+	length := int(lengthByte)
+	if lengthByte&0x80 != 0 {
+		lengthBytes := int(lengthByte & 0x7f)
+		if lengthBytes == 0 || lengthBytes > 4 {
+			return berElement{}, errors.New("unsupported BER length")
+		}
+		length = 0
+		for i := 0; i < lengthBytes; i++ {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return berElement{}, io.ErrUnexpectedEOF
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return berElement{}, io.ErrUnexpectedEOF
+	}
+	return berElement{tag: tag, content: content}, nil
+}
+
+// berElementBytes encodes an element with its definite length
+func berElementBytes(tag byte, content []byte) []byte {
+	length := len(content)
+	encoded := []byte{tag}
+	if length < 0x80 {
+		encoded = append(encoded, byte(length))
+	} else {
+		lengthBytes := []byte{}
+		for ; length > 0; length >>= 8 {
+			lengthBytes = append([]byte{byte(length)}, lengthBytes...)
+		}
+		encoded = append(encoded, 0x80|byte(len(lengthBytes)))
+		encoded = append(encoded, lengthBytes...)
+	}
+	return append(encoded, content...)
+}
+
+// berIntegerBytes encodes an integer that is not negative
+func berIntegerBytes(tag byte, value int) []byte {
+	content := []byte{byte(value)}
+	for value >>= 8; value > 0; value >>= 8 {
+		content = append([]byte{byte(value)}, content...)
+	}
+	if content[0]&0x80 != 0 {
+		content = append([]byte{0}, content...)
+	}
+	return berElementBytes(tag, content)
+}
+
+// berInteger decodes the content of an integer that is not negative
+func berInteger(content []byte) int {
+	value := 0
+	for _, b := range content {
+		value = value<<8 | int(b)
+	}
+	return value
+}
+
+// concatBytes joins encoded elements
+func concatBytes(elements ...[]byte) []byte {
+	joined := []byte{}
+	for _, element := range elements {
+		joined = append(joined, element...)
+	}
+	return joined
+}
@@ -0,0 +1,154 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const berTagSet = 0x31
+
+// fakeLDAPServer answers binds with the password, and searches with the
+// entries whose badgeID is the card ID. It returns the ldap:// URL of the
+// server.
+func fakeLDAPServer(t *testing.T, password string, entries map[string][]map[string]string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	ldapResult := func(tag byte, resultCode int) []byte {
+		return berElementBytes(tag, concatBytes(
+			berIntegerBytes(berTagEnumerated, resultCode),
+			berElementBytes(berTagOctetString, nil),
+			berElementBytes(berTagOctetString, nil),
+		))
+	}
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			message, err := readBERElement(reader)
+			if err != nil {
+				return
+			}
+			elements, err := message.children()
+			if err != nil || len(elements) < 2 {
+				return
+			}
+			messageID := berInteger(elements[0].content)
+			request, _ := elements[1].children()
+			switch elements[1].tag {
+			case ldapTagBindRequest:
+				resultCode := 0
+				if string(request[2].content) != password {
+					// invalidCredentials
+					resultCode = 49
+				}
+				_, _ = conn.Write(ldapMessage(messageID, ldapResult(ldapTagBindResponse, resultCode)))
+			case ldapTagSearchRequest:
+				filter, _ := request[6].children()
+				cardID := string(filter[1].content)
+				for i, attributes := range entries[cardID] {
+					encoded := []byte{}
+					for name, value := range attributes {
+						encoded = append(encoded, berElementBytes(berTagSequence, concatBytes(
+							berElementBytes(berTagOctetString, []byte(name)),
+							berElementBytes(berTagSet, berElementBytes(berTagOctetString, []byte(value))),
+						))...)
+					}
+					dn := "cn=holder" + string(rune('0'+i)) + ",ou=people,dc=example,dc=com"
+					_, _ = conn.Write(ldapMessage(messageID, berElementBytes(ldapTagSearchEntry, concatBytes(
+						berElementBytes(berTagOctetString, []byte(dn)),
+						berElementBytes(berTagSequence, encoded),
+					))))
+				}
+				_, _ = conn.Write(ldapMessage(messageID, ldapResult(ldapTagSearchDone, 0)))
+			default:
+				return
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return "ldap://" + listener.Addr().String()
+}
+
+func TestLDAPIdentityConnector(t *testing.T) {
+	url := fakeLDAPServer(t, "secret", map[string][]map[string]string{
+		"0009990001": {{"kioskRole": "stocker", "employeeNumber": "100", "costCenter": "10", "creditLimit": "25.5"}},
+		"0009990002": {{"kioskRole": "3", "employeeNumber": "101", "costCenter": "10"}, {"kioskRole": "1", "employeeNumber": "102", "costCenter": "11"}},
+		"0009990003": {{"kioskRole": "owner", "employeeNumber": "103", "costCenter": "10"}},
+	})
+	connector := &LDAPIdentityConnector{
+		URL:                  url,
+		BindDN:               "cn=kiosk,dc=example,dc=com",
+		BindPassword:         "secret",
+		BaseDN:               "ou=people,dc=example,dc=com",
+		CardAttribute:        "badgeID",
+		RoleAttribute:        "kioskRole",
+		PersonAttribute:      "employeeNumber",
+		AccountAttribute:     "costCenter",
+		CreditLimitAttribute: "creditLimit",
+	}
+	require.NoError(t, connector.validate())
+
+	identity, err := connector.LookupCard("0009990001")
+	require.NoError(t, err)
+	assert.Equal(t, Identity{CardID: "0009990001", RoleID: RoleStocker, PersonID: 100, AccountID: 10, CreditLimit: 25.5, IsActive: true}, identity)
+
+	_, err = connector.LookupCard("0009990009")
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+
+	_, err = connector.LookupCard("0009990002")
+	assert.ErrorContains(t, err, "matches 2 LDAP entries")
+	assert.NotErrorIs(t, err, ErrIdentityNotFound)
+
+	_, err = connector.LookupCard("0009990003")
+	assert.ErrorContains(t, err, "invalid kioskRole")
+
+	connector.BindPassword = "wrong"
+	_, err = connector.LookupCard("0009990001")
+	assert.ErrorContains(t, err, "result code 49")
+	assert.NotErrorIs(t, err, ErrIdentityNotFound)
+}
+
+func TestLDAPIdentityConnectorUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "ldap://" + listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	connector := &LDAPIdentityConnector{URL: url, BaseDN: "ou=people,dc=example,dc=com", CardAttribute: "badgeID", RoleAttribute: "kioskRole", PersonAttribute: "employeeNumber", AccountAttribute: "costCenter"}
+	_, err = connector.LookupCard("0009990001")
+	assert.ErrorContains(t, err, "failed to reach the LDAP directory")
+}
+
+func TestBERElementBytes(t *testing.T) {
+	content := make([]byte, 300)
+	encoded := berElementBytes(berTagOctetString, content)
+	assert.Equal(t, []byte{berTagOctetString, 0x82, 0x01, 0x2c}, encoded[:4])
+
+	element, err := readBERElement(bufio.NewReader(bytes.NewReader(encoded)))
+	require.NoError(t, err)
+	assert.Equal(t, content, element.content)
+
+	for _, value := range []int{0, 127, 128, 300, 70000} {
+		integer, err := readBERElement(bufio.NewReader(bytes.NewReader(berIntegerBytes(berTagInteger, value))))
+		require.NoError(t, err)
+		assert.Equal(t, value, berInteger(integer.content))
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultFailedSwipeWindow is how long a failed swipe counts towards a
+// lockout, when no window is configured
+const defaultFailedSwipeWindow = 5 * time.Minute
+
+// CardLock is the lockout state of a card. FailedSwipes are the failed swipes
+// within the window, and LockedAt is when the card was locked.
+type CardLock struct {
+	CardID       string `json:"cardID"`
+	Locked       bool   `json:"locked"`
+	FailedSwipes int    `json:"failedSwipes"`
+	LockedAt     int64  `json:"lockedAt,string,omitempty"`
+}
+
+// SwipeGuard locks a card after too many failed swipes within a window, until
+// an admin unlocks it, and rejects a card swiped again within the
+// anti-passback interval of its last successful swipe, so that one card
+// cannot let several people in. The state is kept in memory. A nil
+// SwipeGuard allows every swipe.
+type SwipeGuard struct {
+	mutex           sync.Mutex
+	maxFailedSwipes int
+	window          time.Duration
+	passback        time.Duration
+	failures        map[string][]time.Time
+	locked          map[string]time.Time
+	lastSwipe       map[string]time.Time
+}
+
+// NewSwipeGuard creates a SwipeGuard that locks a card after maxFailedSwipes
+// failed swipes within the window, and rejects swipes within the passback
+// interval. Zero maxFailedSwipes disables the lockout and zero passback the
+// anti-passback, and it is nil when both are disabled.
+func NewSwipeGuard(maxFailedSwipes int, window time.Duration, passback time.Duration) *SwipeGuard {
+	if maxFailedSwipes <= 0 && passback <= 0 {
+		return nil
+	}
+	if window <= 0 {
+		window = defaultFailedSwipeWindow
+	}
+	return &SwipeGuard{
+		maxFailedSwipes: maxFailedSwipes,
+		window:          window,
+		passback:        passback,
+		failures:        make(map[string][]time.Time),
+		locked:          make(map[string]time.Time),
+		lastSwipe:       make(map[string]time.Time),
+	}
+}
+
+// ParseSwipeDuration parses a duration of the swipe rules, empty is the
+// default duration
+func ParseSwipeDuration(name string, duration string, defaultDuration time.Duration) (time.Duration, error) {
+	if duration == "" {
+		return defaultDuration, nil
+	}
+	parsed, err := time.ParseDuration(duration)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s %q must be a duration that is not negative", name, duration)
+	}
+	return parsed, nil
+}
+
+// Locked returns whether the card is locked
+func (guard *SwipeGuard) Locked(cardID string) bool {
+	if guard == nil {
+		return false
+	}
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	_, locked := guard.locked[cardID]
+	return locked
+}
+
+// Failed records a failed swipe of the card at now, and returns whether the
+// card is locked by it
+func (guard *SwipeGuard) Failed(cardID string, now time.Time) bool {
+	if guard == nil || guard.maxFailedSwipes <= 0 {
+		return false
+	}
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	failures := append(guard.recentFailures(cardID, now), now)
+	guard.failures[cardID] = failures
+	if len(failures) < guard.maxFailedSwipes {
+		return false
+	}
+	if _, locked := guard.locked[cardID]; !locked {
+		guard.locked[cardID] = now
+	}
+	return true
+}
+
+// Admit records a successful swipe of the card at now, and returns false
+// when the card was already swiped within the anti-passback interval
+func (guard *SwipeGuard) Admit(cardID string, now time.Time) bool {
+	if guard == nil {
+		return true
+	}
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	if last, ok := guard.lastSwipe[cardID]; ok && guard.passback > 0 && now.Sub(last) < guard.passback {
+		return false
+	}
+	guard.lastSwipe[cardID] = now
+	delete(guard.failures, cardID)
+	return true
+}
+
+// State returns the lockout state of the card at now
+func (guard *SwipeGuard) State(cardID string, now time.Time) CardLock {
+	state := CardLock{CardID: cardID}
+	if guard == nil {
+		return state
+	}
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	state.FailedSwipes = len(guard.recentFailures(cardID, now))
+	if lockedAt, locked := guard.locked[cardID]; locked {
+		state.Locked = true
+		state.LockedAt = lockedAt.UnixNano()
+	}
+	return state
+}
+
+// Unlock unlocks the card and forgets its failed swipes, and returns false
+// when it was not locked
+func (guard *SwipeGuard) Unlock(cardID string) bool {
+	if guard == nil {
+		return false
+	}
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	if _, locked := guard.locked[cardID]; !locked {
+		return false
+	}
+	delete(guard.locked, cardID)
+	delete(guard.failures, cardID)
+	return true
+}
+
+// recentFailures returns the failed swipes of the card within the window
+// before now. The mutex must be held.
+func (guard *SwipeGuard) recentFailures(cardID string, now time.Time) []time.Time {
+	recent := []time.Time{}
+	for _, failedAt := range guard.failures[cardID] {
+		if now.Sub(failedAt) < guard.window {
+			recent = append(recent, failedAt)
+		}
+	}
+	return recent
+}
+
+// CardLockGet returns the lockout state of the card in the form:
+// /cards/0003278380/lock
+func (c *Controller) CardLockGet(writer http.ResponseWriter, req *http.Request) {
+	c.writeJSON(writer, c.swipeGuard.State(mux.Vars(req)["cardid"], time.Now()))
+}
+
+// CardLockDelete unlocks a card that was locked after too many failed
+// swipes, and returns its lockout state. A card that is not locked returns
+// status code 404.
+func (c *Controller) CardLockDelete(writer http.ResponseWriter, req *http.Request) {
+	cardID := mux.Vars(req)["cardid"]
+	if !c.swipeGuard.Unlock(cardID) {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Card %s is not locked", cardID))
+		return
+	}
+	c.lc.Infof("Card %s was unlocked", cardID)
+	c.writeJSON(writer, c.swipeGuard.State(cardID, time.Now()))
+}
+
+// requireAdmin wraps a route handler to only serve requests with the token
+// of an admin card, which this service signed. Without a token signer the
+// route is open.
+func (c *Controller) requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	if c.tokenSigner == nil {
+		return handler
+	}
+	return func(writer http.ResponseWriter, req *http.Request) {
+		claims, err := c.tokenSigner.Verify(req.Header.Get("Authorization"))
+		if err != nil {
+			c.lc.Infof("Rejected %s %s: %s", req.Method, req.URL.Path, err.Error())
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writer.WriteHeader(http.StatusUnauthorized)
+			writer.Write([]byte(err.Error()))
+			return
+		}
+		if claims.RoleID != RoleAdmin {
+			c.lc.Infof("Rejected %s %s: person %d is not an admin", req.Method, req.URL.Path, claims.PersonID)
+			writer.WriteHeader(http.StatusForbidden)
+			writer.Write([]byte("an admin token is required"))
+			return
+		}
+		handler(writer, req)
+	}
+}
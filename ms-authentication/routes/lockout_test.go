@@ -0,0 +1,185 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwipeGuardLockout(t *testing.T) {
+	guard := NewSwipeGuard(3, time.Minute, 0)
+	now := time.Now()
+
+	// failed swipes outside of the window do not count
+	assert.False(t, guard.Failed("0001230004", now))
+	assert.False(t, guard.Failed("0001230004", now.Add(time.Minute)))
+	assert.False(t, guard.Failed("0001230004", now.Add(90*time.Second)))
+	assert.Equal(t, 2, guard.State("0001230004", now.Add(90*time.Second)).FailedSwipes)
+	assert.True(t, guard.Failed("0001230004", now.Add(100*time.Second)))
+	assert.True(t, guard.Locked("0001230004"))
+	assert.False(t, guard.Locked("0001230001"), "other cards are not locked")
+
+	state := guard.State("0001230004", now.Add(100*time.Second))
+	assert.True(t, state.Locked)
+	assert.Equal(t, now.Add(100*time.Second).UnixNano(), state.LockedAt)
+
+	// a locked card stays locked after the window, until it is unlocked
+	assert.True(t, guard.Locked("0001230004"))
+	assert.True(t, guard.Unlock("0001230004"))
+	assert.False(t, guard.Unlock("0001230004"))
+	assert.Equal(t, CardLock{CardID: "0001230004"}, guard.State("0001230004", now.Add(100*time.Second)))
+
+	// a successful swipe forgets the failed ones
+	assert.False(t, guard.Failed("0001230001", now))
+	assert.True(t, guard.Admit("0001230001", now))
+	assert.Equal(t, 0, guard.State("0001230001", now).FailedSwipes)
+}
+
+func TestSwipeGuardAntiPassback(t *testing.T) {
+	guard := NewSwipeGuard(0, 0, 30*time.Second)
+	now := time.Now()
+	assert.True(t, guard.Admit("0001230001", now))
+	assert.False(t, guard.Admit("0001230001", now.Add(10*time.Second)))
+	assert.True(t, guard.Admit("0001230002", now.Add(10*time.Second)), "other cards are not held back")
+	assert.True(t, guard.Admit("0001230001", now.Add(30*time.Second)))
+
+	// the lockout is disabled
+	assert.False(t, guard.Failed("0001230004", now))
+	assert.False(t, guard.Failed("0001230004", now))
+	assert.False(t, guard.Locked("0001230004"))
+
+	// without rules every swipe is allowed
+	var disabled *SwipeGuard
+	assert.Nil(t, NewSwipeGuard(0, time.Minute, 0))
+	assert.True(t, disabled.Admit("0001230001", now))
+	assert.True(t, disabled.Admit("0001230001", now))
+	assert.False(t, disabled.Failed("0001230001", now))
+	assert.False(t, disabled.Unlock("0001230001"))
+}
+
+func TestParseSwipeDuration(t *testing.T) {
+	duration, err := ParseSwipeDuration("AntiPassbackInterval", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), duration)
+	duration, err = ParseSwipeDuration("FailedSwipeWindow", "10m", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, duration)
+	_, err = ParseSwipeDuration("FailedSwipeWindow", "-1m", 0)
+	assert.Error(t, err)
+	_, err = ParseSwipeDuration("FailedSwipeWindow", "often", 0)
+	assert.Error(t, err)
+}
+
+// TestAuthenticationGetLockout tests that a card is locked after too many
+// failed swipes, for authorization checks too, until it is unlocked
+func TestAuthenticationGetLockout(t *testing.T) {
+	c := newStoreTestController(t)
+	c.swipeGuard = NewSwipeGuard(2, time.Minute, 0)
+	swipe := func(cardID string) int {
+		return storeRequest(c.AuthenticationGet, http.MethodGet, "http://localhost:48096/authentication/"+cardID, map[string]string{"cardid": cardID}, "").Code
+	}
+
+	// the invalid card is refused, and then locked
+	assert.Equal(t, http.StatusUnauthorized, swipe("0001230004"))
+	assert.Equal(t, http.StatusUnauthorized, swipe("0001230004"))
+	assert.Equal(t, http.StatusLocked, swipe("0001230004"))
+	assert.Equal(t, http.StatusOK, swipe("0001230001"), "other cards are not locked")
+
+	// a card that is not enrolled is locked too
+	assert.Equal(t, http.StatusUnauthorized, swipe("0001239999"))
+	assert.Equal(t, http.StatusUnauthorized, swipe("0001239999"))
+	assert.Equal(t, http.StatusLocked, swipe("0001239999"))
+
+	w := storeRequest(c.CardLockGet, http.MethodGet, "http://localhost:48096/cards/0001230004/lock", map[string]string{"cardid": "0001230004"}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var state CardLock
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.True(t, state.Locked)
+	assert.Equal(t, 2, state.FailedSwipes)
+
+	// the card is made valid, but stays locked until it is unlocked
+	cards := setupCards()
+	cards.Cards[3].IsValid = true
+	cards.Cards[3].PersonID = 1
+	require.NoError(t, c.store.SaveCards(cards))
+	assert.Equal(t, http.StatusLocked, swipe("0001230004"))
+	w = storeRequest(c.AuthorizationGet, http.MethodGet, "http://localhost:48096/authorization/0001230004/vend", map[string]string{"cardid": "0001230004", "action": ActionVend}, "")
+	assert.Equal(t, http.StatusLocked, w.Code)
+
+	w = storeRequest(c.CardLockDelete, http.MethodDelete, "http://localhost:48096/cards/0001230004/lock", map[string]string{"cardid": "0001230004"}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.False(t, state.Locked)
+	assert.Equal(t, http.StatusOK, swipe("0001230004"))
+
+	w = storeRequest(c.CardLockDelete, http.MethodDelete, "http://localhost:48096/cards/0001230004/lock", map[string]string{"cardid": "0001230004"}, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAuthenticationGetAntiPassback(t *testing.T) {
+	c := newStoreTestController(t)
+	c.swipeGuard = NewSwipeGuard(0, 0, time.Minute)
+	swipe := func(cardID string) int {
+		return storeRequest(c.AuthenticationGet, http.MethodGet, "http://localhost:48096/authentication/"+cardID, map[string]string{"cardid": cardID}, "").Code
+	}
+
+	assert.Equal(t, http.StatusOK, swipe("0001230001"))
+	assert.Equal(t, http.StatusTooManyRequests, swipe("0001230001"))
+
+	// the kiosk's authorization check right after the swipe is not held back
+	w := storeRequest(c.AuthorizationGet, http.MethodGet, "http://localhost:48096/authorization/0001230001/vend", map[string]string{"cardid": "0001230001", "action": ActionVend}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRequireAdmin(t *testing.T) {
+	c := newStoreTestController(t)
+	c.tokenSigner = NewTokenSigner("test-secret", time.Minute)
+	unlock := func(writer http.ResponseWriter, req *http.Request) {
+		writer.WriteHeader(http.StatusNoContent)
+	}
+	handler := c.requireAdmin(unlock)
+	sign := func(roleID int) string {
+		token, err := c.tokenSigner.Sign(AuthData{CardID: "0001230001", RoleID: roleID}, time.Now())
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		Name               string
+		Authorization      string
+		ExpectedStatusCode int
+	}{
+		{"admin", sign(RoleAdmin), http.StatusNoContent},
+		{"maintainer", sign(RoleMaintainer), http.StatusForbidden},
+		{"no token", "", http.StatusUnauthorized},
+		{"another secret", "Bearer " + func() string {
+			token, err := NewTokenSigner("another-secret", time.Minute).Sign(AuthData{RoleID: RoleAdmin}, time.Now())
+			require.NoError(t, err)
+			return token
+		}(), http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "http://localhost:48096/cards/0001230004/lock", nil)
+			req.Header.Set("Authorization", currentTest.Authorization)
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+		})
+	}
+
+	// without a token signer the route is open
+	c.tokenSigner = nil
+	w := httptest.NewRecorder()
+	c.requireAdmin(unlock)(w, httptest.NewRequest(http.MethodDelete, "http://localhost:48096/cards/0001230004/lock", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
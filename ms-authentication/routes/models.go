@@ -24,12 +24,18 @@ type Accounts struct {
 // take on a different role, they must use a different card with the desired
 // role
 type Card struct {
-	CardID    string `json:"cardID"`
-	RoleID    int    `json:"roleID"`
-	IsValid   bool   `json:"isValid"`
-	PersonID  int    `json:"personID"`
-	CreatedAt int64  `json:"createdAt,string"`
-	UpdatedAt int64  `json:"updatedAt,string"`
+	CardID      string `json:"cardID"`
+	RoleID      int    `json:"roleID"`
+	IsValid     bool   `json:"isValid"`
+	PersonID    int    `json:"personID"`
+	CreatedAt   int64  `json:"createdAt,string"`
+	UpdatedAt   int64  `json:"updatedAt,string"`
+	PinRequired bool   `json:"pinRequired,omitempty"` // the card needs its PIN after every swipe
+	PinSalt     string `json:"pinSalt,omitempty"`     // hex salt of the PIN hash
+	PinHash     string `json:"pinHash,omitempty"`     // hex PBKDF2 hash of the PIN
+	// Validity is when the card is authenticated, such as until it is lost
+	// and suspended
+	Validity
 }
 
 // Person contains person, account, and full name associations. A person
@@ -55,14 +61,22 @@ type Account struct {
 	CreatedAt        int64  `json:"createdAt,string"`
 	UpdatedAt        int64  `json:"updatedAt,string"`
 	IsActive         bool   `json:"isActive"`
+	// CreditLimit is the unpaid ledger balance above which the account may
+	// not open the vending machine. Zero means the account has no limit.
+	CreditLimit float64 `json:"creditLimit,omitempty"`
+	// Validity is when the cards of the account's people are authenticated
+	Validity
 }
 
 // AuthData is what is expected to be sent back as a response when something
 // hits this endpoint. A card number is passed in, and this code will
 // resolve the card's corresponding role, person, and account
 type AuthData struct {
-	AccountID int    `json:"accountID"`
-	PersonID  int    `json:"personID"`
-	RoleID    int    `json:"roleID"`
-	CardID    string `json:"cardID"`
+	AccountID   int     `json:"accountID"`
+	PersonID    int     `json:"personID"`
+	RoleID      int     `json:"roleID"`
+	CardID      string  `json:"cardID"`
+	CreditLimit float64 `json:"creditLimit,omitempty"` // the account's credit limit, zero for none
+	Token       string  `json:"token,omitempty"`       // the signed authentication token, when tokens are configured
+	PinRequired bool    `json:"pinRequired,omitempty"` // the card's PIN must be verified before the door is unlocked
 }
@@ -0,0 +1,149 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// CardIDLength is the length of the card IDs enrolled in cards.json
+const CardIDLength = 10
+
+// cardIDTransform is a single step of a card ID format. It returns an error
+// when the card ID is not in the format, so that the format is skipped.
+type cardIDTransform func(cardID string) (string, error)
+
+// CardIDFormat is a chain of transforms that converts the card IDs emitted
+// by one kind of reader into the enrolled form, such as
+// "stripPrefix:0x radix:16:10 pad:10" for readers that emit hex IDs
+type CardIDFormat struct {
+	Name       string
+	transforms []cardIDTransform
+}
+
+// CardIDFormats are the reader formats that card IDs are normalized from,
+// so that one enrolled card works across mixed reader hardware
+type CardIDFormats []CardIDFormat
+
+// ParseCardIDFormats parses card ID formats, each a space separated chain
+// of these steps:
+//
+//	stripPrefix:<prefix> - removes the prefix, the format is skipped without it
+//	radix:<from>:<to>    - converts the ID from one base to another
+//	pad:<length>         - left pads the ID with zeros up to the length
+func ParseCardIDFormats(entries []string) (CardIDFormats, error) {
+	formats := CardIDFormats{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		format := CardIDFormat{Name: entry}
+		for _, step := range strings.Fields(entry) {
+			transform, err := parseCardIDTransform(step)
+			if err != nil {
+				return nil, fmt.Errorf("card ID format %q is not valid: %s", entry, err.Error())
+			}
+			format.transforms = append(format.transforms, transform)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+func parseCardIDTransform(step string) (cardIDTransform, error) {
+	name, args, _ := strings.Cut(step, ":")
+	switch name {
+	case "stripPrefix":
+		if args == "" {
+			return nil, fmt.Errorf("step %q is not in the stripPrefix:<prefix> format", step)
+		}
+		return stripPrefix(args), nil
+	case "radix":
+		fromStr, toStr, found := strings.Cut(args, ":")
+		from, fromErr := strconv.Atoi(fromStr)
+		to, toErr := strconv.Atoi(toStr)
+		if !found || fromErr != nil || toErr != nil {
+			return nil, fmt.Errorf("step %q is not in the radix:<from>:<to> format", step)
+		}
+		if from < 2 || from > 36 || to < 2 || to > 36 {
+			return nil, fmt.Errorf("step %q must use bases between 2 and 36", step)
+		}
+		return convertRadix(from, to), nil
+	case "pad":
+		length, err := strconv.Atoi(args)
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("step %q is not in the pad:<length> format", step)
+		}
+		return padZeros(length), nil
+	default:
+		return nil, fmt.Errorf("step %q is not one of stripPrefix, radix or pad", step)
+	}
+}
+
+func stripPrefix(prefix string) cardIDTransform {
+	return func(cardID string) (string, error) {
+		if len(cardID) < len(prefix) || !strings.EqualFold(cardID[:len(prefix)], prefix) {
+			return "", fmt.Errorf("card ID does not start with %s", prefix)
+		}
+		return cardID[len(prefix):], nil
+	}
+}
+
+func convertRadix(from int, to int) cardIDTransform {
+	return func(cardID string) (string, error) {
+		value, ok := new(big.Int).SetString(cardID, from)
+		if !ok || value.Sign() < 0 {
+			return "", fmt.Errorf("card ID is not a base %d number", from)
+		}
+		return strings.ToUpper(value.Text(to)), nil
+	}
+}
+
+func padZeros(length int) cardIDTransform {
+	return func(cardID string) (string, error) {
+		if len(cardID) >= length {
+			return cardID, nil
+		}
+		return strings.Repeat("0", length-len(cardID)) + cardID, nil
+	}
+}
+
+// Normalize runs the card ID through the format's transforms
+func (format CardIDFormat) Normalize(cardID string) (string, error) {
+	var err error
+	for _, transform := range format.transforms {
+		cardID, err = transform(cardID)
+		if err != nil {
+			return "", err
+		}
+	}
+	return cardID, nil
+}
+
+// Candidates returns the card ID as it was read followed by its normalized
+// form for every format that applies to it, without duplicates
+func (formats CardIDFormats) Candidates(cardID string) []string {
+	candidates := []string{cardID}
+	for _, format := range formats {
+		normalized, err := format.Normalize(cardID)
+		if err != nil {
+			continue
+		}
+		duplicate := false
+		for _, candidate := range candidates {
+			if candidate == normalized {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			candidates = append(candidates, normalized)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,86 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCardIDFormats(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Entries       []string
+		ExpectedCount int
+		ExpectedError bool
+	}{
+		{"No formats", nil, 0, false},
+		{"Empty entries", []string{"", " "}, 0, false},
+		{"Hex format", []string{"stripPrefix:0x radix:16:10 pad:10"}, 1, false},
+		{"Multiple formats", []string{"radix:16:10 pad:10", "stripPrefix:FC12"}, 2, false},
+		{"Unknown step", []string{"reverse"}, 0, true},
+		{"Missing prefix", []string{"stripPrefix"}, 0, true},
+		{"Invalid radix", []string{"radix:16"}, 0, true},
+		{"Radix out of range", []string{"radix:16:40"}, 0, true},
+		{"Invalid pad", []string{"pad:abc"}, 0, true},
+		{"Zero pad", []string{"pad:0"}, 0, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			formats, err := ParseCardIDFormats(currentTest.Entries)
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, formats, currentTest.ExpectedCount)
+		})
+	}
+}
+
+func TestCardIDFormatsCandidates(t *testing.T) {
+	formats, err := ParseCardIDFormats([]string{
+		"stripPrefix:0x radix:16:10 pad:10",
+		"stripPrefix:FC042- pad:10",
+		"pad:10",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name               string
+		CardID             string
+		ExpectedCandidates []string
+	}{
+		{"Enrolled form", "0001230001", []string{"0001230001"}},
+		{"Hex", "0x12C4B1", []string{"0x12C4B1", "0001230001", "000x12C4B1"}},
+		{"Lower case hex prefix", "0X12c4b1", []string{"0X12c4b1", "0001230001", "000X12c4b1"}},
+		{"Facility code", "FC042-1230001", []string{"FC042-1230001", "0001230001"}},
+		{"Decimal without leading zeros", "1230001", []string{"1230001", "0001230001"}},
+		{"Not hex", "0xZZZZ", []string{"0xZZZZ", "00000xZZZZ"}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			assert.Equal(t, currentTest.ExpectedCandidates, formats.Candidates(currentTest.CardID))
+		})
+	}
+}
+
+func TestCardIDFormatNormalize(t *testing.T) {
+	formats, err := ParseCardIDFormats([]string{"radix:10:16"})
+	require.NoError(t, err)
+	require.Len(t, formats, 1)
+
+	normalized, err := formats[0].Normalize("3278425")
+	require.NoError(t, err)
+	assert.Equal(t, "320659", normalized)
+
+	_, err = formats[0].Normalize("ABC")
+	assert.Error(t, err)
+}
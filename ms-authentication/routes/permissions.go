@@ -0,0 +1,112 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// The roles a card can have
+const (
+	RoleConsumer   = 1
+	RoleStocker    = 2
+	RoleMaintainer = 3
+	RoleTechnician = 4
+	RoleAdmin      = 5
+)
+
+// The actions a card can be authorized for
+const (
+	// ActionVend opens the door for a purchase charged to the account
+	ActionVend = "vend"
+	// ActionStock opens the door to restock the vending machine
+	ActionStock = "stock"
+	// ActionMaintain puts the vending machine in maintenance mode and
+	// clears its faults
+	ActionMaintain = "maintain"
+	// ActionTestVend opens the door for a zero-priced test vend
+	ActionTestVend = "testVend"
+	// ActionManageCards enrolls and changes cards, people and accounts
+	ActionManageCards = "manageCards"
+)
+
+// Actions are all the actions, in the order they are listed
+var Actions = []string{ActionVend, ActionStock, ActionMaintain, ActionTestVend, ActionManageCards}
+
+// rolePermissions are the actions each role is allowed. The admin role is
+// allowed every action.
+var rolePermissions = map[int][]string{
+	RoleConsumer:   {ActionVend},
+	RoleStocker:    {ActionStock},
+	RoleMaintainer: {ActionMaintain},
+	RoleTechnician: {ActionTestVend},
+	RoleAdmin:      Actions,
+}
+
+// Authorization is whether the card of CardID is allowed an action
+type Authorization struct {
+	CardID  string `json:"cardID"`
+	RoleID  int    `json:"roleID"`
+	Action  string `json:"action"`
+	Allowed bool   `json:"allowed"`
+}
+
+// IsRole checks whether roleID is one of the roles
+func IsRole(roleID int) bool {
+	_, ok := rolePermissions[roleID]
+	return ok
+}
+
+// IsAction checks whether action is one of the actions
+func IsAction(action string) bool {
+	for _, known := range Actions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleAllows checks whether the role is allowed the action
+func RoleAllows(roleID int, action string) bool {
+	for _, allowed := range rolePermissions[roleID] {
+		if action == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationGet accepts a card ID and an action in the form:
+// /authorization/0003278380/stock
+// It authenticates the card like AuthenticationGet, and returns whether its
+// role is allowed the action. A card that cannot be authenticated is never
+// allowed any action.
+func (c *Controller) AuthorizationGet(writer http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	action := vars["action"]
+	if !IsAction(action) {
+		c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Unknown action %q, the actions are %v", action, Actions))
+		return
+	}
+
+	authData, statusCode, errMsg := c.authenticate(vars["cardid"])
+	if statusCode != http.StatusOK {
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	authorization := Authorization{
+		CardID:  authData.CardID,
+		RoleID:  authData.RoleID,
+		Action:  action,
+		Allowed: RoleAllows(authData.RoleID, action),
+	}
+	c.lc.Infof("Card %s with role %d is allowed to %s: %v", authorization.CardID, authorization.RoleID, action, authorization.Allowed)
+	c.writeJSON(writer, authorization)
+}
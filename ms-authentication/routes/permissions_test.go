@@ -0,0 +1,70 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleAllows(t *testing.T) {
+	for _, action := range Actions {
+		assert.True(t, RoleAllows(RoleAdmin, action), "the admin role is allowed %s", action)
+	}
+	assert.True(t, RoleAllows(RoleStocker, ActionStock))
+	assert.False(t, RoleAllows(RoleStocker, ActionMaintain))
+	assert.True(t, RoleAllows(RoleMaintainer, ActionMaintain))
+	assert.False(t, RoleAllows(RoleConsumer, ActionStock))
+	assert.False(t, RoleAllows(0, ActionVend))
+	assert.False(t, IsRole(0))
+	assert.True(t, IsRole(RoleAdmin))
+}
+
+func TestAuthorizationGet(t *testing.T) {
+	tests := []struct {
+		Name               string
+		CardID             string
+		RoleID             int
+		Action             string
+		ExpectedStatusCode int
+		ExpectedAllowed    bool
+	}{
+		{"consumer vends", "0001230001", RoleConsumer, ActionVend, http.StatusOK, true},
+		{"consumer stocks", "0001230001", RoleConsumer, ActionStock, http.StatusOK, false},
+		{"stocker stocks", "0001230001", RoleStocker, ActionStock, http.StatusOK, true},
+		{"maintainer maintains", "0001230001", RoleMaintainer, ActionMaintain, http.StatusOK, true},
+		{"admin maintains", "0001230001", RoleAdmin, ActionMaintain, http.StatusOK, true},
+		{"stocker maintains", "0001230001", RoleStocker, ActionMaintain, http.StatusOK, false},
+		{"invalid card", "0001230004", RoleConsumer, ActionVend, http.StatusUnauthorized, false},
+		{"unknown card", "0001239999", RoleConsumer, ActionVend, http.StatusUnauthorized, false},
+		{"unknown action", "0001230001", RoleConsumer, "fly", http.StatusBadRequest, false},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t)
+			// the active card takes the role of the test
+			cards := setupCards()
+			cards.Cards[0].RoleID = currentTest.RoleID
+			require.NoError(t, c.store.SaveCards(cards))
+
+			w := storeRequest(c.AuthorizationGet, http.MethodGet, "http://localhost:48096/authorization/"+currentTest.CardID+"/"+currentTest.Action, map[string]string{"cardid": currentTest.CardID, "action": currentTest.Action}, "")
+			require.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var authorization Authorization
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &authorization))
+			assert.Equal(t, currentTest.CardID, authorization.CardID)
+			assert.Equal(t, currentTest.RoleID, authorization.RoleID)
+			assert.Equal(t, currentTest.Action, authorization.Action)
+			assert.Equal(t, currentTest.ExpectedAllowed, authorization.Allowed)
+		})
+	}
+}
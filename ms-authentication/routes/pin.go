@@ -0,0 +1,205 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// MinPinLength and MaxPinLength are how many digits a PIN has
+	MinPinLength = 4
+	MaxPinLength = 8
+
+	pinSaltLength     = 16
+	pinHashLength     = 32
+	pinHashIterations = 10000
+)
+
+// PinRequest sets the PIN of a card, or verifies the PIN entered for it
+type PinRequest struct {
+	CardID string `json:"cardID"`
+	Pin    string `json:"pin"`
+}
+
+// ValidatePin checks that the PIN is MinPinLength to MaxPinLength digits
+func ValidatePin(pin string) error {
+	if len(pin) < MinPinLength || len(pin) > MaxPinLength {
+		return fmt.Errorf("PIN must be %d to %d digits", MinPinLength, MaxPinLength)
+	}
+	for _, digit := range pin {
+		if digit < '0' || digit > '9' {
+			return fmt.Errorf("PIN must be %d to %d digits", MinPinLength, MaxPinLength)
+		}
+	}
+	return nil
+}
+
+// hashPin derives the hash of the PIN with the salt
+func hashPin(pin string, salt []byte) []byte {
+	return pbkdf2.Key([]byte(pin), salt, pinHashIterations, pinHashLength, sha256.New)
+}
+
+// SetPin stores the salted hash of the PIN on the card, and requires it
+// after every swipe
+func (card *Card) SetPin(pin string) error {
+	if err := ValidatePin(pin); err != nil {
+		return err
+	}
+	salt := make([]byte, pinSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate the PIN salt: %s", err.Error())
+	}
+	card.PinSalt = hex.EncodeToString(salt)
+	card.PinHash = hex.EncodeToString(hashPin(pin, salt))
+	card.PinRequired = true
+	return nil
+}
+
+// VerifyPin returns whether the PIN matches the card's PIN. A card without
+// a PIN matches none.
+func (card Card) VerifyPin(pin string) bool {
+	salt, err := hex.DecodeString(card.PinSalt)
+	if err != nil || len(salt) == 0 {
+		return false
+	}
+	hash, err := hex.DecodeString(card.PinHash)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hashPin(pin, salt), hash) == 1
+}
+
+// withoutPinHash returns the card without its PIN salt and hash, which are
+// never returned
+func (card Card) withoutPinHash() Card {
+	card.PinSalt = ""
+	card.PinHash = ""
+	return card
+}
+
+// CardPinPut sets the PIN of the card ID in the URL, which the card then
+// needs after every swipe. The body is a PinRequest.
+func (c *Controller) CardPinPut(writer http.ResponseWriter, req *http.Request) {
+	c.updateCardPin(writer, req, func(card *Card, request PinRequest) error {
+		return card.SetPin(request.Pin)
+	})
+}
+
+// CardPinDelete removes the PIN of the card ID in the URL, which the card
+// then no longer needs
+func (c *Controller) CardPinDelete(writer http.ResponseWriter, req *http.Request) {
+	c.updateCardPin(writer, req, func(card *Card, request PinRequest) error {
+		card.PinRequired = false
+		card.PinSalt = ""
+		card.PinHash = ""
+		return nil
+	})
+}
+
+// updateCardPin changes the PIN of the card ID in the URL with update, and
+// returns the updated card
+func (c *Controller) updateCardPin(writer http.ResponseWriter, req *http.Request, update func(*Card, PinRequest) error) {
+	cardID := mux.Vars(req)["cardid"]
+	var request PinRequest
+	if req.Method == http.MethodPut {
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal PIN: "+err.Error())
+			return
+		}
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	index := -1
+	for i := range cards.Cards {
+		if cards.Cards[i].CardID == cardID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Card %s is not enrolled", cardID))
+		return
+	}
+
+	card := cards.Cards[index]
+	if err := update(&card, request); err != nil {
+		c.writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	card.UpdatedAt = time.Now().UnixNano()
+	cards.Cards[index] = card
+	if err := c.store.SaveCards(cards); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write cards data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Updated the PIN of card %s, PIN required: %v", card.CardID, card.PinRequired)
+	c.writeJSON(writer, card.withoutPinHash())
+}
+
+// VerifyPinPost verifies the PIN entered for a card that was swiped, and
+// returns its AuthData, with the token that AuthenticationGet withholds
+// from cards that need a PIN. A wrong PIN is a failed swipe of the card.
+func (c *Controller) VerifyPinPost(writer http.ResponseWriter, req *http.Request) {
+	var request PinRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal PIN: "+err.Error())
+		return
+	}
+
+	now := time.Now()
+	authData, statusCode, errMsg := c.authenticate(request.CardID)
+	if statusCode != http.StatusOK {
+		if statusCode == http.StatusUnauthorized {
+			c.swipeGuard.Failed(authData.CardID, now)
+		}
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	card := cards.GetCardByCardID(authData.CardID)
+	if !card.PinRequired || !card.VerifyPin(request.Pin) {
+		if c.swipeGuard.Failed(card.CardID, now) {
+			c.lc.Infof("Card ID: %s is locked after too many failed swipes", card.CardID)
+		}
+		c.lc.Infof("Card ID: %s was given a wrong PIN", card.CardID)
+		writer.WriteHeader(http.StatusUnauthorized)
+		writer.Write([]byte("PIN is not correct"))
+		return
+	}
+
+	if c.tokenSigner != nil {
+		token, err := c.tokenSigner.Sign(authData, now)
+		if err != nil {
+			c.writeError(writer, http.StatusInternalServerError, "failed to sign authentication token")
+			return
+		}
+		authData.Token = token
+	}
+	c.lc.Infof("Verified the PIN of card %s", card.CardID)
+	c.writeJSON(writer, authData)
+}
@@ -0,0 +1,139 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePin(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Pin   string
+		Valid bool
+	}{
+		{"four digits", "1234", true},
+		{"eight digits", "12345678", true},
+		{"too short", "123", false},
+		{"too long", "123456789", false},
+		{"not digits", "12a4", false},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			err := ValidatePin(currentTest.Pin)
+			if currentTest.Valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestCardSetPin(t *testing.T) {
+	card := Card{CardID: "0001230001"}
+	assert.False(t, card.VerifyPin("1234"), "a card without a PIN matches none")
+
+	require.NoError(t, card.SetPin("1234"))
+	assert.True(t, card.PinRequired)
+	assert.NotEmpty(t, card.PinSalt)
+	assert.NotContains(t, card.PinHash, "1234")
+	assert.True(t, card.VerifyPin("1234"))
+	assert.False(t, card.VerifyPin("4321"))
+
+	// the same PIN is salted differently on another card
+	other := Card{CardID: "0001230002"}
+	require.NoError(t, other.SetPin("1234"))
+	assert.NotEqual(t, card.PinHash, other.PinHash)
+
+	assert.Error(t, card.SetPin("12"))
+	assert.True(t, card.VerifyPin("1234"), "an invalid PIN keeps the PIN")
+
+	hidden := card.withoutPinHash()
+	assert.True(t, hidden.PinRequired)
+	assert.Empty(t, hidden.PinSalt)
+	assert.Empty(t, hidden.PinHash)
+}
+
+// TestVerifyPinPost tests that a card that needs a PIN only gets its token
+// once the PIN is verified, and that wrong PINs lock the card
+func TestVerifyPinPost(t *testing.T) {
+	c := newStoreTestController(t)
+	c.tokenSigner = NewTokenSigner("test-secret", time.Minute)
+	c.swipeGuard = NewSwipeGuard(2, time.Minute, 0)
+	verify := func(cardID string, pin string) (int, AuthData) {
+		w := storeRequest(c.VerifyPinPost, http.MethodPost, "http://localhost:48096/authentication/verify-pin", nil, `{"cardID":"`+cardID+`","pin":"`+pin+`"}`)
+		var authData AuthData
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &authData))
+		}
+		return w.Code, authData
+	}
+
+	w := storeRequest(c.CardPinPut, http.MethodPut, "http://localhost:48096/cards/0001230001/pin", map[string]string{"cardid": "0001230001"}, `{"pin":"12"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = storeRequest(c.CardPinPut, http.MethodPut, "http://localhost:48096/cards/0001239999/pin", map[string]string{"cardid": "0001239999"}, `{"pin":"1234"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// a card without a PIN cannot verify one
+	statusCode, _ := verify("0001230001", "1234")
+	assert.Equal(t, http.StatusUnauthorized, statusCode)
+	assert.Equal(t, 1, c.swipeGuard.State("0001230001", time.Now()).FailedSwipes)
+
+	w = storeRequest(c.CardPinPut, http.MethodPut, "http://localhost:48096/cards/0001230001/pin", map[string]string{"cardid": "0001230001"}, `{"pin":"1234"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var card Card
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &card))
+	assert.True(t, card.PinRequired)
+	assert.Empty(t, card.PinHash, "the PIN hash is never returned")
+
+	// the swipe withholds the token until the PIN is verified
+	w = storeRequest(c.AuthenticationGet, http.MethodGet, "http://localhost:48096/authentication/0001230001", map[string]string{"cardid": "0001230001"}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var authData AuthData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &authData))
+	assert.True(t, authData.PinRequired)
+	assert.Empty(t, authData.Token)
+
+	statusCode, authData = verify("0001230001", "1234")
+	require.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, 1, authData.AccountID)
+	assert.NotEmpty(t, authData.Token)
+
+	// updating the card keeps its PIN
+	w = storeRequest(c.CardPut, http.MethodPut, "http://localhost:48096/cards/0001230001", map[string]string{"cardid": "0001230001"}, `{"cardID":"0001230001","roleID":1,"isValid":true,"personID":1}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	statusCode, _ = verify("0001230001", "1234")
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	// wrong PINs lock the card
+	statusCode, _ = verify("0001230001", "4321")
+	assert.Equal(t, http.StatusUnauthorized, statusCode)
+	statusCode, _ = verify("0001230001", "4321")
+	assert.Equal(t, http.StatusUnauthorized, statusCode)
+	statusCode, _ = verify("0001230001", "1234")
+	assert.Equal(t, http.StatusLocked, statusCode)
+	require.True(t, c.swipeGuard.Unlock("0001230001"))
+
+	// an invalid card is refused whatever the PIN
+	statusCode, _ = verify("0001230004", "1234")
+	assert.Equal(t, http.StatusUnauthorized, statusCode)
+
+	w = storeRequest(c.CardPinDelete, http.MethodDelete, "http://localhost:48096/cards/0001230001/pin", map[string]string{"cardid": "0001230001"}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = storeRequest(c.AuthenticationGet, http.MethodGet, "http://localhost:48096/authentication/0001230001", map[string]string{"cardid": "0001230001"}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var withoutPin AuthData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &withoutPin))
+	assert.False(t, withoutPin.PinRequired)
+	assert.NotEmpty(t, withoutPin.Token)
+}
@@ -0,0 +1,188 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// QRCodePrefix starts the payload of a QR code, so that other QR codes
+	// shown to the kiosk camera are told apart without checking a signature
+	QRCodePrefix = "acqr1:"
+	// qrCodeAudience keeps QR codes and authentication tokens from being
+	// used for each other
+	qrCodeAudience = "kiosk"
+
+	defaultQRCodeTTL = 2 * time.Minute
+)
+
+// QRCode is a signed, time-limited code that authenticates a card holder
+// at the kiosk camera in place of their card. Its payload is QRCodePrefix
+// followed by a JWT signed with HS256, whose subject is the card ID and
+// whose ID is a nonce, so that each code is used once.
+type QRCode struct {
+	CardID    string `json:"cardID"`
+	Payload   string `json:"payload"`
+	ExpiresAt int64  `json:"expiresAt,string"`
+}
+
+// QRCodeRequest is the body of a QR code authentication, the payload that
+// the kiosk camera decoded
+type QRCodeRequest struct {
+	Payload string `json:"payload"`
+}
+
+// QRCodeSigner issues and verifies the QR codes of the cards. The nonces of
+// the codes that were used are kept until the codes expire. A nil
+// QRCodeSigner issues no QR codes.
+type QRCodeSigner struct {
+	secret []byte
+	ttl    time.Duration
+	mutex  sync.Mutex
+	// used maps the nonce of each used QR code to when it expires
+	used map[string]int64
+}
+
+// NewQRCodeSigner creates a QRCodeSigner for the secret, or nil when the
+// secret is empty
+func NewQRCodeSigner(secret string, ttl time.Duration) *QRCodeSigner {
+	if secret == "" {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = defaultQRCodeTTL
+	}
+	return &QRCodeSigner{secret: []byte(secret), ttl: ttl, used: map[string]int64{}}
+}
+
+// ParseQRCodeTTL parses how long QR codes are valid, empty is the default
+// TTL
+func ParseQRCodeTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return defaultQRCodeTTL, nil
+	}
+	duration, err := time.ParseDuration(ttl)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("QR code TTL %q must be a positive duration", ttl)
+	}
+	return duration, nil
+}
+
+// Issue creates a QR code for the card, valid from now for the TTL
+func (signer *QRCodeSigner) Issue(cardID string, now time.Time) (QRCode, error) {
+	if signer == nil {
+		return QRCode{}, errors.New("QR codes are not configured")
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return QRCode{}, fmt.Errorf("failed to create a nonce: %s", err.Error())
+	}
+	claims := jwt.StandardClaims{
+		Audience:  qrCodeAudience,
+		Id:        hex.EncodeToString(nonce),
+		Issuer:    TokenIssuer,
+		Subject:   cardID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(signer.ttl).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(signer.secret)
+	if err != nil {
+		return QRCode{}, err
+	}
+	return QRCode{CardID: cardID, Payload: QRCodePrefix + token, ExpiresAt: claims.ExpiresAt}, nil
+}
+
+// Verify returns the card ID of the QR code's payload, and an error when it
+// is not a QR code signed by this service, has expired or was already used
+func (signer *QRCodeSigner) Verify(payload string, now time.Time) (string, error) {
+	token := strings.TrimPrefix(payload, QRCodePrefix)
+	if token == payload {
+		return "", errors.New("the payload is not a kiosk QR code")
+	}
+	var claims jwt.StandardClaims
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return signer.secret, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("the QR code is not valid: %s", err.Error())
+	}
+	if claims.Issuer != TokenIssuer || !claims.VerifyAudience(qrCodeAudience, true) || claims.Id == "" || claims.Subject == "" {
+		return "", errors.New("the QR code is not valid: it is not a kiosk QR code of this service")
+	}
+	if claims.ExpiresAt == 0 || now.Unix() > claims.ExpiresAt {
+		return "", errors.New("the QR code has expired")
+	}
+
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+	for nonce, expiresAt := range signer.used {
+		if now.Unix() > expiresAt {
+			delete(signer.used, nonce)
+		}
+	}
+	if _, used := signer.used[claims.Id]; used {
+		return "", errors.New("the QR code was already used")
+	}
+	signer.used[claims.Id] = claims.ExpiresAt
+	return claims.Subject, nil
+}
+
+// QRCodePost issues a QR code for the card ID in the URL, which a mobile
+// app shows to the kiosk camera to open the door. Whether the card may
+// open the door is checked when the code is used.
+func (c *Controller) QRCodePost(writer http.ResponseWriter, req *http.Request) {
+	if c.qrCodeSigner == nil {
+		c.writeError(writer, http.StatusServiceUnavailable, "QR codes are not configured, set QRCodeSecret")
+		return
+	}
+	cardID := mux.Vars(req)["cardid"]
+	if len(cardID) != CardIDLength {
+		c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("cardID must be %d characters", CardIDLength))
+		return
+	}
+	qrCode, err := c.qrCodeSigner.Issue(cardID, time.Now())
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, fmt.Sprintf("Failed to issue a QR code for card %s: %s", cardID, err.Error()))
+		return
+	}
+	c.lc.Infof("Issued a QR code for card %s", cardID)
+	c.writeJSON(writer, qrCode)
+}
+
+// AuthenticationQRPost authenticates the card of the QR code payload that
+// the kiosk camera decoded, and responds as AuthenticationGet does for the
+// card. A payload that is not valid is unauthorized.
+func (c *Controller) AuthenticationQRPost(writer http.ResponseWriter, req *http.Request) {
+	if c.qrCodeSigner == nil {
+		c.writeError(writer, http.StatusServiceUnavailable, "QR codes are not configured, set QRCodeSecret")
+		return
+	}
+	var request QRCodeRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "failed to parse the QR code request: "+err.Error())
+		return
+	}
+	cardID, err := c.qrCodeSigner.Verify(request.Payload, time.Now())
+	if err != nil {
+		c.writeError(writer, http.StatusUnauthorized, "Rejected a QR code: "+err.Error())
+		return
+	}
+	c.respondAuthentication(writer, cardID)
+}
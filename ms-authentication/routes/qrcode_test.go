@@ -0,0 +1,120 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQRCodeSigner(t *testing.T) {
+	now := time.Now()
+	signer := NewQRCodeSigner("qr-secret", time.Minute)
+	qrCode, err := signer.Issue("0001230001", now)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(qrCode.Payload, QRCodePrefix))
+	assert.Equal(t, now.Add(time.Minute).Unix(), qrCode.ExpiresAt)
+
+	cardID, err := signer.Verify(qrCode.Payload, now)
+	require.NoError(t, err)
+	assert.Equal(t, "0001230001", cardID)
+	_, err = signer.Verify(qrCode.Payload, now)
+	assert.EqualError(t, err, "the QR code was already used")
+
+	expired, err := signer.Issue("0001230001", now)
+	require.NoError(t, err)
+	_, err = signer.Verify(expired.Payload, now.Add(2*time.Minute))
+	assert.EqualError(t, err, "the QR code has expired")
+
+	other, err := NewQRCodeSigner("other-secret", time.Minute).Issue("0001230001", now)
+	require.NoError(t, err)
+	_, err = signer.Verify(other.Payload, now)
+	assert.Error(t, err, "signed with another secret")
+
+	// an authentication token signed with the same secret is not a QR code
+	token, err := NewTokenSigner("qr-secret", time.Minute).Sign(AuthData{CardID: "0001230001"}, now)
+	require.NoError(t, err)
+	_, err = signer.Verify(QRCodePrefix+token, now)
+	assert.Error(t, err)
+	_, err = signer.Verify(token, now)
+	assert.EqualError(t, err, "the payload is not a kiosk QR code")
+}
+
+func TestQRCodeSignerUsedNoncesExpire(t *testing.T) {
+	now := time.Now()
+	signer := NewQRCodeSigner("qr-secret", time.Minute)
+	qrCode, err := signer.Issue("0001230001", now)
+	require.NoError(t, err)
+	_, err = signer.Verify(qrCode.Payload, now)
+	require.NoError(t, err)
+	assert.Len(t, signer.used, 1)
+
+	later, err := signer.Issue("0001230001", now.Add(2*time.Minute))
+	require.NoError(t, err)
+	_, err = signer.Verify(later.Payload, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, signer.used, 1, "the nonce of the expired code is dropped")
+}
+
+func TestParseQRCodeTTL(t *testing.T) {
+	ttl, err := ParseQRCodeTTL("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultQRCodeTTL, ttl)
+	ttl, err = ParseQRCodeTTL("30s")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, ttl)
+	_, err = ParseQRCodeTTL("0s")
+	assert.Error(t, err)
+	assert.Nil(t, NewQRCodeSigner("", time.Minute))
+}
+
+// TestAuthenticationQRPost tests that an issued QR code authenticates its
+// card once, as a swipe of the card would
+func TestAuthenticationQRPost(t *testing.T) {
+	c := newStoreTestController(t)
+	c.qrCodeSigner = NewQRCodeSigner("qr-secret", time.Minute)
+
+	w := storeRequest(c.QRCodePost, http.MethodPost, "http://localhost:48096/cards/0001230001/qrcode", map[string]string{"cardid": "0001230001"}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var qrCode QRCode
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &qrCode))
+	assert.Equal(t, "0001230001", qrCode.CardID)
+
+	authenticate := func(payload string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(QRCodeRequest{Payload: payload})
+		require.NoError(t, err)
+		return storeRequest(c.AuthenticationQRPost, http.MethodPost, "http://localhost:48096/authentication/qr", nil, string(body))
+	}
+	w = authenticate(qrCode.Payload)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var authData AuthData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &authData))
+	assert.Equal(t, "0001230001", authData.CardID)
+	assert.Equal(t, 1, authData.AccountID)
+
+	assert.Equal(t, http.StatusUnauthorized, authenticate(qrCode.Payload).Code, "used twice")
+	assert.Equal(t, http.StatusUnauthorized, authenticate("https://example.com").Code)
+	assert.Equal(t, http.StatusBadRequest, storeRequest(c.AuthenticationQRPost, http.MethodPost, "http://localhost:48096/authentication/qr", nil, `{"payload":`).Code)
+
+	// the card of a valid QR code must still be allowed in
+	suspended, err := c.qrCodeSigner.Issue("0001230001", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, storeRequest(c.CardStatusPut, http.MethodPut, "http://localhost:48096/cards/0001230001/status", map[string]string{"cardid": "0001230001"}, `{"status":"suspended"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, authenticate(suspended.Payload).Code)
+
+	assert.Equal(t, http.StatusBadRequest, storeRequest(c.QRCodePost, http.MethodPost, "http://localhost:48096/cards/123/qrcode", map[string]string{"cardid": "123"}, "").Code)
+}
+
+func TestQRCodeNotConfigured(t *testing.T) {
+	c := newStoreTestController(t)
+	assert.Equal(t, http.StatusServiceUnavailable, storeRequest(c.QRCodePost, http.MethodPost, "http://localhost:48096/cards/0001230001/qrcode", map[string]string{"cardid": "0001230001"}, "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, storeRequest(c.AuthenticationQRPost, http.MethodPost, "http://localhost:48096/authentication/qr", nil, `{"payload":"acqr1:x"}`).Code)
+}
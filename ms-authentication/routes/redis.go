@@ -0,0 +1,133 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// RedisCardsKey, RedisAccountsKey and RedisPeopleKey are the keys of the
+	// cards, accounts and people JSON documents in Redis
+	RedisCardsKey    = "ms-authentication:cards"
+	RedisAccountsKey = "ms-authentication:accounts"
+	RedisPeopleKey   = "ms-authentication:people"
+
+	redisMaxIdle     = 3
+	redisIdleTimeout = 4 * time.Minute
+)
+
+// RedisStore keeps the cards, accounts and people as JSON documents in
+// Redis, so that every instance of the service connected to it shares them
+type RedisStore struct {
+	pool *redis.Pool
+}
+
+// NewRedisStore connects to the Redis server at the URL, such as
+// redis://localhost:6379/0
+func NewRedisStore(url string) (AuthStore, error) {
+	if len(url) == 0 {
+		return nil, errors.New("the redis authentication store needs the URL of the Redis server")
+	}
+	store := &RedisStore{pool: &redis.Pool{
+		MaxIdle:     redisMaxIdle,
+		IdleTimeout: redisIdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url)
+		},
+	}}
+
+	conn := store.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		store.pool.Close()
+		return nil, fmt.Errorf("failed to connect to redis authentication store: %s", err.Error())
+	}
+	return store, nil
+}
+
+// LoadCards reads the cards document
+func (store *RedisStore) LoadCards() (Cards, error) {
+	var cards Cards
+	if err := store.get(RedisCardsKey, &cards); err != nil {
+		return cards, fmt.Errorf("failed to load cards from redis: %w", err)
+	}
+	return cards, nil
+}
+
+// SaveCards replaces the cards document
+func (store *RedisStore) SaveCards(cards Cards) error {
+	if err := store.set(RedisCardsKey, cards); err != nil {
+		return fmt.Errorf("failed to save cards to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadAccounts reads the accounts document
+func (store *RedisStore) LoadAccounts() (Accounts, error) {
+	var accounts Accounts
+	if err := store.get(RedisAccountsKey, &accounts); err != nil {
+		return accounts, fmt.Errorf("failed to load accounts from redis: %w", err)
+	}
+	return accounts, nil
+}
+
+// SaveAccounts replaces the accounts document
+func (store *RedisStore) SaveAccounts(accounts Accounts) error {
+	if err := store.set(RedisAccountsKey, accounts); err != nil {
+		return fmt.Errorf("failed to save accounts to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadPeople reads the people document
+func (store *RedisStore) LoadPeople() (People, error) {
+	var people People
+	if err := store.get(RedisPeopleKey, &people); err != nil {
+		return people, fmt.Errorf("failed to load people from redis: %w", err)
+	}
+	return people, nil
+}
+
+// SavePeople replaces the people document
+func (store *RedisStore) SavePeople(people People) error {
+	if err := store.set(RedisPeopleKey, people); err != nil {
+		return fmt.Errorf("failed to save people to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// Close closes the connections to Redis
+func (store *RedisStore) Close() error {
+	return store.pool.Close()
+}
+
+func (store *RedisStore) get(key string, content interface{}) error {
+	conn := store.pool.Get()
+	defer conn.Close()
+	data, err := redis.Bytes(conn.Do("GET", key))
+	if errors.Is(err, redis.ErrNil) {
+		return ErrNotStored
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, content)
+}
+
+func (store *RedisStore) set(key string, content interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	conn := store.pool.Get()
+	defer conn.Close()
+	_, err = conn.Do("SET", key, data)
+	return err
+}
@@ -0,0 +1,151 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is an in-memory Redis server that answers PING, GET and SET
+type fakeRedis struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+// fakeRedisConn is a connection to a fakeRedis
+type fakeRedisConn struct {
+	server *fakeRedis
+}
+
+func (conn *fakeRedisConn) Close() error { return nil }
+func (conn *fakeRedisConn) Err() error   { return nil }
+func (conn *fakeRedisConn) Flush() error { return nil }
+
+func (conn *fakeRedisConn) Send(commandName string, args ...interface{}) error {
+	return errors.New("pipelining is not supported")
+}
+
+func (conn *fakeRedisConn) Receive() (interface{}, error) {
+	return nil, errors.New("pipelining is not supported")
+}
+
+func (conn *fakeRedisConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	server := conn.server
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.err != nil {
+		return nil, server.err
+	}
+	switch commandName {
+	case "":
+		// the pool checks connections with an empty command
+		return nil, nil
+	case "PING":
+		return "PONG", nil
+	case "GET":
+		value, ok := server.values[args[0].(string)]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	case "SET":
+		server.values[args[0].(string)] = args[1].([]byte)
+		return "OK", nil
+	default:
+		return nil, fmt.Errorf("unsupported command %s", commandName)
+	}
+}
+
+// newFakeRedisServer returns a store connected to a new fakeRedis
+func newFakeRedisServer() (*RedisStore, *fakeRedis) {
+	server := &fakeRedis{values: map[string][]byte{}}
+	return &RedisStore{pool: &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return &fakeRedisConn{server: server}, nil
+		},
+	}}, server
+}
+
+func TestRedisStore(t *testing.T) {
+	store, server := newFakeRedisServer()
+
+	_, err := store.LoadCards()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadAccounts()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadPeople()
+	assert.ErrorIs(t, err, ErrNotStored)
+
+	require.NoError(t, store.SaveCards(setupCards()))
+	cards, err := store.LoadCards()
+	require.NoError(t, err)
+	assert.Equal(t, setupCards(), cards)
+	require.NoError(t, store.SaveAccounts(setupAccounts()))
+	accounts, err := store.LoadAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, setupAccounts(), accounts)
+	require.NoError(t, store.SavePeople(setupPeople()))
+	people, err := store.LoadPeople()
+	require.NoError(t, err)
+	assert.Equal(t, setupPeople(), people)
+
+	server.values[RedisCardsKey] = []byte(`{"cards":[{"cardID":`)
+	_, err = store.LoadCards()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotStored, "a corrupt document is not a missing one")
+
+	server.err = errors.New("connection refused")
+	_, err = store.LoadPeople()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotStored)
+	assert.Error(t, store.SaveAccounts(setupAccounts()))
+}
+
+func TestNewAuthStore(t *testing.T) {
+	store, err := NewAuthStore(AuthStoreFile, "")
+	require.NoError(t, err)
+	assert.IsType(t, &FileStore{}, store)
+
+	_, err = NewAuthStore(AuthStoreRedis, "")
+	assert.Error(t, err, "the redis store needs a URL")
+	_, err = NewAuthStore(AuthStoreRedis, "redis://127.0.0.1:1/0")
+	assert.Error(t, err, "the redis server is unreachable")
+	_, err = NewAuthStore("mongodb", "")
+	assert.Error(t, err)
+}
+
+func TestMigrateAuthData(t *testing.T) {
+	cards := setupCards()
+	require.NoError(t, cards.WriteCards())
+	accounts := setupAccounts()
+	require.NoError(t, accounts.WriteAccounts())
+	require.NoError(t, os.Remove(PeopleFileName), "people are only migrated when their file exists")
+
+	store, _ := newFakeRedisServer()
+	// the store already has accounts, which are not replaced
+	storedAccounts := Accounts{Accounts: []Account{{AccountID: 42, IsActive: true}}}
+	require.NoError(t, store.SaveAccounts(storedAccounts))
+
+	require.NoError(t, MigrateAuthData(store))
+	migratedCards, err := store.LoadCards()
+	require.NoError(t, err)
+	assert.Equal(t, setupCards(), migratedCards)
+	migratedAccounts, err := store.LoadAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, storedAccounts, migratedAccounts)
+	_, err = store.LoadPeople()
+	assert.ErrorIs(t, err, ErrNotStored)
+	assert.FileExists(t, CardsFileName, "the files are left in place")
+
+	require.NoError(t, MigrateAuthData(NewFileStore()), "the file store has nothing to migrate")
+}
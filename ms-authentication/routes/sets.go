@@ -0,0 +1,436 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// loadCards loads the cards, which are empty until the first card is saved
+func (c *Controller) loadCards() (Cards, error) {
+	cards, err := c.store.LoadCards()
+	if errors.Is(err, ErrNotStored) {
+		return Cards{Cards: []Card{}}, nil
+	}
+	return cards, err
+}
+
+// loadAccounts loads the accounts, which are empty until the first account
+// is saved
+func (c *Controller) loadAccounts() (Accounts, error) {
+	accounts, err := c.store.LoadAccounts()
+	if errors.Is(err, ErrNotStored) {
+		return Accounts{Accounts: []Account{}}, nil
+	}
+	return accounts, err
+}
+
+// loadPeople loads the people, which are empty until the first person is
+// saved
+func (c *Controller) loadPeople() (People, error) {
+	people, err := c.store.LoadPeople()
+	if errors.Is(err, ErrNotStored) {
+		return People{People: []Person{}}, nil
+	}
+	return people, err
+}
+
+// writeError logs the error message and responds with it
+func (c *Controller) writeError(writer http.ResponseWriter, statusCode int, errMsg string) {
+	c.lc.Error(errMsg)
+	writer.WriteHeader(statusCode)
+	writer.Write([]byte(errMsg))
+}
+
+// writeJSON responds with the content as JSON
+func (c *Controller) writeJSON(writer http.ResponseWriter, content interface{}) {
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to marshal response: "+err.Error())
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(contentJSON)
+}
+
+// hasPerson returns whether the person is in the list
+func (people *People) hasPerson(personID int) bool {
+	return people.GetPersonByPersonID(personID).PersonID == personID && personID != 0
+}
+
+// hasAccount returns whether the account is in the list
+func (accounts *Accounts) hasAccount(accountID int) bool {
+	return accounts.GetAccountByAccountID(accountID).AccountID == accountID && accountID != 0
+}
+
+// nextPersonID returns the ID after the highest person ID
+func (people *People) nextPersonID() int {
+	next := 1
+	for _, person := range people.People {
+		if person.PersonID >= next {
+			next = person.PersonID + 1
+		}
+	}
+	return next
+}
+
+// nextAccountID returns the ID after the highest account ID
+func (accounts *Accounts) nextAccountID() int {
+	next := 1
+	for _, account := range accounts.Accounts {
+		if account.AccountID >= next {
+			next = account.AccountID + 1
+		}
+	}
+	return next
+}
+
+// CardPost enrolls a new card for an existing person. The card ID must be
+// 10 characters, as it is read by the card reader after any CardIDFormats.
+func (c *Controller) CardPost(writer http.ResponseWriter, req *http.Request) {
+	var card Card
+	if err := json.NewDecoder(req.Body).Decode(&card); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal card: "+err.Error())
+		return
+	}
+	if len(card.CardID) != CardIDLength {
+		c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Card ID must be %d characters", CardIDLength))
+		return
+	}
+	if !IsRole(card.RoleID) {
+		c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Unknown role %d", card.RoleID))
+		return
+	}
+	if err := card.validate(); err != nil {
+		c.writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	if cards.GetCardByCardID(card.CardID).CardID == card.CardID {
+		c.writeError(writer, http.StatusConflict, fmt.Sprintf("Card %s is already enrolled", card.CardID))
+		return
+	}
+	if statusCode, err := c.checkCardPerson(card); err != nil {
+		c.writeError(writer, statusCode, err.Error())
+		return
+	}
+
+	// a PIN is only set with CardPinPut, so that it is always hashed
+	card.PinRequired = false
+	card.PinSalt = ""
+	card.PinHash = ""
+	now := time.Now().UnixNano()
+	card.CreatedAt = now
+	card.UpdatedAt = now
+	cards.Cards = append(cards.Cards, card)
+	if err := c.store.SaveCards(cards); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write cards data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Enrolled card %s for person %d", card.CardID, card.PersonID)
+	c.writeJSON(writer, card.withoutPinHash())
+}
+
+// CardPut replaces the card of the card ID in the URL, such as to move it
+// to another person or to invalidate it. Its PIN is kept, and so is its
+// status unless the body has one.
+func (c *Controller) CardPut(writer http.ResponseWriter, req *http.Request) {
+	cardID := mux.Vars(req)["cardid"]
+	var card Card
+	if err := json.NewDecoder(req.Body).Decode(&card); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal card: "+err.Error())
+		return
+	}
+	if card.CardID != "" && card.CardID != cardID {
+		c.writeError(writer, http.StatusBadRequest, "Card ID in the body does not match the URL")
+		return
+	}
+	card.CardID = cardID
+	if !IsRole(card.RoleID) {
+		c.writeError(writer, http.StatusBadRequest, fmt.Sprintf("Unknown role %d", card.RoleID))
+		return
+	}
+	if err := card.validate(); err != nil {
+		c.writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	index := -1
+	for i := range cards.Cards {
+		if cards.Cards[i].CardID == cardID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Card %s is not enrolled", cardID))
+		return
+	}
+	if statusCode, err := c.checkCardPerson(card); err != nil {
+		c.writeError(writer, statusCode, err.Error())
+		return
+	}
+
+	card.CreatedAt = cards.Cards[index].CreatedAt
+	if card.Status == "" {
+		card.Status = cards.Cards[index].Status
+	}
+	card.PinRequired = cards.Cards[index].PinRequired
+	card.PinSalt = cards.Cards[index].PinSalt
+	card.PinHash = cards.Cards[index].PinHash
+	card.UpdatedAt = time.Now().UnixNano()
+	cards.Cards[index] = card
+	if err := c.store.SaveCards(cards); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write cards data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Updated card %s", card.CardID)
+	c.writeJSON(writer, card.withoutPinHash())
+}
+
+// checkCardPerson checks that the person of the card exists
+func (c *Controller) checkCardPerson(card Card) (int, error) {
+	people, err := c.loadPeople()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to read people data: %s", err.Error())
+	}
+	if !people.hasPerson(card.PersonID) {
+		return http.StatusBadRequest, fmt.Errorf("Card is associated with an unknown person %d", card.PersonID)
+	}
+	return http.StatusOK, nil
+}
+
+// AccountPost adds a new account. An account without an account ID is
+// given the next free ID.
+func (c *Controller) AccountPost(writer http.ResponseWriter, req *http.Request) {
+	var account Account
+	if err := json.NewDecoder(req.Body).Decode(&account); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal account: "+err.Error())
+		return
+	}
+	if account.AccountID < 0 {
+		c.writeError(writer, http.StatusBadRequest, "Account ID must be positive")
+		return
+	}
+	if err := account.validate(); err != nil {
+		c.writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read accounts data: "+err.Error())
+		return
+	}
+	if account.AccountID == 0 {
+		account.AccountID = accounts.nextAccountID()
+	} else if accounts.hasAccount(account.AccountID) {
+		c.writeError(writer, http.StatusConflict, fmt.Sprintf("Account %d already exists", account.AccountID))
+		return
+	}
+
+	now := time.Now().UnixNano()
+	account.CreatedAt = now
+	account.UpdatedAt = now
+	accounts.Accounts = append(accounts.Accounts, account)
+	if err := c.store.SaveAccounts(accounts); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write accounts data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Added account %d", account.AccountID)
+	c.writeJSON(writer, account)
+}
+
+// AccountPut replaces the account of the account ID in the URL. Its status
+// is kept unless the body has one.
+func (c *Controller) AccountPut(writer http.ResponseWriter, req *http.Request) {
+	accountID, err := strconv.Atoi(mux.Vars(req)["accountid"])
+	if err != nil {
+		c.writeError(writer, http.StatusBadRequest, "accountID contains bad data")
+		return
+	}
+	var account Account
+	if err := json.NewDecoder(req.Body).Decode(&account); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal account: "+err.Error())
+		return
+	}
+	if account.AccountID != 0 && account.AccountID != accountID {
+		c.writeError(writer, http.StatusBadRequest, "Account ID in the body does not match the URL")
+		return
+	}
+	account.AccountID = accountID
+	if err := account.validate(); err != nil {
+		c.writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read accounts data: "+err.Error())
+		return
+	}
+	index := -1
+	for i := range accounts.Accounts {
+		if accounts.Accounts[i].AccountID == accountID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Account %d does not exist", accountID))
+		return
+	}
+
+	account.CreatedAt = accounts.Accounts[index].CreatedAt
+	if account.Status == "" {
+		account.Status = accounts.Accounts[index].Status
+	}
+	account.UpdatedAt = time.Now().UnixNano()
+	accounts.Accounts[index] = account
+	if err := c.store.SaveAccounts(accounts); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write accounts data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Updated account %d", account.AccountID)
+	c.writeJSON(writer, account)
+}
+
+// PersonPost adds a new person to an existing account. A person without a
+// person ID is given the next free ID.
+func (c *Controller) PersonPost(writer http.ResponseWriter, req *http.Request) {
+	var person Person
+	if err := json.NewDecoder(req.Body).Decode(&person); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal person: "+err.Error())
+		return
+	}
+	if person.PersonID < 0 {
+		c.writeError(writer, http.StatusBadRequest, "Person ID must be positive")
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	people, err := c.loadPeople()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read people data: "+err.Error())
+		return
+	}
+	if person.PersonID == 0 {
+		person.PersonID = people.nextPersonID()
+	} else if people.hasPerson(person.PersonID) {
+		c.writeError(writer, http.StatusConflict, fmt.Sprintf("Person %d already exists", person.PersonID))
+		return
+	}
+	if statusCode, err := c.checkPersonAccount(person); err != nil {
+		c.writeError(writer, statusCode, err.Error())
+		return
+	}
+
+	now := time.Now().UnixNano()
+	person.CreatedAt = now
+	person.UpdatedAt = now
+	people.People = append(people.People, person)
+	if err := c.store.SavePeople(people); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write people data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Added person %d to account %d", person.PersonID, person.AccountID)
+	c.writeJSON(writer, person)
+}
+
+// PersonPut replaces the person of the person ID in the URL, such as to
+// move them to another account
+func (c *Controller) PersonPut(writer http.ResponseWriter, req *http.Request) {
+	personID, err := strconv.Atoi(mux.Vars(req)["personid"])
+	if err != nil {
+		c.writeError(writer, http.StatusBadRequest, "personID contains bad data")
+		return
+	}
+	var person Person
+	if err := json.NewDecoder(req.Body).Decode(&person); err != nil {
+		c.writeError(writer, http.StatusBadRequest, "Failed to unmarshal person: "+err.Error())
+		return
+	}
+	if person.PersonID != 0 && person.PersonID != personID {
+		c.writeError(writer, http.StatusBadRequest, "Person ID in the body does not match the URL")
+		return
+	}
+	person.PersonID = personID
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	people, err := c.loadPeople()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read people data: "+err.Error())
+		return
+	}
+	index := -1
+	for i := range people.People {
+		if people.People[i].PersonID == personID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Person %d does not exist", personID))
+		return
+	}
+	if statusCode, err := c.checkPersonAccount(person); err != nil {
+		c.writeError(writer, statusCode, err.Error())
+		return
+	}
+
+	person.CreatedAt = people.People[index].CreatedAt
+	person.UpdatedAt = time.Now().UnixNano()
+	people.People[index] = person
+	if err := c.store.SavePeople(people); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write people data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Updated person %d", person.PersonID)
+	c.writeJSON(writer, person)
+}
+
+// checkPersonAccount checks that the account of the person exists
+func (c *Controller) checkPersonAccount(person Person) (int, error) {
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Failed to read accounts data: %s", err.Error())
+	}
+	if !accounts.hasAccount(person.AccountID) {
+		return http.StatusBadRequest, fmt.Errorf("Person is associated with an unknown account %d", person.AccountID)
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,175 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStoreTestController returns a controller whose store has the test
+// cards, accounts and people
+func newStoreTestController(t *testing.T) Controller {
+	store, _ := newFakeRedisServer()
+	require.NoError(t, store.SaveCards(setupCards()))
+	require.NoError(t, store.SaveAccounts(setupAccounts()))
+	require.NoError(t, store.SavePeople(setupPeople()))
+	return Controller{
+		lc:         logger.NewMockClient(),
+		store:      store,
+		storeMutex: &sync.Mutex{},
+	}
+}
+
+// storeRequest runs the handler with the body and URL variables
+func storeRequest(handler http.HandlerFunc, method string, url string, vars map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	req = mux.SetURLVars(req, vars)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestCardPost(t *testing.T) {
+	tests := []struct {
+		Name               string
+		Body               string
+		ExpectedStatusCode int
+	}{
+		{"new card", `{"cardID":"0001239999","roleID":1,"isValid":true,"personID":6}`, http.StatusOK},
+		{"already enrolled", `{"cardID":"0001230001","roleID":1,"isValid":true,"personID":6}`, http.StatusConflict},
+		{"unknown person", `{"cardID":"0001239999","roleID":1,"isValid":true,"personID":42}`, http.StatusBadRequest},
+		{"short card ID", `{"cardID":"123","roleID":1,"isValid":true,"personID":6}`, http.StatusBadRequest},
+		{"unknown role", `{"cardID":"0001239999","roleID":9,"isValid":true,"personID":6}`, http.StatusBadRequest},
+		{"invalid JSON", `{"cardID":`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t)
+			w := storeRequest(c.CardPost, http.MethodPost, "http://localhost:48096/cards", nil, currentTest.Body)
+			require.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+
+			cards, err := c.store.LoadCards()
+			require.NoError(t, err)
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				assert.Equal(t, setupCards(), cards, "a rejected card is not saved")
+				return
+			}
+			card := cards.GetCardByCardID("0001239999")
+			assert.Equal(t, 6, card.PersonID)
+			assert.NotZero(t, card.CreatedAt)
+		})
+	}
+}
+
+func TestCardPut(t *testing.T) {
+	tests := []struct {
+		Name               string
+		CardID             string
+		Body               string
+		ExpectedStatusCode int
+	}{
+		{"move card", "0001230001", `{"roleID":2,"isValid":false,"personID":6}`, http.StatusOK},
+		{"not enrolled", "0001239999", `{"roleID":2,"isValid":false,"personID":6}`, http.StatusNotFound},
+		{"unknown person", "0001230001", `{"roleID":2,"isValid":false,"personID":42}`, http.StatusBadRequest},
+		{"unknown role", "0001230001", `{"roleID":9,"isValid":false,"personID":6}`, http.StatusBadRequest},
+		{"mismatched card ID", "0001230001", `{"cardID":"0001230002","personID":6}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t)
+			w := storeRequest(c.CardPut, http.MethodPut, "http://localhost:48096/cards/"+currentTest.CardID, map[string]string{"cardid": currentTest.CardID}, currentTest.Body)
+			require.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			cards, err := c.store.LoadCards()
+			require.NoError(t, err)
+			card := cards.GetCardByCardID(currentTest.CardID)
+			assert.Equal(t, 6, card.PersonID)
+			assert.Equal(t, 2, card.RoleID)
+			assert.False(t, card.IsValid)
+			assert.Equal(t, int64(1560815799), card.CreatedAt, "the enrollment date is kept")
+			assert.Len(t, cards.Cards, len(setupCards().Cards))
+		})
+	}
+}
+
+func TestAccountPostPut(t *testing.T) {
+	c := newStoreTestController(t)
+
+	w := storeRequest(c.AccountPost, http.MethodPost, "http://localhost:48096/accounts", nil, `{"emailAddress":"new@example.com","isActive":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var account Account
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	assert.Equal(t, 6, account.AccountID, "the next free account ID is assigned")
+
+	w = storeRequest(c.AccountPost, http.MethodPost, "http://localhost:48096/accounts", nil, `{"accountID":1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = storeRequest(c.AccountPost, http.MethodPost, "http://localhost:48096/accounts", nil, `{"accountID":-2}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = storeRequest(c.AccountPut, http.MethodPut, "http://localhost:48096/accounts/6", map[string]string{"accountid": "6"}, `{"emailAddress":"changed@example.com","isActive":true,"creditLimit":10}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	accounts, err := c.store.LoadAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, "changed@example.com", accounts.GetAccountByAccountID(6).EmailAddress)
+	assert.Equal(t, float64(10), accounts.GetAccountByAccountID(6).CreditLimit)
+
+	w = storeRequest(c.AccountPut, http.MethodPut, "http://localhost:48096/accounts/42", map[string]string{"accountid": "42"}, `{}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = storeRequest(c.AccountPut, http.MethodPut, "http://localhost:48096/accounts/abc", map[string]string{"accountid": "abc"}, `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPersonPostPut(t *testing.T) {
+	c := newStoreTestController(t)
+
+	w := storeRequest(c.PersonPost, http.MethodPost, "http://localhost:48096/people", nil, `{"accountID":2,"fullName":"New Person","isActive":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var person Person
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &person))
+	assert.Equal(t, 8, person.PersonID, "the next free person ID is assigned")
+
+	w = storeRequest(c.PersonPost, http.MethodPost, "http://localhost:48096/people", nil, `{"personID":9,"accountID":42}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the account must exist")
+	w = storeRequest(c.PersonPost, http.MethodPost, "http://localhost:48096/people", nil, `{"personID":1,"accountID":1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = storeRequest(c.PersonPut, http.MethodPut, "http://localhost:48096/people/8", map[string]string{"personid": "8"}, `{"accountID":42}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the account must exist")
+	w = storeRequest(c.PersonPut, http.MethodPut, "http://localhost:48096/people/8", map[string]string{"personid": "8"}, `{"accountID":3,"fullName":"Moved Person"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	people, err := c.store.LoadPeople()
+	require.NoError(t, err)
+	assert.Equal(t, 3, people.GetPersonByPersonID(8).AccountID)
+
+	w = storeRequest(c.PersonPut, http.MethodPut, "http://localhost:48096/people/42", map[string]string{"personid": "42"}, `{"accountID":3}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStoreNotStored(t *testing.T) {
+	store, _ := newFakeRedisServer()
+	c := Controller{lc: logger.NewMockClient(), store: store, storeMutex: &sync.Mutex{}}
+
+	// an empty store takes the first account, person and card
+	w := storeRequest(c.AccountPost, http.MethodPost, "http://localhost:48096/accounts", nil, `{"isActive":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = storeRequest(c.PersonPost, http.MethodPost, "http://localhost:48096/people", nil, `{"accountID":1,"isActive":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = storeRequest(c.CardPost, http.MethodPost, "http://localhost:48096/cards", nil, `{"cardID":"0001230001","roleID":1,"isValid":true,"personID":1}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,149 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+const (
+	// AuthStoreFile keeps the cards, accounts and people in their JSON
+	// files, which only a single instance of the service can use
+	AuthStoreFile = "file"
+	// AuthStoreRedis keeps the cards, accounts and people in Redis, so that
+	// several instances of the service share them
+	AuthStoreRedis = "redis"
+)
+
+// ErrNotStored is returned when the store does not have the cards, accounts
+// or people yet, which is the case until they are first saved
+var ErrNotStored = errors.New("not stored")
+
+// AuthStore persists the cards, accounts and people. Each is loaded and
+// saved as a whole, and a save replaces what was stored before.
+type AuthStore interface {
+	LoadCards() (Cards, error)
+	SaveCards(cards Cards) error
+	LoadAccounts() (Accounts, error)
+	SaveAccounts(accounts Accounts) error
+	LoadPeople() (People, error)
+	SavePeople(people People) error
+	Close() error
+}
+
+// FileStore keeps the cards, accounts and people in the CardsFileName,
+// AccountsFileName and PeopleFileName files of the DataDirectory
+type FileStore struct{}
+
+// NewFileStore creates a store of the cards, accounts and people files
+func NewFileStore() AuthStore {
+	return &FileStore{}
+}
+
+// NewAuthStore creates the store of the given type. The URL is the Redis
+// URL of the redis store.
+func NewAuthStore(storeType string, url string) (AuthStore, error) {
+	switch storeType {
+	case AuthStoreFile:
+		return NewFileStore(), nil
+	case AuthStoreRedis:
+		return NewRedisStore(url)
+	default:
+		return nil, fmt.Errorf("unknown authentication store %q, expected %s or %s", storeType, AuthStoreFile, AuthStoreRedis)
+	}
+}
+
+// LoadCards reads the cards file
+func (store *FileStore) LoadCards() (Cards, error) {
+	return GetCardsData()
+}
+
+// SaveCards replaces the cards file
+func (store *FileStore) SaveCards(cards Cards) error {
+	return cards.WriteCards()
+}
+
+// LoadAccounts reads the accounts file
+func (store *FileStore) LoadAccounts() (Accounts, error) {
+	return GetAccountsData()
+}
+
+// SaveAccounts replaces the accounts file
+func (store *FileStore) SaveAccounts(accounts Accounts) error {
+	return accounts.WriteAccounts()
+}
+
+// LoadPeople reads the people file
+func (store *FileStore) LoadPeople() (People, error) {
+	return GetPeopleData()
+}
+
+// SavePeople replaces the people file
+func (store *FileStore) SavePeople(people People) error {
+	return people.WritePeople()
+}
+
+// Close does nothing, as the files are only open while they are read or
+// written
+func (store *FileStore) Close() error {
+	return nil
+}
+
+// MigrateAuthData copies the cards, accounts and people files into the
+// store on the first start with it, so that the enrolled cards are kept
+// when moving from the files to another store. Each is only copied when
+// the store does not have it yet, and when its file exists. The files are
+// left in place, as they are the sample data the service is shipped with.
+func MigrateAuthData(store AuthStore) error {
+	if _, ok := store.(*FileStore); ok {
+		return nil
+	}
+
+	if _, err := store.LoadCards(); errors.Is(err, ErrNotStored) {
+		if _, statErr := os.Stat(utilities.DataFileName(DataDirectory, CardsFileName)); statErr == nil {
+			cards, err := GetCardsData()
+			if err != nil {
+				return err
+			}
+			if err := store.SaveCards(cards); err != nil {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := store.LoadAccounts(); errors.Is(err, ErrNotStored) {
+		if _, statErr := os.Stat(utilities.DataFileName(DataDirectory, AccountsFileName)); statErr == nil {
+			accounts, err := GetAccountsData()
+			if err != nil {
+				return err
+			}
+			if err := store.SaveAccounts(accounts); err != nil {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := store.LoadPeople(); errors.Is(err, ErrNotStored) {
+		if _, statErr := os.Stat(utilities.DataFileName(DataDirectory, PeopleFileName)); statErr == nil {
+			people, err := GetPeopleData()
+			if err != nil {
+				return err
+			}
+			if err := store.SavePeople(people); err != nil {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// TokenIssuer is the issuer of the authentication tokens, which the
+	// other services check
+	TokenIssuer = "ms-authentication"
+
+	defaultTokenTTL = 5 * time.Minute
+)
+
+// AuthClaims are the claims of an authentication token, the account, person
+// and role of the authenticated card
+type AuthClaims struct {
+	AccountID int `json:"accountID"`
+	PersonID  int `json:"personID"`
+	RoleID    int `json:"roleID"`
+	jwt.StandardClaims
+}
+
+// TokenSigner mints short-lived authentication tokens, signed with HS256
+// and the secret shared with the services that check them. A nil
+// TokenSigner mints no tokens.
+type TokenSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewTokenSigner creates a TokenSigner for the secret, or nil when the
+// secret is empty
+func NewTokenSigner(secret string, ttl time.Duration) *TokenSigner {
+	if secret == "" {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	return &TokenSigner{secret: []byte(secret), ttl: ttl}
+}
+
+// ParseTokenTTL parses how long authentication tokens are valid, empty is
+// the default TTL
+func ParseTokenTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return defaultTokenTTL, nil
+	}
+	duration, err := time.ParseDuration(ttl)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("token TTL %q must be a positive duration", ttl)
+	}
+	return duration, nil
+}
+
+// Sign mints a token for the authenticated card, valid from now for the TTL
+func (signer *TokenSigner) Sign(authData AuthData, now time.Time) (string, error) {
+	if signer == nil {
+		return "", errors.New("authentication tokens are not configured")
+	}
+	claims := AuthClaims{
+		AccountID: authData.AccountID,
+		PersonID:  authData.PersonID,
+		RoleID:    authData.RoleID,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    TokenIssuer,
+			Subject:   authData.CardID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(signer.ttl).Unix(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(signer.secret)
+}
+
+// Verify returns the claims of the bearer token in the Authorization header,
+// and an error when it is missing, not signed by this service or expired
+func (signer *TokenSigner) Verify(authorization string) (AuthClaims, error) {
+	var claims AuthClaims
+	tokenString := strings.TrimPrefix(authorization, "Bearer ")
+	if authorization == "" || tokenString == authorization {
+		return claims, errors.New("a bearer token is required")
+	}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return signer.secret, nil
+	})
+	if err != nil {
+		return claims, fmt.Errorf("the token is not valid: %s", err.Error())
+	}
+	if claims.ExpiresAt == 0 || claims.Issuer != TokenIssuer {
+		return claims, errors.New("the token is not valid: it has no expiry or another issuer")
+	}
+	return claims, nil
+}
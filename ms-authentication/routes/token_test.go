@@ -0,0 +1,75 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuthenticationGetToken tests that an authenticated card gets a signed
+// token with its account, person and role
+func TestAuthenticationGetToken(t *testing.T) {
+	cards := setupCards()
+	require.NoError(t, writeJSONFiles(setupPeople(), setupAccounts(), cards), "Failed to write to test file")
+
+	mockAppService := &mocks.ApplicationService{}
+	mockAppService.On("LoggingClient").Return(logger.NewMockClient())
+	c := NewController(mockAppService, nil, NewFileStore(), NewTokenSigner("test-secret", time.Minute), nil, nil, nil)
+
+	req := httptest.NewRequest("GET", "/authentication/"+cards.Cards[0].CardID, nil)
+	req = mux.SetURLVars(req, map[string]string{"cardid": cards.Cards[0].CardID})
+	w := httptest.NewRecorder()
+	c.AuthenticationGet(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var authData AuthData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &authData))
+	require.NotEmpty(t, authData.Token)
+
+	var claims AuthClaims
+	token, err := jwt.ParseWithClaims(authData.Token, &claims, func(token *jwt.Token) (interface{}, error) {
+		assert.Equal(t, jwt.SigningMethodHS256, token.Method)
+		return []byte("test-secret"), nil
+	})
+	require.NoError(t, err)
+	assert.True(t, token.Valid)
+	assert.Equal(t, authData.AccountID, claims.AccountID)
+	assert.Equal(t, authData.PersonID, claims.PersonID)
+	assert.Equal(t, authData.RoleID, claims.RoleID)
+	assert.Equal(t, cards.Cards[0].CardID, claims.Subject)
+	assert.Equal(t, TokenIssuer, claims.Issuer)
+	assert.Equal(t, time.Minute, time.Duration(claims.ExpiresAt-claims.IssuedAt)*time.Second)
+
+	// another secret does not verify the token
+	_, err = jwt.ParseWithClaims(authData.Token, &AuthClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte("another-secret"), nil
+	})
+	assert.Error(t, err)
+}
+
+func TestTokenSigner(t *testing.T) {
+	assert.Nil(t, NewTokenSigner("", time.Minute))
+	_, err := (*TokenSigner)(nil).Sign(AuthData{}, time.Now())
+	assert.Error(t, err)
+
+	ttl, err := ParseTokenTTL("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultTokenTTL, ttl)
+	ttl, err = ParseTokenTTL("15m")
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, ttl)
+	_, err = ParseTokenTTL("-1m")
+	assert.Error(t, err)
+}
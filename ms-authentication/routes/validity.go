@@ -0,0 +1,200 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The statuses of cards and accounts. An empty status is active, as are
+// the cards and accounts that were saved before they had a status.
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+	StatusExpired   = "expired"
+)
+
+// Validity is the status and validity window of a card or account, which
+// is only authenticated while it is active and within the window. The
+// window is in Unix nanoseconds, like CreatedAt, and zero leaves that end
+// of the window open.
+type Validity struct {
+	Status     string `json:"status,omitempty"`
+	ValidFrom  int64  `json:"validFrom,omitempty,string"`
+	ValidUntil int64  `json:"validUntil,omitempty,string"`
+}
+
+// StatusRequest is the body of the routes that suspend and reactivate a
+// card or account
+type StatusRequest struct {
+	Status string `json:"status"`
+}
+
+// validate checks the status and that the window does not end before it
+// starts
+func (validity Validity) validate() error {
+	switch validity.Status {
+	case "", StatusActive, StatusSuspended, StatusExpired:
+	default:
+		return fmt.Errorf("Unknown status %q, expected %s, %s or %s", validity.Status, StatusActive, StatusSuspended, StatusExpired)
+	}
+	if validity.ValidFrom < 0 || validity.ValidUntil < 0 {
+		return errors.New("validFrom and validUntil must not be negative")
+	}
+	if validity.ValidFrom != 0 && validity.ValidUntil != 0 && validity.ValidUntil <= validity.ValidFrom {
+		return errors.New("validUntil must be after validFrom")
+	}
+	return nil
+}
+
+// StatusAt returns the status at the time, which is expired once the
+// window has ended
+func (validity Validity) StatusAt(now time.Time) string {
+	if validity.Status == StatusSuspended || validity.Status == StatusExpired {
+		return validity.Status
+	}
+	if validity.ValidUntil != 0 && now.UnixNano() >= validity.ValidUntil {
+		return StatusExpired
+	}
+	return StatusActive
+}
+
+// check returns why the card or account is not valid at the time, or nil
+// when it is
+func (validity Validity) check(now time.Time) error {
+	switch validity.StatusAt(now) {
+	case StatusSuspended:
+		return errors.New("is suspended")
+	case StatusExpired:
+		return errors.New("has expired")
+	}
+	if validity.ValidFrom != 0 && now.UnixNano() < validity.ValidFrom {
+		return fmt.Errorf("is not valid until %s", time.Unix(0, validity.ValidFrom).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// decodeStatusRequest decodes the status that a card or account is set to
+func decodeStatusRequest(req *http.Request) (string, error) {
+	var request StatusRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return "", fmt.Errorf("Failed to unmarshal status: %s", err.Error())
+	}
+	if request.Status == "" {
+		return "", errors.New("status must be set")
+	}
+	if err := (Validity{Status: request.Status}).validate(); err != nil {
+		return "", err
+	}
+	return request.Status, nil
+}
+
+// CardStatusPut suspends or reactivates the card ID in the URL, such as
+// when it is lost, which takes effect on its next swipe. The body is a
+// StatusRequest. A card whose window has ended is not reactivated, its
+// validUntil is changed with CardPut instead.
+func (c *Controller) CardStatusPut(writer http.ResponseWriter, req *http.Request) {
+	cardID := mux.Vars(req)["cardid"]
+	status, err := decodeStatusRequest(req)
+	if err != nil {
+		c.writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	cards, err := c.loadCards()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read cards data: "+err.Error())
+		return
+	}
+	index := -1
+	for i := range cards.Cards {
+		if cards.Cards[i].CardID == cardID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Card %s is not enrolled", cardID))
+		return
+	}
+
+	now := time.Now()
+	card := cards.Cards[index]
+	card.Status = status
+	if status == StatusActive && card.StatusAt(now) == StatusExpired {
+		c.writeError(writer, http.StatusConflict, fmt.Sprintf("Card %s is past its validUntil", cardID))
+		return
+	}
+	card.UpdatedAt = now.UnixNano()
+	cards.Cards[index] = card
+	if err := c.store.SaveCards(cards); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write cards data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Card %s is now %s", card.CardID, status)
+	c.writeJSON(writer, card.withoutPinHash())
+}
+
+// AccountStatusPut suspends or reactivates the account ID in the URL, and
+// with it every card of its people. The body is a StatusRequest. An
+// account whose window has ended is not reactivated, its validUntil is
+// changed with AccountPut instead.
+func (c *Controller) AccountStatusPut(writer http.ResponseWriter, req *http.Request) {
+	accountID, err := strconv.Atoi(mux.Vars(req)["accountid"])
+	if err != nil {
+		c.writeError(writer, http.StatusBadRequest, "accountID contains bad data")
+		return
+	}
+	status, err := decodeStatusRequest(req)
+	if err != nil {
+		c.writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	accounts, err := c.loadAccounts()
+	if err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to read accounts data: "+err.Error())
+		return
+	}
+	index := -1
+	for i := range accounts.Accounts {
+		if accounts.Accounts[i].AccountID == accountID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		c.writeError(writer, http.StatusNotFound, fmt.Sprintf("Account %d does not exist", accountID))
+		return
+	}
+
+	now := time.Now()
+	account := accounts.Accounts[index]
+	account.Status = status
+	if status == StatusActive && account.StatusAt(now) == StatusExpired {
+		c.writeError(writer, http.StatusConflict, fmt.Sprintf("Account %d is past its validUntil", accountID))
+		return
+	}
+	account.UpdatedAt = now.UnixNano()
+	accounts.Accounts[index] = account
+	if err := c.store.SaveAccounts(accounts); err != nil {
+		c.writeError(writer, http.StatusInternalServerError, "Failed to write accounts data: "+err.Error())
+		return
+	}
+	c.lc.Infof("Account %d is now %s", account.AccountID, status)
+	c.writeJSON(writer, account)
+}
@@ -0,0 +1,129 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidityCheck(t *testing.T) {
+	now := time.Now()
+	hour := int64(time.Hour)
+	tests := []struct {
+		Name           string
+		Validity       Validity
+		ExpectedStatus string
+		ExpectedValid  bool
+	}{
+		{"no status", Validity{}, StatusActive, true},
+		{"active", Validity{Status: StatusActive}, StatusActive, true},
+		{"within the window", Validity{ValidFrom: now.UnixNano() - hour, ValidUntil: now.UnixNano() + hour}, StatusActive, true},
+		{"suspended", Validity{Status: StatusSuspended}, StatusSuspended, false},
+		{"expired", Validity{Status: StatusExpired}, StatusExpired, false},
+		{"after the window", Validity{Status: StatusActive, ValidUntil: now.UnixNano() - hour}, StatusExpired, false},
+		{"before the window", Validity{ValidFrom: now.UnixNano() + hour}, StatusActive, false},
+		{"suspended within the window", Validity{Status: StatusSuspended, ValidUntil: now.UnixNano() + hour}, StatusSuspended, false},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			assert.Equal(t, currentTest.ExpectedStatus, currentTest.Validity.StatusAt(now))
+			assert.Equal(t, currentTest.ExpectedValid, currentTest.Validity.check(now) == nil)
+		})
+	}
+}
+
+func TestValidityValidate(t *testing.T) {
+	assert.NoError(t, Validity{}.validate())
+	assert.NoError(t, Validity{Status: StatusSuspended, ValidFrom: 1, ValidUntil: 2}.validate())
+	assert.NoError(t, Validity{ValidUntil: 2}.validate())
+	assert.Error(t, Validity{Status: "lost"}.validate())
+	assert.Error(t, Validity{ValidFrom: 2, ValidUntil: 1}.validate())
+	assert.Error(t, Validity{ValidFrom: -1}.validate())
+}
+
+// TestCardStatusPut tests that a suspended card is refused until it is
+// reactivated
+func TestCardStatusPut(t *testing.T) {
+	c := newStoreTestController(t)
+	authenticate := func() int {
+		return storeRequest(c.AuthenticationGet, http.MethodGet, "http://localhost:48096/authentication/0001230001", map[string]string{"cardid": "0001230001"}, "").Code
+	}
+	setStatus := func(cardID string, body string) int {
+		return storeRequest(c.CardStatusPut, http.MethodPut, "http://localhost:48096/cards/"+cardID+"/status", map[string]string{"cardid": cardID}, body).Code
+	}
+	require.Equal(t, http.StatusOK, authenticate())
+
+	require.Equal(t, http.StatusOK, setStatus("0001230001", `{"status":"suspended"}`))
+	cards, err := c.store.LoadCards()
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuspended, cards.GetCardByCardID("0001230001").Status)
+	assert.Equal(t, http.StatusUnauthorized, authenticate())
+
+	require.Equal(t, http.StatusOK, setStatus("0001230001", `{"status":"active"}`))
+	assert.Equal(t, http.StatusOK, authenticate())
+
+	assert.Equal(t, http.StatusBadRequest, setStatus("0001230001", `{"status":"lost"}`))
+	assert.Equal(t, http.StatusBadRequest, setStatus("0001230001", `{}`))
+	assert.Equal(t, http.StatusNotFound, setStatus("0001239999", `{"status":"suspended"}`))
+
+	// a card past its window is not reactivated
+	cards, err = c.store.LoadCards()
+	require.NoError(t, err)
+	for i := range cards.Cards {
+		if cards.Cards[i].CardID == "0001230001" {
+			cards.Cards[i].ValidUntil = time.Now().Add(-time.Hour).UnixNano()
+		}
+	}
+	require.NoError(t, c.store.SaveCards(cards))
+	assert.Equal(t, http.StatusUnauthorized, authenticate())
+	assert.Equal(t, http.StatusConflict, setStatus("0001230001", `{"status":"active"}`))
+}
+
+// TestAccountStatusPut tests that the cards of a suspended account are
+// refused until it is reactivated
+func TestAccountStatusPut(t *testing.T) {
+	c := newStoreTestController(t)
+	authenticate := func() int {
+		return storeRequest(c.AuthenticationGet, http.MethodGet, "http://localhost:48096/authentication/0001230001", map[string]string{"cardid": "0001230001"}, "").Code
+	}
+	setStatus := func(accountID int, body string) int {
+		id := strconv.Itoa(accountID)
+		return storeRequest(c.AccountStatusPut, http.MethodPut, "http://localhost:48096/accounts/"+id+"/status", map[string]string{"accountid": id}, body).Code
+	}
+
+	require.Equal(t, http.StatusOK, setStatus(1, `{"status":"suspended"}`))
+	assert.Equal(t, http.StatusUnauthorized, authenticate())
+	require.Equal(t, http.StatusOK, setStatus(1, `{"status":"active"}`))
+	assert.Equal(t, http.StatusOK, authenticate())
+
+	assert.Equal(t, http.StatusNotFound, setStatus(42, `{"status":"suspended"}`))
+	assert.Equal(t, http.StatusBadRequest, setStatus(1, `{"status":`))
+}
+
+// TestCardPutValidity tests that a replaced card keeps its status unless
+// the body has one, and that its window is validated
+func TestCardPutValidity(t *testing.T) {
+	c := newStoreTestController(t)
+	putCard := func(body string) int {
+		return storeRequest(c.CardPut, http.MethodPut, "http://localhost:48096/cards/0001230001", map[string]string{"cardid": "0001230001"}, body).Code
+	}
+	require.Equal(t, http.StatusOK, storeRequest(c.CardStatusPut, http.MethodPut, "http://localhost:48096/cards/0001230001/status", map[string]string{"cardid": "0001230001"}, `{"status":"suspended"}`).Code)
+
+	require.Equal(t, http.StatusOK, putCard(`{"roleID":1,"isValid":true,"personID":1,"validUntil":"4102444800000000000"}`))
+	cards, err := c.store.LoadCards()
+	require.NoError(t, err)
+	card := cards.GetCardByCardID("0001230001")
+	assert.Equal(t, StatusSuspended, card.Status)
+	assert.Equal(t, int64(4102444800000000000), card.ValidUntil)
+
+	assert.Equal(t, http.StatusBadRequest, putCard(`{"roleID":1,"isValid":true,"personID":1,"validFrom":"2","validUntil":"1"}`))
+	assert.Equal(t, http.StatusBadRequest, putCard(`{"roleID":1,"isValid":true,"personID":1,"status":"lost"}`))
+}
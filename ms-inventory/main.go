@@ -5,10 +5,16 @@ package main
 
 import (
 	"ms-inventory/routes"
+	"time"
+	// the time zone database is embedded, as the container image has none
+	_ "time/tzdata"
 
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 const (
@@ -23,6 +29,15 @@ func main() {
 		os.Exit(1)
 	}
 	lc := service.LoggingClient()
+	// the build is logged first, so that every log shows what was running
+	lc.Info("starting service", routes.CurrentVersion().LogFields()...)
+
+	// DataDirectory is optional, the relative data file names are resolved
+	// against it. Without it they are relative to the working directory.
+	dataDirectory, err := service.GetAppSetting("DataDirectory")
+	if err != nil {
+		dataDirectory = ""
+	}
 
 	inventoryFileName, err := service.GetAppSetting("InventoryFileName")
 	if err != nil {
@@ -45,19 +60,231 @@ func main() {
 		lc.Error("AuditLogFileName configuration setting is empty")
 		os.Exit(1)
 	}
+	inventoryFileName = utilities.DataFileName(dataDirectory, inventoryFileName)
+	auditLogFileName = utilities.DataFileName(dataDirectory, auditLogFileName)
+	for _, directory := range []string{filepath.Dir(inventoryFileName), filepath.Dir(auditLogFileName)} {
+		if err := utilities.ValidateDataDirectory(directory); err != nil {
+			lc.Errorf("InventoryFileName and AuditLogFileName from ApplicationSettings must be in writable directories: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// SlowRequestThreshold is optional, without it slow requests are not logged
+	var slowRequestThreshold time.Duration
+	threshold, err := service.GetAppSetting("SlowRequestThreshold")
+	if err == nil && len(threshold) > 0 {
+		slowRequestThreshold, err = time.ParseDuration(threshold)
+		if err != nil {
+			lc.Errorf("SlowRequestThreshold from ApplicationSettings is not a valid duration: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// WriteDurability and FsyncInterval are optional, by default every write
+	// is flushed to disk before the request completes
+	writeDurability, err := service.GetAppSetting("WriteDurability")
+	if err != nil || len(writeDurability) == 0 {
+		writeDurability = utilities.DurabilityFsyncOnWrite
+	}
+	fileWriter, err := utilities.NewFileWriter(writeDurability)
+	if err != nil {
+		lc.Errorf("WriteDurability from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	fsyncInterval := utilities.DefaultFsyncInterval
+	interval, err := service.GetAppSetting("FsyncInterval")
+	if err == nil && len(interval) > 0 {
+		fsyncInterval, err = time.ParseDuration(interval)
+		if err != nil || fsyncInterval <= 0 {
+			lc.Errorf("FsyncInterval from ApplicationSettings must be a positive duration")
+			os.Exit(1)
+		}
+	}
+	go fileWriter.Run(service.AppContext(), fsyncInterval, lc)
+
+	// MaxRequestBodySize is optional, larger request bodies are rejected
+	maxBodySize := utilities.DefaultMaxRequestBodySize
+	bodySize, err := service.GetAppSetting("MaxRequestBodySize")
+	if err == nil && len(bodySize) > 0 {
+		maxBodySize, err = strconv.ParseInt(bodySize, 10, 64)
+		if err != nil || maxBodySize <= 0 {
+			lc.Errorf("MaxRequestBodySize from ApplicationSettings must be a positive number of bytes")
+			os.Exit(1)
+		}
+	}
+
+	// InventoryEventTopic is optional, without it inventory events are not published
+	eventTopic, err := service.GetAppSetting("InventoryEventTopic")
+	if err != nil || len(eventTopic) == 0 {
+		lc.Info("InventoryEventTopic is not set in ApplicationSettings, inventory events will not be published")
+		eventTopic = ""
+	}
+
+	// InventoryStore is optional, by default the inventory and audit log are
+	// kept in their files. InventoryStoreURL is the Redis URL of the redis
+	// store and the database file of the sqlite store.
+	storeType, err := service.GetAppSetting("InventoryStore")
+	if err != nil || len(storeType) == 0 {
+		storeType = routes.InventoryStoreFile
+	}
+	storeURL, err := service.GetAppSetting("InventoryStoreURL")
+	if err != nil {
+		storeURL = ""
+	}
+	if storeType == routes.InventoryStoreSQLite {
+		// the database file of the sqlite store is a data file too
+		storeURL = utilities.DataFileName(dataDirectory, storeURL)
+	}
+	store, err := routes.NewInventoryStore(storeType, storeURL, inventoryFileName, auditLogFileName, fileWriter)
+	if err != nil {
+		lc.Errorf("InventoryStore from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// TimeZone is optional, without it the kiosk's local time is UTC
+	timeZoneName, _ := service.GetAppSetting("TimeZone")
+	timeZone, err := routes.LoadTimeZone(timeZoneName)
+	if err != nil {
+		lc.Errorf("TimeZone from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// AuditLogMaxEntries and AuditLogMaxAge are optional, without them the
+	// audit log is never rotated
+	auditLogRotation := routes.AuditLogRotationPolicy{Directory: filepath.Join(filepath.Dir(auditLogFileName), "auditlog-archive")}
+	maxEntries, err := service.GetAppSetting("AuditLogMaxEntries")
+	if err == nil && len(maxEntries) > 0 {
+		auditLogRotation.MaxEntries, err = strconv.Atoi(maxEntries)
+		if err != nil || auditLogRotation.MaxEntries < 0 {
+			lc.Errorf("AuditLogMaxEntries from ApplicationSettings must be a whole number that is not negative")
+			os.Exit(1)
+		}
+	}
+	maxAge, err := service.GetAppSetting("AuditLogMaxAge")
+	if err == nil && len(maxAge) > 0 {
+		auditLogRotation.MaxAge, err = time.ParseDuration(maxAge)
+		if err != nil || auditLogRotation.MaxAge < 0 {
+			lc.Errorf("AuditLogMaxAge from ApplicationSettings must be a duration that is not negative")
+			os.Exit(1)
+		}
+	}
+	archiveDirectory, err := service.GetAppSetting("AuditLogArchiveDirectory")
+	if err == nil && len(archiveDirectory) > 0 {
+		auditLogRotation.Directory = utilities.DataFileName(dataDirectory, archiveDirectory)
+	}
+	rotationInterval := routes.DefaultAuditLogRotationInterval
+	interval, err = service.GetAppSetting("AuditLogRotationInterval")
+	if err == nil && len(interval) > 0 {
+		rotationInterval, err = time.ParseDuration(interval)
+		if err != nil || rotationInterval <= 0 {
+			lc.Errorf("AuditLogRotationInterval from ApplicationSettings must be a positive duration")
+			os.Exit(1)
+		}
+	}
+	if auditLogRotation.Enabled() {
+		if err := utilities.ValidateDataDirectory(auditLogRotation.Directory); err != nil {
+			lc.Errorf("AuditLogArchiveDirectory from ApplicationSettings must be writable: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// NegativeStockPolicy is optional, by default deltas may take the units
+	// on hand below zero
+	negativeStockPolicy, err := service.GetAppSetting("NegativeStockPolicy")
+	if err != nil || len(negativeStockPolicy) == 0 {
+		negativeStockPolicy = routes.NegativeStockAllow
+	}
+	if err := routes.ValidateNegativeStockPolicy(negativeStockPolicy); err != nil {
+		lc.Errorf("NegativeStockPolicy from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// AuthTokenSecret is optional, with it the administrative routes need
+	// the token of a maintainer card from ms-authentication
+	tokenSecret, _ := service.GetAppSetting("AuthTokenSecret")
+	tokenVerifier := utilities.NewTokenVerifier(tokenSecret)
+	if tokenVerifier == nil {
+		lc.Warn("AuthTokenSecret is not set in ApplicationSettings, the administrative routes are open")
+	}
+
+	// WMSEndpoint is optional, with it the stock changes are posted to the
+	// warehouse management system
+	wmsOptions := routes.WMSOptions{}
+	wmsOptions.Endpoint, _ = service.GetAppSetting("WMSEndpoint")
+	wmsOptions.KioskID, _ = service.GetAppSetting("KioskID")
+	fieldMapping, _ := service.GetAppSetting("WMSFieldMapping")
+	wmsOptions.FieldMapping, err = routes.ParseWMSFieldMapping(fieldMapping)
+	if err != nil {
+		lc.Errorf("WMSFieldMapping from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	batchInterval, err := service.GetAppSetting("WMSBatchInterval")
+	if err == nil && len(batchInterval) > 0 {
+		wmsOptions.BatchInterval, err = time.ParseDuration(batchInterval)
+		if err != nil || wmsOptions.BatchInterval < 0 {
+			lc.Errorf("WMSBatchInterval from ApplicationSettings must be a duration that is not negative")
+			os.Exit(1)
+		}
+	}
+	batchSize, err := service.GetAppSetting("WMSBatchSize")
+	if err == nil && len(batchSize) > 0 {
+		wmsOptions.BatchSize, err = strconv.Atoi(batchSize)
+		if err != nil || wmsOptions.BatchSize < 0 {
+			lc.Errorf("WMSBatchSize from ApplicationSettings must be a whole number that is not negative")
+			os.Exit(1)
+		}
+	}
+	wmsExporter := routes.NewWMSExporter(lc, wmsOptions)
+	if wmsExporter != nil {
+		lc.Infof("stock changes will be posted to the WMS at %s", wmsOptions.Endpoint)
+	}
+
+	controller := routes.NewController(lc, service, auditLogFileName, inventoryFileName, fileWriter, slowRequestThreshold, maxBodySize, eventTopic, store, timeZone, auditLogRotation, negativeStockPolicy, tokenVerifier, wmsExporter)
+	// a running marker left by the previous run means it crashed, so the
+	// inventory and audit log files are recovered before any traffic is served
+	markerName := utilities.MarkerFileName(inventoryFileName, serviceKey)
+	if err := controller.RecoverData(markerName); err != nil {
+		lc.Errorf("failed to recover data files: %s", err.Error())
+		os.Exit(1)
+	}
+	if report := controller.Recovery(); report.CrashDetected {
+		lc.Warnf("previous run did not shut down cleanly, removed %d temporary files and quarantined %d data files", len(report.RemovedTempFiles), len(report.QuarantinedFiles))
+	}
+	// the inventory and audit log files are copied into a new store once
+	migrated, err := controller.MigrateInventory()
+	if err != nil {
+		lc.Errorf("failed to migrate inventory to the %s store: %s", storeType, err.Error())
+		os.Exit(1)
+	}
+	if len(migrated) > 0 {
+		lc.Infof("migrated %v to the %s store", migrated, storeType)
+	}
 
-	controller := routes.NewController(lc, service, auditLogFileName, inventoryFileName)
 	err = controller.AddAllRoutes()
 	if err != nil {
 		lc.Errorf("failed to add all Routes: %s", err.Error())
 		os.Exit(1)
 	}
+	go controller.RunAuditLogRotation(service.AppContext(), rotationInterval)
+	go wmsExporter.Run(service.AppContext())
+
 	if err := service.Run(); err != nil {
 		lc.Errorf("Run returned error: %s", err.Error())
 		os.Exit(1)
 	}
 
-	// Do any required cleanup here
+	if err := fileWriter.Sync(); err != nil {
+		lc.Errorf("failed to sync data files: %s", err.Error())
+		os.Exit(1)
+	}
+	if err := store.Close(); err != nil {
+		lc.Errorf("failed to close the inventory store: %s", err.Error())
+		os.Exit(1)
+	}
+	if err := utilities.MarkStopped(markerName); err != nil {
+		lc.Errorf("failed to remove running marker: %s", err.Error())
+		os.Exit(1)
+	}
 
 	os.Exit(0)
 }
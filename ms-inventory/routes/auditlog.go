@@ -0,0 +1,99 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseAuditLogFilter reads the audit log filter from the accountId, cardId,
+// sku, from, to, limit and offset query parameters. The from and to bounds
+// are RFC 3339 timestamps.
+func ParseAuditLogFilter(query url.Values) (AuditLogFilter, error) {
+	filter := AuditLogFilter{
+		CardID: strings.TrimSpace(query.Get("cardId")),
+		SKU:    strings.TrimSpace(query.Get("sku")),
+	}
+
+	if value := query.Get("accountId"); value != "" {
+		accountID, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, errors.New("accountId must be a whole number")
+		}
+		filter.AccountID = &accountID
+	}
+	bounds := map[string]*time.Time{"from": &filter.From, "to": &filter.To}
+	for _, name := range []string{"from", "to"} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*bounds[name] = parsed
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, errors.New("from must be before to")
+	}
+	paging := map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset}
+	for _, name := range []string{"limit", "offset"} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative whole number", name)
+			}
+			*paging[name] = parsed
+		}
+	}
+	return filter, nil
+}
+
+// Matches reports whether the audit log entry passes every set field of the
+// filter. The paging fields are not part of the match.
+func (filter AuditLogFilter) Matches(auditLogEntry AuditLogEntry) bool {
+	createdAt := time.Unix(0, auditLogEntry.CreatedAt)
+	switch {
+	case filter.AccountID != nil && auditLogEntry.AccountID != *filter.AccountID:
+		return false
+	case filter.CardID != "" && auditLogEntry.CardID != filter.CardID:
+		return false
+	case filter.SKU != "" && !hasDeltaForSKU(auditLogEntry.InventoryDelta, filter.SKU):
+		return false
+	case !filter.From.IsZero() && createdAt.Before(filter.From):
+		return false
+	case !filter.To.IsZero() && !createdAt.Before(filter.To):
+		return false
+	}
+	return true
+}
+
+func hasDeltaForSKU(inventoryDelta []DeltaInventorySKU, sku string) bool {
+	for _, delta := range inventoryDelta {
+		if delta.SKU == sku {
+			return true
+		}
+	}
+	return false
+}
+
+// PageAuditLog returns the page of the audit log entries matching the
+// filter, in the order they were added
+func PageAuditLog(auditLog AuditLog, filter AuditLogFilter) AuditLogPage {
+	page := AuditLogPage{Data: []AuditLogEntry{}, Offset: filter.Offset, Limit: filter.Limit}
+	for _, auditLogEntry := range auditLog.Data {
+		if !filter.Matches(auditLogEntry) {
+			continue
+		}
+		if page.Total >= filter.Offset && (filter.Limit == 0 || len(page.Data) < filter.Limit) {
+			page.Data = append(page.Data, auditLogEntry)
+		}
+		page.Total++
+	}
+	return page
+}
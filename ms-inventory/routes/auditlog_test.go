@@ -0,0 +1,99 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuditLogFilter(t *testing.T) {
+	accountID := 2
+	tests := []struct {
+		Name           string
+		Query          string
+		ExpectedFilter AuditLogFilter
+		ExpectedError  string
+	}{
+		{"No filter", "", AuditLogFilter{}, ""},
+		{"Every filter", "accountId=2&cardId=0003292371&sku=4900002470&from=2019-09-06T00:00:00Z&to=2019-09-07T00:00:00Z&limit=10&offset=20",
+			AuditLogFilter{
+				AccountID: &accountID,
+				CardID:    "0003292371",
+				SKU:       "4900002470",
+				From:      time.Date(2019, time.September, 6, 0, 0, 0, 0, time.UTC),
+				To:        time.Date(2019, time.September, 7, 0, 0, 0, 0, time.UTC),
+				Limit:     10,
+				Offset:    20,
+			}, ""},
+		{"Invalid account ID", "accountId=two", AuditLogFilter{}, "accountId must be a whole number"},
+		{"Invalid from", "from=2019-09-06", AuditLogFilter{}, "from must be an RFC 3339 timestamp"},
+		{"From after to", "from=2019-09-07T00:00:00Z&to=2019-09-06T00:00:00Z", AuditLogFilter{}, "from must be before to"},
+		{"Negative limit", "limit=-1", AuditLogFilter{}, "limit must be a non-negative whole number"},
+		{"Invalid offset", "offset=first", AuditLogFilter{}, "offset must be a non-negative whole number"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			query, err := url.ParseQuery(currentTest.Query)
+			require.NoError(t, err)
+			filter, err := ParseAuditLogFilter(query)
+			if currentTest.ExpectedError != "" {
+				require.EqualError(t, err, currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedFilter, filter)
+		})
+	}
+}
+
+func TestPageAuditLog(t *testing.T) {
+	auditLog := getDefaultAuditsList()
+	for i := range auditLog.Data {
+		auditLog.Data[i].CreatedAt = time.Date(2019, time.September, 6, i, 0, 0, 0, time.UTC).UnixNano()
+	}
+	// the last entry only changes the first SKU
+	auditLog.Data[2].InventoryDelta = auditLog.Data[2].InventoryDelta[:1]
+	accountID := 2
+
+	tests := []struct {
+		Name          string
+		Filter        AuditLogFilter
+		ExpectedIDs   []string
+		ExpectedTotal int
+	}{
+		{"No filter", AuditLogFilter{}, []string{"1", "2", "3"}, 3},
+		{"Account", AuditLogFilter{AccountID: &accountID}, []string{"2"}, 1},
+		{"Card", AuditLogFilter{CardID: "0003621873"}, []string{"3"}, 1},
+		{"SKU", AuditLogFilter{SKU: "1200010735"}, []string{"1", "2"}, 2},
+		{"Time range includes from and excludes to", AuditLogFilter{
+			From: time.Date(2019, time.September, 6, 1, 0, 0, 0, time.UTC),
+			To:   time.Date(2019, time.September, 6, 2, 0, 0, 0, time.UTC),
+		}, []string{"2"}, 1},
+		{"Limit", AuditLogFilter{Limit: 2}, []string{"1", "2"}, 3},
+		{"Offset", AuditLogFilter{Offset: 1, Limit: 1}, []string{"2"}, 3},
+		{"Offset past the end", AuditLogFilter{Offset: 5}, []string{}, 3},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			page := PageAuditLog(auditLog, currentTest.Filter)
+			ids := []string{}
+			for _, auditLogEntry := range page.Data {
+				ids = append(ids, auditLogEntry.AuditEntryID)
+			}
+			assert.Equal(t, currentTest.ExpectedIDs, ids)
+			assert.Equal(t, currentTest.ExpectedTotal, page.Total)
+			assert.Equal(t, currentTest.Filter.Offset, page.Offset)
+			assert.Equal(t, currentTest.Filter.Limit, page.Limit)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errNoProductsSelected is returned when no product matches the category,
+// supplier or SKUs of a bulk activation request
+var errNoProductsSelected = errors.New("no products match the request")
+
+// Validate checks that the request selects the products by exactly one of
+// a category, a supplier or a list of SKUs, which are listed once, and that
+// a deactivation has a reason
+func (request BulkActivationRequest) Validate(isActive bool) error {
+	selectors := 0
+	for _, selected := range []bool{strings.TrimSpace(request.Category) != "", strings.TrimSpace(request.Supplier) != "", len(request.SKUs) > 0} {
+		if selected {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return errors.New("exactly one of category, supplier or skus is required")
+	}
+	skus := make(map[string]bool)
+	for _, sku := range request.SKUs {
+		switch {
+		case strings.TrimSpace(sku) == "":
+			return errors.New("skus must not be empty")
+		case skus[sku]:
+			return fmt.Errorf("SKU %s is listed more than once", sku)
+		}
+		skus[sku] = true
+	}
+	if !isActive && strings.TrimSpace(request.Reason) == "" {
+		return errors.New("reason is required to deactivate products")
+	}
+	return nil
+}
+
+// selects returns whether the request selects the product. Categories and
+// suppliers are matched regardless of case.
+func (request BulkActivationRequest) selects(product Product) bool {
+	switch {
+	case strings.TrimSpace(request.Category) != "":
+		return strings.EqualFold(product.Category, strings.TrimSpace(request.Category))
+	case strings.TrimSpace(request.Supplier) != "":
+		return strings.EqualFold(product.Supplier, strings.TrimSpace(request.Supplier))
+	}
+	for _, sku := range request.SKUs {
+		if product.SKU == sku {
+			return true
+		}
+	}
+	return false
+}
+
+// InventoryBulkDeactivatePost deactivates the products of a category, a
+// supplier or a list of SKUs at once, such as for a recall, so that they are
+// no longer vended
+func (c *Controller) InventoryBulkDeactivatePost(writer http.ResponseWriter, req *http.Request) {
+	c.setProductsActive(writer, req, false)
+}
+
+// InventoryBulkReactivatePost reactivates the products of a category, a
+// supplier or a list of SKUs at once, such as once a recall is over
+func (c *Controller) InventoryBulkReactivatePost(writer http.ResponseWriter, req *http.Request) {
+	c.setProductsActive(writer, req, true)
+}
+
+// setProductsActive sets whether the products selected by the request are
+// active, and publishes the products that changed. Nothing is changed when
+// any SKU of the request is not in inventory.
+func (c *Controller) setProductsActive(writer http.ResponseWriter, req *http.Request, isActive bool) {
+	var request BulkActivationRequest
+	if statusCode, err := c.decodeJSONBody(writer, req, &request); err != nil {
+		c.lc.Errorf("Failed to process the bulk activation request: %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the bulk activation request: " + err.Error()))
+		return
+	}
+	if err := request.Validate(isActive); err != nil {
+		c.lc.Errorf("Failed to process the bulk activation request: %s", err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Failed to process the bulk activation request: " + err.Error()))
+		return
+	}
+
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+	skus := make(map[string]bool)
+	for _, item := range inventoryItems.Data {
+		skus[item.SKU] = true
+	}
+	for _, sku := range request.SKUs {
+		if !skus[sku] {
+			c.lc.Errorf("Failed to process the bulk activation request: SKU %s does not exist in inventory", sku)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Failed to process the bulk activation request: SKU " + sku + " does not exist in inventory"))
+			return
+		}
+	}
+
+	now := time.Now().UnixNano()
+	reason := strings.TrimSpace(request.Reason)
+	report := BulkActivationReport{IsActive: isActive, Reason: reason, Products: []Product{}, Unchanged: []string{}}
+	for i := range inventoryItems.Data {
+		product := &inventoryItems.Data[i]
+		if !request.selects(*product) {
+			continue
+		}
+		if product.IsActive == isActive {
+			report.Unchanged = append(report.Unchanged, product.SKU)
+			continue
+		}
+		product.IsActive = isActive
+		product.DeactivationReason = ""
+		if !isActive {
+			product.DeactivationReason = reason
+		}
+		product.UpdatedAt = now
+		product.Version++
+		report.Products = append(report.Products, *product)
+	}
+	if len(report.Products) == 0 && len(report.Unchanged) == 0 {
+		c.lc.Infof("Failed to process the bulk activation request: %s", errNoProductsSelected.Error())
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte("Failed to process the bulk activation request: " + errNoProductsSelected.Error()))
+		return
+	}
+
+	if len(report.Products) > 0 {
+		if err := c.inventoryStore().SaveInventory(inventoryItems); err != nil {
+			c.lc.Errorf("Failed to write inventory data: %s", err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("Failed to write inventory data: " + err.Error()))
+			return
+		}
+		c.publishInventoryEvent(InventoryEventProductUpdated, report.Products)
+	}
+	if isActive {
+		c.lc.Infof("Reactivated %d product(s), %d were already active", len(report.Products), len(report.Unchanged))
+	} else {
+		c.lc.Infof("Deactivated %d product(s) for %q, %d were already deactivated", len(report.Products), reason, len(report.Unchanged))
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		c.lc.Errorf("Failed to process the bulk activation report: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the bulk activation report: " + err.Error()))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(reportJSON)
+}
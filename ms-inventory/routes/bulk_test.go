@@ -0,0 +1,100 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkActivationRequestValidate(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Request       BulkActivationRequest
+		IsActive      bool
+		ExpectedError string
+	}{
+		{"category", BulkActivationRequest{Category: "soda", Reason: "recall"}, false, ""},
+		{"supplier", BulkActivationRequest{Supplier: "PepsiCo", Reason: "recall"}, false, ""},
+		{"skus", BulkActivationRequest{SKUs: []string{"4900002470"}, Reason: "recall"}, false, ""},
+		{"reactivate without a reason", BulkActivationRequest{Category: "soda"}, true, ""},
+		{"no selector", BulkActivationRequest{Reason: "recall"}, false, "exactly one of category, supplier or skus is required"},
+		{"two selectors", BulkActivationRequest{Category: "soda", Supplier: "PepsiCo", Reason: "recall"}, false, "exactly one of category, supplier or skus is required"},
+		{"empty sku", BulkActivationRequest{SKUs: []string{" "}, Reason: "recall"}, false, "skus must not be empty"},
+		{"duplicate sku", BulkActivationRequest{SKUs: []string{"4900002470", "4900002470"}, Reason: "recall"}, false, "SKU 4900002470 is listed more than once"},
+		{"deactivate without a reason", BulkActivationRequest{Category: "soda"}, false, "reason is required to deactivate products"},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			err := currentTest.Request.Validate(currentTest.IsActive)
+			if currentTest.ExpectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, currentTest.ExpectedError, err.Error())
+		})
+	}
+}
+
+func TestBulkActivationRoutes(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	c.inventoryItems = getDefaultProductsList()
+	c.inventoryItems.Data[0].Supplier = "Coca-Cola"
+	c.inventoryItems.Data[1].Supplier = "PepsiCo"
+	c.inventoryItems.Data[2].Supplier = "PepsiCo"
+	c.inventoryItems.Data[2].IsActive = false
+	require.NoError(t, c.WriteInventory())
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "http://localhost:48095/inventory/bulk-deactivate", bytes.NewBufferString(body)))
+		return w
+	}
+
+	w := post(c.InventoryBulkDeactivatePost, `{"skus":["9999999999","4900002470"],"reason":"recall"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Failed to process the bulk activation request: SKU 9999999999 does not exist in inventory", w.Body.String())
+	w = post(c.InventoryBulkDeactivatePost, `{"supplier":"Nestle","reason":"recall"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = post(c.InventoryBulkDeactivatePost, `{"supplier":"pepsico"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = post(c.InventoryBulkDeactivatePost, `{"supplier":"pepsico","reason":"recall R-42"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report BulkActivationReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.False(t, report.IsActive)
+	require.Len(t, report.Products, 1)
+	assert.Equal(t, "1200010735", report.Products[0].SKU)
+	assert.Equal(t, "recall R-42", report.Products[0].DeactivationReason)
+	assert.Equal(t, int64(1), report.Products[0].Version)
+	assert.Equal(t, []string{"1200050408"}, report.Unchanged, "products that are already inactive are not changed")
+
+	inventoryItems, err := c.GetInventoryItems()
+	require.NoError(t, err)
+	assert.True(t, inventoryItems.Data[0].IsActive)
+	assert.False(t, inventoryItems.Data[1].IsActive)
+	assert.Equal(t, "recall R-42", inventoryItems.Data[1].DeactivationReason)
+
+	w = post(c.InventoryBulkReactivatePost, `{"skus":["1200010735","4900002470"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.IsActive)
+	require.Len(t, report.Products, 1)
+	assert.Empty(t, report.Products[0].DeactivationReason)
+	assert.Equal(t, []string{"4900002470"}, report.Unchanged)
+
+	inventoryItems, err = c.GetInventoryItems()
+	require.NoError(t, err)
+	assert.True(t, inventoryItems.Data[1].IsActive)
+	assert.Empty(t, inventoryItems.Data[1].DeactivationReason)
+}
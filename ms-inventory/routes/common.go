@@ -7,7 +7,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // DeleteAllQueryString is a string used across this module to enable
@@ -16,18 +20,21 @@ const (
 	DeleteAllQueryString = "all"
 )
 
-// GetInventoryItems returns a list of InventoryItems by reading the inventory
-// JSON file
-func (c *Controller) GetInventoryItems() (inventoryItems Products, err error) {
-	data, err := os.ReadFile(c.inventoryFileName)
-	if err != nil {
-		return inventoryItems, fmt.Errorf("failed to read from inventory file: %s", err.Error())
-	}
-	if err := json.Unmarshal(data, &inventoryItems); err != nil {
-		return inventoryItems, fmt.Errorf("failed to unmarshal inventory file: %s", err.Error())
-	}
+// Search scores used to rank inventory search results, higher is better
+const (
+	searchScoreExactSKU      = 100
+	searchScoreExactName     = 90
+	searchScoreSKUPrefix     = 80
+	searchScoreNamePrefix    = 70
+	searchScoreWordPrefix    = 60
+	searchScoreNameSubstring = 50
+	searchScoreFuzzy         = 10
+)
 
-	return
+// GetInventoryItems returns a list of InventoryItems by loading the
+// inventory from the inventory store
+func (c *Controller) GetInventoryItems() (inventoryItems Products, err error) {
+	return c.inventoryStore().LoadInventory()
 }
 
 // GetInventoryItemBySKU returns an inventory item by reading from the
@@ -48,19 +55,10 @@ func (c *Controller) GetInventoryItemBySKU(SKU string) (inventoryItem Product, i
 	return Product{SKU: ""}, inventoryItems, nil
 }
 
-// GetAuditLog returns a list of audit log entries by reading from the
-// audit log JSON file
+// GetAuditLog returns a list of audit log entries by loading the audit log
+// from the inventory store
 func (c *Controller) GetAuditLog() (auditLog AuditLog, err error) {
-	data, err := os.ReadFile(c.auditLogFileName)
-	if err != nil {
-		return auditLog, fmt.Errorf("failed to read from audit log JSON file: %s", err.Error())
-	}
-
-	if err := json.Unmarshal(data, &auditLog); err != nil {
-		return auditLog, fmt.Errorf("failed to unmarshal audit log JSON file: %s", err.Error())
-	}
-
-	return
+	return c.inventoryStore().LoadAuditLog()
 }
 
 // GetAuditLogEntryByID returns an audit log entry by reading from the
@@ -81,42 +79,28 @@ func (c *Controller) GetAuditLogEntryByID(auditEntryID string) (auditLogEntry Au
 	return AuditLogEntry{}, auditLogEntries, nil
 }
 
-// DeleteInventory will reset the content of the inventory JSON file
+// DeleteInventory will reset the content of the inventory
 func (c *Controller) DeleteInventory() error {
-	c.lc.Debug("Inventory JSON content reset")
-	return c.WriteJSON(c.inventoryFileName, Products{Data: []Product{}})
+	c.lc.Debug("Inventory content reset")
+	return c.inventoryStore().SaveInventory(Products{Data: []Product{}})
 }
 
-// DeleteAuditLog will reset the content of the audit log JSON file
+// DeleteAuditLog will reset the content of the audit log
 func (c *Controller) DeleteAuditLog() error {
-	c.lc.Debug("Audit Log JSON content reset")
-	return c.WriteJSON(c.auditLogFileName, AuditLog{Data: []AuditLogEntry{}})
-}
-
-// WriteJSON is a shorthand for writing an interface to JSON
-func (c *Controller) WriteJSON(fileName string, content interface{}) error {
-	c.lc.Debugf("Writing: %s to Inventory JSON: %s", content, fileName)
-	data, err := json.Marshal(content)
-	if err != nil {
-		return fmt.Errorf("failed to marshal data: %s", err.Error())
-	}
-	err = os.WriteFile(fileName, data, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write data to file: %s", err.Error())
-	}
-	return nil
+	c.lc.Debug("Audit Log content reset")
+	return c.inventoryStore().SaveAuditLog(AuditLog{Data: []AuditLogEntry{}})
 }
 
 // WriteInventory is a shorthand for writing the inventory quickly
 func (c *Controller) WriteInventory() error {
-	c.lc.Debugf("Wrote: %s to Inventory: %s", c.inventoryItems, c.inventoryFileName)
-	return c.WriteJSON(c.inventoryFileName, c.inventoryItems)
+	c.lc.Debugf("Wrote: %s to Inventory", c.inventoryItems)
+	return c.inventoryStore().SaveInventory(c.inventoryItems)
 }
 
 // WriteAuditLog is a shorthand for writing the audit log quickly
 func (c *Controller) WriteAuditLog() error {
-	c.lc.Debugf("Wrote: %s to Inventory: %s", c.auditLog, c.auditLogFileName)
-	return c.WriteJSON(c.auditLogFileName, c.auditLog)
+	c.lc.Debugf("Wrote: %s to Audit Log", c.auditLog)
+	return c.inventoryStore().SaveAuditLog(c.auditLog)
 }
 
 // DeleteInventoryItem deletes an inventory item matching the
@@ -142,3 +126,328 @@ func (c *Controller) DeleteAuditLogEntry(auditLogEntry AuditLogEntry) {
 		}
 	}
 }
+
+// FilterInventoryItemsBySKU returns the inventory items with the given
+// SKUs, in inventory order
+func FilterInventoryItemsBySKU(inventoryItems Products, skus []string) Products {
+	wanted := make(map[string]bool)
+	for _, sku := range skus {
+		wanted[strings.TrimSpace(sku)] = true
+	}
+	filtered := Products{Data: []Product{}}
+	for _, item := range inventoryItems.Data {
+		if wanted[item.SKU] {
+			filtered.Data = append(filtered.Data, item)
+		}
+	}
+	return filtered
+}
+
+// ParseInventoryFilter reads the inventory filter from the productName,
+// isActive, minPrice, maxPrice, minUnitsOnHand, maxUnitsOnHand, belowMin,
+// barcode and category query parameters
+func ParseInventoryFilter(query url.Values) (InventoryFilter, error) {
+	filter := InventoryFilter{
+		ProductName: strings.TrimSpace(query.Get("productName")),
+		Barcode:     strings.TrimSpace(query.Get("barcode")),
+		Category:    strings.TrimSpace(query.Get("category")),
+	}
+
+	if value := query.Get("isActive"); value != "" {
+		isActive, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("isActive must be true or false")
+		}
+		filter.IsActive = &isActive
+	}
+	if value := query.Get("belowMin"); value != "" {
+		belowMin, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("belowMin must be true or false")
+		}
+		filter.BelowMin = belowMin
+	}
+
+	for name, price := range map[string]**float64{"minPrice": &filter.MinPrice, "maxPrice": &filter.MaxPrice} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative number", name)
+			}
+			*price = &parsed
+		}
+	}
+	for name, units := range map[string]**int{"minUnitsOnHand": &filter.MinUnitsOnHand, "maxUnitsOnHand": &filter.MaxUnitsOnHand} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a whole number", name)
+			}
+			*units = &parsed
+		}
+	}
+
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, fmt.Errorf("minPrice must not be above maxPrice")
+	}
+	if filter.MinUnitsOnHand != nil && filter.MaxUnitsOnHand != nil && *filter.MinUnitsOnHand > *filter.MaxUnitsOnHand {
+		return filter, fmt.Errorf("minUnitsOnHand must not be above maxUnitsOnHand")
+	}
+	return filter, nil
+}
+
+// Matches reports whether the inventory item passes every set field of the
+// filter
+func (filter InventoryFilter) Matches(item Product) bool {
+	switch {
+	case filter.ProductName != "" && !strings.Contains(strings.ToLower(item.ProductName), strings.ToLower(filter.ProductName)):
+		return false
+	case filter.IsActive != nil && item.IsActive != *filter.IsActive:
+		return false
+	case filter.MinPrice != nil && item.ItemPrice < *filter.MinPrice:
+		return false
+	case filter.MaxPrice != nil && item.ItemPrice > *filter.MaxPrice:
+		return false
+	case filter.MinUnitsOnHand != nil && item.UnitsOnHand < *filter.MinUnitsOnHand:
+		return false
+	case filter.MaxUnitsOnHand != nil && item.UnitsOnHand > *filter.MaxUnitsOnHand:
+		return false
+	case filter.BelowMin && item.UnitsOnHand >= item.MinRestockingLevel:
+		return false
+	case filter.Barcode != "" && item.Barcode != filter.Barcode:
+		return false
+	case filter.Category != "" && !strings.EqualFold(item.Category, filter.Category):
+		return false
+	}
+	return true
+}
+
+// FilterInventoryItems returns the inventory items matching the filter, in
+// inventory order
+func FilterInventoryItems(inventoryItems Products, filter InventoryFilter) Products {
+	filtered := Products{Data: []Product{}}
+	for _, item := range inventoryItems.Data {
+		if filter.Matches(item) {
+			filtered.Data = append(filtered.Data, item)
+		}
+	}
+	return filtered
+}
+
+// LookupInventoryItems returns the inventory items of the SKUs in the order
+// they are given, along with the SKUs that are not in inventory. Repeated
+// SKUs are only looked up once.
+func LookupInventoryItems(inventoryItems Products, skus []string) ProductBatch {
+	products := make(map[string]Product)
+	for _, item := range inventoryItems.Data {
+		products[item.SKU] = item
+	}
+	batch := ProductBatch{Data: []Product{}, NotFound: []string{}}
+	seen := make(map[string]bool)
+	for _, sku := range skus {
+		sku = strings.TrimSpace(sku)
+		if seen[sku] {
+			continue
+		}
+		seen[sku] = true
+		if product, ok := products[sku]; ok {
+			batch.Data = append(batch.Data, product)
+		} else {
+			batch.NotFound = append(batch.NotFound, sku)
+		}
+	}
+	return batch
+}
+
+// SearchInventoryItems returns the inventory items matching the query and
+// category, ranked from best to worst match. The query is matched against the
+// product name (case-insensitive substring, with a fuzzy fallback) and the SKU
+// (prefix). An empty query matches every item, and an empty category matches
+// every category.
+func SearchInventoryItems(inventoryItems []Product, query string, category string) []Product {
+	type rankedProduct struct {
+		product Product
+		score   int
+	}
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	var ranked []rankedProduct
+	for _, item := range inventoryItems {
+		if category != "" && !strings.EqualFold(item.Category, category) {
+			continue
+		}
+		score := searchScore(item, query)
+		if score == 0 {
+			continue
+		}
+		ranked = append(ranked, rankedProduct{product: item, score: score})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		// among equal scores, shorter names are the closer match
+		if len(ranked[i].product.ProductName) != len(ranked[j].product.ProductName) {
+			return len(ranked[i].product.ProductName) < len(ranked[j].product.ProductName)
+		}
+		return ranked[i].product.ProductName < ranked[j].product.ProductName
+	})
+
+	results := make([]Product, 0, len(ranked))
+	for _, r := range ranked {
+		results = append(results, r.product)
+	}
+	return results
+}
+
+// searchScore ranks how well a lower-cased query matches an inventory item.
+// A score of 0 means the item does not match at all.
+func searchScore(item Product, query string) int {
+	if query == "" {
+		return searchScoreFuzzy
+	}
+
+	name := strings.ToLower(item.ProductName)
+	sku := strings.ToLower(item.SKU)
+	switch {
+	case sku == query:
+		return searchScoreExactSKU
+	case name == query:
+		return searchScoreExactName
+	case strings.HasPrefix(sku, query):
+		return searchScoreSKUPrefix
+	case strings.HasPrefix(name, query):
+		return searchScoreNamePrefix
+	}
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == '-'
+	}) {
+		if strings.HasPrefix(word, query) {
+			return searchScoreWordPrefix
+		}
+	}
+	if strings.Contains(name, query) {
+		return searchScoreNameSubstring
+	}
+	if isSubsequence(query, name) {
+		return searchScoreFuzzy
+	}
+	return 0
+}
+
+// isSubsequence reports whether all characters of query appear in target in
+// order, which tolerates missing letters such as "mtndew" for "mountain dew"
+func isSubsequence(query string, target string) bool {
+	queryRunes := []rune(query)
+	position := 0
+	for _, r := range target {
+		if position < len(queryRunes) && r == queryRunes[position] {
+			position++
+		}
+	}
+	return position == len(queryRunes)
+}
+
+// availabilityTimeLayout is the layout of AvailabilityWindow start and end times
+const availabilityTimeLayout = "15:04"
+
+// IsAvailableAt reports whether the product may be vended at the given time
+func (product Product) IsAvailableAt(t time.Time) bool {
+	if len(product.Availability) == 0 {
+		return true
+	}
+	for _, window := range product.Availability {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate ensures the window's times and days can be parsed
+func (window AvailabilityWindow) Validate() error {
+	if _, err := time.Parse(availabilityTimeLayout, window.Start); err != nil {
+		return fmt.Errorf("invalid availability start time %q, expected HH:MM", window.Start)
+	}
+	if _, err := time.Parse(availabilityTimeLayout, window.End); err != nil {
+		return fmt.Errorf("invalid availability end time %q, expected HH:MM", window.End)
+	}
+	for _, day := range window.Days {
+		if _, ok := parseWeekday(day); !ok {
+			return fmt.Errorf("invalid availability day %q", day)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the time falls within the window. Invalid windows
+// never contain any time.
+func (window AvailabilityWindow) Contains(t time.Time) bool {
+	start, err := time.Parse(availabilityTimeLayout, window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(availabilityTimeLayout, window.End)
+	if err != nil {
+		return false
+	}
+
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+	minutes := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	switch {
+	case startMinutes <= endMinutes:
+		if minutes < startMinutes || minutes >= endMinutes {
+			return false
+		}
+	case minutes >= startMinutes:
+		// the window spans midnight and started today
+	case minutes < endMinutes:
+		// the window spans midnight and started yesterday
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, d := range window.Days {
+		if weekday, ok := parseWeekday(d); ok && weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWeekday accepts either full or three letter weekday names
+func parseWeekday(day string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(day, weekday.String()) || strings.EqualFold(day, weekday.String()[:3]) {
+			return weekday, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// parseAvailability converts a posted availability field into validated
+// availability windows
+func parseAvailability(value interface{}) ([]AvailabilityWindow, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal availability: %s", err.Error())
+	}
+	var windows []AvailabilityWindow
+	if err := json.Unmarshal(data, &windows); err != nil {
+		return nil, fmt.Errorf("availability must be a list of windows: %s", err.Error())
+	}
+	for _, window := range windows {
+		if err := window.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return windows, nil
+}
@@ -6,6 +6,7 @@ package routes
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
 	"github.com/stretchr/testify/require"
@@ -456,3 +457,77 @@ func TestGetAuditLogErrors(t *testing.T) {
 		require.LessOrEqual(t, len(auditsFromFile.Data), 0, "Expected audits list to be empty but it contained 1 or more entry")
 	})
 }
+
+// TestSearchInventoryItems tests the ranking and filtering of inventory search
+func TestSearchInventoryItems(t *testing.T) {
+	products := getDefaultProductsList()
+	products.Data[0].Category = "Soda"
+	products.Data[1].Category = "Diet Soda"
+	products.Data[2].Category = "Soda"
+
+	tests := []struct {
+		Name         string
+		Query        string
+		Category     string
+		ExpectedSKUs []string
+	}{
+		{"Exact SKU", "4900002470", "", []string{"4900002470"}},
+		{"SKU prefix", "1200", "", []string{"1200050408", "1200010735"}},
+		{"Name prefix ranks above substring", "mountain", "", []string{"1200050408", "1200010735"}},
+		{"Word prefix", "lemon", "", []string{"4900002470"}},
+		{"Name substring", "calorie", "", []string{"1200010735"}},
+		{"Fuzzy", "mtndw", "", []string{"1200050408", "1200010735"}},
+		{"Category filter", "mountain", "soda", []string{"1200050408"}},
+		{"Category only", "", "Diet Soda", []string{"1200010735"}},
+		{"No match", "pringles", "", []string{}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			results := SearchInventoryItems(products.Data, currentTest.Query, currentTest.Category)
+			actualSKUs := []string{}
+			for _, result := range results {
+				actualSKUs = append(actualSKUs, result.SKU)
+			}
+			require.Equal(t, currentTest.ExpectedSKUs, actualSKUs)
+		})
+	}
+}
+
+// TestIsAvailableAt tests availability windows, including windows that span
+// midnight and windows restricted to specific days
+func TestIsAvailableAt(t *testing.T) {
+	// 2023-01-02 is a Monday
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2023, 1, 2, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		Name         string
+		Availability []AvailabilityWindow
+		At           time.Time
+		Available    bool
+	}{
+		{"No windows", nil, monday(3, 0), true},
+		{"Inside window", []AvailabilityWindow{{Start: "06:00", End: "11:00"}}, monday(10, 59), true},
+		{"At window end", []AvailabilityWindow{{Start: "06:00", End: "11:00"}}, monday(11, 0), false},
+		{"Before window", []AvailabilityWindow{{Start: "06:00", End: "11:00"}}, monday(5, 59), false},
+		{"Second window", []AvailabilityWindow{{Start: "06:00", End: "11:00"}, {Start: "17:00", End: "20:00"}}, monday(18, 0), true},
+		{"Overnight window late", []AvailabilityWindow{{Start: "22:00", End: "02:00"}}, monday(23, 0), true},
+		{"Overnight window early", []AvailabilityWindow{{Start: "22:00", End: "02:00"}}, monday(1, 0), true},
+		{"Overnight window outside", []AvailabilityWindow{{Start: "22:00", End: "02:00"}}, monday(3, 0), false},
+		{"Matching day", []AvailabilityWindow{{Start: "06:00", End: "11:00", Days: []string{"mon"}}}, monday(7, 0), true},
+		{"Other day", []AvailabilityWindow{{Start: "06:00", End: "11:00", Days: []string{"Saturday"}}}, monday(7, 0), false},
+		{"Overnight window from previous day", []AvailabilityWindow{{Start: "22:00", End: "02:00", Days: []string{"Sun"}}}, monday(1, 0), true},
+		{"Invalid window", []AvailabilityWindow{{Start: "6am", End: "11:00"}}, monday(7, 0), false},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			product := Product{SKU: "4900002470", Availability: currentTest.Availability}
+			require.Equal(t, currentTest.Available, product.IsAvailableAt(currentTest.At))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errVersionRequired is returned for updates of existing products that do
+// not say which version of the product they were made against
+var errVersionRequired = errors.New("the version of the product being updated is required, in the version field or the If-Match header")
+
+// versionConflict is an update made against a version of a product that is
+// no longer current
+type versionConflict struct {
+	SKU             string
+	ExpectedVersion int64
+	CurrentVersion  int64
+}
+
+func (conflict versionConflict) Error() string {
+	return fmt.Sprintf("SKU %s is at version %d, not version %d", conflict.SKU, conflict.CurrentVersion, conflict.ExpectedVersion)
+}
+
+// productETag returns the entity tag of the version of a product
+func productETag(product Product) string {
+	return fmt.Sprintf("%q", strconv.FormatInt(product.Version, 10))
+}
+
+// parseIfMatch parses the version in an If-Match header, which may be
+// quoted and weak. It returns false when the header is not set.
+func parseIfMatch(req *http.Request) (int64, bool, error) {
+	value := strings.TrimSpace(req.Header.Get("If-Match"))
+	if value == "" {
+		return 0, false, nil
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("If-Match must be the version of the product, received %q", req.Header.Get("If-Match"))
+	}
+	return version, true, nil
+}
+
+// postedVersion returns the version a posted inventory item was made
+// against. The version field of the item is used, or else the If-Match
+// version when it is the only item posted.
+func postedVersion(postedInventoryItem map[string]interface{}, ifMatch int64, hasIfMatch bool, single bool) (int64, error) {
+	if value, ok := postedInventoryItem["version"]; ok && value != nil {
+		version, ok := value.(float64)
+		if !ok || version < 0 || version != float64(int64(version)) {
+			return 0, errors.New("version must be a non-negative whole number")
+		}
+		return int64(version), nil
+	}
+	if hasIfMatch && single {
+		return ifMatch, nil
+	}
+	return 0, errVersionRequired
+}
+
+// checkInventoryVersions checks that every posted item that updates an
+// existing product was made against its current version, so that two
+// operators, or an operator and a vend, cannot overwrite each other's
+// changes. New products do not need a version.
+func checkInventoryVersions(req *http.Request, postedInventoryItems []map[string]interface{}, inventoryItems Products) error {
+	ifMatch, hasIfMatch, err := parseIfMatch(req)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]int64)
+	for _, item := range inventoryItems.Data {
+		current[item.SKU] = item.Version
+	}
+	for _, postedInventoryItem := range postedInventoryItems {
+		sku, _ := postedInventoryItem["sku"].(string)
+		currentVersion, exists := current[sku]
+		if !exists {
+			continue
+		}
+		version, err := postedVersion(postedInventoryItem, ifMatch, hasIfMatch, len(postedInventoryItems) == 1)
+		if err != nil {
+			return fmt.Errorf("SKU %s: %w", sku, err)
+		}
+		if version != currentVersion {
+			return versionConflict{SKU: sku, ExpectedVersion: version, CurrentVersion: currentVersion}
+		}
+	}
+	return nil
+}
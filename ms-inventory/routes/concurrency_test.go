@@ -0,0 +1,121 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryPostVersion(t *testing.T) {
+	products := getDefaultProductsList()
+	products.Data[0].Version = 3
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+
+	tests := []struct {
+		Name               string
+		IfMatch            string
+		Body               string
+		ExpectedStatusCode int
+		ExpectedVersion    int64
+	}{
+		{"Current version", "", `[{"sku":"4900002470","itemPrice":2.49,"version":3}]`, http.StatusOK, 4},
+		{"Current version in If-Match", `"3"`, `[{"sku":"4900002470","itemPrice":2.49}]`, http.StatusOK, 4},
+		{"Weak If-Match", `W/"3"`, `[{"sku":"4900002470","itemPrice":2.49}]`, http.StatusOK, 4},
+		{"Version field over If-Match", `"2"`, `[{"sku":"4900002470","itemPrice":2.49,"version":3}]`, http.StatusOK, 4},
+		{"Stale version", "", `[{"sku":"4900002470","itemPrice":2.49,"version":2}]`, http.StatusConflict, 3},
+		{"Stale version in If-Match", `"2"`, `[{"sku":"4900002470","itemPrice":2.49}]`, http.StatusConflict, 3},
+		{"Stale version of another item", "", `[{"sku":"4900002470","itemPrice":2.49,"version":3},{"sku":"1200010735","itemPrice":2.49,"version":1}]`, http.StatusConflict, 3},
+		{"No version", "", `[{"sku":"4900002470","itemPrice":2.49}]`, http.StatusPreconditionRequired, 3},
+		{"If-Match with several items", `"3"`, `[{"sku":"4900002470","itemPrice":2.49},{"sku":"1200010735","itemPrice":2.49,"version":0}]`, http.StatusPreconditionRequired, 3},
+		{"Invalid If-Match", `"three"`, `[{"sku":"4900002470","itemPrice":2.49}]`, http.StatusBadRequest, 3},
+		{"Invalid version", "", `[{"sku":"4900002470","itemPrice":2.49,"version":"3"}]`, http.StatusBadRequest, 3},
+		{"New item without a version", "", `[{"sku":"4900002470","itemPrice":2.49,"version":3},{"sku":"9999999999","itemPrice":1.25}]`, http.StatusOK, 4},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			require.NoError(t, c.WriteInventory())
+			defer func() {
+				_ = os.Remove(c.inventoryFileName)
+			}()
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost:48096/inventory", bytes.NewBufferString(currentTest.Body))
+			req.Header.Set("Content-Type", "application/json")
+			if currentTest.IfMatch != "" {
+				req.Header.Set("If-Match", currentTest.IfMatch)
+			}
+			w := httptest.NewRecorder()
+			c.InventoryPost(w, req)
+			require.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+
+			inventoryItem, inventoryItems, err := c.GetInventoryItemBySKU("4900002470")
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedVersion, inventoryItem.Version)
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				assert.Equal(t, products, inventoryItems, "nothing is updated when a version does not match")
+			}
+		})
+	}
+}
+
+func TestInventoryVersionIncrements(t *testing.T) {
+	products := getDefaultProductsList()
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+	require.NoError(t, c.WriteInventory())
+	defer func() {
+		_ = os.Remove(c.inventoryFileName)
+	}()
+
+	// a manual edit made against the version read before a vend is rejected
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:48096/inventory/4900002470", nil)
+	req = mux.SetURLVars(req, map[string]string{"sku": "4900002470"})
+	w := httptest.NewRecorder()
+	c.InventoryItemGet(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `"0"`, etag)
+
+	req = httptest.NewRequest(http.MethodPost, "http://localhost:48096/inventory/delta", bytes.NewBufferString(`[{"SKU":"4900002470","delta":-1}]`))
+	w = httptest.NewRecorder()
+	c.DeltaInventorySKUPost(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var deltaResults []DeltaInventoryResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deltaResults))
+	assert.Equal(t, int64(1), deltaResults[0].Product.Version)
+
+	req = httptest.NewRequest(http.MethodPost, "http://localhost:48096/inventory", bytes.NewBufferString(`[{"sku":"4900002470","itemPrice":2.49}]`))
+	req.Header.Set("If-Match", etag)
+	w = httptest.NewRecorder()
+	c.InventoryPost(w, req)
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "Failed to process the posted inventory item(s): SKU 4900002470 is at version 1, not version 0", w.Body.String())
+
+	// a new product starts at version 1
+	req = httptest.NewRequest(http.MethodPost, "http://localhost:48096/inventory", bytes.NewBufferString(`[{"sku":"9999999999","itemPrice":1.25}]`))
+	w = httptest.NewRecorder()
+	c.InventoryPost(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	created, _, err := c.GetInventoryItemBySKU("9999999999")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.Version)
+}
@@ -6,9 +6,13 @@ package routes
 import (
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
+	bootstrapInterfaces "github.com/edgexfoundry/go-mod-bootstrap/v3/bootstrap/interfaces"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 type Controller struct {
@@ -18,61 +22,219 @@ type Controller struct {
 	auditLog          AuditLog
 	auditLogFileName  string
 	inventoryFileName string
+	fileWriter        *utilities.FileWriter
+	// store persists the inventory and audit log, nil keeps them in their
+	// files
+	store InventoryStore
+	// metricsManager registers the per-route latency timers, requests
+	// taking slowRequestThreshold or longer are logged
+	metricsManager       bootstrapInterfaces.MetricsManager
+	slowRequestThreshold time.Duration
+	// maxBodySize is the largest request body accepted, in bytes
+	maxBodySize int64
+	// recovery is the crash recovery done on start
+	recovery utilities.RecoveryReport
+	// eventTopic is the message bus topic inventory events are published
+	// to, empty disables publishing
+	eventTopic string
+	// timeZone is the kiosk's time zone that availability windows are in,
+	// UTC when it is nil
+	timeZone *time.Location
+	// auditLogRotation is when audit log entries are rotated into
+	// compressed segments
+	auditLogRotation AuditLogRotationPolicy
+	// negativeStockPolicy is how deltas that take more units than are on
+	// hand are applied, negative stock is allowed when it is empty
+	negativeStockPolicy string
+	// tokenVerifier checks the tokens of the administrative routes, nil
+	// leaves them open
+	tokenVerifier *utilities.TokenVerifier
+	// wmsExporter posts the stock changes to the warehouse management
+	// system, nil does not post them
+	wmsExporter *WMSExporter
+	// inventoryMutex is held while the inventory is read, changed and
+	// written, so that concurrent updates are not lost
+	inventoryMutex sync.Mutex
 }
 
-func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, auditLogFileName string, inventoryFileName string) Controller {
+func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, auditLogFileName string, inventoryFileName string, fileWriter *utilities.FileWriter, slowRequestThreshold time.Duration, maxBodySize int64, eventTopic string, store InventoryStore, timeZone *time.Location, auditLogRotation AuditLogRotationPolicy, negativeStockPolicy string, tokenVerifier *utilities.TokenVerifier, wmsExporter *WMSExporter) Controller {
 	return Controller{
-		lc:                lc,
-		service:           service,
-		inventoryFileName: inventoryFileName,
-		auditLogFileName:  auditLogFileName,
+		lc:                   lc,
+		service:              service,
+		inventoryFileName:    inventoryFileName,
+		auditLogFileName:     auditLogFileName,
+		fileWriter:           fileWriter,
+		metricsManager:       service.MetricsManager(),
+		slowRequestThreshold: slowRequestThreshold,
+		maxBodySize:          maxBodySize,
+		eventTopic:           eventTopic,
+		store:                store,
+		timeZone:             timeZone,
+		auditLogRotation:     auditLogRotation,
+		negativeStockPolicy:  negativeStockPolicy,
+		tokenVerifier:        tokenVerifier,
+		wmsExporter:          wmsExporter,
 	}
 }
 
 func (c *Controller) AddAllRoutes() error {
 	var err error
 
-	err = c.service.AddRoute("/inventory", c.InventoryGet, http.MethodGet)
+	err = c.service.AddRoute("/health", c.instrument("/health", http.MethodGet, c.HealthGet), http.MethodGet)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/inventory", c.InventoryPost, http.MethodPost)
+	err = c.service.AddRoute("/version", c.instrument("/version", http.MethodGet, utilities.VersionHandler(CurrentVersion())), http.MethodGet)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/inventory/delta", c.DeltaInventorySKUPost, http.MethodPost)
+	err = c.service.AddRoute("/inventory", c.instrument("/inventory", http.MethodGet, c.InventoryGet), http.MethodGet)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/inventory/{sku}", c.InventoryItemGet, http.MethodGet)
+	err = c.service.AddRoute("/inventory", c.instrument("/inventory", http.MethodPost, c.requireMaintainer(c.InventoryPost)), http.MethodPost)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/inventory/{sku}", c.InventoryDelete, http.MethodDelete)
+	err = c.service.AddRoute("/inventory/delta", c.instrument("/inventory/delta", http.MethodPost, c.DeltaInventorySKUPost), http.MethodPost)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/auditlog", c.AuditLogGetAll, http.MethodGet)
+	err = c.service.AddRoute("/inventory/returns", c.instrument("/inventory/returns", http.MethodPost, c.ContainerReturnPost), http.MethodPost)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/auditlog", c.AuditLogPost, http.MethodPost)
+	err = c.service.AddRoute("/inventory/import", c.instrument("/inventory/import", http.MethodPost, c.requireMaintainer(c.InventoryImportPost)), http.MethodPost)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/auditlog/{entry}", c.AuditLogGetEntry, http.MethodGet)
+	err = c.service.AddRoute("/inventory/batch", c.instrument("/inventory/batch", http.MethodPost, c.requireMaintainer(c.InventoryBatchPost)), http.MethodPost)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/auditlog/{entry}", c.AuditLogDelete, http.MethodDelete)
+	// the search, availability, export, movements and restock routes must be
+	// registered before /inventory/{sku} so that they are not treated as a SKU
+	err = c.service.AddRoute("/inventory/search", c.instrument("/inventory/search", http.MethodGet, c.InventorySearchGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/availability", c.instrument("/inventory/availability", http.MethodGet, c.InventoryAvailabilityGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/export", c.instrument("/inventory/export", http.MethodGet, c.InventoryExportGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/movements", c.instrument("/inventory/movements", http.MethodGet, c.StockMovementsGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/restock", c.instrument("/inventory/restock", http.MethodPost, c.requireMaintainer(c.RestockPost)), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/bulk-deactivate", c.instrument("/inventory/bulk-deactivate", http.MethodPost, c.requireMaintainer(c.InventoryBulkDeactivatePost)), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/bulk-reactivate", c.instrument("/inventory/bulk-reactivate", http.MethodPost, c.requireMaintainer(c.InventoryBulkReactivatePost)), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/restock/suggestions", c.instrument("/inventory/restock/suggestions", http.MethodGet, c.RestockSuggestionsGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/{sku}", c.instrument("/inventory/{sku}", http.MethodGet, c.InventoryItemGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/{sku}/priceHistory", c.instrument("/inventory/{sku}/priceHistory", http.MethodGet, c.PriceHistoryGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/{sku}/restore", c.instrument("/inventory/{sku}/restore", http.MethodPost, c.requireMaintainer(c.InventoryRestorePost)), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/{sku}/purge", c.instrument("/inventory/{sku}/purge", http.MethodDelete, c.requireMaintainer(c.InventoryPurgeDelete)), http.MethodDelete)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/inventory/{sku}", c.instrument("/inventory/{sku}", http.MethodDelete, c.requireMaintainer(c.InventoryDelete)), http.MethodDelete)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/planogram", c.instrument("/planogram", http.MethodGet, c.PlanogramGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/planogram", c.instrument("/planogram", http.MethodPost, c.requireMaintainer(c.PlanogramPost)), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/planogram/check", c.instrument("/planogram/check", http.MethodPost, c.PlanogramCheckPost), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/planogram/picklist", c.instrument("/planogram/picklist", http.MethodGet, c.PlanogramPickListGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/planogram/{shelf}/{lane}", c.instrument("/planogram/{shelf}/{lane}", http.MethodDelete, c.requireMaintainer(c.PlanogramDelete)), http.MethodDelete)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/auditlog", c.instrument("/auditlog", http.MethodGet, c.AuditLogGetAll), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/auditlog", c.instrument("/auditlog", http.MethodPost, c.AuditLogPost), http.MethodPost)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// the archive route must be registered before /auditlog/{entry} so that
+	// "archive" is not treated as an entry ID
+	err = c.service.AddRoute("/auditlog/archive", c.instrument("/auditlog/archive", http.MethodGet, c.AuditLogArchiveGet), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/auditlog/{entry}", c.instrument("/auditlog/{entry}", http.MethodGet, c.AuditLogGetEntry), http.MethodGet)
+	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/auditlog/{entry}", c.instrument("/auditlog/{entry}", http.MethodDelete, c.requireMaintainer(c.AuditLogDelete)), http.MethodDelete)
 	if errWithMsg := c.errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
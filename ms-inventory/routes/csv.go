@@ -0,0 +1,297 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// InventoryCSVColumns are the columns of an inventory CSV file, in the order
+// they are exported. The column names are the JSON field names of Product.
+var InventoryCSVColumns = []string{
+	"sku",
+	"productName",
+	"itemPrice",
+	"unitsOnHand",
+	"minRestockingLevel",
+	"maxRestockingLevel",
+	"isActive",
+	"category",
+	"deposit",
+	"taxCategory",
+	"currency",
+	"barcode",
+	"imageURL",
+	"weight",
+	"supplier",
+}
+
+// WriteInventoryCSV writes the inventory items as CSV, with a header row of
+// InventoryCSVColumns
+func WriteInventoryCSV(w io.Writer, inventoryItems []Product) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(InventoryCSVColumns); err != nil {
+		return err
+	}
+	for _, item := range inventoryItems {
+		record := []string{
+			item.SKU,
+			item.ProductName,
+			strconv.FormatFloat(item.ItemPrice, 'f', -1, 64),
+			strconv.Itoa(item.UnitsOnHand),
+			strconv.Itoa(item.MinRestockingLevel),
+			strconv.Itoa(item.MaxRestockingLevel),
+			strconv.FormatBool(item.IsActive),
+			item.Category,
+			strconv.FormatFloat(item.Deposit, 'f', -1, 64),
+			item.TaxCategory,
+			item.Currency,
+			item.Barcode,
+			item.ImageURL,
+			strconv.FormatFloat(item.Weight, 'f', -1, 64),
+			item.Supplier,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// ImportInventoryCSV applies the rows of an inventory CSV file to a copy of
+// the inventory, and returns it with a report of the created and updated
+// SKUs. The header row names the columns, in any order, and every other
+// column of InventoryCSVColumns is left unchanged. Empty cells also leave the
+// field unchanged, or at its default for new products. Rows with errors are
+// reported rather than applied, and an error is only returned when the file
+// itself cannot be read.
+func ImportInventoryCSV(r io.Reader, inventoryItems Products, now int64) (Products, InventoryImportReport, error) {
+	report := InventoryImportReport{Created: []string{}, Updated: []string{}, Errors: []ImportRowError{}}
+	imported := Products{Data: append([]Product{}, inventoryItems.Data...)}
+
+	csvReader := csv.NewReader(r)
+	// rows with the wrong number of cells are reported like any other
+	// row error, rather than stopping the import
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return imported, report, errors.New("the CSV file is empty, it must start with a header row")
+	}
+	if err != nil {
+		return imported, report, err
+	}
+	columns, err := parseInventoryCSVHeader(header)
+	if err != nil {
+		return imported, report, err
+	}
+
+	index := make(map[string]int)
+	for i, item := range imported.Data {
+		index[item.SKU] = i
+	}
+	seen := make(map[string]int)
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a malformed row cannot be told apart from the rows after it
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return imported, report, fmt.Errorf("the CSV file is malformed: %s", err.Error())
+			}
+			return imported, report, err
+		}
+		row, _ := csvReader.FieldPos(0)
+		if isEmptyCSVRecord(record) {
+			continue
+		}
+		report.Rows++
+
+		if len(record) != len(columns) {
+			report.Errors = append(report.Errors, ImportRowError{Row: row, Message: fmt.Sprintf("the row has %d cells, the header has %d", len(record), len(columns))})
+			continue
+		}
+		values := make(map[string]string)
+		for i, column := range columns {
+			values[column] = strings.TrimSpace(record[i])
+		}
+
+		sku := values["sku"]
+		if sku == "" {
+			report.Errors = append(report.Errors, ImportRowError{Row: row, Column: "sku", Message: "sku is required"})
+			continue
+		}
+		if previous, ok := seen[sku]; ok {
+			report.Errors = append(report.Errors, ImportRowError{Row: row, SKU: sku, Column: "sku", Message: fmt.Sprintf("sku is already imported by row %d", previous)})
+			continue
+		}
+		seen[sku] = row
+
+		i, exists := index[sku]
+		product := Product{SKU: sku, CreatedAt: now, IsActive: true, MaxRestockingLevel: 5}
+		if exists {
+			product = imported.Data[i]
+		}
+		rowErrors := applyInventoryCSVRow(&product, values)
+		for _, rowError := range rowErrors {
+			rowError.Row = row
+			rowError.SKU = sku
+			report.Errors = append(report.Errors, rowError)
+		}
+		if len(rowErrors) > 0 {
+			continue
+		}
+
+		product.UpdatedAt = now
+		product.Version++
+		if exists {
+			imported.Data[i] = product
+			report.Updated = append(report.Updated, sku)
+		} else {
+			index[sku] = len(imported.Data)
+			imported.Data = append(imported.Data, product)
+			report.Created = append(report.Created, sku)
+		}
+	}
+	return imported, report, nil
+}
+
+// parseInventoryCSVHeader maps the header row to InventoryCSVColumns,
+// ignoring case. The sku column is required.
+func parseInventoryCSVHeader(header []string) ([]string, error) {
+	known := make(map[string]string)
+	for _, column := range InventoryCSVColumns {
+		known[strings.ToLower(column)] = column
+	}
+
+	columns := make([]string, len(header))
+	found := make(map[string]bool)
+	for i, name := range header {
+		// spreadsheets may save a byte order mark at the start of the file
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		column, ok := known[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, the columns are %s", name, strings.Join(InventoryCSVColumns, ", "))
+		}
+		if found[column] {
+			return nil, fmt.Errorf("column %s is repeated", column)
+		}
+		found[column] = true
+		columns[i] = column
+	}
+	if !found["sku"] {
+		return nil, errors.New("the header row must have a sku column")
+	}
+	return columns, nil
+}
+
+// applyInventoryCSVRow sets the product fields of the non-empty cells, and
+// returns an error for every cell that is not valid
+func applyInventoryCSVRow(product *Product, values map[string]string) []ImportRowError {
+	var rowErrors []ImportRowError
+	invalid := func(column string, message string) {
+		rowErrors = append(rowErrors, ImportRowError{Column: column, Message: message})
+	}
+
+	for _, column := range InventoryCSVColumns {
+		value, ok := values[column]
+		if !ok || value == "" {
+			continue
+		}
+		switch column {
+		case "productName":
+			product.ProductName = value
+		case "category":
+			product.Category = value
+		case "taxCategory":
+			product.TaxCategory = value
+		case "supplier":
+			product.Supplier = value
+		case "currency":
+			if len(value) != 3 || strings.Trim(strings.ToUpper(value), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+				invalid(column, "currency must be a 3-letter ISO 4217 code")
+				continue
+			}
+			product.Currency = strings.ToUpper(value)
+		case "barcode":
+			if err := ValidateBarcode(value); err != nil {
+				invalid(column, err.Error())
+				continue
+			}
+			product.Barcode = value
+		case "imageURL":
+			if err := ValidateImageURL(value); err != nil {
+				invalid(column, err.Error())
+				continue
+			}
+			product.ImageURL = value
+		case "isActive":
+			isActive, err := strconv.ParseBool(value)
+			if err != nil {
+				invalid(column, "isActive must be true or false")
+				continue
+			}
+			product.IsActive = isActive
+			if isActive {
+				product.DeactivationReason = ""
+			}
+		case "itemPrice", "deposit", "weight":
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 {
+				invalid(column, column+" must be a non-negative number")
+				continue
+			}
+			switch column {
+			case "itemPrice":
+				product.ItemPrice = parsed
+			case "deposit":
+				product.Deposit = parsed
+			default:
+				product.Weight = parsed
+			}
+		case "unitsOnHand", "minRestockingLevel", "maxRestockingLevel":
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				invalid(column, column+" must be a non-negative whole number")
+				continue
+			}
+			switch column {
+			case "unitsOnHand":
+				product.UnitsOnHand = parsed
+			case "minRestockingLevel":
+				product.MinRestockingLevel = parsed
+			default:
+				product.MaxRestockingLevel = parsed
+			}
+		}
+	}
+
+	levelsSet := values["minRestockingLevel"] != "" || values["maxRestockingLevel"] != ""
+	if len(rowErrors) == 0 && levelsSet && product.MinRestockingLevel > product.MaxRestockingLevel {
+		invalid("minRestockingLevel", "minRestockingLevel must not be above maxRestockingLevel")
+	}
+	return rowErrors
+}
+
+// isEmptyCSVRecord reports whether every cell of the record is blank, such
+// as the trailing rows a spreadsheet may export
+func isEmptyCSVRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
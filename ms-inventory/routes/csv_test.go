@@ -0,0 +1,167 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryCSVRoundTrip(t *testing.T) {
+	products := getDefaultProductsList()
+	products.Data[0].Category = "soda, lemon-lime"
+	products.Data[0].Deposit = 0.25
+	products.Data[0].TaxCategory = "reduced"
+	products.Data[0].Currency = "EUR"
+	products.Data[0].Barcode = "049000024708"
+	products.Data[0].ImageURL = "https://example.com/sprite.png"
+	products.Data[0].Weight = 520.5
+	products.Data[1].IsActive = false
+	products.Data[1].UnitsOnHand = 7
+
+	var exported bytes.Buffer
+	require.NoError(t, WriteInventoryCSV(&exported, products.Data))
+	assert.True(t, strings.HasPrefix(exported.String(), strings.Join(InventoryCSVColumns, ",")+"\n"))
+
+	// importing the export into the same inventory only updates the time
+	// and version
+	imported, report, err := ImportInventoryCSV(&exported, products, 42)
+	require.NoError(t, err)
+	require.Empty(t, report.Errors)
+	assert.Empty(t, report.Created)
+	assert.Len(t, report.Updated, len(products.Data))
+	for i := range imported.Data {
+		expected := products.Data[i]
+		expected.UpdatedAt = 42
+		expected.Version++
+		assert.Equal(t, expected, imported.Data[i])
+	}
+}
+
+func TestImportInventoryCSV(t *testing.T) {
+	tests := []struct {
+		Name            string
+		CSV             string
+		ExpectedError   string
+		ExpectedCreated []string
+		ExpectedUpdated []string
+		ExpectedErrors  []ImportRowError
+	}{
+		{
+			Name:            "Create and update",
+			CSV:             "SKU,itemPrice,unitsOnHand\n4900002470,2.49,12\n9999999999,1.25,3\n",
+			ExpectedCreated: []string{"9999999999"},
+			ExpectedUpdated: []string{"4900002470"},
+		},
+		{
+			Name:            "Byte order mark and blank rows",
+			CSV:             "\ufeffsku,productName\n\n9999999999,Pringles\n,\n",
+			ExpectedCreated: []string{"9999999999"},
+		},
+		{
+			Name: "Row errors",
+			CSV:  "sku,itemPrice,unitsOnHand,isActive,currency,minRestockingLevel,maxRestockingLevel\n,1,1,true,USD,,\n4900002470,free,-1,maybe,dollars,,\n4900002470,1,1,true,USD,,\n1200010735,1,1,true,usd,5,2\n1200050408,1\n",
+			ExpectedErrors: []ImportRowError{
+				{Row: 2, Column: "sku", Message: "sku is required"},
+				{Row: 3, SKU: "4900002470", Column: "itemPrice", Message: "itemPrice must be a non-negative number"},
+				{Row: 3, SKU: "4900002470", Column: "unitsOnHand", Message: "unitsOnHand must be a non-negative whole number"},
+				{Row: 3, SKU: "4900002470", Column: "isActive", Message: "isActive must be true or false"},
+				{Row: 3, SKU: "4900002470", Column: "currency", Message: "currency must be a 3-letter ISO 4217 code"},
+				{Row: 4, SKU: "4900002470", Column: "sku", Message: "sku is already imported by row 3"},
+				{Row: 5, SKU: "1200010735", Column: "minRestockingLevel", Message: "minRestockingLevel must not be above maxRestockingLevel"},
+				{Row: 6, Message: "the row has 2 cells, the header has 7"},
+			},
+		},
+		{
+			Name: "Metadata",
+			CSV:  "sku,barcode,imageURL,weight\n4900002470,049000024708,https://example.com/sprite.png,520\n1200010735,049000024709,sprite.png,-1\n",
+			ExpectedErrors: []ImportRowError{
+				{Row: 3, SKU: "1200010735", Column: "barcode", Message: "barcode 049000024709 has an invalid check digit"},
+				{Row: 3, SKU: "1200010735", Column: "imageURL", Message: "imageURL must be an absolute http or https URL"},
+				{Row: 3, SKU: "1200010735", Column: "weight", Message: "weight must be a non-negative number"},
+			},
+		},
+		{
+			Name:          "Empty file",
+			CSV:           "",
+			ExpectedError: "the CSV file is empty, it must start with a header row",
+		},
+		{
+			Name:          "Unknown column",
+			CSV:           "sku,price\n4900002470,1\n",
+			ExpectedError: `unknown column "price"`,
+		},
+		{
+			Name:          "Repeated column",
+			CSV:           "sku,itemPrice,ITEMPRICE\n4900002470,1,2\n",
+			ExpectedError: "column itemPrice is repeated",
+		},
+		{
+			Name:          "Missing sku column",
+			CSV:           "productName\nPringles\n",
+			ExpectedError: "the header row must have a sku column",
+		},
+		{
+			Name:          "Malformed file",
+			CSV:           "sku,productName\n4900002470,\"Sprite\n",
+			ExpectedError: "the CSV file is malformed",
+		},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			products := getDefaultProductsList()
+			_, report, err := ImportInventoryCSV(strings.NewReader(currentTest.CSV), products, 42)
+			if currentTest.ExpectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			if currentTest.ExpectedErrors != nil {
+				assert.Equal(t, currentTest.ExpectedErrors, report.Errors)
+				return
+			}
+			assert.Empty(t, report.Errors)
+			assert.ElementsMatch(t, currentTest.ExpectedCreated, report.Created)
+			assert.ElementsMatch(t, currentTest.ExpectedUpdated, report.Updated)
+		})
+	}
+}
+
+func TestImportInventoryCSVFields(t *testing.T) {
+	products := getDefaultProductsList()
+	csvFile := "sku,productName,itemPrice,unitsOnHand,isActive,currency\n" +
+		"4900002470,,2.49,12,false,eur\n" +
+		"9999999999,Pringles,1.25,,,\n"
+
+	imported, report, err := ImportInventoryCSV(strings.NewReader(csvFile), products, 42)
+	require.NoError(t, err)
+	require.Empty(t, report.Errors)
+	assert.Equal(t, 2, report.Rows)
+
+	// empty cells leave existing fields unchanged, and units on hand are set
+	// rather than added
+	updated := imported.Data[0]
+	assert.Equal(t, products.Data[0].ProductName, updated.ProductName)
+	assert.Equal(t, 2.49, updated.ItemPrice)
+	assert.Equal(t, 12, updated.UnitsOnHand)
+	assert.False(t, updated.IsActive)
+	assert.Equal(t, "EUR", updated.Currency)
+	assert.Equal(t, products.Data[0].CreatedAt, updated.CreatedAt)
+	assert.Equal(t, int64(42), updated.UpdatedAt)
+	assert.Equal(t, products.Data[0].Version+1, updated.Version)
+
+	// new products get the same defaults as POST /inventory
+	created := imported.Data[len(imported.Data)-1]
+	assert.Equal(t, Product{SKU: "9999999999", ProductName: "Pringles", ItemPrice: 1.25, MaxRestockingLevel: 5, IsActive: true, CreatedAt: 42, UpdatedAt: 42, Version: 1}, created)
+
+	// the inventory passed in is not changed
+	assert.Equal(t, getDefaultProductsList(), products)
+}
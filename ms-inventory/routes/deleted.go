@@ -0,0 +1,200 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// PurgeConfirmQueryString is the query parameter that has to be true to
+// purge a deleted product, since it cannot be restored afterwards
+const PurgeConfirmQueryString = "confirm"
+
+// IncludeDeletedQueryString is the query parameter that includes the
+// deleted products in the inventory GET responses
+const IncludeDeletedQueryString = "includeDeleted"
+
+// GetDeletedInventoryItems returns the deleted products from the inventory
+// store, which are empty until the first product is deleted
+func (c *Controller) GetDeletedInventoryItems() (Products, error) {
+	deletedItems, err := c.inventoryStore().LoadDeletedInventory()
+	if errors.Is(err, ErrNotStored) {
+		return Products{Data: []Product{}}, nil
+	}
+	return deletedItems, err
+}
+
+// removeProduct returns the products without the product of the SKU, and
+// the removed product
+func removeProduct(inventoryItems Products, sku string) (Products, Product, bool) {
+	remaining := Products{Data: []Product{}}
+	var removed Product
+	found := false
+	for _, item := range inventoryItems.Data {
+		if item.SKU == sku && !found {
+			removed = item
+			found = true
+			continue
+		}
+		remaining.Data = append(remaining.Data, item)
+	}
+	return remaining, removed, found
+}
+
+// softDeleteInventoryItem marks the inventory item as deleted and moves it
+// from the inventory to the deleted products, replacing an earlier deleted
+// product with the same SKU. The deleted product is saved first, so that
+// the item is never lost if saving the inventory fails.
+func (c *Controller) softDeleteInventoryItem(inventoryItem Product, now time.Time) (Product, error) {
+	deletedItems, err := c.GetDeletedInventoryItems()
+	if err != nil {
+		return Product{}, err
+	}
+	inventoryItem.Deleted = true
+	inventoryItem.DeletedAt = now.UnixNano()
+	inventoryItem.UpdatedAt = now.UnixNano()
+	inventoryItem.Version++
+	deletedItems, _, _ = removeProduct(deletedItems, inventoryItem.SKU)
+	deletedItems.Data = append(deletedItems.Data, inventoryItem)
+	if err := c.inventoryStore().SaveDeletedInventory(deletedItems); err != nil {
+		return Product{}, err
+	}
+
+	c.inventoryItems, _, _ = removeProduct(c.inventoryItems, inventoryItem.SKU)
+	if err := c.WriteInventory(); err != nil {
+		return Product{}, err
+	}
+	return inventoryItem, nil
+}
+
+// InventoryRestorePost restores the deleted product of the SKU in the URL to
+// the inventory, with the details and stock it had when it was deleted
+func (c *Controller) InventoryRestorePost(writer http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+	if sku == "" {
+		c.lc.Error("Missing SKU in the restore request")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a valid inventory item in the form of /inventory/{sku}/restore"))
+		return
+	}
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	deletedItems, err := c.GetDeletedInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve deleted inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve deleted inventory items: " + err.Error()))
+		return
+	}
+	deletedItems, restoredItem, found := removeProduct(deletedItems, sku)
+	if !found {
+		c.lc.Infof("SKU %s is not a deleted inventory item", sku)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte("Deleted item does not exist"))
+		return
+	}
+
+	inventoryItem, inventoryItems, err := c.GetInventoryItemBySKU(sku)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	// a product added with the SKU since it was deleted is not replaced
+	if inventoryItem.SKU != "" {
+		c.lc.Errorf("SKU %s cannot be restored, it was added to inventory again", sku)
+		writer.WriteHeader(http.StatusConflict)
+		writer.Write([]byte("Item " + sku + " is already in inventory, delete it before restoring"))
+		return
+	}
+
+	restoredItem.Deleted = false
+	restoredItem.DeletedAt = 0
+	restoredItem.UpdatedAt = time.Now().UnixNano()
+	restoredItem.Version++
+	c.inventoryItems = inventoryItems
+	c.inventoryItems.Data = append(c.inventoryItems.Data, restoredItem)
+	// the product is back in inventory before it leaves the deleted
+	// products, so that it is never lost if saving fails
+	if err := c.WriteInventory(); err != nil {
+		c.lc.Errorf("Failed to write updated inventory: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to write updated inventory: " + err.Error()))
+		return
+	}
+	if err := c.inventoryStore().SaveDeletedInventory(deletedItems); err != nil {
+		c.lc.Errorf("Restored %s, but failed to remove it from the deleted inventory items: %s", sku, err.Error())
+	}
+	c.publishInventoryEvent(InventoryEventProductUpdated, []Product{restoredItem})
+
+	restoredItemJSON, err := json.Marshal(restoredItem)
+	if err != nil {
+		c.lc.Errorf("Failed to process the restored inventory item: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the restored inventory item: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully restored the item: %s to inventory", sku)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(restoredItemJSON)
+}
+
+// InventoryPurgeDelete permanently removes the deleted product of the SKU
+// in the URL. It has to be confirmed with the confirm=true query parameter,
+// and only deleted products can be purged.
+func (c *Controller) InventoryPurgeDelete(writer http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+	if sku == "" {
+		c.lc.Error("Missing SKU in the purge request")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a valid inventory item in the form of /inventory/{sku}/purge"))
+		return
+	}
+	if req.URL.Query().Get(PurgeConfirmQueryString) != "true" {
+		c.lc.Errorf("Purge of %s was not confirmed", sku)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Purging cannot be undone, please confirm it in the form of /inventory/{sku}/purge?confirm=true"))
+		return
+	}
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	deletedItems, err := c.GetDeletedInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve deleted inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve deleted inventory items: " + err.Error()))
+		return
+	}
+	deletedItems, purgedItem, found := removeProduct(deletedItems, sku)
+	if !found {
+		c.lc.Infof("SKU %s is not a deleted inventory item", sku)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte("Deleted item does not exist, only deleted items can be purged"))
+		return
+	}
+	if err := c.inventoryStore().SaveDeletedInventory(deletedItems); err != nil {
+		c.lc.Errorf("Failed to write deleted inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to write deleted inventory items: " + err.Error()))
+		return
+	}
+
+	purgedItemJSON, err := json.Marshal(purgedItem)
+	if err != nil {
+		c.lc.Errorf("Failed to process the purged inventory item: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the purged inventory item: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully purged the deleted item: %s", sku)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(purgedItemJSON)
+}
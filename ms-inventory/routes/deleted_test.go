@@ -0,0 +1,127 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	c.inventoryItems = getDefaultProductsList()
+	require.NoError(t, c.WriteInventory())
+	sku := c.inventoryItems.Data[0].SKU
+
+	request := func(handler http.HandlerFunc, method string, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		req = mux.SetURLVars(req, map[string]string{"sku": sku})
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	w := request(c.InventoryDelete, http.MethodDelete, "http://localhost:48095/inventory/"+sku)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var deletedItem Product
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deletedItem))
+	assert.True(t, deletedItem.Deleted)
+	assert.NotZero(t, deletedItem.DeletedAt)
+	assert.Equal(t, int64(1), deletedItem.Version)
+
+	// the deleted item is hidden unless it is asked for
+	assert.Equal(t, http.StatusNotFound, request(c.InventoryItemGet, http.MethodGet, "http://localhost:48095/inventory/"+sku).Code)
+	w = request(c.InventoryItemGet, http.MethodGet, "http://localhost:48095/inventory/"+sku+"?includeDeleted=true")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deletedItem))
+	assert.True(t, deletedItem.Deleted)
+
+	var inventoryItems Products
+	w = request(c.InventoryGet, http.MethodGet, "http://localhost:48095/inventory")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inventoryItems))
+	assert.Len(t, inventoryItems.Data, 2)
+	w = request(c.InventoryGet, http.MethodGet, "http://localhost:48095/inventory?includeDeleted=true")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inventoryItems))
+	assert.Len(t, inventoryItems.Data, 3)
+
+	// deleting it again finds nothing to delete
+	assert.Equal(t, http.StatusNotFound, request(c.InventoryDelete, http.MethodDelete, "http://localhost:48095/inventory/"+sku).Code)
+
+	w = request(c.InventoryRestorePost, http.MethodPost, "http://localhost:48095/inventory/"+sku+"/restore")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var restoredItem Product
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restoredItem))
+	assert.False(t, restoredItem.Deleted)
+	assert.Zero(t, restoredItem.DeletedAt)
+	assert.Equal(t, int64(2), restoredItem.Version)
+	assert.Equal(t, getDefaultProductsList().Data[0].MaxRestockingLevel, restoredItem.MaxRestockingLevel)
+	inventoryItem, _, err := c.GetInventoryItemBySKU(sku)
+	require.NoError(t, err)
+	assert.Equal(t, sku, inventoryItem.SKU)
+	deletedItems, err := c.GetDeletedInventoryItems()
+	require.NoError(t, err)
+	assert.Empty(t, deletedItems.Data)
+
+	assert.Equal(t, http.StatusNotFound, request(c.InventoryRestorePost, http.MethodPost, "http://localhost:48095/inventory/"+sku+"/restore").Code)
+}
+
+func TestRestoreConflict(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	c.inventoryItems = getDefaultProductsList()
+	require.NoError(t, c.WriteInventory())
+	deletedItem := getDefaultProductsList().Data[0]
+	deletedItem.Deleted = true
+	require.NoError(t, c.inventoryStore().SaveDeletedInventory(Products{Data: []Product{deletedItem}}))
+
+	// the SKU was added to inventory again after it was deleted
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:48095/inventory/"+deletedItem.SKU+"/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"sku": deletedItem.SKU})
+	w := httptest.NewRecorder()
+	c.InventoryRestorePost(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestInventoryPurgeDelete(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	c.inventoryItems = getDefaultProductsList()
+	require.NoError(t, c.WriteInventory())
+	deletedItem := Product{SKU: "0012000001", Deleted: true, DeletedAt: 1}
+	require.NoError(t, c.inventoryStore().SaveDeletedInventory(Products{Data: []Product{deletedItem}}))
+
+	tests := []struct {
+		Name               string
+		SKU                string
+		Query              string
+		ExpectedStatusCode int
+	}{
+		{"not confirmed", deletedItem.SKU, "", http.StatusBadRequest},
+		{"confirm false", deletedItem.SKU, "?confirm=false", http.StatusBadRequest},
+		{"not deleted", getDefaultProductsList().Data[0].SKU, "?confirm=true", http.StatusNotFound},
+		{"deleted", deletedItem.SKU, "?confirm=true", http.StatusOK},
+		{"already purged", deletedItem.SKU, "?confirm=true", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "http://localhost:48095/inventory/"+currentTest.SKU+"/purge"+currentTest.Query, nil)
+			req = mux.SetURLVars(req, map[string]string{"sku": currentTest.SKU})
+			w := httptest.NewRecorder()
+			c.InventoryPurgeDelete(w, req)
+			assert.Equal(t, currentTest.ExpectedStatusCode, w.Code, w.Body.String())
+		})
+	}
+
+	deletedItems, err := c.GetDeletedInventoryItems()
+	require.NoError(t, err)
+	assert.Empty(t, deletedItems.Data)
+	inventoryItems, err := c.GetInventoryItems()
+	require.NoError(t, err)
+	assert.Len(t, inventoryItems.Data, 3, "purging does not touch the inventory")
+}
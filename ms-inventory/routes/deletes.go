@@ -7,11 +7,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
-// InventoryDelete allows deletion of an inventory item or multiple items
+// InventoryDelete allows deletion of an inventory item or multiple items.
+// A deleted item is kept, with its deleted flag set, so that it can be
+// restored or looked up for earlier transactions until it is purged.
+// Deleting all items resets the inventory.
 func (c *Controller) InventoryDelete(writer http.ResponseWriter, req *http.Request) {
 	// find the requested SKU and exit if it's invalid
 	vars := mux.Vars(req)
@@ -22,6 +26,9 @@ func (c *Controller) InventoryDelete(writer http.ResponseWriter, req *http.Reque
 		writer.Write([]byte("Please enter a valid inventory item in the form of /inventory/{sku}"))
 		return
 	}
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
 	// if the user wants to delete all inventory, do it
 	if SKU == DeleteAllQueryString {
 		err := c.DeleteInventory()
@@ -31,6 +38,7 @@ func (c *Controller) InventoryDelete(writer http.ResponseWriter, req *http.Reque
 			writer.Write([]byte("Failed to properly reset inventory: " + err.Error()))
 			return
 		}
+		c.publishInventoryEvent(InventoryEventProductDeleted, nil)
 		emptyInventoryResponseJSON, err := json.Marshal(Products{Data: []Product{}})
 		if err != nil {
 			c.lc.Errorf("Failed to serialize empty inventory response: %s", err.Error())
@@ -58,15 +66,15 @@ func (c *Controller) InventoryDelete(writer http.ResponseWriter, req *http.Reque
 		writer.Write([]byte("Item does not exist"))
 		return
 	}
-	// delete the inventory item & write the modified inventory
-	c.DeleteInventoryItem(inventoryItemToDelete)
-	err = c.WriteInventory()
+	// move the inventory item to the deleted items & write the modified inventory
+	inventoryItemToDelete, err = c.softDeleteInventoryItem(inventoryItemToDelete, time.Now())
 	if err != nil {
 		c.lc.Errorf("Failed to write updated inventory: %s", err.Error())
 		writer.WriteHeader(http.StatusInternalServerError)
 		writer.Write([]byte("Failed to write updated inventory"))
 		return
 	}
+	c.publishInventoryEvent(InventoryEventProductDeleted, []Product{inventoryItemToDelete})
 	inventoryItemToDeleteJSON, err := json.Marshal(inventoryItemToDelete)
 	if err != nil {
 		c.lc.Errorf("Successfully deleted the item from inventory, but failed to serialize it so that it could be sent back to the requester: %s", err.Error())
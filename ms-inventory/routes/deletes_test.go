@@ -51,6 +51,7 @@ func TestInventoryDelete(t *testing.T) {
 			require.NoError(t, err)
 			defer func() {
 				_ = os.Remove(c.inventoryFileName)
+				_ = os.Remove(DeletedInventoryFileName(c.inventoryFileName))
 			}()
 
 			if currentTest.BadInventory {
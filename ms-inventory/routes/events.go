@@ -0,0 +1,54 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+)
+
+const (
+	// InventoryEventProductUpdated is published when products are added or
+	// their details, such as the price, are updated
+	InventoryEventProductUpdated = "ProductUpdated"
+	// InventoryEventProductDeleted is published when products are deleted.
+	// Deleting all inventory is published without SKUs.
+	InventoryEventProductDeleted = "ProductDeleted"
+	// InventoryEventStockUpdated is published when only the stock of
+	// products changes, through deltas or container returns
+	InventoryEventStockUpdated = "StockUpdated"
+)
+
+// InventoryEvent is published to the EdgeX message bus when the inventory
+// changes, so that services caching products know when to refresh them
+type InventoryEvent struct {
+	EventType string   `json:"eventType"`
+	SKUs      []string `json:"skus"`
+	Timestamp int64    `json:"timestamp,string"`
+}
+
+// publishInventoryEvent publishes an InventoryEvent for the products to the
+// configured topic. Events are not published when no topic is configured.
+// The inventory has already been saved, so a failure to publish is only
+// logged.
+func (c *Controller) publishInventoryEvent(eventType string, products []Product) {
+	if c.eventTopic == "" || c.service == nil {
+		return
+	}
+
+	event := InventoryEvent{
+		EventType: eventType,
+		SKUs:      []string{},
+		Timestamp: time.Now().UnixNano(),
+	}
+	for _, product := range products {
+		event.SKUs = append(event.SKUs, product.SKU)
+	}
+	if err := c.service.PublishWithTopic(c.eventTopic, event, common.ContentTypeJSON); err != nil {
+		c.lc.Errorf("Failed to publish %s event for SKUs %v: %s", eventType, event.SKUs, err.Error())
+		return
+	}
+	c.lc.Debugf("Published %s event for SKUs %v to %s", eventType, event.SKUs, c.eventTopic)
+}
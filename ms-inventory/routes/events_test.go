@@ -0,0 +1,113 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishInventoryEvent(t *testing.T) {
+	products := getDefaultProductsList()
+
+	tests := []struct {
+		Name          string
+		EventTopic    string
+		PublishError  error
+		ExpectPublish bool
+	}{
+		{"Published", "inventory/events", nil, true},
+		{"No topic", "", nil, false},
+		{"Publish failure", "inventory/events", errors.New("message bus unavailable"), true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockAppService := &mocks.ApplicationService{}
+			mockAppService.On("PublishWithTopic", mock.Anything, mock.Anything, mock.Anything).
+				Return(currentTest.PublishError)
+			c := Controller{
+				lc:         logger.NewMockClient(),
+				service:    mockAppService,
+				eventTopic: currentTest.EventTopic,
+			}
+
+			c.publishInventoryEvent(InventoryEventProductUpdated, products.Data[:2])
+
+			if !currentTest.ExpectPublish {
+				mockAppService.AssertNotCalled(t, "PublishWithTopic", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			mockAppService.AssertCalled(t, "PublishWithTopic", currentTest.EventTopic, mock.MatchedBy(func(event InventoryEvent) bool {
+				return event.EventType == InventoryEventProductUpdated && event.Timestamp > 0 &&
+					assert.ObjectsAreEqual([]string{products.Data[0].SKU, products.Data[1].SKU}, event.SKUs)
+			}), common.ContentTypeJSON)
+		})
+	}
+}
+
+func TestInventoryChangesPublishEvents(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Method            string
+		SKU               string
+		Body              string
+		ExpectedEventType string
+		ExpectedSKUs      []string
+	}{
+		{"Product updated", http.MethodPost, "", `[{"sku":"4900002470","itemPrice":2.49,"version":0}]`, InventoryEventProductUpdated, []string{"4900002470"}},
+		{"Stock updated", http.MethodPost, "delta", `[{"sku":"4900002470","delta":-1}]`, InventoryEventStockUpdated, []string{"4900002470"}},
+		{"Product deleted", http.MethodDelete, "4900002470", "", InventoryEventProductDeleted, []string{"4900002470"}},
+		{"All products deleted", http.MethodDelete, DeleteAllQueryString, "", InventoryEventProductDeleted, []string{}},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockAppService := &mocks.ApplicationService{}
+			mockAppService.On("PublishWithTopic", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				service:           mockAppService,
+				inventoryItems:    getDefaultProductsList(),
+				inventoryFileName: InventoryFileName,
+				eventTopic:        "inventory/events",
+			}
+			require.NoError(t, c.WriteInventory())
+			defer func() {
+				_ = os.Remove(c.inventoryFileName)
+			}()
+
+			req := httptest.NewRequest(currentTest.Method, "http://localhost:48095/inventory", bytes.NewBuffer([]byte(currentTest.Body)))
+			w := httptest.NewRecorder()
+			switch {
+			case currentTest.Method == http.MethodDelete:
+				req = mux.SetURLVars(req, map[string]string{"sku": currentTest.SKU})
+				c.InventoryDelete(w, req)
+			case currentTest.SKU == "delta":
+				c.DeltaInventorySKUPost(w, req)
+			default:
+				c.InventoryPost(w, req)
+			}
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+
+			mockAppService.AssertCalled(t, "PublishWithTopic", "inventory/events", mock.MatchedBy(func(event InventoryEvent) bool {
+				return event.EventType == currentTest.ExpectedEventType && assert.ObjectsAreEqual(currentTest.ExpectedSKUs, event.SKUs)
+			}), common.ContentTypeJSON)
+		})
+	}
+}
@@ -4,14 +4,29 @@
 package routes
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 // InventoryGet allows for the retrieval of the entire inventory
 func (c *Controller) InventoryGet(writer http.ResponseWriter, req *http.Request) {
+	filter, err := ParseInventoryFilter(req.URL.Query())
+	if err != nil {
+		c.lc.Errorf("Invalid inventory filter: %s", err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a valid inventory filter: " + err.Error()))
+		return
+	}
+
 	inventoryItems, err := c.GetInventoryItems()
 	c.inventoryItems = inventoryItems
 	if err != nil {
@@ -21,6 +36,29 @@ func (c *Controller) InventoryGet(writer http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	// a comma separated skus query parameter looks up several products in
+	// one request, SKUs that are not in inventory are left out
+	if skus := req.URL.Query().Get("skus"); skus != "" {
+		inventoryItems = FilterInventoryItemsBySKU(inventoryItems, strings.Split(skus, ","))
+	}
+	// deleted items are only included when asked for
+	if req.URL.Query().Get(IncludeDeletedQueryString) == "true" {
+		deletedItems, err := c.GetDeletedInventoryItems()
+		if err != nil {
+			c.lc.Errorf("Failed to retrieve deleted inventory items: %s", err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("Failed to retrieve deleted inventory items: " + err.Error()))
+			return
+		}
+		if skus := req.URL.Query().Get("skus"); skus != "" {
+			deletedItems = FilterInventoryItemsBySKU(deletedItems, strings.Split(skus, ","))
+		}
+		inventoryItems.Data = append(inventoryItems.Data, deletedItems.Data...)
+	}
+	// the filter query parameters narrow the items down further, such as
+	// belowMin=true for the items that need restocking
+	inventoryItems = FilterInventoryItems(inventoryItems, filter)
+
 	// No logic needs to be done here, since we are just reading the file
 	// and writing it back out. Simply marshaling it will validate its structure
 	inventoryItemsJSON, err := json.Marshal(inventoryItems)
@@ -34,7 +72,206 @@ func (c *Controller) InventoryGet(writer http.ResponseWriter, req *http.Request)
 	writer.Write(inventoryItemsJSON)
 }
 
-// InventoryItemGet allows for a single inventory item to be retrieved by SKU
+// InventoryBatchPost looks up the products of a list of SKUs in one request,
+// so that a whole cart can be resolved without looking up each SKU
+func (c *Controller) InventoryBatchPost(writer http.ResponseWriter, req *http.Request) {
+	var skuBatch SKUBatch
+	if statusCode, err := c.decodeJSONBody(writer, req, &skuBatch); err != nil {
+		c.lc.Errorf("Failed to process the posted SKU batch: %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the posted SKU batch: " + err.Error()))
+		return
+	}
+	if len(skuBatch.SKUs) == 0 {
+		c.lc.Error("SKU batch does not contain any SKUs")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter at least one SKU in the form of {\"skus\":[\"{sku}\"]}"))
+		return
+	}
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+
+	batch := LookupInventoryItems(inventoryItems, skuBatch.SKUs)
+	batchJSON, err := json.Marshal(batch)
+	if err != nil {
+		c.lc.Errorf("Failed to process inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process inventory items: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully looked up %d of %d inventory items", len(batch.Data), len(batch.Data)+len(batch.NotFound))
+	writer.Write(batchJSON)
+}
+
+// InventorySearchGet allows inventory items to be searched by name, SKU
+// prefix and category, in the form of
+// /inventory/search?q={query}&category={category}&limit={limit}
+func (c *Controller) InventorySearchGet(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query().Get("q")
+	category := req.URL.Query().Get("category")
+	if query == "" && category == "" {
+		c.lc.Error("Inventory search requires a q or category query parameter")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a search in the form of /inventory/search?q={query}&category={category}"))
+		return
+	}
+
+	limit := 0
+	if limitStr := req.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			c.lc.Errorf("Invalid inventory search limit: %s", limitStr)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Please enter a valid non-negative limit"))
+			return
+		}
+	}
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+
+	results := SearchInventoryItems(inventoryItems.Data, query, category)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	resultsJSON, err := json.Marshal(Products{Data: results})
+	if err != nil {
+		c.lc.Errorf("Failed to process inventory search results: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process inventory search results: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully searched inventory items, found %d", len(results))
+	writer.Write(resultsJSON)
+}
+
+// InventoryAvailabilityGet returns whether each inventory item can currently
+// be vended according to its availability windows. An optional RFC 3339
+// "at" query parameter checks availability at a different time, which is
+// converted to the kiosk's time zone.
+func (c *Controller) InventoryAvailabilityGet(writer http.ResponseWriter, req *http.Request) {
+	at := time.Now()
+	if atStr := req.URL.Query().Get("at"); atStr != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			c.lc.Errorf("Invalid availability time: %s", atStr)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Please enter a valid RFC 3339 time in the form of /inventory/availability?at={time}"))
+			return
+		}
+	}
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+
+	availability := ProductAvailabilities{Data: []ProductAvailability{}}
+	for _, item := range inventoryItems.Data {
+		availability.Data = append(availability.Data, ProductAvailability{
+			SKU:         item.SKU,
+			ProductName: item.ProductName,
+			Available:   item.IsActive && item.IsAvailableAt(at.In(c.location())),
+		})
+	}
+
+	availabilityJSON, err := json.Marshal(availability)
+	if err != nil {
+		c.lc.Errorf("Failed to process inventory availability: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process inventory availability: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully retrieved inventory availability")
+	writer.Write(availabilityJSON)
+}
+
+// InventoryExportGet returns the inventory as a CSV file that can be edited
+// in a spreadsheet and imported again. It takes the same filter query
+// parameters as InventoryGet.
+func (c *Controller) InventoryExportGet(writer http.ResponseWriter, req *http.Request) {
+	filter, err := ParseInventoryFilter(req.URL.Query())
+	if err != nil {
+		c.lc.Errorf("Invalid inventory filter: %s", err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a valid inventory filter: " + err.Error()))
+		return
+	}
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+	inventoryItems = FilterInventoryItems(inventoryItems, filter)
+
+	var inventoryCSV bytes.Buffer
+	if err := WriteInventoryCSV(&inventoryCSV, inventoryItems.Data); err != nil {
+		c.lc.Errorf("Failed to export inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to export inventory items: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully exported %d inventory items", len(inventoryItems.Data))
+	writer.Header().Set("Content-Type", "text/csv")
+	writer.Header().Set("Content-Disposition", `attachment; filename="inventory.csv"`)
+	writer.Write(inventoryCSV.Bytes())
+}
+
+// StockMovementsGet returns the stock movements matching the filter query
+// parameters, with the total delta of each reason, so that sales can be told
+// apart from restocks, shrinkage and corrections
+func (c *Controller) StockMovementsGet(writer http.ResponseWriter, req *http.Request) {
+	filter, err := ParseStockMovementFilter(req.URL.Query())
+	if err != nil {
+		c.lc.Errorf("Invalid stock movement filter: %s", err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a valid stock movement filter: " + err.Error()))
+		return
+	}
+
+	stockMovements, err := c.GetStockMovements()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve stock movements: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve stock movements: " + err.Error()))
+		return
+	}
+
+	reportJSON, err := json.Marshal(ReportStockMovements(stockMovements, filter))
+	if err != nil {
+		c.lc.Errorf("Failed to process stock movements: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process stock movements: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully retrieved stock movements")
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(reportJSON)
+}
+
+// InventoryItemGet allows for a single inventory item to be retrieved by
+// SKU. A deleted item is only returned with includeDeleted=true, such as to
+// look up the product of an earlier transaction.
 func (c *Controller) InventoryItemGet(writer http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	sku := vars["sku"]
@@ -46,6 +283,16 @@ func (c *Controller) InventoryItemGet(writer http.ResponseWriter, req *http.Requ
 			writer.Write([]byte("Failed to get inventory item by SKU: " + err.Error()))
 			return
 		}
+		if inventoryItem.SKU == "" && req.URL.Query().Get(IncludeDeletedQueryString) == "true" {
+			deletedItems, err := c.GetDeletedInventoryItems()
+			if err != nil {
+				c.lc.Errorf("Failed to retrieve deleted inventory items: %s", err.Error())
+				writer.WriteHeader(http.StatusInternalServerError)
+				writer.Write([]byte("Failed to retrieve deleted inventory items: " + err.Error()))
+				return
+			}
+			_, inventoryItem, _ = removeProduct(deletedItems, sku)
+		}
 		if inventoryItem.SKU == "" {
 			c.lc.Infof("SKU is empty")
 			writer.WriteHeader(http.StatusNotFound)
@@ -60,6 +307,8 @@ func (c *Controller) InventoryItemGet(writer http.ResponseWriter, req *http.Requ
 			return
 		}
 		c.lc.Infof("Succcessfully got inventory item by SKU: %s", sku)
+		// the ETag is the version to update the item with, in If-Match
+		writer.Header().Set("ETag", productETag(inventoryItem))
 		writer.WriteHeader(http.StatusOK)
 		writer.Write([]byte(outputInventoryItemJSON))
 		return
@@ -69,8 +318,18 @@ func (c *Controller) InventoryItemGet(writer http.ResponseWriter, req *http.Requ
 	writer.Write([]byte("Please enter a valid inventory item in the form of /inventory/{sku}"))
 }
 
-// AuditLogGetAll allows all audit log entries to be retrieved
+// AuditLogGetAll allows the audit log entries to be retrieved, filtered by
+// the accountId, cardId, sku, from and to query parameters and paged with
+// the limit and offset query parameters
 func (c *Controller) AuditLogGetAll(writer http.ResponseWriter, req *http.Request) {
+	filter, err := ParseAuditLogFilter(req.URL.Query())
+	if err != nil {
+		c.lc.Errorf("Invalid audit log query: %s", err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Invalid audit log query: " + err.Error()))
+		return
+	}
+
 	auditLog, err := c.GetAuditLog()
 	c.auditLog = auditLog
 	if err != nil {
@@ -80,9 +339,7 @@ func (c *Controller) AuditLogGetAll(writer http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	// No logic needs to be done here, since we are just reading the file
-	// and writing it back out. Simply marshaling it will validate its structure
-	auditLogJSON, err := json.Marshal(auditLog)
+	auditLogJSON, err := json.Marshal(PageAuditLog(auditLog, filter))
 	if err != nil {
 		c.lc.Errorf("Failed to process audit log entries: %s", err.Error())
 		writer.WriteHeader(http.StatusInternalServerError)
@@ -99,18 +356,18 @@ func (c *Controller) AuditLogGetEntry(writer http.ResponseWriter, req *http.Requ
 	vars := mux.Vars(req)
 	entryID := vars["entry"]
 	if entryID != "" {
-		auditLogEntry, _, err := c.GetAuditLogEntryByID(entryID)
+		auditLogEntry, err := c.inventoryStore().LoadAuditLogEntry(entryID)
+		if errors.Is(err, ErrAuditLogEntryNotFound) || errors.Is(err, ErrNotStored) {
+			c.lc.Info("Audit log entry is not set")
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			c.lc.Errorf("Failed to get audit log entry ID: %s with error: %s", entryID, err.Error())
 			writer.WriteHeader(http.StatusInternalServerError)
 			writer.Write([]byte("Failed to get audit log entry by ID: " + err.Error()))
 			return
 		}
-		if auditLogEntry.AuditEntryID == "" {
-			c.lc.Info("Audit log entry is not set")
-			writer.WriteHeader(http.StatusNotFound)
-			return
-		}
 		outputAuditLogEntryJSON, err := json.Marshal(auditLogEntry)
 		if err != nil {
 			c.lc.Errorf("Failed to process the requested audit log entry item: %s with error: %s", entryID, err.Error())
@@ -126,3 +383,68 @@ func (c *Controller) AuditLogGetEntry(writer http.ResponseWriter, req *http.Requ
 	writer.WriteHeader(http.StatusBadRequest)
 	writer.Write([]byte("Please enter a valid entry ID in the form of /auditlog/{entry}"))
 }
+
+// AuditLogArchiveGet returns the entries of the rotated audit log segment
+// given in the "segment" query parameter, or the list of rotated segments
+// when no segment is given
+func (c *Controller) AuditLogArchiveGet(writer http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("segment")
+	if name == "" {
+		segments, err := c.GetAuditLogSegments()
+		if err != nil {
+			c.lc.Errorf("Failed to retrieve audit log segments: %s", err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("Failed to retrieve audit log segments: " + err.Error()))
+			return
+		}
+		segmentsJSON, err := json.Marshal(segments)
+		if err != nil {
+			c.lc.Errorf("Failed to process audit log segments: %s", err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("Failed to process audit log segments: " + err.Error()))
+			return
+		}
+		writer.Write(segmentsJSON)
+		return
+	}
+
+	if !isAuditLogSegmentName(name) {
+		c.lc.Errorf("Invalid audit log segment name: %s", name)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a segment name listed by /auditlog/archive"))
+		return
+	}
+	auditLog, err := c.GetAuditLogSegment(name)
+	if errors.Is(err, os.ErrNotExist) {
+		c.lc.Infof("Audit log segment %s does not exist", name)
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve audit log segment: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve audit log segment: " + err.Error()))
+		return
+	}
+	auditLogJSON, err := json.Marshal(auditLog)
+	if err != nil {
+		c.lc.Errorf("Failed to process audit log segment: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process audit log segment: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully retrieved audit log segment %s", name)
+	writer.Write(auditLogJSON)
+}
+
+// HealthGet reports whether the service recovered from a crash on start
+func (c *Controller) HealthGet(writer http.ResponseWriter, req *http.Request) {
+	healthJSON, err := json.Marshal(utilities.NewHealth(c.recovery))
+	if err != nil {
+		c.lc.Errorf("Failed to process health: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process health: " + err.Error()))
+		return
+	}
+	writer.Write(healthJSON)
+}
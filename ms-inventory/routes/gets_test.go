@@ -4,12 +4,15 @@
 package routes
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/gorilla/mux"
@@ -62,6 +65,121 @@ func TestInventoryGet(t *testing.T) {
 	}
 }
 
+// TestInventorySearchGet tests the function InventorySearchGet
+func TestInventorySearchGet(t *testing.T) {
+	// Product slice
+	products := getDefaultProductsList()
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+	tests := []struct {
+		Name               string
+		BadInventory       bool
+		URL                string
+		ExpectedStatusCode int
+		ExpectedCount      int
+	}{
+		{"Search by name", false, "http://localhost:48095/inventory/search?q=mountain", http.StatusOK, 2},
+		{"Search with limit", false, "http://localhost:48095/inventory/search?q=mountain&limit=1", http.StatusOK, 1},
+		{"Search with no results", false, "http://localhost:48095/inventory/search?q=pringles", http.StatusOK, 0},
+		{"Missing query", false, "http://localhost:48095/inventory/search", http.StatusBadRequest, 0},
+		{"Invalid limit", false, "http://localhost:48095/inventory/search?q=mountain&limit=-1", http.StatusBadRequest, 0},
+		{"Invalid inventory", true, "http://localhost:48095/inventory/search?q=mountain", http.StatusInternalServerError, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			if currentTest.BadInventory {
+				err := os.WriteFile(c.inventoryFileName, []byte("invalid json test"), 0644)
+				require.NoError(t, err)
+			} else {
+				err := c.WriteInventory()
+				require.NoError(t, err)
+			}
+			defer func() {
+				_ = os.Remove(c.inventoryFileName)
+			}()
+
+			req := httptest.NewRequest("GET", currentTest.URL, nil)
+			w := httptest.NewRecorder()
+			c.InventorySearchGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode == http.StatusOK {
+				var results Products
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+				require.Len(t, results.Data, currentTest.ExpectedCount)
+			}
+		})
+	}
+}
+
+// TestInventoryAvailabilityGet tests the function InventoryAvailabilityGet
+func TestInventoryAvailabilityGet(t *testing.T) {
+	// Product slice
+	products := getDefaultProductsList()
+	products.Data[0].Availability = []AvailabilityWindow{{Start: "06:00", End: "11:00"}}
+	products.Data[1].IsActive = false
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+	tests := []struct {
+		Name               string
+		BadInventory       bool
+		URL                string
+		ExpectedStatusCode int
+		ExpectedAvailable  []bool
+	}{
+		{"Inside window", false, "http://localhost:48095/inventory/availability?at=2023-01-02T07:00:00Z", http.StatusOK, []bool{true, false, true}},
+		{"Outside window", false, "http://localhost:48095/inventory/availability?at=2023-01-02T12:00:00Z", http.StatusOK, []bool{false, false, true}},
+		{"Offset converted to the kiosk's time zone", false, "http://localhost:48095/inventory/availability?at=2023-01-02T08:00:00%2B05:00", http.StatusOK, []bool{false, false, true}},
+		{"Invalid time", false, "http://localhost:48095/inventory/availability?at=noon", http.StatusBadRequest, nil},
+		{"Invalid inventory", true, "http://localhost:48095/inventory/availability", http.StatusInternalServerError, nil},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			if currentTest.BadInventory {
+				err := os.WriteFile(c.inventoryFileName, []byte("invalid json test"), 0644)
+				require.NoError(t, err)
+			} else {
+				err := c.WriteInventory()
+				require.NoError(t, err)
+			}
+			defer func() {
+				_ = os.Remove(c.inventoryFileName)
+			}()
+
+			req := httptest.NewRequest("GET", currentTest.URL, nil)
+			w := httptest.NewRecorder()
+			c.InventoryAvailabilityGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode == http.StatusOK {
+				var availability ProductAvailabilities
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&availability))
+				actualAvailable := []bool{}
+				for _, item := range availability.Data {
+					actualAvailable = append(actualAvailable, item.Available)
+				}
+				require.Equal(t, currentTest.ExpectedAvailable, actualAvailable)
+			}
+		})
+	}
+}
+
 // TestInventoryItemGet tests the function InventoryGet
 func TestInventoryItemGet(t *testing.T) {
 	// Product slice
@@ -165,6 +283,59 @@ func TestAuditLogGetAll(t *testing.T) {
 	}
 }
 
+// TestAuditLogGetAllFiltered tests filtering and paging the audit log with
+// query parameters
+func TestAuditLogGetAllFiltered(t *testing.T) {
+	c := Controller{
+		lc:               logger.NewMockClient(),
+		service:          nil,
+		auditLog:         getDefaultAuditsList(),
+		auditLogFileName: AuditLogFileName,
+	}
+	require.NoError(t, c.WriteAuditLog())
+	defer func() {
+		_ = os.Remove(c.auditLogFileName)
+	}()
+
+	tests := []struct {
+		Name               string
+		Query              string
+		ExpectedStatusCode int
+		ExpectedIDs        []string
+		ExpectedTotal      int
+	}{
+		{"Every entry", "", http.StatusOK, []string{"1", "2", "3"}, 3},
+		{"Card", "?cardId=0003292371", http.StatusOK, []string{"2"}, 1},
+		{"Paged", "?limit=1&offset=2", http.StatusOK, []string{"3"}, 3},
+		{"Time range without entries", "?from=2023-01-01T00:00:00Z", http.StatusOK, []string{}, 0},
+		{"Invalid limit", "?limit=ten", http.StatusBadRequest, nil, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:48096/auditlog"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.AuditLogGetAll(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var page AuditLogPage
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+			ids := []string{}
+			for _, auditLogEntry := range page.Data {
+				ids = append(ids, auditLogEntry.AuditEntryID)
+			}
+			assert.Equal(t, currentTest.ExpectedIDs, ids)
+			assert.Equal(t, currentTest.ExpectedTotal, page.Total)
+		})
+	}
+}
+
 // TestAuditLogGetEntry tests the ability to get all audit logs
 // related functions
 func TestAuditLogGetEntry(t *testing.T) {
@@ -222,3 +393,269 @@ func TestAuditLogGetEntry(t *testing.T) {
 		})
 	}
 }
+
+// TestInventoryGetBySKUs tests looking up several products in one request
+// with the skus query parameter
+func TestInventoryGetBySKUs(t *testing.T) {
+	products := getDefaultProductsList()
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+	require.NoError(t, c.WriteInventory())
+	defer func() {
+		_ = os.Remove(c.inventoryFileName)
+	}()
+
+	tests := []struct {
+		Name         string
+		SKUs         string
+		ExpectedSKUs []string
+	}{
+		{"All products", "", []string{products.Data[0].SKU, products.Data[1].SKU, products.Data[2].SKU}},
+		{"Several products", products.Data[2].SKU + "," + products.Data[0].SKU, []string{products.Data[0].SKU, products.Data[2].SKU}},
+		{"Unknown SKU left out", products.Data[1].SKU + ",0000000000", []string{products.Data[1].SKU}},
+		{"No matching SKUs", "0000000000", []string{}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:48095/inventory?skus="+currentTest.SKUs, nil)
+			w := httptest.NewRecorder()
+			c.InventoryGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+
+			var inventoryItems Products
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&inventoryItems))
+			skus := []string{}
+			for _, item := range inventoryItems.Data {
+				skus = append(skus, item.SKU)
+			}
+			assert.Equal(t, currentTest.ExpectedSKUs, skus)
+		})
+	}
+}
+
+// TestInventoryGetFiltered tests the filter query parameters of the function
+// InventoryGet
+func TestInventoryGetFiltered(t *testing.T) {
+	products := getDefaultProductsList()
+	products.Data[0].ItemPrice = 1.50
+	products.Data[0].UnitsOnHand = 2
+	products.Data[0].MinRestockingLevel = 5
+	products.Data[1].IsActive = false
+	products.Data[1].UnitsOnHand = 10
+	products.Data[2].ItemPrice = 2.50
+	products.Data[2].UnitsOnHand = 6
+	products.Data[2].MinRestockingLevel = 3
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+	require.NoError(t, c.WriteInventory())
+	defer func() {
+		_ = os.Remove(c.inventoryFileName)
+	}()
+
+	sprite, dietDew, dew := products.Data[0].SKU, products.Data[1].SKU, products.Data[2].SKU
+	tests := []struct {
+		Name               string
+		Query              string
+		ExpectedStatusCode int
+		ExpectedSKUs       []string
+	}{
+		{"Product name substring", "productName=MOUNTAIN", http.StatusOK, []string{dietDew, dew}},
+		{"Inactive products", "isActive=false", http.StatusOK, []string{dietDew}},
+		{"Active products", "isActive=true", http.StatusOK, []string{sprite, dew}},
+		{"Price range", "minPrice=1.75&maxPrice=2.50", http.StatusOK, []string{dietDew, dew}},
+		{"Units on hand thresholds", "minUnitsOnHand=2&maxUnitsOnHand=6", http.StatusOK, []string{sprite, dew}},
+		{"Below minimum restocking level", "belowMin=true", http.StatusOK, []string{sprite}},
+		{"Combined filters", "productName=mountain&isActive=true&maxUnitsOnHand=6", http.StatusOK, []string{dew}},
+		{"Combined with SKUs", "skus=" + sprite + "," + dietDew + "&isActive=true", http.StatusOK, []string{sprite}},
+		{"No matching products", "productName=pringles", http.StatusOK, []string{}},
+		{"Invalid isActive", "isActive=maybe", http.StatusBadRequest, nil},
+		{"Invalid price", "minPrice=cheap", http.StatusBadRequest, nil},
+		{"Negative price", "maxPrice=-1", http.StatusBadRequest, nil},
+		{"Invalid units on hand", "minUnitsOnHand=1.5", http.StatusBadRequest, nil},
+		{"Inverted price range", "minPrice=3&maxPrice=2", http.StatusBadRequest, nil},
+		{"Inverted units on hand range", "minUnitsOnHand=3&maxUnitsOnHand=2", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:48095/inventory?"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.InventoryGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var inventoryItems Products
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&inventoryItems))
+			skus := []string{}
+			for _, item := range inventoryItems.Data {
+				skus = append(skus, item.SKU)
+			}
+			assert.Equal(t, currentTest.ExpectedSKUs, skus)
+		})
+	}
+}
+
+// TestInventoryBatchPost tests the function InventoryBatchPost
+func TestInventoryBatchPost(t *testing.T) {
+	products := getDefaultProductsList()
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+
+	tests := []struct {
+		Name               string
+		BadInventory       bool
+		Body               string
+		ExpectedStatusCode int
+		ExpectedSKUs       []string
+		ExpectedNotFound   []string
+	}{
+		{"Several products", false, `{"skus":["` + products.Data[2].SKU + `","` + products.Data[0].SKU + `"]}`, http.StatusOK, []string{products.Data[2].SKU, products.Data[0].SKU}, []string{}},
+		{"Repeated SKU", false, `{"skus":["` + products.Data[1].SKU + `","` + products.Data[1].SKU + `"]}`, http.StatusOK, []string{products.Data[1].SKU}, []string{}},
+		{"Unknown SKU", false, `{"skus":["` + products.Data[1].SKU + `","0000000000"]}`, http.StatusOK, []string{products.Data[1].SKU}, []string{"0000000000"}},
+		{"No SKUs", false, `{"skus":[]}`, http.StatusBadRequest, nil, nil},
+		{"Empty body", false, ``, http.StatusBadRequest, nil, nil},
+		{"Invalid body", false, `{"skus":"` + products.Data[0].SKU + `"}`, http.StatusBadRequest, nil, nil},
+		{"Invalid inventory", true, `{"skus":["` + products.Data[0].SKU + `"]}`, http.StatusInternalServerError, nil, nil},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			if currentTest.BadInventory {
+				err := os.WriteFile(c.inventoryFileName, []byte("invalid json test"), 0644)
+				require.NoError(t, err)
+			} else {
+				err := c.WriteInventory()
+				require.NoError(t, err)
+			}
+			defer func() {
+				_ = os.Remove(c.inventoryFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48095/inventory/batch", bytes.NewBuffer([]byte(currentTest.Body)))
+			w := httptest.NewRecorder()
+			c.InventoryBatchPost(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var batch ProductBatch
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&batch))
+			skus := []string{}
+			for _, item := range batch.Data {
+				skus = append(skus, item.SKU)
+			}
+			assert.Equal(t, currentTest.ExpectedSKUs, skus)
+			assert.Equal(t, currentTest.ExpectedNotFound, batch.NotFound)
+		})
+	}
+}
+
+func TestInventoryExportGet(t *testing.T) {
+	products := getDefaultProductsList()
+	products.Data[1].IsActive = false
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+	require.NoError(t, c.WriteInventory())
+	defer func() {
+		_ = os.Remove(c.inventoryFileName)
+	}()
+
+	tests := []struct {
+		Name               string
+		Query              string
+		ExpectedStatusCode int
+		ExpectedProducts   []Product
+	}{
+		{"Entire inventory", "", http.StatusOK, products.Data},
+		{"Filtered", "isActive=false", http.StatusOK, products.Data[1:2]},
+		{"Invalid filter", "isActive=maybe", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:48095/inventory/export?"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.InventoryExportGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+			var expected bytes.Buffer
+			require.NoError(t, WriteInventoryCSV(&expected, currentTest.ExpectedProducts))
+			assert.Equal(t, expected.String(), w.Body.String())
+		})
+	}
+}
+
+func TestStockMovementsGet(t *testing.T) {
+	c := newStoreTestController(t, nil)
+
+	tests := []struct {
+		Name               string
+		Query              string
+		Stored             bool
+		ExpectedStatusCode int
+		ExpectedCount      int
+	}{
+		{"No movements yet", "", false, http.StatusOK, 0},
+		{"All movements", "", true, http.StatusOK, 4},
+		{"Filtered", "reason=restock", true, http.StatusOK, 1},
+		{"Invalid filter", "reason=theft", true, http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			if currentTest.Stored {
+				require.NoError(t, c.inventoryStore().SaveStockMovements(getDefaultStockMovements()))
+			}
+			req := httptest.NewRequest("GET", "http://localhost:48095/inventory/movements?"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.StockMovementsGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var report StockMovementReport
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+			assert.Len(t, report.Data, currentTest.ExpectedCount)
+		})
+	}
+}
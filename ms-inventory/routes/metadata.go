@@ -0,0 +1,136 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidateBarcode checks that a barcode is a UPC-A, EAN-8 or EAN-13 code:
+// 12, 8 or 13 digits ending with a valid GS1 check digit
+func ValidateBarcode(barcode string) error {
+	if len(barcode) != 8 && len(barcode) != 12 && len(barcode) != 13 {
+		return errors.New("barcode must be a UPC-A, EAN-8 or EAN-13 code of 12, 8 or 13 digits")
+	}
+	sum := 0
+	for i := range barcode {
+		digit := barcode[len(barcode)-1-i]
+		if digit < '0' || digit > '9' {
+			return errors.New("barcode must only contain digits")
+		}
+		// from the right, the check digit has a weight of 1 and the other
+		// digits alternate weights of 3 and 1
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+	if sum%10 != 0 {
+		return fmt.Errorf("barcode %s has an invalid check digit", barcode)
+	}
+	return nil
+}
+
+// ValidateImageURL checks that an image URL is an absolute http or https URL
+func ValidateImageURL(imageURL string) error {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("imageURL must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// validateProductMetadata validates the barcode, imageURL, weight and
+// supplier fields of a posted inventory item. An empty barcode or image URL
+// clears the field.
+func validateProductMetadata(postedInventoryItem map[string]interface{}) error {
+	for _, name := range []string{"barcode", "imageURL"} {
+		value, ok := postedInventoryItem[name]
+		if !ok || value == nil {
+			continue
+		}
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		var err error
+		if name == "barcode" {
+			err = ValidateBarcode(text)
+		} else {
+			err = ValidateImageURL(text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if value, ok := postedInventoryItem["weight"]; ok && value != nil {
+		weight, ok := value.(float64)
+		if !ok || weight < 0 {
+			return errors.New("weight must be a non-negative number of grams")
+		}
+	}
+	if value, ok := postedInventoryItem["supplier"]; ok && value != nil {
+		if _, ok := value.(string); !ok {
+			return errors.New("supplier must be a string")
+		}
+	}
+	return nil
+}
+
+// setProductMetadata sets the barcode, imageURL, weight and supplier fields
+// of a posted inventory item that was validated by validateProductMetadata
+func setProductMetadata(product *Product, postedInventoryItem map[string]interface{}) {
+	if barcode, ok := postedInventoryItem["barcode"].(string); ok {
+		product.Barcode = strings.TrimSpace(barcode)
+	}
+	if imageURL, ok := postedInventoryItem["imageURL"].(string); ok {
+		product.ImageURL = strings.TrimSpace(imageURL)
+	}
+	if weight, ok := postedInventoryItem["weight"].(float64); ok {
+		product.Weight = weight
+	}
+	if supplier, ok := postedInventoryItem["supplier"].(string); ok {
+		product.Supplier = strings.TrimSpace(supplier)
+	}
+}
+
+// productJSON is Product without its UnmarshalJSON method
+type productJSON Product
+
+// legacyBarcode holds the names other product catalogs use for the barcode
+type legacyBarcode struct {
+	UPC string `json:"upc"`
+	EAN string `json:"ean"`
+}
+
+// UnmarshalJSON decodes a product, also accepting the upc and ean names of
+// the barcode used by other product catalogs. Products stored before the
+// metadata fields were added decode unchanged.
+func (p *Product) UnmarshalJSON(data []byte) error {
+	var decoded productJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Barcode == "" {
+		// a upc or ean of another type, such as a number, is left out
+		// rather than failing the whole product
+		var legacy legacyBarcode
+		_ = json.Unmarshal(data, &legacy)
+		decoded.Barcode = legacy.UPC
+		if decoded.Barcode == "" {
+			decoded.Barcode = legacy.EAN
+		}
+	}
+	*p = Product(decoded)
+	return nil
+}
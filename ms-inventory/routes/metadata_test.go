@@ -0,0 +1,103 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBarcode(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Barcode       string
+		ExpectedError string
+	}{
+		{"UPC-A", "049000024708", ""},
+		{"EAN-13", "4006381333931", ""},
+		{"EAN-8", "96385074", ""},
+		{"Invalid check digit", "049000024709", "barcode 049000024709 has an invalid check digit"},
+		{"Wrong length", "4900002470", "barcode must be a UPC-A, EAN-8 or EAN-13 code of 12, 8 or 13 digits"},
+		{"Not digits", "04900002470A", "barcode must only contain digits"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			err := ValidateBarcode(currentTest.Barcode)
+			if currentTest.ExpectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, currentTest.ExpectedError)
+		})
+	}
+}
+
+func TestValidateImageURL(t *testing.T) {
+	assert.NoError(t, ValidateImageURL("https://example.com/images/sprite.png"))
+	assert.NoError(t, ValidateImageURL("http://ms-inventory:48095/images/sprite.png"))
+	for _, imageURL := range []string{"sprite.png", "/images/sprite.png", "ftp://example.com/sprite.png", "https://", "://example.com"} {
+		assert.EqualError(t, ValidateImageURL(imageURL), "imageURL must be an absolute http or https URL", imageURL)
+	}
+}
+
+func TestProductUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		Name            string
+		JSON            string
+		ExpectedProduct Product
+	}{
+		{
+			Name:            "Product without metadata",
+			JSON:            `{"sku":"4900002470","productName":"Sprite","createdAt":"42","updatedAt":"43","isActive":true}`,
+			ExpectedProduct: Product{SKU: "4900002470", ProductName: "Sprite", CreatedAt: 42, UpdatedAt: 43, IsActive: true},
+		},
+		{
+			Name:            "Product with metadata",
+			JSON:            `{"sku":"4900002470","barcode":"049000024708","imageURL":"https://example.com/sprite.png","weight":520.5,"category":"soda"}`,
+			ExpectedProduct: Product{SKU: "4900002470", Barcode: "049000024708", ImageURL: "https://example.com/sprite.png", Weight: 520.5, Category: "soda"},
+		},
+		{
+			Name:            "UPC name of the barcode",
+			JSON:            `{"sku":"4900002470","upc":"049000024708"}`,
+			ExpectedProduct: Product{SKU: "4900002470", Barcode: "049000024708"},
+		},
+		{
+			Name:            "EAN name of the barcode",
+			JSON:            `{"sku":"4900002470","ean":"4006381333931"}`,
+			ExpectedProduct: Product{SKU: "4900002470", Barcode: "4006381333931"},
+		},
+		{
+			Name:            "Barcode takes precedence",
+			JSON:            `{"sku":"4900002470","barcode":"049000024708","upc":"036000291452"}`,
+			ExpectedProduct: Product{SKU: "4900002470", Barcode: "049000024708"},
+		},
+		{
+			Name:            "Numeric UPC is left out",
+			JSON:            `{"sku":"4900002470","upc":49000024708}`,
+			ExpectedProduct: Product{SKU: "4900002470"},
+		},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			var product Product
+			require.NoError(t, json.Unmarshal([]byte(currentTest.JSON), &product))
+			assert.Equal(t, currentTest.ExpectedProduct, product)
+		})
+	}
+
+	// the products of a list use the same decoding
+	var products Products
+	require.NoError(t, json.Unmarshal([]byte(`{"data":[{"sku":"4900002470","upc":"049000024708"}]}`), &products))
+	assert.Equal(t, "049000024708", products.Data[0].Barcode)
+
+	var product Product
+	assert.Error(t, json.Unmarshal([]byte(`{"sku":4900002470}`), &product))
+}
@@ -0,0 +1,52 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// RouteLatencyMetricName prefixes the name of every route's latency timer,
+// enabling it in Writable.Telemetry.Metrics enables all of them
+const RouteLatencyMetricName = "RouteLatency"
+
+// statusRecorder records the status code written by a route handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+// instrument wraps a route handler to record the latency of each request
+// in a timer registered with the service's metrics manager, and to log
+// requests that take longer than the slow request threshold
+func (c *Controller) instrument(route string, method string, handler http.HandlerFunc) http.HandlerFunc {
+	timer := gometrics.NewTimer()
+	if c.metricsManager != nil {
+		name := RouteLatencyMetricName + "-" + method + "-" + route
+		tags := map[string]string{"route": route, "method": method}
+		if err := c.metricsManager.Register(name, timer, tags); err != nil {
+			c.lc.Warnf("failed to register %s metric: %s", name, err.Error())
+		}
+	}
+
+	return func(writer http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, statusCode: http.StatusOK}
+		handler(recorder, req)
+		elapsed := time.Since(start)
+
+		timer.Update(elapsed)
+		if c.slowRequestThreshold > 0 && elapsed >= c.slowRequestThreshold {
+			c.lc.Warnf("Slow request: %s %s took %v, over the %v threshold, and returned %d", method, req.URL.Path, elapsed, c.slowRequestThreshold, recorder.statusCode)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	bootstrapMocks "github.com/edgexfoundry/go-mod-bootstrap/v3/bootstrap/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		Name               string
+		HandlerDelay       time.Duration
+		StatusCode         int
+		SlowThreshold      time.Duration
+		ExpectedSlowLogged bool
+	}{
+		{"Fast request", 0, http.StatusOK, time.Second, false},
+		{"Slow request", 20 * time.Millisecond, http.StatusBadRequest, 10 * time.Millisecond, true},
+		{"Slow request logging disabled", 20 * time.Millisecond, http.StatusOK, 0, false},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			var timer gometrics.Timer
+			mockMetricsManager := &bootstrapMocks.MetricsManager{}
+			mockMetricsManager.On("Register", RouteLatencyMetricName+"-POST-/inventory/delta", mock.Anything, map[string]string{"route": "/inventory/delta", "method": http.MethodPost}).
+				Run(func(args mock.Arguments) {
+					timer = args.Get(1).(gometrics.Timer)
+				}).Return(nil)
+
+			mockLogger := &loggerSpy{LoggingClient: logger.NewMockClient()}
+			c := Controller{
+				lc:                   mockLogger,
+				metricsManager:       mockMetricsManager,
+				slowRequestThreshold: currentTest.SlowThreshold,
+			}
+
+			handler := c.instrument("/inventory/delta", http.MethodPost, func(writer http.ResponseWriter, req *http.Request) {
+				time.Sleep(currentTest.HandlerDelay)
+				writer.WriteHeader(currentTest.StatusCode)
+			})
+			mockMetricsManager.AssertExpectations(t)
+			require.NotNil(t, timer)
+
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "http://localhost:48095/inventory/delta", nil))
+
+			assert.Equal(t, currentTest.StatusCode, w.Code, "the handler's status code should be passed through")
+			assert.Equal(t, int64(1), timer.Count())
+			assert.GreaterOrEqual(t, timer.Max(), int64(currentTest.HandlerDelay))
+			assert.Equal(t, currentTest.ExpectedSlowLogged, mockLogger.warnings > 0)
+		})
+	}
+}
+
+func TestInstrumentWithoutMetricsManager(t *testing.T) {
+	c := Controller{lc: logger.NewMockClient()}
+	handler := c.instrument("/inventory", http.MethodGet, func(writer http.ResponseWriter, req *http.Request) {
+		writer.Write([]byte("[]"))
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "http://localhost:48095/inventory", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+// loggerSpy counts the warnings logged through it
+type loggerSpy struct {
+	logger.LoggingClient
+	warnings int
+}
+
+func (spy *loggerSpy) Warnf(msg string, args ...interface{}) {
+	spy.warnings++
+	spy.LoggingClient.Warnf(msg, args...)
+}
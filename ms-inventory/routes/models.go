@@ -3,13 +3,21 @@
 
 package routes
 
+import "time"
+
 // Products is the schema for the data that will be returned to the user
 // when hitting the inventory endpoint
 type Products struct {
 	Data []Product `json:"data"`
 }
 
-// Product is the schema for a single inventory item
+// Product is the schema for a single inventory item. ItemPrice and Deposit
+// are the prices of record, in major units of Currency such as dollars.
+// The inventory service only stores, filters and reports them; it never
+// adds or multiplies them. The ledger service converts each of them once
+// to integer minor units of its currency when it records a transaction,
+// and every amount of the transaction is calculated from those minor units
+// alone.
 type Product struct {
 	SKU                string  `json:"sku"`
 	ItemPrice          float64 `json:"itemPrice"`
@@ -20,6 +28,67 @@ type Product struct {
 	CreatedAt          int64   `json:"createdAt,string"`
 	UpdatedAt          int64   `json:"updatedAt,string"`
 	IsActive           bool    `json:"isActive"`
+	Category           string  `json:"category,omitempty"`
+	// Availability restricts when the product may be vended. A product
+	// without any availability windows is always available.
+	Availability []AvailabilityWindow `json:"availability,omitempty"`
+	// Deposit is the per-unit container deposit charged on top of ItemPrice
+	Deposit float64 `json:"deposit,omitempty"`
+	// ReturnedContainers counts the empty containers returned for this
+	// product. They are tracked separately from UnitsOnHand since they
+	// cannot be sold again.
+	ReturnedContainers int `json:"returnedContainers,omitempty"`
+	// TaxCategory selects the product's rate from the ledger's tax table
+	TaxCategory string `json:"taxCategory,omitempty"`
+	// Currency is the ISO 4217 code of ItemPrice and Deposit. Products
+	// without a currency are priced in the ledger's currency.
+	Currency string `json:"currency,omitempty"`
+	// Barcode is the product's UPC-A, EAN-8 or EAN-13 code, which the CV
+	// inference mapping and barcode scanners can key off
+	Barcode string `json:"barcode,omitempty"`
+	// ImageURL is an absolute http or https URL of the product's picture
+	// for the UI
+	ImageURL string `json:"imageURL,omitempty"`
+	// Weight is the weight of one unit in grams
+	Weight float64 `json:"weight,omitempty"`
+	// Supplier is who the product is sourced from, such as for a recall of
+	// all of its products
+	Supplier string `json:"supplier,omitempty"`
+	// DeactivationReason is why the product was deactivated in bulk, such as
+	// a recall, until it is reactivated
+	DeactivationReason string `json:"deactivationReason,omitempty"`
+	// Version is incremented on every change to the product. Updates of
+	// the product are made against the version they were read at, and are
+	// rejected once another change has been made.
+	Version int64 `json:"version"`
+	// Deleted is set on a product that was deleted, which is kept out of
+	// the inventory until it is restored or purged. DeletedAt is when it
+	// was deleted.
+	Deleted   bool  `json:"deleted,omitempty"`
+	DeletedAt int64 `json:"deletedAt,string,omitempty"`
+}
+
+// AvailabilityWindow is a daily window, in the kiosk's local time, during
+// which a product may be vended. Start and End use the 24-hour "15:04"
+// format, and a window whose End is before its Start spans midnight. Days
+// optionally restricts the window to weekdays such as "Mon" or "Sat", and
+// refers to the day the window starts on.
+type AvailabilityWindow struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
+}
+
+// ProductAvailability is the schema returned by the availability endpoint
+type ProductAvailability struct {
+	SKU         string `json:"sku"`
+	ProductName string `json:"productName"`
+	Available   bool   `json:"available"`
+}
+
+// ProductAvailabilities is a list of ProductAvailability
+type ProductAvailabilities struct {
+	Data []ProductAvailability `json:"data"`
 }
 
 // DeltaInventorySKU is required because we cannot unmarshal a delta
@@ -27,6 +96,163 @@ type Product struct {
 type DeltaInventorySKU struct {
 	SKU   string `json:"SKU"`
 	Delta int    `json:"delta"`
+	// Reason is why the stock moved, one of DeltaReasons. Deltas posted
+	// without one are sales, which is all the vending service posted before
+	// reasons were added.
+	Reason string       `json:"reason,omitempty"`
+	Source *DeltaSource `json:"source,omitempty"`
+}
+
+// DeltaInventoryResult is an inventory delta applied to a SKU, with the
+// units on hand before and after it
+type DeltaInventoryResult struct {
+	SKU string `json:"sku"`
+	// Delta is the posted delta, and AppliedDelta the part of it that was
+	// applied by the negative stock policy
+	Delta             int `json:"delta"`
+	AppliedDelta      int `json:"appliedDelta"`
+	UnitsOnHandBefore int `json:"unitsOnHandBefore"`
+	UnitsOnHandAfter  int `json:"unitsOnHandAfter"`
+	// Discrepancy is set when the delta took more units than were on hand
+	Discrepancy bool    `json:"discrepancy,omitempty"`
+	Product     Product `json:"product"`
+}
+
+// The reasons an inventory delta can be posted for
+const (
+	DeltaReasonSale       = "sale"
+	DeltaReasonRestock    = "restock"
+	DeltaReasonShrinkage  = "shrinkage"
+	DeltaReasonCorrection = "correction"
+	DeltaReasonSnapshot   = "snapshot"
+	// DeltaReasonTest is the reason of the items taken in the test vend of
+	// a technician, which are not sales
+	DeltaReasonTest = "test"
+)
+
+// DeltaReasons are the valid reasons of an inventory delta
+var DeltaReasons = []string{DeltaReasonSale, DeltaReasonRestock, DeltaReasonShrinkage, DeltaReasonCorrection, DeltaReasonSnapshot, DeltaReasonTest}
+
+// DeltaSource attributes an inventory delta to the service, user and vending
+// session it came from. Every field is optional.
+type DeltaSource struct {
+	Service   string `json:"service,omitempty"`
+	User      string `json:"user,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// StockMovement is an inventory delta that was applied to a SKU, with the
+// units on hand after it
+type StockMovement struct {
+	MovementID  string      `json:"movementId"`
+	SKU         string      `json:"sku"`
+	Delta       int         `json:"delta"`
+	UnitsOnHand int         `json:"unitsOnHand"`
+	Reason      string      `json:"reason"`
+	Source      DeltaSource `json:"source"`
+	CreatedAt   int64       `json:"createdAt,string"`
+}
+
+// StockMovements is the log of every applied inventory delta, oldest first
+type StockMovements struct {
+	Data []StockMovement `json:"data"`
+}
+
+// PriceChange is a price of a product, which is its price from EffectiveAt
+// until the next change of its price. ItemPrice is in major units of
+// Currency, the same as the ItemPrice of the product. The first change of a product added
+// after price history was kept is the price it was added with.
+type PriceChange struct {
+	ChangeID    string  `json:"changeId"`
+	SKU         string  `json:"sku"`
+	ItemPrice   float64 `json:"itemPrice"`
+	Currency    string  `json:"currency,omitempty"`
+	EffectiveAt int64   `json:"effectiveAt,string"`
+}
+
+// PriceHistory is the log of every price change, oldest first
+type PriceHistory struct {
+	Data []PriceChange `json:"data"`
+}
+
+// StockMovementFilter selects the stock movements returned by
+// GET /inventory/movements. Unset fields match every movement.
+type StockMovementFilter struct {
+	SKU       string
+	Reason    string
+	Service   string
+	User      string
+	SessionID string
+	// From and To bound the time of the movement, To is exclusive
+	From time.Time
+	To   time.Time
+}
+
+// StockMovementReport is the response to GET /inventory/movements. Totals
+// holds the sum of the deltas of the matching movements by reason.
+type StockMovementReport struct {
+	Data   []StockMovement `json:"data"`
+	Totals map[string]int  `json:"totals"`
+}
+
+// ContainerReturn is a number of empty containers returned for a SKU
+type ContainerReturn struct {
+	SKU   string `json:"sku"`
+	Count int    `json:"count"`
+}
+
+// InventoryFilter selects the inventory items returned by GET /inventory.
+// Unset fields match every item.
+type InventoryFilter struct {
+	// ProductName matches product names containing it, ignoring case
+	ProductName string
+	IsActive    *bool
+	// MinPrice and MaxPrice bound the item price, inclusive
+	MinPrice *float64
+	MaxPrice *float64
+	// MinUnitsOnHand and MaxUnitsOnHand bound the units on hand, inclusive
+	MinUnitsOnHand *int
+	MaxUnitsOnHand *int
+	// BelowMin matches items with fewer units on hand than their minimum
+	// restocking level, which need restocking
+	BelowMin bool
+	// Barcode matches the item with the barcode, as read by a scanner
+	Barcode string
+	// Category matches the items of the category, ignoring case
+	Category string
+}
+
+// SKUBatch is a list of SKUs to look up in one request
+type SKUBatch struct {
+	SKUs []string `json:"skus"`
+}
+
+// ProductBatch is the response to a batch lookup. Data holds the matching
+// products in the order they were requested, and NotFound the requested SKUs
+// that are not in inventory.
+type ProductBatch struct {
+	Data     []Product `json:"data"`
+	NotFound []string  `json:"notFound"`
+}
+
+// InventoryImportReport is the result of a CSV inventory import. When any
+// row has errors nothing is imported, and Errors holds every problem found.
+type InventoryImportReport struct {
+	DryRun   bool             `json:"dryRun"`
+	Imported bool             `json:"imported"`
+	Rows     int              `json:"rows"`
+	Created  []string         `json:"created"`
+	Updated  []string         `json:"updated"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ImportRowError is a problem with a row of an imported CSV file. Row is the
+// line number of the row in the file, where the header is row 1.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
 }
 
 // AuditLog is similar to Products in that it is the schema for the data
@@ -35,6 +261,32 @@ type AuditLog struct {
 	Data []AuditLogEntry `json:"data"`
 }
 
+// AuditLogFilter selects audit log entries. Every set field must match, and
+// the zero value matches every entry.
+type AuditLogFilter struct {
+	AccountID *int
+	CardID    string
+	// SKU matches entries with an inventory delta of the SKU
+	SKU string
+	// From and To bound the entry creation time, From inclusive and To
+	// exclusive
+	From time.Time
+	To   time.Time
+	// Offset skips the first matching entries and Limit, when above 0,
+	// caps the number of entries returned
+	Offset int
+	Limit  int
+}
+
+// AuditLogPage is a page of the audit log entries matching a filter. Total
+// counts every matching entry, so that the next pages can be requested.
+type AuditLogPage struct {
+	Data   []AuditLogEntry `json:"data"`
+	Total  int             `json:"total"`
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit,omitempty"`
+}
+
 // AuditLogEntry represents the schema for a single audit log entry
 type AuditLogEntry struct {
 	CardID         string              `json:"cardId"`
@@ -45,3 +297,119 @@ type AuditLogEntry struct {
 	CreatedAt      int64               `json:"createdAt,string"`
 	AuditEntryID   string              `json:"auditEntryId"`
 }
+
+// PlanogramSlot is the SKU expected in a lane of a shelf of the cooler.
+// Shelves and lanes are numbered from 1, and a SKU may be stocked in
+// several lanes. Capacity is the number of units the lane holds.
+type PlanogramSlot struct {
+	Shelf     int    `json:"shelf"`
+	Lane      int    `json:"lane"`
+	SKU       string `json:"sku"`
+	Capacity  int    `json:"capacity,omitempty"`
+	UpdatedAt int64  `json:"updatedAt,string"`
+}
+
+// Planogram maps the shelf and lane positions of the cooler to the SKUs
+// stocked in them, ordered by shelf and lane
+type Planogram struct {
+	Data []PlanogramSlot `json:"data"`
+}
+
+// PlanogramObservation is a SKU that was seen in a position of the cooler,
+// such as by the CV inference
+type PlanogramObservation struct {
+	Shelf int    `json:"shelf"`
+	Lane  int    `json:"lane"`
+	SKU   string `json:"sku"`
+}
+
+// PlanogramCheckResult is an observation checked against the planogram.
+// ExpectedSKU is empty when the position is not in the planogram.
+type PlanogramCheckResult struct {
+	PlanogramObservation
+	ExpectedSKU string `json:"expectedSku"`
+	Match       bool   `json:"match"`
+}
+
+// PlanogramCheck is the response to POST /planogram/check
+type PlanogramCheck struct {
+	Results    []PlanogramCheckResult `json:"results"`
+	Mismatches int                    `json:"mismatches"`
+}
+
+// PickListItem is a product to restock, with the number of units that
+// brings it up to its maximum restocking level and the lanes it is stocked
+// in
+type PickListItem struct {
+	SKU         string          `json:"sku"`
+	ProductName string          `json:"productName"`
+	Units       int             `json:"units"`
+	Locations   []PlanogramSlot `json:"locations"`
+}
+
+// RestockRequest is the stock a worker added to the cooler. CardID is the
+// card the worker opened the cooler with.
+type RestockRequest struct {
+	CardID string        `json:"cardId"`
+	Items  []RestockItem `json:"items"`
+}
+
+// RestockItem is the number of units of a SKU added by a restock
+type RestockItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+// RestockReport is the result of a restock, with the audit log entry it was
+// recorded in
+type RestockReport struct {
+	AuditEntryID string              `json:"auditEntryId"`
+	CardID       string              `json:"cardId"`
+	Items        []RestockReportItem `json:"items"`
+	TotalUnits   int                 `json:"totalUnits"`
+	CreatedAt    int64               `json:"createdAt,string"`
+}
+
+// RestockReportItem is a SKU that was restocked, with its units on hand
+// before and after. Overstocked is set when the units on hand are now above
+// the maximum restocking level of the product.
+type RestockReportItem struct {
+	SKU                string `json:"sku"`
+	ProductName        string `json:"productName"`
+	Quantity           int    `json:"quantity"`
+	UnitsOnHandBefore  int    `json:"unitsOnHandBefore"`
+	UnitsOnHandAfter   int    `json:"unitsOnHandAfter"`
+	MaxRestockingLevel int    `json:"maxRestockingLevel"`
+	Overstocked        bool   `json:"overstocked,omitempty"`
+}
+
+// RestockSuggestion is the number of units of a product that brings it up
+// to its maximum restocking level
+type RestockSuggestion struct {
+	SKU                string `json:"sku"`
+	ProductName        string `json:"productName"`
+	UnitsOnHand        int    `json:"unitsOnHand"`
+	MaxRestockingLevel int    `json:"maxRestockingLevel"`
+	Quantity           int    `json:"quantity"`
+}
+
+// BulkActivationRequest selects the products to deactivate or reactivate at
+// once, such as for a recall, by exactly one of their category, their
+// supplier or a list of SKUs. Reason is why they are deactivated, and is
+// required to deactivate them.
+type BulkActivationRequest struct {
+	Category string   `json:"category,omitempty"`
+	Supplier string   `json:"supplier,omitempty"`
+	SKUs     []string `json:"skus,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// BulkActivationReport is the result of a bulk deactivation or reactivation.
+// Products are the products that were changed, and Unchanged the SKUs of
+// the selected products that already were deactivated or active.
+type BulkActivationReport struct {
+	IsActive  bool      `json:"isActive"`
+	Reason    string    `json:"reason,omitempty"`
+	Products  []Product `json:"products"`
+	Unchanged []string  `json:"unchanged"`
+}
@@ -0,0 +1,133 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IsValidDeltaReason reports whether the reason is one of DeltaReasons
+func IsValidDeltaReason(reason string) bool {
+	for _, valid := range DeltaReasons {
+		if reason == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// NewStockMovement records an inventory delta applied to the item, which
+// already has the units on hand after it
+func NewStockMovement(delta DeltaInventorySKU, item Product, now int64) StockMovement {
+	movement := StockMovement{
+		MovementID:  uuid.New().String(),
+		SKU:         item.SKU,
+		Delta:       delta.Delta,
+		UnitsOnHand: item.UnitsOnHand,
+		Reason:      delta.Reason,
+		CreatedAt:   now,
+	}
+	if movement.Reason == "" {
+		movement.Reason = DeltaReasonSale
+	}
+	if delta.Source != nil {
+		movement.Source = *delta.Source
+	}
+	return movement
+}
+
+// GetStockMovements returns the stock movements from the inventory store.
+// The movements are empty until the first delta is applied.
+func (c *Controller) GetStockMovements() (StockMovements, error) {
+	stockMovements, err := c.inventoryStore().LoadStockMovements()
+	if errors.Is(err, ErrNotStored) {
+		return StockMovements{Data: []StockMovement{}}, nil
+	}
+	return stockMovements, err
+}
+
+// recordStockMovements appends the movements to the stock movements in the
+// inventory store, and queues them to be posted to the WMS
+func (c *Controller) recordStockMovements(movements []StockMovement) error {
+	// the stock has changed even if its movements cannot be recorded
+	c.wmsExporter.Add(movements)
+	stockMovements, err := c.GetStockMovements()
+	if err != nil {
+		return err
+	}
+	stockMovements.Data = append(stockMovements.Data, movements...)
+	return c.inventoryStore().SaveStockMovements(stockMovements)
+}
+
+// ParseStockMovementFilter reads the stock movement filter from the sku,
+// reason, service, user, sessionId, from and to query parameters. The from
+// and to bounds are RFC 3339 timestamps.
+func ParseStockMovementFilter(query url.Values) (StockMovementFilter, error) {
+	filter := StockMovementFilter{
+		SKU:       strings.TrimSpace(query.Get("sku")),
+		Reason:    strings.TrimSpace(query.Get("reason")),
+		Service:   strings.TrimSpace(query.Get("service")),
+		User:      strings.TrimSpace(query.Get("user")),
+		SessionID: strings.TrimSpace(query.Get("sessionId")),
+	}
+	if filter.Reason != "" && !IsValidDeltaReason(filter.Reason) {
+		return filter, fmt.Errorf("reason must be one of %s", strings.Join(DeltaReasons, ", "))
+	}
+
+	for name, bound := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*bound = parsed
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, errors.New("from must be before to")
+	}
+	return filter, nil
+}
+
+// Matches reports whether the stock movement passes every set field of the
+// filter
+func (filter StockMovementFilter) Matches(movement StockMovement) bool {
+	createdAt := time.Unix(0, movement.CreatedAt)
+	switch {
+	case filter.SKU != "" && movement.SKU != filter.SKU:
+		return false
+	case filter.Reason != "" && movement.Reason != filter.Reason:
+		return false
+	case filter.Service != "" && movement.Source.Service != filter.Service:
+		return false
+	case filter.User != "" && movement.Source.User != filter.User:
+		return false
+	case filter.SessionID != "" && movement.Source.SessionID != filter.SessionID:
+		return false
+	case !filter.From.IsZero() && createdAt.Before(filter.From):
+		return false
+	case !filter.To.IsZero() && !createdAt.Before(filter.To):
+		return false
+	}
+	return true
+}
+
+// ReportStockMovements returns the stock movements matching the filter,
+// oldest first, with the total delta of each reason
+func ReportStockMovements(stockMovements StockMovements, filter StockMovementFilter) StockMovementReport {
+	report := StockMovementReport{Data: []StockMovement{}, Totals: map[string]int{}}
+	for _, movement := range stockMovements.Data {
+		if filter.Matches(movement) {
+			report.Data = append(report.Data, movement)
+			report.Totals[movement.Reason] += movement.Delta
+		}
+	}
+	return report
+}
@@ -0,0 +1,66 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getDefaultStockMovements() StockMovements {
+	return StockMovements{Data: []StockMovement{
+		{MovementID: "1", SKU: "4900002470", Delta: -2, UnitsOnHand: 3, Reason: DeltaReasonSale, Source: DeltaSource{Service: "as-vending", User: "0003293374", SessionID: "a"}, CreatedAt: time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC).UnixNano()},
+		{MovementID: "2", SKU: "1200010735", Delta: -1, UnitsOnHand: 4, Reason: DeltaReasonSale, Source: DeltaSource{Service: "as-vending", User: "0003293374", SessionID: "a"}, CreatedAt: time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC).UnixNano()},
+		{MovementID: "3", SKU: "4900002470", Delta: 12, UnitsOnHand: 15, Reason: DeltaReasonRestock, Source: DeltaSource{Service: "as-vending", User: "0003278380", SessionID: "b"}, CreatedAt: time.Date(2023, 5, 2, 8, 0, 0, 0, time.UTC).UnixNano()},
+		{MovementID: "4", SKU: "4900002470", Delta: -1, UnitsOnHand: 14, Reason: DeltaReasonShrinkage, Source: DeltaSource{User: "admin"}, CreatedAt: time.Date(2023, 5, 3, 17, 0, 0, 0, time.UTC).UnixNano()},
+	}}
+}
+
+func TestReportStockMovements(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Query          string
+		ExpectedError  string
+		ExpectedIDs    []string
+		ExpectedTotals map[string]int
+	}{
+		{"No filter", "", "", []string{"1", "2", "3", "4"}, map[string]int{DeltaReasonSale: -3, DeltaReasonRestock: 12, DeltaReasonShrinkage: -1}},
+		{"SKU", "sku=4900002470", "", []string{"1", "3", "4"}, map[string]int{DeltaReasonSale: -2, DeltaReasonRestock: 12, DeltaReasonShrinkage: -1}},
+		{"Reason", "reason=sale", "", []string{"1", "2"}, map[string]int{DeltaReasonSale: -3}},
+		{"Source", "service=as-vending&user=0003278380", "", []string{"3"}, map[string]int{DeltaReasonRestock: 12}},
+		{"Session", "sessionId=a", "", []string{"1", "2"}, map[string]int{DeltaReasonSale: -3}},
+		{"Time range", "from=2023-05-01T10:00:00Z&to=2023-05-03T17:00:00Z", "", []string{"3"}, map[string]int{DeltaReasonRestock: 12}},
+		{"No match", "reason=correction", "", []string{}, map[string]int{}},
+		{"Unknown reason", "reason=theft", "reason must be one of", nil, nil},
+		{"Invalid time", "from=yesterday", "from must be an RFC 3339 timestamp", nil, nil},
+		{"Empty time range", "from=2023-05-02T00:00:00Z&to=2023-05-01T00:00:00Z", "from must be before to", nil, nil},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			query, err := url.ParseQuery(currentTest.Query)
+			require.NoError(t, err)
+			filter, err := ParseStockMovementFilter(query)
+			if currentTest.ExpectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			report := ReportStockMovements(getDefaultStockMovements(), filter)
+			ids := []string{}
+			for _, movement := range report.Data {
+				ids = append(ids, movement.MovementID)
+			}
+			assert.Equal(t, currentTest.ExpectedIDs, ids)
+			assert.Equal(t, currentTest.ExpectedTotals, report.Totals)
+		})
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetPlanogram returns the planogram from the inventory store. The planogram
+// is empty until the first slot is posted.
+func (c *Controller) GetPlanogram() (Planogram, error) {
+	planogram, err := c.inventoryStore().LoadPlanogram()
+	if errors.Is(err, ErrNotStored) {
+		return Planogram{Data: []PlanogramSlot{}}, nil
+	}
+	return planogram, err
+}
+
+// Validate checks that the slot has a position and a SKU
+func (slot PlanogramSlot) Validate() error {
+	switch {
+	case slot.Shelf < 1:
+		return fmt.Errorf("shelf of %s must be 1 or more", slot.SKU)
+	case slot.Lane < 1:
+		return fmt.Errorf("lane of %s must be 1 or more", slot.SKU)
+	case strings.TrimSpace(slot.SKU) == "":
+		return fmt.Errorf("shelf %d lane %d has no sku", slot.Shelf, slot.Lane)
+	case slot.Capacity < 0:
+		return fmt.Errorf("capacity of shelf %d lane %d must not be negative", slot.Shelf, slot.Lane)
+	}
+	return nil
+}
+
+// MergePlanogram adds the slots to the planogram, replacing the slots in
+// the same positions, and returns it ordered by shelf and lane
+func MergePlanogram(planogram Planogram, slots []PlanogramSlot, now int64) Planogram {
+	merged := Planogram{Data: []PlanogramSlot{}}
+	replaced := make(map[[2]int]bool)
+	for _, slot := range slots {
+		slot.UpdatedAt = now
+		merged.Data = append(merged.Data, slot)
+		replaced[[2]int{slot.Shelf, slot.Lane}] = true
+	}
+	for _, slot := range planogram.Data {
+		if !replaced[[2]int{slot.Shelf, slot.Lane}] {
+			merged.Data = append(merged.Data, slot)
+		}
+	}
+	sortPlanogramSlots(merged.Data)
+	return merged
+}
+
+func sortPlanogramSlots(slots []PlanogramSlot) {
+	sort.SliceStable(slots, func(i, j int) bool {
+		if slots[i].Shelf != slots[j].Shelf {
+			return slots[i].Shelf < slots[j].Shelf
+		}
+		return slots[i].Lane < slots[j].Lane
+	})
+}
+
+// CheckPlanogram compares the SKUs seen in positions of the cooler with the
+// SKUs the planogram expects there
+func CheckPlanogram(planogram Planogram, observations []PlanogramObservation) PlanogramCheck {
+	expected := make(map[[2]int]string)
+	for _, slot := range planogram.Data {
+		expected[[2]int{slot.Shelf, slot.Lane}] = slot.SKU
+	}
+	check := PlanogramCheck{Results: []PlanogramCheckResult{}}
+	for _, observation := range observations {
+		result := PlanogramCheckResult{
+			PlanogramObservation: observation,
+			ExpectedSKU:          expected[[2]int{observation.Shelf, observation.Lane}],
+		}
+		result.Match = result.ExpectedSKU != "" && result.ExpectedSKU == observation.SKU
+		if !result.Match {
+			check.Mismatches++
+		}
+		check.Results = append(check.Results, result)
+	}
+	return check
+}
+
+// BuildPickList returns the active products with fewer units on hand than
+// their maximum restocking level, with the lanes they are stocked in,
+// ordered by their first lane. Products that are not in the planogram come
+// last.
+func BuildPickList(inventoryItems Products, planogram Planogram) []PickListItem {
+	locations := make(map[string][]PlanogramSlot)
+	for _, slot := range planogram.Data {
+		locations[slot.SKU] = append(locations[slot.SKU], slot)
+	}
+	pickList := []PickListItem{}
+	for _, item := range inventoryItems.Data {
+		units := restockQuantity(item)
+		if units == 0 {
+			continue
+		}
+		slots := locations[item.SKU]
+		if slots == nil {
+			slots = []PlanogramSlot{}
+		}
+		sortPlanogramSlots(slots)
+		pickList = append(pickList, PickListItem{
+			SKU:         item.SKU,
+			ProductName: item.ProductName,
+			Units:       units,
+			Locations:   slots,
+		})
+	}
+	sort.SliceStable(pickList, func(i, j int) bool {
+		a, b := pickList[i].Locations, pickList[j].Locations
+		if len(a) == 0 || len(b) == 0 {
+			return len(a) > 0 && len(b) == 0
+		}
+		if a[0].Shelf != b[0].Shelf {
+			return a[0].Shelf < b[0].Shelf
+		}
+		return a[0].Lane < b[0].Lane
+	})
+	return pickList
+}
+
+// PlanogramGet returns the planogram, filtered by the sku and shelf query
+// parameters
+func (c *Controller) PlanogramGet(writer http.ResponseWriter, req *http.Request) {
+	sku := strings.TrimSpace(req.URL.Query().Get("sku"))
+	shelf := 0
+	if value := req.URL.Query().Get("shelf"); value != "" {
+		var err error
+		shelf, err = strconv.Atoi(value)
+		if err != nil || shelf < 1 {
+			c.lc.Errorf("Invalid shelf value: %s", value)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Please enter a valid shelf in the form of /planogram?shelf=1"))
+			return
+		}
+	}
+
+	planogram, err := c.GetPlanogram()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve the planogram: " + err.Error()))
+		return
+	}
+	filtered := Planogram{Data: []PlanogramSlot{}}
+	for _, slot := range planogram.Data {
+		if (sku == "" || slot.SKU == sku) && (shelf == 0 || slot.Shelf == shelf) {
+			filtered.Data = append(filtered.Data, slot)
+		}
+	}
+
+	planogramJSON, err := json.Marshal(filtered)
+	if err != nil {
+		c.lc.Errorf("Failed to process the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the planogram: " + err.Error()))
+		return
+	}
+	c.lc.Info("Successfully retrieved the planogram")
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(planogramJSON)
+}
+
+// PlanogramPost adds or replaces planogram slots. Every slot is validated
+// first, and nothing is changed when any slot is invalid or its SKU is not
+// in inventory.
+func (c *Controller) PlanogramPost(writer http.ResponseWriter, req *http.Request) {
+	var slots []PlanogramSlot
+	if statusCode, err := c.decodeJSONBody(writer, req, &slots); err != nil {
+		c.lc.Errorf("Failed to process the posted planogram slot(s): %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the posted planogram slot(s): " + err.Error()))
+		return
+	}
+
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+	skus := make(map[string]bool)
+	for _, item := range inventoryItems.Data {
+		skus[item.SKU] = true
+	}
+	positions := make(map[[2]int]bool)
+	for _, slot := range slots {
+		err := slot.Validate()
+		if err == nil && !skus[slot.SKU] {
+			err = fmt.Errorf("SKU %s does not exist in inventory", slot.SKU)
+		}
+		if err == nil && positions[[2]int{slot.Shelf, slot.Lane}] {
+			err = fmt.Errorf("shelf %d lane %d is posted more than once", slot.Shelf, slot.Lane)
+		}
+		if err != nil {
+			c.lc.Errorf("Failed to process the posted planogram slot(s): %s", err.Error())
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Failed to process the posted planogram slot(s): " + err.Error()))
+			return
+		}
+		positions[[2]int{slot.Shelf, slot.Lane}] = true
+	}
+
+	planogram, err := c.GetPlanogram()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve the planogram: " + err.Error()))
+		return
+	}
+	planogram = MergePlanogram(planogram, slots, time.Now().UnixNano())
+	if err := c.inventoryStore().SavePlanogram(planogram); err != nil {
+		c.lc.Errorf("Failed to write the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to write the planogram: " + err.Error()))
+		return
+	}
+
+	planogramJSON, err := json.Marshal(planogram)
+	if err != nil {
+		c.lc.Info("Updated the planogram successfully")
+		writer.Write([]byte("Updated the planogram successfully"))
+		return
+	}
+	c.lc.Infof("Updated the planogram successfully: %d slot(s) posted", len(slots))
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(planogramJSON)
+}
+
+// PlanogramDelete removes the slot of the shelf and lane from the planogram
+func (c *Controller) PlanogramDelete(writer http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	shelf, shelfErr := strconv.Atoi(vars["shelf"])
+	lane, laneErr := strconv.Atoi(vars["lane"])
+	if shelfErr != nil || laneErr != nil {
+		c.lc.Errorf("Invalid planogram slot: shelf %s lane %s", vars["shelf"], vars["lane"])
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a valid planogram slot in the form of /planogram/{shelf}/{lane}"))
+		return
+	}
+
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	planogram, err := c.GetPlanogram()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve the planogram: " + err.Error()))
+		return
+	}
+	remaining := Planogram{Data: []PlanogramSlot{}}
+	var deleted *PlanogramSlot
+	for i, slot := range planogram.Data {
+		if slot.Shelf == shelf && slot.Lane == lane {
+			deleted = &planogram.Data[i]
+			continue
+		}
+		remaining.Data = append(remaining.Data, slot)
+	}
+	if deleted == nil {
+		c.lc.Infof("Planogram slot shelf %d lane %d does not exist", shelf, lane)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte("Planogram slot does not exist"))
+		return
+	}
+	if err := c.inventoryStore().SavePlanogram(remaining); err != nil {
+		c.lc.Errorf("Failed to write the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to write the planogram: " + err.Error()))
+		return
+	}
+
+	deletedJSON, err := json.Marshal(deleted)
+	if err != nil {
+		c.lc.Errorf("Successfully deleted the planogram slot, but failed to serialize it: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Successfully deleted the planogram slot, but failed to serialize it: " + err.Error()))
+		return
+	}
+	c.lc.Infof("Successfully deleted planogram slot shelf %d lane %d", shelf, lane)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(deletedJSON)
+}
+
+// PlanogramCheckPost checks the posted observations, such as the positions
+// the CV inference saw SKUs in, against the planogram
+func (c *Controller) PlanogramCheckPost(writer http.ResponseWriter, req *http.Request) {
+	var observations []PlanogramObservation
+	if statusCode, err := c.decodeJSONBody(writer, req, &observations); err != nil {
+		c.lc.Errorf("Failed to process the posted planogram observation(s): %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the posted planogram observation(s): " + err.Error()))
+		return
+	}
+
+	planogram, err := c.GetPlanogram()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve the planogram: " + err.Error()))
+		return
+	}
+	check := CheckPlanogram(planogram, observations)
+	if check.Mismatches > 0 {
+		c.lc.Warnf("%d of %d planogram observation(s) do not match the planogram", check.Mismatches, len(observations))
+	}
+
+	checkJSON, err := json.Marshal(check)
+	if err != nil {
+		c.lc.Errorf("Failed to process the planogram check: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the planogram check: " + err.Error()))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(checkJSON)
+}
+
+// PlanogramPickListGet returns the products to restock with the lanes to
+// stock them in
+func (c *Controller) PlanogramPickListGet(writer http.ResponseWriter, req *http.Request) {
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+	planogram, err := c.GetPlanogram()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve the planogram: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve the planogram: " + err.Error()))
+		return
+	}
+
+	pickListJSON, err := json.Marshal(BuildPickList(inventoryItems, planogram))
+	if err != nil {
+		c.lc.Errorf("Failed to process the pick list: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the pick list: " + err.Error()))
+		return
+	}
+	c.lc.Info("Successfully built the pick list")
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(pickListJSON)
+}
@@ -0,0 +1,168 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePlanogram(t *testing.T) {
+	planogram := Planogram{Data: []PlanogramSlot{
+		{Shelf: 1, Lane: 1, SKU: "4900002470", UpdatedAt: 1},
+		{Shelf: 2, Lane: 1, SKU: "1200010735", UpdatedAt: 1},
+	}}
+	merged := MergePlanogram(planogram, []PlanogramSlot{
+		{Shelf: 2, Lane: 1, SKU: "1200050408", Capacity: 8},
+		{Shelf: 1, Lane: 2, SKU: "4900002470"},
+	}, 42)
+	assert.Equal(t, []PlanogramSlot{
+		{Shelf: 1, Lane: 1, SKU: "4900002470", UpdatedAt: 1},
+		{Shelf: 1, Lane: 2, SKU: "4900002470", UpdatedAt: 42},
+		{Shelf: 2, Lane: 1, SKU: "1200050408", Capacity: 8, UpdatedAt: 42},
+	}, merged.Data)
+}
+
+func TestPlanogramSlotValidate(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Slot          PlanogramSlot
+		ExpectedError string
+	}{
+		{"Valid", PlanogramSlot{Shelf: 1, Lane: 3, SKU: "4900002470", Capacity: 8}, ""},
+		{"No shelf", PlanogramSlot{Lane: 3, SKU: "4900002470"}, "shelf of 4900002470 must be 1 or more"},
+		{"No lane", PlanogramSlot{Shelf: 1, SKU: "4900002470"}, "lane of 4900002470 must be 1 or more"},
+		{"No SKU", PlanogramSlot{Shelf: 1, Lane: 3}, "shelf 1 lane 3 has no sku"},
+		{"Negative capacity", PlanogramSlot{Shelf: 1, Lane: 3, SKU: "4900002470", Capacity: -1}, "capacity of shelf 1 lane 3 must not be negative"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			err := currentTest.Slot.Validate()
+			if currentTest.ExpectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, currentTest.ExpectedError)
+		})
+	}
+}
+
+func TestCheckPlanogram(t *testing.T) {
+	planogram := Planogram{Data: []PlanogramSlot{
+		{Shelf: 1, Lane: 1, SKU: "4900002470"},
+		{Shelf: 1, Lane: 2, SKU: "1200010735"},
+	}}
+	check := CheckPlanogram(planogram, []PlanogramObservation{
+		{Shelf: 1, Lane: 1, SKU: "4900002470"},
+		{Shelf: 1, Lane: 2, SKU: "4900002470"},
+		{Shelf: 3, Lane: 1, SKU: "1200050408"},
+	})
+	assert.Equal(t, 2, check.Mismatches)
+	assert.Equal(t, []PlanogramCheckResult{
+		{PlanogramObservation: PlanogramObservation{Shelf: 1, Lane: 1, SKU: "4900002470"}, ExpectedSKU: "4900002470", Match: true},
+		{PlanogramObservation: PlanogramObservation{Shelf: 1, Lane: 2, SKU: "4900002470"}, ExpectedSKU: "1200010735"},
+		{PlanogramObservation: PlanogramObservation{Shelf: 3, Lane: 1, SKU: "1200050408"}},
+	}, check.Results)
+}
+
+func TestBuildPickList(t *testing.T) {
+	inventoryItems := getDefaultProductsList()
+	inventoryItems.Data[0].UnitsOnHand = 20
+	inventoryItems.Data[1].UnitsOnHand = -2
+	inventoryItems.Data[2].UnitsOnHand = 6
+	inventoryItems.Data = append(inventoryItems.Data, Product{SKU: "9999999999", ProductName: "Pringles", MaxRestockingLevel: 5, IsActive: true}, Product{SKU: "8888888888", MaxRestockingLevel: 5})
+	planogram := Planogram{Data: []PlanogramSlot{
+		{Shelf: 1, Lane: 1, SKU: "1200050408"},
+		{Shelf: 2, Lane: 1, SKU: "1200010735"},
+		{Shelf: 2, Lane: 2, SKU: "4900002470"},
+		{Shelf: 3, Lane: 1, SKU: "1200010735"},
+	}}
+
+	pickList := BuildPickList(inventoryItems, planogram)
+	assert.Equal(t, []PickListItem{
+		{SKU: "1200010735", ProductName: "Mountain Dew (Low Calorie) - 16.9 oz", Units: 18, Locations: []PlanogramSlot{{Shelf: 2, Lane: 1, SKU: "1200010735"}, {Shelf: 3, Lane: 1, SKU: "1200010735"}}},
+		{SKU: "4900002470", ProductName: "Sprite (Lemon-Lime) - 16.9 oz", Units: 4, Locations: []PlanogramSlot{{Shelf: 2, Lane: 2, SKU: "4900002470"}}},
+		{SKU: "9999999999", ProductName: "Pringles", Units: 5, Locations: []PlanogramSlot{}},
+	}, pickList, "full and inactive products are left out, and products without a lane come last")
+}
+
+func TestPlanogramRoutes(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	c.inventoryItems = getDefaultProductsList()
+	require.NoError(t, c.WriteInventory())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.PlanogramPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48095/planogram", bytes.NewBufferString(body)))
+		return w
+	}
+
+	w := post(`[{"shelf":1,"lane":1,"sku":"4900002470","capacity":8},{"shelf":1,"lane":2,"sku":"1200010735"},{"shelf":2,"lane":1,"sku":"1200010735"}]`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	for _, test := range []struct {
+		Body          string
+		ExpectedError string
+	}{
+		{`[{"shelf":1,"lane":1,"sku":"9999999999"}]`, "Failed to process the posted planogram slot(s): SKU 9999999999 does not exist in inventory"},
+		{`[{"shelf":0,"lane":1,"sku":"4900002470"}]`, "Failed to process the posted planogram slot(s): shelf of 4900002470 must be 1 or more"},
+		{`[{"shelf":3,"lane":1,"sku":"4900002470"},{"shelf":3,"lane":1,"sku":"1200010735"}]`, "Failed to process the posted planogram slot(s): shelf 3 lane 1 is posted more than once"},
+	} {
+		w = post(test.Body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, test.ExpectedError, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	c.PlanogramGet(w, httptest.NewRequest(http.MethodGet, "http://localhost:48095/planogram?sku=1200010735", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var planogram Planogram
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &planogram))
+	require.Len(t, planogram.Data, 2, "invalid posts do not change the planogram")
+	assert.Equal(t, 1, planogram.Data[0].Shelf)
+	assert.Equal(t, 2, planogram.Data[1].Shelf)
+
+	w = httptest.NewRecorder()
+	c.PlanogramGet(w, httptest.NewRequest(http.MethodGet, "http://localhost:48095/planogram?shelf=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	c.PlanogramCheckPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48095/planogram/check", bytes.NewBufferString(`[{"shelf":1,"lane":1,"sku":"1200010735"}]`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var check PlanogramCheck
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &check))
+	assert.Equal(t, 1, check.Mismatches)
+	assert.Equal(t, "4900002470", check.Results[0].ExpectedSKU)
+
+	w = httptest.NewRecorder()
+	c.PlanogramPickListGet(w, httptest.NewRequest(http.MethodGet, "http://localhost:48095/planogram/picklist", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var pickList []PickListItem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pickList))
+	require.Len(t, pickList, 3)
+	assert.Equal(t, "4900002470", pickList[0].SKU)
+
+	deleteSlot := func(shelf string, lane string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "http://localhost:48095/planogram/"+shelf+"/"+lane, nil)
+		req = mux.SetURLVars(req, map[string]string{"shelf": shelf, "lane": lane})
+		w := httptest.NewRecorder()
+		c.PlanogramDelete(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, deleteSlot("1", "2").Code)
+	assert.Equal(t, http.StatusNotFound, deleteSlot("1", "2").Code)
+	assert.Equal(t, http.StatusBadRequest, deleteSlot("one", "2").Code)
+	planogram, err := c.GetPlanogram()
+	require.NoError(t, err)
+	assert.Len(t, planogram.Data, 2)
+}
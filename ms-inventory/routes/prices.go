@@ -0,0 +1,135 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// productPrice is the price of a product in its currency
+type productPrice struct {
+	itemPrice float64
+	currency  string
+}
+
+// productPrices returns the price of each product by SKU, so that the prices
+// before an update can be compared with the prices after it
+func productPrices(inventoryItems Products) map[string]productPrice {
+	prices := make(map[string]productPrice)
+	for _, item := range inventoryItems.Data {
+		prices[item.SKU] = productPrice{itemPrice: item.ItemPrice, currency: item.Currency}
+	}
+	return prices
+}
+
+// PriceChanges returns a price change for each product whose price or
+// currency is not the one it had before, including the products that did
+// not exist before
+func PriceChanges(before map[string]productPrice, inventoryItems Products, now int64) []PriceChange {
+	var changes []PriceChange
+	for _, item := range inventoryItems.Data {
+		price, existed := before[item.SKU]
+		if existed && price.itemPrice == item.ItemPrice && price.currency == item.Currency {
+			continue
+		}
+		changes = append(changes, PriceChange{
+			ChangeID:    uuid.New().String(),
+			SKU:         item.SKU,
+			ItemPrice:   item.ItemPrice,
+			Currency:    item.Currency,
+			EffectiveAt: now,
+		})
+	}
+	return changes
+}
+
+// GetPriceHistory returns the price history from the inventory store. The
+// history is empty until the first price change is recorded.
+func (c *Controller) GetPriceHistory() (PriceHistory, error) {
+	priceHistory, err := c.inventoryStore().LoadPriceHistory()
+	if errors.Is(err, ErrNotStored) {
+		return PriceHistory{Data: []PriceChange{}}, nil
+	}
+	return priceHistory, err
+}
+
+// recordPriceChanges appends the changes to the price history in the
+// inventory store
+func (c *Controller) recordPriceChanges(changes []PriceChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	priceHistory, err := c.GetPriceHistory()
+	if err != nil {
+		return err
+	}
+	priceHistory.Data = append(priceHistory.Data, changes...)
+	return c.inventoryStore().SavePriceHistory(priceHistory)
+}
+
+// PriceHistoryOf returns the price changes of the SKU, ordered by when they
+// took effect
+func PriceHistoryOf(priceHistory PriceHistory, sku string) PriceHistory {
+	filtered := PriceHistory{Data: []PriceChange{}}
+	for _, change := range priceHistory.Data {
+		if change.SKU == sku {
+			filtered.Data = append(filtered.Data, change)
+		}
+	}
+	sort.SliceStable(filtered.Data, func(i, j int) bool {
+		return filtered.Data[i].EffectiveAt < filtered.Data[j].EffectiveAt
+	})
+	return filtered
+}
+
+// PriceHistoryGet returns the price changes of the SKU in the URL, oldest
+// first. The history of a deleted product is still returned.
+func (c *Controller) PriceHistoryGet(writer http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+	if sku == "" {
+		c.lc.Error("Missing SKU in the price history request")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Please enter a valid SKU in the form of /inventory/{sku}/priceHistory"))
+		return
+	}
+
+	priceHistory, err := c.GetPriceHistory()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve the price history: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve the price history: " + err.Error()))
+		return
+	}
+	skuHistory := PriceHistoryOf(priceHistory, sku)
+	if len(skuHistory.Data) == 0 {
+		inventoryItem, _, err := c.GetInventoryItemBySKU(sku)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(err.Error()))
+			return
+		}
+		if inventoryItem.SKU == "" {
+			c.lc.Infof("SKU %s has no price history and is not in inventory", sku)
+			writer.WriteHeader(http.StatusNotFound)
+			writer.Write([]byte("Item does not exist"))
+			return
+		}
+	}
+
+	priceHistoryJSON, err := json.Marshal(skuHistory)
+	if err != nil {
+		c.lc.Errorf("Failed to process the price history: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the price history: " + err.Error()))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(priceHistoryJSON)
+}
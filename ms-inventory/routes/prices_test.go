@@ -0,0 +1,100 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceChanges(t *testing.T) {
+	before := productPrices(getDefaultProductsList())
+	inventoryItems := getDefaultProductsList()
+	inventoryItems.Data[0].ItemPrice = 2.49
+	inventoryItems.Data[1].Currency = "EUR"
+	inventoryItems.Data[2].UnitsOnHand = 4
+	inventoryItems.Data = append(inventoryItems.Data, Product{SKU: "0012000001", ItemPrice: 0.99})
+
+	changes := PriceChanges(before, inventoryItems, 42)
+	require.Len(t, changes, 3, "only changed prices and currencies, and new products, are recorded")
+	assert.Equal(t, "4900002470", changes[0].SKU)
+	assert.Equal(t, 2.49, changes[0].ItemPrice)
+	assert.Equal(t, int64(42), changes[0].EffectiveAt)
+	assert.NotEmpty(t, changes[0].ChangeID)
+	assert.Equal(t, "EUR", changes[1].Currency)
+	assert.Equal(t, "0012000001", changes[2].SKU)
+}
+
+func TestPriceHistoryOf(t *testing.T) {
+	priceHistory := PriceHistory{Data: []PriceChange{
+		{ChangeID: "1", SKU: "4900002470", ItemPrice: 2.49, EffectiveAt: 3},
+		{ChangeID: "2", SKU: "1200010735", ItemPrice: 1.99, EffectiveAt: 1},
+		{ChangeID: "3", SKU: "4900002470", ItemPrice: 1.99, EffectiveAt: 2},
+	}}
+	history := PriceHistoryOf(priceHistory, "4900002470")
+	require.Len(t, history.Data, 2)
+	assert.Equal(t, "3", history.Data[0].ChangeID)
+	assert.Equal(t, "1", history.Data[1].ChangeID)
+	assert.Empty(t, PriceHistoryOf(priceHistory, "0000000000").Data)
+}
+
+func TestPriceHistoryRoutes(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	c.inventoryItems = getDefaultProductsList()
+	require.NoError(t, c.WriteInventory())
+
+	getHistory := func(sku string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:48095/inventory/"+sku+"/priceHistory", nil)
+		req = mux.SetURLVars(req, map[string]string{"sku": sku})
+		w := httptest.NewRecorder()
+		c.PriceHistoryGet(w, req)
+		return w
+	}
+
+	// products added before the price history was kept have no history
+	w := getHistory("4900002470")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, getHistory("0000000000").Code)
+
+	post := func(body string) {
+		w := httptest.NewRecorder()
+		c.InventoryPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48095/inventory", bytes.NewBufferString(body)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	post(`[{"sku":"4900002470","itemPrice":2.49,"version":0}]`)
+	post(`[{"sku":"4900002470","unitsOnHand":5,"version":1}]`)
+	post(`[{"sku":"0012000001","productName":"Water - 16.9 oz","itemPrice":0.99}]`)
+
+	w = getHistory("4900002470")
+	require.Equal(t, http.StatusOK, w.Code)
+	var priceHistory PriceHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &priceHistory))
+	require.Len(t, priceHistory.Data, 1, "updates that do not change the price are not recorded")
+	assert.Equal(t, 2.49, priceHistory.Data[0].ItemPrice)
+
+	w = getHistory("0012000001")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &priceHistory))
+	require.Len(t, priceHistory.Data, 1, "a new product starts its history at the price it was added with")
+	assert.Equal(t, 0.99, priceHistory.Data[0].ItemPrice)
+
+	// the CSV import records its price changes too
+	importReq := httptest.NewRequest(http.MethodPost, "http://localhost:48095/inventory/import", strings.NewReader("sku,productName,itemPrice\n0012000001,Water - 16.9 oz,1.09\n"))
+	w = httptest.NewRecorder()
+	c.InventoryImportPost(w, importReq)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = getHistory("0012000001")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &priceHistory))
+	require.Len(t, priceHistory.Data, 2)
+	assert.Equal(t, 1.09, priceHistory.Data[1].ItemPrice)
+}
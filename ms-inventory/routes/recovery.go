@@ -0,0 +1,68 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+// RecoverData runs the crash recovery of the inventory, audit log, stock
+// movements, planogram, price history and deleted products files, keeping
+// the report for the health endpoint
+func (c *Controller) RecoverData(markerName string) error {
+	dataFiles := []utilities.DataFile{{
+		Name: c.inventoryFileName,
+		Validate: func(data []byte) error {
+			var inventoryItems Products
+			return json.Unmarshal(data, &inventoryItems)
+		},
+		Empty: Products{Data: []Product{}},
+	}, {
+		Name: c.auditLogFileName,
+		Validate: func(data []byte) error {
+			var auditLog AuditLog
+			return json.Unmarshal(data, &auditLog)
+		},
+		Empty: AuditLog{Data: []AuditLogEntry{}},
+	}, {
+		Name: StockMovementsFileName(c.inventoryFileName),
+		Validate: func(data []byte) error {
+			var stockMovements StockMovements
+			return json.Unmarshal(data, &stockMovements)
+		},
+		Empty: StockMovements{Data: []StockMovement{}},
+	}, {
+		Name: PlanogramFileName(c.inventoryFileName),
+		Validate: func(data []byte) error {
+			var planogram Planogram
+			return json.Unmarshal(data, &planogram)
+		},
+		Empty: Planogram{Data: []PlanogramSlot{}},
+	}, {
+		Name: PriceHistoryFileName(c.inventoryFileName),
+		Validate: func(data []byte) error {
+			var priceHistory PriceHistory
+			return json.Unmarshal(data, &priceHistory)
+		},
+		Empty: PriceHistory{Data: []PriceChange{}},
+	}, {
+		Name: DeletedInventoryFileName(c.inventoryFileName),
+		Validate: func(data []byte) error {
+			var deletedItems Products
+			return json.Unmarshal(data, &deletedItems)
+		},
+		Empty: Products{Data: []Product{}},
+	}}
+	report, err := utilities.Recover(markerName, dataFiles, c.fileWriter, time.Now())
+	c.recovery = report
+	return err
+}
+
+// Recovery returns the crash recovery done on start
+func (c *Controller) Recovery() utilities.RecoveryReport {
+	return c.recovery
+}
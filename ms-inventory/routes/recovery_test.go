@@ -0,0 +1,121 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverData(t *testing.T) {
+	validInventory, err := json.Marshal(getDefaultProductsList())
+	require.NoError(t, err)
+	validAuditLog, err := json.Marshal(getDefaultAuditsList())
+	require.NoError(t, err)
+	corrupt := []byte(`{"data":[{"sku":`)
+
+	tests := []struct {
+		Name                string
+		Marker              bool
+		InventoryData       []byte
+		AuditLogData        []byte
+		TempFile            bool
+		ExpectCrash         bool
+		ExpectedQuarantined []string
+	}{
+		{"Clean start", false, validInventory, validAuditLog, false, false, nil},
+		{"Clean start keeps corrupt files", false, corrupt, validAuditLog, false, false, nil},
+		{"Crash with valid files", true, validInventory, validAuditLog, false, true, nil},
+		{"Crash with interrupted write", true, validInventory, validAuditLog, true, true, nil},
+		{"Crash with corrupt inventory", true, corrupt, validAuditLog, false, true, []string{InventoryFileName}},
+		{"Crash with corrupt audit log", true, validInventory, corrupt, false, true, []string{AuditLogFileName}},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				inventoryFileName: filepath.Join(dir, InventoryFileName),
+				auditLogFileName:  filepath.Join(dir, AuditLogFileName),
+			}
+			markerName := utilities.MarkerFileName(c.inventoryFileName, "ms-inventory")
+			if currentTest.Marker {
+				require.NoError(t, os.WriteFile(markerName, []byte("1"), 0644))
+			}
+			require.NoError(t, os.WriteFile(c.inventoryFileName, currentTest.InventoryData, 0644))
+			require.NoError(t, os.WriteFile(c.auditLogFileName, currentTest.AuditLogData, 0644))
+			tempName := filepath.Join(dir, "."+InventoryFileName+".tmp-123")
+			if currentTest.TempFile {
+				require.NoError(t, os.WriteFile(tempName, []byte(`{"da`), 0644))
+			}
+
+			require.NoError(t, c.RecoverData(markerName))
+			report := c.Recovery()
+
+			assert.Equal(t, currentTest.ExpectCrash, report.CrashDetected)
+			assert.FileExists(t, markerName, "the running marker should be written for this run")
+			if currentTest.TempFile {
+				assert.Equal(t, []string{tempName}, report.RemovedTempFiles)
+				assert.NoFileExists(t, tempName)
+			} else {
+				assert.Empty(t, report.RemovedTempFiles)
+			}
+
+			require.Len(t, report.QuarantinedFiles, len(currentTest.ExpectedQuarantined))
+			for _, name := range currentTest.ExpectedQuarantined {
+				assert.FileExists(t, report.QuarantinedFiles[filepath.Join(dir, name)])
+			}
+
+			// both files can be loaded after the recovery
+			if currentTest.ExpectCrash {
+				_, err := c.GetInventoryItems()
+				require.NoError(t, err)
+				_, err = c.GetAuditLog()
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHealthGet(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Recovery       utilities.RecoveryReport
+		ExpectedStatus string
+	}{
+		{"Clean start", utilities.RecoveryReport{}, utilities.HealthStatusOK},
+		{"Recovered", utilities.RecoveryReport{CrashDetected: true, RecoveredAt: 1685577600000000000}, utilities.HealthStatusRecovered},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:       logger.NewMockClient(),
+				recovery: currentTest.Recovery,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			w := httptest.NewRecorder()
+			c.HealthGet(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var result utilities.Health
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+			assert.Equal(t, currentTest.ExpectedStatus, result.Status)
+			assert.Equal(t, currentTest.Recovery, result.Recovery)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// RedisInventoryKey, RedisAuditLogKey, RedisStockMovementsKey,
+	// RedisPlanogramKey, RedisPriceHistoryKey and RedisDeletedInventoryKey
+	// are the keys of the inventory, audit log, stock movements, planogram,
+	// price history and deleted products JSON documents in Redis
+	RedisInventoryKey        = "ms-inventory:inventory"
+	RedisAuditLogKey         = "ms-inventory:auditlog"
+	RedisStockMovementsKey   = "ms-inventory:movements"
+	RedisPlanogramKey        = "ms-inventory:planogram"
+	RedisPriceHistoryKey     = "ms-inventory:prices"
+	RedisDeletedInventoryKey = "ms-inventory:deleted"
+	// RedisAuditLogIndexKey is the hash of the audit log entries by their
+	// ID, saved along with the audit log document
+	RedisAuditLogIndexKey = "ms-inventory:auditlog:index"
+
+	redisMaxIdle     = 3
+	redisIdleTimeout = 4 * time.Minute
+)
+
+// RedisStore keeps the inventory and audit log as JSON documents in Redis,
+// so that every instance of the service connected to it shares them
+type RedisStore struct {
+	pool *redis.Pool
+}
+
+// NewRedisStore connects to the Redis server at the URL, such as
+// redis://localhost:6379/0
+func NewRedisStore(url string) (InventoryStore, error) {
+	if len(url) == 0 {
+		return nil, errors.New("the redis inventory store needs the URL of the Redis server")
+	}
+	store := &RedisStore{pool: &redis.Pool{
+		MaxIdle:     redisMaxIdle,
+		IdleTimeout: redisIdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url)
+		},
+	}}
+
+	conn := store.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		store.pool.Close()
+		return nil, fmt.Errorf("failed to connect to redis inventory store: %s", err.Error())
+	}
+	return store, nil
+}
+
+// LoadInventory reads the inventory document
+func (store *RedisStore) LoadInventory() (Products, error) {
+	var inventoryItems Products
+	if err := store.get(RedisInventoryKey, &inventoryItems); err != nil {
+		return inventoryItems, fmt.Errorf("failed to load inventory from redis: %w", err)
+	}
+	return inventoryItems, nil
+}
+
+// SaveInventory replaces the inventory document
+func (store *RedisStore) SaveInventory(inventoryItems Products) error {
+	if err := store.set(RedisInventoryKey, inventoryItems); err != nil {
+		return fmt.Errorf("failed to save inventory to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadAuditLog reads the audit log document
+func (store *RedisStore) LoadAuditLog() (AuditLog, error) {
+	var auditLog AuditLog
+	if err := store.get(RedisAuditLogKey, &auditLog); err != nil {
+		return auditLog, fmt.Errorf("failed to load audit log from redis: %w", err)
+	}
+	return auditLog, nil
+}
+
+// LoadAuditLogEntry reads the entry from the audit log index. An audit log
+// saved before the index existed has no index until it is saved again, and
+// is searched instead.
+func (store *RedisStore) LoadAuditLogEntry(auditEntryID string) (AuditLogEntry, error) {
+	var auditLogEntry AuditLogEntry
+	conn := store.pool.Get()
+	defer conn.Close()
+	data, err := redis.Bytes(conn.Do("HGET", RedisAuditLogIndexKey, auditEntryID))
+	if err == nil {
+		if err := json.Unmarshal(data, &auditLogEntry); err != nil {
+			return auditLogEntry, fmt.Errorf("failed to unmarshal audit log entry: %s", err.Error())
+		}
+		return auditLogEntry, nil
+	}
+	if !errors.Is(err, redis.ErrNil) {
+		return auditLogEntry, fmt.Errorf("failed to load audit log entry from redis: %s", err.Error())
+	}
+
+	indexed, err := redis.Bool(conn.Do("EXISTS", RedisAuditLogIndexKey))
+	if err != nil {
+		return auditLogEntry, fmt.Errorf("failed to load audit log entry from redis: %s", err.Error())
+	}
+	if indexed {
+		return auditLogEntry, ErrAuditLogEntryNotFound
+	}
+	auditLog, err := store.LoadAuditLog()
+	if err != nil {
+		return auditLogEntry, err
+	}
+	for _, entry := range auditLog.Data {
+		if entry.AuditEntryID == auditEntryID {
+			return entry, nil
+		}
+	}
+	return auditLogEntry, ErrAuditLogEntryNotFound
+}
+
+// SaveAuditLog replaces the audit log document and its index in one
+// transaction
+func (store *RedisStore) SaveAuditLog(auditLog AuditLog) error {
+	data, err := json.Marshal(auditLog)
+	if err != nil {
+		return fmt.Errorf("failed to save audit log to redis: %s", err.Error())
+	}
+	index := redis.Args{}.Add(RedisAuditLogIndexKey)
+	indexed := make(map[string]bool)
+	for _, auditLogEntry := range auditLog.Data {
+		// the first entry of a repeated ID is the one found
+		if indexed[auditLogEntry.AuditEntryID] {
+			continue
+		}
+		indexed[auditLogEntry.AuditEntryID] = true
+		entryData, err := json.Marshal(auditLogEntry)
+		if err != nil {
+			return fmt.Errorf("failed to save audit log to redis: %s", err.Error())
+		}
+		index = index.Add(auditLogEntry.AuditEntryID, entryData)
+	}
+
+	conn := store.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("MULTI"); err != nil {
+		return fmt.Errorf("failed to save audit log to redis: %s", err.Error())
+	}
+	conn.Do("SET", RedisAuditLogKey, data)
+	conn.Do("DEL", RedisAuditLogIndexKey)
+	if len(indexed) > 0 {
+		conn.Do("HSET", index...)
+	}
+	// the errors of the queued commands are returned by EXEC
+	if _, err := conn.Do("EXEC"); err != nil {
+		return fmt.Errorf("failed to save audit log to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadStockMovements reads the stock movements document
+func (store *RedisStore) LoadStockMovements() (StockMovements, error) {
+	var stockMovements StockMovements
+	if err := store.get(RedisStockMovementsKey, &stockMovements); err != nil {
+		return stockMovements, fmt.Errorf("failed to load stock movements from redis: %w", err)
+	}
+	return stockMovements, nil
+}
+
+// SaveStockMovements replaces the stock movements document
+func (store *RedisStore) SaveStockMovements(stockMovements StockMovements) error {
+	if err := store.set(RedisStockMovementsKey, stockMovements); err != nil {
+		return fmt.Errorf("failed to save stock movements to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadPlanogram reads the planogram document
+func (store *RedisStore) LoadPlanogram() (Planogram, error) {
+	var planogram Planogram
+	if err := store.get(RedisPlanogramKey, &planogram); err != nil {
+		return planogram, fmt.Errorf("failed to load planogram from redis: %w", err)
+	}
+	return planogram, nil
+}
+
+// SavePlanogram replaces the planogram document
+func (store *RedisStore) SavePlanogram(planogram Planogram) error {
+	if err := store.set(RedisPlanogramKey, planogram); err != nil {
+		return fmt.Errorf("failed to save planogram to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadPriceHistory reads the price history document
+func (store *RedisStore) LoadPriceHistory() (PriceHistory, error) {
+	var priceHistory PriceHistory
+	if err := store.get(RedisPriceHistoryKey, &priceHistory); err != nil {
+		return priceHistory, fmt.Errorf("failed to load price history from redis: %w", err)
+	}
+	return priceHistory, nil
+}
+
+// SavePriceHistory replaces the price history document
+func (store *RedisStore) SavePriceHistory(priceHistory PriceHistory) error {
+	if err := store.set(RedisPriceHistoryKey, priceHistory); err != nil {
+		return fmt.Errorf("failed to save price history to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadDeletedInventory reads the deleted products document
+func (store *RedisStore) LoadDeletedInventory() (Products, error) {
+	var deletedItems Products
+	if err := store.get(RedisDeletedInventoryKey, &deletedItems); err != nil {
+		return deletedItems, fmt.Errorf("failed to load deleted products from redis: %w", err)
+	}
+	return deletedItems, nil
+}
+
+// SaveDeletedInventory replaces the deleted products document
+func (store *RedisStore) SaveDeletedInventory(deletedItems Products) error {
+	if err := store.set(RedisDeletedInventoryKey, deletedItems); err != nil {
+		return fmt.Errorf("failed to save deleted products to redis: %s", err.Error())
+	}
+	return nil
+}
+
+// Close closes the connections to the Redis server
+func (store *RedisStore) Close() error {
+	return store.pool.Close()
+}
+
+func (store *RedisStore) get(key string, content interface{}) error {
+	conn := store.pool.Get()
+	defer conn.Close()
+	data, err := redis.Bytes(conn.Do("GET", key))
+	if errors.Is(err, redis.ErrNil) {
+		return ErrNotStored
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, content)
+}
+
+func (store *RedisStore) set(key string, content interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	conn := store.pool.Get()
+	defer conn.Close()
+	_, err = conn.Do("SET", key, data)
+	return err
+}
@@ -0,0 +1,159 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is an in-memory Redis server that answers GET, SET, DEL,
+// EXISTS, HGET and HSET. MULTI and EXEC are accepted, and the commands in
+// between are run right away.
+type fakeRedis struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+	hashes map[string]map[string][]byte
+	err    error
+}
+
+// fakeRedisConn is a connection to a fakeRedis
+type fakeRedisConn struct {
+	server *fakeRedis
+}
+
+func (conn *fakeRedisConn) Close() error { return nil }
+func (conn *fakeRedisConn) Err() error   { return nil }
+func (conn *fakeRedisConn) Flush() error { return nil }
+
+func (conn *fakeRedisConn) Send(commandName string, args ...interface{}) error {
+	return errors.New("pipelining is not supported")
+}
+
+func (conn *fakeRedisConn) Receive() (interface{}, error) {
+	return nil, errors.New("pipelining is not supported")
+}
+
+func (conn *fakeRedisConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	server := conn.server
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.err != nil {
+		return nil, server.err
+	}
+	switch commandName {
+	case "":
+		// the pool checks connections with an empty command
+		return nil, nil
+	case "PING":
+		return "PONG", nil
+	case "GET":
+		value, ok := server.values[args[0].(string)]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	case "SET":
+		server.values[args[0].(string)] = args[1].([]byte)
+		return "OK", nil
+	case "DEL":
+		delete(server.values, args[0].(string))
+		delete(server.hashes, args[0].(string))
+		return int64(1), nil
+	case "EXISTS":
+		_, isValue := server.values[args[0].(string)]
+		_, isHash := server.hashes[args[0].(string)]
+		if isValue || isHash {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case "HGET":
+		value, ok := server.hashes[args[0].(string)][args[1].(string)]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	case "HSET":
+		hash, ok := server.hashes[args[0].(string)]
+		if !ok {
+			hash = map[string][]byte{}
+			server.hashes[args[0].(string)] = hash
+		}
+		for i := 1; i+1 < len(args); i += 2 {
+			hash[args[i].(string)] = args[i+1].([]byte)
+		}
+		return int64((len(args) - 1) / 2), nil
+	case "MULTI":
+		return "OK", nil
+	case "EXEC":
+		return []interface{}{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported command %s", commandName)
+	}
+}
+
+func newFakeRedisStore() *RedisStore {
+	store, _ := newFakeRedisServer()
+	return store
+}
+
+// newFakeRedisServer returns a store connected to a new fakeRedis
+func newFakeRedisServer() (*RedisStore, *fakeRedis) {
+	server := &fakeRedis{values: map[string][]byte{}, hashes: map[string]map[string][]byte{}}
+	return &RedisStore{pool: &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return &fakeRedisConn{server: server}, nil
+		},
+	}}, server
+}
+
+func TestRedisStore(t *testing.T) {
+	testInventoryStore(t, newFakeRedisStore())
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	store, server := newFakeRedisServer()
+	require.NoError(t, store.SaveInventory(getDefaultProductsList()))
+
+	server.values[RedisAuditLogKey] = []byte(`{"data":[{"cardId":`)
+	_, err := store.LoadAuditLog()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotStored, "a corrupt document is not a missing one")
+
+	server.err = errors.New("connection refused")
+	_, err = store.LoadInventory()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotStored)
+	assert.Error(t, store.SaveInventory(getDefaultProductsList()))
+}
+
+func TestRedisStoreLoadAuditLogEntryWithoutIndex(t *testing.T) {
+	store, server := newFakeRedisServer()
+	// an audit log saved before the index was added
+	data, err := json.Marshal(getDefaultAuditsList())
+	require.NoError(t, err)
+	server.values[RedisAuditLogKey] = data
+
+	auditLogEntry, err := store.LoadAuditLogEntry("3")
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultAuditsList().Data[2], auditLogEntry)
+	_, err = store.LoadAuditLogEntry("42")
+	assert.ErrorIs(t, err, ErrAuditLogEntryNotFound)
+
+	// the next save builds the index
+	require.NoError(t, store.SaveAuditLog(getDefaultAuditsList()))
+	assert.Len(t, server.hashes[RedisAuditLogIndexKey], len(getDefaultAuditsList().Data))
+}
+
+func TestNewRedisStoreUnreachable(t *testing.T) {
+	_, err := NewRedisStore("redis://127.0.0.1:1/0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,210 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// restockQuantity returns the number of units that brings the product up to
+// its maximum restocking level. Negative units on hand count as none.
+func restockQuantity(item Product) int {
+	unitsOnHand := item.UnitsOnHand
+	if unitsOnHand < 0 {
+		unitsOnHand = 0
+	}
+	if !item.IsActive || unitsOnHand >= item.MaxRestockingLevel {
+		return 0
+	}
+	return item.MaxRestockingLevel - unitsOnHand
+}
+
+// BuildRestockSuggestions returns the active products with fewer units on
+// hand than their maximum restocking level, in inventory order
+func BuildRestockSuggestions(inventoryItems Products) []RestockSuggestion {
+	suggestions := []RestockSuggestion{}
+	for _, item := range inventoryItems.Data {
+		quantity := restockQuantity(item)
+		if quantity == 0 {
+			continue
+		}
+		suggestions = append(suggestions, RestockSuggestion{
+			SKU:                item.SKU,
+			ProductName:        item.ProductName,
+			UnitsOnHand:        item.UnitsOnHand,
+			MaxRestockingLevel: item.MaxRestockingLevel,
+			Quantity:           quantity,
+		})
+	}
+	return suggestions
+}
+
+// Validate checks that the restock has a card ID and a positive quantity of
+// each of its SKUs, which are listed once
+func (restock RestockRequest) Validate() error {
+	if strings.TrimSpace(restock.CardID) == "" {
+		return errors.New("cardId is required")
+	}
+	if len(restock.Items) == 0 {
+		return errors.New("items must list at least one SKU")
+	}
+	skus := make(map[string]bool)
+	for _, item := range restock.Items {
+		switch {
+		case strings.TrimSpace(item.SKU) == "":
+			return errors.New("every item must have a sku")
+		case item.Quantity < 1:
+			return fmt.Errorf("quantity of %s must be 1 or more", item.SKU)
+		case skus[item.SKU]:
+			return fmt.Errorf("SKU %s is listed more than once", item.SKU)
+		}
+		skus[item.SKU] = true
+	}
+	return nil
+}
+
+// RestockPost adds the units a worker restocked to the units on hand of
+// their SKUs, and records the restock in the stock movements and the audit
+// log. Nothing is changed when any item is invalid or its SKU is not in
+// inventory.
+func (c *Controller) RestockPost(writer http.ResponseWriter, req *http.Request) {
+	var restock RestockRequest
+	if statusCode, err := c.decodeJSONBody(writer, req, &restock); err != nil {
+		c.lc.Errorf("Failed to process the posted restock: %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the posted restock: " + err.Error()))
+		return
+	}
+	if err := restock.Validate(); err != nil {
+		c.lc.Errorf("Failed to process the posted restock: %s", err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("Failed to process the posted restock: " + err.Error()))
+		return
+	}
+
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+	index := make(map[string]int)
+	for i, item := range inventoryItems.Data {
+		index[item.SKU] = i
+	}
+	for _, item := range restock.Items {
+		if _, ok := index[item.SKU]; !ok {
+			c.lc.Errorf("Failed to process the posted restock: SKU %s does not exist in inventory", item.SKU)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Failed to process the posted restock: SKU " + item.SKU + " does not exist in inventory"))
+			return
+		}
+	}
+
+	now := time.Now().UnixNano()
+	source := &DeltaSource{User: restock.CardID}
+	report := RestockReport{CardID: restock.CardID, Items: []RestockReportItem{}, CreatedAt: now}
+	auditLogEntry := AuditLogEntry{CardID: restock.CardID, InventoryDelta: []DeltaInventorySKU{}, CreatedAt: now}
+	var updatedInventoryItems []Product
+	var stockMovements []StockMovement
+	for _, item := range restock.Items {
+		product := &inventoryItems.Data[index[item.SKU]]
+		unitsOnHand := product.UnitsOnHand
+		product.UnitsOnHand += item.Quantity
+		product.Version++
+		product.UpdatedAt = now
+
+		delta := DeltaInventorySKU{SKU: item.SKU, Delta: item.Quantity, Reason: DeltaReasonRestock, Source: source}
+		auditLogEntry.InventoryDelta = append(auditLogEntry.InventoryDelta, delta)
+		stockMovements = append(stockMovements, NewStockMovement(delta, *product, now))
+		updatedInventoryItems = append(updatedInventoryItems, *product)
+		report.Items = append(report.Items, RestockReportItem{
+			SKU:                item.SKU,
+			ProductName:        product.ProductName,
+			Quantity:           item.Quantity,
+			UnitsOnHandBefore:  unitsOnHand,
+			UnitsOnHandAfter:   product.UnitsOnHand,
+			MaxRestockingLevel: product.MaxRestockingLevel,
+			Overstocked:        product.UnitsOnHand > product.MaxRestockingLevel,
+		})
+		report.TotalUnits += item.Quantity
+	}
+
+	if err := c.inventoryStore().SaveInventory(inventoryItems); err != nil {
+		c.lc.Errorf("Failed to write inventory data: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to write inventory data: " + err.Error()))
+		return
+	}
+	// the restock is already applied, so failures to record it are only
+	// logged rather than failing the request and having it posted again
+	if err := c.recordStockMovements(stockMovements); err != nil {
+		c.lc.Errorf("failed to record stock movements: %s", err.Error())
+	}
+	auditLogEntry.AuditEntryID = uuid.New().String()
+	if err := c.appendAuditLogEntry(auditLogEntry); err != nil {
+		c.lc.Errorf("failed to record the restock in the audit log: %s", err.Error())
+	} else {
+		report.AuditEntryID = auditLogEntry.AuditEntryID
+	}
+	c.publishInventoryEvent(InventoryEventStockUpdated, updatedInventoryItems)
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		c.lc.Info("Restocked inventory successfully")
+		writer.Write([]byte("Restocked inventory successfully"))
+		return
+	}
+	c.lc.Infof("Card %s restocked %d unit(s) of %d SKU(s)", restock.CardID, report.TotalUnits, len(report.Items))
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(reportJSON)
+}
+
+// appendAuditLogEntry appends the entry to the audit log in the inventory
+// store, starting the audit log if it has not been stored yet
+func (c *Controller) appendAuditLogEntry(auditLogEntry AuditLogEntry) error {
+	auditLog, err := c.GetAuditLog()
+	if errors.Is(err, ErrNotStored) {
+		auditLog, err = AuditLog{Data: []AuditLogEntry{}}, nil
+	}
+	if err != nil {
+		return err
+	}
+	auditLog.Data = append(auditLog.Data, auditLogEntry)
+	return c.inventoryStore().SaveAuditLog(auditLog)
+}
+
+// RestockSuggestionsGet returns the units of each product that bring it up
+// to its maximum restocking level
+func (c *Controller) RestockSuggestionsGet(writer http.ResponseWriter, req *http.Request) {
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+
+	suggestionsJSON, err := json.Marshal(BuildRestockSuggestions(inventoryItems))
+	if err != nil {
+		c.lc.Errorf("Failed to process the restock suggestions: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the restock suggestions: " + err.Error()))
+		return
+	}
+	c.lc.Info("Successfully built the restock suggestions")
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(suggestionsJSON)
+}
@@ -0,0 +1,117 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildRestockSuggestions(t *testing.T) {
+	inventoryItems := getDefaultProductsList()
+	inventoryItems.Data[0].UnitsOnHand = 20
+	inventoryItems.Data[1].UnitsOnHand = 18
+	inventoryItems.Data[2].UnitsOnHand = -2
+
+	suggestions := BuildRestockSuggestions(inventoryItems)
+	require.Len(t, suggestions, 2)
+	assert.Equal(t, RestockSuggestion{SKU: "4900002470", ProductName: "Sprite (Lemon-Lime) - 16.9 oz", UnitsOnHand: 20, MaxRestockingLevel: 24, Quantity: 4}, suggestions[0])
+	assert.Equal(t, "1200050408", suggestions[1].SKU)
+	assert.Equal(t, 6, suggestions[1].Quantity, "negative units on hand count as none")
+
+	inventoryItems.Data[0].IsActive = false
+	assert.Len(t, BuildRestockSuggestions(inventoryItems), 1, "inactive products are not restocked")
+}
+
+func TestRestockRequestValidate(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Restock       RestockRequest
+		ExpectedError string
+	}{
+		{"valid", RestockRequest{CardID: "0003293374", Items: []RestockItem{{SKU: "4900002470", Quantity: 4}}}, ""},
+		{"no card", RestockRequest{Items: []RestockItem{{SKU: "4900002470", Quantity: 4}}}, "cardId is required"},
+		{"no items", RestockRequest{CardID: "0003293374"}, "items must list at least one SKU"},
+		{"no sku", RestockRequest{CardID: "0003293374", Items: []RestockItem{{Quantity: 4}}}, "every item must have a sku"},
+		{"zero quantity", RestockRequest{CardID: "0003293374", Items: []RestockItem{{SKU: "4900002470"}}}, "quantity of 4900002470 must be 1 or more"},
+		{"duplicate", RestockRequest{CardID: "0003293374", Items: []RestockItem{{SKU: "4900002470", Quantity: 4}, {SKU: "4900002470", Quantity: 1}}}, "SKU 4900002470 is listed more than once"},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			err := currentTest.Restock.Validate()
+			if currentTest.ExpectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, currentTest.ExpectedError, err.Error())
+		})
+	}
+}
+
+func TestRestockRoutes(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	c.inventoryItems = getDefaultProductsList()
+	c.inventoryItems.Data[1].UnitsOnHand = 10
+	require.NoError(t, c.WriteInventory())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.RestockPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48095/inventory/restock", bytes.NewBufferString(body)))
+		return w
+	}
+
+	w := post(`{"cardId":"0003293374","items":[{"sku":"9999999999","quantity":1},{"sku":"4900002470","quantity":2}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Failed to process the posted restock: SKU 9999999999 does not exist in inventory", w.Body.String())
+	w = post(`{"items":[{"sku":"4900002470","quantity":2}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Failed to process the posted restock: cardId is required", w.Body.String())
+
+	w = post(`{"cardId":"0003293374","items":[{"sku":"4900002470","quantity":24},{"sku":"1200010735","quantity":10}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report RestockReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "0003293374", report.CardID)
+	assert.Equal(t, 34, report.TotalUnits)
+	assert.NotEmpty(t, report.AuditEntryID)
+	require.Len(t, report.Items, 2)
+	assert.Equal(t, RestockReportItem{SKU: "1200010735", ProductName: "Mountain Dew (Low Calorie) - 16.9 oz", Quantity: 10, UnitsOnHandBefore: 10, UnitsOnHandAfter: 20, MaxRestockingLevel: 18, Overstocked: true}, report.Items[1])
+
+	inventoryItems, err := c.GetInventoryItems()
+	require.NoError(t, err)
+	assert.Equal(t, 24, inventoryItems.Data[0].UnitsOnHand, "the rejected restock changes nothing")
+	assert.Equal(t, int64(1), inventoryItems.Data[0].Version)
+	assert.Equal(t, 20, inventoryItems.Data[1].UnitsOnHand)
+
+	auditLog, err := c.GetAuditLog()
+	require.NoError(t, err)
+	require.Len(t, auditLog.Data, 1)
+	assert.Equal(t, report.AuditEntryID, auditLog.Data[0].AuditEntryID)
+	assert.Equal(t, "0003293374", auditLog.Data[0].CardID)
+	require.Len(t, auditLog.Data[0].InventoryDelta, 2)
+	assert.Equal(t, DeltaReasonRestock, auditLog.Data[0].InventoryDelta[0].Reason)
+
+	stockMovements, err := c.GetStockMovements()
+	require.NoError(t, err)
+	require.Len(t, stockMovements.Data, 2)
+	assert.Equal(t, DeltaReasonRestock, stockMovements.Data[0].Reason)
+	assert.Equal(t, "0003293374", stockMovements.Data[0].Source.User)
+
+	w = httptest.NewRecorder()
+	c.RestockSuggestionsGet(w, httptest.NewRequest(http.MethodGet, "http://localhost:48095/inventory/restock/suggestions", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var suggestions []RestockSuggestion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &suggestions))
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "1200050408", suggestions[0].SKU)
+	assert.Equal(t, 6, suggestions[0].Quantity)
+}
@@ -0,0 +1,232 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAuditLogRotationInterval is how often the audit log is rotated
+	// when no interval is configured
+	DefaultAuditLogRotationInterval = time.Hour
+
+	// rotated segments are named auditlog-YYYYMMDDTHHMMSSZ.json.gz after
+	// the time they were rotated
+	auditLogSegmentPrefix     = "auditlog-"
+	auditLogSegmentSuffix     = ".json.gz"
+	auditLogSegmentTimeLayout = "20060102T150405Z"
+)
+
+// AuditLogRotationPolicy configures when audit log entries are moved out of
+// the audit log into compressed segments
+type AuditLogRotationPolicy struct {
+	// MaxEntries is how many entries are kept in the audit log, 0 does not
+	// limit the number of entries
+	MaxEntries int
+	// MaxAge is how long entries are kept in the audit log, 0 does not
+	// limit the age of entries
+	MaxAge time.Duration
+	// Directory holds the rotated segments
+	Directory string
+}
+
+// AuditLogSegment describes a rotated segment of the audit log
+type AuditLogSegment struct {
+	Name      string `json:"name"`
+	RotatedAt string `json:"rotatedAt"`
+	// Size is the compressed size of the segment in bytes
+	Size int64 `json:"size"`
+}
+
+// AuditLogSegments is the list of rotated segments, oldest first
+type AuditLogSegments struct {
+	Segments []AuditLogSegment `json:"segments"`
+}
+
+// Enabled reports whether the audit log is rotated
+func (policy AuditLogRotationPolicy) Enabled() bool {
+	return policy.MaxEntries > 0 || policy.MaxAge > 0
+}
+
+// segmentFileName is the file of the segment with the name
+func (policy AuditLogRotationPolicy) segmentFileName(name string) string {
+	return filepath.Join(policy.Directory, name)
+}
+
+// rotated splits the entries of the audit log into the ones that are kept
+// and the ones that are rotated: the entries older than MaxAge, and the
+// oldest entries beyond MaxEntries
+func (policy AuditLogRotationPolicy) rotated(auditLog AuditLog, now time.Time) (kept []AuditLogEntry, rotated []AuditLogEntry) {
+	kept = []AuditLogEntry{}
+	excess := 0
+	if policy.MaxEntries > 0 && len(auditLog.Data) > policy.MaxEntries {
+		excess = len(auditLog.Data) - policy.MaxEntries
+	}
+	cutoff := now.Add(-policy.MaxAge).UnixNano()
+	for i, auditLogEntry := range auditLog.Data {
+		if i < excess || (policy.MaxAge > 0 && auditLogEntry.CreatedAt < cutoff) {
+			rotated = append(rotated, auditLogEntry)
+			continue
+		}
+		kept = append(kept, auditLogEntry)
+	}
+	return kept, rotated
+}
+
+// RotateAuditLog moves the entries that exceed the rotation policy into a
+// new compressed segment, and returns how many were rotated. The segment is
+// written before the entries are removed from the audit log, so an
+// interrupted rotation does not lose any entries.
+func (c *Controller) RotateAuditLog(now time.Time) (int, error) {
+	if !c.auditLogRotation.Enabled() {
+		return 0, nil
+	}
+	auditLog, err := c.GetAuditLog()
+	if errors.Is(err, ErrNotStored) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	kept, rotated := c.auditLogRotation.rotated(auditLog, now)
+	if len(rotated) == 0 {
+		return 0, nil
+	}
+
+	name := auditLogSegmentPrefix + now.UTC().Format(auditLogSegmentTimeLayout) + auditLogSegmentSuffix
+	fileName := c.auditLogRotation.segmentFileName(name)
+	if _, err := os.Stat(fileName); err == nil {
+		return 0, fmt.Errorf("audit log segment %s already exists", name)
+	}
+	data, err := json.Marshal(AuditLog{Data: rotated})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal audit log segment: %s", err.Error())
+	}
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to compress audit log segment: %s", err.Error())
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to compress audit log segment: %s", err.Error())
+	}
+	if err := os.MkdirAll(c.auditLogRotation.Directory, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create audit log archive directory: %s", err.Error())
+	}
+	if err := c.fileWriter.WriteFile(fileName, compressed.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write audit log segment %s: %s", name, err.Error())
+	}
+
+	if err := c.inventoryStore().SaveAuditLog(AuditLog{Data: kept}); err != nil {
+		return 0, fmt.Errorf("failed to write audit log: %s", err.Error())
+	}
+	return len(rotated), nil
+}
+
+// GetAuditLogSegment returns the entries of a rotated segment. The error
+// wraps os.ErrNotExist when there is no segment with the name.
+func (c *Controller) GetAuditLogSegment(name string) (AuditLog, error) {
+	if !isAuditLogSegmentName(name) {
+		return AuditLog{}, fmt.Errorf("%s is not an audit log segment name", name)
+	}
+	file, err := os.Open(c.auditLogRotation.segmentFileName(name))
+	if err != nil {
+		return AuditLog{}, fmt.Errorf("failed to load audit log segment %s: %w", name, err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return AuditLog{}, fmt.Errorf("failed to decompress audit log segment %s: %s", name, err.Error())
+	}
+	data, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return AuditLog{}, fmt.Errorf("failed to decompress audit log segment %s: %s", name, err.Error())
+	}
+	var auditLog AuditLog
+	if err := json.Unmarshal(data, &auditLog); err != nil {
+		return AuditLog{}, fmt.Errorf("failed to unmarshal audit log segment %s: %s", name, err.Error())
+	}
+	return auditLog, nil
+}
+
+// GetAuditLogSegments returns the rotated segments, oldest first
+func (c *Controller) GetAuditLogSegments() (AuditLogSegments, error) {
+	segments := AuditLogSegments{Segments: []AuditLogSegment{}}
+	entries, err := os.ReadDir(c.auditLogRotation.Directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return segments, nil
+	}
+	if err != nil {
+		return segments, fmt.Errorf("failed to read audit log archive directory: %s", err.Error())
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isAuditLogSegmentName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return segments, fmt.Errorf("failed to read audit log segment %s: %s", entry.Name(), err.Error())
+		}
+		rotatedAt, _ := time.Parse(auditLogSegmentTimeLayout, strings.TrimSuffix(strings.TrimPrefix(entry.Name(), auditLogSegmentPrefix), auditLogSegmentSuffix))
+		segments.Segments = append(segments.Segments, AuditLogSegment{
+			Name:      entry.Name(),
+			RotatedAt: rotatedAt.Format(time.RFC3339),
+			Size:      info.Size(),
+		})
+	}
+	sort.Slice(segments.Segments, func(i, j int) bool {
+		return segments.Segments[i].Name < segments.Segments[j].Name
+	})
+	return segments, nil
+}
+
+// isAuditLogSegmentName checks that the name is a segment name, which also
+// keeps it from reaching outside of the archive directory
+func isAuditLogSegmentName(name string) bool {
+	if !strings.HasPrefix(name, auditLogSegmentPrefix) || !strings.HasSuffix(name, auditLogSegmentSuffix) {
+		return false
+	}
+	_, err := time.Parse(auditLogSegmentTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, auditLogSegmentPrefix), auditLogSegmentSuffix))
+	return err == nil
+}
+
+// RunAuditLogRotation rotates the audit log on start and then every
+// interval until the context is cancelled. It returns straight away when
+// rotation is disabled.
+func (c *Controller) RunAuditLogRotation(ctx context.Context, interval time.Duration) {
+	if !c.auditLogRotation.Enabled() {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultAuditLogRotationInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rotated, err := c.RotateAuditLog(time.Now())
+		if err != nil {
+			c.lc.Errorf("Failed to rotate the audit log: %s", err.Error())
+		} else if rotated > 0 {
+			c.lc.Infof("Rotated %d audit log entries to %s", rotated, c.auditLogRotation.Directory)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
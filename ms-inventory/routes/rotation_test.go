@@ -0,0 +1,142 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRotationTestController(t *testing.T, policy AuditLogRotationPolicy) Controller {
+	dir := t.TempDir()
+	policy.Directory = filepath.Join(dir, "auditlog-archive")
+	return Controller{
+		lc:               logger.NewMockClient(),
+		auditLogFileName: filepath.Join(dir, AuditLogFileName),
+		auditLogRotation: policy,
+	}
+}
+
+func TestRotateAuditLog(t *testing.T) {
+	now := time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC)
+	auditLog := getDefaultAuditsList()
+	auditLog.Data[0].CreatedAt = now.Add(-48 * time.Hour).UnixNano()
+	auditLog.Data[1].CreatedAt = now.Add(-2 * time.Hour).UnixNano()
+	auditLog.Data[2].CreatedAt = now.Add(-time.Hour).UnixNano()
+
+	tests := []struct {
+		Name        string
+		Policy      AuditLogRotationPolicy
+		ExpectedIDs []string
+	}{
+		{"Disabled", AuditLogRotationPolicy{}, nil},
+		{"Max entries", AuditLogRotationPolicy{MaxEntries: 1}, []string{"1", "2"}},
+		{"Max age", AuditLogRotationPolicy{MaxAge: 24 * time.Hour}, []string{"1"}},
+		{"Max entries and age", AuditLogRotationPolicy{MaxEntries: 2, MaxAge: 90 * time.Minute}, []string{"1", "2"}},
+		{"Within the policy", AuditLogRotationPolicy{MaxEntries: 3, MaxAge: 72 * time.Hour}, nil},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newRotationTestController(t, currentTest.Policy)
+			c.auditLog = auditLog
+			require.NoError(t, c.WriteAuditLog())
+
+			rotated, err := c.RotateAuditLog(now)
+			require.NoError(t, err)
+			assert.Equal(t, len(currentTest.ExpectedIDs), rotated)
+
+			segments, err := c.GetAuditLogSegments()
+			require.NoError(t, err)
+			remaining, err := c.GetAuditLog()
+			require.NoError(t, err)
+			if currentTest.ExpectedIDs == nil {
+				assert.Empty(t, segments.Segments)
+				assert.Equal(t, auditLog, remaining)
+				return
+			}
+
+			require.Len(t, segments.Segments, 1)
+			assert.Equal(t, AuditLogSegment{Name: "auditlog-20230315T120000Z.json.gz", RotatedAt: "2023-03-15T12:00:00Z", Size: segments.Segments[0].Size}, segments.Segments[0])
+			segment, err := c.GetAuditLogSegment(segments.Segments[0].Name)
+			require.NoError(t, err)
+			rotatedIDs := []string{}
+			for _, auditLogEntry := range segment.Data {
+				rotatedIDs = append(rotatedIDs, auditLogEntry.AuditEntryID)
+			}
+			assert.Equal(t, currentTest.ExpectedIDs, rotatedIDs)
+			assert.Len(t, remaining.Data, len(auditLog.Data)-rotated, "rotated entries are removed from the audit log")
+		})
+	}
+}
+
+func TestRotateAuditLogExistingSegment(t *testing.T) {
+	now := time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC)
+	c := newRotationTestController(t, AuditLogRotationPolicy{MaxEntries: 1})
+	c.auditLog = getDefaultAuditsList()
+	require.NoError(t, c.WriteAuditLog())
+	require.NoError(t, os.MkdirAll(c.auditLogRotation.Directory, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(c.auditLogRotation.Directory, "auditlog-20230315T120000Z.json.gz"), []byte{}, 0644))
+
+	_, err := c.RotateAuditLog(now)
+	require.EqualError(t, err, "audit log segment auditlog-20230315T120000Z.json.gz already exists")
+	remaining, err := c.GetAuditLog()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultAuditsList(), remaining, "the entries stay in the audit log until they are rotated")
+}
+
+// TestAuditLogArchiveGet tests listing and reading the rotated audit log
+// segments
+func TestAuditLogArchiveGet(t *testing.T) {
+	now := time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC)
+	c := newRotationTestController(t, AuditLogRotationPolicy{MaxEntries: 2})
+	c.auditLog = getDefaultAuditsList()
+	require.NoError(t, c.WriteAuditLog())
+	_, err := c.RotateAuditLog(now)
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name               string
+		Query              string
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{"List", "", http.StatusOK, `"name":"auditlog-20230315T120000Z.json.gz","rotatedAt":"2023-03-15T12:00:00Z"`},
+		{"Segment", "?segment=auditlog-20230315T120000Z.json.gz", http.StatusOK, `"auditEntryId":"1"`},
+		{"Missing segment", "?segment=auditlog-20230316T120000Z.json.gz", http.StatusNotFound, ""},
+		{"Invalid segment name", "?segment=../auditlog.json", http.StatusBadRequest, "Please enter a segment name listed by /auditlog/archive"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:48095/auditlog/archive"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.AuditLogArchiveGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			assert.Contains(t, w.Body.String(), currentTest.ExpectedBody)
+		})
+	}
+
+	// an empty archive is listed as no segments
+	c.auditLogRotation.Directory = filepath.Join(t.TempDir(), "missing")
+	segments, err := c.GetAuditLogSegments()
+	require.NoError(t, err)
+	segmentsJSON, err := json.Marshal(segments)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"segments":[]}`, string(segmentsJSON))
+}
@@ -5,11 +5,13 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"io"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,56 +19,88 @@ import (
 // REST requests to occur
 func (c *Controller) DeltaInventorySKUPost(writer http.ResponseWriter, req *http.Request) {
 
-	// Read request body
-	body := make([]byte, req.ContentLength)
-	if _, err := io.ReadFull(req.Body, body); err != nil {
-		c.lc.Errorf("Failed to process the posted delta inventory item(s): %s", err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
-		writer.Write([]byte("Failed to process the posted delta inventory item(s): " + err.Error()))
-		return
-	}
-
-	// Unmarshal the string contents of request into a proper structure
+	// Decode the request body into a proper structure
 	var deltaInventorySKUList []DeltaInventorySKU
-	err := json.Unmarshal(body, &deltaInventorySKUList)
-	if err != nil {
+	if statusCode, err := c.decodeJSONBody(writer, req, &deltaInventorySKUList); err != nil {
 		c.lc.Errorf("Failed to process the posted delta inventory item(s): %s", err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
+		writer.WriteHeader(statusCode)
 		writer.Write([]byte("Failed to process the posted delta inventory item(s): " + err.Error()))
 		return
 	}
+	for _, deltaInventorySKU := range deltaInventorySKUList {
+		if deltaInventorySKU.Reason != "" && !IsValidDeltaReason(deltaInventorySKU.Reason) {
+			c.lc.Errorf("Delta inventory item %s has an unknown reason %q", deltaInventorySKU.SKU, deltaInventorySKU.Reason)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(fmt.Sprintf("Failed to process the posted delta inventory item(s): the reason of %s must be one of %s", deltaInventorySKU.SKU, strings.Join(DeltaReasons, ", "))))
+			return
+		}
+	}
+
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
 
-	// load the inventory.json file
-	var inventoryItems Products
-	data, err := os.ReadFile(c.inventoryFileName)
+	// load the inventory
+	inventoryItems, err := c.GetInventoryItems()
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to read from inventory items JSON file: %s", err.Error())
-		c.lc.Error(errMsg)
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte(errMsg))
-	}
-	if err = json.Unmarshal(data, &inventoryItems); err != nil {
-		errMsg := fmt.Sprintf("failed to unmarshal inventory items from JSON file: %s", err.Error())
+		errMsg := fmt.Sprintf("failed to load inventory items: %s", err.Error())
 		c.lc.Error(errMsg)
 		writer.WriteHeader(http.StatusInternalServerError)
 		writer.Write([]byte(errMsg))
+		return
 	}
 
 	// iterate over all deltaInventorySKU's and find their corresponding SKU in inventory
-	// then update the inventory with the delta
-	var updatedInventoryItems []Product // will return the inventory items that got updated
+	// then update the inventory with the delta, following the negative stock policy
+	var deltaResults []DeltaInventoryResult // will return the deltas that were applied
+	var updatedInventoryItems []Product
+	var stockMovements []StockMovement
+	var rejected []string
 	performedUpdate := false
+	now := time.Now().UnixNano()
 	for _, deltaInventorySKU := range deltaInventorySKUList {
 		for i, inventoryItem := range inventoryItems.Data {
 			if deltaInventorySKU.SKU == inventoryItem.SKU {
-				inventoryItems.Data[i].UnitsOnHand += deltaInventorySKU.Delta
+				unitsOnHand := inventoryItem.UnitsOnHand
+				applied, discrepancy, err := applyNegativeStockPolicy(c.negativeStockPolicy, unitsOnHand, deltaInventorySKU.Delta)
+				if err != nil {
+					rejected = append(rejected, fmt.Sprintf("%s: %s", deltaInventorySKU.SKU, err.Error()))
+					break
+				}
+				if discrepancy {
+					c.lc.Warnf("Delta of %d for SKU %s took more than the %d units on hand, %d was applied", deltaInventorySKU.Delta, deltaInventorySKU.SKU, unitsOnHand, applied)
+				}
+				inventoryItems.Data[i].UnitsOnHand += applied
+				inventoryItems.Data[i].Version++
+				deltaResults = append(deltaResults, DeltaInventoryResult{
+					SKU:               deltaInventorySKU.SKU,
+					Delta:             deltaInventorySKU.Delta,
+					AppliedDelta:      applied,
+					UnitsOnHandBefore: unitsOnHand,
+					UnitsOnHandAfter:  inventoryItems.Data[i].UnitsOnHand,
+					Discrepancy:       discrepancy,
+					Product:           inventoryItems.Data[i],
+				})
 				updatedInventoryItems = append(updatedInventoryItems, inventoryItems.Data[i])
+				// the movement records the delta that was applied, so that
+				// the movements add up to the units on hand
+				appliedDelta := deltaInventorySKU
+				appliedDelta.Delta = applied
+				stockMovements = append(stockMovements, NewStockMovement(appliedDelta, inventoryItems.Data[i], now))
 				performedUpdate = true
 				break
 			}
 		}
 	}
 
+	// a rejected delta rejects the whole request, so that none of its deltas
+	// are applied
+	if len(rejected) > 0 {
+		c.lc.Errorf("Rejected the delta inventory item(s) that take more units than are on hand: %s", strings.Join(rejected, "; "))
+		writer.WriteHeader(http.StatusConflict)
+		writer.Write([]byte("Failed to process the posted delta inventory item(s), not enough units on hand for " + strings.Join(rejected, "; ")))
+		return
+	}
+
 	// Nothing was done, so return "Not Modified" status
 	if !performedUpdate {
 		c.lc.Info("No change made to inventory")
@@ -75,47 +109,125 @@ func (c *Controller) DeltaInventorySKUPost(writer http.ResponseWriter, req *http
 		return
 	}
 
-	// Write the updated inventory to the inventory json file
-	inventoryData, err := json.Marshal(inventoryItems)
-	if err != nil {
-		errMsg := fmt.Sprintf("failed to marshal inventory : %s", err.Error())
+	// Write the updated inventory to the inventory store
+	if err = c.inventoryStore().SaveInventory(inventoryItems); err != nil {
+		errMsg := fmt.Sprintf("failed to write inventory data: %s", err.Error())
 		c.lc.Error(errMsg)
 		writer.WriteHeader(http.StatusInternalServerError)
 		writer.Write([]byte(errMsg))
+		return
 	}
-	err = os.WriteFile(c.inventoryFileName, inventoryData, 0644)
-	if err != nil {
-		errMsg := fmt.Sprintf("failed to write inventory data to file: %s", err.Error())
-		c.lc.Error(errMsg)
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte(errMsg))
+	// the delta is already applied, so a failure to record its movements is
+	// only logged rather than failing the request and having it posted again
+	if err = c.recordStockMovements(stockMovements); err != nil {
+		c.lc.Errorf("failed to record stock movements: %s", err.Error())
 	}
 
-	// return the new/updated items as JSON, or if for some reason it cannot be processed back into
-	// JSON for returning to the user, fallback to a simple string
-	updatedInventoryItemsJSON, err := json.Marshal(updatedInventoryItems)
+	// return the applied deltas with the updated items as JSON, or if for some reason it cannot be
+	// processed back into JSON for returning to the user, fallback to a simple string
+	deltaResultsJSON, err := json.Marshal(deltaResults)
 	if err != nil {
 		c.lc.Info("Updated inventory successfully")
 		writer.Write([]byte("Updated inventory successfully"))
 	} else {
-		c.lc.Infof("Updated inventory successfully: %s", updatedInventoryItemsJSON)
-		writer.Write(updatedInventoryItemsJSON)
+		c.lc.Infof("Updated inventory successfully: %s", deltaResultsJSON)
+		writer.Write(deltaResultsJSON)
 	}
+	c.publishInventoryEvent(InventoryEventStockUpdated, updatedInventoryItems)
 }
 
-// InventoryPost allows new items to be added to inventory, as well as updating
-// existing items
-func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request) {
+// ContainerReturnPost records empty containers returned for deposit
+// refunds. Returned containers are counted separately from units on hand.
+func (c *Controller) ContainerReturnPost(writer http.ResponseWriter, req *http.Request) {
 
-	// Read request body
-	body := make([]byte, req.ContentLength)
-	if _, err := io.ReadFull(req.Body, body); err != nil {
-		c.lc.Errorf("Failed to process the posted inventory item(s): %s", err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
-		writer.Write([]byte("Failed to process the posted inventory item(s): " + err.Error()))
+	var containerReturns []ContainerReturn
+	if statusCode, err := c.decodeJSONBody(writer, req, &containerReturns); err != nil {
+		c.lc.Errorf("Failed to process the posted container return(s): %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the posted container return(s): " + err.Error()))
+		return
+	}
+
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
+		return
+	}
+
+	// validate every return before changing anything, so that a bad
+	// return does not leave the inventory partially updated
+	var updatedInventoryItems []Product
+	for _, containerReturn := range containerReturns {
+		if containerReturn.Count <= 0 {
+			errMsg := fmt.Sprintf("container return count for SKU %s must be greater than 0", containerReturn.SKU)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		found := false
+		for i := range inventoryItems.Data {
+			if inventoryItems.Data[i].SKU != containerReturn.SKU {
+				continue
+			}
+			if inventoryItems.Data[i].Deposit <= 0 {
+				errMsg := fmt.Sprintf("SKU %s does not have a container deposit", containerReturn.SKU)
+				c.lc.Error(errMsg)
+				writer.WriteHeader(http.StatusBadRequest)
+				writer.Write([]byte(errMsg))
+				return
+			}
+			found = true
+		}
+		if !found {
+			errMsg := fmt.Sprintf("SKU %s does not exist in inventory", containerReturn.SKU)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	for _, containerReturn := range containerReturns {
+		for i := range inventoryItems.Data {
+			if inventoryItems.Data[i].SKU == containerReturn.SKU {
+				inventoryItems.Data[i].ReturnedContainers += containerReturn.Count
+				inventoryItems.Data[i].UpdatedAt = time.Now().UnixNano()
+				inventoryItems.Data[i].Version++
+				updatedInventoryItems = append(updatedInventoryItems, inventoryItems.Data[i])
+				break
+			}
+		}
+	}
+
+	if err := c.inventoryStore().SaveInventory(inventoryItems); err != nil {
+		c.lc.Errorf("Failed to write inventory: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to write inventory: " + err.Error()))
 		return
 	}
 
+	c.publishInventoryEvent(InventoryEventStockUpdated, updatedInventoryItems)
+
+	updatedInventoryItemsJSON, err := json.Marshal(updatedInventoryItems)
+	if err != nil {
+		c.lc.Info("Recorded container returns successfully")
+		writer.Write([]byte("Recorded container returns successfully"))
+		return
+	}
+	c.lc.Infof("Recorded container returns successfully: %s", updatedInventoryItemsJSON)
+	writer.Write(updatedInventoryItemsJSON)
+}
+
+// InventoryPost allows new items to be added to inventory, as well as updating
+// existing items
+func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request) {
+
 	// deltaInventoryList is created as a map[string]interface{} because we are being
 	// passed in _fields_ to update, not entire structs. If we attempt to use structs,
 	// golang will automatically populate fields that were not modified by the user.
@@ -123,30 +235,66 @@ func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request
 	// https://play.golang.org/p/XJ0wiE629z8
 	deltaInventoryList := make([]map[string]interface{}, 0)
 
-	err := json.Unmarshal(body, &deltaInventoryList)
-	if err != nil {
+	if statusCode, err := c.decodeJSONBody(writer, req, &deltaInventoryList); err != nil {
 		c.lc.Errorf("Failed to process the posted inventory item(s): %s", err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
+		writer.WriteHeader(statusCode)
 		writer.Write([]byte("Failed to process the posted inventory item(s): " + err.Error()))
 		return
 	}
 
-	// load the inventory.json file
-	var inventoryItems Products
-	data, err := os.ReadFile(c.inventoryFileName)
+	// Validate the availability windows up front so that a bad window
+	// does not leave the inventory partially updated
+	for _, postedInventoryItem := range deltaInventoryList {
+		if postedInventoryItem["availability"] != nil {
+			if _, err := parseAvailability(postedInventoryItem["availability"]); err != nil {
+				c.lc.Errorf("Failed to process the posted inventory item(s): %s", err.Error())
+				writer.WriteHeader(http.StatusBadRequest)
+				writer.Write([]byte("Failed to process the posted inventory item(s): " + err.Error()))
+				return
+			}
+		}
+		if err := validateProductMetadata(postedInventoryItem); err != nil {
+			c.lc.Errorf("Failed to process the posted inventory item(s): %s", err.Error())
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Failed to process the posted inventory item(s): " + err.Error()))
+			return
+		}
+	}
+
+	// the inventory is locked from loading it until it is written, so that
+	// the versions checked are still current when the update is written
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	// load the inventory
+	inventoryItems, err := c.GetInventoryItems()
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to read from inventory items JSON file: %s", err.Error())
+		errMsg := fmt.Sprintf("failed to load inventory items: %s", err.Error())
 		c.lc.Error(errMsg)
 		writer.WriteHeader(http.StatusInternalServerError)
 		writer.Write([]byte(errMsg))
+		return
 	}
-	if err = json.Unmarshal(data, &inventoryItems); err != nil {
-		errMsg := fmt.Sprintf("failed to unmarshal inventory items from JSON file: %s", err.Error())
-		c.lc.Error(errMsg)
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte(errMsg))
+
+	// Updates of existing products must be made against their current
+	// version, and nothing is updated when any of them is not
+	if err := checkInventoryVersions(req, deltaInventoryList, inventoryItems); err != nil {
+		statusCode := http.StatusBadRequest
+		var conflict versionConflict
+		if errors.As(err, &conflict) {
+			statusCode = http.StatusConflict
+		} else if errors.Is(err, errVersionRequired) {
+			statusCode = http.StatusPreconditionRequired
+		}
+		c.lc.Errorf("Failed to process the posted inventory item(s): %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the posted inventory item(s): " + err.Error()))
+		return
 	}
 
+	// the prices before the update, to record the prices it changes
+	prices := productPrices(inventoryItems)
+
 	// Keep track of the items that get added so that the user can be informed of them in our response
 	var newInventoryItems []Product
 
@@ -180,8 +328,41 @@ func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request
 					switch postedInventoryItem["isActive"].(type) {
 					case bool:
 						inventoryItems.Data[i].IsActive = postedInventoryItem["isActive"].(bool)
+						// a reactivated product is no longer deactivated for
+						// its bulk deactivation's reason
+						if inventoryItems.Data[i].IsActive {
+							inventoryItems.Data[i].DeactivationReason = ""
+						}
 					}
 				}
+				if postedInventoryItem["category"] != nil {
+					switch postedInventoryItem["category"].(type) {
+					case string:
+						inventoryItems.Data[i].Category = postedInventoryItem["category"].(string)
+					}
+				}
+				if postedInventoryItem["availability"] != nil {
+					inventoryItems.Data[i].Availability, _ = parseAvailability(postedInventoryItem["availability"])
+				}
+				if postedInventoryItem["deposit"] != nil {
+					switch postedInventoryItem["deposit"].(type) {
+					case float64:
+						inventoryItems.Data[i].Deposit = postedInventoryItem["deposit"].(float64)
+					}
+				}
+				if postedInventoryItem["taxCategory"] != nil {
+					switch postedInventoryItem["taxCategory"].(type) {
+					case string:
+						inventoryItems.Data[i].TaxCategory = postedInventoryItem["taxCategory"].(string)
+					}
+				}
+				if postedInventoryItem["currency"] != nil {
+					switch postedInventoryItem["currency"].(type) {
+					case string:
+						inventoryItems.Data[i].Currency = strings.ToUpper(postedInventoryItem["currency"].(string))
+					}
+				}
+				setProductMetadata(&inventoryItems.Data[i], postedInventoryItem)
 				if postedInventoryItem["unitsOnHand"] != nil {
 					switch postedInventoryItem["unitsOnHand"].(type) {
 					case float64:
@@ -202,6 +383,7 @@ func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request
 					}
 				}
 				inventoryItems.Data[i].UpdatedAt = time.Now().UnixNano()
+				inventoryItems.Data[i].Version++
 				inventoryChanged = true
 				newInventoryItems = append(newInventoryItems, inventoryItems.Data[i])
 				writer.Write([]byte("Updated inventory"))
@@ -213,6 +395,7 @@ func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request
 				CreatedAt: time.Now().UnixNano(),
 				UpdatedAt: time.Now().UnixNano(),
 				IsActive:  true,
+				Version:   1,
 			}
 			// Set the ItemPrice. If the ItemPrice isn't provided set a default value
 			if postedInventoryItem["itemPrice"] != nil {
@@ -258,6 +441,40 @@ func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request
 			} else {
 				newProduct.MinRestockingLevel = 0
 			}
+			// Set the category if provided
+			if postedInventoryItem["category"] != nil {
+				switch postedInventoryItem["category"].(type) {
+				case string:
+					newProduct.Category = postedInventoryItem["category"].(string)
+				}
+			}
+			// Set the availability windows if provided
+			if postedInventoryItem["availability"] != nil {
+				newProduct.Availability, _ = parseAvailability(postedInventoryItem["availability"])
+			}
+			// Set the container deposit if provided
+			if postedInventoryItem["deposit"] != nil {
+				switch postedInventoryItem["deposit"].(type) {
+				case float64:
+					newProduct.Deposit = postedInventoryItem["deposit"].(float64)
+				}
+			}
+			// Set the tax category if provided
+			if postedInventoryItem["taxCategory"] != nil {
+				switch postedInventoryItem["taxCategory"].(type) {
+				case string:
+					newProduct.TaxCategory = postedInventoryItem["taxCategory"].(string)
+				}
+			}
+			// Set the currency if provided
+			if postedInventoryItem["currency"] != nil {
+				switch postedInventoryItem["currency"].(type) {
+				case string:
+					newProduct.Currency = strings.ToUpper(postedInventoryItem["currency"].(string))
+				}
+			}
+			// Set the barcode, image URL and weight if provided
+			setProductMetadata(&newProduct, postedInventoryItem)
 			// Add new product to the product List
 			inventoryItems.Data = append(inventoryItems.Data, newProduct)
 			inventoryChanged = true
@@ -267,21 +484,19 @@ func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request
 	}
 
 	if inventoryChanged {
-		// Write the updated audit log to the audit log json file
-		inventoryData, err := json.Marshal(inventoryItems)
-		if err != nil {
-			errMsg := fmt.Sprintf("failed to marshal inventory data: %s", err.Error())
-			c.lc.Errorf(errMsg)
-			writer.WriteHeader(http.StatusInternalServerError)
-			writer.Write([]byte(errMsg))
-			return
-		}
-		if err = os.WriteFile(c.inventoryFileName, inventoryData, 0644); err != nil {
+		// Write the updated inventory to the inventory store
+		if err := c.inventoryStore().SaveInventory(inventoryItems); err != nil {
 			c.lc.Errorf("Failed to write inventory: %s", err.Error())
 			writer.WriteHeader(http.StatusInternalServerError)
 			writer.Write([]byte("Failed to write inventory: " + err.Error()))
 			return
 		}
+		// the update is already applied, so a failure to record its price
+		// changes is only logged rather than failing the request
+		if err := c.recordPriceChanges(PriceChanges(prices, inventoryItems, time.Now().UnixNano())); err != nil {
+			c.lc.Errorf("failed to record price changes: %s", err.Error())
+		}
+		c.publishInventoryEvent(InventoryEventProductUpdated, newInventoryItems)
 		// return the new/updated items as JSON, or if for some reason it cannot be processed back into
 		// JSON for returning to the user, fallback to a simple string
 		newInventoryItemsJSON, err := json.Marshal(newInventoryItems)
@@ -295,24 +510,95 @@ func (c *Controller) InventoryPost(writer http.ResponseWriter, req *http.Request
 	}
 }
 
-// AuditLogPost allows for a new audit log entry to be added
-func (c *Controller) AuditLogPost(writer http.ResponseWriter, req *http.Request) {
+// InventoryImportPost adds and updates inventory items from a CSV file, so
+// that product catalogs can be managed in a spreadsheet. Every row is
+// validated first, and nothing is imported when any row has errors. With the
+// dryRun query parameter set, the report is returned without importing.
+func (c *Controller) InventoryImportPost(writer http.ResponseWriter, req *http.Request) {
+	dryRun := false
+	if value := req.URL.Query().Get("dryRun"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			c.lc.Errorf("Invalid dryRun value: %s", value)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte("Please enter a valid dryRun value in the form of /inventory/import?dryRun=true"))
+			return
+		}
+	}
 
-	// Read request body
-	body := make([]byte, req.ContentLength)
-	if _, err := io.ReadFull(req.Body, body); err != nil {
-		c.lc.Errorf("Failed to process the posted audit log entry: %s", err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
-		writer.Write([]byte("Failed to process the posted audit log entry: " + err.Error()))
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	inventoryItems, err := c.GetInventoryItems()
+	if err != nil {
+		c.lc.Errorf("Failed to retrieve all inventory items: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to retrieve all inventory items: " + err.Error()))
 		return
 	}
 
-	// Unmarshal the string contents of request into an AuditLogEntry struct
-	var postedAuditLogEntry AuditLogEntry
-	err := json.Unmarshal(body, &postedAuditLogEntry)
+	maxBodySize := c.maxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = utilities.DefaultMaxRequestBodySize
+	}
+	prices := productPrices(inventoryItems)
+	now := time.Now().UnixNano()
+	importedItems, report, err := ImportInventoryCSV(http.MaxBytesReader(writer, req.Body, maxBodySize), inventoryItems, now)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			statusCode = http.StatusRequestEntityTooLarge
+			err = fmt.Errorf("request body is larger than %d bytes", maxBodySize)
+		}
+		c.lc.Errorf("Failed to process the imported inventory: %s", err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte("Failed to process the imported inventory: " + err.Error()))
+		return
+	}
+	report.DryRun = dryRun
+
+	statusCode := http.StatusOK
+	if len(report.Errors) > 0 {
+		c.lc.Errorf("Inventory import has %d errors, nothing was imported", len(report.Errors))
+		statusCode = http.StatusBadRequest
+	} else if !dryRun && len(report.Created)+len(report.Updated) > 0 {
+		if err := c.inventoryStore().SaveInventory(importedItems); err != nil {
+			c.lc.Errorf("Failed to write inventory: %s", err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("Failed to write inventory: " + err.Error()))
+			return
+		}
+		report.Imported = true
+		if err := c.recordPriceChanges(PriceChanges(prices, importedItems, now)); err != nil {
+			c.lc.Errorf("failed to record price changes: %s", err.Error())
+		}
+
+		c.publishInventoryEvent(InventoryEventProductUpdated, FilterInventoryItemsBySKU(importedItems, append(report.Created, report.Updated...)).Data)
+		c.lc.Infof("Imported inventory: %d created, %d updated", len(report.Created), len(report.Updated))
+	}
+
+	reportJSON, err := json.Marshal(report)
 	if err != nil {
+		c.lc.Errorf("Failed to process the inventory import report: %s", err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte("Failed to process the inventory import report: " + err.Error()))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+	writer.Write(reportJSON)
+}
+
+// AuditLogPost allows for a new audit log entry to be added
+func (c *Controller) AuditLogPost(writer http.ResponseWriter, req *http.Request) {
+
+	// Decode the request body into an AuditLogEntry struct
+	var postedAuditLogEntry AuditLogEntry
+	if statusCode, err := c.decodeJSONBody(writer, req, &postedAuditLogEntry); err != nil {
 		c.lc.Errorf("Failed to process the posted audit log entry: %s", err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
+		writer.WriteHeader(statusCode)
 		writer.Write([]byte("Failed to process the posted audit log entry: " + err.Error()))
 		return
 	}
@@ -334,18 +620,10 @@ func (c *Controller) AuditLogPost(writer http.ResponseWriter, req *http.Request)
 		postedAuditLogEntry.CreatedAt = time.Now().UnixNano()
 	}
 
-	// load the auditlog.json file
-	data, err := os.ReadFile(c.auditLogFileName)
+	// load the audit log
+	auditLog, err := c.GetAuditLog()
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to read from cards JSON file: %s", err.Error())
-		c.lc.Error(errMsg)
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte(errMsg))
-		return
-	}
-	var auditLog AuditLog
-	if err = json.Unmarshal(data, &auditLog); err != nil {
-		errMsg := fmt.Sprintf("failed to unmarshal cards from JSON file: %s", err.Error())
+		errMsg := fmt.Sprintf("failed to load audit log: %s", err.Error())
 		c.lc.Error(errMsg)
 		writer.WriteHeader(http.StatusInternalServerError)
 		writer.Write([]byte(errMsg))
@@ -373,15 +651,8 @@ func (c *Controller) AuditLogPost(writer http.ResponseWriter, req *http.Request)
 		// write the result
 		auditLog.Data = append(auditLog.Data, postedAuditLogEntry)
 
-		auditLogData, err := json.Marshal(auditLog)
-		if err != nil {
-			errMsg := fmt.Sprintf("failed to marshal audit log: %s", err.Error())
-			writer.WriteHeader(http.StatusInternalServerError)
-			writer.Write([]byte(errMsg))
-			return
-		}
-		if err = os.WriteFile(c.auditLogFileName, auditLogData, 0644); err != nil {
-			errMsg := fmt.Sprintf("failed to write audit log data to file: %s", err.Error())
+		if err = c.inventoryStore().SaveAuditLog(auditLog); err != nil {
+			errMsg := fmt.Sprintf("failed to write audit log data: %s", err.Error())
 			writer.WriteHeader(http.StatusInternalServerError)
 			writer.Write([]byte(errMsg))
 			return
@@ -5,12 +5,14 @@ package routes
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,12 +35,20 @@ func TestInventoryPost(t *testing.T) {
 		ExpectedStatusCode  int
 		ProductsMatch       bool
 	}{
-		{"modify first inventory item price", false, `[{"sku": "4900002470","itemPrice": 10.5,"unitsOnHand": 2,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false}]`, http.StatusOK, false},
+		{"modify first inventory item price", false, `[{"sku": "4900002470","itemPrice": 10.5,"unitsOnHand": 2,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false,"version": 0}]`, http.StatusOK, false},
 		{"add new inventory item", false, `[{"sku": "9999999999","itemPrice": 10.5,"unitsOnHand": 2,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false}]`, http.StatusOK, false},
 		{"add new inventory item with default items", false, `[{"sku": "8888888888","isActive": false}]`, http.StatusOK, false},
 		{"modify inventory item with strings instead of float values", false, `[{"sku": "7777777777","itemPrice": "zero","unitsOnHand": "zero","maxRestockingLevel": "zero","minRestockingLevel": "zero","isActive": false}]`, http.StatusOK, false},
-		{"reduce inventory below 0", false, `[{"sku": "4900002470","itemPrice": 10.5,"unitsOnHand": -10,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false}]`, http.StatusOK, false},
-		{"raise inventory above max threshold", false, `[{"sku": "4900002470","itemPrice": 10.5,"unitsOnHand": 20,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false}]`, http.StatusOK, false},
+		{"reduce inventory below 0", false, `[{"sku": "4900002470","itemPrice": 10.5,"unitsOnHand": -10,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false,"version": 0}]`, http.StatusOK, false},
+		{"raise inventory above max threshold", false, `[{"sku": "4900002470","itemPrice": 10.5,"unitsOnHand": 20,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false,"version": 0}]`, http.StatusOK, false},
+		{"set inventory item availability", false, `[{"sku": "4900002470","availability": [{"start": "06:00","end": "11:00","days": ["Mon","Tue"]}],"version": 0}]`, http.StatusOK, false},
+		{"set inventory item deposit and tax category", false, `[{"sku": "4900002470","deposit": 0.25,"taxCategory": "reduced","currency": "eur","version": 0}]`, http.StatusOK, false},
+		{"set inventory item barcode, image and weight", false, `[{"sku": "4900002470","barcode": "049000024708","imageURL": "https://example.com/sprite.png","weight": 520,"version": 0}]`, http.StatusOK, false},
+		{"invalid inventory item barcode", false, `[{"sku": "4900002470","barcode": "049000024709"}]`, http.StatusBadRequest, true},
+		{"invalid inventory item image URL", false, `[{"sku": "4900002470","imageURL": "sprite.png"}]`, http.StatusBadRequest, true},
+		{"invalid inventory item weight", false, `[{"sku": "4900002470","weight": -1}]`, http.StatusBadRequest, true},
+		{"invalid inventory item supplier", false, `[{"sku": "4900002470","supplier": 5}]`, http.StatusBadRequest, true},
+		{"invalid inventory item availability", false, `[{"sku": "4900002470","availability": [{"start": "6am","end": "11:00"}]}]`, http.StatusBadRequest, true},
 		{"invalid inventory item", false, `invalid item`, http.StatusBadRequest, true},
 		{"invalid inventory item", true, `[{"sku": "4900002470","itemPrice": 10.5,"unitsOnHand": 2,"maxRestockingLevel": 9,"minRestockingLevel": 1,"isActive": false}]`, http.StatusInternalServerError, true},
 	}
@@ -202,6 +212,8 @@ func TestDeltaInventorySKUPost(t *testing.T) {
 		{"missing SKU and no delta change", false, `[{"SKU": "0000000000","Delta": 0}]`, http.StatusNotModified, true},
 		{"invalid delta json", false, `This is an invalid string`, http.StatusBadRequest, true},
 		{"subtracting 1 item from existing SKU with invalid inventory", true, `[{"SKU": "4900002470","Delta": -1}]`, http.StatusInternalServerError, false},
+		{"restocking with a reason and source", false, `[{"SKU": "4900002470","delta": 6,"reason":"restock","source":{"user":"0003278380"}}]`, http.StatusOK, false},
+		{"unknown reason", false, `[{"SKU": "4900002470","delta": -1,"reason":"theft"}]`, http.StatusBadRequest, true},
 	}
 
 	for _, test := range tests {
@@ -219,6 +231,7 @@ func TestDeltaInventorySKUPost(t *testing.T) {
 			}
 			defer func() {
 				_ = os.Remove(c.inventoryFileName)
+				_ = os.Remove(StockMovementsFileName(c.inventoryFileName))
 			}()
 
 			req := httptest.NewRequest("POST", "http://localhost:48096/inventory/delta", bytes.NewBuffer([]byte(currentTest.DeltaUpdateString)))
@@ -244,3 +257,278 @@ func TestDeltaInventorySKUPost(t *testing.T) {
 		})
 	}
 }
+
+func TestDeltaInventorySKUPostStockMovements(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	require.NoError(t, c.inventoryStore().SaveInventory(getDefaultProductsList()))
+
+	deltas := []string{
+		`[{"SKU":"4900002470","delta":-2,"source":{"service":"as-vending","user":"0003293374","sessionId":"42"}},{"SKU":"0000000000","delta":-1}]`,
+		`[{"SKU":"4900002470","delta":10,"reason":"restock","source":{"user":"0003278380"}},{"SKU":"1200010735","delta":-1,"reason":"shrinkage"}]`,
+	}
+	for _, delta := range deltas {
+		req := httptest.NewRequest("POST", "http://localhost:48096/inventory/delta", bytes.NewBuffer([]byte(delta)))
+		w := httptest.NewRecorder()
+		c.DeltaInventorySKUPost(w, req)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	}
+
+	// only the deltas of SKUs in inventory are recorded, and deltas without a
+	// reason are sales
+	stockMovements, err := c.GetStockMovements()
+	require.NoError(t, err)
+	require.Len(t, stockMovements.Data, 3)
+	expected := []StockMovement{
+		{SKU: "4900002470", Delta: -2, UnitsOnHand: -2, Reason: DeltaReasonSale, Source: DeltaSource{Service: "as-vending", User: "0003293374", SessionID: "42"}},
+		{SKU: "4900002470", Delta: 10, UnitsOnHand: 8, Reason: DeltaReasonRestock, Source: DeltaSource{User: "0003278380"}},
+		{SKU: "1200010735", Delta: -1, UnitsOnHand: -1, Reason: DeltaReasonShrinkage},
+	}
+	for i, movement := range stockMovements.Data {
+		assert.NotEmpty(t, movement.MovementID)
+		assert.NotZero(t, movement.CreatedAt)
+		movement.MovementID = ""
+		movement.CreatedAt = 0
+		assert.Equal(t, expected[i], movement)
+	}
+}
+
+func TestDeltaInventorySKUPostNegativeStock(t *testing.T) {
+	tests := []struct {
+		Name                string
+		Policy              string
+		ExpectedStatusCode  int
+		ExpectedResults     []DeltaInventoryResult
+		ExpectedUnitsOnHand int
+	}{
+		{
+			Name:               "Allow",
+			Policy:             NegativeStockAllow,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedResults: []DeltaInventoryResult{
+				{SKU: "4900002470", Delta: -1, AppliedDelta: -1, UnitsOnHandBefore: 2, UnitsOnHandAfter: 1},
+				{SKU: "4900002470", Delta: -3, AppliedDelta: -3, UnitsOnHandBefore: 1, UnitsOnHandAfter: -2, Discrepancy: true},
+			},
+			ExpectedUnitsOnHand: -2,
+		},
+		{
+			Name:               "Clamp",
+			Policy:             NegativeStockClamp,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedResults: []DeltaInventoryResult{
+				{SKU: "4900002470", Delta: -1, AppliedDelta: -1, UnitsOnHandBefore: 2, UnitsOnHandAfter: 1},
+				{SKU: "4900002470", Delta: -3, AppliedDelta: -1, UnitsOnHandBefore: 1, UnitsOnHandAfter: 0, Discrepancy: true},
+			},
+			ExpectedUnitsOnHand: 0,
+		},
+		{
+			Name:                "Reject",
+			Policy:              NegativeStockReject,
+			ExpectedStatusCode:  http.StatusConflict,
+			ExpectedUnitsOnHand: 2,
+		},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newStoreTestController(t, nil)
+			c.negativeStockPolicy = currentTest.Policy
+			products := getDefaultProductsList()
+			products.Data[0].UnitsOnHand = 2
+			require.NoError(t, c.inventoryStore().SaveInventory(products))
+
+			req := httptest.NewRequest("POST", "http://localhost:48096/inventory/delta", bytes.NewBuffer([]byte(`[{"SKU":"4900002470","delta":-1},{"SKU":"4900002470","delta":-3}]`)))
+			w := httptest.NewRecorder()
+			c.DeltaInventorySKUPost(w, req)
+			require.Equal(t, currentTest.ExpectedStatusCode, w.Result().StatusCode)
+
+			inventoryItem, _, err := c.GetInventoryItemBySKU("4900002470")
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedUnitsOnHand, inventoryItem.UnitsOnHand)
+			stockMovements, err := c.GetStockMovements()
+			require.NoError(t, err)
+			if currentTest.ExpectedResults == nil {
+				assert.Contains(t, w.Body.String(), "not enough units on hand for 4900002470: a delta of -3 would take the 1 units on hand below zero")
+				assert.Empty(t, stockMovements.Data, "a rejected request does not apply any delta")
+				return
+			}
+
+			var results []DeltaInventoryResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+			require.Len(t, results, len(currentTest.ExpectedResults))
+			for i, result := range results {
+				assert.Equal(t, result.UnitsOnHandAfter, result.Product.UnitsOnHand)
+				result.Product = Product{}
+				assert.Equal(t, currentTest.ExpectedResults[i], result)
+				// the movements record the applied deltas
+				assert.Equal(t, result.AppliedDelta, stockMovements.Data[i].Delta)
+			}
+		})
+	}
+}
+
+func TestContainerReturnPost(t *testing.T) {
+	products := Products{
+		Data: []Product{{
+			CreatedAt:          1567787309,
+			IsActive:           true,
+			ItemPrice:          1.99,
+			Deposit:            0.25,
+			MaxRestockingLevel: 24,
+			MinRestockingLevel: 0,
+			ProductName:        "Sprite (Lemon-Lime) - 16.9 oz",
+			SKU:                "4900002470",
+			UnitsOnHand:        5,
+			UpdatedAt:          1567787309,
+		}, {
+			CreatedAt:          1567787309,
+			IsActive:           true,
+			ItemPrice:          1.99,
+			MaxRestockingLevel: 18,
+			MinRestockingLevel: 0,
+			ProductName:        "Pringles (Original) - 5.2 oz",
+			SKU:                "3800035902",
+			UnitsOnHand:        5,
+			UpdatedAt:          1567787309,
+		}}}
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+
+	tests := []struct {
+		Name               string
+		BadInventory       bool
+		ReturnString       string
+		ExpectedStatusCode int
+		ExpectedReturned   int
+	}{
+		{"returning 2 containers", false, `[{"sku": "4900002470","count": 2}]`, http.StatusOK, 2},
+		{"SKU without deposit", false, `[{"sku": "3800035902","count": 1}]`, http.StatusBadRequest, 0},
+		{"unknown SKU", false, `[{"sku": "0000000000","count": 1}]`, http.StatusBadRequest, 0},
+		{"non-positive count", false, `[{"sku": "4900002470","count": 0}]`, http.StatusBadRequest, 0},
+		{"one bad return rejects all", false, `[{"sku": "4900002470","count": 2},{"sku": "0000000000","count": 1}]`, http.StatusBadRequest, 0},
+		{"invalid return json", false, `This is an invalid string`, http.StatusBadRequest, 0},
+		{"invalid inventory", true, `[{"sku": "4900002470","count": 2}]`, http.StatusInternalServerError, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			err := c.DeleteInventory()
+			require.NoError(t, err)
+
+			if currentTest.BadInventory {
+				err := os.WriteFile(c.inventoryFileName, []byte("invalid json test"), 0644)
+				require.NoError(t, err)
+			} else {
+				err := c.WriteInventory()
+				require.NoError(t, err)
+			}
+			defer func() {
+				_ = os.Remove(c.inventoryFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48096/inventory/returns", bytes.NewBuffer([]byte(currentTest.ReturnString)))
+			w := httptest.NewRecorder()
+			req.Header.Set("Content-Type", "application/json")
+			c.ContainerReturnPost(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			if !currentTest.BadInventory {
+				productsFromFile, err := c.GetInventoryItems()
+				require.NoError(t, err)
+				require.Equal(t, currentTest.ExpectedReturned, productsFromFile.Data[0].ReturnedContainers)
+				// returned containers must not be counted as sellable units
+				require.Equal(t, products.Data[0].UnitsOnHand, productsFromFile.Data[0].UnitsOnHand)
+			}
+		})
+	}
+}
+
+func TestInventoryImportPost(t *testing.T) {
+	products := getDefaultProductsList()
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+	}
+
+	validCSV := "sku,itemPrice,unitsOnHand\n4900002470,2.49,12\n9999999999,1.25,3\n"
+	tests := []struct {
+		Name               string
+		Query              string
+		CSV                string
+		ExpectedStatusCode int
+		ExpectedImported   bool
+		ExpectedErrors     int
+	}{
+		{"Import", "", validCSV, http.StatusOK, true, 0},
+		{"Dry run", "?dryRun=true", validCSV, http.StatusOK, false, 0},
+		{"Row errors", "", validCSV + "1200010735,free,1\n", http.StatusBadRequest, false, 1},
+		{"Unknown column", "", "sku,price\n4900002470,1\n", http.StatusBadRequest, false, 0},
+		{"Invalid dry run", "?dryRun=maybe", validCSV, http.StatusBadRequest, false, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			require.NoError(t, c.WriteInventory())
+			defer func() {
+				_ = os.Remove(c.inventoryFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48095/inventory/import"+currentTest.Query, bytes.NewBufferString(currentTest.CSV))
+			req.Header.Set("Content-Type", "text/csv")
+			w := httptest.NewRecorder()
+			c.InventoryImportPost(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			var report InventoryImportReport
+			if resp.Header.Get("Content-Type") == "application/json" {
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+				assert.Len(t, report.Errors, currentTest.ExpectedErrors)
+				assert.Equal(t, currentTest.ExpectedImported, report.Imported)
+				assert.Equal(t, currentTest.Query == "?dryRun=true", report.DryRun)
+			}
+
+			productsFromFile, err := c.GetInventoryItems()
+			require.NoError(t, err)
+			if currentTest.ExpectedImported {
+				require.Len(t, productsFromFile.Data, len(products.Data)+1)
+				assert.Equal(t, 2.49, productsFromFile.Data[0].ItemPrice)
+				assert.Equal(t, 12, productsFromFile.Data[0].UnitsOnHand)
+				assert.Equal(t, "9999999999", productsFromFile.Data[len(products.Data)].SKU)
+			} else {
+				assert.Equal(t, products, productsFromFile, "the inventory must not change")
+			}
+		})
+	}
+}
+
+func TestInventoryImportPostTooLarge(t *testing.T) {
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryItems:    getDefaultProductsList(),
+		inventoryFileName: InventoryFileName,
+		maxBodySize:       16,
+	}
+	require.NoError(t, c.WriteInventory())
+	defer func() {
+		_ = os.Remove(c.inventoryFileName)
+	}()
+
+	req := httptest.NewRequest("POST", "http://localhost:48095/inventory/import", bytes.NewBufferString("sku,productName\n4900002470,Sprite\n"))
+	w := httptest.NewRecorder()
+	c.InventoryImportPost(w, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Result().StatusCode)
+}
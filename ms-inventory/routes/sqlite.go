@@ -0,0 +1,400 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build cgo
+
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	sqliteInventoryDocument = "inventory"
+	sqliteAuditLogDocument  = "auditlog"
+	sqliteMovementsDocument = "movements"
+	sqlitePlanogramDocument = "planogram"
+	sqlitePricesDocument    = "prices"
+	sqliteDeletedDocument   = "deleted"
+)
+
+// sqliteMigrations create and upgrade the schema of the SQLite inventory
+// store. The schema version is kept in the user_version of the database, so
+// only the migrations after it are run, and new migrations are appended.
+var sqliteMigrations = []string{
+	`CREATE TABLE products (position INTEGER PRIMARY KEY, sku TEXT NOT NULL, data TEXT NOT NULL);
+	CREATE INDEX products_sku ON products (sku);
+	CREATE TABLE audit_log (position INTEGER PRIMARY KEY, audit_entry_id TEXT NOT NULL, data TEXT NOT NULL);
+	CREATE TABLE stored_documents (name TEXT PRIMARY KEY);`,
+	`CREATE TABLE stock_movements (position INTEGER PRIMARY KEY, movement_id TEXT NOT NULL, data TEXT NOT NULL);`,
+	`CREATE INDEX audit_log_entry_id ON audit_log (audit_entry_id);`,
+	`CREATE TABLE planogram (position INTEGER PRIMARY KEY, slot TEXT NOT NULL, data TEXT NOT NULL);`,
+	`CREATE TABLE price_history (position INTEGER PRIMARY KEY, change_id TEXT NOT NULL, data TEXT NOT NULL);`,
+	`CREATE TABLE deleted_products (position INTEGER PRIMARY KEY, sku TEXT NOT NULL, data TEXT NOT NULL);`,
+}
+
+// SQLiteStore keeps the inventory, audit log, stock movements, planogram,
+// price history and deleted products in a SQLite database, with a row for
+// each product, audit log entry, movement, planogram slot, price change and
+// deleted product. Every save is a transaction that is flushed to disk
+// before it completes.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens the SQLite database file, creating it if needed, and
+// migrates its schema to the latest version
+func NewSQLiteStore(fileName string) (InventoryStore, error) {
+	if len(fileName) == 0 {
+		return nil, errors.New("the sqlite inventory store needs the file name of the database")
+	}
+	db, err := sql.Open("sqlite3", "file:"+fileName+"?_journal_mode=WAL&_synchronous=FULL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite inventory store: %s", err.Error())
+	}
+	// a single connection serializes the writes of this instance
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate sqlite inventory store: %s", err.Error())
+	}
+	return store, nil
+}
+
+// migrate runs the schema migrations the database has not run yet
+func (store *SQLiteStore) migrate() error {
+	var version int
+	if err := store.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := store.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %s", version+1, err.Error())
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadInventory reads the products in inventory order
+func (store *SQLiteStore) LoadInventory() (Products, error) {
+	inventoryItems := Products{Data: []Product{}}
+	if err := store.checkStored(sqliteInventoryDocument); err != nil {
+		return inventoryItems, fmt.Errorf("failed to load inventory from sqlite: %w", err)
+	}
+	rows, err := store.db.Query("SELECT data FROM products ORDER BY position")
+	if err != nil {
+		return inventoryItems, fmt.Errorf("failed to load inventory from sqlite: %s", err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		var product Product
+		if err := rows.Scan(&data); err != nil {
+			return inventoryItems, fmt.Errorf("failed to load inventory from sqlite: %s", err.Error())
+		}
+		if err := json.Unmarshal(data, &product); err != nil {
+			return inventoryItems, fmt.Errorf("failed to unmarshal inventory product: %s", err.Error())
+		}
+		inventoryItems.Data = append(inventoryItems.Data, product)
+	}
+	if err := rows.Err(); err != nil {
+		return inventoryItems, fmt.Errorf("failed to load inventory from sqlite: %s", err.Error())
+	}
+	return inventoryItems, nil
+}
+
+// SaveInventory replaces the products in a single transaction
+func (store *SQLiteStore) SaveInventory(inventoryItems Products) error {
+	err := store.replace(sqliteInventoryDocument, "products", "sku", len(inventoryItems.Data), func(i int) (string, interface{}) {
+		return inventoryItems.Data[i].SKU, inventoryItems.Data[i]
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save inventory to sqlite: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadAuditLog reads the audit log entries in the order they were added
+func (store *SQLiteStore) LoadAuditLog() (AuditLog, error) {
+	auditLog := AuditLog{Data: []AuditLogEntry{}}
+	if err := store.checkStored(sqliteAuditLogDocument); err != nil {
+		return auditLog, fmt.Errorf("failed to load audit log from sqlite: %w", err)
+	}
+	rows, err := store.db.Query("SELECT data FROM audit_log ORDER BY position")
+	if err != nil {
+		return auditLog, fmt.Errorf("failed to load audit log from sqlite: %s", err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		var auditLogEntry AuditLogEntry
+		if err := rows.Scan(&data); err != nil {
+			return auditLog, fmt.Errorf("failed to load audit log from sqlite: %s", err.Error())
+		}
+		if err := json.Unmarshal(data, &auditLogEntry); err != nil {
+			return auditLog, fmt.Errorf("failed to unmarshal audit log entry: %s", err.Error())
+		}
+		auditLog.Data = append(auditLog.Data, auditLogEntry)
+	}
+	if err := rows.Err(); err != nil {
+		return auditLog, fmt.Errorf("failed to load audit log from sqlite: %s", err.Error())
+	}
+	return auditLog, nil
+}
+
+// LoadAuditLogEntry reads the entry with the ID, which is looked up in the
+// index of the audit log entry IDs
+func (store *SQLiteStore) LoadAuditLogEntry(auditEntryID string) (AuditLogEntry, error) {
+	var auditLogEntry AuditLogEntry
+	if err := store.checkStored(sqliteAuditLogDocument); err != nil {
+		return auditLogEntry, fmt.Errorf("failed to load audit log from sqlite: %w", err)
+	}
+	var data []byte
+	err := store.db.QueryRow("SELECT data FROM audit_log WHERE audit_entry_id = ? ORDER BY position LIMIT 1", auditEntryID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auditLogEntry, ErrAuditLogEntryNotFound
+	}
+	if err != nil {
+		return auditLogEntry, fmt.Errorf("failed to load audit log entry from sqlite: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &auditLogEntry); err != nil {
+		return auditLogEntry, fmt.Errorf("failed to unmarshal audit log entry: %s", err.Error())
+	}
+	return auditLogEntry, nil
+}
+
+// SaveAuditLog replaces the audit log entries in a single transaction
+func (store *SQLiteStore) SaveAuditLog(auditLog AuditLog) error {
+	err := store.replace(sqliteAuditLogDocument, "audit_log", "audit_entry_id", len(auditLog.Data), func(i int) (string, interface{}) {
+		return auditLog.Data[i].AuditEntryID, auditLog.Data[i]
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save audit log to sqlite: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadStockMovements reads the stock movements in the order they were made
+func (store *SQLiteStore) LoadStockMovements() (StockMovements, error) {
+	stockMovements := StockMovements{Data: []StockMovement{}}
+	if err := store.checkStored(sqliteMovementsDocument); err != nil {
+		return stockMovements, fmt.Errorf("failed to load stock movements from sqlite: %w", err)
+	}
+	rows, err := store.db.Query("SELECT data FROM stock_movements ORDER BY position")
+	if err != nil {
+		return stockMovements, fmt.Errorf("failed to load stock movements from sqlite: %s", err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		var stockMovement StockMovement
+		if err := rows.Scan(&data); err != nil {
+			return stockMovements, fmt.Errorf("failed to load stock movements from sqlite: %s", err.Error())
+		}
+		if err := json.Unmarshal(data, &stockMovement); err != nil {
+			return stockMovements, fmt.Errorf("failed to unmarshal stock movement: %s", err.Error())
+		}
+		stockMovements.Data = append(stockMovements.Data, stockMovement)
+	}
+	if err := rows.Err(); err != nil {
+		return stockMovements, fmt.Errorf("failed to load stock movements from sqlite: %s", err.Error())
+	}
+	return stockMovements, nil
+}
+
+// SaveStockMovements replaces the stock movements in a single transaction
+func (store *SQLiteStore) SaveStockMovements(stockMovements StockMovements) error {
+	err := store.replace(sqliteMovementsDocument, "stock_movements", "movement_id", len(stockMovements.Data), func(i int) (string, interface{}) {
+		return stockMovements.Data[i].MovementID, stockMovements.Data[i]
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save stock movements to sqlite: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadPlanogram reads the planogram slots in planogram order
+func (store *SQLiteStore) LoadPlanogram() (Planogram, error) {
+	planogram := Planogram{Data: []PlanogramSlot{}}
+	if err := store.checkStored(sqlitePlanogramDocument); err != nil {
+		return planogram, fmt.Errorf("failed to load planogram from sqlite: %w", err)
+	}
+	rows, err := store.db.Query("SELECT data FROM planogram ORDER BY position")
+	if err != nil {
+		return planogram, fmt.Errorf("failed to load planogram from sqlite: %s", err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		var slot PlanogramSlot
+		if err := rows.Scan(&data); err != nil {
+			return planogram, fmt.Errorf("failed to load planogram from sqlite: %s", err.Error())
+		}
+		if err := json.Unmarshal(data, &slot); err != nil {
+			return planogram, fmt.Errorf("failed to unmarshal planogram slot: %s", err.Error())
+		}
+		planogram.Data = append(planogram.Data, slot)
+	}
+	if err := rows.Err(); err != nil {
+		return planogram, fmt.Errorf("failed to load planogram from sqlite: %s", err.Error())
+	}
+	return planogram, nil
+}
+
+// SavePlanogram replaces the planogram slots in a single transaction
+func (store *SQLiteStore) SavePlanogram(planogram Planogram) error {
+	err := store.replace(sqlitePlanogramDocument, "planogram", "slot", len(planogram.Data), func(i int) (string, interface{}) {
+		return fmt.Sprintf("%d-%d", planogram.Data[i].Shelf, planogram.Data[i].Lane), planogram.Data[i]
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save planogram to sqlite: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadPriceHistory reads the price changes in the order they were made
+func (store *SQLiteStore) LoadPriceHistory() (PriceHistory, error) {
+	priceHistory := PriceHistory{Data: []PriceChange{}}
+	if err := store.checkStored(sqlitePricesDocument); err != nil {
+		return priceHistory, fmt.Errorf("failed to load price history from sqlite: %w", err)
+	}
+	rows, err := store.db.Query("SELECT data FROM price_history ORDER BY position")
+	if err != nil {
+		return priceHistory, fmt.Errorf("failed to load price history from sqlite: %s", err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		var priceChange PriceChange
+		if err := rows.Scan(&data); err != nil {
+			return priceHistory, fmt.Errorf("failed to load price history from sqlite: %s", err.Error())
+		}
+		if err := json.Unmarshal(data, &priceChange); err != nil {
+			return priceHistory, fmt.Errorf("failed to unmarshal price change: %s", err.Error())
+		}
+		priceHistory.Data = append(priceHistory.Data, priceChange)
+	}
+	if err := rows.Err(); err != nil {
+		return priceHistory, fmt.Errorf("failed to load price history from sqlite: %s", err.Error())
+	}
+	return priceHistory, nil
+}
+
+// SavePriceHistory replaces the price changes in a single transaction
+func (store *SQLiteStore) SavePriceHistory(priceHistory PriceHistory) error {
+	err := store.replace(sqlitePricesDocument, "price_history", "change_id", len(priceHistory.Data), func(i int) (string, interface{}) {
+		return priceHistory.Data[i].ChangeID, priceHistory.Data[i]
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save price history to sqlite: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadDeletedInventory reads the deleted products in the order they were
+// deleted
+func (store *SQLiteStore) LoadDeletedInventory() (Products, error) {
+	deletedItems := Products{Data: []Product{}}
+	if err := store.checkStored(sqliteDeletedDocument); err != nil {
+		return deletedItems, fmt.Errorf("failed to load deleted products from sqlite: %w", err)
+	}
+	rows, err := store.db.Query("SELECT data FROM deleted_products ORDER BY position")
+	if err != nil {
+		return deletedItems, fmt.Errorf("failed to load deleted products from sqlite: %s", err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		var product Product
+		if err := rows.Scan(&data); err != nil {
+			return deletedItems, fmt.Errorf("failed to load deleted products from sqlite: %s", err.Error())
+		}
+		if err := json.Unmarshal(data, &product); err != nil {
+			return deletedItems, fmt.Errorf("failed to unmarshal deleted product: %s", err.Error())
+		}
+		deletedItems.Data = append(deletedItems.Data, product)
+	}
+	if err := rows.Err(); err != nil {
+		return deletedItems, fmt.Errorf("failed to load deleted products from sqlite: %s", err.Error())
+	}
+	return deletedItems, nil
+}
+
+// SaveDeletedInventory replaces the deleted products in a single transaction
+func (store *SQLiteStore) SaveDeletedInventory(deletedItems Products) error {
+	err := store.replace(sqliteDeletedDocument, "deleted_products", "sku", len(deletedItems.Data), func(i int) (string, interface{}) {
+		return deletedItems.Data[i].SKU, deletedItems.Data[i]
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save deleted products to sqlite: %s", err.Error())
+	}
+	return nil
+}
+
+// Close closes the database
+func (store *SQLiteStore) Close() error {
+	return store.db.Close()
+}
+
+// checkStored returns ErrNotStored until the document is first saved, so
+// that an empty table can be told apart from one that was never written
+func (store *SQLiteStore) checkStored(document string) error {
+	var name string
+	err := store.db.QueryRow("SELECT name FROM stored_documents WHERE name = ?", document).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotStored
+	}
+	return err
+}
+
+// replace deletes the rows of the table and inserts the given rows in their
+// order, in one transaction
+func (store *SQLiteStore) replace(document string, table string, idColumn string, count int, row func(int) (string, interface{})) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+		return err
+	}
+	insert, err := tx.Prepare("INSERT INTO " + table + " (position, " + idColumn + ", data) VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for i := 0; i < count; i++ {
+		id, content := row(i)
+		data, err := json.Marshal(content)
+		if err != nil {
+			return err
+		}
+		if _, err := insert.Exec(i, id, data); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT OR IGNORE INTO stored_documents (name) VALUES (?)", document); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
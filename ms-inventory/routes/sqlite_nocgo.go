@@ -0,0 +1,13 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !cgo
+
+package routes
+
+import "errors"
+
+// NewSQLiteStore is not available without cgo, which the SQLite driver needs
+func NewSQLiteStore(fileName string) (InventoryStore, error) {
+	return nil, errors.New("the sqlite inventory store needs the service to be built with CGO_ENABLED=1")
+}
@@ -0,0 +1,57 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build cgo
+
+package routes
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "inventory.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	testInventoryStore(t, store)
+}
+
+func TestSQLiteStoreReopen(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "inventory.db")
+	store, err := NewSQLiteStore(fileName)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveInventory(getDefaultProductsList()))
+	require.NoError(t, store.Close())
+
+	// the schema is only migrated once and the inventory is kept
+	store, err = NewSQLiteStore(fileName)
+	require.NoError(t, err)
+	defer store.Close()
+	var version int
+	require.NoError(t, store.(*SQLiteStore).db.QueryRow("PRAGMA user_version").Scan(&version))
+	assert.Equal(t, len(sqliteMigrations), version)
+	inventoryItems, err := store.LoadInventory()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultProductsList(), inventoryItems)
+	_, err = store.LoadAuditLog()
+	assert.ErrorIs(t, err, ErrNotStored)
+}
+
+func TestSQLiteStoreMigrateInventory(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "inventory.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	c := newStoreTestController(t, store)
+	require.NoError(t, NewFileStore(c.inventoryFileName, c.auditLogFileName, nil).SaveAuditLog(getDefaultAuditsList()))
+
+	migrated, err := c.MigrateInventory()
+	require.NoError(t, err)
+	assert.Equal(t, []string{c.auditLogFileName}, migrated)
+	auditLog, err := c.GetAuditLog()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultAuditsList(), auditLog)
+}
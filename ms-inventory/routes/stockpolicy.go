@@ -0,0 +1,54 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+)
+
+const (
+	// NegativeStockAllow applies a delta that takes more units than are on
+	// hand, leaving the units on hand negative, and logs a warning
+	NegativeStockAllow = "allow"
+	// NegativeStockClamp applies as much of such a delta as there are units
+	// on hand, leaving zero units on hand
+	NegativeStockClamp = "clamp"
+	// NegativeStockReject rejects the request of such a delta, and none of
+	// its deltas are applied
+	NegativeStockReject = "reject"
+)
+
+// ValidateNegativeStockPolicy checks that the policy is one of allow, clamp
+// or reject
+func ValidateNegativeStockPolicy(policy string) error {
+	switch policy {
+	case NegativeStockAllow, NegativeStockClamp, NegativeStockReject:
+		return nil
+	default:
+		return fmt.Errorf("unknown negative stock policy %q, expected %s, %s or %s", policy, NegativeStockAllow, NegativeStockClamp, NegativeStockReject)
+	}
+}
+
+// applyNegativeStockPolicy returns the part of the delta that is applied to
+// the units on hand, and whether the delta takes more units than are on
+// hand. Only removing units can be a discrepancy, so units on hand that are
+// already negative are not changed by clamping, and are not rejected when
+// they are restocked. An empty policy allows negative stock.
+func applyNegativeStockPolicy(policy string, unitsOnHand int, delta int) (applied int, discrepancy bool, err error) {
+	if delta >= 0 || unitsOnHand+delta >= 0 {
+		return delta, false, nil
+	}
+	switch policy {
+	case NegativeStockClamp:
+		applied = -unitsOnHand
+		if unitsOnHand < 0 {
+			applied = 0
+		}
+		return applied, true, nil
+	case NegativeStockReject:
+		return 0, true, fmt.Errorf("a delta of %d would take the %d units on hand below zero", delta, unitsOnHand)
+	default:
+		return delta, true, nil
+	}
+}
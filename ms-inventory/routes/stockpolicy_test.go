@@ -0,0 +1,53 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNegativeStockPolicy(t *testing.T) {
+	for _, policy := range []string{NegativeStockAllow, NegativeStockClamp, NegativeStockReject} {
+		assert.NoError(t, ValidateNegativeStockPolicy(policy))
+	}
+	assert.EqualError(t, ValidateNegativeStockPolicy("ignore"), `unknown negative stock policy "ignore", expected allow, clamp or reject`)
+}
+
+func TestApplyNegativeStockPolicy(t *testing.T) {
+	tests := []struct {
+		Name                string
+		Policy              string
+		UnitsOnHand         int
+		Delta               int
+		ExpectedApplied     int
+		ExpectedDiscrepancy bool
+		ExpectedError       string
+	}{
+		{"Enough units on hand", NegativeStockReject, 3, -3, -3, false, ""},
+		{"Restock", NegativeStockReject, 0, 5, 5, false, ""},
+		{"Restock of negative stock", NegativeStockReject, -4, 2, 2, false, ""},
+		{"Allow", NegativeStockAllow, 1, -3, -3, true, ""},
+		{"Empty policy allows", "", 1, -3, -3, true, ""},
+		{"Clamp", NegativeStockClamp, 1, -3, -1, true, ""},
+		{"Clamp of negative stock", NegativeStockClamp, -2, -1, 0, true, ""},
+		{"Reject", NegativeStockReject, 1, -3, 0, true, "a delta of -3 would take the 1 units on hand below zero"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			applied, discrepancy, err := applyNegativeStockPolicy(currentTest.Policy, currentTest.UnitsOnHand, currentTest.Delta)
+			if currentTest.ExpectedError != "" {
+				require.EqualError(t, err, currentTest.ExpectedError)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, currentTest.ExpectedApplied, applied)
+			assert.Equal(t, currentTest.ExpectedDiscrepancy, discrepancy)
+		})
+	}
+}
@@ -0,0 +1,489 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+const (
+	// InventoryStoreFile keeps the inventory and audit log in their JSON
+	// files, which only a single instance of the service can use
+	InventoryStoreFile = "file"
+	// InventoryStoreRedis keeps the inventory and audit log in Redis, so
+	// that several instances of the service share them
+	InventoryStoreRedis = "redis"
+	// InventoryStoreSQLite keeps the inventory and audit log in a SQLite
+	// database, whose writes are transactional
+	InventoryStoreSQLite = "sqlite"
+)
+
+// ErrNotStored is returned when the store does not have the inventory, audit
+// log, stock movements, planogram, price history or deleted products yet,
+// which is the case until they are first saved
+var ErrNotStored = errors.New("not stored")
+
+// ErrAuditLogEntryNotFound is returned when the audit log does not have an
+// entry with the requested ID
+var ErrAuditLogEntryNotFound = errors.New("audit log entry not found")
+
+// InventoryStore persists the inventory, the audit log, the stock movements,
+// the planogram, the price history and the deleted products. Each is loaded
+// and saved as a whole, and a save replaces what was stored before. The
+// audit log entries are also indexed by their ID, so that a single entry is
+// loaded without reading the whole audit log.
+type InventoryStore interface {
+	LoadInventory() (Products, error)
+	SaveInventory(inventoryItems Products) error
+	LoadAuditLog() (AuditLog, error)
+	LoadAuditLogEntry(auditEntryID string) (AuditLogEntry, error)
+	SaveAuditLog(auditLog AuditLog) error
+	LoadStockMovements() (StockMovements, error)
+	SaveStockMovements(stockMovements StockMovements) error
+	LoadPlanogram() (Planogram, error)
+	SavePlanogram(planogram Planogram) error
+	LoadPriceHistory() (PriceHistory, error)
+	SavePriceHistory(priceHistory PriceHistory) error
+	LoadDeletedInventory() (Products, error)
+	SaveDeletedInventory(deletedItems Products) error
+	Close() error
+}
+
+// FileStore keeps the inventory, audit log, stock movements, planogram,
+// price history and deleted products in JSON files, written through the
+// FileWriter so that they are as durable as it is configured
+type FileStore struct {
+	inventoryFileName      string
+	auditLogFileName       string
+	stockMovementsFileName string
+	planogramFileName      string
+	priceHistoryFileName   string
+	deletedFileName        string
+	fileWriter             *utilities.FileWriter
+
+	auditLogIndexMutex sync.Mutex
+	auditLogIndex      *auditLogIndex
+}
+
+// auditLogIndex maps the audit log entry IDs to their entries, as of the
+// modification time and size of the audit log file it was built from
+type auditLogIndex struct {
+	modTime time.Time
+	size    int64
+	entries map[string]AuditLogEntry
+}
+
+// NewFileStore creates a FileStore for the inventory and audit log files. The
+// stock movements, planogram, price history and deleted products are kept
+// next to the inventory file.
+func NewFileStore(inventoryFileName string, auditLogFileName string, fileWriter *utilities.FileWriter) *FileStore {
+	return &FileStore{
+		inventoryFileName:      inventoryFileName,
+		auditLogFileName:       auditLogFileName,
+		stockMovementsFileName: StockMovementsFileName(inventoryFileName),
+		planogramFileName:      PlanogramFileName(inventoryFileName),
+		priceHistoryFileName:   PriceHistoryFileName(inventoryFileName),
+		deletedFileName:        DeletedInventoryFileName(inventoryFileName),
+		fileWriter:             fileWriter,
+	}
+}
+
+// StockMovementsFileName is the file of the stock movements, which is kept
+// next to the inventory file so that it needs no setting of its own
+func StockMovementsFileName(inventoryFileName string) string {
+	return strings.TrimSuffix(inventoryFileName, filepath.Ext(inventoryFileName)) + "-movements.json"
+}
+
+// PlanogramFileName is the file of the planogram, which is kept next to the
+// inventory file like the stock movements
+func PlanogramFileName(inventoryFileName string) string {
+	return strings.TrimSuffix(inventoryFileName, filepath.Ext(inventoryFileName)) + "-planogram.json"
+}
+
+// PriceHistoryFileName is the file of the price history, which is kept next
+// to the inventory file like the stock movements
+func PriceHistoryFileName(inventoryFileName string) string {
+	return strings.TrimSuffix(inventoryFileName, filepath.Ext(inventoryFileName)) + "-prices.json"
+}
+
+// DeletedInventoryFileName is the file of the deleted products, which is
+// kept next to the inventory file like the stock movements
+func DeletedInventoryFileName(inventoryFileName string) string {
+	return strings.TrimSuffix(inventoryFileName, filepath.Ext(inventoryFileName)) + "-deleted.json"
+}
+
+// NewInventoryStore creates the store of the given type. The URL is the
+// Redis URL of the redis store, and the database file of the sqlite store.
+func NewInventoryStore(storeType string, url string, inventoryFileName string, auditLogFileName string, fileWriter *utilities.FileWriter) (InventoryStore, error) {
+	switch storeType {
+	case InventoryStoreFile:
+		return NewFileStore(inventoryFileName, auditLogFileName, fileWriter), nil
+	case InventoryStoreRedis:
+		return NewRedisStore(url)
+	case InventoryStoreSQLite:
+		return NewSQLiteStore(url)
+	default:
+		return nil, fmt.Errorf("unknown inventory store %q, expected %s, %s or %s", storeType, InventoryStoreFile, InventoryStoreRedis, InventoryStoreSQLite)
+	}
+}
+
+// LoadInventory reads the inventory file
+func (store *FileStore) LoadInventory() (Products, error) {
+	var inventoryItems Products
+	data, err := os.ReadFile(store.inventoryFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return inventoryItems, fmt.Errorf("failed to read from inventory file: %w: %s", ErrNotStored, err.Error())
+	}
+	if err != nil {
+		return inventoryItems, fmt.Errorf("failed to read from inventory file: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &inventoryItems); err != nil {
+		return inventoryItems, fmt.Errorf("failed to unmarshal inventory file: %s", err.Error())
+	}
+	return inventoryItems, nil
+}
+
+// SaveInventory replaces the inventory file
+func (store *FileStore) SaveInventory(inventoryItems Products) error {
+	return store.writeJSON(store.inventoryFileName, inventoryItems)
+}
+
+// LoadAuditLog reads the audit log file
+func (store *FileStore) LoadAuditLog() (AuditLog, error) {
+	var auditLog AuditLog
+	data, err := os.ReadFile(store.auditLogFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return auditLog, fmt.Errorf("failed to read from audit log JSON file: %w: %s", ErrNotStored, err.Error())
+	}
+	if err != nil {
+		return auditLog, fmt.Errorf("failed to read from audit log JSON file: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &auditLog); err != nil {
+		return auditLog, fmt.Errorf("failed to unmarshal audit log JSON file: %s", err.Error())
+	}
+	return auditLog, nil
+}
+
+// LoadAuditLogEntry looks the entry up in the index of the audit log file,
+// which is only rebuilt when the file has changed since it was built
+func (store *FileStore) LoadAuditLogEntry(auditEntryID string) (AuditLogEntry, error) {
+	info, err := os.Stat(store.auditLogFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return AuditLogEntry{}, fmt.Errorf("failed to read from audit log JSON file: %w: %s", ErrNotStored, err.Error())
+	}
+	if err != nil {
+		return AuditLogEntry{}, fmt.Errorf("failed to read from audit log JSON file: %s", err.Error())
+	}
+
+	store.auditLogIndexMutex.Lock()
+	defer store.auditLogIndexMutex.Unlock()
+	index := store.auditLogIndex
+	if index == nil || !index.modTime.Equal(info.ModTime()) || index.size != info.Size() {
+		auditLog, err := store.LoadAuditLog()
+		if err != nil {
+			return AuditLogEntry{}, err
+		}
+		index = newAuditLogIndex(auditLog, info)
+		store.auditLogIndex = index
+	}
+
+	auditLogEntry, ok := index.entries[auditEntryID]
+	if !ok {
+		return AuditLogEntry{}, ErrAuditLogEntryNotFound
+	}
+	return auditLogEntry, nil
+}
+
+// SaveAuditLog replaces the audit log file and its index
+func (store *FileStore) SaveAuditLog(auditLog AuditLog) error {
+	store.auditLogIndexMutex.Lock()
+	defer store.auditLogIndexMutex.Unlock()
+	store.auditLogIndex = nil
+	if err := store.writeJSON(store.auditLogFileName, auditLog); err != nil {
+		return err
+	}
+	if info, err := os.Stat(store.auditLogFileName); err == nil {
+		store.auditLogIndex = newAuditLogIndex(auditLog, info)
+	}
+	return nil
+}
+
+func newAuditLogIndex(auditLog AuditLog, info os.FileInfo) *auditLogIndex {
+	index := &auditLogIndex{modTime: info.ModTime(), size: info.Size(), entries: make(map[string]AuditLogEntry, len(auditLog.Data))}
+	for _, auditLogEntry := range auditLog.Data {
+		// the first entry of a repeated ID is the one found
+		if _, ok := index.entries[auditLogEntry.AuditEntryID]; !ok {
+			index.entries[auditLogEntry.AuditEntryID] = auditLogEntry
+		}
+	}
+	return index
+}
+
+// LoadStockMovements reads the stock movements file
+func (store *FileStore) LoadStockMovements() (StockMovements, error) {
+	var stockMovements StockMovements
+	data, err := os.ReadFile(store.stockMovementsFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return stockMovements, fmt.Errorf("failed to read from stock movements file: %w: %s", ErrNotStored, err.Error())
+	}
+	if err != nil {
+		return stockMovements, fmt.Errorf("failed to read from stock movements file: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &stockMovements); err != nil {
+		return stockMovements, fmt.Errorf("failed to unmarshal stock movements file: %s", err.Error())
+	}
+	return stockMovements, nil
+}
+
+// SaveStockMovements replaces the stock movements file
+func (store *FileStore) SaveStockMovements(stockMovements StockMovements) error {
+	return store.writeJSON(store.stockMovementsFileName, stockMovements)
+}
+
+// LoadPlanogram reads the planogram file
+func (store *FileStore) LoadPlanogram() (Planogram, error) {
+	var planogram Planogram
+	data, err := os.ReadFile(store.planogramFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return planogram, fmt.Errorf("failed to read from planogram file: %w: %s", ErrNotStored, err.Error())
+	}
+	if err != nil {
+		return planogram, fmt.Errorf("failed to read from planogram file: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &planogram); err != nil {
+		return planogram, fmt.Errorf("failed to unmarshal planogram file: %s", err.Error())
+	}
+	return planogram, nil
+}
+
+// SavePlanogram replaces the planogram file
+func (store *FileStore) SavePlanogram(planogram Planogram) error {
+	return store.writeJSON(store.planogramFileName, planogram)
+}
+
+// LoadPriceHistory reads the price history file
+func (store *FileStore) LoadPriceHistory() (PriceHistory, error) {
+	var priceHistory PriceHistory
+	data, err := os.ReadFile(store.priceHistoryFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return priceHistory, fmt.Errorf("failed to read from price history file: %w: %s", ErrNotStored, err.Error())
+	}
+	if err != nil {
+		return priceHistory, fmt.Errorf("failed to read from price history file: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &priceHistory); err != nil {
+		return priceHistory, fmt.Errorf("failed to unmarshal price history file: %s", err.Error())
+	}
+	return priceHistory, nil
+}
+
+// SavePriceHistory replaces the price history file
+func (store *FileStore) SavePriceHistory(priceHistory PriceHistory) error {
+	return store.writeJSON(store.priceHistoryFileName, priceHistory)
+}
+
+// LoadDeletedInventory reads the deleted products file
+func (store *FileStore) LoadDeletedInventory() (Products, error) {
+	var deletedItems Products
+	data, err := os.ReadFile(store.deletedFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return deletedItems, fmt.Errorf("failed to read from deleted products file: %w: %s", ErrNotStored, err.Error())
+	}
+	if err != nil {
+		return deletedItems, fmt.Errorf("failed to read from deleted products file: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &deletedItems); err != nil {
+		return deletedItems, fmt.Errorf("failed to unmarshal deleted products file: %s", err.Error())
+	}
+	return deletedItems, nil
+}
+
+// SaveDeletedInventory replaces the deleted products file
+func (store *FileStore) SaveDeletedInventory(deletedItems Products) error {
+	return store.writeJSON(store.deletedFileName, deletedItems)
+}
+
+// Close does nothing, since the files are not kept open
+func (store *FileStore) Close() error {
+	return nil
+}
+
+func (store *FileStore) writeJSON(fileName string, content interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %s", err.Error())
+	}
+	if err = store.fileWriter.WriteFile(fileName, data, 0644); err != nil {
+		return fmt.Errorf("failed to write data to file: %s", err.Error())
+	}
+	return nil
+}
+
+// inventoryStore is the store of the inventory and audit log, which are
+// kept in their files when no store is set
+func (c *Controller) inventoryStore() InventoryStore {
+	if c.store != nil {
+		return c.store
+	}
+	return NewFileStore(c.inventoryFileName, c.auditLogFileName, c.fileWriter)
+}
+
+// MigrateInventory copies the inventory, audit log, stock movements,
+// planogram, price history and deleted products files into the store the first time a store
+// other than the files is used, and returns the files that were migrated. Migrated files are renamed with a
+// .migrated suffix. Without a file to migrate, the store starts out empty.
+func (c *Controller) MigrateInventory() ([]string, error) {
+	store := c.inventoryStore()
+	if _, ok := store.(*FileStore); ok {
+		return nil, nil
+	}
+	files := NewFileStore(c.inventoryFileName, c.auditLogFileName, c.fileWriter)
+	var migrated []string
+
+	if _, err := store.LoadInventory(); errors.Is(err, ErrNotStored) {
+		inventoryItems, err := files.LoadInventory()
+		fileExists := err == nil
+		if errors.Is(err, ErrNotStored) {
+			inventoryItems, err = Products{Data: []Product{}}, nil
+		}
+		if err != nil {
+			return migrated, err
+		}
+		if err = store.SaveInventory(inventoryItems); err != nil {
+			return migrated, fmt.Errorf("failed to migrate inventory: %s", err.Error())
+		}
+		if fileExists {
+			if err = os.Rename(c.inventoryFileName, c.inventoryFileName+".migrated"); err != nil {
+				return migrated, fmt.Errorf("failed to rename migrated inventory file: %s", err.Error())
+			}
+			migrated = append(migrated, c.inventoryFileName)
+		}
+	} else if err != nil {
+		return migrated, err
+	}
+
+	if _, err := store.LoadAuditLog(); errors.Is(err, ErrNotStored) {
+		auditLog, err := files.LoadAuditLog()
+		fileExists := err == nil
+		if errors.Is(err, ErrNotStored) {
+			auditLog, err = AuditLog{Data: []AuditLogEntry{}}, nil
+		}
+		if err != nil {
+			return migrated, err
+		}
+		if err = store.SaveAuditLog(auditLog); err != nil {
+			return migrated, fmt.Errorf("failed to migrate audit log: %s", err.Error())
+		}
+		if fileExists {
+			if err = os.Rename(c.auditLogFileName, c.auditLogFileName+".migrated"); err != nil {
+				return migrated, fmt.Errorf("failed to rename migrated audit log file: %s", err.Error())
+			}
+			migrated = append(migrated, c.auditLogFileName)
+		}
+	} else if err != nil {
+		return migrated, err
+	}
+
+	if _, err := store.LoadStockMovements(); errors.Is(err, ErrNotStored) {
+		stockMovements, err := files.LoadStockMovements()
+		fileExists := err == nil
+		if errors.Is(err, ErrNotStored) {
+			stockMovements, err = StockMovements{Data: []StockMovement{}}, nil
+		}
+		if err != nil {
+			return migrated, err
+		}
+		if err = store.SaveStockMovements(stockMovements); err != nil {
+			return migrated, fmt.Errorf("failed to migrate stock movements: %s", err.Error())
+		}
+		if fileExists {
+			stockMovementsFileName := StockMovementsFileName(c.inventoryFileName)
+			if err = os.Rename(stockMovementsFileName, stockMovementsFileName+".migrated"); err != nil {
+				return migrated, fmt.Errorf("failed to rename migrated stock movements file: %s", err.Error())
+			}
+			migrated = append(migrated, stockMovementsFileName)
+		}
+	} else if err != nil {
+		return migrated, err
+	}
+
+	if _, err := store.LoadPlanogram(); errors.Is(err, ErrNotStored) {
+		planogram, err := files.LoadPlanogram()
+		fileExists := err == nil
+		if errors.Is(err, ErrNotStored) {
+			planogram, err = Planogram{Data: []PlanogramSlot{}}, nil
+		}
+		if err != nil {
+			return migrated, err
+		}
+		if err = store.SavePlanogram(planogram); err != nil {
+			return migrated, fmt.Errorf("failed to migrate planogram: %s", err.Error())
+		}
+		if fileExists {
+			planogramFileName := PlanogramFileName(c.inventoryFileName)
+			if err = os.Rename(planogramFileName, planogramFileName+".migrated"); err != nil {
+				return migrated, fmt.Errorf("failed to rename migrated planogram file: %s", err.Error())
+			}
+			migrated = append(migrated, planogramFileName)
+		}
+	} else if err != nil {
+		return migrated, err
+	}
+
+	if _, err := store.LoadPriceHistory(); errors.Is(err, ErrNotStored) {
+		priceHistory, err := files.LoadPriceHistory()
+		fileExists := err == nil
+		if errors.Is(err, ErrNotStored) {
+			priceHistory, err = PriceHistory{Data: []PriceChange{}}, nil
+		}
+		if err != nil {
+			return migrated, err
+		}
+		if err = store.SavePriceHistory(priceHistory); err != nil {
+			return migrated, fmt.Errorf("failed to migrate price history: %s", err.Error())
+		}
+		if fileExists {
+			priceHistoryFileName := PriceHistoryFileName(c.inventoryFileName)
+			if err = os.Rename(priceHistoryFileName, priceHistoryFileName+".migrated"); err != nil {
+				return migrated, fmt.Errorf("failed to rename migrated price history file: %s", err.Error())
+			}
+			migrated = append(migrated, priceHistoryFileName)
+		}
+	} else if err != nil {
+		return migrated, err
+	}
+
+	if _, err := store.LoadDeletedInventory(); errors.Is(err, ErrNotStored) {
+		deletedItems, err := files.LoadDeletedInventory()
+		fileExists := err == nil
+		if errors.Is(err, ErrNotStored) {
+			deletedItems, err = Products{Data: []Product{}}, nil
+		}
+		if err != nil {
+			return migrated, err
+		}
+		if err = store.SaveDeletedInventory(deletedItems); err != nil {
+			return migrated, fmt.Errorf("failed to migrate deleted products: %s", err.Error())
+		}
+		if fileExists {
+			deletedFileName := DeletedInventoryFileName(c.inventoryFileName)
+			if err = os.Rename(deletedFileName, deletedFileName+".migrated"); err != nil {
+				return migrated, fmt.Errorf("failed to rename migrated deleted products file: %s", err.Error())
+			}
+			migrated = append(migrated, deletedFileName)
+		}
+	} else if err != nil {
+		return migrated, err
+	}
+	return migrated, nil
+}
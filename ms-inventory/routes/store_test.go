@@ -0,0 +1,216 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStoreTestController(t *testing.T, store InventoryStore) Controller {
+	dir := t.TempDir()
+	return Controller{
+		lc:                logger.NewMockClient(),
+		inventoryFileName: filepath.Join(dir, InventoryFileName),
+		auditLogFileName:  filepath.Join(dir, AuditLogFileName),
+		store:             store,
+	}
+}
+
+// testInventoryStore runs the checks every inventory store must pass
+func testInventoryStore(t *testing.T, store InventoryStore) {
+	_, err := store.LoadInventory()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadAuditLog()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadStockMovements()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadPlanogram()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadPriceHistory()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadDeletedInventory()
+	assert.ErrorIs(t, err, ErrNotStored)
+	_, err = store.LoadAuditLogEntry("1")
+	assert.ErrorIs(t, err, ErrNotStored)
+
+	require.NoError(t, store.SaveInventory(getDefaultProductsList()))
+	require.NoError(t, store.SaveAuditLog(getDefaultAuditsList()))
+	inventoryItems, err := store.LoadInventory()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultProductsList(), inventoryItems)
+	auditLog, err := store.LoadAuditLog()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultAuditsList(), auditLog)
+	auditLogEntry, err := store.LoadAuditLogEntry("2")
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultAuditsList().Data[1], auditLogEntry)
+	_, err = store.LoadAuditLogEntry("42")
+	assert.ErrorIs(t, err, ErrAuditLogEntryNotFound)
+
+	// a save replaces what was stored before
+	require.NoError(t, store.SaveInventory(Products{Data: []Product{getDefaultProductsList().Data[1]}}))
+	inventoryItems, err = store.LoadInventory()
+	require.NoError(t, err)
+	assert.Equal(t, []Product{getDefaultProductsList().Data[1]}, inventoryItems.Data)
+
+	require.NoError(t, store.SaveAuditLog(AuditLog{Data: []AuditLogEntry{}}))
+	auditLog, err = store.LoadAuditLog()
+	require.NoError(t, err, "an empty audit log is still stored")
+	assert.Empty(t, auditLog.Data)
+	_, err = store.LoadAuditLogEntry("2")
+	assert.ErrorIs(t, err, ErrAuditLogEntryNotFound, "the entries of the replaced audit log are not found")
+
+	stockMovements := StockMovements{Data: []StockMovement{
+		{MovementID: "1", SKU: "4900002470", Delta: -1, UnitsOnHand: 4, Reason: DeltaReasonSale, Source: DeltaSource{Service: "as-vending", SessionID: "42"}, CreatedAt: 1},
+		{MovementID: "2", SKU: "4900002470", Delta: 6, UnitsOnHand: 10, Reason: DeltaReasonRestock, CreatedAt: 2},
+	}}
+	require.NoError(t, store.SaveStockMovements(stockMovements))
+	loaded, err := store.LoadStockMovements()
+	require.NoError(t, err)
+	assert.Equal(t, stockMovements, loaded)
+
+	planogram := Planogram{Data: []PlanogramSlot{
+		{Shelf: 1, Lane: 1, SKU: "4900002470", Capacity: 8, UpdatedAt: 1},
+		{Shelf: 1, Lane: 2, SKU: "4900002470", UpdatedAt: 2},
+	}}
+	require.NoError(t, store.SavePlanogram(planogram))
+	loadedPlanogram, err := store.LoadPlanogram()
+	require.NoError(t, err)
+	assert.Equal(t, planogram, loadedPlanogram)
+
+	priceHistory := PriceHistory{Data: []PriceChange{
+		{ChangeID: "1", SKU: "4900002470", ItemPrice: 1.99, EffectiveAt: 1},
+		{ChangeID: "2", SKU: "4900002470", ItemPrice: 2.49, Currency: "EUR", EffectiveAt: 2},
+	}}
+	require.NoError(t, store.SavePriceHistory(priceHistory))
+	loadedPriceHistory, err := store.LoadPriceHistory()
+	require.NoError(t, err)
+	assert.Equal(t, priceHistory, loadedPriceHistory)
+
+	deletedProduct := getDefaultProductsList().Data[0]
+	deletedProduct.Deleted = true
+	deletedProduct.DeletedAt = 3
+	deletedItems := Products{Data: []Product{deletedProduct}}
+	require.NoError(t, store.SaveDeletedInventory(deletedItems))
+	loadedDeletedItems, err := store.LoadDeletedInventory()
+	require.NoError(t, err)
+	assert.Equal(t, deletedItems, loadedDeletedItems)
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	testInventoryStore(t, NewFileStore(filepath.Join(dir, InventoryFileName), filepath.Join(dir, AuditLogFileName), nil))
+}
+
+func TestNewInventoryStore(t *testing.T) {
+	store, err := NewInventoryStore(InventoryStoreFile, "", InventoryFileName, AuditLogFileName, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &FileStore{}, store)
+
+	_, err = NewInventoryStore("mongodb", "", InventoryFileName, AuditLogFileName, nil)
+	assert.Error(t, err)
+	_, err = NewInventoryStore(InventoryStoreRedis, "", InventoryFileName, AuditLogFileName, nil)
+	assert.Error(t, err, "the redis store needs a URL")
+	_, err = NewInventoryStore(InventoryStoreSQLite, "", InventoryFileName, AuditLogFileName, nil)
+	assert.Error(t, err, "the sqlite store needs a file name")
+}
+
+func TestMigrateInventory(t *testing.T) {
+	c := newStoreTestController(t, newFakeRedisStore())
+	inventoryData, err := json.Marshal(getDefaultProductsList())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.inventoryFileName, inventoryData, 0644))
+
+	migrated, err := c.MigrateInventory()
+	require.NoError(t, err)
+	assert.Equal(t, []string{c.inventoryFileName}, migrated, "without an audit log file the audit log starts out empty")
+	assert.NoFileExists(t, c.inventoryFileName)
+	assert.FileExists(t, c.inventoryFileName+".migrated")
+
+	inventoryItems, err := c.GetInventoryItems()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultProductsList(), inventoryItems)
+	auditLog, err := c.GetAuditLog()
+	require.NoError(t, err)
+	assert.Empty(t, auditLog.Data)
+	stockMovements, err := c.store.LoadStockMovements()
+	require.NoError(t, err, "the stock movements are stored even without a file")
+	assert.Empty(t, stockMovements.Data)
+	planogram, err := c.store.LoadPlanogram()
+	require.NoError(t, err, "the planogram is stored even without a file")
+	assert.Empty(t, planogram.Data)
+	priceHistory, err := c.store.LoadPriceHistory()
+	require.NoError(t, err, "the price history is stored even without a file")
+	assert.Empty(t, priceHistory.Data)
+	deletedItems, err := c.store.LoadDeletedInventory()
+	require.NoError(t, err, "the deleted products are stored even without a file")
+	assert.Empty(t, deletedItems.Data)
+
+	// the files are only migrated once
+	require.NoError(t, os.WriteFile(c.inventoryFileName, []byte(`{"data":[]}`), 0644))
+	migrated, err = c.MigrateInventory()
+	require.NoError(t, err)
+	assert.Empty(t, migrated)
+	assert.FileExists(t, c.inventoryFileName)
+	inventoryItems, err = c.GetInventoryItems()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultProductsList(), inventoryItems)
+}
+
+func TestMigrateInventoryFileStore(t *testing.T) {
+	c := newStoreTestController(t, nil)
+	require.NoError(t, os.WriteFile(c.inventoryFileName, []byte(`{"data":[]}`), 0644))
+
+	migrated, err := c.MigrateInventory()
+	require.NoError(t, err)
+	assert.Empty(t, migrated)
+	assert.FileExists(t, c.inventoryFileName, "the file store is not migrated")
+}
+
+func TestMigrateInventoryInvalidFile(t *testing.T) {
+	c := newStoreTestController(t, newFakeRedisStore())
+	require.NoError(t, os.WriteFile(c.inventoryFileName, []byte(`{"data":[{"sku":`), 0644))
+
+	_, err := c.MigrateInventory()
+	assert.Error(t, err)
+	assert.FileExists(t, c.inventoryFileName, "a file that cannot be read is kept")
+}
+
+func TestStoreRequests(t *testing.T) {
+	c := newStoreTestController(t, newFakeRedisStore())
+	require.NoError(t, c.store.SaveInventory(getDefaultProductsList()))
+	require.NoError(t, c.store.SaveAuditLog(getDefaultAuditsList()))
+
+	req := httptest.NewRequest("POST", "http://localhost:48095/inventory/delta", bytes.NewBuffer([]byte(`[{"sku":"4900002470","delta":-1}]`)))
+	w := httptest.NewRecorder()
+	c.DeltaInventorySKUPost(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	req = httptest.NewRequest("POST", "http://localhost:48095/auditlog", bytes.NewBuffer([]byte(`{"cardId":"0003293374","accountId":1,"roleId":1,"personId":1,"inventoryDelta":[]}`)))
+	w = httptest.NewRecorder()
+	c.AuditLogPost(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	inventoryItems, err := c.store.LoadInventory()
+	require.NoError(t, err)
+	product := getDefaultProductsList().Data[0]
+	require.Equal(t, product.SKU, inventoryItems.Data[0].SKU)
+	assert.Equal(t, product.UnitsOnHand-1, inventoryItems.Data[0].UnitsOnHand)
+	auditLog, err := c.store.LoadAuditLog()
+	require.NoError(t, err)
+	assert.Len(t, auditLog.Data, len(getDefaultAuditsList().Data)+1)
+
+	assert.NoFileExists(t, c.inventoryFileName, "the files are not used")
+	assert.NoFileExists(t, c.auditLogFileName, "the files are not used")
+}
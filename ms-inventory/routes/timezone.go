@@ -0,0 +1,30 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoadTimeZone loads the kiosk's time zone from its IANA name, such as
+// "America/Chicago". An empty name is UTC.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q: %s", name, err.Error())
+	}
+	return location, nil
+}
+
+// location returns the kiosk's time zone, which is UTC when none is set
+func (c *Controller) location() *time.Location {
+	if c.timeZone == nil {
+		return time.UTC
+	}
+	return c.timeZone
+}
@@ -0,0 +1,61 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTimeZone(t *testing.T) {
+	location, err := LoadTimeZone("")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, location)
+
+	location, err = LoadTimeZone("Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", location.String())
+
+	_, err = LoadTimeZone("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
+
+// TestInventoryAvailabilityGetTimeZone tests that availability windows are
+// in the kiosk's time zone
+func TestInventoryAvailabilityGetTimeZone(t *testing.T) {
+	chicago, err := LoadTimeZone("America/Chicago")
+	require.NoError(t, err)
+	products := getDefaultProductsList()
+	products.Data[0].Availability = []AvailabilityWindow{{Start: "06:00", End: "11:00"}}
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		inventoryItems:    products,
+		inventoryFileName: InventoryFileName,
+		timeZone:          chicago,
+	}
+	require.NoError(t, c.WriteInventory())
+	defer func() {
+		_ = os.Remove(c.inventoryFileName)
+	}()
+
+	// 13:00 UTC is 07:00 in Chicago
+	req := httptest.NewRequest("GET", "http://localhost:48095/inventory/availability?at=2023-01-02T13:00:00Z", nil)
+	w := httptest.NewRecorder()
+	c.InventoryAvailabilityGet(w, req)
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var availability ProductAvailabilities
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&availability))
+	assert.True(t, availability.Data[0].Available)
+}
@@ -0,0 +1,59 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/golang-jwt/jwt"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRequireMaintainer tests that the administrative routes check the
+// tokens with the TokenVerifier of the controller
+func TestRequireMaintainer(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, utilities.AuthClaims{
+		PersonID:       2,
+		RoleID:         utilities.MaintainerRoleID,
+		StandardClaims: jwt.StandardClaims{Issuer: utilities.TokenIssuer, ExpiresAt: time.Now().Add(time.Minute).Unix()},
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name           string
+		Verifier       *utilities.TokenVerifier
+		Authorization  string
+		ExpectedStatus int
+	}{
+		{"maintainer", utilities.NewTokenVerifier("secret"), "Bearer " + token, http.StatusOK},
+		{"no token", utilities.NewTokenVerifier("secret"), "", http.StatusUnauthorized},
+		{"another secret", utilities.NewTokenVerifier("another"), "Bearer " + token, http.StatusUnauthorized},
+		{"tokens not configured", utilities.NewTokenVerifier(""), "", http.StatusOK},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{lc: logger.NewMockClient(), tokenVerifier: currentTest.Verifier}
+			served := false
+			handler := c.requireMaintainer(func(writer http.ResponseWriter, req *http.Request) {
+				served = true
+			})
+
+			req := httptest.NewRequest(http.MethodDelete, "/inventory/4900002470", nil)
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, currentTest.ExpectedStatus, w.Code, w.Body.String())
+			assert.Equal(t, currentTest.ExpectedStatus == http.StatusOK, served)
+		})
+	}
+}
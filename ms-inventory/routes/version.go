@@ -0,0 +1,25 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import "github.com/intel-iot-devkit/automated-checkout-utilities"
+
+// versionServiceName is the name of the service in its build
+const versionServiceName = "ms-inventory"
+
+// schemaVersions are the versions of the layouts of the data in the
+// inventory store, each bumped when its layout changes
+var schemaVersions = map[string]int{
+	"inventory":      1,
+	"auditLog":       1,
+	"stockMovements": 1,
+	"planogram":      1,
+	"priceHistory":   1,
+	"deleted":        1,
+}
+
+// CurrentVersion returns the build of the running service
+func CurrentVersion() utilities.VersionInfo {
+	return utilities.CurrentVersion(versionServiceName, utilities.AppSDKModulePath, schemaVersions)
+}
@@ -0,0 +1,245 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+const (
+	// DefaultWMSRetryInterval is how often stock changes that failed to post
+	// to the WMS are posted again when they are not posted in batches
+	DefaultWMSRetryInterval = time.Minute
+	// DefaultWMSTimeout is how long a post to the WMS may take
+	DefaultWMSTimeout = 15 * time.Second
+	// maxWMSQueue is the number of stock changes kept while the WMS is
+	// unreachable, the oldest are dropped beyond it
+	maxWMSQueue = 10000
+)
+
+// WMSFields are the stock movement fields that can be posted to the WMS
+var WMSFields = []string{"movementId", "kioskId", "sku", "delta", "unitsOnHand", "reason", "service", "user", "sessionId", "createdAt"}
+
+// WMSOptions configure the posting of stock changes to a warehouse
+// management system
+type WMSOptions struct {
+	Endpoint string
+	// KioskID identifies the kiosk of the stock changes
+	KioskID string
+	// FieldMapping maps each field that is posted to the name the WMS
+	// expects for it. Without it every field is posted with its own name.
+	FieldMapping map[string]string
+	// BatchInterval posts the stock changes collected during each interval
+	// together, zero posts the changes of each request as they happen
+	BatchInterval time.Duration
+	// BatchSize posts a batch early once it has this many stock changes,
+	// zero does not limit it
+	BatchSize int
+	Timeout   time.Duration
+}
+
+// WMSExporter posts the stock changes of the kiosk to a warehouse
+// management system (WMS), so that central replenishment planning sees the
+// consumption of every kiosk without polling them. The stock changes are
+// queued and posted as a JSON array of objects, one for each change, in the
+// background. A failed post is kept queued and posted again later. A nil
+// WMSExporter does not post anything.
+type WMSExporter struct {
+	options WMSOptions
+	client  *http.Client
+	lc      logger.LoggingClient
+	mutex   sync.Mutex
+	queue   []StockMovement
+	flush   chan struct{}
+}
+
+// ParseWMSFieldMapping parses a field mapping of comma separated
+// field:name pairs, such as "sku:itemCode,delta:quantity". The fields must
+// be WMSFields.
+func ParseWMSFieldMapping(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		field, name, found := strings.Cut(strings.TrimSpace(pair), ":")
+		field = strings.TrimSpace(field)
+		name = strings.TrimSpace(name)
+		if !found || field == "" || name == "" {
+			return nil, fmt.Errorf("%q is not a field:name pair", pair)
+		}
+		if !isWMSField(field) {
+			return nil, fmt.Errorf("%s is not one of %s", field, strings.Join(WMSFields, ", "))
+		}
+		mapping[field] = name
+	}
+	return mapping, nil
+}
+
+func isWMSField(field string) bool {
+	for _, valid := range WMSFields {
+		if field == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWMSExporter creates a WMSExporter for the options, or returns nil when
+// no endpoint is set
+func NewWMSExporter(lc logger.LoggingClient, options WMSOptions) *WMSExporter {
+	if options.Endpoint == "" {
+		return nil
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultWMSTimeout
+	}
+	return &WMSExporter{
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+		lc:      lc,
+		queue:   []StockMovement{},
+		flush:   make(chan struct{}, 1),
+	}
+}
+
+// Add queues the stock changes to be posted
+func (exporter *WMSExporter) Add(movements []StockMovement) {
+	if exporter == nil || len(movements) == 0 {
+		return
+	}
+	exporter.mutex.Lock()
+	exporter.queue = append(exporter.queue, movements...)
+	if dropped := len(exporter.queue) - maxWMSQueue; dropped > 0 {
+		exporter.lc.Errorf("Dropped %d stock changes that were not posted to the WMS", dropped)
+		exporter.queue = exporter.queue[dropped:]
+	}
+	full := exporter.options.BatchSize > 0 && len(exporter.queue) >= exporter.options.BatchSize
+	exporter.mutex.Unlock()
+
+	if exporter.options.BatchInterval <= 0 || full {
+		select {
+		case exporter.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Queued returns the number of stock changes waiting to be posted
+func (exporter *WMSExporter) Queued() int {
+	if exporter == nil {
+		return 0
+	}
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	return len(exporter.queue)
+}
+
+// Run posts the queued stock changes until the context is done, every
+// BatchInterval in batch mode, or as soon as they are added otherwise
+func (exporter *WMSExporter) Run(ctx context.Context) {
+	if exporter == nil {
+		return
+	}
+	interval := exporter.options.BatchInterval
+	if interval <= 0 {
+		interval = DefaultWMSRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-exporter.flush:
+		}
+		if err := exporter.Flush(); err != nil {
+			exporter.lc.Errorf("Failed to post stock changes to the WMS, they will be posted again: %s", err.Error())
+		}
+	}
+}
+
+// Flush posts the queued stock changes, at most BatchSize of them in each
+// post. The changes of a failed post stay queued.
+func (exporter *WMSExporter) Flush() error {
+	for {
+		exporter.mutex.Lock()
+		batch := exporter.queue
+		if exporter.options.BatchSize > 0 && len(batch) > exporter.options.BatchSize {
+			batch = batch[:exporter.options.BatchSize]
+		}
+		exporter.mutex.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := exporter.post(batch); err != nil {
+			return err
+		}
+		exporter.lc.Debugf("Posted %d stock changes to the WMS", len(batch))
+
+		// changes added during the post were appended after the batch
+		exporter.mutex.Lock()
+		exporter.queue = exporter.queue[len(batch):]
+		exporter.mutex.Unlock()
+	}
+}
+
+func (exporter *WMSExporter) post(batch []StockMovement) error {
+	records := []map[string]interface{}{}
+	for _, movement := range batch {
+		records = append(records, exporter.record(movement))
+	}
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stock changes: %s", err.Error())
+	}
+
+	resp, err := exporter.client.Post(exporter.options.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("WMS responded with status code %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// record is the stock change with the fields of the field mapping, named
+// as the WMS expects
+func (exporter *WMSExporter) record(movement StockMovement) map[string]interface{} {
+	fields := map[string]interface{}{
+		"movementId":  movement.MovementID,
+		"kioskId":     exporter.options.KioskID,
+		"sku":         movement.SKU,
+		"delta":       movement.Delta,
+		"unitsOnHand": movement.UnitsOnHand,
+		"reason":      movement.Reason,
+		"service":     movement.Source.Service,
+		"user":        movement.Source.User,
+		"sessionId":   movement.Source.SessionID,
+		"createdAt":   time.Unix(0, movement.CreatedAt).UTC().Format(time.RFC3339Nano),
+	}
+	if len(exporter.options.FieldMapping) == 0 {
+		return fields
+	}
+	record := map[string]interface{}{}
+	for field, name := range exporter.options.FieldMapping {
+		record[name] = fields[field]
+	}
+	return record
+}
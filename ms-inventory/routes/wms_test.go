@@ -0,0 +1,156 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wmsTestServer records the batches posted to it, and fails them while
+// failing is set
+type wmsTestServer struct {
+	mutex   sync.Mutex
+	batches [][]map[string]interface{}
+	failing bool
+}
+
+func newWMSTestServer(t *testing.T) (*wmsTestServer, string) {
+	wms := &wmsTestServer{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		wms.mutex.Lock()
+		defer wms.mutex.Unlock()
+		if wms.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		wms.batches = append(wms.batches, batch)
+	}))
+	t.Cleanup(server.Close)
+	return wms, server.URL
+}
+
+func (wms *wmsTestServer) posted() [][]map[string]interface{} {
+	wms.mutex.Lock()
+	defer wms.mutex.Unlock()
+	return append([][]map[string]interface{}{}, wms.batches...)
+}
+
+func TestParseWMSFieldMapping(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Value         string
+		Expected      map[string]string
+		ExpectedError string
+	}{
+		{"Empty", "", nil, ""},
+		{"Mapping", "sku:itemCode, delta : quantity", map[string]string{"sku": "itemCode", "delta": "quantity"}, ""},
+		{"Not a pair", "sku", nil, "is not a field:name pair"},
+		{"Empty name", "sku:", nil, "is not a field:name pair"},
+		{"Unknown field", "price:amount", nil, "price is not one of"},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mapping, err := ParseWMSFieldMapping(currentTest.Value)
+			if currentTest.ExpectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), currentTest.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, mapping)
+		})
+	}
+}
+
+func TestWMSExporterFieldMapping(t *testing.T) {
+	wms, endpoint := newWMSTestServer(t)
+	movements := getDefaultStockMovements().Data[:1]
+
+	exporter := NewWMSExporter(logger.NewMockClient(), WMSOptions{Endpoint: endpoint, KioskID: "kiosk-1"})
+	exporter.Add(movements)
+	require.NoError(t, exporter.Flush())
+
+	exporter = NewWMSExporter(logger.NewMockClient(), WMSOptions{Endpoint: endpoint, KioskID: "kiosk-1", FieldMapping: map[string]string{"kioskId": "site", "sku": "itemCode", "delta": "quantity"}})
+	exporter.Add(movements)
+	require.NoError(t, exporter.Flush())
+
+	batches := wms.posted()
+	require.Len(t, batches, 2)
+	assert.Equal(t, []map[string]interface{}{{
+		"movementId":  "1",
+		"kioskId":     "kiosk-1",
+		"sku":         "4900002470",
+		"delta":       float64(-2),
+		"unitsOnHand": float64(3),
+		"reason":      DeltaReasonSale,
+		"service":     "as-vending",
+		"user":        "0003293374",
+		"sessionId":   "a",
+		"createdAt":   "2023-05-01T09:00:00Z",
+	}}, batches[0])
+	assert.Equal(t, []map[string]interface{}{{"site": "kiosk-1", "itemCode": "4900002470", "quantity": float64(-2)}}, batches[1])
+}
+
+func TestWMSExporterBatches(t *testing.T) {
+	wms, endpoint := newWMSTestServer(t)
+	exporter := NewWMSExporter(logger.NewMockClient(), WMSOptions{Endpoint: endpoint, BatchInterval: time.Hour, BatchSize: 3})
+
+	// the WMS is unreachable, so the changes stay queued
+	wms.failing = true
+	exporter.Add(getDefaultStockMovements().Data)
+	require.Error(t, exporter.Flush())
+	assert.Equal(t, 4, exporter.Queued())
+
+	wms.failing = false
+	require.NoError(t, exporter.Flush())
+	assert.Zero(t, exporter.Queued())
+	batches := wms.posted()
+	require.Len(t, batches, 2, "a batch has at most BatchSize changes")
+	assert.Len(t, batches[0], 3)
+	assert.Len(t, batches[1], 1)
+
+	var nilExporter *WMSExporter
+	nilExporter.Add(getDefaultStockMovements().Data)
+	assert.Zero(t, nilExporter.Queued())
+	assert.Nil(t, NewWMSExporter(logger.NewMockClient(), WMSOptions{}))
+}
+
+func TestDeltaInventorySKUPostWMS(t *testing.T) {
+	wms, endpoint := newWMSTestServer(t)
+	c := newStoreTestController(t, nil)
+	c.wmsExporter = NewWMSExporter(logger.NewMockClient(), WMSOptions{Endpoint: endpoint, KioskID: "kiosk-1"})
+	require.NoError(t, c.inventoryStore().SaveInventory(getDefaultProductsList()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.wmsExporter.Run(ctx)
+
+	req := httptest.NewRequest("POST", "http://localhost:48096/inventory/delta", bytes.NewBuffer([]byte(`[{"SKU":"4900002470","delta":-2}]`)))
+	w := httptest.NewRecorder()
+	c.DeltaInventorySKUPost(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	// the change is posted as soon as it is recorded, without a batch interval
+	require.Eventually(t, func() bool { return len(wms.posted()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	batch := wms.posted()[0]
+	require.Len(t, batch, 1)
+	assert.Equal(t, "4900002470", batch[0]["sku"])
+	assert.Equal(t, float64(-2), batch[0]["delta"])
+	assert.Equal(t, "kiosk-1", batch[0]["kioskId"])
+}
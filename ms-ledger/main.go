@@ -4,11 +4,18 @@
 package main
 
 import (
+	"ms-ledger/payment"
 	"ms-ledger/routes"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+	// the time zone database is embedded, as the container image has none
+	_ "time/tzdata"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 const (
@@ -18,12 +25,15 @@ const (
 func main() {
 	// See https://docs.edgexfoundry.org/2.2/microservices/application/ApplicationServices/
 	//       for documentation on application services.
-	service, ok := pkg.NewAppService(serviceKey)
+	// inventory events are received as raw bytes rather than EdgeX events
+	service, ok := pkg.NewAppServiceWithTargetType(serviceKey, &[]byte{})
 	if !ok {
 		os.Exit(1)
 	}
 
 	lc := service.LoggingClient()
+	// the build is logged first, so that every log shows what was running
+	lc.Info("starting service", routes.CurrentVersion().LogFields()...)
 
 	inventoryEndpoint, err := service.GetAppSetting("InventoryEndpoint")
 	if err != nil {
@@ -41,6 +51,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	// DataDirectory is optional, the relative data file names are resolved
+	// against it. Without it they are relative to the working directory.
+	dataDirectory, err := service.GetAppSetting("DataDirectory")
+	if err != nil {
+		dataDirectory = ""
+	}
+
 	ledgerFileName, err := service.GetAppSetting("LedgerFileName")
 	if err != nil {
 		lc.Errorf("failed load LedgerFileName from ApplicationSettings: %s", err.Error())
@@ -51,19 +68,347 @@ func main() {
 		lc.Error("LedgerFileName configuration setting is empty")
 		os.Exit(1)
 	}
+	ledgerFileName = utilities.DataFileName(dataDirectory, ledgerFileName)
+	if err := utilities.ValidateDataDirectory(filepath.Dir(ledgerFileName)); err != nil {
+		lc.Errorf("LedgerFileName from ApplicationSettings must be in a writable directory: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// StoreName is optional and only used when rendering receipts
+	storeName, err := service.GetAppSetting("StoreName")
+	if err != nil || len(storeName) == 0 {
+		lc.Infof("StoreName is not set in ApplicationSettings, using %s", routes.DefaultStoreName)
+		storeName = routes.DefaultStoreName
+	}
+
+	// TaxRates is optional, without it no tax is added to transactions
+	var taxTable routes.TaxTable
+	taxRates, err := service.GetAppSettingStrings("TaxRates")
+	if err != nil {
+		lc.Info("TaxRates is not set in ApplicationSettings, transactions will not be taxed")
+	} else {
+		taxTable, err = routes.ParseTaxTable(taxRates)
+		if err != nil {
+			lc.Errorf("TaxRates from ApplicationSettings is not valid: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Currency, Currencies and ExchangeRates are optional, without them the
+	// ledger is kept in USD and products must be priced in USD
+	ledgerCurrency, err := service.GetAppSetting("Currency")
+	if err != nil {
+		lc.Infof("Currency is not set in ApplicationSettings, using %s", routes.DefaultCurrencyCode)
+	}
+	currencies, _ := service.GetAppSettingStrings("Currencies")
+	exchangeRates, _ := service.GetAppSettingStrings("ExchangeRates")
+	currency, err := routes.NewCurrencyConverter(ledgerCurrency, currencies, exchangeRates)
+	if err != nil {
+		lc.Errorf("currency settings from ApplicationSettings are not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// PaymentProvider is optional, without it marking a transaction paid
+	// only records the payment status
+	paymentConfig := payment.Config{}
+	paymentConfig.Provider, _ = service.GetAppSetting("PaymentProvider")
+	paymentConfig.Endpoint, _ = service.GetAppSetting("PaymentEndpoint")
+	paymentConfig.APIKey, _ = service.GetAppSetting("PaymentAPIKey")
+	paymentProvider, err := payment.NewProvider(paymentConfig)
+	if err != nil {
+		lc.Errorf("payment settings from ApplicationSettings are not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// PreAuthHoldAmount is optional, it is the amount in the ledger's currency
+	// held on an account's stored payment method before the door is unlocked
+	var holdAmountMinor int64
+	holdAmount, err := service.GetAppSetting("PreAuthHoldAmount")
+	if err == nil && len(holdAmount) > 0 {
+		amount, err := strconv.ParseFloat(holdAmount, 64)
+		if err != nil || amount < 0 {
+			lc.Errorf("PreAuthHoldAmount from ApplicationSettings must be a number that is not negative")
+			os.Exit(1)
+		}
+		holdAmountMinor = currency.Base().ToMinor(amount)
+	}
+	if holdAmountMinor > 0 && paymentProvider == nil {
+		lc.Warn("PreAuthHoldAmount is set without a PaymentProvider, no holds will be placed")
+	}
+
+	// HoldSettlement is optional, by default a hold is settled when its
+	// transaction is marked paid
+	holdSettlement, err := service.GetAppSetting("HoldSettlement")
+	if err != nil || len(holdSettlement) == 0 {
+		holdSettlement = routes.HoldSettlementOnPaid
+	}
+	if holdSettlement != routes.HoldSettlementOnPaid && holdSettlement != routes.HoldSettlementOnCreate {
+		lc.Errorf("HoldSettlement from ApplicationSettings must be %s or %s", routes.HoldSettlementOnPaid, routes.HoldSettlementOnCreate)
+		os.Exit(1)
+	}
+
+	// LedgerEventTopic is optional, without it ledger events are not published
+	eventTopic, err := service.GetAppSetting("LedgerEventTopic")
+	if err != nil || len(eventTopic) == 0 {
+		lc.Info("LedgerEventTopic is not set in ApplicationSettings, ledger events will not be published")
+		eventTopic = ""
+	}
+
+	// WriteDurability and FsyncInterval are optional, by default every write
+	// is flushed to disk before the request completes
+	writeDurability, err := service.GetAppSetting("WriteDurability")
+	if err != nil || len(writeDurability) == 0 {
+		writeDurability = utilities.DurabilityFsyncOnWrite
+	}
+	fileWriter, err := utilities.NewFileWriter(writeDurability)
+	if err != nil {
+		lc.Errorf("WriteDurability from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	fsyncInterval := utilities.DefaultFsyncInterval
+	interval, err := service.GetAppSetting("FsyncInterval")
+	if err == nil && len(interval) > 0 {
+		fsyncInterval, err = time.ParseDuration(interval)
+		if err != nil || fsyncInterval <= 0 {
+			lc.Errorf("FsyncInterval from ApplicationSettings must be a positive duration")
+			os.Exit(1)
+		}
+	}
+	go fileWriter.Run(service.AppContext(), fsyncInterval, lc)
+
+	// RetentionDays is optional, without it transactions are never archived
+	archivePolicy := routes.ArchivePolicy{Directory: filepath.Join(filepath.Dir(ledgerFileName), "ledger-archive")}
+	retentionDays, err := service.GetAppSetting("RetentionDays")
+	if err == nil && len(retentionDays) > 0 {
+		archivePolicy.RetentionDays, err = strconv.Atoi(retentionDays)
+		if err != nil || archivePolicy.RetentionDays < 0 {
+			lc.Errorf("RetentionDays from ApplicationSettings must be a whole number that is not negative")
+			os.Exit(1)
+		}
+	}
+	archiveDirectory, err := service.GetAppSetting("ArchiveDirectory")
+	if err == nil && len(archiveDirectory) > 0 {
+		archivePolicy.Directory = utilities.DataFileName(dataDirectory, archiveDirectory)
+	}
+	archiveInterval := routes.DefaultArchiveInterval
+	interval, err = service.GetAppSetting("ArchiveInterval")
+	if err == nil && len(interval) > 0 {
+		archiveInterval, err = time.ParseDuration(interval)
+		if err != nil || archiveInterval <= 0 {
+			lc.Errorf("ArchiveInterval from ApplicationSettings must be a positive duration")
+			os.Exit(1)
+		}
+	}
+	if archivePolicy.RetentionDays > 0 {
+		if err := utilities.ValidateDataDirectory(archivePolicy.Directory); err != nil {
+			lc.Errorf("ArchiveDirectory from ApplicationSettings must be writable: %s", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// MaxRequestBodySize is optional, larger request bodies are rejected
+	maxBodySize := utilities.DefaultMaxRequestBodySize
+	bodySize, err := service.GetAppSetting("MaxRequestBodySize")
+	if err == nil && len(bodySize) > 0 {
+		maxBodySize, err = strconv.ParseInt(bodySize, 10, 64)
+		if err != nil || maxBodySize <= 0 {
+			lc.Errorf("MaxRequestBodySize from ApplicationSettings must be a positive number of bytes")
+			os.Exit(1)
+		}
+	}
+
+	// ProductCacheTTL is optional, products are cached for the default TTL
+	productCacheTTL := routes.DefaultProductCacheTTL
+	cacheTTL, err := service.GetAppSetting("ProductCacheTTL")
+	if err == nil && len(cacheTTL) > 0 {
+		productCacheTTL, err = time.ParseDuration(cacheTTL)
+		if err != nil || productCacheTTL < 0 {
+			lc.Errorf("ProductCacheTTL from ApplicationSettings must be a duration that is not negative")
+			os.Exit(1)
+		}
+	}
+	productCache := routes.NewProductCache(productCacheTTL)
+
+	// LedgerStorage is optional, by default every account is kept in the
+	// ledger file
+	ledgerStorage, err := service.GetAppSetting("LedgerStorage")
+	if err != nil || len(ledgerStorage) == 0 {
+		ledgerStorage = routes.LedgerStorageSingleFile
+	}
+	if err := routes.ValidateLedgerStorage(ledgerStorage); err != nil {
+		lc.Errorf("LedgerStorage from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// DualControlThreshold is optional, it is the amount in the ledger's
+	// currency an admin may edit or void without a second admin's approval
+	dualControl := routes.DualControl{ApprovalTTL: routes.DefaultApprovalTTL}
+	threshold, err := service.GetAppSetting("DualControlThreshold")
+	if err == nil && len(threshold) > 0 {
+		amount, err := strconv.ParseFloat(threshold, 64)
+		if err != nil || amount < 0 {
+			lc.Errorf("DualControlThreshold from ApplicationSettings must be a number that is not negative")
+			os.Exit(1)
+		}
+		dualControl.ThresholdMinor = currency.Base().ToMinor(amount)
+	}
+	approvalTTL, err := service.GetAppSetting("DualControlApprovalTTL")
+	if err == nil && len(approvalTTL) > 0 {
+		dualControl.ApprovalTTL, err = time.ParseDuration(approvalTTL)
+		if err != nil || dualControl.ApprovalTTL <= 0 {
+			lc.Errorf("DualControlApprovalTTL from ApplicationSettings must be a positive duration")
+			os.Exit(1)
+		}
+	}
+
+	// TimeZone is optional, without it the kiosk's local time is UTC
+	timeZoneName, _ := service.GetAppSetting("TimeZone")
+	timeZone, err := routes.LoadTimeZone(timeZoneName)
+	if err != nil {
+		lc.Errorf("TimeZone from ApplicationSettings is not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	// AuthTokenSecret is optional, with it the administrative routes need
+	// the token of a maintainer card from ms-authentication
+	tokenSecret, _ := service.GetAppSetting("AuthTokenSecret")
+	tokenVerifier := utilities.NewTokenVerifier(tokenSecret)
+	if tokenVerifier == nil {
+		lc.Warn("AuthTokenSecret is not set in ApplicationSettings, the administrative routes are open")
+	}
+
+	// StatementSMTPServer is optional, without it statements are not sent
+	smtpServer, _ := service.GetAppSetting("StatementSMTPServer")
+	smtpUsername, _ := service.GetAppSetting("StatementSMTPUsername")
+	smtpPassword, _ := service.GetAppSetting("StatementSMTPPassword")
+	fromAddress, _ := service.GetAppSetting("StatementFromAddress")
+	mailer, err := routes.NewSMTPMailer(smtpServer, smtpUsername, smtpPassword, fromAddress)
+	if err != nil {
+		lc.Errorf("statement settings from ApplicationSettings are not valid: %s", err.Error())
+		os.Exit(1)
+	}
+	var statementMailer *routes.StatementMailer
+	if mailer != nil {
+		accountsEndpoint, err := service.GetAppSetting("AccountsEndpoint")
+		if err != nil || accountsEndpoint == "" {
+			lc.Errorf("AccountsEndpoint must be set in ApplicationSettings to send statements")
+			os.Exit(1)
+		}
+		sendInterval := routes.DefaultStatementSendInterval
+		interval, err := service.GetAppSetting("StatementSendInterval")
+		if err == nil && len(interval) > 0 {
+			sendInterval, err = time.ParseDuration(interval)
+			if err != nil || sendInterval < 0 {
+				lc.Errorf("StatementSendInterval from ApplicationSettings must be a duration that is not negative")
+				os.Exit(1)
+			}
+		}
+		statementMailer = routes.NewStatementMailer(mailer, accountsEndpoint, sendInterval)
+	}
+
+	// IntentRecoveryAfter and IntentRecoveryInterval are optional, pending
+	// basket intents are charged after the default recovery period
+	intentRecoveryAfter := routes.DefaultIntentRecoveryAfter
+	interval, err = service.GetAppSetting("IntentRecoveryAfter")
+	if err == nil && len(interval) > 0 {
+		intentRecoveryAfter, err = time.ParseDuration(interval)
+		if err != nil || intentRecoveryAfter <= 0 {
+			lc.Errorf("IntentRecoveryAfter from ApplicationSettings must be a positive duration")
+			os.Exit(1)
+		}
+	}
+	intentRecoveryInterval := routes.DefaultIntentRecoveryInterval
+	interval, err = service.GetAppSetting("IntentRecoveryInterval")
+	if err == nil && len(interval) > 0 {
+		intentRecoveryInterval, err = time.ParseDuration(interval)
+		if err != nil || intentRecoveryInterval <= 0 {
+			lc.Errorf("IntentRecoveryInterval from ApplicationSettings must be a positive duration")
+			os.Exit(1)
+		}
+	}
+
+	// PricingMode is optional, in the cashRounded mode the totals of new
+	// transactions are rounded to the smallest cash denomination
+	pricingMode, _ := service.GetAppSetting("PricingMode")
+	roundingIncrement, _ := service.GetAppSetting("CashRoundingIncrement")
+	roundingRule, _ := service.GetAppSetting("CashRoundingRule")
+	cashRounding, err := routes.ParseCashRounding(pricingMode, roundingIncrement, roundingRule, currency.Base())
+	if err != nil {
+		lc.Errorf("pricing settings from ApplicationSettings are not valid: %s", err.Error())
+		os.Exit(1)
+	}
+
+	controller := routes.NewController(lc, service, routes.ControllerConfig{
+		InventoryEndpoint: inventoryEndpoint,
+		LedgerFileName:    ledgerFileName,
+		StoreName:         storeName,
+		TaxTable:          taxTable,
+		Currency:          currency,
+		PaymentProvider:   paymentProvider,
+		HoldAmountMinor:   holdAmountMinor,
+		HoldSettlement:    holdSettlement,
+		EventTopic:        eventTopic,
+		FileWriter:        fileWriter,
+		ArchivePolicy:     archivePolicy,
+		MaxBodySize:       maxBodySize,
+		ProductCache:      productCache,
+		LedgerStorage:     ledgerStorage,
+		DualControl:       dualControl,
+		TimeZone:          timeZone,
+		TokenVerifier:     tokenVerifier,
+		StatementMailer:   statementMailer,
+		CashRounding:      cashRounding,
+	})
+	// a running marker left by the previous run means it crashed, so the
+	// ledger file is recovered before any traffic is served
+	markerName := utilities.MarkerFileName(ledgerFileName, serviceKey)
+	if err := controller.RecoverData(markerName); err != nil {
+		lc.Errorf("failed to recover data files: %s", err.Error())
+		os.Exit(1)
+	}
+	if report := controller.Recovery(); report.CrashDetected {
+		lc.Warnf("previous run did not shut down cleanly, removed %d temporary files and quarantined %d data files", len(report.RemovedTempFiles), len(report.QuarantinedFiles))
+	}
+	// the accounts of an existing ledger file are moved into their own
+	// files the first time the per-account storage is used
+	migrated, err := controller.MigrateLedgers()
+	if err != nil {
+		lc.Errorf("failed to migrate the ledger file to per-account storage: %s", err.Error())
+		os.Exit(1)
+	}
+	if migrated > 0 {
+		lc.Infof("migrated %d accounts from %s to per-account storage in %s", migrated, ledgerFileName, routes.AccountsDirectory(ledgerFileName))
+	}
 
-	controller := routes.NewController(lc, service, inventoryEndpoint, ledgerFileName)
 	err = controller.AddAllRoutes()
 	if err != nil {
 		lc.Errorf("failed to add all Routes: %s", err.Error())
 		os.Exit(1)
 	}
+	go controller.RunArchival(service.AppContext(), archiveInterval)
+	// baskets that as-vending failed to charge are charged or escalated
+	go controller.RunIntentRecovery(service.AppContext(), intentRecoveryInterval, intentRecoveryAfter)
+
+	// the inventory events the service subscribes to invalidate cached products
+	if err := service.SetDefaultFunctionsPipeline(controller.InventoryEventReceived); err != nil {
+		lc.Errorf("failed to set the inventory events pipeline: %s", err.Error())
+		os.Exit(1)
+	}
 
 	if err := service.Run(); err != nil {
 		lc.Errorf("Run returned error: %s", err.Error())
 		os.Exit(1)
 	}
 
+	if err := fileWriter.Sync(); err != nil {
+		lc.Errorf("failed to sync data files: %s", err.Error())
+		os.Exit(1)
+	}
+	if err := utilities.MarkStopped(markerName); err != nil {
+		lc.Errorf("failed to remove running marker: %s", err.Error())
+		os.Exit(1)
+	}
+
 	os.Exit(0)
 
 }
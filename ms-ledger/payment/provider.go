@@ -0,0 +1,99 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package payment
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// ProviderNone disables charging, marking a transaction paid only
+	// records the payment status
+	ProviderNone = "none"
+	// ProviderREST charges through a Stripe-style REST API
+	ProviderREST = "rest"
+
+	ChargeStatusSucceeded = "succeeded"
+	ChargeStatusPending   = "pending"
+	ChargeStatusFailed    = "failed"
+	// ChargeStatusAuthorized is the status of a hold that has been placed
+	// but not yet captured
+	ChargeStatusAuthorized = "authorized"
+)
+
+// Provider is a common interface for payment providers to implement
+type Provider interface {
+	Charge(ChargeRequest) (Charge, error)
+	// Authorize places a hold for the amount on the stored payment method
+	// without charging it
+	Authorize(ChargeRequest) (Charge, error)
+	// Capture charges up to the held amount of an authorization
+	Capture(CaptureRequest) (Charge, error)
+	// Release cancels an authorization so that the hold is lifted
+	Release(authorizationID string) error
+}
+
+// ChargeRequest is a request to charge a stored payment method
+type ChargeRequest struct {
+	// PaymentMethod identifies the stored payment method with the provider,
+	// such as a customer ID
+	PaymentMethod string
+	// AmountMinor is the amount to charge in minor units, such as cents
+	AmountMinor int64
+	// Currency is the ISO 4217 code of the amount
+	Currency    string
+	Description string
+	// IdempotencyKey makes retrying the same charge safe
+	IdempotencyKey string
+}
+
+// CaptureRequest is a request to charge a held authorization
+type CaptureRequest struct {
+	AuthorizationID string
+	// AmountMinor is the amount to capture in minor units, which must not
+	// be more than the held amount
+	AmountMinor    int64
+	IdempotencyKey string
+}
+
+// Charge is the provider's result of a ChargeRequest
+type Charge struct {
+	ID     string
+	Status string
+	// FailureMessage explains why a charge failed, when it did
+	FailureMessage string
+}
+
+// Config holds the settings used to create a Provider
+type Config struct {
+	Provider string
+	Endpoint string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+// NewProvider is used to determine the Provider type from the config and
+// create it. No provider is returned when charging is disabled.
+func NewProvider(config Config) (Provider, error) {
+	switch strings.ToLower(config.Provider) {
+	case "", ProviderNone:
+		return nil, nil
+	case ProviderREST:
+		if len(config.Endpoint) == 0 {
+			return nil, fmt.Errorf("payment endpoint is required for the %s payment provider", ProviderREST)
+		}
+		if _, err := url.ParseRequestURI(config.Endpoint); err != nil {
+			return nil, fmt.Errorf("payment endpoint is not a valid URL: %s", err.Error())
+		}
+		if len(config.APIKey) == 0 {
+			return nil, fmt.Errorf("payment API key is required for the %s payment provider", ProviderREST)
+		}
+		return NewRESTProvider(config.Endpoint, config.APIKey, config.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", config.Provider)
+	}
+}
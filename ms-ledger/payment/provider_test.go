@@ -0,0 +1,41 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Config        Config
+		ExpectedNil   bool
+		ExpectedError bool
+	}{
+		{"no provider", Config{}, true, false},
+		{"none provider", Config{Provider: "None"}, true, false},
+		{"rest provider", Config{Provider: ProviderREST, Endpoint: "https://api.example.com", APIKey: "sk_test"}, false, false},
+		{"rest provider without endpoint", Config{Provider: ProviderREST, APIKey: "sk_test"}, true, true},
+		{"rest provider with invalid endpoint", Config{Provider: ProviderREST, Endpoint: "not a url", APIKey: "sk_test"}, true, true},
+		{"rest provider without API key", Config{Provider: ProviderREST, Endpoint: "https://api.example.com"}, true, true},
+		{"unknown provider", Config{Provider: "cash"}, true, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			provider, err := NewProvider(currentTest.Config)
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, currentTest.ExpectedNil, provider == nil)
+		})
+	}
+}
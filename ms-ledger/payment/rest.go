@@ -0,0 +1,173 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeout is used when no timeout is configured for the REST provider
+const defaultTimeout = 15 * time.Second
+
+// RESTProvider is a reference Provider for Stripe-style REST APIs. Charges
+// are created by a form encoded POST to the /v1/charges endpoint using
+// bearer token authentication.
+type RESTProvider struct {
+	Endpoint string
+	APIKey   string
+	Client   *http.Client
+}
+
+// restCharge is the subset of the charge object returned by the API
+type restCharge struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	FailureMessage string `json:"failure_message"`
+}
+
+// restError is the error object returned by the API
+type restError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewRESTProvider creates a RESTProvider for the given API endpoint
+func NewRESTProvider(endpoint string, apiKey string, timeout time.Duration) *RESTProvider {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &RESTProvider{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		APIKey:   apiKey,
+		Client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Charge charges the stored payment method. A declined charge is returned
+// with a failed status rather than as an error, errors are only returned
+// when the outcome of the charge is unknown.
+func (provider *RESTProvider) Charge(request ChargeRequest) (Charge, error) {
+	return provider.postCharge("/v1/charges", chargeForm(request), request.IdempotencyKey)
+}
+
+// Authorize creates an uncaptured charge, which holds the amount on the
+// stored payment method until it is captured or released
+func (provider *RESTProvider) Authorize(request ChargeRequest) (Charge, error) {
+	form := chargeForm(request)
+	form.Set("capture", "false")
+	charge, err := provider.postCharge("/v1/charges", form, request.IdempotencyKey)
+	if err != nil {
+		return Charge{}, err
+	}
+	// an uncaptured charge succeeds once the hold is placed
+	if charge.Status == ChargeStatusSucceeded {
+		charge.Status = ChargeStatusAuthorized
+	}
+	return charge, nil
+}
+
+// Capture charges the given amount of an uncaptured charge, releasing the
+// rest of the hold
+func (provider *RESTProvider) Capture(request CaptureRequest) (Charge, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(request.AmountMinor, 10))
+	return provider.postCharge("/v1/charges/"+url.PathEscape(request.AuthorizationID)+"/capture", form, request.IdempotencyKey)
+}
+
+// Release refunds an uncaptured charge, which lifts the hold
+func (provider *RESTProvider) Release(authorizationID string) error {
+	form := url.Values{}
+	form.Set("charge", authorizationID)
+	resp, body, err := provider.post("/v1/refunds", form, "release-"+authorizationID)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError("release", resp, body)
+	}
+	return nil
+}
+
+// chargeForm is the form of a ChargeRequest
+func chargeForm(request ChargeRequest) url.Values {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(request.AmountMinor, 10))
+	form.Set("currency", strings.ToLower(request.Currency))
+	form.Set("customer", request.PaymentMethod)
+	if request.Description != "" {
+		form.Set("description", request.Description)
+	}
+	return form
+}
+
+// postCharge posts a request that responds with a charge object
+func (provider *RESTProvider) postCharge(path string, form url.Values, idempotencyKey string) (Charge, error) {
+	resp, body, err := provider.post(path, form, idempotencyKey)
+	if err != nil {
+		return Charge{}, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		var charge restCharge
+		if err := json.Unmarshal(body, &charge); err != nil {
+			return Charge{}, fmt.Errorf("failed to unmarshal charge response: %s", err.Error())
+		}
+		return Charge{ID: charge.ID, Status: charge.Status, FailureMessage: charge.FailureMessage}, nil
+	case resp.StatusCode == http.StatusPaymentRequired:
+		// the card was declined, the response carries the failed charge
+		var charge restCharge
+		_ = json.Unmarshal(body, &charge)
+		var apiError restError
+		_ = json.Unmarshal(body, &apiError)
+		if charge.FailureMessage == "" {
+			charge.FailureMessage = apiError.Error.Message
+		}
+		return Charge{ID: charge.ID, Status: ChargeStatusFailed, FailureMessage: charge.FailureMessage}, nil
+	default:
+		return Charge{}, responseError("charge", resp, body)
+	}
+}
+
+// post sends a form encoded POST request to the API
+func (provider *RESTProvider) post(path string, form url.Values, idempotencyKey string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, provider.Endpoint+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s request: %s", path, err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer "+provider.APIKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := provider.Client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send %s request: %s", path, err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s response: %s", path, err.Error())
+	}
+	return resp, body, nil
+}
+
+// responseError is the error of an unexpected API response
+func responseError(action string, resp *http.Response, body []byte) error {
+	var apiError restError
+	if err := json.Unmarshal(body, &apiError); err == nil && apiError.Error.Message != "" {
+		return fmt.Errorf("%s request failed with status %s: %s", action, resp.Status, apiError.Error.Message)
+	}
+	return fmt.Errorf("%s request failed with status %s", action, resp.Status)
+}
@@ -0,0 +1,145 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package payment
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESTProviderCharge(t *testing.T) {
+	request := ChargeRequest{
+		PaymentMethod:  "cus_1",
+		AmountMinor:    199,
+		Currency:       "USD",
+		Description:    "Automated Checkout transaction 1",
+		IdempotencyKey: "1-1",
+	}
+
+	tests := []struct {
+		Name           string
+		StatusCode     int
+		Body           string
+		ExpectedCharge Charge
+		ExpectedError  bool
+	}{
+		{"successful charge", http.StatusOK, `{"id":"ch_1","status":"succeeded"}`, Charge{ID: "ch_1", Status: ChargeStatusSucceeded}, false},
+		{"pending charge", http.StatusOK, `{"id":"ch_1","status":"pending"}`, Charge{ID: "ch_1", Status: ChargeStatusPending}, false},
+		{"declined charge", http.StatusPaymentRequired, `{"error":{"message":"Your card was declined."}}`, Charge{Status: ChargeStatusFailed, FailureMessage: "Your card was declined."}, false},
+		{"invalid response", http.StatusOK, `not json`, Charge{}, true},
+		{"server error", http.StatusInternalServerError, `{"error":{"message":"internal error"}}`, Charge{}, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/v1/charges", r.URL.Path)
+				assert.Equal(t, "Bearer sk_test", r.Header.Get("Authorization"))
+				assert.Equal(t, "1-1", r.Header.Get("Idempotency-Key"))
+				require.NoError(t, r.ParseForm())
+				assert.Equal(t, "199", r.PostForm.Get("amount"))
+				assert.Equal(t, "usd", r.PostForm.Get("currency"))
+				assert.Equal(t, "cus_1", r.PostForm.Get("customer"))
+				w.WriteHeader(currentTest.StatusCode)
+				_, _ = w.Write([]byte(currentTest.Body))
+			}))
+			defer server.Close()
+
+			provider := NewRESTProvider(server.URL+"/", "sk_test", 0)
+			charge, err := provider.Charge(request)
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedCharge, charge)
+		})
+	}
+}
+
+func TestRESTProviderChargeUnreachable(t *testing.T) {
+	provider := NewRESTProvider("http://127.0.0.1:0", "sk_test", 0)
+	_, err := provider.Charge(ChargeRequest{PaymentMethod: "cus_1", AmountMinor: 199, Currency: "USD"})
+	require.Error(t, err)
+}
+
+func TestRESTProviderAuthorize(t *testing.T) {
+	tests := []struct {
+		Name           string
+		StatusCode     int
+		Body           string
+		ExpectedCharge Charge
+		ExpectedError  bool
+	}{
+		{"hold placed", http.StatusOK, `{"id":"ch_1","status":"succeeded"}`, Charge{ID: "ch_1", Status: ChargeStatusAuthorized}, false},
+		{"hold declined", http.StatusPaymentRequired, `{"error":{"message":"Your card has insufficient funds."}}`, Charge{Status: ChargeStatusFailed, FailureMessage: "Your card has insufficient funds."}, false},
+		{"server error", http.StatusInternalServerError, ``, Charge{}, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/charges", r.URL.Path)
+				require.NoError(t, r.ParseForm())
+				assert.Equal(t, "false", r.PostForm.Get("capture"))
+				assert.Equal(t, "2000", r.PostForm.Get("amount"))
+				w.WriteHeader(currentTest.StatusCode)
+				_, _ = w.Write([]byte(currentTest.Body))
+			}))
+			defer server.Close()
+
+			provider := NewRESTProvider(server.URL, "sk_test", 0)
+			charge, err := provider.Authorize(ChargeRequest{PaymentMethod: "cus_1", AmountMinor: 2000, Currency: "USD"})
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedCharge, charge)
+		})
+	}
+}
+
+func TestRESTProviderCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/charges/ch_1/capture", r.URL.Path)
+		assert.Equal(t, "1-1-capture", r.Header.Get("Idempotency-Key"))
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "455", r.PostForm.Get("amount"))
+		_, _ = w.Write([]byte(`{"id":"ch_1","status":"succeeded"}`))
+	}))
+	defer server.Close()
+
+	provider := NewRESTProvider(server.URL, "sk_test", 0)
+	charge, err := provider.Capture(CaptureRequest{AuthorizationID: "ch_1", AmountMinor: 455, IdempotencyKey: "1-1-capture"})
+	require.NoError(t, err)
+	assert.Equal(t, Charge{ID: "ch_1", Status: ChargeStatusSucceeded}, charge)
+}
+
+func TestRESTProviderRelease(t *testing.T) {
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/refunds", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "ch_1", r.PostForm.Get("charge"))
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(`{"error":{"message":"Charge ch_1 has already been refunded."}}`))
+	}))
+	defer server.Close()
+
+	provider := NewRESTProvider(server.URL, "sk_test", 0)
+	require.NoError(t, provider.Release("ch_1"))
+
+	statusCode = http.StatusBadRequest
+	err := provider.Release("ch_1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already been refunded")
+}
@@ -0,0 +1,102 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// RoleStocker and RoleMaintainer are the ms-authentication roles of the
+	// operators that may override prices and add discounts
+	RoleStocker    = 2
+	RoleMaintainer = 3
+
+	// DiscountReportKey is the sales report group of discounts that do not
+	// apply to a SKU
+	DiscountReportKey = "discounts"
+)
+
+// isOperatorRole checks whether the role may override prices and add
+// discounts
+func isOperatorRole(roleID int) bool {
+	return roleID == RoleStocker || roleID == RoleMaintainer
+}
+
+// hasAdjustments checks whether the request overrides prices or adds
+// discounts
+func (updateLedger deltaLedger) hasAdjustments() bool {
+	return len(updateLedger.PriceOverrides) > 0 || len(updateLedger.Discounts) > 0
+}
+
+// applyAdjustments overrides the prices of the transaction's charged items,
+// keeping their original price, and adds the discount lines. The totals are
+// recalculated, and a transaction may not be discounted below zero.
+func (ledger *Ledger) applyAdjustments(overrides []priceOverride, discounts []discountLine, currency Currency) error {
+	overridden := map[string]bool{}
+	for _, override := range overrides {
+		if overridden[override.SKU] {
+			return fmt.Errorf("SKU %s has more than one price override", override.SKU)
+		}
+		if override.ItemPrice < 0 {
+			return fmt.Errorf("price override for SKU %s must not be negative", override.SKU)
+		}
+		if strings.TrimSpace(override.Reason) == "" {
+			return fmt.Errorf("price override for SKU %s requires a reason", override.SKU)
+		}
+		lineItem := ledger.chargedLineItem(override.SKU)
+		if lineItem == nil {
+			return fmt.Errorf("SKU %s is not charged in the transaction and its price cannot be overridden", override.SKU)
+		}
+		lineItem.PriceOverridden = true
+		lineItem.OriginalItemPriceMinor = lineItem.ItemPriceMinor
+		lineItem.ItemPriceMinor = currency.ToMinor(override.ItemPrice)
+		lineItem.OverrideReason = override.Reason
+		overridden[override.SKU] = true
+	}
+
+	for _, discount := range discounts {
+		if discount.Amount <= 0 {
+			return fmt.Errorf("discount %q must be greater than zero", discount.Description)
+		}
+		discountItem := LineItem{
+			SKU:            discount.SKU,
+			ProductName:    strings.TrimSpace(discount.Description),
+			ItemCount:      1,
+			ItemPriceMinor: -currency.ToMinor(discount.Amount),
+			Discount:       true,
+		}
+		if discountItem.ProductName == "" {
+			discountItem.ProductName = "Discount"
+		}
+		if discount.SKU != "" {
+			lineItem := ledger.chargedLineItem(discount.SKU)
+			if lineItem == nil {
+				return fmt.Errorf("SKU %s is not charged in the transaction and cannot be discounted", discount.SKU)
+			}
+			discountItem.TaxRate = lineItem.TaxRate
+		}
+		ledger.LineItems = append(ledger.LineItems, discountItem)
+	}
+
+	ledger.calculateTotals()
+	if ledger.SubtotalMinor < 0 || ledger.LineTotalMinor < 0 {
+		return fmt.Errorf("discounts exceed the transaction total")
+	}
+	ledger.setAmounts(currency)
+	return nil
+}
+
+// chargedLineItem returns the charged line of the SKU, or nil when the SKU
+// was not charged in the transaction
+func (ledger *Ledger) chargedLineItem(sku string) *LineItem {
+	for i := range ledger.LineItems {
+		lineItem := &ledger.LineItems[i]
+		if lineItem.SKU == sku && !lineItem.Unavailable && !lineItem.Returned && !lineItem.ContainerReturn && !lineItem.Discount && !lineItem.Rounding {
+			return lineItem
+		}
+	}
+	return nil
+}
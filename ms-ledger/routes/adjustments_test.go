@@ -0,0 +1,150 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyAdjustments(t *testing.T) {
+	usd, _ := NewCurrencyConverter("", nil, nil)
+
+	tests := []struct {
+		Name                  string
+		Overrides             []priceOverride
+		Discounts             []discountLine
+		ExpectedError         bool
+		ExpectedSubtotalMinor int64
+		ExpectedTaxMinor      int64
+		ExpectedTotalMinor    int64
+	}{
+		{"No adjustments", nil, nil, false, 398, 32, 430},
+		{"Price override", []priceOverride{{SKU: "A", ItemPrice: 1.50, Reason: "damaged packaging"}}, nil, false, 300, 24, 324},
+		{"Discount for a SKU reduces its tax", nil, []discountLine{{SKU: "A", Description: "Promotion", Amount: 0.50}}, false, 348, 28, 376},
+		{"Discount for the transaction", nil, []discountLine{{Description: "Loyalty", Amount: 1.00}}, false, 298, 32, 330},
+		{"Override and discount", []priceOverride{{SKU: "A", ItemPrice: 1.00, Reason: "correction"}}, []discountLine{{Description: "Loyalty", Amount: 0.50}}, false, 150, 16, 166},
+		{"Duplicate override", []priceOverride{{SKU: "A", ItemPrice: 1.50, Reason: "one"}, {SKU: "A", ItemPrice: 1.00, Reason: "two"}}, nil, true, 0, 0, 0},
+		{"Negative override", []priceOverride{{SKU: "A", ItemPrice: -1, Reason: "correction"}}, nil, true, 0, 0, 0},
+		{"Override without reason", []priceOverride{{SKU: "A", ItemPrice: 1.50}}, nil, true, 0, 0, 0},
+		{"Override of a returned item", []priceOverride{{SKU: "B", ItemPrice: 1.50, Reason: "correction"}}, nil, true, 0, 0, 0},
+		{"Override of an unknown SKU", []priceOverride{{SKU: "C", ItemPrice: 1.50, Reason: "correction"}}, nil, true, 0, 0, 0},
+		{"Zero discount", nil, []discountLine{{Description: "Loyalty", Amount: 0}}, true, 0, 0, 0},
+		{"Discount for an unknown SKU", nil, []discountLine{{SKU: "C", Description: "Promotion", Amount: 0.50}}, true, 0, 0, 0},
+		{"Discount exceeds the total", nil, []discountLine{{Description: "Loyalty", Amount: 5.00}}, true, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			ledger := Ledger{
+				LineItems: []LineItem{
+					{SKU: "A", ProductName: "Product A", ItemCount: 2, ItemPriceMinor: 199, TaxRate: 0.08},
+					{SKU: "B", ProductName: "Product B", ItemCount: 1, ItemPriceMinor: 299, Returned: true},
+				},
+			}
+			ledger.calculateTotals()
+			ledger.setAmounts(usd.Base())
+
+			err := ledger.applyAdjustments(currentTest.Overrides, currentTest.Discounts, usd.Base())
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedSubtotalMinor, ledger.SubtotalMinor)
+			assert.Equal(t, currentTest.ExpectedTaxMinor, ledger.TaxMinor)
+			assert.Equal(t, currentTest.ExpectedTotalMinor, ledger.LineTotalMinor)
+			assert.Len(t, ledger.LineItems, 2+len(currentTest.Discounts))
+
+			if len(currentTest.Overrides) > 0 {
+				overridden := ledger.LineItems[0]
+				assert.True(t, overridden.PriceOverridden)
+				assert.Equal(t, int64(199), overridden.OriginalItemPriceMinor)
+				assert.Equal(t, 1.99, overridden.OriginalItemPrice)
+				assert.Equal(t, currentTest.Overrides[0].ItemPrice, overridden.ItemPrice)
+				assert.Equal(t, currentTest.Overrides[0].Reason, overridden.OverrideReason)
+			}
+			for i, discount := range currentTest.Discounts {
+				discountItem := ledger.LineItems[2+i]
+				assert.True(t, discountItem.Discount)
+				assert.Equal(t, discount.SKU, discountItem.SKU)
+				assert.Equal(t, discount.Description, discountItem.ProductName)
+				assert.Equal(t, 1, discountItem.ItemCount)
+				assert.Equal(t, -discount.Amount, discountItem.ItemPrice)
+			}
+		})
+	}
+}
+
+func TestLedgerAddTransactionAdjustments(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+
+	tests := []struct {
+		Name               string
+		UpdateLedger       string
+		Authorization      string
+		ExpectedStatusCode int
+		ExpectedTotalMinor int64
+	}{
+		{"Maintainer overrides a price", `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}],"priceOverrides":[{"sku":"4900002470","itemPrice":1.00,"reason":"shelf label"}]}`, bearerToken(1, RoleMaintainer), http.StatusOK, 100},
+		{"Stocker adds a discount", `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}],"discounts":[{"description":"Promotion","amount":0.49}]}`, bearerToken(1, RoleStocker), http.StatusOK, 150},
+		{"Customer may not override prices", `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}],"priceOverrides":[{"sku":"4900002470","itemPrice":1.00,"reason":"shelf label"}]}`, bearerToken(1, 1), http.StatusForbidden, 0},
+		{"Role in body", `{"accountId":2,"roleId":3,"deltaSKUs":[{"sku":"4900002470","delta":-1}],"discounts":[{"description":"Promotion","amount":0.49}]}`, bearerToken(1, 1), http.StatusBadRequest, 0},
+		{"Discount without a token", `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}],"discounts":[{"description":"Promotion","amount":0.49}]}`, "", http.StatusUnauthorized, 0},
+		{"Invalid override", `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}],"priceOverrides":[{"sku":"4900002472","itemPrice":1.00,"reason":"shelf label"}]}`, bearerToken(1, RoleMaintainer), http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				service:           nil,
+				inventoryEndpoint: inventoryServer.URL,
+				ledgerFileName:    LedgerFileName,
+				tokenVerifier:     utilities.NewTokenVerifier(testTokenSecret),
+			}
+			data, err := json.Marshal(getDefaultAccountLedgers())
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer([]byte(currentTest.UpdateLedger)))
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			c.LedgerAddTransaction(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			accountLedgers, err := c.GetAllLedgers()
+			require.NoError(t, err)
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				assert.Equal(t, getDefaultAccountLedgers().Data[1].Ledgers, accountLedgers.Data[1].Ledgers, "the ledger should not change")
+				return
+			}
+
+			var newLedger Ledger
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&newLedger))
+			assert.Equal(t, currentTest.ExpectedTotalMinor, newLedger.LineTotalMinor)
+			assert.Equal(t, currentTest.ExpectedTotalMinor, accountLedgers.Data[1].Ledgers[len(accountLedgers.Data[1].Ledgers)-1].LineTotalMinor)
+		})
+	}
+}
@@ -0,0 +1,475 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+const (
+	// APIKeyScopeLedger allows reading the transactions of an account
+	APIKeyScopeLedger = "ledger:read"
+	// APIKeyScopeBalance allows reading the unpaid balance of an account
+	APIKeyScopeBalance = "balance:read"
+	// APIKeyScopeSummary allows reading the account summary
+	APIKeyScopeSummary = "summary:read"
+	// APIKeyScopeReceipts allows reading the receipts of transactions
+	APIKeyScopeReceipts = "receipts:read"
+
+	// DefaultAPIKeyRateLimit is how many requests a minute an API key is
+	// allowed when it is created without a rate limit
+	DefaultAPIKeyRateLimit = 60
+
+	// apiKeyHeader is the request header that consumer apps send their API
+	// key in
+	apiKeyHeader = "X-API-Key"
+	// apiKeyPrefix starts every API key, so that a leaked key is recognized
+	apiKeyPrefix = "ak_"
+)
+
+// apiKeyScopes are the scopes that an API key can be given
+var apiKeyScopes = map[string]bool{
+	APIKeyScopeLedger:   true,
+	APIKeyScopeBalance:  true,
+	APIKeyScopeSummary:  true,
+	APIKeyScopeReceipts: true,
+}
+
+// APIKey is the API key of an integration that calls the consumer routes,
+// with the scopes of the routes it may call, the accounts it may read and
+// how many requests a minute it is allowed. Only the SHA-256 hash of the key is kept, the key itself
+// is returned once when it is created.
+type APIKey struct {
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes"`
+	AccountIDs         []int    `json:"accountIDs"`
+	RateLimitPerMinute int      `json:"rateLimitPerMinute"`
+	Hash               string   `json:"hash,omitempty"`
+	CreatedAt          int64    `json:"createdAt,string"`
+	RevokedAt          int64    `json:"revokedAt,string,omitempty"`
+}
+
+// APIKeys is the API key file
+type APIKeys struct {
+	Data []APIKey `json:"data"`
+}
+
+// apiKeyRequest is the body of a request to create an API key
+type apiKeyRequest struct {
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes"`
+	AccountIDs         []int    `json:"accountIDs"`
+	RateLimitPerMinute int      `json:"rateLimitPerMinute"`
+}
+
+// createdAPIKey is the response to the creation of an API key, the only
+// time that the key is returned
+type createdAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyError is why a request to a consumer route was rejected, with the
+// HTTP status to respond with
+type APIKeyError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is when a rate limited key may call again
+	RetryAfter time.Duration
+}
+
+func (err *APIKeyError) Error() string {
+	return err.Message
+}
+
+// hasScope checks whether the key was given the scope
+func (key APIKey) hasScope(scope string) bool {
+	for _, keyScope := range key.Scopes {
+		if keyScope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAccount checks whether the key is bound to the account
+func (key APIKey) hasAccount(accountID string) bool {
+	for _, keyAccountID := range key.AccountIDs {
+		if strconv.Itoa(keyAccountID) == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyFileName is the file of the API keys, which is kept next to the
+// ledger file
+func APIKeyFileName(ledgerFileName string) string {
+	return strings.TrimSuffix(ledgerFileName, filepath.Ext(ledgerFileName)) + "-apikeys.json"
+}
+
+// apiKeyWindow counts the requests of a key in the current minute
+type apiKeyWindow struct {
+	start    time.Time
+	requests int
+}
+
+// APIKeyStore keeps the API keys in the API key file, and counts the
+// requests of each key against its rate limit. The counts are only kept in
+// memory, so they start over after a restart. A nil APIKeyStore has no
+// keys.
+type APIKeyStore struct {
+	mutex      sync.Mutex
+	fileName   string
+	fileWriter *utilities.FileWriter
+	windows    map[string]*apiKeyWindow
+	now        func() time.Time
+}
+
+// NewAPIKeyStore creates an APIKeyStore of the API key file
+func NewAPIKeyStore(fileName string, fileWriter *utilities.FileWriter) *APIKeyStore {
+	return &APIKeyStore{
+		fileName:   fileName,
+		fileWriter: fileWriter,
+		windows:    make(map[string]*apiKeyWindow),
+		now:        time.Now,
+	}
+}
+
+// load reads the API keys, which are empty until the first key is created.
+// The caller holds the mutex.
+func (store *APIKeyStore) load() (APIKeys, error) {
+	keys := APIKeys{Data: []APIKey{}}
+	data, err := os.ReadFile(store.fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return APIKeys{}, errors.New("failed to read API keys: " + err.Error())
+	}
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return APIKeys{}, errors.New("failed to unmarshal API keys: " + err.Error())
+	}
+	return keys, nil
+}
+
+// save writes the API keys. The caller holds the mutex.
+func (store *APIKeyStore) save(keys APIKeys) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return errors.New("failed to marshal API keys: " + err.Error())
+	}
+	return store.fileWriter.WriteFile(store.fileName, data, 0600)
+}
+
+// Create creates an API key for the integration with the scopes, accounts
+// and rate limit, and returns it with the key, which is not kept
+func (store *APIKeyStore) Create(name string, scopes []string, accountIDs []int, rateLimitPerMinute int) (APIKey, string, error) {
+	if store == nil {
+		return APIKey{}, "", errors.New("API keys are not enabled")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIKey{}, "", errors.New("an API key requires the name of its integration")
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, "", errors.New("an API key requires at least one scope")
+	}
+	for _, scope := range scopes {
+		if !apiKeyScopes[scope] {
+			return APIKey{}, "", fmt.Errorf("unknown API key scope %q", scope)
+		}
+	}
+	if len(accountIDs) == 0 {
+		return APIKey{}, "", errors.New("an API key requires at least one account")
+	}
+	for _, accountID := range accountIDs {
+		if accountID <= 0 {
+			return APIKey{}, "", fmt.Errorf("invalid API key account %d", accountID)
+		}
+	}
+	if rateLimitPerMinute < 0 {
+		return APIKey{}, "", errors.New("the rate limit of an API key must not be negative")
+	}
+	if rateLimitPerMinute == 0 {
+		rateLimitPerMinute = DefaultAPIKeyRateLimit
+	}
+
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 24)
+	if _, err := rand.Read(idBytes); err != nil {
+		return APIKey{}, "", errors.New("failed to generate API key: " + err.Error())
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return APIKey{}, "", errors.New("failed to generate API key: " + err.Error())
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(secretBytes)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	keys, err := store.load()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	key := APIKey{
+		ID:                 hex.EncodeToString(idBytes),
+		Name:               name,
+		Scopes:             scopes,
+		AccountIDs:         accountIDs,
+		RateLimitPerMinute: rateLimitPerMinute,
+		Hash:               hashAPIKey(secret),
+		CreatedAt:          store.now().UnixNano(),
+	}
+	keys.Data = append(keys.Data, key)
+	if err := store.save(keys); err != nil {
+		return APIKey{}, "", err
+	}
+	key.Hash = ""
+	return key, secret, nil
+}
+
+// List returns the API keys without their hashes, revoked keys included
+func (store *APIKeyStore) List() ([]APIKey, error) {
+	if store == nil {
+		return []APIKey{}, nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	keys, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys.Data {
+		keys.Data[i].Hash = ""
+	}
+	return keys.Data, nil
+}
+
+// Revoke revokes the API key with the ID, and returns false when there is
+// no such key. Revoking a revoked key keeps when it was first revoked.
+func (store *APIKeyStore) Revoke(id string) (APIKey, bool, error) {
+	if store == nil {
+		return APIKey{}, false, nil
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	keys, err := store.load()
+	if err != nil {
+		return APIKey{}, false, err
+	}
+	for i := range keys.Data {
+		if keys.Data[i].ID != id {
+			continue
+		}
+		if keys.Data[i].RevokedAt == 0 {
+			keys.Data[i].RevokedAt = store.now().UnixNano()
+			if err := store.save(keys); err != nil {
+				return APIKey{}, false, err
+			}
+			delete(store.windows, id)
+		}
+		key := keys.Data[i]
+		key.Hash = ""
+		return key, true, nil
+	}
+	return APIKey{}, false, nil
+}
+
+// Authenticate returns the API key of the request, and an APIKeyError when
+// the key is missing, unknown or revoked, was not given the scope, is not
+// bound to the account, or has used up its requests of the current minute
+func (store *APIKeyStore) Authenticate(secret string, scope string, accountID string) (APIKey, error) {
+	if secret == "" {
+		return APIKey{}, &APIKeyError{StatusCode: http.StatusUnauthorized, Message: "an API key is required in the " + apiKeyHeader + " header"}
+	}
+	if store == nil {
+		return APIKey{}, &APIKeyError{StatusCode: http.StatusUnauthorized, Message: "the API key is not valid"}
+	}
+	hash := hashAPIKey(secret)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	keys, err := store.load()
+	if err != nil {
+		return APIKey{}, &APIKeyError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+	var key APIKey
+	found := false
+	for _, candidate := range keys.Data {
+		if subtle.ConstantTimeCompare([]byte(candidate.Hash), []byte(hash)) == 1 {
+			key = candidate
+			found = true
+		}
+	}
+	if !found || key.RevokedAt != 0 {
+		return APIKey{}, &APIKeyError{StatusCode: http.StatusUnauthorized, Message: "the API key is not valid"}
+	}
+	if !key.hasScope(scope) {
+		return key, &APIKeyError{StatusCode: http.StatusForbidden, Message: fmt.Sprintf("the API key %s does not have the %s scope", key.ID, scope)}
+	}
+	if !key.hasAccount(accountID) {
+		return key, &APIKeyError{StatusCode: http.StatusForbidden, Message: fmt.Sprintf("the API key %s is not bound to account %s", key.ID, accountID)}
+	}
+
+	now := store.now()
+	window, ok := store.windows[key.ID]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		window = &apiKeyWindow{start: now}
+		store.windows[key.ID] = window
+	}
+	if window.requests >= key.RateLimitPerMinute {
+		return key, &APIKeyError{
+			StatusCode: http.StatusTooManyRequests,
+			Message:    fmt.Sprintf("the API key %s is limited to %d requests a minute", key.ID, key.RateLimitPerMinute),
+			RetryAfter: window.start.Add(time.Minute).Sub(now),
+		}
+	}
+	window.requests++
+	return key, nil
+}
+
+// hashAPIKey returns the hex encoded SHA-256 hash of the key that is kept
+// in place of the key
+func hashAPIKey(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// requireAPIKey wraps a consumer route handler to only serve requests with
+// an API key that has the scope, is bound to the account in the URL and
+// has not used up its rate limit.
+// Preflight requests carry no key and are always served.
+func (c *Controller) requireAPIKey(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			handler(writer, req)
+			return
+		}
+		if _, err := c.apiKeys.Authenticate(req.Header.Get(apiKeyHeader), scope, mux.Vars(req)["accountid"]); err != nil {
+			statusCode := http.StatusInternalServerError
+			var apiKeyErr *APIKeyError
+			if errors.As(err, &apiKeyErr) {
+				statusCode = apiKeyErr.StatusCode
+				if apiKeyErr.RetryAfter > 0 {
+					writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiKeyErr.RetryAfter.Seconds()))))
+				}
+			}
+			c.lc.Infof("Rejected %s %s: %s", req.Method, req.URL.Path, err.Error())
+			writer.WriteHeader(statusCode)
+			writer.Write([]byte(err.Error()))
+			return
+		}
+		handler(writer, req)
+	}
+}
+
+// APIKeyPost creates an API key for an integration, and returns the key,
+// which cannot be looked up again
+func (c *Controller) APIKeyPost(writer http.ResponseWriter, req *http.Request) {
+	var request apiKeyRequest
+	if statusCode, err := c.decodeJSONBody(writer, req, &request); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+
+	key, secret, err := c.apiKeys.Create(request.Name, request.Scopes, request.AccountIDs, request.RateLimitPerMinute)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to create API key: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Created API key %s for %s with scopes %s for accounts %v", key.ID, key.Name, strings.Join(key.Scopes, ", "), key.AccountIDs)
+
+	keyJSON, err := json.Marshal(createdAPIKey{APIKey: key, Key: secret})
+	if err != nil {
+		errMsg := "Failed to marshal API key"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	writer.Write(keyJSON)
+}
+
+// APIKeysGet returns the API keys, without the keys themselves
+func (c *Controller) APIKeysGet(writer http.ResponseWriter, req *http.Request) {
+	keys, err := c.apiKeys.List()
+	if err != nil {
+		errMsg := err.Error()
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	keysJSON, err := json.Marshal(APIKeys{Data: keys})
+	if err != nil {
+		errMsg := "Failed to marshal API keys"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(keysJSON)
+}
+
+// APIKeyDelete revokes the API key in the URL, so that its integration can
+// no longer call the consumer routes
+func (c *Controller) APIKeyDelete(writer http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["keyid"]
+
+	key, found, err := c.apiKeys.Revoke(id)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to revoke API key %s: %s", id, err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if !found {
+		errMsg := fmt.Sprintf("Could not find API key %s", id)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Revoked API key %s of %s", key.ID, key.Name)
+
+	keyJSON, err := json.Marshal(key)
+	if err != nil {
+		errMsg := "Failed to marshal API key"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(keyJSON)
+}
@@ -0,0 +1,202 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyFileName(t *testing.T) {
+	assert.Equal(t, "/tmp/ledger-apikeys.json", APIKeyFileName("/tmp/ledger.json"))
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ledger-apikeys.json")
+	store := NewAPIKeyStore(fileName, nil)
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+
+	key, secret, err := store.Create("partner app", []string{APIKeyScopeBalance}, []int{1}, 2)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, apiKeyPrefix))
+	assert.Empty(t, key.Hash)
+
+	// only the hash of the key is kept
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), secret)
+	assert.Contains(t, string(data), hashAPIKey(secret))
+
+	_, err = store.Authenticate(secret, APIKeyScopeBalance, "1")
+	assert.NoError(t, err)
+	_, err = store.Authenticate(secret, APIKeyScopeLedger, "1")
+	assertAPIKeyError(t, err, http.StatusForbidden)
+	_, err = store.Authenticate(secret, APIKeyScopeBalance, "2")
+	assertAPIKeyError(t, err, http.StatusForbidden)
+	_, err = store.Authenticate(apiKeyPrefix+"unknown", APIKeyScopeBalance, "1")
+	assertAPIKeyError(t, err, http.StatusUnauthorized)
+	_, err = store.Authenticate("", APIKeyScopeBalance, "1")
+	assertAPIKeyError(t, err, http.StatusUnauthorized)
+
+	// the second request of the minute uses up the rate limit
+	_, err = store.Authenticate(secret, APIKeyScopeBalance, "1")
+	assert.NoError(t, err)
+	now = now.Add(20 * time.Second)
+	_, err = store.Authenticate(secret, APIKeyScopeBalance, "1")
+	assertAPIKeyError(t, err, http.StatusTooManyRequests)
+	assert.Equal(t, 40*time.Second, err.(*APIKeyError).RetryAfter)
+	now = now.Add(40 * time.Second)
+	_, err = store.Authenticate(secret, APIKeyScopeBalance, "1")
+	assert.NoError(t, err)
+
+	revoked, found, err := store.Revoke(key.ID)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, now.UnixNano(), revoked.RevokedAt)
+	_, err = store.Authenticate(secret, APIKeyScopeBalance, "1")
+	assertAPIKeyError(t, err, http.StatusUnauthorized)
+	_, found, err = store.Revoke("unknown")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Empty(t, keys[0].Hash)
+	assert.Equal(t, revoked, keys[0])
+}
+
+func TestAPIKeyStoreCreateInvalid(t *testing.T) {
+	store := NewAPIKeyStore(filepath.Join(t.TempDir(), "ledger-apikeys.json"), nil)
+	tests := []struct {
+		Name       string
+		KeyName    string
+		Scopes     []string
+		AccountIDs []int
+		RateLimit  int
+	}{
+		{"no name", " ", []string{APIKeyScopeBalance}, []int{1}, 0},
+		{"no scopes", "partner app", nil, []int{1}, 0},
+		{"unknown scope", "partner app", []string{"ledger:write"}, []int{1}, 0},
+		{"no accounts", "partner app", []string{APIKeyScopeBalance}, nil, 0},
+		{"invalid account", "partner app", []string{APIKeyScopeBalance}, []int{0}, 0},
+		{"negative rate limit", "partner app", []string{APIKeyScopeBalance}, []int{1}, -1},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			_, _, err := store.Create(currentTest.KeyName, currentTest.Scopes, currentTest.AccountIDs, currentTest.RateLimit)
+			assert.Error(t, err)
+		})
+	}
+
+	key, _, err := store.Create("partner app", []string{APIKeyScopeBalance}, []int{1}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAPIKeyRateLimit, key.RateLimitPerMinute)
+}
+
+func assertAPIKeyError(t *testing.T, err error, expectedStatus int) {
+	t.Helper()
+	require.Error(t, err)
+	apiKeyErr, ok := err.(*APIKeyError)
+	require.True(t, ok, err.Error())
+	assert.Equal(t, expectedStatus, apiKeyErr.StatusCode)
+}
+
+func TestAPIKeyRoutes(t *testing.T) {
+	c := Controller{
+		lc:      logger.NewMockClient(),
+		apiKeys: NewAPIKeyStore(filepath.Join(t.TempDir(), "ledger-apikeys.json"), nil),
+	}
+
+	w := httptest.NewRecorder()
+	c.APIKeyPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/admin/apikeys", bytes.NewBufferString(`{"name":"partner app","scopes":["balance:read","summary:read"],"accountIDs":[1,2],"rateLimitPerMinute":30}`)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created createdAPIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Key)
+	assert.Equal(t, "partner app", created.Name)
+	assert.Equal(t, []int{1, 2}, created.AccountIDs)
+	assert.Equal(t, 30, created.RateLimitPerMinute)
+
+	w = httptest.NewRecorder()
+	c.APIKeyPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/admin/apikeys", bytes.NewBufferString(`{"name":"partner app","scopes":["ledger:write"]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	c.APIKeysGet(w, httptest.NewRequest(http.MethodGet, "http://localhost:48093/admin/apikeys", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Key)
+	assert.NotContains(t, w.Body.String(), "hash")
+	var keys APIKeys
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	require.Len(t, keys.Data, 1)
+	assert.Equal(t, created.ID, keys.Data[0].ID)
+
+	w = httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "http://localhost:48093/admin/apikeys/"+created.ID, nil), map[string]string{"keyid": created.ID})
+	c.APIKeyDelete(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var revoked APIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &revoked))
+	assert.NotZero(t, revoked.RevokedAt)
+
+	w = httptest.NewRecorder()
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "http://localhost:48093/admin/apikeys/unknown", nil), map[string]string{"keyid": "unknown"})
+	c.APIKeyDelete(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	store := NewAPIKeyStore(filepath.Join(t.TempDir(), "ledger-apikeys.json"), nil)
+	_, secret, err := store.Create("partner app", []string{APIKeyScopeBalance}, []int{1}, 1)
+	require.NoError(t, err)
+	c := Controller{lc: logger.NewMockClient(), apiKeys: store}
+	handler := c.requireAPIKey(APIKeyScopeBalance, func(writer http.ResponseWriter, req *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		Name           string
+		Method         string
+		Key            string
+		AccountID      string
+		ExpectedStatus int
+	}{
+		{"another account", http.MethodGet, secret, "2", http.StatusForbidden},
+		{"valid key", http.MethodGet, secret, "1", http.StatusOK},
+		{"rate limited", http.MethodGet, secret, "1", http.StatusTooManyRequests},
+		{"no key", http.MethodGet, "", "1", http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, apiKeyPrefix + "unknown", "1", http.StatusUnauthorized},
+		{"preflight", http.MethodOptions, "", "1", http.StatusOK},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest(currentTest.Method, "/consumer/ledger/"+currentTest.AccountID+"/balance", nil), map[string]string{"accountid": currentTest.AccountID})
+			if currentTest.Key != "" {
+				req.Header.Set(apiKeyHeader, currentTest.Key)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, currentTest.ExpectedStatus, w.Code, w.Body.String())
+			if currentTest.ExpectedStatus == http.StatusTooManyRequests {
+				assert.NotEmpty(t, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
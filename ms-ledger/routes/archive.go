@@ -0,0 +1,240 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultArchiveInterval is how often transactions are archived when no
+	// interval is configured
+	DefaultArchiveInterval = 24 * time.Hour
+
+	// archive files are named ledger-YYYY-MM-DD.json after the day of the
+	// transactions they hold
+	archiveFilePrefix = "ledger-"
+	archiveFileSuffix = ".json"
+)
+
+// ArchivePolicy configures when transactions are moved out of the ledger
+// file into dated archive files
+type ArchivePolicy struct {
+	// RetentionDays is how many days transactions are kept in the ledger
+	// file, 0 disables archival
+	RetentionDays int
+	// Directory holds the archive files
+	Directory string
+}
+
+// archiveDates is the list of days that have an archive file
+type archiveDates struct {
+	Dates []string `json:"dates"`
+}
+
+// isArchivable checks whether a transaction may be moved to the archive.
+// Unpaid transactions and transactions with a hold that has not been
+// settled are outstanding, so they stay in the ledger file.
+func (ledger Ledger) isArchivable() bool {
+	if !ledger.IsPaid {
+		return false
+	}
+	return ledger.Hold == nil || ledger.Hold.Status != HoldStatusAuthorized
+}
+
+// archiveFileName is the archive file of the transactions of a day
+func (policy ArchivePolicy) archiveFileName(date string) string {
+	return filepath.Join(policy.Directory, archiveFilePrefix+date+archiveFileSuffix)
+}
+
+// ArchiveLedgers moves the archivable transactions from before the
+// retention period into the archive file of the day they were made, and
+// returns how many were archived. Archive files are written before the
+// transactions are removed from the ledger file, and transactions already
+// in an archive file are not added again, so an interrupted run is safely
+// completed by the next one.
+func (c *Controller) ArchiveLedgers(now time.Time) (int, error) {
+	if c.archivePolicy.RetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := now.AddDate(0, 0, -c.archivePolicy.RetentionDays).UnixNano()
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	accountLedgers, err := c.GetAllLedgers()
+	if err != nil {
+		return 0, err
+	}
+
+	// archived transactions by day and then by account
+	archived := map[string]map[int][]Ledger{}
+	archivedCount := 0
+	var archivedAccountIDs []int
+	for accountIndex, account := range accountLedgers.Data {
+		var kept []Ledger
+		for _, ledger := range account.Ledgers {
+			if ledger.TxTimeStamp >= cutoff || !ledger.isArchivable() {
+				kept = append(kept, ledger)
+				continue
+			}
+			date := time.Unix(0, ledger.TxTimeStamp).UTC().Format(exportDateLayout)
+			if archived[date] == nil {
+				archived[date] = map[int][]Ledger{}
+			}
+			archived[date][account.AccountID] = append(archived[date][account.AccountID], ledger)
+			archivedCount++
+		}
+		if kept == nil {
+			kept = []Ledger{}
+		}
+		if len(kept) < len(account.Ledgers) {
+			archivedAccountIDs = append(archivedAccountIDs, account.AccountID)
+		}
+		accountLedgers.Data[accountIndex].Ledgers = kept
+	}
+	if archivedCount == 0 {
+		return 0, nil
+	}
+
+	if err := os.MkdirAll(c.archivePolicy.Directory, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create archive directory: %s", err.Error())
+	}
+	for date, accounts := range archived {
+		if err := c.appendArchive(date, accounts); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = c.saveLedgers(accountLedgers, archivedAccountIDs...); err != nil {
+		return 0, errors.New("failed to write ledger JSON file: " + err.Error())
+	}
+	return archivedCount, nil
+}
+
+// appendArchive adds the transactions of each account to the archive file
+// of the day
+func (c *Controller) appendArchive(date string, accounts map[int][]Ledger) error {
+	archive, err := c.GetArchive(date)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	accountIDs := make([]int, 0, len(accounts))
+	for accountID := range accounts {
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Ints(accountIDs)
+
+	for _, accountID := range accountIDs {
+		accountIndex := -1
+		for i, account := range archive.Data {
+			if account.AccountID == accountID {
+				accountIndex = i
+				break
+			}
+		}
+		if accountIndex == -1 {
+			archive.Data = append(archive.Data, Account{AccountID: accountID, Ledgers: []Ledger{}})
+			accountIndex = len(archive.Data) - 1
+		}
+
+		existing := map[int64]bool{}
+		for _, ledger := range archive.Data[accountIndex].Ledgers {
+			existing[ledger.TransactionID] = true
+		}
+		for _, ledger := range accounts[accountID] {
+			if !existing[ledger.TransactionID] {
+				archive.Data[accountIndex].Ledgers = append(archive.Data[accountIndex].Ledgers, ledger)
+			}
+		}
+	}
+
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive for %s: %s", date, err.Error())
+	}
+	if err = c.fileWriter.WriteFile(c.archivePolicy.archiveFileName(date), data, 0644); err != nil {
+		return fmt.Errorf("failed to write archive for %s: %s", date, err.Error())
+	}
+	return nil
+}
+
+// GetArchive returns the archived transactions of a day, given in the
+// YYYY-MM-DD format. The error wraps os.ErrNotExist when the day has no
+// archive.
+func (c *Controller) GetArchive(date string) (Accounts, error) {
+	if _, err := time.Parse(exportDateLayout, date); err != nil {
+		return Accounts{}, fmt.Errorf("date %s is not in the YYYY-MM-DD format", date)
+	}
+
+	data, err := os.ReadFile(c.archivePolicy.archiveFileName(date))
+	if err != nil {
+		return Accounts{Data: []Account{}}, fmt.Errorf("failed to load archive for %s: %w", date, err)
+	}
+	var archive Accounts
+	if err = json.Unmarshal(data, &archive); err != nil {
+		return Accounts{}, fmt.Errorf("failed to unmarshal archive for %s: %s", date, err.Error())
+	}
+	return archive, nil
+}
+
+// GetArchiveDates returns the days that have an archive, oldest first
+func (c *Controller) GetArchiveDates() ([]string, error) {
+	dates := []string{}
+	entries, err := os.ReadDir(c.archivePolicy.Directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return dates, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %s", err.Error())
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archiveFilePrefix) || !strings.HasSuffix(name, archiveFileSuffix) {
+			continue
+		}
+		date := strings.TrimSuffix(strings.TrimPrefix(name, archiveFilePrefix), archiveFileSuffix)
+		if _, err := time.Parse(exportDateLayout, date); err == nil {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+	return dates, nil
+}
+
+// RunArchival archives transactions on start and then every interval until
+// the context is cancelled. It returns straight away when archival is
+// disabled.
+func (c *Controller) RunArchival(ctx context.Context, interval time.Duration) {
+	if c.archivePolicy.RetentionDays <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultArchiveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		archived, err := c.ArchiveLedgers(time.Now())
+		if err != nil {
+			c.lc.Errorf("Failed to archive ledgers: %s", err.Error())
+		} else if archived > 0 {
+			c.lc.Infof("Archived %d transactions older than %d days to %s", archived, c.archivePolicy.RetentionDays, c.archivePolicy.Directory)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveNow is a time after the 2020 transaction of account 1 and before
+// the 2051 transaction of account 2
+var archiveNow = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func newArchiveTestController(t *testing.T, retentionDays int, accountLedgers Accounts) Controller {
+	dir := t.TempDir()
+	c := Controller{
+		lc:             logger.NewMockClient(),
+		ledgerFileName: filepath.Join(dir, LedgerFileName),
+		archivePolicy:  ArchivePolicy{RetentionDays: retentionDays, Directory: filepath.Join(dir, "ledger-archive")},
+	}
+	data, err := json.Marshal(accountLedgers)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.ledgerFileName, data, 0644))
+	return c
+}
+
+func TestArchiveLedgers(t *testing.T) {
+	paidLedgers := getDefaultAccountLedgers()
+	paidLedgers.Data[0].Ledgers[0].IsPaid = true
+	paidLedgers.Data[1].Ledgers[0].IsPaid = true
+
+	heldLedgers := getDefaultAccountLedgers()
+	heldLedgers.Data[0].Ledgers[0].IsPaid = true
+	heldLedgers.Data[0].Ledgers[0].Hold = &Hold{AuthorizationID: "ch_1", Status: HoldStatusAuthorized}
+
+	tests := []struct {
+		Name             string
+		RetentionDays    int
+		AccountLedgers   Accounts
+		ExpectedArchived int
+	}{
+		{"Archives paid transactions", 30, paidLedgers, 1},
+		{"Keeps unpaid transactions", 30, getDefaultAccountLedgers(), 0},
+		{"Keeps transactions with an authorized hold", 30, heldLedgers, 0},
+		{"Keeps transactions in the retention period", 365 * 10, paidLedgers, 0},
+		{"Disabled", 0, paidLedgers, 0},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newArchiveTestController(t, currentTest.RetentionDays, currentTest.AccountLedgers)
+
+			archived, err := c.ArchiveLedgers(archiveNow)
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedArchived, archived)
+
+			accountLedgers, err := c.GetAllLedgers()
+			require.NoError(t, err)
+			assert.Len(t, accountLedgers.Data[0].Ledgers, 1-currentTest.ExpectedArchived)
+			assert.Len(t, accountLedgers.Data[1].Ledgers, 1, "transactions in the retention period should be kept")
+
+			dates, err := c.GetArchiveDates()
+			require.NoError(t, err)
+			if currentTest.ExpectedArchived == 0 {
+				assert.Empty(t, dates)
+				return
+			}
+			assert.Equal(t, []string{"2020-01-16"}, dates)
+			archive, err := c.GetArchive("2020-01-16")
+			require.NoError(t, err)
+			require.Len(t, archive.Data, 1)
+			assert.Equal(t, 1, archive.Data[0].AccountID)
+			assert.Equal(t, currentTest.AccountLedgers.Data[0].Ledgers, archive.Data[0].Ledgers)
+		})
+	}
+}
+
+// TestArchiveLedgersWaitsForWriters tests that the archiver takes the lock
+// of the ledger read-modify-writes, so that it does not write back ledgers
+// a route handler changed while it archived
+func TestArchiveLedgersWaitsForWriters(t *testing.T) {
+	paidLedgers := getDefaultAccountLedgers()
+	paidLedgers.Data[0].Ledgers[0].IsPaid = true
+	c := newArchiveTestController(t, 30, paidLedgers)
+
+	ledgerMutex.Lock()
+	done := make(chan int)
+	go func() {
+		archived, err := c.ArchiveLedgers(archiveNow)
+		assert.NoError(t, err)
+		done <- archived
+	}()
+	select {
+	case <-done:
+		t.Fatal("the archiver should wait for the ledger lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	ledgerMutex.Unlock()
+	assert.Equal(t, 1, <-done)
+}
+
+func TestArchiveLedgersInterrupted(t *testing.T) {
+	accountLedgers := getDefaultAccountLedgers()
+	accountLedgers.Data[0].Ledgers[0].IsPaid = true
+	c := newArchiveTestController(t, 30, accountLedgers)
+
+	// an earlier run wrote the archive but not the ledger file
+	require.NoError(t, os.MkdirAll(c.archivePolicy.Directory, 0755))
+	data, err := json.Marshal(Accounts{Data: []Account{{AccountID: 1, Ledgers: accountLedgers.Data[0].Ledgers}}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.archivePolicy.archiveFileName("2020-01-16"), data, 0644))
+
+	archived, err := c.ArchiveLedgers(archiveNow)
+	require.NoError(t, err)
+	assert.Equal(t, 1, archived)
+
+	archive, err := c.GetArchive("2020-01-16")
+	require.NoError(t, err)
+	require.Len(t, archive.Data, 1)
+	assert.Len(t, archive.Data[0].Ledgers, 1, "transactions should not be archived twice")
+}
+
+func TestLedgerArchiveGet(t *testing.T) {
+	accountLedgers := getDefaultAccountLedgers()
+	accountLedgers.Data[0].Ledgers[0].IsPaid = true
+	c := newArchiveTestController(t, 30, accountLedgers)
+	_, err := c.ArchiveLedgers(archiveNow)
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name               string
+		Query              string
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{"List dates", "", http.StatusOK, `{"dates":["2020-01-16"]}`},
+		{"Archived date", "?date=2020-01-16", http.StatusOK, ""},
+		{"Date without archive", "?date=2020-01-17", http.StatusNotFound, "No transactions archived for 2020-01-17"},
+		{"Invalid date", "?date=16-01-2020", http.StatusBadRequest, "Invalid archive date 16-01-2020, expected YYYY-MM-DD"},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:48093/ledger/archive"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.LedgerArchiveGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedBody != "" {
+				assert.Equal(t, currentTest.ExpectedBody, w.Body.String())
+				return
+			}
+			var archive Accounts
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&archive))
+			require.Len(t, archive.Data, 1)
+			assert.Equal(t, accountLedgers.Data[0].Ledgers[0].TransactionID, archive.Data[0].Ledgers[0].TransactionID)
+		})
+	}
+}
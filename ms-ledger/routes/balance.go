@@ -0,0 +1,43 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"strings"
+)
+
+// AccountBalance is the running unpaid balance of an account, in the
+// ledger's currency, less any partial payments. Unpaid refunds and
+// container returns are netted against the balance, so it can be negative.
+type AccountBalance struct {
+	AccountID          int     `json:"accountID"`
+	Currency           string  `json:"currency"`
+	UnpaidBalance      float64 `json:"unpaidBalance"`
+	UnpaidBalanceMinor int64   `json:"unpaidBalanceMinor"`
+	UnpaidTransactions int     `json:"unpaidTransactions"`
+	// ExcludedTransactions counts the unpaid transactions recorded in a
+	// currency other than the ledger's, which are not in the balance
+	ExcludedTransactions int `json:"excludedTransactions"`
+}
+
+// accountBalance sums the unpaid transactions of the account
+func accountBalance(account Account, currency CurrencyConverter) AccountBalance {
+	base := currency.Base()
+	balance := AccountBalance{AccountID: account.AccountID, Currency: base.Code}
+	for _, ledger := range account.Ledgers {
+		// voided transactions are not charged
+		if ledger.IsPaid || ledger.IsVoided {
+			continue
+		}
+		if ledger.Currency != "" && !strings.EqualFold(ledger.Currency, base.Code) {
+			balance.ExcludedTransactions++
+			continue
+		}
+		// partial payments already made are not part of the balance
+		balance.UnpaidBalanceMinor = balance.UnpaidBalanceMinor + ledger.amountDueMinor(base)
+		balance.UnpaidTransactions++
+	}
+	balance.UnpaidBalance = base.FromMinor(balance.UnpaidBalanceMinor)
+	return balance
+}
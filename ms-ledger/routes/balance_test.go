@@ -0,0 +1,111 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountBalance(t *testing.T) {
+	ledgers := reportLedgers()
+	unpaidRefund := Ledger{TransactionID: 4, Currency: "USD", LineTotalMinor: -199, RefundOf: 2}
+	euroLedger := Ledger{TransactionID: 5, Currency: "EUR", LineTotalMinor: 500}
+	legacyLedger := Ledger{TransactionID: 6, LineTotal: 2.99}
+	partlyPaidLedger := Ledger{TransactionID: 7, Currency: "USD", LineTotalMinor: 500, Payments: []Payment{{Amount: 2, AmountMinor: 200, Method: "cash"}}}
+
+	tests := []struct {
+		Name              string
+		Account           Account
+		ExpectedMinor     int64
+		ExpectedUnpaid    int
+		ExpectedExcluded  int
+		ExpectedFormatted float64
+	}{
+		{"Paid and unpaid", Account{AccountID: 1, Ledgers: ledgers[1]}, 199, 1, 0, 1.99},
+		{"Only paid", Account{AccountID: 2, Ledgers: ledgers[2]}, 0, 0, 0, 0},
+		{"Refund nets the balance", Account{AccountID: 1, Ledgers: append(ledgers[1], unpaidRefund)}, 0, 2, 0, 0},
+		{"Other currency excluded", Account{AccountID: 1, Ledgers: []Ledger{euroLedger}}, 0, 0, 1, 0},
+		{"Legacy float amounts", Account{AccountID: 1, Ledgers: []Ledger{legacyLedger}}, 299, 1, 0, 2.99},
+		{"Partial payments", Account{AccountID: 1, Ledgers: []Ledger{partlyPaidLedger}}, 300, 1, 0, 3},
+		{"No transactions", Account{AccountID: 7}, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			balance := accountBalance(currentTest.Account, CurrencyConverter{})
+			assert.Equal(t, currentTest.Account.AccountID, balance.AccountID)
+			assert.Equal(t, "USD", balance.Currency)
+			assert.Equal(t, currentTest.ExpectedMinor, balance.UnpaidBalanceMinor)
+			assert.Equal(t, currentTest.ExpectedFormatted, balance.UnpaidBalance)
+			assert.Equal(t, currentTest.ExpectedUnpaid, balance.UnpaidTransactions)
+			assert.Equal(t, currentTest.ExpectedExcluded, balance.ExcludedTransactions)
+		})
+	}
+}
+
+func TestLedgerBalanceGet(t *testing.T) {
+	tests := []struct {
+		Name               string
+		InvalidLedger      bool
+		AccountID          string
+		ExpectedStatusCode int
+		ExpectedMinor      int64
+	}{
+		{"Account with unpaid transactions", false, "2", http.StatusOK, 299},
+		{"Account not in ledger", false, "9", http.StatusOK, 0},
+		{"Invalid account ID", false, "abc", http.StatusBadRequest, 0},
+		{"Invalid Ledger", true, "1", http.StatusInternalServerError, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:             logger.NewMockClient(),
+				service:        nil,
+				ledgerFileName: LedgerFileName,
+			}
+			var err error
+			if currentTest.InvalidLedger {
+				err = os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644)
+			} else {
+				data, err := json.Marshal(getDefaultAccountLedgers())
+				require.NoError(t, err)
+				err = os.WriteFile(c.ledgerFileName, data, 0644)
+				require.NoError(t, err)
+			}
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("GET", "http://localhost:48093/ledger/"+currentTest.AccountID+"/balance", nil)
+			req = mux.SetURLVars(req, map[string]string{"accountid": currentTest.AccountID})
+			w := httptest.NewRecorder()
+			c.LedgerBalanceGet(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var balance AccountBalance
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&balance))
+			assert.Equal(t, currentTest.AccountID, strconv.Itoa(balance.AccountID))
+			assert.Equal(t, currentTest.ExpectedMinor, balance.UnpaidBalanceMinor)
+		})
+	}
+}
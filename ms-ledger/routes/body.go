@@ -0,0 +1,16 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+// decodeJSONBody decodes the request's JSON body into v, rejecting bodies
+// over the configured size
+func (c *Controller) decodeJSONBody(writer http.ResponseWriter, req *http.Request, v interface{}) (int, error) {
+	return utilities.DecodeJSONBody(writer, req, c.maxBodySize, v)
+}
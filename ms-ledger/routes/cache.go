@@ -0,0 +1,213 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
+)
+
+const (
+	// DefaultProductCacheTTL is how long products looked up in inventory
+	// are cached by default
+	DefaultProductCacheTTL = 30 * time.Second
+
+	// inventoryEventStockUpdated is the inventory event published when only
+	// the stock of products changes, which the ledger does not use
+	inventoryEventStockUpdated = "StockUpdated"
+)
+
+type cachedProduct struct {
+	product   Product
+	expiresAt time.Time
+}
+
+// ProductCache caches the products looked up in inventory for a TTL, so that
+// creating a transaction does not look up every product again. Products are
+// invalidated early by inventory events. A nil ProductCache does not cache
+// anything.
+type ProductCache struct {
+	mutex    sync.Mutex
+	ttl      time.Duration
+	products map[string]cachedProduct
+	now      func() time.Time
+}
+
+// inventoryEvent is the inventory service's event about changed products.
+// An event without SKUs is about every product.
+type inventoryEvent struct {
+	EventType string   `json:"eventType"`
+	SKUs      []string `json:"skus"`
+}
+
+// inventorySKUBatch is the inventory service's batch lookup request
+type inventorySKUBatch struct {
+	SKUs []string `json:"skus"`
+}
+
+// inventoryProducts is the inventory service's response to a lookup of
+// several products
+type inventoryProducts struct {
+	Data []Product `json:"data"`
+}
+
+// NewProductCache creates a ProductCache for the TTL, or nil when the TTL
+// is not positive so that products are not cached
+func NewProductCache(ttl time.Duration) *ProductCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &ProductCache{
+		ttl:      ttl,
+		products: make(map[string]cachedProduct),
+		now:      time.Now,
+	}
+}
+
+// Get returns the cached product for the SKU, if it has not expired
+func (cache *ProductCache) Get(sku string) (Product, bool) {
+	if cache == nil {
+		return Product{}, false
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cached, ok := cache.products[sku]
+	if !ok {
+		return Product{}, false
+	}
+	if !cache.now().Before(cached.expiresAt) {
+		delete(cache.products, sku)
+		return Product{}, false
+	}
+	return cached.product, true
+}
+
+// Set caches the products for the TTL
+func (cache *ProductCache) Set(products ...Product) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	expiresAt := cache.now().Add(cache.ttl)
+	for _, product := range products {
+		cache.products[product.SKU] = cachedProduct{product: product, expiresAt: expiresAt}
+	}
+}
+
+// Invalidate removes the SKUs from the cache, or every product when no SKUs
+// are given
+func (cache *ProductCache) Invalidate(skus ...string) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if len(skus) == 0 {
+		cache.products = make(map[string]cachedProduct)
+		return
+	}
+	for _, sku := range skus {
+		delete(cache.products, sku)
+	}
+}
+
+// getInventoryItems looks up the products of the SKUs, from the cache when
+// they are cached and otherwise from inventory in a single request. SKUs
+// that are not in inventory are missing from the returned products.
+func (c *Controller) getInventoryItems(inventoryEndpoint string, skus []string) (map[string]Product, error) {
+	products := make(map[string]Product)
+	var missing []string
+	for _, sku := range skus {
+		if product, ok := c.productCache.Get(sku); ok {
+			products[sku] = product
+			continue
+		}
+		missing = append(missing, sku)
+	}
+	if len(missing) == 0 {
+		return products, nil
+	}
+
+	resp, err := c.lookupInventoryItems(inventoryEndpoint, missing)
+	if err != nil {
+		return nil, fmt.Errorf("Could not hit inventoryEndpoint: %v", err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read response body from InventoryEndpoint")
+	}
+	var inventoryItems inventoryProducts
+	if err = json.Unmarshal(body, &inventoryItems); err != nil {
+		return nil, fmt.Errorf("Received an invalid data structure from InventoryEndpoint")
+	}
+
+	// older inventory services return every product, so only the
+	// requested ones are kept
+	wanted := make(map[string]bool)
+	for _, sku := range missing {
+		wanted[sku] = true
+	}
+	for _, product := range inventoryItems.Data {
+		if !wanted[product.SKU] {
+			continue
+		}
+		products[product.SKU] = product
+		c.productCache.Set(product)
+	}
+	return products, nil
+}
+
+// lookupInventoryItems requests the products of the SKUs from the inventory
+// batch endpoint, falling back to the skus query parameter of inventory
+// services without it
+func (c *Controller) lookupInventoryItems(inventoryEndpoint string, skus []string) (*http.Response, error) {
+	batchBytes, err := json.Marshal(inventorySKUBatch{SKUs: skus})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.sendCommand(http.MethodPost, inventoryEndpoint+"/batch", batchBytes)
+	if err == nil {
+		return resp, nil
+	}
+	c.lc.Debugf("Inventory batch lookup failed, looking up the SKUs in the query instead: %s", err.Error())
+	return c.sendCommand(http.MethodGet, inventoryEndpoint+"?skus="+url.QueryEscape(strings.Join(skus, ",")), []byte(""))
+}
+
+// InventoryEventReceived is the functions pipeline for inventory events. It
+// invalidates the cached products that were updated or deleted.
+func (c *Controller) InventoryEventReceived(ctx interfaces.AppFunctionContext, data interface{}) (bool, interface{}) {
+	eventBytes, ok := data.([]byte)
+	if !ok {
+		return false, fmt.Errorf("inventory event is %T rather than bytes", data)
+	}
+	var event inventoryEvent
+	if err := json.Unmarshal(eventBytes, &event); err != nil {
+		return false, fmt.Errorf("failed to unmarshal inventory event: %s", err.Error())
+	}
+	if event.EventType == inventoryEventStockUpdated {
+		return false, nil
+	}
+
+	c.productCache.Invalidate(event.SKUs...)
+	if len(event.SKUs) == 0 {
+		c.lc.Debugf("Invalidated all cached products for %s event", event.EventType)
+	} else {
+		c.lc.Debugf("Invalidated cached products %v for %s event", event.SKUs, event.EventType)
+	}
+	return false, nil
+}
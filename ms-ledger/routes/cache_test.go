@@ -0,0 +1,186 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewProductCache(time.Minute)
+	require.NotNil(t, cache)
+	cache.now = func() time.Time { return now }
+
+	product := getDefaultProduct()
+	depositProduct := getDepositProduct()
+	cache.Set(product, depositProduct)
+
+	cached, ok := cache.Get(product.SKU)
+	require.True(t, ok)
+	assert.Equal(t, product, cached)
+	_, ok = cache.Get("0000000000")
+	assert.False(t, ok, "products that were not looked up should not be cached")
+
+	cache.Invalidate(product.SKU)
+	_, ok = cache.Get(product.SKU)
+	assert.False(t, ok, "invalidated products should not be cached")
+	_, ok = cache.Get(depositProduct.SKU)
+	assert.True(t, ok, "other products should stay cached")
+
+	cache.Set(product)
+	cache.Invalidate()
+	_, ok = cache.Get(product.SKU)
+	assert.False(t, ok, "invalidating without SKUs should invalidate every product")
+	_, ok = cache.Get(depositProduct.SKU)
+	assert.False(t, ok, "invalidating without SKUs should invalidate every product")
+
+	cache.Set(product)
+	now = now.Add(time.Minute)
+	_, ok = cache.Get(product.SKU)
+	assert.False(t, ok, "products should expire after the TTL")
+}
+
+func TestProductCacheDisabled(t *testing.T) {
+	cache := NewProductCache(0)
+	assert.Nil(t, cache)
+
+	cache.Set(getDefaultProduct())
+	_, ok := cache.Get(getDefaultProduct().SKU)
+	assert.False(t, ok)
+	cache.Invalidate()
+}
+
+func TestGetInventoryItems(t *testing.T) {
+	product := getDefaultProduct()
+	depositProduct := getDepositProduct()
+
+	var requests int32
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// an older inventory service returns every product
+		jsonProducts, _ := json.Marshal(inventoryProducts{Data: []Product{product, depositProduct, getUnavailableProduct()}})
+		_, _ = w.Write(jsonProducts)
+	}))
+	defer inventoryServer.Close()
+
+	tests := []struct {
+		Name             string
+		Cache            *ProductCache
+		ExpectedRequests int32
+	}{
+		{"Cached", NewProductCache(time.Minute), 1},
+		{"Not cached", nil, 2},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			c := Controller{
+				lc:           logger.NewMockClient(),
+				productCache: currentTest.Cache,
+			}
+
+			skus := []string{product.SKU, depositProduct.SKU, "0000000000"}
+			products, err := c.getInventoryItems(inventoryServer.URL, skus)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]Product{product.SKU: product, depositProduct.SKU: depositProduct}, products)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "every product should be looked up in one request")
+
+			products, err = c.getInventoryItems(inventoryServer.URL, skus[:2])
+			require.NoError(t, err)
+			assert.Len(t, products, 2)
+			assert.Equal(t, currentTest.ExpectedRequests, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestGetInventoryItemsFallback(t *testing.T) {
+	product := getDefaultProduct()
+
+	var methods []string
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		// an older inventory service without the batch endpoint
+		if r.URL.Path == "/batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, product.SKU, r.URL.Query().Get("skus"))
+		jsonProducts, _ := json.Marshal(inventoryProducts{Data: []Product{product}})
+		_, _ = w.Write(jsonProducts)
+	}))
+	defer inventoryServer.Close()
+
+	c := Controller{lc: logger.NewMockClient()}
+	products, err := c.getInventoryItems(inventoryServer.URL, []string{product.SKU})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Product{product.SKU: product}, products)
+	assert.Equal(t, []string{http.MethodPost, http.MethodGet}, methods)
+}
+
+func TestGetInventoryItemsError(t *testing.T) {
+	c := Controller{
+		lc:           logger.NewMockClient(),
+		productCache: NewProductCache(time.Minute),
+	}
+	_, err := c.getInventoryItems("http://localhost:1", []string{getDefaultProduct().SKU})
+	assert.Error(t, err)
+}
+
+func TestInventoryEventReceived(t *testing.T) {
+	product := getDefaultProduct()
+	depositProduct := getDepositProduct()
+
+	tests := []struct {
+		Name           string
+		Data           interface{}
+		ExpectedError  bool
+		ExpectedCached []string
+	}{
+		{"Product updated", []byte(`{"eventType":"ProductUpdated","skus":["` + product.SKU + `"]}`), false, []string{depositProduct.SKU}},
+		{"Product deleted", []byte(`{"eventType":"ProductDeleted","skus":["` + depositProduct.SKU + `"]}`), false, []string{product.SKU}},
+		{"All products deleted", []byte(`{"eventType":"ProductDeleted","skus":[]}`), false, []string{}},
+		{"Stock updated", []byte(`{"eventType":"StockUpdated","skus":["` + product.SKU + `"]}`), false, []string{product.SKU, depositProduct.SKU}},
+		{"Invalid event", []byte(`{"eventType":`), true, []string{product.SKU, depositProduct.SKU}},
+		{"Not bytes", "event", true, []string{product.SKU, depositProduct.SKU}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:           logger.NewMockClient(),
+				productCache: NewProductCache(time.Minute),
+			}
+			c.productCache.Set(product, depositProduct)
+
+			continuePipeline, result := c.InventoryEventReceived(nil, currentTest.Data)
+			assert.False(t, continuePipeline)
+			if currentTest.ExpectedError {
+				assert.Error(t, result.(error))
+			} else {
+				assert.Nil(t, result)
+			}
+
+			cached := []string{}
+			for _, sku := range []string{product.SKU, depositProduct.SKU} {
+				if _, ok := c.productCache.Get(sku); ok {
+					cached = append(cached, sku)
+				}
+			}
+			assert.Equal(t, currentTest.ExpectedCached, cached)
+		})
+	}
+}
@@ -8,17 +8,43 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
 const (
 	connectionTimeout = 15
+	// maxInventoryErrorLength bounds the reason of a failed inventory request
+	// that is kept in its error
+	maxInventoryErrorLength = 512
+	// availabilityTimeLayout is the layout of AvailabilityWindow start and end times
+	availabilityTimeLayout = "15:04"
+
+	HoldStatusAuthorized = "authorized"
+	HoldStatusCaptured   = "captured"
+	HoldStatusReleased   = "released"
+
+	// HoldSettlementOnPaid settles a transaction's hold when the transaction
+	// is marked paid, and HoldSettlementOnCreate as soon as the transaction
+	// of the basket is created
+	HoldSettlementOnPaid   = "onPaid"
+	HoldSettlementOnCreate = "onCreate"
+
+	// refunded items are returned to inventory as a correction, attributed
+	// to this service
+	inventoryDeltaReasonCorrection = "correction"
+	inventoryDeltaService          = "ms-ledger"
 )
 
 // GetAllLedgers is a common function to get all ledgers for all accounts
 func (c *Controller) GetAllLedgers() (Accounts, error) {
+	if c.perAccount() {
+		return c.getAllAccountLedgers()
+	}
+
 	var accountLedgers Accounts
 
 	data, err := os.ReadFile(c.ledgerFileName)
@@ -32,13 +58,12 @@ func (c *Controller) GetAllLedgers() (Accounts, error) {
 	return accountLedgers, nil
 }
 
-// DeleteAllLedgers will reset the content of the inventory JSON file
+// DeleteAllLedgers will reset the content of the inventory JSON file, or
+// remove every account file in the per-account storage
 func (c *Controller) DeleteAllLedgers() error {
-	data, err := json.Marshal(Accounts{Data: []Account{}})
-	if err != nil {
-		return errors.New("failed to marshal ledger JSON file for delete: " + err.Error())
-	}
-	if err = os.WriteFile(c.ledgerFileName, data, 0644); err != nil {
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	if err := c.saveLedgers(Accounts{Data: []Account{}}); err != nil {
 		return errors.New("failed to write ledger JSON file for delete: " + err.Error())
 	}
 
@@ -61,8 +86,116 @@ func (c *Controller) sendCommand(method string, commandURL string, inputBytes []
 
 	// Check the status code and return any errors
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, fmt.Errorf("error sending request: Received status code %v", resp.Status)
 	}
 
 	return resp, nil
 }
+
+// postInventory posts the body to the inventory service, and returns an
+// error with the inventory service's reason unless it responds with 200 OK,
+// such as a 409 for a delta that takes more units than are on hand or a 304
+// for SKUs that are not in inventory
+func (c *Controller) postInventory(commandURL string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, commandURL, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err.Error())
+	}
+	client := &http.Client{
+		Timeout: time.Duration(connectionTimeout) * time.Second,
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending data: %v", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, maxInventoryErrorLength))
+		if len(bytes.TrimSpace(reason)) == 0 {
+			return fmt.Errorf("inventory service responded with status %v", resp.Status)
+		}
+		return fmt.Errorf("inventory service responded with status %v: %s", resp.Status, bytes.TrimSpace(reason))
+	}
+	return nil
+}
+
+// netDeltaSKUs sums the deltas of each SKU, keeping the order in which the
+// SKUs first appear
+func netDeltaSKUs(deltaSKUs []deltaSKU) []deltaSKU {
+	var netDeltas []deltaSKU
+	index := map[string]int{}
+	for _, delta := range deltaSKUs {
+		if i, ok := index[delta.SKU]; ok {
+			netDeltas[i].Delta += delta.Delta
+			continue
+		}
+		index[delta.SKU] = len(netDeltas)
+		netDeltas = append(netDeltas, delta)
+	}
+	return netDeltas
+}
+
+// IsAvailableAt reports whether the product may be vended at the given time
+func (product Product) IsAvailableAt(t time.Time) bool {
+	if len(product.Availability) == 0 {
+		return true
+	}
+	for _, window := range product.Availability {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether the time falls within the window. Invalid windows
+// never contain any time.
+func (window AvailabilityWindow) Contains(t time.Time) bool {
+	start, err := time.Parse(availabilityTimeLayout, window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(availabilityTimeLayout, window.End)
+	if err != nil {
+		return false
+	}
+
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+	minutes := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	switch {
+	case startMinutes <= endMinutes:
+		if minutes < startMinutes || minutes >= endMinutes {
+			return false
+		}
+	case minutes >= startMinutes:
+		// the window spans midnight and started today
+	case minutes < endMinutes:
+		// the window spans midnight and started yesterday
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, d := range window.Days {
+		if strings.EqualFold(d, day.String()) || strings.EqualFold(d, day.String()[:3]) {
+			return true
+		}
+	}
+	return false
+}
+
+// takeHold removes the account's pending hold so it can be attached to a
+// transaction
+func (account *Account) takeHold() *Hold {
+	hold := account.Hold
+	account.Hold = nil
+	return hold
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 
@@ -124,3 +125,49 @@ func TestDeleteAllLedgers(t *testing.T) {
 	// Check that deleted Ledger has no ledger data
 	require.Equal(updatedLedger, expectedLedger, "Ledger should have no data")
 }
+
+func TestIsAvailableAt(t *testing.T) {
+	// 2023-01-02 is a Monday
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2023, 1, 2, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		Name         string
+		Availability []AvailabilityWindow
+		At           time.Time
+		Available    bool
+	}{
+		{"No windows", nil, monday(3, 0), true},
+		{"Inside window", []AvailabilityWindow{{Start: "06:00", End: "11:00"}}, monday(10, 59), true},
+		{"Outside window", []AvailabilityWindow{{Start: "06:00", End: "11:00"}}, monday(11, 0), false},
+		{"Overnight window", []AvailabilityWindow{{Start: "22:00", End: "02:00", Days: []string{"Sun"}}}, monday(1, 0), true},
+		{"Other day", []AvailabilityWindow{{Start: "06:00", End: "11:00", Days: []string{"Saturday"}}}, monday(7, 0), false},
+		{"Invalid window", []AvailabilityWindow{{Start: "6am", End: "11:00"}}, monday(7, 0), false},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			product := Product{SKU: "4900002470", Availability: currentTest.Availability}
+			require.Equal(t, currentTest.Available, product.IsAvailableAt(currentTest.At))
+		})
+	}
+}
+
+func TestNetDeltaSKUs(t *testing.T) {
+	deltaSKUs := []deltaSKU{
+		{SKU: "4900002470", Delta: -2},
+		{SKU: "4900002472", Delta: 1},
+		{SKU: "4900002470", Delta: 1},
+		{SKU: "4900002471", Delta: -1},
+		{SKU: "4900002471", Delta: 1},
+	}
+	expected := []deltaSKU{
+		{SKU: "4900002470", Delta: -1},
+		{SKU: "4900002472", Delta: 1},
+		{SKU: "4900002471", Delta: 0},
+	}
+	require.Equal(t, expected, netDeltaSKUs(deltaSKUs))
+	require.Nil(t, netDeltaSKUs(nil))
+}
@@ -5,9 +5,12 @@ package routes
 
 import (
 	"fmt"
+	"ms-ledger/payment"
+	"time"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 type Controller struct {
@@ -15,14 +18,111 @@ type Controller struct {
 	service           interfaces.ApplicationService
 	inventoryEndpoint string
 	ledgerFileName    string
+	storeName         string
+	taxTable          TaxTable
+	currency          CurrencyConverter
+	paymentProvider   payment.Provider
+	holdAmountMinor   int64
+	// holdSettlement is when the holds of transactions are settled, when
+	// they are marked paid when it is empty
+	holdSettlement string
+	eventTopic     string
+	fileWriter     *utilities.FileWriter
+	archivePolicy  ArchivePolicy
+	// maxBodySize is the largest request body accepted, in bytes
+	maxBodySize int64
+	// recovery is the crash recovery done on start
+	recovery utilities.RecoveryReport
+	// productCache caches the products looked up in inventory, nil
+	// disables caching
+	productCache *ProductCache
+	// ledgerStorage is how the ledgers of the accounts are stored, the
+	// ledger file is used when it is empty
+	ledgerStorage string
+	// dualControl is the policy for admin overrides of transactions, and
+	// approvals are the dual-control approvals issued for them
+	dualControl DualControl
+	approvals   *ApprovalStore
+	// timeZone is the kiosk's time zone, UTC when it is nil
+	timeZone *time.Location
+	// tokenVerifier checks the tokens of the administrative routes, nil
+	// leaves them open
+	tokenVerifier *utilities.TokenVerifier
+	// statementMailer emails the monthly statements, nil when no SMTP
+	// server is configured
+	statementMailer *StatementMailer
+	// cashRounding rounds the totals of new transactions in the cash
+	// rounded pricing mode, the zero value does not round
+	cashRounding CashRounding
+	// apiKeys are the API keys of the integrations that call the consumer
+	// routes
+	apiKeys *APIKeyStore
 }
 
-func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, inventoryEndpoint string, ledgerFileName string) Controller {
+// ControllerConfig is the configuration of the ledger's Controller. Only
+// LedgerFileName is required, and the zero value of every other field
+// disables the feature or uses its default.
+type ControllerConfig struct {
+	InventoryEndpoint string
+	LedgerFileName    string
+	StoreName         string
+	TaxTable          TaxTable
+	Currency          CurrencyConverter
+	PaymentProvider   payment.Provider
+	HoldAmountMinor   int64
+	// HoldSettlement is when the holds of transactions are settled, when
+	// they are marked paid when it is empty
+	HoldSettlement string
+	EventTopic     string
+	FileWriter     *utilities.FileWriter
+	ArchivePolicy  ArchivePolicy
+	// MaxBodySize is the largest request body accepted, in bytes
+	MaxBodySize int64
+	// ProductCache caches the products looked up in inventory, nil
+	// disables caching
+	ProductCache *ProductCache
+	// LedgerStorage is how the ledgers of the accounts are stored, the
+	// ledger file is used when it is empty
+	LedgerStorage string
+	DualControl   DualControl
+	// TimeZone is the kiosk's time zone, UTC when it is nil
+	TimeZone *time.Location
+	// TokenVerifier checks the tokens of the administrative routes, nil
+	// leaves them open
+	TokenVerifier *utilities.TokenVerifier
+	// StatementMailer emails the monthly statements, nil when no SMTP
+	// server is configured
+	StatementMailer *StatementMailer
+	// CashRounding rounds the totals of new transactions in the cash
+	// rounded pricing mode, the zero value does not round
+	CashRounding CashRounding
+}
+
+func NewController(lc logger.LoggingClient, service interfaces.ApplicationService, config ControllerConfig) Controller {
 	return Controller{
 		lc:                lc,
 		service:           service,
-		inventoryEndpoint: inventoryEndpoint,
-		ledgerFileName:    ledgerFileName,
+		inventoryEndpoint: config.InventoryEndpoint,
+		ledgerFileName:    config.LedgerFileName,
+		storeName:         config.StoreName,
+		taxTable:          config.TaxTable,
+		currency:          config.Currency,
+		paymentProvider:   config.PaymentProvider,
+		holdAmountMinor:   config.HoldAmountMinor,
+		holdSettlement:    config.HoldSettlement,
+		eventTopic:        config.EventTopic,
+		fileWriter:        config.FileWriter,
+		archivePolicy:     config.ArchivePolicy,
+		maxBodySize:       config.MaxBodySize,
+		productCache:      config.ProductCache,
+		ledgerStorage:     config.LedgerStorage,
+		dualControl:       config.DualControl,
+		approvals:         NewApprovalStore(config.DualControl.ApprovalTTL),
+		timeZone:          config.TimeZone,
+		tokenVerifier:     config.TokenVerifier,
+		statementMailer:   config.StatementMailer,
+		cashRounding:      config.CashRounding,
+		apiKeys:           NewAPIKeyStore(APIKeyFileName(config.LedgerFileName), config.FileWriter),
 	}
 }
 
@@ -34,7 +134,99 @@ func (c *Controller) AddAllRoutes() error {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/ledger/{accountid}", c.LedgerAccountGet, "OPTIONS", "GET")
+	// registered before /ledger/{accountid} so that "export" is not treated
+	// as an account ID
+	err = c.service.AddRoute("/ledger/export", c.LedgerExportGet, "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/health", c.HealthGet, "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/version", utilities.VersionHandler(CurrentVersion()), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/reports/sales", c.SalesReportGet, "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// registered before /ledger/{accountid} so that "archive" is not treated
+	// as an account ID
+	err = c.service.AddRoute("/ledger/archive", c.LedgerArchiveGet, "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// registered before /ledger/{accountid} so that "audit" is not treated
+	// as an account ID
+	err = c.service.AddRoute("/ledger/audit", c.LedgerAuditGet, "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// registered before /ledger/{accountid} so that "review" is not treated
+	// as an account ID
+	err = c.service.AddRoute("/ledger/review", c.requireMaintainer(c.LedgerReviewGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// registered before /ledger/{accountid} so that "split" is not treated
+	// as an account ID
+	err = c.service.AddRoute("/ledger/split", c.LedgerSplitTransaction, "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// registered before /ledger/{accountid} so that "intents" is not
+	// treated as an account ID
+	err = c.service.AddRoute("/ledger/intents", c.BasketIntentPost, "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/intents", c.requireMaintainer(c.BasketIntentsGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/intents/{sessionid}", c.requireMaintainer(c.BasketIntentDelete), "DELETE", "OPTIONS")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/admin/statements/send", c.requireMaintainer(c.StatementsSendPost), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/admin/statements/jobs/{jobid}", c.requireMaintainer(c.StatementJobGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/admin/apikeys", c.requireMaintainer(c.APIKeyPost), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/admin/apikeys", c.requireMaintainer(c.APIKeysGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/admin/apikeys/{keyid}", c.requireMaintainer(c.APIKeyDelete), "DELETE", "OPTIONS")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}", c.requireAccount(c.LedgerAccountGet), "OPTIONS", "GET")
 	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
@@ -44,12 +236,101 @@ func (c *Controller) AddAllRoutes() error {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/ledgerPaymentUpdate", c.SetPaymentStatus, "OPTIONS", "POST")
+	err = c.service.AddRoute("/ledgerPaymentUpdate", c.requireMaintainer(c.SetPaymentStatus), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// registered before /ledger/{accountid}/{tid} so that "returns",
+	// "preauth" and "balance" are not treated as transaction IDs
+	err = c.service.AddRoute("/ledger/{accountid}/balance", c.requireAccount(c.LedgerBalanceGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/accounts/{accountid}/summary", c.requireAccount(c.AccountSummaryGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/returns", c.LedgerContainerReturn, "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/preauth", c.LedgerPreAuthorize, "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/preauth", c.LedgerReleaseHold, "DELETE", "OPTIONS")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}", c.requireMaintainer(c.LedgerDelete), "DELETE", "OPTIONS")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}", c.requireMaintainer(c.LedgerOverride), "OPTIONS", "PUT")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}/approval", c.requireMaintainer(c.LedgerApprovalPost), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}/review/approve", c.requireMaintainer(c.LedgerReviewApprove), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}/review/adjust", c.requireMaintainer(c.LedgerReviewAdjust), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}/review/void", c.requireMaintainer(c.LedgerReviewVoid), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}/refund", c.requireMaintainer(c.LedgerRefund), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}/payments", c.requireMaintainer(c.LedgerAddPayment), "OPTIONS", "POST")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/ledger/{accountid}/{tid}/receipt", c.requireAccount(c.LedgerReceiptGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	// the consumer routes are the self-service routes that external
+	// consumer apps call with an API key
+	err = c.service.AddRoute("/consumer/ledger/{accountid}", c.requireAPIKey(APIKeyScopeLedger, c.LedgerAccountGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/consumer/ledger/{accountid}/balance", c.requireAPIKey(APIKeyScopeBalance, c.LedgerBalanceGet), "OPTIONS", "GET")
+	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
+		return errWithMsg
+	}
+
+	err = c.service.AddRoute("/consumer/accounts/{accountid}/summary", c.requireAPIKey(APIKeyScopeSummary, c.AccountSummaryGet), "OPTIONS", "GET")
 	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
 
-	err = c.service.AddRoute("/ledger/{accountid}/{tid}", c.LedgerDelete, "DELETE", "OPTIONS")
+	err = c.service.AddRoute("/consumer/ledger/{accountid}/{tid}/receipt", c.requireAPIKey(APIKeyScopeReceipts, c.LedgerReceiptGet), "OPTIONS", "GET")
 	if errWithMsg := errorAddRouteHandler(err); errWithMsg != nil {
 		return errWithMsg
 	}
@@ -0,0 +1,199 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrencyCode is the ledger currency when no Currency is configured
+const DefaultCurrencyCode = "USD"
+
+// Currency describes how amounts in an ISO 4217 currency are stored and
+// displayed. Exponent is the number of minor unit digits, such as 2 for
+// cents or 0 for yen.
+type Currency struct {
+	Code     string
+	Exponent int
+	Symbol   string
+}
+
+// defaultCurrencies are available even when no Currencies are configured
+var defaultCurrencies = []Currency{
+	{Code: "USD", Exponent: 2, Symbol: "$"},
+	{Code: "EUR", Exponent: 2, Symbol: "€"},
+	{Code: "GBP", Exponent: 2, Symbol: "£"},
+	{Code: "JPY", Exponent: 0, Symbol: "¥"},
+}
+
+// ToMinor converts an amount in major units, such as dollars, to an
+// integer number of minor units, such as cents
+func (currency Currency) ToMinor(amount float64) int64 {
+	return int64(math.Round(amount * math.Pow10(currency.Exponent)))
+}
+
+// FromMinor converts an integer number of minor units to major units
+func (currency Currency) FromMinor(minor int64) float64 {
+	return float64(minor) / math.Pow10(currency.Exponent)
+}
+
+// Format renders an amount in minor units with the currency's symbol,
+// falling back to the currency code when it has no symbol
+func (currency Currency) Format(minor int64) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	amount := strconv.FormatFloat(currency.FromMinor(minor), 'f', currency.Exponent, 64)
+	if currency.Symbol == "" {
+		return sign + amount + " " + currency.Code
+	}
+	return sign + currency.Symbol + amount
+}
+
+// CurrencyConverter converts product prices into the ledger's currency.
+// The zero value uses the default currencies with a USD ledger.
+type CurrencyConverter struct {
+	base       Currency
+	currencies map[string]Currency
+	// rates is the number of base currency units per unit of each currency
+	rates map[string]float64
+}
+
+// NewCurrencyConverter creates a converter for a ledger kept in the base
+// currency. Currency entries use the "code:exponent:symbol" format, such
+// as "EUR:2:€", and extend or override the default currencies. Rate
+// entries use the "code:rate" format, such as "EUR:1.08", where rate is
+// the number of base currency units per unit of that currency.
+func NewCurrencyConverter(base string, currencyEntries []string, rateEntries []string) (CurrencyConverter, error) {
+	converter := CurrencyConverter{
+		currencies: map[string]Currency{},
+		rates:      map[string]float64{},
+	}
+	for _, currency := range defaultCurrencies {
+		converter.currencies[currency.Code] = currency
+	}
+
+	for _, entry := range currencyEntries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return CurrencyConverter{}, fmt.Errorf("currency %q is not in the code:exponent:symbol format", entry)
+		}
+		code := strings.ToUpper(strings.TrimSpace(fields[0]))
+		if len(code) != 3 {
+			return CurrencyConverter{}, fmt.Errorf("currency code %q must be 3 letters", code)
+		}
+		exponent, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil || exponent < 0 || exponent > 4 {
+			return CurrencyConverter{}, fmt.Errorf("currency %s exponent must be a number between 0 and 4", code)
+		}
+		currency := Currency{Code: code, Exponent: exponent}
+		if len(fields) == 3 {
+			currency.Symbol = strings.TrimSpace(fields[2])
+		}
+		converter.currencies[code] = currency
+	}
+
+	base = strings.ToUpper(strings.TrimSpace(base))
+	if base == "" {
+		base = DefaultCurrencyCode
+	}
+	baseCurrency, ok := converter.currencies[base]
+	if !ok {
+		return CurrencyConverter{}, fmt.Errorf("ledger currency %s is not a known currency", base)
+	}
+	converter.base = baseCurrency
+
+	for _, entry := range rateEntries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		code, rateStr, found := strings.Cut(entry, ":")
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if !found {
+			return CurrencyConverter{}, fmt.Errorf("exchange rate %q is not in the code:rate format", entry)
+		}
+		if _, ok := converter.currencies[code]; !ok {
+			return CurrencyConverter{}, fmt.Errorf("exchange rate currency %s is not a known currency", code)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil || rate <= 0 {
+			return CurrencyConverter{}, fmt.Errorf("exchange rate for %s must be a number greater than 0", code)
+		}
+		converter.rates[code] = rate
+	}
+
+	return converter, nil
+}
+
+// Base returns the ledger's currency
+func (converter CurrencyConverter) Base() Currency {
+	if converter.base.Code == "" {
+		return defaultCurrencies[0]
+	}
+	return converter.base
+}
+
+// Lookup returns the currency for the given code, where an empty code
+// is the ledger's currency
+func (converter CurrencyConverter) Lookup(code string) (Currency, bool) {
+	code = strings.ToUpper(code)
+	if code == "" || code == converter.Base().Code {
+		return converter.Base(), true
+	}
+	if converter.currencies == nil {
+		for _, currency := range defaultCurrencies {
+			if currency.Code == code {
+				return currency, true
+			}
+		}
+		return Currency{}, false
+	}
+	currency, ok := converter.currencies[code]
+	return currency, ok
+}
+
+// ToBaseMinor converts an amount in major units of the given currency to
+// minor units of the ledger's currency. Products without a currency are
+// priced in the ledger's currency.
+func (converter CurrencyConverter) ToBaseMinor(amount float64, code string) (int64, error) {
+	base := converter.Base()
+	code = strings.ToUpper(code)
+	if code == "" || code == base.Code {
+		return base.ToMinor(amount), nil
+	}
+	if _, ok := converter.Lookup(code); !ok {
+		return 0, fmt.Errorf("currency %s is not a known currency", code)
+	}
+	rate, ok := converter.rates[code]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate from %s to %s", code, base.Code)
+	}
+	return base.ToMinor(amount * rate), nil
+}
+
+// setAmounts derives the ledger's float amounts and DisplayTotal from its
+// minor unit amounts
+func (ledger *Ledger) setAmounts(currency Currency) {
+	ledger.Currency = currency.Code
+	ledger.LineTotal = currency.FromMinor(ledger.LineTotalMinor)
+	ledger.Subtotal = currency.FromMinor(ledger.SubtotalMinor)
+	ledger.Tax = currency.FromMinor(ledger.TaxMinor)
+	ledger.DisplayTotal = currency.Format(ledger.LineTotalMinor)
+	for i := range ledger.LineItems {
+		ledger.LineItems[i].ItemPrice = currency.FromMinor(ledger.LineItems[i].ItemPriceMinor)
+		ledger.LineItems[i].Deposit = currency.FromMinor(ledger.LineItems[i].DepositMinor)
+		ledger.LineItems[i].Tax = currency.FromMinor(ledger.LineItems[i].TaxMinor)
+		ledger.LineItems[i].OriginalItemPrice = currency.FromMinor(ledger.LineItems[i].OriginalItemPriceMinor)
+	}
+}
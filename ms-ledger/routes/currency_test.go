@@ -0,0 +1,84 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyFormat(t *testing.T) {
+	usd := Currency{Code: "USD", Exponent: 2, Symbol: "$"}
+	assert.Equal(t, int64(199), usd.ToMinor(1.99))
+	assert.Equal(t, 1.99, usd.FromMinor(199))
+	assert.Equal(t, "$1.99", usd.Format(199))
+	assert.Equal(t, "-$0.05", usd.Format(-5))
+
+	jpy := Currency{Code: "JPY", Exponent: 0, Symbol: "¥"}
+	assert.Equal(t, int64(150), jpy.ToMinor(150))
+	assert.Equal(t, "¥150", jpy.Format(150))
+
+	chf := Currency{Code: "CHF", Exponent: 2}
+	assert.Equal(t, "2.50 CHF", chf.Format(250))
+}
+
+func TestNewCurrencyConverter(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Base          string
+		Currencies    []string
+		Rates         []string
+		ExpectedBase  Currency
+		ExpectedError bool
+	}{
+		{"default", "", nil, nil, Currency{Code: "USD", Exponent: 2, Symbol: "$"}, false},
+		{"built in currency", "eur", nil, []string{"USD:0.92"}, Currency{Code: "EUR", Exponent: 2, Symbol: "€"}, false},
+		{"configured currency", "CHF", []string{"CHF:2"}, []string{" EUR : 0.95"}, Currency{Code: "CHF", Exponent: 2}, false},
+		{"empty entries are skipped", "USD", []string{""}, []string{""}, Currency{Code: "USD", Exponent: 2, Symbol: "$"}, false},
+		{"unknown base", "CHF", nil, nil, Currency{}, true},
+		{"bad currency format", "USD", []string{"CHF"}, nil, Currency{}, true},
+		{"bad currency code", "USD", []string{"SWISS:2"}, nil, Currency{}, true},
+		{"bad exponent", "USD", []string{"CHF:two"}, nil, Currency{}, true},
+		{"bad rate format", "USD", nil, []string{"EUR"}, Currency{}, true},
+		{"unknown rate currency", "USD", nil, []string{"CHF:1.1"}, Currency{}, true},
+		{"non-positive rate", "USD", nil, []string{"EUR:0"}, Currency{}, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			converter, err := NewCurrencyConverter(currentTest.Base, currentTest.Currencies, currentTest.Rates)
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedBase, converter.Base())
+		})
+	}
+}
+
+func TestCurrencyConverterToBaseMinor(t *testing.T) {
+	converter, err := NewCurrencyConverter("EUR", nil, []string{"USD:0.92"})
+	require.NoError(t, err)
+
+	minor, err := converter.ToBaseMinor(1.99, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(199), minor, "products without a currency are priced in the ledger currency")
+
+	minor, err = converter.ToBaseMinor(1.99, "usd")
+	require.NoError(t, err)
+	assert.Equal(t, int64(183), minor)
+
+	_, err = converter.ToBaseMinor(150, "JPY")
+	assert.Error(t, err, "currencies without an exchange rate cannot be converted")
+
+	_, err = converter.ToBaseMinor(1, "XYZ")
+	assert.Error(t, err)
+
+	_, ok := CurrencyConverter{}.Lookup("GBP")
+	assert.True(t, ok, "the zero value should know the default currencies")
+}
@@ -4,10 +4,8 @@
 package routes
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 
 	"github.com/gorilla/mux"
@@ -15,17 +13,6 @@ import (
 
 // LedgerDelete will delete a specific ledger for an account
 func (c *Controller) LedgerDelete(writer http.ResponseWriter, req *http.Request) {
-
-	//Get all ledgers for all accounts
-	accountLedgers, err := c.GetAllLedgers()
-	if err != nil {
-		errMsg := fmt.Sprintf("failed to retrieve all ledgers for accounts: %v", err.Error())
-		c.lc.Error(errMsg)
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte(errMsg))
-		return
-	}
-
 	// Get variables from HTTP request
 	vars := mux.Vars(req)
 	tidstr := vars["tid"]
@@ -48,6 +35,18 @@ func (c *Controller) LedgerDelete(writer http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to retrieve all ledgers for accounts: %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
 	//Iterate through accounts
 	if tid > 0 && accountID >= 0 {
 		for accountIndex, account := range accountLedgers.Data {
@@ -56,16 +55,7 @@ func (c *Controller) LedgerDelete(writer http.ResponseWriter, req *http.Request)
 					if tid == ledger.TransactionID {
 						accountLedgers.Data[accountIndex].Ledgers = append(account.Ledgers[:ledgerIndex], account.Ledgers[ledgerIndex+1:]...)
 
-						data, err := json.Marshal(accountLedgers)
-						if err != nil {
-							errMsg := "marshal failed for update ledger with deleted transaction"
-							c.lc.Errorf("%s: %s", errMsg, err.Error())
-							writer.WriteHeader(http.StatusInternalServerError)
-							writer.Write([]byte(errMsg))
-							return
-						}
-
-						if err = os.WriteFile(c.ledgerFileName, data, 0644); err != nil {
+						if err = c.saveLedgers(accountLedgers, accountID); err != nil {
 							errMsg := "write failed for update ledger with deleted transaction"
 							c.lc.Errorf("%s: %s", errMsg, err.Error())
 							writer.WriteHeader(http.StatusInternalServerError)
@@ -92,3 +82,70 @@ func (c *Controller) LedgerDelete(writer http.ResponseWriter, req *http.Request)
 		return
 	}
 }
+
+// LedgerReleaseHold releases the account's pending pre-authorization, such
+// as when the door was unlocked for the account but never opened
+func (c *Controller) LedgerReleaseHold(writer http.ResponseWriter, req *http.Request) {
+	accountID, err := strconv.Atoi(mux.Vars(req)["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to retrieve all ledgers for accounts: %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	for accountIndex, account := range accountLedgers.Data {
+		if accountID != account.AccountID {
+			continue
+		}
+		if account.Hold == nil {
+			c.lc.Infof("Account %d has no hold to release", accountID)
+			writer.Write([]byte("No hold to release for account " + strconv.Itoa(accountID)))
+			return
+		}
+		if c.paymentProvider == nil {
+			errMsg := fmt.Sprintf("Cannot release hold %v without a payment provider", account.Hold.AuthorizationID)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		if err := c.paymentProvider.Release(account.Hold.AuthorizationID); err != nil {
+			errMsg := fmt.Sprintf("Failed to release hold %v: %v", account.Hold.AuthorizationID, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadGateway)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		hold := accountLedgers.Data[accountIndex].takeHold()
+
+		if err = c.saveLedgers(accountLedgers, accountID); err != nil {
+			errMsg := "write failed for update ledger with released hold"
+			c.lc.Errorf("%s: %s", errMsg, err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		c.lc.Infof("Released hold %s for account %d", hold.AuthorizationID, accountID)
+		writer.Write([]byte("Released hold " + hold.AuthorizationID))
+		return
+	}
+
+	errMsg := fmt.Sprintf("Could not find account %v", strconv.Itoa(accountID))
+	c.lc.Error(errMsg)
+	writer.WriteHeader(http.StatusBadRequest)
+	writer.Write([]byte(errMsg))
+}
@@ -6,6 +6,8 @@ package routes
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	paymentMocks "ms-ledger/payment/mocks"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -108,3 +110,60 @@ func TestLedgerDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestLedgerReleaseHold(t *testing.T) {
+	tests := []struct {
+		Name               string
+		AccountID          string
+		Hold               *Hold
+		ReleaseError       error
+		ExpectedStatusCode int
+		ExpectedHold       bool
+	}{
+		{"Hold released", "1", &Hold{AuthorizationID: "ch_hold", Status: HoldStatusAuthorized}, nil, http.StatusOK, false},
+		{"No hold", "1", nil, nil, http.StatusOK, false},
+		{"Provider error", "1", &Hold{AuthorizationID: "ch_hold", Status: HoldStatusAuthorized}, errors.New("connection refused"), http.StatusBadGateway, true},
+		{"Unknown account", "10", nil, nil, http.StatusBadRequest, false},
+		{"Invalid account", "a", nil, nil, http.StatusBadRequest, false},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockProvider := &paymentMocks.Provider{}
+			mockProvider.On("Release", "ch_hold").Return(currentTest.ReleaseError)
+
+			c := Controller{
+				lc:              logger.NewMockClient(),
+				service:         nil,
+				ledgerFileName:  LedgerFileName,
+				paymentProvider: mockProvider,
+			}
+			accountLedgers := getDefaultAccountLedgers()
+			accountLedgers.Data[0].Hold = currentTest.Hold
+			data, err := json.Marshal(accountLedgers)
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("DELETE", "http://localhost:48093/ledger/"+currentTest.AccountID+"/preauth", nil)
+			req = mux.SetURLVars(req, map[string]string{"accountid": currentTest.AccountID})
+			w := httptest.NewRecorder()
+			c.LedgerReleaseHold(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			accountLedgers, err = c.GetAllLedgers()
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.ExpectedHold, accountLedgers.Data[0].Hold != nil)
+			if currentTest.Hold == nil {
+				mockProvider.AssertNotCalled(t, "Release", mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+)
+
+const (
+	// LedgerEventCreated is published when a transaction is added to an
+	// account, including refund, container return and split transactions
+	LedgerEventCreated = "TransactionCreated"
+	// LedgerEventPaid is published when a transaction is marked as paid
+	LedgerEventPaid = "TransactionPaid"
+	// LedgerEventEdited and LedgerEventVoided are published when an admin
+	// edits or voids a transaction
+	LedgerEventEdited = "TransactionEdited"
+	LedgerEventVoided = "TransactionVoided"
+	// LedgerEventReviewed is published when an admin approves a transaction
+	// pending review as it is
+	LedgerEventReviewed = "TransactionReviewed"
+)
+
+// LedgerEvent is published to the EdgeX message bus so that downstream
+// services can consume checkout events without polling the REST API
+type LedgerEvent struct {
+	EventType   string `json:"eventType"`
+	AccountID   int    `json:"accountID"`
+	Transaction Ledger `json:"transaction"`
+	Timestamp   int64  `json:"timestamp,string"`
+}
+
+// publishLedgerEvent publishes a LedgerEvent for the transaction to the
+// configured topic. Events are not published when no topic is configured.
+// The transaction has already been saved, so a failure to publish is only
+// logged.
+func (c *Controller) publishLedgerEvent(eventType string, accountID int, transaction Ledger) {
+	if c.eventTopic == "" || c.service == nil {
+		return
+	}
+
+	event := LedgerEvent{
+		EventType:   eventType,
+		AccountID:   accountID,
+		Transaction: transaction,
+		Timestamp:   time.Now().UnixNano(),
+	}
+	transactionID := strconv.FormatInt(transaction.TransactionID, 10)
+	if err := c.service.PublishWithTopic(c.eventTopic, event, common.ContentTypeJSON); err != nil {
+		c.lc.Errorf("Failed to publish %s event for transaction %s: %s", eventType, transactionID, err.Error())
+		return
+	}
+	c.lc.Debugf("Published %s event for transaction %s to %s", eventType, transactionID, c.eventTopic)
+}
@@ -0,0 +1,143 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishLedgerEvent(t *testing.T) {
+	transaction := getDefaultAccountLedgers().Data[0].Ledgers[0]
+
+	tests := []struct {
+		Name          string
+		EventTopic    string
+		PublishError  error
+		ExpectPublish bool
+	}{
+		{"Published", "ledger/events", nil, true},
+		{"No topic", "", nil, false},
+		{"Publish failure", "ledger/events", errors.New("message bus unavailable"), true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockAppService := &mocks.ApplicationService{}
+			mockAppService.On("PublishWithTopic", mock.Anything, mock.Anything, mock.Anything).
+				Return(currentTest.PublishError)
+			c := Controller{
+				lc:         logger.NewMockClient(),
+				service:    mockAppService,
+				eventTopic: currentTest.EventTopic,
+			}
+
+			c.publishLedgerEvent(LedgerEventCreated, 1, transaction)
+
+			if !currentTest.ExpectPublish {
+				mockAppService.AssertNotCalled(t, "PublishWithTopic", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			mockAppService.AssertCalled(t, "PublishWithTopic", currentTest.EventTopic, mock.MatchedBy(func(event LedgerEvent) bool {
+				return event.EventType == LedgerEventCreated && event.AccountID == 1 &&
+					event.Transaction.TransactionID == transaction.TransactionID && event.Timestamp > 0
+			}), common.ContentTypeJSON)
+		})
+	}
+}
+
+func TestLedgerAddTransactionPublishesEvent(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+
+	mockAppService := &mocks.ApplicationService{}
+	mockAppService.On("PublishWithTopic", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           mockAppService,
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+		eventTopic:        "ledger/events",
+	}
+	data, err := json.Marshal(getDefaultAccountLedgers())
+	require.NoError(t, err)
+	err = os.WriteFile(c.ledgerFileName, data, 0644)
+	require.NoError(t, err)
+	defer func() {
+		os.Remove(c.ledgerFileName)
+	}()
+
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer([]byte(`{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}]}`)))
+	w := httptest.NewRecorder()
+	c.LedgerAddTransaction(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+
+	var newLedger Ledger
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&newLedger))
+	mockAppService.AssertCalled(t, "PublishWithTopic", "ledger/events", mock.MatchedBy(func(event LedgerEvent) bool {
+		return event.EventType == LedgerEventCreated && event.AccountID == 2 &&
+			event.Transaction.TransactionID == newLedger.TransactionID
+	}), common.ContentTypeJSON)
+}
+
+func TestSetPaymentStatusPublishesEvent(t *testing.T) {
+	tests := []struct {
+		Name          string
+		PaymentInfo   string
+		ExpectPublish bool
+	}{
+		{"Marked paid", `{"accountId":1,"transactionID":"1579215712984890248","isPaid": true }`, true},
+		{"Marked unpaid", `{"accountId":1,"transactionID":"1579215712984890248","isPaid": false }`, false},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockAppService := &mocks.ApplicationService{}
+			mockAppService.On("PublishWithTopic", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			c := Controller{
+				lc:             logger.NewMockClient(),
+				service:        mockAppService,
+				ledgerFileName: LedgerFileName,
+				eventTopic:     "ledger/events",
+			}
+			data, err := json.Marshal(getDefaultAccountLedgers())
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/ledgerPaymentUpdate", bytes.NewBuffer([]byte(currentTest.PaymentInfo)))
+			w := httptest.NewRecorder()
+			c.SetPaymentStatus(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+
+			if !currentTest.ExpectPublish {
+				mockAppService.AssertNotCalled(t, "PublishWithTopic", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			mockAppService.AssertCalled(t, "PublishWithTopic", "ledger/events", mock.MatchedBy(func(event LedgerEvent) bool {
+				return event.EventType == LedgerEventPaid && event.AccountID == 1 &&
+					event.Transaction.TransactionID == 1579215712984890248 && event.Transaction.IsPaid
+			}), common.ContentTypeJSON)
+		})
+	}
+}
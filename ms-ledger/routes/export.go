@@ -0,0 +1,280 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+
+	// exportDateLayout is the layout of export ranges given as a date only
+	exportDateLayout = "2006-01-02"
+)
+
+// exportHeader is the header row of a CSV export
+var exportHeader = []string{
+	"accountID", "transactionID", "timestamp", "currency", "subtotal", "tax", "total",
+	"isPaid", "refundOf", "splitID", "chargeID", "chargeStatus", "isFlagged", "itemCount", "isTest",
+}
+
+// exportEntry is a single transaction of a JSON Lines export
+type exportEntry struct {
+	AccountID int `json:"accountID"`
+	Ledger
+}
+
+// ExportRange limits an export to transactions from From, inclusive, until
+// To, exclusive. A zero time leaves that end of the range open.
+type ExportRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParseExportRange parses the from and to export range bounds. Bounds are
+// RFC 3339 timestamps, or dates in the YYYY-MM-DD format, where a to date
+// includes the whole day. Dates are days in the time zone of the location.
+func ParseExportRange(from string, to string, location *time.Location) (ExportRange, error) {
+	var exportRange ExportRange
+	var err error
+	if from != "" {
+		if exportRange.From, err = parseExportTime(from, location); err != nil {
+			return ExportRange{}, fmt.Errorf("from is invalid: %s", err.Error())
+		}
+	}
+	if to != "" {
+		if exportRange.To, err = parseExportTime(to, location); err != nil {
+			return ExportRange{}, fmt.Errorf("to is invalid: %s", err.Error())
+		}
+		if len(to) == len(exportDateLayout) {
+			exportRange.To = exportRange.To.AddDate(0, 0, 1)
+		}
+	}
+	if !exportRange.From.IsZero() && !exportRange.To.IsZero() && !exportRange.From.Before(exportRange.To) {
+		return ExportRange{}, errors.New("from must be before to")
+	}
+	return exportRange, nil
+}
+
+func parseExportTime(value string, location *time.Location) (time.Time, error) {
+	if len(value) == len(exportDateLayout) {
+		return time.ParseInLocation(exportDateLayout, value, location)
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Contains checks whether a transaction timestamp in nanoseconds is in the range
+func (exportRange ExportRange) Contains(timestamp int64) bool {
+	t := time.Unix(0, timestamp)
+	if !exportRange.From.IsZero() && t.Before(exportRange.From) {
+		return false
+	}
+	if !exportRange.To.IsZero() && !t.Before(exportRange.To) {
+		return false
+	}
+	return true
+}
+
+// ledgerExporter writes transactions in an export format
+type ledgerExporter interface {
+	Write(accountID int, ledger Ledger) error
+	Flush() error
+}
+
+// newLedgerExporter creates the exporter for the format. Amounts in the CSV
+// format are written in the ledger's currency.
+func newLedgerExporter(format string, writer io.Writer, currency CurrencyConverter) (ledgerExporter, error) {
+	switch format {
+	case ExportFormatCSV:
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.Write(exportHeader); err != nil {
+			return nil, err
+		}
+		return &csvExporter{writer: csvWriter, currency: currency}, nil
+	case ExportFormatJSONL:
+		return &jsonlExporter{encoder: json.NewEncoder(writer)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %s, expected csv or jsonl", format)
+	}
+}
+
+// csvExporter writes one CSV row per transaction
+type csvExporter struct {
+	writer   *csv.Writer
+	currency CurrencyConverter
+}
+
+func (exporter *csvExporter) Write(accountID int, ledger Ledger) error {
+	currency, ok := exporter.currency.Lookup(ledger.Currency)
+	if !ok {
+		currency = exporter.currency.Base()
+	}
+	subtotalMinor, taxMinor, totalMinor := ledger.SubtotalMinor, ledger.TaxMinor, ledger.LineTotalMinor
+	// ledgers from before minor units were recorded only have float amounts
+	if ledger.Currency == "" {
+		subtotalMinor, taxMinor, totalMinor = currency.ToMinor(ledger.Subtotal), currency.ToMinor(ledger.Tax), currency.ToMinor(ledger.LineTotal)
+	}
+	itemCount := 0
+	for _, lineItem := range ledger.LineItems {
+		if lineItem.Returned || lineItem.Unavailable || lineItem.Discount || lineItem.Rounding {
+			continue
+		}
+		itemCount = itemCount + lineItem.ItemCount
+	}
+
+	return exporter.writer.Write([]string{
+		strconv.Itoa(accountID),
+		strconv.FormatInt(ledger.TransactionID, 10),
+		time.Unix(0, ledger.TxTimeStamp).UTC().Format(time.RFC3339),
+		currency.Code,
+		formatExportAmount(currency, subtotalMinor),
+		formatExportAmount(currency, taxMinor),
+		formatExportAmount(currency, totalMinor),
+		strconv.FormatBool(ledger.IsPaid),
+		formatExportID(ledger.RefundOf),
+		formatExportID(ledger.SplitID),
+		ledger.ChargeID,
+		ledger.ChargeStatus,
+		strconv.FormatBool(ledger.IsFlagged),
+		strconv.Itoa(itemCount),
+		strconv.FormatBool(ledger.IsTest),
+	})
+}
+
+func (exporter *csvExporter) Flush() error {
+	exporter.writer.Flush()
+	return exporter.writer.Error()
+}
+
+// formatExportAmount formats an amount in minor units as a plain decimal
+// number, without a currency symbol, for spreadsheets
+func formatExportAmount(currency Currency, amountMinor int64) string {
+	return strconv.FormatFloat(currency.FromMinor(amountMinor), 'f', currency.Exponent, 64)
+}
+
+// formatExportID leaves IDs that are not set empty
+func formatExportID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// jsonlExporter writes one JSON object per line per transaction
+type jsonlExporter struct {
+	encoder *json.Encoder
+}
+
+func (exporter *jsonlExporter) Write(accountID int, ledger Ledger) error {
+	return exporter.encoder.Encode(exportEntry{AccountID: accountID, Ledger: ledger})
+}
+
+func (exporter *jsonlExporter) Flush() error {
+	return nil
+}
+
+// ledgerStream decodes the ledger JSON file one account at a time, so that
+// the ledgers of every account are never held in memory at once. In the
+// per-account storage it reads one account file at a time instead.
+type ledgerStream struct {
+	file    *os.File
+	decoder *json.Decoder
+	// accountFiles are the account files left to read in the per-account
+	// storage
+	accountFiles []string
+}
+
+// openLedgerStream opens the ledger JSON file and reads up to its first account
+func (c *Controller) openLedgerStream() (*ledgerStream, error) {
+	if c.perAccount() {
+		accountFiles, err := c.accountFileNames()
+		if err != nil {
+			return nil, err
+		}
+		return &ledgerStream{accountFiles: accountFiles}, nil
+	}
+
+	file, err := os.Open(c.ledgerFileName)
+	if err != nil {
+		return nil, errors.New("failed to load ledger JSON file: " + err.Error())
+	}
+	stream := &ledgerStream{file: file, decoder: json.NewDecoder(file)}
+	if err := stream.seekAccounts(); err != nil {
+		file.Close()
+		return nil, errors.New("Failed to unmarshal ledger JSON file: " + err.Error())
+	}
+	return stream, nil
+}
+
+// seekAccounts reads up to the start of the "data" array of accounts
+func (stream *ledgerStream) seekAccounts() error {
+	if err := stream.expectDelim('{'); err != nil {
+		return err
+	}
+	for stream.decoder.More() {
+		token, err := stream.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key, ok := token.(string); ok && key == "data" {
+			return stream.expectDelim('[')
+		}
+		// skip the value of any other key
+		var value json.RawMessage
+		if err := stream.decoder.Decode(&value); err != nil {
+			return err
+		}
+	}
+	return errors.New("ledger JSON file has no data")
+}
+
+func (stream *ledgerStream) expectDelim(delim json.Delim) error {
+	token, err := stream.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v but found %v", delim, token)
+	}
+	return nil
+}
+
+// Next decodes the next account, returning false once all accounts are read
+func (stream *ledgerStream) Next() (Account, bool, error) {
+	if stream.file == nil {
+		if len(stream.accountFiles) == 0 {
+			return Account{}, false, nil
+		}
+		account, err := readAccountFile(stream.accountFiles[0])
+		if err != nil {
+			return Account{}, false, errors.New("failed to load ledger account file: " + err.Error())
+		}
+		stream.accountFiles = stream.accountFiles[1:]
+		return account, true, nil
+	}
+	if !stream.decoder.More() {
+		return Account{}, false, nil
+	}
+	var account Account
+	if err := stream.decoder.Decode(&account); err != nil {
+		return Account{}, false, errors.New("Failed to unmarshal ledger JSON file: " + err.Error())
+	}
+	return account, true, nil
+}
+
+func (stream *ledgerStream) Close() error {
+	if stream.file == nil {
+		return nil
+	}
+	return stream.file.Close()
+}
@@ -0,0 +1,103 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExportRange(t *testing.T) {
+	tests := []struct {
+		Name          string
+		From          string
+		To            string
+		Expected      ExportRange
+		ExpectedError bool
+	}{
+		{"Open range", "", "", ExportRange{}, false},
+		{"Dates", "2020-01-01", "2020-01-31", ExportRange{From: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)}, false},
+		{"Timestamps", "2020-01-01T08:00:00Z", "2020-01-01T17:00:00Z", ExportRange{From: time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC), To: time.Date(2020, 1, 1, 17, 0, 0, 0, time.UTC)}, false},
+		{"From only", "2020-01-01", "", ExportRange{From: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, false},
+		{"Invalid from", "yesterday", "", ExportRange{}, true},
+		{"Invalid to", "", "2020-13-01", ExportRange{}, true},
+		{"From after to", "2020-02-01", "2020-01-01", ExportRange{}, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			exportRange, err := ParseExportRange(currentTest.From, currentTest.To, time.UTC)
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, currentTest.Expected.From.Equal(exportRange.From), "unexpected from %v", exportRange.From)
+			assert.True(t, currentTest.Expected.To.Equal(exportRange.To), "unexpected to %v", exportRange.To)
+		})
+	}
+}
+
+func TestExportRangeContains(t *testing.T) {
+	exportRange, err := ParseExportRange("2020-01-16", "2020-01-16", time.UTC)
+	require.NoError(t, err)
+
+	assert.True(t, exportRange.Contains(1579215712984890363))
+	assert.False(t, exportRange.Contains(2579215712984890363))
+	assert.False(t, exportRange.Contains(time.Date(2020, 1, 17, 0, 0, 0, 0, time.UTC).UnixNano()), "to should be exclusive")
+	assert.True(t, ExportRange{}.Contains(0))
+}
+
+func TestCSVExporter(t *testing.T) {
+	var buffer bytes.Buffer
+	exporter, err := newLedgerExporter(ExportFormatCSV, &buffer, CurrencyConverter{})
+	require.NoError(t, err)
+
+	ledger := Ledger{
+		TransactionID:  1579215712984890248,
+		TxTimeStamp:    1579215712984890363,
+		Currency:       "USD",
+		SubtotalMinor:  398,
+		TaxMinor:       32,
+		LineTotalMinor: 455,
+		ChargeID:       "ch_1",
+		ChargeStatus:   "succeeded",
+		IsPaid:         true,
+		LineItems: []LineItem{
+			{SKU: "4900002472", ProductName: "Sprite, \"Lemon-Lime\"", ItemCount: 2},
+			{SKU: "1200050408", ProductName: "Mountain Dew - 16.9 oz", ItemCount: 1, Returned: true},
+		},
+	}
+	require.NoError(t, exporter.Write(1, ledger))
+	// ledgers without a currency only have float amounts
+	require.NoError(t, exporter.Write(2, Ledger{TransactionID: 2, TxTimeStamp: 1579215712984890363, LineTotal: 2.99, RefundOf: 1}))
+	require.NoError(t, exporter.Flush())
+
+	expected := "accountID,transactionID,timestamp,currency,subtotal,tax,total,isPaid,refundOf,splitID,chargeID,chargeStatus,isFlagged,itemCount,isTest\n" +
+		"1,1579215712984890248,2020-01-16T23:01:52Z,USD,3.98,0.32,4.55,true,,,ch_1,succeeded,false,2,false\n" +
+		"2,2,2020-01-16T23:01:52Z,USD,0.00,0.00,2.99,false,1,,,,false,0,false\n"
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestJSONLExporter(t *testing.T) {
+	var buffer bytes.Buffer
+	exporter, err := newLedgerExporter(ExportFormatJSONL, &buffer, CurrencyConverter{})
+	require.NoError(t, err)
+
+	require.NoError(t, exporter.Write(1, Ledger{TransactionID: 1, LineTotal: 1.99, LineItems: []LineItem{}}))
+	require.NoError(t, exporter.Write(2, Ledger{TransactionID: 2, LineTotal: 2.99, LineItems: []LineItem{}}))
+	require.NoError(t, exporter.Flush())
+
+	expected := `{"accountID":1,"transactionID":"1","txTimeStamp":"0","lineTotal":1.99,"createdAt":"0","updatedAt":"0","isPaid":false,"lineItems":[]}` + "\n" +
+		`{"accountID":2,"transactionID":"2","txTimeStamp":"0","lineTotal":2.99,"createdAt":"0","updatedAt":"0","isPaid":false,"lineItems":[]}` + "\n"
+	assert.Equal(t, expected, buffer.String())
+
+	_, err = newLedgerExporter("xlsx", &buffer, CurrencyConverter{})
+	assert.Error(t, err)
+}
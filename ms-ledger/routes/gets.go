@@ -5,25 +5,20 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
 )
 
 // LedgerAccountGet will get the transaction ledger for a specific account
 func (c *Controller) LedgerAccountGet(writer http.ResponseWriter, req *http.Request) {
-	//Get all ledgers for all accounts
-	accountLedgers, err := c.GetAllLedgers()
-	if err != nil {
-		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
-		c.lc.Error(errMsg)
-		writer.WriteHeader(http.StatusInternalServerError)
-		writer.Write([]byte(errMsg))
-		return
-	}
-
 	// Get the current accountID from the request
 	vars := mux.Vars(req)
 	accountIDstr := vars["accountid"]
@@ -36,6 +31,16 @@ func (c *Controller) LedgerAccountGet(writer http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
 	if accountID >= 0 {
 		for _, account := range accountLedgers.Data {
 			if accountID == account.AccountID {
@@ -85,3 +90,375 @@ func (c *Controller) AllAccountsGet(writer http.ResponseWriter, req *http.Reques
 	c.lc.Info("GET ALL ledger accounts successfully")
 	writer.Write(accountLedgersJSON)
 }
+
+// LedgerReceiptGet renders a receipt for a single transaction. The format is
+// chosen with the "format" query parameter (text, html or pdf), falling back
+// to the Accept header and then plain text.
+func (c *Controller) LedgerReceiptGet(writer http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	tidstr := vars["tid"]
+	tid, err := strconv.ParseInt(tidstr, 10, 64)
+	if err != nil {
+		errMsg := "transactionID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		accept := req.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, "application/pdf"):
+			format = ReceiptFormatPDF
+		case strings.Contains(accept, "text/html"):
+			format = ReceiptFormatHTML
+		default:
+			format = ReceiptFormatText
+		}
+	}
+	if format != ReceiptFormatText && format != ReceiptFormatHTML && format != ReceiptFormatPDF {
+		errMsg := fmt.Sprintf("Unsupported receipt format %s, expected text, html or pdf", format)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	for _, account := range accountLedgers.Data {
+		if account.AccountID != accountID {
+			continue
+		}
+		for _, ledger := range account.Ledgers {
+			if ledger.TransactionID != tid {
+				continue
+			}
+			currency, ok := c.currency.Lookup(ledger.Currency)
+			if !ok {
+				c.lc.Warnf("Transaction %v has unknown currency %s, using %s for the receipt", tidstr, ledger.Currency, c.currency.Base().Code)
+				currency = c.currency.Base()
+			}
+			receipt := NewReceipt(c.storeName, accountID, ledger, currency, c.ledgerLocation(ledger))
+			switch format {
+			case ReceiptFormatHTML:
+				html, err := receipt.HTML()
+				if err != nil {
+					c.lc.Error(err.Error())
+					writer.WriteHeader(http.StatusInternalServerError)
+					writer.Write([]byte(err.Error()))
+					return
+				}
+				writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+				writer.Write(html)
+			case ReceiptFormatPDF:
+				writer.Header().Set("Content-Type", "application/pdf")
+				writer.Header().Set("Content-Disposition", "inline; filename=receipt-"+tidstr+".pdf")
+				writer.Write(receipt.PDF())
+			default:
+				writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				writer.Write([]byte(receipt.Text()))
+			}
+			c.lc.Infof("GET %s receipt for transaction %s successfully", format, tidstr)
+			return
+		}
+		errMsg := fmt.Sprintf("Could not find Transaction %v", tidstr)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	errMsg := fmt.Sprintf("AccountID %v not found in ledger", accountID)
+	c.lc.Error(errMsg)
+	writer.WriteHeader(http.StatusBadRequest)
+	writer.Write([]byte(errMsg))
+}
+
+// LedgerExportGet streams the transactions of all accounts as CSV or JSON
+// Lines, chosen with the "format" query parameter. The optional "from" and
+// "to" query parameters limit the export to a range of transaction times.
+func (c *Controller) LedgerExportGet(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = ExportFormatCSV
+	}
+	if format != ExportFormatCSV && format != ExportFormatJSONL {
+		errMsg := fmt.Sprintf("Unsupported export format %s, expected csv or jsonl", format)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	exportRange, err := ParseExportRange(query.Get("from"), query.Get("to"), c.location())
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid export range: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	stream, err := c.openLedgerStream()
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	defer stream.Close()
+
+	if format == ExportFormatJSONL {
+		writer.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	writer.Header().Set("Content-Disposition", "attachment; filename=ledger."+format)
+	// the size of the export is not known up front, so the response is
+	// sent with chunked transfer encoding as each account is exported
+	flusher, _ := writer.(http.Flusher)
+
+	exporter, err := newLedgerExporter(format, writer, c.currency)
+	if err != nil {
+		c.lc.Errorf("Failed to start ledger export: %s", err.Error())
+		return
+	}
+
+	exported := 0
+	for {
+		account, ok, err := stream.Next()
+		if err != nil {
+			// the response has already started, so the export can only be cut short
+			c.lc.Errorf("Ledger export stopped after %d transactions: %s", exported, err.Error())
+			return
+		}
+		if !ok {
+			break
+		}
+		for _, ledger := range account.Ledgers {
+			if !exportRange.Contains(ledger.TxTimeStamp) {
+				continue
+			}
+			if err := exporter.Write(account.AccountID, ledger); err != nil {
+				c.lc.Errorf("Ledger export stopped after %d transactions: %s", exported, err.Error())
+				return
+			}
+			exported++
+		}
+		if err := exporter.Flush(); err != nil {
+			c.lc.Errorf("Ledger export stopped after %d transactions: %s", exported, err.Error())
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if err := exporter.Flush(); err != nil {
+		c.lc.Errorf("Ledger export stopped after %d transactions: %s", exported, err.Error())
+		return
+	}
+	c.lc.Infof("GET %s export of %d transactions successfully", format, exported)
+}
+
+// LedgerArchiveGet returns the archived transactions of the day given in the
+// "date" query parameter, or the list of archived days when no date is given
+func (c *Controller) LedgerArchiveGet(writer http.ResponseWriter, req *http.Request) {
+	date := req.URL.Query().Get("date")
+	if date == "" {
+		dates, err := c.GetArchiveDates()
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to retrieve archived dates: %s", err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		datesJSON, err := json.Marshal(archiveDates{Dates: dates})
+		if err != nil {
+			errMsg := "Failed to marshal archived dates"
+			c.lc.Errorf("%s: %s", errMsg, err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		writer.Write(datesJSON)
+		return
+	}
+
+	if _, err := time.Parse(exportDateLayout, date); err != nil {
+		errMsg := fmt.Sprintf("Invalid archive date %s, expected YYYY-MM-DD", date)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	archive, err := c.GetArchive(date)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve archive: %s", err.Error())
+		c.lc.Error(errMsg)
+		if errors.Is(err, os.ErrNotExist) {
+			errMsg = fmt.Sprintf("No transactions archived for %s", date)
+			writer.WriteHeader(http.StatusNotFound)
+		} else {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	archiveJSON, err := json.Marshal(archive)
+	if err != nil {
+		errMsg := "Failed to marshal archive"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("GET archive for %s successfully", date)
+	writer.Write(archiveJSON)
+}
+
+// SalesReportGet aggregates the transactions of all accounts, grouped by the
+// "groupBy" query parameter (day, sku or account). The optional "from" and
+// "to" query parameters limit the report to a range of transaction times.
+func (c *Controller) SalesReportGet(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	groupBy := query.Get("groupBy")
+	if groupBy == "" {
+		groupBy = ReportGroupByDay
+	}
+	builder, err := newSalesReportBuilder(groupBy, c.currency, c.location())
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid sales report: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	reportRange, err := ParseExportRange(query.Get("from"), query.Get("to"), c.location())
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid report range: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	stream, err := c.openLedgerStream()
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	defer stream.Close()
+
+	for {
+		account, ok, err := stream.Next()
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		if !ok {
+			break
+		}
+		for _, ledger := range account.Ledgers {
+			if reportRange.Contains(ledger.TxTimeStamp) {
+				builder.Add(account.AccountID, ledger)
+			}
+		}
+	}
+
+	reportJSON, err := json.Marshal(builder.Report())
+	if err != nil {
+		errMsg := "Failed to marshal sales report"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("GET sales report by %s successfully", groupBy)
+	writer.Write(reportJSON)
+}
+
+// LedgerBalanceGet returns the unpaid balance of an account. An account
+// without any transactions in the ledger has a zero balance.
+func (c *Controller) LedgerBalanceGet(writer http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	account := Account{AccountID: accountID}
+	for _, accountLedger := range accountLedgers.Data {
+		if accountLedger.AccountID == accountID {
+			account = accountLedger
+			break
+		}
+	}
+
+	balanceJSON, err := json.Marshal(accountBalance(account, c.currency))
+	if err != nil {
+		errMsg := "Failed to marshal account balance"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("GET balance of account %d successfully", accountID)
+	writer.Write(balanceJSON)
+}
+
+// HealthGet reports whether the service recovered from a crash on start
+func (c *Controller) HealthGet(writer http.ResponseWriter, req *http.Request) {
+	healthJSON, err := json.Marshal(utilities.NewHealth(c.recovery))
+	if err != nil {
+		errMsg := "Failed to marshal health"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Write(healthJSON)
+}
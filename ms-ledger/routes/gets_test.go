@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -126,3 +127,198 @@ func TestLedgerAccountGet(t *testing.T) {
 		})
 	}
 }
+
+func TestLedgerReceiptGet(t *testing.T) {
+	// Accounts slice
+	accountLedgers := getDefaultAccountLedgers()
+	defaultAccountID := "1"
+	defaultTransactionID := "1579215712984890248"
+
+	tests := []struct {
+		Name                string
+		InvalidLedger       bool
+		AccountID           string
+		TransactionID       string
+		Query               string
+		Accept              string
+		ExpectedStatusCode  int
+		ExpectedContentType string
+	}{
+		{"Default text receipt", false, defaultAccountID, defaultTransactionID, "", "", http.StatusOK, "text/plain; charset=utf-8"},
+		{"HTML receipt", false, defaultAccountID, defaultTransactionID, "?format=html", "", http.StatusOK, "text/html; charset=utf-8"},
+		{"PDF receipt from Accept header", false, defaultAccountID, defaultTransactionID, "", "application/pdf", http.StatusOK, "application/pdf"},
+		{"Unsupported format", false, defaultAccountID, defaultTransactionID, "?format=docx", "", http.StatusBadRequest, ""},
+		{"Bad data AccountID", false, "badformat", defaultTransactionID, "", "", http.StatusBadRequest, ""},
+		{"Nonexistent AccountID", false, "10", defaultTransactionID, "", "", http.StatusBadRequest, ""},
+		{"Bad data TransactionID", false, defaultAccountID, "badformat", "", "", http.StatusBadRequest, ""},
+		{"Nonexistent TransactionID", false, defaultAccountID, "1579215712984890249", "", "", http.StatusBadRequest, ""},
+		{"Invalid Ledger", true, defaultAccountID, defaultTransactionID, "", "", http.StatusInternalServerError, ""},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				service:           nil,
+				inventoryEndpoint: "test.com",
+				ledgerFileName:    LedgerFileName,
+				storeName:         DefaultStoreName,
+			}
+			var err error
+			if currentTest.InvalidLedger {
+				err = os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644)
+			} else {
+				data, err := json.Marshal(accountLedgers)
+				require.NoError(t, err)
+				err = os.WriteFile(c.ledgerFileName, data, 0644)
+				require.NoError(t, err)
+			}
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("GET", "http://localhost:48093/ledger/"+currentTest.AccountID+"/"+currentTest.TransactionID+"/receipt"+currentTest.Query, nil)
+			if currentTest.Accept != "" {
+				req.Header.Set("Accept", currentTest.Accept)
+			}
+			req = mux.SetURLVars(req, map[string]string{
+				"accountid": currentTest.AccountID,
+				"tid":       currentTest.TransactionID,
+			})
+			w := httptest.NewRecorder()
+			c.LedgerReceiptGet(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedContentType != "" {
+				assert.Equal(t, currentTest.ExpectedContentType, resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestLedgerExportGet(t *testing.T) {
+	tests := []struct {
+		Name               string
+		InvalidLedger      bool
+		Query              string
+		ExpectedStatusCode int
+		ExpectedType       string
+		ExpectedLines      int
+	}{
+		{"Default CSV", false, "", http.StatusOK, "text/csv; charset=utf-8", 3},
+		{"JSON Lines", false, "?format=jsonl", http.StatusOK, "application/x-ndjson", 2},
+		{"Date range", false, "?format=csv&from=2020-01-01&to=2020-12-31", http.StatusOK, "text/csv; charset=utf-8", 2},
+		{"Empty range", false, "?format=jsonl&from=2021-01-01&to=2021-12-31", http.StatusOK, "application/x-ndjson", 0},
+		{"Unsupported format", false, "?format=xlsx", http.StatusBadRequest, "", 0},
+		{"Invalid range", false, "?from=2021-01-01&to=2020-01-01", http.StatusBadRequest, "", 0},
+		{"Invalid Ledger", true, "", http.StatusInternalServerError, "", 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:             logger.NewMockClient(),
+				service:        nil,
+				ledgerFileName: LedgerFileName,
+			}
+			var err error
+			if currentTest.InvalidLedger {
+				err = os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644)
+			} else {
+				data, err := json.Marshal(getDefaultAccountLedgers())
+				require.NoError(t, err)
+				err = os.WriteFile(c.ledgerFileName, data, 0644)
+				require.NoError(t, err)
+			}
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("GET", "http://localhost:48093/ledger/export"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.LedgerExportGet(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, currentTest.ExpectedType, resp.Header.Get("Content-Type"))
+			assert.True(t, w.Flushed, "the export should be streamed")
+
+			body := w.Body.String()
+			assert.Equal(t, currentTest.ExpectedLines, strings.Count(body, "\n"))
+		})
+	}
+}
+
+func TestSalesReportGet(t *testing.T) {
+	tests := []struct {
+		Name               string
+		InvalidLedger      bool
+		Query              string
+		ExpectedStatusCode int
+		ExpectedGroups     []string
+	}{
+		{"Default by day", false, "", http.StatusOK, []string{"2020-01-16", "2051-09-25"}},
+		{"By account", false, "?groupBy=account", http.StatusOK, []string{"1", "2"}},
+		{"By SKU", false, "?groupBy=sku", http.StatusOK, []string{"1200050408", "2200050408"}},
+		{"Date range", false, "?groupBy=account&from=2020-01-01&to=2020-12-31", http.StatusOK, []string{"1"}},
+		{"Unsupported grouping", false, "?groupBy=week", http.StatusBadRequest, nil},
+		{"Invalid range", false, "?from=2021-01-01&to=2020-01-01", http.StatusBadRequest, nil},
+		{"Invalid Ledger", true, "", http.StatusInternalServerError, nil},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:             logger.NewMockClient(),
+				service:        nil,
+				ledgerFileName: LedgerFileName,
+			}
+			var err error
+			if currentTest.InvalidLedger {
+				err = os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644)
+			} else {
+				data, err := json.Marshal(getDefaultAccountLedgers())
+				require.NoError(t, err)
+				err = os.WriteFile(c.ledgerFileName, data, 0644)
+				require.NoError(t, err)
+			}
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("GET", "http://localhost:48093/reports/sales"+currentTest.Query, nil)
+			w := httptest.NewRecorder()
+			c.SalesReportGet(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var report SalesReport
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+			var keys []string
+			for _, group := range report.Groups {
+				keys = append(keys, group.Key)
+			}
+			assert.Equal(t, currentTest.ExpectedGroups, keys)
+			assert.Equal(t, len(currentTest.ExpectedGroups), report.Totals.TransactionCount)
+			assert.Equal(t, report.Totals.RevenueMinor, report.Totals.UnpaidBalanceMinor, "the default ledgers are unpaid")
+		})
+	}
+}
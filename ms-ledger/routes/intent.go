@@ -0,0 +1,466 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	IntentStatusPending   = "pending"
+	IntentStatusSettled   = "settled"
+	IntentStatusEscalated = "escalated"
+
+	// DefaultIntentRecoveryAfter is how long a basket intent stays pending
+	// before the recovery job charges it. It must be longer than
+	// as-vending's session linger, which delays the charge.
+	DefaultIntentRecoveryAfter = 5 * time.Minute
+	// DefaultIntentRecoveryInterval is how often the recovery job runs
+	DefaultIntentRecoveryInterval = time.Minute
+
+	// maxIntentAttempts is how many times the recovery job tries to charge
+	// a basket before it is escalated to an operator
+	maxIntentAttempts = 3
+	// settledIntentRetention is how long settled intents are kept, so that
+	// a late charge of the basket is recognized
+	settledIntentRetention = 24 * time.Hour
+)
+
+// basketIntentMutex serializes the changes to the basket intents, and the
+// charging of a basket with the settling of its intent, so that a basket
+// is not charged by both as-vending and the recovery job. It is taken
+// before ledgerMutex.
+var basketIntentMutex sync.Mutex
+
+// BasketIntent is written ahead of the charge of a vending session's
+// basket, as soon as its inference result arrives. It is settled by the
+// charge, so a pending intent is a basket that was never charged.
+type BasketIntent struct {
+	SessionID string     `json:"sessionId"`
+	AccountID int        `json:"accountId"`
+	DeltaSKUs []deltaSKU `json:"deltaSKUs"`
+	IsTest    bool       `json:"isTest,omitempty"`
+	// AccountIDs and SplitRule are set when the basket is split between
+	// accounts, the first of which is AccountID
+	AccountIDs []int  `json:"accountIds,omitempty"`
+	SplitRule  string `json:"splitRule,omitempty"`
+	Status     string `json:"status"`
+	CreatedAt  int64  `json:"createdAt,string"`
+	UpdatedAt  int64  `json:"updatedAt,string"`
+	// TransactionIDs are the transactions that charged the basket
+	TransactionIDs []int64 `json:"transactionIds,omitempty"`
+	// Attempts and LastError are the recovery job's failed charges
+	Attempts  int    `json:"attempts,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// BasketIntents is the basket intent file
+type BasketIntents struct {
+	Data []BasketIntent `json:"data"`
+}
+
+// isSplit checks whether the basket is split between accounts
+func (intent BasketIntent) isSplit() bool {
+	return len(intent.AccountIDs) > 1
+}
+
+// BasketIntentFileName is the file of the basket intents, which is kept
+// next to the ledger file
+func BasketIntentFileName(ledgerFileName string) string {
+	return strings.TrimSuffix(ledgerFileName, filepath.Ext(ledgerFileName)) + "-intents.json"
+}
+
+// getBasketIntents loads the basket intents, which are empty until the
+// first intent is recorded
+func (c *Controller) getBasketIntents() (BasketIntents, error) {
+	intents := BasketIntents{Data: []BasketIntent{}}
+	data, err := os.ReadFile(BasketIntentFileName(c.ledgerFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return intents, nil
+	}
+	if err != nil {
+		return BasketIntents{}, errors.New("failed to read basket intents: " + err.Error())
+	}
+	if err = json.Unmarshal(data, &intents); err != nil {
+		return BasketIntents{}, errors.New("failed to unmarshal basket intents: " + err.Error())
+	}
+	return intents, nil
+}
+
+// saveBasketIntents writes the basket intents
+func (c *Controller) saveBasketIntents(intents BasketIntents) error {
+	data, err := json.Marshal(intents)
+	if err != nil {
+		return errors.New("failed to marshal basket intents: " + err.Error())
+	}
+	return c.fileWriter.WriteFile(BasketIntentFileName(c.ledgerFileName), data, 0644)
+}
+
+// settledLedgers returns the transactions that charged the basket of the
+// session, which are none unless its intent is settled. The caller holds
+// basketIntentMutex.
+func (c *Controller) settledLedgers(sessionID string) ([]Ledger, error) {
+	intents, err := c.getBasketIntents()
+	if err != nil {
+		return nil, err
+	}
+	var settled *BasketIntent
+	for i, intent := range intents.Data {
+		if intent.SessionID == sessionID && intent.Status == IntentStatusSettled {
+			settled = &intents.Data[i]
+			break
+		}
+	}
+	if settled == nil {
+		return nil, nil
+	}
+
+	accountIDs := settled.AccountIDs
+	if !settled.isSplit() {
+		accountIDs = []int{settled.AccountID}
+	}
+	accountLedgers, err := c.getLedgers(accountIDs...)
+	if err != nil {
+		return nil, err
+	}
+	var ledgers []Ledger
+	for _, transactionID := range settled.TransactionIDs {
+		for _, account := range accountLedgers.Data {
+			if ledger, found := findLedger(account.Ledgers, transactionID); found {
+				ledgers = append(ledgers, ledger)
+				break
+			}
+		}
+	}
+	return ledgers, nil
+}
+
+// findLedger finds the transaction in the ledgers
+func findLedger(ledgers []Ledger, transactionID int64) (Ledger, bool) {
+	for _, ledger := range ledgers {
+		if ledger.TransactionID == transactionID {
+			return ledger, true
+		}
+	}
+	return Ledger{}, false
+}
+
+// settleBasketIntent marks the intent of the session as settled by the
+// transactions. The basket has already been charged, so a failure is only
+// logged, and a session without an intent is ignored. The caller holds
+// basketIntentMutex.
+func (c *Controller) settleBasketIntent(sessionID string, transactionIDs ...int64) {
+	intents, err := c.getBasketIntents()
+	if err != nil {
+		c.lc.Errorf("Failed to settle the basket intent of session %s: %s", sessionID, err.Error())
+		return
+	}
+	for i, intent := range intents.Data {
+		if intent.SessionID != sessionID {
+			continue
+		}
+		intents.Data[i].Status = IntentStatusSettled
+		intents.Data[i].TransactionIDs = transactionIDs
+		intents.Data[i].UpdatedAt = time.Now().UnixNano()
+		if err := c.saveBasketIntents(intents); err != nil {
+			c.lc.Errorf("Failed to settle the basket intent of session %s: %s", sessionID, err.Error())
+		}
+		return
+	}
+}
+
+// writeSettledLedgers responds with the transactions of a basket that was
+// already charged
+func (c *Controller) writeSettledLedgers(writer http.ResponseWriter, settled interface{}) {
+	settledJSON, err := json.Marshal(settled)
+	if err != nil {
+		errMsg := "Failed to marshal the settled transactions"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Write(settledJSON)
+}
+
+// BasketIntentPost records the intent to charge the basket of a vending
+// session before it is charged. Recording the intent of the same session
+// again replaces its basket, as the basket of a lingering session grows
+// each time the door is closed. The basket of a settled intent cannot be
+// replaced.
+func (c *Controller) BasketIntentPost(writer http.ResponseWriter, req *http.Request) {
+	var request BasketIntent
+	if statusCode, err := c.decodeJSONBody(writer, req, &request); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+	if request.SessionID == "" {
+		errMsg := "A basket intent requires a sessionId"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if request.isSplit() && request.AccountIDs[0] != request.AccountID {
+		errMsg := fmt.Sprintf("The first of the accountIds must be account %d", request.AccountID)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	basketIntentMutex.Lock()
+	defer basketIntentMutex.Unlock()
+	intents, err := c.getBasketIntents()
+	if err != nil {
+		errMsg := err.Error()
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	now := time.Now().UnixNano()
+	intent := BasketIntent{
+		SessionID:  request.SessionID,
+		AccountID:  request.AccountID,
+		DeltaSKUs:  request.DeltaSKUs,
+		IsTest:     request.IsTest,
+		AccountIDs: request.AccountIDs,
+		SplitRule:  request.SplitRule,
+		Status:     IntentStatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if intent.DeltaSKUs == nil {
+		intent.DeltaSKUs = []deltaSKU{}
+	}
+	intentIndex := -1
+	for i, existing := range intents.Data {
+		if existing.SessionID == request.SessionID {
+			intentIndex = i
+			break
+		}
+	}
+	if intentIndex < 0 {
+		intents.Data = append(intents.Data, intent)
+		intentIndex = len(intents.Data) - 1
+	} else {
+		if intents.Data[intentIndex].Status == IntentStatusSettled {
+			errMsg := fmt.Sprintf("The basket of session %s was already charged", request.SessionID)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusConflict)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		intent.CreatedAt = intents.Data[intentIndex].CreatedAt
+		intents.Data[intentIndex] = intent
+	}
+
+	if err = c.saveBasketIntents(intents); err != nil {
+		errMsg := "failed to write basket intents"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Recorded basket intent of session %s for account %d with %d SKUs", intent.SessionID, intent.AccountID, len(intent.DeltaSKUs))
+
+	intentJSON, err := json.Marshal(intents.Data[intentIndex])
+	if err != nil {
+		c.lc.Warnf("Recorded basket intent successfully with error %s", err.Error())
+		writer.Write([]byte("Recorded basket intent successfully, but could not marshal to json"))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(intentJSON)
+}
+
+// BasketIntentsGet returns the basket intents, only those with the status
+// of the "status" query parameter when it is given
+func (c *Controller) BasketIntentsGet(writer http.ResponseWriter, req *http.Request) {
+	status := req.URL.Query().Get("status")
+	switch status {
+	case "", IntentStatusPending, IntentStatusSettled, IntentStatusEscalated:
+	default:
+		errMsg := fmt.Sprintf("Unknown basket intent status %q", status)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	basketIntentMutex.Lock()
+	intents, err := c.getBasketIntents()
+	basketIntentMutex.Unlock()
+	if err != nil {
+		errMsg := err.Error()
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if status != "" {
+		filtered := []BasketIntent{}
+		for _, intent := range intents.Data {
+			if intent.Status == status {
+				filtered = append(filtered, intent)
+			}
+		}
+		intents.Data = filtered
+	}
+
+	intentsJSON, err := json.Marshal(intents)
+	if err != nil {
+		errMsg := "Failed to marshal basket intents"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(intentsJSON)
+}
+
+// BasketIntentDelete removes the basket intent of the session in the URL,
+// once an operator has resolved an escalated basket
+func (c *Controller) BasketIntentDelete(writer http.ResponseWriter, req *http.Request) {
+	sessionID := mux.Vars(req)["sessionid"]
+
+	basketIntentMutex.Lock()
+	defer basketIntentMutex.Unlock()
+	intents, err := c.getBasketIntents()
+	if err != nil {
+		errMsg := err.Error()
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	for i, intent := range intents.Data {
+		if intent.SessionID != sessionID {
+			continue
+		}
+		intents.Data = append(intents.Data[:i], intents.Data[i+1:]...)
+		if err = c.saveBasketIntents(intents); err != nil {
+			errMsg := "failed to write basket intents"
+			c.lc.Errorf("%s: %s", errMsg, err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		c.lc.Infof("Removed the %s basket intent of session %s", intent.Status, sessionID)
+		writer.Write([]byte("Removed basket intent of session " + sessionID))
+		return
+	}
+
+	errMsg := fmt.Sprintf("Basket intent of session %s not found", sessionID)
+	c.lc.Error(errMsg)
+	writer.WriteHeader(http.StatusNotFound)
+	writer.Write([]byte(errMsg))
+}
+
+// RecoverBasketIntents charges the baskets whose intents were not settled
+// within the recovery period, which means as-vending failed between the
+// inference and the charge. A basket that cannot be charged is escalated
+// to an operator: a split basket, a basket rejected by the ledger, or one
+// that failed to be charged maxIntentAttempts times. Settled intents are
+// removed once they are past their retention. It returns how many baskets
+// were charged and escalated.
+func (c *Controller) RecoverBasketIntents(now time.Time, recoverAfter time.Duration) (int, int, error) {
+	basketIntentMutex.Lock()
+	defer basketIntentMutex.Unlock()
+	intents, err := c.getBasketIntents()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := now.Add(-recoverAfter).UnixNano()
+	retentionCutoff := now.Add(-settledIntentRetention).UnixNano()
+	changed := false
+	settled, escalated := 0, 0
+	kept := []BasketIntent{}
+	for _, intent := range intents.Data {
+		switch {
+		case intent.Status == IntentStatusSettled && intent.UpdatedAt < retentionCutoff:
+			changed = true
+			continue
+
+		case intent.Status == IntentStatusPending && intent.UpdatedAt < cutoff:
+			changed = true
+			intent.UpdatedAt = now.UnixNano()
+			if intent.isSplit() {
+				intent.Status = IntentStatusEscalated
+				intent.LastError = "a split basket is charged by an operator"
+				escalated++
+				c.lc.Errorf("Escalated the split basket of session %s for accounts %v, it was not charged", intent.SessionID, intent.AccountIDs)
+				break
+			}
+
+			newLedger, statusCode, err := c.addTransaction(deltaLedger{AccountID: intent.AccountID, DeltaSKUs: intent.DeltaSKUs, IsTest: intent.IsTest, SessionID: intent.SessionID})
+			if err == nil {
+				intent.Status = IntentStatusSettled
+				intent.TransactionIDs = []int64{newLedger.TransactionID}
+				settled++
+				c.lc.Warnf("Charged the basket of session %s for account %d as transaction %d, it was not charged by as-vending", intent.SessionID, intent.AccountID, newLedger.TransactionID)
+				break
+			}
+			intent.Attempts++
+			intent.LastError = err.Error()
+			if statusCode == http.StatusBadRequest || intent.Attempts >= maxIntentAttempts {
+				intent.Status = IntentStatusEscalated
+				escalated++
+				c.lc.Errorf("Escalated the basket of session %s for account %d after %d attempts to charge it: %s", intent.SessionID, intent.AccountID, intent.Attempts, err.Error())
+			}
+		}
+		kept = append(kept, intent)
+	}
+	if !changed {
+		return 0, 0, nil
+	}
+
+	intents.Data = kept
+	if err = c.saveBasketIntents(intents); err != nil {
+		return settled, escalated, err
+	}
+	return settled, escalated, nil
+}
+
+// RunIntentRecovery recovers the basket intents on start and then every
+// interval until the context is cancelled
+func (c *Controller) RunIntentRecovery(ctx context.Context, interval time.Duration, recoverAfter time.Duration) {
+	if interval <= 0 {
+		interval = DefaultIntentRecoveryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		settled, escalated, err := c.RecoverBasketIntents(time.Now(), recoverAfter)
+		if err != nil {
+			c.lc.Errorf("Failed to recover basket intents: %s", err.Error())
+		} else if settled > 0 || escalated > 0 {
+			c.lc.Infof("Recovered basket intents, charged %d baskets and escalated %d", settled, escalated)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIntentTestController writes the default ledgers for a controller that
+// looks its products up in the test inventory
+func newIntentTestController(t *testing.T) Controller {
+	inventoryServer := newInventoryTestServer(t)
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+	}
+	data, err := json.Marshal(getDefaultAccountLedgers())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.ledgerFileName, data, 0644))
+	t.Cleanup(func() {
+		os.Remove(c.ledgerFileName)
+		os.Remove(BasketIntentFileName(c.ledgerFileName))
+	})
+	return c
+}
+
+func postIntent(c Controller, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.BasketIntentPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/ledger/intents", bytes.NewBufferString(body)))
+	return w
+}
+
+func TestBasketIntentFileName(t *testing.T) {
+	assert.Equal(t, "/tmp/ledger-intents.json", BasketIntentFileName("/tmp/ledger.json"))
+}
+
+// TestBasketIntentSettledByCharge tests that the charge of a session settles
+// its intent, and that charging the session again does not charge twice
+func TestBasketIntentSettledByCharge(t *testing.T) {
+	c := newIntentTestController(t)
+
+	w := postIntent(c, `{"sessionId":"s1","accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var intent BasketIntent
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &intent))
+	assert.Equal(t, IntentStatusPending, intent.Status)
+
+	// the basket of a lingering session grows
+	w = postIntent(c, `{"sessionId":"s1","accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-2}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	intents, err := c.getBasketIntents()
+	require.NoError(t, err)
+	require.Len(t, intents.Data, 1)
+	assert.Equal(t, -2, intents.Data[0].DeltaSKUs[0].Delta)
+	assert.Equal(t, intent.CreatedAt, intents.Data[0].CreatedAt)
+
+	charge := func() Ledger {
+		w := httptest.NewRecorder()
+		c.LedgerAddTransaction(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/ledger", bytes.NewBufferString(`{"sessionId":"s1","accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-2}]}`)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var ledger Ledger
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ledger))
+		return ledger
+	}
+	first := charge()
+	intents, err = c.getBasketIntents()
+	require.NoError(t, err)
+	assert.Equal(t, IntentStatusSettled, intents.Data[0].Status)
+	assert.Equal(t, []int64{first.TransactionID}, intents.Data[0].TransactionIDs)
+
+	second := charge()
+	assert.Equal(t, first.TransactionID, second.TransactionID)
+	accountLedgers, err := c.GetAllLedgers()
+	require.NoError(t, err)
+	assert.Len(t, accountLedgers.Data[1].Ledgers, len(getDefaultAccountLedgers().Data[1].Ledgers)+1, "charged once")
+
+	assert.Equal(t, http.StatusConflict, postIntent(c, `{"sessionId":"s1","accountId":2,"deltaSKUs":[]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, postIntent(c, `{"accountId":2,"deltaSKUs":[]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, postIntent(c, `{"sessionId":"s2","accountId":2,"accountIds":[1,2],"deltaSKUs":[]}`).Code)
+}
+
+// TestBasketIntentSettledBySplit tests that the split of a session settles
+// its intent with the transactions of every account
+func TestBasketIntentSettledBySplit(t *testing.T) {
+	c := newIntentTestController(t)
+	require.Equal(t, http.StatusOK, postIntent(c, `{"sessionId":"s1","accountId":1,"accountIds":[1,2],"splitRule":"even","deltaSKUs":[{"sku":"4900002470","delta":-2}]}`).Code)
+
+	split := func() []Ledger {
+		w := httptest.NewRecorder()
+		c.LedgerSplitTransaction(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/ledger/split", bytes.NewBufferString(`{"sessionId":"s1","accountIds":[1,2],"rule":"even","deltaSKUs":[{"sku":"4900002470","delta":-2}]}`)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var ledgers []Ledger
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ledgers))
+		return ledgers
+	}
+	first := split()
+	require.Len(t, first, 2)
+	second := split()
+	assert.Equal(t, first, second)
+
+	intents, err := c.getBasketIntents()
+	require.NoError(t, err)
+	assert.Equal(t, IntentStatusSettled, intents.Data[0].Status)
+	assert.Equal(t, []int64{first[0].TransactionID, first[1].TransactionID}, intents.Data[0].TransactionIDs)
+}
+
+// TestRecoverBasketIntents tests that the recovery job charges the baskets
+// that were left pending, and escalates those it cannot charge
+func TestRecoverBasketIntents(t *testing.T) {
+	c := newIntentTestController(t)
+	now := time.Now()
+	old := now.Add(-10 * time.Minute).UnixNano()
+	intents := BasketIntents{Data: []BasketIntent{
+		{SessionID: "charged", AccountID: 2, DeltaSKUs: []deltaSKU{{SKU: "4900002470", Delta: -1}}, Status: IntentStatusPending, CreatedAt: old, UpdatedAt: old},
+		{SessionID: "recent", AccountID: 2, DeltaSKUs: []deltaSKU{{SKU: "4900002470", Delta: -1}}, Status: IntentStatusPending, CreatedAt: now.UnixNano(), UpdatedAt: now.UnixNano()},
+		{SessionID: "unknown account", AccountID: 10, DeltaSKUs: []deltaSKU{{SKU: "4900002470", Delta: -1}}, Status: IntentStatusPending, CreatedAt: old, UpdatedAt: old},
+		{SessionID: "split", AccountID: 1, AccountIDs: []int{1, 2}, SplitRule: SplitRuleEven, DeltaSKUs: []deltaSKU{{SKU: "4900002470", Delta: -2}}, Status: IntentStatusPending, CreatedAt: old, UpdatedAt: old},
+		{SessionID: "expired", AccountID: 1, Status: IntentStatusSettled, CreatedAt: old, UpdatedAt: now.Add(-25 * time.Hour).UnixNano()},
+	}}
+	require.NoError(t, c.saveBasketIntents(intents))
+
+	settled, escalated, err := c.RecoverBasketIntents(now, 5*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, settled)
+	assert.Equal(t, 2, escalated)
+
+	intents, err = c.getBasketIntents()
+	require.NoError(t, err)
+	require.Len(t, intents.Data, 4, "the expired settled intent is removed")
+	assert.Equal(t, IntentStatusSettled, intents.Data[0].Status)
+	require.Len(t, intents.Data[0].TransactionIDs, 1)
+	assert.Equal(t, IntentStatusPending, intents.Data[1].Status)
+	assert.Equal(t, IntentStatusEscalated, intents.Data[2].Status)
+	assert.Equal(t, 1, intents.Data[2].Attempts)
+	assert.Equal(t, "Account not found", intents.Data[2].LastError)
+	assert.Equal(t, IntentStatusEscalated, intents.Data[3].Status)
+
+	accountLedgers, err := c.GetAllLedgers()
+	require.NoError(t, err)
+	ledger, found := findLedger(accountLedgers.Data[1].Ledgers, intents.Data[0].TransactionIDs[0])
+	require.True(t, found)
+	assert.Equal(t, "4900002470", ledger.LineItems[0].SKU)
+
+	// nothing is left to recover
+	settled, escalated, err = c.RecoverBasketIntents(now, 5*time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, settled+escalated)
+}
+
+// TestRecoverBasketIntentsRetries tests that a basket that fails to be
+// charged is retried before it is escalated
+func TestRecoverBasketIntentsRetries(t *testing.T) {
+	c := newIntentTestController(t)
+	old := time.Now().Add(-10 * time.Minute).UnixNano()
+	require.NoError(t, c.saveBasketIntents(BasketIntents{Data: []BasketIntent{
+		{SessionID: "s1", AccountID: 2, DeltaSKUs: []deltaSKU{{SKU: "4900002470", Delta: -1}}, Status: IntentStatusPending, CreatedAt: old, UpdatedAt: old},
+	}}))
+	// the ledger file cannot be loaded
+	require.NoError(t, os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644))
+
+	for attempt := 1; attempt <= maxIntentAttempts; attempt++ {
+		_, escalated, err := c.RecoverBasketIntents(time.Now().Add(time.Duration(attempt)*10*time.Minute), 5*time.Minute)
+		require.NoError(t, err)
+		intents, err := c.getBasketIntents()
+		require.NoError(t, err)
+		assert.Equal(t, attempt, intents.Data[0].Attempts)
+		if attempt < maxIntentAttempts {
+			assert.Zero(t, escalated)
+			assert.Equal(t, IntentStatusPending, intents.Data[0].Status)
+		} else {
+			assert.Equal(t, 1, escalated)
+			assert.Equal(t, IntentStatusEscalated, intents.Data[0].Status)
+		}
+	}
+}
+
+func TestBasketIntentsGetAndDelete(t *testing.T) {
+	c := newIntentTestController(t)
+	require.NoError(t, c.saveBasketIntents(BasketIntents{Data: []BasketIntent{
+		{SessionID: "s1", AccountID: 1, Status: IntentStatusEscalated},
+		{SessionID: "s2", AccountID: 2, Status: IntentStatusPending},
+	}}))
+
+	w := httptest.NewRecorder()
+	c.BasketIntentsGet(w, httptest.NewRequest(http.MethodGet, "http://localhost:48093/ledger/intents?status=escalated", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var intents BasketIntents
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &intents))
+	require.Len(t, intents.Data, 1)
+	assert.Equal(t, "s1", intents.Data[0].SessionID)
+
+	w = httptest.NewRecorder()
+	c.BasketIntentsGet(w, httptest.NewRequest(http.MethodGet, "http://localhost:48093/ledger/intents?status=lost", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	deleteIntent := func(sessionID string) int {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "http://localhost:48093/ledger/intents/"+sessionID, nil), map[string]string{"sessionid": sessionID})
+		w := httptest.NewRecorder()
+		c.BasketIntentDelete(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, deleteIntent("s1"))
+	assert.Equal(t, http.StatusNotFound, deleteIntent("s1"))
+	remaining, err := c.getBasketIntents()
+	require.NoError(t, err)
+	require.Len(t, remaining.Data, 1)
+	assert.Equal(t, "s2", remaining.Data[0].SessionID)
+}
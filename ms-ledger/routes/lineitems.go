@@ -0,0 +1,97 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// maxConcurrentLineItemLookups is how many line items of a transaction are
+// resolved at once, so that a large basket does not flood inventory
+const maxConcurrentLineItemLookups = 8
+
+// lineItemInfo is the product of a line item with the amounts it is charged
+type lineItemInfo struct {
+	product        Product
+	itemPriceMinor int64
+	depositMinor   int64
+}
+
+// lineItemFailure is why the line item of a SKU could not be resolved
+type lineItemFailure struct {
+	SKU    string `json:"sku"`
+	Reason string `json:"reason"`
+}
+
+// lineItemLookupError is every line item of a transaction that could not be
+// resolved, rather than only the first one
+type lineItemLookupError struct {
+	Failures []lineItemFailure `json:"failures"`
+}
+
+func (err *lineItemLookupError) Error() string {
+	failures := make([]string, 0, len(err.Failures))
+	for _, failure := range err.Failures {
+		failures = append(failures, failure.SKU+": "+failure.Reason)
+	}
+	return fmt.Sprintf("Could not resolve %d line item(s): %s", len(err.Failures), strings.Join(failures, "; "))
+}
+
+// resolveLineItems looks up the products of the SKUs in inventory, in a
+// single request, and the price each was charged at the transaction time.
+// The prices are looked up concurrently, so that checkout does not take
+// longer with every item in the cart.
+func (c *Controller) resolveLineItems(skus []string, at int64) (map[string]lineItemInfo, error) {
+	products, err := c.getInventoryItems(c.inventoryEndpoint, skus)
+	if err != nil {
+		return nil, fmt.Errorf("Could not find product Info for %v errir: %v", strings.Join(skus, ", "), err.Error())
+	}
+
+	resolved := make([]lineItemInfo, len(skus))
+	failures := make([]*lineItemFailure, len(skus))
+	var group errgroup.Group
+	group.SetLimit(maxConcurrentLineItemLookups)
+	for index, sku := range skus {
+		index, sku := index, sku
+		group.Go(func() error {
+			itemInfo, ok := products[sku]
+			if !ok {
+				failures[index] = &lineItemFailure{SKU: sku, Reason: "SKU may not exist"}
+				return nil
+			}
+			// the price is the one in effect at the transaction time, which
+			// later price changes do not affect
+			itemPrice, itemCurrency := c.effectivePrice(itemInfo, at)
+			itemPriceMinor, err := c.currency.ToBaseMinor(itemPrice, itemCurrency)
+			if err != nil {
+				failures[index] = &lineItemFailure{SKU: sku, Reason: "could not convert the price: " + err.Error()}
+				return nil
+			}
+			// the deposit is in the same currency as the price, so it converts without error
+			depositMinor, _ := c.currency.ToBaseMinor(itemInfo.Deposit, itemInfo.Currency)
+			resolved[index] = lineItemInfo{product: itemInfo, itemPriceMinor: itemPriceMinor, depositMinor: depositMinor}
+			return nil
+		})
+	}
+	// failures are recorded per SKU rather than returned, so that every
+	// lookup runs and all of them are reported
+	_ = group.Wait()
+
+	lookupErr := &lineItemLookupError{}
+	lineItems := make(map[string]lineItemInfo, len(skus))
+	for index, sku := range skus {
+		if failures[index] != nil {
+			lookupErr.Failures = append(lookupErr.Failures, *failures[index])
+			continue
+		}
+		lineItems[sku] = resolved[index]
+	}
+	if len(lookupErr.Failures) > 0 {
+		return nil, lookupErr
+	}
+	return lineItems, nil
+}
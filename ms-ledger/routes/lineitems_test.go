@@ -0,0 +1,78 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLineItems(t *testing.T) {
+	var products []Product
+	var skus []string
+	for i := 0; i < 12; i++ {
+		product := getDefaultProduct()
+		product.SKU = fmt.Sprintf("49000024%02d", i)
+		products = append(products, product)
+		skus = append(skus, product.SKU)
+	}
+
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch" {
+			jsonProducts, _ := json.Marshal(inventoryProducts{Data: products})
+			_, _ = w.Write(jsonProducts)
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/priceHistory") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer inventoryServer.Close()
+
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		inventoryEndpoint: inventoryServer.URL,
+	}
+	lineItems, err := c.resolveLineItems(skus, time.Now().UnixNano())
+	require.NoError(t, err)
+	require.Len(t, lineItems, len(skus))
+	assert.Equal(t, int64(199), lineItems[skus[0]].itemPriceMinor)
+	assert.Greater(t, maxInFlight, 1, "the prices are looked up concurrently")
+	assert.LessOrEqual(t, maxInFlight, maxConcurrentLineItemLookups)
+
+	// every line item that cannot be resolved is reported
+	_, err = c.resolveLineItems([]string{skus[0], "0000000001", "0000000002"}, time.Now().UnixNano())
+	var lookupErr *lineItemLookupError
+	require.True(t, errors.As(err, &lookupErr))
+	assert.Equal(t, []lineItemFailure{
+		{SKU: "0000000001", Reason: "SKU may not exist"},
+		{SKU: "0000000002", Reason: "SKU may not exist"},
+	}, lookupErr.Failures)
+	assert.Contains(t, err.Error(), "Could not resolve 2 line item(s)")
+}
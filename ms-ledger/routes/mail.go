@@ -0,0 +1,79 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer sends a plain text email
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// SMTPMailer sends emails through an SMTP server, which is a host:port
+// address. The credentials are optional, without them the server must
+// accept mail from the service unauthenticated.
+type SMTPMailer struct {
+	Server   string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailer validates the SMTP settings. It returns nil when no server
+// is configured.
+func NewSMTPMailer(server string, username string, password string, from string) (*SMTPMailer, error) {
+	if server == "" {
+		return nil, nil
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return nil, fmt.Errorf("StatementSMTPServer %q must be a host:port address", server)
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("StatementFromAddress %q is not an email address", from)
+	}
+	return &SMTPMailer{Server: server, Username: username, Password: password, From: from}, nil
+}
+
+// Send sends the email to the address
+func (mailer *SMTPMailer) Send(to string, subject string, body string) error {
+	message, err := mailMessage(mailer.From, to, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		host, _, _ := net.SplitHostPort(mailer.Server)
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, host)
+	}
+	return smtp.SendMail(mailer.Server, auth, mailer.From, []string{to}, message)
+}
+
+// mailMessage formats a plain text email. The address is checked, and the
+// subject may not contain line breaks, so that neither can add headers.
+func mailMessage(from string, to string, subject string, body string, now time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(to); err != nil {
+		return nil, fmt.Errorf("%q is not an email address", to)
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, errors.New("the subject may not contain line breaks")
+	}
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + to + "\r\n")
+	sb.WriteString("Subject: " + subject + "\r\n")
+	sb.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(sb.String()), nil
+}
@@ -15,6 +15,70 @@ type Ledger struct {
 	UpdatedAt     int64      `json:"updatedAt,string"`
 	IsPaid        bool       `json:"isPaid"`
 	LineItems     []LineItem `json:"lineItems"`
+	// PaidAt is when the transaction was marked as paid, or when its last
+	// partial payment settled it
+	PaidAt int64 `json:"paidAt,string,omitempty"`
+	// RefundOf links a reversal entry back to the transaction it refunds
+	RefundOf int64 `json:"refundOf,string,omitempty"`
+	// IsFlagged marks a transaction that needs review before it is charged,
+	// with the reasons recorded in FlagReasons
+	IsFlagged   bool     `json:"isFlagged,omitempty"`
+	FlagReasons []string `json:"flagReasons,omitempty"`
+	// ReviewStatus is pendingReview while a flagged transaction waits in
+	// the review queue, and how an admin resolved it afterwards. ReviewedBy
+	// is the person ID of that admin and ReviewedAt when they resolved it.
+	ReviewStatus string `json:"reviewStatus,omitempty"`
+	ReviewedBy   int    `json:"reviewedBy,omitempty"`
+	ReviewedAt   int64  `json:"reviewedAt,string,omitempty"`
+	// Subtotal is the charged item total before deposits and tax, and Tax
+	// is the sum of the line item taxes. LineTotal is the grand total.
+	Subtotal float64 `json:"subtotal,omitempty"`
+	Tax      float64 `json:"tax,omitempty"`
+	// Currency is the ISO 4217 code of the ledger's amounts. The *Minor
+	// fields hold the amounts as integer minor units, such as cents, and
+	// are authoritative; the float amounts are kept for existing clients.
+	Currency       string `json:"currency,omitempty"`
+	LineTotalMinor int64  `json:"lineTotalMinor,omitempty"`
+	SubtotalMinor  int64  `json:"subtotalMinor,omitempty"`
+	TaxMinor       int64  `json:"taxMinor,omitempty"`
+	// DisplayTotal is LineTotal formatted for display in Currency
+	DisplayTotal string `json:"displayTotal,omitempty"`
+	// ChargeID and ChargeStatus record the payment provider's charge when
+	// the transaction was paid through a payment provider
+	ChargeID     string `json:"chargeID,omitempty"`
+	ChargeStatus string `json:"chargeStatus,omitempty"`
+	// SplitID links the transactions created by splitting one basket
+	// between several accounts, and SplitRule is how it was split
+	SplitID   int64  `json:"splitID,string,omitempty"`
+	SplitRule string `json:"splitRule,omitempty"`
+	// Hold is the pre-authorization placed before the door was unlocked,
+	// which is captured or released when the transaction is paid
+	Hold *Hold `json:"hold,omitempty"`
+	// IsTest marks a zero-priced test vend made by a field technician to
+	// validate a kiosk, which is excluded from sales reports
+	IsTest bool `json:"isTest,omitempty"`
+	// Payments are the partial payments made towards the transaction,
+	// which is paid once they cover LineTotal
+	Payments []Payment `json:"payments,omitempty"`
+	// IsEdited marks a transaction corrected by an admin, and IsVoided one
+	// cancelled by an admin for VoidReason. A voided transaction keeps its
+	// items but its amounts are zero. Overrides are audited separately.
+	IsEdited   bool   `json:"isEdited,omitempty"`
+	IsVoided   bool   `json:"isVoided,omitempty"`
+	VoidReason string `json:"voidReason,omitempty"`
+	// TimeZone is the IANA time zone of the kiosk the transaction was
+	// recorded at. The timestamps are UTC, and the zone is only used to
+	// show them in the kiosk's local time.
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Payment is a partial payment towards a transaction, in the transaction's
+// currency. Method is how it was paid, such as "cash" or "card".
+type Payment struct {
+	Amount      float64 `json:"amount"`
+	AmountMinor int64   `json:"amountMinor"`
+	Timestamp   int64   `json:"timestamp,string"`
+	Method      string  `json:"method"`
 }
 
 type LineItem struct {
@@ -22,13 +86,85 @@ type LineItem struct {
 	ProductName string  `json:"productName"`
 	ItemPrice   float64 `json:"itemPrice"`
 	ItemCount   int     `json:"itemCount"`
+	// Unavailable marks an item vended outside of its availability windows.
+	// Unavailable items are not included in the ledger's LineTotal.
+	Unavailable bool `json:"unavailable,omitempty"`
+	// Deposit is the per-unit container deposit charged with the item
+	Deposit float64 `json:"deposit,omitempty"`
+	// ContainerReturn marks a line that refunds the deposit of returned
+	// empty containers. Its ItemCount is negative and ItemPrice is zero.
+	ContainerReturn bool `json:"containerReturn,omitempty"`
+	// Returned marks an item put back, or added, during a session without
+	// having been taken in it. It is recorded for audit and is not charged.
+	Returned bool `json:"returned,omitempty"`
+	// TaxRate is the rate of the product's tax category when the item was
+	// vended, and Tax is the resulting tax on the line
+	TaxRate float64 `json:"taxRate,omitempty"`
+	Tax     float64 `json:"tax,omitempty"`
+	// ItemPriceMinor, DepositMinor and TaxMinor are the line's amounts in
+	// minor units of the ledger's currency
+	ItemPriceMinor int64 `json:"itemPriceMinor,omitempty"`
+	DepositMinor   int64 `json:"depositMinor,omitempty"`
+	TaxMinor       int64 `json:"taxMinor,omitempty"`
+	// PriceOverridden marks an item whose price was overridden by an
+	// operator. OriginalItemPrice is the inventory price, ItemPrice is the
+	// effective price, and OverrideReason is why it was overridden.
+	PriceOverridden        bool    `json:"priceOverridden,omitempty"`
+	OriginalItemPrice      float64 `json:"originalItemPrice,omitempty"`
+	OriginalItemPriceMinor int64   `json:"originalItemPriceMinor,omitempty"`
+	OverrideReason         string  `json:"overrideReason,omitempty"`
+	// Discount marks a discount line. Its ItemCount is one and ItemPrice is
+	// the negated discount amount. A discount for a SKU is taxed at the
+	// item's rate, so that it also reduces the tax.
+	Discount bool `json:"discount,omitempty"`
+	// Rounding marks the cash rounding line of a transaction in the cash
+	// rounded pricing mode. Its ItemCount is one and ItemPrice is the
+	// difference between the exact and the rounded total, which was rounded
+	// to a multiple of RoundingIncrementMinor with RoundingRule.
+	Rounding               bool   `json:"rounding,omitempty"`
+	RoundingIncrementMinor int64  `json:"roundingIncrementMinor,omitempty"`
+	RoundingRule           string `json:"roundingRule,omitempty"`
 }
 
 type Account struct {
 	AccountID int      `json:"accountID"`
 	Ledgers   []Ledger `json:"ledgers"`
+	// PaymentMethod is the payment provider's reference to the account's
+	// stored payment method, such as a customer ID
+	PaymentMethod string `json:"paymentMethod,omitempty"`
+	// Hold is a pre-authorization waiting for the account's next transaction
+	Hold *Hold `json:"hold,omitempty"`
 }
 
+// Hold is a pre-authorization of an account's stored payment method for
+// the configured hold amount
+type Hold struct {
+	AuthorizationID string `json:"authorizationID"`
+	AmountMinor     int64  `json:"amountMinor"`
+	Currency        string `json:"currency"`
+	CreatedAt       int64  `json:"createdAt,string"`
+	// Status is authorized until the hold is captured or released
+	Status string `json:"status"`
+}
+
+// preAuthorization is the response to a pre-authorization request. Accounts
+// without a stored payment method, or when holds are not configured, do
+// not require a hold.
+// preAuthRequest is the optional body of a pre-authorization, with the
+// amount to hold instead of the configured amount
+type preAuthRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+type preAuthorization struct {
+	Required bool  `json:"required"`
+	Hold     *Hold `json:"hold,omitempty"`
+}
+
+// Product is an inventory item as the inventory service returns it. Its
+// ItemPrice and Deposit, in major units of Currency, are only read to be
+// converted to minor units of the ledger's currency with ToBaseMinor, and
+// the line items keep the converted amounts as the source of truth.
 type Product struct {
 	SKU                string  `json:"sku"`
 	ItemPrice          float64 `json:"itemPrice"`
@@ -39,6 +175,26 @@ type Product struct {
 	CreatedAt          int64   `json:"createdAt,string"`
 	UpdatedAt          int64   `json:"updatedAt,string"`
 	IsActive           bool    `json:"isActive"`
+	// Availability restricts when the product may be vended. A product
+	// without any availability windows is always available.
+	Availability []AvailabilityWindow `json:"availability,omitempty"`
+	// Deposit is the per-unit container deposit charged on top of ItemPrice
+	Deposit float64 `json:"deposit,omitempty"`
+	// TaxCategory selects the product's rate from the ledger's tax table
+	TaxCategory string `json:"taxCategory,omitempty"`
+	// Currency is the ISO 4217 code of ItemPrice and Deposit. Products
+	// without a currency are priced in the ledger's currency.
+	Currency string `json:"currency,omitempty"`
+}
+
+// AvailabilityWindow is a daily window, in the kiosk's local time, during
+// which a product may be vended. Start and End use the 24-hour "15:04"
+// format, and a window whose End is before its Start spans midnight. Days
+// optionally restricts the window to weekdays such as "Mon" or "Sat".
+type AvailabilityWindow struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
 }
 
 type paymentInfo struct {
@@ -50,9 +206,105 @@ type paymentInfo struct {
 type deltaLedger struct {
 	AccountID int        `json:"accountId"`
 	DeltaSKUs []deltaSKU `json:"deltaSKUs"`
+	IsTest    bool       `json:"isTest,omitempty"`
+	// SessionID is the vending session of the basket, which settles the
+	// session's basket intent
+	SessionID string `json:"sessionId,omitempty"`
+	// RoleID is the role of the operator making the price overrides and
+	// discounts, which require an operator role. It is taken from the
+	// verified token and never from the body.
+	RoleID         int             `json:"-"`
+	PriceOverrides []priceOverride `json:"priceOverrides,omitempty"`
+	Discounts      []discountLine  `json:"discounts,omitempty"`
+	// FlagReason flags the transaction for review before it is charged,
+	// such as when its items were entered by hand
+	FlagReason string `json:"flagReason,omitempty"`
+}
+
+// priceOverride replaces the price of a SKU in the transaction, in the
+// ledger's currency
+type priceOverride struct {
+	SKU       string  `json:"sku"`
+	ItemPrice float64 `json:"itemPrice"`
+	Reason    string  `json:"reason"`
+}
+
+// discountLine is a discount added to the transaction, in the ledger's
+// currency. A discount with a SKU applies to that item.
+type discountLine struct {
+	SKU         string  `json:"sku,omitempty"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// transactionOverride is an admin's edit or void of a transaction.
+// RoleID and OperatorID are the role and person ID of the admin, which are
+// taken from the verified token and never from the body, and ApprovalToken
+// is the second admin's approval of overrides above the dual-control
+// threshold.
+type transactionOverride struct {
+	RoleID        int            `json:"-"`
+	OperatorID    int            `json:"-"`
+	Action        string         `json:"action"`
+	Reason        string         `json:"reason"`
+	LineItems     []lineItemEdit `json:"lineItems,omitempty"`
+	ApprovalToken string         `json:"approvalToken,omitempty"`
+}
+
+// lineItemEdit corrects the count or price of a charged SKU, in the
+// transaction's currency. Fields that are left out are not changed, and a
+// count of zero removes the item.
+type lineItemEdit struct {
+	SKU       string   `json:"sku"`
+	ItemCount *int     `json:"itemCount,omitempty"`
+	ItemPrice *float64 `json:"itemPrice,omitempty"`
 }
 
 type deltaSKU struct {
 	SKU   string `json:"sku"`
 	Delta int    `json:"delta"`
 }
+
+// inventoryDelta is a deltaSKU posted to the inventory delta endpoint, with
+// the reason the stock moved and the service that moved it
+type inventoryDelta struct {
+	SKU    string      `json:"sku"`
+	Delta  int         `json:"delta"`
+	Reason string      `json:"reason"`
+	Source deltaSource `json:"source"`
+}
+
+type deltaSource struct {
+	Service string `json:"service"`
+}
+
+type paymentRequest struct {
+	Amount float64 `json:"amount"`
+	Method string  `json:"method"`
+}
+
+type refundInfo struct {
+	Restock bool `json:"restock"`
+}
+
+type containerReturn struct {
+	SKU   string `json:"sku"`
+	Count int    `json:"count"`
+}
+
+type splitLedger struct {
+	AccountIDs  []int             `json:"accountIds"`
+	Rule        string            `json:"rule"`
+	DeltaSKUs   []deltaSKU        `json:"deltaSKUs"`
+	Assignments []splitAssignment `json:"assignments,omitempty"`
+	SessionID   string            `json:"sessionId,omitempty"`
+	// FlagReason flags each transaction of the split for review before it
+	// is charged
+	FlagReason string `json:"flagReason,omitempty"`
+}
+
+type splitAssignment struct {
+	AccountID int    `json:"accountId"`
+	SKU       string `json:"sku"`
+	Count     int    `json:"count"`
+}
@@ -0,0 +1,689 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+const (
+	// RoleAdmin is the ms-authentication role that may edit and void
+	// transactions, which is the maintainer that administers the kiosk
+	RoleAdmin = RoleMaintainer
+
+	// OverrideActionEdit corrects the counts and prices of a transaction's
+	// charged items
+	OverrideActionEdit = "edit"
+	// OverrideActionVoid cancels a transaction so that it is not charged
+	OverrideActionVoid = "void"
+	// OverrideActionApprove accepts a transaction pending review as it is
+	OverrideActionApprove = "approve"
+
+	// DefaultApprovalTTL is how long a dual-control approval can be used
+	// by default
+	DefaultApprovalTTL = 5 * time.Minute
+)
+
+// isAdminRole checks whether the role may edit, void and approve overrides
+// of transactions. The admin cards of ms-authentication may do every
+// action, so they may too.
+func isAdminRole(roleID int) bool {
+	return roleID == RoleAdmin || roleID == utilities.AdminRoleID
+}
+
+// DualControl is the policy for admin overrides of transactions. Overrides
+// that change the transaction by more than ThresholdMinor must be approved
+// by a second admin, whose approval expires after ApprovalTTL.
+type DualControl struct {
+	ThresholdMinor int64
+	ApprovalTTL    time.Duration
+}
+
+// Approval is a one-time dual-control token issued by an admin to approve
+// an override of a transaction by another admin
+type Approval struct {
+	Token         string `json:"token"`
+	AccountID     int    `json:"accountID"`
+	TransactionID int64  `json:"transactionID,string"`
+	ApproverID    int    `json:"approverID"`
+	ExpiresAt     int64  `json:"expiresAt,string"`
+}
+
+// ApprovalStore keeps the approvals that have been issued and not yet used
+// or expired. Approvals are only kept in memory, so they do not survive a
+// restart. A nil ApprovalStore does not issue any approvals.
+type ApprovalStore struct {
+	mutex     sync.Mutex
+	ttl       time.Duration
+	approvals map[string]Approval
+	now       func() time.Time
+}
+
+// OverrideAudit records an admin's edit or void of a transaction, with the
+// transaction before and after the override
+type OverrideAudit struct {
+	AccountID     int    `json:"accountID"`
+	TransactionID int64  `json:"transactionID,string"`
+	Action        string `json:"action"`
+	Reason        string `json:"reason"`
+	RoleID        int    `json:"roleId"`
+	OperatorID    int    `json:"operatorId"`
+	// ApproverID is the admin that approved the override, which is zero
+	// for overrides within the dual-control threshold
+	ApproverID  int    `json:"approverId,omitempty"`
+	AmountMinor int64  `json:"amountMinor"`
+	Before      Ledger `json:"before"`
+	After       Ledger `json:"after"`
+	Timestamp   int64  `json:"timestamp,string"`
+}
+
+// OverrideAuditLog is the audit log of every admin override
+type OverrideAuditLog struct {
+	Data []OverrideAudit `json:"data"`
+}
+
+// NewApprovalStore creates an ApprovalStore whose approvals expire after
+// the TTL, or after DefaultApprovalTTL when the TTL is not positive
+func NewApprovalStore(ttl time.Duration) *ApprovalStore {
+	if ttl <= 0 {
+		ttl = DefaultApprovalTTL
+	}
+	return &ApprovalStore{
+		ttl:       ttl,
+		approvals: make(map[string]Approval),
+		now:       time.Now,
+	}
+}
+
+// Issue creates an approval by the approver for an override of the
+// transaction
+func (store *ApprovalStore) Issue(accountID int, transactionID int64, approverID int) (Approval, error) {
+	if store == nil {
+		return Approval{}, errors.New("dual-control approvals are not enabled")
+	}
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return Approval{}, errors.New("failed to generate approval token: " + err.Error())
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	for token, approval := range store.approvals {
+		if now.UnixNano() >= approval.ExpiresAt {
+			delete(store.approvals, token)
+		}
+	}
+	approval := Approval{
+		Token:         hex.EncodeToString(tokenBytes),
+		AccountID:     accountID,
+		TransactionID: transactionID,
+		ApproverID:    approverID,
+		ExpiresAt:     now.Add(store.ttl).UnixNano(),
+	}
+	store.approvals[approval.Token] = approval
+	return approval, nil
+}
+
+// Verify checks that the token approves an override of the transaction by
+// the operator. The approver must be a different admin than the operator.
+func (store *ApprovalStore) Verify(token string, accountID int, transactionID int64, operatorID int) (Approval, error) {
+	if store == nil {
+		return Approval{}, errors.New("dual-control approvals are not enabled")
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	approval, ok := store.approvals[token]
+	if !ok || store.now().UnixNano() >= approval.ExpiresAt {
+		return Approval{}, errors.New("approval token is not valid or has expired")
+	}
+	if approval.AccountID != accountID || approval.TransactionID != transactionID {
+		return Approval{}, errors.New("approval token was issued for a different transaction")
+	}
+	if approval.ApproverID == operatorID {
+		return Approval{}, errors.New("the approver must be a different admin than the operator")
+	}
+	return approval, nil
+}
+
+// Consume removes the approval so that it cannot be used again
+func (store *ApprovalStore) Consume(token string) {
+	if store == nil {
+		return
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.approvals, token)
+}
+
+// OverrideAuditFileName is the audit log of admin overrides, which is kept
+// next to the ledger file
+func OverrideAuditFileName(ledgerFileName string) string {
+	return strings.TrimSuffix(ledgerFileName, filepath.Ext(ledgerFileName)) + "-audit.json"
+}
+
+// getOverrideAuditLog loads the audit log of admin overrides, which is
+// empty until the first override
+func (c *Controller) getOverrideAuditLog() (OverrideAuditLog, error) {
+	auditLog := OverrideAuditLog{Data: []OverrideAudit{}}
+	data, err := os.ReadFile(OverrideAuditFileName(c.ledgerFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return auditLog, nil
+	}
+	if err != nil {
+		return OverrideAuditLog{}, errors.New("failed to read override audit log: " + err.Error())
+	}
+	if err = json.Unmarshal(data, &auditLog); err != nil {
+		return OverrideAuditLog{}, errors.New("failed to unmarshal override audit log: " + err.Error())
+	}
+	return auditLog, nil
+}
+
+// addOverrideAudit appends the override to the audit log
+func (c *Controller) addOverrideAudit(audit OverrideAudit) error {
+	auditLog, err := c.getOverrideAuditLog()
+	if err != nil {
+		return err
+	}
+	auditLog.Data = append(auditLog.Data, audit)
+	data, err := json.Marshal(auditLog)
+	if err != nil {
+		return errors.New("failed to marshal override audit log: " + err.Error())
+	}
+	return c.fileWriter.WriteFile(OverrideAuditFileName(c.ledgerFileName), data, 0644)
+}
+
+// applyEdits corrects the counts and prices of the transaction's charged
+// items, keeping the original price of repriced items. Items whose count is
+// corrected to zero are removed, and the totals are recalculated.
+func (ledger *Ledger) applyEdits(edits []lineItemEdit, reason string, currency Currency) error {
+	if len(edits) == 0 {
+		return errors.New("an edit requires at least one line item")
+	}
+	edited := map[string]bool{}
+	for _, edit := range edits {
+		if edited[edit.SKU] {
+			return fmt.Errorf("SKU %s is edited more than once", edit.SKU)
+		}
+		edited[edit.SKU] = true
+		lineItem := ledger.chargedLineItem(edit.SKU)
+		if lineItem == nil {
+			return fmt.Errorf("SKU %s is not charged in the transaction and cannot be edited", edit.SKU)
+		}
+		if edit.ItemCount == nil && edit.ItemPrice == nil {
+			return fmt.Errorf("edit of SKU %s does not change its count or price", edit.SKU)
+		}
+		if edit.ItemCount != nil {
+			if *edit.ItemCount < 0 {
+				return fmt.Errorf("count of SKU %s must not be negative", edit.SKU)
+			}
+			lineItem.ItemCount = *edit.ItemCount
+		}
+		if edit.ItemPrice != nil {
+			if *edit.ItemPrice < 0 {
+				return fmt.Errorf("price of SKU %s must not be negative", edit.SKU)
+			}
+			if !lineItem.PriceOverridden {
+				lineItem.PriceOverridden = true
+				lineItem.OriginalItemPriceMinor = lineItem.ItemPriceMinor
+			}
+			lineItem.ItemPriceMinor = currency.ToMinor(*edit.ItemPrice)
+			lineItem.OverrideReason = reason
+		}
+	}
+
+	lineItems := []LineItem{}
+	for _, lineItem := range ledger.LineItems {
+		if edited[lineItem.SKU] && lineItem.ItemCount == 0 && !lineItem.Discount {
+			continue
+		}
+		lineItems = append(lineItems, lineItem)
+	}
+	ledger.LineItems = lineItems
+
+	ledger.calculateTotals()
+	if ledger.SubtotalMinor < 0 || ledger.LineTotalMinor < 0 {
+		return errors.New("the edited transaction total must not be negative")
+	}
+	ledger.setAmounts(currency)
+	ledger.IsEdited = true
+	return nil
+}
+
+// void cancels the transaction, keeping its items for audit and zeroing
+// its amounts so that nothing is charged
+func (ledger *Ledger) void(reason string) {
+	ledger.IsVoided = true
+	ledger.VoidReason = reason
+	ledger.LineTotal = 0
+	ledger.Subtotal = 0
+	ledger.Tax = 0
+	ledger.LineTotalMinor = 0
+	ledger.SubtotalMinor = 0
+	ledger.TaxMinor = 0
+	ledger.DisplayTotal = ""
+}
+
+// LedgerOverride lets an admin edit or void an unpaid transaction. Overrides
+// that change the transaction total by more than the dual-control threshold
+// require an approval token from a second admin. Every override is recorded
+// in the audit log with the transaction before and after it.
+func (c *Controller) LedgerOverride(writer http.ResponseWriter, req *http.Request) {
+	// Get variables from HTTP request
+	vars := mux.Vars(req)
+	tidstr := vars["tid"]
+	tid, err := strconv.ParseInt(tidstr, 10, 64)
+	if err != nil {
+		errMsg := "transactionID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	claims, ok := c.operatorClaims(writer, req)
+	if !ok {
+		return
+	}
+
+	var override transactionOverride
+	if statusCode, err := c.decodeJSONBody(writer, req, &override); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+	override.RoleID = claims.RoleID
+	override.OperatorID = claims.PersonID
+	if override.Action != OverrideActionEdit && override.Action != OverrideActionVoid {
+		errMsg := fmt.Sprintf("Unknown override action %q, expected %s or %s", override.Action, OverrideActionEdit, OverrideActionVoid)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.overrideTransaction(writer, accountID, tid, override, false)
+}
+
+// overrideTransaction applies the admin's override to the transaction and
+// writes the overridden transaction. A review override must be of a
+// transaction pending review, and any override of a transaction pending
+// review resolves its review.
+func (c *Controller) overrideTransaction(writer http.ResponseWriter, accountID int, tid int64, override transactionOverride, review bool) {
+	tidstr := strconv.FormatInt(tid, 10)
+	if !isAdminRole(override.RoleID) {
+		errMsg := fmt.Sprintf("Role %v may not edit or void transactions", override.RoleID)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if override.OperatorID <= 0 {
+		errMsg := "The token of the admin names no person to record as the operator"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	override.Reason = strings.TrimSpace(override.Reason)
+	if override.Reason == "" {
+		errMsg := "An override requires a reason"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	var transaction *Ledger
+	var transactionAccount Account
+	for accountIndex, account := range accountLedgers.Data {
+		if account.AccountID != accountID {
+			continue
+		}
+		for transactionIndex, ledger := range account.Ledgers {
+			if ledger.TransactionID == tid {
+				transaction = &accountLedgers.Data[accountIndex].Ledgers[transactionIndex]
+				transactionAccount = account
+				break
+			}
+		}
+		break
+	}
+	if transaction == nil {
+		errMsg := fmt.Sprintf("Could not find Transaction %v for account %v", tidstr, strconv.Itoa(accountID))
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	if review && !transaction.pendingReview() {
+		errMsg := fmt.Sprintf("Transaction %v is not pending review", tidstr)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusConflict)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	// paid transactions are corrected with a refund, so that the payment
+	// is reversed as well. An approval does not change the transaction, so
+	// any transaction pending review may be approved.
+	var errMsg string
+	switch {
+	case override.Action == OverrideActionApprove:
+	case transaction.IsPaid:
+		errMsg = fmt.Sprintf("Transaction %v is paid and must be refunded instead", tidstr)
+	case len(transaction.Payments) > 0:
+		errMsg = fmt.Sprintf("Transaction %v has partial payments and must be refunded instead", tidstr)
+	case transaction.RefundOf != 0:
+		errMsg = fmt.Sprintf("Transaction %v is a refund and cannot be overridden", tidstr)
+	case transaction.IsVoided:
+		errMsg = fmt.Sprintf("Transaction %v is already voided", tidstr)
+	case override.Action == OverrideActionEdit && transaction.Currency == "":
+		errMsg = fmt.Sprintf("Transaction %v was recorded without a currency and cannot be edited", tidstr)
+	}
+	if errMsg != "" {
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	currency, ok := c.currency.Lookup(transaction.Currency)
+	if !ok {
+		errMsg := fmt.Sprintf("Transaction currency %s is not a known currency", transaction.Currency)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	before := *transaction
+	before.LineItems = append([]LineItem{}, transaction.LineItems...)
+	beforeMinor := transaction.amountDueMinor(currency)
+
+	switch override.Action {
+	case OverrideActionEdit:
+		if err := transaction.applyEdits(override.LineItems, override.Reason, currency); err != nil {
+			errMsg := fmt.Sprintf("Failed to edit transaction %v: %v", tidstr, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	case OverrideActionVoid:
+		if len(override.LineItems) > 0 {
+			errMsg := "A void does not take line items"
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		transaction.void(override.Reason)
+	case OverrideActionApprove:
+		if len(override.LineItems) > 0 {
+			errMsg := "An approval does not take line items, adjust the transaction instead"
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	// the override's amount is how much it changes what the account owes
+	amountMinor := beforeMinor - transaction.amountDueMinor(currency)
+	if amountMinor < 0 {
+		amountMinor = -amountMinor
+	}
+	var approval Approval
+	if amountMinor > c.dualControl.ThresholdMinor {
+		if override.ApprovalToken == "" {
+			errMsg := fmt.Sprintf("Override of %s exceeds the dual-control threshold of %s and requires an approval token", currency.Format(amountMinor), currency.Format(c.dualControl.ThresholdMinor))
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusForbidden)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		approval, err = c.approvals.Verify(override.ApprovalToken, accountID, tid, override.OperatorID)
+		if err != nil {
+			errMsg := fmt.Sprintf("Override of transaction %v is not approved: %v", tidstr, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusForbidden)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	// a voided transaction is not charged, so its hold is no longer needed
+	if transaction.IsVoided && transaction.Hold != nil && transaction.Hold.Status == HoldStatusAuthorized && c.paymentProvider != nil {
+		if err := c.paymentProvider.Release(transaction.Hold.AuthorizationID); err != nil {
+			errMsg := fmt.Sprintf("Failed to release hold %v: %v", transaction.Hold.AuthorizationID, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadGateway)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		transaction.Hold.Status = HoldStatusReleased
+		c.lc.Infof("Released hold %s for voided transaction %s", transaction.Hold.AuthorizationID, tidstr)
+	}
+
+	now := time.Now().UnixNano()
+	transaction.UpdatedAt = now
+	if transaction.pendingReview() {
+		transaction.resolveReview(override.Action, override.OperatorID, now)
+		// the reviewed transaction is no longer flagged, so its hold is
+		// settled as it would have been when it was created
+		if !transaction.IsVoided {
+			c.settleHoldOnCreate(transactionAccount, transaction)
+		}
+	}
+	audit := OverrideAudit{
+		AccountID:     accountID,
+		TransactionID: tid,
+		Action:        override.Action,
+		Reason:        override.Reason,
+		RoleID:        override.RoleID,
+		OperatorID:    override.OperatorID,
+		ApproverID:    approval.ApproverID,
+		AmountMinor:   amountMinor,
+		Before:        before,
+		After:         *transaction,
+		Timestamp:     now,
+	}
+	// the override is audited before it is saved, so that no override is
+	// ever saved without its audit
+	if err = c.addOverrideAudit(audit); err != nil {
+		errMsg := "failed to write override audit log"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if err = c.saveLedgers(accountLedgers, accountID); err != nil {
+		errMsg := "failed to write ledger JSON file for override"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.approvals.Consume(override.ApprovalToken)
+
+	switch {
+	case override.Action == OverrideActionApprove:
+		c.lc.Infof("Admin %d approved transaction %s of account %d after review: %s", override.OperatorID, tidstr, accountID, override.Reason)
+	case approval.ApproverID != 0:
+		c.lc.Infof("Admin %d %sed transaction %s of account %d for %s, approved by admin %d: %s", override.OperatorID, override.Action, tidstr, accountID, currency.Format(amountMinor), approval.ApproverID, override.Reason)
+	default:
+		c.lc.Infof("Admin %d %sed transaction %s of account %d for %s: %s", override.OperatorID, override.Action, tidstr, accountID, currency.Format(amountMinor), override.Reason)
+	}
+	switch {
+	case transaction.IsVoided:
+		c.publishLedgerEvent(LedgerEventVoided, accountID, *transaction)
+	case override.Action == OverrideActionApprove:
+		c.publishLedgerEvent(LedgerEventReviewed, accountID, *transaction)
+	default:
+		c.publishLedgerEvent(LedgerEventEdited, accountID, *transaction)
+	}
+
+	transactionJSON, err := json.Marshal(transaction)
+	if err != nil {
+		errMsg := "Failed to marshal transaction"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Write(transactionJSON)
+}
+
+// LedgerApprovalPost issues a dual-control approval token to an admin, for
+// another admin to edit or void the transaction
+func (c *Controller) LedgerApprovalPost(writer http.ResponseWriter, req *http.Request) {
+	// Get variables from HTTP request
+	vars := mux.Vars(req)
+	tidstr := vars["tid"]
+	tid, err := strconv.ParseInt(tidstr, 10, 64)
+	if err != nil {
+		errMsg := "transactionID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	approver, ok := c.operatorClaims(writer, req)
+	if !ok {
+		return
+	}
+	if !isAdminRole(approver.RoleID) {
+		errMsg := fmt.Sprintf("Role %v may not approve transaction overrides", approver.RoleID)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if approver.PersonID <= 0 {
+		errMsg := "The token of the admin names no person to record as the approver"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	found := false
+	for _, account := range accountLedgers.Data {
+		if account.AccountID != accountID {
+			continue
+		}
+		for _, ledger := range account.Ledgers {
+			if ledger.TransactionID == tid {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		errMsg := fmt.Sprintf("Could not find Transaction %v for account %v", tidstr, strconv.Itoa(accountID))
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	approval, err := c.approvals.Issue(accountID, tid, approver.PersonID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to approve override of transaction %v: %v", tidstr, err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Admin %d approved an override of transaction %s of account %d", approver.PersonID, tidstr, accountID)
+
+	approvalJSON, err := json.Marshal(approval)
+	if err != nil {
+		errMsg := "Failed to marshal approval"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Write(approvalJSON)
+}
+
+// LedgerAuditGet returns the audit log of every admin override
+func (c *Controller) LedgerAuditGet(writer http.ResponseWriter, req *http.Request) {
+	auditLog, err := c.getOverrideAuditLog()
+	if err != nil {
+		errMsg := "Failed to retrieve the override audit log"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	auditLogJSON, err := json.Marshal(auditLog)
+	if err != nil {
+		errMsg := "Failed to marshal the override audit log"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Write(auditLogJSON)
+}
@@ -0,0 +1,342 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const overrideTransactionID = "1579215712984890248"
+
+// getOverrideAccountLedgers returns an account with an unpaid transaction
+// of two items at 1.99 USD
+func getOverrideAccountLedgers() Accounts {
+	accountLedgers := getDefaultAccountLedgers()
+	ledger := &accountLedgers.Data[0].Ledgers[0]
+	ledger.LineItems[0].ItemCount = 2
+	ledger.LineItems[0].ItemPriceMinor = 199
+	ledger.calculateTotals()
+	ledger.setAmounts(CurrencyConverter{}.Base())
+	return accountLedgers
+}
+
+func newOverrideController(t *testing.T) Controller {
+	c := Controller{
+		lc:             logger.NewMockClient(),
+		ledgerFileName: filepath.Join(t.TempDir(), LedgerFileName),
+		dualControl:    DualControl{ThresholdMinor: 200},
+		approvals:      NewApprovalStore(time.Minute),
+		tokenVerifier:  utilities.NewTokenVerifier(testTokenSecret),
+	}
+	require.NoError(t, c.saveLedgers(getOverrideAccountLedgers()))
+	return c
+}
+
+func TestApprovalStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewApprovalStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	approval, err := store.Issue(1, 10, 7)
+	require.NoError(t, err)
+	assert.NotEmpty(t, approval.Token)
+	assert.Equal(t, now.Add(time.Minute).UnixNano(), approval.ExpiresAt)
+
+	verified, err := store.Verify(approval.Token, 1, 10, 8)
+	require.NoError(t, err)
+	assert.Equal(t, approval, verified)
+
+	_, err = store.Verify(approval.Token, 1, 11, 8)
+	assert.Error(t, err, "approvals are only valid for their transaction")
+	_, err = store.Verify(approval.Token, 1, 10, 7)
+	assert.Error(t, err, "admins may not approve their own overrides")
+	_, err = store.Verify("unknown", 1, 10, 8)
+	assert.Error(t, err)
+
+	store.Consume(approval.Token)
+	_, err = store.Verify(approval.Token, 1, 10, 8)
+	assert.Error(t, err, "approvals may only be used once")
+
+	approval, err = store.Issue(1, 10, 7)
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = store.Verify(approval.Token, 1, 10, 8)
+	assert.Error(t, err, "approvals expire after the TTL")
+
+	var disabled *ApprovalStore
+	_, err = disabled.Issue(1, 10, 7)
+	assert.Error(t, err)
+	disabled.Consume(approval.Token)
+}
+
+func TestLedgerOverride(t *testing.T) {
+	tests := []struct {
+		Name               string
+		TransactionID      string
+		Body               string
+		Authorization      string
+		IsPaid             bool
+		ApproverID         int
+		ExpectedStatusCode int
+		ExpectedTotalMinor int64
+		ExpectedItems      int
+		ExpectedVoided     bool
+	}{
+		{"Edit count within threshold", overrideTransactionID, `{"action":"edit","reason":"one item was scanned twice","lineItems":[{"sku":"1200050408","itemCount":1}]}`, bearerToken(1, RoleAdmin), false, 0, http.StatusOK, 199, 1, false},
+		{"Edit price within threshold", overrideTransactionID, `{"action":"edit","reason":"shelf price","lineItems":[{"sku":"1200050408","itemPrice":0.99}]}`, bearerToken(1, RoleAdmin), false, 0, http.StatusOK, 198, 1, false},
+		{"Edit above threshold without approval", overrideTransactionID, `{"action":"edit","reason":"nothing was taken","lineItems":[{"sku":"1200050408","itemCount":0}]}`, bearerToken(1, RoleAdmin), false, 0, http.StatusForbidden, 398, 1, false},
+		{"Edit above threshold with approval", overrideTransactionID, `{"action":"edit","reason":"nothing was taken","lineItems":[{"sku":"1200050408","itemCount":0}]}`, bearerToken(1, RoleAdmin), false, 2, http.StatusOK, 0, 0, false},
+		{"Void without approval", overrideTransactionID, `{"action":"void","reason":"door fault"}`, bearerToken(1, RoleAdmin), false, 0, http.StatusForbidden, 398, 1, false},
+		{"Void with approval", overrideTransactionID, `{"action":"void","reason":"door fault"}`, bearerToken(1, RoleAdmin), false, 2, http.StatusOK, 0, 1, true},
+		{"Void approved by operator", overrideTransactionID, `{"action":"void","reason":"door fault"}`, bearerToken(1, RoleAdmin), false, 1, http.StatusForbidden, 398, 1, false},
+		{"Void with line items", overrideTransactionID, `{"action":"void","reason":"door fault","lineItems":[{"sku":"1200050408","itemCount":1}]}`, bearerToken(1, RoleAdmin), false, 2, http.StatusBadRequest, 398, 1, false},
+		{"Not an admin", overrideTransactionID, `{"action":"void","reason":"door fault"}`, bearerToken(1, RoleStocker), false, 2, http.StatusForbidden, 398, 1, false},
+		{"Unknown action", overrideTransactionID, `{"action":"delete","reason":"door fault"}`, bearerToken(1, RoleAdmin), false, 0, http.StatusBadRequest, 398, 1, false},
+		{"Missing reason", overrideTransactionID, `{"action":"void","reason":" "}`, bearerToken(1, RoleAdmin), false, 2, http.StatusBadRequest, 398, 1, false},
+		{"Admin card", overrideTransactionID, `{"action":"void","reason":"door fault"}`, bearerToken(1, utilities.AdminRoleID), false, 2, http.StatusOK, 0, 1, true},
+		{"Missing token", overrideTransactionID, `{"action":"void","reason":"door fault"}`, "", false, 2, http.StatusUnauthorized, 398, 1, false},
+		{"Token without person", overrideTransactionID, `{"action":"void","reason":"door fault"}`, bearerToken(0, RoleAdmin), false, 2, http.StatusBadRequest, 398, 1, false},
+		{"Operator in body", overrideTransactionID, `{"roleId":3,"operatorId":2,"action":"void","reason":"door fault"}`, bearerToken(1, RoleAdmin), false, 2, http.StatusBadRequest, 398, 1, false},
+		{"Edit unknown SKU", overrideTransactionID, `{"action":"edit","reason":"wrong item","lineItems":[{"sku":"0000000000","itemCount":1}]}`, bearerToken(1, RoleAdmin), false, 0, http.StatusBadRequest, 398, 1, false},
+		{"Edit without line items", overrideTransactionID, `{"action":"edit","reason":"wrong item"}`, bearerToken(1, RoleAdmin), false, 0, http.StatusBadRequest, 398, 1, false},
+		{"Paid transaction", overrideTransactionID, `{"action":"void","reason":"door fault"}`, bearerToken(1, RoleAdmin), true, 2, http.StatusBadRequest, 398, 1, false},
+		{"Transaction not found", "2579215712984890248", `{"action":"void","reason":"door fault"}`, bearerToken(1, RoleAdmin), false, 0, http.StatusNotFound, 398, 1, false},
+		{"Invalid transaction ID", "abc", `{"action":"void","reason":"door fault"}`, bearerToken(1, RoleAdmin), false, 0, http.StatusBadRequest, 398, 1, false},
+		{"Invalid body", overrideTransactionID, `{"action":`, bearerToken(1, RoleAdmin), false, 0, http.StatusBadRequest, 398, 1, false},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newOverrideController(t)
+			if currentTest.IsPaid {
+				accountLedgers := getOverrideAccountLedgers()
+				accountLedgers.Data[0].Ledgers[0].IsPaid = true
+				require.NoError(t, c.saveLedgers(accountLedgers))
+			}
+
+			body := currentTest.Body
+			if currentTest.ApproverID != 0 {
+				tid, _ := strconv.ParseInt(overrideTransactionID, 10, 64)
+				approval, err := c.approvals.Issue(1, tid, currentTest.ApproverID)
+				require.NoError(t, err)
+				body = body[:len(body)-1] + `,"approvalToken":"` + approval.Token + `"}`
+			}
+
+			req := httptest.NewRequest("PUT", "http://localhost:48093/ledger/1/"+currentTest.TransactionID, bytes.NewBuffer([]byte(body)))
+			req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": currentTest.TransactionID})
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			c.LedgerOverride(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			accountLedgers, err := c.GetAllLedgers()
+			require.NoError(t, err)
+			ledger := accountLedgers.Data[0].Ledgers[0]
+			assert.Equal(t, currentTest.ExpectedTotalMinor, ledger.LineTotalMinor)
+			assert.Len(t, ledger.LineItems, currentTest.ExpectedItems)
+			assert.Equal(t, currentTest.ExpectedVoided, ledger.IsVoided)
+
+			auditLog, err := c.getOverrideAuditLog()
+			require.NoError(t, err)
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				assert.Empty(t, auditLog.Data, "rejected overrides are not audited")
+				return
+			}
+
+			var updated Ledger
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&updated))
+			assert.Equal(t, ledger, updated)
+
+			require.Len(t, auditLog.Data, 1)
+			audit := auditLog.Data[0]
+			assert.Equal(t, getOverrideAccountLedgers().Data[0].Ledgers[0], audit.Before)
+			assert.Equal(t, ledger, audit.After)
+			assert.Equal(t, 1, audit.OperatorID)
+			assert.Equal(t, currentTest.ApproverID, audit.ApproverID)
+			assert.Equal(t, 398-currentTest.ExpectedTotalMinor, audit.AmountMinor)
+		})
+	}
+}
+
+func TestLedgerOverrideApprovalUsedOnce(t *testing.T) {
+	c := newOverrideController(t)
+	tid, _ := strconv.ParseInt(overrideTransactionID, 10, 64)
+	approval, err := c.approvals.Issue(1, tid, 2)
+	require.NoError(t, err)
+
+	body := `{"action":"edit","reason":"nothing was taken","lineItems":[{"sku":"1200050408","itemCount":0}],"approvalToken":"` + approval.Token + `"}`
+	req := httptest.NewRequest("PUT", "http://localhost:48093/ledger/1/"+overrideTransactionID, bytes.NewBuffer([]byte(body)))
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(1, RoleAdmin))
+	w := httptest.NewRecorder()
+	c.LedgerOverride(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	require.NoError(t, c.saveLedgers(getOverrideAccountLedgers()))
+	req = httptest.NewRequest("PUT", "http://localhost:48093/ledger/1/"+overrideTransactionID, bytes.NewBuffer([]byte(body)))
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(1, RoleAdmin))
+	w = httptest.NewRecorder()
+	c.LedgerOverride(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+}
+
+// TestLedgerOverrideOwnApproval tests that the approver and operator are the
+// people of the tokens, so that an admin cannot approve their own override
+func TestLedgerOverrideOwnApproval(t *testing.T) {
+	c := newOverrideController(t)
+
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger/1/"+overrideTransactionID+"/approval", nil)
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(1, RoleAdmin))
+	w := httptest.NewRecorder()
+	c.LedgerApprovalPost(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var approval Approval
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&approval))
+
+	body := `{"action":"void","reason":"door fault","approvalToken":"` + approval.Token + `"}`
+	req = httptest.NewRequest("PUT", "http://localhost:48093/ledger/1/"+overrideTransactionID, bytes.NewBuffer([]byte(body)))
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(1, RoleAdmin))
+	w = httptest.NewRecorder()
+	c.LedgerOverride(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code, "the approver of the token is the operator")
+
+	req = httptest.NewRequest("PUT", "http://localhost:48093/ledger/1/"+overrideTransactionID, bytes.NewBuffer([]byte(body)))
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(2, RoleAdmin))
+	w = httptest.NewRecorder()
+	c.LedgerOverride(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// TestLedgerOverrideWithoutTokenSecret tests that overrides are refused when
+// no token secret is set, because the operator cannot be verified
+func TestLedgerOverrideWithoutTokenSecret(t *testing.T) {
+	c := newOverrideController(t)
+	c.tokenVerifier = nil
+
+	body := `{"action":"edit","reason":"shelf price","lineItems":[{"sku":"1200050408","itemPrice":0.99}]}`
+	req := httptest.NewRequest("PUT", "http://localhost:48093/ledger/1/"+overrideTransactionID, bytes.NewBuffer([]byte(body)))
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(1, RoleAdmin))
+	w := httptest.NewRecorder()
+	c.LedgerOverride(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req = httptest.NewRequest("POST", "http://localhost:48093/ledger/1/"+overrideTransactionID+"/approval", nil)
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(2, RoleAdmin))
+	w = httptest.NewRecorder()
+	c.LedgerApprovalPost(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestLedgerApprovalPost(t *testing.T) {
+	tests := []struct {
+		Name               string
+		TransactionID      string
+		Authorization      string
+		ExpectedStatusCode int
+	}{
+		{"Approved", overrideTransactionID, bearerToken(2, RoleAdmin), http.StatusOK},
+		{"Not an admin", overrideTransactionID, bearerToken(2, RoleStocker), http.StatusForbidden},
+		{"Missing token", overrideTransactionID, "", http.StatusUnauthorized},
+		{"Token without person", overrideTransactionID, bearerToken(0, RoleAdmin), http.StatusBadRequest},
+		{"Transaction not found", "2579215712984890248", bearerToken(2, RoleAdmin), http.StatusNotFound},
+		{"Invalid transaction ID", "abc", bearerToken(2, RoleAdmin), http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newOverrideController(t)
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/1/"+currentTest.TransactionID+"/approval", nil)
+			req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": currentTest.TransactionID})
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			c.LedgerApprovalPost(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			require.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var approval Approval
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&approval))
+			assert.Equal(t, 2, approval.ApproverID)
+			_, err := c.approvals.Verify(approval.Token, 1, approval.TransactionID, 1)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLedgerAuditGet(t *testing.T) {
+	c := newOverrideController(t)
+
+	req := httptest.NewRequest("GET", "http://localhost:48093/ledger/audit", nil)
+	w := httptest.NewRecorder()
+	c.LedgerAuditGet(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	var auditLog OverrideAuditLog
+	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&auditLog))
+	assert.Empty(t, auditLog.Data)
+
+	require.NoError(t, c.addOverrideAudit(OverrideAudit{AccountID: 1, Action: OverrideActionVoid, Reason: "door fault"}))
+	w = httptest.NewRecorder()
+	c.LedgerAuditGet(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&auditLog))
+	require.Len(t, auditLog.Data, 1)
+	assert.Equal(t, OverrideActionVoid, auditLog.Data[0].Action)
+
+	require.NoError(t, os.WriteFile(OverrideAuditFileName(c.ledgerFileName), []byte(`{"data":`), 0644))
+	w = httptest.NewRecorder()
+	c.LedgerAuditGet(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestVoidedTransactionNotCharged(t *testing.T) {
+	accountLedgers := getOverrideAccountLedgers()
+	accountLedgers.Data[0].Ledgers[0].void("door fault")
+
+	balance := accountBalance(accountLedgers.Data[0], CurrencyConverter{})
+	assert.Zero(t, balance.UnpaidBalanceMinor)
+	assert.Zero(t, balance.UnpaidTransactions)
+
+	builder, err := newSalesReportBuilder(ReportGroupByDay, CurrencyConverter{}, time.UTC)
+	require.NoError(t, err)
+	builder.Add(1, accountLedgers.Data[0].Ledgers[0])
+	assert.Equal(t, 1, builder.report.VoidedTransactions)
+	assert.Empty(t, builder.report.Groups)
+}
+
+func TestOverrideAuditFileName(t *testing.T) {
+	assert.Equal(t, "/tmp/ledger-audit.json", OverrideAuditFileName("/tmp/ledger.json"))
+}
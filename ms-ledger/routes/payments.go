@@ -0,0 +1,196 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// paidMinor sums the partial payments made towards the transaction
+func (ledger Ledger) paidMinor() int64 {
+	var paidMinor int64
+	for _, payment := range ledger.Payments {
+		paidMinor = paidMinor + payment.AmountMinor
+	}
+	return paidMinor
+}
+
+// amountDueMinor is the part of the transaction's total that is not yet
+// covered by partial payments. Transactions recorded before minor units
+// were added only have a decimal total in the given currency.
+func (ledger Ledger) amountDueMinor(currency Currency) int64 {
+	totalMinor := ledger.LineTotalMinor
+	if ledger.Currency == "" {
+		totalMinor = currency.ToMinor(ledger.LineTotal)
+	}
+	return totalMinor - ledger.paidMinor()
+}
+
+// LedgerAddPayment records a partial payment towards a transaction, so that
+// it can be settled over several payments. The transaction is paid once
+// its payments cover the LineTotal, and a pending hold is then released
+// since there is nothing left to capture.
+func (c *Controller) LedgerAddPayment(writer http.ResponseWriter, req *http.Request) {
+	// Get variables from HTTP request
+	vars := mux.Vars(req)
+	tidstr := vars["tid"]
+	tid, err := strconv.ParseInt(tidstr, 10, 64)
+	if err != nil {
+		errMsg := "transactionID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	var request paymentRequest
+	if statusCode, err := c.decodeJSONBody(writer, req, &request); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+	if request.Amount <= 0 {
+		errMsg := "Payment amount must be greater than zero"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if strings.TrimSpace(request.Method) == "" {
+		errMsg := "Payment method is required"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	var transaction *Ledger
+	for accountIndex, account := range accountLedgers.Data {
+		if account.AccountID != accountID {
+			continue
+		}
+		for transactionIndex, ledger := range account.Ledgers {
+			if ledger.TransactionID == tid {
+				transaction = &accountLedgers.Data[accountIndex].Ledgers[transactionIndex]
+				break
+			}
+		}
+		break
+	}
+	if transaction == nil {
+		errMsg := fmt.Sprintf("Could not find Transaction %v for account %v", tidstr, strconv.Itoa(accountID))
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if transaction.IsPaid {
+		errMsg := fmt.Sprintf("Transaction %v is already paid", tidstr)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	currency, ok := c.currency.Lookup(transaction.Currency)
+	if !ok {
+		errMsg := fmt.Sprintf("Transaction currency %s is not a known currency", transaction.Currency)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	dueMinor := transaction.amountDueMinor(currency)
+	if dueMinor <= 0 {
+		errMsg := fmt.Sprintf("Transaction %v has no amount due", tidstr)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	amountMinor := currency.ToMinor(request.Amount)
+	if amountMinor > dueMinor {
+		errMsg := fmt.Sprintf("Payment of %s exceeds the %s due on transaction %v", currency.Format(amountMinor), currency.Format(dueMinor), tidstr)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	now := time.Now().UnixNano()
+	transaction.Payments = append(transaction.Payments, Payment{
+		Amount:      currency.FromMinor(amountMinor),
+		AmountMinor: amountMinor,
+		Timestamp:   now,
+		Method:      request.Method,
+	})
+	transaction.UpdatedAt = now
+	transaction.IsPaid = amountMinor == dueMinor
+	if transaction.IsPaid {
+		transaction.PaidAt = now
+	}
+
+	// the payments settled the transaction, so its hold is no longer needed
+	if transaction.IsPaid && transaction.Hold != nil && transaction.Hold.Status == HoldStatusAuthorized && c.paymentProvider != nil {
+		if err := c.paymentProvider.Release(transaction.Hold.AuthorizationID); err != nil {
+			errMsg := fmt.Sprintf("Failed to release hold %v: %v", transaction.Hold.AuthorizationID, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadGateway)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		transaction.Hold.Status = HoldStatusReleased
+		c.lc.Infof("Released hold %s for transaction %s, paid by partial payments", transaction.Hold.AuthorizationID, tidstr)
+	}
+
+	if err = c.saveLedgers(accountLedgers, accountID); err != nil {
+		errMsg := "failed to write ledger JSON file for payment"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Recorded %s %s payment for transaction %s", currency.Format(amountMinor), request.Method, tidstr)
+	if transaction.IsPaid {
+		c.publishLedgerEvent(LedgerEventPaid, accountID, *transaction)
+	}
+
+	transactionJSON, err := json.Marshal(transaction)
+	if err != nil {
+		c.lc.Warnf("Recorded payment successfully with error %s", err.Error())
+		writer.Write([]byte("Recorded payment successfully, but could not marshal to json"))
+		return
+	}
+	writer.Write(transactionJSON)
+}
@@ -0,0 +1,174 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"ms-ledger/payment"
+	paymentMocks "ms-ledger/payment/mocks"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerAddPayment(t *testing.T) {
+	tests := []struct {
+		Name               string
+		AccountID          string
+		TransactionID      string
+		Body               string
+		PriorPayments      []Payment
+		IsPaid             bool
+		ExpectedStatusCode int
+		ExpectedPayments   int
+		ExpectedIsPaid     bool
+	}{
+		{"Partial payment", "1", "1579215712984890248", `{"amount":1.00,"method":"cash"}`, nil, false, http.StatusOK, 1, false},
+		{"Payment settles the transaction", "1", "1579215712984890248", `{"amount":0.99,"method":"card"}`, []Payment{{Amount: 1, AmountMinor: 100, Method: "cash"}}, false, http.StatusOK, 2, true},
+		{"Full payment", "1", "1579215712984890248", `{"amount":1.99,"method":"cash"}`, nil, false, http.StatusOK, 1, true},
+		{"Payment exceeds amount due", "1", "1579215712984890248", `{"amount":1.00,"method":"cash"}`, []Payment{{Amount: 1, AmountMinor: 100, Method: "cash"}}, false, http.StatusBadRequest, 1, false},
+		{"Already paid", "1", "1579215712984890248", `{"amount":1.00,"method":"cash"}`, nil, true, http.StatusBadRequest, 0, true},
+		{"Zero amount", "1", "1579215712984890248", `{"amount":0,"method":"cash"}`, nil, false, http.StatusBadRequest, 0, false},
+		{"Missing method", "1", "1579215712984890248", `{"amount":1.00}`, nil, false, http.StatusBadRequest, 0, false},
+		{"Invalid body", "1", "1579215712984890248", `{"amount":`, nil, false, http.StatusBadRequest, 0, false},
+		{"Transaction not found", "1", "2579215712984890248", `{"amount":1.00,"method":"cash"}`, nil, false, http.StatusNotFound, 0, false},
+		{"Account not found", "9", "1579215712984890248", `{"amount":1.00,"method":"cash"}`, nil, false, http.StatusNotFound, 0, false},
+		{"Invalid transaction ID", "1", "abc", `{"amount":1.00,"method":"cash"}`, nil, false, http.StatusBadRequest, 0, false},
+		{"Invalid account ID", "abc", "1579215712984890248", `{"amount":1.00,"method":"cash"}`, nil, false, http.StatusBadRequest, 0, false},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:             logger.NewMockClient(),
+				service:        nil,
+				ledgerFileName: LedgerFileName,
+			}
+			accountLedgers := getDefaultAccountLedgers()
+			accountLedgers.Data[0].Ledgers[0].Payments = currentTest.PriorPayments
+			accountLedgers.Data[0].Ledgers[0].IsPaid = currentTest.IsPaid
+			data, err := json.Marshal(accountLedgers)
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/"+currentTest.AccountID+"/"+currentTest.TransactionID+"/payments", bytes.NewBuffer([]byte(currentTest.Body)))
+			req = mux.SetURLVars(req, map[string]string{"accountid": currentTest.AccountID, "tid": currentTest.TransactionID})
+			w := httptest.NewRecorder()
+			c.LedgerAddPayment(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			accountLedgers, err = c.GetAllLedgers()
+			require.NoError(t, err)
+			ledger := accountLedgers.Data[0].Ledgers[0]
+			assert.Equal(t, currentTest.ExpectedIsPaid, ledger.IsPaid)
+			assert.Equal(t, currentTest.ExpectedIsPaid && !currentTest.IsPaid, ledger.PaidAt != 0, "paidAt is set when the payment settles the transaction")
+			assert.Len(t, ledger.Payments, currentTest.ExpectedPayments)
+
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var updated Ledger
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&updated))
+			assert.Equal(t, ledger, updated)
+			assert.NotZero(t, updated.Payments[len(updated.Payments)-1].Timestamp)
+		})
+	}
+}
+
+func TestLedgerAddPaymentReleasesHold(t *testing.T) {
+	mockProvider := &paymentMocks.Provider{}
+	mockProvider.On("Release", "ch_hold").Return(nil)
+
+	c := Controller{
+		lc:              logger.NewMockClient(),
+		service:         nil,
+		ledgerFileName:  LedgerFileName,
+		paymentProvider: mockProvider,
+	}
+	accountLedgers := getDefaultAccountLedgers()
+	accountLedgers.Data[0].Ledgers[0].Currency = "USD"
+	accountLedgers.Data[0].Ledgers[0].LineTotalMinor = 199
+	accountLedgers.Data[0].Ledgers[0].Hold = &Hold{AuthorizationID: "ch_hold", AmountMinor: 2000, Currency: "USD", Status: HoldStatusAuthorized}
+	data, err := json.Marshal(accountLedgers)
+	require.NoError(t, err)
+	err = os.WriteFile(c.ledgerFileName, data, 0644)
+	require.NoError(t, err)
+	defer func() {
+		os.Remove(c.ledgerFileName)
+	}()
+
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger/1/1579215712984890248/payments", bytes.NewBuffer([]byte(`{"amount":1.99,"method":"cash"}`)))
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": "1579215712984890248"})
+	w := httptest.NewRecorder()
+	c.LedgerAddPayment(w, req)
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+	mockProvider.AssertCalled(t, "Release", "ch_hold")
+
+	accountLedgers, err = c.GetAllLedgers()
+	require.NoError(t, err)
+	ledger := accountLedgers.Data[0].Ledgers[0]
+	assert.True(t, ledger.IsPaid)
+	require.NotNil(t, ledger.Hold)
+	assert.Equal(t, HoldStatusReleased, ledger.Hold.Status)
+}
+
+func TestSetPaymentStatusChargesAmountDue(t *testing.T) {
+	mockProvider := &paymentMocks.Provider{}
+	mockProvider.On("Charge", mock.Anything).Return(payment.Charge{ID: "ch_1", Status: payment.ChargeStatusSucceeded}, nil)
+
+	c := Controller{
+		lc:              logger.NewMockClient(),
+		service:         nil,
+		ledgerFileName:  LedgerFileName,
+		storeName:       DefaultStoreName,
+		paymentProvider: mockProvider,
+	}
+	accountLedgers := getDefaultAccountLedgers()
+	accountLedgers.Data[0].PaymentMethod = "cus_1"
+	accountLedgers.Data[0].Ledgers[0].Currency = "USD"
+	accountLedgers.Data[0].Ledgers[0].LineTotalMinor = 199
+	accountLedgers.Data[0].Ledgers[0].Payments = []Payment{{Amount: 1, AmountMinor: 100, Method: "cash"}}
+	data, err := json.Marshal(accountLedgers)
+	require.NoError(t, err)
+	err = os.WriteFile(c.ledgerFileName, data, 0644)
+	require.NoError(t, err)
+	defer func() {
+		os.Remove(c.ledgerFileName)
+	}()
+
+	paymentInfo := `{"accountId":1,"transactionID":"1579215712984890248","isPaid": true }`
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger/ledgerPaymentUpdate", bytes.NewBuffer([]byte(paymentInfo)))
+	w := httptest.NewRecorder()
+	c.SetPaymentStatus(w, req)
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+	mockProvider.AssertCalled(t, "Charge", payment.ChargeRequest{
+		PaymentMethod:  "cus_1",
+		AmountMinor:    99,
+		Currency:       "USD",
+		Description:    DefaultStoreName + " transaction 1579215712984890248",
+		IdempotencyKey: "1-1579215712984890248",
+	})
+}
@@ -0,0 +1,83 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// priceChange is a price of a product in the inventory service's price
+// history, which is its price from EffectiveAt until the next change
+type priceChange struct {
+	SKU         string  `json:"sku"`
+	ItemPrice   float64 `json:"itemPrice"`
+	Currency    string  `json:"currency,omitempty"`
+	EffectiveAt int64   `json:"effectiveAt,string"`
+}
+
+// priceHistory is the inventory service's price history of a product
+type priceHistory struct {
+	Data []priceChange `json:"data"`
+}
+
+// priceAt returns the price of the SKU that was in effect at the time, the
+// latest change that took effect at or before it
+func priceAt(changes []priceChange, sku string, at int64) (priceChange, bool) {
+	var effective priceChange
+	found := false
+	for _, change := range changes {
+		if change.SKU != sku || change.EffectiveAt > at {
+			continue
+		}
+		if !found || change.EffectiveAt >= effective.EffectiveAt {
+			effective = change
+			found = true
+		}
+	}
+	return effective, found
+}
+
+// lookupPriceHistory requests the price history of the SKU from inventory
+func (c *Controller) lookupPriceHistory(inventoryEndpoint string, sku string) ([]priceChange, error) {
+	resp, err := c.sendCommand(http.MethodGet, inventoryEndpoint+"/"+url.PathEscape(sku)+"/priceHistory", []byte(""))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the price history of %s: %s", sku, err.Error())
+	}
+	var history priceHistory
+	if err := json.Unmarshal(body, &history); err != nil {
+		return nil, fmt.Errorf("received an invalid price history for %s: %s", sku, err.Error())
+	}
+	return history.Data, nil
+}
+
+// effectivePrice returns the price and currency of the product that were in
+// effect at the transaction time, so that a price changed since, or not yet
+// seen by the product cache, is not charged. The product's current price is
+// used when its price history cannot be looked up or has no price in effect
+// then, such as for products priced before the history was kept.
+func (c *Controller) effectivePrice(product Product, at int64) (float64, string) {
+	changes, err := c.lookupPriceHistory(c.inventoryEndpoint, product.SKU)
+	if err != nil {
+		c.lc.Warnf("Could not look up the price history of %s, charging its current price: %s", product.SKU, err.Error())
+		return product.ItemPrice, product.Currency
+	}
+	change, ok := priceAt(changes, product.SKU, at)
+	if !ok {
+		return product.ItemPrice, product.Currency
+	}
+	if change.ItemPrice != product.ItemPrice || change.Currency != product.Currency {
+		c.lc.Infof("SKU %s is charged %v %s, its price at the transaction time, rather than its current price %v %s", product.SKU, change.ItemPrice, change.Currency, product.ItemPrice, product.Currency)
+	}
+	return change.ItemPrice, change.Currency
+}
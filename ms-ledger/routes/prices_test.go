@@ -0,0 +1,92 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceAt(t *testing.T) {
+	changes := []priceChange{
+		{SKU: "4900002470", ItemPrice: 1.99, EffectiveAt: 10},
+		{SKU: "4900002470", ItemPrice: 2.49, EffectiveAt: 20},
+		{SKU: "1200010735", ItemPrice: 0.99, EffectiveAt: 15},
+	}
+
+	tests := []struct {
+		Name          string
+		SKU           string
+		At            int64
+		ExpectedFound bool
+		ExpectedPrice float64
+	}{
+		{"before the first change", "4900002470", 5, false, 0},
+		{"at a change", "4900002470", 10, true, 1.99},
+		{"between changes", "4900002470", 19, true, 1.99},
+		{"after the last change", "4900002470", 30, true, 2.49},
+		{"other SKU", "1200010735", 30, true, 0.99},
+		{"no history", "0000000000", 30, false, 0},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			change, found := priceAt(changes, currentTest.SKU, currentTest.At)
+			assert.Equal(t, currentTest.ExpectedFound, found)
+			assert.Equal(t, currentTest.ExpectedPrice, change.ItemPrice)
+		})
+	}
+}
+
+func TestNewTransactionEffectivePrice(t *testing.T) {
+	product := getDefaultProduct()
+	tests := []struct {
+		Name          string
+		History       *priceHistory
+		ExpectedPrice float64
+	}{
+		// the product cache still has the price from before a change
+		{"changed price", &priceHistory{Data: []priceChange{{SKU: product.SKU, ItemPrice: 1.49, EffectiveAt: 1}}}, 1.49},
+		{"future price", &priceHistory{Data: []priceChange{{SKU: product.SKU, ItemPrice: 1.49, EffectiveAt: 1}, {SKU: product.SKU, ItemPrice: 9.99, EffectiveAt: 1 << 62}}}, 1.49},
+		{"no history", &priceHistory{Data: []priceChange{}}, 1.99},
+		{"history unavailable", nil, 1.99},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/batch":
+					jsonProducts, _ := json.Marshal(inventoryProducts{Data: []Product{product}})
+					_, _ = w.Write(jsonProducts)
+				case "/" + product.SKU + "/priceHistory":
+					if currentTest.History == nil {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					jsonHistory, _ := json.Marshal(currentTest.History)
+					_, _ = w.Write(jsonHistory)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer inventoryServer.Close()
+
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				inventoryEndpoint: inventoryServer.URL,
+			}
+			newLedger, err := c.newTransaction(1, []deltaSKU{{SKU: product.SKU, Delta: -2}})
+			require.NoError(t, err)
+			require.Len(t, newLedger.LineItems, 1)
+			assert.Equal(t, currentTest.ExpectedPrice, newLedger.LineItems[0].ItemPrice)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultStoreName is printed on receipts when no StoreName is configured
+	DefaultStoreName = "Automated Checkout"
+
+	ReceiptFormatText = "text"
+	ReceiptFormatHTML = "html"
+	ReceiptFormatPDF  = "pdf"
+
+	// receiptWidth is the number of characters per line of a text receipt
+	receiptWidth = 40
+	// receiptDateLayout shows the date in the kiosk's local time, with the
+	// time zone abbreviation
+	receiptDateLayout = "2006-01-02 15:04:05 MST"
+)
+
+// Receipt is the presentation model of a single ledger transaction
+type Receipt struct {
+	StoreName     string
+	AccountID     int
+	TransactionID string
+	RefundOf      string
+	Date          time.Time
+	Lines         []ReceiptLine
+	Subtotal      float64
+	Deposits      float64
+	Tax           float64
+	Rounding      float64
+	Total         float64
+	IsPaid        bool
+	Currency      Currency
+}
+
+// ReceiptLine is a single line item on a receipt
+type ReceiptLine struct {
+	Description string
+	Quantity    int
+	UnitPrice   float64
+	Amount      float64
+	NotCharged  bool
+}
+
+// NewReceipt builds a receipt from a ledger transaction, dated in the time
+// zone of the location
+func NewReceipt(storeName string, accountID int, ledger Ledger, currency Currency, location *time.Location) Receipt {
+	receipt := Receipt{
+		StoreName:     storeName,
+		AccountID:     accountID,
+		TransactionID: strconv.FormatInt(ledger.TransactionID, 10),
+		Date:          time.Unix(0, ledger.TxTimeStamp).In(location),
+		Total:         ledger.LineTotal,
+		IsPaid:        ledger.IsPaid,
+		Currency:      currency,
+	}
+	if ledger.RefundOf != 0 {
+		receipt.RefundOf = strconv.FormatInt(ledger.RefundOf, 10)
+	}
+	for _, lineItem := range ledger.LineItems {
+		line := ReceiptLine{
+			Description: lineItem.ProductName,
+			Quantity:    lineItem.ItemCount,
+			UnitPrice:   lineItem.ItemPrice,
+			Amount:      lineItem.ItemPrice * float64(lineItem.ItemCount),
+			NotCharged:  lineItem.Unavailable,
+		}
+		if lineItem.PriceOverridden {
+			line.Description = lineItem.ProductName + " (price override)"
+		}
+		if lineItem.Returned {
+			// returned items are only listed for audit
+			line.Description = "Returned: " + lineItem.ProductName
+			line.NotCharged = true
+		} else if lineItem.Rounding {
+			// rounding is on the total, so it is shown with the totals
+			receipt.Rounding += line.Amount
+			continue
+		} else if lineItem.Discount {
+			// discounts reduce the subtotal
+			line.Description = "Discount: " + lineItem.ProductName
+			receipt.Subtotal += line.Amount
+		} else if lineItem.ContainerReturn {
+			// container returns only refund the deposit
+			line.Description = "Container return: " + lineItem.ProductName
+			line.UnitPrice = lineItem.Deposit
+			line.Amount = lineItem.Deposit * float64(lineItem.ItemCount)
+			receipt.Deposits += line.Amount
+		} else if !line.NotCharged {
+			receipt.Subtotal += line.Amount
+			receipt.Deposits += lineItem.Deposit * float64(lineItem.ItemCount)
+		}
+		receipt.Lines = append(receipt.Lines, line)
+	}
+	if ledger.Currency != "" {
+		// The ledger records its own subtotal and tax, which differ from the
+		// line items when the transaction is a share of a split basket
+		receipt.Subtotal = ledger.Subtotal
+		receipt.Tax = ledger.Tax
+		receipt.Deposits = receipt.Total - receipt.Subtotal - receipt.Tax - receipt.Rounding
+	} else {
+		// The ledger total is authoritative, anything on top of the line
+		// items and deposits is tax
+		receipt.Tax = receipt.Total - receipt.Subtotal - receipt.Deposits - receipt.Rounding
+	}
+	return receipt
+}
+
+// PaymentStatus returns the human readable payment status
+func (receipt Receipt) PaymentStatus() string {
+	if receipt.IsPaid {
+		return "PAID"
+	}
+	return "UNPAID"
+}
+
+// Format renders an amount on the receipt in the receipt's currency
+func (receipt Receipt) Format(amount float64) string {
+	return receipt.Currency.Format(receipt.Currency.ToMinor(amount))
+}
+
+// Text renders the receipt as fixed width plain text
+func (receipt Receipt) Text() string {
+	var sb strings.Builder
+	separator := strings.Repeat("-", receiptWidth) + "\n"
+
+	padding := (receiptWidth - len(receipt.StoreName)) / 2
+	if padding < 0 {
+		padding = 0
+	}
+	sb.WriteString(strings.Repeat(" ", padding) + receipt.StoreName + "\n")
+	sb.WriteString(separator)
+	sb.WriteString(fmt.Sprintf("Transaction: %s\n", receipt.TransactionID))
+	if receipt.RefundOf != "" {
+		sb.WriteString(fmt.Sprintf("Refund of: %s\n", receipt.RefundOf))
+	}
+	sb.WriteString(fmt.Sprintf("Account: %d\n", receipt.AccountID))
+	sb.WriteString(fmt.Sprintf("Date: %s\n", receipt.Date.Format(receiptDateLayout)))
+	sb.WriteString(separator)
+	for _, line := range receipt.Lines {
+		amount := receipt.Format(line.Amount)
+		if line.NotCharged {
+			amount = "N/C"
+		}
+		sb.WriteString(receiptRow(fmt.Sprintf("%d x %s", line.Quantity, line.Description), amount))
+	}
+	sb.WriteString(separator)
+	sb.WriteString(receiptRow("Subtotal", receipt.Format(receipt.Subtotal)))
+	if receipt.Deposits != 0 {
+		sb.WriteString(receiptRow("Deposit", receipt.Format(receipt.Deposits)))
+	}
+	sb.WriteString(receiptRow("Tax", receipt.Format(receipt.Tax)))
+	if receipt.Rounding != 0 {
+		sb.WriteString(receiptRow(roundingProductName, receipt.Format(receipt.Rounding)))
+	}
+	sb.WriteString(receiptRow("Total", receipt.Format(receipt.Total)))
+	sb.WriteString(separator)
+	sb.WriteString(fmt.Sprintf("Payment status: %s\n", receipt.PaymentStatus()))
+	return sb.String()
+}
+
+// receiptRow left aligns the label and right aligns the amount, truncating
+// the label if the row would not fit the receipt width
+func receiptRow(label string, amount string) string {
+	maxLabel := receiptWidth - utf8.RuneCountInString(amount) - 1
+	// the label is cut by runes, so that a multi-byte character is not split
+	if runes := []rune(label); len(runes) > maxLabel {
+		label = string(runes[:maxLabel])
+	}
+	return fmt.Sprintf("%-*s %s\n", maxLabel, label, amount)
+}
+
+var receiptHTMLTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.StoreName}} receipt {{.TransactionID}}</title></head>
+<body>
+<h1>{{.StoreName}}</h1>
+<p>Transaction: {{.TransactionID}}<br>
+{{if .RefundOf}}Refund of: {{.RefundOf}}<br>
+{{end}}Account: {{.AccountID}}<br>
+Date: {{.Date.Format "2006-01-02 15:04:05 MST"}}</p>
+<table>
+<tr><th>Qty</th><th>Item</th><th>Price</th><th>Amount</th></tr>
+{{range .Lines}}<tr><td>{{.Quantity}}</td><td>{{.Description}}</td><td>{{$.Format .UnitPrice}}</td><td>{{if .NotCharged}}Not charged{{else}}{{$.Format .Amount}}{{end}}</td></tr>
+{{end}}</table>
+<p>Subtotal: {{$.Format .Subtotal}}<br>
+{{if .Deposits}}Deposit: {{$.Format .Deposits}}<br>
+{{end}}Tax: {{$.Format .Tax}}<br>
+{{if .Rounding}}Cash rounding: {{$.Format .Rounding}}<br>
+{{end}}<strong>Total: {{$.Format .Total}}</strong></p>
+<p>Payment status: {{.PaymentStatus}}</p>
+</body>
+</html>
+`))
+
+// HTML renders the receipt as an HTML document
+func (receipt Receipt) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := receiptHTMLTemplate.Execute(&buf, receipt); err != nil {
+		return nil, fmt.Errorf("failed to render receipt HTML: %s", err.Error())
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF renders the text receipt onto a single page PDF document using a
+// monospaced font, so no external PDF library is required
+func (receipt Receipt) PDF() []byte {
+	const (
+		fontSize   = 10
+		lineHeight = 12
+		margin     = 36
+	)
+	lines := strings.Split(strings.TrimRight(receipt.Text(), "\n"), "\n")
+	width := margin*2 + receiptWidth*fontSize*6/10
+	height := margin*2 + len(lines)*lineHeight
+
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("BT /F1 %d Tf %d TL %d %d Td\n", fontSize, lineHeight, margin, height-margin-fontSize))
+	for _, line := range lines {
+		content.WriteString("(" + escapePDFString(line) + ") Tj T*\n")
+	}
+	content.WriteString("ET")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", width, height),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		buf.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, object))
+	}
+	xref := buf.Len()
+	buf.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, offset := range offsets {
+		buf.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref))
+	return buf.Bytes()
+}
+
+// escapePDFString escapes the characters that are special inside a PDF
+// literal string and drops anything the standard fonts cannot render
+func escapePDFString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r < 32 || r > 126:
+			sb.WriteRune('?')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
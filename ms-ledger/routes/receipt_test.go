@@ -0,0 +1,118 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getDefaultReceipt() Receipt {
+	ledger := getDefaultAccountLedgers().Data[0].Ledgers[0]
+	ledger.LineItems = append(ledger.LineItems, LineItem{
+		SKU:         "4900002470",
+		ProductName: "Sprite (Lemon-Lime) - 16.9 oz <diet>",
+		ItemPrice:   1.50,
+		ItemCount:   2,
+		Unavailable: true,
+	})
+	ledger.LineTotal = 2.15
+	return NewReceipt(DefaultStoreName, 1, ledger, CurrencyConverter{}.Base(), time.UTC)
+}
+
+func TestNewReceipt(t *testing.T) {
+	receipt := getDefaultReceipt()
+
+	assert.Equal(t, "1579215712984890248", receipt.TransactionID)
+	assert.Len(t, receipt.Lines, 2)
+	assert.InDelta(t, 1.99, receipt.Subtotal, 0.001, "items that were not charged should not be in the subtotal")
+	assert.InDelta(t, 0.16, receipt.Tax, 0.001)
+	assert.Equal(t, "UNPAID", receipt.PaymentStatus())
+}
+
+func TestNewReceiptDeposits(t *testing.T) {
+	ledger := Ledger{
+		TransactionID: 1579215712984890248,
+		LineTotal:     1.99 + 0.25 - 0.50,
+		LineItems: []LineItem{{
+			SKU:         "4900002472",
+			ProductName: "Sprite (Lemon-Lime) - 16.9 oz",
+			ItemPrice:   1.99,
+			ItemCount:   1,
+			Deposit:     0.25,
+		}, {
+			SKU:             "4900002472",
+			ProductName:     "Sprite (Lemon-Lime) - 16.9 oz",
+			ItemCount:       -2,
+			Deposit:         0.25,
+			ContainerReturn: true,
+		}},
+	}
+	receipt := NewReceipt(DefaultStoreName, 1, ledger, CurrencyConverter{}.Base(), time.UTC)
+
+	assert.InDelta(t, 1.99, receipt.Subtotal, 0.001)
+	assert.InDelta(t, -0.25, receipt.Deposits, 0.001)
+	assert.InDelta(t, 0, receipt.Tax, 0.001, "deposits should not be reported as tax")
+	assert.Equal(t, "Container return: Sprite (Lemon-Lime) - 16.9 oz", receipt.Lines[1].Description)
+	assert.Contains(t, receipt.Text(), "Deposit")
+}
+
+func TestReceiptText(t *testing.T) {
+	text := getDefaultReceipt().Text()
+
+	assert.Contains(t, text, DefaultStoreName)
+	assert.Contains(t, text, "Transaction: 1579215712984890248")
+	assert.Contains(t, text, "Date: 2020-01-16 23:01:52 UTC")
+	assert.Contains(t, text, "1 x Mountain Dew - 16.9 oz")
+	assert.Contains(t, text, "N/C")
+	assert.Contains(t, text, "Payment status: UNPAID")
+	for _, line := range bytes.Split([]byte(text), []byte("\n")) {
+		assert.LessOrEqual(t, len(line), receiptWidth, "receipt lines should fit the receipt width")
+	}
+}
+
+func TestReceiptRow(t *testing.T) {
+	row := receiptRow("2 x Crème brûlée à la française, grand format", "$12.00")
+	assert.True(t, utf8.ValidString(row), "a multi-byte character should not be split")
+	assert.Equal(t, receiptWidth, utf8.RuneCountInString(strings.TrimSuffix(row, "\n")))
+	assert.Equal(t, "2 x Crème brûlée à la française,  $12.00\n", row)
+
+	assert.Equal(t, "Subtotal"+strings.Repeat(" ", 26)+"$12.00\n", receiptRow("Subtotal", "$12.00"))
+}
+
+func TestReceiptTimeZone(t *testing.T) {
+	chicago, err := LoadTimeZone("America/Chicago")
+	require.NoError(t, err)
+	ledger := getDefaultAccountLedgers().Data[0].Ledgers[0]
+	receipt := NewReceipt(DefaultStoreName, 1, ledger, CurrencyConverter{}.Base(), chicago)
+
+	assert.Contains(t, receipt.Text(), "Date: 2020-01-16 17:01:52 CST")
+	html, err := receipt.HTML()
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "2020-01-16 17:01:52 CST")
+}
+
+func TestReceiptHTML(t *testing.T) {
+	html, err := getDefaultReceipt().HTML()
+	require.NoError(t, err)
+
+	assert.Contains(t, string(html), "<h1>"+DefaultStoreName+"</h1>")
+	assert.Contains(t, string(html), "&lt;diet&gt;", "product names should be escaped")
+	assert.Contains(t, string(html), "Not charged")
+}
+
+func TestReceiptPDF(t *testing.T) {
+	pdf := getDefaultReceipt().PDF()
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "(Payment status: UNPAID) Tj")
+	assert.Equal(t, `a\(b\)\\c?`, escapePDFString("a(b)\\cé"))
+}
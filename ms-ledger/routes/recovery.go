@@ -0,0 +1,72 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+// RecoverData runs the crash recovery of the ledger file, the override audit
+// log, the basket intents, the API keys, and every account file in the
+// per-account storage, keeping the report for the health endpoint
+func (c *Controller) RecoverData(markerName string) error {
+	dataFiles := []utilities.DataFile{{
+		Name: c.ledgerFileName,
+		Validate: func(data []byte) error {
+			var accountLedgers Accounts
+			return json.Unmarshal(data, &accountLedgers)
+		},
+		Empty: Accounts{Data: []Account{}},
+	}, {
+		Name: OverrideAuditFileName(c.ledgerFileName),
+		Validate: func(data []byte) error {
+			var auditLog OverrideAuditLog
+			return json.Unmarshal(data, &auditLog)
+		},
+		Empty: OverrideAuditLog{Data: []OverrideAudit{}},
+	}, {
+		Name: BasketIntentFileName(c.ledgerFileName),
+		Validate: func(data []byte) error {
+			var intents BasketIntents
+			return json.Unmarshal(data, &intents)
+		},
+		Empty: BasketIntents{Data: []BasketIntent{}},
+	}, {
+		Name: APIKeyFileName(c.ledgerFileName),
+		Validate: func(data []byte) error {
+			var keys APIKeys
+			return json.Unmarshal(data, &keys)
+		},
+		Empty: APIKeys{Data: []APIKey{}},
+	}}
+	if c.perAccount() {
+		accountFiles, err := c.accountFileNames()
+		if err != nil {
+			return err
+		}
+		for _, accountFile := range accountFiles {
+			accountID, _ := parseAccountFileName(filepath.Base(accountFile))
+			dataFiles = append(dataFiles, utilities.DataFile{
+				Name: accountFile,
+				Validate: func(data []byte) error {
+					var account Account
+					return json.Unmarshal(data, &account)
+				},
+				Empty: Account{AccountID: accountID, Ledgers: []Ledger{}},
+			})
+		}
+	}
+	report, err := utilities.Recover(markerName, dataFiles, c.fileWriter, time.Now())
+	c.recovery = report
+	return err
+}
+
+// Recovery returns the crash recovery done on start
+func (c *Controller) Recovery() utilities.RecoveryReport {
+	return c.recovery
+}
@@ -0,0 +1,123 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var recoveryNow = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestRecoverData(t *testing.T) {
+	validLedger, err := json.Marshal(getDefaultAccountLedgers())
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name              string
+		Marker            bool
+		LedgerData        []byte
+		TempFile          bool
+		ExpectCrash       bool
+		ExpectQuarantined bool
+		ExpectTempRemoved bool
+	}{
+		{"Clean start", false, validLedger, false, false, false, false},
+		{"Clean start keeps temporary files", false, validLedger, true, false, false, false},
+		{"Crash with valid ledger", true, validLedger, false, true, false, false},
+		{"Crash with interrupted write", true, validLedger, true, true, false, true},
+		{"Crash with corrupt ledger", true, []byte(`{"data":[{"accountID":1,`), false, true, true, false},
+		{"Crash with missing ledger", true, nil, false, true, false, false},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := Controller{
+				lc:             logger.NewMockClient(),
+				ledgerFileName: filepath.Join(dir, LedgerFileName),
+			}
+			markerName := utilities.MarkerFileName(c.ledgerFileName, "ms-ledger")
+			if currentTest.Marker {
+				require.NoError(t, os.WriteFile(markerName, []byte("1"), 0644))
+			}
+			if currentTest.LedgerData != nil {
+				require.NoError(t, os.WriteFile(c.ledgerFileName, currentTest.LedgerData, 0644))
+			}
+			tempName := filepath.Join(dir, "."+LedgerFileName+".tmp-123")
+			if currentTest.TempFile {
+				require.NoError(t, os.WriteFile(tempName, []byte(`{"da`), 0644))
+			}
+
+			require.NoError(t, c.RecoverData(markerName))
+			report := c.Recovery()
+
+			assert.Equal(t, currentTest.ExpectCrash, report.CrashDetected)
+			assert.FileExists(t, markerName, "the running marker should be written for this run")
+
+			if currentTest.ExpectTempRemoved {
+				assert.Equal(t, []string{tempName}, report.RemovedTempFiles)
+				assert.NoFileExists(t, tempName)
+			} else {
+				assert.Empty(t, report.RemovedTempFiles)
+			}
+
+			if !currentTest.ExpectQuarantined {
+				assert.Empty(t, report.QuarantinedFiles)
+				return
+			}
+			quarantineName := report.QuarantinedFiles[c.ledgerFileName]
+			require.NotEmpty(t, quarantineName)
+			quarantined, err := os.ReadFile(quarantineName)
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.LedgerData, quarantined, "the corrupt ledger should be kept for inspection")
+
+			accountLedgers, err := c.GetAllLedgers()
+			require.NoError(t, err)
+			assert.Empty(t, accountLedgers.Data, "the corrupt ledger should be reset")
+		})
+	}
+}
+
+func TestHealthGet(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Recovery       utilities.RecoveryReport
+		ExpectedStatus string
+	}{
+		{"Clean start", utilities.RecoveryReport{}, utilities.HealthStatusOK},
+		{"Recovered", utilities.RecoveryReport{CrashDetected: true, RecoveredAt: recoveryNow.UnixNano()}, utilities.HealthStatusRecovered},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:       logger.NewMockClient(),
+				recovery: currentTest.Recovery,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			w := httptest.NewRecorder()
+			c.HealthGet(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var result utilities.Health
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+			assert.Equal(t, currentTest.ExpectedStatus, result.Status)
+			assert.Equal(t, currentTest.Recovery, result.Recovery)
+		})
+	}
+}
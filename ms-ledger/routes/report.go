@@ -0,0 +1,220 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ReportGroupByDay     = "day"
+	ReportGroupBySKU     = "sku"
+	ReportGroupByAccount = "account"
+)
+
+// SalesReport aggregates the transactions of the ledger by day, SKU or
+// account. Days are in the kiosk's time zone, amounts are in the ledger's
+// currency, and refunds are netted against the sales they refund.
+type SalesReport struct {
+	GroupBy  string             `json:"groupBy"`
+	Currency string             `json:"currency"`
+	Groups   []SalesReportGroup `json:"groups"`
+	Totals   SalesReportGroup   `json:"totals"`
+	// ExcludedTransactions counts the transactions recorded in a currency
+	// other than the ledger's, which are not included in the report
+	ExcludedTransactions int `json:"excludedTransactions"`
+	// TestTransactions counts the technicians' test vends, which are not
+	// sales and are not included in the report
+	TestTransactions int `json:"testTransactions"`
+	// VoidedTransactions counts the transactions voided by an admin, which
+	// are not included in the report
+	VoidedTransactions int `json:"voidedTransactions"`
+}
+
+// SalesReportGroup holds the totals of the transactions of one group
+type SalesReportGroup struct {
+	Key              string `json:"key,omitempty"`
+	TransactionCount int    `json:"transactionCount"`
+	ItemCount        int    `json:"itemCount"`
+	// Revenue is the total of all transactions and UnpaidBalance the part
+	// of it that has not been paid yet
+	Revenue            float64 `json:"revenue"`
+	RevenueMinor       int64   `json:"revenueMinor"`
+	UnpaidBalance      float64 `json:"unpaidBalance"`
+	UnpaidBalanceMinor int64   `json:"unpaidBalanceMinor"`
+}
+
+// salesReportBuilder adds transactions to a SalesReport one at a time, so
+// that the report can be built while streaming the ledger file
+type salesReportBuilder struct {
+	report   SalesReport
+	currency CurrencyConverter
+	// location is the time zone of the days of the ReportGroupByDay groups
+	location *time.Location
+	groups   map[string]*SalesReportGroup
+	// skuTransactions is the last transaction counted for each SKU group
+	skuTransactions map[string]int64
+}
+
+func newSalesReportBuilder(groupBy string, currency CurrencyConverter, location *time.Location) (*salesReportBuilder, error) {
+	switch groupBy {
+	case ReportGroupByDay, ReportGroupBySKU, ReportGroupByAccount:
+	default:
+		return nil, fmt.Errorf("unsupported report grouping %s, expected %s, %s or %s", groupBy, ReportGroupByDay, ReportGroupBySKU, ReportGroupByAccount)
+	}
+	return &salesReportBuilder{
+		report:          SalesReport{GroupBy: groupBy, Currency: currency.Base().Code, Groups: []SalesReportGroup{}},
+		currency:        currency,
+		location:        location,
+		groups:          map[string]*SalesReportGroup{},
+		skuTransactions: map[string]int64{},
+	}, nil
+}
+
+// group returns the group with the key, creating it when needed
+func (builder *salesReportBuilder) group(key string) *SalesReportGroup {
+	group, ok := builder.groups[key]
+	if !ok {
+		group = &SalesReportGroup{Key: key}
+		builder.groups[key] = group
+	}
+	return group
+}
+
+// Add adds a transaction of the account to the report
+func (builder *salesReportBuilder) Add(accountID int, ledger Ledger) {
+	if ledger.IsTest {
+		builder.report.TestTransactions++
+		return
+	}
+	if ledger.IsVoided {
+		builder.report.VoidedTransactions++
+		return
+	}
+	base := builder.currency.Base()
+	if ledger.Currency != "" && !strings.EqualFold(ledger.Currency, base.Code) {
+		builder.report.ExcludedTransactions++
+		return
+	}
+	// ledgers from before minor units were recorded only have float amounts
+	legacy := ledger.Currency == ""
+	totalMinor := ledger.LineTotalMinor
+	if legacy {
+		totalMinor = base.ToMinor(ledger.LineTotal)
+	}
+	// the items of an evenly split basket are listed on every payer's
+	// transaction, so they are only counted with the first payer's
+	countItems := ledger.holdsSplitItems()
+
+	itemCount := 0
+	var linesMinor int64
+	for _, lineItem := range ledger.LineItems {
+		if isReportedItem(lineItem) {
+			itemCount = itemCount + lineItem.ItemCount
+		}
+		linesMinor = linesMinor + lineAmountMinor(lineItem, base, legacy)
+	}
+	if !countItems {
+		itemCount = 0
+	}
+
+	builder.report.Totals.add(1, itemCount, totalMinor, ledger.IsPaid)
+	switch builder.report.GroupBy {
+	case ReportGroupByDay:
+		day := time.Unix(0, ledger.TxTimeStamp).In(builder.location).Format(exportDateLayout)
+		builder.group(day).add(1, itemCount, totalMinor, ledger.IsPaid)
+	case ReportGroupByAccount:
+		builder.group(strconv.Itoa(accountID)).add(1, itemCount, totalMinor, ledger.IsPaid)
+	case ReportGroupBySKU:
+		for _, lineItem := range ledger.LineItems {
+			amountMinor := lineAmountMinor(lineItem, base, legacy)
+			// the transaction total is shared between its lines in proportion
+			// to their amounts, so that split transactions are not counted
+			// in full for every payer
+			if linesMinor != 0 && linesMinor != totalMinor {
+				amountMinor = int64(math.Round(float64(amountMinor) * float64(totalMinor) / float64(linesMinor)))
+			}
+			lineItemCount := 0
+			if countItems && isReportedItem(lineItem) {
+				lineItemCount = lineItem.ItemCount
+			}
+			if lineItemCount == 0 && amountMinor == 0 {
+				continue
+			}
+			key := lineItem.SKU
+			if lineItem.Discount && key == "" {
+				key = DiscountReportKey
+			}
+			if lineItem.Rounding {
+				key = RoundingReportKey
+			}
+			transactionCount := 0
+			if builder.skuTransactions[key] != ledger.TransactionID {
+				builder.skuTransactions[key] = ledger.TransactionID
+				transactionCount = 1
+			}
+			builder.group(key).add(transactionCount, lineItemCount, amountMinor, ledger.IsPaid)
+		}
+	}
+}
+
+// Report returns the report with the groups sorted by key
+func (builder *salesReportBuilder) Report() SalesReport {
+	base := builder.currency.Base()
+	report := builder.report
+	report.Groups = []SalesReportGroup{}
+	for _, group := range builder.groups {
+		group.setAmounts(base)
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.GroupBy == ReportGroupByAccount {
+			left, _ := strconv.Atoi(report.Groups[i].Key)
+			right, _ := strconv.Atoi(report.Groups[j].Key)
+			return left < right
+		}
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+	report.Totals.setAmounts(base)
+	return report
+}
+
+func (group *SalesReportGroup) add(transactionCount int, itemCount int, amountMinor int64, isPaid bool) {
+	group.TransactionCount = group.TransactionCount + transactionCount
+	group.ItemCount = group.ItemCount + itemCount
+	group.RevenueMinor = group.RevenueMinor + amountMinor
+	if !isPaid {
+		group.UnpaidBalanceMinor = group.UnpaidBalanceMinor + amountMinor
+	}
+}
+
+func (group *SalesReportGroup) setAmounts(currency Currency) {
+	group.Revenue = currency.FromMinor(group.RevenueMinor)
+	group.UnpaidBalance = currency.FromMinor(group.UnpaidBalanceMinor)
+}
+
+// isReportedItem checks whether a line counts towards the items sold.
+// Unavailable and returned items are not charged, and container returns,
+// discounts and cash rounding are not sales.
+func isReportedItem(lineItem LineItem) bool {
+	return !lineItem.Unavailable && !lineItem.Returned && !lineItem.ContainerReturn && !lineItem.Discount && !lineItem.Rounding
+}
+
+// lineAmountMinor is the charged amount of a line, including deposits and
+// tax, in minor units
+func lineAmountMinor(lineItem LineItem, currency Currency, legacy bool) int64 {
+	if lineItem.Unavailable || lineItem.Returned {
+		return 0
+	}
+	count := int64(lineItem.ItemCount)
+	if legacy {
+		return currency.ToMinor((lineItem.ItemPrice+lineItem.Deposit)*float64(lineItem.ItemCount) + lineItem.Tax)
+	}
+	return (lineItem.ItemPriceMinor+lineItem.DepositMinor)*count + lineItem.TaxMinor
+}
@@ -0,0 +1,141 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reportLedgers returns a paid sale of two items on 2023-06-01 and an unpaid
+// sale on 2023-06-02 for account 1, and a refund of the first sale for
+// account 2, all in USD
+func reportLedgers() map[int][]Ledger {
+	sale := Ledger{
+		TransactionID: 1, TxTimeStamp: 1685620800000000000, IsPaid: true, Currency: "USD",
+		LineTotalMinor: 448,
+		LineItems: []LineItem{
+			{SKU: "4900002470", ItemCount: 2, ItemPriceMinor: 199},
+			{SKU: "4900002472", ItemCount: 1, ItemPriceMinor: 25, DepositMinor: 25},
+			{SKU: "4900002471", ItemCount: 1, ItemPriceMinor: 100, Returned: true},
+		},
+	}
+	unpaid := Ledger{
+		TransactionID: 2, TxTimeStamp: 1685707200000000000, Currency: "USD",
+		LineTotalMinor: 199,
+		LineItems:      []LineItem{{SKU: "4900002470", ItemCount: 1, ItemPriceMinor: 199}},
+	}
+	refund := Ledger{
+		TransactionID: 3, TxTimeStamp: 1685707200000000000, IsPaid: true, Currency: "USD",
+		LineTotalMinor: -199, RefundOf: 1,
+		LineItems: []LineItem{{SKU: "4900002470", ItemCount: -1, ItemPriceMinor: 199}},
+	}
+	return map[int][]Ledger{1: {sale, unpaid}, 2: {refund}}
+}
+
+func buildReport(t *testing.T, groupBy string, ledgers map[int][]Ledger) SalesReport {
+	builder, err := newSalesReportBuilder(groupBy, CurrencyConverter{}, time.UTC)
+	require.NoError(t, err)
+	for _, accountID := range []int{1, 2, 3} {
+		for _, ledger := range ledgers[accountID] {
+			builder.Add(accountID, ledger)
+		}
+	}
+	return builder.Report()
+}
+
+func TestSalesReport(t *testing.T) {
+	tests := []struct {
+		Name           string
+		GroupBy        string
+		ExpectedGroups []SalesReportGroup
+	}{
+		{"By day", ReportGroupByDay, []SalesReportGroup{
+			{Key: "2023-06-01", TransactionCount: 1, ItemCount: 3, Revenue: 4.48, RevenueMinor: 448},
+			{Key: "2023-06-02", TransactionCount: 2, ItemCount: 0, Revenue: 0, RevenueMinor: 0, UnpaidBalance: 1.99, UnpaidBalanceMinor: 199},
+		}},
+		{"By account", ReportGroupByAccount, []SalesReportGroup{
+			{Key: "1", TransactionCount: 2, ItemCount: 4, Revenue: 6.47, RevenueMinor: 647, UnpaidBalance: 1.99, UnpaidBalanceMinor: 199},
+			{Key: "2", TransactionCount: 1, ItemCount: -1, Revenue: -1.99, RevenueMinor: -199},
+		}},
+		{"By SKU", ReportGroupBySKU, []SalesReportGroup{
+			{Key: "4900002470", TransactionCount: 3, ItemCount: 2, Revenue: 3.98, RevenueMinor: 398, UnpaidBalance: 1.99, UnpaidBalanceMinor: 199},
+			{Key: "4900002472", TransactionCount: 1, ItemCount: 1, Revenue: 0.50, RevenueMinor: 50},
+		}},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			report := buildReport(t, currentTest.GroupBy, reportLedgers())
+
+			assert.Equal(t, currentTest.GroupBy, report.GroupBy)
+			assert.Equal(t, "USD", report.Currency)
+			assert.Equal(t, currentTest.ExpectedGroups, report.Groups)
+			assert.Equal(t, SalesReportGroup{TransactionCount: 3, ItemCount: 3, Revenue: 4.48, RevenueMinor: 448, UnpaidBalance: 1.99, UnpaidBalanceMinor: 199}, report.Totals)
+		})
+	}
+}
+
+func TestSalesReportTimeZone(t *testing.T) {
+	chicago, err := LoadTimeZone("America/Chicago")
+	require.NoError(t, err)
+	builder, err := newSalesReportBuilder(ReportGroupByDay, CurrencyConverter{}, chicago)
+	require.NoError(t, err)
+	// 02:00 UTC is still the evening before in Chicago
+	builder.Add(1, Ledger{
+		TransactionID: 1, TxTimeStamp: time.Date(2023, 6, 2, 2, 0, 0, 0, time.UTC).UnixNano(), IsPaid: true, Currency: "USD",
+		LineTotalMinor: 199,
+	})
+	assert.Equal(t, "2023-06-01", builder.Report().Groups[0].Key)
+}
+
+func TestSalesReportUnknownGrouping(t *testing.T) {
+	_, err := newSalesReportBuilder("week", CurrencyConverter{}, time.UTC)
+	assert.Error(t, err)
+}
+
+func TestSalesReportEvenSplit(t *testing.T) {
+	basket := Ledger{
+		TransactionID: 10, TxTimeStamp: 1685620800000000000, IsPaid: true, Currency: "USD",
+		LineTotalMinor: 398,
+		LineItems:      []LineItem{{SKU: "4900002470", ItemCount: 2, ItemPriceMinor: 199}},
+	}
+	ledgers, err := splitTransaction(basket, []int{1, 2}, SplitRuleEven, nil, CurrencyConverter{}.Base())
+	require.NoError(t, err)
+	ledgers[1].IsPaid = false
+
+	report := buildReport(t, ReportGroupBySKU, map[int][]Ledger{1: {ledgers[0]}, 2: {ledgers[1]}})
+
+	require.Len(t, report.Groups, 1)
+	assert.Equal(t, 2, report.Groups[0].ItemCount, "split items should be counted once")
+	assert.Equal(t, int64(398), report.Groups[0].RevenueMinor)
+	assert.Equal(t, int64(199), report.Groups[0].UnpaidBalanceMinor)
+	assert.Equal(t, 2, report.Totals.ItemCount)
+}
+
+func TestSalesReportLegacyAndExcluded(t *testing.T) {
+	legacy := getDefaultAccountLedgers().Data[0].Ledgers[0]
+	euro := Ledger{TransactionID: 20, Currency: "EUR", LineTotalMinor: 500}
+
+	report := buildReport(t, ReportGroupByAccount, map[int][]Ledger{1: {legacy, euro}})
+
+	assert.Equal(t, 1, report.ExcludedTransactions)
+	assert.Equal(t, []SalesReportGroup{{Key: "1", TransactionCount: 1, ItemCount: 1, Revenue: 1.99, RevenueMinor: 199, UnpaidBalance: 1.99, UnpaidBalanceMinor: 199}}, report.Groups)
+}
+
+func TestSalesReportTestVends(t *testing.T) {
+	testVend := Ledger{
+		TransactionID: 30, TxTimeStamp: 1685620800000000000, IsPaid: true, IsTest: true, Currency: "USD",
+		LineItems: []LineItem{{SKU: "4900002470", ItemCount: 2}},
+	}
+
+	report := buildReport(t, ReportGroupBySKU, map[int][]Ledger{1: {testVend}})
+
+	assert.Equal(t, 1, report.TestTransactions)
+	assert.Empty(t, report.Groups, "test vends should not be reported as sales")
+	assert.Equal(t, SalesReportGroup{}, report.Totals)
+}
@@ -0,0 +1,182 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// ReviewStatusPending is a flagged transaction waiting for an admin to
+	// approve, adjust or void it before it is charged
+	ReviewStatusPending = "pendingReview"
+	// ReviewStatusApproved, ReviewStatusAdjusted and ReviewStatusVoided are
+	// how an admin resolved the review of a transaction
+	ReviewStatusApproved = "approved"
+	ReviewStatusAdjusted = "adjusted"
+	ReviewStatusVoided   = "voided"
+)
+
+// ReviewItem is a transaction pending review and the account it belongs to
+type ReviewItem struct {
+	AccountID   int    `json:"accountID"`
+	Transaction Ledger `json:"transaction"`
+}
+
+// ReviewQueue is the transactions pending review, oldest first
+type ReviewQueue struct {
+	Data []ReviewItem `json:"data"`
+}
+
+// reviewDecision is an admin's resolution of a transaction pending review.
+// LineItems are the corrections of an adjustment, and ApprovalToken is the
+// second admin's approval of adjustments and voids above the dual-control
+// threshold.
+type reviewDecision struct {
+	Reason        string         `json:"reason"`
+	LineItems     []lineItemEdit `json:"lineItems,omitempty"`
+	ApprovalToken string         `json:"approvalToken,omitempty"`
+}
+
+// flag marks the transaction for review before it is charged, for the
+// reason, and puts it in the review queue
+func (ledger *Ledger) flag(reason string) {
+	ledger.IsFlagged = true
+	ledger.FlagReasons = append(ledger.FlagReasons, reason)
+	ledger.ReviewStatus = ReviewStatusPending
+}
+
+// pendingReview reports whether the transaction waits in the review queue.
+// Transactions flagged before the review queue existed have no review
+// status and are pending until they are reviewed or voided.
+func (ledger Ledger) pendingReview() bool {
+	if ledger.ReviewStatus == "" {
+		return ledger.IsFlagged && !ledger.IsVoided
+	}
+	return ledger.ReviewStatus == ReviewStatusPending
+}
+
+// resolveReview takes the transaction out of the review queue with the
+// status of the admin's override action. The flag reasons are kept for
+// audit.
+func (ledger *Ledger) resolveReview(action string, operatorID int, timestamp int64) {
+	switch action {
+	case OverrideActionEdit:
+		ledger.ReviewStatus = ReviewStatusAdjusted
+	case OverrideActionVoid:
+		ledger.ReviewStatus = ReviewStatusVoided
+	default:
+		ledger.ReviewStatus = ReviewStatusApproved
+	}
+	ledger.IsFlagged = false
+	ledger.ReviewedBy = operatorID
+	ledger.ReviewedAt = timestamp
+}
+
+// LedgerReviewGet returns the transactions of every account that are
+// pending review, oldest first
+func (c *Controller) LedgerReviewGet(writer http.ResponseWriter, req *http.Request) {
+	accountLedgers, err := c.GetAllLedgers()
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	queue := ReviewQueue{Data: []ReviewItem{}}
+	for _, account := range accountLedgers.Data {
+		for _, ledger := range account.Ledgers {
+			if ledger.pendingReview() {
+				queue.Data = append(queue.Data, ReviewItem{AccountID: account.AccountID, Transaction: ledger})
+			}
+		}
+	}
+	sort.SliceStable(queue.Data, func(i, j int) bool {
+		return queue.Data[i].Transaction.CreatedAt < queue.Data[j].Transaction.CreatedAt
+	})
+
+	queueJSON, err := json.Marshal(queue)
+	if err != nil {
+		errMsg := "Failed to marshal the review queue"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(queueJSON)
+}
+
+// LedgerReviewApprove resolves the review of a transaction by charging it
+// as it is
+func (c *Controller) LedgerReviewApprove(writer http.ResponseWriter, req *http.Request) {
+	c.reviewTransaction(writer, req, OverrideActionApprove)
+}
+
+// LedgerReviewAdjust resolves the review of a transaction by correcting the
+// counts and prices of its items before it is charged
+func (c *Controller) LedgerReviewAdjust(writer http.ResponseWriter, req *http.Request) {
+	c.reviewTransaction(writer, req, OverrideActionEdit)
+}
+
+// LedgerReviewVoid resolves the review of a transaction by cancelling it,
+// so that it is not charged
+func (c *Controller) LedgerReviewVoid(writer http.ResponseWriter, req *http.Request) {
+	c.reviewTransaction(writer, req, OverrideActionVoid)
+}
+
+// reviewTransaction resolves the review of the transaction with an admin
+// override, which is audited and dual-controlled like any other override
+func (c *Controller) reviewTransaction(writer http.ResponseWriter, req *http.Request, action string) {
+	// Get variables from HTTP request
+	vars := mux.Vars(req)
+	tid, err := strconv.ParseInt(vars["tid"], 10, 64)
+	if err != nil {
+		errMsg := "transactionID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	claims, ok := c.operatorClaims(writer, req)
+	if !ok {
+		return
+	}
+
+	var decision reviewDecision
+	if statusCode, err := c.decodeJSONBody(writer, req, &decision); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+
+	c.overrideTransaction(writer, accountID, tid, transactionOverride{
+		RoleID:        claims.RoleID,
+		OperatorID:    claims.PersonID,
+		Action:        action,
+		Reason:        decision.Reason,
+		LineItems:     decision.LineItems,
+		ApprovalToken: decision.ApprovalToken,
+	}, true)
+}
@@ -0,0 +1,171 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReviewController returns a controller whose transaction of two items
+// at 1.99 USD was flagged for review
+func newReviewController(t *testing.T) Controller {
+	c := newOverrideController(t)
+	accountLedgers := getOverrideAccountLedgers()
+	accountLedgers.Data[0].Ledgers[0].flag("inference confidence 0.42 is below 0.6")
+	require.NoError(t, c.saveLedgers(accountLedgers))
+	return c
+}
+
+func TestLedgerFlag(t *testing.T) {
+	var ledger Ledger
+	assert.False(t, ledger.pendingReview())
+	ledger.flag("manual entry")
+	assert.True(t, ledger.IsFlagged)
+	assert.Equal(t, []string{"manual entry"}, ledger.FlagReasons)
+	assert.True(t, ledger.pendingReview())
+
+	ledger.resolveReview(OverrideActionEdit, 7, 100)
+	assert.False(t, ledger.IsFlagged)
+	assert.Equal(t, []string{"manual entry"}, ledger.FlagReasons, "the flag reasons are kept for audit")
+	assert.Equal(t, ReviewStatusAdjusted, ledger.ReviewStatus)
+	assert.Equal(t, 7, ledger.ReviewedBy)
+	assert.Equal(t, int64(100), ledger.ReviewedAt)
+	assert.False(t, ledger.pendingReview())
+
+	// transactions flagged before the review queue existed are pending too
+	assert.True(t, Ledger{IsFlagged: true}.pendingReview())
+	assert.False(t, Ledger{IsFlagged: true, IsVoided: true}.pendingReview())
+}
+
+func TestLedgerReviewGet(t *testing.T) {
+	c := newReviewController(t)
+	accountLedgers, err := c.getLedgers()
+	require.NoError(t, err)
+	older := accountLedgers.Data[0].Ledgers[0]
+	older.TransactionID++
+	older.CreatedAt--
+	accountLedgers.Data[0].Ledgers = append(accountLedgers.Data[0].Ledgers, older)
+	reviewed := older
+	reviewed.TransactionID++
+	reviewed.resolveReview(OverrideActionApprove, 1, 1)
+	accountLedgers.Data[0].Ledgers = append(accountLedgers.Data[0].Ledgers, reviewed)
+	require.NoError(t, c.saveLedgers(accountLedgers))
+
+	req := httptest.NewRequest("GET", "http://localhost:48093/ledger/review", nil)
+	w := httptest.NewRecorder()
+	c.LedgerReviewGet(w, req)
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var queue ReviewQueue
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&queue))
+	require.Len(t, queue.Data, 2)
+	assert.Equal(t, older.TransactionID, queue.Data[0].Transaction.TransactionID, "the oldest transaction is first")
+	assert.Equal(t, accountLedgers.Data[0].AccountID, queue.Data[0].AccountID)
+	assert.Equal(t, ReviewStatusPending, queue.Data[1].Transaction.ReviewStatus)
+}
+
+func TestLedgerReview(t *testing.T) {
+	tests := []struct {
+		Name                 string
+		Action               string
+		Body                 string
+		Authorization        string
+		Flagged              bool
+		ApproverID           int
+		ExpectedStatusCode   int
+		ExpectedTotalMinor   int64
+		ExpectedReviewStatus string
+	}{
+		{"Approve", OverrideActionApprove, `{"reason":"checked the camera footage"}`, bearerToken(1, RoleAdmin), true, 0, http.StatusOK, 398, ReviewStatusApproved},
+		{"Approve with line items", OverrideActionApprove, `{"reason":"checked","lineItems":[{"sku":"1200050408","itemCount":1}]}`, bearerToken(1, RoleAdmin), true, 0, http.StatusBadRequest, 398, ReviewStatusPending},
+		{"Adjust", OverrideActionEdit, `{"reason":"only one item was taken","lineItems":[{"sku":"1200050408","itemCount":1}]}`, bearerToken(1, RoleAdmin), true, 0, http.StatusOK, 199, ReviewStatusAdjusted},
+		{"Void without approval", OverrideActionVoid, `{"reason":"nothing was taken"}`, bearerToken(1, RoleAdmin), true, 0, http.StatusForbidden, 398, ReviewStatusPending},
+		{"Void with approval", OverrideActionVoid, `{"reason":"nothing was taken"}`, bearerToken(1, RoleAdmin), true, 2, http.StatusOK, 0, ReviewStatusVoided},
+		{"Not an admin", OverrideActionApprove, `{"reason":"checked"}`, bearerToken(2, RoleStocker), true, 0, http.StatusForbidden, 398, ReviewStatusPending},
+		{"Missing token", OverrideActionApprove, `{"reason":"checked"}`, "", true, 0, http.StatusUnauthorized, 398, ReviewStatusPending},
+		{"Missing reason", OverrideActionApprove, `{}`, bearerToken(1, RoleAdmin), true, 0, http.StatusBadRequest, 398, ReviewStatusPending},
+		{"Unknown field", OverrideActionApprove, `{"reason":"checked","action":"void"}`, bearerToken(1, RoleAdmin), true, 0, http.StatusBadRequest, 398, ReviewStatusPending},
+		{"Not pending review", OverrideActionApprove, `{"reason":"checked"}`, bearerToken(1, RoleAdmin), false, 0, http.StatusConflict, 398, ""},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newOverrideController(t)
+			if currentTest.Flagged {
+				c = newReviewController(t)
+			}
+
+			body := currentTest.Body
+			if currentTest.ApproverID != 0 {
+				tid, _ := strconv.ParseInt(overrideTransactionID, 10, 64)
+				approval, err := c.approvals.Issue(1, tid, currentTest.ApproverID)
+				require.NoError(t, err)
+				body = body[:len(body)-1] + `,"approvalToken":"` + approval.Token + `"}`
+			}
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/1/"+overrideTransactionID+"/review", bytes.NewBuffer([]byte(body)))
+			req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			switch currentTest.Action {
+			case OverrideActionApprove:
+				c.LedgerReviewApprove(w, req)
+			case OverrideActionEdit:
+				c.LedgerReviewAdjust(w, req)
+			case OverrideActionVoid:
+				c.LedgerReviewVoid(w, req)
+			}
+			resp := w.Result()
+			defer resp.Body.Close()
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode)
+
+			accountLedgers, err := c.getLedgers()
+			require.NoError(t, err)
+			transaction := accountLedgers.Data[0].Ledgers[0]
+			assert.Equal(t, currentTest.ExpectedTotalMinor, transaction.LineTotalMinor)
+			assert.Equal(t, currentTest.ExpectedReviewStatus, transaction.ReviewStatus)
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			assert.False(t, transaction.IsFlagged)
+			assert.NotEmpty(t, transaction.FlagReasons)
+			assert.Equal(t, 1, transaction.ReviewedBy)
+
+			auditLog, err := c.getOverrideAuditLog()
+			require.NoError(t, err)
+			require.Len(t, auditLog.Data, 1)
+			assert.Equal(t, currentTest.Action, auditLog.Data[0].Action)
+			assert.True(t, auditLog.Data[0].Before.pendingReview())
+		})
+	}
+}
+
+func TestLedgerOverrideResolvesReview(t *testing.T) {
+	c := newReviewController(t)
+	body := `{"action":"edit","reason":"only one item was taken","lineItems":[{"sku":"1200050408","itemCount":1}]}`
+	req := httptest.NewRequest("PUT", "http://localhost:48093/ledger/1/"+overrideTransactionID, bytes.NewBuffer([]byte(body)))
+	req = mux.SetURLVars(req, map[string]string{"accountid": "1", "tid": overrideTransactionID})
+	req.Header.Set("Authorization", bearerToken(1, RoleAdmin))
+	w := httptest.NewRecorder()
+	c.LedgerOverride(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	accountLedgers, err := c.getLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, ReviewStatusAdjusted, accountLedgers.Data[0].Ledgers[0].ReviewStatus)
+}
@@ -0,0 +1,142 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PricingModeStandard charges the exact total of each transaction
+	PricingModeStandard = "standard"
+	// PricingModeCashRounded rounds the total of each transaction to the
+	// smallest cash denomination, for kiosks that take cash where small
+	// coins have been eliminated
+	PricingModeCashRounded = "cashRounded"
+
+	// RoundingRuleNearest rounds to the nearest multiple of the increment,
+	// halfway amounts are rounded up
+	RoundingRuleNearest = "nearest"
+	// RoundingRuleUp rounds up to the next multiple of the increment
+	RoundingRuleUp = "up"
+	// RoundingRuleDown rounds down to the previous multiple of the increment
+	RoundingRuleDown = "down"
+
+	// RoundingReportKey is the sales report group of cash rounding lines
+	RoundingReportKey = "rounding"
+	// roundingProductName is the description of cash rounding lines
+	roundingProductName = "Cash rounding"
+)
+
+// CashRounding is the rounding of transaction totals to the smallest cash
+// denomination. The zero value does not round.
+type CashRounding struct {
+	// IncrementMinor is the smallest cash denomination in minor units of
+	// the ledger's currency, such as 5 for 0.05
+	IncrementMinor int64
+	// Rule is RoundingRuleNearest, RoundingRuleUp or RoundingRuleDown
+	Rule string
+}
+
+// ParseCashRounding parses the pricing mode settings. The increment is in
+// major units of the currency, such as "0.05", and the rule defaults to
+// RoundingRuleNearest. The standard pricing mode does not round.
+func ParseCashRounding(mode string, increment string, rule string, currency Currency) (CashRounding, error) {
+	switch strings.TrimSpace(mode) {
+	case "", PricingModeStandard:
+		return CashRounding{}, nil
+	case PricingModeCashRounded:
+	default:
+		return CashRounding{}, fmt.Errorf("pricing mode %q is not one of %s, %s", mode, PricingModeStandard, PricingModeCashRounded)
+	}
+
+	rounding := CashRounding{Rule: strings.TrimSpace(rule)}
+	switch rounding.Rule {
+	case "":
+		rounding.Rule = RoundingRuleNearest
+	case RoundingRuleNearest, RoundingRuleUp, RoundingRuleDown:
+	default:
+		return CashRounding{}, fmt.Errorf("rounding rule %q is not one of %s, %s, %s", rule, RoundingRuleNearest, RoundingRuleUp, RoundingRuleDown)
+	}
+	amount, err := strconv.ParseFloat(strings.TrimSpace(increment), 64)
+	if err != nil {
+		return CashRounding{}, fmt.Errorf("rounding increment is not a number: %s", err.Error())
+	}
+	rounding.IncrementMinor = currency.ToMinor(amount)
+	if rounding.IncrementMinor <= 0 {
+		return CashRounding{}, fmt.Errorf("rounding increment must be at least one minor unit of %s", currency.Code)
+	}
+	return rounding, nil
+}
+
+// Enabled returns whether transaction totals are rounded
+func (rounding CashRounding) Enabled() bool {
+	return rounding.IncrementMinor > 0
+}
+
+// Round rounds the amount in minor units to a multiple of the increment
+// using the rule
+func (rounding CashRounding) Round(amountMinor int64) int64 {
+	if !rounding.Enabled() {
+		return amountMinor
+	}
+	increment := rounding.IncrementMinor
+	floor := amountMinor / increment * increment
+	if floor > amountMinor {
+		floor = floor - increment
+	}
+	remainder := amountMinor - floor
+	if remainder == 0 {
+		return amountMinor
+	}
+	switch rounding.Rule {
+	case RoundingRuleUp:
+		return floor + increment
+	case RoundingRuleDown:
+		return floor
+	}
+	if remainder*2 >= increment {
+		return floor + increment
+	}
+	return floor
+}
+
+// lineItem returns the rounding line added to new transactions. Its amount
+// is set when the totals are calculated.
+func (rounding CashRounding) lineItem() LineItem {
+	return LineItem{
+		ProductName:            roundingProductName,
+		ItemCount:              1,
+		Rounding:               true,
+		RoundingIncrementMinor: rounding.IncrementMinor,
+		RoundingRule:           rounding.Rule,
+	}
+}
+
+// applyRounding rounds the ledger's grand total with the rule of its
+// rounding line, and records the difference as the line's price. Ledgers
+// without a rounding line are not rounded.
+func (ledger *Ledger) applyRounding() {
+	lineItem := ledger.roundingLineItem()
+	if lineItem == nil {
+		return
+	}
+	rounding := CashRounding{IncrementMinor: lineItem.RoundingIncrementMinor, Rule: lineItem.RoundingRule}
+	lineItem.ItemPriceMinor = rounding.Round(ledger.LineTotalMinor) - ledger.LineTotalMinor
+	lineItem.TaxMinor = 0
+	ledger.LineTotalMinor = ledger.LineTotalMinor + lineItem.ItemPriceMinor
+}
+
+// roundingLineItem returns the rounding line of the ledger, or nil when its
+// total is not rounded
+func (ledger *Ledger) roundingLineItem() *LineItem {
+	for i := range ledger.LineItems {
+		if ledger.LineItems[i].Rounding {
+			return &ledger.LineItems[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCashRounding(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Mode          string
+		Increment     string
+		Rule          string
+		Expected      CashRounding
+		ExpectedError bool
+	}{
+		{"Not set", "", "", "", CashRounding{}, false},
+		{"Standard", PricingModeStandard, "0.05", RoundingRuleUp, CashRounding{}, false},
+		{"Cash rounded", PricingModeCashRounded, "0.05", "", CashRounding{IncrementMinor: 5, Rule: RoundingRuleNearest}, false},
+		{"Cash rounded down", PricingModeCashRounded, "0.10", RoundingRuleDown, CashRounding{IncrementMinor: 10, Rule: RoundingRuleDown}, false},
+		{"Unknown mode", "rounded", "0.05", "", CashRounding{}, true},
+		{"Unknown rule", PricingModeCashRounded, "0.05", "bankers", CashRounding{}, true},
+		{"No increment", PricingModeCashRounded, "", "", CashRounding{}, true},
+		{"Increment below a minor unit", PricingModeCashRounded, "0.001", "", CashRounding{}, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			rounding, err := ParseCashRounding(currentTest.Mode, currentTest.Increment, currentTest.Rule, CurrencyConverter{}.Base())
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, rounding)
+		})
+	}
+}
+
+func TestCashRoundingRound(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Rule     string
+		Amount   int64
+		Expected int64
+	}{
+		{"Nearest down", RoundingRuleNearest, 642, 640},
+		{"Nearest halfway", RoundingRuleNearest, 1005, 1010},
+		{"Nearest up", RoundingRuleNearest, 647, 650},
+		{"Nearest refund", RoundingRuleNearest, -647, -650},
+		{"Up", RoundingRuleUp, 641, 650},
+		{"Down", RoundingRuleDown, 649, 640},
+		{"Down refund", RoundingRuleDown, -641, -650},
+		{"Exact", RoundingRuleUp, 650, 650},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			rounding := CashRounding{IncrementMinor: 10, Rule: currentTest.Rule}
+			assert.Equal(t, currentTest.Expected, rounding.Round(currentTest.Amount))
+		})
+	}
+
+	assert.Equal(t, int64(647), CashRounding{}.Round(647), "the zero value should not round")
+}
+
+func TestLedgerAddTransactionCashRounding(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+		cashRounding:      CashRounding{IncrementMinor: 5, Rule: RoundingRuleNearest},
+	}
+	data, err := json.Marshal(getDefaultAccountLedgers())
+	require.NoError(t, err)
+	err = os.WriteFile(c.ledgerFileName, data, 0644)
+	require.NoError(t, err)
+	defer func() {
+		os.Remove(c.ledgerFileName)
+	}()
+
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer([]byte(`{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1},{"sku":"4900002472","delta":-2}]}`)))
+	w := httptest.NewRecorder()
+	c.LedgerAddTransaction(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+
+	var newLedger Ledger
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&newLedger))
+	require.Len(t, newLedger.LineItems, 3)
+	rounding := newLedger.LineItems[2]
+	assert.True(t, rounding.Rounding)
+	assert.Equal(t, "Cash rounding", rounding.ProductName)
+	assert.Equal(t, int64(-2), rounding.ItemPriceMinor)
+	assert.Equal(t, int64(5), rounding.RoundingIncrementMinor)
+	assert.Equal(t, RoundingRuleNearest, rounding.RoundingRule)
+	assert.Equal(t, int64(597), newLedger.SubtotalMinor, "the subtotal should not be rounded")
+	assert.Equal(t, int64(645), newLedger.LineTotalMinor)
+	assert.Equal(t, "$6.45", newLedger.DisplayTotal)
+}
+
+func getRoundedLedger() Ledger {
+	ledger := Ledger{
+		TransactionID: 1579215712984890248,
+		LineItems: []LineItem{{
+			SKU:            "4900002470",
+			ProductName:    "Sprite (Lemon-Lime) - 16.9 oz",
+			ItemCount:      3,
+			ItemPriceMinor: 199,
+		}, CashRounding{IncrementMinor: 5, Rule: RoundingRuleNearest}.lineItem()},
+	}
+	ledger.calculateTotals()
+	ledger.setAmounts(CurrencyConverter{}.Base())
+	return ledger
+}
+
+func TestCashRoundingAdjustments(t *testing.T) {
+	ledger := getRoundedLedger()
+	require.Equal(t, int64(595), ledger.LineTotalMinor)
+
+	// the total is rounded again with the rule of the rounding line
+	require.NoError(t, ledger.applyAdjustments(nil, []discountLine{{Description: "Loyalty", Amount: 0.49}}, CurrencyConverter{}.Base()))
+	assert.Equal(t, int64(548), ledger.SubtotalMinor)
+	assert.Equal(t, int64(550), ledger.LineTotalMinor)
+	assert.Equal(t, int64(2), ledger.roundingLineItem().ItemPriceMinor)
+
+	ledger.setTestVend(CurrencyConverter{}.Base())
+	assert.Zero(t, ledger.LineTotalMinor)
+	assert.Zero(t, ledger.roundingLineItem().ItemPriceMinor)
+}
+
+func TestCashRoundingSplit(t *testing.T) {
+	ledgers, err := splitTransaction(getRoundedLedger(), []int{1, 2}, SplitRuleEven, nil, CurrencyConverter{}.Base())
+	require.NoError(t, err)
+	require.Len(t, ledgers, 2)
+	assert.Equal(t, int64(300), ledgers[0].LineTotalMinor, "each share should be rounded")
+	assert.Equal(t, int64(300), ledgers[1].LineTotalMinor)
+	assert.Equal(t, int64(1), ledgers[0].roundingLineItem().ItemPriceMinor)
+	assert.Equal(t, int64(2), ledgers[1].roundingLineItem().ItemPriceMinor)
+
+	ledgers, err = splitTransaction(getRoundedLedger(), []int{1, 2}, SplitRuleItemized, []splitAssignment{{1, "4900002470", 2}, {2, "4900002470", 1}}, CurrencyConverter{}.Base())
+	require.NoError(t, err)
+	require.Len(t, ledgers, 2)
+	assert.Equal(t, int64(400), ledgers[0].LineTotalMinor)
+	assert.Equal(t, int64(200), ledgers[1].LineTotalMinor)
+}
+
+func TestCashRoundingReceipt(t *testing.T) {
+	receipt := NewReceipt(DefaultStoreName, 1, getRoundedLedger(), CurrencyConverter{}.Base(), time.UTC)
+
+	assert.Len(t, receipt.Lines, 1, "the rounding should be shown with the totals")
+	assert.InDelta(t, 5.97, receipt.Subtotal, 0.001)
+	assert.InDelta(t, -0.02, receipt.Rounding, 0.001)
+	assert.InDelta(t, 0, receipt.Deposits, 0.001)
+	assert.Contains(t, receipt.Text(), "Cash rounding")
+}
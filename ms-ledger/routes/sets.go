@@ -5,40 +5,33 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math"
+	"ms-ledger/payment"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 // SetPaymentStatus sets the `isPaid` field for a transaction to true/false
 func (c *Controller) SetPaymentStatus(writer http.ResponseWriter, req *http.Request) {
-	// Read request body
-	body := make([]byte, req.ContentLength)
-	_, err := io.ReadFull(req.Body, body)
-	if err != nil {
-		errMsg := "Failed to parse request body"
-		c.lc.Errorf("%s: %s", errMsg, err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
-		writer.Write([]byte(errMsg))
-		return
-	}
-
-	// Unmarshal the string contents of request into a proper structure
+	// Decode the request body into a proper structure
 	var paymentStatus paymentInfo
-	if err := json.Unmarshal(body, &paymentStatus); err != nil {
+	if statusCode, err := c.decodeJSONBody(writer, req, &paymentStatus); err != nil {
 		errMsg := "Failed to unmarshal body"
 		c.lc.Errorf("%s: %s", errMsg, err.Error())
-		writer.WriteHeader(http.StatusBadRequest)
-		writer.Write([]byte(errMsg))
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
 		return
 	}
 
-	//Get all ledgers for all accounts
-	accountLedgers, err := c.GetAllLedgers()
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(paymentStatus.AccountID)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts: %v", err.Error())
 		c.lc.Error(errMsg)
@@ -51,20 +44,50 @@ func (c *Controller) SetPaymentStatus(writer http.ResponseWriter, req *http.Requ
 		if paymentStatus.AccountID == account.AccountID {
 			for transactionIndex, transaction := range account.Ledgers {
 				if paymentStatus.TransactionID == transaction.TransactionID {
+					// When a payment provider is configured, marking a transaction
+					// paid charges the account's stored payment method first
+					if paymentStatus.IsPaid && !transaction.IsPaid && c.paymentProvider != nil {
+						var charge payment.Charge
+						var statusCode int
+						if transaction.Hold != nil {
+							charge, statusCode, err = c.settleHold(account, &accountLedgers.Data[accountIndex].Ledgers[transactionIndex])
+						} else {
+							charge, statusCode, err = c.chargeTransaction(account, transaction)
+						}
+						if err != nil {
+							errMsg := fmt.Sprintf("Failed to charge transaction %v: %v", strconv.FormatInt(paymentStatus.TransactionID, 10), err.Error())
+							c.lc.Error(errMsg)
+							writer.WriteHeader(statusCode)
+							writer.Write([]byte(errMsg))
+							return
+						}
+						accountLedgers.Data[accountIndex].Ledgers[transactionIndex].ChargeID = charge.ID
+						accountLedgers.Data[accountIndex].Ledgers[transactionIndex].ChargeStatus = charge.Status
+						// only a successful charge pays the transaction, a failed or
+						// pending charge is recorded and reported to the caller
+						paymentStatus.IsPaid = charge.Status == payment.ChargeStatusSucceeded
+					}
 					accountLedgers.Data[accountIndex].Ledgers[transactionIndex].IsPaid = paymentStatus.IsPaid
+					if paymentStatus.IsPaid && !transaction.IsPaid {
+						accountLedgers.Data[accountIndex].Ledgers[transactionIndex].PaidAt = time.Now().UnixNano()
+					}
 
-					data, err := json.Marshal(accountLedgers)
-					if err != nil {
-						errMsg := fmt.Sprintf("failed to marshal ledger JSON file for set: " + err.Error())
+					if err = c.saveLedgers(accountLedgers, paymentStatus.AccountID); err != nil {
+						errMsg := fmt.Sprintf("failed to write ledger JSON file for set: " + err.Error())
 						c.lc.Errorf("%s: %s", errMsg, err.Error())
 						writer.WriteHeader(http.StatusInternalServerError)
 						writer.Write([]byte(errMsg))
 						return
 					}
-					if err = os.WriteFile(c.ledgerFileName, data, 0644); err != nil {
-						errMsg := fmt.Sprintf("failed to write ledger JSON file for set: " + err.Error())
-						c.lc.Errorf("%s: %s", errMsg, err.Error())
-						writer.WriteHeader(http.StatusInternalServerError)
+
+					updated := accountLedgers.Data[accountIndex].Ledgers[transactionIndex]
+					if updated.IsPaid && !transaction.IsPaid {
+						c.publishLedgerEvent(LedgerEventPaid, account.AccountID, updated)
+					}
+					if c.paymentProvider != nil && !updated.IsPaid && updated.ChargeStatus != "" && updated.ChargeStatus != payment.ChargeStatusSucceeded {
+						errMsg := fmt.Sprintf("Charge %v for transaction %v is %v", updated.ChargeID, strconv.FormatInt(paymentStatus.TransactionID, 10), updated.ChargeStatus)
+						c.lc.Error(errMsg)
+						writer.WriteHeader(http.StatusPaymentRequired)
 						writer.Write([]byte(errMsg))
 						return
 					}
@@ -89,33 +112,172 @@ func (c *Controller) SetPaymentStatus(writer http.ResponseWriter, req *http.Requ
 	writer.Write([]byte(errMsg))
 }
 
-// LedgerAddTransaction adds a new transaction to the Account Ledger
-func (c *Controller) LedgerAddTransaction(writer http.ResponseWriter, req *http.Request) {
+// chargeTransaction is a helper function that charges the account's stored
+// payment method for the transaction through the payment provider. The
+// returned status code is the HTTP status to respond with on error.
+func (c *Controller) chargeTransaction(account Account, transaction Ledger) (payment.Charge, int, error) {
+	if account.PaymentMethod == "" {
+		return payment.Charge{}, http.StatusBadRequest, fmt.Errorf("account %v does not have a stored payment method", account.AccountID)
+	}
 
-	// Read request body (this is the inference data)
-	body := make([]byte, req.ContentLength)
-	_, err := io.ReadFull(req.Body, body)
+	// only the amount not covered by partial payments is charged
+	currency, ok := c.currency.Lookup(transaction.Currency)
+	if !ok {
+		return payment.Charge{}, http.StatusInternalServerError, fmt.Errorf("transaction currency %s is not a known currency", transaction.Currency)
+	}
+	amountMinor := transaction.amountDueMinor(currency)
+	if amountMinor <= 0 {
+		// nothing to charge, the transaction is paid as is
+		return payment.Charge{Status: payment.ChargeStatusSucceeded}, http.StatusOK, nil
+	}
+
+	transactionID := strconv.FormatInt(transaction.TransactionID, 10)
+	charge, err := c.paymentProvider.Charge(payment.ChargeRequest{
+		PaymentMethod:  account.PaymentMethod,
+		AmountMinor:    amountMinor,
+		Currency:       currency.Code,
+		Description:    fmt.Sprintf("%s transaction %s", c.storeName, transactionID),
+		IdempotencyKey: strconv.Itoa(account.AccountID) + "-" + transactionID,
+	})
 	if err != nil {
-		errMsg := "Failed to parse request body"
+		return payment.Charge{}, http.StatusBadGateway, err
+	}
+	c.lc.Infof("Charged transaction %s with charge %s: %s", transactionID, charge.ID, charge.Status)
+	return charge, http.StatusOK, nil
+}
+
+// settleHold is a helper function that settles the transaction against its
+// pre-authorization. The hold is released when there is nothing to charge,
+// otherwise up to the held amount is captured and any amount over the hold
+// is charged separately. The transaction's hold status is updated, and the
+// provider requests use idempotency keys so that retrying is safe.
+func (c *Controller) settleHold(account Account, transaction *Ledger) (payment.Charge, int, error) {
+	hold := transaction.Hold
+	transactionID := strconv.FormatInt(transaction.TransactionID, 10)
+	idempotencyKey := strconv.Itoa(account.AccountID) + "-" + transactionID
+
+	// only the amount not covered by partial payments is settled
+	dueMinor := transaction.LineTotalMinor - transaction.paidMinor()
+	captureMinor := dueMinor
+	if captureMinor > hold.AmountMinor {
+		captureMinor = hold.AmountMinor
+	}
+
+	if hold.Status == HoldStatusAuthorized {
+		if captureMinor <= 0 {
+			if err := c.paymentProvider.Release(hold.AuthorizationID); err != nil {
+				return payment.Charge{}, http.StatusBadGateway, err
+			}
+			hold.Status = HoldStatusReleased
+			c.lc.Infof("Released hold %s for transaction %s, nothing to charge", hold.AuthorizationID, transactionID)
+			return payment.Charge{Status: payment.ChargeStatusSucceeded}, http.StatusOK, nil
+		}
+
+		charge, err := c.paymentProvider.Capture(payment.CaptureRequest{
+			AuthorizationID: hold.AuthorizationID,
+			AmountMinor:     captureMinor,
+			IdempotencyKey:  idempotencyKey + "-capture",
+		})
+		if err != nil {
+			return payment.Charge{}, http.StatusBadGateway, err
+		}
+		c.lc.Infof("Captured %d of hold %s for transaction %s: %s", captureMinor, hold.AuthorizationID, transactionID, charge.Status)
+		if charge.Status != payment.ChargeStatusSucceeded {
+			return charge, http.StatusOK, nil
+		}
+		hold.Status = HoldStatusCaptured
+		if dueMinor == captureMinor {
+			return charge, http.StatusOK, nil
+		}
+	}
+	if hold.Status == HoldStatusReleased {
+		return payment.Charge{Status: payment.ChargeStatusSucceeded}, http.StatusOK, nil
+	}
+
+	remainderMinor := dueMinor - captureMinor
+	if remainderMinor <= 0 {
+		return payment.Charge{Status: payment.ChargeStatusSucceeded}, http.StatusOK, nil
+	}
+	if account.PaymentMethod == "" {
+		return payment.Charge{}, http.StatusBadRequest, fmt.Errorf("account %v does not have a stored payment method", account.AccountID)
+	}
+	charge, err := c.paymentProvider.Charge(payment.ChargeRequest{
+		PaymentMethod:  account.PaymentMethod,
+		AmountMinor:    remainderMinor,
+		Currency:       hold.Currency,
+		Description:    fmt.Sprintf("%s transaction %s over the held amount", c.storeName, transactionID),
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		return payment.Charge{}, http.StatusBadGateway, err
+	}
+	c.lc.Infof("Charged %d over the hold for transaction %s with charge %s: %s", remainderMinor, transactionID, charge.ID, charge.Status)
+	return charge, http.StatusOK, nil
+}
+
+// settleHoldOnCreate settles the hold that a new transaction took over when
+// holds are settled as soon as the transaction of the basket is created. The
+// transaction is paid when the settlement succeeds, otherwise it is left
+// unpaid and its hold is settled when it is marked paid. A flagged
+// transaction is reviewed first, so its hold is settled when it is marked
+// paid too.
+func (c *Controller) settleHoldOnCreate(account Account, transaction *Ledger) {
+	if c.holdSettlement != HoldSettlementOnCreate || c.paymentProvider == nil || transaction.IsFlagged || transaction.Hold == nil || transaction.Hold.Status != HoldStatusAuthorized {
+		return
+	}
+	charge, _, err := c.settleHold(account, transaction)
+	if err != nil {
+		c.lc.Errorf("Failed to settle hold %s of transaction %s, it is settled when the transaction is marked paid: %s", transaction.Hold.AuthorizationID, strconv.FormatInt(transaction.TransactionID, 10), err.Error())
+		return
+	}
+	transaction.ChargeID = charge.ID
+	transaction.ChargeStatus = charge.Status
+	if charge.Status == payment.ChargeStatusSucceeded {
+		transaction.IsPaid = true
+		transaction.PaidAt = time.Now().UnixNano()
+	}
+}
+
+// LedgerPreAuthorize places a hold for the configured amount, or the amount
+// in the optional request body, on the account's stored payment method,
+// before the door is unlocked for it. The hold is attached to the account's
+// next transaction.
+func (c *Controller) LedgerPreAuthorize(writer http.ResponseWriter, req *http.Request) {
+	accountID, err := strconv.Atoi(mux.Vars(req)["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
 		writer.WriteHeader(http.StatusBadRequest)
 		writer.Write([]byte(errMsg))
-		c.lc.Errorf("%s: %s", errMsg, err.Error())
 		return
 	}
 
-	// Unmarshal the string contents of request for inference data into a proper structure
-	// deltaLedger is accountID and list of Sku:delta
-	var updateLedger deltaLedger
-	if err := json.Unmarshal(body, &updateLedger); err != nil {
+	// the kiosk may ask for its own hold amount, otherwise the configured
+	// amount is held
+	holdAmountMinor := c.holdAmountMinor
+	var request preAuthRequest
+	if statusCode, err := c.decodeJSONBody(writer, req, &request); err != nil && !errors.Is(err, io.EOF) {
 		errMsg := "Failed to unmarshal request body"
 		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+	if request.Amount < 0 {
+		errMsg := "The hold amount must not be negative"
+		c.lc.Error(errMsg)
 		writer.WriteHeader(http.StatusBadRequest)
 		writer.Write([]byte(errMsg))
 		return
 	}
+	if request.Amount > 0 {
+		holdAmountMinor = c.currency.Base().ToMinor(request.Amount)
+	}
 
-	//Get all ledgers for all accounts
-	accountLedgers, err := c.GetAllLedgers()
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
 		c.lc.Error(errMsg)
@@ -124,38 +286,198 @@ func (c *Controller) LedgerAddTransaction(writer http.ResponseWriter, req *http.
 		return
 	}
 
+	accountIndex := -1
+	for i, account := range accountLedgers.Data {
+		if account.AccountID == accountID {
+			accountIndex = i
+			break
+		}
+	}
+	if accountIndex < 0 {
+		errMsg := fmt.Sprintf("AccountID %v not found in ledger", accountID)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	account := accountLedgers.Data[accountIndex]
+
+	result := preAuthorization{}
+	switch {
+	case c.paymentProvider == nil || holdAmountMinor <= 0 || account.PaymentMethod == "":
+		c.lc.Infof("Account %d does not require a hold", accountID)
+	case account.Hold != nil:
+		// the door was not opened since the last hold, so it is still usable
+		result = preAuthorization{Required: true, Hold: account.Hold}
+		c.lc.Infof("Account %d already has hold %s", accountID, account.Hold.AuthorizationID)
+	default:
+		currency := c.currency.Base()
+		now := time.Now().UnixNano()
+		charge, err := c.paymentProvider.Authorize(payment.ChargeRequest{
+			PaymentMethod:  account.PaymentMethod,
+			AmountMinor:    holdAmountMinor,
+			Currency:       currency.Code,
+			Description:    fmt.Sprintf("%s pre-authorization", c.storeName),
+			IdempotencyKey: strconv.Itoa(accountID) + "-hold-" + strconv.FormatInt(now, 10),
+		})
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to pre-authorize account %v: %v", accountID, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadGateway)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		if charge.Status != payment.ChargeStatusAuthorized {
+			errMsg := fmt.Sprintf("Pre-authorization for account %v is %v: %v", accountID, charge.Status, charge.FailureMessage)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusPaymentRequired)
+			writer.Write([]byte(errMsg))
+			return
+		}
+
+		accountLedgers.Data[accountIndex].Hold = &Hold{
+			AuthorizationID: charge.ID,
+			AmountMinor:     holdAmountMinor,
+			Currency:        currency.Code,
+			CreatedAt:       now,
+			Status:          HoldStatusAuthorized,
+		}
+		if err = c.saveLedgers(accountLedgers, accountID); err != nil {
+			errMsg := "failed to write ledger JSON file for pre-authorization"
+			c.lc.Errorf("%s: %s", errMsg, err.Error())
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		result = preAuthorization{Required: true, Hold: accountLedgers.Data[accountIndex].Hold}
+		c.lc.Infof("Placed hold %s of %s on account %d", charge.ID, currency.Format(holdAmountMinor), accountID)
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		errMsg := "Failed to marshal pre-authorization"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Write(resultJSON)
+}
+
+// LedgerAddTransaction adds a new transaction to the Account Ledger
+func (c *Controller) LedgerAddTransaction(writer http.ResponseWriter, req *http.Request) {
+
+	// Decode the request body for inference data into a proper structure
+	// deltaLedger is accountID and list of Sku:delta
+	var updateLedger deltaLedger
+	if statusCode, err := c.decodeJSONBody(writer, req, &updateLedger); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+	if updateLedger.hasAdjustments() {
+		claims, ok := c.operatorClaims(writer, req)
+		if !ok {
+			return
+		}
+		updateLedger.RoleID = claims.RoleID
+		if !isOperatorRole(updateLedger.RoleID) {
+			errMsg := fmt.Sprintf("Role %v may not override prices or add discounts", updateLedger.RoleID)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusForbidden)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	// a basket with a pending intent is settled once, so a retry after the
+	// recovery job charged it returns the recorded transaction
+	if updateLedger.SessionID != "" {
+		basketIntentMutex.Lock()
+		defer basketIntentMutex.Unlock()
+		settled, err := c.settledLedgers(updateLedger.SessionID)
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to look up the basket intent of session %s: %s", updateLedger.SessionID, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		if len(settled) > 0 {
+			c.lc.Infof("Basket of session %s was already charged as transaction %d", updateLedger.SessionID, settled[0].TransactionID)
+			c.writeSettledLedgers(writer, settled[0])
+			return
+		}
+	}
+
+	newLedger, statusCode, err := c.addTransaction(updateLedger)
+	if err != nil {
+		errMsg := err.Error()
+		c.lc.Error(errMsg)
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if updateLedger.SessionID != "" {
+		c.settleBasketIntent(updateLedger.SessionID, newLedger.TransactionID)
+	}
+
+	// return the new ledger as JSON, or if for some reason it cannot be processed back into
+	// JSON for returning to the user, fallback to a simple string
+	newLedgerJSON, err := json.Marshal(newLedger)
+	if err != nil {
+		c.lc.Warnf("Updated ledger successfully with error %s", err.Error())
+		writer.Write([]byte("Updated ledger successfully, but could not marshal to json"))
+	} else {
+		c.lc.Infof("Updated ledger %s successfully", newLedgerJSON)
+		writer.Write(newLedgerJSON)
+	}
+}
+
+// addTransaction adds a transaction for the inference deltas to the
+// account's ledger, settling the account's hold, and returns it with the
+// status code of the failure when it cannot be added
+func (c *Controller) addTransaction(updateLedger deltaLedger) (Ledger, int, error) {
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(updateLedger.AccountID)
+	if err != nil {
+		return Ledger{}, http.StatusInternalServerError, fmt.Errorf("Failed to retrieve all ledgers for accounts %v", err.Error())
+	}
+
 	ledgerChanged := false
 	var newLedger Ledger
 
 	for accountIndex, account := range accountLedgers.Data {
 		if updateLedger.AccountID == account.AccountID {
-			newLedger = Ledger{
-				TransactionID: time.Now().UnixNano(),
-				TxTimeStamp:   time.Now().UnixNano(),
-				LineTotal:     0,
-				CreatedAt:     time.Now().UnixNano(),
-				UpdatedAt:     time.Now().UnixNano(),
-				IsPaid:        false,
-				LineItems:     []LineItem{},
+			newLedger, err = c.newTransaction(updateLedger.AccountID, updateLedger.DeltaSKUs)
+			if err != nil {
+				return Ledger{}, http.StatusBadRequest, err
 			}
 
-			for _, deltaSKU := range updateLedger.DeltaSKUs {
-				itemInfo, err := c.getInventoryItemInfo(c.inventoryEndpoint, deltaSKU.SKU)
-				if err != nil {
-					errMsg := fmt.Sprintf("Could not find product Info for %v errir: %v", deltaSKU.SKU, err.Error())
-					c.lc.Error(errMsg)
-					writer.WriteHeader(http.StatusBadRequest)
-					writer.Write([]byte(errMsg))
-					return
-				}
-				newLineItem := LineItem{
-					SKU:         deltaSKU.SKU,
-					ProductName: itemInfo.ProductName,
-					ItemPrice:   itemInfo.ItemPrice,
-					ItemCount:   int(math.Abs(float64(deltaSKU.Delta))),
+			if updateLedger.hasAdjustments() {
+				if err = newLedger.applyAdjustments(updateLedger.PriceOverrides, updateLedger.Discounts, c.currency.Base()); err != nil {
+					return Ledger{}, http.StatusBadRequest, fmt.Errorf("Failed to apply price overrides and discounts: %v", err.Error())
 				}
-				newLedger.LineItems = append(newLedger.LineItems, newLineItem)
-				newLedger.LineTotal = newLedger.LineTotal + (newLineItem.ItemPrice * float64(newLineItem.ItemCount))
+				c.lc.Infof("Applied %d price override(s) and %d discount(s) for account %v by role %v", len(updateLedger.PriceOverrides), len(updateLedger.Discounts), updateLedger.AccountID, updateLedger.RoleID)
+			}
+
+			if updateLedger.FlagReason != "" {
+				newLedger.flag(updateLedger.FlagReason)
+				c.lc.Warnf("Transaction for account %v was flagged: %s", updateLedger.AccountID, updateLedger.FlagReason)
+			}
+
+			if updateLedger.IsTest {
+				// test vends are not charged, so they do not settle a hold
+				newLedger.setTestVend(c.currency.Base())
+				c.lc.Infof("Recording test vend for account %v", updateLedger.AccountID)
+			} else {
+				// the account's pre-authorization is settled with this transaction
+				newLedger.Hold = accountLedgers.Data[accountIndex].takeHold()
+				c.settleHoldOnCreate(account, &newLedger)
 			}
 
 			// Add new Ledger to array of Ledgers for that account
@@ -165,43 +487,675 @@ func (c *Controller) LedgerAddTransaction(writer http.ResponseWriter, req *http.
 	}
 
 	if !ledgerChanged {
-		c.lc.Error("No ledger change in any account")
+		return Ledger{}, http.StatusBadRequest, errors.New("Account not found")
+	}
+
+	if err = c.saveLedgers(accountLedgers, updateLedger.AccountID); err != nil {
+		return Ledger{}, http.StatusInternalServerError, errors.New("failed to write ledger JSON file for update: " + err.Error())
+	}
+	c.publishLedgerEvent(LedgerEventCreated, updateLedger.AccountID, newLedger)
+	if newLedger.IsPaid {
+		c.publishLedgerEvent(LedgerEventPaid, updateLedger.AccountID, newLedger)
+	}
+
+	return newLedger, http.StatusOK, nil
+}
+
+// LedgerSplitTransaction creates a transaction from the inference deltas of
+// a single door open and splits it between several accounts, adding one
+// linked transaction to each account
+func (c *Controller) LedgerSplitTransaction(writer http.ResponseWriter, req *http.Request) {
+	var split splitLedger
+	if statusCode, err := c.decodeJSONBody(writer, req, &split); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+	if len(split.AccountIDs) < 2 {
+		errMsg := "A split requires at least 2 accounts"
+		c.lc.Error(errMsg)
 		writer.WriteHeader(http.StatusBadRequest)
-		writer.Write([]byte("Account not found"))
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	if split.SessionID != "" {
+		basketIntentMutex.Lock()
+		defer basketIntentMutex.Unlock()
+		settled, err := c.settledLedgers(split.SessionID)
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to look up the basket intent of session %s: %s", split.SessionID, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		if len(settled) > 0 {
+			c.lc.Infof("Basket of session %s was already split", split.SessionID)
+			c.writeSettledLedgers(writer, settled)
+			return
+		}
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the accounts
+	accountLedgers, err := c.getLedgers(split.AccountIDs...)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountIndexes := make([]int, len(split.AccountIDs))
+	for i, accountID := range split.AccountIDs {
+		accountIndexes[i] = -1
+		for j, account := range accountLedgers.Data {
+			if account.AccountID == accountID {
+				accountIndexes[i] = j
+				break
+			}
+		}
+		if accountIndexes[i] < 0 {
+			errMsg := fmt.Sprintf("Could not find account %v", strconv.Itoa(accountID))
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	basket, err := c.newTransaction(split.AccountIDs[0], split.DeltaSKUs)
+	if err != nil {
+		errMsg := err.Error()
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
 		return
 	}
 
-	data, err := json.Marshal(accountLedgers)
+	splitLedgers, err := splitTransaction(basket, split.AccountIDs, split.Rule, split.Assignments, c.currency.Base())
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to marshal ledger JSON file for update: " + err.Error())
+		errMsg := fmt.Sprintf("Failed to split transaction: %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	for i, splitLedger := range splitLedgers {
+		if split.FlagReason != "" {
+			splitLedger.flag(split.FlagReason)
+			c.lc.Warnf("Transaction for account %v was flagged: %s", split.AccountIDs[i], split.FlagReason)
+		}
+		splitLedger.Hold = accountLedgers.Data[accountIndexes[i]].takeHold()
+		c.settleHoldOnCreate(accountLedgers.Data[accountIndexes[i]], &splitLedger)
+		splitLedgers[i] = splitLedger
+		accountLedgers.Data[accountIndexes[i]].Ledgers = append(accountLedgers.Data[accountIndexes[i]].Ledgers, splitLedger)
+	}
+
+	if err = c.saveLedgers(accountLedgers, split.AccountIDs...); err != nil {
+		errMsg := "failed to write ledger JSON file for split"
 		c.lc.Errorf("%s: %s", errMsg, err.Error())
 		writer.WriteHeader(http.StatusInternalServerError)
 		writer.Write([]byte(errMsg))
 		return
 	}
-	if err = os.WriteFile(c.ledgerFileName, data, 0644); err != nil {
-		errMsg := fmt.Sprintf("failed to write ledger JSON file for update: " + err.Error())
+	c.lc.Infof("Split transaction %s %s between accounts %v", strconv.FormatInt(basket.TransactionID, 10), split.Rule, split.AccountIDs)
+	for i, splitLedger := range splitLedgers {
+		c.publishLedgerEvent(LedgerEventCreated, split.AccountIDs[i], splitLedger)
+		if splitLedger.IsPaid {
+			c.publishLedgerEvent(LedgerEventPaid, split.AccountIDs[i], splitLedger)
+		}
+	}
+	if split.SessionID != "" {
+		transactionIDs := make([]int64, len(splitLedgers))
+		for i, splitLedger := range splitLedgers {
+			transactionIDs[i] = splitLedger.TransactionID
+		}
+		c.settleBasketIntent(split.SessionID, transactionIDs...)
+	}
+
+	splitLedgersJSON, err := json.Marshal(splitLedgers)
+	if err != nil {
+		c.lc.Warnf("Split transaction successfully with error %s", err.Error())
+		writer.Write([]byte("Split transaction successfully, but could not marshal to json"))
+		return
+	}
+	writer.Write(splitLedgersJSON)
+}
+
+// newTransaction is a helper function that creates a new ledger transaction
+// for the account from the inference deltas, looking up each product in
+// inventory. Returned errors are caused by the deltas or the products.
+func (c *Controller) newTransaction(accountID int, deltaSKUs []deltaSKU) (Ledger, error) {
+	newLedger := Ledger{
+		TransactionID: time.Now().UnixNano(),
+		TxTimeStamp:   time.Now().UnixNano(),
+		LineTotal:     0,
+		CreatedAt:     time.Now().UnixNano(),
+		UpdatedAt:     time.Now().UnixNano(),
+		IsPaid:        false,
+		LineItems:     []LineItem{},
+		TimeZone:      c.location().String(),
+	}
+
+	// Net the deltas of each SKU so that an item taken and put back
+	// during the same session is not charged
+	netDeltas := netDeltaSKUs(deltaSKUs)
+	var skus []string
+	for _, deltaSKU := range netDeltas {
+		if deltaSKU.Delta != 0 {
+			skus = append(skus, deltaSKU.SKU)
+		}
+	}
+	// all of the products are looked up at once, so that the lookup does
+	// not take longer with every item in the cart
+	lineItems, err := c.resolveLineItems(skus, newLedger.TxTimeStamp)
+	if err != nil {
+		return Ledger{}, err
+	}
+
+	for _, deltaSKU := range netDeltas {
+		if deltaSKU.Delta == 0 {
+			continue
+		}
+		lineItem := lineItems[deltaSKU.SKU]
+		itemInfo := lineItem.product
+		newLineItem := LineItem{
+			SKU:            deltaSKU.SKU,
+			ProductName:    itemInfo.ProductName,
+			ItemCount:      -deltaSKU.Delta,
+			ItemPriceMinor: lineItem.itemPriceMinor,
+			DepositMinor:   lineItem.depositMinor,
+		}
+		if deltaSKU.Delta > 0 {
+			// A positive net delta means more items were put back than taken,
+			// these are recorded for audit but are neither charged nor refunded
+			newLineItem.ItemCount = deltaSKU.Delta
+			newLineItem.Returned = true
+			c.lc.Infof("SKU %s had %d item(s) returned for account %v", deltaSKU.SKU, deltaSKU.Delta, accountID)
+		} else if !itemInfo.IsAvailableAt(time.Unix(0, newLedger.TxTimeStamp).In(c.location())) {
+			// Items vended outside of their availability windows are flagged
+			// for review instead of being charged
+			newLineItem.Unavailable = true
+			newLedger.flag(fmt.Sprintf("SKU %s was vended outside of its availability window", deltaSKU.SKU))
+			c.lc.Warnf("SKU %s was vended outside of its availability window for account %v", deltaSKU.SKU, accountID)
+		}
+		if !newLineItem.Unavailable && !newLineItem.Returned {
+			rate, ok := c.taxTable.Rate(itemInfo.TaxCategory)
+			if !ok {
+				c.lc.Warnf("SKU %s has tax category %s which is not in the tax table, it is taxed at the %s rate", deltaSKU.SKU, itemInfo.TaxCategory, DefaultTaxCategory)
+			}
+			newLineItem.TaxRate = rate
+		}
+		newLedger.LineItems = append(newLedger.LineItems, newLineItem)
+	}
+	if c.cashRounding.Enabled() {
+		// the rounding line records the rule, so that the total is rounded
+		// the same way when it is discounted or edited later
+		newLedger.LineItems = append(newLedger.LineItems, c.cashRounding.lineItem())
+	}
+
+	newLedger.calculateTotals()
+	// Never charge a negative total, returned items are not refunded so
+	// this only happens with misconfigured prices
+	if newLedger.LineTotalMinor < 0 {
+		c.lc.Warnf("Transaction for account %v had a negative total which was not charged", accountID)
+		newLedger.LineTotalMinor = 0
+	}
+	newLedger.setAmounts(c.currency.Base())
+	return newLedger, nil
+}
+
+// setTestVend turns the transaction into a technician's test vend. The
+// items are kept for audit with their prices, deposits and tax zeroed, and
+// as there is nothing to charge the transaction is marked as paid.
+func (ledger *Ledger) setTestVend(currency Currency) {
+	for i := range ledger.LineItems {
+		ledger.LineItems[i].ItemPriceMinor = 0
+		ledger.LineItems[i].DepositMinor = 0
+		ledger.LineItems[i].TaxRate = 0
+	}
+	ledger.calculateTotals()
+	ledger.setAmounts(currency)
+	ledger.IsTest = true
+	ledger.IsPaid = true
+}
+
+// LedgerRefund reverses an existing transaction by adding a linked refund
+// entry with negated counts and totals to the same account. When the request
+// body asks for it, the refunded items are also returned to inventory.
+func (c *Controller) LedgerRefund(writer http.ResponseWriter, req *http.Request) {
+	// Get variables from HTTP request
+	vars := mux.Vars(req)
+	tidstr := vars["tid"]
+	tid, err := strconv.ParseInt(tidstr, 10, 64)
+	if err != nil {
+		errMsg := "transactionID contains bad data"
 		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	// The request body is optional and only carries the restock flag
+	var refund refundInfo
+	if statusCode, err := c.decodeJSONBody(writer, req, &refund); err != nil && err != io.EOF {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
 		writer.WriteHeader(http.StatusInternalServerError)
 		writer.Write([]byte(errMsg))
 		return
 	}
 
-	// return the new ledger as JSON, or if for some reason it cannot be processed back into
-	// JSON for returning to the user, fallback to a simple string
-	newLedgerJSON, err := json.Marshal(newLedger)
+	accountIndex := -1
+	for i, account := range accountLedgers.Data {
+		if account.AccountID == accountID {
+			accountIndex = i
+			break
+		}
+	}
+	if accountIndex < 0 {
+		errMsg := fmt.Sprintf("Could not find account %v", strconv.Itoa(accountID))
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	var original *Ledger
+	for i, ledger := range accountLedgers.Data[accountIndex].Ledgers {
+		if ledger.RefundOf == tid {
+			errMsg := fmt.Sprintf("Transaction %v has already been refunded", tidstr)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		if ledger.TransactionID == tid {
+			original = &accountLedgers.Data[accountIndex].Ledgers[i]
+		}
+	}
+	if original == nil {
+		errMsg := fmt.Sprintf("Could not find Transaction %v", tidstr)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	if original.RefundOf != 0 {
+		errMsg := fmt.Sprintf("Transaction %v is a refund and cannot be refunded", tidstr)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	for _, lineItem := range original.LineItems {
+		if lineItem.ContainerReturn {
+			errMsg := fmt.Sprintf("Transaction %v is a container return and cannot be refunded", tidstr)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	now := time.Now().UnixNano()
+	refundLedger := Ledger{
+		TransactionID: now,
+		TxTimeStamp:   now,
+		LineTotal:     -original.LineTotal,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		IsPaid:        original.IsPaid,
+		LineItems:     []LineItem{},
+		RefundOf:      original.TransactionID,
+		Subtotal:      -original.Subtotal,
+		Tax:           -original.Tax,
+		// transactions recorded before minor units were added have no
+		// currency, and only their float amounts are refunded
+		Currency:       original.Currency,
+		LineTotalMinor: -original.LineTotalMinor,
+		SubtotalMinor:  -original.SubtotalMinor,
+		TaxMinor:       -original.TaxMinor,
+		TimeZone:       c.location().String(),
+	}
+	var restockSKUs []deltaSKU
+	for _, lineItem := range original.LineItems {
+		// returned items were never charged, so there is nothing to reverse
+		if lineItem.Returned {
+			continue
+		}
+		refundLedger.LineItems = append(refundLedger.LineItems, LineItem{
+			SKU:         lineItem.SKU,
+			ProductName: lineItem.ProductName,
+			ItemPrice:   lineItem.ItemPrice,
+			ItemCount:   -lineItem.ItemCount,
+			Unavailable: lineItem.Unavailable,
+			Deposit:     lineItem.Deposit,
+			TaxRate:     lineItem.TaxRate,
+			Tax:         -lineItem.Tax,
+
+			ItemPriceMinor: lineItem.ItemPriceMinor,
+			DepositMinor:   lineItem.DepositMinor,
+			TaxMinor:       -lineItem.TaxMinor,
+
+			PriceOverridden:        lineItem.PriceOverridden,
+			OriginalItemPrice:      lineItem.OriginalItemPrice,
+			OriginalItemPriceMinor: lineItem.OriginalItemPriceMinor,
+			OverrideReason:         lineItem.OverrideReason,
+			Discount:               lineItem.Discount,
+			Rounding:               lineItem.Rounding,
+			RoundingIncrementMinor: lineItem.RoundingIncrementMinor,
+			RoundingRule:           lineItem.RoundingRule,
+		})
+		// discounts and rounding are not items, so there is nothing to restock
+		if lineItem.Discount || lineItem.Rounding {
+			continue
+		}
+		restockSKUs = append(restockSKUs, deltaSKU{SKU: lineItem.SKU, Delta: lineItem.ItemCount})
+	}
+
+	// the items of an evenly split basket are restocked with the refund of
+	// the first payer's share, so that they are not restocked once per share
+	if refund.Restock && len(restockSKUs) > 0 && !original.holdsSplitItems() {
+		c.lc.Infof("Transaction %s is a share of the evenly split basket %s, whose items are restocked with the refund of its first share", tidstr, strconv.FormatInt(original.SplitID, 10))
+		restockSKUs = nil
+	}
+
+	if currency, ok := c.currency.Lookup(original.Currency); ok && original.Currency != "" {
+		refundLedger.DisplayTotal = currency.Format(refundLedger.LineTotalMinor)
+	}
+
+	// the items are restocked before the refund is recorded, so that a
+	// refund whose restock failed is not recorded and can be retried
+	if refund.Restock && len(restockSKUs) > 0 {
+		if err := c.restockInventory(c.inventoryEndpoint, restockSKUs); err != nil {
+			errMsg := fmt.Sprintf("Failed to restock inventory, transaction %v was not refunded: %v", tidstr, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	accountLedgers.Data[accountIndex].Ledgers = append(accountLedgers.Data[accountIndex].Ledgers, refundLedger)
+
+	if err = c.saveLedgers(accountLedgers, accountID); err != nil {
+		errMsg := "failed to write ledger JSON file for refund"
+		if refund.Restock && len(restockSKUs) > 0 {
+			errMsg = "failed to write ledger JSON file for refund, after its items were restocked"
+		}
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Refunded transaction %s with transaction %s", tidstr, strconv.FormatInt(refundLedger.TransactionID, 10))
+	c.publishLedgerEvent(LedgerEventCreated, accountID, refundLedger)
+
+	refundLedgerJSON, err := json.Marshal(refundLedger)
 	if err != nil {
-		c.lc.Warnf("Updated ledger successfully with error %s", err.Error())
-		writer.Write([]byte("Updated ledger successfully, but could not marshal to json"))
-	} else {
-		c.lc.Infof("Updated ledger %s successfully", newLedgerJSON)
-		writer.Write(newLedgerJSON)
+		c.lc.Warnf("Refunded transaction successfully with error %s", err.Error())
+		writer.Write([]byte("Refunded transaction successfully, but could not marshal to json"))
+		return
+	}
+	writer.Write(refundLedgerJSON)
+}
+
+// LedgerContainerReturn records empty containers returned by a customer.
+// A new transaction refunding the container deposits is added to the
+// account, and the returned containers are reported to inventory so they
+// are tracked separately from sellable stock.
+func (c *Controller) LedgerContainerReturn(writer http.ResponseWriter, req *http.Request) {
+	// Get variables from HTTP request
+	vars := mux.Vars(req)
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	var containerReturns []containerReturn
+	if statusCode, err := c.decodeJSONBody(writer, req, &containerReturns); err != nil {
+		errMsg := "Failed to unmarshal request body"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(statusCode)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return
+	}
+	if len(containerReturns) == 0 {
+		errMsg := "No container returns provided"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+	//Get the ledgers of the account
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountIndex := -1
+	for i, account := range accountLedgers.Data {
+		if account.AccountID == accountID {
+			accountIndex = i
+			break
+		}
+	}
+	if accountIndex < 0 {
+		errMsg := fmt.Sprintf("Could not find account %v", strconv.Itoa(accountID))
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	now := time.Now().UnixNano()
+	returnLedger := Ledger{
+		TransactionID: now,
+		TxTimeStamp:   now,
+		LineTotal:     0,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		IsPaid:        false,
+		LineItems:     []LineItem{},
+		TimeZone:      c.location().String(),
+	}
+	for _, containerReturn := range containerReturns {
+		if containerReturn.Count <= 0 {
+			errMsg := fmt.Sprintf("Container return count for %v must be greater than 0", containerReturn.SKU)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		itemInfo, err := c.getInventoryItemInfo(c.inventoryEndpoint, containerReturn.SKU)
+		if err != nil {
+			errMsg := fmt.Sprintf("Could not find product Info for %v error: %v", containerReturn.SKU, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		if itemInfo.Deposit <= 0 {
+			errMsg := fmt.Sprintf("Product %v does not have a container deposit", containerReturn.SKU)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		depositMinor, err := c.currency.ToBaseMinor(itemInfo.Deposit, itemInfo.Currency)
+		if err != nil {
+			errMsg := fmt.Sprintf("Could not convert the deposit of %v: %v", containerReturn.SKU, err.Error())
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+		lineItem := LineItem{
+			SKU:             containerReturn.SKU,
+			ProductName:     itemInfo.ProductName,
+			ItemCount:       -containerReturn.Count,
+			ContainerReturn: true,
+			DepositMinor:    depositMinor,
+		}
+		returnLedger.LineItems = append(returnLedger.LineItems, lineItem)
+		returnLedger.LineTotalMinor = returnLedger.LineTotalMinor + (lineItem.DepositMinor * int64(lineItem.ItemCount))
+	}
+	returnLedger.setAmounts(c.currency.Base())
+
+	// only the containers whose deposit the account paid, and has not been
+	// refunded yet, can be returned
+	returnable := accountLedgers.Data[accountIndex].returnableContainers()
+	returned := map[string]int{}
+	for _, containerReturn := range containerReturns {
+		returned[containerReturn.SKU] += containerReturn.Count
+		if returned[containerReturn.SKU] > returnable[containerReturn.SKU] {
+			errMsg := fmt.Sprintf("Account %v can return at most %d container(s) of %v, which is the number of deposits it paid and has not been refunded", accountID, returnable[containerReturn.SKU], containerReturn.SKU)
+			c.lc.Error(errMsg)
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(errMsg))
+			return
+		}
+	}
+
+	// the inventory records the containers before the deposits are refunded,
+	// so that a return it rejects is not refunded and can be retried
+	if err := c.recordContainerReturns(c.inventoryEndpoint, containerReturns); err != nil {
+		errMsg := fmt.Sprintf("Failed to record the container returns in inventory, the deposits were not refunded: %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountLedgers.Data[accountIndex].Ledgers = append(accountLedgers.Data[accountIndex].Ledgers, returnLedger)
+
+	if err = c.saveLedgers(accountLedgers, accountID); err != nil {
+		errMsg := "failed to write ledger JSON file for container return, after the containers were recorded in inventory"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Recorded container return transaction %s for account %v", strconv.FormatInt(returnLedger.TransactionID, 10), accountID)
+	c.publishLedgerEvent(LedgerEventCreated, accountID, returnLedger)
+
+	returnLedgerJSON, err := json.Marshal(returnLedger)
+	if err != nil {
+		c.lc.Warnf("Recorded container return successfully with error %s", err.Error())
+		writer.Write([]byte("Recorded container return successfully, but could not marshal to json"))
+		return
+	}
+	writer.Write(returnLedgerJSON)
+}
+
+// returnableContainers returns how many containers of each SKU the account
+// can return: those whose deposit it paid, less those refunded with their
+// item or returned already. Deposits of voided transactions and test vends
+// were not paid, and the deposits of an evenly split basket count with the
+// first payer, whose share holds its items.
+func (account Account) returnableContainers() map[string]int {
+	returnable := map[string]int{}
+	for _, ledger := range account.Ledgers {
+		if ledger.IsVoided || ledger.IsTest || !ledger.holdsSplitItems() {
+			continue
+		}
+		for _, lineItem := range ledger.LineItems {
+			if lineItem.ContainerReturn {
+				// the count of a container return is negative
+				returnable[lineItem.SKU] = returnable[lineItem.SKU] + lineItem.ItemCount
+				continue
+			}
+			if lineItem.Unavailable || lineItem.Returned || lineItem.Discount || lineItem.Rounding {
+				continue
+			}
+			if lineItem.DepositMinor > 0 || lineItem.Deposit > 0 {
+				// the count of a refunded item is negative
+				returnable[lineItem.SKU] = returnable[lineItem.SKU] + lineItem.ItemCount
+			}
+		}
 	}
+	return returnable
+}
+
+// recordContainerReturns is a helper function that sends the returned
+// containers to the inventory returns endpoint. A return the inventory
+// service does not record is an error.
+func (c *Controller) recordContainerReturns(inventoryEndpoint string, containerReturns []containerReturn) error {
+	outputBytes, err := json.Marshal(containerReturns)
+	if err != nil {
+		return fmt.Errorf("failed to marshal container returns: %s", err.Error())
+	}
+
+	return c.postInventory(inventoryEndpoint+"/returns", outputBytes)
+}
+
+// restockInventory is a helper function that sends the given SKU deltas
+// to the inventory delta endpoint. Refunded items are returned as a
+// correction of the sale, so that they are not counted as sales or restocks.
+// A delta the inventory service does not apply is an error.
+func (c *Controller) restockInventory(inventoryEndpoint string, deltaSKUs []deltaSKU) error {
+	inventoryDeltas := []inventoryDelta{}
+	for _, item := range deltaSKUs {
+		inventoryDeltas = append(inventoryDeltas, inventoryDelta{
+			SKU:    item.SKU,
+			Delta:  item.Delta,
+			Reason: inventoryDeltaReasonCorrection,
+			Source: deltaSource{Service: inventoryDeltaService},
+		})
+	}
+	outputBytes, err := json.Marshal(inventoryDeltas)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory delta: %s", err.Error())
+	}
+
+	return c.postInventory(inventoryEndpoint+"/delta", outputBytes)
 }
 
 // getInventoryItemInfo is a helper function that will take the inference data (SKU)
 // and return product details for a transaction to be recorded in the ledger
 func (c *Controller) getInventoryItemInfo(inventoryEndpoint string, SKU string) (Product, error) {
+	if product, ok := c.productCache.Get(SKU); ok {
+		return product, nil
+	}
 
 	resp, err := c.sendCommand("GET", inventoryEndpoint+"/"+SKU, []byte(""))
 	if err != nil {
@@ -222,6 +1176,7 @@ func (c *Controller) getInventoryItemInfo(inventoryEndpoint string, SKU string)
 	if err != nil {
 		return Product{}, fmt.Errorf("Received an invalid data structure from InventoryEndpoint")
 	}
+	c.productCache.Set(inventoryItem)
 
 	return inventoryItem, nil
 }
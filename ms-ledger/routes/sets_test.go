@@ -6,15 +6,23 @@ package routes
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"ms-ledger/payment"
+	paymentMocks "ms-ledger/payment/mocks"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 
 	"github.com/edgexfoundry/app-functions-sdk-go/v3/pkg/interfaces/mocks"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -33,6 +41,26 @@ func getDefaultProduct() Product {
 	}
 }
 
+// getUnavailableProduct returns a product whose only availability window
+// starts two hours from now, so it can never be vended during a test
+func getUnavailableProduct() Product {
+	product := getDefaultProduct()
+	product.SKU = "4900002471"
+	now := time.Now()
+	product.Availability = []AvailabilityWindow{{
+		Start: now.Add(2 * time.Hour).Format("15:04"),
+		End:   now.Add(3 * time.Hour).Format("15:04"),
+	}}
+	return product
+}
+
+func getDepositProduct() Product {
+	product := getDefaultProduct()
+	product.SKU = "4900002472"
+	product.Deposit = 0.25
+	return product
+}
+
 func newInventoryTestServer(t *testing.T) *httptest.Server {
 
 	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,6 +69,71 @@ func newInventoryTestServer(t *testing.T) *httptest.Server {
 		defaultProduct := getDefaultProduct()
 		sku := r.RequestURI
 
+		if (sku == "/delta" || sku == "/returns") && r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// batch lookup of the posted SKUs
+		if sku == "/batch" && r.Method == http.MethodPost {
+			var batch inventorySKUBatch
+			if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			products := inventoryProducts{Data: []Product{}}
+			for _, product := range []Product{defaultProduct, getDepositProduct(), getUnavailableProduct()} {
+				for _, batchSKU := range batch.SKUs {
+					if batchSKU == product.SKU {
+						products.Data = append(products.Data, product)
+						break
+					}
+				}
+			}
+			jsonProducts, _ := json.Marshal(products)
+			_, err := w.Write(jsonProducts)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			return
+		}
+
+		// lookup of the products in the skus query parameter
+		if r.URL.Path == "/" && r.Method == http.MethodGet {
+			products := inventoryProducts{Data: []Product{}}
+			for _, product := range []Product{defaultProduct, getDepositProduct(), getUnavailableProduct()} {
+				if strings.Contains(","+r.URL.Query().Get("skus")+",", ","+product.SKU+",") {
+					products.Data = append(products.Data, product)
+				}
+			}
+			jsonProducts, _ := json.Marshal(products)
+			_, err := w.Write(jsonProducts)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			return
+		}
+
+		if depositProduct := getDepositProduct(); sku == "/"+depositProduct.SKU {
+			w.WriteHeader(http.StatusOK)
+			jsonProduct, _ := json.Marshal(depositProduct)
+			_, err := w.Write(jsonProduct)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			return
+		}
+
+		if unavailableProduct := getUnavailableProduct(); sku == "/"+unavailableProduct.SKU {
+			w.WriteHeader(http.StatusOK)
+			jsonProduct, _ := json.Marshal(unavailableProduct)
+			_, err := w.Write(jsonProduct)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			return
+		}
+
 		if sku == "/"+defaultProduct.SKU {
 			w.WriteHeader(http.StatusOK)
 			jsonProduct, _ := json.Marshal(defaultProduct)
@@ -78,6 +171,10 @@ func TestLedgerAddTransaction(t *testing.T) {
 		{"Nonexistent accountID", false, `{"accountId":10,"deltaSKUs":[{"sku":"4900002470","delta":-1}]}`, http.StatusBadRequest},
 		{"bad data for SKU", false, `{"accountId":2,"deltaSKUs":[{"sku":"badSKU","delta":-1}]}`, http.StatusBadRequest},
 		{"Nonexistent SKU in inventory", false, `{"accountId":2,"deltaSKUs":[{"sku":"4900002479","delta":-1}]}`, http.StatusBadRequest},
+		{"SKU outside availability window", false, `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1},{"sku":"4900002471","delta":-1}]}`, http.StatusOK},
+		{"SKU taken and partly put back", false, `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-2},{"sku":"4900002470","delta":1}]}`, http.StatusOK},
+		{"SKU put back without being taken", false, `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1},{"sku":"4900002472","delta":1}]}`, http.StatusOK},
+		{"Flagged for review", false, `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}],"flagReason":"Items were entered by hand"}`, http.StatusOK},
 		{"Invalid Ledger", true, `{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}]}`, http.StatusInternalServerError},
 	}
 
@@ -117,10 +214,519 @@ func TestLedgerAddTransaction(t *testing.T) {
 			defer resp.Body.Close()
 
 			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var newLedger Ledger
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&newLedger))
+			flagged := strings.Contains(currentTest.UpdateLedger, "flagReason")
+			for _, lineItem := range newLedger.LineItems {
+				flagged = flagged || lineItem.Unavailable
+			}
+			assert.Equal(t, flagged, newLedger.IsFlagged)
+			assert.Equal(t, 1.99, newLedger.LineTotal, "unavailable items should not be charged")
+		})
+	}
+}
+
+func TestLedgerAddTransactionTax(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+		taxTable:          TaxTable{DefaultTaxCategory: 0.08},
+	}
+	data, err := json.Marshal(getDefaultAccountLedgers())
+	require.NoError(t, err)
+	err = os.WriteFile(c.ledgerFileName, data, 0644)
+	require.NoError(t, err)
+	defer func() {
+		os.Remove(c.ledgerFileName)
+	}()
+
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer([]byte(`{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1},{"sku":"4900002472","delta":-2}]}`)))
+	w := httptest.NewRecorder()
+	c.LedgerAddTransaction(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+
+	var newLedger Ledger
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&newLedger))
+	require.Len(t, newLedger.LineItems, 2)
+	assert.Equal(t, 0.16, newLedger.LineItems[0].Tax)
+	assert.Equal(t, 0.32, newLedger.LineItems[1].Tax, "deposits should not be taxed")
+	assert.InDelta(t, 5.97, newLedger.Subtotal, 0.001)
+	assert.InDelta(t, 0.48, newLedger.Tax, 0.001)
+	assert.InDelta(t, 5.97+0.50+0.48, newLedger.LineTotal, 0.001)
+	assert.Equal(t, "USD", newLedger.Currency)
+	assert.Equal(t, int64(695), newLedger.LineTotalMinor)
+	assert.Equal(t, "$6.95", newLedger.DisplayTotal)
+}
+
+func TestLedgerAddTransactionTestVend(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+		taxTable:          TaxTable{DefaultTaxCategory: 0.08},
+	}
+	accountLedgers := getDefaultAccountLedgers()
+	hold := &Hold{AuthorizationID: "ch_1", AmountMinor: 2000, Currency: "USD", Status: HoldStatusAuthorized}
+	accountLedgers.Data[1].Hold = hold
+	data, err := json.Marshal(accountLedgers)
+	require.NoError(t, err)
+	err = os.WriteFile(c.ledgerFileName, data, 0644)
+	require.NoError(t, err)
+	defer func() {
+		os.Remove(c.ledgerFileName)
+	}()
+
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer([]byte(`{"accountId":2,"isTest":true,"deltaSKUs":[{"sku":"4900002470","delta":-1},{"sku":"4900002472","delta":-2}]}`)))
+	w := httptest.NewRecorder()
+	c.LedgerAddTransaction(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+
+	var newLedger Ledger
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&newLedger))
+	assert.True(t, newLedger.IsTest)
+	assert.True(t, newLedger.IsPaid, "there is nothing to charge for a test vend")
+	assert.Nil(t, newLedger.Hold, "a test vend should not settle the account's hold")
+	require.Len(t, newLedger.LineItems, 2, "the items should be kept for audit")
+	for _, lineItem := range newLedger.LineItems {
+		assert.Zero(t, lineItem.ItemPriceMinor)
+		assert.Zero(t, lineItem.DepositMinor)
+		assert.Zero(t, lineItem.TaxMinor)
+	}
+	assert.Zero(t, newLedger.LineTotalMinor)
+	assert.Equal(t, "$0.00", newLedger.DisplayTotal)
+
+	accountLedgers, err = c.GetAllLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, hold, accountLedgers.Data[1].Hold)
+}
+
+func TestLedgerSplitTransaction(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+
+	tests := []struct {
+		Name               string
+		InvalidLedger      bool
+		Body               string
+		ExpectedStatusCode int
+		ExpectedLedgers    int
+	}{
+		{"Even split", false, `{"accountIds":[1,2],"rule":"even","deltaSKUs":[{"sku":"4900002470","delta":-2}]}`, http.StatusOK, 2},
+		{"Itemized split", false, `{"accountIds":[1,2],"rule":"itemized","deltaSKUs":[{"sku":"4900002470","delta":-2}],"assignments":[{"accountId":1,"sku":"4900002470","count":1},{"accountId":2,"sku":"4900002470","count":1}]}`, http.StatusOK, 2},
+		{"Flagged for review", false, `{"accountIds":[1,2],"rule":"even","deltaSKUs":[{"sku":"4900002470","delta":-2}],"flagReason":"Low inference confidence"}`, http.StatusOK, 2},
+		{"Itemized split with unassigned items", false, `{"accountIds":[1,2],"rule":"itemized","deltaSKUs":[{"sku":"4900002470","delta":-2}],"assignments":[{"accountId":1,"sku":"4900002470","count":1}]}`, http.StatusBadRequest, 0},
+		{"Single account", false, `{"accountIds":[1],"rule":"even","deltaSKUs":[{"sku":"4900002470","delta":-2}]}`, http.StatusBadRequest, 0},
+		{"Nonexistent account", false, `{"accountIds":[1,10],"rule":"even","deltaSKUs":[{"sku":"4900002470","delta":-2}]}`, http.StatusBadRequest, 0},
+		{"Nonexistent SKU in inventory", false, `{"accountIds":[1,2],"rule":"even","deltaSKUs":[{"sku":"4900002479","delta":-2}]}`, http.StatusBadRequest, 0},
+		{"Unknown rule", false, `{"accountIds":[1,2],"rule":"random","deltaSKUs":[{"sku":"4900002470","delta":-2}]}`, http.StatusBadRequest, 0},
+		{"Bad body", false, `invalid`, http.StatusBadRequest, 0},
+		{"Invalid Ledger", true, `{"accountIds":[1,2],"rule":"even","deltaSKUs":[{"sku":"4900002470","delta":-2}]}`, http.StatusInternalServerError, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				service:           nil,
+				inventoryEndpoint: inventoryServer.URL,
+				ledgerFileName:    LedgerFileName,
+			}
+			var err error
+			if currentTest.InvalidLedger {
+				err = os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644)
+			} else {
+				data, err := json.Marshal(getDefaultAccountLedgers())
+				require.NoError(t, err)
+				err = os.WriteFile(c.ledgerFileName, data, 0644)
+				require.NoError(t, err)
+			}
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/split", bytes.NewBuffer([]byte(currentTest.Body)))
+			w := httptest.NewRecorder()
+			c.LedgerSplitTransaction(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var splitLedgers []Ledger
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&splitLedgers))
+			require.Len(t, splitLedgers, currentTest.ExpectedLedgers)
+			assert.Equal(t, int64(398), splitLedgers[0].LineTotalMinor+splitLedgers[1].LineTotalMinor)
+			for _, splitLedger := range splitLedgers {
+				assert.Equal(t, strings.Contains(currentTest.Body, "flagReason"), splitLedger.IsFlagged)
+			}
+
+			accountLedgers, err := c.GetAllLedgers()
+			require.NoError(t, err)
+			for _, account := range accountLedgers.Data {
+				assert.Len(t, account.Ledgers, 2, "every account should have its share of the split")
+			}
+		})
+	}
+}
+
+func TestLedgerRefund(t *testing.T) {
+	// Default variables
+	defaultAccountID := "1"
+	defaultTransactionID := "1579215712984890248"
+
+	inventoryServer := newInventoryTestServer(t)
+
+	tests := []struct {
+		Name               string
+		InvalidLedger      bool
+		AccountID          string
+		TransactionID      string
+		Body               string
+		InventoryEndpoint  string
+		ExpectedStatusCode int
+	}{
+		{"Valid refund", false, defaultAccountID, defaultTransactionID, "", inventoryServer.URL, http.StatusOK},
+		{"Valid refund with restock", false, defaultAccountID, defaultTransactionID, `{"restock":true}`, inventoryServer.URL, http.StatusOK},
+		{"Restock failure", false, defaultAccountID, defaultTransactionID, `{"restock":true}`, "badURL", http.StatusInternalServerError},
+		{"Bad data AccountID", false, "badformat", defaultTransactionID, "", inventoryServer.URL, http.StatusBadRequest},
+		{"Nonexistent AccountID", false, "10", defaultTransactionID, "", inventoryServer.URL, http.StatusBadRequest},
+		{"Bad data TransactionID", false, defaultAccountID, "badformat", "", inventoryServer.URL, http.StatusBadRequest},
+		{"Nonexistent TransactionID", false, defaultAccountID, "1579215712984890249", "", inventoryServer.URL, http.StatusBadRequest},
+		{"Bad body", false, defaultAccountID, defaultTransactionID, `{"restock":"yes"}`, inventoryServer.URL, http.StatusBadRequest},
+		{"Invalid Ledger", true, defaultAccountID, defaultTransactionID, "", inventoryServer.URL, http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				service:           nil,
+				inventoryEndpoint: currentTest.InventoryEndpoint,
+				ledgerFileName:    LedgerFileName,
+			}
+			var err error
+			if currentTest.InvalidLedger {
+				err = os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644)
+			} else {
+				data, err := json.Marshal(getDefaultAccountLedgers())
+				require.NoError(t, err)
+				err = os.WriteFile(c.ledgerFileName, data, 0644)
+				require.NoError(t, err)
+			}
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/"+currentTest.AccountID+"/"+currentTest.TransactionID+"/refund", bytes.NewBuffer([]byte(currentTest.Body)))
+			req = mux.SetURLVars(req, map[string]string{
+				"accountid": currentTest.AccountID,
+				"tid":       currentTest.TransactionID,
+			})
+			w := httptest.NewRecorder()
+			c.LedgerRefund(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var refundLedger Ledger
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&refundLedger))
+			assert.Equal(t, int64(1579215712984890248), refundLedger.RefundOf)
+			assert.Equal(t, -1.99, refundLedger.LineTotal)
+			assert.Equal(t, -1, refundLedger.LineItems[0].ItemCount)
+
+			// a second refund of the same transaction must be rejected
+			req = httptest.NewRequest("POST", "http://localhost:48093/ledger/"+currentTest.AccountID+"/"+currentTest.TransactionID+"/refund", nil)
+			req = mux.SetURLVars(req, map[string]string{
+				"accountid": currentTest.AccountID,
+				"tid":       currentTest.TransactionID,
+			})
+			w = httptest.NewRecorder()
+			c.LedgerRefund(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "invalid status code")
+		})
+	}
+}
+
+func TestLedgerRefundRestockRejected(t *testing.T) {
+	restockStatus := http.StatusConflict
+	restocks := 0
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/delta", r.URL.Path)
+		restocks++
+		w.WriteHeader(restockStatus)
+		w.Write([]byte("not enough units on hand"))
+	}))
+	defer inventoryServer.Close()
+
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+	}
+	data, err := json.Marshal(getDefaultAccountLedgers())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.ledgerFileName, data, 0644))
+	defer os.Remove(c.ledgerFileName)
+
+	refund := func() *http.Response {
+		req := httptest.NewRequest("POST", "http://localhost:48093/ledger/1/1579215712984890248/refund", bytes.NewBuffer([]byte(`{"restock":true}`)))
+		req = mux.SetURLVars(req, map[string]string{
+			"accountid": "1",
+			"tid":       "1579215712984890248",
+		})
+		w := httptest.NewRecorder()
+		c.LedgerRefund(w, req)
+		return w.Result()
+	}
+
+	// a restock the inventory service rejects does not record the refund
+	resp := refund()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "invalid status code")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "not enough units on hand")
+	accountLedgers, err := c.getLedgers(1)
+	require.NoError(t, err)
+	for _, ledger := range accountLedgers.Data[0].Ledgers {
+		assert.Zero(t, ledger.RefundOf, "the refund should not be recorded")
+	}
+
+	// so that the refund can be retried once the inventory accepts it
+	restockStatus = http.StatusOK
+	resp = refund()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+	assert.Equal(t, 2, restocks)
+}
+
+func TestLedgerRefundEvenSplitShare(t *testing.T) {
+	var restocked []inventoryDelta
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/delta", r.URL.Path)
+		var deltas []inventoryDelta
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&deltas))
+		restocked = append(restocked, deltas...)
+	}))
+	defer inventoryServer.Close()
+
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+	}
+	basket := getSplitBasket()
+	shares, err := splitTransaction(basket, []int{1, 2}, SplitRuleEven, nil, CurrencyConverter{}.Base())
+	require.NoError(t, err)
+	accountLedgers := Accounts{Data: []Account{
+		{AccountID: 1, Ledgers: []Ledger{shares[0]}},
+		{AccountID: 2, Ledgers: []Ledger{shares[1]}},
+	}}
+	data, err := json.Marshal(accountLedgers)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.ledgerFileName, data, 0644))
+	defer os.Remove(c.ledgerFileName)
+
+	refund := func(accountID string, tid int64) {
+		req := httptest.NewRequest("POST", "http://localhost:48093/ledger/"+accountID+"/"+strconv.FormatInt(tid, 10)+"/refund", bytes.NewBuffer([]byte(`{"restock":true}`)))
+		req = mux.SetURLVars(req, map[string]string{
+			"accountid": accountID,
+			"tid":       strconv.FormatInt(tid, 10),
+		})
+		w := httptest.NewRecorder()
+		c.LedgerRefund(w, req)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, "invalid status code")
+	}
+
+	// the second share lists the items, but they are not its own
+	refund("2", shares[1].TransactionID)
+	assert.Empty(t, restocked)
+
+	refund("1", shares[0].TransactionID)
+	assert.Equal(t, []inventoryDelta{
+		{SKU: "4900002470", Delta: 2, Reason: "correction", Source: deltaSource{Service: "ms-ledger"}},
+		{SKU: "4900002472", Delta: 1, Reason: "correction", Source: deltaSource{Service: "ms-ledger"}},
+	}, restocked, "each item is restocked once")
+}
+
+func TestLedgerContainerReturn(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+	// an inventory service that finds the products, but rejects the returns
+	rejectingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/returns" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("SKU 4900002472 does not have a container deposit"))
+			return
+		}
+		inventoryServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer rejectingServer.Close()
+
+	tests := []struct {
+		Name               string
+		InvalidLedger      bool
+		AccountID          string
+		Body               string
+		InventoryEndpoint  string
+		ExpectedStatusCode int
+	}{
+		{"Valid container return", false, "1", `[{"sku":"4900002472","count":2}]`, inventoryServer.URL, http.StatusOK},
+		{"More containers than deposits paid", false, "1", `[{"sku":"4900002472","count":3}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Same SKU beyond deposits paid", false, "1", `[{"sku":"4900002472","count":2},{"sku":"4900002472","count":1}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Deposits of another account", false, "2", `[{"sku":"4900002472","count":1}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Inventory rejects the return", false, "1", `[{"sku":"4900002472","count":2}]`, rejectingServer.URL, http.StatusInternalServerError},
+		{"Product without deposit", false, "1", `[{"sku":"4900002470","count":1}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Nonexistent SKU in inventory", false, "1", `[{"sku":"4900002479","count":1}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Non-positive count", false, "1", `[{"sku":"4900002472","count":0}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Empty return", false, "1", `[]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Bad body", false, "1", `{"sku":"4900002472"}`, inventoryServer.URL, http.StatusBadRequest},
+		{"Bad data AccountID", false, "badformat", `[{"sku":"4900002472","count":2}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Nonexistent AccountID", false, "10", `[{"sku":"4900002472","count":2}]`, inventoryServer.URL, http.StatusBadRequest},
+		{"Invalid Ledger", true, "1", `[{"sku":"4900002472","count":2}]`, inventoryServer.URL, http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				service:           nil,
+				inventoryEndpoint: currentTest.InventoryEndpoint,
+				ledgerFileName:    LedgerFileName,
+			}
+			var err error
+			if currentTest.InvalidLedger {
+				err = os.WriteFile(c.ledgerFileName, []byte("invalid json test"), 0644)
+			} else {
+				// account 1 paid the deposits of 3 containers, and got one of
+				// them refunded with its item
+				accountLedgers := getDefaultAccountLedgers()
+				accountLedgers.Data[0].Ledgers = append(accountLedgers.Data[0].Ledgers, Ledger{
+					TransactionID: 1579215712984890300,
+					LineItems:     []LineItem{{SKU: "4900002472", ItemCount: 3, ItemPriceMinor: 199, DepositMinor: 25}},
+				}, Ledger{
+					TransactionID: 1579215712984890400,
+					RefundOf:      1579215712984890300,
+					LineItems:     []LineItem{{SKU: "4900002472", ItemCount: -1, ItemPriceMinor: 199, DepositMinor: 25}},
+				})
+				data, err := json.Marshal(accountLedgers)
+				require.NoError(t, err)
+				err = os.WriteFile(c.ledgerFileName, data, 0644)
+				require.NoError(t, err)
+			}
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+			ledgersBefore, _ := os.ReadFile(c.ledgerFileName)
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/"+currentTest.AccountID+"/returns", bytes.NewBuffer([]byte(currentTest.Body)))
+			req = mux.SetURLVars(req, map[string]string{
+				"accountid": currentTest.AccountID,
+			})
+			w := httptest.NewRecorder()
+			c.LedgerContainerReturn(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				// a rejected return does not refund any deposits
+				ledgersAfter, _ := os.ReadFile(c.ledgerFileName)
+				assert.Equal(t, string(ledgersBefore), string(ledgersAfter))
+				return
+			}
+
+			var returnLedger Ledger
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&returnLedger))
+			assert.InDelta(t, -0.50, returnLedger.LineTotal, 0.001, "the deposit should be refunded")
+			require.Len(t, returnLedger.LineItems, 1)
+			assert.True(t, returnLedger.LineItems[0].ContainerReturn)
+			assert.Equal(t, -2, returnLedger.LineItems[0].ItemCount)
+
+			// a container return cannot be refunded
+			tid := strconv.FormatInt(returnLedger.TransactionID, 10)
+			req = httptest.NewRequest("POST", "http://localhost:48093/ledger/"+currentTest.AccountID+"/"+tid+"/refund", nil)
+			req = mux.SetURLVars(req, map[string]string{
+				"accountid": currentTest.AccountID,
+				"tid":       tid,
+			})
+			w = httptest.NewRecorder()
+			c.LedgerRefund(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "invalid status code")
+
+			// the containers returned already cannot be returned again
+			req = httptest.NewRequest("POST", "http://localhost:48093/ledger/"+currentTest.AccountID+"/returns", bytes.NewBuffer([]byte(`[{"sku":"4900002472","count":1}]`)))
+			req = mux.SetURLVars(req, map[string]string{
+				"accountid": currentTest.AccountID,
+			})
+			w = httptest.NewRecorder()
+			c.LedgerContainerReturn(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "invalid status code")
 		})
 	}
 }
 
+func TestReturnableContainers(t *testing.T) {
+	deposit := LineItem{SKU: "4900002472", ItemCount: 2, DepositMinor: 25}
+	unavailable := deposit
+	unavailable.Unavailable = true
+	legacy := LineItem{SKU: "4900002473", ItemCount: 1, Deposit: 0.10}
+	account := Account{Ledgers: []Ledger{
+		{TransactionID: 1, LineItems: []LineItem{deposit, legacy, {SKU: "4900002470", ItemCount: 1}}},
+		{TransactionID: 2, IsVoided: true, LineItems: []LineItem{deposit}},
+		{TransactionID: 3, IsTest: true, LineItems: []LineItem{deposit}},
+		{TransactionID: 4, LineItems: []LineItem{unavailable}},
+		// the second share of an evenly split basket lists its items too
+		{TransactionID: 6, SplitID: 5, SplitRule: SplitRuleEven, LineItems: []LineItem{deposit}},
+		{TransactionID: 7, LineItems: []LineItem{{SKU: "4900002472", ItemCount: -1, DepositMinor: 25, ContainerReturn: true}}},
+	}}
+	assert.Equal(t, map[string]int{"4900002472": 1, "4900002473": 1}, account.returnableContainers())
+}
+
+func TestRestockInventory(t *testing.T) {
+	var posted []inventoryDelta
+	inventoryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/delta", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&posted))
+	}))
+	defer inventoryServer.Close()
+
+	c := Controller{lc: logger.NewMockClient()}
+	require.NoError(t, c.restockInventory(inventoryServer.URL, []deltaSKU{{SKU: "4900002470", Delta: 2}}))
+
+	// refunded items are corrections of the sale, not restocks
+	assert.Equal(t, []inventoryDelta{{SKU: "4900002470", Delta: 2, Reason: "correction", Source: deltaSource{Service: "ms-ledger"}}}, posted)
+}
+
 func TestGetInventoryItemInfo(t *testing.T) {
 
 	// Default variables
@@ -230,3 +836,419 @@ func TestSetPaymentStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestSetPaymentStatusWithProvider(t *testing.T) {
+	paymentInfo := `{"accountId":1,"transactionID":"1579215712984890248","isPaid": true }`
+
+	tests := []struct {
+		Name                 string
+		PaymentMethod        string
+		Charge               payment.Charge
+		ChargeError          error
+		ExpectedStatusCode   int
+		ExpectedIsPaid       bool
+		ExpectedChargeStatus string
+	}{
+		{"Successful charge", "cus_1", payment.Charge{ID: "ch_1", Status: payment.ChargeStatusSucceeded}, nil, http.StatusOK, true, payment.ChargeStatusSucceeded},
+		{"Declined charge", "cus_1", payment.Charge{ID: "ch_1", Status: payment.ChargeStatusFailed}, nil, http.StatusPaymentRequired, false, payment.ChargeStatusFailed},
+		{"Pending charge", "cus_1", payment.Charge{ID: "ch_1", Status: payment.ChargeStatusPending}, nil, http.StatusPaymentRequired, false, payment.ChargeStatusPending},
+		{"Provider error", "cus_1", payment.Charge{}, errors.New("connection refused"), http.StatusBadGateway, false, ""},
+		{"No stored payment method", "", payment.Charge{}, nil, http.StatusBadRequest, false, ""},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockProvider := &paymentMocks.Provider{}
+			mockProvider.On("Charge", payment.ChargeRequest{
+				PaymentMethod:  "cus_1",
+				AmountMinor:    199,
+				Currency:       "USD",
+				Description:    DefaultStoreName + " transaction 1579215712984890248",
+				IdempotencyKey: "1-1579215712984890248",
+			}).Return(currentTest.Charge, currentTest.ChargeError)
+
+			c := Controller{
+				lc:              logger.NewMockClient(),
+				service:         nil,
+				ledgerFileName:  LedgerFileName,
+				storeName:       DefaultStoreName,
+				paymentProvider: mockProvider,
+			}
+			accountLedgers := getDefaultAccountLedgers()
+			accountLedgers.Data[0].PaymentMethod = currentTest.PaymentMethod
+			data, err := json.Marshal(accountLedgers)
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/ledgerPaymentUpdate", bytes.NewBuffer([]byte(paymentInfo)))
+			w := httptest.NewRecorder()
+			c.SetPaymentStatus(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			accountLedgers, err = c.GetAllLedgers()
+			require.NoError(t, err)
+			ledger := accountLedgers.Data[0].Ledgers[0]
+			assert.Equal(t, currentTest.ExpectedIsPaid, ledger.IsPaid)
+			assert.Equal(t, currentTest.ExpectedIsPaid, ledger.PaidAt != 0)
+			assert.Equal(t, currentTest.ExpectedChargeStatus, ledger.ChargeStatus)
+			if currentTest.ExpectedChargeStatus != "" {
+				assert.Equal(t, "ch_1", ledger.ChargeID)
+			}
+		})
+	}
+}
+
+func TestSetPaymentStatusWithHold(t *testing.T) {
+	paymentInfo := `{"accountId":1,"transactionID":"1579215712984890248","isPaid": true }`
+
+	tests := []struct {
+		Name               string
+		LineTotalMinor     int64
+		CaptureStatus      string
+		ExpectedCapture    int64
+		ExpectedCharge     int64
+		ExpectedRelease    bool
+		ExpectedStatusCode int
+		ExpectedIsPaid     bool
+		ExpectedHoldStatus string
+	}{
+		{"Total under the hold", 455, payment.ChargeStatusSucceeded, 455, 0, false, http.StatusOK, true, HoldStatusCaptured},
+		{"Total over the hold", 2455, payment.ChargeStatusSucceeded, 2000, 455, false, http.StatusOK, true, HoldStatusCaptured},
+		{"Nothing to charge", 0, "", 0, 0, true, http.StatusOK, true, HoldStatusReleased},
+		{"Capture failed", 455, payment.ChargeStatusFailed, 455, 0, false, http.StatusPaymentRequired, false, HoldStatusAuthorized},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockProvider := &paymentMocks.Provider{}
+			mockProvider.On("Capture", payment.CaptureRequest{
+				AuthorizationID: "ch_hold",
+				AmountMinor:     currentTest.ExpectedCapture,
+				IdempotencyKey:  "1-1579215712984890248-capture",
+			}).Return(payment.Charge{ID: "ch_hold", Status: currentTest.CaptureStatus}, nil)
+			mockProvider.On("Charge", mock.Anything).Return(payment.Charge{ID: "ch_2", Status: payment.ChargeStatusSucceeded}, nil)
+			mockProvider.On("Release", "ch_hold").Return(nil)
+
+			c := Controller{
+				lc:              logger.NewMockClient(),
+				service:         nil,
+				ledgerFileName:  LedgerFileName,
+				storeName:       DefaultStoreName,
+				paymentProvider: mockProvider,
+			}
+			accountLedgers := getDefaultAccountLedgers()
+			accountLedgers.Data[0].PaymentMethod = "cus_1"
+			accountLedgers.Data[0].Ledgers[0].Currency = "USD"
+			accountLedgers.Data[0].Ledgers[0].LineTotalMinor = currentTest.LineTotalMinor
+			accountLedgers.Data[0].Ledgers[0].Hold = &Hold{AuthorizationID: "ch_hold", AmountMinor: 2000, Currency: "USD", Status: HoldStatusAuthorized}
+			data, err := json.Marshal(accountLedgers)
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/ledgerPaymentUpdate", bytes.NewBuffer([]byte(paymentInfo)))
+			w := httptest.NewRecorder()
+			c.SetPaymentStatus(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+
+			if currentTest.ExpectedCapture > 0 {
+				mockProvider.AssertCalled(t, "Capture", mock.Anything)
+			} else {
+				mockProvider.AssertNotCalled(t, "Capture", mock.Anything)
+			}
+			if currentTest.ExpectedCharge > 0 {
+				mockProvider.AssertCalled(t, "Charge", payment.ChargeRequest{
+					PaymentMethod:  "cus_1",
+					AmountMinor:    currentTest.ExpectedCharge,
+					Currency:       "USD",
+					Description:    DefaultStoreName + " transaction 1579215712984890248 over the held amount",
+					IdempotencyKey: "1-1579215712984890248",
+				})
+			} else {
+				mockProvider.AssertNotCalled(t, "Charge", mock.Anything)
+			}
+			if currentTest.ExpectedRelease {
+				mockProvider.AssertCalled(t, "Release", "ch_hold")
+			} else {
+				mockProvider.AssertNotCalled(t, "Release", mock.Anything)
+			}
+
+			accountLedgers, err = c.GetAllLedgers()
+			require.NoError(t, err)
+			ledger := accountLedgers.Data[0].Ledgers[0]
+			assert.Equal(t, currentTest.ExpectedIsPaid, ledger.IsPaid)
+			require.NotNil(t, ledger.Hold)
+			assert.Equal(t, currentTest.ExpectedHoldStatus, ledger.Hold.Status)
+		})
+	}
+}
+
+func TestLedgerPreAuthorize(t *testing.T) {
+	existingHold := &Hold{AuthorizationID: "ch_existing", AmountMinor: 2000, Currency: "USD", Status: HoldStatusAuthorized}
+
+	tests := []struct {
+		Name               string
+		AccountID          string
+		NoProvider         bool
+		PaymentMethod      string
+		ExistingHold       *Hold
+		Charge             payment.Charge
+		ChargeError        error
+		ExpectedStatusCode int
+		ExpectedRequired   bool
+		ExpectedHoldID     string
+	}{
+		{"Hold placed", "1", false, "cus_1", nil, payment.Charge{ID: "ch_hold", Status: payment.ChargeStatusAuthorized}, nil, http.StatusOK, true, "ch_hold"},
+		{"Existing hold", "1", false, "cus_1", existingHold, payment.Charge{}, nil, http.StatusOK, true, "ch_existing"},
+		{"Hold declined", "1", false, "cus_1", nil, payment.Charge{Status: payment.ChargeStatusFailed, FailureMessage: "insufficient funds"}, nil, http.StatusPaymentRequired, false, ""},
+		{"Provider error", "1", false, "cus_1", nil, payment.Charge{}, errors.New("connection refused"), http.StatusBadGateway, false, ""},
+		{"No stored payment method", "1", false, "", nil, payment.Charge{}, nil, http.StatusOK, false, ""},
+		{"No payment provider", "1", true, "cus_1", nil, payment.Charge{}, nil, http.StatusOK, false, ""},
+		{"Unknown account", "10", false, "cus_1", nil, payment.Charge{}, nil, http.StatusBadRequest, false, ""},
+		{"Invalid account", "a", false, "cus_1", nil, payment.Charge{}, nil, http.StatusBadRequest, false, ""},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockProvider := &paymentMocks.Provider{}
+			mockProvider.On("Authorize", mock.MatchedBy(func(request payment.ChargeRequest) bool {
+				return request.PaymentMethod == "cus_1" && request.AmountMinor == 2000 && request.Currency == "USD"
+			})).Return(currentTest.Charge, currentTest.ChargeError)
+
+			c := Controller{
+				lc:              logger.NewMockClient(),
+				service:         nil,
+				ledgerFileName:  LedgerFileName,
+				storeName:       DefaultStoreName,
+				paymentProvider: mockProvider,
+				holdAmountMinor: 2000,
+			}
+			if currentTest.NoProvider {
+				c.paymentProvider = nil
+			}
+			accountLedgers := getDefaultAccountLedgers()
+			accountLedgers.Data[0].PaymentMethod = currentTest.PaymentMethod
+			accountLedgers.Data[0].Hold = currentTest.ExistingHold
+			data, err := json.Marshal(accountLedgers)
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/"+currentTest.AccountID+"/preauth", nil)
+			req = mux.SetURLVars(req, map[string]string{"accountid": currentTest.AccountID})
+			w := httptest.NewRecorder()
+			c.LedgerPreAuthorize(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExistingHold != nil {
+				mockProvider.AssertNotCalled(t, "Authorize", mock.Anything)
+			}
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var result preAuthorization
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+			assert.Equal(t, currentTest.ExpectedRequired, result.Required)
+
+			accountLedgers, err = c.GetAllLedgers()
+			require.NoError(t, err)
+			if currentTest.ExpectedHoldID == "" {
+				assert.Nil(t, result.Hold)
+				assert.Nil(t, accountLedgers.Data[0].Hold)
+				return
+			}
+			require.NotNil(t, result.Hold)
+			assert.Equal(t, currentTest.ExpectedHoldID, result.Hold.AuthorizationID)
+			require.NotNil(t, accountLedgers.Data[0].Hold)
+			assert.Equal(t, currentTest.ExpectedHoldID, accountLedgers.Data[0].Hold.AuthorizationID)
+			assert.Equal(t, HoldStatusAuthorized, accountLedgers.Data[0].Hold.Status)
+		})
+	}
+}
+
+func TestLedgerAddTransactionTakesHold(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+
+	c := Controller{
+		lc:                logger.NewMockClient(),
+		service:           nil,
+		inventoryEndpoint: inventoryServer.URL,
+		ledgerFileName:    LedgerFileName,
+	}
+	accountLedgers := getDefaultAccountLedgers()
+	accountLedgers.Data[0].Hold = &Hold{AuthorizationID: "ch_hold", AmountMinor: 2000, Currency: "USD", Status: HoldStatusAuthorized}
+	data, err := json.Marshal(accountLedgers)
+	require.NoError(t, err)
+	err = os.WriteFile(c.ledgerFileName, data, 0644)
+	require.NoError(t, err)
+	defer func() {
+		os.Remove(c.ledgerFileName)
+	}()
+
+	body := []byte(`{"accountId":1,"deltaSKUs":[{"sku":"4900002470","delta":-1}]}`)
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	c.LedgerAddTransaction(w, req)
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	accountLedgers, err = c.GetAllLedgers()
+	require.NoError(t, err)
+	account := accountLedgers.Data[0]
+	assert.Nil(t, account.Hold, "the hold should move to the new transaction")
+	require.NotNil(t, account.Ledgers[len(account.Ledgers)-1].Hold)
+	assert.Equal(t, "ch_hold", account.Ledgers[len(account.Ledgers)-1].Hold.AuthorizationID)
+}
+
+func TestLedgerAddTransactionSettlesHoldOnCreate(t *testing.T) {
+	tests := []struct {
+		Name                 string
+		HoldSettlement       string
+		FlagReason           string
+		CaptureStatus        string
+		CaptureError         error
+		ExpectedPaid         bool
+		ExpectedChargeStatus string
+		ExpectedHoldStatus   string
+	}{
+		{"Hold captured", HoldSettlementOnCreate, "", payment.ChargeStatusSucceeded, nil, true, payment.ChargeStatusSucceeded, HoldStatusCaptured},
+		{"Capture declined", HoldSettlementOnCreate, "", payment.ChargeStatusFailed, nil, false, payment.ChargeStatusFailed, HoldStatusAuthorized},
+		{"Provider error", HoldSettlementOnCreate, "", "", errors.New("connection refused"), false, "", HoldStatusAuthorized},
+		{"Settled when paid", HoldSettlementOnPaid, "", "", nil, false, "", HoldStatusAuthorized},
+		{"Flagged for review", HoldSettlementOnCreate, "Low inference confidence", payment.ChargeStatusSucceeded, nil, false, "", HoldStatusAuthorized},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			inventoryServer := newInventoryTestServer(t)
+			mockProvider := &paymentMocks.Provider{}
+			mockProvider.On("Capture", mock.MatchedBy(func(request payment.CaptureRequest) bool {
+				return request.AuthorizationID == "ch_hold" && request.AmountMinor == 199
+			})).Return(payment.Charge{ID: "ch_hold", Status: currentTest.CaptureStatus}, currentTest.CaptureError)
+
+			c := Controller{
+				lc:                logger.NewMockClient(),
+				service:           nil,
+				inventoryEndpoint: inventoryServer.URL,
+				ledgerFileName:    LedgerFileName,
+				paymentProvider:   mockProvider,
+				holdSettlement:    currentTest.HoldSettlement,
+			}
+			accountLedgers := getDefaultAccountLedgers()
+			accountLedgers.Data[0].PaymentMethod = "cus_1"
+			accountLedgers.Data[0].Hold = &Hold{AuthorizationID: "ch_hold", AmountMinor: 2000, Currency: "USD", Status: HoldStatusAuthorized}
+			data, err := json.Marshal(accountLedgers)
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			body, err := json.Marshal(deltaLedger{AccountID: 1, DeltaSKUs: []deltaSKU{{SKU: "4900002470", Delta: -1}}, FlagReason: currentTest.FlagReason})
+			require.NoError(t, err)
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			c.LedgerAddTransaction(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode, "a failed settlement does not fail the transaction")
+
+			accountLedgers, err = c.GetAllLedgers()
+			require.NoError(t, err)
+			newLedger := accountLedgers.Data[0].Ledgers[len(accountLedgers.Data[0].Ledgers)-1]
+			assert.Equal(t, currentTest.ExpectedPaid, newLedger.IsPaid)
+			assert.Equal(t, currentTest.ExpectedPaid, newLedger.PaidAt != 0)
+			assert.Equal(t, currentTest.ExpectedChargeStatus, newLedger.ChargeStatus)
+			require.NotNil(t, newLedger.Hold)
+			assert.Equal(t, currentTest.ExpectedHoldStatus, newLedger.Hold.Status)
+			if currentTest.HoldSettlement != HoldSettlementOnCreate || currentTest.FlagReason != "" {
+				mockProvider.AssertNotCalled(t, "Capture", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestLedgerPreAuthorizeAmount(t *testing.T) {
+	tests := []struct {
+		Name               string
+		Body               string
+		ExpectedStatusCode int
+		ExpectedAmount     int64
+	}{
+		{"Configured amount", "", http.StatusOK, 2000},
+		{"Amount of the kiosk", `{"amount":25}`, http.StatusOK, 2500},
+		{"Negative amount", `{"amount":-1}`, http.StatusBadRequest, 0},
+		{"Malformed body", `{"amount":`, http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			mockProvider := &paymentMocks.Provider{}
+			mockProvider.On("Authorize", mock.Anything).Return(payment.Charge{ID: "ch_hold", Status: payment.ChargeStatusAuthorized}, nil)
+
+			c := Controller{
+				lc:              logger.NewMockClient(),
+				service:         nil,
+				ledgerFileName:  LedgerFileName,
+				storeName:       DefaultStoreName,
+				paymentProvider: mockProvider,
+				holdAmountMinor: 2000,
+			}
+			accountLedgers := getDefaultAccountLedgers()
+			accountLedgers.Data[0].PaymentMethod = "cus_1"
+			data, err := json.Marshal(accountLedgers)
+			require.NoError(t, err)
+			err = os.WriteFile(c.ledgerFileName, data, 0644)
+			require.NoError(t, err)
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("POST", "http://localhost:48093/ledger/1/preauth", bytes.NewBufferString(currentTest.Body))
+			req = mux.SetURLVars(req, map[string]string{"accountid": "1"})
+			w := httptest.NewRecorder()
+			c.LedgerPreAuthorize(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				mockProvider.AssertNotCalled(t, "Authorize", mock.Anything)
+				return
+			}
+			var result preAuthorization
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+			require.NotNil(t, result.Hold)
+			assert.Equal(t, currentTest.ExpectedAmount, result.Hold.AmountMinor)
+			mockProvider.AssertCalled(t, "Authorize", mock.MatchedBy(func(request payment.ChargeRequest) bool {
+				return request.AmountMinor == currentTest.ExpectedAmount
+			}))
+		})
+	}
+}
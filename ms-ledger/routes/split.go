@@ -0,0 +1,157 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+)
+
+const (
+	// SplitRuleEven divides the basket's amounts evenly between the accounts
+	SplitRuleEven = "even"
+	// SplitRuleItemized charges each account for the items assigned to it
+	SplitRuleItemized = "itemized"
+)
+
+// splitTransaction divides a basket transaction between the given accounts
+// using the split rule, returning one transaction per account in the same
+// order as the accounts. Any remainder of an even split is charged to the
+// first accounts, one minor unit each.
+func splitTransaction(basket Ledger, accountIDs []int, rule string, assignments []splitAssignment, currency Currency) ([]Ledger, error) {
+	if len(accountIDs) < 2 {
+		return nil, fmt.Errorf("a split requires at least 2 accounts")
+	}
+	accountIndexes := map[int]int{}
+	for i, accountID := range accountIDs {
+		if _, exists := accountIndexes[accountID]; exists {
+			return nil, fmt.Errorf("account %v is listed more than once", accountID)
+		}
+		accountIndexes[accountID] = i
+	}
+
+	ledgers := make([]Ledger, len(accountIDs))
+	for i := range ledgers {
+		ledgers[i] = basket
+		ledgers[i].TransactionID = basket.TransactionID + int64(i)
+		ledgers[i].SplitID = basket.TransactionID
+		ledgers[i].SplitRule = rule
+	}
+
+	switch rule {
+	case SplitRuleEven:
+		if len(assignments) > 0 {
+			return nil, fmt.Errorf("assignments are only used by the %s split rule", SplitRuleItemized)
+		}
+		count := int64(len(accountIDs))
+		depositsMinor := basket.LineTotalMinor - basket.SubtotalMinor - basket.TaxMinor
+		if rounding := basket.roundingLineItem(); rounding != nil {
+			depositsMinor = depositsMinor - rounding.ItemPriceMinor
+		}
+		for i := range ledgers {
+			// every account sees all of the items, only the amounts are
+			// split, and the items belong to the first account's share
+			ledgers[i].LineItems = append([]LineItem{}, basket.LineItems...)
+			ledgers[i].SubtotalMinor = evenShare(basket.SubtotalMinor, count, int64(i))
+			ledgers[i].TaxMinor = evenShare(basket.TaxMinor, count, int64(i))
+			ledgers[i].LineTotalMinor = ledgers[i].SubtotalMinor + ledgers[i].TaxMinor + evenShare(depositsMinor, count, int64(i))
+			// each share is paid on its own, so each is rounded
+			ledgers[i].applyRounding()
+		}
+
+	case SplitRuleItemized:
+		for i := range ledgers {
+			ledgers[i].LineItems = []LineItem{}
+			ledgers[i].IsFlagged = false
+			ledgers[i].FlagReasons = nil
+			ledgers[i].ReviewStatus = ""
+		}
+		assigned := map[string]int{}
+		for _, assignment := range assignments {
+			i, ok := accountIndexes[assignment.AccountID]
+			if !ok {
+				return nil, fmt.Errorf("account %v is assigned items but is not part of the split", assignment.AccountID)
+			}
+			if assignment.Count <= 0 {
+				return nil, fmt.Errorf("assigned count for SKU %s must be greater than 0", assignment.SKU)
+			}
+			var takenItem *LineItem
+			for j := range basket.LineItems {
+				if basket.LineItems[j].SKU == assignment.SKU && !basket.LineItems[j].Returned {
+					takenItem = &basket.LineItems[j]
+					break
+				}
+			}
+			if takenItem == nil {
+				return nil, fmt.Errorf("SKU %s is assigned but was not taken", assignment.SKU)
+			}
+			assigned[assignment.SKU] += assignment.Count
+			if assigned[assignment.SKU] > takenItem.ItemCount {
+				return nil, fmt.Errorf("more items of SKU %s are assigned than were taken", assignment.SKU)
+			}
+			lineItem := *takenItem
+			lineItem.ItemCount = assignment.Count
+			ledgers[i].LineItems = append(ledgers[i].LineItems, lineItem)
+			if lineItem.Unavailable {
+				ledgers[i].flag(fmt.Sprintf("SKU %s was vended outside of its availability window", lineItem.SKU))
+			}
+		}
+		for _, lineItem := range basket.LineItems {
+			if lineItem.Rounding {
+				// each share is paid on its own, so each is rounded
+				for i := range ledgers {
+					ledgers[i].LineItems = append(ledgers[i].LineItems, lineItem)
+				}
+				continue
+			}
+			if lineItem.Returned {
+				// returned items are only recorded for audit, on the first account
+				ledgers[0].LineItems = append(ledgers[0].LineItems, lineItem)
+				continue
+			}
+			if assigned[lineItem.SKU] < lineItem.ItemCount {
+				return nil, fmt.Errorf("%d item(s) of SKU %s are not assigned to an account", lineItem.ItemCount-assigned[lineItem.SKU], lineItem.SKU)
+			}
+		}
+		for i := range ledgers {
+			ledgers[i].calculateTotals()
+			if ledgers[i].LineTotalMinor < 0 {
+				ledgers[i].LineTotalMinor = 0
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown split rule %q", rule)
+	}
+
+	for i := range ledgers {
+		ledgers[i].setAmounts(currency)
+	}
+	return ledgers, nil
+}
+
+// holdsSplitItems returns whether the items listed on the transaction are
+// its own. Every share of an evenly split basket lists all of its items, but
+// they belong to the first payer's share only, so that they are counted and
+// restocked once.
+func (ledger Ledger) holdsSplitItems() bool {
+	return ledger.SplitRule != SplitRuleEven || ledger.TransactionID == ledger.SplitID
+}
+
+// evenShare returns the index-th of count shares of the amount in minor
+// units, where the first shares absorb the remainder
+func evenShare(amountMinor int64, count int64, index int64) int64 {
+	share := amountMinor / count
+	remainder := amountMinor % count
+	if remainder < 0 {
+		remainder = -remainder
+		if index < remainder {
+			return share - 1
+		}
+		return share
+	}
+	if index < remainder {
+		return share + 1
+	}
+	return share
+}
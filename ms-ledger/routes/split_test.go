@@ -0,0 +1,112 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getSplitBasket() Ledger {
+	basket := Ledger{
+		TransactionID: 1579215712984890248,
+		LineItems: []LineItem{{
+			SKU:            "4900002470",
+			ProductName:    "Sprite (Lemon-Lime) - 16.9 oz",
+			ItemCount:      2,
+			ItemPriceMinor: 199,
+			TaxRate:        0.08,
+		}, {
+			SKU:            "4900002472",
+			ProductName:    "Sprite (Lemon-Lime) - 16.9 oz",
+			ItemCount:      1,
+			ItemPriceMinor: 199,
+			DepositMinor:   25,
+		}, {
+			SKU:            "1200050408",
+			ProductName:    "Mountain Dew - 16.9 oz",
+			ItemCount:      1,
+			ItemPriceMinor: 199,
+			Returned:       true,
+		}},
+	}
+	basket.calculateTotals()
+	return basket
+}
+
+func TestSplitTransactionEven(t *testing.T) {
+	basket := getSplitBasket()
+	require.Equal(t, int64(597), basket.SubtotalMinor)
+	require.Equal(t, int64(32), basket.TaxMinor)
+	require.Equal(t, int64(654), basket.LineTotalMinor)
+
+	ledgers, err := splitTransaction(basket, []int{1, 2}, SplitRuleEven, nil, CurrencyConverter{}.Base())
+	require.NoError(t, err)
+	require.Len(t, ledgers, 2)
+
+	assert.Equal(t, int64(299), ledgers[0].SubtotalMinor, "the first account absorbs the remainder")
+	assert.Equal(t, int64(298), ledgers[1].SubtotalMinor)
+	assert.Equal(t, basket.LineTotalMinor, ledgers[0].LineTotalMinor+ledgers[1].LineTotalMinor, "the split totals should add up to the basket")
+	for i, ledger := range ledgers {
+		assert.Equal(t, basket.TransactionID, ledger.SplitID)
+		assert.Equal(t, basket.TransactionID+int64(i), ledger.TransactionID)
+		assert.Equal(t, SplitRuleEven, ledger.SplitRule)
+		assert.Len(t, ledger.LineItems, len(basket.LineItems))
+		assert.Equal(t, i == 0, ledger.holdsSplitItems(), "the items belong to the first account's share")
+	}
+}
+
+func TestSplitTransactionItemized(t *testing.T) {
+	tests := []struct {
+		Name                string
+		AccountIDs          []int
+		Assignments         []splitAssignment
+		ExpectedTotalsMinor []int64
+		ExpectedError       bool
+	}{
+		{"each account takes a Sprite", []int{1, 2}, []splitAssignment{{1, "4900002470", 1}, {2, "4900002470", 1}, {2, "4900002472", 1}}, []int64{215, 439}, false},
+		{"one account takes everything", []int{1, 2}, []splitAssignment{{2, "4900002470", 2}, {2, "4900002472", 1}}, []int64{0, 654}, false},
+		{"items not assigned", []int{1, 2}, []splitAssignment{{1, "4900002470", 1}, {2, "4900002472", 1}}, nil, true},
+		{"too many items assigned", []int{1, 2}, []splitAssignment{{1, "4900002470", 2}, {2, "4900002470", 1}, {2, "4900002472", 1}}, nil, true},
+		{"returned item assigned", []int{1, 2}, []splitAssignment{{1, "4900002470", 2}, {2, "4900002472", 1}, {2, "1200050408", 1}}, nil, true},
+		{"account not in split", []int{1, 2}, []splitAssignment{{3, "4900002470", 2}, {2, "4900002472", 1}}, nil, true},
+		{"non-positive count", []int{1, 2}, []splitAssignment{{1, "4900002470", 0}}, nil, true},
+		{"single account", []int{1}, []splitAssignment{{1, "4900002470", 2}, {1, "4900002472", 1}}, nil, true},
+		{"duplicate account", []int{1, 1}, []splitAssignment{{1, "4900002470", 2}, {1, "4900002472", 1}}, nil, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			ledgers, err := splitTransaction(getSplitBasket(), currentTest.AccountIDs, SplitRuleItemized, currentTest.Assignments, CurrencyConverter{}.Base())
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, ledgers, len(currentTest.ExpectedTotalsMinor))
+			for i, ledger := range ledgers {
+				assert.Equal(t, currentTest.ExpectedTotalsMinor[i], ledger.LineTotalMinor)
+			}
+			// the returned item is recorded on the first account only
+			assert.True(t, ledgers[0].LineItems[len(ledgers[0].LineItems)-1].Returned)
+		})
+	}
+}
+
+func TestSplitTransactionUnknownRule(t *testing.T) {
+	_, err := splitTransaction(getSplitBasket(), []int{1, 2}, "random", nil, CurrencyConverter{}.Base())
+	require.Error(t, err)
+
+	_, err = splitTransaction(getSplitBasket(), []int{1, 2}, SplitRuleEven, []splitAssignment{{1, "4900002470", 2}}, CurrencyConverter{}.Base())
+	require.Error(t, err, "assignments should be rejected for an even split")
+}
+
+func TestEvenShare(t *testing.T) {
+	assert.Equal(t, []int64{4, 3, 3}, []int64{evenShare(10, 3, 0), evenShare(10, 3, 1), evenShare(10, 3, 2)})
+	assert.Equal(t, []int64{-4, -3, -3}, []int64{evenShare(-10, 3, 0), evenShare(-10, 3, 1), evenShare(-10, 3, 2)})
+	assert.Equal(t, []int64{0, 0}, []int64{evenShare(0, 2, 0), evenShare(0, 2, 1)})
+}
@@ -0,0 +1,138 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// statementMonthLayout is the layout of the month query parameter
+	statementMonthLayout = "2006-01"
+	// statementDateLayout shows the day of a transaction on a statement
+	statementDateLayout = "2006-01-02"
+)
+
+// Statement is the presentation model of an account's transactions in one
+// calendar month, with the account's unpaid balance when it is sent
+type Statement struct {
+	StoreName string
+	AccountID int
+	// Month is the first instant of the month, in the kiosk's time zone
+	Month    time.Time
+	Lines    []StatementLine
+	Currency Currency
+	// ChargedMinor is the total of the month's transactions in the ledger's
+	// currency, voided transactions and other currencies excluded
+	ChargedMinor int64
+	Balance      AccountBalance
+}
+
+// StatementLine is one transaction on a statement
+type StatementLine struct {
+	Date          time.Time
+	TransactionID string
+	Description   string
+	Amount        string
+	Status        string
+}
+
+// ParseStatementMonth parses a month in the form 2023-10, in the time zone
+// of the location. An empty month is the month before now.
+func ParseStatementMonth(value string, location *time.Location, now time.Time) (time.Time, error) {
+	if value == "" {
+		now = now.In(location)
+		return time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, location), nil
+	}
+	month, err := time.ParseInLocation(statementMonthLayout, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("month %q must be in the form YYYY-MM", value)
+	}
+	return month, nil
+}
+
+// NewStatement builds the statement of the account's transactions in the
+// month, and returns false when the account had none. Test vends are not
+// account activity.
+func NewStatement(storeName string, account Account, month time.Time, currency CurrencyConverter) (Statement, bool) {
+	base := currency.Base()
+	statement := Statement{
+		StoreName: storeName,
+		AccountID: account.AccountID,
+		Month:     month,
+		Currency:  base,
+		Balance:   accountBalance(account, currency),
+	}
+	monthRange := ExportRange{From: month, To: month.AddDate(0, 1, 0)}
+	for _, ledger := range account.Ledgers {
+		if ledger.IsTest || !monthRange.Contains(ledger.TxTimeStamp) {
+			continue
+		}
+		ledgerCurrency := base
+		if ledger.Currency != "" {
+			if known, ok := currency.Lookup(ledger.Currency); ok {
+				ledgerCurrency = known
+			}
+		}
+		totalMinor := ledger.LineTotalMinor
+		if ledger.Currency == "" {
+			totalMinor = ledgerCurrency.ToMinor(ledger.LineTotal)
+		}
+
+		line := StatementLine{
+			Date:          time.Unix(0, ledger.TxTimeStamp).In(month.Location()),
+			TransactionID: strconv.FormatInt(ledger.TransactionID, 10),
+			Description:   "Purchase",
+			Amount:        ledgerCurrency.Format(totalMinor),
+			Status:        "UNPAID",
+		}
+		if ledger.RefundOf != 0 {
+			line.Description = "Refund of " + strconv.FormatInt(ledger.RefundOf, 10)
+		}
+		switch {
+		case ledger.IsVoided:
+			line.Status = "VOIDED"
+		case ledger.IsPaid:
+			line.Status = "PAID"
+		}
+		statement.Lines = append(statement.Lines, line)
+		if !ledger.IsVoided && (ledger.Currency == "" || strings.EqualFold(ledger.Currency, base.Code)) {
+			statement.ChargedMinor = statement.ChargedMinor + totalMinor
+		}
+	}
+	return statement, len(statement.Lines) > 0
+}
+
+// Subject is the subject of the statement's email
+func (statement Statement) Subject() string {
+	return fmt.Sprintf("%s statement for %s", statement.StoreName, statement.Month.Format("January 2006"))
+}
+
+// Text renders the statement as fixed width plain text, as wide as a
+// receipt
+func (statement Statement) Text() string {
+	var sb strings.Builder
+	separator := strings.Repeat("-", receiptWidth) + "\n"
+
+	padding := (receiptWidth - len(statement.StoreName)) / 2
+	if padding < 0 {
+		padding = 0
+	}
+	sb.WriteString(strings.Repeat(" ", padding) + statement.StoreName + "\n")
+	sb.WriteString(separator)
+	sb.WriteString(fmt.Sprintf("Statement: %s\n", statement.Month.Format("January 2006")))
+	sb.WriteString(fmt.Sprintf("Account: %d\n", statement.AccountID))
+	sb.WriteString(separator)
+	for _, line := range statement.Lines {
+		sb.WriteString(fmt.Sprintf("%s %s %s\n", line.Date.Format(statementDateLayout), line.TransactionID, line.Status))
+		sb.WriteString(receiptRow("  "+line.Description, line.Amount))
+	}
+	sb.WriteString(separator)
+	sb.WriteString(receiptRow("Charged this month", statement.Currency.Format(statement.ChargedMinor)))
+	sb.WriteString(receiptRow("Unpaid balance", statement.Currency.Format(statement.Balance.UnpaidBalanceMinor)))
+	return sb.String()
+}
@@ -0,0 +1,292 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	StatementJobRunning   = "running"
+	StatementJobCompleted = "completed"
+
+	StatementSent   = "sent"
+	StatementFailed = "failed"
+
+	// DefaultStatementSendInterval is how long the job waits between two
+	// statements
+	DefaultStatementSendInterval = time.Second
+
+	// maxStatementJobs is how many of the latest jobs are kept to be
+	// looked up
+	maxStatementJobs = 10
+)
+
+// StatementJob is a run of sending the statements of a month, with the
+// result for each account that had activity in the month
+type StatementJob struct {
+	ID          int               `json:"id"`
+	Month       string            `json:"month"`
+	Status      string            `json:"status"`
+	StartedAt   int64             `json:"startedAt,string"`
+	CompletedAt int64             `json:"completedAt,string,omitempty"`
+	Accounts    int               `json:"accounts"`
+	Sent        int               `json:"sent"`
+	Failed      int               `json:"failed"`
+	Results     []StatementResult `json:"results"`
+}
+
+// StatementResult is whether the statement of an account was sent, and
+// why not when it failed
+type StatementResult struct {
+	AccountID    int    `json:"accountID"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+}
+
+// accountContact is the part of an ms-authentication account that a
+// statement is sent to
+type accountContact struct {
+	AccountID    int    `json:"accountID"`
+	EmailAddress string `json:"emailAddress"`
+}
+
+// StatementMailer emails the statements of the accounts, one at a time
+// with the interval in between so that the SMTP server does not throttle
+// or reject them. One job runs at a time.
+type StatementMailer struct {
+	mailer Mailer
+	// accountsEndpoint is the ms-authentication accounts route, which the
+	// email address of an account is looked up at
+	accountsEndpoint string
+	interval         time.Duration
+	mutex            sync.Mutex
+	jobs             []*StatementJob
+	nextID           int
+	running          sync.WaitGroup
+}
+
+// NewStatementMailer creates a StatementMailer that sends the statements
+// with the mailer
+func NewStatementMailer(mailer Mailer, accountsEndpoint string, interval time.Duration) *StatementMailer {
+	return &StatementMailer{
+		mailer:           mailer,
+		accountsEndpoint: accountsEndpoint,
+		interval:         interval,
+		nextID:           1,
+	}
+}
+
+// start starts a job sending the statements, unless a job is running
+func (statementMailer *StatementMailer) start(month string, statements []Statement) (StatementJob, bool) {
+	statementMailer.mutex.Lock()
+	defer statementMailer.mutex.Unlock()
+	for _, job := range statementMailer.jobs {
+		if job.Status == StatementJobRunning {
+			return job.copy(), false
+		}
+	}
+	job := &StatementJob{
+		ID:        statementMailer.nextID,
+		Month:     month,
+		Status:    StatementJobRunning,
+		StartedAt: time.Now().UnixNano(),
+		Accounts:  len(statements),
+		Results:   []StatementResult{},
+	}
+	statementMailer.nextID++
+	statementMailer.jobs = append(statementMailer.jobs, job)
+	if len(statementMailer.jobs) > maxStatementJobs {
+		statementMailer.jobs = statementMailer.jobs[1:]
+	}
+
+	statementMailer.running.Add(1)
+	go func() {
+		defer statementMailer.running.Done()
+		statementMailer.run(job, statements)
+	}()
+	return job.copy(), true
+}
+
+// run sends the statements of the job, recording the result of each
+func (statementMailer *StatementMailer) run(job *StatementJob, statements []Statement) {
+	for index, statement := range statements {
+		if index > 0 {
+			time.Sleep(statementMailer.interval)
+		}
+		result := StatementResult{AccountID: statement.AccountID, Status: StatementSent}
+		contact, err := statementMailer.lookupContact(statement.AccountID)
+		if err == nil {
+			result.EmailAddress = contact.EmailAddress
+			err = statementMailer.mailer.Send(contact.EmailAddress, statement.Subject(), statement.Text())
+		}
+		if err != nil {
+			result.Status = StatementFailed
+			result.Error = err.Error()
+		}
+
+		statementMailer.mutex.Lock()
+		job.Results = append(job.Results, result)
+		if result.Status == StatementSent {
+			job.Sent++
+		} else {
+			job.Failed++
+		}
+		statementMailer.mutex.Unlock()
+	}
+
+	statementMailer.mutex.Lock()
+	job.Status = StatementJobCompleted
+	job.CompletedAt = time.Now().UnixNano()
+	statementMailer.mutex.Unlock()
+}
+
+// lookupContact looks the email address of the account up in
+// ms-authentication
+func (statementMailer *StatementMailer) lookupContact(accountID int) (accountContact, error) {
+	client := &http.Client{Timeout: time.Duration(connectionTimeout) * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(statementMailer.accountsEndpoint, "/") + "/" + strconv.Itoa(accountID))
+	if err != nil {
+		return accountContact{}, fmt.Errorf("failed to look up the account: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return accountContact{}, fmt.Errorf("failed to look up the account: received status code %v", resp.Status)
+	}
+	var contact accountContact
+	if err := json.NewDecoder(resp.Body).Decode(&contact); err != nil {
+		return accountContact{}, fmt.Errorf("failed to parse the account: %s", err.Error())
+	}
+	if contact.EmailAddress == "" {
+		return accountContact{}, fmt.Errorf("account %d has no email address", accountID)
+	}
+	return contact, nil
+}
+
+// job returns a copy of the job with the ID
+func (statementMailer *StatementMailer) job(id int) (StatementJob, bool) {
+	statementMailer.mutex.Lock()
+	defer statementMailer.mutex.Unlock()
+	for _, job := range statementMailer.jobs {
+		if job.ID == id {
+			return job.copy(), true
+		}
+	}
+	return StatementJob{}, false
+}
+
+// wait waits for the running job to complete
+func (statementMailer *StatementMailer) wait() {
+	statementMailer.running.Wait()
+}
+
+// copy copies the job, so that it is not changed while it is marshaled
+func (job *StatementJob) copy() StatementJob {
+	copied := *job
+	copied.Results = append([]StatementResult{}, job.Results...)
+	return copied
+}
+
+// StatementsSendPost starts a job that emails the statements of the
+// accounts that had transactions in the "month" query parameter, in the
+// form 2023-10, which defaults to the month before. It responds with the
+// job at once, and the results of the accounts are looked up with
+// StatementJobGet while it runs. Only one job runs at a time.
+func (c *Controller) StatementsSendPost(writer http.ResponseWriter, req *http.Request) {
+	if c.statementMailer == nil {
+		errMsg := "Statements are not configured, set StatementSMTPServer"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	month, err := ParseStatementMonth(req.URL.Query().Get("month"), c.location(), time.Now())
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid statement month: %s", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountLedgers, err := c.GetAllLedgers()
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	statements := []Statement{}
+	for _, account := range accountLedgers.Data {
+		if statement, ok := NewStatement(c.storeName, account, month, c.currency); ok {
+			statements = append(statements, statement)
+		}
+	}
+
+	job, started := c.statementMailer.start(month.Format(statementMonthLayout), statements)
+	if !started {
+		errMsg := fmt.Sprintf("Statement job %d is still running", job.ID)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusConflict)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		errMsg := "Failed to marshal statement job"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("Started statement job %d for %s, sending %d statements", job.ID, job.Month, job.Accounts)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusAccepted)
+	writer.Write(jobJSON)
+}
+
+// StatementJobGet returns the statement job of the job ID in the URL, with
+// the results of the accounts so far
+func (c *Controller) StatementJobGet(writer http.ResponseWriter, req *http.Request) {
+	jobID, err := strconv.Atoi(mux.Vars(req)["jobid"])
+	if err != nil {
+		errMsg := "jobID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	var job StatementJob
+	found := false
+	if c.statementMailer != nil {
+		job, found = c.statementMailer.job(jobID)
+	}
+	if !found {
+		errMsg := fmt.Sprintf("Statement job %d not found", jobID)
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		errMsg := "Failed to marshal statement job"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(jobJSON)
+}
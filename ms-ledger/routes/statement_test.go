@@ -0,0 +1,213 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMailer records the emails it sends, and fails those to the address
+// in failTo
+type fakeMailer struct {
+	mutex  sync.Mutex
+	failTo string
+	sent   map[string]string
+}
+
+func (mailer *fakeMailer) Send(to string, subject string, body string) error {
+	mailer.mutex.Lock()
+	defer mailer.mutex.Unlock()
+	if to == mailer.failTo {
+		return errors.New("mailbox unavailable")
+	}
+	mailer.sent[to] = subject + "\n" + body
+	return nil
+}
+
+func TestParseStatementMonth(t *testing.T) {
+	location, err := time.LoadLocation("America/Chicago")
+	require.NoError(t, err)
+	now := time.Date(2023, 1, 15, 12, 0, 0, 0, location)
+
+	month, err := ParseStatementMonth("2023-10", location, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 10, 1, 0, 0, 0, 0, location), month)
+
+	month, err = ParseStatementMonth("", location, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 12, 1, 0, 0, 0, 0, location), month, "the month before")
+
+	_, err = ParseStatementMonth("October", location, now)
+	assert.Error(t, err)
+	_, err = ParseStatementMonth("2023-13", location, now)
+	assert.Error(t, err)
+}
+
+func TestNewStatement(t *testing.T) {
+	month := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	day := func(day int) int64 {
+		return time.Date(2023, 10, day, 12, 0, 0, 0, time.UTC).UnixNano()
+	}
+	account := Account{AccountID: 7, Ledgers: []Ledger{
+		{TransactionID: 1, TxTimeStamp: time.Date(2023, 9, 30, 23, 0, 0, 0, time.UTC).UnixNano(), Currency: "USD", LineTotalMinor: 1000},
+		{TransactionID: 2, TxTimeStamp: day(2), Currency: "USD", LineTotalMinor: 399, IsPaid: true},
+		{TransactionID: 3, TxTimeStamp: day(5), Currency: "USD", LineTotalMinor: 250},
+		{TransactionID: 4, TxTimeStamp: day(6), Currency: "USD", LineTotalMinor: -399, RefundOf: 2, IsPaid: true},
+		{TransactionID: 5, TxTimeStamp: day(7), Currency: "USD", IsVoided: true},
+		{TransactionID: 6, TxTimeStamp: day(8), Currency: "USD", IsTest: true},
+		{TransactionID: 7, TxTimeStamp: time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC).UnixNano(), Currency: "USD", LineTotalMinor: 100},
+	}}
+
+	statement, ok := NewStatement("Automated Checkout", account, month, CurrencyConverter{})
+	require.True(t, ok)
+	require.Len(t, statement.Lines, 4)
+	assert.Equal(t, []string{"2", "3", "4", "5"}, []string{statement.Lines[0].TransactionID, statement.Lines[1].TransactionID, statement.Lines[2].TransactionID, statement.Lines[3].TransactionID})
+	assert.Equal(t, "Refund of 2", statement.Lines[2].Description)
+	assert.Equal(t, "VOIDED", statement.Lines[3].Status)
+	assert.Equal(t, int64(250), statement.ChargedMinor)
+	assert.Equal(t, int64(1350), statement.Balance.UnpaidBalanceMinor)
+	assert.Equal(t, "Automated Checkout statement for October 2023", statement.Subject())
+
+	text := statement.Text()
+	assert.Contains(t, text, "Statement: October 2023")
+	assert.Contains(t, text, "2023-10-05 3 UNPAID")
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		assert.LessOrEqual(t, len(line), receiptWidth, line)
+	}
+
+	_, ok = NewStatement("Automated Checkout", account, time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC), CurrencyConverter{})
+	assert.False(t, ok, "no activity in the month")
+}
+
+func TestMailMessage(t *testing.T) {
+	now := time.Date(2023, 11, 1, 9, 0, 0, 0, time.UTC)
+	message, err := mailMessage("statements@example.com", "someone@example.com", "Statement", "line 1\nline 2\n", now)
+	require.NoError(t, err)
+	assert.Equal(t, "From: statements@example.com\r\nTo: someone@example.com\r\nSubject: Statement\r\nDate: Wed, 01 Nov 2023 09:00:00 +0000\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nline 1\r\nline 2\r\n", string(message))
+
+	_, err = mailMessage("statements@example.com", "someone@example.com\r\nBcc: other@example.com", "Statement", "", now)
+	assert.Error(t, err)
+	_, err = mailMessage("statements@example.com", "someone@example.com", "Statement\r\nBcc: other@example.com", "", now)
+	assert.Error(t, err)
+}
+
+func TestNewSMTPMailer(t *testing.T) {
+	mailer, err := NewSMTPMailer("", "", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, mailer)
+
+	mailer, err = NewSMTPMailer("smtp.example.com:587", "user", "secret", "statements@example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, mailer)
+
+	_, err = NewSMTPMailer("smtp.example.com", "", "", "statements@example.com")
+	assert.Error(t, err, "no port")
+	_, err = NewSMTPMailer("smtp.example.com:25", "", "", "statements")
+	assert.Error(t, err, "not an email address")
+}
+
+// TestStatementsSendPost tests that a statement is sent to every account
+// with activity in the month, with the result of each
+func TestStatementsSendPost(t *testing.T) {
+	accounts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/accounts/1":
+			_, _ = w.Write([]byte(`{"accountID":1,"emailAddress":"one@example.com"}`))
+		case "/accounts/2":
+			_, _ = w.Write([]byte(`{"accountID":2,"emailAddress":"two@example.com"}`))
+		case "/accounts/3":
+			_, _ = w.Write([]byte(`{"accountID":3,"emailAddress":""}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer accounts.Close()
+
+	ledgers := getDefaultAccountLedgers()
+	january := time.Date(2020, 1, 20, 0, 0, 0, 0, time.UTC).UnixNano()
+	ledgers.Data[1].Ledgers = append(ledgers.Data[1].Ledgers, Ledger{TransactionID: 3, TxTimeStamp: january, LineTotal: 1})
+	for _, accountID := range []int{3, 4} {
+		ledgers.Data = append(ledgers.Data, Account{AccountID: accountID, Ledgers: []Ledger{{TransactionID: int64(accountID), TxTimeStamp: january, LineTotal: 1}}})
+	}
+	// no activity in January
+	ledgers.Data = append(ledgers.Data, Account{AccountID: 5, Ledgers: []Ledger{{TransactionID: 5, TxTimeStamp: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC).UnixNano(), LineTotal: 1}}})
+	data, err := json.Marshal(ledgers)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(LedgerFileName, data, 0644))
+	defer os.Remove(LedgerFileName)
+
+	mailer := &fakeMailer{failTo: "two@example.com", sent: map[string]string{}}
+	c := Controller{
+		lc:              logger.NewMockClient(),
+		ledgerFileName:  LedgerFileName,
+		storeName:       DefaultStoreName,
+		statementMailer: NewStatementMailer(mailer, accounts.URL+"/accounts", 0),
+	}
+
+	w := httptest.NewRecorder()
+	c.StatementsSendPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/admin/statements/send?month=2020-01", nil))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var job StatementJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "2020-01", job.Month)
+	assert.Equal(t, 4, job.Accounts)
+	c.statementMailer.wait()
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:48093/admin/statements/jobs/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"jobid": "1"})
+	w = httptest.NewRecorder()
+	c.StatementJobGet(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, StatementJobCompleted, job.Status)
+	assert.NotZero(t, job.CompletedAt)
+	assert.Equal(t, 1, job.Sent)
+	assert.Equal(t, 3, job.Failed)
+	require.Len(t, job.Results, 4)
+	assert.Equal(t, StatementResult{AccountID: 1, EmailAddress: "one@example.com", Status: StatementSent}, job.Results[0])
+	assert.Equal(t, "mailbox unavailable", job.Results[1].Error)
+	assert.Contains(t, job.Results[2].Error, "has no email address")
+	assert.Contains(t, job.Results[3].Error, "404")
+	assert.Contains(t, mailer.sent["one@example.com"], "Statement: January 2020")
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://localhost:48093/admin/statements/jobs/9", nil), map[string]string{"jobid": "9"})
+	w = httptest.NewRecorder()
+	c.StatementJobGet(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	c.StatementsSendPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/admin/statements/send?month=January", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStatementsSendPostNotConfigured(t *testing.T) {
+	c := Controller{lc: logger.NewMockClient()}
+	w := httptest.NewRecorder()
+	c.StatementsSendPost(w, httptest.NewRequest(http.MethodPost, "http://localhost:48093/admin/statements/send", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+// TestStatementMailerOneJob tests that a job is not started while another
+// one is running
+func TestStatementMailerOneJob(t *testing.T) {
+	statementMailer := NewStatementMailer(&fakeMailer{sent: map[string]string{}}, "http://localhost:0/accounts", time.Hour)
+	statementMailer.jobs = append(statementMailer.jobs, &StatementJob{ID: 1, Status: StatementJobRunning})
+
+	job, started := statementMailer.start("2023-10", nil)
+	assert.False(t, started)
+	assert.Equal(t, 1, job.ID)
+}
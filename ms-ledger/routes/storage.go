@@ -0,0 +1,248 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// LedgerStorageSingleFile keeps the ledgers of every account in the
+	// ledger JSON file, so every write replaces the data of every account
+	LedgerStorageSingleFile = "single-file"
+	// LedgerStoragePerAccount keeps the ledgers of each account in its own
+	// file, so a write only replaces the files of the accounts it changes
+	LedgerStoragePerAccount = "per-account"
+
+	accountFilePrefix = "account-"
+	accountFileSuffix = ".json"
+)
+
+// ledgerMutex serializes every read-modify-write of the ledgers, by the
+// route handlers, the archiver and the migration, so that none of them
+// writes back ledgers that another changed in the meantime. It is taken
+// after basketIntentMutex when both are held.
+var ledgerMutex sync.Mutex
+
+// ValidateLedgerStorage checks that the ledger storage is one of
+// single-file or per-account
+func ValidateLedgerStorage(ledgerStorage string) error {
+	switch ledgerStorage {
+	case LedgerStorageSingleFile, LedgerStoragePerAccount:
+		return nil
+	default:
+		return fmt.Errorf("unknown ledger storage %q, expected %s or %s", ledgerStorage, LedgerStorageSingleFile, LedgerStoragePerAccount)
+	}
+}
+
+// AccountsDirectory is the directory of the account files in the
+// per-account storage, which is kept next to the ledger file
+func AccountsDirectory(ledgerFileName string) string {
+	return strings.TrimSuffix(ledgerFileName, filepath.Ext(ledgerFileName)) + "-accounts"
+}
+
+// perAccount checks whether each account is stored in its own file. The
+// ledger file is used when no storage is set.
+func (c *Controller) perAccount() bool {
+	return c.ledgerStorage == LedgerStoragePerAccount
+}
+
+// accountFileName is the file of the account in the per-account storage
+func (c *Controller) accountFileName(accountID int) string {
+	return filepath.Join(AccountsDirectory(c.ledgerFileName), accountFilePrefix+strconv.Itoa(accountID)+accountFileSuffix)
+}
+
+// accountFileNames returns the account files in the per-account storage,
+// ordered by account ID
+func (c *Controller) accountFileNames() ([]string, error) {
+	entries, err := os.ReadDir(AccountsDirectory(c.ledgerFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to list ledger account files: " + err.Error())
+	}
+
+	accountIDs := []int{}
+	for _, entry := range entries {
+		accountID, ok := parseAccountFileName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Ints(accountIDs)
+
+	fileNames := make([]string, 0, len(accountIDs))
+	for _, accountID := range accountIDs {
+		fileNames = append(fileNames, c.accountFileName(accountID))
+	}
+	return fileNames, nil
+}
+
+// parseAccountFileName returns the account ID of an account file name.
+// Temporary files of interrupted writes are not account files.
+func parseAccountFileName(name string) (int, bool) {
+	if !strings.HasPrefix(name, accountFilePrefix) || !strings.HasSuffix(name, accountFileSuffix) {
+		return 0, false
+	}
+	accountID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, accountFilePrefix), accountFileSuffix))
+	if err != nil {
+		return 0, false
+	}
+	return accountID, true
+}
+
+// readAccountFile loads an account file of the per-account storage
+func readAccountFile(fileName string) (Account, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return Account{}, err
+	}
+	var account Account
+	if err = json.Unmarshal(data, &account); err != nil {
+		return Account{}, errors.New("Failed to unmarshal ledger account file: " + err.Error())
+	}
+	return account, nil
+}
+
+// getLedgers loads the ledgers of the accounts. In the per-account storage
+// only the files of the accounts are read, and accounts without a file are
+// left out, otherwise the ledgers of every account are loaded.
+func (c *Controller) getLedgers(accountIDs ...int) (Accounts, error) {
+	if !c.perAccount() {
+		return c.GetAllLedgers()
+	}
+
+	accountLedgers := Accounts{Data: []Account{}}
+	loaded := map[int]bool{}
+	for _, accountID := range accountIDs {
+		if loaded[accountID] {
+			continue
+		}
+		loaded[accountID] = true
+		account, err := readAccountFile(c.accountFileName(accountID))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Accounts{}, errors.New("failed to load ledger account file: " + err.Error())
+		}
+		accountLedgers.Data = append(accountLedgers.Data, account)
+	}
+	return accountLedgers, nil
+}
+
+// getAllAccountLedgers loads every account file of the per-account storage
+func (c *Controller) getAllAccountLedgers() (Accounts, error) {
+	fileNames, err := c.accountFileNames()
+	if err != nil {
+		return Accounts{}, err
+	}
+	accountLedgers := Accounts{Data: []Account{}}
+	for _, fileName := range fileNames {
+		account, err := readAccountFile(fileName)
+		if err != nil {
+			return Accounts{}, errors.New("failed to load ledger account file: " + err.Error())
+		}
+		accountLedgers.Data = append(accountLedgers.Data, account)
+	}
+	return accountLedgers, nil
+}
+
+// saveLedgers writes the ledgers of the accounts. In the per-account storage
+// only the files of the accounts are replaced, and the file of an account
+// that is no longer in the ledgers is removed. Without accounts, every
+// account is written and the files of any other account are removed. The
+// ledger file storage always writes every account.
+func (c *Controller) saveLedgers(accountLedgers Accounts, accountIDs ...int) error {
+	if !c.perAccount() {
+		data, err := json.Marshal(accountLedgers)
+		if err != nil {
+			return errors.New("failed to marshal ledger JSON file: " + err.Error())
+		}
+		return c.fileWriter.WriteFile(c.ledgerFileName, data, 0644)
+	}
+
+	if err := os.MkdirAll(AccountsDirectory(c.ledgerFileName), 0755); err != nil {
+		return errors.New("failed to create ledger accounts directory: " + err.Error())
+	}
+
+	accounts := map[int]Account{}
+	for _, account := range accountLedgers.Data {
+		accounts[account.AccountID] = account
+	}
+	if len(accountIDs) == 0 {
+		fileNames, err := c.accountFileNames()
+		if err != nil {
+			return err
+		}
+		for _, fileName := range fileNames {
+			accountID, _ := parseAccountFileName(filepath.Base(fileName))
+			accountIDs = append(accountIDs, accountID)
+		}
+		for accountID := range accounts {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+
+	for _, accountID := range accountIDs {
+		account, ok := accounts[accountID]
+		if !ok {
+			if err := os.Remove(c.accountFileName(accountID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return errors.New("failed to remove ledger account file: " + err.Error())
+			}
+			continue
+		}
+		data, err := json.Marshal(account)
+		if err != nil {
+			return errors.New("failed to marshal ledger account file: " + err.Error())
+		}
+		if err = c.fileWriter.WriteFile(c.accountFileName(accountID), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateLedgers moves the accounts of the ledger file into their own files
+// when the per-account storage is used, and returns how many were moved.
+// The ledger file is renamed once every account file is written, so an
+// interrupted migration is completed on the next start.
+func (c *Controller) MigrateLedgers() (int, error) {
+	if !c.perAccount() {
+		return 0, nil
+	}
+	if _, err := os.Stat(c.ledgerFileName); errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	ledgerMutex.Lock()
+	defer ledgerMutex.Unlock()
+
+	// the ledger file is read as the single file storage would
+	singleFile := *c
+	singleFile.ledgerStorage = LedgerStorageSingleFile
+	accountLedgers, err := singleFile.GetAllLedgers()
+	if err != nil {
+		return 0, err
+	}
+	for _, account := range accountLedgers.Data {
+		if err := c.saveLedgers(accountLedgers, account.AccountID); err != nil {
+			return 0, fmt.Errorf("failed to migrate account %d: %s", account.AccountID, err.Error())
+		}
+	}
+	if err := os.Rename(c.ledgerFileName, c.ledgerFileName+".migrated"); err != nil {
+		return 0, errors.New("failed to rename migrated ledger JSON file: " + err.Error())
+	}
+	return len(accountLedgers.Data), nil
+}
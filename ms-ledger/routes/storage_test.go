@@ -0,0 +1,194 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPerAccountController(t *testing.T) Controller {
+	return Controller{
+		lc:             logger.NewMockClient(),
+		ledgerFileName: filepath.Join(t.TempDir(), LedgerFileName),
+		ledgerStorage:  LedgerStoragePerAccount,
+	}
+}
+
+func TestValidateLedgerStorage(t *testing.T) {
+	assert.NoError(t, ValidateLedgerStorage(LedgerStorageSingleFile))
+	assert.NoError(t, ValidateLedgerStorage(LedgerStoragePerAccount))
+	assert.Error(t, ValidateLedgerStorage("database"))
+}
+
+func TestAccountsDirectory(t *testing.T) {
+	assert.Equal(t, "/tmp/ledger-accounts", AccountsDirectory("/tmp/ledger.json"))
+	assert.Equal(t, "/tmp/ledger-accounts", AccountsDirectory("/tmp/ledger"))
+}
+
+func TestPerAccountStorage(t *testing.T) {
+	c := newPerAccountController(t)
+
+	// without any account files there are no accounts
+	accountLedgers, err := c.GetAllLedgers()
+	require.NoError(t, err)
+	assert.Empty(t, accountLedgers.Data)
+
+	require.NoError(t, c.saveLedgers(getDefaultAccountLedgers()))
+	assert.FileExists(t, c.accountFileName(1))
+	assert.FileExists(t, c.accountFileName(2))
+	assert.NoFileExists(t, c.ledgerFileName, "the ledger file is not used")
+
+	accountLedgers, err = c.GetAllLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultAccountLedgers(), accountLedgers)
+
+	accountLedgers, err = c.getLedgers(2, 3, 2)
+	require.NoError(t, err)
+	require.Len(t, accountLedgers.Data, 1, "accounts without a file are left out")
+	assert.Equal(t, getDefaultAccountLedgers().Data[1], accountLedgers.Data[0])
+
+	// writing an account leaves the files of other accounts alone
+	otherAccount, err := os.ReadFile(c.accountFileName(1))
+	require.NoError(t, err)
+	accountLedgers.Data[0].Ledgers = []Ledger{}
+	require.NoError(t, c.saveLedgers(Accounts{Data: []Account{getDefaultAccountLedgers().Data[0], accountLedgers.Data[0]}}, 2))
+	written, err := os.ReadFile(c.accountFileName(1))
+	require.NoError(t, err)
+	assert.Equal(t, otherAccount, written)
+	account, err := readAccountFile(c.accountFileName(2))
+	require.NoError(t, err)
+	assert.Empty(t, account.Ledgers)
+
+	require.NoError(t, c.DeleteAllLedgers())
+	assert.NoFileExists(t, c.accountFileName(1))
+	assert.NoFileExists(t, c.accountFileName(2))
+	accountLedgers, err = c.GetAllLedgers()
+	require.NoError(t, err)
+	assert.Empty(t, accountLedgers.Data)
+}
+
+func TestPerAccountStorageInvalidFile(t *testing.T) {
+	c := newPerAccountController(t)
+	require.NoError(t, os.MkdirAll(AccountsDirectory(c.ledgerFileName), 0755))
+	require.NoError(t, os.WriteFile(c.accountFileName(1), []byte(`{"accountID":`), 0644))
+	// temporary files of interrupted writes are not accounts
+	require.NoError(t, os.WriteFile(filepath.Join(AccountsDirectory(c.ledgerFileName), ".account-2.json.tmp-1"), []byte(`{`), 0644))
+
+	_, err := c.GetAllLedgers()
+	assert.Error(t, err)
+	_, err = c.getLedgers(1)
+	assert.Error(t, err)
+
+	accountLedgers, err := c.getLedgers(2)
+	require.NoError(t, err)
+	assert.Empty(t, accountLedgers.Data)
+}
+
+func TestMigrateLedgers(t *testing.T) {
+	c := newPerAccountController(t)
+	data, err := json.Marshal(getDefaultAccountLedgers())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.ledgerFileName, data, 0644))
+
+	migrated, err := c.MigrateLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+	assert.NoFileExists(t, c.ledgerFileName)
+	assert.FileExists(t, c.ledgerFileName+".migrated")
+
+	accountLedgers, err := c.GetAllLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultAccountLedgers(), accountLedgers)
+
+	migrated, err = c.MigrateLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, 0, migrated, "the ledger file is only migrated once")
+
+	// the ledger file storage is not migrated
+	c.ledgerStorage = LedgerStorageSingleFile
+	require.NoError(t, os.WriteFile(c.ledgerFileName, data, 0644))
+	migrated, err = c.MigrateLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+	assert.FileExists(t, c.ledgerFileName)
+}
+
+func TestPerAccountStorageRequests(t *testing.T) {
+	inventoryServer := newInventoryTestServer(t)
+	c := newPerAccountController(t)
+	c.inventoryEndpoint = inventoryServer.URL
+	require.NoError(t, c.saveLedgers(getDefaultAccountLedgers()))
+	otherAccount, err := os.ReadFile(c.accountFileName(1))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer([]byte(`{"accountId":2,"deltaSKUs":[{"sku":"4900002470","delta":-1}]}`)))
+	w := httptest.NewRecorder()
+	c.LedgerAddTransaction(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	written, err := os.ReadFile(c.accountFileName(1))
+	require.NoError(t, err)
+	assert.Equal(t, otherAccount, written, "only the file of the account is written")
+
+	req = httptest.NewRequest("GET", "http://localhost:48093/ledger/2", nil)
+	req = mux.SetURLVars(req, map[string]string{"accountid": "2"})
+	w = httptest.NewRecorder()
+	c.LedgerAccountGet(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	var account Account
+	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&account))
+	assert.Len(t, account.Ledgers, 2)
+
+	req = httptest.NewRequest("POST", "http://localhost:48093/ledger", bytes.NewBuffer([]byte(`{"accountId":3,"deltaSKUs":[{"sku":"4900002470","delta":-1}]}`)))
+	w = httptest.NewRecorder()
+	c.LedgerAddTransaction(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "accounts without a file do not exist")
+	assert.NoFileExists(t, c.accountFileName(3))
+
+	// the export streams every account file
+	stream, err := c.openLedgerStream()
+	require.NoError(t, err)
+	defer stream.Close()
+	var accountIDs []int
+	for {
+		account, ok, err := stream.Next()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		accountIDs = append(accountIDs, account.AccountID)
+	}
+	assert.Equal(t, []int{1, 2}, accountIDs)
+}
+
+func TestRecoverDataPerAccount(t *testing.T) {
+	c := newPerAccountController(t)
+	require.NoError(t, c.saveLedgers(getDefaultAccountLedgers()))
+	require.NoError(t, os.WriteFile(c.accountFileName(2), []byte(`{"accountID":2,"ledgers":[`), 0644))
+	markerName := utilities.MarkerFileName(c.ledgerFileName, "ms-ledger")
+	require.NoError(t, os.WriteFile(markerName, []byte("1"), 0644))
+
+	require.NoError(t, c.RecoverData(markerName))
+	report := c.Recovery()
+	assert.True(t, report.CrashDetected)
+	require.Len(t, report.QuarantinedFiles, 1)
+	assert.Contains(t, report.QuarantinedFiles, c.accountFileName(2))
+
+	// the corrupt account is reset while the other account is kept
+	accountLedgers, err := c.GetAllLedgers()
+	require.NoError(t, err)
+	assert.Equal(t, []Account{getDefaultAccountLedgers().Data[0], {AccountID: 2, Ledgers: []Ledger{}}}, accountLedgers.Data)
+}
@@ -0,0 +1,162 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// agingBuckets are the ages, in days since the transaction, that the unpaid
+// balance is split into. The last bucket has no upper bound.
+var agingBuckets = []struct {
+	label   string
+	maxDays int
+}{
+	{"0-30", 30},
+	{"31-60", 60},
+	{"61+", -1},
+}
+
+// AgingBucket is the part of the unpaid balance whose transactions are the
+// bucket's number of days old
+type AgingBucket struct {
+	Days               string  `json:"days"`
+	UnpaidBalance      float64 `json:"unpaidBalance"`
+	UnpaidBalanceMinor int64   `json:"unpaidBalanceMinor"`
+	UnpaidTransactions int     `json:"unpaidTransactions"`
+}
+
+// TransactionCounts counts the transactions of an account. Voided
+// transactions are neither paid nor unpaid.
+type TransactionCounts struct {
+	Total   int `json:"total"`
+	Paid    int `json:"paid"`
+	Unpaid  int `json:"unpaid"`
+	Voided  int `json:"voided"`
+	Refunds int `json:"refunds"`
+}
+
+// AccountSummary is the account's unpaid balance, aged by when the unpaid
+// transactions were made, with its last payment and transaction counts
+type AccountSummary struct {
+	AccountBalance
+	Aging []AgingBucket `json:"aging"`
+	// LastPaymentAt is the latest time a transaction was paid or a partial
+	// payment was made, none when the account never paid
+	LastPaymentAt     int64             `json:"lastPaymentAt,string,omitempty"`
+	TransactionCounts TransactionCounts `json:"transactionCounts"`
+}
+
+// accountSummary summarizes the account as of now. The aging buckets add up
+// to the unpaid balance of accountBalance.
+func accountSummary(account Account, currency CurrencyConverter, now time.Time) AccountSummary {
+	base := currency.Base()
+	summary := AccountSummary{
+		AccountBalance: accountBalance(account, currency),
+		Aging:          make([]AgingBucket, len(agingBuckets)),
+	}
+	for index, bucket := range agingBuckets {
+		summary.Aging[index].Days = bucket.label
+	}
+
+	for _, ledger := range account.Ledgers {
+		summary.TransactionCounts.Total++
+		if ledger.RefundOf != 0 {
+			summary.TransactionCounts.Refunds++
+		}
+		if ledger.PaidAt > summary.LastPaymentAt {
+			summary.LastPaymentAt = ledger.PaidAt
+		}
+		for _, payment := range ledger.Payments {
+			if payment.Timestamp > summary.LastPaymentAt {
+				summary.LastPaymentAt = payment.Timestamp
+			}
+		}
+
+		switch {
+		case ledger.IsVoided:
+			summary.TransactionCounts.Voided++
+			continue
+		case ledger.IsPaid:
+			summary.TransactionCounts.Paid++
+			continue
+		}
+		summary.TransactionCounts.Unpaid++
+		// the same transactions as the balance, so that the buckets add up
+		if ledger.Currency != "" && !strings.EqualFold(ledger.Currency, base.Code) {
+			continue
+		}
+		bucket := &summary.Aging[agingBucket(now.Sub(time.Unix(0, ledger.TxTimeStamp)))]
+		bucket.UnpaidBalanceMinor = bucket.UnpaidBalanceMinor + ledger.amountDueMinor(base)
+		bucket.UnpaidTransactions++
+	}
+	for index := range summary.Aging {
+		summary.Aging[index].UnpaidBalance = base.FromMinor(summary.Aging[index].UnpaidBalanceMinor)
+	}
+	return summary
+}
+
+// agingBucket returns the index of the aging bucket of a transaction of the
+// age. A transaction from the future, such as with a skewed kiosk clock,
+// is in the first bucket.
+func agingBucket(age time.Duration) int {
+	days := int(age / (24 * time.Hour))
+	for index, bucket := range agingBuckets {
+		if bucket.maxDays < 0 || days <= bucket.maxDays {
+			return index
+		}
+	}
+	return len(agingBuckets) - 1
+}
+
+// AccountSummaryGet returns the summary of the account, computed from its
+// ledger when it is requested, so that collections does not need to export
+// the whole ledger. An account without transactions has an empty summary.
+func (c *Controller) AccountSummaryGet(writer http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	accountID, err := strconv.Atoi(vars["accountid"])
+	if err != nil {
+		errMsg := "accountID contains bad data"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	accountLedgers, err := c.getLedgers(accountID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve all ledgers for accounts %v", err.Error())
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+
+	account := Account{AccountID: accountID}
+	for _, accountLedger := range accountLedgers.Data {
+		if accountLedger.AccountID == accountID {
+			account = accountLedger
+			break
+		}
+	}
+
+	summaryJSON, err := json.Marshal(accountSummary(account, c.currency, time.Now()))
+	if err != nil {
+		errMsg := "Failed to marshal account summary"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(errMsg))
+		return
+	}
+	c.lc.Infof("GET summary of account %d successfully", accountID)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(summaryJSON)
+}
@@ -0,0 +1,126 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountSummary(t *testing.T) {
+	now := time.Date(2023, 6, 30, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) int64 {
+		return now.Add(-time.Duration(days) * 24 * time.Hour).UnixNano()
+	}
+	account := Account{AccountID: 1, Ledgers: []Ledger{
+		{TransactionID: 1, TxTimeStamp: daysAgo(2), Currency: "USD", LineTotalMinor: 199},
+		{TransactionID: 2, TxTimeStamp: daysAgo(30), Currency: "USD", LineTotalMinor: 100},
+		{TransactionID: 3, TxTimeStamp: daysAgo(31), Currency: "USD", LineTotalMinor: 500, Payments: []Payment{{AmountMinor: 200, Timestamp: daysAgo(10)}}},
+		{TransactionID: 4, TxTimeStamp: daysAgo(90), LineTotal: 2.99},
+		{TransactionID: 5, TxTimeStamp: daysAgo(40), Currency: "USD", LineTotalMinor: 300, IsPaid: true, PaidAt: daysAgo(5)},
+		{TransactionID: 6, TxTimeStamp: daysAgo(4), Currency: "USD", LineTotalMinor: -300, IsPaid: true, RefundOf: 5},
+		{TransactionID: 7, TxTimeStamp: daysAgo(70), Currency: "USD", IsVoided: true},
+		{TransactionID: 8, TxTimeStamp: daysAgo(1), Currency: "EUR", LineTotalMinor: 400},
+	}}
+
+	summary := accountSummary(account, CurrencyConverter{}, now)
+	assert.Equal(t, 1, summary.AccountID)
+	assert.Equal(t, int64(898), summary.UnpaidBalanceMinor)
+	assert.Equal(t, []AgingBucket{
+		{Days: "0-30", UnpaidBalance: 2.99, UnpaidBalanceMinor: 299, UnpaidTransactions: 2},
+		{Days: "31-60", UnpaidBalance: 3, UnpaidBalanceMinor: 300, UnpaidTransactions: 1},
+		{Days: "61+", UnpaidBalance: 2.99, UnpaidBalanceMinor: 299, UnpaidTransactions: 1},
+	}, summary.Aging)
+	assert.Equal(t, daysAgo(5), summary.LastPaymentAt)
+	assert.Equal(t, TransactionCounts{Total: 8, Paid: 2, Unpaid: 5, Voided: 1, Refunds: 1}, summary.TransactionCounts)
+
+	var agedMinor int64
+	for _, bucket := range summary.Aging {
+		agedMinor = agedMinor + bucket.UnpaidBalanceMinor
+	}
+	assert.Equal(t, summary.UnpaidBalanceMinor, agedMinor, "the buckets add up to the unpaid balance")
+}
+
+func TestAgingBucket(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Age           time.Duration
+		ExpectedIndex int
+	}{
+		{"future", -time.Hour, 0},
+		{"today", time.Hour, 0},
+		{"30 days", 30*24*time.Hour + time.Hour, 0},
+		{"31 days", 31 * 24 * time.Hour, 1},
+		{"60 days", 60*24*time.Hour + time.Hour, 1},
+		{"61 days", 61 * 24 * time.Hour, 2},
+		{"a year", 365 * 24 * time.Hour, 2},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			assert.Equal(t, currentTest.ExpectedIndex, agingBucket(currentTest.Age))
+		})
+	}
+}
+
+func TestAccountSummaryGet(t *testing.T) {
+	tests := []struct {
+		Name               string
+		InvalidLedger      bool
+		AccountID          string
+		ExpectedStatusCode int
+		ExpectedTotal      int
+	}{
+		{"Account with transactions", false, "2", http.StatusOK, 1},
+		{"Account not in ledger", false, "9", http.StatusOK, 0},
+		{"Invalid account ID", false, "abc", http.StatusBadRequest, 0},
+		{"Invalid Ledger", true, "1", http.StatusInternalServerError, 0},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{
+				lc:             logger.NewMockClient(),
+				ledgerFileName: LedgerFileName,
+			}
+			data := []byte("invalid json test")
+			if !currentTest.InvalidLedger {
+				var err error
+				data, err = json.Marshal(getDefaultAccountLedgers())
+				require.NoError(t, err)
+			}
+			require.NoError(t, os.WriteFile(c.ledgerFileName, data, 0644))
+			defer func() {
+				os.Remove(c.ledgerFileName)
+			}()
+
+			req := httptest.NewRequest("GET", "http://localhost:48093/accounts/"+currentTest.AccountID+"/summary", nil)
+			req = mux.SetURLVars(req, map[string]string{"accountid": currentTest.AccountID})
+			w := httptest.NewRecorder()
+			c.AccountSummaryGet(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, currentTest.ExpectedStatusCode, resp.StatusCode, "invalid status code")
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			var summary AccountSummary
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&summary))
+			assert.Equal(t, currentTest.ExpectedTotal, summary.TransactionCounts.Total)
+			assert.Len(t, summary.Aging, 3)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultTaxCategory is the tax category applied to products that do not
+// have a tax category of their own
+const DefaultTaxCategory = "default"
+
+// TaxTable maps a product tax category to its tax rate, where a rate of
+// 0.08 is 8%
+type TaxTable map[string]float64
+
+// ParseTaxTable parses tax table entries in the "category:rate" format,
+// such as "standard:0.08". A table with any entries must have the rate of
+// the DefaultTaxCategory, which is also the rate of the categories that are
+// missing from it.
+func ParseTaxTable(entries []string) (TaxTable, error) {
+	taxTable := TaxTable{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		category, rateStr, found := strings.Cut(entry, ":")
+		category = strings.TrimSpace(category)
+		if !found || category == "" {
+			return nil, fmt.Errorf("tax rate %q is not in the category:rate format", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil {
+			return nil, fmt.Errorf("tax rate for category %s is not a number: %s", category, err.Error())
+		}
+		if rate < 0 {
+			return nil, fmt.Errorf("tax rate for category %s must not be negative", category)
+		}
+		if _, exists := taxTable[category]; exists {
+			return nil, fmt.Errorf("tax category %s is defined more than once", category)
+		}
+		taxTable[category] = rate
+	}
+	if _, ok := taxTable[DefaultTaxCategory]; len(taxTable) > 0 && !ok {
+		return nil, fmt.Errorf("tax category %s must be defined, as its rate applies to the categories that are not in the table", DefaultTaxCategory)
+	}
+	return taxTable, nil
+}
+
+// Rate returns the tax rate for the given category. Products without a
+// category, and products whose category is missing from the table, use the
+// DefaultTaxCategory rate, and a missing category is reported as not found.
+// Without a tax table nothing is taxed.
+func (taxTable TaxTable) Rate(category string) (float64, bool) {
+	if len(taxTable) == 0 {
+		return 0, true
+	}
+	if category == "" {
+		category = DefaultTaxCategory
+	}
+	if rate, ok := taxTable[category]; ok {
+		return rate, true
+	}
+	return taxTable[DefaultTaxCategory], false
+}
+
+// calculateTax returns the tax for the given amount in minor units,
+// rounded to the nearest minor unit
+func calculateTax(amountMinor int64, rate float64) int64 {
+	return int64(math.Round(float64(amountMinor) * rate))
+}
+
+// calculateTotals sets the tax of every charged line item from its tax
+// rate, and the ledger's subtotal, tax and grand total in minor units.
+// Unavailable and returned items are not charged. The grand total of a
+// ledger with a rounding line is then rounded to the cash denomination.
+func (ledger *Ledger) calculateTotals() {
+	ledger.SubtotalMinor = 0
+	ledger.TaxMinor = 0
+	ledger.LineTotalMinor = 0
+	for i := range ledger.LineItems {
+		lineItem := &ledger.LineItems[i]
+		if lineItem.Unavailable || lineItem.Returned || lineItem.Rounding {
+			continue
+		}
+		amount := lineItem.ItemPriceMinor * int64(lineItem.ItemCount)
+		lineItem.TaxMinor = calculateTax(amount, lineItem.TaxRate)
+		ledger.SubtotalMinor = ledger.SubtotalMinor + amount
+		ledger.TaxMinor = ledger.TaxMinor + lineItem.TaxMinor
+		ledger.LineTotalMinor = ledger.LineTotalMinor + amount + (lineItem.DepositMinor * int64(lineItem.ItemCount)) + lineItem.TaxMinor
+	}
+	ledger.applyRounding()
+}
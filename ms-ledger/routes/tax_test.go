@@ -0,0 +1,67 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTaxTable(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Entries       []string
+		Expected      TaxTable
+		ExpectedError bool
+	}{
+		{"valid table", []string{"default:0.08", " reduced : 0.02", "exempt:0"}, TaxTable{"default": 0.08, "reduced": 0.02, "exempt": 0}, false},
+		{"empty entries are skipped", []string{""}, TaxTable{}, false},
+		{"missing rate", []string{"default"}, nil, true},
+		{"missing category", []string{":0.08"}, nil, true},
+		{"rate is not a number", []string{"default:eight"}, nil, true},
+		{"negative rate", []string{"default:-0.08"}, nil, true},
+		{"duplicate category", []string{"default:0.08", "default:0.02"}, nil, true},
+		{"missing default category", []string{"standard:0.08", "reduced:0.02"}, nil, true},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			taxTable, err := ParseTaxTable(currentTest.Entries)
+			if currentTest.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, taxTable)
+		})
+	}
+}
+
+func TestTaxTableRate(t *testing.T) {
+	taxTable := TaxTable{"default": 0.08, "reduced": 0.02}
+
+	rate, ok := taxTable.Rate("reduced")
+	assert.True(t, ok)
+	assert.Equal(t, 0.02, rate)
+
+	rate, ok = taxTable.Rate("")
+	assert.True(t, ok)
+	assert.Equal(t, 0.08, rate, "products without a category should use the default rate")
+
+	rate, ok = taxTable.Rate("luxury")
+	assert.False(t, ok)
+	assert.Equal(t, 0.08, rate, "categories missing from the table should use the default rate")
+
+	rate, ok = TaxTable{}.Rate("luxury")
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, rate, "nothing is taxed without a tax table")
+}
+
+func TestCalculateTax(t *testing.T) {
+	assert.Equal(t, int64(16), calculateTax(199, 0.08))
+	assert.Equal(t, int64(0), calculateTax(199, 0))
+}
@@ -0,0 +1,37 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTimeZone(t *testing.T) {
+	location, err := LoadTimeZone("")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, location)
+
+	location, err = LoadTimeZone("Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", location.String())
+
+	_, err = LoadTimeZone("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
+
+func TestLedgerLocation(t *testing.T) {
+	chicago, err := LoadTimeZone("America/Chicago")
+	require.NoError(t, err)
+	c := Controller{}
+	assert.Equal(t, time.UTC, c.location(), "without a time zone the kiosk is in UTC")
+
+	c.timeZone = chicago
+	assert.Equal(t, "America/Chicago", c.ledgerLocation(Ledger{}).String(), "transactions without a zone use the kiosk's")
+	assert.Equal(t, "America/Chicago", c.ledgerLocation(Ledger{TimeZone: "Mars/Olympus_Mons"}).String())
+	assert.Equal(t, "Asia/Tokyo", c.ledgerLocation(Ledger{TimeZone: "Asia/Tokyo"}).String(), "transactions keep the zone they were recorded in")
+}
@@ -0,0 +1,74 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+)
+
+// requireMaintainer wraps an administrative route handler to only serve
+// requests with the token of a maintainer card
+func (c *Controller) requireMaintainer(handler http.HandlerFunc) http.HandlerFunc {
+	return c.tokenVerifier.RequireMaintainer(c.lc, handler)
+}
+
+// requireAccount wraps a route handler that reads an account to only serve
+// requests with the token of a card of the account in the URL, or of a
+// maintainer card. Without a token secret the route is left open, like the
+// administrative routes. Preflight requests carry no token and are always
+// served.
+func (c *Controller) requireAccount(handler http.HandlerFunc) http.HandlerFunc {
+	if c.tokenVerifier == nil {
+		return handler
+	}
+	return func(writer http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			handler(writer, req)
+			return
+		}
+		claims, err := c.tokenVerifier.Verify(req.Header.Get("Authorization"))
+		if err != nil {
+			c.lc.Infof("Rejected %s %s: %s", req.Method, req.URL.Path, err.Error())
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writer.WriteHeader(http.StatusUnauthorized)
+			writer.Write([]byte(err.Error()))
+			return
+		}
+		accountID := mux.Vars(req)["accountid"]
+		if !claims.IsMaintainer() && strconv.Itoa(claims.AccountID) != accountID {
+			c.lc.Infof("Rejected %s %s: person %d is not a member of account %s", req.Method, req.URL.Path, claims.PersonID, accountID)
+			writer.WriteHeader(http.StatusForbidden)
+			writer.Write([]byte("a token of the account or of a maintainer is required"))
+			return
+		}
+		handler(writer, req)
+	}
+}
+
+// operatorClaims returns the verified claims of the token of the request,
+// which name the role and person of the operator. Without a token secret no
+// operator can be verified, so the request is refused and false returned.
+func (c *Controller) operatorClaims(writer http.ResponseWriter, req *http.Request) (utilities.AuthClaims, bool) {
+	if c.tokenVerifier == nil {
+		errMsg := "The operator cannot be verified because AuthTokenSecret is not set"
+		c.lc.Error(errMsg)
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write([]byte(errMsg))
+		return utilities.AuthClaims{}, false
+	}
+	claims, err := c.tokenVerifier.Verify(req.Header.Get("Authorization"))
+	if err != nil {
+		errMsg := "Failed to verify the token of the operator"
+		c.lc.Errorf("%s: %s", errMsg, err.Error())
+		writer.Header().Set("WWW-Authenticate", "Bearer")
+		writer.WriteHeader(http.StatusUnauthorized)
+		writer.Write([]byte(errMsg + ": " + err.Error()))
+		return claims, false
+	}
+	return claims, true
+}
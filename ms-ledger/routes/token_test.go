@@ -0,0 +1,122 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
+	"github.com/intel-iot-devkit/automated-checkout-utilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTokenSecret signs the tokens of the tests
+const testTokenSecret = "secret"
+
+// bearerToken returns the Authorization header with a token for the person
+// and role, signed with testTokenSecret
+func bearerToken(personID int, roleID int) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, utilities.AuthClaims{
+		PersonID:       personID,
+		RoleID:         roleID,
+		StandardClaims: jwt.StandardClaims{Issuer: utilities.TokenIssuer, ExpiresAt: time.Now().Add(time.Minute).Unix()},
+	}).SignedString([]byte(testTokenSecret))
+	return "Bearer " + token
+}
+
+// TestRequireMaintainer tests that the administrative routes check the
+// tokens with the TokenVerifier of the controller
+func TestRequireMaintainer(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, utilities.AuthClaims{
+		PersonID:       2,
+		RoleID:         utilities.MaintainerRoleID,
+		StandardClaims: jwt.StandardClaims{Issuer: utilities.TokenIssuer, ExpiresAt: time.Now().Add(time.Minute).Unix()},
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name           string
+		Verifier       *utilities.TokenVerifier
+		Authorization  string
+		ExpectedStatus int
+	}{
+		{"maintainer", utilities.NewTokenVerifier("secret"), "Bearer " + token, http.StatusOK},
+		{"no token", utilities.NewTokenVerifier("secret"), "", http.StatusUnauthorized},
+		{"another secret", utilities.NewTokenVerifier("another"), "Bearer " + token, http.StatusUnauthorized},
+		{"tokens not configured", utilities.NewTokenVerifier(""), "", http.StatusOK},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{lc: logger.NewMockClient(), tokenVerifier: currentTest.Verifier}
+			served := false
+			handler := c.requireMaintainer(func(writer http.ResponseWriter, req *http.Request) {
+				served = true
+			})
+
+			req := httptest.NewRequest(http.MethodDelete, "/ledger/1/1", nil)
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, currentTest.ExpectedStatus, w.Code, w.Body.String())
+			assert.Equal(t, currentTest.ExpectedStatus == http.StatusOK, served)
+		})
+	}
+}
+
+// TestRequireAccount tests that the routes that read an account only serve
+// the tokens of its cards and of maintainers
+func TestRequireAccount(t *testing.T) {
+	accountToken := func(accountID int, roleID int) string {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, utilities.AuthClaims{
+			AccountID:      accountID,
+			PersonID:       1,
+			RoleID:         roleID,
+			StandardClaims: jwt.StandardClaims{Issuer: utilities.TokenIssuer, ExpiresAt: time.Now().Add(time.Minute).Unix()},
+		}).SignedString([]byte(testTokenSecret))
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		Name           string
+		Verifier       *utilities.TokenVerifier
+		Method         string
+		Authorization  string
+		ExpectedStatus int
+	}{
+		{"card of the account", utilities.NewTokenVerifier(testTokenSecret), http.MethodGet, accountToken(1, 1), http.StatusOK},
+		{"card of another account", utilities.NewTokenVerifier(testTokenSecret), http.MethodGet, accountToken(2, 1), http.StatusForbidden},
+		{"maintainer", utilities.NewTokenVerifier(testTokenSecret), http.MethodGet, accountToken(2, utilities.MaintainerRoleID), http.StatusOK},
+		{"no token", utilities.NewTokenVerifier(testTokenSecret), http.MethodGet, "", http.StatusUnauthorized},
+		{"preflight", utilities.NewTokenVerifier(testTokenSecret), http.MethodOptions, "", http.StatusOK},
+		{"tokens not configured", utilities.NewTokenVerifier(""), http.MethodGet, "", http.StatusOK},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := Controller{lc: logger.NewMockClient(), tokenVerifier: currentTest.Verifier}
+			served := false
+			handler := c.requireAccount(func(writer http.ResponseWriter, req *http.Request) {
+				served = true
+			})
+
+			req := mux.SetURLVars(httptest.NewRequest(currentTest.Method, "/ledger/1/balance", nil), map[string]string{"accountid": "1"})
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, currentTest.ExpectedStatus, w.Code, w.Body.String())
+			assert.Equal(t, currentTest.ExpectedStatus == http.StatusOK, served)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package routes
+
+import "github.com/intel-iot-devkit/automated-checkout-utilities"
+
+// versionServiceName is the name of the service in its build
+const versionServiceName = "ms-ledger"
+
+// schemaVersions are the versions of the layouts of the data this service
+// stores, each bumped when its layout changes
+var schemaVersions = map[string]int{
+	"ledger":        1,
+	"overrideAudit": 1,
+	"archive":       1,
+}
+
+// CurrentVersion returns the build of the running service
+func CurrentVersion() utilities.VersionInfo {
+	return utilities.CurrentVersion(versionServiceName, utilities.AppSDKModulePath, schemaVersions)
+}
@@ -0,0 +1,99 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// The actions that cards are authorized for
+const (
+	ActionStock    = "stock"
+	ActionMaintain = "maintain"
+)
+
+// AuthData is the card holder of an authenticated card
+type AuthData struct {
+	AccountID   int     `json:"accountID"`
+	PersonID    int     `json:"personID"`
+	RoleID      int     `json:"roleID"`
+	CardID      string  `json:"cardID"`
+	CreditLimit float64 `json:"creditLimit,omitempty"`
+	// Token is the signed authentication token of the card, when tokens are
+	// configured and the card needs no PIN or its PIN was verified
+	Token       string `json:"token,omitempty"`
+	PinRequired bool   `json:"pinRequired,omitempty"`
+}
+
+// Authorization is whether a card is allowed an action
+type Authorization struct {
+	CardID  string `json:"cardID"`
+	RoleID  int    `json:"roleID"`
+	Action  string `json:"action"`
+	Allowed bool   `json:"allowed"`
+}
+
+// QRCode is a signed, time-limited code that authenticates a card at the
+// kiosk camera
+type QRCode struct {
+	CardID    string `json:"cardID"`
+	Payload   string `json:"payload"`
+	ExpiresAt int64  `json:"expiresAt,string"`
+}
+
+type pinRequest struct {
+	CardID string `json:"cardID"`
+	Pin    string `json:"pin"`
+}
+
+type qrCodeRequest struct {
+	Payload string `json:"payload"`
+}
+
+// AuthenticationClient calls the authentication service. A card is
+// authenticated as a swipe of the card, which counts towards its lockout,
+// so the authentications are not retried.
+type AuthenticationClient struct {
+	r *requester
+}
+
+func (c *AuthenticationClient) url(path string) string {
+	return c.r.options.Endpoints.Authentication + path
+}
+
+// Authenticate returns the card holder of the card
+func (c *AuthenticationClient) Authenticate(ctx context.Context, cardID string) (AuthData, error) {
+	var auth AuthData
+	return auth, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/authentication/" + url.PathEscape(cardID))}, &auth)
+}
+
+// VerifyPin returns the card holder of the card that needs a PIN, with its
+// token, once the PIN is right
+func (c *AuthenticationClient) VerifyPin(ctx context.Context, cardID string, pin string) (AuthData, error) {
+	var auth AuthData
+	return auth, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/authentication/verify-pin"), body: pinRequest{CardID: cardID, Pin: pin}}, &auth)
+}
+
+// AuthenticateQRCode returns the card holder of the card of the QR code
+// payload, which is used up
+func (c *AuthenticationClient) AuthenticateQRCode(ctx context.Context, payload string) (AuthData, error) {
+	var auth AuthData
+	return auth, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/authentication/qr"), body: qrCodeRequest{Payload: payload}}, &auth)
+}
+
+// Authorize returns whether the card is allowed the action, such as
+// ActionStock
+func (c *AuthenticationClient) Authorize(ctx context.Context, cardID string, action string) (Authorization, error) {
+	var authorization Authorization
+	return authorization, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/authorization/" + url.PathEscape(cardID) + "/" + url.PathEscape(action))}, &authorization)
+}
+
+// IssueQRCode issues a QR code for the card. It needs the token of an admin
+// card when tokens are configured.
+func (c *AuthenticationClient) IssueQRCode(ctx context.Context, cardID string) (QRCode, error) {
+	var qrCode QRCode
+	return qrCode, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/cards/" + url.PathEscape(cardID) + "/qrcode")}, &qrCode)
+}
@@ -0,0 +1,32 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// BoardStatus is the state of the controller board. A lock status of 1 is
+// locked.
+type BoardStatus struct {
+	Lock1                int     `json:"lock1_status"`
+	Lock2                int     `json:"lock2_status"`
+	DoorClosed           bool    `json:"door_closed"`
+	Temperature          float64 `json:"temperature"`
+	Humidity             float64 `json:"humidity"`
+	MinTemperatureStatus bool    `json:"minTemperatureStatus"`
+	MaxTemperatureStatus bool    `json:"maxTemperatureStatus"`
+}
+
+// BoardStatusClient calls the controller board status application service
+type BoardStatusClient struct {
+	r *requester
+}
+
+// Status returns the current state of the controller board
+func (c *BoardStatusClient) Status(ctx context.Context) (BoardStatus, error) {
+	var status BoardStatus
+	return status, c.r.do(ctx, request{method: http.MethodGet, url: c.r.options.Endpoints.BoardStatus + "/status", idempotent: true}, &status)
+}
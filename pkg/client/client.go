@@ -0,0 +1,269 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetries is how many times a request that is safe to repeat is
+	// retried by default
+	DefaultRetries = 2
+	// DefaultRetryBackoff is the wait before the first retry, which doubles
+	// for each further retry
+	DefaultRetryBackoff = 200 * time.Millisecond
+	// DefaultTimeout is the timeout of each attempt of a request with the
+	// default HTTP client
+	DefaultTimeout = 15 * time.Second
+)
+
+// Endpoints are the base URLs of the services, such as
+// http://localhost:48093, without a trailing slash
+type Endpoints struct {
+	Ledger         string
+	Inventory      string
+	Authentication string
+	Vending        string
+	BoardStatus    string
+}
+
+// DefaultEndpoints returns the endpoints of the services at their default
+// ports on localhost
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		Ledger:         "http://localhost:48093",
+		Inventory:      "http://localhost:48095",
+		Authentication: "http://localhost:48096",
+		Vending:        "http://localhost:48099",
+		BoardStatus:    "http://localhost:48094",
+	}
+}
+
+// Options configure a Client
+type Options struct {
+	Endpoints Endpoints
+	// Token is sent as the bearer token of every request. The administrative
+	// routes need the token of a maintainer or admin card when the services
+	// share an AuthTokenSecret, and the routes that read an account the
+	// token of a card of the account.
+	Token string
+	// HTTPClient sends the requests, a client with the DefaultTimeout when
+	// nil
+	HTTPClient *http.Client
+	// Retries is how many times a request that is safe to repeat is retried,
+	// zero never retries
+	Retries int
+	// RetryBackoff is the wait before the first retry, DefaultRetryBackoff
+	// when zero
+	RetryBackoff time.Duration
+}
+
+// DefaultOptions returns the options of a client of the services on
+// localhost, which retries the requests that are safe to repeat
+func DefaultOptions() Options {
+	return Options{
+		Endpoints:    DefaultEndpoints(),
+		Retries:      DefaultRetries,
+		RetryBackoff: DefaultRetryBackoff,
+	}
+}
+
+// Client calls the REST APIs of the services. It is safe for concurrent use.
+type Client struct {
+	Ledger         *LedgerClient
+	Inventory      *InventoryClient
+	Authentication *AuthenticationClient
+	Vending        *VendingClient
+	BoardStatus    *BoardStatusClient
+
+	options Options
+}
+
+// New creates a Client with the options
+func New(options Options) *Client {
+	if options.HTTPClient == nil {
+		options.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = DefaultRetryBackoff
+	}
+	for _, endpoint := range []*string{&options.Endpoints.Ledger, &options.Endpoints.Inventory, &options.Endpoints.Authentication, &options.Endpoints.Vending, &options.Endpoints.BoardStatus} {
+		*endpoint = strings.TrimSuffix(*endpoint, "/")
+	}
+
+	r := &requester{options: options}
+	return &Client{
+		Ledger:         &LedgerClient{r: r},
+		Inventory:      &InventoryClient{r: r},
+		Authentication: &AuthenticationClient{r: r},
+		Vending:        &VendingClient{r: r},
+		BoardStatus:    &BoardStatusClient{r: r},
+		options:        options,
+	}
+}
+
+// WithToken returns a copy of the client that sends the token, such as the
+// token of an authenticated maintainer card
+func (c *Client) WithToken(token string) *Client {
+	options := c.options
+	options.Token = token
+	return New(options)
+}
+
+// Error is the response of a service to a request that failed
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Message is the body of the response, the reason the service gave
+	Message string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s %s returned %d: %s", err.Method, err.URL, err.StatusCode, err.Message)
+}
+
+// IsStatus reports whether err is an *Error with the status code, such as
+// http.StatusNotFound for a card, account or product that does not exist
+func IsStatus(err error, statusCode int) bool {
+	var clientErr *Error
+	return errors.As(err, &clientErr) && clientErr.StatusCode == statusCode
+}
+
+// envelope is the content envelope that the original services wrapped their
+// responses in. A JSON content is a string that holds the JSON.
+type envelope struct {
+	Content     json.RawMessage `json:"content"`
+	ContentType string          `json:"contentType"`
+	StatusCode  int             `json:"statusCode"`
+	Error       bool            `json:"error"`
+}
+
+// unwrapEnvelope returns the content of an enveloped response, and the body
+// of any other response
+func unwrapEnvelope(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil || len(fields) != 4 {
+		return body
+	}
+	for _, name := range []string{"content", "contentType", "statusCode", "error"} {
+		if _, ok := fields[name]; !ok {
+			return body
+		}
+	}
+	var response envelope
+	if json.Unmarshal(body, &response) != nil {
+		return body
+	}
+	var content string
+	if json.Unmarshal(response.Content, &content) == nil {
+		return []byte(content)
+	}
+	return response.Content
+}
+
+// requester sends the requests of the service clients
+type requester struct {
+	options Options
+}
+
+// request is a call to a service. Idempotent requests are retried.
+type request struct {
+	method     string
+	url        string
+	body       interface{}
+	idempotent bool
+}
+
+// do sends the request and decodes the response into result, unless result
+// is nil
+func (r *requester) do(ctx context.Context, req request, result interface{}) error {
+	var data []byte
+	if req.body != nil {
+		var err error
+		if data, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("failed to marshal the %s %s request: %s", req.method, req.url, err.Error())
+		}
+	}
+
+	backoff := r.options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, err := r.send(ctx, req, data)
+		if err == nil {
+			if result == nil {
+				return nil
+			}
+			if err := json.Unmarshal(unwrapEnvelope(body), result); err != nil {
+				return fmt.Errorf("%s %s returned an unexpected payload: %s", req.method, req.url, err.Error())
+			}
+			return nil
+		}
+		if !req.idempotent || attempt >= r.options.Retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one attempt of the request and returns the body of a response
+// with status code 200
+func (r *requester) send(ctx context.Context, req request, data []byte) ([]byte, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, req.url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if r.options.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+r.options.Token)
+	}
+
+	resp, err := r.options.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response of %s %s: %s", req.method, req.url, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Method: req.method, URL: req.url, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(unwrapEnvelope(body)))}
+	}
+	return body, nil
+}
+
+// retryable returns whether the request may succeed if it is sent again,
+// after a network error or while the service is unavailable
+func retryable(err error) bool {
+	var clientErr *Error
+	if !errors.As(err, &clientErr) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch clientErr.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client of every service at the test server, which
+// retries quickly
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(Options{
+		Endpoints:    Endpoints{Ledger: server.URL, Inventory: server.URL, Authentication: server.URL + "/", Vending: server.URL, BoardStatus: server.URL},
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	})
+}
+
+func TestClientToken(t *testing.T) {
+	var authorization string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"maintenanceMode":true,"reasons":["doorLeftOpen"]}`))
+	})
+
+	mode, err := c.Vending.MaintenanceMode(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, MaintenanceMode{MaintenanceMode: true, Reasons: []string{"doorLeftOpen"}}, mode)
+	assert.Empty(t, authorization)
+
+	require.NoError(t, c.WithToken("token").Vending.ResetDoorLock(context.Background()))
+	assert.Equal(t, "Bearer token", authorization)
+	_, err = c.Vending.MaintenanceMode(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, authorization, "the original client sends no token")
+}
+
+func TestClientError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/authentication/0003278425", r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Card ID is not a valid card\n"))
+	})
+
+	_, err := c.Authentication.Authenticate(context.Background(), "0003278425")
+	require.Error(t, err)
+	assert.True(t, IsStatus(err, http.StatusUnauthorized))
+	assert.False(t, IsStatus(err, http.StatusNotFound))
+	var clientErr *Error
+	require.ErrorAs(t, err, &clientErr)
+	assert.Equal(t, "Card ID is not a valid card", clientErr.Message)
+	assert.Equal(t, http.MethodGet, clientErr.Method)
+}
+
+func TestClientEnvelope(t *testing.T) {
+	tests := []struct {
+		Name       string
+		StatusCode int
+		Body       string
+	}{
+		{"JSON content", http.StatusOK, `{"content":"{\"accountID\":1,\"roleID\":1,\"cardID\":\"0003278425\"}","contentType":"json","statusCode":200,"error":false}`},
+		{"Plain response", http.StatusOK, `{"accountID":1,"roleID":1,"cardID":"0003278425"}`},
+		{"String error", http.StatusUnauthorized, `{"content":"Card ID is not a valid card","contentType":"string","statusCode":401,"error":true}`},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(currentTest.StatusCode)
+				w.Write([]byte(currentTest.Body))
+			})
+			auth, err := c.Authentication.Authenticate(context.Background(), "0003278425")
+			if currentTest.StatusCode != http.StatusOK {
+				var clientErr *Error
+				require.ErrorAs(t, err, &clientErr)
+				assert.Equal(t, "Card ID is not a valid card", clientErr.Message)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, AuthData{AccountID: 1, RoleID: 1, CardID: "0003278425"}, auth)
+		})
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var attempts int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"lock1_status":1,"door_closed":true}`))
+	})
+
+	status, err := c.BoardStatus.Status(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, BoardStatus{Lock1: 1, DoorClosed: true}, status)
+	assert.Equal(t, int32(3), attempts)
+
+	// the retries run out
+	atomic.StoreInt32(&attempts, -10)
+	_, err = c.BoardStatus.Status(context.Background())
+	assert.True(t, IsStatus(err, http.StatusServiceUnavailable))
+	assert.Equal(t, int32(-7), attempts)
+}
+
+func TestClientDoesNotRetry(t *testing.T) {
+	tests := []struct {
+		Name       string
+		StatusCode int
+		Call       func(c *Client) error
+	}{
+		{"Not safe to repeat", http.StatusServiceUnavailable, func(c *Client) error {
+			_, err := c.Inventory.ApplyDeltas(context.Background(), []InventoryDelta{{SKU: "4900002470", Delta: -1}})
+			return err
+		}},
+		{"Transaction without a session", http.StatusServiceUnavailable, func(c *Client) error {
+			_, err := c.Ledger.AddTransaction(context.Background(), Transaction{AccountID: 1})
+			return err
+		}},
+		{"Card swipe", http.StatusServiceUnavailable, func(c *Client) error {
+			_, err := c.Authentication.Authenticate(context.Background(), "0003278425")
+			return err
+		}},
+		{"Client error", http.StatusBadRequest, func(c *Client) error {
+			_, err := c.Inventory.Products(context.Background())
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			var attempts int32
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(currentTest.StatusCode)
+			})
+			assert.True(t, IsStatus(currentTest.Call(c), currentTest.StatusCode))
+			assert.Equal(t, int32(1), attempts)
+		})
+	}
+}
+
+func TestClientRetryCancelled(t *testing.T) {
+	var attempts int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	c = New(Options{Endpoints: c.options.Endpoints, Retries: 5, RetryBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Vending.StoreState(ctx)
+	assert.True(t, IsStatus(err, http.StatusBadGateway))
+	assert.Equal(t, int32(1), attempts)
+}
+
+// TestLedgerTransactionRetried tests that a basket with a session is
+// retried with the same body, since the ledger charges a session once
+func TestLedgerTransactionRetried(t *testing.T) {
+	var transactions []Transaction
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ledger", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var transaction Transaction
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&transaction))
+		transactions = append(transactions, transaction)
+		if len(transactions) == 1 {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.Write([]byte(`{"transactionID":"1700000000","lineTotal":1.99,"isPaid":false,"lineItems":[{"sku":"4900002470","productName":"Sprite","itemPrice":1.99,"itemCount":1}]}`))
+	})
+
+	transaction := Transaction{AccountID: 1, DeltaSKUs: []DeltaSKU{{SKU: "4900002470", Delta: -1}}, SessionID: "session-1"}
+	ledger, err := c.Ledger.AddTransaction(context.Background(), transaction)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000000), ledger.TransactionID)
+	assert.Equal(t, 1.99, ledger.LineTotal)
+	assert.Equal(t, []Transaction{transaction, transaction}, transactions)
+}
+
+func TestLedgerBasketIntents(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ledger/intents", r.URL.Path)
+		assert.Equal(t, "escalated", r.URL.Query().Get("status"))
+		w.Write([]byte(`{"data":[{"sessionId":"s1","accountId":1,"deltaSKUs":[],"status":"escalated","createdAt":"1700000000"}]}`))
+	})
+
+	intents, err := c.Ledger.BasketIntents(context.Background(), IntentStatusEscalated)
+	require.NoError(t, err)
+	require.Len(t, intents.Data, 1)
+	assert.Equal(t, "s1", intents.Data[0].SessionID)
+	assert.Equal(t, int64(1700000000), intents.Data[0].CreatedAt)
+}
@@ -0,0 +1,21 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package client calls the REST APIs of the Automated Checkout services with
+// typed requests and responses, so that services and integrators do not
+// build the URLs and payloads of each call themselves:
+//
+//	options := client.DefaultOptions()
+//	options.Endpoints.Ledger = "http://ledger.example.com:48093"
+//	c := client.New(options)
+//	auth, err := c.Authentication.Authenticate(ctx, "0003278425")
+//	...
+//	admin := c.WithToken(auth.Token)
+//	err = admin.Ledger.SetPaymentStatus(ctx, 1, transactionID, true)
+//
+// A service that responds with a status code other than 200 returns an
+// *Error with the status code and the service's message. Responses wrapped in
+// the content envelope of the original services are unwrapped. Requests that
+// are safe to repeat are retried after a network error or a 502, 503 or 504
+// status code, up to Options.Retries times.
+package client
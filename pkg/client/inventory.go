@@ -0,0 +1,139 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Product is an item of the inventory
+type Product struct {
+	SKU                string  `json:"sku"`
+	ItemPrice          float64 `json:"itemPrice"`
+	ProductName        string  `json:"productName"`
+	UnitsOnHand        int     `json:"unitsOnHand"`
+	MaxRestockingLevel int     `json:"maxRestockingLevel"`
+	MinRestockingLevel int     `json:"minRestockingLevel"`
+	CreatedAt          int64   `json:"createdAt,string"`
+	UpdatedAt          int64   `json:"updatedAt,string"`
+	IsActive           bool    `json:"isActive"`
+	Category           string  `json:"category,omitempty"`
+	Deposit            float64 `json:"deposit,omitempty"`
+	TaxCategory        string  `json:"taxCategory,omitempty"`
+	Currency           string  `json:"currency,omitempty"`
+	Barcode            string  `json:"barcode,omitempty"`
+	ImageURL           string  `json:"imageURL,omitempty"`
+	Weight             float64 `json:"weight,omitempty"`
+	// Version is the version of the product that an update of it is made
+	// against
+	Version int64 `json:"version"`
+}
+
+// Products is a list of products
+type Products struct {
+	Data []Product `json:"data"`
+}
+
+// The reasons of an inventory delta
+const (
+	DeltaReasonSale       = "sale"
+	DeltaReasonRestock    = "restock"
+	DeltaReasonShrinkage  = "shrinkage"
+	DeltaReasonCorrection = "correction"
+	DeltaReasonSnapshot   = "snapshot"
+)
+
+// InventoryDelta is a change of the units on hand of a SKU, with the reason
+// and the source of the change
+type InventoryDelta struct {
+	SKU    string       `json:"SKU"`
+	Delta  int          `json:"delta"`
+	Reason string       `json:"reason,omitempty"`
+	Source *DeltaSource `json:"source,omitempty"`
+}
+
+// DeltaSource is the service, user and vending session an inventory delta
+// came from
+type DeltaSource struct {
+	Service   string `json:"service,omitempty"`
+	User      string `json:"user,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// DeltaResult is an inventory delta that was applied, with the units on
+// hand before and after it
+type DeltaResult struct {
+	SKU               string  `json:"sku"`
+	Delta             int     `json:"delta"`
+	AppliedDelta      int     `json:"appliedDelta"`
+	UnitsOnHandBefore int     `json:"unitsOnHandBefore"`
+	UnitsOnHandAfter  int     `json:"unitsOnHandAfter"`
+	Discrepancy       bool    `json:"discrepancy,omitempty"`
+	Product           Product `json:"product"`
+}
+
+// AuditLogEntry is the record of the inventory deltas of a vend
+type AuditLogEntry struct {
+	CardID         string           `json:"cardId"`
+	AccountID      int              `json:"accountId"`
+	RoleID         int              `json:"roleId"`
+	PersonID       int              `json:"personId"`
+	InventoryDelta []InventoryDelta `json:"inventoryDelta"`
+	CreatedAt      int64            `json:"createdAt,string"`
+	AuditEntryID   string           `json:"auditEntryId"`
+}
+
+// AuditLog is a list of audit log entries
+type AuditLog struct {
+	Data []AuditLogEntry `json:"data"`
+}
+
+// InventoryClient calls the inventory service
+type InventoryClient struct {
+	r *requester
+}
+
+func (c *InventoryClient) url(path string) string {
+	return c.r.options.Endpoints.Inventory + path
+}
+
+// Products returns the inventory
+func (c *InventoryClient) Products(ctx context.Context) (Products, error) {
+	var products Products
+	return products, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/inventory"), idempotent: true}, &products)
+}
+
+// Product returns the product of the SKU
+func (c *InventoryClient) Product(ctx context.Context, sku string) (Product, error) {
+	var product Product
+	return product, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/inventory/" + url.PathEscape(sku)), idempotent: true}, &product)
+}
+
+// UpdateProducts adds the products, or updates them against their Version.
+// It needs the token of a maintainer card when tokens are configured.
+func (c *InventoryClient) UpdateProducts(ctx context.Context, products []Product) error {
+	return c.r.do(ctx, request{method: http.MethodPost, url: c.url("/inventory"), body: products, idempotent: true}, nil)
+}
+
+// ApplyDeltas changes the units on hand of the SKUs. The deltas are not
+// retried, since each attempt would change the units on hand again.
+func (c *InventoryClient) ApplyDeltas(ctx context.Context, deltas []InventoryDelta) ([]DeltaResult, error) {
+	var results []DeltaResult
+	return results, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/inventory/delta"), body: deltas}, &results)
+}
+
+// AuditLog returns the audit log
+func (c *InventoryClient) AuditLog(ctx context.Context) (AuditLog, error) {
+	var auditLog AuditLog
+	return auditLog, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/auditlog"), idempotent: true}, &auditLog)
+}
+
+// AddAuditLogEntry adds the entry to the audit log, and returns it with its
+// AuditEntryID
+func (c *InventoryClient) AddAuditLogEntry(ctx context.Context, entry AuditLogEntry) (AuditLogEntry, error) {
+	var added AuditLogEntry
+	return added, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/auditlog"), body: entry}, &added)
+}
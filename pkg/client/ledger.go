@@ -0,0 +1,241 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Ledger is a transaction of an account
+type Ledger struct {
+	TransactionID int64      `json:"transactionID,string"`
+	TxTimeStamp   int64      `json:"txTimeStamp,string"`
+	LineTotal     float64    `json:"lineTotal"`
+	CreatedAt     int64      `json:"createdAt,string"`
+	UpdatedAt     int64      `json:"updatedAt,string"`
+	IsPaid        bool       `json:"isPaid"`
+	LineItems     []LineItem `json:"lineItems"`
+	PaidAt        int64      `json:"paidAt,string,omitempty"`
+	RefundOf      int64      `json:"refundOf,string,omitempty"`
+	IsFlagged     bool       `json:"isFlagged,omitempty"`
+	FlagReasons   []string   `json:"flagReasons,omitempty"`
+	// ReviewStatus is pendingReview while a flagged transaction waits for
+	// an admin, and approved, adjusted or voided once it is resolved
+	ReviewStatus string  `json:"reviewStatus,omitempty"`
+	Subtotal     float64 `json:"subtotal,omitempty"`
+	Tax          float64 `json:"tax,omitempty"`
+	// Currency is the ISO 4217 code of the amounts, and the *Minor fields
+	// are the amounts in minor units such as cents
+	Currency       string `json:"currency,omitempty"`
+	LineTotalMinor int64  `json:"lineTotalMinor,omitempty"`
+	SubtotalMinor  int64  `json:"subtotalMinor,omitempty"`
+	TaxMinor       int64  `json:"taxMinor,omitempty"`
+	DisplayTotal   string `json:"displayTotal,omitempty"`
+	SplitID        int64  `json:"splitID,string,omitempty"`
+	SplitRule      string `json:"splitRule,omitempty"`
+	IsTest         bool   `json:"isTest,omitempty"`
+	IsEdited       bool   `json:"isEdited,omitempty"`
+	IsVoided       bool   `json:"isVoided,omitempty"`
+	VoidReason     string `json:"voidReason,omitempty"`
+}
+
+// LineItem is an item of a transaction
+type LineItem struct {
+	SKU             string  `json:"sku"`
+	ProductName     string  `json:"productName"`
+	ItemPrice       float64 `json:"itemPrice"`
+	ItemCount       int     `json:"itemCount"`
+	Unavailable     bool    `json:"unavailable,omitempty"`
+	Deposit         float64 `json:"deposit,omitempty"`
+	ContainerReturn bool    `json:"containerReturn,omitempty"`
+	Returned        bool    `json:"returned,omitempty"`
+	TaxRate         float64 `json:"taxRate,omitempty"`
+	Tax             float64 `json:"tax,omitempty"`
+	ItemPriceMinor  int64   `json:"itemPriceMinor,omitempty"`
+	Discount        bool    `json:"discount,omitempty"`
+}
+
+// Account is the transactions of an account
+type Account struct {
+	AccountID int      `json:"accountID"`
+	Ledgers   []Ledger `json:"ledgers"`
+	Hold      *Hold    `json:"hold,omitempty"`
+}
+
+// Accounts is every account of the ledger
+type Accounts struct {
+	Data []Account `json:"data"`
+}
+
+// Hold is a pre-authorization of an account's stored payment method
+type Hold struct {
+	AuthorizationID string `json:"authorizationID"`
+	AmountMinor     int64  `json:"amountMinor"`
+	Currency        string `json:"currency"`
+	CreatedAt       int64  `json:"createdAt,string"`
+	Status          string `json:"status"`
+}
+
+// PreAuthorization is whether an account needed a hold, and the hold that
+// was placed
+type PreAuthorization struct {
+	Required bool  `json:"required"`
+	Hold     *Hold `json:"hold,omitempty"`
+}
+
+// AccountBalance is the unpaid balance of an account
+type AccountBalance struct {
+	AccountID            int     `json:"accountID"`
+	Currency             string  `json:"currency"`
+	UnpaidBalance        float64 `json:"unpaidBalance"`
+	UnpaidBalanceMinor   int64   `json:"unpaidBalanceMinor"`
+	UnpaidTransactions   int     `json:"unpaidTransactions"`
+	ExcludedTransactions int     `json:"excludedTransactions"`
+}
+
+// DeltaSKU is the change of the units of a SKU in the cooler, negative for
+// the units taken
+type DeltaSKU struct {
+	SKU   string `json:"sku"`
+	Delta int    `json:"delta"`
+}
+
+// Transaction is a basket to charge to an account
+type Transaction struct {
+	AccountID int        `json:"accountId"`
+	DeltaSKUs []DeltaSKU `json:"deltaSKUs"`
+	IsTest    bool       `json:"isTest,omitempty"`
+	// SessionID is the vending session of the basket. A basket with a
+	// session is charged once however often it is sent.
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// SplitTransaction is a basket to split between the accounts by the rule,
+// such as "even"
+type SplitTransaction struct {
+	AccountIDs []int      `json:"accountIds"`
+	Rule       string     `json:"rule"`
+	DeltaSKUs  []DeltaSKU `json:"deltaSKUs"`
+	SessionID  string     `json:"sessionId,omitempty"`
+}
+
+// The statuses of a basket intent
+const (
+	IntentStatusPending   = "pending"
+	IntentStatusSettled   = "settled"
+	IntentStatusEscalated = "escalated"
+)
+
+// BasketIntent is a basket recorded before it is charged, which the ledger
+// service charges itself if it is not charged in time
+type BasketIntent struct {
+	SessionID      string     `json:"sessionId"`
+	AccountID      int        `json:"accountId"`
+	DeltaSKUs      []DeltaSKU `json:"deltaSKUs"`
+	IsTest         bool       `json:"isTest,omitempty"`
+	AccountIDs     []int      `json:"accountIds,omitempty"`
+	SplitRule      string     `json:"splitRule,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	CreatedAt      int64      `json:"createdAt,string,omitempty"`
+	UpdatedAt      int64      `json:"updatedAt,string,omitempty"`
+	TransactionIDs []int64    `json:"transactionIds,omitempty"`
+	Attempts       int        `json:"attempts,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+}
+
+// BasketIntents is a list of basket intents
+type BasketIntents struct {
+	Data []BasketIntent `json:"data"`
+}
+
+// paymentUpdate sets whether a transaction is paid
+type paymentUpdate struct {
+	AccountID     int   `json:"accountID"`
+	TransactionID int64 `json:"transactionID,string"`
+	IsPaid        bool  `json:"isPaid"`
+}
+
+// LedgerClient calls the ledger service
+type LedgerClient struct {
+	r *requester
+}
+
+func (c *LedgerClient) url(path string) string {
+	return c.r.options.Endpoints.Ledger + path
+}
+
+// Accounts returns the transactions of every account
+func (c *LedgerClient) Accounts(ctx context.Context) (Accounts, error) {
+	var accounts Accounts
+	return accounts, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/ledger"), idempotent: true}, &accounts)
+}
+
+// Account returns the transactions of the account
+func (c *LedgerClient) Account(ctx context.Context, accountID int) (Account, error) {
+	var account Account
+	return account, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/ledger/" + strconv.Itoa(accountID)), idempotent: true}, &account)
+}
+
+// Balance returns the unpaid balance of the account
+func (c *LedgerClient) Balance(ctx context.Context, accountID int) (AccountBalance, error) {
+	var balance AccountBalance
+	return balance, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/ledger/" + strconv.Itoa(accountID) + "/balance"), idempotent: true}, &balance)
+}
+
+// AddTransaction charges the basket to the account and returns the
+// transaction. A basket with a SessionID is retried, since it is only
+// charged once.
+func (c *LedgerClient) AddTransaction(ctx context.Context, transaction Transaction) (Ledger, error) {
+	var ledger Ledger
+	return ledger, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/ledger"), body: transaction, idempotent: transaction.SessionID != ""}, &ledger)
+}
+
+// SplitTransaction splits the basket between the accounts and returns the
+// transaction of each account. A basket with a SessionID is retried, since
+// it is only charged once.
+func (c *LedgerClient) SplitTransaction(ctx context.Context, transaction SplitTransaction) ([]Ledger, error) {
+	var ledgers []Ledger
+	return ledgers, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/ledger/split"), body: transaction, idempotent: transaction.SessionID != ""}, &ledgers)
+}
+
+// SetPaymentStatus marks the transaction of the account as paid or unpaid.
+// It needs the token of a maintainer card when tokens are configured.
+func (c *LedgerClient) SetPaymentStatus(ctx context.Context, accountID int, transactionID int64, isPaid bool) error {
+	update := paymentUpdate{AccountID: accountID, TransactionID: transactionID, IsPaid: isPaid}
+	return c.r.do(ctx, request{method: http.MethodPost, url: c.url("/ledgerPaymentUpdate"), body: update, idempotent: true}, nil)
+}
+
+// RecordBasketIntent records the basket of a session before it is charged,
+// replacing the basket recorded for the session before
+func (c *LedgerClient) RecordBasketIntent(ctx context.Context, intent BasketIntent) (BasketIntent, error) {
+	var recorded BasketIntent
+	return recorded, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/ledger/intents"), body: intent, idempotent: true}, &recorded)
+}
+
+// BasketIntents returns the basket intents with the status, or every intent
+// when the status is empty. It needs the token of a maintainer card when
+// tokens are configured.
+func (c *LedgerClient) BasketIntents(ctx context.Context, status string) (BasketIntents, error) {
+	path := "/ledger/intents"
+	if status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+	var intents BasketIntents
+	return intents, c.r.do(ctx, request{method: http.MethodGet, url: c.url(path), idempotent: true}, &intents)
+}
+
+// PreAuthorize places a hold on the stored payment method of the account,
+// when the account needs one
+func (c *LedgerClient) PreAuthorize(ctx context.Context, accountID int) (PreAuthorization, error) {
+	var preAuthorization PreAuthorization
+	return preAuthorization, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/ledger/" + strconv.Itoa(accountID) + "/preauth")}, &preAuthorization)
+}
+
+// ReleaseHold releases the hold of the account that was not captured
+func (c *LedgerClient) ReleaseHold(ctx context.Context, accountID int) error {
+	return c.r.do(ctx, request{method: http.MethodDelete, url: c.url("/ledger/" + strconv.Itoa(accountID) + "/preauth"), idempotent: true}, nil)
+}
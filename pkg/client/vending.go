@@ -0,0 +1,170 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// MaintenanceMode is whether the vending machine is out of service, and the
+// reason codes of why, such as "doorLeftOpen"
+type MaintenanceMode struct {
+	MaintenanceMode bool     `json:"maintenanceMode"`
+	Reasons         []string `json:"reasons,omitempty"`
+}
+
+// StoreState is whether the vending machine is open
+type StoreState struct {
+	KioskID            string   `json:"kioskId,omitempty"`
+	Open               bool     `json:"open"`
+	Reason             string   `json:"reason,omitempty"`
+	ChangedAt          int64    `json:"changedAt,string,omitempty"`
+	MaintenanceMode    bool     `json:"maintenanceMode"`
+	MaintenanceReasons []string `json:"maintenanceReasons,omitempty"`
+}
+
+// StoreStateRequest opens or closes the vending machine for the reason
+type StoreStateRequest struct {
+	Open   bool   `json:"open"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// The stages of a session
+const (
+	SessionIdle        = "idle"
+	SessionAwaitingPin = "awaitingPin"
+	SessionUnlocked    = "unlocked"
+	SessionDoorOpen    = "doorOpen"
+	SessionVerifying   = "verifying"
+	SessionLingering   = "lingering"
+	SessionEnrolling   = "enrolling"
+	SessionMaintenance = "maintenance"
+)
+
+// SessionStatus is the progress of the current session of the vending
+// machine
+type SessionStatus struct {
+	Stage            string     `json:"stage"`
+	SessionID        string     `json:"sessionId,omitempty"`
+	CardID           string     `json:"cardId,omitempty"`
+	AccountID        int        `json:"accountId,omitempty"`
+	RoleID           int        `json:"roleId,omitempty"`
+	TimeoutAt        int64      `json:"timeoutAt,string,omitempty"`
+	RemainingSeconds int        `json:"remainingSeconds"`
+	Items            []DeltaSKU `json:"items"`
+	ItemCount        int        `json:"itemCount"`
+	KioskID          string     `json:"kioskId"`
+}
+
+// WorkflowTransition is a change of the state of the vend workflow, and the
+// event that caused it
+type WorkflowTransition struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Event string `json:"event"`
+	At    int64  `json:"at,string"`
+}
+
+// WorkflowStatus is the state of the vend workflow, such as "doorOpen", when
+// it was entered, and the account of the vend in progress
+type WorkflowStatus struct {
+	State     string `json:"state"`
+	EnteredAt int64  `json:"enteredAt,string,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	AccountID int    `json:"accountId,omitempty"`
+	CardID    string `json:"cardId,omitempty"`
+	RoleID    int    `json:"roleId,omitempty"`
+}
+
+// WorkflowHistory is the most recent transitions of the vend workflow,
+// oldest first
+type WorkflowHistory struct {
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+// WorkflowCancellation is the vend that was cancelled, the items of its
+// session that were not charged, and whether its door was locked and its
+// basket intent voided
+type WorkflowCancellation struct {
+	State          string     `json:"state"`
+	SessionID      string     `json:"sessionId,omitempty"`
+	AccountID      int        `json:"accountId,omitempty"`
+	CardID         string     `json:"cardId,omitempty"`
+	DiscardedItems []DeltaSKU `json:"discardedItems,omitempty"`
+	DoorLocked     bool       `json:"doorLocked"`
+	IntentVoided   bool       `json:"intentVoided"`
+}
+
+type pinEntry struct {
+	Pin string `json:"pin"`
+}
+
+// VendingClient calls the vending application service
+type VendingClient struct {
+	r *requester
+}
+
+func (c *VendingClient) url(path string) string {
+	return c.r.options.Endpoints.Vending + path
+}
+
+// MaintenanceMode returns whether the vending machine is in maintenance mode
+func (c *VendingClient) MaintenanceMode(ctx context.Context) (MaintenanceMode, error) {
+	var mode MaintenanceMode
+	return mode, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/maintenanceMode"), idempotent: true}, &mode)
+}
+
+// ResetDoorLock takes the vending machine out of maintenance mode and resets
+// the vend workflow. It needs the token of a maintainer card when tokens are
+// configured.
+func (c *VendingClient) ResetDoorLock(ctx context.Context) error {
+	return c.r.do(ctx, request{method: http.MethodPost, url: c.url("/resetDoorLock"), idempotent: true}, nil)
+}
+
+// StoreState returns whether the vending machine is open
+func (c *VendingClient) StoreState(ctx context.Context) (StoreState, error) {
+	var state StoreState
+	return state, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/storeState"), idempotent: true}, &state)
+}
+
+// SetStoreState opens or closes the vending machine, and returns the
+// resulting store state. It needs the token of a maintainer card when
+// tokens are configured.
+func (c *VendingClient) SetStoreState(ctx context.Context, state StoreStateRequest) (StoreState, error) {
+	var result StoreState
+	return result, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/storeState"), body: state, idempotent: true}, &result)
+}
+
+// CurrentSession returns the progress of the current session
+func (c *VendingClient) CurrentSession(ctx context.Context) (SessionStatus, error) {
+	var status SessionStatus
+	return status, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/session/current"), idempotent: true}, &status)
+}
+
+// WorkflowState returns the state of the vend workflow
+func (c *VendingClient) WorkflowState(ctx context.Context) (WorkflowStatus, error) {
+	var status WorkflowStatus
+	return status, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/workflow/state"), idempotent: true}, &status)
+}
+
+// WorkflowHistory returns the most recent transitions of the vend workflow
+func (c *VendingClient) WorkflowHistory(ctx context.Context) (WorkflowHistory, error) {
+	var history WorkflowHistory
+	return history, c.r.do(ctx, request{method: http.MethodGet, url: c.url("/workflow/history"), idempotent: true}, &history)
+}
+
+// CancelWorkflow aborts the vend in progress and returns it. It needs the
+// token of a maintainer card when tokens are configured.
+func (c *VendingClient) CancelWorkflow(ctx context.Context) (WorkflowCancellation, error) {
+	var cancellation WorkflowCancellation
+	return cancellation, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/workflow/cancel")}, &cancellation)
+}
+
+// EnterPin enters the PIN of the card waiting for it, and returns the
+// session once the door is unlocked. A wrong PIN is not retried.
+func (c *VendingClient) EnterPin(ctx context.Context, pin string) (SessionStatus, error) {
+	var status SessionStatus
+	return status, c.r.do(ctx, request{method: http.MethodPost, url: c.url("/pin"), body: pinEntry{Pin: pin}}, &status)
+}
@@ -0,0 +1,127 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// MaintainerRoleID is the role of the cards whose tokens may call the
+	// administrative routes
+	MaintainerRoleID = 3
+	// AdminRoleID is the role of the cards that are allowed every action,
+	// including the administrative routes
+	AdminRoleID = 5
+	// TokenIssuer is the service that mints the authentication tokens
+	TokenIssuer = "ms-authentication"
+)
+
+// AuthClaims are the claims of an authentication token minted by
+// ms-authentication, the account, person and role of the card
+type AuthClaims struct {
+	AccountID int `json:"accountID"`
+	PersonID  int `json:"personID"`
+	RoleID    int `json:"roleID"`
+	jwt.StandardClaims
+}
+
+// InfoLogger is the part of a service's logging client that TokenVerifier
+// logs the rejected requests with
+type InfoLogger interface {
+	Infof(msg string, args ...interface{})
+}
+
+// TokenVerifier checks the bearer tokens of requests to the administrative
+// routes with the secret shared with ms-authentication. A nil TokenVerifier
+// leaves the routes open.
+type TokenVerifier struct {
+	secret []byte
+}
+
+// NewTokenVerifier creates a TokenVerifier for the secret, or nil when the
+// secret is empty
+func NewTokenVerifier(secret string) *TokenVerifier {
+	if secret == "" {
+		return nil
+	}
+	return &TokenVerifier{secret: []byte(secret)}
+}
+
+// Verify returns the claims of the bearer token in the Authorization header,
+// and an error when it is missing, not signed with the secret or expired
+func (verifier *TokenVerifier) Verify(authorization string) (AuthClaims, error) {
+	var claims AuthClaims
+	tokenString := strings.TrimPrefix(authorization, "Bearer ")
+	if authorization == "" || tokenString == authorization {
+		return claims, errors.New("a bearer token is required")
+	}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return verifier.secret, nil
+	})
+	if err != nil {
+		return claims, fmt.Errorf("the token is not valid: %s", err.Error())
+	}
+	if claims.ExpiresAt == 0 || claims.Issuer != TokenIssuer {
+		return claims, errors.New("the token is not valid: it has no expiry or another issuer")
+	}
+	return claims, nil
+}
+
+// VerifyMaintainer returns the claims of the token, and an error when it is
+// not valid or not the token of a maintainer or admin card
+func (verifier *TokenVerifier) VerifyMaintainer(token string) (AuthClaims, error) {
+	claims, err := verifier.Verify("Bearer " + token)
+	if err != nil {
+		return claims, err
+	}
+	if !claims.IsMaintainer() {
+		return claims, fmt.Errorf("person %d is not a maintainer", claims.PersonID)
+	}
+	return claims, nil
+}
+
+// IsMaintainer returns whether the claims are those of a maintainer or admin
+// card
+func (claims AuthClaims) IsMaintainer() bool {
+	return claims.RoleID == MaintainerRoleID || claims.RoleID == AdminRoleID
+}
+
+// RequireMaintainer wraps an administrative route handler to only serve
+// requests with the token of a maintainer card, and logs the rejected ones
+// with lc. Preflight requests carry no token and are always served.
+func (verifier *TokenVerifier) RequireMaintainer(lc InfoLogger, handler http.HandlerFunc) http.HandlerFunc {
+	if verifier == nil {
+		return handler
+	}
+	return func(writer http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			handler(writer, req)
+			return
+		}
+		claims, err := verifier.Verify(req.Header.Get("Authorization"))
+		if err != nil {
+			lc.Infof("Rejected %s %s: %s", req.Method, req.URL.Path, err.Error())
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writer.WriteHeader(http.StatusUnauthorized)
+			writer.Write([]byte(err.Error()))
+			return
+		}
+		if !claims.IsMaintainer() {
+			lc.Infof("Rejected %s %s: person %d is not a maintainer", req.Method, req.URL.Path, claims.PersonID)
+			writer.WriteHeader(http.StatusForbidden)
+			writer.Write([]byte("a maintainer token is required"))
+			return
+		}
+		handler(writer, req)
+	}
+}
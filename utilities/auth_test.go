@@ -0,0 +1,114 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogger records the messages logged by RequireMaintainer and
+// FileWriter
+type testLogger struct {
+	messages []string
+}
+
+func (lc *testLogger) Infof(msg string, args ...interface{}) {
+	lc.messages = append(lc.messages, fmt.Sprintf(msg, args...))
+}
+
+func (lc *testLogger) Errorf(msg string, args ...interface{}) {
+	lc.messages = append(lc.messages, fmt.Sprintf(msg, args...))
+}
+
+func signTestToken(t *testing.T, secret string, roleID int, issuer string, expiresAt int64) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, AuthClaims{
+		AccountID: 1,
+		PersonID:  2,
+		RoleID:    roleID,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    issuer,
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: expiresAt,
+		},
+	}).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestRequireMaintainer(t *testing.T) {
+	inAMinute := time.Now().Add(time.Minute).Unix()
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, AuthClaims{
+		RoleID:         MaintainerRoleID,
+		StandardClaims: jwt.StandardClaims{Issuer: TokenIssuer, ExpiresAt: inAMinute},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name           string
+		Verifier       *TokenVerifier
+		Method         string
+		Authorization  string
+		ExpectedStatus int
+	}{
+		{"maintainer", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + signTestToken(t, "secret", MaintainerRoleID, TokenIssuer, inAMinute), http.StatusOK},
+		{"admin", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + signTestToken(t, "secret", AdminRoleID, TokenIssuer, inAMinute), http.StatusOK},
+		{"consumer", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + signTestToken(t, "secret", 1, TokenIssuer, inAMinute), http.StatusForbidden},
+		{"no token", NewTokenVerifier("secret"), http.MethodPost, "", http.StatusUnauthorized},
+		{"not a bearer token", NewTokenVerifier("secret"), http.MethodPost, signTestToken(t, "secret", MaintainerRoleID, TokenIssuer, inAMinute), http.StatusUnauthorized},
+		{"malformed token", NewTokenVerifier("secret"), http.MethodPost, "Bearer not-a-token", http.StatusUnauthorized},
+		{"another secret", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + signTestToken(t, "another", MaintainerRoleID, TokenIssuer, inAMinute), http.StatusUnauthorized},
+		{"expired", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + signTestToken(t, "secret", MaintainerRoleID, TokenIssuer, time.Now().Add(-time.Minute).Unix()), http.StatusUnauthorized},
+		{"no expiry", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + signTestToken(t, "secret", MaintainerRoleID, TokenIssuer, 0), http.StatusUnauthorized},
+		{"another issuer", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + signTestToken(t, "secret", MaintainerRoleID, "as-vending", inAMinute), http.StatusUnauthorized},
+		{"unsigned", NewTokenVerifier("secret"), http.MethodPost, "Bearer " + unsigned, http.StatusUnauthorized},
+		{"preflight", NewTokenVerifier("secret"), http.MethodOptions, "", http.StatusOK},
+		{"tokens not configured", NewTokenVerifier(""), http.MethodPost, "", http.StatusOK},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			lc := &testLogger{}
+			served := false
+			handler := currentTest.Verifier.RequireMaintainer(lc, func(writer http.ResponseWriter, req *http.Request) {
+				served = true
+				writer.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(currentTest.Method, "/ledger/1/1", nil)
+			if currentTest.Authorization != "" {
+				req.Header.Set("Authorization", currentTest.Authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, currentTest.ExpectedStatus, w.Code, w.Body.String())
+			assert.Equal(t, currentTest.ExpectedStatus == http.StatusOK, served)
+			assert.Equal(t, currentTest.ExpectedStatus != http.StatusOK, len(lc.messages) == 1, "rejected requests are logged")
+			if currentTest.ExpectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestVerifyMaintainer(t *testing.T) {
+	inAMinute := time.Now().Add(time.Minute).Unix()
+	verifier := NewTokenVerifier("secret")
+	claims, err := verifier.VerifyMaintainer(signTestToken(t, "secret", MaintainerRoleID, TokenIssuer, inAMinute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, claims.PersonID)
+	_, err = verifier.VerifyMaintainer(signTestToken(t, "secret", AdminRoleID, TokenIssuer, inAMinute))
+	assert.NoError(t, err)
+	_, err = verifier.VerifyMaintainer(signTestToken(t, "secret", 1, TokenIssuer, inAMinute))
+	assert.Error(t, err, "a customer token is not a maintainer token")
+	_, err = verifier.VerifyMaintainer("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxRequestBodySize is the largest request body, in bytes, that is
+// accepted when no MaxRequestBodySize is configured
+const DefaultMaxRequestBodySize int64 = 1 << 20
+
+// DecodeJSONBody decodes the request's JSON body into v as it is read,
+// so chunked bodies work and the body is not buffered first. Bodies over
+// maxBodySize bytes, or DefaultMaxRequestBodySize when it is not positive,
+// and fields that v does not have are rejected. An empty body returns
+// io.EOF. The returned status code is the HTTP status to respond with on
+// error.
+func DecodeJSONBody(writer http.ResponseWriter, req *http.Request, maxBodySize int64, v interface{}) (int, error) {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxRequestBodySize
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, req.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("request body is larger than %d bytes", maxBodySize)
+		}
+		return http.StatusBadRequest, err
+	}
+	// anything after the JSON value means the body is malformed
+	if _, err := decoder.Token(); err != io.EOF {
+		return http.StatusBadRequest, errors.New("request body must contain a single JSON value")
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,58 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeJSONBody(t *testing.T) {
+	type testBody struct {
+		SKU   string `json:"sku"`
+		Count int    `json:"count"`
+	}
+
+	tests := []struct {
+		Name               string
+		Body               string
+		MaxBodySize        int64
+		ExpectedStatusCode int
+		ExpectedEOF        bool
+		Expected           testBody
+	}{
+		{"Valid body", `{"sku":"1200050408","count":2}`, 0, http.StatusOK, false, testBody{SKU: "1200050408", Count: 2}},
+		{"Unknown field", `{"sku":"1200050408","count":2,"price":1.99}`, 0, http.StatusBadRequest, false, testBody{}},
+		{"Malformed body", `{"sku":`, 0, http.StatusBadRequest, false, testBody{}},
+		{"Trailing data", `{"sku":"1200050408","count":2}{}`, 0, http.StatusBadRequest, false, testBody{}},
+		{"Empty body", ``, 0, http.StatusBadRequest, true, testBody{}},
+		{"Body too large", `{"sku":"1200050408","count":2}`, 10, http.StatusRequestEntityTooLarge, false, testBody{}},
+	}
+
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			// a reader without a known length is sent chunked
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/test", io.NopCloser(strings.NewReader(currentTest.Body)))
+			req.ContentLength = -1
+			w := httptest.NewRecorder()
+
+			var decoded testBody
+			statusCode, err := DecodeJSONBody(w, req, currentTest.MaxBodySize, &decoded)
+			assert.Equal(t, currentTest.ExpectedStatusCode, statusCode)
+			assert.Equal(t, currentTest.ExpectedEOF, err == io.EOF)
+			if currentTest.ExpectedStatusCode != http.StatusOK {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, currentTest.Expected, decoded)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataFileName(t *testing.T) {
+	assert.Equal(t, filepath.Join("/data/kiosk-1", "data.json"), DataFileName("/data/kiosk-1", "data.json"))
+	assert.Equal(t, "/tmp/data.json", DataFileName("/data/kiosk-1", "/tmp/data.json"), "absolute names are kept")
+	assert.Equal(t, "data.json", DataFileName("", "data.json"))
+	assert.Equal(t, "", DataFileName("/data/kiosk-1", ""))
+}
+
+func TestValidateDataDirectory(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "kiosk-1")
+	require.NoError(t, ValidateDataDirectory(directory))
+	assert.DirExists(t, directory)
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the write check is removed")
+
+	notDirectory := filepath.Join(t.TempDir(), "data.json")
+	require.NoError(t, os.WriteFile(notDirectory, []byte("{}"), 0644))
+	assert.Error(t, ValidateDataDirectory(notDirectory))
+
+	readOnly := filepath.Join(t.TempDir(), "read-only")
+	require.NoError(t, os.Mkdir(readOnly, 0555))
+	if probe, err := os.CreateTemp(readOnly, "probe"); err == nil {
+		// root can write to any directory
+		_ = probe.Close()
+		t.Skip("the directory permissions are not enforced")
+	}
+	assert.Error(t, ValidateDataDirectory(readOnly))
+	assert.Error(t, ValidateDataDirectory(filepath.Join(readOnly, "kiosk-1")))
+}
@@ -0,0 +1,181 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DurabilityNone leaves flushing written files to disk to the operating
+	// system
+	DurabilityNone = "none"
+	// DurabilityFsyncOnWrite flushes every written file to disk before the
+	// write returns
+	DurabilityFsyncOnWrite = "fsync-on-write"
+	// DurabilityFsyncInterval flushes written files to disk periodically,
+	// so at most one interval of writes can be lost on power loss
+	DurabilityFsyncInterval = "fsync-interval"
+
+	// DefaultFsyncInterval is used when no interval is configured for the
+	// fsync-interval durability
+	DefaultFsyncInterval = time.Second
+)
+
+// ErrorLogger is the part of a service's logging client that FileWriter
+// logs the failed syncs with
+type ErrorLogger interface {
+	Errorf(msg string, args ...interface{})
+}
+
+// FileWriter writes the JSON data files with the configured durability.
+// Files are always replaced by writing a temporary file in the same
+// directory and renaming it over the original, so that a crash mid-write
+// leaves the previous file rather than a partial one. A nil FileWriter
+// writes with DurabilityNone.
+type FileWriter struct {
+	durability string
+	mutex      sync.Mutex
+	// pending holds the files written since the last sync in the
+	// fsync-interval durability
+	pending map[string]bool
+}
+
+// NewFileWriter creates a FileWriter for the durability, which must be one
+// of none, fsync-on-write or fsync-interval
+func NewFileWriter(durability string) (*FileWriter, error) {
+	switch durability {
+	case DurabilityNone, DurabilityFsyncOnWrite, DurabilityFsyncInterval:
+		return &FileWriter{durability: durability, pending: map[string]bool{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown write durability %q, expected %s, %s or %s", durability, DurabilityNone, DurabilityFsyncOnWrite, DurabilityFsyncInterval)
+	}
+}
+
+// Durability returns the durability the files are written with
+func (writer *FileWriter) Durability() string {
+	if writer == nil {
+		return DurabilityNone
+	}
+	return writer.durability
+}
+
+// WriteFile atomically replaces the named file with data
+func (writer *FileWriter) WriteFile(name string, data []byte, perm os.FileMode) error {
+	durability := writer.Durability()
+	dir := filepath.Dir(name)
+
+	tempFile, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempName := tempFile.Name()
+	// the temporary file is removed if anything fails before the rename
+	defer os.Remove(tempName)
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if durability == DurabilityFsyncOnWrite {
+		if err := tempFile.Sync(); err != nil {
+			tempFile.Close()
+			return err
+		}
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tempName, name); err != nil {
+		return err
+	}
+
+	switch durability {
+	case DurabilityFsyncOnWrite:
+		// the rename is only durable once the directory is synced
+		return syncPath(dir)
+	case DurabilityFsyncInterval:
+		writer.mutex.Lock()
+		writer.pending[name] = true
+		writer.mutex.Unlock()
+	}
+	return nil
+}
+
+// Sync flushes the files written since the last sync, and their
+// directories, to disk
+func (writer *FileWriter) Sync() error {
+	if writer == nil {
+		return nil
+	}
+	writer.mutex.Lock()
+	names := make([]string, 0, len(writer.pending))
+	for name := range writer.pending {
+		names = append(names, name)
+	}
+	writer.pending = map[string]bool{}
+	writer.mutex.Unlock()
+	sort.Strings(names)
+
+	var syncErr error
+	dirs := map[string]bool{}
+	for _, name := range names {
+		if err := syncPath(name); err != nil && syncErr == nil {
+			syncErr = err
+		}
+		dirs[filepath.Dir(name)] = true
+	}
+	for dir := range dirs {
+		if err := syncPath(dir); err != nil && syncErr == nil {
+			syncErr = err
+		}
+	}
+	return syncErr
+}
+
+// Run syncs the written files every interval in the fsync-interval
+// durability until the context is cancelled, then syncs a final time. It
+// returns straight away for the other durabilities.
+func (writer *FileWriter) Run(ctx context.Context, interval time.Duration, lc ErrorLogger) {
+	if writer.Durability() != DurabilityFsyncInterval {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultFsyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := writer.Sync(); err != nil {
+				lc.Errorf("Failed to sync data files on shutdown: %s", err.Error())
+			}
+			return
+		case <-ticker.C:
+			if err := writer.Sync(); err != nil {
+				lc.Errorf("Failed to sync data files: %s", err.Error())
+			}
+		}
+	}
+}
+
+// syncPath flushes a file or directory to disk
+func syncPath(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
@@ -0,0 +1,120 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFileWriter(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Durability  string
+		ExpectError bool
+	}{
+		{"None", DurabilityNone, false},
+		{"Fsync on write", DurabilityFsyncOnWrite, false},
+		{"Fsync interval", DurabilityFsyncInterval, false},
+		{"Unknown", "sometimes", true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			writer, err := NewFileWriter(currentTest.Durability)
+			if currentTest.ExpectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, currentTest.Durability, writer.Durability())
+		})
+	}
+}
+
+func TestFileWriterWriteFile(t *testing.T) {
+	nilWriter := (*FileWriter)(nil)
+	noneWriter, _ := NewFileWriter(DurabilityNone)
+	onWriteWriter, _ := NewFileWriter(DurabilityFsyncOnWrite)
+	intervalWriter, _ := NewFileWriter(DurabilityFsyncInterval)
+
+	tests := []struct {
+		Name          string
+		Writer        *FileWriter
+		ExpectPending bool
+	}{
+		{"Nil writer", nilWriter, false},
+		{"None", noneWriter, false},
+		{"Fsync on write", onWriteWriter, false},
+		{"Fsync interval", intervalWriter, true},
+	}
+	for _, test := range tests {
+		currentTest := test
+		t.Run(currentTest.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			fileName := filepath.Join(dir, "data.json")
+			require.NoError(t, os.WriteFile(fileName, []byte(`{"data":[]}`), 0600))
+
+			err := currentTest.Writer.WriteFile(fileName, []byte(`{"data":[{"sku":"4900002470"}]}`), 0644)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(fileName)
+			require.NoError(t, err)
+			assert.Equal(t, `{"data":[{"sku":"4900002470"}]}`, string(data))
+			info, err := os.Stat(fileName)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+			// the temporary file is renamed over the original
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+
+			if currentTest.Writer != nil {
+				assert.Equal(t, currentTest.ExpectPending, currentTest.Writer.pending[fileName])
+			}
+			require.NoError(t, currentTest.Writer.Sync())
+			if currentTest.Writer != nil {
+				assert.Empty(t, currentTest.Writer.pending)
+			}
+		})
+	}
+}
+
+func TestFileWriterWriteFileMissingDirectory(t *testing.T) {
+	writer, err := NewFileWriter(DurabilityFsyncOnWrite)
+	require.NoError(t, err)
+
+	err = writer.WriteFile(filepath.Join(t.TempDir(), "missing", "data.json"), []byte("{}"), 0644)
+	assert.Error(t, err)
+}
+
+func TestFileWriterRun(t *testing.T) {
+	writer, err := NewFileWriter(DurabilityFsyncInterval)
+	require.NoError(t, err)
+	fileName := filepath.Join(t.TempDir(), "data.json")
+	require.NoError(t, writer.WriteFile(fileName, []byte("{}"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		writer.Run(ctx, time.Hour, &testLogger{})
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+	// the pending files are synced on shutdown
+	assert.Empty(t, writer.pending)
+}
@@ -6,6 +6,7 @@ module github.com/intel-iot-devkit/automated-checkout-utilities
 go 1.21
 
 require (
+	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/google/uuid v1.6.0
 	github.com/stretchr/testify v1.9.0
 )
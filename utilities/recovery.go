@@ -0,0 +1,150 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// HealthStatusOK is the health of a service that started cleanly
+	HealthStatusOK = "ok"
+	// HealthStatusRecovered is the health of a service that recovered its
+	// data files from a crash on start
+	HealthStatusRecovered = "recovered"
+)
+
+// DataFile is a JSON data file that is checked on start after a crash
+type DataFile struct {
+	Name string
+	// Validate checks that the file content can be loaded
+	Validate func(data []byte) error
+	// Empty is written in place of a file that cannot be loaded
+	Empty interface{}
+}
+
+// RecoveryReport records the recovery done on start when the previous run
+// of the service did not shut down cleanly
+type RecoveryReport struct {
+	CrashDetected bool  `json:"crashDetected"`
+	RecoveredAt   int64 `json:"recoveredAt,string,omitempty"`
+	// RemovedTempFiles are the leftovers of writes that were interrupted
+	// before they replaced their data file
+	RemovedTempFiles []string `json:"removedTempFiles,omitempty"`
+	// QuarantinedFiles maps each data file that could not be loaded to
+	// the name it was moved to before it was reset
+	QuarantinedFiles map[string]string `json:"quarantinedFiles,omitempty"`
+}
+
+// Health is the response of the health endpoint of a service that
+// recovers its data files on start
+type Health struct {
+	Status   string         `json:"status"`
+	Recovery RecoveryReport `json:"recovery"`
+}
+
+// NewHealth returns the health of a service from the recovery it did on
+// start
+func NewHealth(report RecoveryReport) Health {
+	status := HealthStatusOK
+	if report.CrashDetected {
+		status = HealthStatusRecovered
+	}
+	return Health{Status: status, Recovery: report}
+}
+
+// Recover checks for the running marker left by a previous run that did not
+// shut down cleanly. When it is found, the service boots into safe mode:
+// interrupted writes are cleaned up and every data file is validated, and
+// any file that cannot be loaded is moved aside and reset, before the
+// service serves any traffic. The marker is then written for this run.
+func Recover(markerName string, dataFiles []DataFile, fileWriter *FileWriter, now time.Time) (RecoveryReport, error) {
+	report := RecoveryReport{}
+	_, err := os.Stat(markerName)
+	switch {
+	case err == nil:
+		report.CrashDetected = true
+	case !errors.Is(err, os.ErrNotExist):
+		return report, fmt.Errorf("failed to check the running marker: %s", err.Error())
+	}
+
+	if report.CrashDetected {
+		report.RecoveredAt = now.UnixNano()
+		for _, dataFile := range dataFiles {
+			if err := report.recoverFile(dataFile, fileWriter, now); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	marker := []byte(strconv.Itoa(os.Getpid()) + " " + now.UTC().Format(time.RFC3339))
+	if err := fileWriter.WriteFile(markerName, marker, 0644); err != nil {
+		return report, fmt.Errorf("failed to write the running marker: %s", err.Error())
+	}
+	return report, nil
+}
+
+// recoverFile removes the leftover temporary files of the data file, then
+// quarantines and resets it when it cannot be loaded. A missing data file
+// is left for the service to report as it would without a crash.
+func (report *RecoveryReport) recoverFile(dataFile DataFile, fileWriter *FileWriter, now time.Time) error {
+	tempFiles, err := filepath.Glob(filepath.Join(filepath.Dir(dataFile.Name), "."+filepath.Base(dataFile.Name)+".tmp-*"))
+	if err != nil {
+		return fmt.Errorf("failed to find temporary files of %s: %s", dataFile.Name, err.Error())
+	}
+	for _, tempFile := range tempFiles {
+		if err := os.Remove(tempFile); err != nil {
+			return fmt.Errorf("failed to remove temporary file %s: %s", tempFile, err.Error())
+		}
+		report.RemovedTempFiles = append(report.RemovedTempFiles, tempFile)
+	}
+
+	data, err := os.ReadFile(dataFile.Name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err == nil {
+		if err = dataFile.Validate(data); err == nil {
+			return nil
+		}
+	}
+
+	quarantineName := dataFile.Name + ".corrupt-" + strconv.FormatInt(now.Unix(), 10)
+	if err := os.Rename(dataFile.Name, quarantineName); err != nil {
+		return fmt.Errorf("failed to quarantine %s: %s", dataFile.Name, err.Error())
+	}
+	if report.QuarantinedFiles == nil {
+		report.QuarantinedFiles = map[string]string{}
+	}
+	report.QuarantinedFiles[dataFile.Name] = quarantineName
+
+	data, err = json.Marshal(dataFile.Empty)
+	if err != nil {
+		return fmt.Errorf("failed to marshal empty %s: %s", dataFile.Name, err.Error())
+	}
+	if err := fileWriter.WriteFile(dataFile.Name, data, 0644); err != nil {
+		return fmt.Errorf("failed to reset %s: %s", dataFile.Name, err.Error())
+	}
+	return nil
+}
+
+// MarkStopped removes the running marker on a clean shutdown
+func MarkStopped(markerName string) error {
+	if err := os.Remove(markerName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// MarkerFileName is the running marker of the service, kept next to its
+// data files
+func MarkerFileName(dataFileName string, serviceKey string) string {
+	return filepath.Join(filepath.Dir(dataFileName), "."+serviceKey+".running")
+}
@@ -0,0 +1,57 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var recoveryNow = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	dataFile := DataFile{
+		Name: filepath.Join(dir, "data.json"),
+		Validate: func(data []byte) error {
+			var value map[string]interface{}
+			return json.Unmarshal(data, &value)
+		},
+		Empty: map[string]interface{}{},
+	}
+	markerName := MarkerFileName(dataFile.Name, "test")
+	require.NoError(t, os.WriteFile(markerName, []byte("1"), 0644))
+	require.NoError(t, os.WriteFile(dataFile.Name, []byte("not json"), 0644))
+
+	report, err := Recover(markerName, []DataFile{dataFile}, nil, recoveryNow)
+	require.NoError(t, err)
+	assert.True(t, report.CrashDetected)
+	assert.Equal(t, recoveryNow.UnixNano(), report.RecoveredAt)
+	assert.Equal(t, map[string]string{dataFile.Name: dataFile.Name + ".corrupt-1685577600"}, report.QuarantinedFiles)
+
+	data, err := os.ReadFile(dataFile.Name)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	// a clean shutdown removes the marker, so the next start is not a crash
+	require.NoError(t, MarkStopped(markerName))
+	assert.NoFileExists(t, markerName)
+	require.NoError(t, MarkStopped(markerName), "removing a missing marker should not fail")
+
+	report, err = Recover(markerName, []DataFile{dataFile}, nil, recoveryNow)
+	require.NoError(t, err)
+	assert.False(t, report.CrashDetected)
+}
+
+func TestNewHealth(t *testing.T) {
+	assert.Equal(t, HealthStatusOK, NewHealth(RecoveryReport{}).Status)
+	recovered := RecoveryReport{CrashDetected: true, RecoveredAt: recoveryNow.UnixNano()}
+	assert.Equal(t, Health{Status: HealthStatusRecovered, Recovery: recovered}, NewHealth(recovered))
+}
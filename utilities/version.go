@@ -0,0 +1,123 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version and GitSHA identify the build of the service. They are set when
+// the service is built, with
+// -ldflags "-X github.com/intel-iot-devkit/automated-checkout-utilities.Version=<version> -X github.com/intel-iot-devkit/automated-checkout-utilities.GitSHA=<sha>".
+var (
+	Version = "dev"
+	GitSHA  = "unknown"
+)
+
+const (
+	// AppSDKModulePath is the SDK of the application and microservices
+	AppSDKModulePath = "github.com/edgexfoundry/app-functions-sdk-go/v3"
+	// DeviceSDKModulePath is the SDK of the device services
+	DeviceSDKModulePath = "github.com/edgexfoundry/device-sdk-go/v3"
+)
+
+// VersionInfo is the build of the running service, which fleet inventories
+// compare across kiosks
+type VersionInfo struct {
+	ServiceName    string         `json:"serviceName"`
+	Version        string         `json:"version"`
+	GitSHA         string         `json:"gitSHA"`
+	SDKVersion     string         `json:"sdkVersion"`
+	GoVersion      string         `json:"goVersion"`
+	SchemaVersions map[string]int `json:"schemaVersions"`
+}
+
+// CurrentVersion returns the build of the running service, which is built
+// on the SDK module of sdkModulePath and stores its data in the layouts of
+// schemaVersions, each bumped by the service when its layout changes. The
+// SDK version is read from the build information of the binary.
+func CurrentVersion(serviceName string, sdkModulePath string, schemaVersions map[string]int) VersionInfo {
+	if schemaVersions == nil {
+		schemaVersions = map[string]int{}
+	}
+	info := VersionInfo{
+		ServiceName:    serviceName,
+		Version:        Version,
+		GitSHA:         GitSHA,
+		SDKVersion:     "unknown",
+		GoVersion:      runtime.Version(),
+		SchemaVersions: schemaVersions,
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, dependency := range buildInfo.Deps {
+			if dependency.Path == sdkModulePath {
+				info.SDKVersion = dependency.Version
+			}
+		}
+	}
+	return info
+}
+
+// LogFields returns the build as the key/value pairs of a structured log
+// entry, for the banner logged at startup
+func (info VersionInfo) LogFields() []interface{} {
+	fields := []interface{}{
+		"service", info.ServiceName,
+		"version", info.Version,
+		"gitSHA", info.GitSHA,
+		"sdkVersion", info.SDKVersion,
+		"goVersion", info.GoVersion,
+	}
+	for _, store := range info.stores() {
+		fields = append(fields, store+"SchemaVersion", info.SchemaVersions[store])
+	}
+	return fields
+}
+
+// String returns the build as key=value pairs, for the banner of services
+// that log without a structured logging client
+func (info VersionInfo) String() string {
+	fields := []string{
+		"service=" + info.ServiceName,
+		"version=" + info.Version,
+		"gitSHA=" + info.GitSHA,
+		"sdkVersion=" + info.SDKVersion,
+		"goVersion=" + info.GoVersion,
+	}
+	for _, store := range info.stores() {
+		fields = append(fields, store+"SchemaVersion="+strconv.Itoa(info.SchemaVersions[store]))
+	}
+	return strings.Join(fields, " ")
+}
+
+// stores returns the names of the schema versions in order
+func (info VersionInfo) stores() []string {
+	stores := make([]string, 0, len(info.SchemaVersions))
+	for store := range info.SchemaVersions {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+	return stores
+}
+
+// VersionHandler returns the handler of the /version route of a service,
+// which responds with the build of the running service
+func VersionHandler(info VersionInfo) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		versionJSON, err := json.Marshal(info)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("Failed to marshal version: " + err.Error()))
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(versionJSON)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright © 2023 Intel Corporation. All rights reserved.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package utilities
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrentVersion(t *testing.T) {
+	Version, GitSHA = "1.2.0", "abc1234"
+	defer func() { Version, GitSHA = "dev", "unknown" }()
+
+	info := CurrentVersion("ms-ledger", AppSDKModulePath, map[string]int{"ledger": 2, "archive": 1})
+	assert.Equal(t, "ms-ledger", info.ServiceName)
+	assert.Equal(t, "1.2.0", info.Version)
+	assert.Equal(t, "abc1234", info.GitSHA)
+	assert.Equal(t, "unknown", info.SDKVersion, "the tests are not built with the SDK")
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, 2, info.SchemaVersions["ledger"])
+
+	fields := info.LogFields()
+	assert.Equal(t, 0, len(fields)%2, "the fields are key/value pairs")
+	assert.Equal(t, []interface{}{"archiveSchemaVersion", 1, "ledgerSchemaVersion", 2}, fields[10:], "the schema versions are in order")
+	assert.Equal(t, "service=ms-ledger version=1.2.0 gitSHA=abc1234 sdkVersion=unknown goVersion="+runtime.Version()+" archiveSchemaVersion=1 ledgerSchemaVersion=2", info.String())
+
+	assert.NotNil(t, CurrentVersion("ds-cv-inference", "gocv.io/x/gocv", nil).SchemaVersions, "a service without data has no schema versions")
+}
+
+func TestVersionHandler(t *testing.T) {
+	info := CurrentVersion("ms-inventory", AppSDKModulePath, map[string]int{"inventory": 1})
+
+	w := httptest.NewRecorder()
+	VersionHandler(info)(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var served VersionInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Equal(t, info, served)
+}